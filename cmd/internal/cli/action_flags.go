@@ -19,7 +19,10 @@ var (
 	bindPaths          []string
 	mounts             []string
 	homePath           string
+	homeMode           string
+	mpiMode            string
 	overlayPath        []string
+	overlayPassfile    string
 	scratchPath        []string
 	workdirPath        string
 	cwdPath            string
@@ -27,39 +30,66 @@ var (
 	hostname           string
 	network            string
 	networkArgs        []string
+	networkLimits      []string
 	dns                string
+	dnsSearch          string
+	addHosts           []string
 	security           []string
+	securityOpt        []string
 	cgroupsTOMLFile    string
 	containLibsPath    []string
 	fuseMount          []string
+	dataContainers     []string
+	cvmfsRepos         []string
 	singularityEnv     map[string]string
-	singularityEnvFile string
+	singularityEnvFile []string
 	noMount            []string
 	proot              string
 	device             []string
 	cdiDirs            []string
+	watchHostFiles     string
+	instanceLabels     map[string]string
+	volumePolicy       string
+	seccompProfile     string
+	seccompTrace       string
+	lazy               bool
+	profileIO          string
+	apparmorProfile    string
+	licenseOverride    string
+	recordSessionDir   string
+	coreDir            string
+	ociUser            string
+	signalProxy        []string
 
 	isBoot          bool
 	isFakeroot      bool
 	noSetgroups     bool
 	isCleanEnv      bool
+	noEnv           bool
 	isCompat        bool
 	noCompat        bool
 	isContained     bool
 	isContainAll    bool
 	isWritable      bool
 	isWritableTmpfs bool
+	isReadOnly      bool
 	sifFUSE         bool
+	recordInput     bool
 	nvidia          bool
 	nvCCLI          bool
+	gpus            string
 	rocm            bool
 	noEval          bool
 	noHome          bool
 	noInit          bool
 	noNvidia        bool
 	noRocm          bool
+	intel           bool
+	rdma            bool
 	noUmask         bool
 	disableCache    bool
+	cgroupStats     bool
+	virtualProc     bool
 
 	netNamespace   bool
 	utsNamespace   bool
@@ -85,6 +115,10 @@ var (
 	memorySwap        string // bytes
 	oomKillDisable    bool
 	pidsLimit         int
+
+	tmpSize      uint32 // MiB
+	shmSize      uint32 // MiB
+	writableSize uint32 // MiB
 )
 
 // --app
@@ -104,7 +138,7 @@ var actionBindFlag = cmdline.Flag{
 	DefaultValue: []string{},
 	Name:         "bind",
 	ShortHand:    "B",
-	Usage:        "a user-bind path specification. spec has the format src[:dest[:opts]], where src and dest are outside and inside paths. If dest is not given, it is set equal to src. Mount options ('opts') may be specified as 'ro' (read-only) or 'rw' (read/write, which is the default). Multiple bind paths can be given by a comma separated list.",
+	Usage:        "a user-bind path specification. spec has the format src[:dest[:opts]], where src and dest are outside and inside paths. If dest is not given, it is set equal to src. Mount options ('opts') may be specified as 'ro' (read-only) or 'rw' (read/write, which is the default), and, with --fakeroot in native mode, 'idmap=<uid>[.<gid>]' to map the host user to the container uid and gid. Multiple bind paths can be given by a comma separated list.",
 	EnvKeys:      []string{"BIND", "BINDPATH"},
 	Tag:          "<spec>",
 	EnvHandler:   cmdline.EnvAppendValue,
@@ -116,7 +150,7 @@ var actionMountFlag = cmdline.Flag{
 	Value:        &mounts,
 	DefaultValue: []string{},
 	Name:         "mount",
-	Usage:        "a mount specification e.g. 'type=bind,source=/opt,destination=/hostopt'.",
+	Usage:        "a mount specification e.g. 'type=bind,source=/opt,destination=/hostopt'. Supported types are bind, image, tmpfs, and volume.",
 	EnvKeys:      []string{"MOUNT"},
 	Tag:          "<spec>",
 	EnvHandler:   cmdline.EnvAppendValue,
@@ -135,6 +169,17 @@ var actionHomeFlag = cmdline.Flag{
 	Tag:          "<spec>",
 }
 
+// --home-mode
+var actionHomeModeFlag = cmdline.Flag{
+	ID:           "actionHomeModeFlag",
+	Value:        &homeMode,
+	DefaultValue: "",
+	Name:         "home-mode",
+	Usage:        "how the home directory is provided: 'host' binds it from the host, 'ephemeral' uses an empty tmpfs, 'image' uses the home directory of the image, persisted in its overlay partition (native mode only), and 'none' provides no home directory (default from singularity.conf, or 'host' in native mode, 'ephemeral' in OCI mode)",
+	EnvKeys:      []string{"HOME_MODE"},
+	Tag:          "<mode>",
+}
+
 // -o|--overlay
 var actionOverlayFlag = cmdline.Flag{
 	ID:           "actionOverlayFlag",
@@ -147,6 +192,17 @@ var actionOverlayFlag = cmdline.Flag{
 	Tag:          "<path>",
 }
 
+// --overlay-passfile
+var actionOverlayPassfileFlag = cmdline.Flag{
+	ID:           "actionOverlayPassfileFlag",
+	Value:        &overlayPassfile,
+	DefaultValue: "",
+	Name:         "overlay-passfile",
+	Usage:        "file holding the password of a gocryptfs encrypted overlay directory (OCI mode only)",
+	EnvKeys:      []string{"OVERLAY_PASSFILE"},
+	Tag:          "<path>",
+}
+
 // -S|--scratch
 var actionScratchFlag = cmdline.Flag{
 	ID:           "actionScratchFlag",
@@ -249,6 +305,17 @@ var actionNetworkArgsFlag = cmdline.Flag{
 	Tag:          "<args>",
 }
 
+// --network-limit
+var actionNetworkLimitFlag = cmdline.Flag{
+	ID:           "actionNetworkLimitFlag",
+	Value:        &networkLimits,
+	DefaultValue: []string{},
+	Name:         "network-limit",
+	Usage:        "limit the rate (rate=<rate>, e.g. rate=10mbit) and destinations (egress=<cidr>) of traffic sent from the container network",
+	EnvKeys:      []string{"NETWORK_LIMIT"},
+	Tag:          "<limit>",
+}
+
 // --dns
 var actionDNSFlag = cmdline.Flag{
 	ID:           "actionDnsFlag",
@@ -259,16 +326,156 @@ var actionDNSFlag = cmdline.Flag{
 	EnvKeys:      []string{"DNS"},
 }
 
+// --dns-search
+var actionDNSSearchFlag = cmdline.Flag{
+	ID:           "actionDNSSearchFlag",
+	Value:        &dnsSearch,
+	DefaultValue: "",
+	Name:         "dns-search",
+	Usage:        "list of DNS search domains separated by commas to set in resolv.conf",
+	EnvKeys:      []string{"DNS_SEARCH"},
+}
+
+// --add-host
+var actionAddHostFlag = cmdline.Flag{
+	ID:           "actionAddHostFlag",
+	Value:        &addHosts,
+	DefaultValue: []string{},
+	Name:         "add-host",
+	Usage:        "add a custom host-to-IP mapping (host:ip) to /etc/hosts. Multiple mappings can be given as a comma separated list.",
+	EnvKeys:      []string{"ADD_HOST"},
+	Tag:          "<host:ip>",
+}
+
 // --security
 var actionSecurityFlag = cmdline.Flag{
 	ID:           "actionSecurityFlag",
 	Value:        &security,
 	DefaultValue: []string{},
 	Name:         "security",
-	Usage:        "enable security features (SELinux, Apparmor, Seccomp)",
+	Usage:        "enable security features (SELinux, Apparmor, Seccomp, Landlock)",
 	EnvKeys:      []string{"SECURITY"},
 }
 
+// --security-opt
+var actionSecurityOptFlag = cmdline.Flag{
+	ID:           "actionSecurityOptFlag",
+	Value:        &securityOpt,
+	DefaultValue: []string{},
+	Name:         "security-opt",
+	Usage:        "set a security option for the container (label:<label> applies an SELinux label)",
+	EnvKeys:      []string{"SECURITY_OPT"},
+	Tag:          "<opt>",
+}
+
+// --apparmor-profile
+var actionApparmorProfileFlag = cmdline.Flag{
+	ID:           "actionApparmorProfileFlag",
+	Value:        &apparmorProfile,
+	DefaultValue: "",
+	Name:         "apparmor-profile",
+	Usage:        "confine the container with the specified AppArmor profile",
+	EnvKeys:      []string{"APPARMOR_PROFILE"},
+	Tag:          "<profile>",
+}
+
+// --license-override
+var actionLicenseOverrideFlag = cmdline.Flag{
+	ID:           "actionLicenseOverrideFlag",
+	Value:        &licenseOverride,
+	DefaultValue: "",
+	Name:         "license-override",
+	Usage:        "launch an image with a license you are not entitled to, if permitted, stating the reason recorded in the audit trail",
+	EnvKeys:      []string{"LICENSE_OVERRIDE"},
+	Tag:          "<reason>",
+}
+
+// --seccomp-profile
+var actionSeccompProfileFlag = cmdline.Flag{
+	ID:           "actionSeccompProfileFlag",
+	Value:        &seccompProfile,
+	DefaultValue: "",
+	Name:         "seccomp-profile",
+	Usage:        "apply the seccomp profile in the specified JSON file to the container",
+	EnvKeys:      []string{"SECCOMP_PROFILE"},
+	Tag:          "<path>",
+}
+
+// --seccomp-trace
+var actionSeccompTraceFlag = cmdline.Flag{
+	ID:           "actionSeccompTraceFlag",
+	Value:        &seccompTrace,
+	DefaultValue: "",
+	Name:         "seccomp-trace",
+	Usage:        "(--oci mode) record the syscalls made by the container, and write a seccomp profile allowing only those syscalls to the specified JSON file",
+	EnvKeys:      []string{"SECCOMP_TRACE"},
+	Tag:          "<path>",
+}
+
+// --lazy
+var actionLazyFlag = cmdline.Flag{
+	ID:           "actionLazyFlag",
+	Value:        &lazy,
+	DefaultValue: false,
+	Name:         "lazy",
+	Usage:        "(--oci mode) experimental: run docker:// eStargz images without pulling them, fetching files on demand with stargz-store",
+	EnvKeys:      []string{"LAZY"},
+}
+
+// --profile-io
+var actionProfileIOFlag = cmdline.Flag{
+	ID:           "actionProfileIOFlag",
+	Value:        &profileIO,
+	DefaultValue: "",
+	Name:         "profile-io",
+	Usage:        "(--oci mode, root only) record the image files opened by the container, and write a profile of them, in order of first access, to the specified JSON file",
+	EnvKeys:      []string{"PROFILE_IO"},
+	Tag:          "<path>",
+}
+
+// --core-dir
+var actionCoreDirFlag = cmdline.Flag{
+	ID:           "actionCoreDirFlag",
+	Value:        &coreDir,
+	DefaultValue: "",
+	Name:         "core-dir",
+	Usage:        "collect core dumps of crashed container processes, with metadata describing the crash, into the specified host directory",
+	EnvKeys:      []string{"CORE_DIR"},
+	Tag:          "<path>",
+}
+
+// --signal-proxy
+var actionSignalProxyFlag = cmdline.Flag{
+	ID:           "actionSignalProxyFlag",
+	Value:        &signalProxy,
+	DefaultValue: []string{},
+	Name:         "signal-proxy",
+	Usage:        "signals (e.g. USR1,USR2) always relayed to the container process, even in the foreground of a terminal, or 'all'",
+	EnvKeys:      []string{"SIGNAL_PROXY"},
+	Tag:          "<signals>",
+}
+
+// --record-session
+var actionRecordSessionFlag = cmdline.Flag{
+	ID:           "actionRecordSessionFlag",
+	Value:        &recordSessionDir,
+	DefaultValue: "",
+	Name:         "record-session",
+	Usage:        "record a typescript of the interactive session, with the user and image digest, to the specified directory",
+	EnvKeys:      []string{"RECORD_SESSION"},
+	Tag:          "<dir>",
+}
+
+// --record-keystrokes
+var actionRecordKeystrokesFlag = cmdline.Flag{
+	ID:           "actionRecordKeystrokesFlag",
+	Value:        &recordInput,
+	DefaultValue: false,
+	Name:         "record-keystrokes",
+	Usage:        "record the input, as well as the output, of a session recorded with --record-session",
+	EnvKeys:      []string{"RECORD_KEYSTROKES"},
+}
+
 // --apply-cgroups
 var actionApplyCgroupsFlag = cmdline.Flag{
 	ID:           "actionApplyCgroupsFlag",
@@ -279,6 +486,26 @@ var actionApplyCgroupsFlag = cmdline.Flag{
 	EnvKeys:      []string{"APPLY_CGROUPS"},
 }
 
+// --cgroup-stats
+var actionCgroupStatsFlag = cmdline.Flag{
+	ID:           "actionCgroupStatsFlag",
+	Value:        &cgroupStats,
+	DefaultValue: false,
+	Name:         "cgroup-stats",
+	Usage:        "bind the container cgroup read-only at /.singularity.d/cgroup, exposing its limits and usage in the container",
+	EnvKeys:      []string{"CGROUP_STATS"},
+}
+
+// --virtual-proc
+var actionVirtualProcFlag = cmdline.Flag{
+	ID:           "actionVirtualProcFlag",
+	Value:        &virtualProc,
+	DefaultValue: false,
+	Name:         "virtual-proc",
+	Usage:        "show the container's CPU and memory limits in /proc/cpuinfo, /proc/meminfo etc. (uses LXCFS if available)",
+	EnvKeys:      []string{"VIRTUAL_PROC"},
+}
+
 // hidden flag to handle SINGULARITY_CONTAINLIBS environment variable
 var actionContainLibsFlag = cmdline.Flag{
 	ID:           "actionContainLibsFlag",
@@ -299,6 +526,29 @@ var actionFuseMountFlag = cmdline.Flag{
 	EnvKeys:      []string{"FUSESPEC"},
 }
 
+// --data
+var actionDataFlag = cmdline.Flag{
+	ID:           "actionDataFlag",
+	Value:        &dataContainers,
+	DefaultValue: []string{},
+	Name:         "data",
+	Usage:        "(--oci mode) mount the data in a data container, created with 'singularity data create', at a path in the container. Specified as <data container path>:<container path>",
+	Tag:          "<spec>",
+	EnvKeys:      []string{"DATA"},
+	StringArray:  true,
+}
+
+// --cvmfs
+var actionCVMFSFlag = cmdline.Flag{
+	ID:           "actionCVMFSFlag",
+	Value:        &cvmfsRepos,
+	DefaultValue: []string{},
+	Name:         "cvmfs",
+	Usage:        "bind the listed CVMFS repositories, from /cvmfs on the host, read-only into the container",
+	Tag:          "<repo,...>",
+	EnvKeys:      []string{"CVMFS"},
+}
+
 // hidden flag to handle SINGULARITY_TMPDIR environment variable
 var actionTmpDirFlag = cmdline.Flag{
 	ID:           "actionTmpDirFlag",
@@ -320,6 +570,25 @@ var actionBootFlag = cmdline.Flag{
 	EnvKeys:      []string{"BOOT"},
 }
 
+// --watch-host-files
+var actionWatchHostFilesFlag = cmdline.Flag{
+	ID:           "actionWatchHostFilesFlag",
+	Value:        &watchHostFiles,
+	DefaultValue: "",
+	Name:         "watch-host-files",
+	Usage:        "watch the host's /etc/resolv.conf, /etc/hosts and /etc/passwd for changes while the instance runs, and 'warn' or 'refresh' the container copies",
+	EnvKeys:      []string{"WATCH_HOST_FILES"},
+}
+
+// --label
+var actionInstanceLabelFlag = cmdline.Flag{
+	ID:           "actionInstanceLabelFlag",
+	Value:        &instanceLabels,
+	DefaultValue: map[string]string{},
+	Name:         "label",
+	Usage:        "set a key=value label on the instance, used to select it with 'instance list/stop --filter label=key[=value]'",
+}
+
 // -f|--fakeroot
 var actionFakerootFlag = cmdline.Flag{
 	ID:           "actionFakerootFlag",
@@ -331,6 +600,16 @@ var actionFakerootFlag = cmdline.Flag{
 	EnvKeys:      []string{"FAKEROOT"},
 }
 
+// --oci-user
+var actionOCIUserFlag = cmdline.Flag{
+	ID:           "actionOCIUserFlag",
+	Value:        &ociUser,
+	DefaultValue: "",
+	Name:         "oci-user",
+	Usage:        "run as user[:group] in OCI mode, overriding the USER of the image (names are resolved from the container /etc/passwd and /etc/group)",
+	EnvKeys:      []string{"OCI_USER"},
+}
+
 // --no-setgroups
 var actionNoSetgroupsFlag = cmdline.Flag{
 	ID:           "actionNoSetgroupsFlag",
@@ -352,6 +631,16 @@ var actionCleanEnvFlag = cmdline.Flag{
 	EnvKeys:      []string{"CLEANENV"},
 }
 
+// --no-env
+var actionNoEnvFlag = cmdline.Flag{
+	ID:           "actionNoEnvFlag",
+	Value:        &noEnv,
+	DefaultValue: false,
+	Name:         "no-env",
+	Usage:        "run container with a minimal environment, ignoring host and SINGULARITYENV_ variables",
+	EnvKeys:      []string{"NO_ENV"},
+}
+
 // --compat
 var actionCompatFlag = cmdline.Flag{
 	ID:           "actionCompatFlag",
@@ -372,6 +661,17 @@ var actionNoCompatFlag = cmdline.Flag{
 	EnvKeys:      []string{"NO_COMPAT"},
 }
 
+// --volume-policy
+var actionVolumePolicyFlag = cmdline.Flag{
+	ID:           "actionVolumePolicyFlag",
+	Value:        &volumePolicy,
+	DefaultValue: "",
+	Name:         "volume-policy",
+	Usage:        "(--oci mode) how to provide VOLUMEs declared by the image: tmpfs, persistent, or none. Defaults to 'oci volumes' in singularity.conf.",
+	EnvKeys:      []string{"VOLUME_POLICY"},
+	Tag:          "<policy>",
+}
+
 // -c|--contain
 var actionContainFlag = cmdline.Flag{
 	ID:           "actionContainFlag",
@@ -414,6 +714,16 @@ var actionNvCCLIFlag = cmdline.Flag{
 	EnvKeys:      []string{"NVCCLI"},
 }
 
+// --gpus
+var actionGPUsFlag = cmdline.Flag{
+	ID:           "actionGPUsFlag",
+	Value:        &gpus,
+	DefaultValue: "",
+	Name:         "gpus",
+	Usage:        "NVIDIA GPUs to make available with --nv: 'all', or a comma separated list of GPU indexes, UUIDs, and MIG devices as mig:<gpu>:<mig>",
+	EnvKeys:      []string{"GPUS"},
+}
+
 // --rocm flag to automatically bind
 var actionRocmFlag = cmdline.Flag{
 	ID:           "actionRocmFlag",
@@ -424,6 +734,37 @@ var actionRocmFlag = cmdline.Flag{
 	EnvKeys:      []string{"ROCM"},
 }
 
+// --intel flag to automatically bind
+var actionIntelFlag = cmdline.Flag{
+	ID:           "actionIntelFlag",
+	Value:        &intel,
+	DefaultValue: false,
+	Name:         "intel",
+	Usage:        "enable experimental Intel GPU support",
+	EnvKeys:      []string{"INTEL"},
+}
+
+// --rdma flag to automatically bind
+var actionRdmaFlag = cmdline.Flag{
+	ID:           "actionRdmaFlag",
+	Value:        &rdma,
+	DefaultValue: false,
+	Name:         "rdma",
+	Usage:        "enable InfiniBand / RDMA support, binding devices and the libraries listed in rdmaliblist.conf",
+	EnvKeys:      []string{"RDMA"},
+}
+
+// --mpi
+var actionMPIFlag = cmdline.Flag{
+	ID:           "actionMPIFlag",
+	Value:        &mpiMode,
+	DefaultValue: "",
+	Name:         "mpi",
+	Usage:        "connect MPI applications to the host process manager, binding the PMI libraries listed in <mode>liblist.conf and the PMI sockets: 'pmix' or 'pmi2'",
+	EnvKeys:      []string{"MPI"},
+	Tag:          "<mode>",
+}
+
 // -w|--writable
 var actionWritableFlag = cmdline.Flag{
 	ID:           "actionWritableFlag",
@@ -445,6 +786,46 @@ var actionWritableTmpfsFlag = cmdline.Flag{
 	EnvKeys:      []string{"WRITABLE_TMPFS"},
 }
 
+// --read-only
+var actionReadOnlyFlag = cmdline.Flag{
+	ID:           "actionReadOnlyFlag",
+	Value:        &isReadOnly,
+	DefaultValue: false,
+	Name:         "read-only",
+	Usage:        "run the container with a read-only root file system, without the writable tmpfs that --oci and --compat provide by default. Equivalent to --writable-tmpfs=false.",
+	EnvKeys:      []string{"READ_ONLY"},
+}
+
+// --writable-tmpfs-size
+var actionWritableTmpfsSizeFlag = cmdline.Flag{
+	ID:           "actionWritableTmpfsSizeFlag",
+	Value:        &writableSize,
+	DefaultValue: uint32(0),
+	Name:         "writable-tmpfs-size",
+	Usage:        "size, in MiB, of the tmpfs holding the changes made with --writable-tmpfs (default from singularity.conf)",
+	EnvKeys:      []string{"WRITABLE_TMPFS_SIZE"},
+}
+
+// --tmp-size
+var actionTmpSizeFlag = cmdline.Flag{
+	ID:           "actionTmpSizeFlag",
+	Value:        &tmpSize,
+	DefaultValue: uint32(0),
+	Name:         "tmp-size",
+	Usage:        "size, in MiB, of the tmpfs mounted on /tmp and /var/tmp when they are not shared with the host (default from singularity.conf)",
+	EnvKeys:      []string{"TMP_SIZE"},
+}
+
+// --shm-size
+var actionShmSizeFlag = cmdline.Flag{
+	ID:           "actionShmSizeFlag",
+	Value:        &shmSize,
+	DefaultValue: uint32(0),
+	Name:         "shm-size",
+	Usage:        "size, in MiB, of the tmpfs mounted on /dev/shm when /dev is not shared with the host (default from singularity.conf)",
+	EnvKeys:      []string{"SHM_SIZE"},
+}
+
 // --no-home
 var actionNoHomeFlag = cmdline.Flag{
 	ID:           "actionNoHomeFlag",
@@ -461,7 +842,7 @@ var actionNoMountFlag = cmdline.Flag{
 	Value:        &noMount,
 	DefaultValue: []string{},
 	Name:         "no-mount",
-	Usage:        "disable one or more 'mount xxx' options set in singularity.conf, specify absolute destination path to disable a bind path entry, or 'bind-paths' to disable all bind path entries.",
+	Usage:        "disable one or more 'mount xxx' options set in singularity.conf (proc, sys, dev, devpts, home, tmp, hostfs, cwd), specify absolute destination path to disable a bind path entry or a generated /etc/passwd, /etc/group, /etc/resolv.conf, or 'bind-paths' to disable all bind path entries.",
 	EnvKeys:      []string{"NO_MOUNT"},
 }
 
@@ -622,9 +1003,9 @@ var actionEnvFlag = cmdline.Flag{
 var actionEnvFileFlag = cmdline.Flag{
 	ID:           "actionEnvFileFlag",
 	Value:        &singularityEnvFile,
-	DefaultValue: "",
+	DefaultValue: []string{},
 	Name:         "env-file",
-	Usage:        "pass environment variables from file to contained process",
+	Usage:        "pass environment variables from file to contained process (can be specified multiple times, later files take precedence)",
 	EnvKeys:      []string{"ENV_FILE"},
 }
 
@@ -810,6 +1191,8 @@ func init() {
 		if instanceStartCmd != nil {
 			cmdManager.SetCmdGroup("actions_instance", ExecCmd, ShellCmd, RunCmd, TestCmd, instanceStartCmd)
 			cmdManager.RegisterFlagForCmd(&actionBootFlag, instanceStartCmd)
+			cmdManager.RegisterFlagForCmd(&actionWatchHostFilesFlag, instanceStartCmd)
+			cmdManager.RegisterFlagForCmd(&actionInstanceLabelFlag, instanceStartCmd)
 		} else {
 			cmdManager.SetCmdGroup("actions_instance", actionsCmd...)
 		}
@@ -819,26 +1202,37 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionAllowSetuidFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionAppFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionApplyCgroupsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionCgroupStatsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionVirtualProcFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionBindFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionCleanEnvFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionNoEnvFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionCompatFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoCompatFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionVolumePolicyFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionContainAllFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionContainFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionContainLibsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionDisableCacheFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionDNSFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionDNSSearchFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionAddHostFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionDropCapsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionFakerootFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionOCIUserFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoSetgroupsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionFuseMountFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionDataFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionCVMFSFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionHomeFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionHomeModeFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionHostnameFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionIpcNamespaceFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionKeepPrivsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionMountFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNetNamespaceFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNetworkArgsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionNetworkLimitFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNetworkFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoHomeFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoMountFlag, actionsInstanceCmd...)
@@ -848,16 +1242,27 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionNoPrivsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNvidiaFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNvCCLIFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionGPUsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionRocmFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionIntelFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionRdmaFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionMPIFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionOverlayFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionOverlayPassfileFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&commonPromptForPassphraseFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&commonPEMFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&commonKeyFileFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&commonKeyURIFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionPidNamespaceFlag, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoPidNamespaceFlag, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&actionCwdFlag, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&actionPwdFlag, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&actionScratchFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionSecurityFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionSeccompProfileFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionSecurityOptFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionApparmorProfileFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionLicenseOverrideFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionShellFlag, ShellCmd)
 		cmdManager.RegisterFlagForCmd(&actionTmpDirFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionUserNamespaceFlag, actionsInstanceCmd...)
@@ -865,6 +1270,10 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionWorkdirFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionWritableFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionWritableTmpfsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionReadOnlyFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionWritableTmpfsSizeFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionTmpSizeFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionShmSizeFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&commonNoHTTPSFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&commonOldNoHTTPSFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, actionsInstanceCmd...)
@@ -895,5 +1304,12 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionDevice, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&actionCdiDirs, actionsCmd...)
+		cmdManager.RegisterFlagForCmd(&actionSeccompTraceFlag, actionsCmd...)
+		cmdManager.RegisterFlagForCmd(&actionProfileIOFlag, actionsCmd...)
+		cmdManager.RegisterFlagForCmd(&actionLazyFlag, actionsCmd...)
+		cmdManager.RegisterFlagForCmd(&actionCoreDirFlag, actionsCmd...)
+		cmdManager.RegisterFlagForCmd(&actionSignalProxyFlag, actionsCmd...)
+		cmdManager.RegisterFlagForCmd(&actionRecordSessionFlag, ExecCmd, ShellCmd)
+		cmdManager.RegisterFlagForCmd(&actionRecordKeystrokesFlag, ExecCmd, ShellCmd)
 	})
 }
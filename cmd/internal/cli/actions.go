@@ -17,16 +17,17 @@ import (
 	"github.com/sylabs/singularity/v4/docs"
 	"github.com/sylabs/singularity/v4/internal/pkg/cache"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/library"
-	"github.com/sylabs/singularity/v4/internal/pkg/client/net"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/oci"
 	ocisifclient "github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
-	"github.com/sylabs/singularity/v4/internal/pkg/client/oras"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/shub"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/variant"
 	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential/ociauth"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher/native"
 	ocilauncher "github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher/oci"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/v4/pkg/image"
 	bndocisif "github.com/sylabs/singularity/v4/pkg/ocibundle/ocisif"
@@ -59,6 +60,7 @@ const (
 // actionPreRun will:
 //   - do the proper path unsetting;
 //   - and implement flag inferences for:
+//     --writable-tmpfs=false
 //     --compat
 //     --hostname
 //   - run replaceURIWithImage;
@@ -69,6 +71,21 @@ func actionPreRun(cmd *cobra.Command, args []string) {
 	userPath := strings.Join([]string{os.Getenv("PATH"), defaultPath}, ":")
 	os.Setenv("USER_PATH", userPath)
 
+	// In OCI and compat modes, which provide a writable tmpfs by default, an
+	// explicit --writable-tmpfs=false requests a read-only rootfs, as
+	// --read-only.
+	if isOCI || isCompat {
+		if f := cmd.Flags().Lookup("writable-tmpfs"); f != nil && f.Changed && !isWritableTmpfs {
+			isReadOnly = true
+		}
+	}
+	if isReadOnly && isWritableTmpfs {
+		sylog.Fatalf("Cannot use --read-only with --writable-tmpfs: incompatible options")
+	}
+	if isReadOnly && isWritable {
+		sylog.Fatalf("Cannot use --read-only with --writable: incompatible options")
+	}
+
 	// --compat infers other options that give increased OCI / Docker compatibility
 	// Excludes uts/user/net namespaces as these are restrictive for many Singularity
 	// installs.
@@ -77,7 +94,7 @@ func actionPreRun(cmd *cobra.Command, args []string) {
 			sylog.Fatalf("Cannot use --no-compat with --compat: incompatible options")
 		}
 		isContainAll = true
-		isWritableTmpfs = true
+		isWritableTmpfs = !isReadOnly
 		noInit = true
 		noUmask = true
 		noEval = true
@@ -111,14 +128,6 @@ func handleOCI(ctx context.Context, imgCache *cache.Handle, cmd *cobra.Command,
 	return oci.Pull(ctx, imgCache, pullFrom, pullOpts)
 }
 
-func handleOras(ctx context.Context, imgCache *cache.Handle, cmd *cobra.Command, pullFrom string) (string, error) {
-	ociAuth, err := makeOCICredentials(cmd)
-	if err != nil {
-		return "", fmt.Errorf("while creating docker credentials: %v", err)
-	}
-	return oras.Pull(ctx, imgCache, pullFrom, tmpDir, ociAuth, reqAuthFile)
-}
-
 func handleLibrary(ctx context.Context, imgCache *cache.Handle, pullFrom string) (string, error) {
 	r, err := library.NormalizeLibraryRef(pullFrom)
 	if err != nil {
@@ -156,13 +165,27 @@ func handleShub(ctx context.Context, imgCache *cache.Handle, pullFrom string) (s
 	return shub.Pull(ctx, imgCache, pullFrom, tmpDir, noHTTPS)
 }
 
-func handleNet(ctx context.Context, imgCache *cache.Handle, pullFrom string) (string, error) {
-	return net.Pull(ctx, imgCache, pullFrom, tmpDir)
+func handleTransport(ctx context.Context, imgCache *cache.Handle, cmd *cobra.Command, tr transport.Transport, pullFrom string) (string, error) {
+	opts, err := transportOptions(cmd)
+	if err != nil {
+		return "", err
+	}
+	return tr.Fetch(ctx, imgCache, pullFrom, "", opts)
 }
 
 func replaceURIWithImage(ctx context.Context, cmd *cobra.Command, args []string) string {
 	origImageURI := args[0]
-	t, _ := uri.Split(origImageURI)
+	t, ref := uri.Split(origImageURI)
+	// A variants manifest selects the image, which may itself be a URI, to run
+	// on this node.
+	if t == uri.Variants {
+		image, err := variant.SelectImage(strings.TrimPrefix(ref, "//"))
+		if err != nil {
+			sylog.Fatalf("While selecting image variant from %s: %v", origImageURI, err)
+		}
+		args[0] = image
+		return replaceURIWithImage(ctx, cmd, args)
+	}
 	// If joining an instance (instance://xxx), or we have a bare filename then
 	// no retrieval / conversion is required.
 	if t == "instance" || t == "" {
@@ -181,18 +204,16 @@ func replaceURIWithImage(ctx context.Context, cmd *cobra.Command, args []string)
 	switch t {
 	case uri.Library:
 		image, err = handleLibrary(ctx, imgCache, origImageURI)
-	case uri.Oras:
-		image, err = handleOras(ctx, imgCache, cmd, origImageURI)
 	case uri.Shub:
 		image, err = handleShub(ctx, imgCache, origImageURI)
 	case ocitransport.SupportedTransport(t):
 		image, err = handleOCI(ctx, imgCache, cmd, origImageURI)
-	case uri.HTTP:
-		image, err = handleNet(ctx, imgCache, origImageURI)
-	case uri.HTTPS:
-		image, err = handleNet(ctx, imgCache, origImageURI)
 	default:
-		sylog.Fatalf("Unsupported transport type: %s", t)
+		tr, ok := transport.Lookup(t)
+		if !ok {
+			sylog.Fatalf("Unsupported transport type: %s", t)
+		}
+		image, err = handleTransport(ctx, imgCache, cmd, tr, origImageURI)
 	}
 
 	// If we are in OCI mode, then we can still attempt to run from a directory
@@ -315,6 +336,10 @@ var TestCmd = &cobra.Command{
 }
 
 func launchContainer(cmd *cobra.Command, ep launcher.ExecParams) error {
+	if err := recordSession(ep.Image); err != nil {
+		return err
+	}
+
 	ns := launcher.Namespaces{
 		User:  userNamespace,
 		UTS:   utsNamespace,
@@ -333,15 +358,32 @@ func launchContainer(cmd *cobra.Command, ep launcher.ExecParams) error {
 		sylog.Warningf("Resource limits & cgroups configuration are only applied to instances at instance start.")
 	}
 
+	if fs.IsFile(ep.Image) {
+		if err := checkImageAdvisory(ep.Image, ep.Image); err != nil {
+			return err
+		}
+	}
+
 	ki, err := getEncryptionMaterial(cmd)
 	if err != nil {
 		return err
 	}
 
+	selinuxLabel, err := parseSecurityOpt(securityOpt)
+	if err != nil {
+		return err
+	}
+
 	opts := []launcher.Option{
 		launcher.OptWritable(isWritable),
+		launcher.OptLicenseOverride(licenseOverride),
 		launcher.OptWritableTmpfs(isWritableTmpfs),
+		launcher.OptReadOnly(isReadOnly),
+		launcher.OptWritableTmpfsSize(uint(writableSize)),
+		launcher.OptTmpSize(uint(tmpSize)),
+		launcher.OptShmSize(uint(shmSize)),
 		launcher.OptOverlayPaths(overlayPath),
+		launcher.OptOverlayPassfile(overlayPassfile),
 		launcher.OptScratchDirs(scratchPath),
 		launcher.OptWorkDir(workdirPath),
 		launcher.OptHome(
@@ -349,20 +391,31 @@ func launchContainer(cmd *cobra.Command, ep launcher.ExecParams) error {
 			cmd.Flag(actionHomeFlag.Name).Changed,
 			noHome,
 		),
+		launcher.OptHomeMode(homeMode),
 		launcher.OptMounts(bindPaths, mounts, fuseMount),
 		launcher.OptNoMount(noMount),
+		launcher.OptDataContainers(dataContainers),
+		launcher.OptCVMFS(cvmfsRepos),
 		launcher.OptNvidia(nvidia, nvCCLI),
 		launcher.OptNoNvidia(noNvidia),
+		launcher.OptGPUs(gpus),
 		launcher.OptRocm(rocm),
 		launcher.OptNoRocm(noRocm),
+		launcher.OptIntel(intel),
+		launcher.OptRdma(rdma),
+		launcher.OptMPI(mpiMode),
 		launcher.OptContainLibs(containLibsPath),
 		launcher.OptProot(proot),
 		launcher.OptEnv(singularityEnv, singularityEnvFile, isCleanEnv),
+		launcher.OptNoEnv(noEnv),
 		launcher.OptNoEval(noEval),
 		launcher.OptNamespaces(ns),
 		launcher.OptNetwork(network, networkArgs),
+		launcher.OptNetworkLimits(networkLimits),
 		launcher.OptHostname(hostname),
 		launcher.OptDNS(dns),
+		launcher.OptDNSSearch(dnsSearch),
+		launcher.OptAddHosts(addHosts),
 		launcher.OptCaps(addCaps, dropCaps),
 		launcher.OptAllowSUID(allowSUID),
 		launcher.OptKeepPrivs(keepPrivs),
@@ -370,12 +423,17 @@ func launchContainer(cmd *cobra.Command, ep launcher.ExecParams) error {
 		launcher.OptSecurity(security),
 		launcher.OptNoUmask(noUmask),
 		launcher.OptCgroupsJSON(cgJSON),
+		launcher.OptCgroupStats(cgroupStats),
+		launcher.OptVirtualProc(virtualProc),
 		launcher.OptConfigFile(configurationFile),
 		launcher.OptShellPath(shellPath),
 		launcher.OptCwdPath(cwdPath),
 		launcher.OptFakeroot(isFakeroot),
+		launcher.OptOCIUser(ociUser),
 		launcher.OptNoSetgroups(noSetgroups),
 		launcher.OptBoot(isBoot),
+		launcher.OptWatchHostFiles(watchHostFiles),
+		launcher.OptInstanceLabels(instanceLabels),
 		launcher.OptNoInit(noInit),
 		launcher.OptContain(isContained),
 		launcher.OptContainAll(isContainAll),
@@ -386,6 +444,15 @@ func launchContainer(cmd *cobra.Command, ep launcher.ExecParams) error {
 		launcher.OptDevice(device),
 		launcher.OptCdiDirs(cdiDirs),
 		launcher.OptNoCompat(noCompat),
+		launcher.OptVolumePolicy(volumePolicy),
+		launcher.OptSeccompProfile(seccompProfile),
+		launcher.OptSeccompTrace(seccompTrace),
+		launcher.OptLazy(lazy),
+		launcher.OptProfileIO(profileIO),
+		launcher.OptCoreDir(coreDir),
+		launcher.OptSignalProxy(signalProxy),
+		launcher.OptApparmorProfile(apparmorProfile),
+		launcher.OptSelinuxLabel(selinuxLabel),
 		launcher.OptNoTmpSandbox(noTmpSandbox),
 	}
 
@@ -457,3 +524,21 @@ func launchContainer(cmd *cobra.Command, ep launcher.ExecParams) error {
 
 	return l.Exec(cmd.Context(), ep)
 }
+
+// parseSecurityOpt parses the --security-opt values, returning the SELinux
+// label set with label:<label>.
+func parseSecurityOpt(opts []string) (selinuxLabel string, err error) {
+	for _, opt := range opts {
+		key, value, ok := strings.Cut(opt, ":")
+		if !ok || value == "" {
+			return "", fmt.Errorf("invalid --security-opt %q, format is <option>:<value>", opt)
+		}
+		switch key {
+		case "label":
+			selinuxLabel = value
+		default:
+			return "", fmt.Errorf("unsupported --security-opt %q", opt)
+		}
+	}
+	return selinuxLabel, nil
+}
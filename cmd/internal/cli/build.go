@@ -11,6 +11,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"syscall"
 
@@ -29,6 +30,7 @@ import (
 
 var buildArgs struct {
 	sections        []string
+	onlyPaths       []string
 	bindPaths       []string
 	mounts          []string
 	arch            string
@@ -40,9 +42,10 @@ var buildArgs struct {
 	encrypt         bool
 	fakeroot        bool
 	fixPerms        bool
-	isJSON          bool
 	noCleanUp       bool
 	noTest          bool
+	runTests        bool
+	testReport      string // JUnit XML report of --run-tests.
 	noSetgroups     bool
 	remote          bool
 	sandbox         bool
@@ -53,6 +56,19 @@ var buildArgs struct {
 	writableTmpfs   bool     // For test section only
 	buildVarArgs    []string // Variables passed to build procedure.
 	buildVarArgFile string   // Variables file passed to build procedure.
+	printArgs       bool     // Print resolved build args before the build.
+	strictArgs      bool     // Fail when build args are not used by the definition.
+	buildkitMetrics string   // Address for buildkitd metrics and healthz endpoints.
+	buildkitHost    string   // Address of a remote buildkitd daemon.
+	buildkitCACert  string   // CA certificate of the remote buildkitd daemon.
+	buildkitCert    string   // Client certificate for the remote buildkitd daemon.
+	buildkitKey     string   // Client key for the remote buildkitd daemon.
+	buildkitServer  string   // Server name of the remote buildkitd daemon.
+	network         string   // Network policy of %pre, %setup and %post.
+	networkArgs     []string // Arguments of the CNI plugins of the build network.
+	reproducible    bool     // Build bit-identical images from the same inputs.
+	primeCache      bool     // Generate /etc/ld.so.cache in the image.
+	accessProfile   string   // Paths accessed first, stored first in the image.
 }
 
 // -s|--sandbox
@@ -66,6 +82,16 @@ var buildSandboxFlag = cmdline.Flag{
 	EnvKeys:      []string{"SANDBOX"},
 }
 
+// --only
+var buildOnlyFlag = cmdline.Flag{
+	ID:           "buildOnlyFlag",
+	Value:        &buildArgs.onlyPaths,
+	DefaultValue: []string{},
+	Name:         "only",
+	Usage:        "only extract the given paths from a SIF or OCI-SIF image source into a sandbox, e.g. --only /opt,/usr/local",
+	Tag:          "<path>",
+}
+
 // --section
 var buildSectionFlag = cmdline.Flag{
 	ID:           "buildSectionFlag",
@@ -76,16 +102,6 @@ var buildSectionFlag = cmdline.Flag{
 	EnvKeys:      []string{"SECTION"},
 }
 
-// --json
-var buildJSONFlag = cmdline.Flag{
-	ID:           "buildJSONFlag",
-	Value:        &buildArgs.isJSON,
-	DefaultValue: false,
-	Name:         "json",
-	Usage:        "interpret build definition as JSON",
-	EnvKeys:      []string{"JSON"},
-}
-
 // -u|--update
 var buildUpdateFlag = cmdline.Flag{
 	ID:           "buildUpdateFlag",
@@ -108,6 +124,26 @@ var buildNoTestFlag = cmdline.Flag{
 	EnvKeys:      []string{"NOTEST"},
 }
 
+// --run-tests
+var buildRunTestsFlag = cmdline.Flag{
+	ID:           "buildRunTestsFlag",
+	Value:        &buildArgs.runTests,
+	DefaultValue: false,
+	Name:         "run-tests",
+	Usage:        "run tests in %test section in the built container, rather than before it is assembled, failing the build if they fail",
+	EnvKeys:      []string{"RUN_TESTS"},
+}
+
+// --test-report
+var buildTestReportFlag = cmdline.Flag{
+	ID:           "buildTestReportFlag",
+	Value:        &buildArgs.testReport,
+	DefaultValue: "",
+	Name:         "test-report",
+	Usage:        "write the result of tests run with --run-tests to this path, as a JUnit XML report (implies --run-tests)",
+	EnvKeys:      []string{"TEST_REPORT"},
+}
+
 // -r|--remote
 var buildRemoteFlag = cmdline.Flag{
 	ID:           "buildRemoteFlag",
@@ -306,7 +342,134 @@ var buildVarArgFileFlag = cmdline.Flag{
 	Value:        &buildArgs.buildVarArgFile,
 	DefaultValue: "",
 	Name:         "build-arg-file",
-	Usage:        "specifies a file containing variable=value lines to replace '{{ variable }}' with value in build definition files",
+	Usage:        "specifies a file containing variable=value lines, or a JSON/YAML object (.json, .yaml, .yml), to replace '{{ variable }}' with value in build definition files",
+}
+
+// --print-args
+var buildPrintArgsFlag = cmdline.Flag{
+	ID:           "buildPrintArgsFlag",
+	Value:        &buildArgs.printArgs,
+	DefaultValue: false,
+	Name:         "print-args",
+	Usage:        "print the resolved value and source of each build arg before the build starts",
+}
+
+// --strict-build-args
+var buildStrictArgsFlag = cmdline.Flag{
+	ID:           "buildStrictArgsFlag",
+	Value:        &buildArgs.strictArgs,
+	DefaultValue: false,
+	Name:         "strict-build-args",
+	Usage:        "fail if a build arg is not used by the build definition file, instead of warning",
+}
+
+// --network
+var buildNetworkFlag = cmdline.Flag{
+	ID:           "buildNetworkFlag",
+	Value:        &buildArgs.network,
+	DefaultValue: "",
+	Name:         "network",
+	Usage:        "network of %pre, %setup and %post: host (default), none, or a comma separated list of CNI networks (%post only)",
+	EnvKeys:      []string{"BUILD_NETWORK"},
+}
+
+// --network-args
+var buildNetworkArgsFlag = cmdline.Flag{
+	ID:           "buildNetworkArgsFlag",
+	Value:        &buildArgs.networkArgs,
+	DefaultValue: []string{},
+	Name:         "network-args",
+	Usage:        "specify network arguments to pass to CNI plugins of the build network",
+	EnvKeys:      []string{"BUILD_NETWORK_ARGS"},
+}
+
+// --reproducible
+var buildReproducibleFlag = cmdline.Flag{
+	ID:           "buildReproducibleFlag",
+	Value:        &buildArgs.reproducible,
+	DefaultValue: false,
+	Name:         "reproducible",
+	Usage:        "build a bit-identical image from the same inputs, with timestamps set to SOURCE_DATE_EPOCH (default 0)",
+	EnvKeys:      []string{"REPRODUCIBLE"},
+}
+
+// --prime-cache
+var buildPrimeCacheFlag = cmdline.Flag{
+	ID:           "buildPrimeCacheFlag",
+	Value:        &buildArgs.primeCache,
+	DefaultValue: false,
+	Name:         "prime-cache",
+	Usage:        "generate /etc/ld.so.cache in the image with its ldconfig, and a cache of the commands on its PATH, after the %post section",
+	EnvKeys:      []string{"PRIME_CACHE"},
+}
+
+// --access-profile
+var buildAccessProfileFlag = cmdline.Flag{
+	ID:           "buildAccessProfileFlag",
+	Value:        &buildArgs.accessProfile,
+	DefaultValue: "",
+	Name:         "access-profile",
+	Usage:        "file listing the paths in the image accessed first when it runs, one per line, to store first in a SIF image",
+	EnvKeys:      []string{"ACCESS_PROFILE"},
+}
+
+// --buildkit-metrics
+var buildBuildkitMetricsFlag = cmdline.Flag{
+	ID:           "buildBuildkitMetricsFlag",
+	Value:        &buildArgs.buildkitMetrics,
+	DefaultValue: "",
+	Name:         "buildkit-metrics",
+	Usage:        "serve metrics and healthz endpoints of the buildkitd daemon at this localhost host:port address (OCI-mode Dockerfile builds only)",
+	EnvKeys:      []string{"BUILDKIT_METRICS"},
+}
+
+// --buildkit-host
+var buildBuildkitHostFlag = cmdline.Flag{
+	ID:           "buildBuildkitHostFlag",
+	Value:        &buildArgs.buildkitHost,
+	DefaultValue: "",
+	Name:         "buildkit-host",
+	Usage:        "perform a remote build with the buildkitd daemon at this address (e.g. tcp://builder.example.com:1234), translating definition files to Dockerfiles (implies --remote)",
+	EnvKeys:      []string{"BUILDKIT_HOST"},
+}
+
+// --buildkit-ca-cert
+var buildBuildkitCACertFlag = cmdline.Flag{
+	ID:           "buildBuildkitCACertFlag",
+	Value:        &buildArgs.buildkitCACert,
+	DefaultValue: "",
+	Name:         "buildkit-ca-cert",
+	Usage:        "path to the CA certificate used to verify the TLS certificate of the remote buildkitd daemon",
+	EnvKeys:      []string{"BUILDKIT_CA_CERT"},
+}
+
+// --buildkit-cert
+var buildBuildkitCertFlag = cmdline.Flag{
+	ID:           "buildBuildkitCertFlag",
+	Value:        &buildArgs.buildkitCert,
+	DefaultValue: "",
+	Name:         "buildkit-cert",
+	Usage:        "path to the client certificate used for mutual TLS authentication with the remote buildkitd daemon",
+	EnvKeys:      []string{"BUILDKIT_CERT"},
+}
+
+// --buildkit-key
+var buildBuildkitKeyFlag = cmdline.Flag{
+	ID:           "buildBuildkitKeyFlag",
+	Value:        &buildArgs.buildkitKey,
+	DefaultValue: "",
+	Name:         "buildkit-key",
+	Usage:        "path to the client key used for mutual TLS authentication with the remote buildkitd daemon",
+	EnvKeys:      []string{"BUILDKIT_KEY"},
+}
+
+// --buildkit-server-name
+var buildBuildkitServerNameFlag = cmdline.Flag{
+	ID:           "buildBuildkitServerNameFlag",
+	Value:        &buildArgs.buildkitServer,
+	DefaultValue: "",
+	Name:         "buildkit-server-name",
+	Usage:        "server name used to verify the TLS certificate of the remote buildkitd daemon (default: host name of --buildkit-host)",
 }
 
 func init() {
@@ -321,10 +484,13 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&buildFakerootFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildNoSetgroupsFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildFixPermsFlag, buildCmd)
-		cmdManager.RegisterFlagForCmd(&buildJSONFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&commonResultJSONFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildLibraryFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildNoCleanupFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildNoTestFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildRunTestsFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildTestReportFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildOnlyFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildRemoteFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildSandboxFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildSectionFlag, buildCmd)
@@ -340,6 +506,8 @@ func init() {
 
 		cmdManager.RegisterFlagForCmd(&commonPromptForPassphraseFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&commonPEMFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&commonKeyFileFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&commonKeyURIFlag, buildCmd)
 
 		cmdManager.RegisterFlagForCmd(&buildNvFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildNvCCLIFlag, buildCmd)
@@ -349,12 +517,25 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&buildWritableTmpfsFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildVarArgsFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildVarArgFileFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildPrintArgsFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildStrictArgsFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildNetworkFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildNetworkArgsFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildReproducibleFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildPrimeCacheFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildAccessProfileFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildBuildkitHostFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildBuildkitCACertFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildBuildkitCertFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildBuildkitKeyFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildBuildkitServerNameFlag, buildCmd)
 
 		cmdManager.RegisterFlagForCmd(&commonOCIFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&commonNoOCIFlag, buildCmd)
 
 		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&commonKeepLayersFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildBuildkitMetricsFlag, buildCmd)
 	})
 }
 
@@ -372,13 +553,27 @@ var buildCmd = &cobra.Command{
 	TraverseChildren: true,
 }
 
-func preRun(cmd *cobra.Command, _ []string) {
+func preRun(cmd *cobra.Command, args []string) {
+	// Always perform remote build when a remote buildkitd daemon is set
+	if buildArgs.buildkitHost != "" {
+		cmd.Flags().Lookup("remote").Value.Set("true")
+	}
+
 	if isOCI {
+		if buildArgs.remote && buildArgs.buildkitHost == "" {
+			sylog.Fatalf("Remote OCI builds require a buildkitd daemon, specified with --buildkit-host.")
+		}
 		if buildArgs.remote {
-			sylog.Fatalf("Remote OCI builds from Dockerfiles are not supported.")
+			return
 		}
 
-		return
+		// definition files are built natively into an OCI-SIF
+		if !isDefinitionFile(args[1]) {
+			return
+		}
+		if buildArgs.sandbox {
+			sylog.Fatalf("--sandbox option is not supported for OCI builds from definition files")
+		}
 	}
 
 	if buildArgs.noSetgroups && !buildArgs.fakeroot {
@@ -464,6 +659,38 @@ func checkBuildTarget(path string) error {
 	return nil
 }
 
+// absAccessProfile returns the absolute path of the access profile at path,
+// which must be a file.
+func absAccessProfile(path string) string {
+	if !fs.IsFile(path) {
+		sylog.Fatalf("Access profile %s is not a file", path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		sylog.Fatalf("While resolving access profile path: %v", err)
+	}
+	return abs
+}
+
+// isDefinitionFile returns true if spec is a definition file, with a
+// bootstrap header in its first stage, rather than a Dockerfile.
+func isDefinitionFile(spec string) bool {
+	if !fs.IsFile(spec) {
+		return false
+	}
+	f, err := os.Open(spec)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	defs, err := parser.All(f)
+	if err != nil || len(defs) == 0 {
+		return false
+	}
+	return defs[0].Header["bootstrap"] != ""
+}
+
 // definitionFromSpec is specifically for parsing specs for the remote builder
 // it uses a different version the definition struct and parser
 func definitionFromSpec(spec string) (types.Definition, error) {
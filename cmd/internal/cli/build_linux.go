@@ -9,14 +9,17 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	osExec "os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/spf13/cobra"
@@ -24,6 +27,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/build"
 	"github.com/sylabs/singularity/v4/internal/pkg/build/args"
 	bkclient "github.com/sylabs/singularity/v4/internal/pkg/build/buildkit/client"
+	"github.com/sylabs/singularity/v4/internal/pkg/build/buildkit/translate"
 	"github.com/sylabs/singularity/v4/internal/pkg/build/remotebuilder"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/cache"
@@ -31,6 +35,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
 	fakerootConfig "github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/fakeroot/config"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/crypt"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/interactive"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/rootless"
@@ -41,6 +46,7 @@ import (
 	"github.com/sylabs/singularity/v4/pkg/runtime/engine/config"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	"github.com/sylabs/singularity/v4/pkg/util/cryptkey"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
 )
 
 func fakerootExec() {
@@ -109,11 +115,17 @@ func fakerootExec() {
 }
 
 func runBuild(cmd *cobra.Command, args []string) {
+	result := startImageResult()
+
+	// OCI builds from definition files are performed natively, and only
+	// OCI builds from Dockerfiles use BuildKit.
+	isDockerfile := isOCI && !isDefinitionFile(args[1])
+
 	if buildArgs.nvidia {
 		if buildArgs.remote {
 			sylog.Fatalf("--nv option is not supported for remote build")
 		}
-		if isOCI {
+		if isDockerfile {
 			sylog.Fatalf("--nv option is not supported for OCI builds from Dockerfiles")
 		}
 		os.Setenv("SINGULARITY_NV", "1")
@@ -122,7 +134,7 @@ func runBuild(cmd *cobra.Command, args []string) {
 		if buildArgs.remote {
 			sylog.Fatalf("--nvccli option is not supported for remote build")
 		}
-		if isOCI {
+		if isDockerfile {
 			sylog.Fatalf("--nvccli option is not supported for OCI builds from Dockerfiles")
 		}
 		os.Setenv("SINGULARITY_NVCCLI", "1")
@@ -131,7 +143,7 @@ func runBuild(cmd *cobra.Command, args []string) {
 		if buildArgs.remote {
 			sylog.Fatalf("--rocm option is not supported for remote build")
 		}
-		if isOCI {
+		if isDockerfile {
 			sylog.Fatalf("--rocm option is not supported for OCI builds from Dockerfiles")
 		}
 		os.Setenv("SINGULARITY_ROCM", "1")
@@ -140,7 +152,7 @@ func runBuild(cmd *cobra.Command, args []string) {
 		if buildArgs.remote {
 			sylog.Fatalf("-B/--bind option is not supported for remote build")
 		}
-		if isOCI {
+		if isDockerfile {
 			sylog.Fatalf("-B/--bind option is not supported for OCI builds from Dockerfiles")
 		}
 		os.Setenv("SINGULARITY_BINDPATH", strings.Join(buildArgs.bindPaths, ","))
@@ -149,7 +161,7 @@ func runBuild(cmd *cobra.Command, args []string) {
 		if buildArgs.remote {
 			sylog.Fatalf("--mount option is not supported for remote build")
 		}
-		if isOCI {
+		if isDockerfile {
 			sylog.Fatalf("--mount option is not supported for OCI builds from Dockerfiles")
 		}
 		os.Setenv("SINGULARITY_MOUNT", strings.Join(buildArgs.mounts, "\n"))
@@ -161,17 +173,120 @@ func runBuild(cmd *cobra.Command, args []string) {
 		if buildArgs.fakeroot {
 			sylog.Fatalf("--writable-tmpfs option is not supported for fakeroot build")
 		}
-		if isOCI {
+		if isDockerfile {
 			sylog.Fatalf("--writable-tmpfs option is not supported for OCI builds from Dockerfiles")
 		}
 		os.Setenv("SINGULARITY_WRITABLE_TMPFS", "1")
 	}
 
-	if cmd.Flags().Lookup("authfile").Changed && buildArgs.remote {
+	if len(buildArgs.onlyPaths) > 0 {
+		if !buildArgs.sandbox {
+			sylog.Fatalf("--only option requires --sandbox")
+		}
+		if buildArgs.remote {
+			sylog.Fatalf("--only option is not supported for remote build")
+		}
+		if buildArgs.update {
+			sylog.Fatalf("--only option is not supported with --update")
+		}
+		if isDockerfile {
+			sylog.Fatalf("--only option is not supported for OCI builds from Dockerfiles")
+		}
+		for i, p := range buildArgs.onlyPaths {
+			buildArgs.onlyPaths[i] = filepath.Clean("/" + p)
+		}
+	}
+
+	// Remote builds with a buildkitd daemon translate definition files to
+	// Dockerfiles, and build Dockerfiles as-is.
+	isBuildkitRemote := buildArgs.remote && buildArgs.buildkitHost != ""
+
+	if buildArgs.testReport != "" {
+		buildArgs.runTests = true
+		p, err := filepath.Abs(buildArgs.testReport)
+		if err != nil {
+			sylog.Fatalf("While determining test report path: %v", err)
+		}
+		buildArgs.testReport = p
+	}
+	if buildArgs.runTests {
+		if buildArgs.noTest {
+			sylog.Fatalf("--run-tests option cannot be used with --notest")
+		}
+		if buildArgs.remote {
+			sylog.Fatalf("--run-tests option is not supported for remote build")
+		}
+		if isDockerfile {
+			sylog.Fatalf("--run-tests option is not supported for OCI builds from Dockerfiles")
+		}
+		if buildArgs.encrypt {
+			sylog.Fatalf("--run-tests option is not supported for encrypted containers")
+		}
+	}
+
+	if buildArgs.network == "host" {
+		buildArgs.network = ""
+	}
+	if buildArgs.network != "" {
+		if buildArgs.remote {
+			sylog.Fatalf("--network option is not supported for remote build")
+		}
+		if isDockerfile {
+			sylog.Fatalf("--network option is not supported for OCI builds from Dockerfiles")
+		}
+	}
+	if len(buildArgs.networkArgs) > 0 && buildArgs.network == "" {
+		sylog.Fatalf("--network-args option requires --network")
+	}
+
+	if buildArgs.reproducible {
+		if buildArgs.remote && !isBuildkitRemote {
+			sylog.Fatalf("--reproducible option is not supported for remote build")
+		}
+		if buildArgs.sandbox {
+			sylog.Fatalf("--reproducible option is not supported for sandbox build")
+		}
+		if buildArgs.encrypt {
+			sylog.Fatalf("--reproducible option is not supported for encrypted containers")
+		}
+		if _, err := sourceDateEpoch(); err != nil {
+			sylog.Fatalf("While reading build time: %v", err)
+		}
+	}
+
+	if buildArgs.primeCache {
+		if buildArgs.remote {
+			sylog.Fatalf("--prime-cache option is not supported for remote build")
+		}
+		if isDockerfile {
+			sylog.Fatalf("--prime-cache option is not supported for OCI builds from Dockerfiles")
+		}
+	}
+
+	if buildArgs.accessProfile != "" {
+		if buildArgs.remote {
+			sylog.Fatalf("--access-profile option is not supported for remote build")
+		}
+		if isOCI || buildArgs.sandbox {
+			sylog.Fatalf("--access-profile option is only supported for SIF images")
+		}
+		buildArgs.accessProfile = absAccessProfile(buildArgs.accessProfile)
+	}
+
+	if resultJSON {
+		if buildArgs.detached {
+			sylog.Fatalf("--json option is not supported for detached builds")
+		}
+		if strings.HasPrefix(args[0], "library://") {
+			sylog.Fatalf("--json option is not supported for builds to a library")
+		}
+	}
+
+	if cmd.Flags().Lookup("authfile").Changed && buildArgs.remote && !isBuildkitRemote {
 		sylog.Fatalf("Custom authfile is not supported for remote build")
 	}
 
-	if buildArgs.arch != runtime.GOARCH && !(buildArgs.remote || isOCI) {
+	if buildArgs.arch != runtime.GOARCH && !(buildArgs.remote || isDockerfile) {
 		sylog.Fatalf("Requested architecture (%s) does not match host (%s). Cannot build locally.", buildArgs.arch, runtime.GOARCH)
 	}
 
@@ -179,7 +294,7 @@ func runBuild(cmd *cobra.Command, args []string) {
 	spec := args[1]
 
 	// Non-remote build with def file as source
-	rootNeeded := !buildArgs.remote && fs.IsFile(spec) && !isImage(spec) && !isOCI
+	rootNeeded := !buildArgs.remote && fs.IsFile(spec) && !isImage(spec) && !isDockerfile
 
 	if rootNeeded && syscall.Getuid() != 0 && !buildArgs.fakeroot {
 		prootPath, err := bin.FindBin("proot")
@@ -195,8 +310,9 @@ func runBuild(cmd *cobra.Command, args []string) {
 		sylog.Fatalf("While checking build target: %s", err)
 	}
 
-	if buildArgs.remote {
+	if buildArgs.remote && !isBuildkitRemote {
 		runBuildRemote(cmd.Context(), cmd, dest, spec)
+		result.print(dest, spec, nil)
 		return
 	}
 
@@ -205,7 +321,34 @@ func runBuild(cmd *cobra.Command, args []string) {
 		sylog.Fatalf("While creating Docker credentials: %v", err)
 	}
 
-	if isOCI {
+	// the cache handle of local builds, to report cache hits
+	var imgCache *cache.Handle
+
+	// build args are substituted, and printed, when translating a definition
+	translated := false
+	if isBuildkitRemote {
+		if buildArgs.sandbox {
+			sylog.Fatalf("--sandbox option is not supported for remote builds with a buildkitd daemon")
+		}
+		if buildArgs.detached {
+			sylog.Fatalf("--detached option is not supported for remote builds with a buildkitd daemon")
+		}
+		if !fs.IsFile(spec) || isImage(spec) || isDefinitionFile(spec) {
+			dir, err := os.MkdirTemp(tmpDir, "build-dockerfile-")
+			if err != nil {
+				sylog.Fatalf("While creating temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			spec, err = translateDefinition(spec, dir)
+			if err != nil {
+				sylog.Fatalf("Unable to build from %s with a buildkitd daemon: %v", args[1], err)
+			}
+			translated = true
+		}
+		isDockerfile = true
+	}
+
+	if isDockerfile {
 		reqArch := ""
 		if cmd.Flags().Lookup("arch").Changed {
 			reqArch = buildArgs.arch
@@ -224,13 +367,69 @@ func runBuild(cmd *cobra.Command, args []string) {
 			KeepLayers:      keepLayers,
 			ContextDir:      wd,
 			DisableCache:    disableCache,
+			KeyInfo:         buildKeyInfo(cmd, buildArgs.encrypt),
+			MetricsAddr:     buildArgs.buildkitMetrics,
+		}
+		if buildArgs.reproducible {
+			epoch, _ := sourceDateEpoch()
+			bkOpts.SourceDateEpoch = strconv.FormatInt(epoch.Unix(), 10)
+		}
+		if isBuildkitRemote {
+			bkOpts.RemoteAddr = buildArgs.buildkitHost
+			bkOpts.TLS = bkclient.TLSOpts{
+				CACert:     buildArgs.buildkitCACert,
+				Cert:       buildArgs.buildkitCert,
+				Key:        buildArgs.buildkitKey,
+				ServerName: buildArgs.buildkitServer,
+			}
+		}
+		if buildArgs.printArgs && !translated {
+			printBuildArgs(nil)
+		}
+		if buildArgs.encrypt && bkOpts.KeyInfo == nil {
+			sylog.Fatalf("--encrypt requires --passphrase, --pem-path, --key-file, --key-uri, an encryption environment variable, or an 'encryption key uri' in singularity.conf")
 		}
 		bkclient.Run(cmd.Context(), bkOpts, dest, spec)
 	} else {
-		runBuildLocal(cmd.Context(), authConf, cmd, dest, spec)
+		imgCache = runBuildLocal(cmd.Context(), authConf, cmd, dest, spec)
 	}
 
 	sylog.Infof("Build complete: %s", dest)
+	result.print(dest, spec, imgCache)
+}
+
+// translateDefinition writes a Dockerfile equivalent to the definition, or
+// URI, spec in dir, after substitution of build args, and returns its path.
+// Files copied from the host are taken from the current directory, which is
+// the build context.
+func translateDefinition(spec, dir string) (string, error) {
+	buildArgsMap, err := args.ReadBuildArgs(buildArgs.buildVarArgs, buildArgs.buildVarArgFile)
+	if err != nil {
+		return "", err
+	}
+	defs, err := build.MakeAllDefs(spec, buildArgsMap, buildArgs.strictArgs)
+	if err != nil {
+		return "", err
+	}
+	if buildArgs.printArgs {
+		printBuildArgs(defs)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := translate.Dockerfile(&buf, defs, wd); err != nil {
+		return "", err
+	}
+	sylog.Debugf("Definition file translated to Dockerfile:\n%s", buf.String())
+
+	dockerfile := filepath.Join(dir, "Dockerfile")
+	if err := os.WriteFile(dockerfile, buf.Bytes(), 0o600); err != nil {
+		return "", err
+	}
+	return dockerfile, nil
 }
 
 func runBuildRemote(ctx context.Context, cmd *cobra.Command, dst, spec string) {
@@ -357,25 +556,10 @@ func runBuildRemote(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 	}
 }
 
-func runBuildLocal(ctx context.Context, authConf *authn.AuthConfig, cmd *cobra.Command, dst, spec string) {
-	var keyInfo *cryptkey.KeyInfo
-	if buildArgs.encrypt || promptForPassphrase || cmd.Flags().Lookup("pem-path").Changed {
-		if os.Getuid() != 0 {
-			sylog.Fatalf("You must be root to build an encrypted container")
-		}
-
-		k, err := getEncryptionMaterial(cmd)
-		if err != nil {
-			sylog.Fatalf("While handling encryption material: %v", err)
-		}
-		keyInfo = k
-	} else {
-		_, passphraseEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PASSPHRASE")
-		_, pemPathEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PEM_PATH")
-		if passphraseEnvOK || pemPathEnvOK {
-			sylog.Warningf("Encryption related env vars found, but --encrypt was not specified. NOT encrypting container.")
-		}
-	}
+// runBuildLocal builds dst from spec on the host, and returns the handle of
+// the image cache used by the build.
+func runBuildLocal(ctx context.Context, authConf *authn.AuthConfig, cmd *cobra.Command, dst, spec string) *cache.Handle {
+	keyInfo := buildKeyInfo(cmd, buildArgs.encrypt)
 
 	imgCache := getCacheHandle(cache.Config{Disable: disableCache})
 	if imgCache == nil {
@@ -392,25 +576,36 @@ func runBuildLocal(ctx context.Context, authConf *authn.AuthConfig, cmd *cobra.C
 	if err != nil {
 		sylog.Fatalf("While processing the definition file: %v", err)
 	}
-	defs, err := build.MakeAllDefs(spec, buildArgsMap)
+	defs, err := build.MakeAllDefs(spec, buildArgsMap, buildArgs.strictArgs)
 	if err != nil {
 		sylog.Fatalf("Unable to build from %s: %v", spec, err)
 	}
+	if buildArgs.printArgs {
+		printBuildArgs(defs)
+	}
 
 	authToken := ""
 	hasLibrary := false
 	hasSIF := false
 
 	for _, d := range defs {
-		// If there's a library source we need the library client, and it'll be a SIF
-		if d.Header["bootstrap"] == "library" {
-			hasLibrary = true
-			hasSIF = true
-			break
+		bootstraps := []string{d.Header["bootstrap"]}
+		// Images that files are copied from are retrieved like bootstrap sources
+		for _, f := range d.BuildData.Files {
+			if ref := f.Image(); ref != "" {
+				bootstraps = append(bootstraps, strings.SplitN(ref, ":", 2)[0])
+			}
 		}
-		// Certain other bootstrap sources may result in a SIF image source
-		if d.Header["bootstrap"] == "localimage" || d.Header["bootstrap"] == "oras" || d.Header["bootstrap"] == "shub" {
-			hasSIF = true
+		for _, bs := range bootstraps {
+			// If there's a library source we need the library client, and it'll be a SIF
+			if bs == "library" {
+				hasLibrary = true
+				hasSIF = true
+			}
+			// Certain other bootstrap sources may result in a SIF image source
+			if bs == "localimage" || bs == "ocisif" || bs == "oras" || bs == "shub" {
+				hasSIF = true
+			}
 		}
 	}
 
@@ -439,7 +634,9 @@ func runBuildLocal(ctx context.Context, authConf *authn.AuthConfig, cmd *cobra.C
 
 	buildFormat := "sif"
 	sandboxTarget := false
-	if buildArgs.sandbox {
+	if isOCI {
+		buildFormat = "oci-sif"
+	} else if buildArgs.sandbox {
 		buildFormat = "sandbox"
 		sandboxTarget = true
 	}
@@ -449,12 +646,21 @@ func runBuildLocal(ctx context.Context, authConf *authn.AuthConfig, cmd *cobra.C
 		sylog.Fatalf("%v", err)
 	}
 
+	var epoch time.Time
+	if buildArgs.reproducible {
+		if epoch, err = sourceDateEpoch(); err != nil {
+			sylog.Fatalf("While reading build time: %v", err)
+		}
+	}
+
 	b, err := build.New(
 		defs,
 		build.Config{
-			Dest:      dst,
-			Format:    buildFormat,
-			NoCleanUp: buildArgs.noCleanUp,
+			Dest:       dst,
+			Format:     buildFormat,
+			NoCleanUp:  buildArgs.noCleanUp,
+			RunTests:   buildArgs.runTests,
+			TestReport: buildArgs.testReport,
 			Opts: types.Options{
 				ImgCache:          imgCache,
 				TmpDir:            tmpDir,
@@ -473,6 +679,13 @@ func runBuildLocal(ctx context.Context, authConf *authn.AuthConfig, cmd *cobra.C
 				EncryptionKeyInfo: keyInfo,
 				FixPerms:          buildArgs.fixPerms,
 				SandboxTarget:     sandboxTarget,
+				OnlyPaths:         buildArgs.onlyPaths,
+				Network:           buildArgs.network,
+				NetworkArgs:       buildArgs.networkArgs,
+				Reproducible:      buildArgs.reproducible,
+				SourceDateEpoch:   epoch,
+				PrimeCache:        buildArgs.primeCache,
+				AccessProfile:     buildArgs.accessProfile,
 				// Only perform a build with the host DefaultPlatform at present.
 				// TODO: rework --arch handling for remote builds so that local builds can specify --arch and --platform.
 				Platform: *dp,
@@ -485,6 +698,35 @@ func runBuildLocal(ctx context.Context, authConf *authn.AuthConfig, cmd *cobra.C
 	if err = b.Full(ctx); err != nil {
 		sylog.Fatalf("While performing build: %v", err)
 	}
+	return imgCache
+}
+
+// printBuildArgs prints the build args resolved for each stage in defs, or
+// only those provided on the command line, in a build arg file, or in the
+// environment when defs is empty.
+// sourceDateEpoch returns the time recorded in reproducible images, set in
+// seconds since the Unix epoch by the SOURCE_DATE_EPOCH environment variable,
+// or the Unix epoch when it is unset.
+func sourceDateEpoch() (time.Time, error) {
+	v := os.Getenv("SOURCE_DATE_EPOCH")
+	if v == "" {
+		return time.Unix(0, 0), nil
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || sec < 0 {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be a non-negative number of seconds", v)
+	}
+	return time.Unix(sec, 0), nil
+}
+
+func printBuildArgs(defs []types.Definition) {
+	buildArgsMap, argSources, err := args.ReadBuildArgSources(buildArgs.buildVarArgs, buildArgs.buildVarArgFile, os.Environ())
+	if err != nil {
+		sylog.Fatalf("While processing build args: %v", err)
+	}
+	if err := args.WriteResolved(os.Stdout, defs, buildArgsMap, argSources); err != nil {
+		sylog.Fatalf("While printing build args: %v", err)
+	}
 }
 
 func checkSections() error {
@@ -516,6 +758,37 @@ func isImage(spec string) bool {
 	return err == nil
 }
 
+// buildKeyInfo returns the key material with which to encrypt a container,
+// or nil if encryption was not requested.
+func buildKeyInfo(cmd *cobra.Command, encrypt bool) *cryptkey.KeyInfo {
+	if encrypt || promptForPassphrase || cmd.Flags().Lookup("pem-path").Changed || cmd.Flags().Lookup("key-file").Changed || cmd.Flags().Lookup("key-uri").Changed {
+		if os.Getuid() != 0 {
+			sylog.Fatalf("You must be root to build an encrypted container")
+		}
+
+		k, err := getEncryptionMaterial(cmd)
+		if err != nil {
+			sylog.Fatalf("While handling encryption material: %v", err)
+		}
+		if k == nil && encrypt {
+			k, err = defaultKeyInfo()
+			if err != nil {
+				sylog.Fatalf("While handling encryption material: %v", err)
+			}
+		}
+		return k
+	}
+
+	_, passphraseEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PASSPHRASE")
+	_, pemPathEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PEM_PATH")
+	_, keyFileEnvOK := os.LookupEnv(crypt.KeyFileEnv)
+	_, keyURIEnvOK := os.LookupEnv(crypt.KeyURIEnv)
+	if passphraseEnvOK || pemPathEnvOK || keyFileEnvOK || keyURIEnvOK {
+		sylog.Warningf("Encryption related env vars found, but --encrypt was not specified. NOT encrypting container.")
+	}
+	return nil
+}
+
 // getEncryptionMaterial handles the setting of encryption environment and flag parameters to eventually be
 // passed to the crypt package for handling.
 // This handles the SINGULARITY_ENCRYPTION_PASSPHRASE/PEM_PATH envvars outside of cobra in order to
@@ -523,19 +796,19 @@ func isImage(spec string) bool {
 func getEncryptionMaterial(cmd *cobra.Command) (*cryptkey.KeyInfo, error) {
 	passphraseFlag := cmd.Flags().Lookup("passphrase")
 	PEMFlag := cmd.Flags().Lookup("pem-path")
+	keyFileFlag := cmd.Flags().Lookup("key-file")
+	keyURIFlag := cmd.Flags().Lookup("key-uri")
 	passphraseEnv, passphraseEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PASSPHRASE")
 	pemPathEnv, pemPathEnvOK := os.LookupEnv("SINGULARITY_ENCRYPTION_PEM_PATH")
 
-	// checks for no flags/envvars being set
-	if !(PEMFlag.Changed || pemPathEnvOK || passphraseFlag.Changed || passphraseEnvOK) {
-		return nil, nil
-	}
-
 	// order of precedence:
 	// 1. PEM flag
 	// 2. Passphrase flag
-	// 3. PEM envvar
-	// 4. Passphrase envvar
+	// 3. Key file flag
+	// 4. Key URI flag
+	// 5. PEM envvar
+	// 6. Passphrase envvar
+	// 7. Key file envvar, key URI envvar, key agent, systemd credential (see crypt.ResolveKey)
 
 	if PEMFlag.Changed {
 		exists, err := fs.PathExists(encryptionPEMPath)
@@ -550,7 +823,7 @@ func getEncryptionMaterial(cmd *cobra.Command) (*cryptkey.KeyInfo, error) {
 		sylog.Verbosef("Using pem path flag for encrypted container")
 
 		// Check it's a valid PEM public key we can load, before starting the build (#4173)
-		if cmd.Name() == "build" {
+		if cmd.Name() == "build" || cmd.Name() == "pull" {
 			if _, err := cryptkey.LoadPEMPublicKey(encryptionPEMPath); err != nil {
 				sylog.Fatalf("Invalid encryption public key: %v", err)
 			}
@@ -576,6 +849,15 @@ func getEncryptionMaterial(cmd *cobra.Command) (*cryptkey.KeyInfo, error) {
 		return &cryptkey.KeyInfo{Format: cryptkey.Passphrase, Material: passphrase}, nil
 	}
 
+	if keyFileFlag.Changed {
+		return crypt.ResolveKey(encryptionKeyFile)
+	}
+
+	if keyURIFlag.Changed {
+		sylog.Verbosef("Using key URI flag for encrypted container")
+		return crypt.KeyFromURI(encryptionKeyURI)
+	}
+
 	if pemPathEnvOK {
 		exists, err := fs.PathExists(pemPathEnv)
 		if err != nil {
@@ -595,5 +877,17 @@ func getEncryptionMaterial(cmd *cobra.Command) (*cryptkey.KeyInfo, error) {
 		return &cryptkey.KeyInfo{Format: cryptkey.Passphrase, Material: passphraseEnv}, nil
 	}
 
-	return nil, nil
+	return crypt.ResolveKey("")
+}
+
+// defaultKeyInfo returns the key material for the 'encryption key uri' set in
+// singularity.conf, used to encrypt a container when no other key material is
+// specified, or nil if it is not set.
+func defaultKeyInfo() (*cryptkey.KeyInfo, error) {
+	uri := singularityconf.GetCurrentConfig().EncryptionKeyURI
+	if uri == "" {
+		return nil, nil
+	}
+	sylog.Verbosef("Using key URI %s from singularity.conf for encrypted container", uri)
+	return crypt.KeyFromURI(uri)
 }
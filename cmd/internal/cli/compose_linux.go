@@ -0,0 +1,110 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/compose"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(ComposeCmd)
+		cmdManager.RegisterSubCmd(ComposeCmd, ComposeUpCmd)
+		cmdManager.RegisterSubCmd(ComposeCmd, ComposeDownCmd)
+
+		cmdManager.RegisterFlagForCmd(&composeFileFlag, ComposeUpCmd, ComposeDownCmd)
+		cmdManager.RegisterFlagForCmd(&composeDownTimeoutFlag, ComposeDownCmd)
+	})
+}
+
+// -f|--file
+var composeFile string
+
+var composeFileFlag = cmdline.Flag{
+	ID:           "composeFileFlag",
+	Value:        &composeFile,
+	DefaultValue: compose.DefaultFile,
+	Name:         "file",
+	ShortHand:    "f",
+	Usage:        "path of the compose file",
+	Tag:          "<path>",
+	EnvKeys:      []string{"COMPOSE_FILE"},
+}
+
+// -t|--timeout
+var composeDownTimeout int
+
+var composeDownTimeoutFlag = cmdline.Flag{
+	ID:           "composeDownTimeoutFlag",
+	Value:        &composeDownTimeout,
+	DefaultValue: 10,
+	Name:         "timeout",
+	ShortHand:    "t",
+	Usage:        "force kill non stopped instances after X seconds",
+}
+
+// loadCompose returns the project of the compose file.
+func loadCompose() *compose.Project {
+	p, err := compose.Load(composeFile)
+	if err != nil {
+		sylog.Fatalf("%s", err)
+	}
+	return p
+}
+
+// ComposeCmd singularity compose [...]
+var ComposeCmd = &cobra.Command{
+	Run: nil,
+
+	Use:     docs.ComposeUse,
+	Short:   docs.ComposeShort,
+	Long:    docs.ComposeLong,
+	Example: docs.ComposeExample,
+
+	DisableFlagsInUseLine: true,
+}
+
+// ComposeUpCmd singularity compose up
+var ComposeUpCmd = &cobra.Command{
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ComposeUp(cmd.Context(), loadCompose()); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ComposeUpUse,
+	Short:   docs.ComposeUpShort,
+	Long:    docs.ComposeUpLong,
+	Example: docs.ComposeUpExample,
+
+	DisableFlagsInUseLine: true,
+}
+
+// ComposeDownCmd singularity compose down
+var ComposeDownCmd = &cobra.Command{
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout := time.Duration(composeDownTimeout) * time.Second
+		if err := singularity.ComposeDown(loadCompose(), timeout); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ComposeDownUse,
+	Short:   docs.ComposeDownShort,
+	Long:    docs.ComposeDownLong,
+	Example: docs.ComposeDownExample,
+
+	DisableFlagsInUseLine: true,
+}
@@ -27,5 +27,6 @@ func init() {
 
 		cmdManager.RegisterSubCmd(configCmd, configFakerootCmd)
 		cmdManager.RegisterSubCmd(configCmd, configGlobalCmd)
+		cmdManager.RegisterSubCmd(configCmd, configSeccompCmd)
 	})
 }
@@ -0,0 +1,35 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// configSeccompCmd singularity config seccomp
+var configSeccompCmd = &cobra.Command{
+	Args:                  cobra.MaximumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		profile := ""
+		if len(args) > 0 {
+			profile = args[0]
+		}
+		if err := singularity.SeccompConfig(os.Stdout, profile); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ConfigSeccompUse,
+	Short:   docs.ConfigSeccompShort,
+	Long:    docs.ConfigSeccompLong,
+	Example: docs.ConfigSeccompExample,
+}
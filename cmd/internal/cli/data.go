@@ -0,0 +1,81 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(DataCmd)
+		cmdManager.RegisterSubCmd(DataCmd, DataCreateCmd)
+
+		cmdManager.RegisterFlagForCmd(&dataCreateForceFlag, DataCreateCmd)
+		cmdManager.RegisterFlagForCmd(&commonTmpDirFlag, DataCreateCmd)
+	})
+}
+
+// -F|--force
+var dataCreateForce bool
+
+var dataCreateForceFlag = cmdline.Flag{
+	ID:           "dataCreateForceFlag",
+	Value:        &dataCreateForce,
+	DefaultValue: false,
+	Name:         "force",
+	ShortHand:    "F",
+	Usage:        "overwrite an existing data container",
+}
+
+// DataCmd is the 'data' command that allows to manage data containers.
+var DataCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("Invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.DataUse,
+	Short:   docs.DataShort,
+	Long:    docs.DataLong,
+	Example: docs.DataExample,
+}
+
+// DataCreateCmd is the 'data create' command that packages a directory into a
+// data container.
+var DataCreateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		dest, src := args[0], args[1]
+
+		if _, err := os.Stat(dest); err == nil {
+			if !dataCreateForce {
+				sylog.Fatalf("Data container %s already exists, use --force to overwrite it", dest)
+			}
+			if err := os.Remove(dest); err != nil {
+				sylog.Fatalf("While removing %s: %v", dest, err)
+			}
+		}
+
+		if err := ocisif.CreateDataContainer(src, dest, tmpDir); err != nil {
+			sylog.Fatalf("While creating data container: %v", err)
+		}
+	},
+
+	Use:     docs.DataCreateUse,
+	Short:   docs.DataCreateShort,
+	Long:    docs.DataCreateLong,
+	Example: docs.DataCreateExample,
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(DiffCmd)
+		cmdManager.RegisterFlagForCmd(&diffJSONFlag, DiffCmd)
+		cmdManager.RegisterFlagForCmd(&diffConfigOnlyFlag, DiffCmd)
+		cmdManager.RegisterFlagForCmd(&commonTmpDirFlag, DiffCmd)
+	})
+}
+
+var (
+	diffJSON       bool
+	diffConfigOnly bool
+)
+
+// -j|--json
+var diffJSONFlag = cmdline.Flag{
+	ID:           "diffJSONFlag",
+	Value:        &diffJSON,
+	DefaultValue: false,
+	Name:         "json",
+	ShortHand:    "j",
+	Usage:        "print differences in JSON format",
+}
+
+// --config-only
+var diffConfigOnlyFlag = cmdline.Flag{
+	ID:           "diffConfigOnlyFlag",
+	Value:        &diffConfigOnly,
+	DefaultValue: false,
+	Name:         "config-only",
+	Usage:        "only compare the OCI image config and layers of OCI-SIF images, not their files",
+}
+
+// DiffCmd singularity diff
+var DiffCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		d, err := singularity.Diff(args[0], args[1], singularity.DiffOptions{
+			ConfigOnly: diffConfigOnly,
+			TmpDir:     tmpDir,
+		})
+		if err != nil {
+			sylog.Fatalf("While comparing %s and %s: %v", args[0], args[1], err)
+		}
+		if err := singularity.WriteImageDiff(os.Stdout, d, diffJSON); err != nil {
+			sylog.Fatalf("While writing differences: %v", err)
+		}
+	},
+
+	Use:     docs.DiffUse,
+	Short:   docs.DiffShort,
+	Long:    docs.DiffLong,
+	Example: docs.DiffExample,
+}
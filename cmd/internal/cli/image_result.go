@@ -0,0 +1,49 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// imageResult prints the record of the image of a pull, build, or push, when
+// --json is set.
+type imageResult struct {
+	out   *os.File
+	start time.Time
+}
+
+// startImageResult returns the imageResult of the command, or nil if --json
+// is not set. With --json, stdout is redirected to stderr until the record is
+// printed, so that progress and other output do not mix with the record.
+func startImageResult() *imageResult {
+	if !resultJSON {
+		return nil
+	}
+	r := &imageResult{out: os.Stdout, start: time.Now()}
+	os.Stdout = os.Stderr
+	return r
+}
+
+// print prints the record of the image at path, obtained from, or pushed to,
+// ref. imgCache is the cache handle used for the operation, if any.
+func (r *imageResult) print(path, ref string, imgCache *cache.Handle) {
+	if r == nil {
+		return
+	}
+	res, err := singularity.NewImageResult(path, ref, imgCache.Hits() > 0, time.Since(r.start))
+	if err != nil {
+		sylog.Fatalf("While reading image %s: %v", path, err)
+	}
+	if err := singularity.WriteImageResult(r.out, res); err != nil {
+		sylog.Fatalf("While printing image record: %v", err)
+	}
+}
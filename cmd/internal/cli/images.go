@@ -0,0 +1,138 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/imagestore"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(ImagesCmd)
+		cmdManager.RegisterSubCmd(ImagesCmd, ImagesListCmd)
+		cmdManager.RegisterSubCmd(ImagesCmd, ImagesImportCmd)
+		cmdManager.RegisterSubCmd(ImagesCmd, ImagesTagCmd)
+		cmdManager.RegisterSubCmd(ImagesCmd, ImagesRemoveCmd)
+		cmdManager.RegisterSubCmd(ImagesCmd, ImagesPruneCmd)
+
+		cmdManager.RegisterFlagForCmd(&listFormatFlag, ImagesListCmd)
+		cmdManager.RegisterFlagForCmd(&listJSONFlag, ImagesListCmd)
+	})
+}
+
+// openImageStore returns the image store of the current user.
+func openImageStore() *imagestore.Store {
+	s, err := imagestore.Open(imagestore.DefaultDir())
+	if err != nil {
+		sylog.Fatalf("%s", err)
+	}
+	return s
+}
+
+// ImagesCmd singularity images [...]
+var ImagesCmd = &cobra.Command{
+	Run: nil,
+
+	Use:     docs.ImagesUse,
+	Short:   docs.ImagesShort,
+	Long:    docs.ImagesLong,
+	Example: docs.ImagesExample,
+	Aliases: []string{"image"},
+
+	DisableFlagsInUseLine: true,
+}
+
+// ImagesListCmd singularity images list
+var ImagesListCmd = &cobra.Command{
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ImagesList(openImageStore(), listOutputFormat()); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ImagesListUse,
+	Short:   docs.ImagesListShort,
+	Long:    docs.ImagesListLong,
+	Example: docs.ImagesListExample,
+	Aliases: []string{"ls"},
+
+	DisableFlagsInUseLine: true,
+}
+
+// ImagesImportCmd singularity images import <image file> <name[:tag]>
+var ImagesImportCmd = &cobra.Command{
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ImagesImport(openImageStore(), args[0], args[1]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ImagesImportUse,
+	Short:   docs.ImagesImportShort,
+	Long:    docs.ImagesImportLong,
+	Example: docs.ImagesImportExample,
+
+	DisableFlagsInUseLine: true,
+}
+
+// ImagesTagCmd singularity images tag <name[:tag]> <name[:tag]>
+var ImagesTagCmd = &cobra.Command{
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ImagesTag(openImageStore(), args[0], args[1]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ImagesTagUse,
+	Short:   docs.ImagesTagShort,
+	Long:    docs.ImagesTagLong,
+	Example: docs.ImagesTagExample,
+
+	DisableFlagsInUseLine: true,
+}
+
+// ImagesRemoveCmd singularity images remove <name[:tag]>...
+var ImagesRemoveCmd = &cobra.Command{
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ImagesRemove(openImageStore(), args); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ImagesRemoveUse,
+	Short:   docs.ImagesRemoveShort,
+	Long:    docs.ImagesRemoveLong,
+	Example: docs.ImagesRemoveExample,
+	Aliases: []string{"rm"},
+
+	DisableFlagsInUseLine: true,
+}
+
+// ImagesPruneCmd singularity images prune
+var ImagesPruneCmd = &cobra.Command{
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ImagesPrune(openImageStore()); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.ImagesPruneUse,
+	Short:   docs.ImagesPruneShort,
+	Long:    docs.ImagesPruneLong,
+	Example: docs.ImagesPruneExample,
+
+	DisableFlagsInUseLine: true,
+}
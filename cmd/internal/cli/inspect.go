@@ -19,15 +19,20 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/sif/v2/pkg/sif"
 	"github.com/sylabs/singularity/v4/docs"
+	ocisifclient "github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/metadata"
+	ocilauncher "github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher/oci"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/env"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
 	"github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/inspect"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
 )
 
 var (
@@ -46,6 +51,7 @@ var (
 	labels      bool
 	deffile     bool
 	jsonfmt     bool
+	runtimeInfo bool
 )
 
 // -l|--labels
@@ -156,6 +162,15 @@ var inspectAllFlag = cmdline.Flag{
 	Usage:        "show all available data (imply --json option)",
 }
 
+// --runtime
+var inspectRuntimeFlag = cmdline.Flag{
+	ID:           "inspectRuntimeFlag",
+	Value:        &runtimeInfo,
+	DefaultValue: false,
+	Name:         "runtime",
+	Usage:        "show how the OCI-SIF image VOLUMEs will be provided at runtime, in --oci mode",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(InspectCmd)
@@ -171,6 +186,8 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&inspectTestFlag, InspectCmd)
 		cmdManager.RegisterFlagForCmd(&inspectAppsListFlag, InspectCmd)
 		cmdManager.RegisterFlagForCmd(&inspectAllFlag, InspectCmd)
+		cmdManager.RegisterFlagForCmd(&inspectRuntimeFlag, InspectCmd)
+		cmdManager.RegisterFlagForCmd(&actionVolumePolicyFlag, InspectCmd)
 	})
 }
 
@@ -198,13 +215,13 @@ func newCommand(allData bool, appName string, img *image.Image) *command {
 	if img.Type == image.SANDBOX {
 		prefix = img.Path
 	} else if img.Type == image.SIF {
-		metadata, err := getInspectMetadataFromSIF(img)
+		md, err := getInspectMetadataFromSIF(img)
 		if err == nil {
 			sylog.Debugf("Using %s SIF descriptor", metadataJSON)
-			command.sifMetadata = metadata
+			command.sifMetadata = md
 			if listApps || allData {
 				// copy app attributes for related flags as they are not copied by default
-				command.metadata.Attributes.Apps = metadata.Attributes.Apps
+				command.metadata.Attributes.Apps = md.Attributes.Apps
 			}
 		} else if err != image.ErrNoSection {
 			sylog.Warningf("Unable to read %s SIF descriptor: %s", metadataJSON, err)
@@ -554,18 +571,23 @@ func (c *command) addDefinitionCommand() {
 	}
 }
 
+// getInspectMetadataFromSIF returns the current metadata of the SIF image
+// img, or image.ErrNoSection if it holds none.
 func getInspectMetadataFromSIF(img *image.Image) (*inspect.Metadata, error) {
-	r, err := image.NewSectionReader(img, metadataJSON, -1)
+	f, err := sif.LoadContainer(img.File,
+		sif.OptLoadWithFlag(os.O_RDONLY),
+		sif.OptLoadWithCloseOnUnload(false),
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("while loading SIF: %w", err)
 	}
-	metadata := new(inspect.Metadata)
+	defer f.UnloadContainer()
 
-	if err := json.NewDecoder(r).Decode(metadata); err != nil {
-		return nil, fmt.Errorf("while decoding inspect metadata: %s", err)
+	md, err := metadata.Read(f)
+	if errors.Is(err, sif.ErrObjectNotFound) {
+		return nil, image.ErrNoSection
 	}
-
-	return metadata, nil
+	return md, err
 }
 
 func getSIFMetadata(img *image.Image, dataType uint32) ([]byte, error) {
@@ -622,6 +644,127 @@ func printSortedMap(m map[string]string, fn func(key string)) {
 	}
 }
 
+// inspectRuntime prints how the VOLUMEs declared by the OCI-SIF image img are
+// provided when it is run in OCI mode.
+func inspectRuntime(img *image.Image) error {
+	if img.Type != image.OCISIF {
+		return fmt.Errorf("--runtime is only supported for OCI-SIF images")
+	}
+
+	imgSpec, err := ocisifclient.ImageSpec(img.Path)
+	if err != nil {
+		return err
+	}
+
+	policy := volumePolicy
+	if policy == "" {
+		policy = singularityconf.GetCurrentConfig().OCIVolumes
+	}
+	vms, err := ocilauncher.VolumeMappings(*imgSpec, policy)
+	if err != nil {
+		return err
+	}
+
+	if jsonfmt {
+		jsonObj, err := json.MarshalIndent(map[string]interface{}{
+			"volumePolicy": policy,
+			"volumes":      vms,
+		}, "", "\t")
+		if err != nil {
+			return fmt.Errorf("could not format runtime information as JSON: %w", err)
+		}
+		fmt.Printf("%s\n", string(jsonObj))
+		return nil
+	}
+
+	fmt.Printf("Volume policy: %s\n", policy)
+	for _, vm := range vms {
+		src := vm.Source
+		if src == "" {
+			src = vm.Policy
+		}
+		fmt.Printf("%s -> %s\n", vm.Destination, src)
+	}
+	return nil
+}
+
+// inspectOCISIF prints the OCI image configuration, layers, annotations and
+// history of the single image in the OCI-SIF img.
+func inspectOCISIF(img *image.Image) error {
+	info, err := ocisifclient.Inspect(img.Path)
+	if err != nil {
+		return err
+	}
+
+	if jsonfmt {
+		jsonObj, err := json.MarshalIndent(info, "", "\t")
+		if err != nil {
+			return fmt.Errorf("could not format OCI image information as JSON: %w", err)
+		}
+		fmt.Printf("%s\n", string(jsonObj))
+		return nil
+	}
+
+	fmt.Printf("Digest: %s\n", info.Digest)
+	if info.Created != "" {
+		fmt.Printf("Created: %s\n", info.Created)
+	}
+	platform := info.OS + "/" + info.Architecture
+	if info.Variant != "" {
+		platform += "/" + info.Variant
+	}
+	fmt.Printf("Platform: %s\n", platform)
+	if len(info.Entrypoint) > 0 {
+		fmt.Printf("Entrypoint: %s\n", strings.Join(info.Entrypoint, " "))
+	}
+	if len(info.Cmd) > 0 {
+		fmt.Printf("Cmd: %s\n", strings.Join(info.Cmd, " "))
+	}
+	if info.WorkingDir != "" {
+		fmt.Printf("WorkingDir: %s\n", info.WorkingDir)
+	}
+	if info.User != "" {
+		fmt.Printf("User: %s\n", info.User)
+	}
+	if len(info.Env) > 0 {
+		fmt.Printf("Env:\n")
+		for _, e := range info.Env {
+			fmt.Printf("  %s\n", e)
+		}
+	}
+	if len(info.Labels) > 0 {
+		fmt.Printf("Labels:\n")
+		printSortedMap(info.Labels, func(k string) {
+			fmt.Printf("  %s: %s\n", k, info.Labels[k])
+		})
+	}
+	if len(info.Annotations) > 0 {
+		fmt.Printf("Annotations:\n")
+		printSortedMap(info.Annotations, func(k string) {
+			fmt.Printf("  %s: %s\n", k, info.Annotations[k])
+		})
+	}
+	fmt.Printf("Layers:\n")
+	for _, l := range info.Layers {
+		fmt.Printf("  %s (%s, %d bytes)\n", l.Digest, l.MediaType, l.Size)
+	}
+	if len(info.History) > 0 {
+		fmt.Printf("History:\n")
+		for _, h := range info.History {
+			created := ""
+			if h.Created != nil {
+				created = h.Created.UTC().Format(time.RFC3339)
+			}
+			empty := ""
+			if h.EmptyLayer {
+				empty = " (empty layer)"
+			}
+			fmt.Printf("  %s %s%s\n", created, h.CreatedBy, empty)
+		}
+	}
+	return nil
+}
+
 // returns true if flags for other forms of information are unset.
 func defaultToLabels() bool {
 	return !(helpfile || deffile || runscript || startscript || testfile || environment || listApps)
@@ -644,6 +787,25 @@ var InspectCmd = &cobra.Command{
 			sylog.Fatalf("Failed to open image %s: %s", args[0], err)
 		}
 
+		if runtimeInfo {
+			if err := inspectRuntime(img); err != nil {
+				sylog.Fatalf("%s", err)
+			}
+			return
+		}
+
+		// OCI-SIF images carry an OCI image config rather than the native
+		// SIF metadata, so labels & --all are reported from that config.
+		if img.Type == image.OCISIF && (labels || defaultToLabels() || allData) && appName == "" {
+			if allData {
+				jsonfmt = true
+			}
+			if err := inspectOCISIF(img); err != nil {
+				sylog.Fatalf("%s", err)
+			}
+			return
+		}
+
 		if allData {
 			// display all data in JSON format only
 			jsonfmt = true
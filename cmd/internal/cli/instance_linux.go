@@ -20,6 +20,8 @@ func init() {
 		cmdManager.RegisterSubCmd(instanceCmd, instanceStopCmd)
 		cmdManager.RegisterSubCmd(instanceCmd, instanceListCmd)
 		cmdManager.RegisterSubCmd(instanceCmd, instanceStatsCmd)
+		cmdManager.RegisterSubCmd(instanceCmd, instanceMigrateCmd)
+		cmdManager.RegisterSubCmd(instanceCmd, instanceRestoreCmd)
 	})
 }
 
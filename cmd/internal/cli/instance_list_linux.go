@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2020, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/v4/docs"
 	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
@@ -20,6 +21,7 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&instanceListUserFlag, instanceListCmd)
 		cmdManager.RegisterFlagForCmd(&instanceListJSONFlag, instanceListCmd)
 		cmdManager.RegisterFlagForCmd(&instanceListLogsFlag, instanceListCmd)
+		cmdManager.RegisterFlagForCmd(&instanceListFilterFlag, instanceListCmd)
 	})
 }
 
@@ -63,6 +65,19 @@ var instanceListLogsFlag = cmdline.Flag{
 	EnvKeys:      []string{"LOGS"},
 }
 
+// --filter
+var instanceListFilter []string
+
+var instanceListFilterFlag = cmdline.Flag{
+	ID:           "instanceListFilterFlag",
+	Value:        &instanceListFilter,
+	DefaultValue: []string{},
+	Name:         "filter",
+	Usage:        "list only instances with a label, or label value, in label=<key>[=<value>] format",
+	Tag:          "<filter>",
+	StringArray:  true,
+}
+
 // singularity instance list
 var instanceListCmd = &cobra.Command{
 	Args: cobra.RangeArgs(0, 1),
@@ -81,7 +96,12 @@ var instanceListCmd = &cobra.Command{
 			sylog.Fatalf("Only root user can list user's instances")
 		}
 
-		err := singularity.PrintInstanceList(os.Stdout, name, instanceListUser, instanceListJSON, instanceListLogs)
+		filters, err := instance.ParseFilters(instanceListFilter)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+
+		err = singularity.PrintInstanceList(os.Stdout, name, instanceListUser, filters, instanceListJSON, instanceListLogs)
 		if err != nil {
 			sylog.Fatalf("Could not list instances: %v", err)
 		}
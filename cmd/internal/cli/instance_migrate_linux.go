@@ -0,0 +1,89 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&instanceMigrateUserFlag, instanceMigrateCmd)
+		cmdManager.RegisterFlagForCmd(&instanceRestoreMonitorFlag, instanceRestoreCmd)
+	})
+}
+
+// -u|--user
+var instanceMigrateUser string
+
+var instanceMigrateUserFlag = cmdline.Flag{
+	ID:           "instanceMigrateUserFlag",
+	Value:        &instanceMigrateUser,
+	DefaultValue: "",
+	Name:         "user",
+	ShortHand:    "u",
+	Usage:        "migrate an instance belonging to user",
+	Tag:          "<username>",
+	EnvKeys:      []string{"USER"},
+}
+
+// --monitor
+var instanceRestoreMonitor bool
+
+var instanceRestoreMonitorFlag = cmdline.Flag{
+	ID:           "instanceRestoreMonitorFlag",
+	Value:        &instanceRestoreMonitor,
+	DefaultValue: false,
+	Name:         "monitor",
+	Usage:        "restore the instance, and monitor it until it exits",
+	Hidden:       true,
+}
+
+// singularity instance migrate
+var instanceMigrateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if isOCI {
+			sylog.Fatalf("Instances are not yet supported in OCI-mode. Omit --oci, or use --no-oci, to manage a non-OCI Singularity instance.")
+		}
+		if os.Geteuid() != 0 {
+			sylog.Fatalf("Only root user can migrate instances, as CRIU requires root privileges")
+		}
+		return singularity.MigrateInstance(cmd.Context(), instance.ExtractName(args[0]), instanceMigrateUser, args[1])
+	},
+
+	Use:     docs.InstanceMigrateUse,
+	Short:   docs.InstanceMigrateShort,
+	Long:    docs.InstanceMigrateLong,
+	Example: docs.InstanceMigrateExample,
+}
+
+// singularity instance restore, run on the destination node of a migration
+var instanceRestoreCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if os.Geteuid() != 0 {
+			sylog.Fatalf("Only root user can restore migrated instances, as CRIU requires root privileges")
+		}
+		if instanceRestoreMonitor {
+			return singularity.MonitorInstance(cmd.Context(), args[0])
+		}
+		return singularity.RestoreInstance(args[0])
+	},
+
+	Hidden: true,
+	Use:    "restore <checkpoint directory>",
+	Short:  "Restore an instance migrated from another node",
+}
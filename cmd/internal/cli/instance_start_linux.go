@@ -6,9 +6,12 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/v4/docs"
 	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
@@ -17,6 +20,7 @@ import (
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterFlagForCmd(&instanceStartPidFileFlag, instanceStartCmd)
+		cmdManager.RegisterFlagForCmd(&instanceStartNamePrefixFlag, instanceStartCmd)
 	})
 }
 
@@ -32,9 +36,28 @@ var instanceStartPidFileFlag = cmdline.Flag{
 	EnvKeys:      []string{"PID_FILE"},
 }
 
+// --name-prefix
+var instanceStartNamePrefix string
+
+var instanceStartNamePrefixFlag = cmdline.Flag{
+	ID:           "instanceStartNamePrefixFlag",
+	Value:        &instanceStartNamePrefix,
+	DefaultValue: "",
+	Name:         "name-prefix",
+	Usage:        "omit the instance name, and name the instance <prefix>-<N>, with the lowest N not in use. The name is printed on stdout",
+	Tag:          "<prefix>",
+	EnvKeys:      []string{"NAME_PREFIX"},
+}
+
 // singularity instance start
 var instanceStartCmd = &cobra.Command{
-	Args:                  cobra.MinimumNArgs(2),
+	Args: func(cmd *cobra.Command, args []string) error {
+		// the instance name is generated with --name-prefix
+		if instanceStartNamePrefix != "" {
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
 	PreRun:                actionPreRun,
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -43,15 +66,28 @@ var instanceStartCmd = &cobra.Command{
 		}
 
 		ep := launcher.ExecParams{
-			Image:    args[0],
-			Action:   "start",
-			Instance: args[1],
-			Args:     args[2:],
+			Image:  args[0],
+			Action: "start",
+		}
+		if instanceStartNamePrefix != "" {
+			name, err := instance.GenerateName(instanceStartNamePrefix, instance.SingSubDir)
+			if err != nil {
+				sylog.Fatalf("While generating instance name: %s", err)
+			}
+			ep.Instance = name
+			ep.Args = args[1:]
+		} else {
+			ep.Instance = args[1]
+			ep.Args = args[2:]
 		}
 		if err := launchContainer(cmd, ep); err != nil {
 			sylog.Fatalf("%s", err)
 		}
 
+		if instanceStartNamePrefix != "" {
+			fmt.Println(ep.Instance)
+		}
+
 		if instanceStartPidFile != "" {
 			err := singularity.WriteInstancePidFile(ep.Instance, instanceStartPidFile)
 			if err != nil {
@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2020, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -14,6 +14,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/v4/docs"
 	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/signal"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
@@ -26,6 +27,7 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&instanceStopForceFlag, instanceStopCmd)
 		cmdManager.RegisterFlagForCmd(&instanceStopSignalFlag, instanceStopCmd)
 		cmdManager.RegisterFlagForCmd(&instanceStopTimeoutFlag, instanceStopCmd)
+		cmdManager.RegisterFlagForCmd(&instanceStopFilterFlag, instanceStopCmd)
 	})
 }
 
@@ -95,6 +97,19 @@ var instanceStopTimeoutFlag = cmdline.Flag{
 	Usage:        "force kill non stopped instances after X seconds",
 }
 
+// --filter
+var instanceStopFilter []string
+
+var instanceStopFilterFlag = cmdline.Flag{
+	ID:           "instanceStopFilterFlag",
+	Value:        &instanceStopFilter,
+	DefaultValue: []string{},
+	Name:         "filter",
+	Usage:        "stop only instances with a label, or label value, in label=<key>[=<value>] format",
+	Tag:          "<filter>",
+	StringArray:  true,
+}
+
 // singularity instance stop
 var instanceStopCmd = &cobra.Command{
 	Args:                  cobra.RangeArgs(0, 1),
@@ -104,10 +119,15 @@ var instanceStopCmd = &cobra.Command{
 			sylog.Fatalf("Instances are not yet supported in OCI-mode. Omit --oci, or use --no-oci, to manage a non-OCI Singularity instance.")
 		}
 
-		if len(args) == 0 && !instanceStopAll {
+		if len(args) == 0 && !instanceStopAll && len(instanceStopFilter) == 0 {
 			return errors.New("invalid command")
 		}
 
+		filters, err := instance.ParseFilters(instanceStopFilter)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+
 		uid := os.Getuid()
 		if instanceStopUser != "" && uid != 0 {
 			sylog.Fatalf("Only root user can stop user's instances")
@@ -115,7 +135,6 @@ var instanceStopCmd = &cobra.Command{
 
 		sig := syscall.SIGINT
 		if instanceStopSignal != "" {
-			var err error
 			sig, err = signal.Convert(instanceStopSignal)
 			if err != nil {
 				sylog.Fatalf("Could not convert stop signal: %s", err)
@@ -131,7 +150,7 @@ var instanceStopCmd = &cobra.Command{
 		}
 
 		timeout := time.Duration(instanceStopTimeout) * time.Second
-		return singularity.StopInstance(name, instanceStopUser, sig, timeout)
+		return singularity.StopInstance(name, instanceStopUser, filters, sig, timeout)
 	},
 
 	Use:     docs.InstanceStopUse,
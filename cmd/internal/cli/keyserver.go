@@ -97,6 +97,9 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&keyserverOrderFlag, KeyserverAddCmd)
 		cmdManager.RegisterFlagForCmd(&keyserverInsecureFlag, KeyserverAddCmd)
 
+		cmdManager.RegisterFlagForCmd(&listFormatFlag, KeyserverListCmd)
+		cmdManager.RegisterFlagForCmd(&listJSONFlag, KeyserverListCmd)
+
 		cmdManager.RegisterFlagForCmd(&keyserverLoginUsernameFlag, KeyserverLoginCmd)
 		cmdManager.RegisterFlagForCmd(&keyserverLoginPasswordFlag, KeyserverLoginCmd)
 		cmdManager.RegisterFlagForCmd(&keyserverLoginPasswordStdinFlag, KeyserverLoginCmd)
@@ -224,7 +227,7 @@ var KeyserverListCmd = &cobra.Command{
 		if len(args) > 0 {
 			remoteName = args[0]
 		}
-		if err := singularity.KeyserverList(remoteName, remoteConfig); err != nil {
+		if err := singularity.KeyserverList(remoteName, remoteConfig, listOutputFormat()); err != nil {
 			sylog.Fatalf("%s", err)
 		}
 	},
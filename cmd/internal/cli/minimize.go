@@ -0,0 +1,98 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/minimize"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(MinimizeCmd)
+		cmdManager.RegisterFlagForCmd(&minimizeProfileFlag, MinimizeCmd)
+		cmdManager.RegisterFlagForCmd(&minimizeKeepFlag, MinimizeCmd)
+		cmdManager.RegisterFlagForCmd(&minimizeKeepFileFlag, MinimizeCmd)
+		cmdManager.RegisterFlagForCmd(&commonTmpDirFlag, MinimizeCmd)
+	})
+}
+
+var (
+	minimizeProfiles  []string
+	minimizeKeep      []string
+	minimizeKeepFiles []string
+)
+
+// --profile
+var minimizeProfileFlag = cmdline.Flag{
+	ID:           "minimizeProfileFlag",
+	Value:        &minimizeProfiles,
+	DefaultValue: []string{},
+	Name:         "profile",
+	Usage:        "access profile of the files to keep, recorded with --profile-io, or listing one path per line (can be given multiple times)",
+	Tag:          "<path>",
+}
+
+// --keep
+var minimizeKeepFlag = cmdline.Flag{
+	ID:           "minimizeKeepFlag",
+	Value:        &minimizeKeep,
+	DefaultValue: []string{},
+	Name:         "keep",
+	Usage:        "glob pattern of additional paths to keep, with all files below matching directories (can be given multiple times)",
+	Tag:          "<pattern>",
+}
+
+// --keep-file
+var minimizeKeepFileFlag = cmdline.Flag{
+	ID:           "minimizeKeepFileFlag",
+	Value:        &minimizeKeepFiles,
+	DefaultValue: []string{},
+	Name:         "keep-file",
+	Usage:        "file listing glob patterns of additional paths to keep, one per line (can be given multiple times)",
+	Tag:          "<path>",
+}
+
+// MinimizeCmd singularity minimize
+var MinimizeCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(minimizeProfiles) == 0 {
+			sylog.Fatalf("At least one access profile must be given with --profile")
+		}
+
+		r, err := minimize.Minimize(args[0], args[1], minimize.Options{
+			Profiles:  minimizeProfiles,
+			Keep:      minimizeKeep,
+			KeepFiles: minimizeKeepFiles,
+			TmpDir:    tmpDir,
+		})
+		if err != nil {
+			sylog.Fatalf("While minimizing %s: %v", args[0], err)
+		}
+
+		if r.DroppedSignatures {
+			sylog.Warningf("Signatures of %s were not copied, %s must be signed again", args[0], args[1])
+		}
+		sylog.Infof("Kept %d files, removed %d files", r.Kept, r.Removed)
+		sylog.Infof("Image size %s -> %s (saved %s)",
+			units.BytesSize(float64(r.OldSize)),
+			units.BytesSize(float64(r.NewSize)),
+			units.BytesSize(float64(r.OldSize-r.NewSize)),
+		)
+	},
+
+	Use:     docs.MinimizeUse,
+	Short:   docs.MinimizeShort,
+	Long:    docs.MinimizeLong,
+	Example: docs.MinimizeExample,
+}
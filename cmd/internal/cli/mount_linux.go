@@ -0,0 +1,110 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/hostmount"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(MountCmd)
+		cmdManager.RegisterFlagForCmd(&mountJSONFlag, MountCmd)
+		cmdManager.RegisterCmd(UmountCmd)
+	})
+}
+
+// -j|--json
+var mountJSON bool
+
+var mountJSONFlag = cmdline.Flag{
+	ID:           "mountJSONFlag",
+	Value:        &mountJSON,
+	DefaultValue: false,
+	Name:         "json",
+	ShortHand:    "j",
+	Usage:        "list mounted images in JSON format",
+}
+
+// MountCmd singularity mount
+var MountCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return fmt.Errorf("requires an image path and a mount point, or no arguments to list mounts")
+		}
+		return nil
+	},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		r := hostmount.DefaultRegistry()
+
+		if len(args) == 0 {
+			if err := listMounts(r); err != nil {
+				sylog.Fatalf("While listing mounted images: %v", err)
+			}
+			return
+		}
+
+		rec, err := r.Mount(cmd.Context(), args[0], args[1])
+		if err != nil {
+			sylog.Fatalf("While mounting %s: %v", args[0], err)
+		}
+		sylog.Infof("%s mounted at %s", rec.Image, rec.MountPoint)
+	},
+
+	Use:     docs.MountUse,
+	Short:   docs.MountShort,
+	Long:    docs.MountLong,
+	Example: docs.MountExample,
+}
+
+// UmountCmd singularity umount
+var UmountCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := hostmount.DefaultRegistry().Unmount(cmd.Context(), args[0]); err != nil {
+			sylog.Fatalf("While unmounting %s: %v", args[0], err)
+		}
+	},
+
+	Use:     docs.UmountUse,
+	Short:   docs.UmountShort,
+	Long:    docs.UmountLong,
+	Example: docs.UmountExample,
+}
+
+func listMounts(r *hostmount.Registry) error {
+	records, err := r.List()
+	if err != nil {
+		return err
+	}
+
+	if mountJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "MOUNT POINT\tIMAGE\tTYPE\tMOUNTED")
+	for _, rec := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", rec.MountPoint, rec.Image, rec.FSType, rec.Created.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
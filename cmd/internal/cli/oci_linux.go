@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/v4/docs"
 	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher/oci"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
@@ -101,6 +102,59 @@ var ociUpdateFromFileFlag = cmdline.Flag{
 	EnvKeys:      []string{"FROM_FILE"},
 }
 
+// --interval
+var ociEventsIntervalFlag = cmdline.Flag{
+	ID:           "ociEventsIntervalFlag",
+	Value:        &ociArgs.EventsInterval,
+	DefaultValue: uint32(5),
+	Name:         "interval",
+	Usage:        "set the interval, in seconds, between resource usage statistics",
+	EnvKeys:      []string{"INTERVAL"},
+}
+
+// --stats
+var ociEventsStatsFlag = cmdline.Flag{
+	ID:           "ociEventsStatsFlag",
+	Value:        &ociArgs.EventsStats,
+	DefaultValue: false,
+	Name:         "stats",
+	Usage:        "display the container resource usage statistics once, and exit",
+	EnvKeys:      []string{"STATS"},
+}
+
+// --format
+var ociPsFormatFlag = cmdline.Flag{
+	ID:           "ociPsFormatFlag",
+	Value:        &ociArgs.PsFormat,
+	DefaultValue: "table",
+	Name:         "format",
+	Usage:        "select the output format, table or json",
+	Tag:          "<format>",
+	EnvKeys:      []string{"FORMAT"},
+}
+
+// --root
+var ociSnapshotterRootFlag = cmdline.Flag{
+	ID:           "ociSnapshotterRootFlag",
+	Value:        &ociArgs.SnapshotterRoot,
+	DefaultValue: buildcfg.LOCALSTATEDIR + "/singularity/oci-sif-snapshotter",
+	Name:         "root",
+	Usage:        "specify the directory holding snapshots and layers",
+	Tag:          "<path>",
+	EnvKeys:      []string{"SNAPSHOTTER_ROOT"},
+}
+
+// --address
+var ociSnapshotterAddressFlag = cmdline.Flag{
+	ID:           "ociSnapshotterAddressFlag",
+	Value:        &ociArgs.SnapshotterAddress,
+	DefaultValue: buildcfg.RUNSTATEDIR + "/singularity/oci-sif-snapshotter.sock",
+	Name:         "address",
+	Usage:        "specify the unix socket containerd connects to",
+	Tag:          "<path>",
+	EnvKeys:      []string{"SNAPSHOTTER_ADDRESS"},
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(OciCmd)
@@ -115,8 +169,11 @@ func init() {
 		cmdManager.RegisterSubCmd(OciCmd, OciUpdateCmd)
 		cmdManager.RegisterSubCmd(OciCmd, OciPauseCmd)
 		cmdManager.RegisterSubCmd(OciCmd, OciResumeCmd)
+		cmdManager.RegisterSubCmd(OciCmd, OciEventsCmd)
+		cmdManager.RegisterSubCmd(OciCmd, OciPsCmd)
 		cmdManager.RegisterSubCmd(OciCmd, OciMountCmd)
 		cmdManager.RegisterSubCmd(OciCmd, OciUmountCmd)
+		cmdManager.RegisterSubCmd(OciCmd, OciSnapshotterCmd)
 
 		cmdManager.SetCmdGroup("create_run", OciCreateCmd, OciRunCmd)
 		createRunCmd := cmdManager.GetCmdGroup("create_run")
@@ -128,6 +185,11 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&ociKillForceFlag, OciKillCmd)
 		cmdManager.RegisterFlagForCmd(&ociKillSignalFlag, OciKillCmd)
 		cmdManager.RegisterFlagForCmd(&ociUpdateFromFileFlag, OciUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&ociEventsIntervalFlag, OciEventsCmd)
+		cmdManager.RegisterFlagForCmd(&ociEventsStatsFlag, OciEventsCmd)
+		cmdManager.RegisterFlagForCmd(&ociPsFormatFlag, OciPsCmd)
+		cmdManager.RegisterFlagForCmd(&ociSnapshotterRootFlag, OciSnapshotterCmd)
+		cmdManager.RegisterFlagForCmd(&ociSnapshotterAddressFlag, OciSnapshotterCmd)
 	})
 }
 
@@ -352,6 +414,54 @@ var OciUmountCmd = &cobra.Command{
 	Example: docs.OciUmountExample,
 }
 
+// OciEventsCmd represents oci events command.
+var OciEventsCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                CheckRoot,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.OciEvents(cmd.Context(), args[0], &ociArgs); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciEventsUse,
+	Short:   docs.OciEventsShort,
+	Long:    docs.OciEventsLong,
+	Example: docs.OciEventsExample,
+}
+
+// OciPsCmd represents oci ps command.
+var OciPsCmd = &cobra.Command{
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                CheckRoot,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.OciPs(args[0], args[1:], &ociArgs); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciPsUse,
+	Short:   docs.OciPsShort,
+	Long:    docs.OciPsLong,
+	Example: docs.OciPsExample,
+}
+
+// OciSnapshotterCmd represents oci snapshotter command.
+var OciSnapshotterCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(0),
+	DisableFlagsInUseLine: true,
+	PreRun:                CheckRoot,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.OciSnapshotter(cmd.Context(), &ociArgs); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciSnapshotterUse,
+	Short:   docs.OciSnapshotterShort,
+	Long:    docs.OciSnapshotterLong,
+	Example: docs.OciSnapshotterExample,
+}
+
 // OciCmd singularity oci runtime.
 var OciCmd = &cobra.Command{
 	Run:                   nil,
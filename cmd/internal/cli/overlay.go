@@ -12,10 +12,15 @@ func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(OverlayCmd)
 		cmdManager.RegisterSubCmd(OverlayCmd, OverlayCreateCmd)
+		cmdManager.RegisterSubCmd(OverlayCmd, OverlayDiffCmd)
 
 		cmdManager.RegisterFlagForCmd(&overlaySizeFlag, OverlayCreateCmd)
 		cmdManager.RegisterFlagForCmd(&overlayCreateDirFlag, OverlayCreateCmd)
 		cmdManager.RegisterFlagForCmd(&overlaySparseFlag, OverlayCreateCmd)
+
+		cmdManager.RegisterFlagForCmd(&overlayDiffBaseFlag, OverlayDiffCmd)
+		cmdManager.RegisterFlagForCmd(&overlayDiffExportFlag, OverlayDiffCmd)
+		cmdManager.RegisterFlagForCmd(&overlayDiffOutputFlag, OverlayDiffCmd)
 	})
 }
 
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+var (
+	overlayDiffBase   string
+	overlayDiffExport string
+	overlayDiffOutput string
+)
+
+// --base
+var overlayDiffBaseFlag = cmdline.Flag{
+	ID:           "overlayDiffBaseFlag",
+	Value:        &overlayDiffBase,
+	DefaultValue: "",
+	Name:         "base",
+	Usage:        "base image the overlay applies to (defaults to the image itself for a SIF embedded overlay)",
+}
+
+// --export
+var overlayDiffExportFlag = cmdline.Flag{
+	ID:           "overlayDiffExportFlag",
+	Value:        &overlayDiffExport,
+	DefaultValue: "",
+	Name:         "export",
+	Usage:        "export the changed files instead of listing them, in the given format (tar)",
+}
+
+// -o|--output
+var overlayDiffOutputFlag = cmdline.Flag{
+	ID:           "overlayDiffOutputFlag",
+	Value:        &overlayDiffOutput,
+	DefaultValue: "",
+	Name:         "output",
+	ShortHand:    "o",
+	Usage:        "write output to a file instead of standard output",
+}
+
+// OverlayDiffCmd is the 'overlay diff' command that lists changes made by a writable overlay.
+var OverlayDiffCmd = &cobra.Command{
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var w io.Writer = os.Stdout
+		if overlayDiffOutput != "" {
+			f, err := os.Create(overlayDiffOutput)
+			if err != nil {
+				sylog.Fatalf("While creating %s: %v", overlayDiffOutput, err)
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := singularity.OverlayDiff(cmd.Context(), w, args[0], overlayDiffBase, overlayDiffExport); err != nil {
+			sylog.Fatalf(err.Error())
+		}
+		return nil
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.OverlayDiffUse,
+	Short:   docs.OverlayDiffShort,
+	Long:    docs.OverlayDiffLong,
+	Example: docs.OverlayDiffExample,
+}
@@ -7,6 +7,7 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -14,15 +15,19 @@ import (
 	"github.com/sylabs/singularity/v4/docs"
 	"github.com/sylabs/singularity/v4/internal/pkg/cache"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/library"
-	"github.com/sylabs/singularity/v4/internal/pkg/client/net"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/oci"
-	"github.com/sylabs/singularity/v4/internal/pkg/client/oras"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/shub"
+	uritransport "github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+	"github.com/sylabs/singularity/v4/internal/pkg/distribute"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/advisory"
 	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/cryptkey"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
 )
 
 const (
@@ -40,6 +45,10 @@ const (
 	OrasProtocol = "oras"
 	// Docker Registry protocol
 	DockerProtocol = "docker"
+	// DockerDaemonProtocol is the images of a local Docker daemon.
+	DockerDaemonProtocol = "docker-daemon"
+	// ContainersStorageProtocol is the local image storage of Podman.
+	ContainersStorageProtocol = "containers-storage"
 )
 
 var (
@@ -51,6 +60,16 @@ var (
 	unauthenticatedPull bool
 	// pullDir is the path that the containers will be pulled to, if set.
 	pullDir string
+	// pullEncrypt when true; encrypts the layers of a pulled OCI-SIF image.
+	pullEncrypt bool
+	// pullToAllNodes when true; copies the pulled image to the nodes of the job.
+	pullToAllNodes bool
+	// pullNodes is the hostlist of the nodes to copy the pulled image to.
+	pullNodes string
+	// pullNodefile is the path of a file listing the nodes to copy the pulled image to.
+	pullNodefile string
+	// pullFanout is the number of nodes each node copies the pulled image to.
+	pullFanout int
 )
 
 // --library
@@ -118,6 +137,56 @@ var pullAllowUnauthenticatedFlag = cmdline.Flag{
 	Hidden:       true,
 }
 
+// -e|--encrypt
+var pullEncryptFlag = cmdline.Flag{
+	ID:           "pullEncryptFlag",
+	Value:        &pullEncrypt,
+	DefaultValue: false,
+	Name:         "encrypt",
+	ShortHand:    "e",
+	Usage:        "encrypt the layers of the pulled OCI-SIF image (requires --oci)",
+}
+
+// --to-all-nodes
+var pullToAllNodesFlag = cmdline.Flag{
+	ID:           "pullToAllNodesFlag",
+	Value:        &pullToAllNodes,
+	DefaultValue: false,
+	Name:         "to-all-nodes",
+	Usage:        "copy the pulled image to the same path on all nodes of the job",
+	EnvKeys:      []string{"PULL_TO_ALL_NODES"},
+}
+
+// --nodes
+var pullNodesFlag = cmdline.Flag{
+	ID:           "pullNodesFlag",
+	Value:        &pullNodes,
+	DefaultValue: "",
+	Name:         "nodes",
+	Usage:        "nodes to copy the pulled image to with --to-all-nodes, as a hostlist (e.g. node[01-16])",
+	EnvKeys:      []string{"PULL_NODES"},
+}
+
+// --nodefile
+var pullNodefileFlag = cmdline.Flag{
+	ID:           "pullNodefileFlag",
+	Value:        &pullNodefile,
+	DefaultValue: "",
+	Name:         "nodefile",
+	Usage:        "file listing the nodes to copy the pulled image to with --to-all-nodes, one per line",
+	EnvKeys:      []string{"PULL_NODEFILE"},
+}
+
+// --fanout
+var pullFanoutFlag = cmdline.Flag{
+	ID:           "pullFanoutFlag",
+	Value:        &pullFanout,
+	DefaultValue: distribute.DefaultFanout,
+	Name:         "fanout",
+	Usage:        "number of nodes each node copies the pulled image to with --to-all-nodes",
+	EnvKeys:      []string{"PULL_FANOUT"},
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(PullCmd)
@@ -136,6 +205,8 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, PullCmd)
 
 		cmdManager.RegisterFlagForCmd(&buildNoCleanupFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&buildPrimeCacheFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&buildAccessProfileFlag, PullCmd)
 		cmdManager.RegisterFlagForCmd(&pullAllowUnsignedFlag, PullCmd)
 		cmdManager.RegisterFlagForCmd(&pullAllowUnauthenticatedFlag, PullCmd)
 
@@ -143,10 +214,22 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&commonNoOCIFlag, PullCmd)
 		cmdManager.RegisterFlagForCmd(&commonKeepLayersFlag, PullCmd)
 
+		cmdManager.RegisterFlagForCmd(&pullEncryptFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&commonPromptForPassphraseFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&commonPEMFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&commonKeyFileFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&commonKeyURIFlag, PullCmd)
+
 		cmdManager.RegisterFlagForCmd(&commonArchFlag, PullCmd)
 		cmdManager.RegisterFlagForCmd(&commonPlatformFlag, PullCmd)
 
 		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&commonResultJSONFlag, PullCmd)
+
+		cmdManager.RegisterFlagForCmd(&pullToAllNodesFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&pullNodesFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&pullNodefileFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&pullFanoutFlag, PullCmd)
 	})
 }
 
@@ -163,6 +246,7 @@ var PullCmd = &cobra.Command{
 
 func pullRun(cmd *cobra.Command, args []string) {
 	ctx := cmd.Context()
+	result := startImageResult()
 
 	imgCache := getCacheHandle(cache.Config{Disable: disableCache})
 	if imgCache == nil {
@@ -204,6 +288,52 @@ func pullRun(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// Find the nodes to copy the image to before pulling it, so that a missing
+	// node list doesn't waste a pull.
+	var peers []string
+	if pullToAllNodes {
+		if pullTo, err = filepath.Abs(pullTo); err != nil {
+			sylog.Fatalf("While determining image path: %v", err)
+		}
+		if peers, err = pullPeers(); err != nil {
+			sylog.Fatalf("While determining nodes to copy the image to: %v", err)
+		}
+	} else if pullNodes != "" || pullNodefile != "" {
+		sylog.Fatalf("--nodes and --nodefile require --to-all-nodes")
+	}
+
+	var keyInfo *cryptkey.KeyInfo
+	if pullEncrypt {
+		if !isOCI {
+			sylog.Fatalf("--encrypt is only supported when pulling to an OCI-SIF image with --oci")
+		}
+		if os.Getuid() != 0 {
+			sylog.Fatalf("You must be root to pull an encrypted container")
+		}
+		keyInfo, err = getEncryptionMaterial(cmd)
+		if err != nil {
+			sylog.Fatalf("While handling encryption material: %v", err)
+		}
+		if keyInfo == nil {
+			keyInfo, err = defaultKeyInfo()
+			if err != nil {
+				sylog.Fatalf("While handling encryption material: %v", err)
+			}
+		}
+		if keyInfo == nil {
+			sylog.Fatalf("--encrypt requires --passphrase, --pem-path, --key-file, --key-uri, an encryption environment variable, a key agent, a systemd credential, or an 'encryption key uri' in singularity.conf")
+		}
+	}
+
+	if buildArgs.primeCache || buildArgs.accessProfile != "" {
+		if isOCI || ocitransport.SupportedTransport(transport) == "" {
+			sylog.Fatalf("--prime-cache and --access-profile are only supported when converting an OCI image to a SIF image, without --oci")
+		}
+		if buildArgs.accessProfile != "" {
+			buildArgs.accessProfile = absAccessProfile(buildArgs.accessProfile)
+		}
+	}
+
 	switch transport {
 	case LibraryProtocol, "":
 		ref, err := library.NormalizeLibraryRef(pullFrom)
@@ -264,58 +394,125 @@ func pullRun(cmd *cobra.Command, args []string) {
 		if err != nil {
 			sylog.Fatalf("While pulling shub image: %v\n", err)
 		}
-	case OrasProtocol:
-		if isOCI {
-			sylog.Warningf("Pull from oras:// URIs is a direct download, --oci has no effect.")
-		}
-		if platform != "" || arch != "" {
-			sylog.Warningf("Pull from oras:// is a direct download, --arch and --platform have no effect.")
-		}
-
+	case ocitransport.SupportedTransport(transport):
 		ociAuth, err := makeOCICredentials(cmd)
 		if err != nil {
-			sylog.Fatalf("Unable to make docker oci credentials: %s", err)
+			sylog.Fatalf("While creating Docker credentials: %v", err)
+		}
+
+		pullOpts := oci.PullOptions{
+			TmpDir:        tmpDir,
+			OciAuth:       ociAuth,
+			DockerHost:    dockerHost,
+			NoHTTPS:       noHTTPS,
+			NoCleanUp:     buildArgs.noCleanUp,
+			OciSif:        isOCI,
+			KeepLayers:    keepLayers,
+			Platform:      getOCIPlatform(),
+			ReqAuthFile:   reqAuthFile,
+			PrimeCache:    buildArgs.primeCache,
+			AccessProfile: buildArgs.accessProfile,
 		}
 
-		_, err = oras.PullToFile(ctx, imgCache, pullTo, pullFrom, ociAuth, reqAuthFile)
+		_, err = oci.PullToFile(ctx, imgCache, pullTo, pullFrom, pullOpts)
 		if err != nil {
-			sylog.Fatalf("While pulling image from oci registry: %v", err)
+			sylog.Fatalf("While making image from oci registry: %v", err)
+		}
+	default:
+		tr, ok := uritransport.Lookup(transport)
+		if !ok {
+			sylog.Fatalf("Unsupported transport type: %s", transport)
 		}
-	case HTTPProtocol, HTTPSProtocol:
 		if isOCI {
-			sylog.Warningf("Pull from http[s]:// URIs is a direct download, --oci has no effect.")
+			sylog.Warningf("Pull from %s:// URIs is a direct download, --oci has no effect.", transport)
 		}
 		if platform != "" || arch != "" {
-			sylog.Warningf("Pull from http[s]:// is a direct download, --arch and --platform have no effect.")
+			sylog.Warningf("Pull from %s:// is a direct download, --arch and --platform have no effect.", transport)
 		}
 
-		_, err := net.PullToFile(ctx, imgCache, pullTo, pullFrom)
+		opts, err := transportOptions(cmd)
 		if err != nil {
-			sylog.Fatalf("While pulling from image from http(s): %v\n", err)
+			sylog.Fatalf("%v", err)
 		}
-	case ocitransport.SupportedTransport(transport):
-		ociAuth, err := makeOCICredentials(cmd)
-		if err != nil {
-			sylog.Fatalf("While creating Docker credentials: %v", err)
+		if _, err := tr.Fetch(ctx, imgCache, pullFrom, pullTo, opts); err != nil {
+			sylog.Fatalf("While pulling image from %s://: %v", transport, err)
 		}
+	}
 
-		pullOpts := oci.PullOptions{
-			TmpDir:      tmpDir,
-			OciAuth:     ociAuth,
-			DockerHost:  dockerHost,
-			NoHTTPS:     noHTTPS,
-			NoCleanUp:   buildArgs.noCleanUp,
-			OciSif:      isOCI,
-			KeepLayers:  keepLayers,
-			Platform:    getOCIPlatform(),
-			ReqAuthFile: reqAuthFile,
+	if err := checkImageAdvisory(pullTo, pullFrom); err != nil {
+		os.Remove(pullTo)
+		sylog.Fatalf("%v", err)
+	}
+
+	if keyInfo != nil {
+		if err := ocisif.EncryptOCISIF(pullTo, *keyInfo, tmpDir); err != nil {
+			// Don't leave an unencrypted image where an encrypted one was requested.
+			os.Remove(pullTo)
+			sylog.Fatalf("While encrypting OCI-SIF image: %v", err)
 		}
+	}
 
-		_, err = oci.PullToFile(ctx, imgCache, pullTo, pullFrom, pullOpts)
+	if len(peers) > 0 {
+		c, err := distribute.NewSSHCopier()
 		if err != nil {
-			sylog.Fatalf("While making image from oci registry: %v", err)
+			sylog.Fatalf("While copying image to nodes: %v", err)
 		}
-	default:
-		sylog.Fatalf("Unsupported transport type: %s", transport)
+		sylog.Infof("Copying image to %d nodes", len(peers))
+		if err := distribute.Distribute(ctx, c, pullTo, peers, pullFanout); err != nil {
+			sylog.Fatalf("While copying image to nodes: %v", err)
+		}
+	}
+
+	result.print(pullTo, pullFrom, imgCache)
+}
+
+// pullPeers returns the nodes to copy the pulled image to, other than the
+// local node, from --nodes and --nodefile, or from the environment of the job.
+func pullPeers() ([]string, error) {
+	var nodes []string
+	if pullNodes != "" {
+		hosts, err := distribute.ExpandHostlist(pullNodes)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, hosts...)
+	}
+	if pullNodefile != "" {
+		hosts, err := distribute.ReadNodefile(pullNodefile)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, hosts...)
+	}
+	if len(nodes) == 0 {
+		hosts, err := distribute.JobNodes()
+		if err != nil {
+			return nil, err
+		}
+		nodes = hosts
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes found: use --nodes or --nodefile outside of a SLURM or PBS job")
+	}
+
+	self, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	peers := distribute.Peers(nodes, self)
+	if len(peers) == 0 {
+		sylog.Infof("No other nodes to copy the image to")
+	}
+	return peers, nil
+}
+
+// checkImageAdvisory applies the 'image advisory policy' of singularity.conf
+// to any advisory attached to the image at path, which is referred to as name.
+func checkImageAdvisory(path, name string) error {
+	a, err := advisory.FromImage(path)
+	if err != nil {
+		sylog.Warningf("Unable to read advisories for image %s: %v", name, err)
+		return nil
 	}
+	return advisory.Check(a, name, singularityconf.GetCurrentConfig().ImageAdvisoryPolicy)
 }
@@ -7,14 +7,16 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/library"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/oci"
-	"github.com/sylabs/singularity/v4/internal/pkg/client/oras"
+	uritransport "github.com/sylabs/singularity/v4/internal/pkg/client/transport"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
 	"github.com/sylabs/singularity/v4/internal/pkg/signature"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
@@ -74,10 +76,12 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&pushDescriptionFlag, PushCmd)
 
 		cmdManager.RegisterFlagForCmd(&dockerHostFlag, PushCmd)
+		cmdManager.RegisterFlagForCmd(&commonTmpDirFlag, PushCmd)
 		cmdManager.RegisterFlagForCmd(&dockerUsernameFlag, PushCmd)
 		cmdManager.RegisterFlagForCmd(&dockerPasswordFlag, PushCmd)
 
 		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, PushCmd)
+		cmdManager.RegisterFlagForCmd(&commonResultJSONFlag, PushCmd)
 	})
 }
 
@@ -87,6 +91,8 @@ var PushCmd = &cobra.Command{
 	Args:                  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		file, dest := args[0], args[1]
+		result := startImageResult()
+		defer result.print(file, dest, nil)
 
 		transport, ref := uri.Split(dest)
 		if transport == "" {
@@ -133,6 +139,12 @@ var PushCmd = &cobra.Command{
 				Endpoint:      currentRemoteEndpoint,
 				LibraryConfig: lc,
 			}
+			// Interrupted uploads of large images are resumed, unless the cache is disabled.
+			if h := getCacheHandle(cache.Config{Disable: disableCache}); !h.IsDisabled() {
+				if dir, err := h.GetFileCacheDir(cache.UploadCacheType); err == nil {
+					pushCfg.SessionDir = dir
+				}
+			}
 
 			resp, err := library.Push(cmd.Context(), file, destRef, pushCfg)
 			if err != nil {
@@ -162,35 +174,54 @@ var PushCmd = &cobra.Command{
 				fmt.Printf("Container URL: %s\n", feURL+"/"+strings.TrimPrefix(resp.ContainerURL, "/"))
 			}
 
-		case OrasProtocol:
+		case DockerProtocol:
 			if cmd.Flag(pushDescriptionFlag.Name).Changed {
-				sylog.Warningf("Description is not supported for push to oras. Ignoring it.")
+				sylog.Warningf("Description is not supported for push to docker / OCI registries. Ignoring it.")
 			}
 			ociAuth, err := makeOCICredentials(cmd)
 			if err != nil {
 				sylog.Fatalf("Unable to make docker oci credentials: %s", err)
 			}
-
-			if err := oras.UploadImage(cmd.Context(), file, ref, ociAuth, reqAuthFile); err != nil {
+			if err := oci.Push(cmd.Context(), file, ref, ociAuth, reqAuthFile); err != nil {
 				sylog.Fatalf("Unable to push image to oci registry: %v", err)
 			}
 			sylog.Infof("Upload complete")
 
-		case DockerProtocol:
+		case DockerDaemonProtocol, ContainersStorageProtocol:
 			if cmd.Flag(pushDescriptionFlag.Name).Changed {
-				sylog.Warningf("Description is not supported for push to docker / OCI registries. Ignoring it.")
+				sylog.Warningf("Description is not supported for push to %s. Ignoring it.", transport)
 			}
-			ociAuth, err := makeOCICredentials(cmd)
-			if err != nil {
-				sylog.Fatalf("Unable to make docker oci credentials: %s", err)
+			var err error
+			if transport == DockerDaemonProtocol {
+				err = oci.PushDaemon(cmd.Context(), file, ref, dockerHost, tmpDir)
+			} else {
+				err = oci.PushContainersStorage(cmd.Context(), file, ref, tmpDir)
 			}
-			if err := oci.Push(cmd.Context(), file, ref, ociAuth, reqAuthFile); err != nil {
-				sylog.Fatalf("Unable to push image to oci registry: %v", err)
+			if err != nil {
+				sylog.Fatalf("Unable to load image into %s: %v", transport, err)
 			}
-			sylog.Infof("Upload complete")
+			sylog.Infof("Image loaded")
 
 		default:
-			sylog.Fatalf("Unsupported transport type: %s", transport)
+			tr, ok := uritransport.Lookup(transport)
+			if !ok {
+				sylog.Fatalf("Unsupported transport type: %s", transport)
+			}
+			if cmd.Flag(pushDescriptionFlag.Name).Changed {
+				sylog.Warningf("Description is not supported for push to %s://. Ignoring it.", transport)
+			}
+			opts, err := transportOptions(cmd)
+			if err != nil {
+				sylog.Fatalf("%v", err)
+			}
+
+			if err := tr.Push(cmd.Context(), file, dest, opts); err != nil {
+				if errors.Is(err, uritransport.ErrUnsupported) {
+					sylog.Fatalf("Push to %s:// URIs is not supported", transport)
+				}
+				sylog.Fatalf("Unable to push image to %s://: %v", transport, err)
+			}
+			sylog.Infof("Upload complete")
 		}
 	},
 
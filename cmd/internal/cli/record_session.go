@@ -0,0 +1,102 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/recording"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/term"
+)
+
+// recordingEnv is set in the environment of the singularity process that is
+// run, in a pseudo-terminal, by a session recording singularity process.
+const recordingEnv = "SINGULARITY_RECORDING_SESSION"
+
+// recordSession handles --record-session. Unless this process is already
+// being recorded, singularity is run again with the same arguments, in a
+// session that is recorded to recordSessionDir, and the process exits with
+// the exit code of the session. Otherwise recordSession returns, and the
+// container is launched in the recorded session.
+func recordSession(image string) error {
+	if os.Getenv(recordingEnv) != "" {
+		os.Unsetenv(recordingEnv)
+		return nil
+	}
+	if recordInput && recordSessionDir == "" {
+		return fmt.Errorf("--record-keystrokes requires --record-session")
+	}
+	if recordSessionDir == "" {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("--record-session requires an interactive terminal")
+	}
+
+	md := recording.Metadata{
+		UID:     os.Getuid(),
+		Command: os.Args,
+		Image:   image,
+	}
+	if u, err := user.Current(); err == nil {
+		md.User = u.Username
+	} else {
+		md.User = strconv.Itoa(md.UID)
+	}
+	if host, err := os.Hostname(); err == nil {
+		md.Host = host
+	}
+
+	var digest func() (string, error)
+	if fs.IsFile(image) {
+		digest = func() (string, error) {
+			return fileDigest(image)
+		}
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("while finding singularity executable: %w", err)
+	}
+	cmd := exec.Command(self, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), recordingEnv+"=1")
+
+	mdPath, code, err := recording.Run(cmd, os.Stdin, os.Stdout, recording.Options{
+		Dir:        recordSessionDir,
+		Keystrokes: recordInput,
+		Metadata:   md,
+		Digest:     digest,
+	})
+	if err != nil {
+		return fmt.Errorf("while recording session: %w", err)
+	}
+	sylog.Infof("Session recorded to %s", mdPath)
+	os.Exit(code)
+	return nil
+}
+
+// fileDigest returns the sha256 digest of the file at path.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
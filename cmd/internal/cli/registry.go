@@ -65,6 +65,9 @@ func init() {
 		// default location of the remote.yaml file is the user directory
 		cmdManager.RegisterFlagForCmd(&registryConfigFlag, RegistryCmd)
 
+		cmdManager.RegisterFlagForCmd(&listFormatFlag, RegistryListCmd)
+		cmdManager.RegisterFlagForCmd(&listJSONFlag, RegistryListCmd)
+
 		cmdManager.RegisterFlagForCmd(&registryLoginUsernameFlag, RegistryLoginCmd)
 		cmdManager.RegisterFlagForCmd(&registryLoginPasswordFlag, RegistryLoginCmd)
 		cmdManager.RegisterFlagForCmd(&registryLoginPasswordStdinFlag, RegistryLoginCmd)
@@ -131,7 +134,7 @@ var RegistryLogoutCmd = &cobra.Command{
 var RegistryListCmd = &cobra.Command{
 	Args: cobra.ExactArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := singularity.RegistryList(remoteConfig); err != nil {
+		if err := singularity.RegistryList(remoteConfig, listOutputFormat()); err != nil {
 			sylog.Fatalf("%s", err)
 		}
 	},
@@ -38,6 +38,8 @@ var (
 	remoteUseExclusive  bool
 	remoteAddInsecure   bool
 	remoteAddNotDefault bool
+	listFormat          string
+	listJSON            bool
 )
 
 // assemble values of remoteConfig for user/sys locations
@@ -53,6 +55,37 @@ var remoteGlobalFlag = cmdline.Flag{
 	Usage:        "edit the list of globally configured remote endpoints",
 }
 
+// --format
+var listFormatFlag = cmdline.Flag{
+	ID:           "listFormatFlag",
+	Value:        &listFormat,
+	DefaultValue: string(singularity.ListFormatText),
+	Name:         "format",
+	Usage:        "output format: text, json or yaml",
+}
+
+// -j|--json
+var listJSONFlag = cmdline.Flag{
+	ID:           "listJSONFlag",
+	Value:        &listJSON,
+	DefaultValue: false,
+	Name:         "json",
+	ShortHand:    "j",
+	Usage:        "print output in JSON format (same as --format json)",
+}
+
+// listOutputFormat returns the output format selected for a listing command.
+func listOutputFormat() singularity.ListFormat {
+	if listJSON {
+		return singularity.ListFormatJSON
+	}
+	f, err := singularity.ParseListFormat(listFormat)
+	if err != nil {
+		sylog.Fatalf("%s", err)
+	}
+	return f
+}
+
 // -c|--config
 var remoteConfigFlag = cmdline.Flag{
 	ID:           "remoteConfigFlag",
@@ -163,6 +196,7 @@ func init() {
 		cmdManager.RegisterSubCmd(RemoteCmd, RemoteLoginCmd)
 		cmdManager.RegisterSubCmd(RemoteCmd, RemoteLogoutCmd)
 		cmdManager.RegisterSubCmd(RemoteCmd, RemoteStatusCmd)
+		cmdManager.RegisterSubCmd(RemoteCmd, RemotePingCmd)
 		cmdManager.RegisterSubCmd(RemoteCmd, RemoteGetLoginPasswordCmd)
 
 		// default location of the remote.yaml file is the user directory
@@ -175,6 +209,9 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&remoteNoLoginFlag, RemoteAddCmd)
 		cmdManager.RegisterFlagForCmd(&remoteAddInsecureFlag, RemoteAddCmd)
 		cmdManager.RegisterFlagForCmd(&remoteAddNotDefaultFlag, RemoteAddCmd)
+		// add --format, --json flags to list and ping commands
+		cmdManager.RegisterFlagForCmd(&listFormatFlag, RemoteListCmd, RemotePingCmd)
+		cmdManager.RegisterFlagForCmd(&listJSONFlag, RemoteListCmd, RemotePingCmd)
 
 		cmdManager.RegisterFlagForCmd(&remoteLoginUsernameFlag, RemoteLoginCmd)
 		cmdManager.RegisterFlagForCmd(&remoteLoginPasswordFlag, RemoteLoginCmd)
@@ -319,7 +356,7 @@ var RemoteUseCmd = &cobra.Command{
 var RemoteListCmd = &cobra.Command{
 	Args: cobra.ExactArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := singularity.RemoteList(remoteConfig); err != nil {
+		if err := singularity.RemoteList(remoteConfig, listOutputFormat()); err != nil {
 			sylog.Fatalf("%s", err)
 		}
 	},
@@ -416,3 +453,26 @@ var RemoteStatusCmd = &cobra.Command{
 
 	DisableFlagsInUseLine: true,
 }
+
+// RemotePingCmd singularity remote ping [remoteName]
+var RemotePingCmd = &cobra.Command{
+	Args: cobra.RangeArgs(0, 1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// default to empty string to signal to RemotePing to use default remote
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		if err := singularity.RemotePing(remoteConfig, name, listOutputFormat()); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+
+	Use:     docs.RemotePingUse,
+	Short:   docs.RemotePingShort,
+	Long:    docs.RemotePingLong,
+	Example: docs.RemotePingExample,
+
+	DisableFlagsInUseLine: true,
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
+	"github.com/sylabs/singularity/v4/internal/pkg/scan"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(ScanCmd)
+		cmdManager.RegisterFlagForCmd(&scanScannerFlag, ScanCmd)
+		cmdManager.RegisterFlagForCmd(&scanSBOMFlag, ScanCmd)
+		cmdManager.RegisterFlagForCmd(&scanFailOnFlag, ScanCmd)
+		cmdManager.RegisterFlagForCmd(&scanJSONFlag, ScanCmd)
+		cmdManager.RegisterFlagForCmd(&commonTmpDirFlag, ScanCmd)
+	})
+}
+
+var (
+	scanScanner string
+	scanSBOM    string
+	scanFailOn  string
+	scanJSON    bool
+)
+
+// --scanner
+var scanScannerFlag = cmdline.Flag{
+	ID:           "scanScannerFlag",
+	Value:        &scanScanner,
+	DefaultValue: "grype",
+	Name:         "scanner",
+	Usage:        "scanner to use: grype, trivy, or the path to a scanner executable",
+	EnvKeys:      []string{"SCANNER"},
+}
+
+// --sbom
+var scanSBOMFlag = cmdline.Flag{
+	ID:           "scanSBOMFlag",
+	Value:        &scanSBOM,
+	DefaultValue: "",
+	Name:         "sbom",
+	Usage:        "write the CycloneDX JSON SBOM of the image to this path",
+}
+
+// --fail-on
+var scanFailOnFlag = cmdline.Flag{
+	ID:           "scanFailOnFlag",
+	Value:        &scanFailOn,
+	DefaultValue: "",
+	Name:         "fail-on",
+	Usage:        "exit with an error if vulnerabilities of this severity, or higher, are found (negligible, low, medium, high, critical)",
+	EnvKeys:      []string{"SCAN_FAIL_ON"},
+}
+
+// --json
+var scanJSONFlag = cmdline.Flag{
+	ID:           "scanJSONFlag",
+	Value:        &scanJSON,
+	DefaultValue: false,
+	Name:         "json",
+	ShortHand:    "j",
+	Usage:        "print results in JSON format",
+}
+
+// ScanCmd singularity scan
+var ScanCmd = &cobra.Command{
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		doScanCmd(cmd, args[0])
+	},
+
+	Use:     docs.ScanUse,
+	Short:   docs.ScanShort,
+	Long:    docs.ScanLong,
+	Example: docs.ScanExample,
+}
+
+func doScanCmd(cmd *cobra.Command, path string) {
+	failOn := scan.SeverityUnknown
+	if scanFailOn != "" {
+		var err error
+		if failOn, err = scan.ParseSeverity(scanFailOn); err != nil {
+			sylog.Fatalf("While parsing --fail-on: %v", err)
+		}
+	}
+
+	backend, err := scan.NewBackend(scanScanner)
+	if err != nil {
+		sylog.Fatalf("While initializing scanner: %v", err)
+	}
+
+	vulns, err := singularity.Scan(cmd.Context(), path, singularity.ScanOptions{
+		Backend:  backend,
+		SBOMPath: scanSBOM,
+		TmpDir:   tmpDir,
+	})
+	if err != nil {
+		sylog.Fatalf("While scanning %s: %v", path, err)
+	}
+
+	if err := singularity.WriteScanResults(os.Stdout, vulns, scanJSON); err != nil {
+		sylog.Fatalf("While writing results: %v", err)
+	}
+
+	if scanFailOn == "" {
+		return
+	}
+	if n := len(scan.AtLeast(vulns, failOn)); n > 0 {
+		sylog.Fatalf("Found %d vulnerabilities with severity %s or higher", n, failOn)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/compact"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+var (
+	sifCompactCompression string
+	sifCompactAlignment   int
+)
+
+// --compression
+var sifCompactCompressionFlag = cmdline.Flag{
+	ID:           "sifCompactCompressionFlag",
+	Value:        &sifCompactCompression,
+	DefaultValue: "",
+	Name:         "compression",
+	Usage:        "re-compress unsigned squashfs partitions with this mksquashfs algorithm (e.g. gzip, xz, zstd)",
+}
+
+// --alignment
+var sifCompactAlignmentFlag = cmdline.Flag{
+	ID:           "sifCompactAlignmentFlag",
+	Value:        &sifCompactAlignment,
+	DefaultValue: 0,
+	Name:         "alignment",
+	Usage:        "align partitions to this number of bytes (default 4096)",
+}
+
+// SIFCompactCmd is the 'sif compact' command that removes unused data objects
+// from a SIF image.
+var SIFCompactCmd = &cobra.Command{
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if sifCompactAlignment < 0 || sifCompactAlignment&(sifCompactAlignment-1) != 0 {
+			sylog.Fatalf("Alignment must be a power of two, got %d", sifCompactAlignment)
+		}
+
+		r, err := compact.Compact(args[0], compact.Options{
+			Compression: sifCompactCompression,
+			Alignment:   sifCompactAlignment,
+			TmpDir:      tmpDir,
+		})
+		if err != nil {
+			sylog.Fatalf("While compacting %s: %v", args[0], err)
+		}
+
+		if len(r.Dropped) > 0 {
+			sylog.Infof("Dropped data objects %s", idList(r.Dropped))
+		}
+		if len(r.Recompressed) > 0 {
+			sylog.Infof("Re-compressed partitions %s", idList(r.Recompressed))
+		}
+		if len(r.SkippedSigned) > 0 {
+			sylog.Warningf("Partitions %s are signed, and were not re-compressed", idList(r.SkippedSigned))
+		}
+		sylog.Infof("Image size %s -> %s (saved %s)",
+			units.BytesSize(float64(r.OldSize)),
+			units.BytesSize(float64(r.NewSize)),
+			units.BytesSize(float64(r.OldSize-r.NewSize)),
+		)
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.SIFCompactUse,
+	Short:   docs.SIFCompactShort,
+	Long:    docs.SIFCompactLong,
+	Example: docs.SIFCompactExample,
+}
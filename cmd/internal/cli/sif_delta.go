@@ -0,0 +1,60 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/delta"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// SIFDiffCmd is the 'sif diff' command that creates a delta between two
+// versions of a SIF image.
+var SIFDiffCmd = &cobra.Command{
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := delta.Create(args[0], args[1], args[2])
+		if err != nil {
+			sylog.Fatalf("While creating delta from %s to %s: %v", args[0], args[1], err)
+		}
+
+		if len(r.Reused) > 0 {
+			sylog.Infof("Data objects %s are read from the base image", idList(r.Reused))
+		}
+		if len(r.Added) > 0 {
+			sylog.Infof("Data objects %s are held in the delta", idList(r.Added))
+		}
+		sylog.Infof("Delta size %s, target image size %s",
+			units.BytesSize(float64(r.DeltaSize)),
+			units.BytesSize(float64(r.TargetSize)),
+		)
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.SIFDiffUse,
+	Short:   docs.SIFDiffShort,
+	Long:    docs.SIFDiffLong,
+	Example: docs.SIFDiffExample,
+}
+
+// SIFPatchCmd is the 'sif patch' command that applies a delta to a SIF image.
+var SIFPatchCmd = &cobra.Command{
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := delta.Apply(args[0], args[1], args[2]); err != nil {
+			sylog.Fatalf("While applying delta %s to %s: %v", args[1], args[0], err)
+		}
+		sylog.Infof("Image written to %s", args[2])
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.SIFPatchUse,
+	Short:   docs.SIFPatchShort,
+	Long:    docs.SIFPatchLong,
+	Example: docs.SIFPatchExample,
+}
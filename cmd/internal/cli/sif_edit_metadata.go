@@ -0,0 +1,114 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/metadata"
+	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+var (
+	sifEditLabels       map[string]string
+	sifEditRemoveLabels []string
+	sifEditEnv          map[string]string
+	sifEditHelpFile     string
+)
+
+// --label
+var sifEditLabelFlag = cmdline.Flag{
+	ID:           "sifEditLabelFlag",
+	Value:        &sifEditLabels,
+	DefaultValue: map[string]string{},
+	Name:         "label",
+	Usage:        "add or replace a key=value container label",
+}
+
+// --remove-label
+var sifEditRemoveLabelFlag = cmdline.Flag{
+	ID:           "sifEditRemoveLabelFlag",
+	Value:        &sifEditRemoveLabels,
+	DefaultValue: []string{},
+	Name:         "remove-label",
+	Usage:        "remove a container label",
+}
+
+// --env
+var sifEditEnvFlag = cmdline.Flag{
+	ID:           "sifEditEnvFlag",
+	Value:        &sifEditEnv,
+	DefaultValue: map[string]string{},
+	Name:         "env",
+	Usage:        "set a KEY=VALUE variable in the metadata environment overlay",
+}
+
+// --help-file
+var sifEditHelpFileFlag = cmdline.Flag{
+	ID:           "sifEditHelpFileFlag",
+	Value:        &sifEditHelpFile,
+	DefaultValue: "",
+	Name:         "help-file",
+	Usage:        "replace the container help text with the content of a file",
+}
+
+// SIFEditMetadataCmd is the 'sif edit-metadata' command that edits the
+// container metadata of a SIF image in place.
+var SIFEditMetadataCmd = &cobra.Command{
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		e := metadata.Edit{
+			Labels:       sifEditLabels,
+			RemoveLabels: sifEditRemoveLabels,
+			Env:          sifEditEnv,
+		}
+		if cmd.Flags().Changed(sifEditHelpFileFlag.Name) {
+			b, err := os.ReadFile(sifEditHelpFile)
+			if err != nil {
+				sylog.Fatalf("While reading help file: %v", err)
+			}
+			e.Help = string(b)
+			e.SetHelp = true
+		}
+
+		r, err := metadata.EditSIF(args[0], e)
+		if err != nil {
+			sylog.Fatalf("While editing metadata of %s: %v", args[0], err)
+		}
+
+		if r.Replaced {
+			sylog.Infof("Replaced metadata in data object %d", r.ID)
+		} else {
+			sylog.Infof("Added metadata in data object %d", r.ID)
+		}
+		if r.Signed && len(r.Unsigned) > 0 {
+			sylog.Warningf("Data objects %s are not covered by any signature", idList(r.Unsigned))
+			sylog.Infof("The edited metadata can be signed with 'singularity sign --group-id %d'", r.GroupID)
+		}
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.SIFEditMetadataUse,
+	Short:   docs.SIFEditMetadataShort,
+	Long:    docs.SIFEditMetadataLong,
+	Example: docs.SIFEditMetadataExample,
+}
+
+// idList returns ids as a comma separated list.
+func idList(ids []uint32) string {
+	s := ""
+	for i, id := range ids {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprint(id)
+	}
+	return s
+}
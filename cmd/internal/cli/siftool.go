@@ -1,4 +1,4 @@
-// Copyright (c) 2019-2021, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -25,5 +25,19 @@ func init() {
 		siftool.AddCommands(cmd)
 
 		cmdManager.RegisterCmd(cmd)
+		cmdManager.RegisterSubCmd(cmd, SIFEditMetadataCmd)
+
+		cmdManager.RegisterFlagForCmd(&sifEditLabelFlag, SIFEditMetadataCmd)
+		cmdManager.RegisterFlagForCmd(&sifEditRemoveLabelFlag, SIFEditMetadataCmd)
+		cmdManager.RegisterFlagForCmd(&sifEditEnvFlag, SIFEditMetadataCmd)
+		cmdManager.RegisterFlagForCmd(&sifEditHelpFileFlag, SIFEditMetadataCmd)
+
+		cmdManager.RegisterSubCmd(cmd, SIFCompactCmd)
+		cmdManager.RegisterFlagForCmd(&sifCompactCompressionFlag, SIFCompactCmd)
+		cmdManager.RegisterFlagForCmd(&sifCompactAlignmentFlag, SIFCompactCmd)
+		cmdManager.RegisterFlagForCmd(&commonTmpDirFlag, SIFCompactCmd)
+
+		cmdManager.RegisterSubCmd(cmd, SIFDiffCmd)
+		cmdManager.RegisterSubCmd(cmd, SIFPatchCmd)
 	})
 }
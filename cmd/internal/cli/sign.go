@@ -7,15 +7,23 @@ package cli
 
 import (
 	"crypto"
+	"fmt"
+	"strings"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/sigstore/sigstore/pkg/signature"
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential/ociauth"
 	sifsignature "github.com/sylabs/singularity/v4/internal/pkg/signature"
 	"github.com/sylabs/singularity/v4/internal/pkg/sypgp"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
+	"github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
 )
 
 var (
@@ -106,6 +114,10 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&signPrivateKeyFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signKeyIdxFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signAllFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&dockerUsernameFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&dockerPasswordFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, SignCmd)
 	})
 }
 
@@ -126,6 +138,11 @@ var SignCmd = &cobra.Command{
 }
 
 func doSignCmd(cmd *cobra.Command, cpath string) {
+	if isCosignTarget(cpath) {
+		doCosignSignCmd(cmd, cpath)
+		return
+	}
+
 	var opts []sifsignature.SignOpt
 
 	// Set key material.
@@ -137,6 +154,9 @@ func doSignCmd(cmd *cobra.Command, cpath string) {
 		if err != nil {
 			sylog.Fatalf("Failed to load key material: %v", err)
 		}
+		if err := fips.CheckKey(s); err != nil {
+			sylog.Fatalf("Failed to load key material: %v", err)
+		}
 		opts = append(opts, sifsignature.OptSignWithSigner(s))
 
 	default:
@@ -169,3 +189,73 @@ func doSignCmd(cmd *cobra.Command, cpath string) {
 	}
 	sylog.Infof("Signature created and applied to image '%v'", cpath)
 }
+
+// isCosignTarget returns true if cpath is an OCI-SIF image, or an image in an OCI registry, which
+// are signed and verified with cosign compatible signatures.
+func isCosignTarget(cpath string) bool {
+	if strings.HasPrefix(cpath, DockerProtocol+"://") {
+		return true
+	}
+	isOCISIF, err := image.IsOCISIF(cpath)
+	return err == nil && isOCISIF
+}
+
+// cosignRemote returns the reference to the image in an OCI registry at cpath, and the options
+// with which to access the registry, if cpath is a docker:// URI.
+func cosignRemote(cmd *cobra.Command, cpath string) (name.Reference, []remote.Option, error) {
+	if !strings.HasPrefix(cpath, DockerProtocol+"://") {
+		return nil, nil, nil
+	}
+
+	ref, err := name.ParseReference(strings.TrimPrefix(cpath, DockerProtocol+"://"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid reference %q: %w", cpath, err)
+	}
+	ociAuth, err := makeOCICredentials(cmd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to make docker oci credentials: %w", err)
+	}
+	return ref, []remote.Option{
+		ociauth.AuthOptn(ociAuth, reqAuthFile),
+		remote.WithUserAgent(useragent.Value()),
+	}, nil
+}
+
+// doCosignSignCmd signs the OCI-SIF image, or image in an OCI registry, at cpath with a cosign
+// compatible signature.
+func doCosignSignCmd(cmd *cobra.Command, cpath string) {
+	if !cmd.Flag(signPrivateKeyFlag.Name).Changed {
+		sylog.Fatalf("OCI-SIF and OCI registry images can only be signed with --key")
+	}
+	if cmd.Flag(signSifGroupIDFlag.Name).Changed || cmd.Flag(signOldSifGroupIDFlag.Name).Changed ||
+		cmd.Flag(signSifDescSifIDFlag.Name).Changed || cmd.Flag(signSifDescIDFlag.Name).Changed {
+		sylog.Warningf("OCI-SIF and OCI registry images are signed by image digest, ignoring group and object selection")
+	}
+
+	sylog.Infof("Signing image with key material from '%v'", priKeyPath)
+
+	s, err := signature.LoadSignerFromPEMFile(priKeyPath, crypto.SHA256, cryptoutils.GetPasswordFromStdIn)
+	if err != nil {
+		sylog.Fatalf("Failed to load key material: %v", err)
+	}
+	if err := fips.CheckKey(s); err != nil {
+		sylog.Fatalf("Failed to load key material: %v", err)
+	}
+
+	ref, remoteOpts, err := cosignRemote(cmd, cpath)
+	if err != nil {
+		sylog.Fatalf("%v", err)
+	}
+	if ref != nil {
+		if err := sifsignature.SignRemote(cmd.Context(), ref, s, remoteOpts...); err != nil {
+			sylog.Fatalf("Failed to sign image: %v", err)
+		}
+		sylog.Infof("Signature created and pushed as a referrer of image '%v'", cpath)
+		return
+	}
+
+	if err := sifsignature.SignOCISIF(cmd.Context(), cpath, s); err != nil {
+		sylog.Fatalf("Failed to sign container: %v", err)
+	}
+	sylog.Infof("Signature created and applied to image '%v'", cpath)
+}
@@ -11,6 +11,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -21,18 +22,24 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/spf13/cobra"
 	scskeyclient "github.com/sylabs/scs-key-client/client"
 	scslibclient "github.com/sylabs/scs-library-client/client"
 	"github.com/sylabs/singularity/v4/docs"
+	"github.com/sylabs/singularity/v4/internal/app/singularity"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
 	"github.com/sylabs/singularity/v4/internal/pkg/ociplatform"
 	"github.com/sylabs/singularity/v4/internal/pkg/plugin"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
 	ocilauncher "github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher/oci"
+	"github.com/sylabs/singularity/v4/internal/pkg/sypgp"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/rootless"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/tracing"
 	"github.com/sylabs/singularity/v4/pkg/cmdline"
 	clicallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/cli"
 	"github.com/sylabs/singularity/v4/pkg/syfs"
@@ -51,6 +58,10 @@ var CurrentUser = getCurrentUser()
 // currentRemoteEndpoint holds the current remote endpoint
 var currentRemoteEndpoint *endpoint.Config
 
+// projectRemote holds the remote configuration of the project in the working
+// directory, if any
+var projectRemote *remote.ProjectConfig
+
 const (
 	envPrefix = "SINGULARITY_"
 )
@@ -64,6 +75,8 @@ var (
 	quiet   bool
 
 	configurationFile string
+
+	versionCrypto bool
 )
 
 // Common options used with multiple sub-commands.
@@ -76,6 +89,8 @@ var (
 
 	// Encryption Material
 	encryptionPEMPath   string
+	encryptionKeyFile   string
+	encryptionKeyURI    string
 	promptForPassphrase bool
 
 	// Paths / file handling
@@ -99,6 +114,9 @@ var (
 
 	// Optional user requested authentication file for writing/reading OCI registry credentials
 	reqAuthFile string
+
+	// Print a JSON record of the image pulled, built, or pushed?
+	resultJSON bool
 )
 
 //
@@ -253,6 +271,24 @@ var commonPEMFlag = cmdline.Flag{
 	Usage:        "enter an path to a PEM formatted RSA key for an encrypted container",
 }
 
+// --key-file
+var commonKeyFileFlag = cmdline.Flag{
+	ID:           "commonKeyFileFlag",
+	Value:        &encryptionKeyFile,
+	DefaultValue: "",
+	Name:         "key-file",
+	Usage:        "path to a file holding an encryption passphrase, or a PEM formatted RSA key, for an encrypted container",
+}
+
+// --key-uri
+var commonKeyURIFlag = cmdline.Flag{
+	ID:           "commonKeyURIFlag",
+	Value:        &encryptionKeyURI,
+	DefaultValue: "",
+	Name:         "key-uri",
+	Usage:        "URI of a key held by a key management service (vault://<mount>/<key>, kms://<helper>/<key-id>) for an encrypted container",
+}
+
 // -F|--force
 var commonForceFlag = cmdline.Flag{
 	ID:           "commonForceFlag",
@@ -345,6 +381,15 @@ var commonAuthFileFlag = cmdline.Flag{
 	EnvKeys:      []string{"AUTHFILE"},
 }
 
+// --json
+var commonResultJSONFlag = cmdline.Flag{
+	ID:           "commonResultJSONFlag",
+	Value:        &resultJSON,
+	DefaultValue: false,
+	Name:         "json",
+	Usage:        "print a JSON record of the resulting image to stdout, with all other output on stderr",
+}
+
 func getCurrentUser() *user.User {
 	usr, err := user.Current()
 	if err != nil {
@@ -439,6 +484,15 @@ func persistentPreRun(*cobra.Command, []string) error {
 	}
 	singularityconf.SetCurrentConfig(config)
 
+	// Honor 'fips mode' in singularity.conf. Strict FIPS mode is always
+	// enabled when built with the Go FIPS 140 module.
+	fips.SetEnabled(config.FIPSMode)
+	if fips.Enabled() {
+		sylog.Debugf("Strict FIPS mode enabled, crypto backend: %s", fips.Backend())
+		fips.ConfigureTransport(http.DefaultTransport)
+		fips.ConfigureTransport(ggcrremote.DefaultTransport)
+	}
+
 	// Honor 'oci mode' in singularity.conf, and allow negation with `--no-oci`.
 	if isOCI && noOCI {
 		return fmt.Errorf("--oci and --no-oci cannot be used together")
@@ -474,9 +528,40 @@ func persistentPreRun(*cobra.Command, []string) error {
 	if err := handleRemoteConf(syfs.RemoteConf()); err != nil {
 		return fmt.Errorf("while handling remote config: %w", err)
 	}
+
+	// Honor a project remote configuration in the working directory, or its
+	// parents, when located under the 'project remote paths' in
+	// singularity.conf.
+	if len(config.ProjectRemotePaths) > 0 {
+		loadProjectRemote(config.ProjectRemotePaths)
+	}
 	return nil
 }
 
+// loadProjectRemote loads the project remote configuration that applies to
+// the working directory, if any.
+func loadProjectRemote(allowed []string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	p, err := remote.FindProjectConfig(cwd, allowed)
+	if err != nil {
+		sylog.Warningf("Ignoring project remote configuration: %v", err)
+		return
+	}
+	if p == nil {
+		return
+	}
+	sylog.Verbosef("Using project remote configuration %s", p.Path)
+	projectRemote = p
+
+	// the registry credentials file may be overridden by --authfile
+	if reqAuthFile == "" && p.AuthFile != "" {
+		reqAuthFile = p.AuthFile
+	}
+}
+
 // Init initializes and registers all singularity commands.
 func Init(loadPlugins bool) {
 	cmdManager := cmdline.NewCommandManager(singularityCmd)
@@ -517,6 +602,7 @@ func Init(loadPlugins bool) {
 	cmdManager.RegisterFlagForCmd(&singConfigFileFlag, singularityCmd)
 
 	cmdManager.RegisterCmd(VersionCmd)
+	cmdManager.RegisterFlagForCmd(&versionCryptoFlag, VersionCmd)
 
 	// register all others commands/flags
 	for _, cmdInit := range cmdInits {
@@ -534,6 +620,7 @@ func Init(loadPlugins bool) {
 		for _, c := range callbacks {
 			c.(clicallback.Command)(cmdManager)
 		}
+		registerPluginTransports()
 	}
 
 	// any error reported by command manager is considered as fatal
@@ -601,7 +688,19 @@ func ExecuteSingularity() {
 		}
 	}()
 
-	if err := singularityCmd.ExecuteContext(ctx); err != nil {
+	// Trace the whole command when an OTLP endpoint is configured
+	if err := tracing.Init(ctx); err != nil {
+		sylog.Warningf("Tracing disabled: %v", err)
+	}
+	if subCmd, _, err := singularityCmd.Find(args[1:]); err == nil {
+		ctx = tracing.StartRoot(ctx, subCmd.CommandPath())
+	}
+
+	err := singularityCmd.ExecuteContext(ctx)
+	if err := tracing.Shutdown(); err != nil {
+		sylog.Debugf("While flushing traces: %v", err)
+	}
+	if err != nil {
 		// Find the subcommand to display more useful help, and the correct
 		// subcommand name in messages - i.e. 'run' not 'singularity'
 		// This is required because we previously used ExecuteC that returns the
@@ -644,11 +743,28 @@ func TraverseParentsUses(cmd *cobra.Command) string {
 	return cmd.Use + " "
 }
 
+// --crypto
+var versionCryptoFlag = cmdline.Flag{
+	ID:           "versionCryptoFlag",
+	Value:        &versionCrypto,
+	DefaultValue: false,
+	Name:         "crypto",
+	Usage:        "also show the active crypto backend, and whether strict FIPS mode is enabled",
+}
+
 // VersionCmd displays installed singularity version
 var VersionCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println(buildcfg.PACKAGE_VERSION)
+		if versionCrypto {
+			mode := "disabled"
+			if fips.Enabled() {
+				mode = "enabled"
+			}
+			fmt.Printf("crypto backend: %s\n", fips.Backend())
+			fmt.Printf("strict FIPS mode: %s\n", mode)
+		}
 	},
 
 	Use:   "version",
@@ -679,7 +795,10 @@ func sylabsRemote() (*endpoint.Config, error) {
 	cSys, sysErr := loadRemoteConf(remote.SystemConfigPath)
 	cUsr, usrErr := loadRemoteConf(syfs.RemoteConf())
 	if sysErr != nil && usrErr != nil {
-		return endpoint.DefaultEndpointConfig, nil
+		if projectRemote == nil {
+			return endpoint.DefaultEndpointConfig, nil
+		}
+		c = &remote.Config{Remotes: map[string]*endpoint.Config{}}
 	} else if sysErr != nil {
 		c = cUsr
 	} else if usrErr != nil {
@@ -692,7 +811,21 @@ func sylabsRemote() (*endpoint.Config, error) {
 		c = cUsr
 	}
 
+	if projectRemote != nil {
+		name, ep, err := projectRemote.Endpoint(c)
+		if err != nil {
+			return nil, fmt.Errorf("while applying project remote configuration %s: %w", projectRemote.Path, err)
+		}
+		if name != "" && usrErr == nil {
+			setTokenRefreshHandler(ep, name)
+		}
+		return ep, nil
+	}
+
 	ep, err := c.GetDefault()
+	if err == nil && usrErr == nil {
+		setTokenRefreshHandler(ep, c.DefaultRemote)
+	}
 	if err == remote.ErrNoDefault {
 		// all remotes have been deleted, fix that by returning
 		// the default remote endpoint to avoid side effects when
@@ -714,6 +847,14 @@ func sylabsRemote() (*endpoint.Config, error) {
 	return ep, err
 }
 
+// setTokenRefreshHandler stores the token of ep, the remote name, in the user
+// remote config once refreshed.
+func setTokenRefreshHandler(ep *endpoint.Config, name string) {
+	ep.SetTokenRefreshHandler(func(ep *endpoint.Config) error {
+		return singularity.RemoteUpdateToken(syfs.RemoteConf(), name, ep)
+	})
+}
+
 func singularityExec(image string, args []string) (string, error) {
 	// Record from stdout and store as a string to return as the contents of the file.
 	var stdout bytes.Buffer
@@ -778,6 +919,13 @@ func getKeyserverClientOpts(uri string, op endpoint.KeyserverOp) ([]scskeyclient
 		sylog.Warningf("No default remote in use, falling back to default keyserver: %s", endpoint.SCSDefaultKeyserverURI)
 	}
 
+	// Cache public keys fetched from keyservers, unless the cache is disabled.
+	if h := getCacheHandle(cache.Config{Disable: disableCache}); !h.IsDisabled() {
+		if dir, err := h.GetFileCacheDir(cache.KeyCacheType); err == nil {
+			sypgp.SetKeyCacheDir(dir)
+		}
+	}
+
 	return currentRemoteEndpoint.KeyserverClientOpts(uri, op)
 }
 
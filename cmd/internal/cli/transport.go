@@ -0,0 +1,81 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+	"github.com/sylabs/singularity/v4/internal/pkg/plugin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/uri"
+	imagecallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+
+	// Transports registered for the pull, push, and action commands.
+	_ "github.com/sylabs/singularity/v4/internal/pkg/client/globus"
+	_ "github.com/sylabs/singularity/v4/internal/pkg/client/net"
+	_ "github.com/sylabs/singularity/v4/internal/pkg/client/oras"
+	_ "github.com/sylabs/singularity/v4/internal/pkg/imagestore"
+)
+
+// transportOptions returns the transport options set from the command line.
+func transportOptions(cmd *cobra.Command) (transport.Options, error) {
+	ociAuth, err := makeOCICredentials(cmd)
+	if err != nil {
+		return transport.Options{}, fmt.Errorf("while creating docker credentials: %v", err)
+	}
+	return transport.Options{
+		TmpDir:      tmpDir,
+		NoHTTPS:     noHTTPS,
+		OciAuth:     ociAuth,
+		ReqAuthFile: reqAuthFile,
+	}, nil
+}
+
+// builtinSchemes are the URI schemes handled by the pull, push and action
+// commands without a registered transport, which plugins cannot override.
+var builtinSchemes = []string{uri.Library, uri.Shub, uri.Variants, "instance", "oci-sif"}
+
+// registerPluginTransports registers the image transports provided by
+// plugins through the image Transport callback.
+//
+//nolint:forcetypeassert
+func registerPluginTransports() {
+	callbackType := (imagecallback.Transport)(nil)
+	callbacks, err := plugin.LoadCallbacks(callbackType)
+	if err != nil {
+		sylog.Fatalf("Failed to load plugins callbacks '%T': %s", callbackType, err)
+	}
+
+	for _, c := range callbacks {
+		schemes, t := c.(imagecallback.Transport)()
+		if err := checkPluginSchemes(schemes); err != nil {
+			sylog.Fatalf("While registering plugin image transport: %v", err)
+		}
+		if err := transport.RegisterPlugin(t, schemes...); err != nil {
+			sylog.Fatalf("While registering plugin image transport: %v", err)
+		}
+		sylog.Debugf("Registered plugin image transport for %v", schemes)
+	}
+}
+
+// checkPluginSchemes returns an error if one of schemes is handled without a
+// registered transport.
+func checkPluginSchemes(schemes []string) error {
+	for _, s := range schemes {
+		if ocitransport.SupportedTransport(s) != "" {
+			return fmt.Errorf("cannot override builtin transport for %s://", s)
+		}
+		for _, b := range builtinSchemes {
+			if s == b {
+				return fmt.Errorf("cannot override builtin transport for %s://", s)
+			}
+		}
+	}
+	return nil
+}
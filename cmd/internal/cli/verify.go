@@ -192,6 +192,10 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&verifyJSONFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyAllFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyLegacyFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&dockerUsernameFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&dockerPasswordFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, VerifyCmd)
 	})
 }
 
@@ -212,6 +216,11 @@ var VerifyCmd = &cobra.Command{
 }
 
 func doVerifyCmd(cmd *cobra.Command, cpath string) {
+	if isCosignTarget(cpath) {
+		doCosignVerifyCmd(cmd, cpath)
+		return
+	}
+
 	var opts []sifsignature.VerifyOpt
 
 	switch {
@@ -314,3 +323,33 @@ func doVerifyCmd(cmd *cobra.Command, cpath string) {
 		sylog.Infof("Verified signature(s) from image '%v'", cpath)
 	}
 }
+
+// doCosignVerifyCmd verifies the cosign compatible signature of the OCI-SIF image, or image in an
+// OCI registry, at cpath.
+func doCosignVerifyCmd(cmd *cobra.Command, cpath string) {
+	if !cmd.Flag(verifyPublicKeyFlag.Name).Changed {
+		sylog.Fatalf("OCI-SIF and OCI registry images can only be verified with --key")
+	}
+
+	sylog.Infof("Verifying image with key material from '%v'", pubKeyPath)
+
+	v, err := signature.LoadVerifierFromPEMFile(pubKeyPath, crypto.SHA256)
+	if err != nil {
+		sylog.Fatalf("Failed to load key material: %v", err)
+	}
+
+	ref, remoteOpts, err := cosignRemote(cmd, cpath)
+	if err != nil {
+		sylog.Fatalf("%v", err)
+	}
+	if ref != nil {
+		err = sifsignature.VerifyRemote(cmd.Context(), ref, []signature.Verifier{v}, remoteOpts...)
+	} else {
+		err = sifsignature.VerifyOCISIF(cmd.Context(), cpath, v)
+	}
+	if err != nil {
+		sylog.Fatalf("Failed to verify container: %v", err)
+	}
+
+	sylog.Infof("Verified signature(s) from image '%v'", cpath)
+}
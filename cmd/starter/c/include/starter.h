@@ -13,12 +13,16 @@
 #include <sys/user.h>
 
 #define fatalf(b...)     singularity_message(ERROR, b); \
+                         syslog_fatal(__func__, b); \
                          exit(1)
 #define debugf(b...)     singularity_message(DEBUG, b)
 #define verbosef(b...)   singularity_message(VERBOSE, b)
 #define warningf(b...)   singularity_message(WARNING, b)
 #define errorf(b...)     singularity_message(ERROR, b)
 
+#define SYSLOG_IDENT        "singularity-starter"
+#define SYSLOG_ERROR_ID     "starter_fatal"
+
 #define MAX_MAP_SIZE        4096
 #define MAX_PATH_SIZE       PATH_MAX
 #define MAX_GID             32
@@ -181,4 +185,6 @@ struct starterConfig {
     struct engine engine;
 };
 
+void syslog_fatal(const char *function, char *format, ...) __attribute__ ((__format__(printf, 2, 3)));
+
 #endif /* _SINGULARITY_STARTER_H */
@@ -37,6 +37,7 @@
 #include <net/if.h>
 #include <sys/eventfd.h>
 #include <sys/sysmacros.h>
+#include <syslog.h>
 #include <linux/magic.h>
 
 #ifdef SINGULARITY_SECUREBITS
@@ -69,6 +70,53 @@ int cleanup_socket[2] = {-1, -1};
 /* set Go execution call after init function returns */
 enum goexec goexecute;
 
+/*
+ * syslog_fatal logs a fatal error to syslog when starter is run as setuid,
+ * with a structured message so that failures of privileged stages can be
+ * monitored on the node. It must be called before exiting.
+ */
+void syslog_fatal(const char *function, char *format, ...) {
+    char message[512];
+    char *stage = "starter";
+    size_t len;
+    va_list args;
+
+    if ( sconfig == NULL || sconfig == MAP_FAILED || !sconfig->starter.isSuid ) {
+        return;
+    }
+
+    va_start(args, format);
+    vsnprintf(message, sizeof(message), format, args);
+    va_end(args);
+
+    len = strlen(message);
+    while ( len > 0 && message[len-1] == '\n' ) {
+        message[--len] = '\0';
+    }
+
+    switch ( goexecute ) {
+    case STAGE1:
+        stage = "stage1";
+        break;
+    case STAGE2:
+        stage = "stage2";
+        break;
+    case MASTER:
+        stage = "master";
+        break;
+    case RPC_SERVER:
+        stage = "rpc";
+        break;
+    case CLEANUP_HOST:
+        stage = "cleanup";
+        break;
+    }
+
+    openlog(SYSLOG_IDENT, LOG_PID, LOG_USER);
+    syslog(LOG_ERR, "error_id=%s stage=%s function=%s uid=%d msg=%s", SYSLOG_ERROR_ID, stage, function, getuid(), message);
+    closelog();
+}
+
 typedef struct fdlist {
     int *fds;
     unsigned int num;
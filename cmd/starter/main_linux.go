@@ -56,6 +56,18 @@ func startup() {
 	e := getEngine(jsonConfig)
 	sylog.Debugf("%s runtime engine selected", e.EngineName)
 
+	// log fatal errors of privileged stages to syslog
+	if sconfig.GetIsSUID() {
+		stages := map[C.enum_goexec]string{
+			C.STAGE1:       "stage1",
+			C.STAGE2:       "stage2",
+			C.MASTER:       "master",
+			C.RPC_SERVER:   "rpc",
+			C.CLEANUP_HOST: "cleanup",
+		}
+		starter.SyslogFatalErrors(stages[C.goexecute])
+	}
+
 	switch C.goexecute {
 	case C.STAGE1:
 		sylog.Verbosef("Execute stage 1\n")
@@ -0,0 +1,70 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package docs
+
+// Global content for help and man pages
+const (
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// compose command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ComposeUse   string = `compose [subcommand options...]`
+	ComposeShort string = `Manage a set of instances described in a compose file`
+	ComposeLong  string = `
+  The 'compose' command starts and stops a set of services, each run as an
+  instance, described in a compose file (singularity-compose.yml in the current
+  directory, unless --file is specified):
+
+    name: myapp                 # defaults to the compose file directory name
+    network: bridge             # default network of the services
+    services:
+      db:
+        image: docker://postgres:16
+        binds: ["./data:/var/lib/postgresql/data"]
+        environment:
+          POSTGRES_PASSWORD: secret
+      web:
+        image: web.sif
+        command: ["--port", "8080"]   # arguments of the startscript
+        network: host
+        depends_on: [db]
+
+  The instance of a service is named <project name>-<service name>, and is
+  started after the instances of the services it depends on. Relative image
+  and bind paths are resolved from the directory of the compose file.
+
+  Services with the 'host' network, the default, share the network namespace
+  of the host, and reach each other on localhost. Other networks are CNI
+  network configurations, each service then having a dedicated interface.`
+	ComposeExample string = `
+  All group commands have their own help output:
+
+    $ singularity help compose up
+    $ singularity compose down --help`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// compose up command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ComposeUpUse   string = `up [up options...]`
+	ComposeUpShort string = `Start the services of a compose file`
+	ComposeUpLong  string = `
+  The 'compose up' command starts an instance for each service of the compose
+  file that is not already running, in dependency order.`
+	ComposeUpExample string = `
+  $ singularity compose up
+  $ singularity compose up --file /opt/myapp/singularity-compose.yml`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// compose down command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ComposeDownUse   string = `down [down options...]`
+	ComposeDownShort string = `Stop the services of a compose file`
+	ComposeDownLong  string = `
+  The 'compose down' command stops the running instances of the services of
+  the compose file, in reverse dependency order. Instances still running after
+  the timeout are killed.`
+	ComposeDownExample string = `
+  $ singularity compose down
+  $ singularity compose down --timeout 30`
+)
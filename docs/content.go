@@ -82,6 +82,14 @@ Enterprise Performance Computing (EPC)`
           Bootstrap: shub
           From: singularityhub/centos
 
+      ORAS:
+          Bootstrap: oras
+          From: registry.example.com/user/image:tag
+
+      OCI-SIF:
+          Bootstrap: ocisif
+          From: /home/dave/starter.oci.sif # or an OCI reference, e.g. docker://alpine
+
       YUM/RHEL:
           Bootstrap: yum
           OSVersion: 7
@@ -293,7 +301,9 @@ Enterprise Performance Computing (EPC)`
 	KeySearchLong  string = `
   The 'key search' command allows you to connect to a key server and look for
   public keys matching the argument passed to the command line. You can  
-  search by name, email, or fingerprint / key ID. (Maximum 100 search entities)`
+  search by name, email, or fingerprint / key ID. (Maximum 100 search entities)
+  Unless a key server is specified with --url, the key servers of the current
+  remote endpoint are searched in order, until one of them returns results.`
 	KeySearchExample string = `
   $ singularity key search sylabs.io
 
@@ -314,7 +324,9 @@ Enterprise Performance Computing (EPC)`
   your keyring when running commands such as 'singularity verify', and thus
   adding a key to your keyring implies a level of trust. Because of this, it is
   recommended that you verify the fingerprint of the key with its owner prior
-  to running this command.`
+  to running this command. Unless a key server is specified with --url, the key
+  servers of the current remote endpoint are tried in order, until the key is
+  found.`
 	KeyPullExample string = `
   $ singularity key pull 8883491F4268F173C6E5DC49EDECE4F3F38D871E`
 
@@ -325,7 +337,9 @@ Enterprise Performance Computing (EPC)`
 	KeyPushShort string = `Upload a public key to a key server`
 	KeyPushLong  string = `
   The 'key push' command allows you to connect to a key server and upload public
-  keys from the local or the global keyring.`
+  keys from the local or the global keyring. Unless a key server is specified
+  with --url, the key is uploaded to each key server of the current remote
+  endpoint, and the push succeeds if at least one of them accepts it.`
 	KeyPushExample string = `
   $ singularity key push 8883491F4268F173C6E5DC49EDECE4F3F38D871E`
 
@@ -475,18 +489,51 @@ Enterprise Performance Computing (EPC)`
   shub://*            A container hosted on Singularity Hub.
 
   oras://*            A SIF container hosted on an OCI registry that supports
-                      the OCI Registry As Storage (ORAS) specification.`
+                      the OCI Registry As Storage (ORAS) specification.
+
+  variants://*        A TOML manifest listing variants of a container, for
+                      different CPU or GPU architectures. The first variant
+                      matching the local node is run.`
+	signals string = `
+
+  Signals received by singularity are relayed to the container process, unless
+  singularity runs in the foreground of a terminal, which delivers keyboard
+  signals such as SIGINT to the container process itself. Signals listed with
+  --signal-proxy (e.g. --signal-proxy USR1,USR2, to trigger checkpoints) are
+  always relayed, and --signal-proxy all relays all signals but the keyboard
+  signals SIGINT, SIGQUIT and SIGTSTP, which cannot be listed as the terminal
+  already delivers them. In --oci mode, all signals are always relayed.
+
+  singularity exits with the exit code of the container process. If the
+  container process is killed by a signal, singularity exits with 128 plus the
+  signal number, e.g. 137 for SIGKILL. Exit codes of 128 and above set by the
+  container process are returned unchanged.
+
+  The environment of the container is set from, in increasing order of
+  precedence: the image, the host (unless --cleanenv or --no-env is set),
+  SINGULARITYENV_ variables on the host (unless --no-env is set), files given
+  with --env-file, and variables given with --env. --env-file may be specified
+  multiple times: files are evaluated in order, later files take precedence,
+  and may expand variables set by earlier files. Values may be quoted, and
+  span multiple lines. With --no-env, the container starts with a minimal
+  environment, holding only variables set by the image, --env-file, and --env.`
 	ExecUse   string = `exec [exec options...] <container> <command>`
 	ExecShort string = `Run a command within a container`
 	ExecLong  string = `
-  singularity exec supports the following formats:` + formats
+  singularity exec supports the following formats:` + formats + signals
 	ExecExamples string = `
   $ singularity exec /tmp/debian.sif cat /etc/debian_version
   $ singularity exec /tmp/debian.sif python ./hello_world.py
   $ cat hello_world.py | singularity exec /tmp/debian.sif python
   $ sudo singularity exec --writable /tmp/debian.sif apt-get update
   $ singularity exec instance://my_instance ps -ef
-  $ singularity exec library://centos cat /etc/os-release`
+  $ singularity exec library://centos cat /etc/os-release
+  $ singularity exec --signal-proxy USR1 /tmp/debian.sif ./simulate --checkpoint-on-usr1
+  $ singularity exec --mount type=tmpfs,dst=/scratch,tmpfs-size=1g /tmp/debian.sif df -h /scratch
+  $ singularity exec --mount type=image,src=data.sif,dst=/data,image-src=/inputs /tmp/debian.sif ls /data
+  $ singularity exec --mount type=image,src=tools.sif,dst=/opt/tools,partition=3 /tmp/debian.sif /opt/tools/bin/tool
+  $ singularity exec --mount type=volume,src=cache,dst=/var/cache/app /tmp/debian.sif ./app
+  $ singularity exec --cvmfs atlas.cern.ch,sft.cern.ch /tmp/debian.sif ls /cvmfs/sft.cern.ch`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// instance
@@ -525,12 +572,50 @@ Enterprise Performance Computing (EPC)`
   $ sudo singularity instance list -u mibauer
   INSTANCE NAME      PID       IMAGE
   test               11963     /home/mibauer/singularity/sinstance/test.sif
-  test2              16219     /home/mibauer/singularity/sinstance/test.sif`
+  test2              16219     /home/mibauer/singularity/sinstance/test.sif
+
+  List instances started with --label role=db:
+  $ singularity instance list --filter label=role=db`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance migrate
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceMigrateUse   string = `migrate [migrate options...] <instance> <[user@]host:[dir]>`
+	InstanceMigrateShort string = `Move a running instance to another node`
+	InstanceMigrateLong  string = `
+  The instance migrate command moves a running instance to another node, e.g.
+  to drain a node for maintenance. The instance processes are checkpointed
+  with CRIU, and the checkpoint is sent over ssh to the other node, together
+  with the upper layer of the writable --overlay directory of the instance.
+  The instance is then restored on the other node, under the same name and
+  user, and stopped on this node. If the migration fails, the instance keeps
+  running on this node.
+
+  The checkpoint is received in dir on the other node, /var/tmp by default.
+
+  Migration requires root privileges on both nodes, with CRIU and the same
+  singularity installation. The other node must accept non-interactive ssh
+  connections. The instance image, read-only overlays, and the host paths
+  bound into the instance must be present at the same paths on the other node,
+  e.g. on a shared filesystem. Connections held by the instance are closed.
+
+  Instances using --writable, --writable-tmpfs, overlay images, image binds,
+  or FUSE mounts can't be migrated.`
+	InstanceMigrateExample string = `
+  $ sudo singularity instance migrate instance://mysql1 root@node2:
+  INFO:    Checkpointing mysql1 instance of /data/my-sql.sif (PID=23845)
+  INFO:    Sending checkpoint of instance mysql1 to root@node2:/var/tmp/singularity-migrate-2d4f...
+  INFO:    Restoring instance mysql1 on root@node2
+  INFO:    Instance mysql1 restored
+  INFO:    Instance mysql1 migrated to root@node2
+
+  Migrate an instance of user alice:
+  $ sudo singularity instance migrate -u alice mysql1 root@node2:/scratch`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// instance start
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
-	InstanceStartUse   string = `start [start options...] <container path> <instance name> [startscript args...]`
+	InstanceStartUse   string = `start [start options...] <container path> [<instance name>] [startscript args...]`
 	InstanceStartShort string = `Start a named instance of the given container image`
 	InstanceStartLong  string = `
   The instance start command allows you to create a new named instance from an
@@ -539,6 +624,11 @@ Enterprise Performance Computing (EPC)`
   will be executed with the instance start command as well. You can optionally
   pass arguments to startscript
 
+  With --name-prefix, the instance name is omitted, and the instance is named
+  <prefix>-<N>, with the lowest N not in use, which is printed on stdout.
+  Instances can be labeled with --label key=value, to select them with the
+  --filter option of instance list and stop.
+
   singularity instance start accepts the following container formats` + formats
 	InstanceStartExample string = `
   $ singularity instance start /tmp/my-sql.sif mysql
@@ -554,7 +644,10 @@ Enterprise Performance Computing (EPC)`
   Singularity my-sql.sif>
 
   $ singularity instance stop /tmp/my-sql.sif mysql
-  Stopping /tmp/my-sql.sif mysql`
+  Stopping /tmp/my-sql.sif mysql
+
+  $ singularity instance start --name-prefix mysql --label role=db /tmp/my-sql.sif
+  mysql-1`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// instance stats
@@ -566,7 +659,12 @@ Enterprise Performance Computing (EPC)`
   either printed to the terminal or in json. If you are root, you can optionally
   ask for statistics for a container instance belonging to a specific user. If
   you add --no-stream, you will only see one timepoint. Asking for json implies
-  the same.`
+  the same.
+
+  When the instance was started with --fusemount options running FUSE drivers
+  from the host in foreground mode, the state of each driver is also shown:
+  its PID, whether it is healthy, how many times it was restarted after
+  crashing, and how its last process terminated.`
 	InstanceStatsExample string = `
   $ singularity instance stats mysql
   $ singularity instance stats --json mysql
@@ -596,7 +694,10 @@ Enterprise Performance Computing (EPC)`
   Send SIGTERM to the instance
   $ singularity instance stop -s SIGTERM mysql1
   $ singularity instance stop -s TERM mysql1
-  $ singularity instance stop -s 15 mysql1`
+  $ singularity instance stop -s 15 mysql1
+
+  Stop all instances started with --label role=db
+  $ singularity instance stop --filter label=role=db`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// pull
@@ -621,6 +722,11 @@ Enterprise Performance Computing (EPC)`
 
   http, https: Pull an image using the http(s?) protocol
       https://example.com/containers/mycontainer.sif
+
+  globus: Transfer an image from a Globus collection, through the local Globus
+  collection set in SINGULARITY_GLOBUS_LOCAL_ENDPOINT, using the Globus
+  Transfer API access token in SINGULARITY_GLOBUS_TOKEN.
+      globus://<collection id>/path/to/image.sif
   
   By default, images from a library URI will be pulled in the same format they
   were uploaded. If the --oci flag is specified then the pull is required
@@ -630,8 +736,14 @@ Enterprise Performance Computing (EPC)`
   into a singularity native SIF image. If the --oci flag is specified then they
   will be encapsulated in an OCI-SIF image.
 
-  Images pulled from a shub/oras/http/https URI are always directly downloaded,
-  in the same format as they were uploaded.`
+  Images pulled from a shub/oras/http/https/globus URI are always directly downloaded,
+  in the same format as they were uploaded.
+
+  With --to-all-nodes, the image is pulled once, and copied to the same path on
+  the other nodes of the job, with ssh and scp. Nodes that received the image
+  copy it in turn to further nodes, up to --fanout nodes each. The nodes are
+  given by --nodes or --nodefile, or found in the environment of a SLURM or PBS
+  job. The destination directory must exist on all nodes.`
 	PullExample string = `
   From Sylabs cloud library
   $ singularity pull alpine.sif library://alpine:latest
@@ -646,7 +758,15 @@ Enterprise Performance Computing (EPC)`
   $ singularity pull singularity-images.sif shub://vsoch/singularity-images
 
   From an OCI registry supporting ORAS / OCI artifacts
-  $ singularity pull image.sif oras://<username>.azurecr.io/namespace/image:tag`
+  $ singularity pull image.sif oras://<username>.azurecr.io/namespace/image:tag
+
+  To the nodes of a SLURM job, or to a list of nodes
+  $ singularity pull --to-all-nodes /scratch/alpine.sif docker://alpine
+  $ singularity pull --to-all-nodes --nodes 'node[01-16]' /scratch/alpine.sif docker://alpine
+
+  Printing a JSON record of the image (path, digest, size, layers, cache
+  hit, duration) to stdout, for scripts, with all other output on stderr
+  $ singularity pull --json alpine.sif docker://alpine | jq -r .digest`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// push
@@ -663,6 +783,20 @@ Enterprise Performance Computing (EPC)`
   oras:
       oras://registry/namespace/repo:tag
 
+  globus: Transfer the image to a Globus collection, through the local Globus
+  collection set in SINGULARITY_GLOBUS_LOCAL_ENDPOINT, using the Globus
+  Transfer API access token in SINGULARITY_GLOBUS_TOKEN.
+      globus://<collection id>/path/to/image.sif
+
+  docker-daemon: Load an OCI-SIF image into the local Docker daemon, or the
+  daemon set with --docker-host. Squashfs layers are converted to tar layers
+  with sqfs2tar.
+      docker-daemon:name[:tag]
+
+  containers-storage: Load an OCI-SIF image into the local Podman image
+  storage, with 'podman load'. Squashfs layers are converted to tar layers
+  with sqfs2tar.
+      containers-storage:name[:tag]
 
   NOTE: It's always good practice to sign your containers before
   pushing them to the library. An auth token is required to push to the library,
@@ -672,7 +806,11 @@ Enterprise Performance Computing (EPC)`
   $ singularity push /home/user/my.sif library://user/collection/my.sif:latest
 
   To supported OCI registry
-  $ singularity push /home/user/my.sif oras://registry/namespace/image:tag`
+  $ singularity push /home/user/my.sif oras://registry/namespace/image:tag
+
+  To the local Docker daemon, or Podman image storage
+  $ singularity push /home/user/my.oci.sif docker-daemon:my-image:latest
+  $ singularity push /home/user/my.oci.sif containers-storage:my-image:latest`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// search
@@ -701,7 +839,7 @@ Enterprise Performance Computing (EPC)`
   automatically. All arguments following the container name will be passed
   directly to the runscript.
 
-  singularity run accepts the following container formats:` + formats
+  singularity run accepts the following container formats:` + formats + signals
 	RunExamples string = `
   # Here we see that the runscript prints "Hello world: "
   $ singularity exec /tmp/debian.sif cat /singularity
@@ -721,7 +859,7 @@ Enterprise Performance Computing (EPC)`
 	ShellUse   string = `shell [shell options...] <container>`
 	ShellShort string = `Run a shell within a container`
 	ShellLong  string = `
-  singularity shell supports the following formats:` + formats
+  singularity shell supports the following formats:` + formats + signals
 	ShellExamples string = `
   $ singularity shell /tmp/Debian.sif
   Singularity/Debian.sif> pwd
@@ -759,11 +897,19 @@ Enterprise Performance Computing (EPC)`
   the file.
 
   Key material can be provided via PEM-encoded file, or an entity in the PGP
-  keyring. To manage the PGP keyring, see 'singularity help key'.`
+  keyring. To manage the PGP keyring, see 'singularity help key'.
+
+  OCI-SIF images, and images in an OCI registry specified with a docker:// URI,
+  are signed with a cosign compatible signature of the image manifest digest,
+  using a PEM-encoded private key. The signature is stored in the OCI-SIF
+  image, or pushed to the registry as a referrer of the image.`
 	SignExample string = `
   Sign with a private key:
   $ singularity sign --key private.pem container.sif
 
+  Sign an image in an OCI registry:
+  $ singularity sign --key private.pem docker://registry.example.com/image:tag
+
   Sign with PGP:
   $ singularity sign container.sif`
 
@@ -777,14 +923,165 @@ Enterprise Performance Computing (EPC)`
   within a SIF image.
 
   Key material can be provided via PEM-encoded file, or via the PGP keyring. To
-  manage the PGP keyring, see 'singularity help key'.`
+  manage the PGP keyring, see 'singularity help key'.
+
+  OCI-SIF images, and images in an OCI registry specified with a docker:// URI,
+  are verified against the cosign compatible signatures created by 'singularity
+  sign', using a PEM-encoded public key.`
 	VerifyExample string = `
   Verify with a public key:
   $ singularity verify --key public.pem container.sif
 
+  Verify an image in an OCI registry:
+  $ singularity verify --key public.pem docker://registry.example.com/image:tag
+
   Verify with PGP:
   $ singularity verify container.sif`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// scan
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ScanUse   string = `scan [scan options...] <image path>`
+	ScanShort string = `Scan an image for known vulnerabilities`
+	ScanLong  string = `
+  The scan command generates a software bill of materials (SBOM) for the
+  packages installed in a SIF, OCI-SIF, squashfs or sandbox image, and scans it
+  for known vulnerabilities, using an external scanner.
+
+  The grype and trivy scanners are supported, and must be installed on PATH.
+  Another scanner can be used by specifying the path to an executable that
+  implements two subcommands:
+
+    <executable> sbom <rootfs> <sbom>
+
+  writes a CycloneDX JSON SBOM of the root filesystem at <rootfs> to <sbom>.
+
+    <executable> scan <sbom>
+
+  prints the vulnerabilities of the packages in <sbom> to standard output, as
+  a JSON array of objects with the fields id, package, version, fixedVersion,
+  and severity (negligible, low, medium, high, critical or unknown).
+
+  If --fail-on is specified, scan exits with an error when vulnerabilities of
+  that severity, or higher, are found.`
+	ScanExample string = `
+  Scan an image using grype:
+  $ singularity scan container.sif
+
+  Scan an image using trivy, keeping the SBOM:
+  $ singularity scan --scanner trivy --sbom container.sbom.json container.sif
+
+  Fail if high or critical vulnerabilities are found:
+  $ singularity scan --fail-on high container.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// diff
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	DiffUse   string = `diff [diff options...] <image path> <image path>`
+	DiffShort string = `Compare the files and configuration of two images`
+	DiffLong  string = `
+  The diff command compares two SIF, OCI-SIF, squashfs or sandbox images, and
+  lists the files added (A), modified (M) and deleted (D) in the second image
+  relative to the first. A modified file is followed by the attributes that
+  differ: type, mode, owner, symlink target or content. Owners are only
+  compared when run as root, or between two sandboxes, as they are not
+  preserved when an image is extracted by an unprivileged user. A file that
+  can't be read is reported as an error.
+
+  When both images are OCI-SIF images, the differences of their OCI image
+  configs (entrypoint, cmd, environment, labels, etc.) and the layers only
+  present in one image are also shown. Use --config-only to skip the
+  comparison of files.
+
+  The root filesystems of images other than sandboxes are extracted to a
+  temporary directory, which can be set with --tmpdir. Only OCI-SIF images with
+  a single squashfs layer can be compared at the file level, and an OCI-SIF
+  image with multiple layers is rejected unless --config-only is set.`
+	DiffExample string = `
+  Compare a rebuilt image with the previous build:
+  $ singularity diff old.sif new.sif
+
+  Compare the configuration of two OCI-SIF images, as JSON:
+  $ singularity diff --config-only --json old.oci.sif new.oci.sif
+
+  Compare an image with a sandbox:
+  $ singularity diff container.sif sandbox/`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// minimize
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	MinimizeUse   string = `minimize [minimize options...] <source sif> <destination sif>`
+	MinimizeShort string = `Write a copy of a SIF or OCI-SIF image holding only the files its workload accesses`
+	MinimizeLong  string = `
+  The minimize command writes a copy of a SIF or OCI-SIF image, whose root
+  filesystem only holds the regular files listed in one or more access profiles
+  given with --profile, or matching a keep pattern. Access profiles are
+  recorded by running the container with --profile-io, or list one path in the
+  image per line.
+
+  Additional paths to keep are given as glob patterns with --keep, or listed one
+  per line in keep-list files given with --keep-file. All files below a kept
+  directory are kept. The /.singularity.d directory, and the /etc files used by
+  singularity to run the container, are always kept.
+
+  Directories, symlinks and special files are always kept. For a native SIF
+  image, the other data objects of the image are copied, except for signatures,
+  which no longer apply to the minimized image: it must be signed again if
+  required. For an OCI-SIF image, the single squashfs layer of the image is
+  replaced, and its manifest and config are updated, so the image digest
+  changes. Other data objects, such as signatures and overlays, are not copied.
+
+  Native SIF images with a squashfs root filesystem, and OCI-SIF images with a
+  single unencrypted squashfs layer, can be minimized. The root filesystem is
+  extracted to a temporary directory, which can be set with --tmpdir. A
+  workload should be profiled with all of its expected inputs, as files it did
+  not access while profiled are not available in the minimized image. A
+  warning is given if a profile was recorded from another image.`
+	MinimizeExample string = `
+  Record the files accessed by a workload, and minimize the image:
+  $ sudo singularity run --oci --profile-io access.json image.sif
+  $ singularity minimize --profile access.json image.sif image.min.sif
+
+  Merge two profiles, and keep the Python standard library:
+  $ singularity minimize --profile train.json --profile eval.json \
+      --keep '/usr/lib/python3*' image.sif image.min.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// mount
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	MountUse   string = `mount [mount options...] [<image path> <mount point>]`
+	MountShort string = `Mount the root filesystem of an image on the host`
+	MountLong  string = `
+  The mount command mounts the root filesystem of a SIF, OCI-SIF, squashfs or
+  EXT3 image read-only at an existing directory on the host, without starting a
+  container. The filesystem is mounted with squashfuse or fuse2fs, so no
+  privileges are required.
+
+  Mounts made with this command are recorded, and listed when no arguments are
+  given. Use 'singularity umount' to unmount an image.
+
+  Encrypted images can't be mounted. Only OCI-SIF images with a single squashfs
+  layer are supported.`
+	MountExample string = `
+  Mount an image, and browse its files:
+  $ mkdir rootfs
+  $ singularity mount container.sif rootfs
+  $ ls rootfs/etc
+
+  List the images mounted on the host:
+  $ singularity mount`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// umount
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	UmountUse   string = `umount <mount point>`
+	UmountShort string = `Unmount an image mounted with 'singularity mount'`
+	UmountLong  string = `
+  The umount command unmounts the image mounted at a directory by
+  'singularity mount', and removes its record from the list of mounts.`
+	UmountExample string = `
+  $ singularity umount rootfs`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Run-help
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -826,6 +1123,9 @@ Enterprise Performance Computing (EPC)`
   Inspect will show you labels, environment variables, apps and scripts associated 
   with the image determined by the flags you pass. By default, they will be shown in 
   plain text. If you would like to list them in json format, you should use the --json flag.
+
+  For an OCI-SIF image, inspect shows the OCI image config instead: entrypoint,
+  cmd, environment, labels, manifest annotations, layer digests and history.
   `
 	InspectExample string = `
   $ singularity inspect ubuntu.sif
@@ -1020,6 +1320,32 @@ Enterprise Performance Computing (EPC)`
 	OciResumeExample string = `
   $ singularity oci resume mycontainer`
 
+	OciEventsUse   string = `events [events options...] <container_ID>`
+	OciEventsShort string = `Display container events and resource usage statistics (root user only)`
+	OciEventsLong  string = `
+  Events will stream the events of the specified container ID, OOM kills and
+  resource usage statistics, in JSON format, until it exits. The exit status
+  of a container started with 'oci create' / 'oci start' is reported last.
+  Events are read from the container cgroup, with both runc and crun.`
+	OciEventsExample string = `
+  $ singularity oci events --interval 10 mycontainer
+
+  or to display resource usage statistics once :
+
+  $ singularity oci events --stats mycontainer`
+
+	OciPsUse   string = `ps [ps options...] <container_ID> [-- <ps arguments>]`
+	OciPsShort string = `List processes running inside the container (root user only)`
+	OciPsLong  string = `
+  Ps will list the processes running in the specified container ID, found from
+  its cgroup. In table format, the default, additional arguments are passed to
+  the ps command, whose output is filtered to the processes of the container.
+  In json format, the PIDs of the processes are listed.`
+	OciPsExample string = `
+  $ singularity oci ps mycontainer
+  $ singularity oci ps --format json mycontainer
+  $ singularity oci ps mycontainer -- -o pid,rss,args`
+
 	OciMountUse   string = `mount <sif_image> <bundle_path>`
 	OciMountShort string = `Mount create an OCI bundle from SIF image (root user only)`
 	OciMountLong  string = `
@@ -1035,6 +1361,30 @@ Enterprise Performance Computing (EPC)`
 	OciUmountExample string = `
   $ singularity oci umount /var/lib/singularity/bundles/example`
 
+	OciSnapshotterUse   string = `snapshotter [snapshotter options...]`
+	OciSnapshotterShort string = `Serve a containerd snapshotter for OCI-SIF images (root user only)`
+	OciSnapshotterLong  string = `
+  Snapshotter serves a containerd proxy snapshotter plugin, on a unix socket,
+  that mounts the squashfs layers of OCI-SIF images pushed to a registry
+  directly, rather than unpacking them to directories. Other layers are
+  unpacked by containerd as usual.
+
+  Squashfs layers are only mounted for images pulled through the CRI plugin
+  of containerd, e.g. by Kubernetes, with snapshot annotations enabled. The
+  plugin is configured in the containerd configuration file:
+
+    [proxy_plugins.oci-sif]
+      type = "snapshot"
+      address = "/run/singularity/oci-sif-snapshotter.sock"
+
+    [plugins."io.containerd.grpc.v1.cri".containerd]
+      snapshotter = "oci-sif"
+      disable_snapshot_annotations = false
+
+  Layers are fetched with the registry credentials of the root user.`
+	OciSnapshotterExample string = `
+  $ singularity oci snapshotter --address /run/singularity/oci-sif-snapshotter.sock`
+
 	ConfigUse   string = `config`
 	ConfigShort string = `Manage various singularity configuration (root user only)`
 	ConfigLong  string = `
@@ -1085,6 +1435,51 @@ Enterprise Performance Computing (EPC)`
   To display the resulting configuration instead of writing it to file:
   $ singularity config global --dry-run --set "bind path" /etc/resolv.conf`
 
+	ConfigSeccompUse   string = `seccomp [profile]`
+	ConfigSeccompShort string = `Show the seccomp profile applied to containers in OCI mode`
+	ConfigSeccompLong  string = `
+  The config seccomp command shows the seccomp profile that is applied to
+  containers in OCI mode. Without an argument, this is the profile set by the
+  'oci seccomp profile' directive of singularity.conf. A profile argument, as
+  would be given to --security seccomp:<profile>, shows the profile applied
+  with that option instead. The profile is one of 'default', 'unconfined', or
+  the path of a JSON seccomp profile.`
+	ConfigSeccompExample string = `
+  To show the seccomp profile applied by default in OCI mode:
+  $ singularity config seccomp
+
+  To show the profile applied with --security seccomp:default:
+  $ singularity config seccomp default`
+
+	DataUse   string = `data`
+	DataShort string = `Manage data containers`
+	DataLong  string = `
+  The data command allows management of data containers. A data container is
+  an OCI-SIF file holding arbitrary data in a single squashfs layer, rather
+  than a root filesystem. It can be mounted into a container run in OCI mode
+  with --data, and pushed to or pulled from an OCI registry with oras:// or
+  docker:// URIs.`
+	DataExample string = `
+  All data commands have their own help output:
+
+  $ singularity help data create
+  $ singularity data create --help`
+
+	DataCreateUse   string = `create [create options...] <data container path> <directory>`
+	DataCreateShort string = `Create a data container from a directory`
+	DataCreateLong  string = `
+  The data create command packages the contents of a directory as a squashfs
+  layer, in a new OCI-SIF data container. The directory contents are mounted
+  read-only when the data container is used with --data.`
+	DataCreateExample string = `
+  Package a directory, and mount it at /mnt in a container:
+  $ singularity data create mydata.oci.sif ./inputs
+  $ singularity exec --oci --data mydata.oci.sif:/mnt container.oci.sif ls /mnt
+
+  Push the data container to an OCI registry, and pull it elsewhere:
+  $ singularity push mydata.oci.sif oras://registry.example.com/data/mydata:v1
+  $ singularity pull mydata.oci.sif oras://registry.example.com/data/mydata:v1`
+
 	OverlayUse   string = `overlay`
 	OverlayShort string = `Manage an EXT3 writable overlay image`
 	OverlayLong  string = `
@@ -1109,6 +1504,28 @@ Enterprise Performance Computing (EPC)`
 
   To create a sparse overlay when creating a new ext3 file system image:
   $ singularity overlay create --size 1024 --sparse /tmp/ext3_overlay.img`
+
+	OverlayDiffUse   string = `diff <options> overlay`
+	OverlayDiffShort string = `List or export the changes made by a writable overlay`
+	OverlayDiffLong  string = `
+  The overlay diff command lists the files added (A), modified (M) and deleted
+  (D) by a writable overlay, relative to the root filesystem of its base image.
+  The overlay can be an overlay directory, an EXT3 overlay image, or a SIF image
+  with an embedded overlay. For an overlay embedded in a SIF image, the base
+  image defaults to the SIF image itself, otherwise it must be given with
+  --base.
+
+  With --export tar, the changed files are written as a tar archive instead,
+  deleted files being recorded as OCI whiteout files.`
+	OverlayDiffExample string = `
+  To list the changes made by the overlay embedded in a SIF image:
+  $ singularity overlay diff /tmp/image.sif
+
+  To list the changes made by an EXT3 overlay image to a base image:
+  $ singularity overlay diff --base /tmp/image.sif /tmp/my_overlay.img
+
+  To export the changes as a tar archive:
+  $ singularity overlay diff --base /tmp/image.sif --export tar -o changes.tar /tmp/my_overlay.img`
 )
 
 // Documentation for sif/siftool command.
@@ -1125,4 +1542,102 @@ const (
 
   $ singularity help sif list
   $ singularity sif list --help`
+
+	SIFEditMetadataUse   string = `edit-metadata [edit-metadata options...] <sif path>`
+	SIFEditMetadataShort string = `Edit the container metadata of a SIF image in place`
+	SIFEditMetadataLong  string = `
+  The sif edit-metadata command updates the container labels, environment and
+  help text reported by 'singularity inspect' for a SIF image, without
+  rebuilding the image. The container filesystem is not modified. Instead,
+  when the image is run, the edited labels and help text are bound over
+  /.singularity.d/labels.json and /.singularity.d/runscript.help in the
+  container.
+
+  Environment variables set with --env are held in the metadata environment
+  overlay, reported by 'singularity inspect --environment'. They are set in
+  the container after those of the image, and are overridden by variables set
+  with --env, --env-file or SINGULARITYENV_ when the image is run.
+
+  If the image is signed, the edited metadata is written to a new data object,
+  in a new object group, so that existing signatures over the other data
+  objects of the image remain valid, and can be checked with
+  'singularity verify --group-id'. The new data object is not signed. The data
+  objects that are not covered by any signature after the edit are listed, and
+  the new object group can be signed with 'singularity sign --group-id'. A
+  metadata data object that is not signed, for example from an earlier edit,
+  is replaced.`
+	SIFEditMetadataExample string = `
+  Add a label, and remove another:
+  $ singularity sif edit-metadata --label org.example.owner=alice \
+      --remove-label org.example.draft image.sif
+
+  Set an environment variable in the metadata:
+  $ singularity sif edit-metadata --env MODE=production image.sif
+
+  Replace the help text:
+  $ singularity sif edit-metadata --help-file help.txt image.sif`
+
+	SIFCompactUse   string = `compact [compact options...] <sif path>`
+	SIFCompactShort string = `Remove unused data objects from a SIF image, and reclaim their space`
+	SIFCompactLong  string = `
+  The sif compact command rewrites a SIF or OCI-SIF image in place, reclaiming
+  the space of deleted data objects, and dropping data objects that are no
+  longer used:
+
+    - metadata replaced by a later 'singularity sif edit-metadata',
+    - signatures of data objects that no longer exist,
+    - OCI blobs that are not referenced by the image index of an OCI-SIF,
+    - duplicates of a data object with identical type, name and content.
+
+  Data objects covered by a signature, or linked to by another data object,
+  are never dropped. The data objects that remain keep their IDs, and the image
+  keeps its ID, so that existing signatures remain valid.
+
+  With --compression, unsigned squashfs partitions are re-compressed with the
+  given mksquashfs algorithm (e.g. gzip, xz, zstd). Signed partitions are not
+  re-compressed, as this would invalidate their signatures. With --alignment,
+  partitions are aligned to the given number of bytes.
+
+  The size of the image before and after compaction is reported.`
+	SIFCompactExample string = `
+  Reclaim unused space:
+  $ singularity sif compact image.sif
+
+  Re-compress the root filesystem with zstd:
+  $ singularity sif compact --compression zstd image.sif`
+
+	SIFDiffUse   string = `diff <base sif> <target sif> <delta path>`
+	SIFDiffShort string = `Create a delta holding the data objects that changed between two SIF images`
+	SIFDiffLong  string = `
+  The sif diff command writes a delta between two versions of a SIF or OCI-SIF
+  image, which can be applied with 'singularity sif patch' on a host holding
+  the base image, to create the target image. Only the data objects of the
+  target image that are not found, with identical content, in the base image
+  are held in the delta.
+
+  Deltas are created at the level of data objects. The layers of an OCI-SIF
+  image are held in separate data objects, so only changed layers are held in
+  a delta between two OCI-SIF images. The root filesystem of a native SIF image
+  is held in a single partition, which is held in the delta in full if any of
+  its files changed.
+
+  The sizes of the delta and of the target image are reported.`
+	SIFDiffExample string = `
+  Create a delta to update hosts holding image-v1.sif to image-v2.sif:
+  $ singularity sif diff image-v1.sif image-v2.sif image-v2.delta`
+
+	SIFPatchUse   string = `patch <base sif> <delta path> <destination sif>`
+	SIFPatchShort string = `Create a SIF image by applying a delta to a base image`
+	SIFPatchLong  string = `
+  The sif patch command applies a delta, created by 'singularity sif diff', to
+  the base image it was created against, writing the target image to a new
+  file. The data objects not held in the delta are read from the base image,
+  and the content of every data object is checked against the digest recorded
+  in the delta.
+
+  The written image keeps the ID, data object IDs and creation times of the
+  target image, so that signatures over the target image remain valid.`
+	SIFPatchExample string = `
+  Update image-v1.sif to image-v2.sif:
+  $ singularity sif patch image-v1.sif image-v2.delta image-v2.sif`
 )
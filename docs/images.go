@@ -0,0 +1,96 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package docs
+
+// Global content for help and man pages
+const (
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// images command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImagesUse   string = `images [subcommand options...]`
+	ImagesShort string = `Manage the local image store`
+	ImagesLong  string = `
+  The 'images' command manages your local image store (stored at
+  $HOME/.singularity/images if SINGULARITY_IMAGESDIR is not set), which holds
+  SIF and OCI-SIF images under names of the form name:tag. Unlike the cache,
+  the image store is never cleaned automatically.
+
+  Images are stored as blobs, by digest, however many names point to them. An
+  OCI-SIF image holding a single image is stored as the blobs of its manifest,
+  config and layers, so that images share their common layers. Other images,
+  such as native SIF images, or OCI-SIF images with signatures or other data
+  objects, are stored as a single blob. Blobs are only deleted once no image
+  name uses them.
+
+  Images in the store are run, pulled, or added with store:// URIs, e.g.
+  'singularity run store://python:3.11', 'singularity pull python.sif
+  store://python:3.11', or 'singularity push python.sif store://python:3.11'.
+  OCI-SIF images stored by layer are assembled into an image file in the cache
+  when they are run.`
+	ImagesExample string = `
+  All group commands have their own help output:
+
+    $ singularity help images tag
+    $ singularity images list --help`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// images list command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImagesListUse   string = `list [list options...]`
+	ImagesListShort string = `List the images of the local image store`
+	ImagesListLong  string = `
+  The 'images list' command lists the names of the images in your local image
+  store, along with the location of their image file, or their store:// URI
+  for OCI-SIF images stored by layer.`
+	ImagesListExample string = `
+  $ singularity images list
+
+  To list images in JSON format:
+  $ singularity images list --json`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// images import command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImagesImportUse   string = `import <image file> <name[:tag]>`
+	ImagesImportShort string = `Add an image file to the local image store`
+	ImagesImportLong  string = `
+  The 'images import' command copies a SIF or OCI-SIF image file into your
+  local image store under a name. The tag defaults to 'latest'. The blobs of
+  the image already in the store are shared.`
+	ImagesImportExample string = `
+  $ singularity images import ./python.sif python:3.11`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// images tag command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImagesTagUse   string = `tag <name[:tag]> <name[:tag]>`
+	ImagesTagShort string = `Give another name to an image of the local image store`
+	ImagesTagLong  string = `
+  The 'images tag' command gives another name to an image already in your
+  local image store. The tag defaults to 'latest'. Both names share the blobs
+  of the image.`
+	ImagesTagExample string = `
+  $ singularity images tag python:3.11 python:latest`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// images remove command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImagesRemoveUse   string = `remove <name[:tag]>...`
+	ImagesRemoveShort string = `Remove images from the local image store`
+	ImagesRemoveLong  string = `
+  The 'images remove' command removes names from your local image store. The
+  blobs of an image are deleted when no remaining image name uses them.`
+	ImagesRemoveExample string = `
+  $ singularity images remove python:3.11`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// images prune command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	ImagesPruneUse   string = `prune`
+	ImagesPruneShort string = `Reclaim the space of unreferenced files in the local image store`
+	ImagesPruneLong  string = `
+  The 'images prune' command deletes the blobs of your local image store that
+  no image name uses, and the files left by interrupted imports. Images that
+  have a name are never deleted.`
+	ImagesPruneExample string = `
+  $ singularity images prune`
+)
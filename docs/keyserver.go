@@ -81,5 +81,8 @@ const (
   each remote endpoint. If the optional remoteName argument is provided, the
   command will only list keyservers for the remote endpoint matching that name.`
 	KeyserverListExample string = `
-  $ singularity keyserver list`
+  $ singularity keyserver list
+
+  To list keyservers in YAML format:
+  $ singularity keyserver list --format yaml`
 )
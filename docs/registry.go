@@ -61,5 +61,8 @@ const (
   The 'registry list' command lists all credentials for OCI/Docker registries
   that are configured for use.`
 	RegistryListExample string = `
-  $ singularity registry list`
+  $ singularity registry list
+
+  To list registries in JSON format:
+  $ singularity registry list --json`
 )
@@ -31,12 +31,26 @@ const (
   and push. You can also 'remote logout' from and 'remote remove' an endpoint that
   is no longer required.
 
-  The remote configuration is stored in $HOME/.singularity/remotes.yaml by default.`
+  The remote configuration is stored in $HOME/.singularity/remotes.yaml by default.
+
+  When allowed by 'project remote paths' in singularity.conf, a
+  .singularity-remote.yaml file in the working directory, or one of its
+  parents, overrides the active remote endpoint for commands run in that
+  project. It may hold:
+
+    Remote: <name>        # a configured remote endpoint to use
+    URI: <uri>            # or the address of a remote endpoint to use
+    Keyservers:           # keyservers replacing those of the endpoint
+      - URI: https://keys.example.com
+    AuthFile: <path>      # registry credentials file, relative to the project`
 	RemoteExample string = `
   All group commands have their own help output:
 
     $ singularity help remote list
-    $ singularity remote list`
+    $ singularity remote list
+
+  To list remote endpoints in JSON format:
+  $ singularity remote list --json`
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// remote get-login-password
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -97,7 +111,12 @@ const (
   endpoint.
 
   If no endpoint is specified, the command will login to the currently active
-  remote endpoint. This is cloud.sylabs.io by default.`
+  remote endpoint. This is cloud.sylabs.io by default.
+
+  The file given with --tokenfile may hold the token alone, or an OAuth 2.0
+  token response in JSON format. When the response includes a refresh_token,
+  the token is refreshed automatically once it expires, or is rejected by the
+  endpoint.`
 	RemoteLoginExample string = `
   To log in to an endpoint:
   $ singularity remote login SylabsCloud`
@@ -125,7 +144,27 @@ const (
   user's logged-in status (or lack thereof) on that endpoint. If no endpoint is
   specified, it will check the status of the default remote (SylabsCloud). If
   you have logged in with an authentication token the validity of that token
-  will be checked.`
+  will be checked, and a warning is displayed if it expires within 7 days.`
 	RemoteStatusExample string = `
   $ singularity remote status SylabsCloud`
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// remote ping command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	RemotePingUse   string = `ping [ping options...] [remote_name]`
+	RemotePingShort string = `Check the health and response times of the services of a remote endpoint`
+	RemotePingLong  string = `
+  The 'remote ping' command checks each service of the specified remote
+  endpoint, and the keyservers added with 'keyserver add'. For each service it
+  reports whether it is reachable, the validity and expiry of its TLS
+  certificate, its version, and its response time. The validity and expiry of
+  the authentication token for the endpoint is also checked. If no endpoint is
+  specified, the default remote is checked.
+
+  With --format json or yaml, the time taken to resolve, connect to, complete
+  the TLS handshake with, and receive a response from each service is also
+  reported. The command exits with an error if any service is unhealthy.`
+	RemotePingExample string = `
+  $ singularity remote ping SylabsCloud
+
+  $ singularity remote ping --json`
 )
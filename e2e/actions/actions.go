@@ -10,6 +10,7 @@ package actions
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -28,6 +29,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
 )
 
 type actionTests struct {
@@ -181,6 +183,24 @@ func (c actionTests) actionExec(t *testing.T) {
 			argv: []string{"--no-home", c.env.ImagePath, "ls", "-ld", user.Dir},
 			exit: 1,
 		},
+		{
+			name: "HomeModeNone",
+			argv: []string{"--home-mode", "none", c.env.ImagePath, "ls", "-ld", user.Dir},
+			exit: 1,
+		},
+		{
+			name: "HomeModeEphemeral",
+			argv: []string{"--home-mode", "ephemeral", c.env.ImagePath, "sh", "-c", "ls -A " + user.Dir + " | wc -l"},
+			exit: 0,
+			wantOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ExactMatch, "0"),
+			},
+		},
+		{
+			name: "HomeModeInvalid",
+			argv: []string{"--home-mode", "persistent", c.env.ImagePath, "true"},
+			exit: 255,
+		},
 		// PID namespace, and override, in --containall mode. Uses --no-init to be able to check PID=1
 		{
 			name: "ContainAllPID",
@@ -213,6 +233,92 @@ func (c actionTests) actionExec(t *testing.T) {
 	}
 }
 
+// actionHomeModeImage tests that --home-mode image provides a home directory
+// overlay persisted in the SIF overlay partition, leaving the rest of the
+// image read-only.
+func (c actionTests) actionHomeModeImage(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+
+	user := e2e.UserProfile.HostUser(t)
+
+	testdir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "home-mode-image-", "")
+	defer cleanup(t)
+
+	sifImage := filepath.Join(testdir, "image.sif")
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("build"),
+		e2e.WithArgs(sifImage, c.env.ImagePath),
+		e2e.ExpectExit(0),
+	)
+
+	// the image must hold a writable overlay partition
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("NoOverlayPartition"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--home-mode", "image", sifImage, "true"),
+		e2e.ExpectExit(255),
+	)
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("overlay"),
+		e2e.WithArgs("create", "--size", "64", sifImage),
+		e2e.ExpectExit(0),
+	)
+
+	tests := []struct {
+		name        string
+		argv        []string
+		exit        int
+		wantOutputs []e2e.SingularityCmdResultOp
+	}{
+		{
+			name: "WriteHome",
+			argv: []string{"--home-mode", "image", sifImage, "sh", "-c", "echo persisted > " + user.Dir + "/file"},
+			exit: 0,
+		},
+		{
+			name: "ReadHome",
+			argv: []string{"--home-mode", "image", sifImage, "cat", user.Dir + "/file"},
+			exit: 0,
+			wantOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ExactMatch, "persisted"),
+			},
+		},
+		{
+			name: "ReadOnlyRootfs",
+			argv: []string{"--home-mode", "image", sifImage, "touch", "/rootfile"},
+			exit: 1,
+		},
+		{
+			name: "NotHostHome",
+			argv: []string{sifImage, "test", "-f", user.Dir + "/file"},
+			exit: 1,
+		},
+		{
+			name: "Writable",
+			argv: []string{"--home-mode", "image", "--writable", sifImage, "true"},
+			exit: 255,
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("exec"),
+			e2e.WithDir("/tmp"),
+			e2e.WithArgs(tt.argv...),
+			e2e.ExpectExit(tt.exit, tt.wantOutputs...),
+		)
+	}
+}
+
 // actionExecMultiProfile tests fuctionality using singularity exec under all native profiles that do not involve user namespaces.
 func (c actionTests) actionExecMultiProfile(t *testing.T) {
 	e2e.EnsureImage(t, c.env)
@@ -335,6 +441,22 @@ func (c actionTests) actionExecMultiProfile(t *testing.T) {
 						e2e.ExpectOutput(e2e.RegexMatch, `^(\s*)Server:(\s+)(1\.1\.1\.1)(\s*)\n`),
 					},
 				},
+				{
+					name: "ResolvConfSearch",
+					argv: []string{"--dns-search", "example.com,example.org", c.env.ImagePath, "grep", "^search", "/etc/resolv.conf"},
+					exit: 0,
+					wantOutputs: []e2e.SingularityCmdResultOp{
+						e2e.ExpectOutput(e2e.ExactMatch, "search example.com example.org"),
+					},
+				},
+				{
+					name: "AddHost",
+					argv: []string{"--add-host", "myhost:10.11.12.13", c.env.ImagePath, "grep", "myhost", "/etc/hosts"},
+					exit: 0,
+					wantOutputs: []e2e.SingularityCmdResultOp{
+						e2e.ExpectOutput(e2e.RegexMatch, `^10\.11\.12\.13\s+myhost$`),
+					},
+				},
 				{
 					name: "CustomHomePreservesRootShell",
 					argv: []string{"--home", "/tmp", c.env.ImagePath, "cat", "/etc/passwd"},
@@ -1103,6 +1225,7 @@ func (c actionTests) actionNetwork(t *testing.T) {
 		name       string
 		profile    e2e.Profile
 		netType    string
+		netArgs    []string
 		expectExit int
 	}{
 		{
@@ -1111,6 +1234,20 @@ func (c actionTests) actionNetwork(t *testing.T) {
 			netType:    "bridge",
 			expectExit: 0,
 		},
+		{
+			name:       "BridgeNetworkLimitRate",
+			profile:    e2e.RootProfile,
+			netType:    "bridge",
+			netArgs:    []string{"--network-limit", "rate=10mbit"},
+			expectExit: 0,
+		},
+		{
+			name:       "BridgeNetworkLimitInvalid",
+			profile:    e2e.RootProfile,
+			netType:    "bridge",
+			netArgs:    []string{"--network-limit", "rate=fast"},
+			expectExit: 255,
+		},
 		{
 			name:       "PtpNetwork",
 			profile:    e2e.RootProfile,
@@ -1149,7 +1286,7 @@ func (c actionTests) actionNetwork(t *testing.T) {
 			e2e.AsSubtest(tt.name),
 			e2e.WithProfile(tt.profile),
 			e2e.WithCommand("exec"),
-			e2e.WithArgs("--net", "--network", tt.netType, c.env.ImagePath, "id"),
+			e2e.WithArgs(append(append([]string{"--net", "--network", tt.netType}, tt.netArgs...), c.env.ImagePath, "id")...),
 			e2e.ExpectExit(tt.expectExit),
 		)
 	}
@@ -1636,6 +1773,197 @@ func (c actionTests) actionBinds(t *testing.T) {
 	}
 }
 
+// actionIDMapBinds tests the idmap bind option, mapping the owner of files
+// on the host to a container uid and gid with --fakeroot.
+func (c actionTests) actionIDMapBinds(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+
+	workspace, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "idmap-bind-", "")
+	defer e2e.Privileged(cleanup)
+
+	if err := fs.Touch(filepath.Join(workspace, "file")); err != nil {
+		t.Fatalf("failed to create file: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		profile     e2e.Profile
+		args        []string
+		wantOutputs []e2e.SingularityCmdResultOp
+		exit        int
+	}{
+		{
+			name:    "Fakeroot",
+			profile: e2e.FakerootProfile,
+			args: []string{
+				"--bind", workspace + ":/data:idmap=1234",
+				c.env.ImagePath,
+				"stat", "-c", "%u:%g", "/data/file",
+			},
+			wantOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ExactMatch, "1234:1234"),
+			},
+			exit: 0,
+		},
+		{
+			name:    "FakerootMount",
+			profile: e2e.FakerootProfile,
+			args: []string{
+				"--mount", "type=bind,source=" + workspace + ",destination=/data,idmap=1234.5678",
+				c.env.ImagePath,
+				"stat", "-c", "%u:%g", "/data/file",
+			},
+			wantOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ExactMatch, "1234:5678"),
+			},
+			exit: 0,
+		},
+		{
+			name:    "FakerootInvalid",
+			profile: e2e.FakerootProfile,
+			args: []string{
+				"--bind", workspace + ":/data:idmap=user",
+				c.env.ImagePath,
+				"true",
+			},
+			exit: 255,
+		},
+		{
+			name:    "NoFakeroot",
+			profile: e2e.UserProfile,
+			args: []string{
+				"--bind", workspace + ":/data:idmap=1234",
+				c.env.ImagePath,
+				"true",
+			},
+			exit: 255,
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(tt.profile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.exit, tt.wantOutputs...),
+		)
+	}
+}
+
+// actionCdi tests CDI devices requested with --device in native mode.
+func (c actionTests) actionCdi(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+
+	workspace, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "cdi-", "")
+	defer e2e.Privileged(cleanup)
+
+	jsonsDir := filepath.Join(workspace, "cdi")
+	mountDir := filepath.Join(workspace, "mount")
+	for _, d := range []string{jsonsDir, mountDir} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatalf("failed to create %s: %s", d, err)
+		}
+	}
+
+	spec := cdispecs.Spec{
+		Version: "0.5.0",
+		Kind:    "singularityCEtesting.sylabs.io/device",
+		Devices: []cdispecs.Device{
+			{
+				Name: "TesterDevice",
+				ContainerEdits: cdispecs.ContainerEdits{
+					DeviceNodes: []*cdispecs.DeviceNode{{Path: "/dev/kmsg"}},
+					Mounts: []*cdispecs.Mount{
+						{HostPath: mountDir, ContainerPath: "/tmp/mount1", Options: []string{"rw", "bind"}},
+						{HostPath: mountDir, ContainerPath: "/tmp/mount2", Options: []string{"ro", "bind"}},
+					},
+				},
+			},
+		},
+		ContainerEdits: cdispecs.ContainerEdits{
+			Env: []string{"ABCD=QWERTY"},
+		},
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("failed to marshal CDI spec: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(jsonsDir, "tester.json"), b, 0o644); err != nil {
+		t.Fatalf("failed to write CDI spec: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantOutputs []e2e.SingularityCmdResultOp
+		exit        int
+	}{
+		{
+			name: "Env",
+			args: []string{
+				"--device", "singularityCEtesting.sylabs.io/device=TesterDevice",
+				c.env.ImagePath,
+				"printenv", "ABCD",
+			},
+			wantOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ExactMatch, "QWERTY"),
+			},
+			exit: 0,
+		},
+		{
+			name: "Mounts",
+			args: []string{
+				"--device", "singularityCEtesting.sylabs.io/device=TesterDevice",
+				c.env.ImagePath,
+				"sh", "-c", "touch /tmp/mount1/file && test -f /tmp/mount2/file && ! touch /tmp/mount2/file2",
+			},
+			exit: 0,
+		},
+		{
+			name: "ContainDeviceNode",
+			args: []string{
+				"--contain",
+				"--device", "singularityCEtesting.sylabs.io/device=TesterDevice",
+				c.env.ImagePath,
+				"test", "-c", "/dev/kmsg",
+			},
+			exit: 0,
+		},
+		{
+			name: "InvalidDevice",
+			args: []string{
+				"--device", "singularityCEtesting.sylabs.io/device=DoesNotExist",
+				c.env.ImagePath,
+				"true",
+			},
+			exit: 255,
+		},
+	}
+
+	for _, profile := range e2e.NativeProfiles {
+		profile := profile
+		t.Run(profile.String(), func(t *testing.T) {
+			for _, tt := range tests {
+				c.env.RunSingularity(
+					t,
+					e2e.AsSubtest(tt.name),
+					e2e.WithProfile(profile),
+					e2e.WithCommand("exec"),
+					e2e.WithArgs(append([]string{"--cdi-dirs", jsonsDir}, tt.args...)...),
+					e2e.ExpectExit(tt.exit, tt.wantOutputs...),
+				)
+			}
+			e2e.Privileged(func(t *testing.T) {
+				if err := os.RemoveAll(filepath.Join(mountDir, "file")); err != nil {
+					t.Errorf("failed to delete %s: %s", mountDir, err)
+				}
+			})(t)
+		})
+	}
+}
+
 func (c actionTests) exitSignals(t *testing.T) {
 	e2e.EnsureImage(t, c.env)
 
@@ -1669,6 +1997,11 @@ func (c actionTests) exitSignals(t *testing.T) {
 			args: []string{c.env.ImagePath, "/bin/sh", "-c", "kill -ABRT $$"},
 			exit: 134,
 		},
+		{
+			name: "Exit255",
+			args: []string{c.env.ImagePath, "/bin/sh", "-c", "exit 255"},
+			exit: 255,
+		},
 	}
 
 	for _, tt := range tests {
@@ -1681,6 +2014,40 @@ func (c actionTests) exitSignals(t *testing.T) {
 			e2e.ExpectExit(tt.exit),
 		)
 	}
+
+	// In the foreground of a terminal, signals are not propagated to the
+	// container process, except those requested with --signal-proxy. The
+	// container process signals the starter, which relays the signal back to
+	// it.
+	script := "trap 'exit 42' USR1; kill -USR1 $PPID; sleep 2 & wait"
+	ttyTests := []struct {
+		name string
+		args []string
+		exit int
+	}{
+		{
+			name: "SignalProxy",
+			args: []string{"--signal-proxy", "USR1", c.env.ImagePath, "/bin/sh", "-c", script},
+			exit: 42,
+		},
+		{
+			name: "NoSignalProxy",
+			args: []string{c.env.ImagePath, "/bin/sh", "-c", script},
+			exit: 0,
+		},
+	}
+
+	for _, tt := range ttyTests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ConsoleRun(),
+			e2e.ExpectExit(tt.exit),
+		)
+	}
 }
 
 func (c actionTests) fuseMount(t *testing.T) {
@@ -2236,6 +2603,37 @@ func (c actionTests) bindImage(t *testing.T) {
 			},
 			exit: 0,
 		},
+		{
+			name:    "MountTypeImage",
+			profile: e2e.UserProfile,
+			args: []string{
+				"--mount", "type=image,source=" + sifSquashImage + ",destination=/squash",
+				c.env.ImagePath,
+				"test", "-f", filepath.Join("/squash", squashMarkerFile),
+			},
+			exit: 0,
+		},
+		{
+			name:    "MountTypeImagePartition",
+			profile: e2e.UserProfile,
+			args: []string{
+				// rootfs ID is now '4'
+				"--mount", "type=image,src=" + c.env.ImagePath + ",dst=/opt/tools,partition=4",
+				c.env.ImagePath,
+				"test", "-d", "/opt/tools/etc",
+			},
+			exit: 0,
+		},
+		{
+			name:    "MountTypeTmpfs",
+			profile: e2e.UserProfile,
+			args: []string{
+				"--mount", "type=tmpfs,destination=/new/scratch,tmpfs-mode=700",
+				c.env.ImagePath,
+				"sh", "-c", "test $(stat -c %a /new/scratch) = 700 && touch /new/scratch/file",
+			},
+			exit: 0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -2308,8 +2706,10 @@ func (c actionTests) actionNoMount(t *testing.T) {
 		testDefault   bool
 		testContained bool
 		// To test --no-mount cwd we need to chdir for the execution
-		cwd  string
-		exit int
+		cwd string
+		// Additional flags, e.g. to request the mount that is disabled
+		flags []string
+		exit  int
 	}{
 		{
 			name:          "proc",
@@ -2420,29 +2820,66 @@ func (c actionTests) actionNoMount(t *testing.T) {
 			testContained: true,
 			exit:          0,
 		},
+		// Generated /etc files can be disabled by abs path.
+		{
+			name:          "/etc/resolv.conf",
+			noMount:       "/etc/resolv.conf",
+			noMatch:       "on /etc/resolv.conf",
+			testDefault:   true,
+			testContained: true,
+			exit:          0,
+		},
+		{
+			name:          "/etc/passwd",
+			noMount:       "/etc/passwd",
+			noMatch:       "on /etc/passwd",
+			testDefault:   true,
+			testContained: true,
+			exit:          0,
+		},
+		{
+			name:          "/etc/group",
+			noMount:       "/etc/group",
+			noMatch:       "on /etc/group",
+			testDefault:   true,
+			testContained: true,
+			exit:          0,
+		},
+		// A hosts file generated for --add-host can be disabled by abs path.
+		{
+			name:          "/etc/hosts",
+			noMount:       "/etc/hosts",
+			noMatch:       "on /etc/hosts",
+			testDefault:   true,
+			testContained: true,
+			flags:         []string{"--add-host", "myhost:10.11.12.13"},
+			exit:          0,
+		},
 	}
 
 	for _, tt := range tests {
 		if tt.testDefault {
+			args := append([]string{"--no-mount", tt.noMount}, tt.flags...)
 			c.env.RunSingularity(
 				t,
 				e2e.WithDir(tt.cwd),
 				e2e.AsSubtest(tt.name),
 				e2e.WithProfile(e2e.UserProfile),
 				e2e.WithCommand("exec"),
-				e2e.WithArgs("--no-mount", tt.noMount, c.env.ImagePath, "mount"),
+				e2e.WithArgs(append(args, c.env.ImagePath, "mount")...),
 				e2e.ExpectExit(tt.exit,
 					e2e.ExpectOutput(e2e.UnwantedContainMatch, tt.noMatch)),
 			)
 		}
 		if tt.testContained {
+			args := append([]string{"--contain", "--no-mount", tt.noMount}, tt.flags...)
 			c.env.RunSingularity(
 				t,
 				e2e.WithDir(tt.cwd),
 				e2e.AsSubtest(tt.name+"Contained"),
 				e2e.WithProfile(e2e.UserProfile),
 				e2e.WithCommand("exec"),
-				e2e.WithArgs("--contain", "--no-mount", tt.noMount, c.env.ImagePath, "mount"),
+				e2e.WithArgs(append(args, c.env.ImagePath, "mount")...),
 				e2e.ExpectExit(tt.exit,
 					e2e.ExpectOutput(e2e.UnwantedContainMatch, tt.noMatch)),
 			)
@@ -2475,6 +2912,17 @@ func (c actionTests) actionCompat(t *testing.T) {
 			args:     []string{"--compat", c.env.ImagePath, "sh", "-c", "touch /test"},
 			exitCode: 0,
 		},
+		{
+			name:     "writable-tmpfs-false",
+			args:     []string{"--compat", "--writable-tmpfs=false", c.env.ImagePath, "sh", "-c", "touch /test"},
+			exitCode: 1,
+		},
+		{
+			name:     "read-only-writable",
+			args:     []string{"--read-only", "--writable", c.env.ImagePath, "true"},
+			exitCode: 255,
+			expect:   e2e.ExpectError(e2e.ContainMatch, "Cannot use --read-only with --writable"),
+		},
 		{
 			name:     "no-init",
 			args:     []string{"--compat", c.env.ImagePath, "sh", "-c", "ps"},
@@ -2784,6 +3232,131 @@ func (c actionTests) actionAuthTester(t *testing.T, withCustomAuthFile bool, pro
 	}
 }
 
+// actionCoreDir tests the collection of the crash of a container process with
+// --core-dir, in native and OCI mode.
+func (c actionTests) actionCoreDir(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+	e2e.EnsureOCISIF(t, c.env)
+
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &rl); err != nil {
+		t.Fatalf("while getting core file size limit: %v", err)
+	}
+	if rl.Max == 0 {
+		t.Skip("core dumps are disabled by the hard core file size limit")
+	}
+
+	tests := []struct {
+		name    string
+		profile e2e.Profile
+		image   string
+	}{
+		{
+			name:    "Native",
+			profile: e2e.UserProfile,
+			image:   c.env.ImagePath,
+		},
+		{
+			name:    "OCI",
+			profile: e2e.OCIUserProfile,
+			image:   c.env.OCISIFPath,
+		},
+	}
+
+	for _, tt := range tests {
+		coreDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "core-dir-", "")
+		defer cleanup(t)
+		cwd, cleanupCwd := e2e.MakeTempDir(t, c.env.TestDir, "core-cwd-", "")
+		defer cleanupCwd(t)
+
+		// --no-pid is required in OCI mode, as in a PID namespace sending a
+		// signal to our PID 1 has no effect here.
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(tt.profile),
+			e2e.WithDir(cwd),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs("--no-pid", "--core-dir", coreDir, "--pwd", cwd, tt.image, "/bin/sh", "-c", "kill -SEGV $$"),
+			e2e.ExpectExit(139),
+		)
+
+		mds, err := filepath.Glob(filepath.Join(coreDir, "crash-*.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mds) != 1 {
+			t.Errorf("%s: got %d crash metadata files, want 1", tt.name, len(mds))
+			continue
+		}
+		b, err := os.ReadFile(mds[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), `"signal": "SIGSEGV"`) {
+			t.Errorf("%s: crash metadata does not record SIGSEGV: %s", tt.name, b)
+		}
+	}
+}
+
+// actionVariants tests selection of the image to run from a variants manifest.
+func (c actionTests) actionVariants(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+
+	dir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "variants-", "")
+	defer cleanup(t)
+
+	manifest := filepath.Join(dir, "variants.toml")
+	content := fmt.Sprintf(`
+[[variant]]
+image = "/does/not/exist.sif"
+arch = "nonexistent"
+
+[[variant]]
+image = %q
+`, c.env.ImagePath)
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	noMatch := filepath.Join(dir, "nomatch.toml")
+	content = "[[variant]]\nimage = \"/does/not/exist.sif\"\narch = \"nonexistent\"\n"
+	if err := os.WriteFile(noMatch, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		exit     int
+		expect   e2e.SingularityCmdResultOp
+	}{
+		{
+			name:     "Match",
+			manifest: manifest,
+			exit:     0,
+			expect:   e2e.ExpectError(e2e.ContainMatch, "Selected image variant "+c.env.ImagePath),
+		},
+		{
+			name:     "NoMatch",
+			manifest: noMatch,
+			exit:     255,
+			expect:   e2e.ExpectError(e2e.ContainMatch, "no image variant matches this node"),
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs("variants://"+tt.manifest, "/bin/true"),
+			e2e.ExpectExit(tt.exit, tt.expect),
+		)
+	}
+}
+
 // E2ETests is the main func to trigger the test suite
 func E2ETests(env e2e.TestEnv) testhelper.Tests {
 	c := actionTests{
@@ -2796,6 +3369,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 		"action URI":                   c.RunFromURI,                     // action_URI
 		"exec":                         c.actionExec,                     // singularity exec
 		"exec under multiple profiles": c.actionExecMultiProfile,         // singularity exec
+		"home mode image":              c.actionHomeModeImage,            // singularity exec --home-mode image
 		"persistent overlay":           c.PersistentOverlay,              // Persistent Overlay
 		"persistent overlay unpriv":    c.PersistentOverlayUnpriv,        // Persistent Overlay Unprivileged
 		"run":                          c.actionRun,                      // singularity run
@@ -2822,6 +3396,8 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 		"issue 1950":                   c.issue1950,                      // https://github.com/sylabs/singularity/issues/1950
 		"network":                      c.actionNetwork,                  // test basic networking
 		"binds":                        c.actionBinds,                    // test various binds with --bind and --mount
+		"idmap binds":                  c.actionIDMapBinds,               // test idmap bind option with --fakeroot
+		"cdi":                          c.actionCdi,                      // test CDI devices with --device
 		"exit and signals":             c.exitSignals,                    // test exit and signals propagation
 		"fuse mount":                   c.fuseMount,                      // test fusemount option
 		"bind image":                   c.bindImage,                      // test bind image with --bind and --mount
@@ -2836,6 +3412,8 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 		"relWorkdirScratch":            np(c.relWorkdirScratch),          // test relative --workdir with --scratch
 		"ociRelWorkdirScratch":         np(c.actionOciRelWorkdirScratch), // test relative --workdir with --scratch in OCI mode
 		"auth":                         np(c.actionAuth),                 // tests action cmds w/authenticated pulls from OCI registries
+		"coreDir":                      c.actionCoreDir,                  // test --core-dir crash collection
+		"variants":                     c.actionVariants,                 // test image selection from a variants manifest
 		//
 		// OCI Runtime Mode
 		//
@@ -2858,5 +3436,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 		"ociHomeCwdPasswd":     c.actionOciHomeCwdPasswd,       // $HOME is correct in /etc/passwd, and is default cwd
 		"ociAllowSetuid":       c.actionOciAllowSetuid,         // --allow-setuid / check for nosuid mount options
 		"ociExitSignals":       c.ociExitSignals,               // test exit and signals propagation
+		"ociEncrypted":         np(c.actionOciEncrypted),       // pull --oci --encrypt, and run encrypted OCI-SIF
+		"ociData":              c.actionOciData,                // singularity data create, and --data in OCI mode
 	}
 }
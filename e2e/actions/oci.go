@@ -311,6 +311,22 @@ func (c actionTests) actionOciExec(t *testing.T) {
 				e2e.ExpectOutput(e2e.RegexMatch, `^(\s*)Server:(\s+)(1\.1\.1\.1)(\s*)\n`),
 			},
 		},
+		{
+			name: "ResolvConfSearch",
+			argv: []string{"--dns-search", "example.com,example.org", imageRef, "grep", "^search", "/etc/resolv.conf"},
+			exit: 0,
+			wantOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ExactMatch, "search example.com example.org"),
+			},
+		},
+		{
+			name: "AddHost",
+			argv: []string{"--add-host", "myhost:10.11.12.13", imageRef, "grep", "myhost", "/etc/hosts"},
+			exit: 0,
+			wantOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.RegexMatch, `^10\.11\.12\.13\s+myhost$`),
+			},
+		},
 		{
 			name: "CustomHomePreservesRootShell",
 			argv: []string{"--home", "/tmp", imageRef, "cat", "/etc/passwd"},
@@ -441,9 +457,21 @@ func (c actionTests) actionOciNetwork(t *testing.T) {
 		expectExit int
 	}{
 		{
-			name:       "InvalidNetworkRoot",
+			name:       "BridgeNetworkRoot",
 			profile:    e2e.OCIRootProfile,
 			netType:    "bridge",
+			expectExit: 0,
+		},
+		{
+			name:       "BridgePtpNetworkRoot",
+			profile:    e2e.OCIRootProfile,
+			netType:    "bridge,ptp",
+			expectExit: 0,
+		},
+		{
+			name:       "UnknownNetworkRoot",
+			profile:    e2e.OCIRootProfile,
+			netType:    "unknown",
 			expectExit: 255,
 		},
 		{
@@ -1093,6 +1121,16 @@ func (c actionTests) actionOciCompat(t *testing.T) {
 			args:     []string{imageRef, "sh", "-c", "touch /test"},
 			exitCode: 0,
 		},
+		{
+			name:     "read-only",
+			args:     []string{"--read-only", imageRef, "sh", "-c", "touch /test"},
+			exitCode: 1,
+		},
+		{
+			name:     "writable-tmpfs-false",
+			args:     []string{"--writable-tmpfs=false", imageRef, "sh", "-c", "touch /test"},
+			exitCode: 1,
+		},
 		{
 			name:     "no-init",
 			args:     []string{imageRef, "sh", "-c", "ps"},
@@ -2058,6 +2096,32 @@ func (c actionTests) actionOciNoMount(t *testing.T) {
 			noMatch:  "on /etc/localtime",
 			exit:     0,
 		},
+		// Generated /etc files can be disabled by abs path, with or without --no-compat.
+		{
+			name:    "/etc/resolv.conf",
+			noMount: "/etc/resolv.conf",
+			noMatch: "on /etc/resolv.conf",
+			exit:    0,
+		},
+		{
+			name:    "/etc/passwd",
+			noMount: "/etc/passwd",
+			noMatch: "on /etc/passwd",
+			exit:    0,
+		},
+		{
+			name:    "/etc/group",
+			noMount: "/etc/group",
+			noMatch: "on /etc/group",
+			exit:    0,
+		},
+		// Bind path entries are only mounted with --no-compat.
+		{
+			name:      "bind-path-compat",
+			noMount:   "/etc/hosts",
+			warnMatch: "only applies to singularity.conf bind paths with --no-compat",
+			exit:      0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -2398,3 +2462,210 @@ func (c actionTests) ociExitSignals(t *testing.T) {
 		}
 	}
 }
+
+// actionOciEncrypted tests pulling to an encrypted OCI-SIF, and running it.
+func (c actionTests) actionOciEncrypted(t *testing.T) {
+	e2e.EnsureOCIArchive(t, c.env)
+
+	// If the version of cryptsetup is not compatible with Singularity encryption,
+	// the pull commands are expected to fail
+	if err := e2e.CheckCryptsetupVersion(); err != nil {
+		t.Skip("cryptsetup is not compatible, skipping test")
+	}
+
+	tmpDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "oci-encrypted-", "")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup(t)
+		}
+	})
+
+	pemPubFile, pemPrivFile := e2e.GeneratePemFiles(t, tmpDir)
+	passphraseEnv := append(os.Environ(), "SINGULARITY_ENCRYPTION_PASSPHRASE="+e2e.Passphrase)
+
+	passphraseImage := filepath.Join(tmpDir, "passphrase.oci.sif")
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("PullPassphrase"),
+		e2e.WithProfile(e2e.RootProfile),
+		e2e.WithCommand("pull"),
+		e2e.WithArgs("--oci", "--encrypt", passphraseImage, "oci-archive:"+c.env.OCIArchivePath),
+		e2e.WithEnv(passphraseEnv),
+		e2e.ExpectExit(0),
+	)
+
+	pemImage := filepath.Join(tmpDir, "pem.oci.sif")
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("PullPEM"),
+		e2e.WithProfile(e2e.RootProfile),
+		e2e.WithCommand("pull"),
+		e2e.WithArgs("--oci", "--encrypt", "--pem-path", pemPubFile, pemImage, "oci-archive:"+c.env.OCIArchivePath),
+		e2e.ExpectExit(0),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("PullNoOCI"),
+		e2e.WithProfile(e2e.RootProfile),
+		e2e.WithCommand("pull"),
+		e2e.WithArgs("--encrypt", filepath.Join(tmpDir, "native.sif"), "oci-archive:"+c.env.OCIArchivePath),
+		e2e.WithEnv(passphraseEnv),
+		e2e.ExpectExit(255),
+	)
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      []string
+		exitCode int
+	}{
+		{
+			name:     "Passphrase",
+			args:     []string{passphraseImage, "/bin/true"},
+			env:      passphraseEnv,
+			exitCode: 0,
+		},
+		{
+			name:     "PEM",
+			args:     []string{"--pem-path", pemPrivFile, pemImage, "/bin/true"},
+			exitCode: 0,
+		},
+		{
+			name:     "NoKey",
+			args:     []string{passphraseImage, "/bin/true"},
+			exitCode: 255,
+		},
+		{
+			name:     "WrongPassphrase",
+			args:     []string{passphraseImage, "/bin/true"},
+			env:      append(os.Environ(), "SINGULARITY_ENCRYPTION_PASSPHRASE=wrong"),
+			exitCode: 255,
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.OCIRootProfile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.WithEnv(tt.env),
+			e2e.ExpectExit(tt.exitCode),
+		)
+	}
+
+	// Encrypted layers can only be opened by root.
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("User"),
+		e2e.WithProfile(e2e.OCIUserProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs(passphraseImage, "/bin/true"),
+		e2e.WithEnv(passphraseEnv),
+		e2e.ExpectExit(255),
+	)
+}
+
+// actionOciData tests creating a data container from a directory, and mounting
+// it into an OCI-mode container with --data.
+func (c actionTests) actionOciData(t *testing.T) {
+	e2e.EnsureOCISIF(t, c.env)
+	imageRef := "oci-sif:" + c.env.OCISIFPath
+
+	require.Command(t, "mksquashfs")
+	require.Command(t, "squashfuse")
+	require.Command(t, "fusermount")
+
+	testdir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "data-", "")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup(t)
+		}
+	})
+
+	dataDir := filepath.Join(testdir, "data")
+	if err := os.Mkdir(dataDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const dataMarkerFile = "data_marker"
+	if err := os.WriteFile(filepath.Join(dataDir, dataMarkerFile), []byte("data\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dataContainer := filepath.Join(testdir, "data.sif")
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("Create"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("data create"),
+		e2e.WithArgs(dataContainer, dataDir),
+		e2e.ExpectExit(0),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("CreateExists"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("data create"),
+		e2e.WithArgs(dataContainer, dataDir),
+		e2e.ExpectExit(255),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("CreateForce"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("data create"),
+		e2e.WithArgs("--force", dataContainer, dataDir),
+		e2e.ExpectExit(0),
+	)
+
+	tests := []struct {
+		name string
+		args []string
+		exit int
+	}{
+		{
+			name: "Read",
+			args: []string{"--data", dataContainer + ":/data", imageRef, "cat", filepath.Join("/data", dataMarkerFile)},
+			exit: 0,
+		},
+		{
+			name: "ReadOnly",
+			args: []string{"--data", dataContainer + ":/data", imageRef, "touch", "/data/new_file"},
+			exit: 1,
+		},
+		{
+			name: "NoDest",
+			args: []string{"--data", dataContainer, imageRef, "true"},
+			exit: 255,
+		},
+		{
+			name: "NotData",
+			args: []string{"--data", c.env.OCISIFPath + ":/data", imageRef, "true"},
+			exit: 255,
+		},
+		{
+			name: "RunData",
+			args: []string{"oci-sif:" + dataContainer, "true"},
+			exit: 255,
+		},
+	}
+
+	for _, p := range e2e.OCIProfiles {
+		t.Run(p.String(), func(t *testing.T) {
+			for _, tt := range tests {
+				c.env.RunSingularity(
+					t,
+					e2e.AsSubtest(tt.name),
+					e2e.WithProfile(p),
+					e2e.WithCommand("exec"),
+					e2e.WithArgs(tt.args...),
+					e2e.ExpectExit(tt.exit),
+				)
+			}
+		})
+	}
+}
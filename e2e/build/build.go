@@ -398,6 +398,58 @@ func (c imgBuildTests) buildLocalImage(t *testing.T) {
 	}
 }
 
+// buildOnlyPaths checks that --only extracts just the requested paths of a SIF
+// or OCI-SIF image into a sandbox.
+func (c imgBuildTests) buildOnlyPaths(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+	e2e.EnsureOCISIF(t, c.env)
+
+	tmpdir, cleanup := c.tempDir(t, "build-only-paths")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup()
+		}
+	})
+
+	tt := []struct {
+		name      string
+		buildSpec string
+		args      []string
+		exit      int
+	}{
+		{"SIF", c.env.ImagePath, []string{"--sandbox", "--only", "/etc,bin"}, 0},
+		{"OCISIF", c.env.OCISIFPath, []string{"--sandbox", "--only", "/etc,bin"}, 0},
+		{"MissingPath", c.env.ImagePath, []string{"--sandbox", "--only", "/this/does/not/exist"}, 255},
+		{"NoSandbox", c.env.ImagePath, []string{"--only", "/etc"}, 255},
+	}
+
+	for i, tc := range tt {
+		imagePath := filepath.Join(tmpdir, fmt.Sprintf("sandbox-%d", i))
+		args := append(tc.args, imagePath, tc.buildSpec)
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tc.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("build"),
+			e2e.WithArgs(args...),
+			e2e.PostRun(func(t *testing.T) {
+				if t.Failed() || tc.exit != 0 {
+					return
+				}
+				for _, p := range []string{"etc", "bin", ".singularity.d"} {
+					if _, err := os.Lstat(filepath.Join(imagePath, p)); err != nil {
+						t.Errorf("expected %s in sandbox: %v", p, err)
+					}
+				}
+				if _, err := os.Lstat(filepath.Join(imagePath, "usr")); err == nil {
+					t.Errorf("unexpected usr directory in sandbox")
+				}
+			}),
+			e2e.ExpectExit(tc.exit),
+		)
+	}
+}
+
 func (c imgBuildTests) badPath(t *testing.T) {
 	dn, cleanup := c.tempDir(t, "bad-path")
 	t.Cleanup(func() {
@@ -2452,6 +2504,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 		"build encrypted with passphrase": c.buildEncryptPassphrase,        // build encrypted images with passphrase
 		"definition":                      c.buildDefinition,               // builds from definition template
 		"from local image":                c.buildLocalImage,               // build and image from an existing image
+		"only paths":                      c.buildOnlyPaths,                // build sandbox with only some paths of an image
 		"from":                            c.buildFrom,                     // builds from definition file and URI
 		"multistage":                      c.buildMultiStageDefinition,     // multistage build from definition templates
 		"non-root build":                  c.nonRootBuild,                  // build sifs from non-root
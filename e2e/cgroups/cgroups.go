@@ -646,6 +646,79 @@ func (c *ctx) actionFlagsRootless(t *testing.T) {
 	}
 }
 
+// actionCgroupStats checks that --cgroup-stats exposes the container cgroup
+// read-only at /.singularity.d/cgroup.
+func (c *ctx) actionCgroupStats(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+	require.CgroupsV2Unified(t)
+
+	tests := []struct {
+		name       string
+		args       []string
+		expectExit int
+		expectOp   e2e.SingularityCmdResultOp
+	}{
+		{
+			name:       "MemoryMax",
+			args:       []string{"--cgroup-stats", "--memory", "500M", c.env.ImagePath, "cat", "/.singularity.d/cgroup/memory.max"},
+			expectExit: 0,
+			expectOp:   e2e.ExpectOutput(e2e.ExactMatch, "524288000"),
+		},
+		{
+			name:       "ReadOnly",
+			args:       []string{"--cgroup-stats", "--memory", "500M", c.env.ImagePath, "/bin/sh", "-c", "echo max > /.singularity.d/cgroup/memory.max"},
+			expectExit: 1,
+			expectOp:   e2e.ExpectError(e2e.ContainMatch, "Read-only file system"),
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.RootProfile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.expectExit, tt.expectOp),
+		)
+	}
+}
+
+// actionVirtualProc checks that --virtual-proc shows the container limits in
+// /proc/cpuinfo and /proc/meminfo.
+func (c *ctx) actionVirtualProc(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+	require.Cgroups(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expectOp e2e.SingularityCmdResultOp
+	}{
+		{
+			name:     "CpusetCpus",
+			args:     []string{"--virtual-proc", "--cpuset-cpus", "0", c.env.ImagePath, "grep", "-c", "^processor", "/proc/cpuinfo"},
+			expectOp: e2e.ExpectOutput(e2e.ExactMatch, "1"),
+		},
+		{
+			name:     "Memory",
+			args:     []string{"--virtual-proc", "--memory", "500M", c.env.ImagePath, "grep", "MemTotal", "/proc/meminfo"},
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `^MemTotal:\s+512000 kB$`),
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.RootProfile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(0, tt.expectOp),
+		)
+	}
+}
+
 func (c *ctx) instanceFlags(t *testing.T, profile e2e.Profile) {
 	e2e.EnsureImage(t, c.env)
 
@@ -795,5 +868,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 		"action rootless cgroups":         np(env.WithRootlessManagers(c.actionApplyRootless)),
 		"action flags root cgroups":       np(env.WithRootManagers(c.actionFlagsRoot)),
 		"action flags rootless cgroups":   np(env.WithRootlessManagers(c.actionFlagsRootless)),
+		"action cgroup stats":             np(env.WithRootManagers(c.actionCgroupStats)),
+		"action virtual proc":             np(env.WithRootManagers(c.actionVirtualProc)),
 	}
 }
@@ -0,0 +1,109 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/e2e/internal/e2e"
+	"github.com/sylabs/singularity/v4/e2e/internal/testhelper"
+)
+
+type ctx struct {
+	env e2e.TestEnv
+}
+
+func (c ctx) testDiff(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+	e2e.EnsureOCISIF(t, c.env)
+
+	tmpDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "diff-", "")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup(t)
+		}
+	})
+
+	sandbox := filepath.Join(tmpDir, "sandbox")
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("build"),
+		e2e.WithArgs("--sandbox", sandbox, c.env.ImagePath),
+		e2e.ExpectExit(0),
+	)
+	if t.Failed() {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(sandbox, "added"), []byte("added"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(sandbox, "etc", "hostname")); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		exit     int
+		expectOp e2e.SingularityCmdResultOp
+	}{
+		{
+			name:     "SIFIdentical",
+			args:     []string{c.env.ImagePath, c.env.ImagePath},
+			exit:     0,
+			expectOp: e2e.ExpectOutput(e2e.ExactMatch, ""),
+		},
+		{
+			name:     "SIFSandbox",
+			args:     []string{c.env.ImagePath, sandbox},
+			exit:     0,
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `(?m)^A /added$`),
+		},
+		{
+			name:     "SIFSandboxJSON",
+			args:     []string{"--json", c.env.ImagePath, sandbox},
+			exit:     0,
+			expectOp: e2e.ExpectOutput(e2e.ContainMatch, `"path": "/added"`),
+		},
+		{
+			name:     "OCISIFConfigOnly",
+			args:     []string{"--config-only", "--json", c.env.OCISIFPath, c.env.OCISIFPath},
+			exit:     0,
+			expectOp: e2e.ExpectOutput(e2e.ContainMatch, `"files": []`),
+		},
+		{
+			name:     "MissingImage",
+			args:     []string{c.env.ImagePath, filepath.Join(tmpDir, "missing.sif")},
+			exit:     255,
+			expectOp: e2e.ExpectError(e2e.ContainMatch, "While comparing"),
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("diff"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.exit, tt.expectOp),
+		)
+	}
+}
+
+// E2ETests is the main func to trigger the test suite
+func E2ETests(env e2e.TestEnv) testhelper.Tests {
+	c := ctx{
+		env: env,
+	}
+
+	return testhelper.Tests{
+		"diff": c.testDiff,
+	}
+}
@@ -1286,6 +1286,55 @@ func (c ctx) testDockerUSERWorker(t *testing.T, container string) {
 				e2e.ExpectOutput(e2e.ContainMatch, `uid=2000(testuser) gid=2000(testgroup)`),
 			},
 		},
+		// `--oci` modes: USER overridden with `--oci-user`
+		{
+			name:    "OCIUserOverrideName",
+			cmd:     "run",
+			profile: e2e.OCIUserProfile,
+			args:    []string{"--oci-user", "root", container},
+			expectOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ContainMatch, `uid=0(root) gid=0(root)`),
+			},
+		},
+		{
+			name:    "OCIUserOverrideNumeric",
+			cmd:     "run",
+			profile: e2e.OCIUserProfile,
+			args:    []string{"--oci-user", "3000:3001", container},
+			expectOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ContainMatch, `uid=3000 gid=3001`),
+			},
+		},
+		{
+			name:    "OCIRootOverrideGroup",
+			cmd:     "run",
+			profile: e2e.OCIRootProfile,
+			args:    []string{"--oci-user", "testuser:root", container},
+			expectOutputs: []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ContainMatch, `uid=2000(testuser) gid=0(root)`),
+			},
+		},
+		{
+			name:       "OCIUserOverrideUnknown",
+			cmd:        "run",
+			profile:    e2e.OCIUserProfile,
+			args:       []string{"--oci-user", "nosuchuser", container},
+			expectExit: 255,
+		},
+		{
+			name:       "OCIUserOverrideFakeroot",
+			cmd:        "run",
+			profile:    e2e.OCIFakerootProfile,
+			args:       []string{"--oci-user", "root", container},
+			expectExit: 255,
+		},
+		{
+			name:       "NativeUserOverride",
+			cmd:        "run",
+			profile:    e2e.UserProfile,
+			args:       []string{"--oci-user", "root", container},
+			expectExit: 255,
+		},
 		// `--oci` modes: check that we correctly error on conflict with `--home`
 		{
 			name:       "WithHomeOCIUser",
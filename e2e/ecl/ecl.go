@@ -1,5 +1,5 @@
 // Copyright (c) 2020, Control Command Inc. All rights reserved.
-// Copyright (c) 2021-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2021-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -14,6 +14,7 @@ import (
 	"github.com/sylabs/singularity/v4/e2e/internal/testhelper"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/syecl"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 )
 
 // KeyMap contains test keys.
@@ -36,6 +37,25 @@ func (c *ctx) eclConfig(t *testing.T) {
 	signedOne := filepath.Join(tmpDir, "signed_one.sif")
 	unsigned := filepath.Join(tmpDir, "unsigned.sif")
 
+	e2e.EnsureOCISIF(t, c.env)
+	ociSigned := filepath.Join(tmpDir, "signed.oci.sif")
+	ociUnsigned := filepath.Join(tmpDir, "unsigned.oci.sif")
+	for _, dst := range []string{ociSigned, ociUnsigned} {
+		if err := fs.CopyFile(c.env.OCISIFPath, dst, 0o644); err != nil {
+			t.Fatalf("while copying OCI-SIF image: %s", err)
+		}
+	}
+
+	cosignPrivKey := filepath.Join("..", "test", "keys", "ecdsa-private.pem")
+	cosignPubKey, err := filepath.Abs(filepath.Join("..", "test", "keys", "ecdsa-public.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPubKey, err := filepath.Abs(filepath.Join("..", "test", "keys", "rsa-public.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	defer func() {
 		c.env.KeyringDir = ""
 		remove(t)
@@ -324,6 +344,121 @@ func (c *ctx) eclConfig(t *testing.T) {
 			args: []string{signedOne, "true"},
 			exit: 0,
 		},
+		{
+			name:    "sign oci-sif image with cosign key",
+			command: "sign",
+			profile: e2e.UserProfile,
+			args:    []string{"--key", cosignPrivKey, ociSigned},
+			exit:    0,
+		},
+		{
+			name:    "run oci-sif with whitelist cosign key and signed image",
+			command: "exec",
+			profile: e2e.OCIUserProfile,
+			config: &syecl.EclConfig{
+				Activated: true,
+				ExecGroups: []syecl.Execgroup{
+					{
+						TagName:  "group1",
+						ListMode: "whitelist",
+						DirPath:  tmpDir,
+						KeyPaths: []string{cosignPubKey},
+					},
+				},
+			},
+			args: []string{ociSigned, "true"},
+			exit: 0,
+		},
+		{
+			name:    "run oci-sif with whitelist other key and signed image",
+			command: "exec",
+			profile: e2e.OCIUserProfile,
+			config: &syecl.EclConfig{
+				Activated: true,
+				ExecGroups: []syecl.Execgroup{
+					{
+						TagName:  "group1",
+						ListMode: "whitelist",
+						DirPath:  tmpDir,
+						KeyPaths: []string{otherPubKey},
+					},
+				},
+			},
+			args: []string{ociSigned, "true"},
+			exit: 255,
+		},
+		{
+			name:    "run oci-sif with whitelist cosign key and unsigned image",
+			command: "exec",
+			profile: e2e.OCIUserProfile,
+			config: &syecl.EclConfig{
+				Activated: true,
+				ExecGroups: []syecl.Execgroup{
+					{
+						TagName:  "group1",
+						ListMode: "whitelist",
+						DirPath:  tmpDir,
+						KeyPaths: []string{cosignPubKey},
+					},
+				},
+			},
+			args: []string{ociUnsigned, "true"},
+			exit: 255,
+		},
+		{
+			name:    "run oci-sif with blacklist cosign key and signed image",
+			command: "exec",
+			profile: e2e.OCIUserProfile,
+			config: &syecl.EclConfig{
+				Activated: true,
+				ExecGroups: []syecl.Execgroup{
+					{
+						TagName:  "group1",
+						ListMode: "blacklist",
+						DirPath:  tmpDir,
+						KeyPaths: []string{cosignPubKey},
+					},
+				},
+			},
+			args: []string{ociSigned, "true"},
+			exit: 255,
+		},
+		{
+			name:    "run sif in oci mode with whitelist key1 and signed image",
+			command: "exec",
+			profile: e2e.OCIUserProfile,
+			config: &syecl.EclConfig{
+				Activated: true,
+				ExecGroups: []syecl.Execgroup{
+					{
+						TagName:  "group1",
+						ListMode: "whitelist",
+						DirPath:  tmpDir,
+						KeyFPs:   []string{KeyMap["key1"]},
+					},
+				},
+			},
+			args: []string{signed, "true"},
+			exit: 0,
+		},
+		{
+			name:    "run sif in oci mode with whitelist key1 and unsigned image",
+			command: "exec",
+			profile: e2e.OCIUserProfile,
+			config: &syecl.EclConfig{
+				Activated: true,
+				ExecGroups: []syecl.Execgroup{
+					{
+						TagName:  "group1",
+						ListMode: "whitelist",
+						DirPath:  tmpDir,
+						KeyFPs:   []string{KeyMap["key1"]},
+					},
+				},
+			},
+			args: []string{unsigned, "true"},
+			exit: 255,
+		},
 		{
 			name:    "remove key1 from global",
 			command: "key remove",
@@ -333,13 +333,16 @@ func (c ctx) singularityEnvFile(t *testing.T) {
 	dir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "envfile-", "")
 	defer cleanup(t)
 	p := filepath.Join(dir, "env.file")
+	p2 := filepath.Join(dir, "env2.file")
 
 	tests := []struct {
 		name     string
 		image    string
 		envFile  string
+		envFile2 string
 		envOpt   []string
 		hostEnv  []string
+		noEnv    bool
 		matchEnv string
 		matchVal string
 	}{
@@ -414,6 +417,54 @@ func (c ctx) singularityEnvFile(t *testing.T) {
 			matchEnv: "LD_LIBRARY_PATH",
 			matchVal: "/foo,:" + singularityLibs,
 		},
+		{
+			name:     "MultipleFilesPrecedence",
+			image:    c.env.ImagePath,
+			envFile:  "FOO=first",
+			envFile2: "FOO=second",
+			matchEnv: "FOO",
+			matchVal: "second",
+		},
+		{
+			name:     "MultipleFilesExpansion",
+			image:    c.env.ImagePath,
+			envFile:  "FOO=first",
+			envFile2: `BAR="\$FOO:second"`,
+			matchEnv: "BAR",
+			matchVal: "first:second",
+		},
+		{
+			name:     "MultiLineValue",
+			image:    c.env.ImagePath,
+			envFile:  "FOO='one\ntwo'",
+			matchEnv: "FOO",
+			matchVal: "one two",
+		},
+		{
+			name:     "NoEnvSingularityEnv",
+			image:    c.env.ImagePath,
+			hostEnv:  []string{"SINGULARITYENV_FOO=host"},
+			noEnv:    true,
+			matchEnv: "FOO",
+			matchVal: "",
+		},
+		{
+			name:     "NoEnvHostEnv",
+			image:    c.env.ImagePath,
+			hostEnv:  []string{"FOO=host"},
+			noEnv:    true,
+			matchEnv: "FOO",
+			matchVal: "",
+		},
+		{
+			name:     "NoEnvEnvFile",
+			image:    c.env.ImagePath,
+			envFile:  "FOO=file",
+			hostEnv:  []string{"SINGULARITYENV_FOO=host"},
+			noEnv:    true,
+			matchEnv: "FOO",
+			matchVal: "file",
+		},
 	}
 
 	for _, tt := range tests {
@@ -425,6 +476,13 @@ func (c ctx) singularityEnvFile(t *testing.T) {
 			os.WriteFile(p, []byte(tt.envFile), 0o644)
 			args = append(args, "--env-file", p)
 		}
+		if tt.envFile2 != "" {
+			os.WriteFile(p2, []byte(tt.envFile2), 0o644)
+			args = append(args, "--env-file", p2)
+		}
+		if tt.noEnv {
+			args = append(args, "--no-env")
+		}
 		args = append(args, tt.image, "/bin/sh", "-c", "echo $"+tt.matchEnv)
 
 		c.env.RunSingularity(
@@ -141,6 +141,137 @@ func (c ctx) ociTestNvidiaLegacy(t *testing.T) {
 	}
 }
 
+// testNvidiaGPUs checks GPU selection with --gpus, using legacy binds, in
+// native and OCI mode.
+func (c ctx) testNvidiaGPUs(t *testing.T) {
+	require.Nvidia(t)
+
+	imageURL := "docker://ubuntu:20.04"
+
+	tests := []struct {
+		name       string
+		profile    e2e.Profile
+		args       []string
+		expectExit int
+		expectOp   e2e.SingularityCmdResultOp
+	}{
+		{
+			name:     "UserCUDAVisibleDevices",
+			profile:  e2e.UserProfile,
+			args:     []string{"--nv", "--gpus", "0", imageURL, "sh", "-c", "echo $CUDA_VISIBLE_DEVICES"},
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `^GPU-[^,]+$`),
+		},
+		{
+			name:     "UserContainDevices",
+			profile:  e2e.UserProfile,
+			args:     []string{"--contain", "--nv", "--gpus", "0", imageURL, "nvidia-smi", "-L"},
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `^GPU 0: [^\n]+$`),
+		},
+		{
+			name:     "UserEnvOverride",
+			profile:  e2e.UserProfile,
+			args:     []string{"--nv", "--gpus", "0", "--env", "CUDA_VISIBLE_DEVICES=none", imageURL, "sh", "-c", "echo $CUDA_VISIBLE_DEVICES"},
+			expectOp: e2e.ExpectOutput(e2e.ExactMatch, "none"),
+		},
+		{
+			name:       "UserBadIndex",
+			profile:    e2e.UserProfile,
+			args:       []string{"--nv", "--gpus", "999", imageURL, "true"},
+			expectExit: 255,
+			expectOp:   e2e.ExpectError(e2e.ContainMatch, "no GPU with index 999"),
+		},
+		{
+			name:     "OCIUserCUDAVisibleDevices",
+			profile:  e2e.OCIUserProfile,
+			args:     []string{"--nv", "--gpus", "0", imageURL, "sh", "-c", "echo $CUDA_VISIBLE_DEVICES"},
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `^GPU-[^,]+$`),
+		},
+		{
+			name:     "OCIUserDevices",
+			profile:  e2e.OCIUserProfile,
+			args:     []string{"--nv", "--gpus", "0", imageURL, "nvidia-smi", "-L"},
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `^GPU 0: [^\n]+$`),
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(tt.profile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.expectExit, tt.expectOp),
+		)
+	}
+}
+
+// ociTestNvCCLI checks --nv --nvccli GPU setup in OCI mode, which uses the
+// NVIDIA CDI spec generated by nvidia-ctk.
+func (c ctx) ociTestNvCCLI(t *testing.T) {
+	require.Nvidia(t)
+
+	specs, _ := filepath.Glob("/etc/cdi/nvidia*")
+	runSpecs, _ := filepath.Glob("/var/run/cdi/nvidia*")
+	if len(specs)+len(runSpecs) == 0 {
+		t.Skip("no NVIDIA CDI spec found in /etc/cdi or /var/run/cdi")
+	}
+
+	imageURL := "docker://ubuntu:20.04"
+
+	tests := []struct {
+		name        string
+		profile     e2e.Profile
+		args        []string
+		expectExit  int
+		expectMatch e2e.SingularityCmdResultOp
+	}{
+		{
+			name:       "User",
+			profile:    e2e.OCIUserProfile,
+			args:       []string{"--nv", "--nvccli", imageURL, "nvidia-smi"},
+			expectExit: 0,
+		},
+		{
+			name:       "Fakeroot",
+			profile:    e2e.OCIFakerootProfile,
+			args:       []string{"--nv", "--nvccli", imageURL, "nvidia-smi"},
+			expectExit: 0,
+		},
+		{
+			name:       "Root",
+			profile:    e2e.OCIRootProfile,
+			args:       []string{"--nv", "--nvccli", imageURL, "nvidia-smi"},
+			expectExit: 0,
+		},
+		{
+			name:        "GPU0",
+			profile:     e2e.OCIUserProfile,
+			args:        []string{"--nv", "--nvccli", "--gpus", "0", imageURL, "nvidia-smi", "-L"},
+			expectExit:  0,
+			expectMatch: e2e.ExpectOutput(e2e.RegexMatch, `^GPU 0:`),
+		},
+		{
+			name:        "InvalidGPUs",
+			profile:     e2e.OCIUserProfile,
+			args:        []string{"--nv", "--nvccli", "--gpus", "mig:0", imageURL, "nvidia-smi"},
+			expectExit:  255,
+			expectMatch: e2e.ExpectError(e2e.ContainMatch, "invalid MIG device"),
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(tt.profile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.expectExit, tt.expectMatch),
+		)
+	}
+}
+
 func (c ctx) testNvCCLI(t *testing.T) {
 	require.Nvidia(t)
 	require.NvCCLI(t)
@@ -364,6 +495,140 @@ func (c ctx) ociTestRocm(t *testing.T) {
 	}
 }
 
+// testIntel checks that the Intel GPU render nodes are available in the
+// container with --intel, including when /dev is contained.
+func (c ctx) testIntel(t *testing.T) {
+	require.Intel(t)
+
+	e2e.EnsureImage(t, c.env)
+
+	tests := []struct {
+		name    string
+		profile e2e.Profile
+		args    []string
+	}{
+		{
+			name:    "User",
+			profile: e2e.UserProfile,
+			args:    []string{"--intel", c.env.ImagePath, "sh", "-c", "ls /dev/dri/renderD*"},
+		},
+		{
+			name:    "UserContain",
+			profile: e2e.UserProfile,
+			args:    []string{"--contain", "--intel", c.env.ImagePath, "sh", "-c", "ls /dev/dri/renderD*"},
+		},
+		{
+			name:    "UserNamespace",
+			profile: e2e.UserNamespaceProfile,
+			args:    []string{"--contain", "--intel", c.env.ImagePath, "sh", "-c", "ls /dev/dri/renderD*"},
+		},
+		{
+			name:    "Fakeroot",
+			profile: e2e.FakerootProfile,
+			args:    []string{"--contain", "--intel", c.env.ImagePath, "sh", "-c", "ls /dev/dri/renderD*"},
+		},
+		{
+			name:    "Root",
+			profile: e2e.RootProfile,
+			args:    []string{"--contain", "--intel", c.env.ImagePath, "sh", "-c", "ls /dev/dri/renderD*"},
+		},
+	}
+
+	for _, tt := range tests {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(tt.profile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(0),
+		)
+	}
+}
+
+func (c ctx) ociTestIntel(t *testing.T) {
+	require.Intel(t)
+
+	e2e.EnsureOCIArchive(t, c.env)
+	imageRef := "oci-archive:" + c.env.OCIArchivePath
+
+	for _, profile := range e2e.OCIProfiles {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(profile.String()),
+			e2e.WithProfile(profile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs("--intel", imageRef, "sh", "-c", "ls /dev/dri/renderD*"),
+			e2e.ExpectExit(0),
+		)
+	}
+}
+
+// testRdma checks that the RDMA devices, and fork safety environment, are
+// available in the container with --rdma, in native and OCI mode.
+func (c ctx) testRdma(t *testing.T) {
+	require.Rdma(t)
+
+	e2e.EnsureImage(t, c.env)
+	e2e.EnsureOCISIF(t, c.env)
+
+	tests := []struct {
+		name     string
+		profile  e2e.Profile
+		args     []string
+		expectOp e2e.SingularityCmdResultOp
+	}{
+		{
+			name:    "User",
+			profile: e2e.UserProfile,
+			args:    []string{"--rdma", c.env.ImagePath, "sh", "-c", "ls /dev/infiniband/uverbs*"},
+		},
+		{
+			name:    "UserContain",
+			profile: e2e.UserProfile,
+			args:    []string{"--contain", "--rdma", c.env.ImagePath, "sh", "-c", "ls /dev/infiniband/uverbs*"},
+		},
+		{
+			name:     "UserEnv",
+			profile:  e2e.UserProfile,
+			args:     []string{"--cleanenv", "--rdma", c.env.ImagePath, "sh", "-c", "echo $RDMAV_FORK_SAFE"},
+			expectOp: e2e.ExpectOutput(e2e.ExactMatch, "1"),
+		},
+		{
+			name:    "OCIUser",
+			profile: e2e.OCIUserProfile,
+			args:    []string{"--rdma", c.env.OCISIFPath, "sh", "-c", "ls /dev/infiniband/uverbs*"},
+		},
+		{
+			name:     "OCIUserEnv",
+			profile:  e2e.OCIUserProfile,
+			args:     []string{"--rdma", c.env.OCISIFPath, "sh", "-c", "echo $RDMAV_FORK_SAFE"},
+			expectOp: e2e.ExpectOutput(e2e.ExactMatch, "1"),
+		},
+		{
+			name:     "OCIUserEnvOverride",
+			profile:  e2e.OCIUserProfile,
+			args:     []string{"--rdma", "--env", "RDMAV_FORK_SAFE=0", c.env.OCISIFPath, "sh", "-c", "echo $RDMAV_FORK_SAFE"},
+			expectOp: e2e.ExpectOutput(e2e.ExactMatch, "0"),
+		},
+	}
+
+	for _, tt := range tests {
+		ops := []e2e.SingularityCmdResultOp{}
+		if tt.expectOp != nil {
+			ops = append(ops, tt.expectOp)
+		}
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(tt.profile),
+			e2e.WithCommand("exec"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(0, ops...),
+		)
+	}
+}
+
 func (c ctx) testBuildNvidiaLegacy(t *testing.T) {
 	require.Nvidia(t)
 
@@ -623,13 +888,18 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 
 	return testhelper.Tests{
 		"nvidia":       c.testNvidiaLegacy,
+		"nvidia gpus":  c.testNvidiaGPUs,
 		"nvccli":       c.testNvCCLI,
 		"rocm":         c.testRocm,
+		"intel":        c.testIntel,
+		"rdma":         c.testRdma,
 		"build nvidia": c.testBuildNvidiaLegacy,
 		"build nvccli": c.testBuildNvCCLI,
 		"build rocm":   c.testBuildRocm,
 		// oci mode
 		"oci nvidia": c.ociTestNvidiaLegacy,
 		"oci rocm":   c.ociTestRocm,
+		"oci nvccli": c.ociTestNvCCLI,
+		"oci intel":  c.ociTestIntel,
 	}
 }
@@ -78,9 +78,11 @@ func (c ctx) testCommands(t *testing.T) {
 		{"Build", "build"},
 		{"Cache", "cache"},
 		{"Capability", "capability"},
+		{"Data", "data"},
 		{"Exec", "exec"},
 		{"Instance", "instance"},
 		{"Key", "key"},
+		{"Mount", "mount"},
 		{"OCI", "oci"},
 		{"Plugin", "plugin"},
 		{"Inspect", "inspect"},
@@ -89,11 +91,13 @@ func (c ctx) testCommands(t *testing.T) {
 		{"Run", "run"},
 		{"Run-help", "run-help"},
 		{"Remote", "remote"},
+		{"Scan", "scan"},
 		{"Search", "search"},
 		{"Shell", "shell"},
 		{"SIF", "sif"},
 		{"Sign", "sign"},
 		{"Test", "test"},
+		{"Umount", "umount"},
 		{"Verify", "verify"},
 		{"InstanceStart", "instance start"},
 		{"InstanceList", "instance list"},
@@ -1,4 +1,4 @@
-// Copyright (c) 2019-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -12,10 +12,12 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/sylabs/singularity/v4/e2e/internal/e2e"
 	"github.com/sylabs/singularity/v4/e2e/internal/testhelper"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
 	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/exec"
 	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
@@ -408,6 +410,52 @@ func (c ctx) singularityInspect(t *testing.T) {
 	)
 }
 
+func (c ctx) singularityInspectOCISIF(t *testing.T) {
+	e2e.EnsureOCISIF(t, c.env)
+
+	compareJSON := func(t *testing.T, r *e2e.SingularityCmdResult) {
+		info := new(ocisif.ImageInfo)
+		if err := json.Unmarshal(r.Stdout, info); err != nil {
+			t.Fatalf("unable to parse json output: %s", err)
+		}
+		if !strings.HasPrefix(info.Digest, "sha256:") {
+			t.Errorf("unexpected image digest %q", info.Digest)
+		}
+		if len(info.Layers) == 0 {
+			t.Errorf("no layers reported")
+		}
+		if len(info.Layers) != len(info.RootFS.DiffIDs) {
+			t.Errorf("reported %d layers, but %d diff IDs", len(info.Layers), len(info.RootFS.DiffIDs))
+		}
+		if len(info.Env) == 0 {
+			t.Errorf("no environment reported")
+		}
+	}
+
+	for _, args := range [][]string{{"--json"}, {"--all"}} {
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest("OCISIF/"+strings.TrimPrefix(args[0], "--")),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("inspect"),
+			e2e.WithArgs(append(args, c.env.OCISIFPath)...),
+			e2e.ExpectExit(0, compareJSON),
+		)
+	}
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("OCISIF/text"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("inspect"),
+		e2e.WithArgs(c.env.OCISIFPath),
+		e2e.ExpectExit(0,
+			e2e.ExpectOutput(e2e.RegexMatch, `(?m)^Digest: sha256:[0-9a-f]{64}$`),
+			e2e.ExpectOutput(e2e.ContainMatch, "Layers:"),
+		),
+	)
+}
+
 // E2ETests is the main func to trigger the test suite
 func E2ETests(env e2e.TestEnv) testhelper.Tests {
 	c := ctx{
@@ -416,5 +464,6 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 
 	return testhelper.Tests{
 		"inspect command": c.singularityInspect,
+		"inspect OCI-SIF": c.singularityInspectOCISIF,
 	}
 }
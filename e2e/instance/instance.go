@@ -380,6 +380,57 @@ func (c *ctx) testGhostInstance(t *testing.T) {
 	)
 }
 
+// Test instance labels, label filters for list and stop, and generated
+// instance names.
+func (c *ctx) testLabels(t *testing.T) {
+	prefix := "labels-" + randomName(t)[:8]
+	name := prefix + "-1"
+
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("instance start"),
+		e2e.WithArgs("--name-prefix", prefix, "--label", "app=web", "--label", "tier=front", c.env.ImagePath),
+		e2e.ExpectExit(
+			0,
+			e2e.ExpectOutput(e2e.ExactMatch, name),
+		),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("instance list"),
+		e2e.WithArgs("--filter", "label=app=web", "--filter", "label=tier", name),
+		e2e.ExpectExit(
+			0,
+			e2e.ExpectOutput(e2e.ContainMatch, name),
+		),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("instance list"),
+		e2e.WithArgs("--filter", "label=app=db", name),
+		e2e.ExpectExit(
+			0,
+			e2e.ExpectOutput(e2e.UnwantedContainMatch, name),
+		),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("instance list"),
+		e2e.WithArgs("--filter", "app=web"),
+		e2e.ExpectExit(255),
+	)
+
+	c.stopInstance(t, "", "--filter", "label=app=web")
+	c.expectInstance(t, name, 0)
+}
+
 // E2ETests is the main func to trigger the test suite
 func E2ETests(env e2e.TestEnv) testhelper.Tests {
 	c := &ctx{
@@ -409,6 +460,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 				{"CreateManyInstances", c.testCreateManyInstances},
 				{"StopAll", c.testStopAll},
 				{"GhostInstance", c.testGhostInstance},
+				{"Labels", c.testLabels},
 			}
 
 			profiles := []e2e.Profile{
@@ -0,0 +1,103 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package minimize
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/e2e/internal/e2e"
+	"github.com/sylabs/singularity/v4/e2e/internal/testhelper"
+)
+
+type ctx struct {
+	env e2e.TestEnv
+}
+
+// testMinimize records an access profile of a workload run from SIF and
+// OCI-SIF images, minimizes the images with it, and checks the workload runs
+// from the minimized images.
+func (c ctx) testMinimize(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+	e2e.EnsureOCISIF(t, c.env)
+
+	tmpDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "minimize-", "")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup(t)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		image      string
+		runProfile e2e.Profile
+	}{
+		{name: "SIF", image: c.env.ImagePath, runProfile: e2e.UserProfile},
+		{name: "OCISIF", image: c.env.OCISIFPath, runProfile: e2e.OCIUserProfile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := filepath.Join(tmpDir, tt.name+".json")
+			minimized := filepath.Join(tmpDir, tt.name+".min.sif")
+
+			c.env.RunSingularity(
+				t,
+				e2e.AsSubtest("Profile"),
+				e2e.WithProfile(e2e.OCIRootProfile),
+				e2e.WithCommand("exec"),
+				e2e.WithArgs("--profile-io", profile, tt.image, "/bin/sh", "-c", "echo minimized"),
+				e2e.ExpectExit(0),
+			)
+			c.env.RunSingularity(
+				t,
+				e2e.AsSubtest("Minimize"),
+				e2e.WithProfile(e2e.UserProfile),
+				e2e.WithCommand("minimize"),
+				e2e.WithArgs("--profile", profile, tt.image, minimized),
+				e2e.ExpectExit(0,
+					e2e.ExpectError(e2e.RegexMatch, `removed [1-9][0-9]* files`),
+				),
+			)
+			if t.Failed() {
+				return
+			}
+			c.env.RunSingularity(
+				t,
+				e2e.AsSubtest("Run"),
+				e2e.WithProfile(tt.runProfile),
+				e2e.WithCommand("exec"),
+				e2e.WithArgs(minimized, "/bin/sh", "-c", "echo minimized"),
+				e2e.ExpectExit(0,
+					e2e.ExpectOutput(e2e.ExactMatch, "minimized"),
+				),
+			)
+		})
+	}
+
+	// A profile recorded from the SIF image does not match the OCI-SIF image.
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("ProfileMismatch"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("minimize"),
+		e2e.WithArgs("--profile", filepath.Join(tmpDir, "SIF.json"), c.env.OCISIFPath, filepath.Join(tmpDir, "mismatch.sif")),
+		e2e.ExpectExit(0,
+			e2e.ExpectError(e2e.ContainMatch, "was recorded from"),
+		),
+	)
+}
+
+// E2ETests is the main func to trigger the test suite
+func E2ETests(env e2e.TestEnv) testhelper.Tests {
+	c := ctx{
+		env: env,
+	}
+
+	return testhelper.Tests{
+		"minimize": c.testMinimize,
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/e2e/internal/e2e"
+	"github.com/sylabs/singularity/v4/e2e/internal/testhelper"
+	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
+)
+
+type ctx struct {
+	env e2e.TestEnv
+}
+
+func (c ctx) testMount(t *testing.T) {
+	require.Command(t, "squashfuse")
+	require.Command(t, "fusermount")
+	e2e.EnsureImage(t, c.env)
+	e2e.EnsureOCISIF(t, c.env)
+
+	tmpDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "mount-", "")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup(t)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		image string
+	}{
+		{"SIF", c.env.ImagePath},
+		{"OCISIF", c.env.OCISIFPath},
+	}
+
+	for _, tt := range tests {
+		mnt := filepath.Join(tmpDir, tt.name)
+		if err := os.Mkdir(mnt, 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name+"/mount"),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("mount"),
+			e2e.WithArgs(tt.image, mnt),
+			e2e.PostRun(func(t *testing.T) {
+				if _, err := os.Lstat(filepath.Join(mnt, "bin", "sh")); err != nil {
+					t.Errorf("image root filesystem not mounted: %v", err)
+				}
+			}),
+			e2e.ExpectExit(0),
+		)
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name+"/list"),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("mount"),
+			e2e.WithArgs("--json"),
+			e2e.ExpectExit(0, e2e.ExpectOutput(e2e.ContainMatch, `"mountPoint": "`+mnt+`"`)),
+		)
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name+"/umount"),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("umount"),
+			e2e.WithArgs(mnt),
+			e2e.PostRun(func(t *testing.T) {
+				if _, err := os.Lstat(filepath.Join(mnt, "bin", "sh")); err == nil {
+					t.Errorf("image root filesystem still mounted")
+				}
+			}),
+			e2e.ExpectExit(0),
+		)
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name+"/umountAgain"),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("umount"),
+			e2e.WithArgs(mnt),
+			e2e.ExpectExit(255, e2e.ExpectError(e2e.ContainMatch, "no image mounted")),
+		)
+	}
+}
+
+// E2ETests is the main func to trigger the test suite
+func E2ETests(env e2e.TestEnv) testhelper.Tests {
+	c := ctx{
+		env: env,
+	}
+
+	return testhelper.Tests{
+		"mount": c.testMount,
+	}
+}
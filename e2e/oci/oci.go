@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sylabs/singularity/v4/e2e/internal/e2e"
 	"github.com/sylabs/singularity/v4/e2e/internal/testhelper"
+	ocitest "github.com/sylabs/singularity/v4/internal/pkg/test/tool/oci"
 	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
 )
 
@@ -435,6 +436,19 @@ func (c ctx) testOciHelp(t *testing.T) {
 	}
 }
 
+// testOciConformance validates the bundle created by 'oci mount' against the
+// OCI runtime specification.
+func (c ctx) testOciConformance(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+
+	bundleDir, umountFn := genericOciMount(t, &c)
+	defer umountFn()
+
+	e2e.Privileged(func(t *testing.T) {
+		ocitest.ValidateBundle(t, bundleDir)
+	})(t)
+}
+
 // E2ETests is the main func to trigger the test suite
 func E2ETests(env e2e.TestEnv) testhelper.Tests {
 	c := ctx{
@@ -448,6 +462,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 				t.Run("attach", c.testOciAttach)
 				t.Run("run", c.testOciRun)
 			})),
-		"help": c.testOciHelp,
+		"help":        c.testOciHelp,
+		"conformance": c.testOciConformance,
 	}
 }
@@ -188,6 +188,113 @@ func (c ctx) testOverlayCreate(t *testing.T) {
 	}
 }
 
+func (c ctx) testOverlayDiff(t *testing.T) {
+	require.Filesystem(t, "overlay")
+	require.MkfsExt3(t)
+	require.Command(t, "fuse2fs")
+	require.Command(t, "squashfuse")
+	require.Command(t, "fusermount")
+	e2e.EnsureImage(t, c.env)
+	busyboxSIF := e2e.BusyboxSIF(t)
+
+	tmpDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "overlay-diff", "")
+	defer cleanup(t)
+
+	sifImage := filepath.Join(tmpDir, "image.sif")
+	ext3Image := filepath.Join(tmpDir, "image.ext3")
+	tarFile := filepath.Join(tmpDir, "changes.tar")
+
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("build"),
+		e2e.WithArgs(sifImage, busyboxSIF),
+		e2e.ExpectExit(0),
+	)
+
+	for _, img := range []string{sifImage, ext3Image} {
+		c.env.RunSingularity(
+			t,
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("overlay"),
+			e2e.WithArgs("create", "--size", "64", img),
+			e2e.ExpectExit(0),
+		)
+	}
+
+	script := "echo changed > /etc/hosts && echo added > /added && rm /etc/passwd"
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(e2e.FakerootProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--writable", sifImage, "/bin/sh", "-c", script),
+		e2e.ExpectExit(0),
+	)
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(e2e.FakerootProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--overlay", ext3Image, busyboxSIF, "/bin/sh", "-c", script),
+		e2e.ExpectExit(0),
+	)
+
+	tests := []struct {
+		name string
+		args []string
+		exit int
+	}{
+		{
+			name: "SIF embedded overlay",
+			args: []string{"diff", sifImage},
+		},
+		{
+			name: "EXT3 overlay image",
+			args: []string{"diff", "--base", busyboxSIF, ext3Image},
+		},
+		{
+			name: "EXT3 overlay image without base",
+			args: []string{"diff", ext3Image},
+			exit: 255,
+		},
+		{
+			name: "unsupported export format",
+			args: []string{"diff", "--export", "zip", sifImage},
+			exit: 255,
+		},
+	}
+
+	for _, tt := range tests {
+		var expect []e2e.SingularityCmdResultOp
+		if tt.exit == 0 {
+			expect = []e2e.SingularityCmdResultOp{
+				e2e.ExpectOutput(e2e.ContainMatch, "M /etc/hosts"),
+				e2e.ExpectOutput(e2e.ContainMatch, "A /added"),
+				e2e.ExpectOutput(e2e.ContainMatch, "D /etc/passwd"),
+			}
+		}
+		c.env.RunSingularity(
+			t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("overlay"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.exit, expect...),
+		)
+	}
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("export tar"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("overlay"),
+		e2e.WithArgs("diff", "--export", "tar", "-o", tarFile, sifImage),
+		e2e.ExpectExit(0),
+	)
+	if fi, err := os.Stat(tarFile); err != nil || fi.Size() == 0 {
+		t.Errorf("expected non-empty tar archive %s: %v", tarFile, err)
+	}
+}
+
 // E2ETests is the main func to trigger the test suite
 func E2ETests(env e2e.TestEnv) testhelper.Tests {
 	c := ctx{
@@ -196,5 +303,6 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 
 	return testhelper.Tests{
 		"create": c.testOverlayCreate,
+		"diff":   c.testOverlayDiff,
 	}
 }
@@ -0,0 +1,145 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/e2e/internal/e2e"
+	"github.com/sylabs/singularity/v4/e2e/internal/testhelper"
+)
+
+type ctx struct {
+	env e2e.TestEnv
+}
+
+// testScanner implements the exec scanner interface, reporting a fixed set of
+// vulnerabilities for any root filesystem holding /bin/sh.
+const testScanner = `#!/bin/sh
+case "$1" in
+sbom)
+	test -e "$2/bin/sh" || exit 1
+	echo '{"bomFormat": "CycloneDX"}' > "$3"
+	;;
+scan)
+	test -s "$2" || exit 1
+	echo '[{"id": "CVE-2023-0001", "package": "busybox", "version": "1.0", "severity": "high"},
+	{"id": "CVE-2023-0002", "package": "busybox", "version": "1.0", "fixedVersion": "1.1", "severity": "low"}]'
+	;;
+*)
+	exit 1
+	;;
+esac
+`
+
+func (c ctx) testScan(t *testing.T) {
+	e2e.EnsureImage(t, c.env)
+	e2e.EnsureOCISIF(t, c.env)
+
+	tmpDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "scan-", "")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup(t)
+		}
+	})
+
+	scanner := filepath.Join(tmpDir, "scanner")
+	if err := os.WriteFile(scanner, []byte(testScanner), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sbom := filepath.Join(tmpDir, "sbom.json")
+
+	tests := []struct {
+		name     string
+		args     []string
+		exit     int
+		expectOp e2e.SingularityCmdResultOp
+		postRun  func(t *testing.T)
+	}{
+		{
+			name:     "SIF",
+			args:     []string{"--scanner", scanner, c.env.ImagePath},
+			exit:     0,
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `CVE-2023-0001\s+high\s+busybox`),
+		},
+		{
+			name:     "OCISIF",
+			args:     []string{"--scanner", scanner, c.env.OCISIFPath},
+			exit:     0,
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `CVE-2023-0002\s+low\s+busybox\s+1.0\s+1.1`),
+		},
+		{
+			name:     "JSON",
+			args:     []string{"--scanner", scanner, "--json", c.env.ImagePath},
+			exit:     0,
+			expectOp: e2e.ExpectOutput(e2e.ContainMatch, `"severity": "high"`),
+		},
+		{
+			name: "SBOM",
+			args: []string{"--scanner", scanner, "--sbom", sbom, c.env.ImagePath},
+			exit: 0,
+			postRun: func(t *testing.T) {
+				if _, err := os.Stat(sbom); err != nil {
+					t.Errorf("SBOM not written: %v", err)
+				}
+			},
+		},
+		{
+			name:     "FailOnHigh",
+			args:     []string{"--scanner", scanner, "--fail-on", "high", c.env.ImagePath},
+			exit:     255,
+			expectOp: e2e.ExpectError(e2e.ContainMatch, "Found 1 vulnerabilities with severity high or higher"),
+		},
+		{
+			name: "FailOnCritical",
+			args: []string{"--scanner", scanner, "--fail-on", "critical", c.env.ImagePath},
+			exit: 0,
+		},
+		{
+			name:     "InvalidFailOn",
+			args:     []string{"--scanner", scanner, "--fail-on", "severe", c.env.ImagePath},
+			exit:     255,
+			expectOp: e2e.ExpectError(e2e.ContainMatch, `invalid severity "severe"`),
+		},
+		{
+			name:     "UnknownScanner",
+			args:     []string{"--scanner", "clair", c.env.ImagePath},
+			exit:     255,
+			expectOp: e2e.ExpectError(e2e.ContainMatch, `unknown scanner "clair"`),
+		},
+	}
+
+	for _, tt := range tests {
+		var resultOps []e2e.SingularityCmdResultOp
+		if tt.expectOp != nil {
+			resultOps = append(resultOps, tt.expectOp)
+		}
+		cmdOps := []e2e.SingularityCmdOp{
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand("scan"),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.exit, resultOps...),
+		}
+		if tt.postRun != nil {
+			cmdOps = append(cmdOps, e2e.PostRun(tt.postRun))
+		}
+		c.env.RunSingularity(t, cmdOps...)
+	}
+}
+
+// E2ETests is the main func to trigger the test suite
+func E2ETests(env e2e.TestEnv) testhelper.Tests {
+	c := ctx{
+		env: env,
+	}
+
+	return testhelper.Tests{
+		"scan": c.testScan,
+	}
+}
@@ -6,11 +6,17 @@
 package security
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	cseccomp "github.com/containers/common/pkg/seccomp"
 	"github.com/sylabs/singularity/v4/e2e/internal/e2e"
+	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
 	"github.com/sylabs/singularity/v4/pkg/util/capabilities"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
 )
 
 var (
@@ -166,3 +172,224 @@ func (c ctx) ociCapabilities(t *testing.T) {
 		}
 	}
 }
+
+func (c ctx) ociSeccomp(t *testing.T) {
+	e2e.EnsureOCISIF(t, c.env)
+	imageRef := "oci-sif:" + c.env.OCISIFPath
+
+	tests := []struct {
+		name       string
+		argv       []string
+		expectExit int
+	}{
+		{
+			name:       "BlackList",
+			argv:       []string{"mkdir", "/tmp/foo"},
+			expectExit: 159, // process should be killed with SIGSYS (128+31)
+		},
+		{
+			name:       "True",
+			argv:       []string{"true"},
+			expectExit: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, p := range []e2e.Profile{e2e.OCIUserProfile, e2e.OCIRootProfile, e2e.OCIFakerootProfile} {
+			args := append([]string{"--seccomp-profile", "./security/testdata/seccomp-profile.json", imageRef}, tt.argv...)
+			c.env.RunSingularity(
+				t,
+				e2e.AsSubtest(tt.name+"/"+p.String()),
+				e2e.WithProfile(p),
+				e2e.WithCommand("exec"),
+				e2e.WithArgs(args...),
+				e2e.PreRun(require.Seccomp),
+				e2e.ExpectExit(tt.expectExit),
+			)
+		}
+	}
+}
+
+// ociSeccompDefault checks that the default seccomp profile is applied in OCI
+// mode, and can be overridden with --security seccomp:<profile>.
+func (c ctx) ociSeccompDefault(t *testing.T) {
+	e2e.EnsureOCISIF(t, c.env)
+	imageRef := "oci-sif:" + c.env.OCISIFPath
+
+	tests := []struct {
+		name       string
+		opts       []string
+		argv       []string
+		expectExit int
+		expectOp   e2e.SingularityCmdResultOp
+	}{
+		{
+			name:     "Default",
+			argv:     []string{"grep", "Seccomp:", "/proc/self/status"},
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `Seccomp:\s+2`),
+		},
+		{
+			name:     "Unconfined",
+			opts:     []string{"--security", "seccomp:unconfined"},
+			argv:     []string{"grep", "Seccomp:", "/proc/self/status"},
+			expectOp: e2e.ExpectOutput(e2e.RegexMatch, `Seccomp:\s+0`),
+		},
+		{
+			name:       "Profile",
+			opts:       []string{"--security", "seccomp:./security/testdata/seccomp-profile.json"},
+			argv:       []string{"mkdir", "/tmp/foo"},
+			expectExit: 159, // process should be killed with SIGSYS (128+31)
+		},
+		{
+			name:       "ProfileAndSecurity",
+			opts:       []string{"--seccomp-profile", "./security/testdata/seccomp-profile.json", "--security", "seccomp:unconfined"},
+			argv:       []string{"true"},
+			expectExit: 255,
+			expectOp:   e2e.ExpectError(e2e.ContainMatch, "--seccomp-profile cannot be used with --security seccomp:<profile>"),
+		},
+	}
+
+	for _, tt := range tests {
+		for _, p := range []e2e.Profile{e2e.OCIUserProfile, e2e.OCIRootProfile, e2e.OCIFakerootProfile} {
+			args := append(append(tt.opts, imageRef), tt.argv...)
+			exitOps := []e2e.SingularityCmdResultOp{}
+			if tt.expectOp != nil {
+				exitOps = append(exitOps, tt.expectOp)
+			}
+			c.env.RunSingularity(
+				t,
+				e2e.AsSubtest(tt.name+"/"+p.String()),
+				e2e.WithProfile(p),
+				e2e.WithCommand("exec"),
+				e2e.WithArgs(args...),
+				e2e.PreRun(require.Seccomp),
+				e2e.ExpectExit(tt.expectExit, exitOps...),
+			)
+		}
+	}
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("ConfigSeccomp"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("config seccomp"),
+		e2e.ExpectExit(0,
+			e2e.ExpectOutput(e2e.ContainMatch, `"defaultAction": "SCMP_ACT_ERRNO"`),
+		),
+	)
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("ConfigSeccompUnconfined"),
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("config seccomp"),
+		e2e.WithArgs("unconfined"),
+		e2e.ExpectExit(0,
+			e2e.ExpectOutput(e2e.ExactMatch, ""),
+		),
+	)
+}
+
+func (c ctx) ociSeccompTrace(t *testing.T) {
+	e2e.EnsureOCISIF(t, c.env)
+	imageRef := "oci-sif:" + c.env.OCISIFPath
+
+	tmpDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "seccomp-trace-", "")
+	t.Cleanup(func() {
+		if !t.Failed() {
+			cleanup(t)
+		}
+	})
+	profile := filepath.Join(tmpDir, "profile.json")
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("Trace"),
+		e2e.WithProfile(e2e.OCIUserProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--seccomp-trace", profile, imageRef, "ls", "/"),
+		e2e.PreRun(require.Seccomp),
+		e2e.ExpectExit(0),
+	)
+	if t.Failed() {
+		return
+	}
+
+	b, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatalf("while reading generated profile: %v", err)
+	}
+	var s cseccomp.Seccomp
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("while decoding generated profile: %v", err)
+	}
+	if len(s.Syscalls) != 1 || !slice.ContainsAnyString(s.Syscalls[0].Names, []string{"getdents64", "getdents"}) {
+		t.Errorf("generated profile does not allow syscalls made by ls: %s", b)
+	}
+
+	// The generated profile must allow the traced command to run again.
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("Enforce"),
+		e2e.WithProfile(e2e.OCIUserProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--seccomp-profile", profile, imageRef, "ls", "/"),
+		e2e.ExpectExit(0),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("ProfileAndTrace"),
+		e2e.WithProfile(e2e.OCIUserProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--seccomp-profile", profile, "--seccomp-trace", profile, imageRef, "true"),
+		e2e.ExpectExit(255,
+			e2e.ExpectError(e2e.ContainMatch, "--seccomp-profile and --seccomp-trace cannot be used together"),
+		),
+	)
+}
+
+func (c ctx) ociApparmor(t *testing.T) {
+	e2e.EnsureOCISIF(t, c.env)
+	imageRef := "oci-sif:" + c.env.OCISIFPath
+
+	apparmorEnabled := func(t *testing.T) {
+		b, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+		if err != nil || len(b) == 0 || b[0] != 'Y' {
+			t.Skip("AppArmor is not enabled")
+		}
+	}
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("ApparmorUnconfined"),
+		e2e.WithProfile(e2e.OCIRootProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--apparmor-profile", "unconfined", imageRef, "cat", "/proc/self/attr/current"),
+		e2e.PreRun(apparmorEnabled),
+		e2e.ExpectExit(0,
+			e2e.ExpectOutput(e2e.ContainMatch, "unconfined"),
+		),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("ApparmorAndLabel"),
+		e2e.WithProfile(e2e.OCIUserProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--apparmor-profile", "unconfined", "--security-opt", "label:system_u:system_r:container_t:s0", imageRef, "true"),
+		e2e.ExpectExit(255,
+			e2e.ExpectError(e2e.ContainMatch, "an AppArmor profile and an SELinux label cannot be used together"),
+		),
+	)
+
+	c.env.RunSingularity(
+		t,
+		e2e.AsSubtest("UnsupportedSecurityOpt"),
+		e2e.WithProfile(e2e.OCIUserProfile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("--security-opt", "no-new-privileges:true", imageRef, "true"),
+		e2e.ExpectExit(255,
+			e2e.ExpectError(e2e.ContainMatch, "unsupported --security-opt"),
+		),
+	)
+}
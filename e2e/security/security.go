@@ -62,6 +62,36 @@ func (c ctx) testSecurityUnpriv(t *testing.T) {
 			preFn:      require.Seccomp,
 			expectExit: 0,
 		},
+		// landlock
+		{
+			name:       "Landlock_WriteTmp",
+			argv:       []string{"sh", "-c", "touch /tmp/.landlock-e2e && rm /tmp/.landlock-e2e"},
+			opts:       []string{"--security", "landlock"},
+			preFn:      require.Landlock,
+			expectExit: 0,
+		},
+		{
+			name:       "Landlock_WriteRootfsWritableTmpfs",
+			argv:       []string{"touch", "/etc/.landlock-e2e"},
+			opts:       []string{"--security", "landlock", "--writable-tmpfs"},
+			preFn:      require.Landlock,
+			expectExit: 0,
+		},
+		{
+			name:       "Landlock_ReadRootfs",
+			argv:       []string{"sh", "-c", "ls / >/dev/null && cat /etc/passwd >/dev/null"},
+			opts:       []string{"--security", "landlock"},
+			preFn:      require.Landlock,
+			expectExit: 0,
+		},
+		{
+			name:       "Landlock_NoNewPrivs",
+			argv:       []string{"grep", "^NoNewPrivs:", "/proc/self/status"},
+			opts:       []string{"--security", "landlock"},
+			preFn:      require.Landlock,
+			expectOp:   e2e.ExpectOutput(e2e.RegexMatch, `NoNewPrivs:\s+1`),
+			expectExit: 0,
+		},
 		// capabilities
 		{
 			name:       "capabilities_keep_true",
@@ -244,6 +274,10 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 		"singularitySecurityPriv":   c.testSecurityPriv,
 		"testSecurityConfOwnership": np(c.testSecurityConfOwnership),
 		// OCI-Mode
-		"ociCapabilities": c.ociCapabilities,
+		"ociCapabilities":   c.ociCapabilities,
+		"ociSeccomp":        c.ociSeccomp,
+		"ociSeccompDefault": c.ociSeccompDefault,
+		"ociSeccompTrace":   c.ociSeccompTrace,
+		"ociApparmor":       c.ociApparmor,
 	}
 }
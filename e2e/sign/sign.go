@@ -1,4 +1,4 @@
-// Copyright (c) 2019-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -20,13 +20,17 @@ type ctx struct {
 }
 
 func getImage(t *testing.T) string {
+	return copyImage(t, filepath.Join("..", "test", "images", "one-group.sif"))
+}
+
+func copyImage(t *testing.T, path string) string {
 	dst, err := os.CreateTemp("", "e2e-sign-keyring-*")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer dst.Close()
 
-	src, err := os.Open(filepath.Join("..", "test", "images", "one-group.sif"))
+	src, err := os.Open(path)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -155,6 +159,107 @@ func (c *ctx) sign(t *testing.T) {
 	}
 }
 
+// signOCISIF tests cosign compatible signing and verification of an OCI-SIF
+// image, and of the same image pushed to an OCI registry.
+func (c *ctx) signOCISIF(t *testing.T) {
+	e2e.EnsureOCISIF(t, c.TestEnv)
+	e2e.EnsureRegistryOCISIF(t, c.TestEnv)
+
+	privPath := filepath.Join("..", "test", "keys", "ecdsa-private.pem")
+	pubPath := filepath.Join("..", "test", "keys", "ecdsa-public.pem")
+	wrongPubPath := filepath.Join("..", "test", "keys", "rsa-public.pem")
+
+	imgPath := copyImage(t, c.OCISIFPath)
+	defer os.Remove(imgPath)
+
+	tests := []struct {
+		name       string
+		command    string
+		args       []string
+		expectCode int
+		expectOps  []e2e.SingularityCmdResultOp
+	}{
+		{
+			name:       "VerifyUnsigned",
+			command:    "verify",
+			args:       []string{"--key", pubPath, imgPath},
+			expectCode: 255,
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "image has no cosign signature"),
+			},
+		},
+		{
+			name:       "SignPGP",
+			command:    "sign",
+			args:       []string{imgPath},
+			expectCode: 255,
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "can only be signed with --key"),
+			},
+		},
+		{
+			name:    "Sign",
+			command: "sign",
+			args:    []string{"--key", privPath, imgPath},
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "Signature created and applied"),
+			},
+		},
+		{
+			name:    "Verify",
+			command: "verify",
+			args:    []string{"--key", pubPath, imgPath},
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "Verified signature(s) from image"),
+			},
+		},
+		{
+			name:       "VerifyWrongKey",
+			command:    "verify",
+			args:       []string{"--key", wrongPubPath, imgPath},
+			expectCode: 255,
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "no valid cosign signature found"),
+			},
+		},
+		{
+			name:    "SignRemote",
+			command: "sign",
+			args:    []string{"--key", privPath, c.TestRegistryOCISIF},
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "Signature created and pushed as a referrer"),
+			},
+		},
+		{
+			name:    "VerifyRemote",
+			command: "verify",
+			args:    []string{"--key", pubPath, c.TestRegistryOCISIF},
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "Verified signature(s) from image"),
+			},
+		},
+		{
+			name:       "VerifyRemoteWrongKey",
+			command:    "verify",
+			args:       []string{"--key", wrongPubPath, c.TestRegistryOCISIF},
+			expectCode: 255,
+			expectOps: []e2e.SingularityCmdResultOp{
+				e2e.ExpectError(e2e.ContainMatch, "no valid cosign signature found"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c.RunSingularity(t,
+			e2e.AsSubtest(tt.name),
+			e2e.WithProfile(e2e.UserProfile),
+			e2e.WithCommand(tt.command),
+			e2e.WithArgs(tt.args...),
+			e2e.ExpectExit(tt.expectCode, tt.expectOps...),
+		)
+	}
+}
+
 func (c *ctx) importPGPKeypairs(t *testing.T) {
 	c.RunSingularity(
 		t,
@@ -190,6 +295,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 			c.importPGPKeypairs(t)
 
 			t.Run("Sign", c.sign)
+			t.Run("SignOCISIF", c.signOCISIF)
 		},
 	}
 }
@@ -30,6 +30,7 @@ import (
 	"github.com/sylabs/singularity/v4/e2e/cmdenvvars"
 	"github.com/sylabs/singularity/v4/e2e/config"
 	"github.com/sylabs/singularity/v4/e2e/delete"
+	"github.com/sylabs/singularity/v4/e2e/diff"
 	"github.com/sylabs/singularity/v4/e2e/docker"
 	"github.com/sylabs/singularity/v4/e2e/ecl"
 	singularityenv "github.com/sylabs/singularity/v4/e2e/env"
@@ -39,6 +40,8 @@ import (
 	"github.com/sylabs/singularity/v4/e2e/instance"
 	"github.com/sylabs/singularity/v4/e2e/key"
 	"github.com/sylabs/singularity/v4/e2e/keyserver"
+	"github.com/sylabs/singularity/v4/e2e/minimize"
+	"github.com/sylabs/singularity/v4/e2e/mount"
 	"github.com/sylabs/singularity/v4/e2e/oci"
 	"github.com/sylabs/singularity/v4/e2e/overlay"
 	"github.com/sylabs/singularity/v4/e2e/plugin"
@@ -48,6 +51,7 @@ import (
 	"github.com/sylabs/singularity/v4/e2e/remote"
 	"github.com/sylabs/singularity/v4/e2e/run"
 	"github.com/sylabs/singularity/v4/e2e/runhelp"
+	"github.com/sylabs/singularity/v4/e2e/scan"
 	"github.com/sylabs/singularity/v4/e2e/security"
 	"github.com/sylabs/singularity/v4/e2e/sign"
 	"github.com/sylabs/singularity/v4/e2e/verify"
@@ -74,6 +78,7 @@ var e2eGroups = map[string]testhelper.Group{
 	"CMDENVVARS":     cmdenvvars.E2ETests,
 	"CONFIG":         config.E2ETests,
 	"DELETE":         delete.E2ETests,
+	"DIFF":           diff.E2ETests,
 	"DOCKER":         docker.E2ETests,
 	"E2EBUILDCFG":    e2ebuildcfg.E2ETests,
 	"ECL":            ecl.E2ETests,
@@ -83,6 +88,8 @@ var e2eGroups = map[string]testhelper.Group{
 	"INSTANCE":       instance.E2ETests,
 	"KEY":            key.E2ETests,
 	"KEYSERVER":      keyserver.E2ETests,
+	"MINIMIZE":       minimize.E2ETests,
+	"MOUNT":          mount.E2ETests,
 	"OCI":            oci.E2ETests,
 	"OVERLAY":        overlay.E2ETests,
 	"PLUGIN":         plugin.E2ETests,
@@ -92,6 +99,7 @@ var e2eGroups = map[string]testhelper.Group{
 	"REMOTE":         remote.E2ETests,
 	"RUN":            run.E2ETests,
 	"RUNHELP":        runhelp.E2ETests,
+	"SCAN":           scan.E2ETests,
 	"SECURITY":       security.E2ETests,
 	"SIGN":           sign.E2ETests,
 	"SINGULARITYENV": singularityenv.E2ETests,
@@ -110,6 +110,21 @@ func (c ctx) testHelpOption(t *testing.T) {
 	)
 }
 
+// Test the crypto backend report
+func (c ctx) testCryptoOption(t *testing.T) {
+	c.env.RunSingularity(
+		t,
+		e2e.WithProfile(e2e.UserProfile),
+		e2e.WithCommand("version"),
+		e2e.WithArgs("--crypto"),
+		e2e.ExpectExit(
+			0,
+			e2e.ExpectOutput(e2e.RegexMatch, "(?m)^crypto backend: .+$"),
+			e2e.ExpectOutput(e2e.RegexMatch, "(?m)^strict FIPS mode: (enabled|disabled)$"),
+		),
+	)
+}
+
 // E2ETests is the main func to trigger the test suite
 func E2ETests(env e2e.TestEnv) testhelper.Tests {
 	c := ctx{
@@ -117,6 +132,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 	}
 
 	return testhelper.Tests{
+		"crypto option":    c.testCryptoOption,
 		"equal version":    c.testEqualVersion,
 		"help option":      c.testHelpOption,
 		"semantic version": c.testSemanticVersion,
@@ -0,0 +1,78 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	pluginapi "github.com/sylabs/singularity/v4/pkg/plugin"
+	launchercallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/runtime/launcher"
+	singularity "github.com/sylabs/singularity/v4/pkg/runtime/engine/singularity/config"
+	"github.com/sylabs/singularity/v4/pkg/util/bind"
+)
+
+// Plugin is the only variable which a plugin MUST export.
+// This symbol is accessed by the plugin framework to initialize the plugin
+var Plugin = pluginapi.Plugin{
+	Manifest: pluginapi.Manifest{
+		Name:        "github.com/sylabs/singularity/policy-example-plugin",
+		Author:      "Sylabs Team",
+		Version:     "0.1.0",
+		Description: "Bind /scratch read-only and scrub AWS_* variables in all containers",
+	},
+	Callbacks: []pluginapi.Callback{
+		(launchercallback.NativeConfig)(nativePolicy),
+		(launchercallback.OCISpec)(ociPolicy),
+	},
+}
+
+const scratch = "/scratch"
+
+// scrub returns env without AWS_* variables.
+func scrub(env []string) []string {
+	kept := make([]string, 0, len(env))
+	for _, e := range env {
+		if !strings.HasPrefix(e, "AWS_") {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func nativePolicy(_ string, cfg *singularity.EngineConfig) error {
+	binds := append(cfg.GetBindPath(), bind.Path{
+		Source:      scratch,
+		Destination: scratch,
+		Options:     map[string]*bind.Option{"ro": {}},
+	})
+	cfg.SetBindPath(binds)
+
+	if cfg.OciConfig.Process != nil {
+		cfg.OciConfig.Process.Env = scrub(cfg.OciConfig.Process.Env)
+	}
+	senv := cfg.GetSingularityEnv()
+	for k := range senv {
+		if strings.HasPrefix(k, "AWS_") {
+			delete(senv, k)
+		}
+	}
+	return nil
+}
+
+func ociPolicy(_ string, spec *specs.Spec) error {
+	spec.Mounts = append(spec.Mounts, specs.Mount{
+		Source:      scratch,
+		Destination: scratch,
+		Type:        "none",
+		Options:     []string{"rbind", "nosuid", "nodev", "ro"},
+	})
+
+	if spec.Process != nil {
+		spec.Process.Env = scrub(spec.Process.Env)
+	}
+	return nil
+}
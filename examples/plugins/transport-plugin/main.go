@@ -0,0 +1,95 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	pluginapi "github.com/sylabs/singularity/v4/pkg/plugin"
+	imagecallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/image"
+)
+
+// Plugin is the only variable which a plugin MUST export.
+// This symbol is accessed by the plugin framework to initialize the plugin
+var Plugin = pluginapi.Plugin{
+	Manifest: pluginapi.Manifest{
+		Name:        "github.com/sylabs/singularity/transport-plugin",
+		Author:      "Sylabs Team",
+		Version:     "0.1.0",
+		Description: "Pull and push images with local:///path/to/image.sif URIs",
+	},
+	Callbacks: []pluginapi.Callback{
+		(imagecallback.Transport)(localTransport),
+	},
+}
+
+func localTransport() ([]string, imagecallback.ImageTransport) {
+	return []string{"local"}, transport{}
+}
+
+// transport copies images from and to local:// URIs, which hold the absolute
+// path of an image file on the host.
+type transport struct{}
+
+func path(ref string) (string, error) {
+	p := strings.TrimPrefix(ref, "local://")
+	if p == ref || !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("invalid local URI %s, expected local:///path/to/image", ref)
+	}
+	return p, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (transport) Pull(_ context.Context, ref, dst string, _ imagecallback.TransportOptions) error {
+	src, err := path(ref)
+	if err != nil {
+		return err
+	}
+	return copyFile(src, dst)
+}
+
+func (transport) Push(_ context.Context, src, ref string, _ imagecallback.TransportOptions) error {
+	dst, err := path(ref)
+	if err != nil {
+		return err
+	}
+	return copyFile(src, dst)
+}
+
+// Digest identifies the content of the image by its size and modification
+// time, so that pulled images are cached until the file changes.
+func (transport) Digest(_ context.Context, ref string, _ imagecallback.TransportOptions) (string, error) {
+	p, err := path(ref)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano()), nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/compose"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// ComposeUp starts an instance for each service of the project p, that is
+// not already running, after the instances of the services it depends on.
+func ComposeUp(ctx context.Context, p *compose.Project) error {
+	order, err := p.Order()
+	if err != nil {
+		return err
+	}
+
+	exe := filepath.Join(buildcfg.BINDIR, "singularity")
+	for _, service := range order {
+		name := p.InstanceName(service)
+		ii, err := listInstances("", name, nil)
+		if err != nil {
+			return fmt.Errorf("could not retrieve instance list: %w", err)
+		}
+		if len(ii) > 0 {
+			sylog.Infof("Service %s is already running as instance %s", service, name)
+			continue
+		}
+
+		sylog.Infof("Starting service %s as instance %s", service, name)
+		cmd := exec.CommandContext(ctx, exe, p.StartArgs(service)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("while starting service %s: %w", service, err)
+		}
+	}
+	return nil
+}
+
+// ComposeDown stops the running instances of the services of the project p,
+// before the instances of the services they depend on. Instances still
+// running timeout after they were signaled are killed.
+func ComposeDown(p *compose.Project, timeout time.Duration) error {
+	order, err := p.Order()
+	if err != nil {
+		return err
+	}
+
+	filters := []instance.Filter{{Key: compose.ProjectLabel, Value: p.Name, HasValue: true}}
+	for i := len(order) - 1; i >= 0; i-- {
+		service := order[i]
+		name := p.InstanceName(service)
+		ii, err := listInstances("", name, filters)
+		if err != nil {
+			return fmt.Errorf("could not retrieve instance list: %w", err)
+		}
+		if len(ii) == 0 {
+			sylog.Debugf("Service %s is not running", service)
+			continue
+		}
+
+		sylog.Infof("Stopping service %s (instance %s)", service, name)
+		if err := StopInstance(name, "", filters, syscall.SIGTERM, timeout); err != nil {
+			return fmt.Errorf("while stopping service %s: %w", service, err)
+		}
+	}
+	return nil
+}
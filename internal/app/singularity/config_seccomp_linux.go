@@ -0,0 +1,47 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/security/seccomp"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// SeccompConfig writes the seccomp profile that is applied to containers in
+// OCI mode to w. profile is the profile that would be requested with
+// --security seccomp:<profile>, or empty to show the profile set in
+// singularity.conf.
+func SeccompConfig(w io.Writer, profile string) error {
+	c := singularityconf.GetCurrentConfig()
+	if c == nil {
+		return fmt.Errorf("singularity configuration is not initialized")
+	}
+
+	sel, err := seccomp.Select(profile, c.OCISeccompProfile)
+	if err != nil {
+		return err
+	}
+	if sel.Path == "" {
+		sylog.Infof("No seccomp profile is applied (unconfined, from %s)", sel.Source)
+		return nil
+	}
+	if !seccomp.Enabled() {
+		sylog.Warningf("seccomp support was not enabled at compilation time, the profile will not be applied")
+	}
+
+	b, err := os.ReadFile(sel.Path)
+	if err != nil {
+		return fmt.Errorf("while reading seccomp profile: %w", err)
+	}
+	sylog.Infof("Seccomp profile %s (from %s):", sel.Path, sel.Source)
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/diff"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// DiffOptions holds the options for Diff.
+type DiffOptions struct {
+	// ConfigOnly skips the comparison of the root filesystems.
+	ConfigOnly bool
+	// TmpDir is the directory in which the root filesystems are extracted.
+	TmpDir string
+}
+
+// ImageDiff holds the differences between two images.
+type ImageDiff struct {
+	// Config holds the differences of the OCI image configs, if both images
+	// are OCI-SIF images.
+	Config []diff.ConfigChange `json:"config,omitempty"`
+	// LayersRemoved and LayersAdded hold the digests of the layers only in
+	// the first, or second, image, if both images are OCI-SIF images.
+	LayersRemoved []string `json:"layersRemoved,omitempty"`
+	LayersAdded   []string `json:"layersAdded,omitempty"`
+	// Files holds the differences of the root filesystems.
+	Files []diff.Change `json:"files"`
+}
+
+// Diff compares the SIF, OCI-SIF, squashfs or sandbox images at pathA and
+// pathB. The root filesystems of images other than sandboxes are extracted to
+// a temporary directory for comparison.
+func Diff(pathA, pathB string, opts DiffOptions) (*ImageDiff, error) {
+	typeA, err := imageType(pathA)
+	if err != nil {
+		return nil, err
+	}
+	typeB, err := imageType(pathB)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &ImageDiff{Files: []diff.Change{}}
+
+	if typeA == image.OCISIF && typeB == image.OCISIF {
+		if err := diffOCISIF(pathA, pathB, d); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ConfigOnly {
+		return d, nil
+	}
+
+	for _, p := range []string{pathA, pathB} {
+		if err := checkDiffLayers(p); err != nil {
+			return nil, err
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp(opts.TmpDir, "diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := fs.ForceRemoveAll(tmpDir); err != nil {
+			sylog.Warningf("Could not remove temporary directory %s: %v", tmpDir, err)
+		}
+	}()
+
+	rootfsA, err := diffRootfs(pathA, typeA, filepath.Join(tmpDir, "a"))
+	if err != nil {
+		return nil, err
+	}
+	rootfsB, err := diffRootfs(pathB, typeB, filepath.Join(tmpDir, "b"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Owners are only preserved when images are extracted by root, so they
+	// are compared between sandboxes, or as root.
+	compareOwners := os.Geteuid() == 0 || (typeA == image.SANDBOX && typeB == image.SANDBOX)
+	if !compareOwners {
+		sylog.Infof("File owners are not compared, as images are extracted without privileges")
+	}
+
+	sylog.Infof("Comparing root filesystems")
+	if d.Files, err = diff.Trees(rootfsA, rootfsB, compareOwners); err != nil {
+		return nil, fmt.Errorf("while comparing root filesystems: %w", err)
+	}
+	return d, nil
+}
+
+func imageType(path string) (int, error) {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return 0, err
+	}
+	defer img.File.Close()
+	return img.Type, nil
+}
+
+// diffOCISIF records the config and layer differences of the OCI-SIF images
+// at pathA and pathB in d.
+func diffOCISIF(pathA, pathB string, d *ImageDiff) error {
+	specA, err := ocisif.ImageSpec(pathA)
+	if err != nil {
+		return err
+	}
+	specB, err := ocisif.ImageSpec(pathB)
+	if err != nil {
+		return err
+	}
+	d.Config = diff.Configs(specA, specB)
+
+	mfA, err := ocisif.ImageManifest(pathA)
+	if err != nil {
+		return err
+	}
+	mfB, err := ocisif.ImageManifest(pathB)
+	if err != nil {
+		return err
+	}
+	inA := make(map[string]bool, len(mfA.Layers))
+	for _, l := range mfA.Layers {
+		inA[l.Digest.String()] = true
+	}
+	inB := make(map[string]bool, len(mfB.Layers))
+	for _, l := range mfB.Layers {
+		inB[l.Digest.String()] = true
+		if !inA[l.Digest.String()] {
+			d.LayersAdded = append(d.LayersAdded, l.Digest.String())
+		}
+	}
+	for _, l := range mfA.Layers {
+		if !inB[l.Digest.String()] {
+			d.LayersRemoved = append(d.LayersRemoved, l.Digest.String())
+		}
+	}
+	return nil
+}
+
+// checkDiffLayers returns an error if the image at path is an OCI-SIF image
+// holding more than one layer, whose files can't be compared, as layers are
+// extracted without applying their whiteouts.
+func checkDiffLayers(path string) error {
+	typ, err := imageType(path)
+	if err != nil || typ != image.OCISIF {
+		return err
+	}
+	mf, err := ocisif.ImageManifest(path)
+	if err != nil {
+		return err
+	}
+	if len(mf.Layers) != 1 {
+		return fmt.Errorf("only OCI-SIF images with a single layer can be compared at the file level, %s has %d layers (use --config-only)", path, len(mf.Layers))
+	}
+	return nil
+}
+
+// diffRootfs returns the root filesystem of the image at path, of type
+// imgType, extracting it to dest unless it is a sandbox.
+func diffRootfs(path string, imgType int, dest string) (string, error) {
+	if imgType == image.SANDBOX {
+		return path, nil
+	}
+	sylog.Infof("Extracting root filesystem of %s", path)
+	if err := extractRootfs(path, dest); err != nil {
+		return "", fmt.Errorf("while extracting root filesystem of %s: %w", path, err)
+	}
+	return dest, nil
+}
+
+// WriteImageDiff writes d to w, as text, or as JSON if asJSON is true.
+func WriteImageDiff(w io.Writer, d *ImageDiff, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	if len(d.Config) > 0 {
+		fmt.Fprintln(w, "Config:")
+		for _, c := range d.Config {
+			fmt.Fprintf(w, "  %s: %q -> %q\n", c.Field, c.Old, c.New)
+		}
+	}
+	if len(d.LayersRemoved) > 0 || len(d.LayersAdded) > 0 {
+		fmt.Fprintln(w, "Layers:")
+		for _, l := range d.LayersRemoved {
+			fmt.Fprintf(w, "  - %s\n", l)
+		}
+		for _, l := range d.LayersAdded {
+			fmt.Fprintf(w, "  + %s\n", l)
+		}
+	}
+	for _, c := range d.Files {
+		if len(c.Reasons) > 0 {
+			fmt.Fprintf(w, "%s %s (%s)\n", c.Kind, c.Path, strings.Join(c.Reasons, ", "))
+			continue
+		}
+		fmt.Fprintf(w, "%s %s\n", c.Kind, c.Path)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+)
+
+// ImageResult is the record of an image pulled, built, or pushed, printed by
+// the --json option of those commands.
+type ImageResult struct {
+	// Image is the path of the local image.
+	Image string `json:"image"`
+	// Ref is the URI the image was pulled from, or pushed to.
+	Ref string `json:"ref,omitempty"`
+	// Digest is the digest of the manifest of an OCI-SIF image, or of the
+	// file of a native SIF image. Sandboxes have no digest.
+	Digest string `json:"digest,omitempty"`
+	// Size is the size of the image, in bytes.
+	Size int64 `json:"size"`
+	// Layers is the number of layers of an OCI-SIF image, or of partitions
+	// of a native SIF image.
+	Layers int `json:"layers"`
+	// CacheHit is set if data was taken from the image cache.
+	CacheHit bool `json:"cacheHit"`
+	// Duration is the duration of the operation, in seconds.
+	Duration float64 `json:"duration"`
+}
+
+// NewImageResult returns the record of the image at path, obtained from ref
+// in d.
+func NewImageResult(path, ref string, cacheHit bool, d time.Duration) (ImageResult, error) {
+	r := ImageResult{
+		Image:    path,
+		Ref:      ref,
+		CacheHit: cacheHit,
+		Duration: d.Seconds(),
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return r, err
+	}
+	if fi.IsDir() {
+		r.Size, err = dirSize(path)
+		return r, err
+	}
+	r.Size = fi.Size()
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return r, fmt.Errorf("while loading SIF: %w", err)
+	}
+	_, err = f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex))
+	isOCISIF := err == nil
+	parts, _ := f.GetDescriptors(sif.WithDataType(sif.DataPartition))
+	f.UnloadContainer()
+
+	if isOCISIF {
+		info, err := ocisif.Inspect(path)
+		if err != nil {
+			return r, err
+		}
+		r.Digest = info.Digest
+		r.Layers = len(info.Layers)
+		return r, nil
+	}
+
+	r.Layers = len(parts)
+	r.Digest, err = fileDigest(path)
+	return r, err
+}
+
+// WriteImageResult writes r to w, as JSON.
+func WriteImageResult(w io.Writer, r ImageResult) error {
+	return writeListing(w, ListFormatJSON, r)
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	d, err := digest.SHA256.FromReader(f)
+	if err != nil {
+		return "", fmt.Errorf("while computing digest of %s: %w", path, err)
+	}
+	return d.String(), nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
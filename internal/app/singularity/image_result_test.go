@@ -0,0 +1,92 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewImageResult(t *testing.T) {
+	sandbox := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(sandbox, "etc"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(sandbox, "etc", "hosts"), []byte("127.0.0.1 localhost\n"), 0o644))
+
+	img, err := random.Image(64, 2)
+	assert.NilError(t, err)
+	imgDigest, err := img.Digest()
+	assert.NilError(t, err)
+	ociSIF := filepath.Join(t.TempDir(), "image.oci.sif")
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+	assert.NilError(t, ocisif.Write(ociSIF, ii))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantDigest string
+		wantLayers int
+		wantSize   int64
+	}{
+		{
+			name:       "SIF",
+			path:       "../../../test/images/one-group.sif",
+			wantDigest: "sha256:",
+			wantLayers: 2,
+		},
+		{
+			name:       "OCISIF",
+			path:       ociSIF,
+			wantDigest: imgDigest.String(),
+			wantLayers: 2,
+		},
+		{
+			name:     "Sandbox",
+			path:     sandbox,
+			wantSize: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewImageResult(tt.path, "docker://alpine", true, 1500*time.Millisecond)
+			assert.NilError(t, err)
+
+			assert.Equal(t, r.Image, tt.path)
+			assert.Equal(t, r.Ref, "docker://alpine")
+			assert.Equal(t, r.Layers, tt.wantLayers)
+			assert.Equal(t, r.CacheHit, true)
+			assert.Equal(t, r.Duration, 1.5)
+			assert.Assert(t, strings.HasPrefix(r.Digest, tt.wantDigest))
+			if tt.wantDigest == "" {
+				assert.Equal(t, r.Digest, "")
+			}
+			if tt.wantSize != 0 {
+				assert.Equal(t, r.Size, tt.wantSize)
+			} else {
+				fi, err := os.Stat(tt.path)
+				assert.NilError(t, err)
+				assert.Equal(t, r.Size, fi.Size())
+			}
+
+			var buf bytes.Buffer
+			assert.NilError(t, WriteImageResult(&buf, r))
+			var got ImageResult
+			assert.NilError(t, json.Unmarshal(buf.Bytes(), &got))
+			assert.DeepEqual(t, got, r)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/imagestore"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// ImagesList prints the images of the image store s, in format.
+func ImagesList(s *imagestore.Store, format ListFormat) error {
+	images, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	if format != ListFormatText {
+		return writeListing(os.Stdout, format, images)
+	}
+
+	if len(images) == 0 {
+		fmt.Println("(no images in the image store)")
+		return nil
+	}
+
+	var total int64
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", "NAME", "DIGEST", "TYPE", "SIZE", "ADDED", "PATH")
+	for _, img := range images {
+		// images stored by layer are run from their store:// URI
+		path := img.Path
+		if path == "" {
+			path = imagestore.Scheme + "://" + img.Ref
+		}
+		fmt.Fprintf(tw, "%s\t%.12s\t%s\t%s\t%s\t%s\n",
+			img.Ref,
+			img.Digest.Encoded(),
+			img.Type,
+			fs.FindSize(img.Size),
+			img.Added.Local().Format("2006-01-02 15:04:05"),
+			path)
+		total += img.Size
+	}
+	tw.Flush()
+
+	stored, err := s.DiskUsage()
+	if err != nil {
+		return err
+	}
+	saved := total - stored
+	if saved < 0 {
+		saved = 0
+	}
+	fmt.Printf("\n%d images using %s of space, %s saved by sharing image files and layers\n",
+		len(images), fs.FindSize(stored), fs.FindSize(saved))
+	return nil
+}
+
+// ImagesImport copies the image file at path into the image store s, named
+// ref.
+func ImagesImport(s *imagestore.Store, path, ref string) error {
+	sylog.Infof("Copying %s to the image store", path)
+	img, err := s.Import(path, ref)
+	if err != nil {
+		return err
+	}
+	sylog.Infof("Image %s stored, run it as %s://%s", img.Ref, imagestore.Scheme, img.Ref)
+	return nil
+}
+
+// ImagesTag names the image src of the image store s with ref.
+func ImagesTag(s *imagestore.Store, src, ref string) error {
+	img, err := s.Tag(src, ref)
+	if err != nil {
+		return err
+	}
+	sylog.Infof("Image %s tagged as %s", src, img.Ref)
+	return nil
+}
+
+// ImagesRemove removes the images named refs from the image store s.
+func ImagesRemove(s *imagestore.Store, refs []string) error {
+	var freed int64
+	for _, ref := range refs {
+		n, err := s.Remove(ref)
+		if err != nil {
+			return err
+		}
+		sylog.Infof("Removed %s", ref)
+		freed += n
+	}
+	sylog.Infof("Reclaimed %s of space", fs.FindSize(freed))
+	return nil
+}
+
+// ImagesPrune deletes the files of the image store s that no image name
+// points to.
+func ImagesPrune(s *imagestore.Store) error {
+	count, freed, err := s.Prune()
+	if err != nil {
+		return err
+	}
+	sylog.Infof("Removed %d unreferenced files, reclaiming %s of space", count, fs.FindSize(freed))
+	return nil
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2020, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // Copyright (c) Contributors to the Apptainer project, established as
 //   Apptainer a Series of LF Projects LLC.
 // This software is licensed under a 3-clause BSD license. Please consult the
@@ -27,6 +27,8 @@ import (
 	libcgroups "github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/sylabs/singularity/v4/internal/pkg/cgroups"
 	"github.com/sylabs/singularity/v4/internal/pkg/instance"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance/migrate"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/singularity/fusedriver"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
 )
@@ -38,13 +40,16 @@ type instanceInfo struct {
 	IP         string `json:"ip"`
 	LogErrPath string `json:"logErrPath"`
 	LogOutPath string `json:"logOutPath"`
+
+	Labels   map[string]string  `json:"labels,omitempty"`
+	OOMKills []instance.OOMKill `json:"oomKills,omitempty"`
 }
 
-// PrintInstanceList fetches instance list, applying name and
-// user filters, and prints it in a regular or a JSON format (if
+// PrintInstanceList fetches instance list, applying name, user
+// and label filters, and prints it in a regular or a JSON format (if
 // formatJSON is true) to the passed writer. Additionally, fetches
 // log paths (if showLogs is true).
-func PrintInstanceList(w io.Writer, name, user string, formatJSON bool, showLogs bool) error {
+func PrintInstanceList(w io.Writer, name, user string, filters []instance.Filter, formatJSON bool, showLogs bool) error {
 	if formatJSON && showLogs {
 		sylog.Fatalf("more than one flags have been set")
 	}
@@ -52,7 +57,7 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool, showLogs
 	tabWriter := tabwriter.NewWriter(w, 0, 8, 4, ' ', 0)
 	defer tabWriter.Flush()
 
-	ii, err := instance.List(user, name, instance.SingSubDir)
+	ii, err := listInstances(user, name, filters)
 	if err != nil {
 		return fmt.Errorf("could not retrieve instance list: %v", err)
 	}
@@ -95,6 +100,8 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool, showLogs
 		instances[i].IP = ii[i].IP
 		instances[i].LogErrPath = ii[i].LogErrPath
 		instances[i].LogOutPath = ii[i].LogOutPath
+		instances[i].Labels = ii[i].Labels
+		instances[i].OOMKills = ii[i].OOMKills
 	}
 
 	enc := json.NewEncoder(w)
@@ -134,10 +141,26 @@ func WriteInstancePidFile(name, pidFile string) error {
 	return nil
 }
 
+// listInstances returns the instances of user matching name, and all label
+// filters.
+func listInstances(user, name string, filters []instance.Filter) ([]*instance.File, error) {
+	ii, err := instance.List(user, name, instance.SingSubDir)
+	if err != nil || len(filters) == 0 {
+		return ii, err
+	}
+	matched := make([]*instance.File, 0, len(ii))
+	for _, i := range ii {
+		if i.Match(filters) {
+			matched = append(matched, i)
+		}
+	}
+	return matched, nil
+}
+
 // instanceListOrError is a private function to retrieve named instances or fail if there are no instances
 // We wrap the error from instance.List to provide a more specific error message
-func instanceListOrError(instanceUser, name string) ([]*instance.File, error) {
-	ii, err := instance.List(instanceUser, name, instance.SingSubDir)
+func instanceListOrError(instanceUser, name string, filters ...instance.Filter) ([]*instance.File, error) {
+	ii, err := listInstances(instanceUser, name, filters)
 	if err != nil {
 		return ii, fmt.Errorf("could not retrieve instance list: %w", err)
 	}
@@ -215,8 +238,14 @@ func InstanceStats(ctx context.Context, name, instanceUser string, formatJSON bo
 		noStream = true
 	}
 
-	// Cut out early if we do not have cgroups
+	// Cut out early if we do not have cgroups, after showing the status of
+	// FUSE drivers, which doesn't depend on them
 	if !i.Cgroup {
+		if len(i.FuseDrivers) > 0 {
+			if err := writeFuseDrivers(os.Stdout, i.FuseDrivers, formatJSON); err != nil {
+				return err
+			}
+		}
 		url := "the Singularity instance user guide for instructions"
 		return fmt.Errorf("stats are only available if cgroups are enabled, see %s", url)
 	}
@@ -260,11 +289,23 @@ func InstanceStats(ctx context.Context, name, instanceUser string, formatJSON bo
 				return fmt.Errorf("while getting stats for pid: %v", err)
 			}
 
+			// The instance file is updated when the state of FUSE drivers
+			// changes
+			fuseDrivers := i.FuseDrivers
+			if len(fuseDrivers) > 0 {
+				if ii, err := instanceListOrError(instanceUser, name); err == nil && len(ii) == 1 {
+					fuseDrivers = ii[0].FuseDrivers
+				}
+			}
+
 			// Do we want json?
 			if formatJSON {
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "\t")
-				err = enc.Encode(stats)
+				err = enc.Encode(struct {
+					*libcgroups.Stats
+					FuseDrivers []fusedriver.Status `json:"fuse_drivers,omitempty"`
+				}{stats, fuseDrivers})
 				return err
 			}
 
@@ -289,6 +330,13 @@ func InstanceStats(ctx context.Context, name, instanceUser string, formatJSON bo
 				return fmt.Errorf("could not write instance stats: %v", err)
 			}
 
+			if len(fuseDrivers) > 0 {
+				fmt.Println()
+				if err := writeFuseDrivers(os.Stdout, fuseDrivers, false); err != nil {
+					return err
+				}
+			}
+
 			// We don't want a stream, return after just one record
 			if noStream {
 				return nil
@@ -297,12 +345,45 @@ func InstanceStats(ctx context.Context, name, instanceUser string, formatJSON bo
 	}
 }
 
-// StopInstance fetches instance list, applying name and
-// user filters, and stops them by sending a signal sig. If an instance
+// writeFuseDrivers writes the status of the FUSE drivers of an instance, as a
+// table or in JSON format.
+func writeFuseDrivers(w io.Writer, drivers []fusedriver.Status, formatJSON bool) error {
+	if formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(struct {
+			FuseDrivers []fusedriver.Status `json:"fuse_drivers"`
+		}{drivers})
+	}
+
+	tabWriter := tabwriter.NewWriter(w, 0, 8, 4, ' ', 0)
+	_, err := fmt.Fprintln(tabWriter, "FUSE MOUNT\tPID\tSTATE\tHEALTHY\tRESTARTS\tLAST EXIT")
+	if err != nil {
+		return fmt.Errorf("could not write FUSE driver header: %v", err)
+	}
+	for _, d := range drivers {
+		pid := "-"
+		if d.PID > 0 {
+			pid = fmt.Sprint(d.PID)
+		}
+		lastExit := d.LastExit
+		if lastExit == "" {
+			lastExit = "-"
+		}
+		_, err := fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%t\t%d\t%s\n", d.MountPoint, pid, d.State, d.Healthy, d.Restarts, lastExit)
+		if err != nil {
+			return fmt.Errorf("could not write FUSE driver status: %v", err)
+		}
+	}
+	return tabWriter.Flush()
+}
+
+// StopInstance fetches instance list, applying name, user and label
+// filters, and stops them by sending a signal sig. If an instance
 // is still running after a grace period defined by timeout is expired,
 // it will be forcibly killed.
-func StopInstance(name, user string, sig syscall.Signal, timeout time.Duration) error {
-	ii, err := instanceListOrError(user, name)
+func StopInstance(name, user string, filters []instance.Filter, sig syscall.Signal, timeout time.Duration) error {
+	ii, err := instanceListOrError(user, name, filters...)
 	if err != nil {
 		return err
 	}
@@ -354,3 +435,59 @@ func killInstance(i *instance.File, sig syscall.Signal, stoppedPID chan<- int) {
 		time.Sleep(10 * time.Millisecond)
 	}
 }
+
+// MigrateInstance migrates the instance name of user to the node dest, in
+// [user@]host:[dir] form. The instance is checkpointed, sent and restored on
+// dest, then stopped. Its processes are resumed if the migration fails.
+func MigrateInstance(ctx context.Context, name, user, dest string) error {
+	d, err := migrate.ParseDestination(dest)
+	if err != nil {
+		return err
+	}
+	ii, err := instanceListOrError(user, name)
+	if err != nil {
+		return err
+	}
+	if len(ii) != 1 {
+		return fmt.Errorf("%s matches %d instances, instances are migrated one at a time", name, len(ii))
+	}
+	i := ii[0]
+
+	sender, err := migrate.NewSender()
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "singularity-migrate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	sylog.Infof("Checkpointing %s instance of %s (PID=%d)", i.Name, i.Image, i.Pid)
+	c, err := migrate.Dump(ctx, i, dir)
+	if err != nil {
+		return err
+	}
+	if err := sender.Send(ctx, c, d); err != nil {
+		sylog.Infof("Resuming %s instance of %s (PID=%d)", i.Name, i.Image, i.Pid)
+		c.Resume()
+		return fmt.Errorf("while migrating instance %s to %s: %w", i.Name, d.Host, err)
+	}
+	c.Kill()
+	sylog.Infof("Instance %s migrated to %s", i.Name, d.Host)
+	return nil
+}
+
+// RestoreInstance restores an instance migrated from another node, whose
+// checkpoint was received in dir. The restored instance is monitored by a
+// process running singularity instance restore --monitor.
+func RestoreInstance(dir string) error {
+	return migrate.Restore(dir, []string{"instance", "restore", "--monitor", dir})
+}
+
+// MonitorInstance restores an instance migrated from another node, whose
+// checkpoint was received in dir, reporting the outcome on the file descriptor
+// 3, and waits for the instance to exit.
+func MonitorInstance(ctx context.Context, dir string) error {
+	return migrate.Monitor(ctx, dir, os.NewFile(3, "ready"))
+}
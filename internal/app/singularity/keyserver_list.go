@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
@@ -18,8 +19,28 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
 )
 
-// KeyserverList prints information about remote configurations
-func KeyserverList(remoteName string, usrConfigFile string) (err error) {
+// KeyserverInfo describes a keyserver of a remote endpoint listed by
+// KeyserverList.
+type KeyserverInfo struct {
+	Order    int    `json:"order" yaml:"order"`
+	URI      string `json:"uri" yaml:"uri"`
+	Insecure bool   `json:"insecure" yaml:"insecure"`
+	LoggedIn bool   `json:"loggedIn" yaml:"loggedIn"`
+}
+
+// KeyserverEndpointInfo describes a remote endpoint, and its keyservers,
+// listed by KeyserverList.
+type KeyserverEndpointInfo struct {
+	Name       string          `json:"name" yaml:"name"`
+	System     bool            `json:"system" yaml:"system"`
+	Default    bool            `json:"default" yaml:"default"`
+	Keyservers []KeyserverInfo `json:"keyservers" yaml:"keyservers"`
+	// Error is set when the keyservers of the endpoint couldn't be fetched.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// KeyserverList prints information about remote configurations, in format
+func KeyserverList(remoteName string, usrConfigFile string, format ListFormat) (err error) {
 	c := &remote.Config{}
 
 	// opening config file
@@ -42,66 +63,42 @@ func KeyserverList(remoteName string, usrConfigFile string) (err error) {
 		return err
 	}
 
-	keyserverCredentials := make(map[string]*credential.Config)
-	for _, cred := range c.Credentials {
-		u, err := url.Parse(cred.URI)
-		if err != nil {
-			return err
-		}
-
-		switch u.Scheme {
-		case "http", "https":
-			keyserverCredentials[cred.URI] = cred
-		}
-	}
-
-	defaultRemote, err := c.GetDefault()
+	endpoints, err := keyserverEndpointInfos(c, remoteName)
 	if err != nil {
-		return fmt.Errorf("error getting default remote-endpoint: %w", err)
+		return err
 	}
-
-	remotes := c.Remotes
-	if remoteName != "" {
-		ep, ok := c.Remotes[remoteName]
-		if !ok {
-			return fmt.Errorf("no remote-endpoint with the name %q found", remoteName)
-		}
-		remotes = map[string]*endpoint.Config{remoteName: ep}
+	if format != ListFormatText {
+		return writeListing(os.Stdout, format, endpoints)
 	}
 
-	for epName, ep := range remotes {
+	for _, ep := range endpoints {
 		fmt.Println()
 		isSystem := ""
 		if ep.System {
 			isSystem = "*"
 		}
 		isDefault := ""
-		if ep == defaultRemote {
+		if ep.Default {
 			isDefault = "^"
 		}
-		fmt.Printf("%s %s%s\n", epName, isSystem, isDefault)
+		fmt.Printf("%s %s%s\n", ep.Name, isSystem, isDefault)
 
-		if err := ep.UpdateKeyserversConfig(); err != nil {
+		if ep.Error != "" {
 			fmt.Println("(unable to fetch associated keyserver info for this endpoint)")
 			continue
 		}
 
-		order := 1
 		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		for _, kc := range ep.Keyservers {
-			if kc.Skip {
-				continue
-			}
 			secure := "TLS"
 			if kc.Insecure {
 				secure = "no TLS"
 			}
 			loggedInStr := ""
-			if _, ok := keyserverCredentials[kc.URI]; ok {
+			if kc.LoggedIn {
 				loggedInStr = "+"
 			}
-			fmt.Fprintf(tw, " \t#%d\t%s\t%s\t%s\n", order, kc.URI, secure, loggedInStr)
-			order++
+			fmt.Fprintf(tw, " \t#%d\t%s\t%s\t%s\n", kc.Order, kc.URI, secure, loggedInStr)
 		}
 		tw.Flush()
 	}
@@ -115,3 +112,75 @@ func KeyserverList(remoteName string, usrConfigFile string) (err error) {
 
 	return nil
 }
+
+// keyserverEndpointInfos returns the keyservers of the remote endpoint
+// remoteName of c, or of all remote endpoints in alphanumeric order if
+// remoteName is empty.
+func keyserverEndpointInfos(c *remote.Config, remoteName string) ([]KeyserverEndpointInfo, error) {
+	keyserverCredentials := make(map[string]*credential.Config)
+	for _, cred := range c.Credentials {
+		u, err := url.Parse(cred.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		switch u.Scheme {
+		case "http", "https":
+			keyserverCredentials[cred.URI] = cred
+		}
+	}
+
+	defaultRemote, err := c.GetDefault()
+	if err != nil {
+		return nil, fmt.Errorf("error getting default remote-endpoint: %w", err)
+	}
+
+	remotes := c.Remotes
+	if remoteName != "" {
+		ep, ok := c.Remotes[remoteName]
+		if !ok {
+			return nil, fmt.Errorf("no remote-endpoint with the name %q found", remoteName)
+		}
+		remotes = map[string]*endpoint.Config{remoteName: ep}
+	}
+
+	names := make([]string, 0, len(remotes))
+	for n := range remotes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	endpoints := make([]KeyserverEndpointInfo, 0, len(names))
+	for _, epName := range names {
+		ep := remotes[epName]
+		info := KeyserverEndpointInfo{
+			Name:       epName,
+			System:     ep.System,
+			Default:    ep == defaultRemote,
+			Keyservers: make([]KeyserverInfo, 0),
+		}
+
+		if err := ep.UpdateKeyserversConfig(); err != nil {
+			info.Error = err.Error()
+			endpoints = append(endpoints, info)
+			continue
+		}
+
+		order := 1
+		for _, kc := range ep.Keyservers {
+			if kc.Skip {
+				continue
+			}
+			_, loggedIn := keyserverCredentials[kc.URI]
+			info.Keyservers = append(info.Keyservers, KeyserverInfo{
+				Order:    order,
+				URI:      kc.URI,
+				Insecure: kc.Insecure,
+				LoggedIn: loggedIn,
+			})
+			order++
+		}
+		endpoints = append(endpoints, info)
+	}
+	return endpoints, nil
+}
@@ -8,8 +8,6 @@ package singularity
 
 import (
 	"fmt"
-	"io"
-	"os"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
@@ -17,44 +15,20 @@ import (
 
 // KeyserverLogin logs in to a keyserver.
 func KeyserverLogin(usrConfigFile string, args *LoginArgs) (err error) {
-	// opening config file
-	file, err := os.OpenFile(usrConfigFile, os.O_RDWR|os.O_CREATE, 0o600)
+	err = remote.UpdateFile(usrConfigFile, 0o600, func(c *remote.Config) error {
+		if err := syncSysConfig(c); err != nil {
+			return err
+		}
+
+		if err := c.Login(args.Name, args.Username, args.Password, args.Insecure, ""); err != nil {
+			return fmt.Errorf("while login to %s: %s", args.Name, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("while opening remote config file: %s", err)
-	}
-	defer file.Close()
-
-	// read file contents to config struct
-	c, err := remote.ReadFrom(file)
-	if err != nil {
-		return fmt.Errorf("while parsing remote config data: %s", err)
-	}
-
-	if err := syncSysConfig(c); err != nil {
 		return err
 	}
 
-	if err := c.Login(args.Name, args.Username, args.Password, args.Insecure, ""); err != nil {
-		return fmt.Errorf("while login to %s: %s", args.Name, err)
-	}
-
-	// truncating file before writing new contents and syncing to commit file
-	if err := file.Truncate(0); err != nil {
-		return fmt.Errorf("while truncating remote config file: %s", err)
-	}
-
-	if n, err := file.Seek(0, io.SeekStart); err != nil || n != 0 {
-		return fmt.Errorf("failed to reset %s cursor: %s", file.Name(), err)
-	}
-
-	if _, err := c.WriteTo(file); err != nil {
-		return fmt.Errorf("while writing remote config to file: %s", err)
-	}
-
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to flush remote config file %s: %s", file.Name(), err)
-	}
-
-	sylog.Infof("Token stored in %s", file.Name())
+	sylog.Infof("Token stored in %s", usrConfigFile)
 	return nil
 }
@@ -0,0 +1,57 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ListFormat is the output format of the remote, registry and keyserver
+// listing commands.
+type ListFormat string
+
+const (
+	// ListFormatText lists entries as a table, for humans.
+	ListFormatText ListFormat = "text"
+	// ListFormatJSON lists entries as a JSON array, for scripts.
+	ListFormatJSON ListFormat = "json"
+	// ListFormatYAML lists entries as a YAML sequence, for scripts.
+	ListFormatYAML ListFormat = "yaml"
+)
+
+// ParseListFormat returns the ListFormat named s.
+func ParseListFormat(s string) (ListFormat, error) {
+	switch f := ListFormat(s); f {
+	case ListFormatText, ListFormatJSON, ListFormatYAML:
+		return f, nil
+	case "":
+		return ListFormatText, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, expected one of %s, %s, %s",
+		s, ListFormatText, ListFormatJSON, ListFormatYAML)
+}
+
+// writeListing writes v to w in the structured format f.
+func writeListing(w io.Writer, f ListFormat, v any) error {
+	switch f {
+	case ListFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case ListFormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("no structured output for format %q", f)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/remote"
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential"
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseListFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ListFormat
+		wantErr bool
+	}{
+		{in: "", want: ListFormatText},
+		{in: "text", want: ListFormatText},
+		{in: "json", want: ListFormatJSON},
+		{in: "yaml", want: ListFormatYAML},
+		{in: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseListFormat(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func testListConfig() *remote.Config {
+	return &remote.Config{
+		DefaultRemote: "cloud",
+		Remotes: map[string]*endpoint.Config{
+			"cloud": {
+				URI:    "cloud.example.com",
+				System: true,
+				Keyservers: []*endpoint.ServiceConfig{
+					{URI: "https://keys.example.com"},
+					{URI: "https://skipped.example.com", Skip: true},
+					{URI: "http://external.example.com", External: true, Insecure: true},
+				},
+			},
+			"local": {
+				URI:      "local.example.com",
+				Insecure: true,
+				Keyservers: []*endpoint.ServiceConfig{
+					{URI: "https://keys.local.example.com"},
+				},
+			},
+		},
+		Credentials: []*credential.Config{
+			{URI: "http://external.example.com"},
+			{URI: "docker://registry.example.com", Insecure: true},
+		},
+	}
+}
+
+func TestRemoteInfos(t *testing.T) {
+	got := remoteInfos(testListConfig())
+	want := []RemoteInfo{
+		{Name: "cloud", URI: "cloud.example.com", Default: true, Global: true},
+		{Name: "local", URI: "local.example.com", Insecure: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, f := range []ListFormat{ListFormatJSON, ListFormatYAML} {
+		var buf bytes.Buffer
+		if err := writeListing(&buf, f, got); err != nil {
+			t.Fatalf("%s: unexpected error: %v", f, err)
+		}
+		var decoded []RemoteInfo
+		var err error
+		if f == ListFormatJSON {
+			err = json.Unmarshal(buf.Bytes(), &decoded)
+		} else {
+			err = yaml.Unmarshal(buf.Bytes(), &decoded)
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", f, err)
+		}
+		if !reflect.DeepEqual(decoded, want) {
+			t.Errorf("%s: got %+v, want %+v", f, decoded, want)
+		}
+	}
+
+	if err := writeListing(&bytes.Buffer{}, ListFormatText, got); err == nil {
+		t.Errorf("unexpected success writing text listing")
+	}
+}
+
+func TestKeyserverEndpointInfos(t *testing.T) {
+	got, err := keyserverEndpointInfos(testListConfig(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []KeyserverEndpointInfo{
+		{
+			Name:    "cloud",
+			System:  true,
+			Default: true,
+			Keyservers: []KeyserverInfo{
+				{Order: 1, URI: "https://keys.example.com"},
+				{Order: 2, URI: "http://external.example.com", Insecure: true, LoggedIn: true},
+			},
+		},
+		{
+			Name: "local",
+			Keyservers: []KeyserverInfo{
+				{Order: 1, URI: "https://keys.local.example.com"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got, err = keyserverEndpointInfos(testListConfig(), "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("got %+v, want %+v", got, want[1:])
+	}
+
+	if _, err := keyserverEndpointInfos(testListConfig(), "unknown"); err == nil {
+		t.Errorf("unexpected success with unknown remote")
+	}
+}
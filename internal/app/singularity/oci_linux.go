@@ -11,10 +11,13 @@ package singularity
 import (
 	"context"
 	"fmt"
+	"os/signal"
+	"syscall"
 
 	lccgroups "github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher/oci"
+	"github.com/sylabs/singularity/v4/internal/pkg/snapshotter"
 	ocibundle "github.com/sylabs/singularity/v4/pkg/ocibundle/sif"
 	"github.com/sylabs/singularity/v4/pkg/util/namespaces"
 	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
@@ -32,6 +35,13 @@ type OciArgs struct {
 	KillTimeout  uint32
 	EmptyProcess bool
 	ForceKill    bool
+
+	EventsInterval uint32
+	EventsStats    bool
+	PsFormat       string
+
+	SnapshotterRoot    string
+	SnapshotterAddress string
 }
 
 // OciRun runs a container (equivalent to create/start/delete)
@@ -102,6 +112,16 @@ func OciResume(containerID string) error {
 	return oci.Resume(containerID, systemdCgroups)
 }
 
+// OciEvents streams container events
+func OciEvents(ctx context.Context, containerID string, args *OciArgs) error {
+	return oci.Events(ctx, containerID, args.EventsInterval, args.EventsStats)
+}
+
+// OciPs lists processes running in a container
+func OciPs(containerID string, psArgs []string, args *OciArgs) error {
+	return oci.Ps(containerID, args.PsFormat, psArgs)
+}
+
 // OciState queries container state
 func OciState(containerID string, _ *OciArgs) error {
 	systemdCgroups, err := systemdCgroups()
@@ -138,6 +158,20 @@ func OciUmount(ctx context.Context, bundle string) error {
 	return d.Delete(ctx)
 }
 
+// OciSnapshotter serves the OCI-SIF containerd snapshotter, until interrupted
+func OciSnapshotter(ctx context.Context, args *OciArgs) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sn, err := snapshotter.New(ctx, args.SnapshotterRoot, snapshotter.RegistryLayerSource())
+	if err != nil {
+		return fmt.Errorf("while opening snapshotter: %w", err)
+	}
+	defer sn.Close()
+
+	return snapshotter.Serve(ctx, sn, args.SnapshotterAddress)
+}
+
 func systemdCgroups() (use bool, err error) {
 	cfg := singularityconf.GetCurrentConfig()
 	if cfg == nil {
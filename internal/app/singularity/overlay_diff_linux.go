@@ -0,0 +1,180 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/overlay"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// OverlayDiffExportTar is the --export format writing overlay changes as a tar
+// archive.
+const OverlayDiffExportTar = "tar"
+
+// OverlayDiff writes to w the files added, modified and deleted by the
+// writable overlay at overlayPath, relative to the root filesystem of the base
+// image at basePath. The overlay may be a directory holding an upper dir, an
+// EXT3 overlay image, or a SIF image with an embedded overlay partition, in
+// which case the base image defaults to the SIF image itself. If export is
+// OverlayDiffExportTar, the changes are written as a tar archive instead of a
+// list.
+func OverlayDiff(ctx context.Context, w io.Writer, overlayPath, basePath, export string) error {
+	if export != "" && export != OverlayDiffExportTar {
+		return fmt.Errorf("unsupported export format %q, only %q is supported", export, OverlayDiffExportTar)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "overlay-diff-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary directory: %w", err)
+	}
+	mounts := []*fuse.ImageMount{}
+	defer func() {
+		for i := len(mounts) - 1; i >= 0; i-- {
+			if err := mounts[i].Unmount(ctx); err != nil {
+				sylog.Warningf("while unmounting %s: %v", mounts[i].GetMountPoint(), err)
+			}
+		}
+		if err := os.RemoveAll(tmpDir); err != nil {
+			sylog.Warningf("while removing %s: %v", tmpDir, err)
+		}
+	}()
+
+	mount := func(im *fuse.ImageMount) (string, error) {
+		im.UID = os.Getuid()
+		im.GID = os.Getgid()
+		im.Readonly = true
+		im.EnclosingDir = tmpDir
+		if err := im.Mount(ctx); err != nil {
+			return "", err
+		}
+		mounts = append(mounts, im)
+		return im.GetMountPoint(), nil
+	}
+
+	upper, isSIF, err := overlayUpperDir(overlayPath, mount)
+	if err != nil {
+		return err
+	}
+
+	if basePath == "" {
+		if !isSIF {
+			return errors.New("a base image must be specified with --base for an overlay not embedded in a SIF image")
+		}
+		basePath = overlayPath
+	}
+	lower, err := baseRootFs(basePath, mount)
+	if err != nil {
+		return err
+	}
+
+	changes, err := overlay.Diff(upper, lower)
+	if err != nil {
+		return fmt.Errorf("while comparing overlay with base image: %w", err)
+	}
+
+	if export == OverlayDiffExportTar {
+		return overlay.WriteTar(w, upper, changes)
+	}
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s %s\n", c.Kind, c.Path)
+	}
+	return nil
+}
+
+// overlayUpperDir returns the upper dir of the overlay at path, using mount to
+// mount overlay images, and whether the overlay is embedded in a SIF image.
+func overlayUpperDir(path string, mount func(*fuse.ImageMount) (string, error)) (string, bool, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+	if fi.IsDir() {
+		upper := filepath.Join(path, "upper")
+		if fi, err := os.Stat(upper); err != nil || !fi.IsDir() {
+			return "", false, fmt.Errorf("overlay directory %s has no upper directory", path)
+		}
+		return upper, false, nil
+	}
+
+	img, err := image.Init(path, false)
+	if err != nil {
+		return "", false, fmt.Errorf("while opening overlay %s: %w", path, err)
+	}
+	defer img.File.Close()
+
+	im := &fuse.ImageMount{Type: image.EXT3, SourcePath: filepath.Clean(path)}
+	isSIF := false
+
+	switch img.Type {
+	case image.EXT3:
+	case image.SIF:
+		parts, err := img.GetOverlayPartitions()
+		if err != nil {
+			return "", false, fmt.Errorf("while getting overlay partitions: %w", err)
+		}
+		if len(parts) == 0 {
+			return "", false, fmt.Errorf("image %s has no overlay partition", path)
+		}
+		if parts[0].Type != image.EXT3 {
+			return "", false, fmt.Errorf("overlay partition of image %s is not an EXT3 partition", path)
+		}
+		im.ExtraOpts = []string{fmt.Sprintf("offset=%d", parts[0].Offset)}
+		isSIF = true
+	default:
+		return "", false, fmt.Errorf("%s is not an overlay directory, EXT3 image or SIF image", path)
+	}
+
+	mnt, err := mount(im)
+	if err != nil {
+		return "", false, err
+	}
+	upper := filepath.Join(mnt, "upper")
+	if _, err := os.Stat(upper); err != nil {
+		return "", false, fmt.Errorf("overlay %s has no upper directory", path)
+	}
+	return upper, isSIF, nil
+}
+
+// baseRootFs returns the root filesystem of the base image at path, using
+// mount to mount image files.
+func baseRootFs(path string, mount func(*fuse.ImageMount) (string, error)) (string, error) {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return "", fmt.Errorf("while opening base image %s: %w", path, err)
+	}
+	defer img.File.Close()
+
+	im := &fuse.ImageMount{Type: img.Type, SourcePath: filepath.Clean(path)}
+
+	switch img.Type {
+	case image.SANDBOX:
+		return path, nil
+	case image.SQUASHFS, image.EXT3:
+	case image.SIF:
+		part, err := img.GetRootFsPartition()
+		if err != nil {
+			return "", fmt.Errorf("while getting root filesystem of %s: %w", path, err)
+		}
+		if part.Type != image.SQUASHFS && part.Type != image.EXT3 {
+			return "", fmt.Errorf("root filesystem of image %s is not a squashfs or EXT3 partition", path)
+		}
+		im.Type = int(part.Type)
+		im.ExtraOpts = []string{fmt.Sprintf("offset=%d", part.Offset)}
+	default:
+		return "", fmt.Errorf("%s is not a sandbox, SIF, squashfs or EXT3 image", path)
+	}
+
+	return mount(im)
+}
@@ -13,11 +13,16 @@ import (
 	"text/tabwriter"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote"
-	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential"
 )
 
-// RegistryList prints information about remote configurations
-func RegistryList(usrConfigFile string) (err error) {
+// RegistryInfo describes a registry listed by RegistryList.
+type RegistryInfo struct {
+	URI      string `json:"uri" yaml:"uri"`
+	Insecure bool   `json:"insecure" yaml:"insecure"`
+}
+
+// RegistryList prints information about remote configurations, in format
+func RegistryList(usrConfigFile string, format ListFormat) (err error) {
 	c := &remote.Config{}
 
 	// opening config file
@@ -40,7 +45,7 @@ func RegistryList(usrConfigFile string) (err error) {
 		return err
 	}
 
-	var registryCredentials []*credential.Config
+	registries := make([]RegistryInfo, 0)
 	for _, cred := range c.Credentials {
 		u, err := url.Parse(cred.URI)
 		if err != nil {
@@ -49,11 +54,15 @@ func RegistryList(usrConfigFile string) (err error) {
 
 		switch u.Scheme {
 		case "oras", "docker":
-			registryCredentials = append(registryCredentials, cred)
+			registries = append(registries, RegistryInfo{URI: cred.URI, Insecure: cred.Insecure})
 		}
 	}
 
-	if len(registryCredentials) < 1 {
+	if format != ListFormatText {
+		return writeListing(os.Stdout, format, registries)
+	}
+
+	if len(registries) < 1 {
 		fmt.Println()
 		fmt.Println("(no registries with stored login information found)")
 		fmt.Println()
@@ -64,7 +73,7 @@ func RegistryList(usrConfigFile string) (err error) {
 	fmt.Println()
 	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintf(tw, "%s\t%s\n", "URI", "SECURE?")
-	for _, r := range registryCredentials {
+	for _, r := range registries {
 		secure := "✓"
 		if r.Insecure {
 			secure = "✗!"
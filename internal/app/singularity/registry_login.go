@@ -8,51 +8,20 @@ package singularity
 
 import (
 	"fmt"
-	"io"
-	"os"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote"
 )
 
 // RegistryLogin logs in to an OCI/Docker registry.
 func RegistryLogin(usrConfigFile string, args *LoginArgs, reqAuthFile string) (err error) {
-	// opening config file
-	file, err := os.OpenFile(usrConfigFile, os.O_RDWR|os.O_CREATE, 0o600)
-	if err != nil {
-		return fmt.Errorf("while opening configuration file: %s", err)
-	}
-	defer file.Close()
-
-	// read file contents to config struct
-	c, err := remote.ReadFrom(file)
-	if err != nil {
-		return fmt.Errorf("while parsing configuration data: %s", err)
-	}
-
-	if err := syncSysConfig(c); err != nil {
-		return err
-	}
-
-	if err := c.Login(args.Name, args.Username, args.Password, args.Insecure, reqAuthFile); err != nil {
-		return fmt.Errorf("while login to %s: %s", args.Name, err)
-	}
-
-	// truncating file before writing new contents and syncing to commit file
-	if err := file.Truncate(0); err != nil {
-		return fmt.Errorf("while truncating configuration file: %s", err)
-	}
-
-	if n, err := file.Seek(0, io.SeekStart); err != nil || n != 0 {
-		return fmt.Errorf("failed to reset %s cursor: %s", file.Name(), err)
-	}
-
-	if _, err := c.WriteTo(file); err != nil {
-		return fmt.Errorf("while writing configuration to file: %s", err)
-	}
-
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to flush configuration file %s: %s", file.Name(), err)
-	}
-
-	return nil
+	return remote.UpdateFile(usrConfigFile, 0o600, func(c *remote.Config) error {
+		if err := syncSysConfig(c); err != nil {
+			return err
+		}
+
+		if err := c.Login(args.Name, args.Username, args.Password, args.Insecure, reqAuthFile); err != nil {
+			return fmt.Errorf("while login to %s: %s", args.Name, err)
+		}
+		return nil
+	})
 }
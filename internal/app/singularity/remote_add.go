@@ -7,7 +7,6 @@ package singularity
 
 import (
 	"fmt"
-	"io"
 	"net/url"
 	"os"
 	"path"
@@ -33,51 +32,20 @@ func RemoteAdd(configFile, name, uri string, global, insecure, makeDefault bool)
 		perm = os.FileMode(0o644)
 	}
 
-	// opening config file
-	file, err := os.OpenFile(configFile, os.O_RDWR|os.O_CREATE, perm)
-	if err != nil {
-		return fmt.Errorf("while opening remote config file: %s", err)
-	}
-	defer file.Close()
-
-	// read file contents to config struct
-	c, err := remote.ReadFrom(file)
-	if err != nil {
-		return fmt.Errorf("while parsing remote config data: %s", err)
-	}
-
 	u, err := url.Parse(uri)
 	if err != nil {
 		return err
 	}
 	e := endpoint.Config{URI: path.Join(u.Host + u.Path), System: global, Insecure: insecure}
 
-	if err := c.Add(name, &e); err != nil {
-		return err
-	}
-
-	if makeDefault {
-		if err := c.SetDefault(name, false); err != nil {
+	return remote.UpdateFile(configFile, perm, func(c *remote.Config) error {
+		if err := c.Add(name, &e); err != nil {
 			return err
 		}
-	}
 
-	// truncating file before writing new contents and syncing to commit file
-	if err := file.Truncate(0); err != nil {
-		return fmt.Errorf("while truncating remote config file: %s", err)
-	}
-
-	if n, err := file.Seek(0, io.SeekStart); err != nil || n != 0 {
-		return fmt.Errorf("failed to reset %s cursor: %s", file.Name(), err)
-	}
-
-	if _, err := c.WriteTo(file); err != nil {
-		return fmt.Errorf("while writing remote config to file: %s", err)
-	}
-
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to flush remote config file %s: %s", file.Name(), err)
-	}
-
-	return nil
+		if makeDefault {
+			return c.SetDefault(name, false)
+		}
+		return nil
+	})
 }
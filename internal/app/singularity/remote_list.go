@@ -17,8 +17,18 @@ import (
 
 const listLine = "%s\t%s\t%s\t%s\t%s\t%s\n"
 
-// RemoteList prints information about remote configurations
-func RemoteList(usrConfigFile string) (err error) {
+// RemoteInfo describes a remote endpoint listed by RemoteList.
+type RemoteInfo struct {
+	Name      string `json:"name" yaml:"name"`
+	URI       string `json:"uri" yaml:"uri"`
+	Default   bool   `json:"default" yaml:"default"`
+	Global    bool   `json:"global" yaml:"global"`
+	Exclusive bool   `json:"exclusive" yaml:"exclusive"`
+	Insecure  bool   `json:"insecure" yaml:"insecure"`
+}
+
+// RemoteList prints information about remote configurations, in format
+func RemoteList(usrConfigFile string, format ListFormat) (err error) {
 	c := &remote.Config{}
 
 	// opening config file
@@ -41,48 +51,70 @@ func RemoteList(usrConfigFile string) (err error) {
 		return err
 	}
 
-	// list in alphanumeric order
-	names := make([]string, 0, len(c.Remotes))
-	for n := range c.Remotes {
-		names = append(names, n)
+	remotes := remoteInfos(c)
+	if format != ListFormatText {
+		return writeListing(os.Stdout, format, remotes)
 	}
-	sort.Slice(names, func(i, j int) bool {
-		iName, jName := names[i], names[j]
-
-		if c.Remotes[iName].System && !c.Remotes[jName].System {
-			return true
-		} else if !c.Remotes[iName].System && c.Remotes[jName].System {
-			return false
-		}
-
-		return names[i] < names[j]
-	})
-	sort.Strings(names)
 
 	fmt.Println()
 	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintf(tw, listLine, "NAME", "URI", "DEFAULT?", "GLOBAL?", "EXCLUSIVE?", "SECURE?")
-	for _, n := range names {
+	for _, r := range remotes {
 		sys := ""
-		if c.Remotes[n].System {
+		if r.Global {
 			sys = "✓"
 		}
 		excl := ""
-		if c.Remotes[n].Exclusive {
+		if r.Exclusive {
 			excl = "✓"
 		}
 		secure := "✓"
-		if c.Remotes[n].Insecure {
+		if r.Insecure {
 			secure = "✗!"
 		}
 		isDefault := ""
-		if c.DefaultRemote != "" && c.DefaultRemote == n {
+		if r.Default {
 			isDefault = "✓"
 		}
 
-		fmt.Fprintf(tw, listLine, n, c.Remotes[n].URI, isDefault, sys, excl, secure)
+		fmt.Fprintf(tw, listLine, r.Name, r.URI, isDefault, sys, excl, secure)
 	}
 	tw.Flush()
 
 	return nil
 }
+
+// remoteInfos returns the remote endpoints of c, in alphanumeric order.
+func remoteInfos(c *remote.Config) []RemoteInfo {
+	// list in alphanumeric order
+	names := make([]string, 0, len(c.Remotes))
+	for n := range c.Remotes {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		iName, jName := names[i], names[j]
+
+		if c.Remotes[iName].System && !c.Remotes[jName].System {
+			return true
+		} else if !c.Remotes[iName].System && c.Remotes[jName].System {
+			return false
+		}
+
+		return names[i] < names[j]
+	})
+	sort.Strings(names)
+
+	remotes := make([]RemoteInfo, 0, len(names))
+	for _, n := range names {
+		ep := c.Remotes[n]
+		remotes = append(remotes, RemoteInfo{
+			Name:      n,
+			URI:       ep.URI,
+			Default:   c.DefaultRemote != "" && c.DefaultRemote == n,
+			Global:    ep.System,
+			Exclusive: ep.Exclusive,
+			Insecure:  ep.Insecure,
+		})
+	}
+	return remotes
+}
@@ -7,10 +7,11 @@
 package singularity
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"os"
+	"time"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
@@ -34,31 +35,20 @@ var ErrLoginAborted = errors.New("user aborted login")
 // If the supplied remote name is an empty string, it will attempt
 // to use the default remote.
 func RemoteLogin(usrConfigFile string, args *LoginArgs) (err error) {
-	// opening config file
-	file, err := os.OpenFile(usrConfigFile, os.O_RDWR|os.O_CREATE, 0o600)
+	// the remote config file isn't locked during the login, which may be
+	// interactive
+	c, err := readRemoteConfig(usrConfigFile)
 	if err != nil {
-		return fmt.Errorf("while opening remote config file: %s", err)
-	}
-	defer file.Close()
-
-	// read file contents to config struct
-	c, err := remote.ReadFrom(file)
-	if err != nil {
-		return fmt.Errorf("while parsing remote config data: %s", err)
+		return err
 	}
 
 	if err := syncSysConfig(c); err != nil {
 		return err
 	}
 
-	var r *endpoint.Config
-	if args.Name == "" {
-		r, err = c.GetDefault()
-	} else {
-		r, err = c.GetRemote(args.Name)
-		if err != nil {
-			return err
-		}
+	r, err := selectRemote(c, args.Name)
+	if err != nil {
+		return err
 	}
 
 	// endpoints (sylabs cloud, singularity enterprise etc.)
@@ -70,25 +60,38 @@ func RemoteLogin(usrConfigFile string, args *LoginArgs) (err error) {
 		return err
 	}
 
-	// truncating file before writing new contents and syncing to commit file
-	if err := file.Truncate(0); err != nil {
-		return fmt.Errorf("while truncating remote config file: %s", err)
+	if err := RemoteUpdateToken(usrConfigFile, args.Name, r); err != nil {
+		return err
 	}
 
-	if n, err := file.Seek(0, io.SeekStart); err != nil || n != 0 {
-		return fmt.Errorf("failed to reset %s cursor: %s", file.Name(), err)
-	}
+	sylog.Infof("Token stored in %s", usrConfigFile)
+	return nil
+}
 
-	if _, err := c.WriteTo(file); err != nil {
-		return fmt.Errorf("while writing remote config to file: %s", err)
+// readRemoteConfig reads the remote config file at path, which may not exist.
+func readRemoteConfig(path string) (*remote.Config, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return remote.ReadFrom(bytes.NewReader(nil))
+	} else if err != nil {
+		return nil, fmt.Errorf("while opening remote config file: %s", err)
 	}
+	defer file.Close()
 
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to flush remote config file %s: %s", file.Name(), err)
+	c, err := remote.ReadFrom(file)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing remote config data: %s", err)
 	}
+	return c, nil
+}
 
-	sylog.Infof("Token stored in %s", file.Name())
-	return nil
+// selectRemote returns the remote name of c, or its default remote if name is
+// empty.
+func selectRemote(c *remote.Config, name string) (*endpoint.Config, error) {
+	if name == "" {
+		return c.GetDefault()
+	}
+	return c.GetRemote(name)
 }
 
 // endPointLogin implements the flow to set a new token against a remote endpoint config.
@@ -98,12 +101,14 @@ func endPointLogin(ep *endpoint.Config, args *LoginArgs) error {
 		token string
 		err   error
 	)
+	ts := new(auth.TokenSet)
 	// Non-interactive with a token file
 	if args.Tokenfile != "" {
-		token, err = auth.ReadToken(args.Tokenfile)
+		ts, err = auth.ReadTokenSet(args.Tokenfile)
 		if err != nil {
 			return fmt.Errorf("while reading tokenfile: %s", err)
 		}
+		token = ts.AccessToken
 	} else {
 		// Interactive login
 		// If a token is already set, prompt to see if we want to replace it
@@ -139,6 +144,25 @@ func endPointLogin(ep *endpoint.Config, args *LoginArgs) error {
 		return fmt.Errorf("while verifying token: %v", err)
 	}
 	// Token is verified, update the endpoint config with it
-	ep.Token = token
+	ep.SetToken(token, ts.RefreshToken, time.Duration(ts.ExpiresIn)*time.Second)
 	return nil
 }
+
+// RemoteUpdateToken stores the token of ep in the remote config file for the
+// remote name, or the default remote if name is empty.
+func RemoteUpdateToken(usrConfigFile, name string, ep *endpoint.Config) error {
+	return remote.UpdateFile(usrConfigFile, 0o600, func(c *remote.Config) error {
+		if err := syncSysConfig(c); err != nil {
+			return err
+		}
+
+		r, err := selectRemote(c, name)
+		if err != nil {
+			return err
+		}
+		r.Token = ep.Token
+		r.TokenExpiry = ep.TokenExpiry
+		r.RefreshToken = ep.RefreshToken
+		return nil
+	})
+}
@@ -8,105 +8,39 @@ package singularity
 
 import (
 	"fmt"
-	"io"
-	"os"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote"
-	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
 )
 
 // RemoteLogout logs out from an endpoint.
 func RemoteLogout(usrConfigFile, name string) (err error) {
-	// opening config file
-	file, err := os.OpenFile(usrConfigFile, os.O_RDWR|os.O_CREATE, 0o600)
-	if err != nil {
-		return fmt.Errorf("while opening remote config file: %s", err)
-	}
-	defer file.Close()
-
-	// read file contents to config struct
-	c, err := remote.ReadFrom(file)
-	if err != nil {
-		return fmt.Errorf("while parsing remote config data: %s", err)
-	}
-
-	if err := syncSysConfig(c); err != nil {
-		return err
-	}
+	return remote.UpdateFile(usrConfigFile, 0o600, func(c *remote.Config) error {
+		if err := syncSysConfig(c); err != nil {
+			return err
+		}
 
-	var r *endpoint.Config
-	if name == "" {
-		r, err = c.GetDefault()
-	} else {
-		r, err = c.GetRemote(name)
+		r, err := selectRemote(c, name)
 		if err != nil {
 			return err
 		}
-	}
-
-	// Remove the token in question
-	r.Token = ""
 
-	// truncating file before writing new contents and syncing to commit file
-	if err := file.Truncate(0); err != nil {
-		return fmt.Errorf("while truncating remote config file: %s", err)
-	}
-
-	if n, err := file.Seek(0, io.SeekStart); err != nil || n != 0 {
-		return fmt.Errorf("failed to reset %s cursor: %s", file.Name(), err)
-	}
-
-	if _, err := c.WriteTo(file); err != nil {
-		return fmt.Errorf("while writing remote config to file: %s", err)
-	}
-
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to flush remote config file %s: %s", file.Name(), err)
-	}
-
-	return nil
+		// Remove the token in question
+		r.SetToken("", "", 0)
+		return nil
+	})
 }
 
 // RemoteLogout logs out from a keyserver or OCI/Docker registry.
 func OtherLogout(usrConfigFile, name string, reqAuthFile string) (err error) {
-	// opening config file
-	file, err := os.OpenFile(usrConfigFile, os.O_RDWR|os.O_CREATE, 0o600)
-	if err != nil {
-		return fmt.Errorf("while opening configuration file: %s", err)
-	}
-	defer file.Close()
-
-	// read file contents to config struct
-	c, err := remote.ReadFrom(file)
-	if err != nil {
-		return fmt.Errorf("while parsing configuration data: %s", err)
-	}
-
-	if err := syncSysConfig(c); err != nil {
-		return err
-	}
-
-	// services
-	if err := c.Logout(name, reqAuthFile); err != nil {
-		return fmt.Errorf("while verifying token: %v", err)
-	}
-
-	// truncating file before writing new contents and syncing to commit file
-	if err := file.Truncate(0); err != nil {
-		return fmt.Errorf("while truncating configuration file: %s", err)
-	}
-
-	if n, err := file.Seek(0, io.SeekStart); err != nil || n != 0 {
-		return fmt.Errorf("failed to reset %s cursor: %s", file.Name(), err)
-	}
-
-	if _, err := c.WriteTo(file); err != nil {
-		return fmt.Errorf("while writing configuration to file: %s", err)
-	}
-
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to flush configuration file %s: %s", file.Name(), err)
-	}
+	return remote.UpdateFile(usrConfigFile, 0o600, func(c *remote.Config) error {
+		if err := syncSysConfig(c); err != nil {
+			return err
+		}
 
-	return nil
+		// services
+		if err := c.Logout(name, reqAuthFile); err != nil {
+			return fmt.Errorf("while verifying token: %v", err)
+		}
+		return nil
+	})
 }
@@ -0,0 +1,263 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/remote"
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
+	remoteutil "github.com/sylabs/singularity/v4/internal/pkg/remote/util"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+const pingLine = "%s\t%s\t%s\t%s\t%s\t%s\n"
+
+// PingResult describes the health of a remote endpoint, as checked by
+// RemotePing.
+type PingResult struct {
+	Remote         string        `json:"remote" yaml:"remote"`
+	URI            string        `json:"uri" yaml:"uri"`
+	Services       []ServicePing `json:"services" yaml:"services"`
+	Authentication AuthStatus    `json:"authentication" yaml:"authentication"`
+}
+
+// ServicePing describes the health of a service of a remote endpoint.
+type ServicePing struct {
+	Service              string `json:"service" yaml:"service"`
+	URI                  string `json:"uri" yaml:"uri"`
+	External             bool   `json:"external" yaml:"external"`
+	Insecure             bool   `json:"insecure" yaml:"insecure"`
+	endpoint.ProbeResult `yaml:",inline"`
+}
+
+// AuthStatus describes the authentication token of a remote endpoint.
+type AuthStatus struct {
+	// Status is one of none (logged out), valid or invalid.
+	Status      string     `json:"status" yaml:"status"`
+	Error       string     `json:"error,omitempty" yaml:"error,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty" yaml:"expires,omitempty"`
+	Refreshable bool       `json:"refreshable" yaml:"refreshable"`
+}
+
+// RemotePing checks the reachability, TLS certificates, and response times of
+// the services of a remote endpoint, and the validity of its authentication
+// token, and prints the results in format. If the supplied remote name is an
+// empty string, it will check the default remote. An error is returned if any
+// service is unhealthy.
+func RemotePing(usrConfigFile, name string, format ListFormat) (err error) {
+	// opening config file
+	file, err := os.OpenFile(usrConfigFile, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no remote configurations")
+		}
+		return fmt.Errorf("while opening remote config file: %s", err)
+	}
+	defer file.Close()
+
+	// read file contents to config struct
+	c, err := remote.ReadFrom(file)
+	if err != nil {
+		return fmt.Errorf("while parsing remote config data: %s", err)
+	}
+
+	if err := syncSysConfig(c); err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = c.DefaultRemote
+	}
+	e, err := c.GetRemote(name)
+	if err != nil {
+		return err
+	}
+
+	res, err := pingEndpoint(context.Background(), e)
+	if err != nil {
+		return err
+	}
+	res.Remote = name
+
+	if format != ListFormatText {
+		if err := writeListing(os.Stdout, format, res); err != nil {
+			return err
+		}
+	} else {
+		printPingResult(res)
+	}
+
+	failed := 0
+	for _, s := range res.Services {
+		if !s.OK() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d services of remote %s are unhealthy", failed, len(res.Services), name)
+	}
+	return nil
+}
+
+// pingEndpoint probes the services and keyservers of e concurrently, and
+// verifies its authentication token.
+func pingEndpoint(ctx context.Context, e *endpoint.Config) (*PingResult, error) {
+	sps, err := e.GetAllServices()
+	if err != nil {
+		return nil, fmt.Errorf("while retrieving services: %s", err)
+	}
+
+	var services []ServicePing
+	seen := make(map[string]bool)
+	for name, sp := range sps {
+		for _, s := range sp {
+			services = append(services, ServicePing{Service: name, URI: s.URI()})
+			seen[s.URI()] = true
+		}
+	}
+	// keyservers added with 'keyserver add' are checked too
+	if err := e.UpdateKeyserversConfig(); err == nil {
+		for _, kc := range e.Keyservers {
+			if kc.Skip || seen[kc.URI] {
+				continue
+			}
+			uri := kc.URI
+			if u, err := remoteutil.NormalizeKeyserverURI(kc.URI); err == nil {
+				uri = u.String()
+			}
+			services = append(services, ServicePing{
+				Service:  endpoint.Keyserver,
+				URI:      uri,
+				External: kc.External,
+				Insecure: kc.Insecure,
+			})
+			seen[kc.URI] = true
+		}
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Service != services[j].Service {
+			return services[i].Service < services[j].Service
+		}
+		return services[i].URI < services[j].URI
+	})
+
+	done := make(chan struct{})
+	for i := range services {
+		go func(s *ServicePing) {
+			s.ProbeResult = *endpoint.ProbeService(ctx, s.URI, s.External, s.Insecure)
+			done <- struct{}{}
+		}(&services[i])
+	}
+	for range services {
+		<-done
+	}
+
+	return &PingResult{
+		URI:            e.URI,
+		Services:       services,
+		Authentication: authStatus(e),
+	}, nil
+}
+
+// authStatus verifies the authentication token of e.
+func authStatus(e *endpoint.Config) AuthStatus {
+	if e.Token == "" {
+		return AuthStatus{Status: "none"}
+	}
+
+	s := AuthStatus{Status: "valid", Refreshable: e.RefreshToken != ""}
+	if _, ok := e.TokenExpiresIn(); ok {
+		expires := e.TokenExpiry
+		s.Expires = &expires
+	}
+	if err := e.VerifyToken(""); err != nil {
+		s.Status = "invalid"
+		s.Error = err.Error()
+	}
+	return s
+}
+
+func printPingResult(res *PingResult) {
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, pingLine, "SERVICE", "STATUS", "VERSION", "TLS", "LATENCY", "URI")
+	for _, s := range res.Services {
+		status := "OK"
+		if !s.Reachable {
+			status = "UNREACHABLE"
+		} else if !s.OK() {
+			status = "FAILED"
+		}
+
+		version := s.Version
+		if version == "" {
+			version = "-"
+		}
+
+		tlsStatus := "none"
+		if s.TLS != nil {
+			switch {
+			case s.TLS.Valid && s.TLS.NotAfter != nil:
+				tlsStatus = "valid until " + s.TLS.NotAfter.Format("2006-01-02")
+			case s.TLS.Valid:
+				tlsStatus = "valid"
+			case s.TLS.Unverified:
+				tlsStatus = "not verified"
+			case s.TLS.Error != "":
+				tlsStatus = "invalid"
+			default:
+				tlsStatus = "-"
+			}
+		}
+
+		latency := "-"
+		if s.Reachable {
+			latency = fmt.Sprintf("%.0fms", s.Timings.Total)
+		}
+
+		service := cases.Title(language.English).String(s.Service)
+		if s.External {
+			service += " (external)"
+		}
+		fmt.Fprintf(tw, pingLine, service, status, version, tlsStatus, latency, s.URI)
+	}
+	tw.Flush()
+
+	for _, s := range res.Services {
+		if s.OK() {
+			continue
+		}
+		msg := s.Error
+		if s.TLS != nil && s.TLS.Error != "" {
+			msg = "TLS certificate: " + s.TLS.Error
+		}
+		sylog.Warningf("%s %s: %s", s.Service, s.URI, msg)
+	}
+
+	fmt.Println()
+	switch res.Authentication.Status {
+	case "none":
+		fmt.Println("No authentication token set (logged out).")
+	case "invalid":
+		fmt.Printf("Authentication token is invalid (please login again): %s\n", res.Authentication.Error)
+	default:
+		if res.Authentication.Expires != nil {
+			fmt.Printf("Valid authentication token set (logged in), expires on %s.\n",
+				res.Authentication.Expires.Local().Format(time.RFC1123))
+		} else {
+			fmt.Println("Valid authentication token set (logged in).")
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"sort"
 	"text/tabwriter"
+	"time"
 
 	scslibclient "github.com/sylabs/scs-library-client/client"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/library"
@@ -155,5 +156,25 @@ func doTokenCheck(e *endpoint.Config) error {
 		return err
 	}
 	fmt.Println("\nValid authentication token set (logged in).")
+	warnTokenExpiry(e)
 	return nil
 }
+
+// warnTokenExpiry warns when the token of e expired, or will expire soon.
+func warnTokenExpiry(e *endpoint.Config) {
+	d, ok := e.TokenExpiresIn()
+	if !ok || d > endpoint.TokenExpiryWarning {
+		return
+	}
+
+	refresh := "please login again"
+	if e.RefreshToken != "" {
+		refresh = "it will be refreshed automatically"
+	}
+	if d <= 0 {
+		sylog.Warningf("Authentication token expired on %s, %s", e.TokenExpiry.Local().Format(time.RFC1123), refresh)
+		return
+	}
+	sylog.Warningf("Authentication token expires in %s, on %s, %s",
+		d.Round(time.Minute), e.TokenExpiry.Local().Format(time.RFC1123), refresh)
+}
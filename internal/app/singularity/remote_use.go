@@ -8,7 +8,6 @@ package singularity
 
 import (
 	"fmt"
-	"io"
 	"os"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote"
@@ -50,45 +49,13 @@ func RemoteUse(usrConfigFile, name string, global, exclusive bool) (err error) {
 		perm = os.FileMode(0o644)
 	}
 
-	// opening config file
-	file, err := os.OpenFile(usrConfigFile, os.O_RDWR|os.O_CREATE, perm)
-	if err != nil {
-		return fmt.Errorf("while opening remote config file: %s", err)
-	}
-	defer file.Close()
-
-	// read file contents to config struct
-	c, err := remote.ReadFrom(file)
-	if err != nil {
-		return fmt.Errorf("while parsing remote config data: %s", err)
-	}
-
-	if !global {
-		if err := syncSysConfig(c); err != nil {
-			return err
+	return remote.UpdateFile(usrConfigFile, perm, func(c *remote.Config) error {
+		if !global {
+			if err := syncSysConfig(c); err != nil {
+				return err
+			}
 		}
-	}
-
-	if err := c.SetDefault(name, exclusive); err != nil {
-		return err
-	}
-
-	// truncating file before writing new contents and syncing to commit file
-	if err := file.Truncate(0); err != nil {
-		return fmt.Errorf("while truncating remote config file: %s", err)
-	}
-
-	if n, err := file.Seek(0, io.SeekStart); err != nil || n != 0 {
-		return fmt.Errorf("failed to reset %s cursor: %s", file.Name(), err)
-	}
-
-	if _, err := c.WriteTo(file); err != nil {
-		return fmt.Errorf("while writing remote config to file: %s", err)
-	}
-
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to flush remote config file %s: %s", file.Name(), err)
-	}
 
-	return nil
+		return c.SetDefault(name, exclusive)
+	})
 }
@@ -0,0 +1,164 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/unpacker"
+	"github.com/sylabs/singularity/v4/internal/pkg/scan"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// ScanOptions holds the options for Scan.
+type ScanOptions struct {
+	// Backend is the scanner used to generate the SBOM, and scan it.
+	Backend scan.Backend
+	// SBOMPath, if set, is the path to which the SBOM is written. Otherwise,
+	// the SBOM is written to a temporary file, which is removed.
+	SBOMPath string
+	// TmpDir is the directory in which temporary files are created.
+	TmpDir string
+}
+
+// Scan generates an SBOM for the SIF, OCI-SIF, squashfs, or sandbox image at
+// path, and returns the known vulnerabilities of the packages it describes.
+func Scan(ctx context.Context, path string, opts ScanOptions) ([]scan.Vulnerability, error) {
+	tmpDir, err := os.MkdirTemp(opts.TmpDir, "scan-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := fs.ForceRemoveAll(tmpDir); err != nil {
+			sylog.Warningf("Could not remove temporary directory %s: %v", tmpDir, err)
+		}
+	}()
+
+	img, err := image.Init(path, false)
+	if err != nil {
+		return nil, err
+	}
+	imgType := img.Type
+	img.File.Close()
+
+	rootfs := path
+	if imgType != image.SANDBOX {
+		rootfs = filepath.Join(tmpDir, "rootfs")
+		sylog.Infof("Extracting root filesystem of %s", path)
+		if err := extractRootfs(path, rootfs); err != nil {
+			return nil, fmt.Errorf("while extracting root filesystem: %w", err)
+		}
+	}
+
+	sbomPath := opts.SBOMPath
+	if sbomPath == "" {
+		sbomPath = filepath.Join(tmpDir, "sbom.json")
+	}
+	sylog.Infof("Generating SBOM with %s", opts.Backend.Name())
+	if err := opts.Backend.SBOM(ctx, rootfs, sbomPath); err != nil {
+		return nil, fmt.Errorf("while generating SBOM: %w", err)
+	}
+	if opts.SBOMPath != "" {
+		sylog.Infof("SBOM written to %s", opts.SBOMPath)
+	}
+
+	sylog.Infof("Scanning SBOM with %s", opts.Backend.Name())
+	vulns, err := opts.Backend.Scan(ctx, sbomPath)
+	if err != nil {
+		return nil, fmt.Errorf("while scanning SBOM: %w", err)
+	}
+	scan.Sort(vulns)
+	return vulns, nil
+}
+
+// extractRootfs extracts the squashfs root filesystem of the SIF, OCI-SIF, or
+// squashfs image at path to dest.
+func extractRootfs(path, dest string) error {
+	s := unpacker.NewSquashfs()
+
+	img, err := image.Init(path, false)
+	if err != nil {
+		return err
+	}
+	defer img.File.Close()
+
+	switch img.Type {
+	case image.SIF, image.SQUASHFS:
+		part, err := img.GetRootFsPartition()
+		if err != nil {
+			return err
+		}
+		if part.Type != image.SQUASHFS {
+			return fmt.Errorf("unsupported root filesystem type, only squashfs root filesystems can be scanned")
+		}
+		r, err := image.NewPartitionReader(img, "", 0)
+		if err != nil {
+			return err
+		}
+		return s.ExtractAll(r, dest)
+	case image.OCISIF:
+		mf, err := ocisif.ImageManifest(path)
+		if err != nil {
+			return err
+		}
+		// Layers other than the first hold overlayfs whiteouts, which can't be
+		// applied by extraction, so only squashed images are supported.
+		if len(mf.Layers) != 1 {
+			return fmt.Errorf("only oci-sif files with a single layer are supported, %s has %d layers", path, len(mf.Layers))
+		}
+		if mf.Layers[0].MediaType != ocisif.SquashfsLayerMediaType {
+			return fmt.Errorf("unsupported layer mediaType %q", mf.Layers[0].MediaType)
+		}
+
+		fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+		if err != nil {
+			return fmt.Errorf("while loading SIF: %w", err)
+		}
+		defer fi.UnloadContainer()
+
+		d, err := fi.GetDescriptor(sif.WithOCIBlobDigest(mf.Layers[0].Digest))
+		if err != nil {
+			return fmt.Errorf("failed to get layer descriptor: %w", err)
+		}
+		return s.ExtractAll(d.GetReader(), dest)
+	}
+	return fmt.Errorf("unsupported image format, only SIF, OCI-SIF, squashfs and sandbox images can be scanned")
+}
+
+// WriteScanResults writes vulns to w, as a table, or as a JSON array if
+// asJSON is true.
+func WriteScanResults(w io.Writer, vulns []scan.Vulnerability, asJSON bool) error {
+	if asJSON {
+		if vulns == nil {
+			vulns = []scan.Vulnerability{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(vulns)
+	}
+
+	if len(vulns) == 0 {
+		_, err := fmt.Fprintln(w, "No known vulnerabilities found")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSEVERITY\tPACKAGE\tVERSION\tFIXED IN")
+	for _, v := range vulns {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.ID, v.Severity, v.Package, v.Version, v.FixedVersion)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package starter
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// syslogIdent and syslogErrorID identify fatal errors of the starter in
+// syslog. They are the same as SYSLOG_IDENT and SYSLOG_ERROR_ID in
+// cmd/starter/c/include/starter.h.
+const (
+	syslogIdent   = "singularity-starter"
+	syslogErrorID = "starter_fatal"
+)
+
+// syslogMessage returns the structured syslog message for a fatal error msg
+// of the starter stage, run by uid.
+func syslogMessage(stage string, uid int, msg string) string {
+	return fmt.Sprintf("error_id=%s stage=%s uid=%d msg=%s", syslogErrorID, stage, uid, msg)
+}
+
+// SyslogFatalErrors logs the fatal errors of the starter stage to syslog, in
+// addition to stderr, so that failures of the setuid starter can be
+// monitored on the node. Errors occurring where syslog is not reachable,
+// e.g. inside the container, are not logged.
+func SyslogFatalErrors(stage string) {
+	uid := os.Getuid()
+	sylog.SetFatalHook(func(msg string) {
+		w, err := syslog.New(syslog.LOG_USER|syslog.LOG_ERR, syslogIdent)
+		if err != nil {
+			return
+		}
+		defer w.Close()
+		w.Err(syslogMessage(stage, uid, msg))
+	})
+}
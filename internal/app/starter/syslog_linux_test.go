@@ -0,0 +1,18 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package starter
+
+import (
+	"testing"
+)
+
+func TestSyslogMessage(t *testing.T) {
+	got := syslogMessage("master", 1000, "failed to attach loop device")
+	want := "error_id=starter_fatal stage=master uid=1000 msg=failed to attach loop device"
+	if got != want {
+		t.Errorf("syslogMessage() = %q, want %q", got, want)
+	}
+}
@@ -208,10 +208,11 @@ func getAppAndSection(ident string) (appName string, sectionName string) {
 }
 
 // HandleBundle is a hook where we can modify the bundle
-func (pl *BuildApp) HandleBundle(b *types.Bundle) {
+func (pl *BuildApp) HandleBundle(b *types.Bundle) error {
 	if err := pl.createAllApps(b); err != nil {
-		sylog.Fatalf("Unable to create apps: %s", err)
+		return fmt.Errorf("unable to create apps: %s", err)
 	}
+	return nil
 }
 
 func (pl *BuildApp) createAllApps(b *types.Bundle) error {
@@ -9,49 +9,156 @@ package args
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/samber/lo"
 	"github.com/sylabs/singularity/v4/pkg/build/types"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"gopkg.in/yaml.v3"
 )
 
+// EnvPrefix is the prefix of the environment variables providing build args,
+// e.g. SINGULARITY_BUILD_ARG_OS_VER=3.18 provides the build arg OS_VER.
+const EnvPrefix = "SINGULARITY_BUILD_ARG_"
+
+// Source identifies where the value of a build arg was provided.
+type Source string
+
+const (
+	// SourceFlag is a value provided with --build-arg.
+	SourceFlag Source = "--build-arg"
+	// SourceFile is a value provided in the --build-arg-file.
+	SourceFile Source = "--build-arg-file"
+	// SourceEnv is a value provided with a SINGULARITY_BUILD_ARG_ variable.
+	SourceEnv Source = "environment"
+	// SourceDefault is a default value from the 'arguments' section.
+	SourceDefault Source = "arguments section"
+)
+
+// ReadBuildArgs returns the build args provided, in increasing order of
+// precedence, by SINGULARITY_BUILD_ARG_ environment variables, the argFile,
+// and args in key=value format.
 func ReadBuildArgs(args []string, argFile string) (map[string]string, error) {
+	buildVarsMap, _, err := ReadBuildArgSources(args, argFile, os.Environ())
+	return buildVarsMap, err
+}
+
+// ReadBuildArgSources returns the build args provided, in increasing order of
+// precedence, by SINGULARITY_BUILD_ARG_ variables in environ, the argFile, and
+// args in key=value format, along with the source of each value.
+func ReadBuildArgSources(args []string, argFile string, environ []string) (map[string]string, map[string]Source, error) {
 	buildVarsMap := make(map[string]string)
-	if argFile != "" {
-		file, err := os.Open(argFile)
+	sources := make(map[string]Source)
+
+	for _, e := range environ {
+		if !strings.HasPrefix(e, EnvPrefix) {
+			continue
+		}
+		k, v, err := getKeyVal(strings.TrimPrefix(e, EnvPrefix))
 		if err != nil {
-			return buildVarsMap, fmt.Errorf("error while opening file %q: %s", argFile, err)
+			sylog.Warningf("Skipping build arg environment variable %s: %s", strings.SplitN(e, "=", 2)[0], err)
+			continue
 		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			text := scanner.Text()
-			k, v, err := getKeyVal(text)
-			if err != nil {
-				sylog.Warningf("Skipping %q in build arg file: %s", text, err)
-				continue
-			}
+		buildVarsMap[k] = v
+		sources[k] = SourceEnv
+	}
 
-			buildVarsMap[k] = v
+	if argFile != "" {
+		fileVarsMap, err := readBuildArgFile(argFile)
+		if err != nil {
+			return nil, nil, err
 		}
-
-		if err := scanner.Err(); err != nil {
-			return buildVarsMap, fmt.Errorf("error reading build arg file %q: %s", argFile, err)
+		for k, v := range fileVarsMap {
+			buildVarsMap[k] = v
+			sources[k] = SourceFile
 		}
 	}
 
 	for _, arg := range args {
 		k, v, err := getKeyVal(arg)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		buildVarsMap[k] = v
+		sources[k] = SourceFlag
+	}
+
+	return buildVarsMap, sources, nil
+}
 
+// readBuildArgFile reads the build args in argFile. Files with a .json, .yaml
+// or .yml extension hold a single object mapping build arg names to scalar
+// values. Other files hold key=value lines.
+func readBuildArgFile(argFile string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(argFile)) {
+	case ".json", ".yaml", ".yml":
+		return readStructuredBuildArgFile(argFile)
+	}
+
+	buildVarsMap := make(map[string]string)
+	file, err := os.Open(argFile)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening file %q: %s", argFile, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		text := scanner.Text()
+		k, v, err := getKeyVal(text)
+		if err != nil {
+			sylog.Warningf("Skipping %q in build arg file: %s", text, err)
+			continue
+		}
 		buildVarsMap[k] = v
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading build arg file %q: %s", argFile, err)
+	}
+	return buildVarsMap, nil
+}
 
+// readStructuredBuildArgFile reads the build args in the JSON or YAML file
+// argFile.
+func readStructuredBuildArgFile(argFile string) (map[string]string, error) {
+	data, err := os.ReadFile(argFile)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening file %q: %s", argFile, err)
+	}
+
+	var values map[string]any
+	if strings.EqualFold(filepath.Ext(argFile), ".json") {
+		err = json.Unmarshal(data, &values)
+	} else {
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading build arg file %q: %s", argFile, err)
+	}
+
+	buildVarsMap := make(map[string]string, len(values))
+	for k, v := range values {
+		switch v := v.(type) {
+		case nil:
+			return nil, fmt.Errorf("error reading build arg file %q: missing value for %q", argFile, k)
+		case map[string]any, []any:
+			return nil, fmt.Errorf("error reading build arg file %q: value of %q is not a string, number or boolean", argFile, k)
+		case float64:
+			// JSON numbers are decoded as float64, which mustn't be printed
+			// with an exponent
+			buildVarsMap[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			buildVarsMap[k] = fmt.Sprint(v)
+		}
+	}
 	return buildVarsMap, nil
 }
 
@@ -99,3 +206,38 @@ func getKeyVal(text string) (string, string, error) {
 	}
 	return key, val, nil
 }
+
+// WriteResolved writes a table of the build args resolved for each stage in
+// defs to w, with their source. A value provided by buildArgsMap takes
+// precedence over the default from the 'arguments' section of the stage. When
+// defs is empty, only buildArgsMap is written.
+func WriteResolved(w io.Writer, defs []types.Definition, buildArgsMap map[string]string, sources map[string]Source) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tNAME\tVALUE\tSOURCE")
+
+	writeStage := func(stage string, defaults map[string]string) {
+		names := lo.Uniq(append(lo.Keys(buildArgsMap), lo.Keys(defaults)...))
+		sort.Strings(names)
+		for _, name := range names {
+			val, ok := buildArgsMap[name]
+			source := sources[name]
+			if !ok {
+				val, source = defaults[name], SourceDefault
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", stage, name, val, source)
+		}
+	}
+
+	if len(defs) == 0 {
+		writeStage("-", nil)
+	}
+	for i, d := range defs {
+		stage := d.Header["stage"]
+		if stage == "" {
+			stage = strconv.Itoa(i + 1)
+		}
+		writeStage(stage, ReadDefaults(d))
+	}
+
+	return tw.Flush()
+}
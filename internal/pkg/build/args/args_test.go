@@ -9,13 +9,17 @@ package args
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"testing"
 
 	"gotest.tools/v3/assert"
 
+	"github.com/sylabs/singularity/v4/pkg/build/types"
 	"github.com/sylabs/singularity/v4/pkg/build/types/parser"
 )
 
@@ -173,7 +177,7 @@ func TestReader(t *testing.T) {
 				"OS_VER": "1",
 			},
 			defaultArgsMap: map[string]string{},
-			err:            "is not defined through either --build-arg (--build-arg-file) or 'arguments' section",
+			err:            "1:25: build var APP_VER is not defined through either --build-arg (--build-arg-file) or 'arguments' section",
 		},
 		{
 			name:   "wrong case because of missing variable 2",
@@ -183,7 +187,91 @@ func TestReader(t *testing.T) {
 				"OS_VE": "1",
 			},
 			defaultArgsMap: map[string]string{},
-			err:            "is not defined through either --build-arg (--build-arg-file) or 'arguments' section",
+			err:            "1:9: build var OS_VER is not defined through either --build-arg (--build-arg-file) or 'arguments' section",
+		},
+		{
+			name:   "template conditional",
+			input:  "{{ if eq .VARIANT \"gpu\" }}cuda-{{ CUDA_VER }}{{ else }}cpu{{ end }}",
+			output: "cuda-12.2",
+			argsMap: map[string]string{
+				"VARIANT": "gpu",
+			},
+			defaultArgsMap: map[string]string{
+				"CUDA_VER": "12.2",
+			},
+			err: "",
+		},
+		{
+			name:           "template conditional not taken",
+			input:          "{{ if eq .VARIANT \"gpu\" }}cuda-{{ CUDA_VER }}{{ else }}cpu{{ end }}",
+			output:         "cpu",
+			argsMap:        map[string]string{"VARIANT": "cpu"},
+			defaultArgsMap: map[string]string{},
+			err:            "",
+		},
+		{
+			name:           "template arch",
+			input:          "{{ if eq .arch \"" + runtime.GOARCH + "\" }}native{{ end }}",
+			output:         "native",
+			argsMap:        map[string]string{},
+			defaultArgsMap: map[string]string{},
+			err:            "",
+		},
+		{
+			name:           "template default",
+			input:          "{{ default \"OS_VER\" \"3.18\" }} {{ default \"APP_VER\" \"0.1\" }}",
+			output:         "3.18 1.0",
+			argsMap:        map[string]string{"APP_VER": "1.0"},
+			defaultArgsMap: map[string]string{},
+			err:            "",
+		},
+		{
+			name:           "template defined",
+			input:          "{{ if defined \"GPU\" }}{{ .GPU }}{{ end }}",
+			output:         "",
+			argsMap:        map[string]string{},
+			defaultArgsMap: map[string]string{},
+			err:            "",
+		},
+		{
+			name:           "template keeps other actions",
+			input:          "{{- if true }}\n{{ APP_VER }}\n{{- end }}",
+			output:         "\n1.0",
+			argsMap:        map[string]string{"APP_VER": "1.0"},
+			defaultArgsMap: map[string]string{},
+			err:            "",
+		},
+		{
+			name:           "wrong case because of required variable",
+			input:          "{{ required \"LICENSE_KEY\" \"set it with --build-arg\" }}",
+			output:         "",
+			argsMap:        map[string]string{},
+			defaultArgsMap: map[string]string{},
+			err:            "build var LICENSE_KEY is required: set it with --build-arg",
+		},
+		{
+			name:           "wrong case because of missing template variable",
+			input:          "{{ if eq .VARIANT \"gpu\" }}gpu{{ end }}",
+			output:         "",
+			argsMap:        map[string]string{},
+			defaultArgsMap: map[string]string{},
+			err:            "map has no entry for key \"VARIANT\"",
+		},
+		{
+			name:           "wrong case because of missing variable in template",
+			input:          "{{ if true }}{{ APP_VER }}{{ end }}",
+			output:         "",
+			argsMap:        map[string]string{},
+			defaultArgsMap: map[string]string{},
+			err:            "1:14: build var APP_VER is not defined through either --build-arg (--build-arg-file) or 'arguments' section",
+		},
+		{
+			name:           "wrong case because of unterminated conditional",
+			input:          "{{ if true }}{{ APP_VER }}",
+			output:         "",
+			argsMap:        map[string]string{"APP_VER": "1.0"},
+			defaultArgsMap: map[string]string{},
+			err:            "while parsing definition template",
 		},
 	}
 
@@ -210,6 +298,20 @@ func TestReader(t *testing.T) {
 	}
 }
 
+func TestReaderConsumedArgs(t *testing.T) {
+	input := "{{ if eq .VARIANT \"gpu\" }}{{ CUDA_VER }}{{ else }}{{ default \"CPU_FLAGS\" \"\" }}{{ end }}"
+	var consumedArgs []string
+	_, err := NewReader(
+		bytes.NewReader([]byte(input)),
+		map[string]string{"VARIANT": "cpu", "CUDA_VER": "12.2", "UNUSED": "1"},
+		map[string]string{},
+		&consumedArgs,
+	)
+	assert.NilError(t, err)
+	sort.Strings(consumedArgs)
+	assert.DeepEqual(t, consumedArgs, []string{"CUDA_VER", "VARIANT"})
+}
+
 func TestReadDefaults(t *testing.T) {
 	defFilePath := filepath.Join("..", "..", "..", "..", "test", "build-args", "single-stage-unit-test.def")
 	defFile, err := os.Open(defFilePath)
@@ -250,3 +352,174 @@ func TestReadDefaults(t *testing.T) {
 		"HOME":        "/root",
 	})
 }
+
+func TestReadBuildArgSources(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	environ := []string{
+		"SINGULARITY_BUILD_ARG_OS_VER=3.17",
+		"SINGULARITY_BUILD_ARG_AUTHOR=env",
+		"SINGULARITY_BUILD_ARG_FROM_ENV=1",
+		"SINGULARITY_BUILD_ARG_=invalid",
+		"HOME=/root",
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		argFile     string
+		wantArgs    map[string]string
+		wantSources map[string]Source
+		err         string
+	}{
+		{
+			name: "environment",
+			wantArgs: map[string]string{
+				"OS_VER":   "3.17",
+				"AUTHOR":   "env",
+				"FROM_ENV": "1",
+			},
+			wantSources: map[string]Source{
+				"OS_VER":   SourceEnv,
+				"AUTHOR":   SourceEnv,
+				"FROM_ENV": SourceEnv,
+			},
+		},
+		{
+			name:    "key value file",
+			args:    []string{"AUTHOR=flag"},
+			argFile: writeFile("args", "OS_VER=3.18\nAUTHOR=file\n"),
+			wantArgs: map[string]string{
+				"OS_VER":   "3.18",
+				"AUTHOR":   "flag",
+				"FROM_ENV": "1",
+			},
+			wantSources: map[string]Source{
+				"OS_VER":   SourceFile,
+				"AUTHOR":   SourceFlag,
+				"FROM_ENV": SourceEnv,
+			},
+		},
+		{
+			name:    "json file",
+			argFile: writeFile("args.json", `{"OS_VER": 3.18, "DEBUG": true, "JOBS": 16, "AUTHOR": "file"}`),
+			wantArgs: map[string]string{
+				"OS_VER":   "3.18",
+				"DEBUG":    "true",
+				"JOBS":     "16",
+				"AUTHOR":   "file",
+				"FROM_ENV": "1",
+			},
+			wantSources: map[string]Source{
+				"OS_VER":   SourceFile,
+				"DEBUG":    SourceFile,
+				"JOBS":     SourceFile,
+				"AUTHOR":   SourceFile,
+				"FROM_ENV": SourceEnv,
+			},
+		},
+		{
+			name:    "yaml file",
+			args:    []string{"JOBS=4"},
+			argFile: writeFile("args.yaml", "OS_VER: \"3.18\"\nJOBS: 16\n"),
+			wantArgs: map[string]string{
+				"OS_VER":   "3.18",
+				"JOBS":     "4",
+				"AUTHOR":   "env",
+				"FROM_ENV": "1",
+			},
+			wantSources: map[string]Source{
+				"OS_VER":   SourceFile,
+				"JOBS":     SourceFlag,
+				"AUTHOR":   SourceEnv,
+				"FROM_ENV": SourceEnv,
+			},
+		},
+		{
+			name:    "yaml file with list",
+			argFile: writeFile("list.yml", "PACKAGES:\n  - gcc\n  - make\n"),
+			err:     `value of "PACKAGES" is not a string, number or boolean`,
+		},
+		{
+			name:    "yaml file with null",
+			argFile: writeFile("null.yml", "PACKAGES:\n"),
+			err:     `missing value for "PACKAGES"`,
+		},
+		{
+			name:    "invalid json file",
+			argFile: writeFile("invalid.json", "OS_VER=3.18"),
+			err:     "error reading build arg file",
+		},
+		{
+			name: "invalid argument",
+			args: []string{"OS_VER"},
+			err:  "is not a key=value pair",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, sources, err := ReadBuildArgSources(tt.args, tt.argFile, environ)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, tt.wantArgs)
+			assert.DeepEqual(t, sources, tt.wantSources)
+		})
+	}
+}
+
+func TestWriteResolved(t *testing.T) {
+	defs := []types.Definition{
+		{
+			Header: map[string]string{"bootstrap": "docker", "stage": "devel"},
+			BuildData: types.Data{
+				Scripts: types.Scripts{
+					Arguments: types.Script{Script: "OS_VER=3.17\nJOBS=2\n"},
+				},
+			},
+		},
+		{
+			Header: map[string]string{"bootstrap": "docker"},
+		},
+	}
+	buildArgsMap := map[string]string{"OS_VER": "3.18"}
+	sources := map[string]Source{"OS_VER": SourceEnv}
+
+	var buf bytes.Buffer
+	assert.NilError(t, WriteResolved(&buf, defs, buildArgsMap, sources))
+	assert.Equal(t, buf.String(), ""+
+		"STAGE    NAME      VALUE    SOURCE\n"+
+		"devel    JOBS      2        arguments section\n"+
+		"devel    OS_VER    3.18     environment\n"+
+		"2        OS_VER    3.18     environment\n")
+
+	buf.Reset()
+	assert.NilError(t, WriteResolved(&buf, nil, buildArgsMap, sources))
+	assert.Equal(t, buf.String(), ""+
+		"STAGE    NAME      VALUE    SOURCE\n"+
+		"-        OS_VER    3.18     environment\n")
+}
+
+func TestReaderUndefinedArgPosition(t *testing.T) {
+	inputs := []string{
+		"Bootstrap: docker\nFrom: alpine\n\n%post\n  echo {{ APP_VER }}\n",
+		"Bootstrap: docker\nFrom: alpine\n{{ if true }}\n%post\n  echo {{ APP_VER }}\n{{ end }}\n",
+	}
+	for _, input := range inputs {
+		var consumedArgs []string
+		_, err := NewReader(bytes.NewReader([]byte(input)), map[string]string{}, map[string]string{}, &consumedArgs)
+		var undefinedErr *UndefinedArgError
+		assert.Assert(t, errors.As(err, &undefinedErr))
+		assert.DeepEqual(t, *undefinedErr, UndefinedArgError{Name: "APP_VER", Line: 5, Column: 8})
+	}
+}
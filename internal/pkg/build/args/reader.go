@@ -9,14 +9,44 @@ package args
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
+	"runtime"
+	"strings"
+	"text/template"
+	"text/template/parse"
 
 	"github.com/samber/lo"
 )
 
-var buildArgsRegexp = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+var (
+	buildArgsRegexp = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+
+	// templateRegexp matches the template actions that switch the evaluation of
+	// a def file to the template engine, rather than plain substitution of
+	// build args.
+	templateRegexp = regexp.MustCompile(`{{-?\s*(if|range|with|default|required|defined)\b`)
+
+	// argFuncs are the template functions taking the name of a build arg as
+	// first argument.
+	argFuncs = map[string]bool{
+		"arg":      true,
+		"default":  true,
+		"required": true,
+		"defined":  true,
+	}
+
+	// templateKeywords are the template actions matching buildArgsRegexp that
+	// aren't build args.
+	templateKeywords = map[string]bool{
+		"else":     true,
+		"end":      true,
+		"break":    true,
+		"continue": true,
+	}
+)
 
 // NewReader creates a io.Reader that will provide the contents of a def file
 // with build-args replacements applied. src is an io.Reader from which the
@@ -31,6 +61,10 @@ func NewReader(src io.Reader, buildArgsMap map[string]string, defaultArgsMap map
 		return nil, err
 	}
 
+	if templateRegexp.Match(srcBytes) {
+		return newTemplateReader(srcBytes, buildArgsMap, defaultArgsMap, consumedArgs)
+	}
+
 	matches := buildArgsRegexp.FindAllSubmatchIndex(srcBytes, -1)
 	mapOfConsumedArgs := make(map[string]bool)
 	var buf bytes.Buffer
@@ -44,7 +78,7 @@ func NewReader(src io.Reader, buildArgsMap map[string]string, defaultArgsMap map
 			val, ok = defaultArgsMap[argName]
 		}
 		if !ok {
-			return nil, fmt.Errorf("build var %s is not defined through either --build-arg (--build-arg-file) or 'arguments' section", argName)
+			return nil, newUndefinedArgError(argName, srcBytes, m[0])
 		}
 		bufWriter.Write([]byte(val))
 		mapOfConsumedArgs[argName] = true
@@ -58,3 +92,192 @@ func NewReader(src io.Reader, buildArgsMap map[string]string, defaultArgsMap map
 
 	return r, nil
 }
+
+// UndefinedArgError is returned when a build arg referenced in a def file is
+// not defined.
+type UndefinedArgError struct {
+	// Name is the name of the build arg.
+	Name string
+	// Line and Column are the 1-based position of the reference to the
+	// build arg in the def file, or 0 if unknown.
+	Line   int
+	Column int
+}
+
+func (e *UndefinedArgError) Error() string {
+	msg := fmt.Sprintf("build var %s is not defined through either --build-arg (--build-arg-file) or 'arguments' section", e.Name)
+	if e.Line == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, msg)
+}
+
+func undefinedArgError(name string) error {
+	return &UndefinedArgError{Name: name}
+}
+
+// newUndefinedArgError returns an UndefinedArgError for the build arg name,
+// referenced at offset in src.
+func newUndefinedArgError(name string, src []byte, offset int) error {
+	line := bytes.Count(src[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(src[:offset], '\n')
+	return &UndefinedArgError{Name: name, Line: line, Column: column}
+}
+
+// newTemplateReader evaluates src as a Go text/template, providing the build
+// args to conditionals and expressions. Plain {{ VAR }} substitutions keep
+// their meaning, and the template data also holds the build args, so that
+// {{ if eq .VAR "value" }} can be used, along with the architecture of the
+// build host as .arch. The following functions are available:
+//
+//	default "VAR" "value"    the value of VAR, or "value" if VAR is undefined
+//	required "VAR" ["msg"]   the value of VAR, failing with msg if VAR is undefined
+//	defined "VAR"            whether VAR is defined
+func newTemplateReader(src []byte, buildArgsMap map[string]string, defaultArgsMap map[string]string, consumedArgs *[]string) (io.Reader, error) {
+	mapOfConsumedArgs := make(map[string]bool)
+
+	lookup := func(name string) (string, bool) {
+		val, ok := buildArgsMap[name]
+		if !ok {
+			val, ok = defaultArgsMap[name]
+		}
+		if ok {
+			mapOfConsumedArgs[name] = true
+		}
+		return val, ok
+	}
+
+	funcs := template.FuncMap{
+		// arg is called for plain build arg substitutions, with the offset
+		// of the substitution in src
+		"arg": func(name string, offset int) (string, error) {
+			val, ok := lookup(name)
+			if !ok {
+				return "", newUndefinedArgError(name, src, offset)
+			}
+			return val, nil
+		},
+		"default": func(name, def string) string {
+			if val, ok := lookup(name); ok {
+				return val
+			}
+			return def
+		},
+		"required": func(name string, msg ...string) (string, error) {
+			val, ok := lookup(name)
+			if !ok {
+				if len(msg) > 0 {
+					return "", fmt.Errorf("build var %s is required: %s", name, strings.Join(msg, " "))
+				}
+				return "", undefinedArgError(name)
+			}
+			return val, nil
+		},
+		"defined": func(name string) bool {
+			_, ok := lookup(name)
+			return ok
+		},
+	}
+
+	// plain build arg substitutions are evaluated with the arg function
+	var text strings.Builder
+	i := 0
+	for _, m := range buildArgsRegexp.FindAllSubmatchIndex(src, -1) {
+		name := string(src[m[2]:m[3]])
+		if templateKeywords[name] {
+			continue
+		}
+		text.Write(src[i:m[0]])
+		fmt.Fprintf(&text, "{{ arg %q %d }}", name, m[0])
+		i = m[1]
+	}
+	text.Write(src[i:])
+
+	tmpl, err := template.New("definition").Option("missingkey=error").Funcs(funcs).Parse(text.String())
+	if err != nil {
+		return nil, fmt.Errorf("while parsing definition template: %w", err)
+	}
+
+	data := map[string]string{"arch": runtime.GOARCH}
+	for k, v := range defaultArgsMap {
+		data[k] = v
+	}
+	for k, v := range buildArgsMap {
+		data[k] = v
+	}
+	// build args referenced in a branch that isn't taken are consumed too
+	for name := range referencedArgs(tmpl.Root) {
+		lookup(name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		var undefinedErr *UndefinedArgError
+		if errors.As(err, &undefinedErr) {
+			return nil, undefinedErr
+		}
+		return nil, fmt.Errorf("while evaluating definition template: %w", err)
+	}
+
+	*consumedArgs = append(*consumedArgs, lo.Keys(mapOfConsumedArgs)...)
+
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// referencedArgs returns the names of the build args referenced from the
+// template node n, as fields of the template data, or with the template
+// functions.
+func referencedArgs(n parse.Node) map[string]bool {
+	names := make(map[string]bool)
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			// dot is only the template data outside of the range body
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) > 1 {
+				fn, isIdent := n.Args[0].(*parse.IdentifierNode)
+				name, isString := n.Args[1].(*parse.StringNode)
+				if isIdent && isString && argFuncs[fn.Ident] {
+					names[name.Text] = true
+				}
+			}
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.FieldNode:
+			names[n.Ident[0]] = true
+		}
+	}
+	walk(n)
+	return names
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package assemblers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/packer"
+	"github.com/sylabs/singularity/v4/internal/pkg/ociplatform"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const (
+	// defaultPath is the PATH set in the config of OCI-SIF images built from
+	// a base image without environment.
+	defaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	// runscriptPath is the path of the runscript inside the root filesystem.
+	runscriptPath = "/.singularity.d/runscript"
+	// labelsPath is the path of the labels inside the root filesystem.
+	labelsPath = "/.singularity.d/labels.json"
+)
+
+// OCISIFAssembler assembles an OCI-SIF image, holding the root filesystem as a
+// single squashfs layer.
+type OCISIFAssembler struct {
+	GzipFlag        bool
+	MksquashfsProcs uint
+	MksquashfsMem   string
+	MksquashfsPath  string
+}
+
+// Assemble creates an OCI-SIF image from a Bundle.
+func (a *OCISIFAssembler) Assemble(b *types.Bundle, path string) error {
+	sylog.Infof("Creating OCI-SIF file...")
+
+	cfg, err := imageConfig(b)
+	if err != nil {
+		return fmt.Errorf("while creating image config: %v", err)
+	}
+
+	platform := b.Opts.Platform
+	if platform.Architecture == "" {
+		dp, err := ociplatform.DefaultPlatform()
+		if err != nil {
+			return err
+		}
+		platform = *dp
+	}
+
+	s := packer.NewSquashfs()
+	s.MksquashfsPath = a.MksquashfsPath
+
+	f, err := os.CreateTemp(b.TmpDir, "squashfs-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary file for squashfs: %v", err)
+	}
+	fsPath := f.Name()
+	f.Close()
+	defer os.Remove(fsPath)
+
+	flags := mksquashfsFlags(a.GzipFlag, a.MksquashfsMem, a.MksquashfsProcs, b.Opts)
+	if err := s.Create([]string{b.RootfsPath}, fsPath, flags); err != nil {
+		return fmt.Errorf("while creating squashfs: %v", err)
+	}
+
+	// remove anything that may exist at the build destination at last moment
+	os.RemoveAll(path)
+
+	created := time.Now()
+	if b.Opts.Reproducible {
+		created = b.Opts.SourceDateEpoch
+	}
+
+	if err := ocisif.CreateImage(fsPath, path, platform, cfg, created, "oci-sif built from definition file"); err != nil {
+		return fmt.Errorf("while creating OCI-SIF: %v", err)
+	}
+
+	if b.Opts.EncryptionKeyInfo != nil {
+		if err := ocisif.EncryptOCISIF(path, *b.Opts.EncryptionKeyInfo, b.TmpDir); err != nil {
+			return fmt.Errorf("while encrypting OCI-SIF: %v", err)
+		}
+	}
+
+	// chown the oci-sif file to the calling user
+	if uid, gid, ok := changeOwner(); ok {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("while changing image ownership: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// imageConfig returns the OCI image config of the container in b. The config
+// of the OCI base image, if any, is extended with the container labels and
+// %environment variables, and runs the container runscript when the
+// definition provides one.
+func imageConfig(b *types.Bundle) (ggcrv1.Config, error) {
+	var cfg ggcrv1.Config
+	if data := b.JSONObjects[image.SIFDescOCIConfigJSON]; len(data) > 0 {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("while decoding base image config: %w", err)
+		}
+	}
+
+	if len(cfg.Env) == 0 {
+		cfg.Env = []string{defaultPath}
+	}
+
+	env, err := environmentVars(b.Recipe.ImageData.Environment.Script, cfg.Env)
+	if err != nil {
+		return cfg, fmt.Errorf("while translating %%environment: %w", err)
+	}
+	cfg.Env = env
+
+	data, err := os.ReadFile(filepath.Join(b.RootfsPath, labelsPath))
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, err
+	}
+	if len(data) > 0 {
+		labels := make(map[string]string)
+		if err := json.Unmarshal(data, &labels); err != nil {
+			return cfg, fmt.Errorf("while decoding labels: %w", err)
+		}
+		if cfg.Labels == nil {
+			cfg.Labels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			cfg.Labels[k] = v
+		}
+	}
+
+	hasCommand := len(cfg.Entrypoint) > 0 || len(cfg.Cmd) > 0
+	if b.Recipe.ImageData.Runscript.Script != "" || !hasCommand {
+		if _, err := os.Stat(filepath.Join(b.RootfsPath, runscriptPath)); err == nil {
+			cfg.Entrypoint = nil
+			cfg.Cmd = []string{runscriptPath}
+		}
+	}
+
+	return cfg, nil
+}
+
+// environmentVars returns env with the variables set by the %environment
+// script applied. The OCI runtime doesn't source the script, so it may only
+// hold variable assignments, optionally exported, whose values are expanded
+// against the variables already set.
+func environmentVars(script string, env []string) ([]string, error) {
+	if strings.TrimSpace(script) == "" {
+		return env, nil
+	}
+
+	f, err := syntax.NewParser().Parse(strings.NewReader(script), "environment")
+	if err != nil {
+		return nil, err
+	}
+
+	env = append([]string(nil), env...)
+	set := func(as *syntax.Assign) error {
+		if as.Append || as.Index != nil || as.Array != nil {
+			return fmt.Errorf("unsupported assignment of %s", as.Name.Value)
+		}
+		if as.Naked {
+			return nil
+		}
+		value := ""
+		if as.Value != nil {
+			cfg := &expand.Config{Env: expand.ListEnviron(env...)}
+			if value, err = expand.Literal(cfg, as.Value); err != nil {
+				return fmt.Errorf("while expanding %s: %w", as.Name.Value, err)
+			}
+		}
+		prefix := as.Name.Value + "="
+		for i, e := range env {
+			if strings.HasPrefix(e, prefix) {
+				env[i] = prefix + value
+				return nil
+			}
+		}
+		env = append(env, prefix+value)
+		return nil
+	}
+
+	for _, stmt := range f.Stmts {
+		if stmt.Negated || stmt.Background || stmt.Coprocess || len(stmt.Redirs) > 0 {
+			return nil, fmt.Errorf("line %d: only variable assignments are supported in OCI-SIF images", stmt.Pos().Line())
+		}
+		var assigns []*syntax.Assign
+		switch cmd := stmt.Cmd.(type) {
+		case *syntax.CallExpr:
+			if len(cmd.Args) == 0 {
+				assigns = cmd.Assigns
+			}
+		case *syntax.DeclClause:
+			if cmd.Variant.Value == "export" {
+				assigns = cmd.Args
+			}
+		}
+		if len(assigns) == 0 {
+			return nil, fmt.Errorf("line %d: only variable assignments are supported in OCI-SIF images", stmt.Pos().Line())
+		}
+		for _, as := range assigns {
+			if err := set(as); err != nil {
+				return nil, fmt.Errorf("line %d: %w", stmt.Pos().Line(), err)
+			}
+		}
+	}
+	return env, nil
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package assemblers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"gotest.tools/v3/assert"
+)
+
+func TestImageConfig(t *testing.T) {
+	newBundle := func(t *testing.T, runscript string, baseConfig string) *types.Bundle {
+		rootfs := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(rootfs, ".singularity.d"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(rootfs, runscriptPath), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(rootfs, labelsPath), []byte(`{"org.label-schema.schema-version": "1.0"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		b := &types.Bundle{
+			RootfsPath:  rootfs,
+			JSONObjects: map[string][]byte{},
+		}
+		b.Recipe.ImageData.Runscript.Script = runscript
+		if baseConfig != "" {
+			b.JSONObjects[image.SIFDescOCIConfigJSON] = []byte(baseConfig)
+		}
+		return b
+	}
+
+	t.Run("NoBase", func(t *testing.T) {
+		cfg, err := imageConfig(newBundle(t, "", ""))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cfg.Env, []string{defaultPath})
+		assert.DeepEqual(t, cfg.Cmd, []string{runscriptPath})
+		assert.Equal(t, cfg.Labels["org.label-schema.schema-version"], "1.0")
+	})
+
+	base := `{"Env": ["PATH=/bin", "LANG=C"], "Entrypoint": ["/entrypoint"], "Cmd": ["serve"], "Labels": {"maintainer": "me"}}`
+
+	t.Run("BaseCommand", func(t *testing.T) {
+		cfg, err := imageConfig(newBundle(t, "", base))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cfg.Env, []string{"PATH=/bin", "LANG=C"})
+		assert.DeepEqual(t, cfg.Entrypoint, []string{"/entrypoint"})
+		assert.DeepEqual(t, cfg.Cmd, []string{"serve"})
+		assert.Equal(t, cfg.Labels["maintainer"], "me")
+		assert.Equal(t, cfg.Labels["org.label-schema.schema-version"], "1.0")
+	})
+
+	t.Run("Runscript", func(t *testing.T) {
+		cfg, err := imageConfig(newBundle(t, "exec hello", base))
+		assert.NilError(t, err)
+		assert.Assert(t, cfg.Entrypoint == nil)
+		assert.DeepEqual(t, cfg.Cmd, []string{runscriptPath})
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		b := newBundle(t, "", base)
+		b.Recipe.ImageData.Environment.Script = "\n# comment\nexport PATH=/opt/bin:$PATH\nFOO='a b' BAR=\"${LANG}\"\nexport LANG\n"
+		cfg, err := imageConfig(b)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cfg.Env, []string{"PATH=/opt/bin:/bin", "LANG=C", "FOO=a b", "BAR=C"})
+	})
+
+	t.Run("EnvironmentUnsupported", func(t *testing.T) {
+		for _, script := range []string{
+			"source /etc/profile",
+			"export FOO=bar\nif true; then BAR=1; fi",
+			"FOO=bar echo",
+			"FOO+=bar",
+		} {
+			b := newBundle(t, "", base)
+			b.Recipe.ImageData.Environment.Script = script
+			_, err := imageConfig(b)
+			assert.ErrorContains(t, err, "%environment", script)
+		}
+	})
+}
+
+func TestReproducibleSIF(t *testing.T) {
+	epoch := time.Unix(1700000000, 0)
+	opts := types.Options{Reproducible: true, SourceDateEpoch: epoch}
+
+	flags := mksquashfsFlags(false, "", 0, opts)
+	assert.DeepEqual(t, flags[len(flags)-4:], []string{"-mkfs-time", "1700000000", "-all-time", "1700000000"})
+
+	squashfile := filepath.Join(t.TempDir(), "squashfs")
+	if err := os.WriteFile(squashfile, []byte("squashfs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b := &types.Bundle{
+		Recipe:      types.Definition{FullRaw: []byte("Bootstrap: scratch\n")},
+		JSONObjects: map[string][]byte{"b": []byte(`{}`), "a": []byte(`{}`)},
+		Opts:        opts,
+	}
+
+	// two images created from the same inputs are identical
+	var images [][]byte
+	var path string
+	for i := 0; i < 2; i++ {
+		path = filepath.Join(t.TempDir(), "image.sif")
+		if err := createSIF(path, b, squashfile, nil, "amd64"); err != nil {
+			t.Fatalf("while creating SIF: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, data)
+	}
+	assert.Assert(t, bytes.Equal(images[0], images[1]))
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatalf("while loading SIF: %v", err)
+	}
+	defer f.UnloadContainer()
+	assert.Equal(t, f.CreatedAt().Unix(), epoch.Unix())
+	assert.Equal(t, f.ID(), "00000000-0000-0000-0000-000000000000")
+}
+
+func TestWriteSortFile(t *testing.T) {
+	rootfs := t.TempDir()
+	for _, d := range []string{"usr/lib", "opt/conda/bin"} {
+		if err := os.MkdirAll(filepath.Join(rootfs, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"usr/lib/libpython.so", "opt/conda/bin/python"} {
+		if err := os.WriteFile(filepath.Join(rootfs, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("python", filepath.Join(rootfs, "opt/conda/bin/python3")); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := filepath.Join(t.TempDir(), "profile")
+	lines := "# hot files\n/opt/conda/bin/python\n\nusr/lib/libpython.so\n/opt/conda/bin/python3\n" +
+		"/missing\n/opt/conda/bin/python\n../../usr/lib\n"
+	if err := os.WriteFile(profile, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sortFile := filepath.Join(t.TempDir(), "sort")
+	assert.NilError(t, writeSortFile(rootfs, profile, sortFile))
+	got, err := os.ReadFile(sortFile)
+	assert.NilError(t, err)
+
+	want := filepath.Join(rootfs, "opt/conda/bin/python") + " 32767\n" +
+		filepath.Join(rootfs, "usr/lib/libpython.so") + " 32766\n" +
+		filepath.Join(rootfs, "usr/lib") + " 32765\n"
+	assert.Equal(t, string(got), want)
+}
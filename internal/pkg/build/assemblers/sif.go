@@ -6,13 +6,16 @@
 package assemblers
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/sylabs/sif/v2/pkg/sif"
@@ -104,7 +107,7 @@ func createSIF(path string, b *types.Bundle, squashfile string, encOpts *encrypt
 			syspartID := uint32(len(dis))
 			part, err := sif.NewDescriptorInput(sif.DataCryptoMessage, bytes.NewReader(data),
 				sif.OptLinkedID(syspartID),
-				sif.OptCryptoMessageMetadata(sif.FormatPEM, sif.MessageRSAOAEP),
+				sif.OptCryptoMessageMetadata(sif.FormatPEM, cryptkey.MessageType(encOpts.keyInfo)),
 			)
 			if err != nil {
 				return err
@@ -117,10 +120,19 @@ func createSIF(path string, b *types.Bundle, squashfile string, encOpts *encrypt
 	// remove anything that may exist at the build destination at last moment
 	os.RemoveAll(path)
 
-	f, err := sif.CreateContainerAtPath(path,
+	opts := []sif.CreateOpt{
 		sif.OptCreateWithLaunchScript("#!/usr/bin/env run-singularity\n"),
 		sif.OptCreateWithDescriptors(dis...),
-	)
+	}
+	// reproducible images have a nil ID, and the time of SourceDateEpoch
+	if b.Opts.Reproducible {
+		opts = append(opts,
+			sif.OptCreateDeterministic(),
+			sif.OptCreateWithTime(b.Opts.SourceDateEpoch),
+		)
+	}
+
+	f, err := sif.CreateContainerAtPath(path, opts...)
 	if err != nil {
 		return fmt.Errorf("while creating container: %w", err)
 	}
@@ -155,20 +167,14 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) error {
 	f.Close()
 	defer os.Remove(fsPath)
 
-	flags := []string{"-noappend"}
-	// build squashfs with all-root flag when building as a user
-	if syscall.Getuid() != 0 {
-		flags = append(flags, "-all-root")
-	}
-	// specify compression if needed
-	if a.GzipFlag {
-		flags = append(flags, "-comp", "gzip")
-	}
-	if a.MksquashfsMem != "" {
-		flags = append(flags, "-mem", a.MksquashfsMem)
-	}
-	if a.MksquashfsProcs != 0 {
-		flags = append(flags, "-processors", fmt.Sprint(a.MksquashfsProcs))
+	flags := mksquashfsFlags(a.GzipFlag, a.MksquashfsMem, a.MksquashfsProcs, b.Opts)
+	if b.Opts.AccessProfile != "" {
+		sortFile := filepath.Join(b.TmpDir, "squashfs-sort")
+		if err := writeSortFile(b.RootfsPath, b.Opts.AccessProfile, sortFile); err != nil {
+			return fmt.Errorf("while reading access profile: %v", err)
+		}
+		defer os.Remove(sortFile)
+		flags = append(flags, "-sort", sortFile)
 	}
 	arch := machine.ArchFromContainer(b.RootfsPath)
 	if arch == "" {
@@ -218,6 +224,96 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) error {
 	return nil
 }
 
+// mksquashfsFlags returns the mksquashfs flags used to create the root
+// filesystem of an image. For reproducible builds, the filesystem and all
+// its files have the time of opts.SourceDateEpoch.
+func mksquashfsFlags(gzip bool, mem string, procs uint, opts types.Options) []string {
+	flags := []string{"-noappend"}
+	// build squashfs with all-root flag when building as a user
+	if syscall.Getuid() != 0 {
+		flags = append(flags, "-all-root")
+	}
+	// specify compression if needed
+	if gzip {
+		flags = append(flags, "-comp", "gzip")
+	}
+	if mem != "" {
+		flags = append(flags, "-mem", mem)
+	}
+	if procs != 0 {
+		flags = append(flags, "-processors", fmt.Sprint(procs))
+	}
+	if opts.Reproducible {
+		epoch := fmt.Sprint(opts.SourceDateEpoch.Unix())
+		flags = append(flags, "-mkfs-time", epoch, "-all-time", epoch)
+	}
+	return flags
+}
+
+// maxSortPriority is the highest priority of a file in a mksquashfs sort file.
+// Files with a higher priority are stored first.
+const maxSortPriority = 32767
+
+// writeSortFile writes to path a mksquashfs sort file, which stores the files
+// and directories of rootfs listed in the access profile first, in the order
+// they are listed. Entries of the profile that are not found in rootfs are
+// ignored.
+func writeSortFile(rootfs, profile, path string) error {
+	in, err := os.Open(profile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	seen := make(map[string]bool)
+	priority := maxSortPriority
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// paths are resolved in rootfs, never outside of it
+		rel := strings.TrimPrefix(filepath.Clean("/"+line), "/")
+		if rel == "" || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+
+		// mksquashfs identifies the listed files by inode, so they are
+		// given with their full path in rootfs
+		full := filepath.Join(rootfs, rel)
+		fi, err := os.Lstat(full)
+		if err != nil || !(fi.Mode().IsRegular() || fi.IsDir()) {
+			sylog.Debugf("Ignoring %s from access profile: not a file or directory in the image", line)
+			continue
+		}
+		// sort file entries are separated from their priority by spaces
+		if strings.ContainsAny(full, " \t") {
+			sylog.Debugf("Ignoring %s from access profile: path contains spaces", line)
+			continue
+		}
+		if priority == 0 {
+			sylog.Warningf("Access profile lists more than %d paths, ignoring the remaining paths", maxSortPriority)
+			break
+		}
+		if _, err := fmt.Fprintf(out, "%s %d\n", full, priority); err != nil {
+			return err
+		}
+		priority--
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
 // changeOwner check the command being called with sudo with the environment
 // variable SUDO_COMMAND. Pattern match that for the singularity bin.
 func changeOwner() (int, int, bool) {
@@ -10,10 +10,12 @@ package build
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 
@@ -25,10 +27,10 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/build/apps"
 	"github.com/sylabs/singularity/v4/internal/pkg/build/args"
 	"github.com/sylabs/singularity/v4/internal/pkg/build/assemblers"
-	"github.com/sylabs/singularity/v4/internal/pkg/build/sources"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/image/packer"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/squashfs"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/tracing"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/v4/pkg/build/types"
 	"github.com/sylabs/singularity/v4/pkg/build/types/parser"
@@ -45,6 +47,8 @@ import (
 type Build struct {
 	// stages of the build
 	stages []stage
+	// images that files are copied from, keyed by URI
+	images map[string]*stage
 	// Conf contains cross stage build configuration.
 	Conf Config
 }
@@ -53,11 +57,17 @@ type Build struct {
 type Config struct {
 	// Dest is the location for container after build is complete.
 	Dest string
-	// Format is the format of built container, e.g. SIF, sandbox.
+	// Format is the format of built container, e.g. SIF, OCI-SIF, sandbox.
 	Format string
 	// NoCleanUp allows a user to prevent a bundle from being cleaned
 	// up after a failed build, useful for debugging.
 	NoCleanUp bool
+	// RunTests runs the test script of the last stage in the built
+	// container, rather than in its root filesystem before assembly.
+	RunTests bool
+	// TestReport is the path of a JUnit XML report written with the
+	// result of RunTests, if not empty.
+	TestReport string
 	// Opts for bundles.
 	Opts types.Options
 }
@@ -88,6 +98,19 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 	}
 	conf.Dest = dest
 
+	if len(conf.Opts.OnlyPaths) > 0 {
+		if conf.Format != "sandbox" {
+			return nil, fmt.Errorf("extracting only some paths of an image requires a sandbox build")
+		}
+		for _, d := range defs {
+			switch bs := d.Header["bootstrap"]; bs {
+			case "localimage", "library", "ocisif", "oras", "shub":
+			default:
+				return nil, fmt.Errorf("extracting only some paths of an image is not supported from a %q source", bs)
+			}
+		}
+	}
+
 	// always build a sandbox if updating an existing sandbox
 	if conf.Opts.Update {
 		conf.Format = "sandbox"
@@ -186,11 +209,16 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 		b.stages = append(b.stages, s)
 	}
 
+	b.images, err = newImageSources(defs, conf.Opts)
+	if err != nil {
+		return nil, err
+	}
+
 	// only need an assembler for last stage
 	switch conf.Format {
 	case "sandbox":
 		b.stages[lastStageIndex].a = &assemblers.SandboxAssembler{Copy: sandboxCopy}
-	case "sif":
+	case "sif", "oci-sif":
 		mksquashfsPath, err := squashfs.GetPath()
 		if err != nil {
 			return nil, fmt.Errorf("while searching for mksquashfs: %v", err)
@@ -208,6 +236,15 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 		if err != nil {
 			return nil, fmt.Errorf("while searching for mksquashfs mem limits: %v", err)
 		}
+		if conf.Format == "oci-sif" {
+			b.stages[lastStageIndex].a = &assemblers.OCISIFAssembler{
+				GzipFlag:        flag,
+				MksquashfsProcs: mksquashfsProcs,
+				MksquashfsMem:   mksquashfsMem,
+				MksquashfsPath:  mksquashfsPath,
+			}
+			break
+		}
 		b.stages[lastStageIndex].a = &assemblers.SIFAssembler{
 			GzipFlag:        flag,
 			MksquashfsProcs: mksquashfsProcs,
@@ -314,6 +351,9 @@ func (b Build) cleanUp() {
 		for _, s := range b.stages {
 			bundlePaths = append(bundlePaths, s.b.RootfsPath, s.b.TmpDir)
 		}
+		for _, s := range b.images {
+			bundlePaths = append(bundlePaths, s.b.RootfsPath, s.b.TmpDir)
+		}
 		sylog.Infof("Build performed with no clean up option, build bundle(s) located at: %v", bundlePaths)
 		return
 	}
@@ -325,6 +365,12 @@ func (b Build) cleanUp() {
 			sylog.Errorf("Could not remove bundle: %v", err)
 		}
 	}
+	for _, s := range b.images {
+		sylog.Debugf("Cleaning up %q and %q", s.b.RootfsPath, s.b.TmpDir)
+		if err := s.b.Remove(); err != nil {
+			sylog.Errorf("Could not remove bundle: %v", err)
+		}
+	}
 }
 
 // Full runs a standard build from start to finish.
@@ -374,56 +420,42 @@ func (b *Build) Full(ctx context.Context) error {
 	}
 	configData := buffer.Bytes()
 
-	// build each stage one after the other
-	for i, stage := range b.stages {
-		if err := stage.runHostScript("pre", stage.b.Recipe.BuildData.Pre); err != nil {
-			return err
-		}
-
-		// only update last stage if specified
-		update := stage.b.Opts.Update && !stage.b.Opts.Force && i == len(b.stages)-1
-		if update {
-			// updating, extract dest container to bundle
-			sylog.Infof("Building into existing container: %s", b.Conf.Dest)
-			p, err := sources.GetLocalPacker(ctx, b.Conf.Dest, stage.b)
-			if err != nil {
-				return err
-			}
-
-			_, err = p.Pack(ctx)
-			if err != nil {
-				return err
-			}
-		} else {
-			// regular build or force, start build from scratch
-			if b.Conf.Opts.ImgCache == nil {
-				return fmt.Errorf("undefined image cache")
-			}
-			if err := stage.c.Get(ctx, stage.b); err != nil {
-				return fmt.Errorf("conveyor failed to get: %v", err)
-			}
+	// retrieve the root filesystem of all stages and images concurrently,
+	// restoring stages from the build cache where possible, and running the
+	// %pre script of each stage before it is bootstrapped
+	b.setSnapshotKeys(ctx)
+	if err := b.resolve(ctx); err != nil {
+		return err
+	}
 
-			_, err := stage.c.Pack(ctx)
-			if err != nil {
-				return fmt.Errorf("packer failed to pack: %v", err)
-			}
-		}
+	// build configuration and stage files used to test the built container
+	var testConfigFile, testResolv, testHosts string
 
+	// build each stage one after the other
+	for i, stage := range b.stages {
 		// create apps in bundle
 		a := apps.New()
 		for k, v := range stage.b.Recipe.CustomData {
 			a.HandleSection(k, v)
 		}
 
-		a.HandleBundle(stage.b)
+		if err := a.HandleBundle(stage.b); err != nil {
+			return err
+		}
 		appPost, err := a.HandlePost(stage.b)
 		if err != nil {
 			return fmt.Errorf("unable to get app post information: %v", err)
 		}
 		stage.b.Recipe.BuildData.Post.Script += appPost
 
+		// sections already applied in a snapshot restored from the build cache
+		restored := ""
+		if stage.snap != nil {
+			restored = stage.snap.restored
+		}
+
 		// copy potential files from previous stage
-		if stage.b.RunSection("files") {
+		if stage.b.RunSection("files") && restored == "" {
 			if err := stage.copyFilesFrom(b); err != nil {
 				return fmt.Errorf("unable to copy files from stage to container fs: %v", err)
 			}
@@ -434,10 +466,11 @@ func (b *Build) Full(ctx context.Context) error {
 		}
 
 		// copy files from host
-		if stage.b.RunSection("files") {
+		if stage.b.RunSection("files") && restored == "" {
 			if err := stage.copyFiles(); err != nil {
 				return fmt.Errorf("unable to copy files from host to container fs: %v", err)
 			}
+			stage.saveSnapshot(snapshotFiles)
 		}
 
 		// create stage file for /etc/resolv.conf and /etc/hosts
@@ -462,9 +495,18 @@ func (b *Build) Full(ctx context.Context) error {
 		}
 		defer os.Remove(configFile)
 
-		if stage.b.Recipe.BuildData.Post.Script != "" {
-			if err := stage.runPostScript(configFile, sessionResolv, sessionHosts); err != nil {
-				return fmt.Errorf("while running engine: %v", err)
+		if restored != snapshotPost {
+			if stage.b.Recipe.BuildData.Post.Script != "" {
+				if err := stage.runPostScript(configFile, sessionResolv, sessionHosts); err != nil {
+					return fmt.Errorf("while running engine: %v", err)
+				}
+			}
+			stage.saveSnapshot(snapshotPost)
+		}
+
+		if stage.b.Opts.PrimeCache && i == len(b.stages)-1 {
+			if err := stage.primeCaches(configFile, sessionResolv, sessionHosts); err != nil {
+				sylog.Warningf("While generating /etc/ld.so.cache and PATH cache: %v", err)
 			}
 		}
 
@@ -473,6 +515,11 @@ func (b *Build) Full(ctx context.Context) error {
 			return fmt.Errorf("while inserting metadata to bundle: %v", err)
 		}
 
+		if b.Conf.RunTests && i == len(b.stages)-1 {
+			testConfigFile, testResolv, testHosts = configFile, sessionResolv, sessionHosts
+			continue
+		}
+
 		if err := stage.runTestScript(configFile, sessionResolv, sessionHosts); err != nil {
 			return fmt.Errorf("failed to execute %%test script: %v", err)
 		}
@@ -481,10 +528,27 @@ func (b *Build) Full(ctx context.Context) error {
 	syscall.Umask(oldumask)
 
 	sylog.Debugf("Calling assembler")
-	if err := b.stages[len(b.stages)-1].Assemble(b.Conf.Dest); err != nil {
+	_, span := tracing.Start(ctx, "assemble image")
+	err = b.stages[len(b.stages)-1].Assemble(b.Conf.Dest)
+	tracing.End(span, err)
+	if err != nil {
 		return err
 	}
 
+	if b.Conf.RunTests {
+		last := b.stages[len(b.stages)-1]
+		r := last.runImageTests(testConfigFile, testResolv, testHosts, b.Conf.Dest, b.Conf.Format == "oci-sif")
+		if b.Conf.TestReport != "" {
+			if err := writeJUnitFile(b.Conf.TestReport, r); err != nil {
+				return err
+			}
+			sylog.Infof("Test report written to %s", b.Conf.TestReport)
+		}
+		if r.err != nil {
+			return fmt.Errorf("tests of built container %s failed: %v", b.Conf.Dest, r.err)
+		}
+	}
+
 	sylog.Verbosef("Build complete: %s", b.Conf.Dest)
 	return nil
 }
@@ -516,8 +580,10 @@ func makeDef(spec string) (types.Definition, error) {
 	return d, nil
 }
 
-// MakeAllDefs gets a definition object from a spec
-func MakeAllDefs(spec string, buildArgsMap map[string]string) ([]types.Definition, error) {
+// MakeAllDefs gets a definition object from a spec. Build args supplied in
+// buildArgsMap that are not referenced by the definition are reported with a
+// warning, or an error when strictArgs is set.
+func MakeAllDefs(spec string, buildArgsMap map[string]string, strictArgs bool) ([]types.Definition, error) {
 	if ok, err := uri.IsValid(spec); ok && err == nil {
 		// URI passed as spec
 		d, err := types.NewDefinitionFromURI(spec)
@@ -546,18 +612,32 @@ func MakeAllDefs(spec string, buildArgsMap map[string]string) ([]types.Definitio
 
 	revisedDefs := make([]types.Definition, 0, nDefs)
 	var overallConsumedArgs []string
+	// offset of the current stage in the definition file, to report
+	// positions relative to the whole file
+	offset := 0
 	for _, def := range defsPreBuildArgs {
 		defaultArgsMap := args.ReadDefaults(def)
 
+		if i := bytes.Index(def.FullRaw[offset:], def.Raw); i >= 0 {
+			offset += i
+		}
+
 		reader, err := args.NewReader(
 			bytes.NewReader(def.Raw),
 			buildArgsMap,
 			defaultArgsMap,
 			&overallConsumedArgs,
 		)
+		var undefinedErr *args.UndefinedArgError
+		if errors.As(err, &undefinedErr) && undefinedErr.Line > 0 {
+			e := *undefinedErr
+			e.Line += bytes.Count(def.FullRaw[:offset], []byte("\n"))
+			return nil, fmt.Errorf("%s:%w", spec, &e)
+		}
 		if err != nil {
 			return nil, err
 		}
+		offset += len(def.Raw)
 
 		revisedDef, err := parser.ParseDefinitionFile(reader)
 		if err != nil {
@@ -582,6 +662,10 @@ func MakeAllDefs(spec string, buildArgsMap map[string]string) ([]types.Definitio
 
 	unusedArgs, _ := lo.Difference(lo.Keys(buildArgsMap), lo.Uniq(overallConsumedArgs))
 	if len(unusedArgs) > 0 {
+		sort.Strings(unusedArgs)
+		if strictArgs {
+			return nil, fmt.Errorf("build variables not used in %s: %s", spec, strings.Join(unusedArgs, ", "))
+		}
 		sylog.Warningf("Unused build variables: %s", strings.Join(unusedArgs, ", "))
 	}
 
@@ -1,10 +1,14 @@
 package build
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/sylabs/singularity/v4/internal/pkg/build/args"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
 	"gotest.tools/v3/assert"
 )
 
@@ -15,6 +19,7 @@ func TestProcessDefsSingleDef(t *testing.T) {
 			"OS_VER": "1",
 			"AUTHOR": "jason",
 		},
+		false,
 	)
 
 	assert.NilError(t, err)
@@ -31,6 +36,7 @@ func TestProcessDefsMultipleDef(t *testing.T) {
 			"DEVEL_IMAGE": "golang:1.12.3-alpine3.9",
 			"FINAL_IMAGE": "alpine:3.9",
 		},
+		false,
 	)
 
 	assert.NilError(t, err)
@@ -44,3 +50,105 @@ func TestProcessDefsMultipleDef(t *testing.T) {
 	rt = strings.Contains(d[1].BuildData.Files[0].Files[0].Src, "/root/hello")
 	assert.Equal(t, rt, true)
 }
+
+func TestNewImageSources(t *testing.T) {
+	defs := []types.Definition{
+		{
+			Header: map[string]string{"bootstrap": "docker", "from": "alpine", "stage": "one"},
+		},
+		{
+			Header: map[string]string{"bootstrap": "docker", "from": "alpine", "stage": "two"},
+			BuildData: types.Data{
+				Files: []types.Files{
+					{Args: "from one"},
+					{Args: "from docker://busybox"},
+					{Args: "from ./tools.oci.sif"},
+				},
+			},
+		},
+		{
+			Header: map[string]string{"bootstrap": "docker", "from": "alpine"},
+			BuildData: types.Data{
+				Files: []types.Files{
+					{Args: "from docker://busybox"},
+				},
+			},
+		},
+	}
+
+	images, err := newImageSources(defs, types.Options{TmpDir: t.TempDir(), Update: true})
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		for _, s := range images {
+			s.b.Remove()
+		}
+	})
+
+	assert.Equal(t, len(images), 2)
+	assert.Equal(t, images["docker://busybox"].b.Recipe.Header["from"], "busybox")
+	assert.Equal(t, images["localimage://./tools.oci.sif"].b.Recipe.Header["bootstrap"], "localimage")
+	for _, s := range images {
+		assert.Assert(t, !s.b.Opts.Update)
+	}
+
+	_, err = newImageSources([]types.Definition{
+		{
+			BuildData: types.Data{
+				Files: []types.Files{{Args: "from unknown://image"}},
+			},
+		},
+	}, types.Options{TmpDir: t.TempDir()})
+	assert.ErrorContains(t, err, "invalid build source")
+}
+
+func TestProcessDefsConditional(t *testing.T) {
+	defPath := filepath.Join("..", "..", "..", "test", "build-args", "conditional-unit-test.def")
+
+	d, err := MakeAllDefs(defPath, map[string]string{}, false)
+	assert.NilError(t, err)
+	assert.Equal(t, d[0].Header["from"], "ubuntu:22.04")
+	assert.Equal(t, strings.Contains(d[0].BuildData.Post.Script, "CUDA"), false)
+	assert.Equal(t, strings.Contains(d[0].BuildData.Post.Script, `echo "Built for any"`), true)
+
+	d, err = MakeAllDefs(defPath, map[string]string{
+		"VARIANT":   "gpu",
+		"ARCH_NAME": "amd64",
+	}, false)
+	assert.NilError(t, err)
+	assert.Equal(t, d[0].Header["from"], "nvidia/cuda:12.2.0-base-ubuntu22.04")
+	assert.Equal(t, strings.Contains(d[0].BuildData.Post.Script, `echo "Built for CUDA 12.2.0"`), true)
+	assert.Equal(t, strings.Contains(d[0].BuildData.Post.Script, `echo "Built for amd64"`), true)
+}
+
+func TestProcessDefsBuildArgErrors(t *testing.T) {
+	defPath := filepath.Join(t.TempDir(), "test.def")
+	def := `Bootstrap: docker
+From: alpine
+Stage: one
+
+%post
+  echo {{ FIRST }}
+
+Bootstrap: docker
+From: alpine
+
+%post
+  echo {{ FIRST }} {{ SECOND }}
+`
+	assert.NilError(t, os.WriteFile(defPath, []byte(def), 0o644))
+
+	_, err := MakeAllDefs(defPath, map[string]string{}, false)
+	assert.ErrorContains(t, err, defPath+":6:8: build var FIRST is not defined")
+
+	_, err = MakeAllDefs(defPath, map[string]string{"FIRST": "1"}, false)
+	assert.ErrorContains(t, err, defPath+":12:20: build var SECOND is not defined")
+	var undefinedErr *args.UndefinedArgError
+	assert.Assert(t, errors.As(err, &undefinedErr))
+	assert.Equal(t, undefinedErr.Name, "SECOND")
+
+	buildArgs := map[string]string{"FIRST": "1", "SECOND": "2", "THIRD": "3", "FOURTH": "4"}
+	_, err = MakeAllDefs(defPath, buildArgs, false)
+	assert.NilError(t, err)
+	_, err = MakeAllDefs(defPath, buildArgs, true)
+	assert.ErrorContains(t, err, "build variables not used in "+defPath+": FOURTH, THIRD")
+}
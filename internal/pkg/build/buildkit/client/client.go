@@ -23,7 +23,9 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -45,6 +47,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/ociplatform"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential/ociauth"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/cryptkey"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -74,17 +77,86 @@ type Opts struct {
 	ContextDir string
 	// Disable buildkitd's internal caching mechanism
 	DisableCache bool
+	// Optional key material with which to encrypt the resulting OCI-SIF
+	KeyInfo *cryptkey.KeyInfo
+	// Optional loopback host:port address at which our own buildkitd daemon
+	// will serve metrics and healthz endpoints
+	MetricsAddr string
+	// Optional address of a remote buildkitd daemon performing the build, in
+	// place of a local daemon (e.g. tcp://builder.example.com:1234)
+	RemoteAddr string
+	// Optional TLS configuration used to connect to the remote buildkitd
+	// daemon
+	TLS TLSOpts
+	// Optional SOURCE_DATE_EPOCH build arg, in seconds, from which BuildKit
+	// sets the timestamps of the image config and history
+	SourceDateEpoch string
+}
+
+// TLSOpts holds the TLS configuration used to connect to a remote buildkitd
+// daemon. The client certificate and key are only required by daemons
+// enforcing mutual TLS authentication.
+type TLSOpts struct {
+	// Path to the CA certificate used to verify the daemon certificate
+	CACert string
+	// Path to the client certificate
+	Cert string
+	// Path to the client key
+	Key string
+	// Server name used to verify the daemon certificate, defaulting to the
+	// host name of the daemon address
+	ServerName string
+}
+
+// enabled returns whether TLS is configured.
+func (t TLSOpts) enabled() bool {
+	return t.CACert != "" || t.Cert != "" || t.Key != ""
+}
+
+// clientOpts returns the options used to connect to the buildkitd daemon at
+// addr.
+func clientOpts(opts *Opts, addr string) ([]client.ClientOpt, error) {
+	clientOpts := []client.ClientOpt{client.WithFailFast()}
+	if opts.RemoteAddr == "" || !opts.TLS.enabled() {
+		return clientOpts, nil
+	}
+
+	if (opts.TLS.Cert == "") != (opts.TLS.Key == "") {
+		return nil, errors.New("both a client certificate and key are required for mutual TLS authentication")
+	}
+	serverName := opts.TLS.ServerName
+	if serverName == "" {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing buildkitd address %q: %w", addr, err)
+		}
+		serverName = u.Hostname()
+	}
+	if opts.TLS.CACert != "" {
+		clientOpts = append(clientOpts, client.WithServerConfig(serverName, opts.TLS.CACert))
+	} else {
+		clientOpts = append(clientOpts, client.WithServerConfigSystem(serverName))
+	}
+	if opts.TLS.Cert != "" {
+		clientOpts = append(clientOpts, client.WithCredentials(opts.TLS.Cert, opts.TLS.Key))
+	}
+	return clientOpts, nil
 }
 
 func Run(ctx context.Context, opts *Opts, dest, spec string) {
 	sylog.Debugf("Requested build architecture is: %q", opts.ReqArch)
-	bkSocket := os.Getenv("BUILDKIT_HOST")
-	if bkSocket == "" {
-		bkSocket = bkDefaultSocket
-	}
-	listenSocket := ensureBuildkitd(ctx, opts, bkSocket)
-	if listenSocket == "" {
-		sylog.Fatalf("Failed to launch buildkitd daemon within specified timeout (%v).", bkLaunchTimeout)
+	listenSocket := opts.RemoteAddr
+	if listenSocket != "" {
+		sylog.Infof("Building with remote buildkitd daemon at %q.", listenSocket)
+	} else {
+		bkSocket := os.Getenv("BUILDKIT_HOST")
+		if bkSocket == "" {
+			bkSocket = bkDefaultSocket
+		}
+		listenSocket = ensureBuildkitd(ctx, opts, bkSocket)
+		if listenSocket == "" {
+			sylog.Fatalf("Failed to launch buildkitd daemon within specified timeout (%v).", bkLaunchTimeout)
+		}
 	}
 
 	tarFile, err := os.CreateTemp("", "singularity-buildkit-tar-")
@@ -118,6 +190,13 @@ func Run(ctx context.Context, opts *Opts, dest, spec string) {
 	if _, err := ocisif.PullOCISIF(ctx, nil, dest, "oci-archive:"+tarFile.Name(), pullOpts); err != nil {
 		sylog.Fatalf("While converting OCI tar image to OCI-SIF: %v", err)
 	}
+	if opts.KeyInfo != nil {
+		if err := ocisif.EncryptOCISIF(dest, *opts.KeyInfo, ""); err != nil {
+			// Don't leave an unencrypted image where an encrypted one was requested.
+			os.Remove(dest)
+			sylog.Fatalf("While encrypting OCI-SIF: %v", err)
+		}
+	}
 }
 
 // ensureBuildkitd checks if a buildkitd daemon is already running, and if not,
@@ -133,7 +212,8 @@ func ensureBuildkitd(ctx context.Context, opts *Opts, bkSocket string) string {
 	socketChan := make(chan string, 1)
 	go func() {
 		daemonOpts := &bkdaemon.Opts{
-			ReqArch: opts.ReqArch,
+			ReqArch:     opts.ReqArch,
+			MetricsAddr: opts.MetricsAddr,
 		}
 		if err := bkdaemon.Run(ctx, daemonOpts, socketChan); err != nil {
 			sylog.Fatalf("buildkitd returned error: %v", err)
@@ -151,7 +231,8 @@ func ensureBuildkitd(ctx context.Context, opts *Opts, bkSocket string) string {
 // buildkitd running. The bkSocket argument is the address at which to look for
 // an already-running daemon.
 func isBuildkitdRunning(ctx context.Context, opts *Opts, bkSocket string) bool {
-	if opts.ReqArch != "" {
+	// Metrics can only be served by a daemon that we spawn ourselves.
+	if opts.ReqArch != "" || opts.MetricsAddr != "" {
 		return false
 	}
 	c, err := client.New(ctx, bkSocket, client.WithFailFast())
@@ -184,10 +265,15 @@ func isBuildkitdRunning(ctx context.Context, opts *Opts, bkSocket string) bool {
 }
 
 func buildImage(ctx context.Context, opts *Opts, tarFile *os.File, listenSocket, spec string, clientsideFrontend bool) error {
-	c, err := client.New(ctx, listenSocket, client.WithFailFast())
+	clientOpts, err := clientOpts(opts, listenSocket)
 	if err != nil {
 		return err
 	}
+	c, err := client.New(ctx, listenSocket, clientOpts...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
 
 	buildDir, err := os.MkdirTemp("", "singularity-buildkit-builddir-")
 	if err != nil {
@@ -272,12 +358,27 @@ func newSolveOpt(_ context.Context, opts *Opts, w io.WriteCloser, buildDir, spec
 		frontendAttrs["no-cache"] = ""
 	}
 
+	// a remote daemon builds for its own platform unless told otherwise
+	if opts.RemoteAddr != "" && opts.ReqArch != "" {
+		platform, err := ociplatform.PlatformFromArch(opts.ReqArch)
+		if err != nil {
+			return nil, err
+		}
+		frontendAttrs["platform"] = platform.OS + "/" + platform.Architecture
+		if platform.Variant != "" {
+			frontendAttrs["platform"] += "/" + platform.Variant
+		}
+	}
+
 	attachable := []session.Attachable{bkdaemon.NewAuthProvider(opts.AuthConf, ociauth.ChooseAuthFile(opts.ReqAuthFile))}
 
 	buildArgsMap, err := args.ReadBuildArgs(opts.BuildVarArgs, opts.BuildVarArgFile)
 	if err != nil {
 		return nil, err
 	}
+	if opts.SourceDateEpoch != "" {
+		frontendAttrs["build-arg:SOURCE_DATE_EPOCH"] = opts.SourceDateEpoch
+	}
 	for k, v := range buildArgsMap {
 		frontendAttrs["build-arg:"+k] = v
 	}
@@ -80,6 +80,9 @@ import (
 type Opts struct {
 	// Requested build architecture
 	ReqArch string
+	// Optional loopback host:port address at which to serve metrics and healthz
+	// endpoints
+	MetricsAddr string
 }
 
 type workerInitializerOpt struct {
@@ -128,7 +131,13 @@ func Run(ctx context.Context, opts *Opts, socketChan chan<- string) error {
 
 	sylog.Infof("cfg.Root for buildkitd: %s", cfg.Root)
 
-	server := grpc.NewServer()
+	var m *metrics
+	var serverOpts []grpc.ServerOption
+	if opts.MetricsAddr != "" {
+		m = newMetrics()
+		serverOpts = append(serverOpts, grpc.UnaryInterceptor(m.unaryInterceptor))
+	}
+	server := grpc.NewServer(serverOpts...)
 
 	// relative path does not work with nightlyone/lockfile
 	root, err := filepath.Abs(cfg.Root)
@@ -157,12 +166,18 @@ func Run(ctx context.Context, opts *Opts, socketChan chan<- string) error {
 		os.RemoveAll(lockPath)
 	}()
 
-	controller, err := newController(ctx, &cfg)
+	controller, err := newController(ctx, &cfg, m)
 	if err != nil {
 		return err
 	}
 	defer controller.Close()
 
+	if m != nil {
+		if err := serveMetrics(ctx, m, opts.MetricsAddr); err != nil {
+			return err
+		}
+	}
+
 	controller.Register(server)
 	reflection.Register(server)
 
@@ -473,7 +488,7 @@ func getListener(addr string, uid, gid int, tlsConfig *tls.Config) (net.Listener
 	}
 }
 
-func newController(ctx context.Context, cfg *config.Config) (*control.Controller, error) {
+func newController(ctx context.Context, cfg *config.Config, m *metrics) (*control.Controller, error) {
 	sessionManager, err := session.NewManager()
 	if err != nil {
 		return nil, err
@@ -482,10 +497,13 @@ func newController(ctx context.Context, cfg *config.Config) (*control.Controller
 	wc, err := newWorkerController(ctx, workerInitializerOpt{
 		config:         cfg,
 		sessionManager: sessionManager,
-	})
+	}, m)
 	if err != nil {
 		return nil, err
 	}
+	if m != nil {
+		m.wc = wc
+	}
 	frontends := map[string]frontend.Frontend{}
 	frontends["dockerfile.v0"] = forwarder.NewGatewayForwarder(wc, dockerfile.Build)
 	frontends["gateway.v0"] = gateway.NewGatewayFrontend(wc)
@@ -531,7 +549,7 @@ func resolverFunc(cfg *config.Config) docker.RegistryHosts {
 	return resolver.NewRegistryConfig(cfg.Registries)
 }
 
-func newWorkerController(ctx context.Context, wiOpt workerInitializerOpt) (*worker.Controller, error) {
+func newWorkerController(ctx context.Context, wiOpt workerInitializerOpt, m *metrics) (*worker.Controller, error) {
 	wc := &worker.Controller{}
 	nWorkers := 0
 	for _, wi := range workerInitializers {
@@ -542,6 +560,9 @@ func newWorkerController(ctx context.Context, wiOpt workerInitializerOpt) (*work
 		for _, w := range ws {
 			p := w.Platforms(false)
 			archutil.WarnIfUnsupported(p)
+			if m != nil {
+				w = m.wrapWorker(w)
+			}
 			if err = wc.Add(w); err != nil {
 				return nil, err
 			}
@@ -0,0 +1,245 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/worker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"google.golang.org/grpc"
+)
+
+// solveMethod is the full name of the gRPC method used to run a build.
+const solveMethod = "/moby.buildkit.v1.Control/Solve"
+
+// metrics collects the state of the daemon, which is reported on the metrics
+// and healthz endpoints of its HTTP listener.
+type metrics struct {
+	registry *prometheus.Registry
+
+	solvesInProgress prometheus.Gauge
+	solvesTotal      prometheus.Counter
+	gcRuns           prometheus.Counter
+	gcRecords        prometheus.Counter
+	gcBytes          prometheus.Counter
+
+	cacheBytes   *prometheus.Desc
+	cacheRecords *prometheus.Desc
+	workers      *prometheus.Desc
+
+	// wc is set once the workers of the daemon have been initialized.
+	wc *worker.Controller
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		solvesInProgress: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "buildkitd_solves_in_progress",
+			Help: "Number of builds currently queued or running.",
+		}),
+		solvesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "buildkitd_solves_total",
+			Help: "Total number of builds requested.",
+		}),
+		gcRuns: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "buildkitd_gc_runs_total",
+			Help: "Total number of cache garbage collection and prune runs.",
+		}),
+		gcRecords: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "buildkitd_gc_records_total",
+			Help: "Total number of cache records removed by garbage collection and prune runs.",
+		}),
+		gcBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "buildkitd_gc_bytes_total",
+			Help: "Total size, in bytes, of cache records removed by garbage collection and prune runs.",
+		}),
+		cacheBytes: prometheus.NewDesc(
+			"buildkitd_cache_bytes",
+			"Total size, in bytes, of the build cache.",
+			nil, nil,
+		),
+		cacheRecords: prometheus.NewDesc(
+			"buildkitd_cache_records",
+			"Number of records in the build cache.",
+			nil, nil,
+		),
+		workers: prometheus.NewDesc(
+			"buildkitd_workers",
+			"Number of workers available to run builds.",
+			nil, nil,
+		),
+	}
+	m.registry.MustRegister(
+		m.solvesInProgress,
+		m.solvesTotal,
+		m.gcRuns,
+		m.gcRecords,
+		m.gcBytes,
+		m,
+		prometheus.NewGoCollector(),
+	)
+	return m
+}
+
+// Describe implements prometheus.Collector for the metrics that are computed
+// from the workers when they are scraped.
+func (m *metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.cacheBytes
+	ch <- m.cacheRecords
+	ch <- m.workers
+}
+
+// Collect implements prometheus.Collector for the metrics that are computed
+// from the workers when they are scraped.
+func (m *metrics) Collect(ch chan<- prometheus.Metric) {
+	workers, err := m.listWorkers()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(m.workers, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(m.workers, prometheus.GaugeValue, float64(len(workers)))
+
+	var size, records int64
+	for _, w := range workers {
+		du, err := w.DiskUsage(context.TODO(), client.DiskUsageInfo{})
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(m.cacheBytes, err)
+			return
+		}
+		for _, r := range du {
+			size += r.Size
+			records++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(m.cacheBytes, prometheus.GaugeValue, float64(size))
+	ch <- prometheus.MustNewConstMetric(m.cacheRecords, prometheus.GaugeValue, float64(records))
+}
+
+func (m *metrics) listWorkers() ([]worker.Worker, error) {
+	if m.wc == nil {
+		return nil, nil
+	}
+	return m.wc.List()
+}
+
+// unaryInterceptor tracks the builds run by the daemon.
+func (m *metrics) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if info.FullMethod != solveMethod {
+		return handler(ctx, req)
+	}
+	m.solvesTotal.Inc()
+	m.solvesInProgress.Inc()
+	defer m.solvesInProgress.Dec()
+	return handler(ctx, req)
+}
+
+// healthz responds with 200 OK if the daemon has a worker available to run
+// builds, and 503 Service Unavailable otherwise.
+func (m *metrics) healthz(w http.ResponseWriter, _ *http.Request) {
+	workers, err := m.listWorkers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if len(workers) == 0 {
+		http.Error(w, "no workers available", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// handler returns the HTTP handler serving the metrics and healthz endpoints.
+func (m *metrics) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", m.healthz)
+	return mux
+}
+
+// wrapWorker returns w, with its Prune method instrumented to record garbage
+// collection activity. Prune is called both by the periodic garbage
+// collection of the controller, and by explicit prune requests.
+func (m *metrics) wrapWorker(w worker.Worker) worker.Worker {
+	return &metricsWorker{Worker: w, m: m}
+}
+
+type metricsWorker struct {
+	worker.Worker
+	m *metrics
+}
+
+func (w *metricsWorker) Prune(ctx context.Context, ch chan client.UsageInfo, opt ...client.PruneInfo) error {
+	w.m.gcRuns.Inc()
+
+	pruned := make(chan client.UsageInfo)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ui := range pruned {
+			w.m.gcRecords.Inc()
+			w.m.gcBytes.Add(float64(ui.Size))
+			if ch != nil {
+				ch <- ui
+			}
+		}
+	}()
+
+	err := w.Worker.Prune(ctx, pruned, opt...)
+	close(pruned)
+	<-done
+	return err
+}
+
+// listenMetrics returns a listener for the metrics endpoint at addr, which
+// must be a host:port address on a loopback interface.
+func listenMetrics(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics address %q: %w", addr, err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("metrics address %q is not a loopback address", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serveMetrics serves the metrics and healthz endpoints at addr, until ctx is
+// canceled.
+func serveMetrics(ctx context.Context, m *metrics, addr string) error {
+	l, err := listenMetrics(addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:           m.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		sylog.Infof("serving buildkitd metrics on http://%s/metrics", l.Addr())
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			sylog.Warningf("buildkitd metrics server: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package daemon
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+)
+
+func TestListenMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		wantErr bool
+	}{
+		{"IPv4Loopback", "127.0.0.1:0", false},
+		{"Localhost", "localhost:0", false},
+		{"NoPort", "127.0.0.1", true},
+		{"AllInterfaces", ":0", true},
+		{"Unspecified", "0.0.0.0:0", true},
+		{"Hostname", "example.com:0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := listenMetrics(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listenMetrics(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			}
+			if l != nil {
+				l.Close()
+			}
+		})
+	}
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	m := newMetrics()
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		if got := testutil.ToFloat64(m.solvesInProgress); got != 1 {
+			t.Errorf("solves in progress during solve = %v, want 1", got)
+		}
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: solveMethod}
+	if _, err := m.unaryInterceptor(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	other := func(ctx context.Context, req any) (any, error) { return nil, nil }
+	info = &grpc.UnaryServerInfo{FullMethod: "/moby.buildkit.v1.Control/Info"}
+	if _, err := m.unaryInterceptor(context.Background(), nil, info, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(m.solvesInProgress); got != 0 {
+		t.Errorf("solves in progress = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.solvesTotal); got != 1 {
+		t.Errorf("solves total = %v, want 1", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(newMetrics().handler())
+	defer srv.Close()
+
+	// Without any workers, the daemon is not healthy.
+	resp, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("healthz status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	resp, err = http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("metrics status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"buildkitd_solves_in_progress", "buildkitd_gc_runs_total", "buildkitd_workers", "buildkitd_cache_bytes"} {
+		if !strings.Contains(string(b), name) {
+			t.Errorf("metrics output does not contain %s", name)
+		}
+	}
+}
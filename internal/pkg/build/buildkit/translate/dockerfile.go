@@ -0,0 +1,247 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package translate converts definition files to Dockerfiles, so that they
+// can be built by a BuildKit daemon.
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// runscriptName is the name given to the runscript, as $0, when it is run as
+// the image entrypoint.
+const runscriptName = "runscript"
+
+// envRegexp matches variable assignments in an %environment section.
+var envRegexp = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// Dockerfile writes a Dockerfile equivalent to the build stages defs to w.
+// Build args must already have been substituted in defs. Paths of files
+// copied from the host are made relative to contextDir, which will be the
+// build context.
+//
+// Only definitions bootstrapping from docker or scratch, and holding %files,
+// %post, %environment, %labels, %runscript and %help sections, can be
+// translated. An error is returned for any other definition.
+func Dockerfile(w io.Writer, defs []types.Definition, contextDir string) error {
+	stages := make(map[string]string)
+	for i, d := range defs {
+		if name := d.Header["stage"]; name != "" {
+			stages[name] = stageName(name)
+		}
+
+		if err := writeStage(w, d, stages, contextDir); err != nil {
+			if name := d.Header["stage"]; name != "" {
+				return fmt.Errorf("stage %s: %w", name, err)
+			}
+			return fmt.Errorf("stage %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// writeStage writes the Dockerfile instructions of the build stage d to w.
+// stages maps the names of the build stages to their Dockerfile names.
+func writeStage(w io.Writer, d types.Definition, stages map[string]string, contextDir string) error {
+	if err := checkSections(d); err != nil {
+		return err
+	}
+
+	from, err := baseImage(d.Header)
+	if err != nil {
+		return err
+	}
+	if name := d.Header["stage"]; name != "" {
+		fmt.Fprintf(w, "FROM %s AS %s\n", from, stages[name])
+	} else {
+		fmt.Fprintf(w, "FROM %s\n", from)
+	}
+
+	for _, f := range d.BuildData.Files {
+		if err := writeFiles(w, f, stages, contextDir); err != nil {
+			return err
+		}
+	}
+
+	if d.BuildData.Post.Script != "" {
+		args, err := scriptArgs(d.BuildData.Post, "/bin/sh", "-ex")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "RUN %s\n", execForm(args))
+	}
+
+	if err := writeEnvironment(w, d.ImageData.Environment.Script); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(d.ImageData.Labels))
+	for k := range d.ImageData.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "LABEL %q=%q\n", k, d.ImageData.Labels[k])
+	}
+
+	if d.ImageData.Runscript.Script != "" {
+		args, err := scriptArgs(d.ImageData.Runscript, "/bin/sh")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "ENTRYPOINT %s\n", execForm(append(args, runscriptName)))
+	}
+
+	fmt.Fprintln(w)
+	return nil
+}
+
+// checkSections returns an error if d holds sections that can't be translated.
+func checkSections(d types.Definition) error {
+	unsupported := map[string]string{
+		"%pre":         d.BuildData.Pre.Script,
+		"%setup":       d.BuildData.Setup.Script,
+		"%test":        d.BuildData.Test.Script + d.ImageData.Test.Script,
+		"%startscript": d.ImageData.Startscript.Script,
+	}
+	for _, section := range []string{"%pre", "%setup", "%test", "%startscript"} {
+		if strings.TrimSpace(unsupported[section]) != "" {
+			return fmt.Errorf("%s section cannot be translated to a Dockerfile", section)
+		}
+	}
+	if len(d.AppOrder) > 0 {
+		return fmt.Errorf("SCIF app sections cannot be translated to a Dockerfile")
+	}
+	if strings.TrimSpace(d.ImageData.Help.Script) != "" {
+		sylog.Warningf("%%help section is not supported in builds with a BuildKit daemon, ignoring it")
+	}
+	return nil
+}
+
+// baseImage returns the Dockerfile base image of a stage with header h.
+func baseImage(h map[string]string) (string, error) {
+	switch bs := strings.ToLower(h["bootstrap"]); bs {
+	case "docker":
+		from := strings.TrimPrefix(h["from"], "//")
+		if from == "" {
+			return "", fmt.Errorf("no base image specified in 'From' header")
+		}
+		if registry := h["registry"]; registry != "" {
+			from = strings.TrimSuffix(registry, "/") + "/" + from
+		}
+		return from, nil
+	case "scratch":
+		return "scratch", nil
+	default:
+		return "", fmt.Errorf("bootstrap agent %q cannot be translated to a Dockerfile", bs)
+	}
+}
+
+// stageName returns the Dockerfile name of the build stage name. Dockerfile
+// stage names are case insensitive.
+func stageName(name string) string {
+	return strings.ToLower(name)
+}
+
+// writeFiles writes the COPY instructions of the %files section f to w.
+func writeFiles(w io.Writer, f types.Files, stages map[string]string, contextDir string) error {
+	from := ""
+	if uri := f.Image(); uri != "" {
+		ref, ok := strings.CutPrefix(uri, "docker://")
+		if !ok {
+			return fmt.Errorf("files can't be copied from image %s in a Dockerfile, only from docker:// images", uri)
+		}
+		from = ref
+	} else if stage := f.Stage(); stage != "" {
+		name, ok := stages[stage]
+		if !ok {
+			return fmt.Errorf("stage %s was not found", stage)
+		}
+		from = name
+	}
+
+	for _, ft := range f.Files {
+		src := ft.Src
+		if from == "" {
+			rel, err := contextPath(src, contextDir)
+			if err != nil {
+				return err
+			}
+			src = rel
+		}
+		dst := ft.Dst
+		if dst == "" {
+			dst = ft.Src
+		}
+
+		if from != "" {
+			fmt.Fprintf(w, "COPY --from=%s %s\n", from, execForm([]string{src, dst}))
+		} else {
+			fmt.Fprintf(w, "COPY %s\n", execForm([]string{src, dst}))
+		}
+	}
+	return nil
+}
+
+// contextPath returns the path of the host file src relative to contextDir.
+func contextPath(src, contextDir string) (string, error) {
+	if !filepath.IsAbs(src) {
+		src = filepath.Join(contextDir, src)
+	}
+	rel, err := filepath.Rel(contextDir, src)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("file %s is outside of the build context %s", src, contextDir)
+	}
+	return rel, nil
+}
+
+// scriptArgs returns the command running the script s with shell, or with the
+// shell specified with the -c option of the section.
+func scriptArgs(s types.Script, shell ...string) ([]string, error) {
+	params := strings.Fields(strings.Split(s.Args, "#")[0])
+	for i, param := range params {
+		if param != "-c" {
+			continue
+		}
+		if len(params) == i+1 {
+			return nil, fmt.Errorf("bad section '-c' parameter: missing arguments")
+		}
+		return append(params[i+1:], "-c", s.Script), nil
+	}
+	return append(shell, "-c", s.Script), nil
+}
+
+// writeEnvironment writes the ENV instructions equivalent to the %environment
+// script to w. Only variable assignments can be translated.
+func writeEnvironment(w io.Writer, script string) error {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := envRegexp.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("%%environment line %q cannot be translated to a Dockerfile, only variable assignments are supported", line)
+		}
+		fmt.Fprintf(w, "ENV %s=%s\n", m[1], m[2])
+	}
+	return nil
+}
+
+// execForm returns args in the JSON array form of Dockerfile instructions.
+func execForm(args []string) string {
+	b, _ := json.Marshal(args)
+	return string(b)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package translate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+)
+
+func TestDockerfile(t *testing.T) {
+	defs := []types.Definition{
+		{
+			Header: map[string]string{"bootstrap": "docker", "from": "golang:1.21", "stage": "Devel"},
+			BuildData: types.Data{
+				Files: []types.Files{
+					{Files: []types.FileTransport{{Src: "hello.go", Dst: "/src/hello.go"}}},
+				},
+				Scripts: types.Scripts{
+					Post: types.Script{Script: "cd /src\ngo build -o hello hello.go"},
+				},
+			},
+		},
+		{
+			Header: map[string]string{"bootstrap": "docker", "from": "alpine:3.18"},
+			BuildData: types.Data{
+				Files: []types.Files{
+					{Args: "from Devel", Files: []types.FileTransport{{Src: "/src/hello", Dst: "/bin/hello"}}},
+					{Args: "from docker://busybox", Files: []types.FileTransport{{Src: "/bin/busybox"}}},
+				},
+				Scripts: types.Scripts{
+					Post: types.Script{Args: "-c /bin/bash", Script: "echo done"},
+				},
+			},
+			ImageData: types.ImageData{
+				Labels: map[string]string{"maintainer": "jane", "app": "hello world"},
+				ImageScripts: types.ImageScripts{
+					Environment: types.Script{Script: "# comment\nexport GREETING=\"hello world\"\nLANG=C\n"},
+					Runscript:   types.Script{Script: "exec /bin/hello \"$@\""},
+				},
+			},
+		},
+	}
+
+	want := `FROM golang:1.21 AS devel
+COPY ["hello.go","/src/hello.go"]
+RUN ["/bin/sh","-ex","-c","cd /src\ngo build -o hello hello.go"]
+
+FROM alpine:3.18
+COPY --from=devel ["/src/hello","/bin/hello"]
+COPY --from=busybox ["/bin/busybox","/bin/busybox"]
+RUN ["/bin/bash","-c","echo done"]
+ENV GREETING="hello world"
+ENV LANG=C
+LABEL "app"="hello world"
+LABEL "maintainer"="jane"
+ENTRYPOINT ["/bin/sh","-c","exec /bin/hello \"$@\"","runscript"]
+
+`
+
+	var buf bytes.Buffer
+	if err := Dockerfile(&buf, defs, "/work"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got Dockerfile:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDockerfileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     types.Definition
+		wantErr string
+	}{
+		{
+			name:    "LibraryBootstrap",
+			def:     types.Definition{Header: map[string]string{"bootstrap": "library", "from": "alpine"}},
+			wantErr: `bootstrap agent "library" cannot be translated`,
+		},
+		{
+			name: "SetupSection",
+			def: types.Definition{
+				Header:    map[string]string{"bootstrap": "docker", "from": "alpine"},
+				BuildData: types.Data{Scripts: types.Scripts{Setup: types.Script{Script: "touch $SINGULARITY_ROOTFS/file"}}},
+			},
+			wantErr: "%setup section cannot be translated",
+		},
+		{
+			name: "OutsideContext",
+			def: types.Definition{
+				Header: map[string]string{"bootstrap": "docker", "from": "alpine"},
+				BuildData: types.Data{Files: []types.Files{
+					{Files: []types.FileTransport{{Src: "/etc/hosts"}}},
+				}},
+			},
+			wantErr: "outside of the build context",
+		},
+		{
+			name: "LocalImage",
+			def: types.Definition{
+				Header: map[string]string{"bootstrap": "docker", "from": "alpine"},
+				BuildData: types.Data{Files: []types.Files{
+					{Args: "from ./tools.sif", Files: []types.FileTransport{{Src: "/bin/tool"}}},
+				}},
+			},
+			wantErr: "only from docker:// images",
+		},
+		{
+			name: "Environment",
+			def: types.Definition{
+				Header: map[string]string{"bootstrap": "docker", "from": "alpine"},
+				ImageData: types.ImageData{ImageScripts: types.ImageScripts{
+					Environment: types.Script{Script: "source /opt/env.sh"},
+				}},
+			},
+			wantErr: `%environment line "source /opt/env.sh" cannot be translated`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Dockerfile(&buf, []types.Definition{tt.def}, "/work")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
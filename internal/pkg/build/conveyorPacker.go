@@ -43,6 +43,8 @@ func NewConveyorPacker(def types.Definition) (ConveyorPacker, error) {
 		return &sources.LibraryConveyorPacker{}, nil
 	case "oras":
 		return &sources.OrasConveyorPacker{}, nil
+	case "ocisif":
+		return &sources.OCISIFConveyorPacker{}, nil
 	case "shub":
 		return &sources.ShubConveyorPacker{}, nil
 	case ocitransport.SupportedTransport(bs):
@@ -0,0 +1,116 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// testResult holds the outcome of running the %test section of a built image.
+type testResult struct {
+	// image is the path of the tested image.
+	image string
+	// started is the time at which the test started.
+	started time.Time
+	// duration is the time taken by the test.
+	duration time.Duration
+	// output is the combined stdout and stderr of the test.
+	output []byte
+	// err is the error returned by the test, if it failed.
+	err error
+	// skipped is set when the image has no %test section.
+	skipped bool
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Output  string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes r as a JUnit XML report to w.
+func writeJUnit(w io.Writer, r testResult) error {
+	seconds := fmt.Sprintf("%.3f", r.duration.Seconds())
+	tc := junitTestCase{
+		Name:      "%test",
+		Classname: r.image,
+		Time:      seconds,
+	}
+	ts := junitTestSuite{
+		Name:      "singularity build",
+		Tests:     1,
+		Time:      seconds,
+		Timestamp: r.started.UTC().Format(time.RFC3339),
+	}
+
+	switch {
+	case r.skipped:
+		ts.Skipped = 1
+		tc.Skipped = &junitSkipped{Message: "image has no %test section"}
+	case r.err != nil:
+		ts.Failures = 1
+		tc.Failure = &junitFailure{Message: r.err.Error(), Type: "failure", Output: string(r.output)}
+	default:
+		tc.SystemOut = string(r.output)
+	}
+	ts.Cases = []junitTestCase{tc}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{ts}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeJUnitFile writes r as a JUnit XML report to the file at path.
+func writeJUnitFile(path string, r testResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("while creating test report: %w", err)
+	}
+	if err := writeJUnit(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("while writing test report: %w", err)
+	}
+	return f.Close()
+}
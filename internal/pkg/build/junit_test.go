@@ -0,0 +1,88 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	started := time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		result testResult
+		want   string
+	}{
+		{
+			name: "Pass",
+			result: testResult{
+				image:    "test.sif",
+				started:  started,
+				duration: 1500 * time.Millisecond,
+				output:   []byte("ok\n"),
+			},
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="singularity build" tests="1" failures="0" skipped="0" time="1.500" timestamp="2023-11-20T10:00:00Z">
+    <testcase name="%test" classname="test.sif" time="1.500">
+      <system-out>ok&#xA;</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`,
+		},
+		{
+			name: "Failure",
+			result: testResult{
+				image:    "test.sif",
+				started:  started,
+				duration: 250 * time.Millisecond,
+				output:   []byte("<missing>"),
+				err:      errors.New("exit status 1"),
+			},
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="singularity build" tests="1" failures="1" skipped="0" time="0.250" timestamp="2023-11-20T10:00:00Z">
+    <testcase name="%test" classname="test.sif" time="0.250">
+      <failure message="exit status 1" type="failure">&lt;missing&gt;</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`,
+		},
+		{
+			name: "Skipped",
+			result: testResult{
+				image:   "test.sif",
+				started: started,
+				skipped: true,
+			},
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="singularity build" tests="1" failures="0" skipped="1" time="0.000" timestamp="2023-11-20T10:00:00Z">
+    <testcase name="%test" classname="test.sif" time="0.000">
+      <skipped message="image has no %test section"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NilError(t, writeJUnit(&buf, tt.result))
+			assert.Equal(t, buf.String(), tt.want)
+		})
+	}
+}
@@ -314,6 +314,9 @@ func addBuildLabels(labels map[string]string, b *types.Bundle) error {
 
 	// build date and time, lots of time formatting
 	currentTime := time.Now()
+	if b.Opts.Reproducible {
+		currentTime = b.Opts.SourceDateEpoch.UTC()
+	}
 	year, month, day := currentTime.Date()
 	date := strconv.Itoa(day) + `_` + month.String() + `_` + strconv.Itoa(year)
 	hour, min, sec := currentTime.Clock()
@@ -344,5 +347,12 @@ func addBuildLabels(labels map[string]string, b *types.Bundle) error {
 	// architecture should match the remote build --arch flag.
 	labels["org.label-schema.build-arch"] = runtime.GOARCH
 
+	// Network policy of the scripts run during the build
+	network := b.Opts.Network
+	if network == "" {
+		network = "host"
+	}
+	labels["org.label-schema.usage.singularity.build-network"] = network
+
 	return nil
 }
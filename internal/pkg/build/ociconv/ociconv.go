@@ -0,0 +1,284 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package ociconv generates the runscript, startscript and environment of a
+// native Singularity container from the config of the OCI image it is
+// converted from.
+package ociconv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/shell"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+const (
+	// AnnotationExposedPorts is the label holding the comma separated list of
+	// ports exposed by the OCI image, e.g. "80/tcp,53/udp".
+	AnnotationExposedPorts = "org.sylabs.oci.exposed-ports"
+	// AnnotationWorkdir is the label holding the WORKDIR of the OCI image.
+	AnnotationWorkdir = "org.sylabs.oci.workdir"
+	// AnnotationUser is the label holding the USER of the OCI image.
+	AnnotationUser = "org.sylabs.oci.user"
+)
+
+// EnvFile is the path of the environment file, relative to the container root.
+const EnvFile = ".singularity.d/env/10-docker2singularity.sh"
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type scriptData struct {
+	Entrypoint        string
+	Cmd               string
+	Workdir           string
+	User              string
+	PrependCmd        string
+	PrependEntrypoint string
+}
+
+//nolint:dupword
+const scriptTemplate = `#!/bin/sh
+OCI_ENTRYPOINT={{.Entrypoint}}
+OCI_CMD={{.Cmd}}
+OCI_WORKDIR={{.Workdir}}
+OCI_USER={{.User}}
+
+# When SINGULARITY_NO_EVAL set, use OCI compatible behavior that does
+# not evaluate resolved CMD / ENTRYPOINT / ARGS through the shell, and
+# does not modify expected quoting behavior of args.
+if [ -n "$SINGULARITY_NO_EVAL" ]; then
+	# Start in WORKDIR, unless another working directory than the default
+	# $HOME was requested.
+	if [ -n "$OCI_WORKDIR" ] && [ "$PWD" = "$HOME" ] && [ -d "$OCI_WORKDIR" ]; then
+		cd "$OCI_WORKDIR" || exit 1
+	fi
+
+	# A USER can't be switched to, warn when running as root instead.
+	case "$OCI_USER" in
+	""|root|0|root:*|0:*)
+		;;
+	*)
+		if [ "$(id -u)" = "0" ]; then
+			echo "WARNING: running as root, rather than image USER $OCI_USER" >&2
+		fi
+		;;
+	esac
+
+	# ENTRYPOINT only - run entrypoint plus args
+	if [ -z "$OCI_CMD" ] && [ -n "$OCI_ENTRYPOINT" ]; then
+		{{.PrependEntrypoint}}
+		exec "$@"
+	fi
+
+	# CMD only - run CMD or override with args
+	if [ -n "$OCI_CMD" ] && [ -z "$OCI_ENTRYPOINT" ]; then
+		if [ $# -eq 0 ]; then
+			{{.PrependCmd}}
+			:
+		fi
+		exec "$@"
+	fi
+
+	# ENTRYPOINT and CMD - run ENTRYPOINT with CMD as default args
+	# override with user provided args
+	if [ $# -gt 0 ]; then
+		{{.PrependEntrypoint}}
+		:
+	else
+		{{.PrependCmd}}
+		{{.PrependEntrypoint}}
+		:
+	fi
+	if [ $# -eq 0 ]; then
+		echo "No ENTRYPOINT or CMD in image, and no command specified" >&2
+		exit 1
+	fi
+	exec "$@"
+fi
+
+# Standard Singularity behavior evaluates CMD / ENTRYPOINT / ARGS
+# combination through shell before exec, and requires special quoting
+# due to concatenation of CMDLINE_ARGS.
+CMDLINE_ARGS=""
+# prepare command line arguments for evaluation
+for arg in "$@"; do
+	CMDLINE_ARGS="${CMDLINE_ARGS} \"$arg\""
+done
+
+if [ -z "$OCI_CMD" ] && [ -n "$OCI_ENTRYPOINT" ]; then
+	# ENTRYPOINT only - run entrypoint plus args
+	SINGULARITY_OCI_RUN="${OCI_ENTRYPOINT} ${CMDLINE_ARGS}"
+elif [ -n "$OCI_CMD" ] && [ -z "$OCI_ENTRYPOINT" ]; then
+	# CMD only - run CMD or override with args
+	if [ $# -gt 0 ]; then
+		SINGULARITY_OCI_RUN="${CMDLINE_ARGS}"
+	else
+		SINGULARITY_OCI_RUN="${OCI_CMD}"
+	fi
+elif [ $# -gt 0 ]; then
+	# ENTRYPOINT and CMD - run ENTRYPOINT with CMD as default args
+	# override with user provided args
+	SINGULARITY_OCI_RUN="${OCI_ENTRYPOINT} ${CMDLINE_ARGS}"
+else
+	SINGULARITY_OCI_RUN="${OCI_ENTRYPOINT} ${OCI_CMD}"
+fi
+
+# Evaluate shell expressions first and set arguments accordingly,
+# then execute final command as first container process
+eval "set -- ${SINGULARITY_OCI_RUN}"
+if [ $# -eq 0 ]; then
+	echo "No ENTRYPOINT or CMD in image, and no command specified" >&2
+	exit 1
+fi
+exec "$@"
+`
+
+// singleQuoted returns s as a single quoted shell word.
+func singleQuoted(s string) string {
+	return "'" + shell.EscapeSingleQuotes(s) + "'"
+}
+
+// prepend returns the shell commands prepending each of args to $@.
+func prepend(args []string) string {
+	var b strings.Builder
+	for i := len(args) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "set -- %s \"$@\"\n", singleQuoted(args[i]))
+	}
+	return b.String()
+}
+
+// Runscript returns a runscript running the ENTRYPOINT and CMD of cfg, with
+// the arguments of the run command overriding CMD. In SINGULARITY_NO_EVAL mode
+// the exec-form ENTRYPOINT and CMD are run as is, from WORKDIR, while they are
+// evaluated through the shell otherwise.
+func Runscript(cfg imgspecv1.ImageConfig) (string, error) {
+	data := scriptData{
+		Entrypoint:        singleQuoted(shell.ArgsQuoted(cfg.Entrypoint)),
+		Cmd:               singleQuoted(shell.ArgsQuoted(cfg.Cmd)),
+		Workdir:           singleQuoted(cfg.WorkingDir),
+		User:              singleQuoted(cfg.User),
+		PrependCmd:        prepend(cfg.Cmd),
+		PrependEntrypoint: prepend(cfg.Entrypoint),
+	}
+
+	tmpl, err := template.New("runscript").Parse(scriptTemplate)
+	if err != nil {
+		return "", fmt.Errorf("while parsing runscript template: %w", err)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("while generating runscript template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Startscript returns a startscript running the ENTRYPOINT and CMD of cfg when
+// an instance is started, as a container engine would. The arguments of the
+// instance start command override CMD.
+func Startscript(cfg imgspecv1.ImageConfig) (string, error) {
+	return Runscript(cfg)
+}
+
+// Environment returns a script exporting the ENV of cfg. Variables are set to
+// their value in the image, unless already set to a non-empty value, except
+// for PATH which is always set. Values are not evaluated by the shell.
+func Environment(cfg imgspecv1.ImageConfig) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+
+	for _, e := range cfg.Env {
+		name, value, hasValue := strings.Cut(e, "=")
+		if !envNameRegexp.MatchString(name) {
+			sylog.Warningf("Ignoring environment variable %q with invalid name", name)
+			continue
+		}
+
+		switch {
+		case !hasValue:
+			fmt.Fprintf(&b, "export %s=\"${%s:-}\"\n", name, name)
+		case name == "PATH":
+			fmt.Fprintf(&b, "export %s=%s\n", name, singleQuoted(value))
+		default:
+			fmt.Fprintf(&b, "if [ -z \"${%s:-}\" ]; then\n\t%s=%s\nfi\nexport %s\n", name, name, singleQuoted(value), name)
+		}
+	}
+
+	return b.String()
+}
+
+// Labels returns the labels of cfg, with added annotations recording its
+// exposed ports, WORKDIR and USER. Existing labels are not overridden.
+func Labels(cfg imgspecv1.ImageConfig) map[string]string {
+	labels := make(map[string]string, len(cfg.Labels)+3)
+	for k, v := range cfg.Labels {
+		labels[k] = v
+	}
+
+	setDefault := func(k, v string) {
+		if _, ok := labels[k]; !ok && v != "" {
+			labels[k] = v
+		}
+	}
+
+	ports := make([]string, 0, len(cfg.ExposedPorts))
+	for p := range cfg.ExposedPorts {
+		if !strings.Contains(p, "/") {
+			p += "/tcp"
+		}
+		ports = append(ports, p)
+	}
+	sort.Strings(ports)
+
+	setDefault(AnnotationExposedPorts, strings.Join(ports, ","))
+	setDefault(AnnotationWorkdir, cfg.WorkingDir)
+	setDefault(AnnotationUser, cfg.User)
+
+	return labels
+}
+
+// Write writes the runscript, startscript and environment generated from cfg
+// into the container root filesystem at rootfs.
+func Write(rootfs string, cfg imgspecv1.ImageConfig) error {
+	runscript, err := Runscript(cfg)
+	if err != nil {
+		return err
+	}
+	startscript, err := Startscript(cfg)
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		path    string
+		content string
+	}{
+		{".singularity.d/runscript", runscript},
+		{".singularity.d/startscript", startscript},
+		{EnvFile, Environment(cfg)},
+	}
+
+	for _, f := range files {
+		path := filepath.Join(rootfs, f.path)
+		if err := os.WriteFile(path, []byte(f.content), 0o755); err != nil {
+			return fmt.Errorf("while writing %s: %w", f.path, err)
+		}
+		// WriteFile doesn't change the mode of existing files.
+		if err := os.Chmod(path, 0o755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
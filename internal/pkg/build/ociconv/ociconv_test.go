@@ -0,0 +1,298 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ociconv
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// runScript runs script with sh, from dir, which is also set as $HOME, and
+// returns its output.
+func runScript(t *testing.T, script, dir string, env []string, args ...string) (string, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("/bin/sh", append([]string{path}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir, "PWD=" + dir}, env...)
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func TestRunscript(t *testing.T) {
+	noEval := []string{"SINGULARITY_NO_EVAL=1"}
+
+	tests := []struct {
+		name    string
+		cfg     imgspecv1.ImageConfig
+		env     []string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "EntrypointArgs",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|", "a b"}},
+			args: []string{"c d"},
+			want: "a b|c d|",
+		},
+		{
+			name: "EntrypointArgsNoEval",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|", "a b"}},
+			env:  noEval,
+			args: []string{"c d"},
+			want: "a b|c d|",
+		},
+		{
+			name: "EntrypointSpecialChars",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|", `'"$HOME` + "`x`"}},
+			want: `'"$HOME` + "`x`|",
+		},
+		{
+			name: "EntrypointSpecialCharsNoEval",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|", `'"$HOME` + "`x`"}},
+			env:  noEval,
+			args: []string{`$HOME`},
+			want: `'"$HOME` + "`x`|$HOME|",
+		},
+		{
+			name: "CmdOnly",
+			cfg:  imgspecv1.ImageConfig{Cmd: []string{"printf", "%s|", "cmd"}},
+			want: "cmd|",
+		},
+		{
+			name: "CmdOverride",
+			cfg:  imgspecv1.ImageConfig{Cmd: []string{"false"}},
+			args: []string{"printf", "%s|", "arg"},
+			want: "arg|",
+		},
+		{
+			name: "CmdOverrideNoEval",
+			cfg:  imgspecv1.ImageConfig{Cmd: []string{"false"}},
+			env:  noEval,
+			args: []string{"printf", "%s|", "arg"},
+			want: "arg|",
+		},
+		{
+			name: "EntrypointCmd",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|"}, Cmd: []string{"-n", "cmd"}},
+			want: "-n|cmd|",
+		},
+		{
+			name: "EntrypointCmdNoEval",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|"}, Cmd: []string{"-n", "cmd"}},
+			env:  noEval,
+			want: "-n|cmd|",
+		},
+		{
+			name: "EntrypointCmdOverride",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|"}, Cmd: []string{"cmd"}},
+			args: []string{"arg"},
+			want: "arg|",
+		},
+		{
+			name: "EntrypointCmdOverrideNoEval",
+			cfg:  imgspecv1.ImageConfig{Entrypoint: []string{"printf", "%s|"}, Cmd: []string{"cmd"}},
+			env:  noEval,
+			args: []string{"arg"},
+			want: "arg|",
+		},
+		{
+			name: "ArgsOnly",
+			cfg:  imgspecv1.ImageConfig{},
+			args: []string{"printf", "%s|", "arg"},
+			want: "arg|",
+		},
+		{
+			name:    "Nothing",
+			cfg:     imgspecv1.ImageConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "NothingNoEval",
+			cfg:     imgspecv1.ImageConfig{},
+			env:     noEval,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := Runscript(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := runScript(t, script, t.TempDir(), tt.env, tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("got output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunscriptWorkdir(t *testing.T) {
+	workdir := t.TempDir()
+	cfg := imgspecv1.ImageConfig{Entrypoint: []string{"pwd"}, WorkingDir: workdir}
+
+	script, err := Runscript(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	home := t.TempDir()
+
+	tests := []struct {
+		name string
+		env  []string
+		want string
+	}{
+		{
+			name: "Eval",
+			want: home + "\n",
+		},
+		{
+			name: "NoEval",
+			env:  []string{"SINGULARITY_NO_EVAL=1"},
+			want: workdir + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runScript(t, script, home, tt.env)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	cfg := imgspecv1.ImageConfig{
+		Env: []string{
+			"PATH=/opt/bin:/usr/bin:/bin",
+			`SPECIAL='"$HOME` + "`x`\\",
+			"MULTILINE=a\nb",
+			"EQUALS=a=b",
+			"EMPTY=",
+			"PRESET=image",
+			"NOVALUE",
+			"INVALID-NAME=x",
+			"=x",
+		},
+	}
+
+	script := Environment(cfg) + `printf '%s|' "$PATH" "$SPECIAL" "$MULTILINE" "$EQUALS" "$EMPTY" "$PRESET" "$NOVALUE"`
+
+	got, err := runScript(t, script, t.TempDir(), []string{"PRESET=host"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "/opt/bin:/usr/bin:/bin|" + `'"$HOME` + "`x`\\|a\nb|a=b||host||"
+	if got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  imgspecv1.ImageConfig
+		want map[string]string
+	}{
+		{
+			name: "Empty",
+			cfg:  imgspecv1.ImageConfig{},
+			want: map[string]string{},
+		},
+		{
+			name: "Annotations",
+			cfg: imgspecv1.ImageConfig{
+				ExposedPorts: map[string]struct{}{"8080/tcp": {}, "53/udp": {}, "443": {}},
+				WorkingDir:   "/app",
+				User:         "1000:1000",
+				Labels:       map[string]string{"maintainer": "me"},
+			},
+			want: map[string]string{
+				"maintainer":           "me",
+				AnnotationExposedPorts: "443/tcp,53/udp,8080/tcp",
+				AnnotationWorkdir:      "/app",
+				AnnotationUser:         "1000:1000",
+			},
+		},
+		{
+			name: "NoOverride",
+			cfg: imgspecv1.ImageConfig{
+				WorkingDir: "/app",
+				Labels:     map[string]string{AnnotationWorkdir: "/label"},
+			},
+			want: map[string]string{
+				AnnotationWorkdir: "/label",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := len(tt.cfg.Labels)
+			got := Labels(tt.cfg)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got labels %v, want %v", got, tt.want)
+			}
+			if len(tt.cfg.Labels) != orig {
+				t.Errorf("image config labels were modified")
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootfs, ".singularity.d", "env"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// An existing startscript, with restrictive permissions.
+	if err := os.WriteFile(filepath.Join(rootfs, ".singularity.d", "startscript"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := imgspecv1.ImageConfig{Entrypoint: []string{"/bin/true"}, Env: []string{"A=b"}}
+	if err := Write(rootfs, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, f := range []string{".singularity.d/runscript", ".singularity.d/startscript", EnvFile} {
+		fi, err := os.Stat(filepath.Join(rootfs, f))
+		if err != nil {
+			t.Errorf("while checking %s: %v", f, err)
+			continue
+		}
+		if fi.Mode().Perm() != 0o755 {
+			t.Errorf("%s has mode %o, want 755", f, fi.Mode().Perm())
+		}
+		if fi.Size() == 0 {
+			t.Errorf("%s is empty", f)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/build/sources"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sync/errgroup"
+)
+
+// resolveConcurrency is the maximum number of stages and images retrieved
+// concurrently.
+const resolveConcurrency = 4
+
+// newImageSources returns a stage for each distinct container image that
+// files are copied from in defs, keyed by image URI. The root filesystem of
+// these images is retrieved into a temporary bundle, but no build section is
+// run against it.
+func newImageSources(defs []types.Definition, opts types.Options) (map[string]*stage, error) {
+	images := make(map[string]*stage)
+	for _, d := range defs {
+		for _, f := range d.BuildData.Files {
+			uri := f.Image()
+			if uri == "" || images[uri] != nil {
+				continue
+			}
+
+			def, err := types.NewDefinitionFromURI(uri)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image %q in %%files section: %v", uri, err)
+			}
+			c, err := NewConveyorPacker(def)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get conveyorpacker for %s: %s", uri, err)
+			}
+
+			parentPath, err := os.MkdirTemp(opts.TmpDir, "build-image-")
+			if err != nil {
+				return nil, fmt.Errorf("failed to create build parent dir: %w", err)
+			}
+			b, err := types.NewBundle(parentPath, opts.TmpDir)
+			if err != nil {
+				return nil, err
+			}
+			b.Recipe = def
+			// the image is only a source of files, never the build target
+			b.Opts = opts
+			b.Opts.EncryptionKeyInfo = nil
+			b.Opts.Update = false
+			b.Opts.SandboxTarget = false
+			b.Opts.OnlyPaths = nil
+
+			images[uri] = &stage{name: uri, c: c, b: b}
+		}
+	}
+	return images, nil
+}
+
+// resolve retrieves the root filesystem of each stage and image source of the
+// build concurrently. The last stage is populated from the existing container
+// at the build destination when updating it. The %pre script of a stage runs
+// on the host right before the stage is bootstrapped, and host scripts of
+// different stages never run concurrently.
+func (b *Build) resolve(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(resolveConcurrency)
+
+	var preMu sync.Mutex
+
+	for i := range b.stages {
+		s := &b.stages[i]
+		// only update last stage if specified
+		update := s.b.Opts.Update && !s.b.Opts.Force && i == len(b.stages)-1
+		g.Go(func() error {
+			preMu.Lock()
+			err := s.runHostScript("pre", s.b.Recipe.BuildData.Pre)
+			preMu.Unlock()
+			if err != nil {
+				return err
+			}
+
+			if update {
+				// updating, extract dest container to bundle
+				sylog.Infof("Building into existing container: %s", b.Conf.Dest)
+				p, err := sources.GetLocalPacker(ctx, b.Conf.Dest, s.b)
+				if err != nil {
+					return err
+				}
+				_, err = p.Pack(ctx)
+				return err
+			}
+			if s.snap != nil {
+				ok, err := s.restoreSnapshot()
+				if ok {
+					return nil
+				}
+				if err != nil {
+					sylog.Warningf("Could not restore stage %s from build cache: %v", s.name, err)
+					if err := resetRootfs(s.b.RootfsPath); err != nil {
+						return err
+					}
+				}
+			}
+			return s.fetch(ctx)
+		})
+	}
+
+	for _, s := range b.images {
+		s := s
+		g.Go(func() error {
+			sylog.Infof("Retrieving files source image %s", s.name)
+			if err := s.fetch(ctx); err != nil {
+				return fmt.Errorf("while retrieving %s: %w", s.name, err)
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// fetch retrieves the root filesystem of the stage from its bootstrap source.
+func (s *stage) fetch(ctx context.Context) error {
+	if s.c == nil {
+		return fmt.Errorf("no bootstrap source for stage %q", s.name)
+	}
+	if s.b.Opts.ImgCache == nil {
+		return fmt.Errorf("undefined image cache")
+	}
+	if err := s.c.Get(ctx, s.b); err != nil {
+		return fmt.Errorf("conveyor failed to get: %v", err)
+	}
+	if _, err := s.c.Pack(ctx); err != nil {
+		return fmt.Errorf("packer failed to pack: %v", err)
+	}
+	return nil
+}
+
+// resetRootfs removes the content of the root filesystem at path.
+func resetRootfs(path string) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(path, e.Name())); err != nil {
+			return fmt.Errorf("while cleaning root filesystem: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	da "github.com/docker/docker/pkg/archive"
+	"github.com/sylabs/singularity/v4/internal/pkg/build/sources"
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// snapshotFormat is part of all snapshot keys, so that snapshots taken by a
+// version of Singularity storing them differently are never restored.
+const snapshotFormat = "1"
+
+const (
+	// snapshotFiles identifies snapshots taken after the %files sections.
+	snapshotFiles = "files"
+	// snapshotPost identifies snapshots taken after the %post section.
+	snapshotPost = "post"
+)
+
+// snapshots holds the cache keys of the snapshots of the root filesystem of a
+// stage, taken after its %files sections and after its %post section. Each
+// key is derived from the inputs of the build up to that section, so that a
+// snapshot is reused when only later sections of the definition change.
+type snapshots struct {
+	files string
+	post  string
+	// restored is the section after which the snapshot restored in place of
+	// bootstrapping the stage was taken, or "" if none was restored.
+	restored string
+}
+
+// key returns the cache key of the snapshot taken after section.
+func (s *snapshots) key(section string) string {
+	if section == snapshotPost {
+		return s.post
+	}
+	return s.files
+}
+
+// setSnapshotKeys computes the snapshot keys of the stages of the build that
+// can be restored from, and saved to, the build cache. Snapshots are not
+// used for stages running a %setup section on the host, or whose base image
+// or %files inputs can't be determined.
+func (b *Build) setSnapshotKeys(ctx context.Context) {
+	digests := make(map[string]string)
+	for i := range b.stages {
+		s := &b.stages[i]
+		if !s.snapshotsEnabled(i == len(b.stages)-1) {
+			continue
+		}
+		keys, err := b.snapshotKeys(ctx, i, digests)
+		if err != nil {
+			sylog.Debugf("Not using build cache for stage %s: %v", s.name, err)
+			continue
+		}
+		s.snap = keys
+	}
+}
+
+// snapshotsEnabled returns whether the build cache can be used for the stage.
+func (s *stage) snapshotsEnabled(last bool) bool {
+	opts := s.b.Opts
+	if opts.ImgCache == nil || opts.ImgCache.IsDisabled() || opts.NoCache {
+		return false
+	}
+	if last && opts.Update && !opts.Force {
+		return false
+	}
+	if len(opts.OnlyPaths) > 0 || !s.b.RunSection("files") || !s.b.RunSection("post") {
+		return false
+	}
+	return s.b.Recipe.BuildData.Setup.Script == ""
+}
+
+// snapshotKeys returns the snapshot keys of stage i. The digests of the base
+// image of the stage, and of the images its files are copied from, are part
+// of the keys, so that snapshots are not restored once a tag points at a new
+// image. They are recorded in digests, keyed by image URI.
+func (b *Build) snapshotKeys(ctx context.Context, i int, digests map[string]string) (*snapshots, error) {
+	def := b.stages[i].b.Recipe
+
+	h := sha256.New()
+	fmt.Fprintf(h, "format %s\n", snapshotFormat)
+	fmt.Fprintf(h, "euid %d proot %t\n", os.Geteuid(), os.Getenv("SINGULARITY_PROOT") != "")
+	hashMap(h, "header", def.Header)
+	hashMap(h, "custom", def.CustomData)
+
+	d, err := sources.Digest(ctx, b.stages[i].b)
+	if err != nil {
+		return nil, fmt.Errorf("while resolving base image: %w", err)
+	}
+	fmt.Fprintf(h, "base %s\n", d)
+
+	for _, f := range def.BuildData.Files {
+		fmt.Fprintf(h, "files %q\n", f.Args)
+
+		host := false
+		if uri := f.Image(); uri != "" {
+			d, err := b.imageDigest(ctx, uri, digests)
+			if err != nil {
+				return nil, fmt.Errorf("while resolving image %s: %w", uri, err)
+			}
+			fmt.Fprintf(h, "image %q %s\n", uri, d)
+		} else if name := f.Stage(); name != "" {
+			j, err := b.findStageIndex(name)
+			if err != nil || j >= i {
+				return nil, fmt.Errorf("invalid stage %s", name)
+			}
+			src := b.stages[j]
+			if src.snap == nil {
+				return nil, fmt.Errorf("files are copied from stage %s, which is not cached", name)
+			}
+			fmt.Fprintf(h, "stage %s %x\n", src.snap.post, sha256.Sum256(src.b.Recipe.Raw))
+		} else {
+			host = true
+		}
+
+		for _, t := range f.Files {
+			fmt.Fprintf(h, "copy %q %q\n", t.Src, t.Dst)
+			if host {
+				if err := hashHostFiles(h, t.Src); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	keys := &snapshots{files: hex.EncodeToString(h.Sum(nil))}
+
+	h = sha256.New()
+	post := def.BuildData.Post
+	opts := b.stages[i].b.Opts
+	fmt.Fprintf(h, "files %s\nnetwork %q %q\n", keys.files, opts.Network, opts.NetworkArgs)
+	fmt.Fprintf(h, "post %q\n%s", post.Args, post.Script)
+	keys.post = hex.EncodeToString(h.Sum(nil))
+
+	return keys, nil
+}
+
+// imageDigest returns the digest of the image that files are copied from at
+// uri, resolving it only once per build.
+func (b *Build) imageDigest(ctx context.Context, uri string, digests map[string]string) (string, error) {
+	if d, ok := digests[uri]; ok {
+		return d, nil
+	}
+	s := b.images[uri]
+	if s == nil {
+		return "", fmt.Errorf("unknown image")
+	}
+	d, err := sources.Digest(ctx, s.b)
+	if err != nil {
+		return "", err
+	}
+	digests[uri] = d
+	return d, nil
+}
+
+// hashMap writes the sorted entries of m to h.
+func hashMap(h hash.Hash, name string, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s %q=%q\n", name, k, m[k])
+	}
+}
+
+// hashHostFiles writes the path, mode and content of the host files matching
+// the src pattern of a %files section to h. As when copying them, symlinks
+// are dereferenced.
+func hashHostFiles(h hash.Hash, src string) error {
+	paths, err := filepath.Glob(src)
+	if err != nil {
+		return fmt.Errorf("while expanding source path: %s: %w", src, err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no source files found matching: %s", src)
+	}
+
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "path %q %v\n", path, fi.Mode())
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(h, f)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("while hashing %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// restoreSnapshot populates the root filesystem of the stage from the most
+// advanced snapshot found in the build cache, and returns whether one was
+// found.
+func (s *stage) restoreSnapshot() (bool, error) {
+	for _, section := range []string{snapshotPost, snapshotFiles} {
+		key := s.snap.key(section)
+		e, err := s.b.Opts.ImgCache.GetEntry(cache.BuildCacheType, key+".tar")
+		if err != nil {
+			return false, err
+		}
+		e.CleanTmp()
+		if !e.Exists {
+			continue
+		}
+
+		sylog.Infof("Using cached snapshot of stage %s after %%%s", s.name, section)
+		if err := s.restoreObjects(key); err != nil {
+			return false, err
+		}
+
+		f, err := os.Open(e.Path)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		opts := &da.TarOptions{NoLchown: os.Geteuid() != 0}
+		if err := da.Untar(f, s.b.RootfsPath, opts); err != nil {
+			return false, fmt.Errorf("while extracting snapshot %s: %w", key, err)
+		}
+
+		s.snap.restored = section
+		return true, nil
+	}
+	return false, nil
+}
+
+// restoreObjects sets the JSON objects of the stage bundle, recorded with
+// the snapshot with key.
+func (s *stage) restoreObjects(key string) error {
+	e, err := s.b.Opts.ImgCache.GetEntry(cache.BuildCacheType, key+".json")
+	if err != nil {
+		return err
+	}
+	e.CleanTmp()
+	if !e.Exists {
+		return fmt.Errorf("snapshot %s has no metadata", key)
+	}
+
+	data, err := os.ReadFile(e.Path)
+	if err != nil {
+		return err
+	}
+	objects := make(map[string][]byte)
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return fmt.Errorf("while decoding snapshot metadata: %w", err)
+	}
+	for k, v := range objects {
+		s.b.JSONObjects[k] = v
+	}
+	return nil
+}
+
+// saveSnapshot records the root filesystem of the stage after section in the
+// build cache, along with the JSON objects of its bundle, unless it is
+// already cached. Failures are reported as warnings, as they don't affect
+// the build.
+func (s *stage) saveSnapshot(section string) {
+	if s.snap == nil {
+		return
+	}
+	key := s.snap.key(section)
+	if err := s.writeSnapshot(key); err != nil {
+		sylog.Warningf("Could not save snapshot of stage %s after %%%s in cache: %v", s.name, section, err)
+	}
+}
+
+func (s *stage) writeSnapshot(key string) error {
+	imgCache := s.b.Opts.ImgCache
+
+	e, err := imgCache.GetEntry(cache.BuildCacheType, key+".tar")
+	if err != nil {
+		return err
+	}
+	defer e.CleanTmp()
+	if e.Exists {
+		return nil
+	}
+
+	// the metadata is finalized first, so that a snapshot is only found with
+	// its metadata
+	je, err := imgCache.GetEntry(cache.BuildCacheType, key+".json")
+	if err != nil {
+		return err
+	}
+	defer je.CleanTmp()
+	data, err := json.Marshal(s.b.JSONObjects)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(je.TmpPath, data, 0o600); err != nil {
+		return err
+	}
+	if err := je.Finalize(); err != nil {
+		return err
+	}
+
+	sylog.Debugf("Saving snapshot %s of stage %s", key, s.name)
+	rc, err := da.TarWithOptions(s.b.RootfsPath, &da.TarOptions{Compression: da.Uncompressed})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.OpenFile(e.TmpPath, os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return e.Finalize()
+}
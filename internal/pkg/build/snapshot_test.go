@@ -0,0 +1,192 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+	"gotest.tools/v3/assert"
+)
+
+func newSnapshotBuild(t *testing.T, imgCache *cache.Handle, defs ...types.Definition) *Build {
+	t.Helper()
+	b := &Build{}
+	for _, d := range defs {
+		bundle, err := types.NewBundle(t.TempDir(), t.TempDir())
+		assert.NilError(t, err)
+		bundle.Recipe = d
+		bundle.Opts = types.Options{ImgCache: imgCache, Sections: []string{"all"}}
+		b.stages = append(b.stages, stage{name: d.Header["stage"], b: bundle})
+	}
+	b.setSnapshotKeys(context.Background())
+	return b
+}
+
+// writeImage writes a fake local image file with content, and returns its
+// path.
+func writeImage(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "image.sif")
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestSnapshotKeys(t *testing.T) {
+	imgCache, err := cache.New(cache.Config{ParentDir: t.TempDir()})
+	assert.NilError(t, err)
+
+	src := filepath.Join(t.TempDir(), "file")
+	assert.NilError(t, os.WriteFile(src, []byte("one"), 0o644))
+	base := writeImage(t, "base")
+
+	def := func(post, env string) types.Definition {
+		return types.Definition{
+			Header: map[string]string{"bootstrap": "localimage", "from": base, "stage": "one"},
+			BuildData: types.Data{
+				Files:   []types.Files{{Files: []types.FileTransport{{Src: src, Dst: "/file"}}}},
+				Scripts: types.Scripts{Post: types.Script{Script: post}},
+			},
+			ImageData: types.ImageData{
+				ImageScripts: types.ImageScripts{Environment: types.Script{Script: env}},
+			},
+			Raw: []byte(post + env),
+		}
+	}
+
+	ref := newSnapshotBuild(t, imgCache, def("echo post", "export A=1")).stages[0].snap
+	assert.Assert(t, ref != nil)
+
+	// sections applied after %post don't change keys
+	s := newSnapshotBuild(t, imgCache, def("echo post", "export A=2")).stages[0].snap
+	assert.Equal(t, *s, *ref)
+
+	// %post changes only change the key of the snapshot after %post
+	s = newSnapshotBuild(t, imgCache, def("echo changed", "export A=1")).stages[0].snap
+	assert.Equal(t, s.files, ref.files)
+	assert.Assert(t, s.post != ref.post)
+
+	// the build network policy only changes the key of the snapshot after %post
+	b := newSnapshotBuild(t, imgCache, def("echo post", "export A=1"))
+	b.stages[0].b.Opts.Network = "none"
+	b.setSnapshotKeys(context.Background())
+	s = b.stages[0].snap
+	assert.Equal(t, s.files, ref.files)
+	assert.Assert(t, s.post != ref.post)
+
+	// base image changes change all keys
+	assert.NilError(t, os.WriteFile(base, []byte("new base"), 0o644))
+	s = newSnapshotBuild(t, imgCache, def("echo post", "export A=1")).stages[0].snap
+	assert.Assert(t, s.files != ref.files)
+	assert.Assert(t, s.post != ref.post)
+	assert.NilError(t, os.WriteFile(base, []byte("base"), 0o644))
+
+	// host file changes change all keys
+	assert.NilError(t, os.WriteFile(src, []byte("two"), 0o644))
+	s = newSnapshotBuild(t, imgCache, def("echo post", "export A=1")).stages[0].snap
+	assert.Assert(t, s.files != ref.files)
+	assert.Assert(t, s.post != ref.post)
+
+	// keys of stages copying files from a stage depend on the source stage
+	second := types.Definition{
+		Header: map[string]string{"bootstrap": "localimage", "from": base},
+		BuildData: types.Data{
+			Files: []types.Files{{Args: "from one", Files: []types.FileTransport{{Src: "/file"}}}},
+		},
+	}
+	k1 := newSnapshotBuild(t, imgCache, def("echo post", ""), second).stages[1].snap
+	k2 := newSnapshotBuild(t, imgCache, def("echo changed", ""), second).stages[1].snap
+	assert.Assert(t, k1 != nil && k2 != nil)
+	assert.Assert(t, k1.files != k2.files)
+
+	// keys of stages copying files from an image depend on the image content
+	img := writeImage(t, "one")
+	third := types.Definition{
+		Header: map[string]string{"bootstrap": "scratch"},
+		BuildData: types.Data{
+			Files: []types.Files{{Args: "from " + img, Files: []types.FileTransport{{Src: "/file"}}}},
+		},
+	}
+	newImageBuild := func() *Build {
+		b := newSnapshotBuild(t, imgCache, third)
+		b.images, err = newImageSources([]types.Definition{third}, b.stages[0].b.Opts)
+		assert.NilError(t, err)
+		b.setSnapshotKeys(context.Background())
+		return b
+	}
+	k1 = newImageBuild().stages[0].snap
+	assert.NilError(t, os.WriteFile(img, []byte("two"), 0o644))
+	k2 = newImageBuild().stages[0].snap
+	assert.Assert(t, k1 != nil && k2 != nil)
+	assert.Assert(t, k1.files != k2.files)
+
+	// stages bootstrapped from a source without digest are not cached
+	d := def("echo post", "")
+	d.Header = map[string]string{"bootstrap": "yum", "osversion": "9"}
+	assert.Assert(t, newSnapshotBuild(t, imgCache, d).stages[0].snap == nil)
+
+	// stages with a %setup section are not cached
+	d = def("echo post", "")
+	d.BuildData.Setup.Script = "touch $SINGULARITY_ROOTFS/file"
+	b = newSnapshotBuild(t, imgCache, d, second)
+	assert.Assert(t, b.stages[0].snap == nil)
+	assert.Assert(t, b.stages[1].snap == nil)
+
+	// the build cache is not used when the cache is disabled
+	disabled, err := cache.New(cache.Config{Disable: true})
+	assert.NilError(t, err)
+	assert.Assert(t, newSnapshotBuild(t, disabled, def("echo post", "")).stages[0].snap == nil)
+}
+
+func TestSnapshotSaveRestore(t *testing.T) {
+	imgCache, err := cache.New(cache.Config{ParentDir: t.TempDir()})
+	assert.NilError(t, err)
+
+	def := types.Definition{
+		Header: map[string]string{"bootstrap": "localimage", "from": writeImage(t, "base")},
+		BuildData: types.Data{
+			Scripts: types.Scripts{Post: types.Script{Script: "echo post"}},
+		},
+	}
+
+	s := newSnapshotBuild(t, imgCache, def).stages[0]
+	ok, err := s.restoreSnapshot()
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+
+	assert.NilError(t, os.MkdirAll(filepath.Join(s.b.RootfsPath, "etc"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(s.b.RootfsPath, "etc", "files"), []byte("files"), 0o644))
+	s.b.JSONObjects["config"] = []byte(`{"a":1}`)
+	s.saveSnapshot(snapshotFiles)
+	assert.NilError(t, os.WriteFile(filepath.Join(s.b.RootfsPath, "etc", "post"), []byte("post"), 0o644))
+	s.saveSnapshot(snapshotPost)
+
+	r := newSnapshotBuild(t, imgCache, def).stages[0]
+	ok, err = r.restoreSnapshot()
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, r.snap.restored, snapshotPost)
+	data, err := os.ReadFile(filepath.Join(r.b.RootfsPath, "etc", "post"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), "post")
+	assert.Equal(t, string(r.b.JSONObjects["config"]), `{"a":1}`)
+
+	// a changed %post restores the snapshot taken after %files
+	def.BuildData.Post.Script = "echo changed"
+	r = newSnapshotBuild(t, imgCache, def).stages[0]
+	ok, err = r.restoreSnapshot()
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	assert.Equal(t, r.snap.restored, snapshotFiles)
+	_, err = os.Stat(filepath.Join(r.b.RootfsPath, "etc", "files"))
+	assert.NilError(t, err)
+	_, err = os.Stat(filepath.Join(r.b.RootfsPath, "etc", "post"))
+	assert.Assert(t, os.IsNotExist(err))
+}
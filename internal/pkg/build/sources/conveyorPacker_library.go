@@ -35,13 +35,36 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 
 	cp.b = b
 
-	libraryURL := b.Opts.LibraryURL
-	authToken := b.Opts.LibraryAuthToken
+	if err = makeBaseEnv(cp.b.RootfsPath); err != nil {
+		return fmt.Errorf("while inserting base environment: %v", err)
+	}
+
+	imageRef, pullOpts, err := libraryPullOptions(b)
+	if err != nil {
+		return err
+	}
+
+	imagePath, err := library.Pull(ctx, b.Opts.ImgCache, imageRef, pullOpts)
+	if err != nil {
+		return fmt.Errorf("while fetching library image: %v", err)
+	}
 
+	// insert base metadata before unpacking fs
 	if err = makeBaseEnv(cp.b.RootfsPath); err != nil {
 		return fmt.Errorf("while inserting base environment: %v", err)
 	}
 
+	cp.LocalPacker, err = GetLocalPacker(ctx, imagePath, cp.b)
+
+	return err
+}
+
+// libraryPullOptions returns the reference of the library image that the
+// bundle b is bootstrapped from, and the options to pull it.
+func libraryPullOptions(b *types.Bundle) (*client.Ref, library.PullOptions, error) {
+	libraryURL := b.Opts.LibraryURL
+	authToken := b.Opts.LibraryAuthToken
+
 	// check for custom library from definition
 	customLib, ok := b.Recipe.Header["library"]
 	if ok {
@@ -51,7 +74,7 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 
 	imageRef, err := library.NormalizeLibraryRef(b.Recipe.Header["from"])
 	if err != nil {
-		return fmt.Errorf("error parsing libraryRef: %v", err)
+		return nil, library.PullOptions{}, fmt.Errorf("error parsing libraryRef: %v", err)
 	}
 
 	if imageRef.Host != "" {
@@ -73,22 +96,10 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 
 	pullOpts := library.PullOptions{
 		LibraryConfig: libraryConfig,
-		TmpDir:        cp.b.TmpDir,
-		Platform:      cp.b.Opts.Platform,
+		TmpDir:        b.TmpDir,
+		Platform:      b.Opts.Platform,
 	}
-	imagePath, err := library.Pull(ctx, b.Opts.ImgCache, imageRef, pullOpts)
-	if err != nil {
-		return fmt.Errorf("while fetching library image: %v", err)
-	}
-
-	// insert base metadata before unpacking fs
-	if err = makeBaseEnv(cp.b.RootfsPath); err != nil {
-		return fmt.Errorf("while inserting base environment: %v", err)
-	}
-
-	cp.LocalPacker, err = GetLocalPacker(ctx, imagePath, cp.b)
-
-	return err
+	return imageRef, pullOpts, nil
 }
 
 // CleanUp removes any files owned by the conveyorPacker on the filesystem.
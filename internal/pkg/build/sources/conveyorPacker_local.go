@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -74,6 +74,13 @@ func GetLocalPacker(ctx context.Context, src string, b *types.Bundle) (LocalPack
 			b:       b,
 			img:     imageObject,
 		}, nil
+	case image.OCISIF:
+		sylog.Debugf("Packing from OCI-SIF")
+
+		return &OCISIFPacker{
+			srcFile: src,
+			b:       b,
+		}, nil
 	case image.SQUASHFS:
 		sylog.Debugf("Packing from Squashfs")
 
@@ -84,6 +91,9 @@ func GetLocalPacker(ctx context.Context, src string, b *types.Bundle) (LocalPack
 		}, nil
 	case image.EXT3:
 		sylog.Debugf("Packing from Ext3")
+		if len(b.Opts.OnlyPaths) > 0 {
+			return nil, fmt.Errorf("extracting only some paths is not supported for ext3 images")
+		}
 
 		return &Ext3Packer{
 			srcfile: src,
@@ -92,6 +102,9 @@ func GetLocalPacker(ctx context.Context, src string, b *types.Bundle) (LocalPack
 		}, nil
 	case image.SANDBOX:
 		sylog.Debugf("Packing from Sandbox")
+		if len(b.Opts.OnlyPaths) > 0 {
+			return nil, fmt.Errorf("extracting only some paths is not supported for sandbox images")
+		}
 
 		return &SandboxPacker{
 			srcdir: src,
@@ -0,0 +1,100 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// OCISIFConveyorPacker bootstraps from an OCI-SIF image, which is either a
+// local file, or an OCI image converted to an OCI-SIF.
+type OCISIFConveyorPacker struct {
+	LocalPacker
+}
+
+// Get retrieves the OCI-SIF image specified by the 'From' header. A path is
+// used as a local OCI-SIF file. An OCI image reference, such as
+// docker://alpine or oci-archive:image.tar, is pulled as an OCI-SIF image.
+func (cp *OCISIFConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err error) {
+	from := b.Recipe.Header["from"]
+	if from == "" {
+		return fmt.Errorf("no OCI-SIF image specified in 'From' header")
+	}
+
+	imagePath := from
+	if transport, _, ok := strings.Cut(from, ":"); ok && ocitransport.SupportedTransport(transport) != "" {
+		sylog.Debugf("Getting OCI-SIF image from %s", from)
+		imagePath, err = pullOCISIF(ctx, b, from)
+		if err != nil {
+			return fmt.Errorf("while fetching OCI-SIF image: %v", err)
+		}
+	} else {
+		sylog.Debugf("Getting OCI-SIF image from local file %s", from)
+		img, err := image.Init(from, false)
+		if err != nil {
+			return fmt.Errorf("while opening %s: %v", from, err)
+		}
+		_ = img.File.Close()
+		if img.Type != image.OCISIF {
+			return fmt.Errorf("%s is not an OCI-SIF image", from)
+		}
+	}
+
+	// insert base metadata before unpacking fs
+	if err = makeBaseEnv(b.RootfsPath); err != nil {
+		return fmt.Errorf("while inserting base environment: %v", err)
+	}
+
+	cp.LocalPacker = &OCISIFPacker{
+		srcFile: imagePath,
+		b:       b,
+	}
+	return nil
+}
+
+// pullOCISIF pulls the OCI image ref as an OCI-SIF image, in the image cache
+// or in the bundle temporary directory when the cache is disabled, and
+// returns its path.
+func pullOCISIF(ctx context.Context, b *types.Bundle, ref string) (string, error) {
+	authConfig := b.Opts.OCIAuthConfig
+	if authConfig == nil && b.Opts.DockerAuthConfig != nil {
+		authConfig = &authn.AuthConfig{
+			Username:      b.Opts.DockerAuthConfig.Username,
+			Password:      b.Opts.DockerAuthConfig.Password,
+			IdentityToken: b.Opts.DockerAuthConfig.IdentityToken,
+		}
+	}
+
+	opts := ocisif.PullOptions{
+		TmpDir:      b.TmpDir,
+		OciAuth:     authConfig,
+		DockerHost:  b.Opts.DockerDaemonHost,
+		NoHTTPS:     b.Opts.NoHTTPS,
+		Platform:    b.Opts.Platform,
+		ReqAuthFile: b.Opts.DockerAuthFile,
+	}
+
+	directTo := ""
+	if b.Opts.ImgCache == nil || b.Opts.ImgCache.IsDisabled() {
+		f, err := os.CreateTemp(b.TmpDir, "oci-sif-")
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+		directTo = f.Name()
+	}
+	return ocisif.PullOCISIF(ctx, b.Opts.ImgCache, directTo, ref, opts)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/build/sources"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+)
+
+func TestOCISIFConveyorPackerGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		wantErr string
+	}{
+		{
+			name: "OCISIF",
+			from: "../../../../test/images/empty.oci.sif",
+		},
+		{
+			name:    "NativeSIF",
+			from:    "../../../../test/images/empty.sif",
+			wantErr: "is not an OCI-SIF image",
+		},
+		{
+			name:    "Missing",
+			from:    "../../../../test/images/missing.oci.sif",
+			wantErr: "while opening",
+		},
+		{
+			name:    "Empty",
+			from:    "",
+			wantErr: "no OCI-SIF image specified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := types.NewBundle(t.TempDir(), t.TempDir())
+			if err != nil {
+				t.Fatalf("while creating bundle: %v", err)
+			}
+			b.Recipe = types.Definition{
+				Header: map[string]string{"bootstrap": "ocisif", "from": tt.from},
+			}
+
+			cp := &sources.OCISIFConveyorPacker{}
+			err = cp.Get(context.Background(), b)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if cp.LocalPacker == nil {
+					t.Fatalf("no packer set for %s", tt.from)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
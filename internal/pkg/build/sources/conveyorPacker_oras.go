@@ -8,6 +8,7 @@ package sources
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/sylabs/singularity/v4/internal/pkg/client/oras"
@@ -25,8 +26,9 @@ type OrasConveyorPacker struct {
 func (cp *OrasConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err error) {
 	sylog.Debugf("Getting container from registry using ORAS")
 
-	// uri with leading // for oras handlers to consume
-	ref := "//" + b.Recipe.Header["from"]
+	// uri with leading // for oras handlers to consume, the 'From' header
+	// may also be a full oras:// uri
+	ref := "//" + strings.TrimPrefix(b.Recipe.Header["from"], "oras://")
 	// full uri for name determination and output
 	fullRef := "oras:" + ref
 
@@ -0,0 +1,113 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/library"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/oras"
+	"github.com/sylabs/singularity/v4/internal/pkg/ociimage"
+	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+)
+
+// ErrNoDigest is returned by Digest for bootstrap sources whose content can't
+// be identified by a digest, such as package mirrors.
+var ErrNoDigest = errors.New("bootstrap source has no digest")
+
+// Digest returns a digest identifying the content of the image that the
+// bundle b is bootstrapped from, as currently found at its source. Tags are
+// resolved to the digest of the manifest or SIF file they point at, and local
+// image files are hashed. An empty digest is returned for the scratch
+// bootstrap, which has no source.
+func Digest(ctx context.Context, b *types.Bundle) (string, error) {
+	header := b.Recipe.Header
+	bootstrap := header["bootstrap"]
+
+	switch bootstrap {
+	case "scratch":
+		return "", nil
+	case "localimage":
+		return fileDigest(header["from"])
+	case "library":
+		imageRef, pullOpts, err := libraryPullOptions(b)
+		if err != nil {
+			return "", err
+		}
+		return library.ImageHash(ctx, imageRef, pullOpts)
+	case "oras":
+		authConfig := b.Opts.OCIAuthConfig
+		if authConfig == nil && b.Opts.DockerAuthConfig != nil {
+			authConfig = &authn.AuthConfig{
+				Username:      b.Opts.DockerAuthConfig.Username,
+				Password:      b.Opts.DockerAuthConfig.Password,
+				IdentityToken: b.Opts.DockerAuthConfig.IdentityToken,
+			}
+		}
+		ref := "oras://" + strings.TrimPrefix(header["from"], "oras://")
+		h, err := oras.RefHash(ctx, ref, authConfig, b.Opts.DockerAuthFile)
+		if err != nil {
+			return "", err
+		}
+		return h.String(), nil
+	case "ocisif":
+		from := header["from"]
+		if transport, _, ok := strings.Cut(from, ":"); ok && ocitransport.SupportedTransport(transport) != "" {
+			return ociDigest(ctx, b, from)
+		}
+		return fileDigest(from)
+	case ocitransport.SupportedTransport(bootstrap):
+		return ociDigest(ctx, b, ociImageRef(header))
+	}
+	return "", fmt.Errorf("%w: %s", ErrNoDigest, bootstrap)
+}
+
+// ociDigest returns the digest of the manifest of the OCI image ref, for the
+// platform of the build.
+func ociDigest(ctx context.Context, b *types.Bundle, ref string) (string, error) {
+	srcRef, err := ocitransport.ParseImageRef(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image source: %v", err)
+	}
+	d, err := ociimage.ImageDigest(ctx, ociTransportOptions(b), b.Opts.ImgCache, srcRef)
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+// fileDigest returns the SHA256 digest of the local image file at path.
+// Sandbox images are not hashed, as they are directories that may be
+// modified at any time.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %s is not an image file", ErrNoDigest, path)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("while hashing %s: %w", path, err)
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	ociclient "github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+)
+
+// OCISIFPacker holds the locations of where to pack from and to.
+type OCISIFPacker struct {
+	srcFile string
+	b       *types.Bundle
+}
+
+// Pack extracts the root filesystem of an OCI-SIF image into the bundle, and
+// converts its image config to the Singularity runscript and environment.
+func (p *OCISIFPacker) Pack(context.Context) (*types.Bundle, error) {
+	fi, err := sif.LoadContainerFromPath(p.srcFile, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer func() { _ = fi.UnloadContainer() }()
+
+	// We currently only support oci-sif files containing exactly 1 image.
+	ix, err := ocisif.ImageIndexFromFileImage(fi)
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining image index: %w", err)
+	}
+	idxManifest, err := ix.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining index manifest: %w", err)
+	}
+	if len(idxManifest.Manifests) != 1 {
+		return nil, fmt.Errorf("only single image oci-sif files are supported")
+	}
+	img, err := ix.Image(idxManifest.Manifests[0].Digest)
+	if err != nil {
+		return nil, fmt.Errorf("while initializing image: %w", err)
+	}
+
+	// Layers other than the first hold overlayfs whiteouts, which can't be
+	// applied by extraction, so only squashed images are supported.
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining manifest: %w", err)
+	}
+	if len(manifest.Layers) != 1 {
+		return nil, fmt.Errorf("only oci-sif files with a single layer are supported, %s has %d layers", p.srcFile, len(manifest.Layers))
+	}
+	layer := manifest.Layers[0]
+	if layer.MediaType != ociclient.SquashfsLayerMediaType {
+		return nil, fmt.Errorf("unsupported layer mediaType %q", layer.MediaType)
+	}
+	d, err := fi.GetDescriptor(sif.WithOCIBlobDigest(layer.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer descriptor: %w", err)
+	}
+
+	if err := extractSquashfs(d.GetReader(), p.b); err != nil {
+		return nil, fmt.Errorf("root filesystem extraction failed: %s", err)
+	}
+
+	rawConf, err := img.RawConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("while retrieving image config: %w", err)
+	}
+	var imageSpec imgspecv1.Image
+	if err := json.Unmarshal(rawConf, &imageSpec); err != nil {
+		return nil, fmt.Errorf("while parsing image spec: %w", err)
+	}
+
+	// The image config is converted in the same way as for OCI sources.
+	cp := &OCIConveyorPacker{b: p.b, imgConfig: imageSpec.Config}
+	if err := cp.insertScripts(); err != nil {
+		return nil, fmt.Errorf("while inserting runscript and environment: %v", err)
+	}
+	if err := cp.insertOCIConfig(); err != nil {
+		return nil, fmt.Errorf("while inserting oci config: %v", err)
+	}
+	if err := cp.insertOCILabels(); err != nil {
+		return nil, fmt.Errorf("while inserting oci labels: %v", err)
+	}
+
+	return p.b, nil
+}
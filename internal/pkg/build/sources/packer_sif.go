@@ -1,4 +1,4 @@
-// Copyright (c) 2019-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/sylabs/singularity/v4/internal/pkg/image/unpacker"
 	"github.com/sylabs/singularity/v4/pkg/build/types"
 	"github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
@@ -51,13 +50,14 @@ func unpackSIF(b *types.Bundle, img *image.Image) (err error) {
 			return fmt.Errorf("could not extract root filesystem: %s", err)
 		}
 
-		s := unpacker.NewSquashfs()
-
 		// extract root filesystem
-		if err := s.ExtractAll(reader, b.RootfsPath); err != nil {
+		if err := extractSquashfs(reader, b); err != nil {
 			return fmt.Errorf("root filesystem extraction failed: %s", err)
 		}
 	case image.EXT3:
+		if len(b.Opts.OnlyPaths) > 0 {
+			return fmt.Errorf("extracting only some paths is not supported for ext3 partitions")
+		}
 
 		// extract ext3 partition by mounting
 		sylog.Debugf("Ext3 partition detected, mounting to extract.")
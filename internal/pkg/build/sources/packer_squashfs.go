@@ -1,4 +1,4 @@
-// Copyright (c) 2018, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -8,10 +8,15 @@ package sources
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/image/unpacker"
 	"github.com/sylabs/singularity/v4/pkg/build/types"
 	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
 
 // SquashfsPacker holds the locations of where to pack from and to, as well as image offset info
@@ -29,12 +34,32 @@ func (p *SquashfsPacker) Pack(context.Context) (*types.Bundle, error) {
 		return nil, fmt.Errorf("could not extract root filesystem: %s", err)
 	}
 
-	s := unpacker.NewSquashfs()
-
 	// extract root filesystem
-	if err := s.ExtractAll(reader, p.b.RootfsPath); err != nil {
+	if err := extractSquashfs(reader, p.b); err != nil {
 		return nil, fmt.Errorf("root filesystem extraction failed: %s", err)
 	}
 
 	return p.b, nil
 }
+
+// extractSquashfs extracts a squashfs root filesystem read from reader to the
+// bundle rootfs. Only the bundle OnlyPaths are extracted, if set.
+func extractSquashfs(reader io.Reader, b *types.Bundle) error {
+	s := unpacker.NewSquashfs()
+
+	if len(b.Opts.OnlyPaths) == 0 {
+		return s.ExtractAll(reader, b.RootfsPath)
+	}
+
+	sylog.Infof("Extracting only %s from image", strings.Join(b.Opts.OnlyPaths, ", "))
+	if err := s.ExtractFiles(b.Opts.OnlyPaths, reader, b.RootfsPath); err != nil {
+		return err
+	}
+	// unsquashfs silently skips paths that are not in the image
+	for _, p := range b.Opts.OnlyPaths {
+		if _, err := os.Lstat(filepath.Join(b.RootfsPath, p)); err != nil {
+			return fmt.Errorf("path %s not found in image", p)
+		}
+	}
+	return nil
+}
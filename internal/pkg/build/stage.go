@@ -6,11 +6,14 @@
 package build
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/build/files"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
@@ -28,6 +31,8 @@ type stage struct {
 	a Assembler
 	// b is an intermediate structure that encapsulates all information for the container, e.g., metadata, filesystems.
 	b *types.Bundle
+	// snap holds the keys of the snapshots of the stage in the build cache, or nil if the build cache is not used.
+	snap *snapshots
 }
 
 const (
@@ -68,6 +73,16 @@ func (s *stage) runHostScript(name string, script types.Script) error {
 		cmd.Env = os.Environ()
 		cmd.Env = append(cmd.Env, sEnvironment, sRootfs)
 
+		// Host scripts can only be isolated from the network, as CNI networks
+		// are set up by the runtime for %post.
+		switch s.b.Opts.Network {
+		case "":
+		case "none":
+			cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+		default:
+			return fmt.Errorf("%%%s script can only run with the host network or no network, not %q", name, s.b.Opts.Network)
+		}
+
 		sylog.Infof("Running %s scriptlet", name)
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("failed to run %%%s script: %v", name, err)
@@ -76,30 +91,44 @@ func (s *stage) runHostScript(name string, script types.Script) error {
 	return nil
 }
 
-func (s *stage) runPostScript(configFile, sessionResolv, sessionHosts string) error {
-	if s.b.Recipe.BuildData.Post.Script != "" {
-		useBuildConfig := os.Geteuid() == 0 || buildcfg.SINGULARITY_SUID_INSTALL == 0
+// writableExecArgs returns the arguments of a singularity exec command
+// running in the writable root filesystem of the stage, as %post does.
+func (s *stage) writableExecArgs(configFile, sessionResolv, sessionHosts string) []string {
+	useBuildConfig := os.Geteuid() == 0 || buildcfg.SINGULARITY_SUID_INSTALL == 0
 
-		cmdArgs := []string{}
-		if useBuildConfig {
-			cmdArgs = append(cmdArgs, "-c", configFile)
-		}
+	cmdArgs := []string{}
+	if useBuildConfig {
+		cmdArgs = append(cmdArgs, "-c", configFile)
+	}
 
-		cmdArgs = append(cmdArgs, "-s", "exec", "--pwd", "/", "--writable")
-		cmdArgs = append(cmdArgs, "--cleanenv", "--env", sEnvironment, "--env", sLabels)
+	cmdArgs = append(cmdArgs, "-s", "exec", "--pwd", "/", "--writable")
+	cmdArgs = append(cmdArgs, "--cleanenv", "--env", sEnvironment, "--env", sLabels)
 
-		// As non-root, non-fakeroot we must use the system config, subtracting any
-		// bind path, home, and devpts mounts.
-		if !useBuildConfig {
-			cmdArgs = append(cmdArgs, "--no-mount", "bind-paths,home,devpts")
-		}
+	// As non-root, non-fakeroot we must use the system config, subtracting any
+	// bind path, home, and devpts mounts.
+	if !useBuildConfig {
+		cmdArgs = append(cmdArgs, "--no-mount", "bind-paths,home,devpts")
+	}
 
-		if sessionResolv != "" {
-			cmdArgs = append(cmdArgs, "-B", sessionResolv+":/etc/resolv.conf")
-		}
-		if sessionHosts != "" {
-			cmdArgs = append(cmdArgs, "-B", sessionHosts+":/etc/hosts")
+	if sessionResolv != "" {
+		cmdArgs = append(cmdArgs, "-B", sessionResolv+":/etc/resolv.conf")
+	}
+	if sessionHosts != "" {
+		cmdArgs = append(cmdArgs, "-B", sessionHosts+":/etc/hosts")
+	}
+
+	if network := s.b.Opts.Network; network != "" {
+		cmdArgs = append(cmdArgs, "--net", "--network", network)
+		for _, a := range s.b.Opts.NetworkArgs {
+			cmdArgs = append(cmdArgs, "--network-args", a)
 		}
+	}
+	return cmdArgs
+}
+
+func (s *stage) runPostScript(configFile, sessionResolv, sessionHosts string) error {
+	if s.b.Recipe.BuildData.Post.Script != "" {
+		cmdArgs := s.writableExecArgs(configFile, sessionResolv, sessionHosts)
 
 		script := s.b.Recipe.BuildData.Post
 		scriptPath := filepath.Join(s.b.RootfsPath, ".post.script")
@@ -134,38 +163,54 @@ func (s *stage) runPostScript(configFile, sessionResolv, sessionHosts string) er
 	return nil
 }
 
-func (s *stage) runTestScript(configFile, sessionResolv, sessionHosts string) error {
-	if !s.b.Opts.NoTest && s.b.Recipe.BuildData.Test.Script != "" {
-		useBuildConfig := os.Geteuid() == 0 || buildcfg.SINGULARITY_SUID_INSTALL == 0
-
-		cmdArgs := []string{}
-		if useBuildConfig {
-			cmdArgs = append(cmdArgs, "-c", configFile)
-		}
-
-		cmdArgs = append(cmdArgs, "-s", "test", "--pwd", "/")
+// primeCaches runs ldconfig in the root filesystem of the stage, so that the
+// image holds an up to date /etc/ld.so.cache and programs started in it do
+// not search the library directories for their shared libraries. A root
+// filesystem without ldconfig is left as is. It also writes the PATH cache of
+// the image, used by the runtime to resolve the command of the container
+// without searching the PATH directories.
+func (s *stage) primeCaches(configFile, sessionResolv, sessionHosts string) error {
+	cmdArgs := s.writableExecArgs(configFile, sessionResolv, sessionHosts)
+	cmdArgs = append(cmdArgs, s.b.RootfsPath)
+	if os.Getenv("SINGULARITY_PROOT") != "" {
+		cmdArgs = append(cmdArgs, "/.singularity.d/libs/proot", "-0")
+	}
+	cmdArgs = append(cmdArgs, "/bin/sh", "-c", primeCachesScript)
 
-		// As non-root, non-fakeroot we must use the system config, subtracting any
-		// bind path, home, and devpts mounts.
-		if !useBuildConfig {
-			cmdArgs = append(cmdArgs, "--no-mount", "bind-paths,home,devpts")
-		}
+	exe := filepath.Join(buildcfg.BINDIR, "singularity")
+	cmd := exec.Command(exe, cmdArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = "/"
+	cmd.Env = currentEnvNoSingularity([]string{"DEBUG", "NV", "NVCCLI", "ROCM", "BINDPATH", "MOUNT", "PROOT"})
 
-		if sessionResolv != "" {
-			cmdArgs = append(cmdArgs, "-B", sessionResolv+":/etc/resolv.conf")
-		}
-		if sessionHosts != "" {
-			cmdArgs = append(cmdArgs, "-B", sessionHosts+":/etc/hosts")
-		}
+	sylog.Infof("Generating /etc/ld.so.cache and PATH cache")
+	return cmd.Run()
+}
 
-		cmdArgs = append(cmdArgs, s.b.RootfsPath)
+// primeCachesScript generates /etc/ld.so.cache with the ldconfig of the
+// container, if any, and the PATH cache of the image, holding the PATH of
+// the container followed by the executables found on it, in PATH order.
+const primeCachesScript = `if command -v ldconfig >/dev/null 2>&1; then
+	ldconfig
+else
+	echo "ldconfig not found in container, /etc/ld.so.cache not generated" >&2
+fi
+printf '%s\n' "$PATH" >/.singularity.d/path.cache
+IFS=:
+for dir in $PATH; do
+	for f in "$dir"/*; do
+		if [ -f "$f" ] && [ -x "$f" ]; then
+			printf '%s\n' "$f"
+		fi
+	done
+done >>/.singularity.d/path.cache`
 
-		exe := filepath.Join(buildcfg.BINDIR, "singularity")
-		cmd := exec.Command(exe, cmdArgs...)
+func (s *stage) runTestScript(configFile, sessionResolv, sessionHosts string) error {
+	if !s.b.Opts.NoTest && s.b.Recipe.BuildData.Test.Script != "" {
+		cmd := s.testCommand(configFile, sessionResolv, sessionHosts, s.b.RootfsPath, false)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
-		cmd.Dir = "/"
-		cmd.Env = currentEnvNoSingularity([]string{"DEBUG", "NV", "NVCCLI", "ROCM", "BINDPATH", "MOUNT", "WRITABLE_TMPFS", "PROOT"})
 
 		sylog.Infof("Running testscript")
 		return cmd.Run()
@@ -173,6 +218,66 @@ func (s *stage) runTestScript(configFile, sessionResolv, sessionHosts string) er
 	return nil
 }
 
+// runImageTests executes the test script of the built image at path, which is
+// an OCI-SIF when oci is set, and returns its result. The output of the test
+// is both displayed and recorded in the result.
+func (s *stage) runImageTests(configFile, sessionResolv, sessionHosts, path string, oci bool) testResult {
+	r := testResult{image: path, started: time.Now()}
+	if s.b.Recipe.BuildData.Test.Script == "" {
+		sylog.Warningf("No %%test section in definition, no tests to run in %s", path)
+		r.skipped = true
+		return r
+	}
+
+	var output bytes.Buffer
+	cmd := s.testCommand(configFile, sessionResolv, sessionHosts, path, oci)
+	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &output)
+
+	sylog.Infof("Running tests in built image %s", path)
+	r.err = cmd.Run()
+	r.duration = time.Since(r.started)
+	r.output = output.Bytes()
+	return r
+}
+
+// testCommand returns the command running the test script of the image or
+// root filesystem at target, with the build configuration.
+func (s *stage) testCommand(configFile, sessionResolv, sessionHosts, target string, oci bool) *exec.Cmd {
+	useBuildConfig := os.Geteuid() == 0 || buildcfg.SINGULARITY_SUID_INSTALL == 0
+
+	cmdArgs := []string{}
+	if useBuildConfig {
+		cmdArgs = append(cmdArgs, "-c", configFile)
+	}
+
+	cmdArgs = append(cmdArgs, "-s", "test", "--pwd", "/")
+	if oci {
+		cmdArgs = append(cmdArgs, "--oci")
+	}
+
+	// As non-root, non-fakeroot we must use the system config, subtracting any
+	// bind path, home, and devpts mounts.
+	if !useBuildConfig {
+		cmdArgs = append(cmdArgs, "--no-mount", "bind-paths,home,devpts")
+	}
+
+	if sessionResolv != "" {
+		cmdArgs = append(cmdArgs, "-B", sessionResolv+":/etc/resolv.conf")
+	}
+	if sessionHosts != "" {
+		cmdArgs = append(cmdArgs, "-B", sessionHosts+":/etc/hosts")
+	}
+
+	cmdArgs = append(cmdArgs, target)
+
+	exe := filepath.Join(buildcfg.BINDIR, "singularity")
+	cmd := exec.Command(exe, cmdArgs...)
+	cmd.Dir = "/"
+	cmd.Env = currentEnvNoSingularity([]string{"DEBUG", "NV", "NVCCLI", "ROCM", "BINDPATH", "MOUNT", "WRITABLE_TMPFS", "PROOT"})
+	return cmd
+}
+
 func (s *stage) copyFilesFrom(b *Build) error {
 	def := s.b.Recipe
 	for _, f := range def.BuildData.Files {
@@ -181,16 +286,24 @@ func (s *stage) copyFilesFrom(b *Build) error {
 			continue
 		}
 
-		stageIndex, err := b.findStageIndex(stageName)
-		if err != nil {
-			return err
+		var srcRootfsPath string
+		if uri := f.Image(); uri != "" {
+			img, ok := b.images[uri]
+			if !ok {
+				return fmt.Errorf("image %s was not retrieved", uri)
+			}
+			srcRootfsPath = img.b.RootfsPath
+			sylog.Debugf("Copying files from image: %s", uri)
+		} else {
+			stageIndex, err := b.findStageIndex(stageName)
+			if err != nil {
+				return err
+			}
+			srcRootfsPath = b.stages[stageIndex].b.RootfsPath
+			sylog.Debugf("Copying files from stage: %s", stageName)
 		}
-
-		srcRootfsPath := b.stages[stageIndex].b.RootfsPath
 		dstRootfsPath := s.b.RootfsPath
 
-		sylog.Debugf("Copying files from stage: %s", stageName)
-
 		// iterate through filetransfers
 		for _, transfer := range f.Files {
 			// sanity
@@ -14,6 +14,7 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -52,8 +53,18 @@ const (
 	OrasCacheType = "oras"
 	// NetCacheType specifies the cache holds images pulled from http(s) internet sources
 	NetCacheType = "net"
+	// GlobusCacheType specifies the cache holds images transferred from Globus collections
+	GlobusCacheType = "globus"
+	// PluginCacheType specifies the cache holds images pulled through plugin image transports
+	PluginCacheType = "plugin"
+	// KeyCacheType specifies the cache holds public keys fetched from keyservers to verify images
+	KeyCacheType = "key"
+	// UploadCacheType specifies the cache holds the state of interrupted multipart uploads to the library
+	UploadCacheType = "upload"
 	// OciSifCachetType specifies cache holds OCI-SIF conversions of OCI sources.
 	OciSifCacheType = "oci-sif"
+	// BuildCacheType specifies the cache holds snapshots of root filesystems taken during definition file builds
+	BuildCacheType = "build"
 
 	// OciBlobCacheType specifies the cache holds OCI blobs (layers) pulled from OCI sources
 	OciBlobCacheType = "blob"
@@ -68,6 +79,11 @@ var (
 		OrasCacheType,
 		NetCacheType,
 		OciSifCacheType,
+		GlobusCacheType,
+		PluginCacheType,
+		KeyCacheType,
+		UploadCacheType,
+		BuildCacheType,
 	}
 	// OciCacheTypes lists the OCI layout cache types, that store OCI blob content in a single OCI layout directory.
 	OciCacheTypes = []string{
@@ -98,6 +114,17 @@ type Handle struct {
 	rootDir string
 	// If the cache is disabled
 	disabled bool
+	// hits counts the entries found in the cache by GetEntry
+	hits atomic.Int64
+}
+
+// Hits returns the number of entries that GetEntry found already present in
+// the cache, since the handle was created.
+func (h *Handle) Hits() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.hits.Load()
 }
 
 func (h *Handle) GetFileCacheDir(cacheType string) (cacheDir string, err error) {
@@ -206,6 +233,7 @@ func (h *Handle) GetEntry(cacheType string, hash string) (e *Entry, err error) {
 
 	// It exists in the cache and it's a file. Caller can use the Path directly
 	e.Exists = true
+	h.hits.Add(1)
 	return e, nil
 }
 
@@ -0,0 +1,65 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"os"
+	"strings"
+)
+
+// BatchJob describes the batch scheduler job that a process runs in.
+type BatchJob struct {
+	// Scheduler is the name of the batch scheduler, i.e. slurm, lsf, or pbs.
+	Scheduler string
+	// ID is the job ID, as set in the environment by the scheduler.
+	ID string
+	// Group is the cgroup of the process, inside the cgroup of the job.
+	Group string
+}
+
+// batchSchedulers lists the supported batch schedulers, with the environment
+// variable holding the job ID, and the prefix of the job ID in the name of the
+// job cgroup.
+var batchSchedulers = []struct {
+	name     string
+	idEnv    string
+	idPrefix string
+}{
+	// e.g. /slurm/uid_1000/job_1234/step_0, /system.slice/slurmstepd.scope/job_1234/step_0
+	{"slurm", "SLURM_JOB_ID", "job_"},
+	// e.g. /lsf/cluster1/job.1234.0.1700000000
+	{"lsf", "LSB_JOBID", "job."},
+	// e.g. /pbs_jobs.service/jobid/1234.server, /torque/1234.server
+	{"pbs", "PBS_JOBID", ""},
+}
+
+// DetectBatchJob returns the batch scheduler job that process pid runs in,
+// or nil if the calling process is not part of a Slurm, LSF, or PBS job, or if
+// pid is not in the cgroup of that job.
+func DetectBatchJob(pid int) (*BatchJob, error) {
+	group, err := pidToPath(pid)
+	if err != nil {
+		return nil, err
+	}
+	return batchJob(os.Getenv, group), nil
+}
+
+// batchJob returns the batch job found in the environment given by getenv, if
+// group is a cgroup of that job.
+func batchJob(getenv func(string) string, group string) *BatchJob {
+	for _, s := range batchSchedulers {
+		id := getenv(s.idEnv)
+		if id == "" {
+			continue
+		}
+		for _, elem := range strings.Split(group, "/") {
+			if elem == s.idPrefix+id || strings.HasPrefix(elem, s.idPrefix+id+".") {
+				return &BatchJob{Scheduler: s.name, ID: id, Group: group}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBatchJob(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		group string
+		want  *BatchJob
+	}{
+		{
+			name:  "NoJob",
+			group: "/user.slice/user-1000.slice/session-1.scope",
+			want:  nil,
+		},
+		{
+			name:  "SlurmV1",
+			env:   map[string]string{"SLURM_JOB_ID": "1234"},
+			group: "/slurm/uid_1000/job_1234/step_0",
+			want:  &BatchJob{Scheduler: "slurm", ID: "1234", Group: "/slurm/uid_1000/job_1234/step_0"},
+		},
+		{
+			name:  "SlurmV2",
+			env:   map[string]string{"SLURM_JOB_ID": "1234"},
+			group: "/system.slice/slurmstepd.scope/job_1234/step_0/user/task_0",
+			want:  &BatchJob{Scheduler: "slurm", ID: "1234", Group: "/system.slice/slurmstepd.scope/job_1234/step_0/user/task_0"},
+		},
+		{
+			name:  "SlurmOtherJob",
+			env:   map[string]string{"SLURM_JOB_ID": "1234"},
+			group: "/slurm/uid_1000/job_12345/step_0",
+			want:  nil,
+		},
+		{
+			name:  "SlurmOutsideJob",
+			env:   map[string]string{"SLURM_JOB_ID": "1234"},
+			group: "/user.slice/user-1000.slice/session-1.scope",
+			want:  nil,
+		},
+		{
+			name:  "LSF",
+			env:   map[string]string{"LSB_JOBID": "42"},
+			group: "/lsf/cluster1/job.42.0.1700000000",
+			want:  &BatchJob{Scheduler: "lsf", ID: "42", Group: "/lsf/cluster1/job.42.0.1700000000"},
+		},
+		{
+			name:  "PBS",
+			env:   map[string]string{"PBS_JOBID": "7.server"},
+			group: "/pbs_jobs.service/jobid/7.server",
+			want:  &BatchJob{Scheduler: "pbs", ID: "7.server", Group: "/pbs_jobs.service/jobid/7.server"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := batchJob(getenv, tt.group); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("batchJob() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
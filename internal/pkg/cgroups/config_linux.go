@@ -7,11 +7,14 @@ package cgroups
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/sys/unix"
 )
 
 func Int64ptr(i int) *int64 {
@@ -145,6 +148,45 @@ type LinuxDeviceCgroup struct {
 	Minor *int64 `toml:"minor" json:"minor,omitempty"`
 	// Cgroup access permissions format, rwm.
 	Access string `toml:"access" json:"access,omitempty"`
+	// Path of a device node, from which the type, major and minor numbers are
+	// set when the configuration is loaded.
+	Path string `toml:"path,omitempty" json:"-"`
+}
+
+// resolve sets the type, major and minor numbers of a device rule given by
+// path, and checks that the rule is valid.
+func (d *LinuxDeviceCgroup) resolve() error {
+	if d.Path != "" {
+		if d.Type != "" || d.Major != nil || d.Minor != nil {
+			return fmt.Errorf("device rule for %s cannot also set type, major or minor", d.Path)
+		}
+		var st unix.Stat_t
+		if err := unix.Stat(d.Path, &st); err != nil {
+			return fmt.Errorf("device rule for %s: %w", d.Path, err)
+		}
+		switch st.Mode & unix.S_IFMT {
+		case unix.S_IFCHR:
+			d.Type = "c"
+		case unix.S_IFBLK:
+			d.Type = "b"
+		default:
+			return fmt.Errorf("device rule for %s: not a character or block device", d.Path)
+		}
+		major := int64(unix.Major(st.Rdev))
+		minor := int64(unix.Minor(st.Rdev))
+		d.Major = &major
+		d.Minor = &minor
+	}
+
+	switch d.Type {
+	case "", "a", "b", "c":
+	default:
+		return fmt.Errorf("invalid device rule type %q, must be one of a, b, c", d.Type)
+	}
+	if strings.Trim(d.Access, "rwm") != "" {
+		return fmt.Errorf("invalid device rule access %q, must be a combination of r, w, m", d.Access)
+	}
+	return nil
 }
 
 // Config has container runtime resource constraints
@@ -204,7 +246,15 @@ func LoadConfig(confPath string) (config Config, err error) {
 	}
 
 	// Unmarshal config file
-	err = toml.Unmarshal(b, &config)
+	if err = toml.Unmarshal(b, &config); err != nil {
+		return
+	}
+
+	for i := range config.Devices {
+		if err = config.Devices[i].resolve(); err != nil {
+			return
+		}
+	}
 	return
 }
 
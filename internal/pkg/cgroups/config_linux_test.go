@@ -0,0 +1,89 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigDevices(t *testing.T) {
+	tests := []struct {
+		name      string
+		toml      string
+		wantType  string
+		wantMajor int64
+		wantMinor int64
+		wantErr   bool
+	}{
+		{
+			name:      "Path",
+			toml:      "[[devices]]\nallow = false\npath = \"/dev/null\"\naccess = \"rw\"\n",
+			wantType:  "c",
+			wantMajor: 1,
+			wantMinor: 3,
+		},
+		{
+			name:      "Numbers",
+			toml:      "[[devices]]\nallow = true\ntype = \"b\"\nmajor = 7\nminor = 0\naccess = \"r\"\n",
+			wantType:  "b",
+			wantMajor: 7,
+			wantMinor: 0,
+		},
+		{
+			name:    "PathAndNumbers",
+			toml:    "[[devices]]\nallow = true\npath = \"/dev/null\"\nmajor = 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "NotDevice",
+			toml:    "[[devices]]\nallow = true\npath = \"/etc/passwd\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "BadType",
+			toml:    "[[devices]]\nallow = true\ntype = \"x\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "BadAccess",
+			toml:    "[[devices]]\nallow = true\ntype = \"a\"\naccess = \"rx\"\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cgroups.toml")
+			if err := os.WriteFile(path, []byte(tt.toml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			spec, err := LoadResources(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(spec.Devices) != 1 {
+				t.Fatalf("got %d device rules, want 1", len(spec.Devices))
+			}
+			d := spec.Devices[0]
+			if d.Type != tt.wantType {
+				t.Errorf("got type %q, want %q", d.Type, tt.wantType)
+			}
+			if d.Major == nil || *d.Major != tt.wantMajor {
+				t.Errorf("got major %v, want %d", d.Major, tt.wantMajor)
+			}
+			if d.Minor == nil || *d.Minor != tt.wantMinor {
+				t.Errorf("got minor %v, want %d", d.Minor, tt.wantMinor)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	lccgroups "github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// userControllersFile returns the path of the file listing the cgroups v2
+// controllers that systemd delegates to the user manager of uid.
+func userControllersFile(uid int) string {
+	return filepath.Join(unifiedMountPoint, "user.slice",
+		fmt.Sprintf("user-%d.slice", uid),
+		fmt.Sprintf("user@%d.service", uid),
+		"cgroup.controllers")
+}
+
+// requiredControllers returns the cgroups v2 controllers needed to apply
+// resources.
+func requiredControllers(resources *specs.LinuxResources) []string {
+	if resources == nil {
+		return nil
+	}
+	controllers := []string{}
+	if cpu := resources.CPU; cpu != nil {
+		if cpu.Shares != nil || cpu.Quota != nil || cpu.Period != nil {
+			controllers = append(controllers, "cpu")
+		}
+		if cpu.Cpus != "" || cpu.Mems != "" {
+			controllers = append(controllers, "cpuset")
+		}
+	}
+	if resources.Memory != nil {
+		controllers = append(controllers, "memory")
+	}
+	if resources.Pids != nil {
+		controllers = append(controllers, "pids")
+	}
+	if resources.BlockIO != nil {
+		controllers = append(controllers, "io")
+	}
+	if len(resources.HugepageLimits) > 0 {
+		controllers = append(controllers, "hugetlb")
+	}
+	if len(resources.Rdma) > 0 {
+		controllers = append(controllers, "rdma")
+	}
+	return controllers
+}
+
+// missingControllers returns the controllers in required that are not listed
+// in available, the content of a cgroup.controllers file.
+func missingControllers(required []string, available string) []string {
+	have := map[string]bool{}
+	for _, c := range strings.Fields(available) {
+		have[c] = true
+	}
+	missing := []string{}
+	for _, c := range required {
+		if !have[c] {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// checkHugepageSizes checks that the page sizes of hugepage limits are
+// supported by the host, from sizes.
+func checkHugepageSizes(limits []specs.LinuxHugepageLimit, sizes []string) error {
+	for _, l := range limits {
+		found := false
+		for _, s := range sizes {
+			if l.Pagesize == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("hugepage size %q is not supported by the host, supported sizes are: %s", l.Pagesize, strings.Join(sizes, ", "))
+		}
+	}
+	return nil
+}
+
+// CheckResources checks that resources can be applied, as limits on a
+// container cgroup, by the current user. With cgroups v2, the controllers for
+// the requested limits must be available. root can otherwise always apply
+// limits. A non-root user requires cgroups v2 in unified mode, systemd as the
+// cgroups manager, and delegation of the controllers for the requested limits
+// to their systemd user manager.
+func CheckResources(resources *specs.LinuxResources, systemd bool) error {
+	if resources != nil && len(resources.HugepageLimits) > 0 {
+		if err := checkHugepageSizes(resources.HugepageLimits, lccgroups.HugePageSizes()); err != nil {
+			return err
+		}
+	}
+
+	required := requiredControllers(resources)
+	if lccgroups.IsCgroup2UnifiedMode() && len(required) > 0 {
+		b, err := os.ReadFile(filepath.Join(unifiedMountPoint, "cgroup.controllers"))
+		if err != nil {
+			return fmt.Errorf("could not determine the available cgroup controllers: %w", err)
+		}
+		if missing := missingControllers(required, string(b)); len(missing) > 0 {
+			return fmt.Errorf("the requested resource limits require cgroup controllers that are not available on this host: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	uid := os.Getuid()
+	if uid == 0 {
+		return nil
+	}
+
+	if !lccgroups.IsCgroup2UnifiedMode() {
+		return fmt.Errorf("applying resource limits as a non-root user requires cgroups v2 in unified mode")
+	}
+	if !systemd {
+		return fmt.Errorf("applying resource limits as a non-root user requires 'systemd cgroups' to be enabled in singularity.conf")
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	controllersFile := userControllersFile(uid)
+	b, err := os.ReadFile(controllersFile)
+	if err != nil {
+		return fmt.Errorf("could not determine the cgroup controllers delegated to your user, check that a systemd user session is running: %w", err)
+	}
+	if missing := missingControllers(required, string(b)); len(missing) > 0 {
+		return fmt.Errorf("the requested resource limits require cgroup controllers that are not delegated to your user: %s (an administrator can enable delegation with a systemd 'Delegate=' drop-in for user@.service)", strings.Join(missing, ", "))
+	}
+	return nil
+}
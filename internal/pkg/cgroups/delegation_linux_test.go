@@ -0,0 +1,92 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestRequiredControllers(t *testing.T) {
+	quota := int64(50000)
+	limit := int64(1024)
+	weight := uint16(500)
+
+	tests := []struct {
+		name      string
+		resources *specs.LinuxResources
+		want      []string
+	}{
+		{
+			name:      "Nil",
+			resources: nil,
+			want:      nil,
+		},
+		{
+			name:      "Empty",
+			resources: &specs.LinuxResources{},
+			want:      []string{},
+		},
+		{
+			name: "All",
+			resources: &specs.LinuxResources{
+				CPU:     &specs.LinuxCPU{Quota: &quota, Cpus: "0-1"},
+				Memory:  &specs.LinuxMemory{Limit: &limit},
+				Pids:    &specs.LinuxPids{Limit: 10},
+				BlockIO: &specs.LinuxBlockIO{Weight: &weight},
+			},
+			want: []string{"cpu", "cpuset", "memory", "pids", "io"},
+		},
+		{
+			name: "HugetlbRdma",
+			resources: &specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 1024}},
+				Rdma:           map[string]specs.LinuxRdma{"mlx5_0": {}},
+			},
+			want: []string{"hugetlb", "rdma"},
+		},
+		{
+			name: "CpusetOnly",
+			resources: &specs.LinuxResources{
+				CPU: &specs.LinuxCPU{Mems: "0"},
+			},
+			want: []string{"cpuset"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requiredControllers(tt.resources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requiredControllers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingControllers(t *testing.T) {
+	required := []string{"cpu", "memory", "pids", "io"}
+
+	if got := missingControllers(required, "cpuset cpu io memory pids\n"); len(got) != 0 {
+		t.Errorf("missingControllers() = %v, want none", got)
+	}
+	want := []string{"cpu", "io"}
+	if got := missingControllers(required, "memory pids\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("missingControllers() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckHugepageSizes(t *testing.T) {
+	sizes := []string{"2MB", "1GB"}
+
+	if err := checkHugepageSizes([]specs.LinuxHugepageLimit{{Pagesize: "1GB"}}, sizes); err != nil {
+		t.Errorf("checkHugepageSizes() unexpected error: %v", err)
+	}
+	if err := checkHugepageSizes([]specs.LinuxHugepageLimit{{Pagesize: "2M"}}, sizes); err == nil {
+		t.Errorf("checkHugepageSizes() expected error for unsupported size")
+	}
+}
@@ -105,6 +105,20 @@ func (m *Manager) GetCgroupRelPath() (relPath string, err error) {
 	return filepath.Clean(pathParts[1]), nil
 }
 
+// GetCgroupPaths returns the absolute paths of the managed cgroup. For v1
+// cgroups the paths are keyed by subsystem. For v2 there is a single path, with
+// an empty key.
+func (m *Manager) GetCgroupPaths() (paths map[string]string, err error) {
+	if m.group == "" || m.cgroup == nil {
+		return nil, ErrUnitialized
+	}
+
+	if lccgroups.IsCgroup2UnifiedMode() {
+		return map[string]string{"": m.cgroup.Path("")}, nil
+	}
+	return m.cgroup.GetPaths(), nil
+}
+
 // GetStats wraps the Manager.GetStats from runc
 func (m *Manager) GetStats() (*lccgroups.Stats, error) {
 	stats, err := m.cgroup.GetStats()
@@ -114,6 +128,15 @@ func (m *Manager) GetStats() (*lccgroups.Stats, error) {
 	return stats, nil
 }
 
+// GetPids returns the PIDs of the processes in the managed cgroup, and its
+// sub-cgroups.
+func (m *Manager) GetPids() ([]int, error) {
+	if m.group == "" || m.cgroup == nil {
+		return nil, ErrUnitialized
+	}
+	return m.cgroup.GetAllPids()
+}
+
 // UpdateFromSpec updates the existing managed cgroup using configuration from
 // an OCI LinuxResources spec struct.
 func (m *Manager) UpdateFromSpec(resources *specs.LinuxResources) (err error) {
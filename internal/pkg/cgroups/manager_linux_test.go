@@ -7,6 +7,7 @@ package cgroups
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -17,6 +18,8 @@ import (
 	"testing"
 	"time"
 
+	lccgroups "github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/v4/internal/pkg/test"
 	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
 )
@@ -38,6 +41,14 @@ func TestCgroups(t *testing.T) {
 			name:     "GetFromPid",
 			testFunc: testGetFromPid,
 		},
+		{
+			name:     "GetCgroupPaths",
+			testFunc: testGetCgroupPaths,
+		},
+		{
+			name:     "WatchOOM",
+			testFunc: testWatchOOM,
+		},
 	}
 	runCgroupfsTests(t, tests)
 	runSystemdTests(t, tests)
@@ -87,6 +98,115 @@ func testGetFromPid(t *testing.T, systemd bool) {
 	}
 }
 
+func testGetCgroupPaths(t *testing.T, systemd bool) {
+	test.EnsurePrivilege(t)
+	require.Cgroups(t)
+
+	_, manager, cleanup := testManager(t, systemd)
+	defer cleanup()
+
+	rootPath, err := manager.GetCgroupRootPath()
+	if err != nil {
+		t.Fatalf("While getting manager cgroup root path: %v", err)
+	}
+	relPath, err := manager.GetCgroupRelPath()
+	if err != nil {
+		t.Fatalf("While getting manager cgroup relative path: %v", err)
+	}
+
+	paths, err := manager.GetCgroupPaths()
+	if err != nil {
+		t.Fatalf("While getting manager cgroup paths: %v", err)
+	}
+
+	key := "devices"
+	wantPath := filepath.Join(rootPath, "devices", relPath)
+	if lccgroups.IsCgroup2UnifiedMode() {
+		key = ""
+		wantPath = filepath.Join(rootPath, relPath)
+	}
+	if paths[key] != wantPath {
+		t.Errorf("Expected %s for cgroup path, got %s", wantPath, paths[key])
+	}
+}
+
+func testWatchOOM(t *testing.T, systemd bool) {
+	test.EnsurePrivilege(t)
+	require.Cgroups(t)
+
+	// The process allocates memory without bound once it reads from stdin,
+	// after being placed into the cgroup.
+	cmd := exec.Command("/bin/sh", "-c", "read x; exec tail /dev/zero")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("While starting test process: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	pid := cmd.Process.Pid
+	strPid := strconv.Itoa(pid)
+	group := filepath.Join("/singularity", strPid)
+	if systemd {
+		group = "system.slice:singularity:" + strPid
+	}
+
+	limit := int64(16 * 1024 * 1024)
+	manager, err := NewManagerWithSpec(&specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: &limit, Swap: &limit}}, pid, group, systemd)
+	if err != nil {
+		t.Fatalf("While creating new cgroup: %v", err)
+	}
+	defer manager.Destroy()
+
+	count, err := manager.OOMKillCount()
+	if err != nil {
+		t.Fatalf("While getting OOM kill count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected 0 OOM kills, got %d", count)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	counts := make(chan uint64, 1)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- manager.WatchOOM(ctx, func(count uint64) {
+			select {
+			case counts <- count:
+			default:
+			}
+		})
+	}()
+	// Give the watch time to register before triggering the OOM kill.
+	time.Sleep(500 * time.Millisecond)
+
+	if _, err := stdin.Write([]byte("\n")); err != nil {
+		t.Fatalf("While starting allocation: %v", err)
+	}
+
+	select {
+	case count := <-counts:
+		if count != 1 {
+			t.Errorf("Expected 1 OOM kill, got %d", count)
+		}
+	case err := <-watchErr:
+		t.Fatalf("WatchOOM returned before OOM kill: %v", err)
+	case <-ctx.Done():
+		t.Fatalf("OOM kill not reported")
+	}
+
+	cancel()
+	if err := <-watchErr; err != nil {
+		t.Errorf("WatchOOM returned error: %v", err)
+	}
+}
+
 // ensureInt asserts that the content of path is the integer wantInt
 func ensureInt(t *testing.T, path string, wantInt int64) {
 	file, err := os.Open(path)
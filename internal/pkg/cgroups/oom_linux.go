@@ -0,0 +1,143 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	lccgroups "github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fscommon"
+	"golang.org/x/sys/unix"
+)
+
+// oomPollTimeout is how long a wait for OOM events blocks before checking
+// whether the watch has been cancelled, in milliseconds.
+const oomPollTimeout = 500
+
+// oomEventsFile returns the directory of the memory controller of the managed
+// cgroup, and the file of this directory reporting OOM kills.
+func (m *Manager) oomEventsFile() (dir, file string, err error) {
+	paths, err := m.GetCgroupPaths()
+	if err != nil {
+		return "", "", err
+	}
+	if lccgroups.IsCgroup2UnifiedMode() {
+		return paths[""], "memory.events", nil
+	}
+	dir, ok := paths["memory"]
+	if !ok || dir == "" {
+		return "", "", errors.New("memory controller is not enabled for the cgroup")
+	}
+	return dir, "memory.oom_control", nil
+}
+
+// OOMKillCount returns the number of processes of the managed cgroup that
+// have been killed by the OOM killer.
+func (m *Manager) OOMKillCount() (uint64, error) {
+	dir, file, err := m.oomEventsFile()
+	if err != nil {
+		return 0, err
+	}
+	return fscommon.GetValueByKey(dir, file, "oom_kill")
+}
+
+// WatchOOM calls fn with the number of processes of the managed cgroup killed
+// by the OOM killer, each time it increases, until ctx is cancelled or the
+// cgroup is removed. On v2 cgroups memory.events is watched with inotify, on
+// v1 cgroups an eventfd is registered for memory.oom_control.
+func (m *Manager) WatchOOM(ctx context.Context, fn func(count uint64)) error {
+	dir, file, err := m.oomEventsFile()
+	if err != nil {
+		return err
+	}
+
+	var fd int
+	if lccgroups.IsCgroup2UnifiedMode() {
+		fd, err = inotifyFd(filepath.Join(dir, file))
+	} else {
+		fd, err = eventFd(dir, file)
+	}
+	if err != nil {
+		return fmt.Errorf("while registering for OOM events: %w", err)
+	}
+	defer unix.Close(fd)
+
+	last, err := m.OOMKillCount()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4096)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		n, err := unix.Poll(fds, oomPollTimeout)
+		if errors.Is(err, unix.EINTR) || n == 0 {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if _, err := unix.Read(fd, buf); err != nil {
+			return err
+		}
+
+		// The event is also sent when the cgroup is removed.
+		count, err := m.OOMKillCount()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if count > last {
+			fn(count)
+			last = count
+		}
+	}
+}
+
+// inotifyFd returns an inotify file descriptor notified of modifications of
+// the file at path.
+func inotifyFd(path string) (int, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return -1, err
+	}
+	if _, err := unix.InotifyAddWatch(fd, path, unix.IN_MODIFY); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// eventFd returns an eventfd file descriptor registered through the
+// cgroup.event_control file of the v1 cgroup at dir, for events of file.
+func eventFd(dir, file string) (int, error) {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+
+	fd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+	if err != nil {
+		return -1, err
+	}
+	data := fmt.Sprintf("%d %d", fd, f.Fd())
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.event_control"), []byte(data), 0o700); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
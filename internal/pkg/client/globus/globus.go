@@ -0,0 +1,285 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package globus transfers images between a remote Globus collection and the
+// local host, through a local Globus collection, using the Globus Transfer API.
+package globus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+const (
+	// TokenEnv is the environment variable holding the Globus Transfer API
+	// access token.
+	TokenEnv = "SINGULARITY_GLOBUS_TOKEN"
+	// LocalEndpointEnv is the environment variable holding the ID of the
+	// Globus collection through which the local host is accessed. Paths on
+	// the host must be accessible at the same paths in the collection.
+	LocalEndpointEnv = "SINGULARITY_GLOBUS_LOCAL_ENDPOINT"
+)
+
+var (
+	// transferURL is the base URL of the Globus Transfer API.
+	transferURL = "https://transfer.api.globus.org/v0.10"
+	// pollInterval is the interval at which the status of a transfer task
+	// is polled.
+	pollInterval = 5 * time.Second
+)
+
+// URI is a parsed globus://<endpoint>/<path> URI.
+type URI struct {
+	// Endpoint is the ID of the Globus collection.
+	Endpoint string
+	// Path is the absolute path of the image in the collection.
+	Path string
+}
+
+// String returns the URI in globus://<endpoint>/<path> form.
+func (u URI) String() string {
+	return "globus://" + u.Endpoint + u.Path
+}
+
+// ParseReference parses a globus://<endpoint>/<path> URI.
+func ParseReference(ref string) (URI, error) {
+	rest, ok := strings.CutPrefix(ref, "globus://")
+	if !ok {
+		return URI{}, fmt.Errorf("not a globus URI: %s", ref)
+	}
+	endpoint, p, _ := strings.Cut(rest, "/")
+	p = path.Clean("/" + p)
+	if endpoint == "" || p == "/" {
+		return URI{}, fmt.Errorf("invalid globus URI %s, format is globus://<endpoint>/<path>", ref)
+	}
+	return URI{Endpoint: endpoint, Path: p}, nil
+}
+
+// fileInfo holds the information returned by the Transfer API for a file.
+type fileInfo struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Size         int64  `json:"size"`
+	LastModified string `json:"last_modified"`
+}
+
+// task holds the status of a transfer task.
+type task struct {
+	TaskID           string `json:"task_id"`
+	Status           string `json:"status"`
+	NiceStatus       string `json:"nice_status"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+	FatalError       *struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+	} `json:"fatal_error"`
+}
+
+// transferItem is a file to transfer, with its expected checksum, if known.
+type transferItem struct {
+	DataType          string `json:"DATA_TYPE"`
+	SourcePath        string `json:"source_path"`
+	DestinationPath   string `json:"destination_path"`
+	ExternalChecksum  string `json:"external_checksum,omitempty"`
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"`
+}
+
+// transferRequest is the document submitting a transfer task.
+type transferRequest struct {
+	DataType            string         `json:"DATA_TYPE"`
+	SubmissionID        string         `json:"submission_id"`
+	SourceEndpoint      string         `json:"source_endpoint"`
+	DestinationEndpoint string         `json:"destination_endpoint"`
+	Label               string         `json:"label"`
+	VerifyChecksum      bool           `json:"verify_checksum"`
+	Data                []transferItem `json:"DATA"`
+}
+
+// client is a Globus Transfer API client.
+type client struct {
+	token string
+	http  *http.Client
+}
+
+// newClient returns a Transfer API client authenticated with the token in
+// the TokenEnv environment variable.
+func newClient() (*client, error) {
+	token := os.Getenv(TokenEnv)
+	if token == "" {
+		return nil, fmt.Errorf("a Globus Transfer API access token must be set in %s", TokenEnv)
+	}
+	return &client{token: token, http: &http.Client{Timeout: 60 * time.Second}}, nil
+}
+
+// localEndpoint returns the ID of the local Globus collection.
+func localEndpoint() (string, error) {
+	ep := os.Getenv(LocalEndpointEnv)
+	if ep == "" {
+		return "", fmt.Errorf("the ID of the local Globus collection must be set in %s", LocalEndpointEnv)
+	}
+	return ep, nil
+}
+
+// do performs a Transfer API request, decoding the JSON response into out.
+func (c *client) do(ctx context.Context, method, endpoint string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, transferURL+endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", useragent.Value())
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err == nil && apiErr.Message != "" {
+			return fmt.Errorf("globus transfer API: %s: %s", apiErr.Code, apiErr.Message)
+		}
+		return fmt.Errorf("globus transfer API: %s", res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// stat returns the information for the file at u.
+func (c *client) stat(ctx context.Context, u URI) (fileInfo, error) {
+	q := url.Values{}
+	q.Set("path", path.Dir(u.Path))
+	q.Set("filter", "name:"+path.Base(u.Path))
+
+	var res struct {
+		Data []fileInfo `json:"DATA"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/operation/endpoint/"+url.PathEscape(u.Endpoint)+"/ls?"+q.Encode(), nil, &res); err != nil {
+		return fileInfo{}, err
+	}
+	for _, fi := range res.Data {
+		if fi.Name == path.Base(u.Path) && fi.Type == "file" {
+			return fi, nil
+		}
+	}
+	return fileInfo{}, fmt.Errorf("%s: file not found", u)
+}
+
+// transfer submits a task transferring src to dst, with checksum
+// verification, and waits for its completion. If sha256 is set, the
+// destination is also verified against it.
+func (c *client) transfer(ctx context.Context, src, dst URI, sha256 string) error {
+	var sub struct {
+		Value string `json:"value"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/submission_id", nil, &sub); err != nil {
+		return fmt.Errorf("while getting submission ID: %w", err)
+	}
+
+	item := transferItem{
+		DataType:        "transfer_item",
+		SourcePath:      src.Path,
+		DestinationPath: dst.Path,
+	}
+	if sha256 != "" {
+		item.ExternalChecksum = sha256
+		item.ChecksumAlgorithm = "SHA256"
+	}
+	req := transferRequest{
+		DataType:            "transfer",
+		SubmissionID:        sub.Value,
+		SourceEndpoint:      src.Endpoint,
+		DestinationEndpoint: dst.Endpoint,
+		Label:               "singularity " + path.Base(src.Path),
+		VerifyChecksum:      true,
+		Data:                []transferItem{item},
+	}
+
+	var t task
+	if err := c.do(ctx, http.MethodPost, "/transfer", req, &t); err != nil {
+		return fmt.Errorf("while submitting transfer: %w", err)
+	}
+	sylog.Infof("Submitted Globus transfer task %s", t.TaskID)
+
+	return c.wait(ctx, t.TaskID)
+}
+
+// wait polls the status of the task with id until it completes. The task is
+// canceled if ctx is done before then.
+func (c *client) wait(ctx context.Context, id string) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		var t task
+		if err := c.do(ctx, http.MethodGet, "/task/"+url.PathEscape(id), nil, &t); err != nil {
+			if ctx.Err() != nil {
+				return c.cancel(id, ctx.Err())
+			}
+			return fmt.Errorf("while getting status of transfer task %s: %w", id, err)
+		}
+
+		switch t.Status {
+		case "SUCCEEDED":
+			sylog.Infof("Globus transfer task %s succeeded", id)
+			return nil
+		case "FAILED":
+			if t.FatalError != nil {
+				return fmt.Errorf("globus transfer task %s failed: %s: %s", id, t.FatalError.Code, t.FatalError.Description)
+			}
+			return fmt.Errorf("globus transfer task %s failed", id)
+		}
+		sylog.Debugf("Globus transfer task %s is %s (%s), %d bytes transferred", id, t.Status, t.NiceStatus, t.BytesTransferred)
+
+		select {
+		case <-ctx.Done():
+			return c.cancel(id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// cancel cancels the task with id, after the transfer was interrupted by
+// cause.
+func (c *client) cancel(id string, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := c.do(ctx, http.MethodPost, "/task/"+url.PathEscape(id)+"/cancel", nil, nil); err != nil {
+		sylog.Warningf("Unable to cancel Globus transfer task %s: %v", id, err)
+	}
+	return errors.Join(fmt.Errorf("globus transfer task %s canceled", id), cause)
+}
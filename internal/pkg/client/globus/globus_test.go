@@ -0,0 +1,142 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package globus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+func TestMain(m *testing.M) {
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	os.Exit(m.Run())
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    URI
+		wantErr bool
+	}{
+		{"globus://ep/images/lolcow.sif", URI{"ep", "/images/lolcow.sif"}, false},
+		{"globus://ep//images/../lolcow.sif", URI{"ep", "/lolcow.sif"}, false},
+		{"globus://ep", URI{}, true},
+		{"globus:///images/lolcow.sif", URI{}, true},
+		{"oras://ep/images/lolcow.sif", URI{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseReference(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReference() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// transferServer is a mock of the Globus Transfer API, recording the
+// transfer submitted to it, and completing its task with status.
+func transferServer(t *testing.T, status string, got *transferRequest) *httptest.Server {
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/submission_id", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"value": "sub-1"})
+	})
+	mux.HandleFunc("/transfer", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Errorf("while decoding transfer: %v", err)
+		}
+		json.NewEncoder(w).Encode(task{TaskID: "task-1", Status: "ACTIVE"})
+	})
+	mux.HandleFunc("/task/task-1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		tk := task{TaskID: "task-1", Status: "ACTIVE"}
+		if polls > 1 {
+			tk.Status = status
+		}
+		json.NewEncoder(w).Encode(tk)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestPush(t *testing.T) {
+	var got transferRequest
+	srv := transferServer(t, "SUCCEEDED", &got)
+	defer srv.Close()
+
+	defer func(u string, i time.Duration) { transferURL, pollInterval = u, i }(transferURL, pollInterval)
+	transferURL, pollInterval = srv.URL, time.Millisecond
+	t.Setenv(TokenEnv, "token")
+	t.Setenv(LocalEndpointEnv, "local")
+
+	src := filepath.Join(t.TempDir(), "image.sif")
+	if err := os.WriteFile(src, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (Transport{}).Push(context.Background(), src, "globus://remote/images/image.sif", transport.Options{}); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if got.SubmissionID != "sub-1" || got.SourceEndpoint != "local" || got.DestinationEndpoint != "remote" || !got.VerifyChecksum {
+		t.Errorf("unexpected transfer %+v", got)
+	}
+	if len(got.Data) != 1 {
+		t.Fatalf("got %d transfer items, want 1", len(got.Data))
+	}
+	want := transferItem{
+		DataType:          "transfer_item",
+		SourcePath:        src,
+		DestinationPath:   "/images/image.sif",
+		ExternalChecksum:  "6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d",
+		ChecksumAlgorithm: "SHA256",
+	}
+	if got.Data[0] != want {
+		t.Errorf("got transfer item %+v, want %+v", got.Data[0], want)
+	}
+}
+
+func TestDownloadImageFailed(t *testing.T) {
+	var got transferRequest
+	srv := transferServer(t, "FAILED", &got)
+	defer srv.Close()
+
+	defer func(u string, i time.Duration) { transferURL, pollInterval = u, i }(transferURL, pollInterval)
+	transferURL, pollInterval = srv.URL, time.Millisecond
+	t.Setenv(LocalEndpointEnv, "local")
+
+	dst := filepath.Join(t.TempDir(), "image.sif")
+
+	t.Setenv(TokenEnv, "")
+	if err := DownloadImage(context.Background(), dst, "globus://remote/images/image.sif"); err == nil {
+		t.Errorf("DownloadImage() without token succeeded")
+	}
+
+	t.Setenv(TokenEnv, "token")
+	if err := DownloadImage(context.Background(), dst, "globus://remote/images/image.sif"); err == nil {
+		t.Errorf("DownloadImage() of failed transfer succeeded")
+	}
+	if got.SourceEndpoint != "remote" || got.DestinationEndpoint != "local" || got.Data[0].DestinationPath != dst {
+		t.Errorf("unexpected transfer %+v", got)
+	}
+}
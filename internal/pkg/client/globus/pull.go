@@ -0,0 +1,134 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package globus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// DownloadImage transfers the image at the globus URI ref to the local file
+// filePath, through the local Globus collection.
+func DownloadImage(ctx context.Context, filePath, ref string) error {
+	src, err := ParseReference(ref)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	local, err := localEndpoint()
+	if err != nil {
+		return err
+	}
+	filePath, err = filepath.Abs(filePath)
+	if err != nil {
+		return err
+	}
+	return c.transfer(ctx, src, URI{Endpoint: local, Path: filePath}, "")
+}
+
+// pull will transfer a globus image into the cache if directTo="", or a specific file if directTo is set.
+func pull(ctx context.Context, imgCache *cache.Handle, directTo, pullFrom string) (imagePath string, err error) {
+	if directTo != "" {
+		sylog.Infof("Transferring image from Globus")
+		if err := DownloadImage(ctx, directTo, pullFrom); err != nil {
+			return "", err
+		}
+		return directTo, nil
+	}
+
+	u, err := ParseReference(pullFrom)
+	if err != nil {
+		return "", err
+	}
+	c, err := newClient()
+	if err != nil {
+		return "", err
+	}
+
+	// We will cache using a sha256 over the URI, and the size and
+	// modification time of the file in the collection.
+	fi, err := c.stat(ctx, u)
+	if err != nil {
+		return "", fmt.Errorf("while getting image information: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(u.String() + strconv.FormatInt(fi.Size, 10) + fi.LastModified))
+	hash := hex.EncodeToString(h.Sum(nil))
+	sylog.Debugf("Image hash for cache is: %s", hash)
+
+	cacheEntry, err := imgCache.GetEntry(cache.GlobusCacheType, hash)
+	if err != nil {
+		return "", fmt.Errorf("unable to check if %v exists in cache: %v", hash, err)
+	}
+	defer cacheEntry.CleanTmp()
+
+	if !cacheEntry.Exists {
+		sylog.Infof("Transferring image from Globus")
+		if err := DownloadImage(ctx, cacheEntry.TmpPath, pullFrom); err != nil {
+			return "", err
+		}
+		if err := cacheEntry.Finalize(); err != nil {
+			return "", err
+		}
+	} else {
+		sylog.Verbosef("Using image from cache")
+	}
+
+	return cacheEntry.Path, nil
+}
+
+// Pull will transfer a globus image to the cache or direct to a temporary file if cache is disabled
+func Pull(ctx context.Context, imgCache *cache.Handle, pullFrom, tmpDir string) (imagePath string, err error) {
+	directTo := ""
+
+	if imgCache.IsDisabled() {
+		file, err := os.CreateTemp(tmpDir, "sbuild-tmp-cache-")
+		if err != nil {
+			return "", fmt.Errorf("unable to create tmp file: %v", err)
+		}
+		file.Close()
+		directTo = file.Name()
+		sylog.Infof("Transferring globus image to tmp cache: %s", directTo)
+	}
+
+	return pull(ctx, imgCache, directTo, pullFrom)
+}
+
+// PullToFile will transfer a globus image to the specified location, through the cache, or directly if cache is disabled
+func PullToFile(ctx context.Context, imgCache *cache.Handle, pullTo, pullFrom string) (imagePath string, err error) {
+	directTo := ""
+	if imgCache.IsDisabled() {
+		directTo = pullTo
+		sylog.Debugf("Cache disabled, pulling directly to: %s", directTo)
+	}
+
+	src, err := pull(ctx, imgCache, directTo, pullFrom)
+	if err != nil {
+		return "", fmt.Errorf("error fetching image to cache: %v", err)
+	}
+
+	if directTo == "" {
+		// mode is before umask if pullTo doesn't exist
+		err = fs.CopyFileAtomic(src, pullTo, 0o777)
+		if err != nil {
+			return "", fmt.Errorf("error copying image out of cache: %v", err)
+		}
+	}
+
+	return pullTo, nil
+}
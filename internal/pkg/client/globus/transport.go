@@ -0,0 +1,92 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package globus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+)
+
+func init() {
+	transport.Register(Transport{}, "globus")
+}
+
+// Transport is the transport for globus:// URIs, which transfers images
+// between Globus collections and the local host.
+type Transport struct{}
+
+// Resolve returns ref with its path cleaned.
+func (Transport) Resolve(_ context.Context, ref string, _ transport.Options) (string, error) {
+	u, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Fetch transfers the image at ref to dst, or to the cache if dst is not set.
+func (Transport) Fetch(ctx context.Context, imgCache *cache.Handle, ref, dst string, opts transport.Options) (string, error) {
+	if dst == "" {
+		return Pull(ctx, imgCache, ref, opts.TmpDir)
+	}
+	return PullToFile(ctx, imgCache, dst, ref)
+}
+
+// Push transfers the image at src to ref, through the local Globus
+// collection. The image transferred is verified against the SHA256 checksum
+// of src.
+func (Transport) Push(ctx context.Context, src, ref string, _ transport.Options) error {
+	dst, err := ParseReference(ref)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	local, err := localEndpoint()
+	if err != nil {
+		return err
+	}
+	src, err = filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(src)
+	if err != nil {
+		return fmt.Errorf("while computing checksum of %s: %w", src, err)
+	}
+	return c.transfer(ctx, URI{Endpoint: local, Path: src}, dst, sum)
+}
+
+// Digest is not supported for globus URIs, as the Transfer API does not
+// report the checksum of a file.
+func (Transport) Digest(context.Context, string, transport.Options) (string, error) {
+	return "", transport.ErrUnsupported
+}
+
+// fileSHA256 returns the hex encoded SHA256 checksum of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -65,17 +65,24 @@ func getEnvInt(key string, defval int64) int64 {
 	return defval
 }
 
-func getDownloadConfig() (scslibrary.Downloader, error) {
-	// get downloader parameters from config
+func getConfig() (*singularityconf.File, error) {
 	conf := singularityconf.GetCurrentConfig()
 	if conf == nil {
-		// sylog.Fatalf("Unable to get singularity configuration")
 		var err error
 		conf, err = singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
 		if err != nil {
-			sylog.Fatalf("unable to parse singularity.conf file: %s", err)
+			return nil, fmt.Errorf("unable to parse singularity.conf file: %s", err)
 		}
 	}
+	return conf, nil
+}
+
+func getDownloadConfig() (scslibrary.Downloader, error) {
+	// get downloader parameters from config
+	conf, err := getConfig()
+	if err != nil {
+		return scslibrary.Downloader{}, err
+	}
 
 	concurrency := int64(getEnvInt("SINGULARITY_DOWNLOAD_CONCURRENCY", int64(conf.DownloadConcurrency)))
 	partSize := int64(getEnvInt("SINGULARITY_DOWNLOAD_PART_SIZE", int64(conf.DownloadPartSize)))
@@ -98,6 +105,19 @@ func getDownloadConfig() (scslibrary.Downloader, error) {
 	}, nil
 }
 
+func getUploadConcurrency() (int, error) {
+	conf, err := getConfig()
+	if err != nil {
+		return 0, err
+	}
+
+	concurrency := getEnvInt("SINGULARITY_UPLOAD_CONCURRENCY", int64(conf.UploadConcurrency))
+	if concurrency < 1 {
+		return 0, fmt.Errorf("invalid upload concurrency value (%v)", concurrency)
+	}
+	return int(concurrency), nil
+}
+
 // DownloadImage is a helper function to wrap library image download operation
 func DownloadImage(ctx context.Context, c *scslibrary.Client, imagePath, arch string, libraryRef *scslibrary.Ref, pb scslibrary.ProgressBar) error {
 	// open destination file for writing
@@ -118,6 +118,23 @@ func pull(ctx context.Context, imgCache *cache.Handle, directTo string, imageRef
 	return cacheEntry.Path, nil
 }
 
+// ImageHash returns the hash of the native SIF image in the library for
+// imageRef, without downloading it.
+func ImageHash(ctx context.Context, imageRef *scslibrary.Ref, opts PullOptions) (string, error) {
+	c, err := scslibrary.NewClient(opts.LibraryConfig)
+	if err != nil {
+		return "", fmt.Errorf("unable to initialize client library: %w", err)
+	}
+
+	ref := fmt.Sprintf("%s:%s", imageRef.Path, imageRef.Tags[0])
+
+	libraryImage, err := c.GetImage(ctx, opts.Platform.Architecture, ref)
+	if err != nil {
+		return "", err
+	}
+	return libraryImage.Hash, nil
+}
+
 // downloadWrapper calls DownloadImage() and outputs download summary if progressBar not specified.
 func downloadWrapper(ctx context.Context, c *scslibrary.Client, imagePath, arch string, libraryRef *scslibrary.Ref, pb scslibrary.ProgressBar) error {
 	sylog.Infof("Downloading library image")
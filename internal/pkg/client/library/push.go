@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -34,6 +35,10 @@ type PushOptions struct {
 	// LibraryConfig configures operations against the library using its native
 	// API, via sylabs/scs-library-client.
 	LibraryConfig *scslibrary.Config
+	// SessionDir holds the state of interrupted multipart uploads of large
+	// images, so that they can be resumed. Uploads are not resumable when it
+	// is empty.
+	SessionDir string
 }
 
 // Push will upload an image file to the library.
@@ -85,17 +90,44 @@ func pushNative(ctx context.Context, sourceFile string, destRef *scslibrary.Ref,
 		return nil, err
 	}
 
-	var progressBar scslibrary.UploadCallback
-	if term.IsTerminal(2) {
-		progressBar = &progress.UploadBar{}
-	}
+	showProgress := term.IsTerminal(2)
 
 	defer func(t time.Time) {
-		if err == nil && progressBar == nil {
+		if err == nil && !showProgress {
 			sylog.Infof("Uploaded %d bytes in %v\n", fSize, time.Since(t))
 		}
 	}(time.Now())
 
+	// large images are uploaded in parts, concurrently, when supported by the
+	// library
+	if fSize > multipartThreshold {
+		concurrency, err := getUploadConcurrency()
+		if err != nil {
+			return nil, err
+		}
+		u := &multipartUploader{
+			c:           libraryClient,
+			concurrency: concurrency,
+			sessionDir:  opts.SessionDir,
+		}
+		if showProgress {
+			u.pb = &progress.UploadBar{}
+		}
+
+		res, err := u.upload(ctx, f, fSize, destRef.Path, arch, destRef.Tags, opts.Description)
+		if !errors.Is(err, errMultipartUnsupported) {
+			return res, err
+		}
+		sylog.Debugf("Library does not support concurrent multipart uploads")
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	var progressBar scslibrary.UploadCallback
+	if showProgress {
+		progressBar = &progress.UploadBar{}
+	}
 	return libraryClient.UploadImage(ctx, f, destRef.Path, arch, destRef.Tags, opts.Description, progressBar)
 }
 
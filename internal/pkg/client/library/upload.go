@@ -0,0 +1,586 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package library
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blang/semver/v4"
+	jsonresp "github.com/sylabs/json-resp"
+	scslibrary "github.com/sylabs/scs-library-client/client"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/progress"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// multipartThreshold is the image size above which a multipart upload is
+	// attempted. It matches the minimum part size of the library.
+	multipartThreshold = 64 * 1024 * 1024
+
+	// partAttempts is the number of attempts made to upload a part.
+	partAttempts = 4
+)
+
+// partRetryDelay is the delay before the first retry of a part upload. It is
+// doubled on each subsequent retry.
+var partRetryDelay = 2 * time.Second
+
+// errMultipartUnsupported is returned when the library doesn't support
+// multipart uploads through its native API.
+var errMultipartUnsupported = errors.New("multipart upload not supported")
+
+// uploadSession records the parts of a multipart upload which have been
+// uploaded, so that an interrupted upload can be resumed.
+type uploadSession struct {
+	UploadID    string         `json:"uploadID"`
+	Size        int64          `json:"size"`
+	PartSize    int64          `json:"partSize"`
+	TotalParts  int            `json:"totalParts"`
+	S3Compliant bool           `json:"s3Compliant"`
+	Parts       map[int]string `json:"parts"`
+
+	// path is the file the session is saved to, if any.
+	path string
+	mu   sync.Mutex
+}
+
+// part returns the ETag of part n, if it has been uploaded.
+func (s *uploadSession) part(n int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	etag, ok := s.Parts[n]
+	return etag, ok
+}
+
+// setPart records the upload of part n, and saves the session.
+func (s *uploadSession) setPart(n int, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Parts[n] = etag
+	if err := s.save(); err != nil {
+		sylog.Debugf("While saving upload session: %v", err)
+	}
+}
+
+// partRange returns the offset and size of part n.
+func (s *uploadSession) partRange(n int) (int64, int64) {
+	offset := int64(n-1) * s.PartSize
+	size := s.PartSize
+	if offset+size > s.Size {
+		size = s.Size - offset
+	}
+	return offset, size
+}
+
+// uploaded returns the number of bytes already uploaded.
+func (s *uploadSession) uploaded() int64 {
+	var n int64
+	for p := range s.Parts {
+		_, size := s.partRange(p)
+		n += size
+	}
+	return n
+}
+
+func (s *uploadSession) save() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *uploadSession) remove() {
+	if s.path == "" {
+		return
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		sylog.Debugf("While removing upload session: %v", err)
+	}
+}
+
+// multipartUploader uploads SIF images to a library through its native API,
+// as parts uploaded concurrently and retried individually on failure.
+type multipartUploader struct {
+	c           *scslibrary.Client
+	concurrency int
+	// sessionDir holds the state of interrupted uploads, which are resumed
+	// when the same image is pushed again. Uploads can't be resumed when it is
+	// empty.
+	sessionDir string
+	// pb displays the upload progress, when set.
+	pb *progress.UploadBar
+}
+
+// upload pushes the image in f to the library at path, with tags. The image
+// record is created as done by the library client, before the image file is
+// uploaded in parts. errMultipartUnsupported is returned when the library
+// doesn't support multipart uploads, or stores images in an OCI registry.
+func (u *multipartUploader) upload(ctx context.Context, f *os.File, size int64, path, arch string, tags []string, description string) (*scslibrary.UploadImageComplete, error) {
+	if !scslibrary.IsLibraryPushRef(path) {
+		return nil, fmt.Errorf("malformed image path: %s", path)
+	}
+	entityName, collectionName, containerName, parsedTags := scslibrary.ParseLibraryPath(path)
+	if len(parsedTags) != 0 {
+		return nil, fmt.Errorf("malformed image path: %s", path)
+	}
+
+	if !u.apiAtLeast(ctx, scslibrary.APIVersionV2Upload) || u.ociSupported(ctx, strings.TrimPrefix(path, "library://")) {
+		return nil, errMultipartUnsupported
+	}
+
+	sha256sum, err := checksum(f)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating checksum: %v", err)
+	}
+	sylog.Debugf("Image hash computed as %s", sha256sum)
+
+	entity := &scslibrary.Entity{}
+	if err := u.get(ctx, "v1/entities/"+entityName, entity); errors.Is(err, scslibrary.ErrNotFound) {
+		sylog.Debugf("Entity %s does not exist in library - creating it.", entityName)
+		err = u.create(ctx, "v1/entities", scslibrary.Entity{Name: entityName, Description: "No description"}, entity)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	collectionRef := entityName + "/" + collectionName
+	collection := &scslibrary.Collection{}
+	if err := u.get(ctx, "v1/collections/"+collectionRef, collection); errors.Is(err, scslibrary.ErrNotFound) {
+		sylog.Debugf("Collection %s does not exist in library - creating it.", collectionName)
+		err = u.create(ctx, "v1/collections", scslibrary.Collection{Name: collectionName, Description: "No description", Entity: entity.ID}, collection)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	containerRef := collectionRef + "/" + containerName
+	container := &scslibrary.Container{}
+	if err := u.get(ctx, "v1/containers/"+containerRef, container); errors.Is(err, scslibrary.ErrNotFound) {
+		sylog.Debugf("Container %s does not exist in library - creating it.", containerName)
+		err = u.create(ctx, "v1/containers", scslibrary.Container{Name: containerName, Description: "No description", Collection: collection.ID}, container)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	image, err := u.c.GetImage(ctx, arch, containerRef+":sha256."+sha256sum)
+	if errors.Is(err, scslibrary.ErrNotFound) {
+		sylog.Debugf("Image %s does not exist in library - creating it.", sha256sum)
+		image = &scslibrary.Image{}
+		err = u.create(ctx, "v1/images", scslibrary.Image{Hash: "sha256." + sha256sum, Description: description, Container: container.ID}, image)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res *scslibrary.UploadImageComplete
+	if !image.Uploaded {
+		res, err = u.uploadImageFile(ctx, f, size, image.ID)
+		if err != nil {
+			return nil, err
+		}
+		sylog.Debugf("Upload completed OK")
+	} else {
+		sylog.Infof("Image is already present in the library - not uploading.")
+	}
+
+	sylog.Debugf("Setting tags against uploaded image")
+	if u.apiAtLeast(ctx, scslibrary.APIVersionV2ArchTags) {
+		for _, tag := range tags {
+			t := scslibrary.ArchImageTag{Arch: arch, Tag: tag, ImageID: image.ID}
+			if err := u.create(ctx, "v2/tags/"+container.ID, t, nil); err != nil {
+				return nil, fmt.Errorf("while setting tag %s: %w", tag, err)
+			}
+		}
+		return res, nil
+	}
+	sylog.Infof("This library does not support multiple architectures per tag.")
+	for _, tag := range tags {
+		t := scslibrary.ImageTag{Tag: tag, ImageID: image.ID}
+		if err := u.create(ctx, "v1/tags/"+container.ID, t, nil); err != nil {
+			return nil, fmt.Errorf("while setting tag %s: %w", tag, err)
+		}
+	}
+	return res, nil
+}
+
+// uploadImageFile uploads the image file of image imageID, resuming a
+// previously interrupted upload if one was recorded.
+func (u *multipartUploader) uploadImageFile(ctx context.Context, f *os.File, size int64, imageID string) (*scslibrary.UploadImageComplete, error) {
+	s := u.loadSession(imageID, size)
+	if s != nil {
+		sylog.Infof("Resuming interrupted upload (%d of %d parts already uploaded)", len(s.Parts), s.TotalParts)
+		res, err := u.uploadParts(ctx, f, imageID, s)
+		if err == nil || !isAPIError(err) {
+			return res, err
+		}
+		// the library may have discarded the upload, start over
+		sylog.Debugf("Resuming upload failed, restarting it: %v", err)
+		s.remove()
+	}
+
+	s, err := u.startSession(ctx, imageID, size)
+	if err != nil {
+		return nil, err
+	}
+	return u.uploadParts(ctx, f, imageID, s)
+}
+
+// uploadParts uploads the parts of s not uploaded yet, concurrently, and
+// completes the upload.
+func (u *multipartUploader) uploadParts(ctx context.Context, f *os.File, imageID string, s *uploadSession) (*scslibrary.UploadImageComplete, error) {
+	if u.pb != nil {
+		u.pb.Init(s.Size)
+		u.pb.IncrBy(int(s.uploaded()))
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(u.concurrency)
+	for n := 1; n <= s.TotalParts; n++ {
+		if _, ok := s.part(n); ok {
+			continue
+		}
+		n := n
+		g.Go(func() error {
+			etag, err := u.uploadPart(gctx, f, imageID, s, n)
+			if err != nil {
+				return fmt.Errorf("while uploading part %d: %w", n, err)
+			}
+			s.setPart(n, etag)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		if u.pb != nil {
+			u.pb.Terminate()
+		}
+		if s.path == "" {
+			u.abort(imageID, s)
+		}
+		return nil, err
+	}
+	if u.pb != nil {
+		u.pb.Finish()
+	}
+
+	completed := make([]scslibrary.CompletedPart, 0, s.TotalParts)
+	for n := 1; n <= s.TotalParts; n++ {
+		etag, _ := s.part(n)
+		completed = append(completed, scslibrary.CompletedPart{PartNumber: n, Token: etag})
+	}
+	res := &scslibrary.UploadImageComplete{}
+	err := u.update(ctx, fmt.Sprintf("v2/imagefile/%s/_multipart_complete", imageID), scslibrary.CompleteMultipartUploadRequest{
+		UploadID:       s.UploadID,
+		CompletedParts: completed,
+	}, res)
+	if err != nil {
+		return nil, fmt.Errorf("error completing multipart upload: %w", err)
+	}
+	s.remove()
+
+	if res.ContainerURL == "" {
+		// success w/o detailed upload complete response
+		return nil, nil
+	}
+	return res, nil
+}
+
+// uploadPart uploads part n of s, retrying on failure.
+func (u *multipartUploader) uploadPart(ctx context.Context, f *os.File, imageID string, s *uploadSession, n int) (string, error) {
+	offset, size := s.partRange(n)
+
+	var checksum string
+	if s.S3Compliant {
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(f, offset, size)); err != nil {
+			return "", err
+		}
+		checksum = hex.EncodeToString(h.Sum(nil))
+	}
+
+	// reported is the number of bytes of the part reported to the progress
+	// bar, across attempts
+	var reported int64
+	for attempt := 1; ; attempt++ {
+		etag, err := u.putPart(ctx, f, imageID, s, n, checksum, &reported)
+		if err == nil {
+			return etag, nil
+		}
+		if attempt == partAttempts || ctx.Err() != nil {
+			return "", err
+		}
+
+		delay := partRetryDelay << (attempt - 1)
+		sylog.Debugf("Upload of part %d failed, retrying in %v: %v", n, delay, err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// putPart requests an upload URL for part n of s, and uploads it there.
+func (u *multipartUploader) putPart(ctx context.Context, f *os.File, imageID string, s *uploadSession, n int, checksum string, reported *int64) (string, error) {
+	offset, size := s.partRange(n)
+
+	var part scslibrary.UploadImagePart
+	err := u.update(ctx, fmt.Sprintf("v2/imagefile/%s/_multipart", imageID), scslibrary.UploadImagePartRequest{
+		PartSize:       size,
+		UploadID:       s.UploadID,
+		PartNumber:     n,
+		SHA256Checksum: checksum,
+	}, &part)
+	if err != nil {
+		return "", err
+	}
+
+	body := &partReader{r: io.NewSectionReader(f, offset, size), pb: u.pb, reported: reported}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, part.PresignedURL, body)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	req.ContentLength = size
+	if checksum != "" {
+		req.Header.Set("x-amz-content-sha256", checksum)
+	}
+
+	resp, err := u.c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("object store returned an error: %d", resp.StatusCode)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// startSession starts a multipart upload of the image file of imageID.
+func (u *multipartUploader) startSession(ctx context.Context, imageID string, size int64) (*uploadSession, error) {
+	var mu scslibrary.MultipartUpload
+	err := u.create(ctx, fmt.Sprintf("v2/imagefile/%s/_multipart", imageID), scslibrary.MultipartUploadStartRequest{Size: size}, &mu)
+	if errors.Is(err, scslibrary.ErrNotFound) {
+		return nil, errMultipartUnsupported
+	} else if err != nil {
+		return nil, fmt.Errorf("error starting multipart upload: %w", err)
+	}
+	if mu.TotalParts < 1 || mu.PartSize < 1 {
+		return nil, fmt.Errorf("invalid multipart upload: %d parts of %d bytes", mu.TotalParts, mu.PartSize)
+	}
+	sylog.Debugf("Multi-part upload: ID=[%s] totalParts=[%d] partSize=[%d]", mu.UploadID, mu.TotalParts, mu.PartSize)
+
+	// S3 compliance mode is enabled by default
+	val := mu.Options[scslibrary.OptionS3Compliant]
+	s := &uploadSession{
+		UploadID:    mu.UploadID,
+		Size:        size,
+		PartSize:    mu.PartSize,
+		TotalParts:  mu.TotalParts,
+		S3Compliant: val == "" || val == "true",
+		Parts:       make(map[int]string),
+	}
+	if u.sessionDir != "" {
+		s.path = filepath.Join(u.sessionDir, imageID+".json")
+		if err := s.save(); err != nil {
+			sylog.Debugf("While saving upload session: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// loadSession returns the recorded upload of the image file of imageID, if
+// any.
+func (u *multipartUploader) loadSession(imageID string, size int64) *uploadSession {
+	if u.sessionDir == "" {
+		return nil
+	}
+	path := filepath.Join(u.sessionDir, imageID+".json")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	s := &uploadSession{path: path}
+	if err := json.Unmarshal(b, s); err != nil || s.Size != size || s.PartSize < 1 || s.TotalParts < 1 {
+		sylog.Debugf("Discarding invalid upload session %s", path)
+		s.remove()
+		return nil
+	}
+	if s.Parts == nil {
+		s.Parts = make(map[int]string)
+	}
+	return s
+}
+
+// abort aborts the multipart upload of s.
+func (u *multipartUploader) abort(imageID string, s *uploadSession) {
+	// the upload context may have been canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := u.update(ctx, fmt.Sprintf("v2/imagefile/%s/_multipart_abort", imageID), scslibrary.AbortMultipartUploadRequest{UploadID: s.UploadID}, nil)
+	if err != nil {
+		sylog.Debugf("Error aborting multipart upload: %v", err)
+	}
+}
+
+// apiAtLeast returns whether the library supports version v of its API.
+func (u *multipartUploader) apiAtLeast(ctx context.Context, v string) bool {
+	vi, err := u.c.GetVersion(ctx)
+	if err != nil || vi.APIVersion == "" {
+		sylog.Debugf("Unable to determine library API version: %v", err)
+		return false
+	}
+	got, err := semver.Make(vi.APIVersion)
+	if err != nil {
+		sylog.Debugf("Unable to decode library API version: %v", err)
+		return false
+	}
+	return got.GTE(semver.MustParse(v))
+}
+
+// ociSupported returns whether images are pushed to an OCI registry backing
+// the library, which the library client uploads to.
+func (u *multipartUploader) ociSupported(ctx context.Context, name string) bool {
+	q := url.Values{}
+	q.Set("namespace", name)
+	q.Set("mapped", "1")
+	q.Set("accessTypes", "pull,push")
+	return u.get(ctx, "v1/oci-redirect?"+q.Encode(), nil) == nil
+}
+
+func (u *multipartUploader) get(ctx context.Context, path string, v any) error {
+	return u.do(ctx, http.MethodGet, path, nil, v)
+}
+
+func (u *multipartUploader) create(ctx context.Context, path string, body, v any) error {
+	return u.do(ctx, http.MethodPost, path, body, v)
+}
+
+func (u *multipartUploader) update(ctx context.Context, path string, body, v any) error {
+	return u.do(ctx, http.MethodPut, path, body, v)
+}
+
+// do sends a request to the library API, and decodes the data of the response
+// in v, if not nil. scslibrary.ErrNotFound is returned for missing resources.
+func (u *multipartUploader) do(ctx context.Context, method, path string, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding object to JSON: %v", err)
+		}
+		r = bytes.NewReader(b)
+	}
+
+	ref, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.c.BaseURL.ResolveReference(ref).String(), r)
+	if err != nil {
+		return err
+	}
+	if u.c.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+u.c.AuthToken)
+	}
+	if u.c.UserAgent != "" {
+		req.Header.Set("User-Agent", u.c.UserAgent)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	sylog.Debugf("Library API request: %s %s", method, ref.Path)
+	res, err := u.c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request to server: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return scslibrary.ErrNotFound
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		if err := jsonresp.ReadError(res.Body); err != nil {
+			return fmt.Errorf("request did not succeed: %w", err)
+		}
+		return fmt.Errorf("request did not succeed: %w", &jsonresp.Error{Code: res.StatusCode, Message: res.Status})
+	case v == nil || res.StatusCode == http.StatusNoContent:
+		return nil
+	}
+	return jsonresp.ReadResponse(res.Body, v)
+}
+
+// isAPIError returns whether err was returned by the library API, rather than
+// by the object store or the network.
+func isAPIError(err error) bool {
+	var jerr *jsonresp.Error
+	return errors.Is(err, scslibrary.ErrNotFound) || errors.As(err, &jerr)
+}
+
+// checksum returns the SHA256 checksum of f, and rewinds it.
+func checksum(f *os.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// partReader reports the bytes read from r to pb, counting only bytes not
+// already reported by a previous attempt of the same part.
+type partReader struct {
+	r        io.Reader
+	pb       *progress.UploadBar
+	read     int64
+	reported *int64
+}
+
+func (p *partReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.read > *p.reported {
+		if p.pb != nil {
+			p.pb.IncrBy(int(p.read - *p.reported))
+		}
+		*p.reported = p.read
+	}
+	return n, err
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package library
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	jsonresp "github.com/sylabs/json-resp"
+	scslibrary "github.com/sylabs/scs-library-client/client"
+)
+
+const testPartSize = 1024
+
+// mockLibrary emulates the native API of a library supporting multipart
+// uploads, with an object store failing the first attempt to upload each
+// part listed in failParts.
+type mockLibrary struct {
+	t         *testing.T
+	url       string
+	failParts map[int]bool
+
+	mu        sync.Mutex
+	parts     map[int][]byte
+	attempts  map[int]int
+	completed []scslibrary.CompletedPart
+	tags      []string
+	starts    int
+}
+
+func (m *mockLibrary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/s3/") {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/s3/%d", &n)
+		m.attempts[n]++
+		b, _ := io.ReadAll(r.Body)
+		if m.failParts[n] && m.attempts[n] == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		m.parts[n] = b
+		w.Header().Set("ETag", fmt.Sprintf("etag-%d", n))
+		return
+	}
+
+	var data any
+	switch p := r.URL.Path; {
+	case p == "/version":
+		data = scslibrary.VersionInfo{APIVersion: "2.0.0"}
+	case p == "/v1/oci-redirect":
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case r.Method == http.MethodGet && p == "/v1/images/entity/collection/container:sha256."+testImageHash:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case r.Method == http.MethodGet:
+		data = map[string]string{"id": "id"}
+	case p == "/v1/images":
+		data = scslibrary.Image{ID: "image"}
+	case p == "/v2/imagefile/image/_multipart" && r.Method == http.MethodPost:
+		m.starts++
+		var req scslibrary.MultipartUploadStartRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		data = scslibrary.MultipartUpload{
+			UploadID:   "upload",
+			PartSize:   testPartSize,
+			TotalParts: int((req.Size + testPartSize - 1) / testPartSize),
+			Options:    map[string]string{scslibrary.OptionS3Compliant: "false"},
+		}
+	case p == "/v2/imagefile/image/_multipart":
+		var req scslibrary.UploadImagePartRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.UploadID != "upload" {
+			jsonresp.WriteError(w, "unknown upload", http.StatusBadRequest)
+			return
+		}
+		data = scslibrary.UploadImagePart{PresignedURL: fmt.Sprintf("%s/s3/%d", m.url, req.PartNumber)}
+	case p == "/v2/imagefile/image/_multipart_complete":
+		var req scslibrary.CompleteMultipartUploadRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		m.completed = req.CompletedParts
+		data = scslibrary.UploadImageComplete{ContainerURL: "/library/entity/collection/container"}
+	case p == "/v2/tags/id":
+		var tag scslibrary.ArchImageTag
+		json.NewDecoder(r.Body).Decode(&tag)
+		m.tags = append(m.tags, tag.Tag)
+	case r.Method == http.MethodPost:
+		data = map[string]string{"id": "id"}
+	default:
+		m.t.Errorf("unexpected request %s %s", r.Method, p)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	jsonresp.WriteResponse(w, data, http.StatusOK)
+}
+
+// testImageHash is set to the hash of the test image.
+var testImageHash string
+
+func TestMultipartUpload(t *testing.T) {
+	partRetryDelay = 0
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4*testPartSize/16+10)
+	path := filepath.Join(t.TempDir(), "image.sif")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if testImageHash, err = checksum(f); err != nil {
+		t.Fatal(err)
+	}
+	totalParts := (len(content) + testPartSize - 1) / testPartSize
+
+	newLibrary := func(failParts map[int]bool) (*mockLibrary, *scslibrary.Client) {
+		m := &mockLibrary{
+			t:         t,
+			failParts: failParts,
+			parts:     make(map[int][]byte),
+			attempts:  make(map[int]int),
+		}
+		srv := httptest.NewServer(m)
+		t.Cleanup(srv.Close)
+		m.url = srv.URL
+
+		c, err := scslibrary.NewClient(&scslibrary.Config{BaseURL: srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m, c
+	}
+
+	checkUpload := func(t *testing.T, m *mockLibrary) {
+		var got []byte
+		for n := 1; n <= totalParts; n++ {
+			got = append(got, m.parts[n]...)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("uploaded content differs")
+		}
+		if len(m.completed) != totalParts {
+			t.Fatalf("got %d completed parts, want %d", len(m.completed), totalParts)
+		}
+		for i, p := range m.completed {
+			if p.PartNumber != i+1 || p.Token != fmt.Sprintf("etag-%d", i+1) {
+				t.Errorf("unexpected completed part %v", p)
+			}
+		}
+	}
+
+	t.Run("Retry", func(t *testing.T) {
+		m, c := newLibrary(map[int]bool{2: true})
+		u := &multipartUploader{c: c, concurrency: 3}
+
+		res, err := u.upload(context.Background(), f, int64(len(content)), "entity/collection/container", "amd64", []string{"latest", "v1"}, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkUpload(t, m)
+		if m.attempts[2] != 2 {
+			t.Errorf("got %d attempts for part 2, want 2", m.attempts[2])
+		}
+		if res == nil || res.ContainerURL == "" {
+			t.Errorf("missing upload completion response")
+		}
+		if strings.Join(m.tags, ",") != "latest,v1" {
+			t.Errorf("got tags %v", m.tags)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		dir := t.TempDir()
+		session := &uploadSession{
+			UploadID:   "upload",
+			Size:       int64(len(content)),
+			PartSize:   testPartSize,
+			TotalParts: totalParts,
+			Parts:      map[int]string{1: "etag-1", 3: "etag-3"},
+			path:       filepath.Join(dir, "image.json"),
+		}
+		if err := session.save(); err != nil {
+			t.Fatal(err)
+		}
+
+		m, c := newLibrary(nil)
+		m.parts[1] = content[:testPartSize]
+		m.parts[3] = content[2*testPartSize : 3*testPartSize]
+		u := &multipartUploader{c: c, concurrency: 2, sessionDir: dir}
+
+		if _, err := u.upload(context.Background(), f, int64(len(content)), "entity/collection/container", "amd64", nil, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkUpload(t, m)
+		if m.starts != 0 || m.attempts[1] != 0 || m.attempts[3] != 0 {
+			t.Errorf("upload was not resumed")
+		}
+		if _, err := os.Stat(session.path); !os.IsNotExist(err) {
+			t.Errorf("upload session was not removed")
+		}
+	})
+
+	t.Run("ResumeUnknown", func(t *testing.T) {
+		dir := t.TempDir()
+		session := &uploadSession{
+			UploadID:   "expired",
+			Size:       int64(len(content)),
+			PartSize:   testPartSize,
+			TotalParts: totalParts,
+			Parts:      map[int]string{1: "etag-1"},
+			path:       filepath.Join(dir, "image.json"),
+		}
+		if err := session.save(); err != nil {
+			t.Fatal(err)
+		}
+
+		m, c := newLibrary(nil)
+		u := &multipartUploader{c: c, concurrency: 2, sessionDir: dir}
+
+		if _, err := u.upload(context.Background(), f, int64(len(content)), "entity/collection/container", "amd64", nil, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkUpload(t, m)
+		if m.starts != 1 {
+			t.Errorf("got %d upload starts, want 1", m.starts)
+		}
+	})
+}
@@ -118,11 +118,11 @@ func pull(ctx context.Context, imgCache *cache.Handle, directTo, pullFrom string
 
 	req, err := http.NewRequest("HEAD", pullFrom, nil)
 	if err != nil {
-		sylog.Fatalf("Error constructing http request: %v\n", err)
+		return "", fmt.Errorf("error constructing http request: %w", err)
 	}
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		sylog.Fatalf("Error making http request: %v\n", err)
+		return "", fmt.Errorf("error making http request: %w", err)
 	}
 	defer res.Body.Close()
 
@@ -154,7 +154,7 @@ func pull(ctx context.Context, imgCache *cache.Handle, directTo, pullFrom string
 			sylog.Infof("Downloading network image")
 			err := DownloadImage(ctx, cacheEntry.TmpPath, pullFrom)
 			if err != nil {
-				sylog.Fatalf("%v\n", err)
+				return "", err
 			}
 
 			err = cacheEntry.Finalize()
@@ -0,0 +1,54 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package net
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+)
+
+func init() {
+	transport.Register(Transport{}, "http", "https")
+}
+
+// Transport is the transport for http:// and https:// URIs, which downloads
+// images from web servers. Images cannot be pushed with this transport.
+type Transport struct{}
+
+// Resolve validates ref, and returns it unchanged.
+func (Transport) Resolve(_ context.Context, ref string, _ transport.Options) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if !IsNetPullRef(ref) || u.Host == "" {
+		return "", fmt.Errorf("not a valid http(s) URI: %s", ref)
+	}
+	return ref, nil
+}
+
+// Fetch downloads the image at ref to dst, or to the cache if dst is not set.
+func (Transport) Fetch(ctx context.Context, imgCache *cache.Handle, ref, dst string, opts transport.Options) (string, error) {
+	if dst == "" {
+		return Pull(ctx, imgCache, ref, opts.TmpDir)
+	}
+	return PullToFile(ctx, imgCache, dst, ref)
+}
+
+// Push is not supported for http(s) URIs.
+func (Transport) Push(context.Context, string, string, transport.Options) error {
+	return transport.ErrUnsupported
+}
+
+// Digest is not supported for http(s) URIs, as the digest of the image is not
+// known until it has been downloaded.
+func (Transport) Digest(context.Context, string, transport.Options) (string, error) {
+	return "", transport.ErrUnsupported
+}
@@ -9,7 +9,9 @@ package oci
 import (
 	"context"
 	"fmt"
+	"os"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/sylabs/singularity/v4/internal/pkg/build"
 	"github.com/sylabs/singularity/v4/internal/pkg/cache"
 	"github.com/sylabs/singularity/v4/internal/pkg/ociimage"
@@ -39,7 +41,11 @@ func pullNativeSIF(ctx context.Context, imgCache *cache.Handle, directTo, pullFr
 		}
 		imagePath = directTo
 	} else {
-		cacheEntry, err := imgCache.GetEntry(cache.OciTempCacheType, hash.String())
+		key, err := cacheKey(hash.String(), opts)
+		if err != nil {
+			return "", err
+		}
+		cacheEntry, err := imgCache.GetEntry(cache.OciTempCacheType, key)
 		if err != nil {
 			return "", fmt.Errorf("unable to check if %v exists in cache: %v", hash, err)
 		}
@@ -64,6 +70,29 @@ func pullNativeSIF(ctx context.Context, imgCache *cache.Handle, directTo, pullFr
 	return imagePath, nil
 }
 
+// cacheKey returns the cache key of the SIF image converted from the OCI
+// image with digest hash. Images with primed caches, or ordered by an access
+// profile, are cached apart from plain conversions, and from each other.
+func cacheKey(hash string, opts PullOptions) (string, error) {
+	key := hash
+	if opts.PrimeCache {
+		key += "-primed"
+	}
+	if opts.AccessProfile != "" {
+		f, err := os.Open(opts.AccessProfile)
+		if err != nil {
+			return "", fmt.Errorf("while reading access profile: %w", err)
+		}
+		defer f.Close()
+		d, err := digest.FromReader(f)
+		if err != nil {
+			return "", fmt.Errorf("while reading access profile: %w", err)
+		}
+		key += "-profile-" + d.Encoded()
+	}
+	return key, nil
+}
+
 // convertOciToSIF will convert an OCI source into a SIF using the build routines
 func convertOciToSIF(ctx context.Context, imgCache *cache.Handle, image, cachedImgPath string, opts PullOptions) error {
 	if imgCache == nil {
@@ -85,6 +114,8 @@ func convertOciToSIF(ctx context.Context, imgCache *cache.Handle, image, cachedI
 				Platform:         opts.Platform,
 				OCIAuthConfig:    opts.OciAuth,
 				DockerAuthFile:   opts.ReqAuthFile,
+				PrimeCache:       opts.PrimeCache,
+				AccessProfile:    opts.AccessProfile,
 			},
 			NoCleanUp: opts.NoCleanUp,
 		},
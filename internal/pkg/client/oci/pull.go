@@ -32,6 +32,12 @@ type PullOptions struct {
 	KeepLayers  bool
 	Platform    gccrv1.Platform
 	ReqAuthFile string
+	// PrimeCache generates /etc/ld.so.cache and the PATH cache in the SIF
+	// image converted from the OCI image.
+	PrimeCache bool
+	// AccessProfile is the path of a file listing the paths accessed first
+	// when the image runs, which are stored first in the converted SIF image.
+	AccessProfile string
 }
 
 // transportOptions maps PullOptions to OCI image transport options
@@ -32,3 +32,39 @@ func Push(ctx context.Context, sourceFile string, destRef string, ociAuth *authn
 
 	return fmt.Errorf("push only supports SIF images")
 }
+
+// PushDaemon loads an image into the Docker daemon at dockerHost, or the
+// daemon set in the environment if empty, as destRef. At present, only
+// OCI-SIF images can be loaded, and their squashfs layers are converted back
+// to tar layers in tmpDir.
+func PushDaemon(ctx context.Context, sourceFile, destRef, dockerHost, tmpDir string) error {
+	if err := checkOCISIF(sourceFile); err != nil {
+		return err
+	}
+	return ocisif.PushDaemon(ctx, sourceFile, destRef, dockerHost, tmpDir)
+}
+
+// PushContainersStorage loads an image into the containers-storage of the
+// current user, used by Podman, as destRef. At present, only OCI-SIF images
+// can be loaded, and their squashfs layers are converted back to tar layers
+// in tmpDir.
+func PushContainersStorage(ctx context.Context, sourceFile, destRef, tmpDir string) error {
+	if err := checkOCISIF(sourceFile); err != nil {
+		return err
+	}
+	return ocisif.PushContainersStorage(ctx, sourceFile, destRef, tmpDir)
+}
+
+// checkOCISIF returns an error if sourceFile is not an OCI-SIF image.
+func checkOCISIF(sourceFile string) error {
+	img, err := image.Init(sourceFile, false)
+	if err != nil {
+		return err
+	}
+	defer img.File.Close()
+
+	if img.Type != image.OCISIF {
+		return fmt.Errorf("only OCI-SIF images can be loaded into container engines")
+	}
+	return nil
+}
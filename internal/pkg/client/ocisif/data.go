@@ -0,0 +1,121 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	ggcrmutate "github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/packer"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+// DataConfigMediaType is the media type of the config of a data container,
+// an OCI artifact holding a single squashfs layer of arbitrary data, rather
+// than a root filesystem.
+const DataConfigMediaType types.MediaType = "application/vnd.sylabs.data.config.v1+json"
+
+var ErrNotDataContainer = errors.New("not a data container")
+
+// CreateDataContainer packages the contents of the directory src as a squashfs
+// layer, in a data container written to the OCI-SIF dest.
+func CreateDataContainer(src, dest, tmpDir string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+
+	workDir, err := os.MkdirTemp(tmpDir, "oci-sif-data-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(workDir); err != nil {
+			sylog.Warningf("Couldn't remove oci-sif temporary directory %q: %v", workDir, err)
+		}
+	}()
+
+	sylog.Infof("Creating squashfs layer from %s", src)
+	sqfsPath := filepath.Join(workDir, "data.sqfs")
+	if err := packer.NewSquashfs().Create([]string{src}, sqfsPath, []string{"-noappend"}); err != nil {
+		return fmt.Errorf("while creating squashfs layer: %w", err)
+	}
+	l, err := newFileLayer(sqfsPath, SquashfsLayerMediaType)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	img, err := ggcrmutate.Append(empty.Image, ggcrmutate.Addendum{
+		Layer: l,
+		History: ggcrv1.History{
+			Created:   ggcrv1.Time{Time: now},
+			CreatedBy: useragent.Value(),
+			Comment:   "data container created from " + filepath.Base(filepath.Clean(src)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("while adding layer: %w", err)
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return fmt.Errorf("while retrieving config: %w", err)
+	}
+	cf = cf.DeepCopy()
+	cf.Created = ggcrv1.Time{Time: now}
+	// The platform is set so that the data container can be pulled as an
+	// image on the same platform.
+	cf.OS = runtime.GOOS
+	cf.Architecture = runtime.GOARCH
+	img, err = ggcrmutate.ConfigFile(img, cf)
+	if err != nil {
+		return fmt.Errorf("while setting config: %w", err)
+	}
+	img = ggcrmutate.MediaType(img, types.OCIManifestSchema1)
+	img = ggcrmutate.ConfigMediaType(img, DataConfigMediaType)
+
+	sylog.Infof("Writing data container %s", dest)
+	ii := ggcrmutate.AppendManifests(empty.Index, ggcrmutate.IndexAddendum{
+		Add: img,
+	})
+	return ocisif.Write(dest, ii)
+}
+
+// IsDataContainer returns true if the OCI-SIF at path is a data container.
+func IsDataContainer(path string) (bool, error) {
+	mf, err := ImageManifest(path)
+	if err != nil {
+		return false, err
+	}
+	return mf.Config.MediaType == DataConfigMediaType, nil
+}
+
+// DataOffset returns the offset of the squashfs layer of the data container
+// at path. If path is not a data container, ErrNotDataContainer is returned.
+func DataOffset(path string) (int64, error) {
+	isData, err := IsDataContainer(path)
+	if err != nil {
+		return 0, err
+	}
+	if !isData {
+		return 0, fmt.Errorf("%s: %w", path, ErrNotDataContainer)
+	}
+	return SquashfsLayerOffset(path)
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	ggcrmutate "github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sylabs/oci-tools/pkg/mutate"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/crypt"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/cryptkey"
+)
+
+const (
+	// EncryptedSquashfsLayerMediaType is the media type of a squashfs layer
+	// that has been encrypted into a LUKS2 volume.
+	EncryptedSquashfsLayerMediaType types.MediaType = "application/vnd.sylabs.image.layer.v1.squashfs+luks2"
+
+	// EncryptionKeyAnnotation is the image manifest annotation holding the
+	// LUKS2 key, encrypted with an RSA public key, or wrapped by a key
+	// provider, when an OCI-SIF has been encrypted using a PEM file or a key
+	// URI.
+	EncryptionKeyAnnotation = "org.sylabs.oci-sif.encryption-key"
+)
+
+var ErrAlreadyEncrypted = errors.New("image is already encrypted")
+
+// IsEncrypted returns true if any layer of the single image in the OCI-SIF at
+// path is encrypted.
+func IsEncrypted(path string) (bool, error) {
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return false, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return false, err
+	}
+	mf, err := img.Manifest()
+	if err != nil {
+		return false, fmt.Errorf("while obtaining manifest: %w", err)
+	}
+	for _, l := range mf.Layers {
+		if l.MediaType == EncryptedSquashfsLayerMediaType {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EncryptOCISIF replaces each squashfs layer of the single image in the
+// OCI-SIF at path with a LUKS2 encrypted copy, using the key described by ki.
+// When ki is a PEM key or a key URI, the LUKS2 key is encrypted with the public
+// key, or wrapped by the key provider, and stored as a manifest annotation. Encryption uses cryptsetup, so must be
+// performed as root.
+func EncryptOCISIF(path string, ki cryptkey.KeyInfo, tmpDir string) error {
+	workDir, err := os.MkdirTemp(tmpDir, "oci-sif-encrypt-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(workDir); err != nil {
+			sylog.Warningf("Couldn't remove oci-sif temporary directory %q: %v", workDir, err)
+		}
+	}()
+
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := cryptkey.NewPlaintextKey(ki)
+	if err != nil {
+		return fmt.Errorf("unable to obtain encryption key: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("while retrieving layers: %w", err)
+	}
+
+	cryptDev := &crypt.Device{}
+	ms := []mutate.Mutation{}
+	for i, l := range layers {
+		mt, err := l.MediaType()
+		if err != nil {
+			return err
+		}
+		switch mt {
+		case SquashfsLayerMediaType:
+		case EncryptedSquashfsLayerMediaType:
+			return ErrAlreadyEncrypted
+		default:
+			return fmt.Errorf("unsupported layer mediaType %q", mt)
+		}
+
+		sylog.Infof("Encrypting layer %d of %d", i+1, len(layers))
+		plainPath := filepath.Join(workDir, fmt.Sprintf("layer-%d.sqfs", i))
+		if err := writeLayer(l, plainPath); err != nil {
+			return fmt.Errorf("while extracting layer: %w", err)
+		}
+		cryptPath, err := cryptDev.EncryptFilesystem(plainPath, plaintext)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt layer: %w", err)
+		}
+		// EncryptFilesystem creates its output outside of workDir, so it must
+		// be removed explicitly.
+		defer os.Remove(cryptPath)
+		os.Remove(plainPath)
+
+		el, err := newFileLayer(cryptPath, EncryptedSquashfsLayerMediaType)
+		if err != nil {
+			return err
+		}
+		ms = append(ms, mutate.SetLayer(i, el))
+	}
+
+	encImg, err := mutate.Apply(img, ms...)
+	if err != nil {
+		return fmt.Errorf("while replacing layers: %w", err)
+	}
+
+	if ki.Format == cryptkey.PEM || ki.Format == cryptkey.URI {
+		encKey, err := cryptkey.EncryptKey(ki, plaintext)
+		if err != nil {
+			return fmt.Errorf("while encrypting key: %w", err)
+		}
+		encImg = ggcrmutate.Annotations(encImg, map[string]string{
+			EncryptionKeyAnnotation: string(encKey),
+		}).(ggcrv1.Image)
+	}
+
+	ii := ggcrmutate.AppendManifests(empty.Index, ggcrmutate.IndexAddendum{
+		Add: encImg,
+	})
+	encSIF := filepath.Join(workDir, "encrypted.sif")
+	if err := ocisif.Write(encSIF, ii); err != nil {
+		return fmt.Errorf("while writing encrypted OCI-SIF: %w", err)
+	}
+
+	return fs.CopyFileAtomic(encSIF, path, 0o755)
+}
+
+// LayerKey returns the plaintext LUKS2 key for the encrypted layers of img,
+// using the key material described by ki.
+func LayerKey(img ggcrv1.Image, ki cryptkey.KeyInfo) ([]byte, error) {
+	if ki.Format == cryptkey.Passphrase {
+		return cryptkey.PlaintextKeyFromMessage(ki, nil)
+	}
+
+	encKey, err := encryptionKey(img)
+	if err != nil {
+		return nil, err
+	}
+	return cryptkey.PlaintextKeyFromMessage(ki, encKey)
+}
+
+// LayerKeyInfo returns the key material that unwraps the LUKS2 key for the
+// encrypted layers of img, when img was encrypted using a key URI.
+func LayerKeyInfo(img ggcrv1.Image) (*cryptkey.KeyInfo, error) {
+	encKey, err := encryptionKey(img)
+	if err != nil {
+		return nil, err
+	}
+	return cryptkey.KeyInfoFromMessage(encKey)
+}
+
+// encryptionKey returns the encrypted LUKS2 key stored in the manifest of img.
+func encryptionKey(img ggcrv1.Image) ([]byte, error) {
+	mf, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining manifest: %w", err)
+	}
+	encKey, ok := mf.Annotations[EncryptionKeyAnnotation]
+	if !ok {
+		return nil, fmt.Errorf("image was not encrypted with a PEM key or key URI: %w", cryptkey.ErrEncryptedKeyNotFound)
+	}
+	return []byte(encKey), nil
+}
+
+func writeLayer(l ggcrv1.Layer, dest string) error {
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sylabs/oci-tools/pkg/mutate"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+const (
+	// podmanDefaultRegistry is the registry of images named without one by
+	// Podman.
+	podmanDefaultRegistry = "localhost"
+
+	aufsWhiteoutPrefix = ".wh."
+	aufsOpaqueMarker   = ".wh..wh..opq"
+)
+
+// opaqueXattrRecords are the PAX records of the extended attributes marking
+// an overlayfs opaque directory.
+var opaqueXattrRecords = []string{
+	"SCHILY.xattr.trusted.overlay.opaque",
+	"SCHILY.xattr.user.overlay.opaque",
+}
+
+// PushDaemon loads the single image of the OCI-SIF sourceFile into the Docker
+// daemon at dockerHost, or the daemon set in the environment if empty, as
+// destRef. Squashfs layers are converted to tar layers in tmpDir.
+func PushDaemon(ctx context.Context, sourceFile, destRef, dockerHost, tmpDir string) error {
+	tag, err := engineTag(destRef, name.DefaultRegistry)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := os.MkdirTemp(tmpDir, "oci-sif-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	clientOpts := []dockerclient.Opt{dockerclient.FromEnv, dockerclient.WithAPIVersionNegotiation()}
+	if dockerHost != "" {
+		clientOpts = append(clientOpts, dockerclient.WithHost(dockerHost))
+	}
+	cli, err := dockerclient.NewClientWithOpts(clientOpts...)
+	if err != nil {
+		return fmt.Errorf("while connecting to Docker daemon: %w", err)
+	}
+	defer cli.Close()
+
+	return withTarImage(sourceFile, workDir, func(img ggcrv1.Image) error {
+		sylog.Infof("Loading image into Docker daemon as %s", tag)
+		resp, err := daemon.Write(tag, img, daemon.WithClient(cli), daemon.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("while loading image into Docker daemon: %w", err)
+		}
+		sylog.Debugf("Docker daemon response: %s", resp)
+		return nil
+	})
+}
+
+// PushContainersStorage loads the single image of the OCI-SIF sourceFile into
+// the containers-storage of the current user, used by Podman, as destRef.
+// Squashfs layers are converted to tar layers in tmpDir.
+func PushContainersStorage(ctx context.Context, sourceFile, destRef, tmpDir string) error {
+	tag, err := engineTag(destRef, podmanDefaultRegistry)
+	if err != nil {
+		return err
+	}
+	podman, err := bin.FindBin("podman")
+	if err != nil {
+		return fmt.Errorf("podman is required to load images into containers-storage: %w", err)
+	}
+
+	workDir, err := os.MkdirTemp(tmpDir, "oci-sif-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	return withTarImage(sourceFile, workDir, func(img ggcrv1.Image) error {
+		pr, pw := io.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			err := tarball.Write(tag, img, pw)
+			pw.CloseWithError(err)
+			done <- err
+		}()
+
+		sylog.Infof("Loading image into containers-storage as %s", tag)
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, podman, "load", "--quiet")
+		cmd.Stdin = pr
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		// unblock tarball.Write if podman exited before reading the image
+		pr.CloseWithError(io.ErrClosedPipe)
+		werr := <-done
+		if err != nil {
+			return fmt.Errorf("while loading image with podman: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		if werr != nil {
+			return fmt.Errorf("while writing image: %w", werr)
+		}
+		sylog.Debugf("podman load: %s", strings.TrimSpace(string(out)))
+		return nil
+	})
+}
+
+// engineTag returns the tag of the image loaded into a container engine as
+// ref. As for the engine, the tag defaults to latest, and the registry to
+// registry.
+func engineTag(ref, registry string) (name.Tag, error) {
+	ref = strings.TrimPrefix(ref, "//")
+	tag, err := name.NewTag(ref, name.WithDefaultRegistry(registry))
+	if err != nil {
+		return name.Tag{}, fmt.Errorf("invalid reference %q, a name with an optional tag is expected: %w", ref, err)
+	}
+	return tag, nil
+}
+
+// withTarImage calls fn with the single image of the OCI-SIF sourceFile,
+// with its squashfs layers converted to tar layers in workDir.
+func withTarImage(sourceFile, workDir string, fn func(ggcrv1.Image) error) error {
+	fi, err := sif.LoadContainerFromPath(sourceFile, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return err
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return fmt.Errorf("only OCI-SIF files can be loaded into container engines: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("while retrieving layers: %w", err)
+	}
+	ms := make([]mutate.Mutation, 0, len(layers)+1)
+	for i, l := range layers {
+		mt, err := l.MediaType()
+		if err != nil {
+			return err
+		}
+		switch mt {
+		case SquashfsLayerMediaType:
+		case EncryptedSquashfsLayerMediaType:
+			return fmt.Errorf("encrypted OCI-SIF images cannot be loaded into container engines")
+		default:
+			continue
+		}
+		sylog.Infof("Converting layer %d/%d to tar format", i+1, len(layers))
+		tl, err := tarLayer(l, workDir, i)
+		if err != nil {
+			return fmt.Errorf("while converting layer %d to tar format: %w", i+1, err)
+		}
+		ms = append(ms, mutate.SetLayer(i, tl))
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	ms = append(ms, mutate.SetHistory(ggcrv1.History{
+		Created:   ggcrv1.Time{Time: time.Now()},
+		CreatedBy: useragent.Value(),
+		Comment:   "image exported from oci-sif " + digest.Hex,
+	}))
+
+	tarImg, err := mutate.Apply(img, ms...)
+	if err != nil {
+		return fmt.Errorf("while replacing layers: %w", err)
+	}
+	return fn(tarImg)
+}
+
+// tarLayer returns a tar layer, with the content of the squashfs layer l,
+// converted in workDir. The overlayfs whiteouts and opaque directories of
+// the squashfs layer are written as AUFS whiteout files, as in OCI layers.
+func tarLayer(l ggcrv1.Layer, workDir string, i int) (ggcrv1.Layer, error) {
+	sqfs2tar, err := bin.FindBin("sqfs2tar")
+	if err != nil {
+		return nil, err
+	}
+
+	sqfsPath := filepath.Join(workDir, fmt.Sprintf("layer-%d.sqfs", i))
+	if err := writeLayer(l, sqfsPath); err != nil {
+		return nil, err
+	}
+	defer os.Remove(sqfsPath)
+
+	tarPath := filepath.Join(workDir, fmt.Sprintf("layer-%d.tar", i))
+	out, err := os.Create(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(sqfs2tar, "--no-skip", sqfsPath)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	ferr := overlayToAUFS(stdout, out)
+	// drain the output, so that sqfs2tar exits if the filter failed
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("sqfs2tar error: %w, output: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if ferr != nil {
+		return nil, ferr
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	return tarball.LayerFromFile(tarPath, tarball.WithCompressedCaching)
+}
+
+// overlayToAUFS streams a layer tar from in to out, replacing overlayfs
+// whiteouts, character devices with device number 0/0, with AUFS .wh.<name>
+// files, and the opaque extended attribute of directories with AUFS
+// .wh..wh..opq marker files.
+func overlayToAUFS(in io.Reader, out io.Writer) error {
+	tr := tar.NewReader(in)
+	tw := tar.NewWriter(out)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeChar && header.Devmajor == 0 && header.Devminor == 0 {
+			name := strings.TrimSuffix(header.Name, "/")
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     path.Join(path.Dir(name), aufsWhiteoutPrefix+path.Base(name)),
+				Mode:     0o600,
+				Uid:      header.Uid,
+				Gid:      header.Gid,
+				ModTime:  header.ModTime,
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		opaque := false
+		if header.Typeflag == tar.TypeDir {
+			for _, r := range opaqueXattrRecords {
+				if v, ok := header.PAXRecords[r]; ok {
+					opaque = opaque || v == "y"
+					delete(header.PAXRecords, r)
+					//nolint:staticcheck // Xattrs is populated by tar.Reader, and written back by tar.Writer.
+					delete(header.Xattrs, strings.TrimPrefix(r, "SCHILY.xattr."))
+				}
+			}
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		//nolint:gosec // streaming from tar reader to tar writer
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+
+		if opaque {
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     path.Join(header.Name, aufsOpaqueMarker),
+				Mode:     0o600,
+				Uid:      header.Uid,
+				Gid:      header.Gid,
+				ModTime:  header.ModTime,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
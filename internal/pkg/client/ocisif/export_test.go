@@ -0,0 +1,97 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gotest.tools/v3/assert"
+)
+
+func TestOverlayToAUFS(t *testing.T) {
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	entries := []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "etc/", Mode: 0o755},
+		{Typeflag: tar.TypeChar, Name: "etc/motd", Mode: 0o600},
+		{
+			Typeflag:   tar.TypeDir,
+			Name:       "opt/app/",
+			Mode:       0o755,
+			Format:     tar.FormatPAX,
+			PAXRecords: map[string]string{"SCHILY.xattr.trusted.overlay.opaque": "y"},
+		},
+		{Typeflag: tar.TypeChar, Name: "dev/null", Mode: 0o666, Devmajor: 1, Devminor: 3},
+		{Typeflag: tar.TypeReg, Name: "etc/hosts", Mode: 0o644, Size: 5},
+	}
+	for _, h := range entries {
+		assert.NilError(t, tw.WriteHeader(h))
+		if h.Size > 0 {
+			_, err := tw.Write([]byte("hosts"))
+			assert.NilError(t, err)
+		}
+	}
+	assert.NilError(t, tw.Close())
+
+	var out bytes.Buffer
+	assert.NilError(t, overlayToAUFS(&in, &out))
+
+	type entry struct {
+		Name     string
+		Typeflag byte
+		Opaque   bool
+		Content  string
+	}
+	var got []entry
+	tr := tar.NewReader(&out)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		content, err := io.ReadAll(tr)
+		assert.NilError(t, err)
+		_, opaque := h.PAXRecords["SCHILY.xattr.trusted.overlay.opaque"]
+		got = append(got, entry{h.Name, h.Typeflag, opaque, string(content)})
+	}
+
+	want := []entry{
+		{Name: "etc/", Typeflag: tar.TypeDir},
+		{Name: "etc/.wh.motd", Typeflag: tar.TypeReg},
+		{Name: "opt/app/", Typeflag: tar.TypeDir},
+		{Name: "opt/app/.wh..wh..opq", Typeflag: tar.TypeReg},
+		{Name: "dev/null", Typeflag: tar.TypeChar},
+		{Name: "etc/hosts", Typeflag: tar.TypeReg, Content: "hosts"},
+	}
+	assert.DeepEqual(t, got, want)
+}
+
+func TestEngineTag(t *testing.T) {
+	tests := []struct {
+		ref      string
+		registry string
+		want     string
+	}{
+		{ref: "//alpine", registry: name.DefaultRegistry, want: "index.docker.io/library/alpine:latest"},
+		{ref: "myimage:1.0", registry: podmanDefaultRegistry, want: "localhost/myimage:1.0"},
+		{ref: "quay.io/org/app:v2", registry: podmanDefaultRegistry, want: "quay.io/org/app:v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			tag, err := engineTag(tt.ref, tt.registry)
+			assert.NilError(t, err)
+			assert.Equal(t, tag.Name(), tt.want)
+		})
+	}
+
+	_, err := engineTag("Invalid Name", name.DefaultRegistry)
+	assert.ErrorContains(t, err, "invalid reference")
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	ggcrmutate "github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sylabs/oci-tools/pkg/mutate"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+// CreateImage writes an OCI-SIF to dest, holding a single image with the
+// squashfs root filesystem at sqfsPath as its only layer, and cfg as its
+// config for platform. The image is created at the time created, and comment
+// is recorded in the image history.
+func CreateImage(sqfsPath, dest string, platform ggcrv1.Platform, cfg ggcrv1.Config, created time.Time, comment string) error {
+	l, err := newFileLayer(sqfsPath, SquashfsLayerMediaType)
+	if err != nil {
+		return fmt.Errorf("while opening squashfs layer: %w", err)
+	}
+
+	img, err := ggcrmutate.Append(empty.Image, ggcrmutate.Addendum{
+		Layer: l,
+		History: ggcrv1.History{
+			Created:   ggcrv1.Time{Time: created},
+			CreatedBy: useragent.Value(),
+			Comment:   comment,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("while adding layer: %w", err)
+	}
+
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return fmt.Errorf("while retrieving config: %w", err)
+	}
+	cf = cf.DeepCopy()
+	cf.Created = ggcrv1.Time{Time: created}
+	cf.OS = platform.OS
+	cf.Architecture = platform.Architecture
+	cf.Variant = platform.Variant
+	cf.Config = cfg
+	img, err = ggcrmutate.ConfigFile(img, cf)
+	if err != nil {
+		return fmt.Errorf("while setting config: %w", err)
+	}
+	img = ggcrmutate.MediaType(img, types.OCIManifestSchema1)
+	img = ggcrmutate.ConfigMediaType(img, types.OCIConfigJSON)
+
+	ii := ggcrmutate.AppendManifests(empty.Index, ggcrmutate.IndexAddendum{
+		Add: img,
+	})
+	return ocisif.Write(dest, ii)
+}
+
+// ReplaceSquashfsLayer writes to dest a copy of the single image in the
+// OCI-SIF at src, whose single squashfs layer is replaced by the squashfs
+// filesystem at sqfsPath. The layer digest, and the diff_id in the image
+// config, are updated, so the manifest and config digests of the copy differ
+// from those of src. Other data objects of src are not copied.
+func ReplaceSquashfsLayer(src, dest, sqfsPath string) error {
+	fi, err := sif.LoadContainerFromPath(src, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("while retrieving layers: %w", err)
+	}
+	if len(layers) != 1 {
+		return fmt.Errorf("only oci-sif files with a single layer are supported, %s has %d layers", src, len(layers))
+	}
+	mt, err := layers[0].MediaType()
+	if err != nil {
+		return err
+	}
+	if mt != SquashfsLayerMediaType {
+		return fmt.Errorf("unsupported layer mediaType %q", mt)
+	}
+
+	l, err := newFileLayer(sqfsPath, SquashfsLayerMediaType)
+	if err != nil {
+		return fmt.Errorf("while opening squashfs layer: %w", err)
+	}
+	img, err = mutate.Apply(img, mutate.SetLayer(0, l))
+	if err != nil {
+		return fmt.Errorf("while replacing layer: %w", err)
+	}
+
+	ii := ggcrmutate.AppendManifests(empty.Index, ggcrmutate.IndexAddendum{
+		Add: img,
+	})
+	return ocisif.Write(dest, ii)
+}
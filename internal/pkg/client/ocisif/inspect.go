@@ -0,0 +1,111 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/sif/v2/pkg/sif"
+)
+
+// LayerInfo describes a single layer of an OCI-SIF image.
+type LayerInfo struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+}
+
+// ImageInfo holds the details of the single image in an OCI-SIF, collected
+// from its index, manifest and config.
+type ImageInfo struct {
+	Digest       string              `json:"digest"`
+	MediaType    string              `json:"mediaType"`
+	Created      string              `json:"created,omitempty"`
+	Architecture string              `json:"architecture,omitempty"`
+	OS           string              `json:"os,omitempty"`
+	Variant      string              `json:"variant,omitempty"`
+	Entrypoint   []string            `json:"entrypoint,omitempty"`
+	Cmd          []string            `json:"cmd,omitempty"`
+	Env          []string            `json:"env,omitempty"`
+	WorkingDir   string              `json:"workingDir,omitempty"`
+	User         string              `json:"user,omitempty"`
+	Labels       map[string]string   `json:"labels,omitempty"`
+	Annotations  map[string]string   `json:"annotations,omitempty"`
+	Layers       []LayerInfo         `json:"layers"`
+	History      []imgspecv1.History `json:"history,omitempty"`
+	Volumes      map[string]struct{} `json:"volumes,omitempty"`
+	ExposedPorts map[string]struct{} `json:"exposedPorts,omitempty"`
+	StopSignal   string              `json:"stopSignal,omitempty"`
+	RootFS       imgspecv1.RootFS    `json:"rootfs"`
+}
+
+// Inspect returns the details of the single image in the OCI-SIF at path.
+func Inspect(path string) (*ImageInfo, error) {
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining image digest: %w", err)
+	}
+	mf, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining manifest: %w", err)
+	}
+	rawConf, err := img.RawConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("while retrieving image config: %w", err)
+	}
+	var imageSpec imgspecv1.Image
+	if err := json.Unmarshal(rawConf, &imageSpec); err != nil {
+		return nil, fmt.Errorf("while parsing image spec: %w", err)
+	}
+
+	info := &ImageInfo{
+		Digest:       digest.String(),
+		MediaType:    string(mf.MediaType),
+		Architecture: imageSpec.Architecture,
+		OS:           imageSpec.OS,
+		Variant:      imageSpec.Variant,
+		Entrypoint:   imageSpec.Config.Entrypoint,
+		Cmd:          imageSpec.Config.Cmd,
+		Env:          imageSpec.Config.Env,
+		WorkingDir:   imageSpec.Config.WorkingDir,
+		User:         imageSpec.Config.User,
+		Labels:       imageSpec.Config.Labels,
+		Annotations:  mf.Annotations,
+		History:      imageSpec.History,
+		Volumes:      imageSpec.Config.Volumes,
+		ExposedPorts: imageSpec.Config.ExposedPorts,
+		StopSignal:   imageSpec.Config.StopSignal,
+		RootFS:       imageSpec.RootFS,
+		Layers:       make([]LayerInfo, 0, len(mf.Layers)),
+	}
+	if imageSpec.Created != nil {
+		info.Created = imageSpec.Created.UTC().Format(time.RFC3339)
+	}
+	for _, l := range mf.Layers {
+		info.Layers = append(info.Layers, LayerInfo{
+			Digest:    l.Digest.String(),
+			MediaType: string(l.MediaType),
+			Size:      l.Size,
+		})
+	}
+
+	return info, nil
+}
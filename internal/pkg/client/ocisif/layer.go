@@ -0,0 +1,67 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"io"
+	"os"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// fileLayer is a layer held in a file, such as a squashfs filesystem or a
+// LUKS2 encrypted squashfs filesystem, which is stored without compression.
+type fileLayer struct {
+	path      string
+	hash      ggcrv1.Hash
+	size      int64
+	mediaType types.MediaType
+}
+
+func newFileLayer(path string, mediaType types.MediaType) (*fileLayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h, n, err := ggcrv1.SHA256(f)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLayer{path: path, hash: h, size: n, mediaType: mediaType}, nil
+}
+
+// Digest returns the Hash of the compressed layer.
+func (l *fileLayer) Digest() (ggcrv1.Hash, error) {
+	return l.hash, nil
+}
+
+// DiffID returns the Hash of the uncompressed layer.
+func (l *fileLayer) DiffID() (ggcrv1.Hash, error) {
+	return l.hash, nil
+}
+
+// Compressed returns an io.ReadCloser for the compressed layer contents.
+func (l *fileLayer) Compressed() (io.ReadCloser, error) {
+	return os.Open(l.path)
+}
+
+// Uncompressed returns an io.ReadCloser for the uncompressed layer contents.
+func (l *fileLayer) Uncompressed() (io.ReadCloser, error) {
+	return os.Open(l.path)
+}
+
+// Size returns the compressed size of the Layer.
+func (l *fileLayer) Size() (int64, error) {
+	return l.size, nil
+}
+
+// MediaType returns the media type of the Layer.
+func (l *fileLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
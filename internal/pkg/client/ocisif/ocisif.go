@@ -8,6 +8,7 @@ package ocisif
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -23,6 +24,7 @@ import (
 	ggcrmutate "github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sylabs/oci-tools/pkg/mutate"
 	ocisif "github.com/sylabs/oci-tools/pkg/sif"
 	"github.com/sylabs/sif/v2/pkg/sif"
@@ -33,6 +35,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential/ociauth"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/tracing"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
 	"golang.org/x/term"
@@ -195,7 +198,7 @@ func createOciSif(ctx context.Context, tOpts *ocitransport.TransportOptions, img
 
 	// Otherwise, conversion and optional squashing are required.
 	sylog.Infof("Converting OCI image to OCI-SIF format")
-	return convertLayoutToOciSif(layoutDir, digest, imageDest, workDir, opts.KeepLayers)
+	return convertLayoutToOciSif(ctx, layoutDir, digest, imageDest, workDir, opts.KeepLayers)
 }
 
 // writeLayoutToOciSif will write an image from an OCI layout to an oci-sif without applying any mutations.
@@ -216,7 +219,10 @@ func writeLayoutToOciSif(layoutDir string, digest ggcrv1.Hash, imageDest string)
 
 // convertLayoutToOciSif will convert an image in an OCI layout to an oci-sif with squashfs layer format.
 // The OCI layout can contain only a single image.
-func convertLayoutToOciSif(layoutDir string, digest ggcrv1.Hash, imageDest, workDir string, keepLayers bool) error {
+func convertLayoutToOciSif(ctx context.Context, layoutDir string, digest ggcrv1.Hash, imageDest, workDir string, keepLayers bool) (err error) {
+	_, span := tracing.Start(ctx, "convert to squashfs")
+	defer func() { tracing.End(span, err) }()
+
 	lp, err := layout.FromPath(layoutDir)
 	if err != nil {
 		return fmt.Errorf("while opening layout: %w", err)
@@ -285,6 +291,142 @@ func imgLayersToSquashfs(img ggcrv1.Image, digest ggcrv1.Hash, workDir string) (
 	return sqfsImage, nil
 }
 
+// ImageSpec returns the OCI image config of the single image in the OCI-SIF at
+// path.
+func ImageSpec(path string) (*imgspecv1.Image, error) {
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return nil, err
+	}
+	rawConf, err := img.RawConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("while retrieving image config: %w", err)
+	}
+	var imageSpec imgspecv1.Image
+	if err := json.Unmarshal(rawConf, &imageSpec); err != nil {
+		return nil, fmt.Errorf("while parsing image spec: %w", err)
+	}
+	return &imageSpec, nil
+}
+
+// ImageManifest returns the manifest of the single image in the OCI-SIF at
+// path.
+func ImageManifest(path string) (*ggcrv1.Manifest, error) {
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return nil, err
+	}
+	mf, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining manifest: %w", err)
+	}
+	return mf, nil
+}
+
+// SquashfsLayerOffset returns the offset, in the OCI-SIF at path, of the
+// single squashfs layer of its single image. Images with multiple layers are
+// not supported, as their layers must be combined by an overlay.
+func SquashfsLayerOffset(path string) (int64, error) {
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return 0, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return 0, err
+	}
+	mf, err := img.Manifest()
+	if err != nil {
+		return 0, fmt.Errorf("while obtaining manifest: %w", err)
+	}
+	if len(mf.Layers) != 1 {
+		return 0, fmt.Errorf("only oci-sif files with a single layer are supported, %s has %d layers", path, len(mf.Layers))
+	}
+	if mf.Layers[0].MediaType != SquashfsLayerMediaType {
+		return 0, fmt.Errorf("unsupported layer mediaType %q", mf.Layers[0].MediaType)
+	}
+
+	d, err := fi.GetDescriptor(sif.WithOCIBlobDigest(mf.Layers[0].Digest))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get layer descriptor: %w", err)
+	}
+	return d.Offset(), nil
+}
+
+// SquashfsLayerOffsetByID returns the offset, in the OCI-SIF at path, of the
+// squashfs layer of its single image held in the descriptor with id.
+func SquashfsLayerOffsetByID(path string, id uint32) (int64, error) {
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return 0, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer fi.UnloadContainer()
+
+	d, err := fi.GetDescriptor(sif.WithID(id))
+	if err != nil {
+		return 0, fmt.Errorf("while getting descriptor %d: %w", id, err)
+	}
+	if d.DataType() != sif.DataOCIBlob {
+		return 0, fmt.Errorf("descriptor %d of %s is not an OCI blob", id, path)
+	}
+	h, err := d.OCIBlobDigest()
+	if err != nil {
+		return 0, fmt.Errorf("while getting digest of descriptor %d: %w", id, err)
+	}
+
+	img, err := singleImage(fi)
+	if err != nil {
+		return 0, err
+	}
+	mf, err := img.Manifest()
+	if err != nil {
+		return 0, fmt.Errorf("while obtaining manifest: %w", err)
+	}
+	for _, l := range mf.Layers {
+		if l.Digest != h {
+			continue
+		}
+		if l.MediaType != SquashfsLayerMediaType {
+			return 0, fmt.Errorf("unsupported layer mediaType %q", l.MediaType)
+		}
+		return d.Offset(), nil
+	}
+	return 0, fmt.Errorf("descriptor %d of %s is not a layer of its image", id, path)
+}
+
+func singleImage(fi *sif.FileImage) (ggcrv1.Image, error) {
+	ix, err := ocisif.ImageIndexFromFileImage(fi)
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining image index: %w", err)
+	}
+	idxManifest, err := ix.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining index manifest: %w", err)
+	}
+	if len(idxManifest.Manifests) != 1 {
+		return nil, fmt.Errorf("only single image oci-sif files are supported")
+	}
+	img, err := ix.Image(idxManifest.Manifests[0].Digest)
+	if err != nil {
+		return nil, fmt.Errorf("while initializing image: %w", err)
+	}
+	return img, nil
+}
+
 // PushOCISIF pushes a single image from sourceFile to the OCI registry destRef.
 func PushOCISIF(ctx context.Context, sourceFile, destRef string, ociAuth *authn.AuthConfig, reqAuthFile string) error {
 	destRef = strings.TrimPrefix(destRef, "docker://")
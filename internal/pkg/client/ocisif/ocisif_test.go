@@ -0,0 +1,96 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocisif
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"gotest.tools/v3/assert"
+)
+
+func TestSquashfsLayerOffsetByID(t *testing.T) {
+	img, err := mutate.AppendLayers(empty.Image,
+		static.NewLayer([]byte("first"), SquashfsLayerMediaType),
+		static.NewLayer([]byte("second"), SquashfsLayerMediaType),
+	)
+	assert.NilError(t, err)
+	path := filepath.Join(t.TempDir(), "image.oci.sif")
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+	assert.NilError(t, ocisif.Write(path, ii))
+
+	mf, err := img.Manifest()
+	assert.NilError(t, err)
+	cfg, err := img.ConfigName()
+	assert.NilError(t, err)
+
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	assert.NilError(t, err)
+	second, err := fi.GetDescriptor(sif.WithOCIBlobDigest(mf.Layers[1].Digest))
+	assert.NilError(t, err)
+	config, err := fi.GetDescriptor(sif.WithOCIBlobDigest(cfg))
+	assert.NilError(t, err)
+	assert.NilError(t, fi.UnloadContainer())
+
+	offset, err := SquashfsLayerOffsetByID(path, second.ID())
+	assert.NilError(t, err)
+	assert.Equal(t, offset, second.Offset())
+
+	_, err = SquashfsLayerOffsetByID(path, config.ID())
+	assert.ErrorContains(t, err, "is not a layer of its image")
+
+	_, err = SquashfsLayerOffsetByID(path, 999)
+	assert.ErrorContains(t, err, "while getting descriptor 999")
+
+	_, err = SquashfsLayerOffset(path)
+	assert.ErrorContains(t, err, "only oci-sif files with a single layer are supported")
+}
+
+func TestReplaceSquashfsLayer(t *testing.T) {
+	dir := t.TempDir()
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer([]byte("original"), SquashfsLayerMediaType))
+	assert.NilError(t, err)
+	src := filepath.Join(dir, "image.oci.sif")
+	assert.NilError(t, ocisif.Write(src, mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})))
+
+	img, err = mutate.AppendLayers(img, static.NewLayer([]byte("second"), SquashfsLayerMediaType))
+	assert.NilError(t, err)
+	multi := filepath.Join(dir, "multi.oci.sif")
+	assert.NilError(t, ocisif.Write(multi, mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})))
+
+	sqfs := filepath.Join(dir, "layer.sqfs")
+	assert.NilError(t, os.WriteFile(sqfs, []byte("replaced"), 0o644))
+
+	dst := filepath.Join(dir, "replaced.oci.sif")
+	assert.NilError(t, ReplaceSquashfsLayer(src, dst, sqfs))
+
+	mf, err := ImageManifest(dst)
+	assert.NilError(t, err)
+	assert.Equal(t, len(mf.Layers), 1)
+	assert.Equal(t, mf.Layers[0].MediaType, SquashfsLayerMediaType)
+	assert.Equal(t, mf.Layers[0].Size, int64(len("replaced")))
+	spec, err := ImageSpec(dst)
+	assert.NilError(t, err)
+	assert.Equal(t, spec.RootFS.DiffIDs[0].String(), mf.Layers[0].Digest.String())
+
+	fi, err := sif.LoadContainerFromPath(dst, sif.OptLoadWithFlag(os.O_RDONLY))
+	assert.NilError(t, err)
+	defer fi.UnloadContainer()
+	d, err := fi.GetDescriptor(sif.WithOCIBlobDigest(mf.Layers[0].Digest))
+	assert.NilError(t, err)
+	b, err := d.GetData()
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "replaced")
+
+	err = ReplaceSquashfsLayer(multi, filepath.Join(dir, "multi.out.sif"), sqfs)
+	assert.ErrorContains(t, err, "only oci-sif files with a single layer are supported")
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oras
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+)
+
+func init() {
+	transport.Register(Transport{}, "oras")
+}
+
+// Transport is the transport for oras:// URIs, which retrieves SIF images
+// from, and pushes SIF images to, OCI registries.
+type Transport struct{}
+
+// Resolve returns ref with the default registry and tag applied.
+func (Transport) Resolve(_ context.Context, ref string, _ transport.Options) (string, error) {
+	r, err := name.ParseReference(strings.TrimPrefix(strings.TrimPrefix(ref, "oras://"), "//"),
+		name.WithDefaultTag(name.DefaultTag),
+		name.WithDefaultRegistry(name.DefaultRegistry),
+	)
+	if err != nil {
+		return "", err
+	}
+	return "oras://" + r.Name(), nil
+}
+
+// Fetch pulls the SIF image at ref to dst, or to the cache if dst is not set.
+func (Transport) Fetch(ctx context.Context, imgCache *cache.Handle, ref, dst string, opts transport.Options) (string, error) {
+	if dst == "" {
+		return Pull(ctx, imgCache, ref, opts.TmpDir, opts.OciAuth, opts.ReqAuthFile)
+	}
+	return PullToFile(ctx, imgCache, dst, ref, opts.OciAuth, opts.ReqAuthFile)
+}
+
+// Push uploads the SIF image at src to ref.
+func (Transport) Push(ctx context.Context, src, ref string, opts transport.Options) error {
+	return UploadImage(ctx, src, ref, opts.OciAuth, opts.ReqAuthFile)
+}
+
+// Digest returns the digest of the SIF layer of the image at ref.
+func (Transport) Digest(ctx context.Context, ref string, opts transport.Options) (string, error) {
+	h, err := RefHash(ctx, ref, opts.OciAuth, opts.ReqAuthFile)
+	if err != nil {
+		return "", err
+	}
+	return h.String(), nil
+}
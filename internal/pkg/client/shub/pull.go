@@ -35,7 +35,7 @@ func DownloadImage(ctx context.Context, manifest APIResponse, filePath, shubRef
 
 	// use custom parser to make sure we have a valid shub URI
 	if ok := isShubPullRef(shubRef); !ok {
-		sylog.Fatalf("Invalid shub URI")
+		return fmt.Errorf("invalid shub URI: %s", shubRef)
 	}
 
 	shubURI, err := ParseReference(shubRef)
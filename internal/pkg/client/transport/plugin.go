@@ -0,0 +1,141 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	imagecallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// RegisterPlugin registers the image transport t, provided by a plugin, for
+// URIs with the given schemes. Unlike Register, an error is returned if a
+// transport is already registered for one of the schemes, and no scheme is
+// registered in that case.
+func RegisterPlugin(t imagecallback.ImageTransport, schemes ...string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(schemes) == 0 {
+		return errors.New("no URI scheme for image transport")
+	}
+	for _, s := range schemes {
+		if s == "" {
+			return errors.New("empty URI scheme for image transport")
+		}
+		if _, ok := transports[s]; ok {
+			return fmt.Errorf("transport already registered for %s://", s)
+		}
+	}
+	for _, s := range schemes {
+		transports[s] = pluginTransport{t}
+	}
+	return nil
+}
+
+// pluginTransport adapts the ImageTransport of a plugin to a Transport.
+type pluginTransport struct {
+	t imagecallback.ImageTransport
+}
+
+func pluginOptions(opts Options) imagecallback.TransportOptions {
+	return imagecallback.TransportOptions{
+		TmpDir:   opts.TmpDir,
+		NoHTTPS:  opts.NoHTTPS,
+		AuthFile: opts.ReqAuthFile,
+	}
+}
+
+// pluginError maps the ErrUnsupported error of plugins to ErrUnsupported.
+func pluginError(err error) error {
+	if errors.Is(err, imagecallback.ErrUnsupported) {
+		return ErrUnsupported
+	}
+	return err
+}
+
+// Resolve returns ref unchanged, as its form is specific to the plugin.
+func (pluginTransport) Resolve(_ context.Context, ref string, _ Options) (string, error) {
+	return ref, nil
+}
+
+// Fetch pulls the image at ref to dst. If dst is not set, the image is pulled
+// to the cache if the plugin supports Digest, or to a temporary file
+// otherwise.
+func (p pluginTransport) Fetch(ctx context.Context, imgCache *cache.Handle, ref, dst string, opts Options) (string, error) {
+	pOpts := pluginOptions(opts)
+
+	if dst != "" {
+		if err := p.t.Pull(ctx, ref, dst, pOpts); err != nil {
+			return "", pluginError(err)
+		}
+		return dst, nil
+	}
+
+	digest := ""
+	if imgCache != nil && !imgCache.IsDisabled() {
+		d, err := p.t.Digest(ctx, ref, pOpts)
+		if err != nil && !errors.Is(err, imagecallback.ErrUnsupported) {
+			return "", fmt.Errorf("while getting image digest: %w", err)
+		}
+		digest = d
+	}
+
+	if digest == "" {
+		f, err := os.CreateTemp(opts.TmpDir, "sbuild-tmp-cache-")
+		if err != nil {
+			return "", fmt.Errorf("unable to create tmp file: %v", err)
+		}
+		f.Close()
+		sylog.Infof("Pulling image to tmp cache: %s", f.Name())
+		if err := p.t.Pull(ctx, ref, f.Name(), pOpts); err != nil {
+			os.Remove(f.Name())
+			return "", pluginError(err)
+		}
+		return f.Name(), nil
+	}
+
+	h := sha256.Sum256([]byte(ref + digest))
+	hash := hex.EncodeToString(h[:])
+	sylog.Debugf("Image hash for cache is: %s", hash)
+
+	cacheEntry, err := imgCache.GetEntry(cache.PluginCacheType, hash)
+	if err != nil {
+		return "", fmt.Errorf("unable to check if %v exists in cache: %v", hash, err)
+	}
+	defer cacheEntry.CleanTmp()
+
+	if cacheEntry.Exists {
+		sylog.Verbosef("Using image from cache")
+		return cacheEntry.Path, nil
+	}
+
+	if err := p.t.Pull(ctx, ref, cacheEntry.TmpPath, pOpts); err != nil {
+		return "", pluginError(err)
+	}
+	if err := cacheEntry.Finalize(); err != nil {
+		return "", err
+	}
+	return cacheEntry.Path, nil
+}
+
+// Push pushes the image at src to ref.
+func (p pluginTransport) Push(ctx context.Context, src, ref string, opts Options) error {
+	return pluginError(p.t.Push(ctx, src, ref, pluginOptions(opts)))
+}
+
+// Digest returns the digest of the image at ref, as reported by the plugin.
+func (p pluginTransport) Digest(ctx context.Context, ref string, opts Options) (string, error) {
+	d, err := p.t.Digest(ctx, ref, pluginOptions(opts))
+	return d, pluginError(err)
+}
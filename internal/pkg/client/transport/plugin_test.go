@@ -0,0 +1,145 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	imagecallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/image"
+)
+
+// testImageTransport is a plugin image transport writing the content of
+// images to pulled files.
+type testImageTransport struct {
+	images map[string]string
+	digest bool
+	pulls  int
+}
+
+func (t *testImageTransport) Pull(_ context.Context, ref, dst string, _ imagecallback.TransportOptions) error {
+	t.pulls++
+	content, ok := t.images[ref]
+	if !ok {
+		return errors.New("image not found")
+	}
+	return os.WriteFile(dst, []byte(content), 0o644)
+}
+
+func (t *testImageTransport) Push(context.Context, string, string, imagecallback.TransportOptions) error {
+	return imagecallback.ErrUnsupported
+}
+
+func (t *testImageTransport) Digest(_ context.Context, ref string, _ imagecallback.TransportOptions) (string, error) {
+	if !t.digest {
+		return "", imagecallback.ErrUnsupported
+	}
+	return t.images[ref], nil
+}
+
+func TestRegisterPlugin(t *testing.T) {
+	defer func(orig map[string]Transport) { transports = orig }(transports)
+	transports = make(map[string]Transport)
+
+	Register(testTransport{"a"}, "globus")
+
+	it := &testImageTransport{}
+	if err := RegisterPlugin(it, "s3", "globus"); err == nil {
+		t.Errorf("RegisterPlugin for a registered scheme succeeded")
+	}
+	if _, ok := Lookup("s3"); ok {
+		t.Errorf("RegisterPlugin registered a scheme on error")
+	}
+	if err := RegisterPlugin(it); err == nil {
+		t.Errorf("RegisterPlugin without scheme succeeded")
+	}
+	if err := RegisterPlugin(it, "s3", "cvmfs"); err != nil {
+		t.Fatalf("RegisterPlugin failed: %v", err)
+	}
+	for _, s := range []string{"s3", "cvmfs"} {
+		got, ok := Lookup(s)
+		if !ok || got != (pluginTransport{it}) {
+			t.Errorf("Lookup(%q) = %v, %v, want plugin transport", s, got, ok)
+		}
+	}
+}
+
+func TestPluginTransportFetch(t *testing.T) {
+	const ref = "s3://bucket/image.sif"
+
+	tests := []struct {
+		name         string
+		digest       bool
+		disableCache bool
+		dst          bool
+		ref          string
+		wantInCache  bool
+		wantPulls    int
+		wantErr      bool
+	}{
+		{name: "Dst", dst: true, ref: ref, wantPulls: 2},
+		{name: "Cached", digest: true, ref: ref, wantInCache: true, wantPulls: 1},
+		{name: "NoDigest", ref: ref, wantPulls: 2},
+		{name: "CacheDisabled", digest: true, disableCache: true, ref: ref, wantPulls: 2},
+		{name: "NotFound", digest: true, ref: "s3://bucket/missing.sif", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheDir := t.TempDir()
+			imgCache, err := cache.New(cache.Config{ParentDir: cacheDir, Disable: tt.disableCache})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			it := &testImageTransport{images: map[string]string{ref: "image"}, digest: tt.digest}
+			p := pluginTransport{it}
+			opts := Options{TmpDir: t.TempDir()}
+
+			// Fetch twice, to check whether the image is pulled from the cache.
+			for i := 0; i < 2; i++ {
+				dst := ""
+				if tt.dst {
+					dst = filepath.Join(t.TempDir(), "image.sif")
+				}
+
+				path, err := p.Fetch(context.Background(), imgCache, tt.ref, dst, opts)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+
+				if tt.dst && path != dst {
+					t.Errorf("Fetch() = %s, want %s", path, dst)
+				}
+				cacheTypeDir, err := imgCache.GetFileCacheDir(cache.PluginCacheType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if inCache := filepath.Dir(path) == cacheTypeDir; inCache != tt.wantInCache {
+					t.Errorf("Fetch() = %s, in cache %v, want %v", path, inCache, tt.wantInCache)
+				}
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "image" {
+					t.Errorf("pulled image content = %q, want %q", b, "image")
+				}
+			}
+
+			if it.pulls != tt.wantPulls {
+				t.Errorf("image pulled %d times, want %d", it.pulls, tt.wantPulls)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package transport provides a registry of the transports that retrieve
+// images from, and push images to, URIs with a given scheme. A transport is
+// registered, usually from the init function of its package, with Register,
+// and is then available to the pull, push, and action commands.
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+)
+
+// ErrUnsupported is returned by the methods of a Transport for operations it
+// does not support.
+var ErrUnsupported = errors.New("operation not supported by transport")
+
+// Options holds the options, set from the command line, that apply to
+// transports. Transports ignore options that are not relevant to them.
+type Options struct {
+	// TmpDir is the directory used for temporary files.
+	TmpDir string
+	// NoHTTPS disables the use of TLS.
+	NoHTTPS bool
+	// OciAuth holds credentials for OCI registries.
+	OciAuth *authn.AuthConfig
+	// ReqAuthFile is the path of an OCI registry authentication file.
+	ReqAuthFile string
+}
+
+// Transport retrieves images from, and pushes images to, URIs with the schemes
+// it is registered for. URIs are passed to its methods in full, including the
+// scheme.
+type Transport interface {
+	// Resolve returns the canonical form of the URI ref.
+	Resolve(ctx context.Context, ref string, opts Options) (string, error)
+	// Fetch retrieves the image at ref, and returns its path. The image is
+	// retrieved to dst if set, or to imgCache otherwise.
+	Fetch(ctx context.Context, imgCache *cache.Handle, ref, dst string, opts Options) (string, error)
+	// Push pushes the image at path src to ref.
+	Push(ctx context.Context, src, ref string, opts Options) error
+	// Digest returns the digest of the image at ref.
+	Digest(ctx context.Context, ref string, opts Options) (string, error)
+}
+
+var (
+	mu         sync.RWMutex
+	transports = make(map[string]Transport)
+)
+
+// Register registers t as the transport for URIs with the given schemes. It
+// panics if a transport is already registered for one of the schemes.
+func Register(t Transport, schemes ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, s := range schemes {
+		if _, ok := transports[s]; ok {
+			panic(fmt.Sprintf("transport already registered for %s://", s))
+		}
+		transports[s] = t
+	}
+}
+
+// Lookup returns the transport registered for URIs with scheme.
+func Lookup(scheme string) (Transport, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	t, ok := transports[scheme]
+	return t, ok
+}
+
+// Schemes returns the sorted list of schemes for which a transport is
+// registered.
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	schemes := make([]string, 0, len(transports))
+	for s := range transports {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package transport
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+)
+
+type testTransport struct{ name string }
+
+func (testTransport) Resolve(_ context.Context, ref string, _ Options) (string, error) {
+	return ref, nil
+}
+
+func (testTransport) Fetch(context.Context, *cache.Handle, string, string, Options) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (testTransport) Push(context.Context, string, string, Options) error {
+	return ErrUnsupported
+}
+
+func (testTransport) Digest(context.Context, string, Options) (string, error) {
+	return "", ErrUnsupported
+}
+
+func TestRegister(t *testing.T) {
+	defer func(orig map[string]Transport) { transports = orig }(transports)
+	transports = make(map[string]Transport)
+
+	a := testTransport{"a"}
+	b := testTransport{"b"}
+	Register(a, "globus", "dataverse")
+	Register(b, "site")
+
+	tests := []struct {
+		scheme string
+		want   Transport
+		wantOK bool
+	}{
+		{"globus", a, true},
+		{"dataverse", a, true},
+		{"site", b, true},
+		{"unknown", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := Lookup(tt.scheme)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.scheme, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if got, want := Schemes(), []string{"dataverse", "globus", "site"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Schemes() = %v, want %v", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register for a registered scheme did not panic")
+		}
+	}()
+	Register(b, "globus")
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package compose reads compose files, describing a set of services run as
+// Singularity instances.
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultFile is the compose file read when none is specified.
+	DefaultFile = "singularity-compose.yml"
+
+	// ProjectLabel and ServiceLabel are the instance labels recording the
+	// project and service an instance was started for.
+	ProjectLabel = "io.sylabs.compose.project"
+	ServiceLabel = "io.sylabs.compose.service"
+
+	// HostNetwork runs a service in the network namespace of the host, shared
+	// by all services using it.
+	HostNetwork = "host"
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Project is a set of services, read from a compose file.
+type Project struct {
+	// Name prefixes the names of the instances of the project services. It
+	// defaults to the name of the directory holding the compose file.
+	Name string `yaml:"name"`
+	// Network is the default network of the project services.
+	Network  string              `yaml:"network"`
+	Services map[string]*Service `yaml:"services"`
+
+	// dir is the directory holding the compose file, to which relative
+	// bind paths are resolved.
+	dir string
+}
+
+// Service is a container run as an instance.
+type Service struct {
+	Image string `yaml:"image"`
+	// Command holds the arguments passed to the startscript of the instance.
+	Command     []string          `yaml:"command"`
+	Binds       []string          `yaml:"binds"`
+	Environment map[string]string `yaml:"environment"`
+	// Network is either host, or the name of a CNI network configuration.
+	Network   string   `yaml:"network"`
+	DependsOn []string `yaml:"depends_on"`
+}
+
+// Load reads the compose file at path.
+func Load(path string) (*Project, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while reading compose file: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Project{dir: filepath.Dir(abs)}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("while parsing compose file %s: %w", path, err)
+	}
+	if p.Name == "" {
+		p.Name = strings.ToLower(filepath.Base(p.dir))
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid compose file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// validate checks the names of p and its services, and that each service has
+// an image and depends on existing services, without cycles.
+func (p *Project) validate() error {
+	if !nameRegexp.MatchString(p.Name) {
+		return fmt.Errorf("invalid project name %q", p.Name)
+	}
+	if len(p.Services) == 0 {
+		return fmt.Errorf("no services defined")
+	}
+	for name, s := range p.Services {
+		if !nameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid service name %q", name)
+		}
+		if s == nil || s.Image == "" {
+			return fmt.Errorf("service %q: no image specified", name)
+		}
+		for _, dep := range s.DependsOn {
+			if _, ok := p.Services[dep]; !ok {
+				return fmt.Errorf("service %q depends on undefined service %q", name, dep)
+			}
+		}
+	}
+	_, err := p.Order()
+	return err
+}
+
+// Order returns the names of the services of p, with each service listed
+// after the services it depends on.
+func (p *Project) Order() ([]string, error) {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, dep := range p.Services[name].DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// InstanceName returns the name of the instance of service.
+func (p *Project) InstanceName(service string) string {
+	return p.Name + "-" + service
+}
+
+// StartArgs returns the arguments of singularity to start the instance of
+// service.
+func (p *Project) StartArgs(service string) []string {
+	s := p.Services[service]
+	args := []string{
+		"instance", "start",
+		"--label", ProjectLabel + "=" + p.Name,
+		"--label", ServiceLabel + "=" + service,
+	}
+
+	network := s.Network
+	if network == "" {
+		network = p.Network
+	}
+	if network != "" && network != HostNetwork {
+		args = append(args, "--net", "--network", network)
+	}
+
+	for _, b := range s.Binds {
+		args = append(args, "--bind", p.resolveBind(b))
+	}
+
+	keys := make([]string, 0, len(s.Environment))
+	for k := range s.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--env", k+"="+s.Environment[k])
+	}
+
+	args = append(args, p.resolveImage(s.Image), p.InstanceName(service))
+	return append(args, s.Command...)
+}
+
+// resolveImage returns the image image, with a relative image file path
+// resolved to the directory of the compose file.
+func (p *Project) resolveImage(image string) string {
+	if strings.Contains(image, "://") || filepath.IsAbs(image) {
+		return image
+	}
+	return filepath.Join(p.dir, image)
+}
+
+// resolveBind returns the bind path spec b, in src[:dest[:opts]] form, with
+// a relative src resolved to the directory of the compose file.
+func (p *Project) resolveBind(b string) string {
+	src, rest, hasRest := strings.Cut(b, ":")
+	if src == "" || filepath.IsAbs(src) {
+		return b
+	}
+	src = filepath.Join(p.dir, src)
+	if hasRest {
+		return src + ":" + rest
+	}
+	return src
+}
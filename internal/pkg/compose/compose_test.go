@@ -0,0 +1,127 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func writeCompose(t *testing.T, content string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "MyApp")
+	assert.NilError(t, os.Mkdir(dir, 0o755))
+	path := filepath.Join(dir, DefaultFile)
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeCompose(t, `
+network: bridge
+services:
+  web:
+    image: docker://nginx:1.25
+    binds:
+      - ./html:/usr/share/nginx/html:ro
+      - /scratch
+    depends_on: [api]
+  api:
+    image: api.sif
+    command: ["--port", "8080"]
+    environment:
+      DB_HOST: localhost
+      DB_USER: api
+    depends_on: [db]
+  db:
+    image: docker://postgres:16
+    network: host
+`)
+	dir := filepath.Dir(path)
+
+	p, err := Load(path)
+	assert.NilError(t, err)
+	assert.Equal(t, p.Name, "myapp")
+
+	order, err := p.Order()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, order, []string{"db", "api", "web"})
+
+	assert.DeepEqual(t, p.StartArgs("web"), []string{
+		"instance", "start",
+		"--label", ProjectLabel + "=myapp",
+		"--label", ServiceLabel + "=web",
+		"--net", "--network", "bridge",
+		"--bind", filepath.Join(dir, "html") + ":/usr/share/nginx/html:ro",
+		"--bind", "/scratch",
+		"docker://nginx:1.25", "myapp-web",
+	})
+	assert.DeepEqual(t, p.StartArgs("api"), []string{
+		"instance", "start",
+		"--label", ProjectLabel + "=myapp",
+		"--label", ServiceLabel + "=api",
+		"--net", "--network", "bridge",
+		"--env", "DB_HOST=localhost",
+		"--env", "DB_USER=api",
+		filepath.Join(dir, "api.sif"), "myapp-api",
+		"--port", "8080",
+	})
+	assert.DeepEqual(t, p.StartArgs("db"), []string{
+		"instance", "start",
+		"--label", ProjectLabel + "=myapp",
+		"--label", ServiceLabel + "=db",
+		"docker://postgres:16", "myapp-db",
+	})
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "NoServices",
+			content: "name: app\n",
+			wantErr: "no services defined",
+		},
+		{
+			name:    "NoImage",
+			content: "services:\n  web:\n    binds: [/data]\n",
+			wantErr: `service "web": no image specified`,
+		},
+		{
+			name:    "UnknownField",
+			content: "services:\n  web:\n    image: web.sif\n    ports: [80]\n",
+			wantErr: "field ports not found",
+		},
+		{
+			name:    "UndefinedDependency",
+			content: "services:\n  web:\n    image: web.sif\n    depends_on: [db]\n",
+			wantErr: `service "web" depends on undefined service "db"`,
+		},
+		{
+			name:    "Cycle",
+			content: "services:\n  a:\n    image: a.sif\n    depends_on: [b]\n  b:\n    image: b.sif\n    depends_on: [a]\n",
+			wantErr: "dependency cycle: a -> b -> a",
+		},
+		{
+			name:    "InvalidProjectName",
+			content: "name: my app\nservices:\n  web:\n    image: web.sif\n",
+			wantErr: `invalid project name "my app"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeCompose(t, tt.content))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
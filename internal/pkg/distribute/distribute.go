@@ -0,0 +1,167 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package distribute copies an image file pulled on one node to the other
+// nodes of a job. Nodes that received the file copy it in turn to further
+// nodes, along a tree, so that the file is pulled from the registry only
+// once, and no single node serves every other node.
+package distribute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// DefaultFanout is the default number of nodes each node copies the file to.
+const DefaultFanout = 4
+
+// Copier copies a file between nodes.
+type Copier interface {
+	// Copy copies the file at path on the node from to the same path on the
+	// node to. The local node is named by an empty from.
+	Copy(ctx context.Context, from, to, path string) error
+}
+
+// Distribute copies the file at path on the local node to the same path on
+// nodes. Each node holding the file copies it to up to fanout nodes. When a
+// copy to a node fails, the nodes it was due to serve are served by its
+// parent instead. The returned error reports all nodes which did not receive
+// the file.
+func Distribute(ctx context.Context, c Copier, path string, nodes []string, fanout int) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path %s is not absolute", path)
+	}
+	if fanout < 1 {
+		return fmt.Errorf("invalid fanout %d", fanout)
+	}
+
+	d := &distribution{c: c, path: path, fanout: fanout}
+	d.serve(ctx, "", nodes)
+	d.wg.Wait()
+	return errors.Join(d.errs...)
+}
+
+type distribution struct {
+	c      Copier
+	path   string
+	fanout int
+
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+// serve copies the file from the node from to the nodes of its subtree. The
+// nodes are split in up to fanout subtrees, of which from serves the first
+// node, which serves in turn the rest of its subtree.
+func (d *distribution) serve(ctx context.Context, from string, nodes []string) {
+	for _, subtree := range split(nodes, d.fanout) {
+		subtree := subtree
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			to, rest := subtree[0], subtree[1:]
+			sylog.Debugf("Copying %s from %s to %s", d.path, nodeName(from), to)
+			if err := d.c.Copy(ctx, from, to, d.path); err != nil {
+				d.mu.Lock()
+				d.errs = append(d.errs, fmt.Errorf("%s: %w", to, err))
+				d.mu.Unlock()
+				// the sender serves the subtree of the failed node itself
+				d.serve(ctx, from, rest)
+				return
+			}
+			d.serve(ctx, to, rest)
+		}()
+	}
+}
+
+// split splits nodes in up to n subtrees of balanced sizes.
+func split(nodes []string, n int) [][]string {
+	if len(nodes) < n {
+		n = len(nodes)
+	}
+	subtrees := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		lo, hi := i*len(nodes)/n, (i+1)*len(nodes)/n
+		subtrees = append(subtrees, nodes[lo:hi])
+	}
+	return subtrees
+}
+
+func nodeName(node string) string {
+	if node == "" {
+		return "local node"
+	}
+	return node
+}
+
+// SSHCopier copies files between nodes with scp, and with ssh to run scp on
+// remote nodes. Nodes must accept non-interactive ssh connections from each
+// other, e.g. with host based authentication or a shared key, as is usual
+// within a cluster.
+type SSHCopier struct {
+	// SSH is the path of the ssh executable.
+	SSH string
+	// SCP is the path of the scp executable, on all nodes.
+	SCP string
+}
+
+// NewSSHCopier returns an SSHCopier using the ssh and scp executables found
+// on PATH.
+func NewSSHCopier() (*SSHCopier, error) {
+	ssh, err := bin.FindBin("ssh")
+	if err != nil {
+		return nil, err
+	}
+	scp, err := bin.FindBin("scp")
+	if err != nil {
+		return nil, err
+	}
+	return &SSHCopier{SSH: ssh, SCP: scp}, nil
+}
+
+// sshOptions disable prompts, which would hang the distribution.
+var sshOptions = []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new"}
+
+// Copy copies the file at path on from to to. The file is written to a
+// temporary name and renamed, so that it never appears partially copied.
+func (c *SSHCopier) Copy(ctx context.Context, from, to, path string) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".part")
+
+	var cmd *exec.Cmd
+	if from == "" {
+		args := append(append([]string{}, sshOptions...), "-q", "-p", path, to+":"+tmp)
+		cmd = exec.CommandContext(ctx, c.SCP, args...)
+	} else {
+		// arguments are run by the shell of the remote node
+		args := append(append([]string{}, sshOptions...), from, "--", shellQuote(c.SCP))
+		for _, a := range append(append([]string{}, sshOptions...), "-q", "-p", path, to+":"+tmp) {
+			args = append(args, shellQuote(a))
+		}
+		cmd = exec.CommandContext(ctx, c.SSH, args...)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("while copying from %s: %v: %s", nodeName(from), err, out)
+	}
+
+	args := append(append([]string{}, sshOptions...), to, "--", "mv", "-f", shellQuote(tmp), shellQuote(path))
+	if out, err := exec.CommandContext(ctx, c.SSH, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("while renaming %s: %v: %s", tmp, err, out)
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
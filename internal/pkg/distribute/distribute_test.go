@@ -0,0 +1,105 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package distribute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeCopier records the nodes holding the file, and fails copies to the
+// nodes in fail.
+type fakeCopier struct {
+	mu      sync.Mutex
+	holders map[string]bool
+	sent    map[string]int
+	fail    map[string]bool
+}
+
+func newFakeCopier(fail ...string) *fakeCopier {
+	c := &fakeCopier{
+		holders: map[string]bool{"": true},
+		sent:    map[string]int{},
+		fail:    map[string]bool{},
+	}
+	for _, n := range fail {
+		c.fail[n] = true
+	}
+	return c
+}
+
+func (c *fakeCopier) Copy(_ context.Context, from, to, _ string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.holders[from] {
+		return fmt.Errorf("%s does not hold the file", from)
+	}
+	if c.holders[to] {
+		return fmt.Errorf("%s already holds the file", to)
+	}
+	if c.fail[to] {
+		return errors.New("unreachable")
+	}
+	c.holders[to] = true
+	c.sent[from]++
+	return nil
+}
+
+func TestDistribute(t *testing.T) {
+	var nodes []string
+	for i := 0; i < 50; i++ {
+		nodes = append(nodes, fmt.Sprintf("node%02d", i))
+	}
+
+	t.Run("All", func(t *testing.T) {
+		c := newFakeCopier()
+		assert.NilError(t, Distribute(context.Background(), c, "/images/test.sif", nodes, 3))
+		for _, n := range nodes {
+			assert.Assert(t, c.holders[n], "%s did not receive the file", n)
+		}
+		for from, n := range c.sent {
+			assert.Assert(t, n <= 3, "%s sent the file to %d nodes", nodeName(from), n)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		c := newFakeCopier("node00", "node20")
+		err := Distribute(context.Background(), c, "/images/test.sif", nodes, 3)
+		assert.ErrorContains(t, err, "node00: unreachable")
+		assert.ErrorContains(t, err, "node20: unreachable")
+
+		var missing []string
+		for _, n := range nodes {
+			if !c.holders[n] {
+				missing = append(missing, n)
+			}
+		}
+		sort.Strings(missing)
+		assert.Equal(t, strings.Join(missing, ","), "node00,node20")
+	})
+
+	t.Run("RelativePath", func(t *testing.T) {
+		err := Distribute(context.Background(), newFakeCopier(), "test.sif", nodes, 3)
+		assert.ErrorContains(t, err, "not absolute")
+	})
+
+	t.Run("BadFanout", func(t *testing.T) {
+		err := Distribute(context.Background(), newFakeCopier(), "/images/test.sif", nodes, 0)
+		assert.ErrorContains(t, err, "invalid fanout")
+	})
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, shellQuote("/images/my image.sif"), `'/images/my image.sif'`)
+	assert.Equal(t, shellQuote("it's"), `'it'\''s'`)
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package distribute
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ExpandHostlist expands a comma separated list of hosts, in which a bracketed
+// list of numbers and ranges stands for a host per number, as in the node
+// lists of SLURM. For example, "node[01-03,07],gpu1" expands to node01,
+// node02, node03, node07 and gpu1. The width of zero padded numbers is
+// preserved.
+func ExpandHostlist(list string) ([]string, error) {
+	var hosts []string
+	for _, h := range splitHostlist(list) {
+		expanded, err := expandHost(h)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, expanded...)
+	}
+	return hosts, nil
+}
+
+// splitHostlist splits list on the commas which are not within brackets.
+func splitHostlist(list string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range list {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, list[start:])
+
+	hosts := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			hosts = append(hosts, p)
+		}
+	}
+	return hosts
+}
+
+// expandHost expands the first bracketed range of host, and recursively the
+// following ones.
+func expandHost(host string) ([]string, error) {
+	open := strings.IndexByte(host, '[')
+	if open < 0 {
+		if strings.ContainsRune(host, ']') {
+			return nil, fmt.Errorf("invalid host %q: unbalanced brackets", host)
+		}
+		return []string{host}, nil
+	}
+	end := strings.IndexByte(host[open:], ']')
+	if end < 0 {
+		return nil, fmt.Errorf("invalid host %q: unbalanced brackets", host)
+	}
+	end += open
+
+	prefix, ranges, suffix := host[:open], host[open+1:end], host[end+1:]
+	suffixes, err := expandHost(suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, r := range strings.Split(ranges, ",") {
+		first, last, isRange := strings.Cut(r, "-")
+		if !isRange {
+			last = first
+		}
+		lo, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host %q: bad number %q", host, first)
+		}
+		hi, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host %q: bad number %q", host, last)
+		}
+		if hi < lo {
+			return nil, fmt.Errorf("invalid host %q: bad range %q", host, r)
+		}
+		for n := lo; n <= hi; n++ {
+			num := fmt.Sprintf("%0*d", len(first), n)
+			for _, s := range suffixes {
+				hosts = append(hosts, prefix+num+s)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// ReadNodefile returns the hosts listed in the node file at path, with one
+// host per line, as written by PBS or for the hostfile of MPI launchers. Only
+// the first word of a line is used, so that slot counts are ignored, and
+// comments starting with '#' are skipped.
+func ReadNodefile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hosts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if fields := strings.Fields(line); len(fields) > 0 {
+			hosts = append(hosts, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("while reading %s: %w", path, err)
+	}
+	return hosts, nil
+}
+
+// JobNodes returns the nodes allocated to the job the command is running in,
+// as found in the environment set by the SLURM or PBS workload managers, or
+// nil if none is found.
+func JobNodes() ([]string, error) {
+	if list := os.Getenv("SLURM_JOB_NODELIST"); list != "" {
+		return ExpandHostlist(list)
+	}
+	if list := os.Getenv("SLURM_NODELIST"); list != "" {
+		return ExpandHostlist(list)
+	}
+	if path := os.Getenv("PBS_NODEFILE"); path != "" {
+		return ReadNodefile(path)
+	}
+	return nil, nil
+}
+
+// Peers returns nodes without duplicates, and without the local node, which
+// is named self. Host names are compared without their domain, so that
+// node1.example.com and node1 are the same node.
+func Peers(nodes []string, self string) []string {
+	seen := map[string]bool{
+		shortName(self): true,
+		"":              true,
+		"localhost":     true,
+		"127.0.0.1":     true,
+		"::1":           true,
+	}
+	var peers []string
+	for _, n := range nodes {
+		if seen[shortName(n)] {
+			continue
+		}
+		seen[shortName(n)] = true
+		peers = append(peers, n)
+	}
+	return peers
+}
+
+// shortName returns host without its domain, unless it is an IP address.
+func shortName(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	host, _, _ = strings.Cut(host, ".")
+	return host
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package distribute
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExpandHostlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "Plain",
+			list: "node1,node2",
+			want: []string{"node1", "node2"},
+		},
+		{
+			name: "Ranges",
+			list: "node[01-03,07],gpu1",
+			want: []string{"node01", "node02", "node03", "node07", "gpu1"},
+		},
+		{
+			name: "Suffix",
+			list: "rack[1-2]-n[1-2].example.com",
+			want: []string{
+				"rack1-n1.example.com", "rack1-n2.example.com",
+				"rack2-n1.example.com", "rack2-n2.example.com",
+			},
+		},
+		{
+			name: "Spaces",
+			list: " node1 , ,node2",
+			want: []string{"node1", "node2"},
+		},
+		{
+			name:    "Unbalanced",
+			list:    "node[1-2",
+			wantErr: true,
+		},
+		{
+			name:    "BadRange",
+			list:    "node[3-1]",
+			wantErr: true,
+		},
+		{
+			name:    "BadNumber",
+			list:    "node[a]",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := ExpandHostlist(tt.list)
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, hosts, tt.want)
+		})
+	}
+}
+
+func TestReadNodefile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodefile")
+	data := "# job nodes\nnode1\nnode1\nnode2 slots=4\n\n  node3\n"
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0o644))
+
+	hosts, err := ReadNodefile(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hosts, []string{"node1", "node1", "node2", "node3"})
+}
+
+func TestJobNodes(t *testing.T) {
+	t.Setenv("SLURM_JOB_NODELIST", "")
+	t.Setenv("SLURM_NODELIST", "")
+
+	path := filepath.Join(t.TempDir(), "nodefile")
+	assert.NilError(t, os.WriteFile(path, []byte("node1\nnode2\n"), 0o644))
+	t.Setenv("PBS_NODEFILE", path)
+	hosts, err := JobNodes()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hosts, []string{"node1", "node2"})
+
+	// SLURM takes precedence
+	t.Setenv("SLURM_JOB_NODELIST", "cn[1-2]")
+	hosts, err = JobNodes()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hosts, []string{"cn1", "cn2"})
+}
+
+func TestPeers(t *testing.T) {
+	nodes := []string{"node1", "node2.example.com", "node2", "localhost", "node3", "10.0.0.1", "10.0.0.2", "node1"}
+	peers := Peers(nodes, "node3.example.com")
+	assert.DeepEqual(t, peers, []string{"node1", "node2.example.com", "10.0.0.1", "10.0.0.2"})
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package advisory reads the advisory notices, such as an end-of-life date or
+// known CVEs, that registries and image authors attach to container images, and
+// applies the host policy for them.
+package advisory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/image/annotation"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+const (
+	// AnnotationEOL is the annotation, or label, holding the end-of-life date
+	// of an image, as YYYY-MM-DD or an RFC 3339 timestamp.
+	AnnotationEOL = "org.sylabs.advisory.eol"
+	// AnnotationCVEs is the annotation, or label, holding a comma separated
+	// list of CVEs known to affect an image.
+	AnnotationCVEs = "org.sylabs.advisory.cves"
+	// AnnotationNotice is the annotation, or label, holding a free-form
+	// advisory notice for an image.
+	AnnotationNotice = "org.sylabs.advisory.notice"
+)
+
+// Advisory policies, set with 'image advisory policy' in singularity.conf.
+const (
+	// PolicyIgnore ignores advisories.
+	PolicyIgnore = "ignore"
+	// PolicyWarn displays advisories as warnings.
+	PolicyWarn = "warn"
+	// PolicyBlock displays advisories as warnings, and refuses images that are
+	// past their end-of-life date, or are affected by known CVEs.
+	PolicyBlock = "block"
+)
+
+// ErrBlocked is returned by Check when an image is refused by PolicyBlock.
+var ErrBlocked = errors.New("image blocked by advisory policy")
+
+// Advisory holds the advisory notices attached to an image.
+type Advisory struct {
+	// EOL is the end-of-life date of the image, or the zero time if unset.
+	EOL time.Time
+	// CVEs lists CVEs known to affect the image.
+	CVEs []string
+	// Notice is a free-form advisory notice.
+	Notice string
+}
+
+// FromAnnotations returns the advisory held in annotations, or nil if there is
+// none. When an advisory key is present in more than one map, the first takes
+// precedence.
+func FromAnnotations(annotations ...map[string]string) (*Advisory, error) {
+	a := Advisory{Notice: annotation.Get(AnnotationNotice, annotations...)}
+
+	if eol := annotation.Get(AnnotationEOL, annotations...); eol != "" {
+		t, err := parseDate(eol)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", AnnotationEOL, eol, err)
+		}
+		a.EOL = t
+	}
+
+	for _, cve := range strings.Split(annotation.Get(AnnotationCVEs, annotations...), ",") {
+		if cve = strings.TrimSpace(cve); cve != "" {
+			a.CVEs = append(a.CVEs, cve)
+		}
+	}
+
+	if a.EOL.IsZero() && len(a.CVEs) == 0 && a.Notice == "" {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// FromImage returns the advisory attached to the image at path, or nil if there
+// is none. For OCI-SIF images, advisories are read from the image manifest
+// annotations, which may be added by a registry, and the image config labels.
+// For SIF images, they are read from the container labels.
+func FromImage(path string) (*Advisory, error) {
+	annotations, err := annotation.FromImage(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromAnnotations(annotations...)
+}
+
+// Expired returns true if a has an end-of-life date before now.
+func (a *Advisory) Expired(now time.Time) bool {
+	return !a.EOL.IsZero() && now.After(a.EOL)
+}
+
+// Check applies policy to the advisory a, of the image name. Advisories are
+// displayed as warnings, unless policy is PolicyIgnore. If policy is
+// PolicyBlock, and the image is past its end-of-life date, or is affected by
+// known CVEs, an error wrapping ErrBlocked is returned.
+func Check(a *Advisory, name, policy string) error {
+	if a == nil || policy == PolicyIgnore {
+		return nil
+	}
+
+	now := time.Now()
+	if !a.EOL.IsZero() {
+		if a.Expired(now) {
+			sylog.Warningf("Image %s reached end-of-life on %s", name, a.EOL.Format(time.DateOnly))
+		} else {
+			sylog.Infof("Image %s reaches end-of-life on %s", name, a.EOL.Format(time.DateOnly))
+		}
+	}
+	if len(a.CVEs) > 0 {
+		sylog.Warningf("Image %s is affected by: %s", name, strings.Join(a.CVEs, ", "))
+	}
+	if a.Notice != "" {
+		sylog.Warningf("Image %s advisory: %s", name, a.Notice)
+	}
+
+	if policy != PolicyBlock {
+		return nil
+	}
+	if a.Expired(now) {
+		return fmt.Errorf("%w: %s is past its end-of-life date", ErrBlocked, name)
+	}
+	if len(a.CVEs) > 0 {
+		return fmt.Errorf("%w: %s is affected by known CVEs", ErrBlocked, name)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package advisory
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+)
+
+func TestFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations []map[string]string
+		want        *Advisory
+		wantErr     bool
+	}{
+		{
+			name:        "None",
+			annotations: []map[string]string{{"other": "value"}},
+			want:        nil,
+		},
+		{
+			name: "All",
+			annotations: []map[string]string{{
+				AnnotationEOL:    "2023-01-02",
+				AnnotationCVEs:   "CVE-2023-0001, CVE-2023-0002,",
+				AnnotationNotice: "Use v2",
+			}},
+			want: &Advisory{
+				EOL:    time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+				CVEs:   []string{"CVE-2023-0001", "CVE-2023-0002"},
+				Notice: "Use v2",
+			},
+		},
+		{
+			name: "RFC3339",
+			annotations: []map[string]string{{
+				AnnotationEOL: "2023-01-02T03:04:05Z",
+			}},
+			want: &Advisory{
+				EOL: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+		},
+		{
+			name: "Precedence",
+			annotations: []map[string]string{
+				{AnnotationNotice: "registry"},
+				{AnnotationNotice: "label", AnnotationCVEs: "CVE-2023-0001"},
+			},
+			want: &Advisory{
+				CVEs:   []string{"CVE-2023-0001"},
+				Notice: "registry",
+			},
+		},
+		{
+			name: "InvalidEOL",
+			annotations: []map[string]string{{
+				AnnotationEOL: "soon",
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromAnnotations(tt.annotations...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	past := time.Now().Add(-24 * time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		advisory    *Advisory
+		policy      string
+		wantBlocked bool
+	}{
+		{
+			name:   "NoAdvisory",
+			policy: PolicyBlock,
+		},
+		{
+			name:     "ExpiredWarn",
+			advisory: &Advisory{EOL: past},
+			policy:   PolicyWarn,
+		},
+		{
+			name:     "ExpiredIgnore",
+			advisory: &Advisory{EOL: past},
+			policy:   PolicyIgnore,
+		},
+		{
+			name:        "ExpiredBlock",
+			advisory:    &Advisory{EOL: past},
+			policy:      PolicyBlock,
+			wantBlocked: true,
+		},
+		{
+			name:     "NotExpiredBlock",
+			advisory: &Advisory{EOL: future},
+			policy:   PolicyBlock,
+		},
+		{
+			name:        "CVEsBlock",
+			advisory:    &Advisory{CVEs: []string{"CVE-2023-0001"}},
+			policy:      PolicyBlock,
+			wantBlocked: true,
+		},
+		{
+			name:     "NoticeBlock",
+			advisory: &Advisory{Notice: "notice"},
+			policy:   PolicyBlock,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.advisory, "test", tt.policy)
+			if got := errors.Is(err, ErrBlocked); got != tt.wantBlocked {
+				t.Errorf("Check() error = %v, wantBlocked %v", err, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestFromImageOCISIF(t *testing.T) {
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.Config(img, ggcrv1.Config{
+		Labels: map[string]string{AnnotationNotice: "label notice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = mutate.Annotations(img, map[string]string{
+		AnnotationEOL: "2023-01-02",
+	}).(ggcrv1.Image)
+
+	path := filepath.Join(t.TempDir(), "image.oci.sif")
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+	if err := ocisif.Write(path, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FromImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Advisory{
+		EOL:    time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		Notice: "label notice",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromImage() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package annotation reads the annotations, and labels, that registries and
+// image authors attach to container images.
+package annotation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/metadata"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/inspect"
+)
+
+// FromImage returns the annotations attached to the image at path, in order of
+// precedence. For OCI-SIF images, these are the image manifest annotations,
+// which may be added by a registry, followed by the image config labels. For
+// SIF images and sandboxes, they are the container labels. Other images have
+// no annotations.
+func FromImage(path string) ([]map[string]string, error) {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return nil, err
+	}
+	imgType := img.Type
+	img.File.Close()
+
+	switch imgType {
+	case image.OCISIF:
+		mf, err := ocisif.ImageManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		spec, err := ocisif.ImageSpec(path)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]string{mf.Annotations, spec.Config.Labels}, nil
+	case image.SIF:
+		labels, err := sifLabels(path)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]string{labels}, nil
+	case image.SANDBOX:
+		labels, err := sandboxLabels(path)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]string{labels}, nil
+	}
+	return nil, nil
+}
+
+// Get returns the trimmed value of key in the first of annotations holding it,
+// or an empty string if none do.
+func Get(key string, annotations ...map[string]string) string {
+	for _, m := range annotations {
+		if v, ok := m[key]; ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// sifLabels returns the container labels held in the metadata of the SIF
+// image at path.
+func sifLabels(path string) (map[string]string, error) {
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer f.UnloadContainer()
+
+	d, err := metadata.Current(f)
+	if errors.Is(err, sif.ErrObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := d.GetData()
+	if err != nil {
+		return nil, err
+	}
+	var m inspect.Metadata
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("while decoding inspect metadata: %w", err)
+	}
+	return m.Attributes.Labels, nil
+}
+
+// sandboxLabels returns the container labels held in the labels.json file of
+// the sandbox image at path.
+func sandboxLabels(path string) (map[string]string, error) {
+	b, err := os.ReadFile(filepath.Join(path, ".singularity.d", "labels.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(b, &labels); err != nil {
+		return nil, fmt.Errorf("while decoding labels: %w", err)
+	}
+	return labels, nil
+}
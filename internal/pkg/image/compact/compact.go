@@ -0,0 +1,486 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package compact rewrites a SIF or OCI-SIF image without the data objects
+// that are no longer used, optionally re-compressing its squashfs partitions.
+//
+// The data objects that remain keep their IDs, groups, links and creation
+// times, and the image keeps its ID, so that signatures over them remain
+// valid. Data objects covered by a signature are never dropped or modified.
+package compact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/metadata"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/packer"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/unpacker"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// Options holds the options for Compact.
+type Options struct {
+	// Compression, if set, is the mksquashfs compression algorithm with which
+	// squashfs partitions are re-compressed.
+	Compression string
+	// Alignment, if set, is the alignment of partitions in the image, in
+	// bytes. Otherwise, the SIF default of 4096 bytes is used.
+	Alignment int
+	// TmpDir is the directory in which partitions are re-compressed.
+	TmpDir string
+}
+
+// Result describes the changes made by Compact.
+type Result struct {
+	// OldSize and NewSize are the sizes of the image, in bytes, before and
+	// after compaction.
+	OldSize int64
+	NewSize int64
+	// Dropped holds the IDs of the data objects removed from the image.
+	Dropped []uint32
+	// Recompressed holds the IDs of the re-compressed partitions.
+	Recompressed []uint32
+	// SkippedSigned holds the IDs of squashfs partitions not re-compressed
+	// because they are covered by a signature.
+	SkippedSigned []uint32
+}
+
+// extra holds the raw "extra" field of a descriptor, so that it can be copied
+// to a new descriptor unchanged.
+type extra []byte
+
+func (e *extra) UnmarshalBinary(b []byte) error {
+	*e = append((*e)[:0], b...)
+	return nil
+}
+
+func (e extra) MarshalBinary() ([]byte, error) {
+	return e, nil
+}
+
+// Compact rewrites the SIF or OCI-SIF image at path without:
+//
+//   - metadata data objects replaced by a later metadata edit,
+//   - signatures of data objects that no longer exist,
+//   - OCI blobs not referenced from the root index of an OCI-SIF image,
+//   - duplicates of a data object with the same type, name, metadata and
+//     content.
+//
+// Data objects covered by a signature, or linked to by another data object,
+// are kept. If opts.Compression is set, unsigned squashfs partitions are
+// re-compressed.
+func Compact(path string, opts Options) (*Result, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer f.UnloadContainer()
+
+	ds, err := f.GetDescriptors()
+	if err != nil {
+		return nil, fmt.Errorf("while reading descriptors: %w", err)
+	}
+
+	signed, _, err := metadata.SignedObjects(f)
+	if err != nil {
+		return nil, err
+	}
+
+	drop, err := unused(f, ds, signed)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp(opts.TmpDir, "sif-compact-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := fs.ForceRemoveAll(tmpDir); err != nil {
+			sylog.Warningf("Could not remove temporary directory %s: %v", tmpDir, err)
+		}
+	}()
+
+	r := &Result{OldSize: fi.Size()}
+
+	var maxID uint32
+	for _, d := range ds {
+		if d.ID() > maxID {
+			maxID = d.ID()
+		}
+	}
+	byID := make(map[uint32]sif.Descriptor, len(ds))
+	for _, d := range ds {
+		byID[d.ID()] = d
+	}
+
+	// The ID of a data object is determined by the position of its
+	// descriptor, so the descriptors of dropped data objects are replaced by
+	// empty placeholders, deleted once the image is created.
+	var dis []sif.DescriptorInput
+	var placeholders []uint32
+	var lastKept uint32
+	for id := uint32(1); id <= maxID; id++ {
+		if _, ok := byID[id]; ok && !drop[id] {
+			lastKept = id
+		}
+	}
+	for id := uint32(1); id <= lastKept; id++ {
+		d, ok := byID[id]
+		if !ok || drop[id] {
+			if ok {
+				r.Dropped = append(r.Dropped, id)
+			}
+			di, err := sif.NewDescriptorInput(sif.DataGeneric, bytes.NewReader(nil), sif.OptNoGroup())
+			if err != nil {
+				return nil, err
+			}
+			dis = append(dis, di)
+			placeholders = append(placeholders, id)
+			continue
+		}
+
+		var rd io.Reader = d.GetReader()
+		if isSquashfs(d) && opts.Compression != "" {
+			if signed[id] {
+				r.SkippedSigned = append(r.SkippedSigned, id)
+			} else {
+				sylog.Infof("Re-compressing partition %d with %s", id, opts.Compression)
+				sqfs, err := recompress(d, opts.Compression, tmpDir)
+				if err != nil {
+					return nil, fmt.Errorf("while re-compressing partition %d: %w", id, err)
+				}
+				defer sqfs.Close()
+				rd = sqfs
+				r.Recompressed = append(r.Recompressed, id)
+			}
+		}
+
+		di, err := DescriptorInput(d, rd, opts.Alignment)
+		if err != nil {
+			return nil, fmt.Errorf("while copying data object %d: %w", id, err)
+		}
+		dis = append(dis, di)
+	}
+	for id := lastKept + 1; id <= maxID; id++ {
+		if _, ok := byID[id]; ok {
+			r.Dropped = append(r.Dropped, id)
+		}
+	}
+
+	createOpts := []sif.CreateOpt{
+		sif.OptCreateWithID(f.ID()),
+		sif.OptCreateWithTime(f.CreatedAt()),
+		sif.OptCreateWithLaunchScript(f.LaunchScript()),
+		sif.OptCreateWithDescriptorCapacity(f.DescriptorsTotal()),
+		sif.OptCreateWithDescriptors(dis...),
+	}
+
+	dst, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return nil, err
+	}
+	dstPath := dst.Name()
+	defer os.Remove(dstPath)
+
+	nf, err := sif.CreateContainer(dst, createOpts...)
+	if err != nil {
+		dst.Close()
+		return nil, fmt.Errorf("while writing compacted image: %w", err)
+	}
+	for _, id := range placeholders {
+		if err := nf.DeleteObject(id); err != nil {
+			nf.UnloadContainer()
+			return nil, fmt.Errorf("while writing compacted image: %w", err)
+		}
+	}
+	if err := nf.UnloadContainer(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(dstPath, fi.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	nfi, err := os.Stat(dstPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(dstPath, path); err != nil {
+		return nil, fmt.Errorf("while replacing image: %w", err)
+	}
+	r.NewSize = nfi.Size()
+
+	return r, nil
+}
+
+// unused returns the IDs of the data objects in ds, from f, that are no longer
+// used. Data objects in signed are never returned.
+func unused(f *sif.FileImage, ds []sif.Descriptor, signed map[uint32]bool) (map[uint32]bool, error) {
+	drop := make(map[uint32]bool)
+
+	ids := make(map[uint32]bool, len(ds))
+	groups := make(map[uint32]bool)
+	linked := make(map[uint32]bool)
+	for _, d := range ds {
+		ids[d.ID()] = true
+		groups[d.GroupID()] = true
+	}
+	for _, d := range ds {
+		if id, isGroup := d.LinkedID(); id != 0 && !isGroup {
+			linked[id] = true
+		}
+	}
+	canDrop := func(d sif.Descriptor) bool {
+		return !signed[d.ID()] && !linked[d.ID()]
+	}
+
+	// Metadata replaced by a later edit.
+	if cur, err := metadata.Current(f); err == nil {
+		for _, d := range ds {
+			if d.DataType() == sif.DataGenericJSON && d.Name() == image.SIFDescInspectMetadataJSON &&
+				d.ID() != cur.ID() && canDrop(d) {
+				drop[d.ID()] = true
+			}
+		}
+	} else if !errors.Is(err, sif.ErrObjectNotFound) {
+		return nil, err
+	}
+
+	// Signatures of data objects that no longer exist.
+	for _, d := range ds {
+		if d.DataType() != sif.DataSignature {
+			continue
+		}
+		id, isGroup := d.LinkedID()
+		if (isGroup && !groups[id]) || (!isGroup && !ids[id]) {
+			drop[d.ID()] = true
+		}
+	}
+
+	// OCI blobs not referenced from the root index.
+	if _, err := f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex)); err == nil {
+		refs, err := ociReferences(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range ds {
+			if d.DataType() != sif.DataOCIBlob || !canDrop(d) {
+				continue
+			}
+			h, err := d.OCIBlobDigest()
+			if err != nil {
+				return nil, err
+			}
+			if !refs[h.String()] {
+				drop[d.ID()] = true
+			}
+		}
+	}
+
+	// Duplicate data objects, keeping the one with the lowest ID.
+	seen := make(map[string]bool)
+	for _, d := range ds {
+		if d.DataType() == sif.DataSignature || drop[d.ID()] {
+			continue
+		}
+		key, err := objectKey(d)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] && canDrop(d) {
+			drop[d.ID()] = true
+			continue
+		}
+		seen[key] = true
+	}
+
+	return drop, nil
+}
+
+// ociReferences returns the digests of the manifests, indexes, configs and
+// layers reachable from the root index of the OCI-SIF image f.
+func ociReferences(f *sif.FileImage) (map[string]bool, error) {
+	d, err := f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex))
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.GetData()
+	if err != nil {
+		return nil, fmt.Errorf("while reading root index: %w", err)
+	}
+
+	refs := make(map[string]bool)
+	var walk func(b []byte) error
+	walk = func(b []byte) error {
+		// An index holds manifests, and a manifest a config and layers, so
+		// decode both to collect the descriptors of either.
+		var content struct {
+			Manifests []imgspecv1.Descriptor `json:"manifests"`
+			Config    *imgspecv1.Descriptor  `json:"config"`
+			Layers    []imgspecv1.Descriptor `json:"layers"`
+		}
+		if err := json.Unmarshal(b, &content); err != nil {
+			return fmt.Errorf("while decoding OCI index or manifest: %w", err)
+		}
+		children := append([]imgspecv1.Descriptor{}, content.Layers...)
+		if content.Config != nil {
+			children = append(children, *content.Config)
+		}
+		for _, c := range content.Manifests {
+			if refs[c.Digest.String()] {
+				continue
+			}
+			refs[c.Digest.String()] = true
+			h, err := ggcrv1.NewHash(c.Digest.String())
+			if err != nil {
+				return err
+			}
+			mf, err := f.GetDescriptor(sif.WithOCIBlobDigest(h))
+			if err != nil {
+				return fmt.Errorf("while getting manifest %s: %w", c.Digest, err)
+			}
+			b, err := mf.GetData()
+			if err != nil {
+				return err
+			}
+			if err := walk(b); err != nil {
+				return err
+			}
+		}
+		for _, c := range children {
+			refs[c.Digest.String()] = true
+		}
+		return nil
+	}
+	if err := walk(b); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// objectKey returns a key identifying the type, name, metadata and content of
+// the data object described by d.
+func objectKey(d sif.Descriptor) (string, error) {
+	var e extra
+	if err := d.GetMetadata(&e); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, d.GetReader()); err != nil {
+		return "", fmt.Errorf("while reading data object %d: %w", d.ID(), err)
+	}
+	return fmt.Sprintf("%d/%s/%s/%s", d.DataType(), d.Name(), hex.EncodeToString(e), hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// isSquashfs returns true if d describes an unencrypted squashfs partition.
+func isSquashfs(d sif.Descriptor) bool {
+	if d.DataType() != sif.DataPartition {
+		return false
+	}
+	fsType, _, _, err := d.PartitionMetadata()
+	return err == nil && fsType == sif.FsSquash
+}
+
+// recompress extracts the squashfs partition described by d to tmpDir, and
+// packs it with compression algorithm comp, returning the new squashfs file.
+func recompress(d sif.Descriptor, comp, tmpDir string) (*os.File, error) {
+	rootfs, err := os.MkdirTemp(tmpDir, "rootfs-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := fs.ForceRemoveAll(rootfs); err != nil {
+			sylog.Warningf("Could not remove temporary directory %s: %v", rootfs, err)
+		}
+	}()
+
+	u := unpacker.NewSquashfs()
+	if !u.HasUnsquashfs() {
+		return nil, fmt.Errorf("could not extract squashfs, unsquashfs not found")
+	}
+	if err := u.ExtractAll(d.GetReader(), rootfs); err != nil {
+		return nil, err
+	}
+
+	dest := filepath.Join(tmpDir, fmt.Sprintf("partition-%d.sqfs", d.ID()))
+	flags := []string{"-noappend", "-comp", comp}
+	// ownership can't be preserved on extraction as a user
+	if os.Getuid() != 0 {
+		sylog.Warningf("Ownership of files in partition %d is reset to root, as compaction is not run as root", d.ID())
+		flags = append(flags, "-all-root")
+	}
+	if err := packer.NewSquashfs().Create([]string{rootfs}, dest, flags); err != nil {
+		return nil, err
+	}
+	return os.Open(dest)
+}
+
+// DescriptorInput returns a DescriptorInput for a copy of the data object
+// described by d, with content read from r. Partitions are aligned to
+// alignment bytes, if set.
+func DescriptorInput(d sif.Descriptor, r io.Reader, alignment int) (sif.DescriptorInput, error) {
+	opts := []sif.DescriptorInputOpt{
+		sif.OptObjectName(d.Name()),
+		sif.OptObjectTime(d.CreatedAt()),
+	}
+
+	if gid := d.GroupID(); gid == 0 {
+		opts = append(opts, sif.OptNoGroup())
+	} else {
+		opts = append(opts, sif.OptGroupID(gid))
+	}
+	if id, isGroup := d.LinkedID(); id != 0 {
+		if isGroup {
+			opts = append(opts, sif.OptLinkedGroupID(id))
+		} else {
+			opts = append(opts, sif.OptLinkedID(id))
+		}
+	}
+
+	// The architecture of a primary partition sets the architecture of the
+	// image, which is only done for partition metadata set with
+	// OptPartitionMetadata. Other metadata is copied unchanged.
+	copyExtra := true
+	if d.DataType() == sif.DataPartition {
+		fsType, pt, arch, err := d.PartitionMetadata()
+		if err != nil {
+			return sif.DescriptorInput{}, err
+		}
+		if arch != "" && arch != "unknown" {
+			opts = append(opts, sif.OptPartitionMetadata(fsType, pt, arch))
+			copyExtra = false
+		}
+		if alignment > 0 {
+			opts = append(opts, sif.OptObjectAlignment(alignment))
+		}
+	}
+	if copyExtra {
+		var e extra
+		if err := d.GetMetadata(&e); err != nil {
+			return sif.DescriptorInput{}, err
+		}
+		opts = append(opts, sif.OptMetadata(e))
+	}
+
+	return sif.NewDescriptorInput(d.DataType(), r, opts...)
+}
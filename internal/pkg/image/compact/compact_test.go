@@ -0,0 +1,219 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package compact
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/pkg/image"
+)
+
+type object struct {
+	dt   sif.DataType
+	data string
+	opts []sif.DescriptorInputOpt
+}
+
+func createSIF(t *testing.T, objects []object) string {
+	t.Helper()
+
+	var dis []sif.DescriptorInput
+	for _, o := range objects {
+		di, err := sif.NewDescriptorInput(o.dt, strings.NewReader(o.data), o.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dis = append(dis, di)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.sif")
+	f, err := sif.CreateContainerAtPath(path,
+		sif.OptCreateWithID("3fa802cc-358b-45e3-bcc0-69dc7a45f9f8"),
+		sif.OptCreateWithDescriptors(dis...),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func partition() object {
+	return object{
+		dt:   sif.DataPartition,
+		data: "rootfs",
+		opts: []sif.DescriptorInputOpt{sif.OptPartitionMetadata(sif.FsRaw, sif.PartPrimSys, "amd64")},
+	}
+}
+
+func metadataObject(data string) object {
+	return object{
+		dt:   sif.DataGenericJSON,
+		data: data,
+		opts: []sif.DescriptorInputOpt{sif.OptObjectName(image.SIFDescInspectMetadataJSON)},
+	}
+}
+
+func signature(opts ...sif.DescriptorInputOpt) object {
+	fp := make([]byte, 20)
+	return object{
+		dt:   sif.DataSignature,
+		data: "signature",
+		opts: append([]sif.DescriptorInputOpt{
+			sif.OptNoGroup(),
+			sif.OptSignatureMetadata(crypto.SHA256, fp),
+		}, opts...),
+	}
+}
+
+// contents returns the data of the data objects in the SIF at path, by ID.
+func contents(t *testing.T, path string) map[uint32]string {
+	t.Helper()
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	m := make(map[uint32]string)
+	f.WithDescriptors(func(d sif.Descriptor) bool {
+		b, err := d.GetData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[d.ID()] = string(b)
+		return false
+	})
+	return m
+}
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name        string
+		objects     []object
+		wantDropped []uint32
+		wantObjects map[uint32]string
+	}{
+		{
+			name: "Unused",
+			objects: []object{
+				partition(),
+				metadataObject(`{"old": true}`),
+				metadataObject(`{"new": true}`),
+				{dt: sif.DataGeneric, data: "dup"},
+				{dt: sif.DataGeneric, data: "dup"},
+				{dt: sif.DataGeneric, data: "unique"},
+				signature(sif.OptLinkedID(42)),
+			},
+			wantDropped: []uint32{2, 5, 7},
+			wantObjects: map[uint32]string{
+				1: "rootfs",
+				3: `{"new": true}`,
+				4: "dup",
+				6: "unique",
+			},
+		},
+		{
+			name: "Signed",
+			objects: []object{
+				partition(),
+				metadataObject(`{"old": true}`),
+				metadataObject(`{"new": true}`),
+				{dt: sif.DataGeneric, data: "dup"},
+				{dt: sif.DataGeneric, data: "dup"},
+				signature(sif.OptLinkedGroupID(sif.DefaultObjectGroup)),
+			},
+			wantDropped: nil,
+			wantObjects: map[uint32]string{
+				1: "rootfs",
+				2: `{"old": true}`,
+				3: `{"new": true}`,
+				4: "dup",
+				5: "dup",
+				6: "signature",
+			},
+		},
+		{
+			name: "Nothing",
+			objects: []object{
+				partition(),
+				metadataObject(`{}`),
+			},
+			wantDropped: nil,
+			wantObjects: map[uint32]string{
+				1: "rootfs",
+				2: `{}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := createSIF(t, tt.objects)
+
+			r, err := Compact(path, Options{TmpDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(r.Dropped, tt.wantDropped) {
+				t.Errorf("got dropped %v, want %v", r.Dropped, tt.wantDropped)
+			}
+			if got := contents(t, path); !reflect.DeepEqual(got, tt.wantObjects) {
+				t.Errorf("got objects %v, want %v", got, tt.wantObjects)
+			}
+			if len(tt.wantDropped) > 0 && r.NewSize >= r.OldSize {
+				t.Errorf("size not reduced: %d -> %d", r.OldSize, r.NewSize)
+			}
+
+			f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.UnloadContainer()
+			if got, want := f.ID(), "3fa802cc-358b-45e3-bcc0-69dc7a45f9f8"; got != want {
+				t.Errorf("got image ID %s, want %s", got, want)
+			}
+			if got, want := f.PrimaryArch(), "amd64"; got != want {
+				t.Errorf("got primary architecture %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCompactAlignment(t *testing.T) {
+	path := createSIF(t, []object{
+		{dt: sif.DataGeneric, data: "generic"},
+		partition(),
+	})
+
+	if _, err := Compact(path, Options{Alignment: 65536, TmpDir: t.TempDir()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	d, err := f.GetDescriptor(sif.WithID(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Offset()%65536 != 0 {
+		t.Errorf("partition offset %d not aligned to 65536", d.Offset())
+	}
+}
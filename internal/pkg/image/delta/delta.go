@@ -0,0 +1,386 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package delta creates and applies deltas between two versions of a SIF or
+// OCI-SIF image, so that an image can be updated on a host holding an earlier
+// version by transferring only the data objects that changed.
+//
+// A delta is itself a SIF file. It holds a descriptor for each data object of
+// the target image, with the same ID, but only the content of data objects
+// that are not found, with identical content, in the base image. The content
+// of the others is read from the base image when the delta is applied. As the
+// layers of an OCI-SIF image are held in separate data objects, only changed
+// layers are held in a delta between two OCI-SIF images.
+//
+// The image created by applying a delta keeps the ID, data object IDs, groups,
+// links and creation times of the target image, so that signatures over it
+// remain valid.
+package delta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/compact"
+)
+
+// ManifestName is the name of the data object holding the manifest of a delta.
+const ManifestName = "sif-delta.json"
+
+// Manifest describes how the target image is created from a delta, and the
+// base image it was created against.
+type Manifest struct {
+	// Base is the ID of the base image.
+	Base string `json:"base"`
+	// Target describes the header of the target image.
+	Target Header `json:"target"`
+	// Objects describes the data objects of the target image, by ID.
+	Objects []Object `json:"objects"`
+}
+
+// Header holds the fields of the header of the target image that are restored
+// when the delta is applied.
+type Header struct {
+	ID                 string `json:"id"`
+	CreatedAt          int64  `json:"createdAt"`
+	LaunchScript       string `json:"launchScript"`
+	DescriptorCapacity int64  `json:"descriptorCapacity"`
+}
+
+// Object describes a data object of the target image.
+type Object struct {
+	// ID is the ID of the data object, in the target image and the delta.
+	ID uint32 `json:"id"`
+	// Digest is the sha256 digest of the content of the data object.
+	Digest string `json:"digest"`
+	// BaseID, if set, is the ID of the data object of the base image from
+	// which the content is read. Otherwise, it is held in the delta.
+	BaseID uint32 `json:"baseID,omitempty"`
+}
+
+// Result describes a delta created by Create.
+type Result struct {
+	// Reused holds the IDs of the data objects of the target image whose
+	// content is read from the base image.
+	Reused []uint32
+	// Added holds the IDs of the data objects of the target image whose
+	// content is held in the delta.
+	Added []uint32
+	// TargetSize and DeltaSize are the sizes of the target image and of the
+	// delta, in bytes.
+	TargetSize int64
+	DeltaSize  int64
+}
+
+// Create writes to delta the delta between the SIF or OCI-SIF images at base
+// and target. Data objects of target with the same content as a data object of
+// base are not held in the delta.
+func Create(base, target, delta string) (*Result, error) {
+	if _, err := os.Stat(delta); err == nil {
+		return nil, fmt.Errorf("delta file already exists: %s", delta)
+	}
+
+	bf, err := sif.LoadContainerFromPath(base, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading base image: %w", err)
+	}
+	defer bf.UnloadContainer()
+
+	tfi, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := sif.LoadContainerFromPath(target, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading target image: %w", err)
+	}
+	defer tf.UnloadContainer()
+
+	bds, err := bf.GetDescriptors()
+	if err != nil {
+		return nil, fmt.Errorf("while reading base descriptors: %w", err)
+	}
+	inBase := make(map[string]uint32, len(bds))
+	for _, d := range bds {
+		if d.Size() == 0 {
+			continue
+		}
+		dgst, err := digest(d.GetReader())
+		if err != nil {
+			return nil, fmt.Errorf("while reading base data object %d: %w", d.ID(), err)
+		}
+		if _, ok := inBase[dgst]; !ok {
+			inBase[dgst] = d.ID()
+		}
+	}
+
+	tds, err := tf.GetDescriptors()
+	if err != nil {
+		return nil, fmt.Errorf("while reading target descriptors: %w", err)
+	}
+	var maxID uint32
+	byID := make(map[uint32]sif.Descriptor, len(tds))
+	for _, d := range tds {
+		byID[d.ID()] = d
+		if d.ID() > maxID {
+			maxID = d.ID()
+		}
+	}
+
+	m := Manifest{
+		Base: bf.ID(),
+		Target: Header{
+			ID:                 tf.ID(),
+			CreatedAt:          tf.CreatedAt().Unix(),
+			LaunchScript:       tf.LaunchScript(),
+			DescriptorCapacity: tf.DescriptorsTotal(),
+		},
+	}
+	r := &Result{TargetSize: tfi.Size()}
+
+	// The ID of a data object is determined by the position of its
+	// descriptor, so deleted data objects of the target image are replaced by
+	// empty placeholders, deleted once the delta is created.
+	var dis []sif.DescriptorInput
+	var placeholders []uint32
+	for id := uint32(1); id <= maxID; id++ {
+		d, ok := byID[id]
+		if !ok {
+			di, err := placeholder()
+			if err != nil {
+				return nil, err
+			}
+			dis = append(dis, di)
+			placeholders = append(placeholders, id)
+			continue
+		}
+
+		dgst, err := digest(d.GetReader())
+		if err != nil {
+			return nil, fmt.Errorf("while reading target data object %d: %w", id, err)
+		}
+		o := Object{ID: id, Digest: dgst}
+		var rd io.Reader = d.GetReader()
+		if baseID, ok := inBase[dgst]; ok && d.Size() > 0 {
+			o.BaseID = baseID
+			rd = bytes.NewReader(nil)
+			r.Reused = append(r.Reused, id)
+		} else {
+			r.Added = append(r.Added, id)
+		}
+
+		di, err := compact.DescriptorInput(d, rd, 0)
+		if err != nil {
+			return nil, fmt.Errorf("while copying data object %d: %w", id, err)
+		}
+		dis = append(dis, di)
+		m.Objects = append(m.Objects, o)
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	di, err := sif.NewDescriptorInput(sif.DataGenericJSON, bytes.NewReader(b),
+		sif.OptObjectName(ManifestName),
+		sif.OptNoGroup(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	dis = append(dis, di)
+
+	if err := write(delta, placeholders,
+		sif.OptCreateWithDescriptorCapacity(tf.DescriptorsTotal()+1),
+		sif.OptCreateWithDescriptors(dis...),
+	); err != nil {
+		return nil, fmt.Errorf("while writing delta: %w", err)
+	}
+
+	dfi, err := os.Stat(delta)
+	if err != nil {
+		return nil, err
+	}
+	r.DeltaSize = dfi.Size()
+
+	return r, nil
+}
+
+// Apply writes to dst the target image of the delta at delta, reading the
+// content of the data objects not held in the delta from the SIF or OCI-SIF
+// image at base. The content of each data object is checked against the
+// digest recorded in the delta.
+func Apply(base, delta, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("image file already exists: %s", dst)
+	}
+
+	df, err := sif.LoadContainerFromPath(delta, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return fmt.Errorf("while loading delta: %w", err)
+	}
+	defer df.UnloadContainer()
+
+	m, err := readManifest(df)
+	if err != nil {
+		return err
+	}
+
+	bfi, err := os.Stat(base)
+	if err != nil {
+		return err
+	}
+	bf, err := sif.LoadContainerFromPath(base, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return fmt.Errorf("while loading base image: %w", err)
+	}
+	defer bf.UnloadContainer()
+
+	if bf.ID() != m.Base {
+		return fmt.Errorf("delta was created against image %s, not %s", m.Base, bf.ID())
+	}
+
+	sort.Slice(m.Objects, func(i, j int) bool { return m.Objects[i].ID < m.Objects[j].ID })
+	var maxID uint32
+	byID := make(map[uint32]Object, len(m.Objects))
+	for _, o := range m.Objects {
+		byID[o.ID] = o
+		maxID = o.ID
+	}
+
+	var dis []sif.DescriptorInput
+	var placeholders []uint32
+	for id := uint32(1); id <= maxID; id++ {
+		o, ok := byID[id]
+		if !ok {
+			di, err := placeholder()
+			if err != nil {
+				return err
+			}
+			dis = append(dis, di)
+			placeholders = append(placeholders, id)
+			continue
+		}
+
+		d, err := df.GetDescriptor(sif.WithID(id))
+		if err != nil {
+			return fmt.Errorf("while getting delta data object %d: %w", id, err)
+		}
+		rd := d.GetReader()
+		if o.BaseID != 0 {
+			bd, err := bf.GetDescriptor(sif.WithID(o.BaseID))
+			if err != nil {
+				return fmt.Errorf("while getting base data object %d: %w", o.BaseID, err)
+			}
+			rd = bd.GetReader()
+		}
+
+		di, err := compact.DescriptorInput(d, &verifyReader{r: rd, h: sha256.New(), o: o}, 0)
+		if err != nil {
+			return fmt.Errorf("while copying data object %d: %w", id, err)
+		}
+		dis = append(dis, di)
+	}
+
+	if err := write(dst, placeholders,
+		sif.OptCreateWithID(m.Target.ID),
+		sif.OptCreateWithTime(time.Unix(m.Target.CreatedAt, 0)),
+		sif.OptCreateWithLaunchScript(m.Target.LaunchScript),
+		sif.OptCreateWithDescriptorCapacity(m.Target.DescriptorCapacity),
+		sif.OptCreateWithDescriptors(dis...),
+	); err != nil {
+		return fmt.Errorf("while writing image: %w", err)
+	}
+	return os.Chmod(dst, bfi.Mode().Perm())
+}
+
+// readManifest returns the manifest of the delta f.
+func readManifest(f *sif.FileImage) (*Manifest, error) {
+	d, err := f.GetDescriptor(
+		sif.WithDataType(sif.DataGenericJSON),
+		func(d sif.Descriptor) (bool, error) { return d.Name() == ManifestName, nil },
+	)
+	if err != nil {
+		return nil, fmt.Errorf("not a SIF delta: %w", err)
+	}
+	b, err := d.GetData()
+	if err != nil {
+		return nil, fmt.Errorf("while reading delta manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("while decoding delta manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// write creates the SIF at path with opts, and deletes the placeholder data
+// objects with the IDs in placeholders. The file is removed on error.
+func write(path string, placeholders []uint32, opts ...sif.CreateOpt) (err error) {
+	f, err := sif.CreateContainerAtPath(path, opts...)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	defer func() {
+		if uerr := f.UnloadContainer(); err == nil {
+			err = uerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	for _, id := range placeholders {
+		if err := f.DeleteObject(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholder returns a DescriptorInput for an empty data object, which holds
+// the ID of a deleted data object.
+func placeholder() (sif.DescriptorInput, error) {
+	return sif.NewDescriptorInput(sif.DataGeneric, bytes.NewReader(nil), sif.OptNoGroup())
+}
+
+// digest returns the hex encoded sha256 digest of the content read from r.
+func digest(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyReader reads the content of the data object o from r, returning an
+// error at the end of the content if it doesn't match the digest of o.
+type verifyReader struct {
+	r io.Reader
+	h hash.Hash
+	o Object
+}
+
+func (v *verifyReader) Read(b []byte) (int, error) {
+	n, err := v.r.Read(b)
+	v.h.Write(b[:n])
+	if err == io.EOF && hex.EncodeToString(v.h.Sum(nil)) != v.o.Digest {
+		if v.o.BaseID != 0 {
+			return n, fmt.Errorf("content of base data object %d does not match delta", v.o.BaseID)
+		}
+		return n, fmt.Errorf("content of data object %d does not match delta", v.o.ID)
+	}
+	return n, err
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package delta
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+)
+
+type object struct {
+	dt   sif.DataType
+	data string
+	opts []sif.DescriptorInputOpt
+}
+
+func createSIF(t *testing.T, id string, objects []object, deleted ...uint32) string {
+	t.Helper()
+
+	var dis []sif.DescriptorInput
+	for _, o := range objects {
+		di, err := sif.NewDescriptorInput(o.dt, strings.NewReader(o.data), o.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dis = append(dis, di)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.sif")
+	f, err := sif.CreateContainerAtPath(path,
+		sif.OptCreateWithID(id),
+		sif.OptCreateWithLaunchScript("#!/usr/bin/env run-singularity\n"),
+		sif.OptCreateWithDescriptors(dis...),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range deleted {
+		if err := f.DeleteObject(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func partition(data string) object {
+	return object{
+		dt:   sif.DataPartition,
+		data: data,
+		opts: []sif.DescriptorInputOpt{sif.OptPartitionMetadata(sif.FsRaw, sif.PartPrimSys, "amd64")},
+	}
+}
+
+// contents returns the data of the data objects in the SIF at path, by ID.
+func contents(t *testing.T, path string) map[uint32]string {
+	t.Helper()
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	m := make(map[uint32]string)
+	f.WithDescriptors(func(d sif.Descriptor) bool {
+		b, err := d.GetData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[d.ID()] = string(b)
+		return false
+	})
+	return m
+}
+
+const (
+	baseID   = "3fa802cc-358b-45e3-bcc0-69dc7a45f9f8"
+	targetID = "6ea4c6b4-2a5d-4c0b-8b0e-4d1b5a3e9f10"
+)
+
+func TestCreateApply(t *testing.T) {
+	base := createSIF(t, baseID, []object{
+		partition("rootfs v1"),
+		{dt: sif.DataGeneric, data: "layer 1"},
+		{dt: sif.DataGeneric, data: "layer 2"},
+	})
+	target := createSIF(t, targetID, []object{
+		{dt: sif.DataGeneric, data: "layer 2"},
+		{dt: sif.DataGeneric, data: "deleted"},
+		partition("rootfs v2"),
+		{dt: sif.DataGeneric, data: "layer 1"},
+		{dt: sif.DataGeneric, data: "layer 3", opts: []sif.DescriptorInputOpt{sif.OptObjectName("new")}},
+	}, 2)
+
+	delta := filepath.Join(t.TempDir(), "image.delta")
+	r, err := Create(base, target, delta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []uint32{1, 4}; !reflect.DeepEqual(r.Reused, want) {
+		t.Errorf("got reused %v, want %v", r.Reused, want)
+	}
+	if want := []uint32{3, 5}; !reflect.DeepEqual(r.Added, want) {
+		t.Errorf("got added %v, want %v", r.Added, want)
+	}
+
+	dst := filepath.Join(t.TempDir(), "image.sif")
+	if err := Apply(base, delta, dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := contents(t, dst), contents(t, target); !reflect.DeepEqual(got, want) {
+		t.Errorf("got objects %v, want %v", got, want)
+	}
+
+	f, err := sif.LoadContainerFromPath(dst, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+	tf, err := sif.LoadContainerFromPath(target, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.UnloadContainer()
+
+	if f.ID() != tf.ID() || !f.CreatedAt().Equal(tf.CreatedAt()) || f.LaunchScript() != tf.LaunchScript() {
+		t.Errorf("got header %s %v %q, want %s %v %q",
+			f.ID(), f.CreatedAt(), f.LaunchScript(), tf.ID(), tf.CreatedAt(), tf.LaunchScript())
+	}
+	if got, want := f.PrimaryArch(), "amd64"; got != want {
+		t.Errorf("got primary architecture %s, want %s", got, want)
+	}
+	d, err := f.GetDescriptor(sif.WithID(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.Name(), "new"; got != want {
+		t.Errorf("got name %q, want %q", got, want)
+	}
+}
+
+func TestApplyWrongBase(t *testing.T) {
+	base := createSIF(t, baseID, []object{{dt: sif.DataGeneric, data: "layer 1"}})
+	target := createSIF(t, targetID, []object{{dt: sif.DataGeneric, data: "layer 1"}})
+	delta := filepath.Join(t.TempDir(), "image.delta")
+	if _, err := Create(base, target, delta); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		base    string
+		delta   string
+		wantErr string
+	}{
+		{
+			name:    "OtherImage",
+			base:    createSIF(t, targetID, []object{{dt: sif.DataGeneric, data: "layer 1"}}),
+			delta:   delta,
+			wantErr: "delta was created against image",
+		},
+		{
+			name:    "ModifiedBase",
+			base:    createSIF(t, baseID, []object{{dt: sif.DataGeneric, data: "layer X"}}),
+			delta:   delta,
+			wantErr: "content of base data object 1 does not match delta",
+		},
+		{
+			name:    "NotDelta",
+			base:    base,
+			delta:   target,
+			wantErr: "not a SIF delta",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "image.sif")
+			err := Apply(tt.base, tt.delta, dst)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(dst); err == nil {
+				t.Errorf("%s was written", dst)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package diff compares the root filesystems and OCI image configs of two
+// container images.
+package diff
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ChangeKind is the kind of a change to a file between two images.
+type ChangeKind string
+
+const (
+	// ChangeAdd is a file only present in the second image.
+	ChangeAdd ChangeKind = "A"
+	// ChangeModify is a file present in both images, which differs.
+	ChangeModify ChangeKind = "M"
+	// ChangeDelete is a file only present in the first image.
+	ChangeDelete ChangeKind = "D"
+)
+
+// Change is a difference of a file between two images.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Path is the absolute path of the file in the container.
+	Path string `json:"path"`
+	// Reasons lists the attributes that differ for a ChangeModify, among
+	// type, mode, owner, target and content.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// ConfigChange is a difference of a field of the OCI image config between
+// two images. Old or New is empty if the field is only set in one image.
+type ConfigChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// Trees returns the differences between the root filesystems at a and b,
+// ordered by path. Regular files of equal size are compared by content. File
+// owners are only compared if compareOwners is true, as they are not
+// preserved when an image is extracted by an unprivileged user.
+func Trees(a, b string, compareOwners bool) ([]Change, error) {
+	aFiles, err := walk(a)
+	if err != nil {
+		return nil, err
+	}
+	bFiles, err := walk(b)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	for p, afi := range aFiles {
+		bfi, ok := bFiles[p]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeDelete, Path: p})
+			continue
+		}
+		reasons, err := compare(filepath.Join(a, p), afi, filepath.Join(b, p), bfi, compareOwners)
+		if err != nil {
+			return nil, err
+		}
+		if len(reasons) > 0 {
+			changes = append(changes, Change{Kind: ChangeModify, Path: p, Reasons: reasons})
+		}
+	}
+	for p := range bFiles {
+		if _, ok := aFiles[p]; !ok {
+			changes = append(changes, Change{Kind: ChangeAdd, Path: p})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// walk returns the file info of every file under root, keyed by its absolute
+// path in the container.
+func walk(root string) (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files["/"+rel] = fi
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while walking %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// compare returns the attributes that differ between the file at aPath,
+// described by afi, and the file at bPath, described by bfi.
+func compare(aPath string, afi fs.FileInfo, bPath string, bfi fs.FileInfo, compareOwners bool) ([]string, error) {
+	if afi.Mode().Type() != bfi.Mode().Type() {
+		return []string{"type"}, nil
+	}
+
+	var reasons []string
+	if afi.Mode() != bfi.Mode() {
+		reasons = append(reasons, "mode")
+	}
+	if compareOwners {
+		ast, aok := afi.Sys().(*syscall.Stat_t)
+		bst, bok := bfi.Sys().(*syscall.Stat_t)
+		if aok && bok && (ast.Uid != bst.Uid || ast.Gid != bst.Gid) {
+			reasons = append(reasons, "owner")
+		}
+	}
+
+	switch {
+	case afi.Mode()&fs.ModeSymlink != 0:
+		aTarget, err := os.Readlink(aPath)
+		if err != nil {
+			return nil, err
+		}
+		bTarget, err := os.Readlink(bPath)
+		if err != nil {
+			return nil, err
+		}
+		if aTarget != bTarget {
+			reasons = append(reasons, "target")
+		}
+	case afi.Mode().IsRegular():
+		same := afi.Size() == bfi.Size()
+		if same {
+			var err error
+			if same, err = sameContent(aPath, bPath); err != nil {
+				return nil, err
+			}
+		}
+		if !same {
+			reasons = append(reasons, "content")
+		}
+	}
+	return reasons, nil
+}
+
+// sameContent returns true if the regular files at a and b have the same
+// content.
+func sameContent(a, b string) (bool, error) {
+	aSum, err := fileSum(a)
+	if err != nil {
+		return false, err
+	}
+	bSum, err := fileSum(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aSum, bSum), nil
+}
+
+func fileSum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrPermission) {
+		return nil, fmt.Errorf("cannot read %s to compare its content: %w", path, err)
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("while reading %s: %w", path, err)
+	}
+	return h.Sum(nil), nil
+}
+
+// Configs returns the differences between the OCI image configs a and b.
+// Environment variables, labels, exposed ports and volumes are compared
+// individually.
+func Configs(a, b *imgspecv1.Image) []ConfigChange {
+	changes := []ConfigChange{}
+	str := func(field, oldVal, newVal string) {
+		if oldVal != newVal {
+			changes = append(changes, ConfigChange{Field: field, Old: oldVal, New: newVal})
+		}
+	}
+	strs := func(field string, oldVal, newVal []string) {
+		str(field, strings.Join(oldVal, " "), strings.Join(newVal, " "))
+	}
+	maps := func(field string, oldVal, newVal map[string]string) {
+		keys := make(map[string]struct{})
+		for k := range oldVal {
+			keys[k] = struct{}{}
+		}
+		for k := range newVal {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			str(field+"["+k+"]", oldVal[k], newVal[k])
+		}
+	}
+
+	str("Architecture", a.Architecture, b.Architecture)
+	str("OS", a.OS, b.OS)
+	str("Variant", a.Variant, b.Variant)
+	strs("Entrypoint", a.Config.Entrypoint, b.Config.Entrypoint)
+	strs("Cmd", a.Config.Cmd, b.Config.Cmd)
+	maps("Env", envMap(a.Config.Env), envMap(b.Config.Env))
+	str("WorkingDir", a.Config.WorkingDir, b.Config.WorkingDir)
+	str("User", a.Config.User, b.Config.User)
+	maps("Labels", a.Config.Labels, b.Config.Labels)
+	maps("ExposedPorts", setMap(a.Config.ExposedPorts), setMap(b.Config.ExposedPorts))
+	maps("Volumes", setMap(a.Config.Volumes), setMap(b.Config.Volumes))
+	str("StopSignal", a.Config.StopSignal, b.Config.StopSignal)
+	return changes
+}
+
+// envMap returns the KEY=VALUE entries of env as a map.
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		m[k] = v
+	}
+	return m
+}
+
+// setMap returns the keys of s as a map, with a value marking presence.
+func setMap(s map[string]struct{}) map[string]string {
+	m := make(map[string]string, len(s))
+	for k := range s {
+		m[k] = "present"
+	}
+	return m
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string, links map[string]string) {
+	t.Helper()
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, target := range links {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, full); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrees(t *testing.T) {
+	a := t.TempDir()
+	b := t.TempDir()
+
+	writeTree(t, a, map[string]string{
+		"etc/same":     "same",
+		"etc/content":  "abc",
+		"etc/size":     "short",
+		"etc/mode":     "mode",
+		"etc/deleted":  "gone",
+		"opt/old/file": "old",
+		"typechange":   "file",
+	}, map[string]string{
+		"bin/sh": "/usr/bin/bash",
+	})
+	writeTree(t, b, map[string]string{
+		"etc/same":     "same",
+		"etc/content":  "abd",
+		"etc/size":     "much longer",
+		"etc/mode":     "mode",
+		"etc/added":    "new",
+		"typechange/x": "dir",
+	}, map[string]string{
+		"bin/sh": "/usr/bin/dash",
+	})
+	if err := os.Chmod(filepath.Join(b, "etc/mode"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Trees(a, b, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Change{
+		{Kind: ChangeModify, Path: "/bin/sh", Reasons: []string{"target"}},
+		{Kind: ChangeAdd, Path: "/etc/added"},
+		{Kind: ChangeModify, Path: "/etc/content", Reasons: []string{"content"}},
+		{Kind: ChangeDelete, Path: "/etc/deleted"},
+		{Kind: ChangeModify, Path: "/etc/mode", Reasons: []string{"mode"}},
+		{Kind: ChangeModify, Path: "/etc/size", Reasons: []string{"content"}},
+		{Kind: ChangeDelete, Path: "/opt"},
+		{Kind: ChangeDelete, Path: "/opt/old"},
+		{Kind: ChangeDelete, Path: "/opt/old/file"},
+		{Kind: ChangeModify, Path: "/typechange", Reasons: []string{"type"}},
+		{Kind: ChangeAdd, Path: "/typechange/x"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+}
+
+func TestTreesIdentical(t *testing.T) {
+	a := t.TempDir()
+	b := t.TempDir()
+	files := map[string]string{"etc/hostname": "host", "usr/bin/tool": "binary"}
+	writeTree(t, a, files, nil)
+	writeTree(t, b, files, nil)
+
+	changes, err := Trees(a, b, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+}
+
+func TestTreesOwner(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	a := t.TempDir()
+	b := t.TempDir()
+	files := map[string]string{"etc/hostname": "host"}
+	writeTree(t, a, files, nil)
+	writeTree(t, b, files, nil)
+	if err := os.Lchown(filepath.Join(b, "etc/hostname"), 1000, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Trees(a, b, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("unexpected changes without owner comparison: %v", changes)
+	}
+
+	changes, err = Trees(a, b, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Change{{Kind: ChangeModify, Path: "/etc/hostname", Reasons: []string{"owner"}}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+}
+
+func TestTreesUnreadable(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	a := t.TempDir()
+	b := t.TempDir()
+	files := map[string]string{"etc/shadow": "secret"}
+	writeTree(t, a, files, nil)
+	writeTree(t, b, files, nil)
+	if err := os.Chmod(filepath.Join(a, "etc/shadow"), 0o000); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(b, "etc/shadow"), 0o000); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Trees(a, b, false); err == nil {
+		t.Errorf("unexpected success comparing unreadable files")
+	}
+}
+
+func TestConfigs(t *testing.T) {
+	a := &imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: "amd64", OS: "linux"},
+		Config: imgspecv1.ImageConfig{
+			Entrypoint: []string{"/bin/sh", "-c"},
+			Cmd:        []string{"run"},
+			Env:        []string{"PATH=/bin", "OLD=1", "SAME=x"},
+			Labels:     map[string]string{"version": "1"},
+			Volumes:    map[string]struct{}{"/data": {}},
+		},
+	}
+	b := &imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: "arm64", OS: "linux"},
+		Config: imgspecv1.ImageConfig{
+			Entrypoint: []string{"/bin/sh", "-c"},
+			Cmd:        []string{"serve"},
+			Env:        []string{"PATH=/usr/bin:/bin", "SAME=x", "NEW=2"},
+			Labels:     map[string]string{"version": "2", "vendor": "me"},
+			WorkingDir: "/work",
+		},
+	}
+
+	want := []ConfigChange{
+		{Field: "Architecture", Old: "amd64", New: "arm64"},
+		{Field: "Cmd", Old: "run", New: "serve"},
+		{Field: "Env[NEW]", New: "2"},
+		{Field: "Env[OLD]", Old: "1"},
+		{Field: "Env[PATH]", Old: "/bin", New: "/usr/bin:/bin"},
+		{Field: "WorkingDir", New: "/work"},
+		{Field: "Labels[vendor]", New: "me"},
+		{Field: "Labels[version]", Old: "1", New: "2"},
+		{Field: "Volumes[/data]", Old: "present"},
+	}
+	if got := Configs(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v, want %v", got, want)
+	}
+
+	if got := Configs(a, a); len(got) != 0 {
+		t.Errorf("unexpected changes comparing config with itself: %v", got)
+	}
+}
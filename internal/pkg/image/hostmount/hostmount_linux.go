@@ -0,0 +1,289 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package hostmount mounts the root filesystem of a container image read-only
+// at a directory on the host, using FUSE, without starting a container. The
+// mounts made are recorded in a registry in the user's singularity
+// configuration directory, so that they can be listed and unmounted later.
+package hostmount
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/syfs"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/fs/lock"
+	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
+)
+
+const (
+	registryFile = "mounts.json"
+	lockFile     = "mounts.lock"
+)
+
+// mountInfoPath is the mountinfo file used to check that recorded mounts are
+// still present.
+var mountInfoPath = "/proc/self/mountinfo"
+
+// ErrNotMounted is returned by Unmount when no mount is recorded at a
+// directory.
+var ErrNotMounted = errors.New("no image mounted by singularity at this directory")
+
+// Record describes an image mounted on the host.
+type Record struct {
+	// Image is the absolute path of the mounted image.
+	Image string `json:"image"`
+	// MountPoint is the absolute path of the directory where the root
+	// filesystem of the image is mounted.
+	MountPoint string `json:"mountPoint"`
+	// FSType is the type of the mounted filesystem, squashfs or ext3.
+	FSType string `json:"fsType"`
+	// Created is the time at which the image was mounted.
+	Created time.Time `json:"created"`
+}
+
+// Registry records the images mounted on the host by a user.
+type Registry struct {
+	dir string
+}
+
+// NewRegistry returns a Registry held in dir.
+func NewRegistry(dir string) *Registry {
+	return &Registry{dir: dir}
+}
+
+// DefaultRegistry returns the Registry held in the singularity configuration
+// directory of the current user.
+func DefaultRegistry() *Registry {
+	return NewRegistry(syfs.ConfigDir())
+}
+
+// update calls fn with the records of r, under an exclusive lock, and writes
+// the records returned by fn.
+func (r *Registry) update(fn func([]Record) ([]Record, error)) error {
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return err
+	}
+	lockPath := filepath.Join(r.dir, lockFile)
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("while creating lock file: %w", err)
+	}
+	f.Close()
+	fd, err := lock.Exclusive(lockPath)
+	if err != nil {
+		return fmt.Errorf("while locking mount registry: %w", err)
+	}
+	defer lock.Release(fd)
+
+	records, err := r.read()
+	if err != nil {
+		return err
+	}
+	if records, err = fn(records); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(r.dir, "."+registryFile)
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("while writing mount registry: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(r.dir, registryFile))
+}
+
+func (r *Registry) read() ([]Record, error) {
+	b, err := os.ReadFile(filepath.Join(r.dir, registryFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Record{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("while reading mount registry: %w", err)
+	}
+	records := []Record{}
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("while decoding mount registry: %w", err)
+	}
+	return records, nil
+}
+
+// List returns the images mounted on the host, ordered by mount point.
+// Records of mounts that are no longer present, for example after a reboot or
+// an unmount with fusermount, are removed from the registry.
+func (r *Registry) List() ([]Record, error) {
+	current := []Record{}
+	err := r.update(func(records []Record) ([]Record, error) {
+		entries, err := proc.GetMountInfoEntry(mountInfoPath)
+		if err != nil {
+			return nil, err
+		}
+		mounted := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			mounted[e.Point] = true
+		}
+		for _, rec := range records {
+			if mounted[rec.MountPoint] {
+				current = append(current, rec)
+			} else {
+				sylog.Debugf("Removing stale mount record for %s", rec.MountPoint)
+			}
+		}
+		return current, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].MountPoint < current[j].MountPoint
+	})
+	return current, nil
+}
+
+// add records rec in r. An existing record for the same mount point is
+// replaced.
+func (r *Registry) add(rec Record) error {
+	return r.update(func(records []Record) ([]Record, error) {
+		kept := records[:0]
+		for _, e := range records {
+			if e.MountPoint != rec.MountPoint {
+				kept = append(kept, e)
+			}
+		}
+		return append(kept, rec), nil
+	})
+}
+
+// remove removes the record for mountPoint from r, calling fn with it first.
+// If fn returns an error, the record is kept.
+func (r *Registry) remove(mountPoint string, fn func(Record) error) error {
+	return r.update(func(records []Record) ([]Record, error) {
+		for i, e := range records {
+			if e.MountPoint != mountPoint {
+				continue
+			}
+			if err := fn(e); err != nil {
+				return nil, err
+			}
+			return append(records[:i], records[i+1:]...), nil
+		}
+		return nil, ErrNotMounted
+	})
+}
+
+// Mount mounts the root filesystem of the SIF, OCI-SIF, squashfs or EXT3
+// image at imagePath read-only at the existing directory dir, and records the
+// mount in r.
+func (r *Registry) Mount(ctx context.Context, imagePath, dir string) (*Record, error) {
+	imagePath, err := filepath.Abs(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("mount point %s is not a directory", dir)
+	}
+
+	im, err := RootFSMount(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	im.UID = os.Getuid()
+	im.GID = os.Getgid()
+	im.Readonly = true
+	im.SetMountPoint(dir)
+
+	if err := im.Mount(ctx); err != nil {
+		return nil, err
+	}
+
+	rec := Record{
+		Image:      imagePath,
+		MountPoint: dir,
+		FSType:     fsTypeName(im.Type),
+		Created:    time.Now(),
+	}
+	if err := r.add(rec); err != nil {
+		if uerr := im.Unmount(ctx); uerr != nil {
+			sylog.Warningf("While unmounting %s: %v", dir, uerr)
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Unmount unmounts the image mounted at dir by Mount, and removes its record
+// from r. If no mount is recorded at dir, ErrNotMounted is returned.
+func (r *Registry) Unmount(ctx context.Context, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	return r.remove(dir, func(rec Record) error {
+		return fuse.UnmountWithFuse(ctx, rec.MountPoint)
+	})
+}
+
+// RootFSMount returns a FUSE ImageMount for the root filesystem of the image
+// at path, whose mount point and options are left for the caller to set.
+func RootFSMount(path string) (*fuse.ImageMount, error) {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("while opening image %s: %w", path, err)
+	}
+	defer img.File.Close()
+
+	im := &fuse.ImageMount{Type: img.Type, SourcePath: path}
+
+	switch img.Type {
+	case image.SQUASHFS, image.EXT3:
+	case image.SIF:
+		part, err := img.GetRootFsPartition()
+		if err != nil {
+			return nil, fmt.Errorf("while getting root filesystem of %s: %w", path, err)
+		}
+		if part.Type != image.SQUASHFS && part.Type != image.EXT3 {
+			return nil, fmt.Errorf("root filesystem of %s is not a squashfs or EXT3 partition, encrypted images can't be mounted", path)
+		}
+		im.Type = int(part.Type)
+		im.ExtraOpts = []string{fmt.Sprintf("offset=%d", part.Offset)}
+	case image.OCISIF:
+		offset, err := ocisif.SquashfsLayerOffset(path)
+		if err != nil {
+			return nil, err
+		}
+		im.Type = image.SQUASHFS
+		im.ExtraOpts = []string{fmt.Sprintf("offset=%d", offset)}
+	default:
+		return nil, fmt.Errorf("%s is not a SIF, OCI-SIF, squashfs or EXT3 image", path)
+	}
+	return im, nil
+}
+
+func fsTypeName(t int) string {
+	switch t {
+	case image.SQUASHFS:
+		return "squashfs"
+	case image.EXT3:
+		return "ext3"
+	}
+	return "unknown"
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package hostmount
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const mountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+40 22 0:36 / /mnt/a ro,nosuid,nodev,relatime shared:20 - fuse.squashfuse squashfuse ro,user_id=1000,group_id=1000
+41 22 0:37 / /mnt/b ro,nosuid,nodev,relatime shared:21 - fuse.squashfuse squashfuse ro,user_id=1000,group_id=1000
+`
+
+func setMountInfo(t *testing.T, content string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	orig := mountInfoPath
+	mountInfoPath = path
+	t.Cleanup(func() { mountInfoPath = orig })
+}
+
+func mountPoints(records []Record) []string {
+	var points []string
+	for _, r := range records {
+		points = append(points, r.MountPoint)
+	}
+	return points
+}
+
+func TestRegistry(t *testing.T) {
+	setMountInfo(t, mountInfo)
+	r := NewRegistry(filepath.Join(t.TempDir(), "config"))
+
+	records, err := r.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("got %d records from empty registry", len(records))
+	}
+
+	for _, rec := range []Record{
+		{Image: "/images/b.sif", MountPoint: "/mnt/b", FSType: "squashfs"},
+		{Image: "/images/a.sif", MountPoint: "/mnt/a", FSType: "squashfs"},
+		{Image: "/images/stale.sif", MountPoint: "/mnt/stale", FSType: "squashfs"},
+		{Image: "/images/a2.sif", MountPoint: "/mnt/a", FSType: "ext3"},
+	} {
+		if err := r.add(rec); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	records, err = r.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := mountPoints(records), []string{"/mnt/a", "/mnt/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got mount points %v, want %v", got, want)
+	}
+	if records[0].Image != "/images/a2.sif" {
+		t.Errorf("record for /mnt/a not replaced: got image %s", records[0].Image)
+	}
+
+	// The stale record must have been pruned from the registry.
+	stored, err := r.read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Errorf("got %d stored records, want 2", len(stored))
+	}
+
+	errUnmount := errors.New("unmount failed")
+	if err := r.remove("/mnt/b", func(Record) error { return errUnmount }); !errors.Is(err, errUnmount) {
+		t.Errorf("got error %v, want %v", err, errUnmount)
+	}
+	if err := r.remove("/mnt/b", func(Record) error { return nil }); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := r.Unmount(context.Background(), "/mnt/b"); !errors.Is(err, ErrNotMounted) {
+		t.Errorf("got error %v, want %v", err, ErrNotMounted)
+	}
+
+	records, err = r.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := mountPoints(records), []string{"/mnt/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got mount points %v, want %v", got, want)
+	}
+}
+
+func TestMountNotDirectory(t *testing.T) {
+	r := NewRegistry(t.TempDir())
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Mount(context.Background(), file, file); err == nil {
+		t.Errorf("unexpected success mounting on a file")
+	}
+}
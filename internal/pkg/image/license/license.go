@@ -0,0 +1,341 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package license reads the license declared by container images, and applies
+// the host policy restricting the launch of images with specific licenses to
+// members of entitled groups.
+package license
+
+import (
+	"errors"
+	"fmt"
+	"log/syslog"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/image/annotation"
+	ugroup "github.com/sylabs/singularity/v4/internal/pkg/util/user"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// AnnotationLicenses is the annotation, or label, holding the SPDX license
+// expression of an image.
+const AnnotationLicenses = "org.opencontainers.image.licenses"
+
+// ErrNotEntitled is returned by Check when the user is not entitled to launch
+// an image.
+var ErrNotEntitled = errors.New("not entitled to use image license")
+
+// auditLog records an overridden license restriction in the audit trail.
+var auditLog = func(msg string) error {
+	w, err := syslog.New(syslog.LOG_AUTH|syslog.LOG_NOTICE, "singularity")
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return w.Notice(msg)
+}
+
+// Expr is a parsed SPDX license expression.
+type Expr interface {
+	// eval returns whether the image may be used under the expression, if
+	// it may be used under the licenses for which usable returns true.
+	eval(usable func(id string) (bool, error)) (bool, error)
+	// ids appends the license identifiers in the expression to ids.
+	ids(ids []string) []string
+}
+
+// licenseID is a license identifier. An exception, added with WITH, applies to
+// the license, so is not held.
+type licenseID string
+
+func (l licenseID) eval(usable func(string) (bool, error)) (bool, error) {
+	return usable(string(l))
+}
+
+func (l licenseID) ids(ids []string) []string {
+	return append(ids, string(l))
+}
+
+// compound is a conjunction (AND) or disjunction (OR) of expressions.
+type compound struct {
+	and   bool
+	exprs []Expr
+}
+
+func (c compound) eval(usable func(string) (bool, error)) (bool, error) {
+	for _, e := range c.exprs {
+		ok, err := e.eval(usable)
+		if err != nil {
+			return false, err
+		}
+		if ok != c.and {
+			return ok, nil
+		}
+	}
+	return c.and, nil
+}
+
+func (c compound) ids(ids []string) []string {
+	for _, e := range c.exprs {
+		ids = e.ids(ids)
+	}
+	return ids
+}
+
+// IDs returns the license identifiers in the expression e.
+func IDs(e Expr) []string {
+	if e == nil {
+		return nil
+	}
+	return e.ids(nil)
+}
+
+// Parse parses the SPDX license expression expr, in which AND takes
+// precedence over OR. An image may be used under an expression if it may be
+// used under both operands of AND, or either operand of OR. Exceptions added
+// with WITH are ignored. A nil Expr is returned for an empty expression.
+func Parse(expr string) (Expr, error) {
+	p := &parser{tokens: tokenize(expr)}
+	if len(p.tokens) == 0 {
+		return nil, nil
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("invalid license expression %q: %w", expr, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid license expression %q: unexpected %q", expr, p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+// tokenize splits a license expression into parentheses and words.
+func tokenize(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	return strings.Fields(expr)
+}
+
+// parser is a recursive descent parser of license expressions.
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) or() (Expr, error) {
+	return p.compound(false, p.and, "OR")
+}
+
+func (p *parser) and() (Expr, error) {
+	return p.compound(true, p.term, "AND")
+}
+
+// compound parses operands with next, separated by the operator op.
+func (p *parser) compound(and bool, next func() (Expr, error), op string) (Expr, error) {
+	e, err := next()
+	if err != nil {
+		return nil, err
+	}
+	c := compound{and: and, exprs: []Expr{e}}
+	for strings.EqualFold(p.peek(), op) {
+		p.pos++
+		e, err := next()
+		if err != nil {
+			return nil, err
+		}
+		c.exprs = append(c.exprs, e)
+	}
+	if len(c.exprs) == 1 {
+		return c.exprs[0], nil
+	}
+	return c, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	tok := p.peek()
+	p.pos++
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return e, nil
+	case tok == ")" || strings.EqualFold(tok, "AND") || strings.EqualFold(tok, "OR") || strings.EqualFold(tok, "WITH"):
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	if strings.EqualFold(p.peek(), "WITH") {
+		p.pos++
+		if exception := p.peek(); exception == "" || exception == "(" || exception == ")" {
+			return nil, fmt.Errorf("missing exception after WITH")
+		}
+		p.pos++
+	}
+	return licenseID(tok), nil
+}
+
+// FromImage returns the SPDX license expression declared by the image at
+// path. For OCI-SIF images, the license is read from the image manifest
+// annotations, and the image config labels. For SIF images and sandboxes, it
+// is read from the container labels.
+func FromImage(path string) (string, error) {
+	annotations, err := annotation.FromImage(path)
+	if err != nil {
+		return "", err
+	}
+	return annotation.Get(AnnotationLicenses, annotations...), nil
+}
+
+// FromLabels returns the SPDX license expression declared by the image config
+// labels.
+func FromLabels(labels map[string]string) string {
+	return annotation.Get(AnnotationLicenses, labels)
+}
+
+// Enforce applies the 'image license groups' policy of singularity.conf to
+// the SPDX license expression expr of the image name, for the calling user,
+// who may provide an override reason. The OCI launcher calls Enforce once the
+// image to run is resolved, so that the policy applies to all image sources.
+// In native mode, the policy is applied by the engine.
+func Enforce(expr, name, override string) error {
+	conf := singularityconf.GetCurrentConfig()
+	if conf == nil || len(conf.LicenseGroups) == 0 {
+		return nil
+	}
+	p, err := NewPolicy(conf.LicenseGroups, conf.LicenseOverrideGroups)
+	if err != nil {
+		return err
+	}
+	return p.Check(expr, name, os.Getuid(), override)
+}
+
+// Policy restricts the launch of images with specific licenses to members of
+// entitled groups.
+type Policy struct {
+	// Groups maps license identifiers to the groups entitled to use them.
+	Groups map[string][]string
+	// OverrideGroups lists the groups whose members may override the
+	// restrictions, which is recorded in the audit trail.
+	OverrideGroups []string
+}
+
+// NewPolicy returns a Policy from the 'image license groups' and 'image
+// license override groups' directives of singularity.conf. Each entitlement is
+// of the form <license>:<group>, and may be repeated to entitle more than one
+// group to a license.
+func NewPolicy(entitlements, overrideGroups []string) (*Policy, error) {
+	p := &Policy{
+		Groups:         make(map[string][]string),
+		OverrideGroups: overrideGroups,
+	}
+	for _, e := range entitlements {
+		id, group, ok := strings.Cut(strings.TrimSpace(e), ":")
+		if !ok || id == "" || group == "" {
+			return nil, fmt.Errorf("invalid image license entitlement %q, format is <license>:<group>", e)
+		}
+		p.Groups[id] = append(p.Groups[id], group)
+	}
+	return p, nil
+}
+
+// Check applies the policy p to the SPDX license expression expr of the image
+// name, for the user with uid. If the image can't be used under any license
+// choice of the expression, as licenses are restricted to groups the user is
+// not a member of, an error wrapping ErrNotEntitled is returned, unless the
+// user is a member of one of the override groups and provides a reason for the
+// override. Each override is recorded, with its reason, in the audit trail
+// (syslog).
+func (p *Policy) Check(expr string, name string, uid int, override string) error {
+	e, err := Parse(expr)
+	if err != nil {
+		return fmt.Errorf("while reading license of image %s: %w", name, err)
+	}
+
+	var denied []string
+	entitlements := make(map[string]bool)
+	usable := func(id string) (bool, error) {
+		groups, ok := p.Groups[id]
+		if !ok {
+			return true, nil
+		}
+		if entitled, ok := entitlements[id]; ok {
+			return entitled, nil
+		}
+		entitled, err := ugroup.UIDInAnyGroup(uid, groups)
+		if err != nil {
+			return false, fmt.Errorf("while checking entitlement to license %s: %w", id, err)
+		}
+		entitlements[id] = entitled
+		if !entitled {
+			denied = append(denied, id)
+		}
+		return entitled, nil
+	}
+	if e != nil {
+		// All restricted licenses are evaluated, rather than stopping at the
+		// first usable one, so that the denied licenses can be reported.
+		for _, id := range IDs(e) {
+			if _, err := usable(id); err != nil {
+				return err
+			}
+		}
+		ok, err := e.eval(usable)
+		if err != nil {
+			return err
+		}
+		if ok {
+			denied = nil
+		}
+	}
+
+	if len(denied) == 0 {
+		if override != "" {
+			sylog.Warningf("Image %s does not require a license override, ignoring", name)
+		}
+		return nil
+	}
+
+	notEntitled := fmt.Errorf("%w: %s is licensed under %s, which is restricted to entitled groups", ErrNotEntitled, name, strings.Join(denied, ", "))
+	if override == "" {
+		return notEntitled
+	}
+	if len(p.OverrideGroups) == 0 {
+		return fmt.Errorf("%w, and overrides are not permitted", notEntitled)
+	}
+	canOverride, err := ugroup.UIDInAnyGroup(uid, p.OverrideGroups)
+	if err != nil {
+		return fmt.Errorf("while checking license override permission: %w", err)
+	}
+	if !canOverride {
+		return fmt.Errorf("%w, and you are not permitted to override it", notEntitled)
+	}
+
+	username := strconv.Itoa(uid)
+	if u, err := user.LookupId(username); err == nil {
+		username = u.Username
+	}
+	msg := fmt.Sprintf("license override: user=%s uid=%d image=%s licenses=%s reason=%q", username, uid, name, strings.Join(denied, ","), override)
+	if err := auditLog(msg); err != nil {
+		return fmt.Errorf("while recording license override in audit trail: %w", err)
+	}
+	sylog.Warningf("Overriding license restriction on image %s (%s): %s", name, strings.Join(denied, ", "), override)
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package license
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantIDs []string
+		wantErr bool
+	}{
+		{expr: "", wantIDs: nil},
+		{expr: "MIT", wantIDs: []string{"MIT"}},
+		{expr: "MIT OR Apache-2.0", wantIDs: []string{"MIT", "Apache-2.0"}},
+		{expr: "(LicenseRef-Acme AND GPL-2.0-only WITH Classpath-exception-2.0)", wantIDs: []string{"LicenseRef-Acme", "GPL-2.0-only"}},
+		{expr: "MIT AND", wantErr: true},
+		{expr: "(MIT OR Apache-2.0", wantErr: true},
+		{expr: "MIT Apache-2.0", wantErr: true},
+		{expr: "GPL-2.0-only WITH", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if got := IDs(e); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("Parse(%q) IDs = %v, want %v", tt.expr, got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	usable := func(id string) (bool, error) {
+		return id != "LicenseRef-Acme" && id != "LicenseRef-Other", nil
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"MIT", true},
+		{"LicenseRef-Acme", false},
+		{"LicenseRef-Acme OR MIT", true},
+		{"LicenseRef-Acme AND MIT", false},
+		{"LicenseRef-Acme or LicenseRef-Other", false},
+		{"MIT AND LicenseRef-Acme OR Apache-2.0", true},
+		{"MIT AND (LicenseRef-Acme OR Apache-2.0)", true},
+		{"Apache-2.0 AND (LicenseRef-Acme OR LicenseRef-Other)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.eval(usable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("eval(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	p, err := NewPolicy([]string{"LicenseRef-Acme:acme", "LicenseRef-Acme:admins", " MIT:users"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"LicenseRef-Acme": {"acme", "admins"},
+		"MIT":             {"users"},
+	}
+	if !reflect.DeepEqual(p.Groups, want) {
+		t.Errorf("got groups %v, want %v", p.Groups, want)
+	}
+
+	for _, e := range []string{"LicenseRef-Acme", "LicenseRef-Acme:", ":acme"} {
+		if _, err := NewPolicy([]string{e}, nil); err == nil {
+			t.Errorf("NewPolicy(%q) succeeded, want error", e)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := os.Getuid()
+
+	var audited []string
+	origAuditLog := auditLog
+	auditLog = func(msg string) error {
+		audited = append(audited, msg)
+		return nil
+	}
+	defer func() { auditLog = origAuditLog }()
+
+	tests := []struct {
+		name           string
+		expr           string
+		entitlements   []string
+		overrideGroups []string
+		override       string
+		wantErr        bool
+		wantAudit      bool
+	}{
+		{
+			name:         "Unrestricted",
+			entitlements: []string{"LicenseRef-Other:nonexistent-group"},
+		},
+		{
+			name:         "Entitled",
+			entitlements: []string{"LicenseRef-Acme:nonexistent-group", "LicenseRef-Acme:" + u.Gid},
+		},
+		{
+			name:         "NotEntitled",
+			entitlements: []string{"LicenseRef-Acme:nonexistent-group"},
+			wantErr:      true,
+		},
+		{
+			name:         "OverrideNotPermitted",
+			entitlements: []string{"LicenseRef-Acme:nonexistent-group"},
+			override:     "support case 1234",
+			wantErr:      true,
+		},
+		{
+			name:           "OverrideNotInGroup",
+			entitlements:   []string{"LicenseRef-Acme:nonexistent-group"},
+			overrideGroups: []string{"nonexistent-group"},
+			override:       "support case 1234",
+			wantErr:        true,
+		},
+		{
+			name:         "DualLicensed",
+			expr:         "LicenseRef-Acme OR MIT",
+			entitlements: []string{"LicenseRef-Acme:nonexistent-group"},
+		},
+		{
+			name:         "DualLicensedNotEntitled",
+			expr:         "LicenseRef-Acme OR LicenseRef-Other",
+			entitlements: []string{"LicenseRef-Acme:nonexistent-group", "LicenseRef-Other:nonexistent-group"},
+			wantErr:      true,
+		},
+		{
+			name:           "Override",
+			entitlements:   []string{"LicenseRef-Acme:nonexistent-group"},
+			overrideGroups: []string{u.Gid},
+			override:       "support case 1234",
+			wantAudit:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audited = nil
+			p, err := NewPolicy(tt.entitlements, tt.overrideGroups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expr := tt.expr
+			if expr == "" {
+				expr = "MIT AND LicenseRef-Acme"
+			}
+			err = p.Check(expr, "test.sif", uid, tt.override)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrNotEntitled) {
+				t.Errorf("Check() error = %v, want ErrNotEntitled", err)
+			}
+			if got := len(audited) > 0; got != tt.wantAudit {
+				t.Fatalf("got audit %v, want audit %v", audited, tt.wantAudit)
+			}
+			if tt.wantAudit && !strings.Contains(audited[0], `reason="support case 1234"`) {
+				t.Errorf("audit record %q does not contain reason", audited[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package metadata edits the container metadata held in a SIF image, without
+// rebuilding the image.
+//
+// The metadata is held in a JSON data object, which is read by inspect. In a
+// signed image, an edit writes a new metadata data object, in a new object
+// group, rather than modifying the existing one, so that signatures over the
+// existing data objects remain valid. When more than one metadata data object
+// is present, the one with the highest ID is current.
+//
+// The root filesystem of the image is left unchanged, so the edited
+// attributes are recorded in the metadata, and applied to the container by
+// the runtime, see RuntimeEdits.
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/inspect"
+)
+
+// EnvOverlayFile is the environment file, in the metadata, holding the
+// environment variables set by an edit.
+const EnvOverlayFile = "/.singularity.d/env/95-metadata-edit.sh"
+
+const (
+	// LabelsFile is the container file holding the labels.
+	LabelsFile = "/.singularity.d/labels.json"
+	// HelpFile is the container file holding the help text.
+	HelpFile = "/.singularity.d/runscript.help"
+)
+
+// Edited attributes, recorded in the metadata.
+const (
+	editedLabels      = "labels"
+	editedEnvironment = "environment"
+	editedHelpfile    = "helpfile"
+)
+
+// Edit describes changes to the container metadata of a SIF image.
+type Edit struct {
+	// Labels are added to the container labels, replacing existing labels
+	// with the same key.
+	Labels map[string]string
+	// RemoveLabels are removed from the container labels.
+	RemoveLabels []string
+	// Env holds environment variables set in the environment overlay.
+	Env map[string]string
+	// Help replaces the container help text when SetHelp is true.
+	Help    string
+	SetHelp bool
+}
+
+// Result describes a SIF image after an edit.
+type Result struct {
+	// ID is the ID of the data object holding the edited metadata.
+	ID uint32
+	// GroupID is the object group ID of the data object holding the edited
+	// metadata.
+	GroupID uint32
+	// Replaced is true if the edit replaced an unsigned metadata data object,
+	// and false if it added a new one.
+	Replaced bool
+	// Signed is true if the image holds signatures.
+	Signed bool
+	// Unsigned lists the IDs of data objects that are not covered by any
+	// signature, if the image holds signatures.
+	Unsigned []uint32
+}
+
+// Current returns the descriptor of the current metadata data object in f.
+// If f holds no metadata, sif.ErrObjectNotFound is returned.
+func Current(f *sif.FileImage) (sif.Descriptor, error) {
+	ds, err := f.GetDescriptors(
+		sif.WithDataType(sif.DataGenericJSON),
+		func(d sif.Descriptor) (bool, error) { return d.Name() == image.SIFDescInspectMetadataJSON, nil },
+	)
+	if err != nil {
+		return sif.Descriptor{}, err
+	}
+	if len(ds) == 0 {
+		return sif.Descriptor{}, sif.ErrObjectNotFound
+	}
+
+	cur := ds[0]
+	for _, d := range ds[1:] {
+		if d.ID() > cur.ID() {
+			cur = d
+		}
+	}
+	return cur, nil
+}
+
+// Read returns the current metadata in f. If f holds no metadata,
+// sif.ErrObjectNotFound is returned.
+func Read(f *sif.FileImage) (*inspect.Metadata, error) {
+	d, err := Current(f)
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.GetData()
+	if err != nil {
+		return nil, fmt.Errorf("while reading metadata: %w", err)
+	}
+	md := inspect.NewMetadata()
+	if err := json.Unmarshal(b, md); err != nil {
+		return nil, fmt.Errorf("while decoding metadata: %w", err)
+	}
+	return md, nil
+}
+
+// Runtime holds the metadata edits of an image, as applied to the container
+// when it runs.
+type Runtime struct {
+	// Env holds the environment variables set by edits, which override those
+	// set by the image, but not those set by the user.
+	Env map[string]string
+	// Files maps the container metadata files replaced by edits to their
+	// content.
+	Files map[string][]byte
+}
+
+// RuntimeEdits returns the metadata edits of the SIF image at path, or nil if
+// the metadata of the image was not edited since it was built.
+func RuntimeEdits(path string) (*Runtime, error) {
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer f.UnloadContainer()
+
+	md, err := Read(f)
+	if errors.Is(err, sif.ErrObjectNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return runtimeEdits(md)
+}
+
+// runtimeEdits returns the edits recorded in md, or nil if there are none.
+func runtimeEdits(md *inspect.Metadata) (*Runtime, error) {
+	if len(md.Edited) == 0 {
+		return nil, nil
+	}
+
+	r := &Runtime{Files: make(map[string][]byte)}
+	for _, attr := range md.Edited {
+		switch attr {
+		case editedLabels:
+			b, err := json.MarshalIndent(md.Attributes.Labels, "", "\t")
+			if err != nil {
+				return nil, err
+			}
+			r.Files[LabelsFile] = b
+		case editedEnvironment:
+			r.Env = parseEnvOverlay(md.Attributes.Environment[EnvOverlayFile])
+		case editedHelpfile:
+			r.Files[HelpFile] = []byte(md.Attributes.Helpfile)
+		}
+	}
+	return r, nil
+}
+
+// SignedObjects returns the IDs of data objects in f that are covered by a
+// signature, and whether f holds any signature.
+func SignedObjects(f *sif.FileImage) (map[uint32]bool, bool, error) {
+	sigs, err := f.GetDescriptors(sif.WithDataType(sif.DataSignature))
+	if err != nil {
+		return nil, false, err
+	}
+
+	signed := make(map[uint32]bool)
+	groups := make(map[uint32]bool)
+	for _, sig := range sigs {
+		if id, isGroup := sig.LinkedID(); isGroup {
+			groups[id] = true
+		} else {
+			signed[id] = true
+		}
+	}
+
+	f.WithDescriptors(func(d sif.Descriptor) bool {
+		if d.DataType() != sif.DataSignature && groups[d.GroupID()] {
+			signed[d.ID()] = true
+		}
+		return false
+	})
+
+	return signed, len(sigs) > 0, nil
+}
+
+// Unsigned returns the IDs of data objects in f, other than signatures, that
+// are not covered by a signature.
+func Unsigned(f *sif.FileImage) ([]uint32, error) {
+	signed, _, err := SignedObjects(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint32
+	f.WithDescriptors(func(d sif.Descriptor) bool {
+		if d.DataType() != sif.DataSignature && !signed[d.ID()] {
+			ids = append(ids, d.ID())
+		}
+		return false
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, nil
+}
+
+// nextGroupID returns an object group ID that is not used in f.
+func nextGroupID(f *sif.FileImage) uint32 {
+	var max uint32
+	f.WithDescriptors(func(d sif.Descriptor) bool {
+		if d.GroupID() > max {
+			max = d.GroupID()
+		}
+		return false
+	})
+	return max + 1
+}
+
+// EditSIF applies e to the container metadata of the SIF image at path.
+//
+// If no data object in the group of the current metadata data object is
+// covered by a signature, the metadata data object is replaced. Otherwise, a
+// new metadata data object is added in a new object group, leaving the signed
+// data objects, and their signatures, unchanged. The new data object is not
+// signed.
+func EditSIF(path string, e Edit) (*Result, error) {
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDWR))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer f.UnloadContainer()
+
+	cur, err := Current(f)
+	found := err == nil
+	if err != nil && !errors.Is(err, sif.ErrObjectNotFound) {
+		return nil, err
+	}
+	md := inspect.NewMetadata()
+	if found {
+		if md, err = Read(f); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := apply(md, e); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(md)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding metadata: %w", err)
+	}
+
+	signed, isSigned, err := SignedObjects(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting a data object from a group holding signed data objects would
+	// change the relative IDs used by their signatures, so only replace the
+	// metadata if its group is unsigned.
+	replace := found
+	if found {
+		f.WithDescriptors(func(d sif.Descriptor) bool {
+			if d.GroupID() == cur.GroupID() && signed[d.ID()] {
+				replace = false
+			}
+			return false
+		})
+	}
+
+	var groupID uint32
+	switch {
+	case replace && cur.GroupID() != 0:
+		groupID = cur.GroupID()
+	case !isSigned:
+		groupID = sif.DefaultObjectGroup
+	default:
+		groupID = nextGroupID(f)
+	}
+
+	di, err := sif.NewDescriptorInput(sif.DataGenericJSON, bytes.NewReader(b),
+		sif.OptObjectName(image.SIFDescInspectMetadataJSON),
+		sif.OptGroupID(groupID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if replace {
+		if err := f.DeleteObject(cur.ID(), sif.OptDeleteZero(true)); err != nil {
+			return nil, fmt.Errorf("while deleting metadata: %w", err)
+		}
+	}
+	if err := f.AddObject(di); err != nil {
+		return nil, fmt.Errorf("while adding metadata: %w", err)
+	}
+
+	d, err := Current(f)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{
+		ID:       d.ID(),
+		GroupID:  d.GroupID(),
+		Replaced: replace,
+		Signed:   isSigned,
+	}
+	if isSigned {
+		if r.Unsigned, err = Unsigned(f); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// apply applies e to md.
+func apply(md *inspect.Metadata, e Edit) error {
+	if md.Attributes.Labels == nil {
+		md.Attributes.Labels = make(map[string]string)
+	}
+	for k, v := range e.Labels {
+		if k == "" {
+			return fmt.Errorf("label key cannot be empty")
+		}
+		md.Attributes.Labels[k] = v
+	}
+	for _, k := range e.RemoveLabels {
+		delete(md.Attributes.Labels, k)
+	}
+	if len(e.Labels) > 0 || len(e.RemoveLabels) > 0 {
+		setEdited(md, editedLabels)
+	}
+
+	if len(e.Env) > 0 {
+		if md.Attributes.Environment == nil {
+			md.Attributes.Environment = make(map[string]string)
+		}
+		env := parseEnvOverlay(md.Attributes.Environment[EnvOverlayFile])
+		for k, v := range e.Env {
+			if k == "" || strings.ContainsAny(k, "= \t\n") {
+				return fmt.Errorf("invalid environment variable name %q", k)
+			}
+			env[k] = v
+		}
+		md.Attributes.Environment[EnvOverlayFile] = envOverlay(env)
+		setEdited(md, editedEnvironment)
+	}
+
+	if e.SetHelp {
+		md.Attributes.Helpfile = e.Help
+		setEdited(md, editedHelpfile)
+	}
+	return nil
+}
+
+// setEdited records attr as edited in md.
+func setEdited(md *inspect.Metadata, attr string) {
+	for _, a := range md.Edited {
+		if a == attr {
+			return
+		}
+	}
+	md.Edited = append(md.Edited, attr)
+}
+
+// envOverlay returns the content of the environment overlay file setting env.
+func envOverlay(env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "export %s='%s'\n", k, strings.ReplaceAll(env[k], "'", `'\''`))
+	}
+	return sb.String()
+}
+
+// parseEnvOverlay returns the environment variables set in s, the content of
+// an environment overlay file written by envOverlay.
+func parseEnvOverlay(s string) map[string]string {
+	env := make(map[string]string)
+	for _, l := range strings.Split(s, "\n") {
+		l, ok := strings.CutPrefix(l, "export ")
+		if !ok {
+			continue
+		}
+		k, v, ok := strings.Cut(l, "=")
+		if !ok || len(v) < 2 || v[0] != '\'' || v[len(v)-1] != '\'' {
+			continue
+		}
+		env[k] = strings.ReplaceAll(v[1:len(v)-1], `'\''`, "'")
+	}
+	return env
+}
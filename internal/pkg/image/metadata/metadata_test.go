@@ -0,0 +1,258 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/sylabs/sif/v2/pkg/integrity"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/inspect"
+)
+
+// createSIF creates a SIF image holding a definition file, metadata with a
+// label, and a data object, all in the default object group.
+func createSIF(t *testing.T) string {
+	t.Helper()
+
+	md := inspect.NewMetadata()
+	md.Attributes.Labels["owner"] = "bob"
+	md.Attributes.Helpfile = "old help"
+	b, err := json.Marshal(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	def, err := sif.NewDescriptorInput(sif.DataDeffile, bytes.NewReader([]byte("Bootstrap: scratch\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := sif.NewDescriptorInput(sif.DataGenericJSON, bytes.NewReader(b),
+		sif.OptObjectName(image.SIFDescInspectMetadataJSON),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := sif.NewDescriptorInput(sif.DataGeneric, bytes.NewReader([]byte("data")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.sif")
+	f, err := sif.CreateContainerAtPath(path, sif.OptCreateWithDescriptors(def, meta, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readMetadata returns the current metadata of the SIF image at path, and the
+// number of metadata data objects it holds.
+func readMetadata(t *testing.T, path string) (*inspect.Metadata, int) {
+	t.Helper()
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	ds, err := f.GetDescriptors(sif.WithDataType(sif.DataGenericJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Current(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.GetData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := new(inspect.Metadata)
+	if err := json.Unmarshal(b, md); err != nil {
+		t.Fatal(err)
+	}
+	return md, len(ds)
+}
+
+func TestEditSIFUnsigned(t *testing.T) {
+	path := createSIF(t)
+
+	r, err := EditSIF(path, Edit{
+		Labels:       map[string]string{"team": "hpc"},
+		RemoveLabels: []string{"owner"},
+		Env:          map[string]string{"MODE": "it's production"},
+		Help:         "new help",
+		SetHelp:      true,
+	})
+	if err != nil {
+		t.Fatalf("EditSIF() error = %v", err)
+	}
+	if !r.Replaced {
+		t.Errorf("EditSIF() replaced = false, want true")
+	}
+	if r.Signed || len(r.Unsigned) > 0 {
+		t.Errorf("EditSIF() signed = %v, unsigned = %v, want false, []", r.Signed, r.Unsigned)
+	}
+
+	// A second edit merges with the first.
+	if _, err := EditSIF(path, Edit{Env: map[string]string{"LEVEL": "2"}}); err != nil {
+		t.Fatalf("EditSIF() error = %v", err)
+	}
+
+	md, n := readMetadata(t, path)
+	if n != 1 {
+		t.Errorf("got %d metadata data objects, want 1", n)
+	}
+	if want := map[string]string{"team": "hpc"}; !reflect.DeepEqual(md.Attributes.Labels, want) {
+		t.Errorf("got labels %v, want %v", md.Attributes.Labels, want)
+	}
+	if md.Attributes.Helpfile != "new help" {
+		t.Errorf("got help %q, want %q", md.Attributes.Helpfile, "new help")
+	}
+	env := parseEnvOverlay(md.Attributes.Environment[EnvOverlayFile])
+	if want := map[string]string{"MODE": "it's production", "LEVEL": "2"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("got environment %v, want %v", env, want)
+	}
+}
+
+func TestEditSIFSigned(t *testing.T) {
+	path := createSIF(t)
+
+	e, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := sif.LoadContainerFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := integrity.NewSigner(f, integrity.OptSignWithEntity(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sign(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := EditSIF(path, Edit{Labels: map[string]string{"owner": "alice"}})
+	if err != nil {
+		t.Fatalf("EditSIF() error = %v", err)
+	}
+	if r.Replaced {
+		t.Errorf("EditSIF() replaced = true, want false")
+	}
+	if r.GroupID == sif.DefaultObjectGroup {
+		t.Errorf("EditSIF() group = %d, want a new group", r.GroupID)
+	}
+	if !r.Signed {
+		t.Errorf("EditSIF() signed = false, want true")
+	}
+	if want := []uint32{r.ID}; !reflect.DeepEqual(r.Unsigned, want) {
+		t.Errorf("EditSIF() unsigned = %v, want %v", r.Unsigned, want)
+	}
+
+	md, n := readMetadata(t, path)
+	if n != 2 {
+		t.Errorf("got %d metadata data objects, want 2", n)
+	}
+	if got := md.Attributes.Labels["owner"]; got != "alice" {
+		t.Errorf("got owner label %q, want %q", got, "alice")
+	}
+
+	// The signature over the original object group must remain valid.
+	f, err = sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	v, err := integrity.NewVerifier(f,
+		integrity.OptVerifyWithKeyRing(openpgp.EntityList{e}),
+		integrity.OptVerifyGroup(sif.DefaultObjectGroup),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// A further edit replaces the unsigned metadata data object.
+	r, err = EditSIF(path, Edit{Labels: map[string]string{"owner": "carol"}})
+	if err != nil {
+		t.Fatalf("EditSIF() error = %v", err)
+	}
+	if !r.Replaced {
+		t.Errorf("EditSIF() replaced = false, want true")
+	}
+	if _, n := readMetadata(t, path); n != 2 {
+		t.Errorf("got %d metadata data objects, want 2", n)
+	}
+}
+
+func TestRuntimeEdits(t *testing.T) {
+	path := createSIF(t)
+
+	// The metadata written at build time is not applied by the runtime.
+	r, err := RuntimeEdits(path)
+	if err != nil {
+		t.Fatalf("RuntimeEdits() error = %v", err)
+	}
+	if r != nil {
+		t.Errorf("RuntimeEdits() = %v, want nil", r)
+	}
+
+	if _, err := EditSIF(path, Edit{Env: map[string]string{"MODE": "test"}}); err != nil {
+		t.Fatalf("EditSIF() error = %v", err)
+	}
+	r, err = RuntimeEdits(path)
+	if err != nil {
+		t.Fatalf("RuntimeEdits() error = %v", err)
+	}
+	if want := map[string]string{"MODE": "test"}; !reflect.DeepEqual(r.Env, want) {
+		t.Errorf("got environment %v, want %v", r.Env, want)
+	}
+	if len(r.Files) > 0 {
+		t.Errorf("got files %v, want none", r.Files)
+	}
+
+	if _, err := EditSIF(path, Edit{Labels: map[string]string{"team": "hpc"}, Help: "new help", SetHelp: true}); err != nil {
+		t.Fatalf("EditSIF() error = %v", err)
+	}
+	r, err = RuntimeEdits(path)
+	if err != nil {
+		t.Fatalf("RuntimeEdits() error = %v", err)
+	}
+	if want := map[string]string{"MODE": "test"}; !reflect.DeepEqual(r.Env, want) {
+		t.Errorf("got environment %v, want %v", r.Env, want)
+	}
+	labels := make(map[string]string)
+	if err := json.Unmarshal(r.Files[LabelsFile], &labels); err != nil {
+		t.Fatalf("while decoding %s: %v", LabelsFile, err)
+	}
+	if want := map[string]string{"owner": "bob", "team": "hpc"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("got labels %v, want %v", labels, want)
+	}
+	if got := string(r.Files[HelpFile]); got != "new help" {
+		t.Errorf("got help %q, want %q", got, "new help")
+	}
+}
@@ -0,0 +1,403 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package minimize rewrites a SIF or OCI-SIF image keeping only the files of
+// its root filesystem that were accessed in recorded access profiles, or that
+// match a keep-list.
+package minimize
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/compact"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/packer"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/unpacker"
+	fsutil "github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/ioprofile"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// DefaultKeep lists the paths that are always kept, as they are used by
+// singularity to run the container, rather than by the workload.
+var DefaultKeep = []string{
+	"/.singularity.d",
+	"/etc/group",
+	"/etc/hosts",
+	"/etc/ld.so.cache",
+	"/etc/nsswitch.conf",
+	"/etc/passwd",
+	"/etc/resolv.conf",
+}
+
+// Options holds the options for Minimize.
+type Options struct {
+	// Profiles are the paths of the access profiles, written by --profile-io
+	// or listing one path per line, of the files to keep.
+	Profiles []string
+	// Keep lists glob patterns of additional paths to keep. All files below a
+	// kept directory are kept.
+	Keep []string
+	// KeepFiles are the paths of files listing keep patterns, one per line.
+	KeepFiles []string
+	// TmpDir is the directory in which the root filesystem is extracted.
+	TmpDir string
+}
+
+// Result describes the changes made by Minimize.
+type Result struct {
+	// OldSize and NewSize are the sizes of the image, in bytes, before and
+	// after minimization.
+	OldSize int64
+	NewSize int64
+	// Kept and Removed are the numbers of files kept and removed.
+	Kept    int
+	Removed int
+	// DroppedSignatures is set if the signatures of the source image were
+	// not copied, as they no longer apply to the minimized image.
+	DroppedSignatures bool
+}
+
+// Minimize writes to dst a copy of the SIF or OCI-SIF image at src, whose
+// root filesystem only holds the regular files listed in the access profiles,
+// or matching the keep patterns, of opts. Directories, symlinks and special
+// files are always kept, as they take little space, and profiles only record
+// the targets of the symlinks they access. For a SIF image, the primary
+// squashfs partition is minimized, and the other data objects of the image are
+// copied, except for signatures. For an OCI-SIF image, the single squashfs
+// layer of the image is minimized, and the manifest and config are updated to
+// match it. Other data objects of an OCI-SIF image are not copied.
+func Minimize(src, dst string, opts Options) (*Result, error) {
+	if len(opts.Profiles) == 0 {
+		return nil, fmt.Errorf("at least one access profile is required")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("image file already exists: %s", dst)
+	}
+
+	keep, err := keepSet(opts, src)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	f, err := sif.LoadContainerFromPath(src, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, fmt.Errorf("while loading SIF: %w", err)
+	}
+	defer f.UnloadContainer()
+
+	_, err = f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex))
+	isOCI := err == nil
+	var rootfsDesc sif.Descriptor
+	if isOCI {
+		rootfsDesc, err = ociLayer(f, src)
+	} else {
+		rootfsDesc, err = primaryPartition(f, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp(opts.TmpDir, "minimize-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := fsutil.ForceRemoveAll(tmpDir); err != nil {
+			sylog.Warningf("Could not remove temporary directory %s: %v", tmpDir, err)
+		}
+	}()
+
+	rootfs := filepath.Join(tmpDir, "rootfs")
+	if err := os.Mkdir(rootfs, 0o755); err != nil {
+		return nil, err
+	}
+	u := unpacker.NewSquashfs()
+	if !u.HasUnsquashfs() {
+		return nil, fmt.Errorf("could not extract squashfs, unsquashfs not found")
+	}
+	if err := u.ExtractAll(rootfsDesc.GetReader(), rootfs); err != nil {
+		return nil, fmt.Errorf("while extracting root filesystem: %w", err)
+	}
+
+	r := &Result{OldSize: fi.Size()}
+	if r.Kept, r.Removed, err = prune(rootfs, keep); err != nil {
+		return nil, err
+	}
+
+	sqfsPath := filepath.Join(tmpDir, "rootfs.sqfs")
+	flags := []string{"-noappend"}
+	// ownership can't be preserved on extraction as a user
+	if os.Getuid() != 0 {
+		sylog.Warningf("Ownership of files in the image is reset to root, as minimization is not run as root")
+		flags = append(flags, "-all-root")
+	}
+	if err := packer.NewSquashfs().Create([]string{rootfs}, sqfsPath, flags); err != nil {
+		return nil, err
+	}
+
+	if isOCI {
+		if _, err := f.GetDescriptor(sif.WithDataType(sif.DataSignature)); err == nil {
+			r.DroppedSignatures = true
+		}
+		if err := ocisif.ReplaceSquashfsLayer(src, dst, sqfsPath); err != nil {
+			return nil, fmt.Errorf("while writing minimized image: %w", err)
+		}
+	} else {
+		if r.DroppedSignatures, err = writeSIF(f, rootfsDesc, sqfsPath, dst); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Chmod(dst, fi.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	nfi, err := os.Stat(dst)
+	if err != nil {
+		return nil, err
+	}
+	r.NewSize = nfi.Size()
+
+	return r, nil
+}
+
+// primaryPartition returns the descriptor of the primary squashfs partition of
+// the SIF image f, loaded from src.
+func primaryPartition(f *sif.FileImage, src string) (sif.Descriptor, error) {
+	part, err := f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	if err != nil {
+		return sif.Descriptor{}, fmt.Errorf("while finding primary partition: %w", err)
+	}
+	if fsType, _, _, err := part.PartitionMetadata(); err != nil || fsType != sif.FsSquash {
+		return sif.Descriptor{}, fmt.Errorf("the primary partition of %s is not an unencrypted squashfs filesystem", src)
+	}
+	return part, nil
+}
+
+// ociLayer returns the descriptor of the single squashfs layer of the single
+// image in the OCI-SIF image f, loaded from src.
+func ociLayer(f *sif.FileImage, src string) (sif.Descriptor, error) {
+	mf, err := ocisif.ImageManifest(src)
+	if err != nil {
+		return sif.Descriptor{}, err
+	}
+	if len(mf.Layers) != 1 {
+		return sif.Descriptor{}, fmt.Errorf("only OCI-SIF images with a single layer can be minimized, %s has %d layers", src, len(mf.Layers))
+	}
+	if mf.Layers[0].MediaType != ocisif.SquashfsLayerMediaType {
+		return sif.Descriptor{}, fmt.Errorf("the layer of %s is not an unencrypted squashfs filesystem", src)
+	}
+	d, err := f.GetDescriptor(sif.WithOCIBlobDigest(mf.Layers[0].Digest))
+	if err != nil {
+		return sif.Descriptor{}, fmt.Errorf("while finding layer: %w", err)
+	}
+	return d, nil
+}
+
+// writeSIF writes to dst a copy of the SIF image f, with the data of the
+// partition part replaced by the squashfs filesystem at sqfsPath. Signatures
+// are not copied, and true is returned if f had any.
+func writeSIF(f *sif.FileImage, part sif.Descriptor, sqfsPath, dst string) (droppedSignatures bool, err error) {
+	sqfs, err := os.Open(sqfsPath)
+	if err != nil {
+		return false, err
+	}
+	defer sqfs.Close()
+
+	ds, err := f.GetDescriptors()
+	if err != nil {
+		return false, fmt.Errorf("while reading descriptors: %w", err)
+	}
+	var dis []sif.DescriptorInput
+	for _, d := range ds {
+		if d.DataType() == sif.DataSignature {
+			droppedSignatures = true
+			continue
+		}
+		var di sif.DescriptorInput
+		if d.ID() == part.ID() {
+			di, err = compact.DescriptorInput(d, sqfs, 0)
+		} else {
+			di, err = compact.DescriptorInput(d, d.GetReader(), 0)
+		}
+		if err != nil {
+			return false, fmt.Errorf("while copying data object %d: %w", d.ID(), err)
+		}
+		dis = append(dis, di)
+	}
+
+	out, err := sif.CreateContainerAtPath(dst,
+		sif.OptCreateWithLaunchScript(f.LaunchScript()),
+		sif.OptCreateWithDescriptors(dis...),
+	)
+	if err != nil {
+		return false, fmt.Errorf("while writing minimized image: %w", err)
+	}
+	return droppedSignatures, out.UnloadContainer()
+}
+
+// keepSet returns the paths listed in the access profiles of opts, and the
+// keep patterns of opts, and the defaults. A warning is given for any profile
+// that was recorded from an image other than src.
+func keepSet(opts Options, src string) (*keepList, error) {
+	k := &keepList{paths: make(map[string]bool)}
+	for _, p := range opts.Profiles {
+		paths, image, err := ioprofile.ReadPaths(p)
+		if err != nil {
+			return nil, fmt.Errorf("while reading access profile: %w", err)
+		}
+		if image != "" && !sameImage(image, src) {
+			sylog.Warningf("Access profile %s was recorded from %s, not %s: files it did not access may be removed", p, image, src)
+		}
+		for _, p := range paths {
+			k.paths[path.Clean("/"+p)] = true
+		}
+	}
+
+	patterns := append([]string{}, DefaultKeep...)
+	patterns = append(patterns, opts.Keep...)
+	for _, kf := range opts.KeepFiles {
+		kp, err := readKeepFile(kf)
+		if err != nil {
+			return nil, fmt.Errorf("while reading keep-list: %w", err)
+		}
+		patterns = append(patterns, kp...)
+	}
+	for _, p := range patterns {
+		p = path.Clean("/" + p)
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid keep pattern %q: %w", p, err)
+		}
+		k.patterns = append(k.patterns, p)
+	}
+	return k, nil
+}
+
+// sameImage returns true if image, as recorded in an access profile, is the
+// local image file at src. Images recorded from a registry, or other
+// transport, never match.
+func sameImage(image, src string) bool {
+	for _, prefix := range []string{"oci-sif:", "sif:"} {
+		if p, ok := strings.CutPrefix(image, prefix); ok {
+			image = p
+			break
+		}
+	}
+	fi, err := os.Stat(image)
+	if err != nil {
+		return false
+	}
+	srcFi, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi, srcFi)
+}
+
+// readKeepFile returns the patterns listed in the keep-list at path, one per
+// line, ignoring empty lines and lines starting with #.
+func readKeepFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// keepList holds the paths, and glob patterns, of the files to keep.
+type keepList struct {
+	paths    map[string]bool
+	patterns []string
+}
+
+// keeps returns true if p, an absolute path in the container, is listed, or
+// p or one of its parent directories matches a pattern.
+func (k *keepList) keeps(p string) bool {
+	if k.paths[p] {
+		return true
+	}
+	for dir := p; ; dir = path.Dir(dir) {
+		for _, pattern := range k.patterns {
+			if ok, _ := path.Match(pattern, dir); ok {
+				return true
+			}
+		}
+		if dir == "/" {
+			return false
+		}
+	}
+}
+
+// prune removes the regular files of rootfs that are not kept by k, returning
+// the numbers of files kept and removed.
+func prune(rootfs string, k *keepList) (kept, removed int, err error) {
+	err = filepath.WalkDir(rootfs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(rootfs, p)
+		if err != nil {
+			return err
+		}
+		if k.keeps("/" + filepath.ToSlash(rel)) {
+			kept++
+			return nil
+		}
+		if err := removeFile(p); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return kept, removed, err
+}
+
+// removeFile removes the file at p. If its directory is not writable, as
+// extracted from the image, it is made writable for the removal, and its mode
+// is then restored, as it is packed into the minimized image.
+func removeFile(p string) error {
+	err := os.Remove(p)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	dir := filepath.Dir(p)
+	fi, statErr := os.Stat(dir)
+	if statErr != nil {
+		return err
+	}
+	if err := os.Chmod(dir, fi.Mode().Perm()|0o200); err != nil {
+		return err
+	}
+	defer os.Chmod(dir, fi.Mode().Perm())
+	return os.Remove(p)
+}
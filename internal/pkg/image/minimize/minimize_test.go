@@ -0,0 +1,294 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package minimize
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/packer"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/unpacker"
+	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
+	"gotest.tools/v3/assert"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "profile.txt")
+	writeFile(t, profile, "/usr/bin/python3\n/usr/lib/libc.so.6\n")
+	keepFile := filepath.Join(dir, "keep.txt")
+	writeFile(t, keepFile, "# data files\n/data\n")
+
+	rootfs := filepath.Join(dir, "rootfs")
+	for _, f := range []string{
+		"usr/bin/python3",
+		"usr/bin/perl",
+		"usr/lib/libc.so.6",
+		"usr/lib/libm.so.6",
+		"usr/share/zoneinfo/UTC",
+		"data/input/a.csv",
+		"opt/app/app.conf",
+		".singularity.d/runscript",
+		"etc/passwd",
+		"etc/shadow",
+	} {
+		writeFile(t, filepath.Join(rootfs, f), f)
+	}
+	if err := os.Symlink("python3", filepath.Join(rootfs, "usr/bin/python")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(rootfs, "home"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A file in a read-only directory can be removed.
+	if err := os.Chmod(filepath.Join(rootfs, "usr/share/zoneinfo"), 0o555); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := keepSet(Options{
+		Profiles:  []string{profile},
+		Keep:      []string{"/opt/*/*.conf"},
+		KeepFiles: []string{keepFile},
+	}, filepath.Join(dir, "image.sif"))
+	assert.NilError(t, err)
+
+	kept, removed, err := prune(rootfs, k)
+	assert.NilError(t, err)
+	assert.Equal(t, kept, 6)
+	assert.Equal(t, removed, 4)
+
+	var got []string
+	err = filepath.Walk(rootfs, func(p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(rootfs, p)
+		got = append(got, rel)
+		return nil
+	})
+	assert.NilError(t, err)
+	sort.Strings(got)
+
+	for _, p := range []string{"usr/bin/perl", "usr/lib/libm.so.6", "usr/share/zoneinfo/UTC", "etc/shadow"} {
+		for _, g := range got {
+			assert.Assert(t, g != p, "%s was not removed", p)
+		}
+	}
+	for _, p := range []string{"usr/bin/python", "usr/bin/python3", "home", "usr/share/zoneinfo", "data/input/a.csv", "opt/app/app.conf", ".singularity.d/runscript"} {
+		found := false
+		for _, g := range got {
+			found = found || g == p
+		}
+		assert.Assert(t, found, "%s was removed", p)
+	}
+
+	fi, err := os.Stat(filepath.Join(rootfs, "usr/share/zoneinfo"))
+	assert.NilError(t, err)
+	assert.Equal(t, fi.Mode().Perm(), os.FileMode(0o555))
+}
+
+func TestMinimizeErrors(t *testing.T) {
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "profile.txt")
+	writeFile(t, profile, "/usr/bin/python3\n")
+
+	createSIF := func(name string, dt sif.DataType, opts ...sif.DescriptorInputOpt) string {
+		di, err := sif.NewDescriptorInput(dt, strings.NewReader("data"), opts...)
+		assert.NilError(t, err)
+		path := filepath.Join(dir, name)
+		f, err := sif.CreateContainerAtPath(path, sif.OptCreateWithDescriptors(di))
+		assert.NilError(t, err)
+		assert.NilError(t, f.UnloadContainer())
+		return path
+	}
+	rawSIF := createSIF("raw.sif", sif.DataPartition, sif.OptPartitionMetadata(sif.FsRaw, sif.PartPrimSys, "amd64"))
+	ociSIF := createSIF("oci.sif", sif.DataOCIRootIndex)
+
+	tests := []struct {
+		name    string
+		src     string
+		dst     string
+		opts    Options
+		wantErr string
+	}{
+		{
+			name:    "NoProfile",
+			src:     rawSIF,
+			dst:     filepath.Join(dir, "out.sif"),
+			wantErr: "at least one access profile is required",
+		},
+		{
+			name:    "Exists",
+			src:     rawSIF,
+			dst:     rawSIF,
+			opts:    Options{Profiles: []string{profile}},
+			wantErr: "image file already exists",
+		},
+		{
+			name:    "InvalidPattern",
+			src:     rawSIF,
+			dst:     filepath.Join(dir, "out.sif"),
+			opts:    Options{Profiles: []string{profile}, Keep: []string{"/usr/["}},
+			wantErr: "invalid keep pattern",
+		},
+		{
+			name:    "InvalidOCISIF",
+			src:     ociSIF,
+			dst:     filepath.Join(dir, "out.sif"),
+			opts:    Options{Profiles: []string{profile}},
+			wantErr: "while obtaining index manifest",
+		},
+		{
+			name:    "NotSquashfs",
+			src:     rawSIF,
+			dst:     filepath.Join(dir, "out.sif"),
+			opts:    Options{Profiles: []string{profile}},
+			wantErr: "is not an unencrypted squashfs filesystem",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Minimize(tt.src, tt.dst, tt.opts)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	require.Command(t, "mksquashfs")
+	require.Command(t, "unsquashfs")
+
+	dir := t.TempDir()
+	rootfs := filepath.Join(dir, "rootfs")
+	for _, f := range []string{"bin/app", "bin/unused", "etc/passwd"} {
+		writeFile(t, filepath.Join(rootfs, f), f)
+	}
+	sqfs := filepath.Join(dir, "rootfs.sqfs")
+	err := packer.NewSquashfs().Create([]string{rootfs}, sqfs, []string{"-noappend", "-all-root"})
+	assert.NilError(t, err)
+
+	nativeSIF := filepath.Join(dir, "native.sif")
+	r, err := os.Open(sqfs)
+	assert.NilError(t, err)
+	defer r.Close()
+	part, err := sif.NewDescriptorInput(sif.DataPartition, r,
+		sif.OptPartitionMetadata(sif.FsSquash, sif.PartPrimSys, "amd64"),
+	)
+	assert.NilError(t, err)
+	sig, err := sif.NewDescriptorInput(sif.DataSignature, strings.NewReader("signature"),
+		sif.OptLinkedID(1),
+	)
+	assert.NilError(t, err)
+	f, err := sif.CreateContainerAtPath(nativeSIF, sif.OptCreateWithDescriptors(part, sig))
+	assert.NilError(t, err)
+	assert.NilError(t, f.UnloadContainer())
+
+	ociSIF := filepath.Join(dir, "oci.sif")
+	err = ocisif.CreateImage(sqfs, ociSIF, ggcrv1.Platform{OS: "linux", Architecture: "amd64"}, ggcrv1.Config{}, time.Now(), "")
+	assert.NilError(t, err)
+
+	profile := filepath.Join(dir, "profile.txt")
+	writeFile(t, profile, "/bin/app\n")
+
+	tests := []struct {
+		name              string
+		src               string
+		droppedSignatures bool
+	}{
+		{name: "SIF", src: nativeSIF, droppedSignatures: true},
+		{name: "OCISIF", src: ociSIF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(dir, tt.name+".min.sif")
+			res, err := Minimize(tt.src, dst, Options{Profiles: []string{profile}, TmpDir: dir})
+			assert.NilError(t, err)
+			assert.Equal(t, res.Kept, 2)
+			assert.Equal(t, res.Removed, 1)
+			assert.Equal(t, res.DroppedSignatures, tt.droppedSignatures)
+
+			f, err := sif.LoadContainerFromPath(dst, sif.OptLoadWithFlag(os.O_RDONLY))
+			assert.NilError(t, err)
+			defer f.UnloadContainer()
+
+			var d sif.Descriptor
+			if tt.src == ociSIF {
+				// The manifest and config must describe the new layer.
+				mf, err := ocisif.ImageManifest(dst)
+				assert.NilError(t, err)
+				assert.Equal(t, len(mf.Layers), 1)
+				d, err = f.GetDescriptor(sif.WithOCIBlobDigest(mf.Layers[0].Digest))
+				assert.NilError(t, err)
+				spec, err := ocisif.ImageSpec(dst)
+				assert.NilError(t, err)
+				assert.Equal(t, spec.RootFS.DiffIDs[0].String(), mf.Layers[0].Digest.String())
+			} else {
+				d, err = f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+				assert.NilError(t, err)
+				_, err = f.GetDescriptor(sif.WithDataType(sif.DataSignature))
+				assert.ErrorIs(t, err, sif.ErrObjectNotFound)
+			}
+
+			out := filepath.Join(t.TempDir(), "rootfs")
+			assert.NilError(t, os.Mkdir(out, 0o755))
+			assert.NilError(t, unpacker.NewSquashfs().ExtractAll(d.GetReader(), out))
+			_, err = os.Stat(filepath.Join(out, "bin/app"))
+			assert.NilError(t, err)
+			_, err = os.Stat(filepath.Join(out, "etc/passwd"))
+			assert.NilError(t, err)
+			_, err = os.Stat(filepath.Join(out, "bin/unused"))
+			assert.Assert(t, os.IsNotExist(err), "bin/unused was not removed")
+		})
+	}
+}
+
+func TestSameImage(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "image.sif")
+	writeFile(t, image, "image")
+	other := filepath.Join(dir, "other.sif")
+	writeFile(t, other, "other")
+	link := filepath.Join(dir, "link.sif")
+	if err := os.Symlink(image, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		image string
+		want  bool
+	}{
+		{name: "Path", image: image, want: true},
+		{name: "SIF", image: "sif:" + image, want: true},
+		{name: "OCISIF", image: "oci-sif:" + image, want: true},
+		{name: "Symlink", image: "oci-sif:" + link, want: true},
+		{name: "Other", image: "oci-sif:" + other, want: false},
+		{name: "Missing", image: "oci-sif:" + filepath.Join(dir, "missing.sif"), want: false},
+		{name: "Remote", image: "docker://alpine", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, sameImage(tt.image, image), tt.want)
+		})
+	}
+}
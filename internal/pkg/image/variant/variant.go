@@ -0,0 +1,260 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package variant selects, from variants of a container image built for
+// different node hardware, the variant to run on the local node. This allows
+// a single job script to run across partitions with different CPU
+// architectures, or GPU architectures.
+//
+// Variants are listed in a TOML manifest file, or are the images in a
+// multi-image OCI-SIF. In both cases the first variant that matches the local
+// node is selected, so more specific variants should be listed first.
+package variant
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sylabs/singularity/v4/internal/pkg/ociplatform"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/uri"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// GPUAnnotation is the annotation, on the descriptor of an image in a
+// multi-image OCI-SIF, holding the GPU requirement of the image.
+const GPUAnnotation = "org.sylabs.singularity.variant.gpu"
+
+const (
+	// VendorNvidia identifies NVIDIA GPUs in a GPU requirement.
+	VendorNvidia = "nvidia"
+	// VendorAMD identifies AMD GPUs in a GPU requirement.
+	VendorAMD = "amd"
+	// NoGPU is the GPU requirement of a variant for nodes without a GPU.
+	NoGPU = "none"
+)
+
+// GPU describes a GPU on a node.
+type GPU struct {
+	// Vendor is VendorNvidia or VendorAMD.
+	Vendor string
+	// Arch is the GPU architecture, e.g. sm_80 or gfx90a, if known.
+	Arch string
+}
+
+// Node describes the hardware of a node on which a container is run.
+type Node struct {
+	// Arch is the OCI platform architecture of the node.
+	Arch string
+	// Variant is the OCI platform architecture variant of the node.
+	Variant string
+	// GPUs are the distinct GPUs of the node.
+	GPUs []GPU
+}
+
+// LocalNode returns a description of the local node. GPUs that cannot be
+// identified are skipped, with a warning.
+func LocalNode() (Node, error) {
+	p, err := ociplatform.DefaultPlatform()
+	if err != nil {
+		return Node{}, err
+	}
+	n := Node{Arch: p.Architecture, Variant: p.Variant}
+
+	nvArchs, err := gpu.NvidiaArchs()
+	if err != nil {
+		sylog.Warningf("Could not identify NVIDIA GPU architectures: %v", err)
+	}
+	for _, a := range nvArchs {
+		n.GPUs = append(n.GPUs, GPU{Vendor: VendorNvidia, Arch: a})
+	}
+	// Without nvidia-smi, NVIDIA GPUs are found, but their architecture is
+	// unknown.
+	if len(nvArchs) == 0 {
+		if gpus, err := gpu.NvidiaGPUs(); err == nil && len(gpus) > 0 {
+			n.GPUs = append(n.GPUs, GPU{Vendor: VendorNvidia})
+		}
+	}
+
+	amdArchs, err := gpu.RocmArchs()
+	if err != nil {
+		sylog.Warningf("Could not identify AMD GPU architectures: %v", err)
+	}
+	for _, a := range amdArchs {
+		n.GPUs = append(n.GPUs, GPU{Vendor: VendorAMD, Arch: a})
+	}
+
+	return n, nil
+}
+
+// String describes the node, for messages.
+func (n Node) String() string {
+	arch := n.Arch
+	if n.Variant != "" {
+		arch += "/" + n.Variant
+	}
+	if len(n.GPUs) == 0 {
+		return arch + " with no GPU"
+	}
+	gpus := make([]string, 0, len(n.GPUs))
+	for _, g := range n.GPUs {
+		gpus = append(gpus, g.String())
+	}
+	return arch + " with GPUs " + strings.Join(gpus, ", ")
+}
+
+// String returns the GPU in the form of a GPU requirement.
+func (g GPU) String() string {
+	if g.Arch == "" {
+		return g.Vendor
+	}
+	return g.Vendor + "/" + g.Arch
+}
+
+// checkGPU checks that req is a valid GPU requirement, which is empty, NoGPU,
+// a vendor, or <vendor>/<arch>.
+func checkGPU(req string) error {
+	if req == "" || req == NoGPU {
+		return nil
+	}
+	vendor, _, _ := strings.Cut(req, "/")
+	if vendor != VendorNvidia && vendor != VendorAMD {
+		return fmt.Errorf("invalid GPU requirement %q, vendor must be %s or %s", req, VendorNvidia, VendorAMD)
+	}
+	return nil
+}
+
+// MatchesArch returns true if the node satisfies arch, which is empty, or an
+// architecture with an optional variant, e.g. arm64/v8.
+func (n Node) MatchesArch(arch string) bool {
+	if arch == "" {
+		return true
+	}
+	a, v, _ := strings.Cut(arch, "/")
+	return a == n.Arch && (v == "" || v == n.Variant)
+}
+
+// MatchesGPU returns true if the node satisfies the GPU requirement req.
+func (n Node) MatchesGPU(req string) bool {
+	switch req {
+	case "":
+		return true
+	case NoGPU:
+		return len(n.GPUs) == 0
+	}
+	vendor, arch, _ := strings.Cut(req, "/")
+	for _, g := range n.GPUs {
+		if g.Vendor == vendor && (arch == "" || g.Arch == arch) {
+			return true
+		}
+	}
+	return false
+}
+
+// Variant is a variant of an image, listed in a manifest.
+type Variant struct {
+	// Image is the image path or URI. A relative path is relative to the
+	// directory holding the manifest.
+	Image string `toml:"image"`
+	// Arch is the required architecture, with an optional variant, e.g.
+	// amd64 or arm64/v8.
+	Arch string `toml:"arch"`
+	// GPU is the required GPU, a vendor or <vendor>/<arch>, e.g. nvidia or
+	// nvidia/sm_80, or NoGPU for nodes without a GPU.
+	GPU string `toml:"gpu"`
+}
+
+// Manifest lists the variants of an image.
+type Manifest struct {
+	Variants []Variant `toml:"variant"`
+}
+
+// LoadManifest loads and checks the TOML manifest at path. Relative image
+// paths are made relative to the directory holding the manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := toml.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("while parsing variants manifest %s: %w", path, err)
+	}
+	if len(m.Variants) == 0 {
+		return nil, fmt.Errorf("variants manifest %s lists no variants", path)
+	}
+
+	dir := filepath.Dir(path)
+	for i, v := range m.Variants {
+		if v.Image == "" {
+			return nil, fmt.Errorf("variant %d in %s has no image", i+1, path)
+		}
+		if err := checkGPU(v.GPU); err != nil {
+			return nil, fmt.Errorf("variant %d in %s: %w", i+1, path, err)
+		}
+		if t, _ := uri.Split(v.Image); t == "" && !filepath.IsAbs(v.Image) {
+			m.Variants[i].Image = filepath.Join(dir, v.Image)
+		}
+	}
+	return m, nil
+}
+
+// Select returns the first variant in the manifest that matches n.
+func (m *Manifest) Select(n Node) (Variant, error) {
+	for _, v := range m.Variants {
+		if n.MatchesArch(v.Arch) && n.MatchesGPU(v.GPU) {
+			return v, nil
+		}
+	}
+	return Variant{}, fmt.Errorf("no image variant matches this node (%s)", n)
+}
+
+// SelectImage returns the image, from the variants manifest at path, to run
+// on the local node.
+func SelectImage(path string) (string, error) {
+	m, err := LoadManifest(path)
+	if err != nil {
+		return "", err
+	}
+	n, err := LocalNode()
+	if err != nil {
+		return "", err
+	}
+	v, err := m.Select(n)
+	if err != nil {
+		return "", err
+	}
+	sylog.Infof("Selected image variant %s for this node (%s)", v.Image, n)
+	return v.Image, nil
+}
+
+// SelectDescriptor returns the first descriptor in descs, which describe the
+// images in a multi-image OCI-SIF, that matches n. An image matches by its
+// platform, if set, and the GPU requirement in its GPUAnnotation, if set.
+func SelectDescriptor(descs []ggcrv1.Descriptor, n Node) (ggcrv1.Descriptor, error) {
+	for _, d := range descs {
+		if p := d.Platform; p != nil {
+			if p.OS != "" && p.OS != "linux" {
+				continue
+			}
+			arch := p.Architecture
+			if p.Variant != "" {
+				arch += "/" + p.Variant
+			}
+			if !n.MatchesArch(arch) {
+				continue
+			}
+		}
+		if !n.MatchesGPU(d.Annotations[GPUAnnotation]) {
+			continue
+		}
+		return d, nil
+	}
+	return ggcrv1.Descriptor{}, fmt.Errorf("no image in the OCI-SIF matches this node (%s)", n)
+}
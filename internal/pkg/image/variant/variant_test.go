@@ -0,0 +1,171 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package variant
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const testManifest = `
+[[variant]]
+image = "app_sm80.sif"
+arch = "amd64"
+gpu = "nvidia/sm_80"
+
+[[variant]]
+image = "app_rocm.sif"
+arch = "amd64"
+gpu = "amd"
+
+[[variant]]
+image = "/images/app_arm64.sif"
+arch = "arm64/v8"
+
+[[variant]]
+image = "docker://example/app:cpu"
+gpu = "none"
+`
+
+func TestManifestSelect(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "variants.toml")
+	if err := os.WriteFile(path, []byte(testManifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		node    Node
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "NvidiaArch",
+			node: Node{Arch: "amd64", GPUs: []GPU{{Vendor: VendorNvidia, Arch: "sm_80"}}},
+			want: filepath.Join(dir, "app_sm80.sif"),
+		},
+		{
+			name: "AMD",
+			node: Node{Arch: "amd64", GPUs: []GPU{{Vendor: VendorAMD, Arch: "gfx90a"}}},
+			want: filepath.Join(dir, "app_rocm.sif"),
+		},
+		{
+			name: "ArchVariant",
+			node: Node{Arch: "arm64", Variant: "v8", GPUs: []GPU{{Vendor: VendorNvidia, Arch: "sm_90"}}},
+			want: "/images/app_arm64.sif",
+		},
+		{
+			name: "NoGPU",
+			node: Node{Arch: "amd64"},
+			want: "docker://example/app:cpu",
+		},
+		{
+			name:    "NoMatch",
+			node:    Node{Arch: "amd64", GPUs: []GPU{{Vendor: VendorNvidia, Arch: "sm_70"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Select(tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Image != tt.want {
+				t.Errorf("Select() = %q, want %q", got.Image, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadManifestInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{name: "Empty", manifest: ""},
+		{name: "NoImage", manifest: "[[variant]]\narch = \"amd64\"\n"},
+		{name: "BadGPU", manifest: "[[variant]]\nimage = \"a.sif\"\ngpu = \"intel\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "variants.toml")
+			if err := os.WriteFile(path, []byte(tt.manifest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadManifest(path); err == nil {
+				t.Errorf("LoadManifest() expected error")
+			}
+		})
+	}
+}
+
+func TestSelectDescriptor(t *testing.T) {
+	amd64 := ggcrv1.Descriptor{
+		Digest:   ggcrv1.Hash{Algorithm: "sha256", Hex: "01"},
+		Platform: &ggcrv1.Platform{OS: "linux", Architecture: "amd64"},
+	}
+	amd64GPU := ggcrv1.Descriptor{
+		Digest:      ggcrv1.Hash{Algorithm: "sha256", Hex: "02"},
+		Platform:    &ggcrv1.Platform{OS: "linux", Architecture: "amd64"},
+		Annotations: map[string]string{GPUAnnotation: "nvidia"},
+	}
+	arm64 := ggcrv1.Descriptor{
+		Digest:   ggcrv1.Hash{Algorithm: "sha256", Hex: "03"},
+		Platform: &ggcrv1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+	}
+	descs := []ggcrv1.Descriptor{amd64GPU, amd64, arm64}
+
+	tests := []struct {
+		name    string
+		node    Node
+		want    ggcrv1.Hash
+		wantErr bool
+	}{
+		{
+			name: "GPU",
+			node: Node{Arch: "amd64", GPUs: []GPU{{Vendor: VendorNvidia}}},
+			want: amd64GPU.Digest,
+		},
+		{
+			name: "NoGPU",
+			node: Node{Arch: "amd64"},
+			want: amd64.Digest,
+		},
+		{
+			name: "Arm64",
+			node: Node{Arch: "arm64", Variant: "v8"},
+			want: arm64.Digest,
+		},
+		{
+			name:    "NoMatch",
+			node:    Node{Arch: "ppc64le"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectDescriptor(descs, tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectDescriptor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Digest != tt.want {
+				t.Errorf("SelectDescriptor() = %v, want %v", got.Digest, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,709 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package imagestore provides a local store of SIF and OCI-SIF images, named
+// by references. Images are held in blobs, stored once by digest however
+// many images use them: a native SIF image is a single blob, and an OCI-SIF
+// image is stored as the blobs of its manifest, config, and layers, so that
+// images share their common layers.
+package imagestore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/opencontainers/go-digest"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/pkg/syfs"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/fs/lock"
+)
+
+const (
+	// DirEnv specifies the environment variable which can set the directory
+	// of the image store.
+	DirEnv = "SINGULARITY_IMAGESDIR"
+	// SubDirName is the name of the image store directory in the singularity
+	// user configuration directory, used when DirEnv is not set.
+	SubDirName = "images"
+
+	// TypeSIF is the type of native SIF images.
+	TypeSIF = "sif"
+	// TypeOCISIF is the type of OCI-SIF images.
+	TypeOCISIF = "oci-sif"
+
+	defaultTag = "latest"
+	refsFile   = "refs.json"
+	lockFile   = "lock"
+	tmpPrefix  = ".tmp-"
+	// staleTmpAge is the age from which Prune removes temporary files left
+	// by interrupted imports.
+	staleTmpAge = time.Hour
+)
+
+// ErrNotFound is returned for references that are not in the store.
+var ErrNotFound = errors.New("no such image in store")
+
+var (
+	nameRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
+	tagRegexp  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+)
+
+// Image is an image reference held in the store.
+type Image struct {
+	// Ref is the reference of the image, as name:tag.
+	Ref string `json:"ref" yaml:"ref"`
+	// Digest is the digest of the image manifest for images stored by layer,
+	// or of the image file otherwise.
+	Digest digest.Digest `json:"digest" yaml:"digest"`
+	// Type is TypeSIF or TypeOCISIF.
+	Type string `json:"type" yaml:"type"`
+	// Layered is set for OCI-SIF images stored as the blobs of their
+	// manifest, config, and layers, rather than as a single image file.
+	Layered bool `json:"layered,omitempty" yaml:"layered,omitempty"`
+	// Size is the total size of the blobs of the image, in bytes.
+	Size int64 `json:"size" yaml:"size"`
+	// Added is the time the reference was added to the store.
+	Added time.Time `json:"added" yaml:"added"`
+	// Path is the location of the image file in the store, for images not
+	// stored by layer. Images stored by layer are assembled into an image
+	// file when they are retrieved.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// refs is the content of the references file of the store.
+type refs struct {
+	Images map[string]Image `json:"images"`
+}
+
+// Store is a local image store in a directory.
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns the directory of the image store of the current user.
+func DefaultDir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join(syfs.ConfigDir(), SubDirName)
+}
+
+// Open returns the image store in dir, creating it if needed.
+func Open(dir string) (*Store, error) {
+	s := &Store{dir: dir}
+	if err := os.MkdirAll(s.blobDir(), 0o755); err != nil {
+		return nil, fmt.Errorf("while creating image store: %w", err)
+	}
+	return s, nil
+}
+
+// ParseRef returns the reference ref in its canonical name:tag form, with
+// the latest tag if ref has none.
+func ParseRef(ref string) (string, error) {
+	name, tag := ref, defaultTag
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, tag = ref[:i], ref[i+1:]
+	}
+	if !nameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid image name %q: must be lowercase alphanumeric components separated by '/', '.', '_' or '-'", name)
+	}
+	if !tagRegexp.MatchString(tag) {
+		return "", fmt.Errorf("invalid image tag %q", tag)
+	}
+	return name + ":" + tag, nil
+}
+
+// Import copies the SIF or OCI-SIF image at path into the store, and names it
+// ref. An OCI-SIF image holding a single image, and nothing else, is stored by
+// layer, sharing the blobs already in the store. Other images are stored as a
+// single blob, shared with the images having the same content.
+func (s *Store) Import(path, ref string) (Image, error) {
+	ref, err := ParseRef(ref)
+	if err != nil {
+		return Image{}, err
+	}
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return Image{}, fmt.Errorf("%s is not a SIF image: %w", path, err)
+	}
+	defer f.UnloadContainer()
+
+	img := Image{Ref: ref, Type: TypeSIF, Added: time.Now().UTC()}
+	if _, err := f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex)); err == nil {
+		img.Type = TypeOCISIF
+	}
+
+	err = s.update(func(r *refs) error {
+		var err error
+		if ociImg := layeredImage(f); ociImg != nil {
+			img.Layered = true
+			img.Digest, img.Size, err = s.storeImage(ociImg)
+		} else {
+			img.Digest, img.Size, err = s.storeFile(path)
+		}
+		if err != nil {
+			return err
+		}
+		r.Images[ref] = img
+		return nil
+	})
+	if err != nil {
+		return Image{}, err
+	}
+	return s.withPath(img), nil
+}
+
+// Tag names the image referenced by src with the reference dst.
+func (s *Store) Tag(src, dst string) (Image, error) {
+	src, err := ParseRef(src)
+	if err != nil {
+		return Image{}, err
+	}
+	dst, err = ParseRef(dst)
+	if err != nil {
+		return Image{}, err
+	}
+
+	var img Image
+	err = s.update(func(r *refs) error {
+		var ok bool
+		img, ok = r.Images[src]
+		if !ok {
+			return fmt.Errorf("%s: %w", src, ErrNotFound)
+		}
+		img.Ref = dst
+		img.Added = time.Now().UTC()
+		r.Images[dst] = img
+		return nil
+	})
+	if err != nil {
+		return Image{}, err
+	}
+	return s.withPath(img), nil
+}
+
+// Remove removes the reference ref from the store. The blobs of the image
+// that no other image uses are deleted, and their total size is returned.
+func (s *Store) Remove(ref string) (int64, error) {
+	ref, err := ParseRef(ref)
+	if err != nil {
+		return 0, err
+	}
+
+	var freed int64
+	err = s.update(func(r *refs) error {
+		img, ok := r.Images[ref]
+		if !ok {
+			return fmt.Errorf("%s: %w", ref, ErrNotFound)
+		}
+		delete(r.Images, ref)
+
+		used, err := s.usedBlobs(r)
+		if err != nil {
+			return err
+		}
+		blobs, err := s.imageBlobs(img)
+		if err != nil {
+			return err
+		}
+		for _, b := range blobs {
+			if used[b] {
+				continue
+			}
+			fi, err := os.Stat(s.blobPath(b))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := os.Remove(s.blobPath(b)); err != nil {
+				return fmt.Errorf("while removing image: %w", err)
+			}
+			used[b] = true
+			freed += fi.Size()
+		}
+		return nil
+	})
+	return freed, err
+}
+
+// Prune deletes the blobs no image uses, and the temporary files left by
+// interrupted imports. It returns the number of deleted files and their
+// total size.
+func (s *Store) Prune() (int, int64, error) {
+	var (
+		count int
+		freed int64
+	)
+	err := s.update(func(r *refs) error {
+		used, err := s.usedBlobs(r)
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(s.blobDir())
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fi, err := e.Info()
+			if err != nil {
+				return err
+			}
+			name := e.Name()
+			if strings.HasPrefix(name, tmpPrefix) {
+				// an import may still be writing to the file
+				if time.Since(fi.ModTime()) < staleTmpAge {
+					continue
+				}
+			} else if used[digest.NewDigestFromEncoded(digest.SHA256, name)] {
+				continue
+			}
+			sylog.Debugf("Removing unused blob %s", name)
+			if err := os.Remove(filepath.Join(s.blobDir(), name)); err != nil {
+				return err
+			}
+			count++
+			freed += fi.Size()
+		}
+		return nil
+	})
+	return count, freed, err
+}
+
+// Get returns the image referenced by ref.
+func (s *Store) Get(ref string) (Image, error) {
+	ref, err := ParseRef(ref)
+	if err != nil {
+		return Image{}, err
+	}
+	r, err := s.readRefs()
+	if err != nil {
+		return Image{}, err
+	}
+	img, ok := r.Images[ref]
+	if !ok {
+		return Image{}, fmt.Errorf("%s: %w", ref, ErrNotFound)
+	}
+	return s.withPath(img), nil
+}
+
+// List returns the images of the store, ordered by reference.
+func (s *Store) List() ([]Image, error) {
+	r, err := s.readRefs()
+	if err != nil {
+		return nil, err
+	}
+	images := make([]Image, 0, len(r.Images))
+	for _, img := range r.Images {
+		images = append(images, s.withPath(img))
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Ref < images[j].Ref })
+	return images, nil
+}
+
+// DiskUsage returns the total size of the blobs of the store.
+func (s *Store) DiskUsage() (int64, error) {
+	entries, err := os.ReadDir(s.blobDir())
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), tmpPrefix) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return 0, err
+		}
+		size += fi.Size()
+	}
+	return size, nil
+}
+
+// WriteImage writes the image img of the store to an image file at dst. An
+// image stored as a single blob is copied, and an image stored by layer is
+// assembled into an OCI-SIF image.
+func (s *Store) WriteImage(img Image, dst string) error {
+	if !img.Layered {
+		return copyFile(s.blobPath(img.Digest), dst)
+	}
+	ociImg, err := s.storedImage(img)
+	if err != nil {
+		return fmt.Errorf("while reading image %s from store: %w", img.Ref, err)
+	}
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: ociImg})
+	if err := ocisif.Write(dst, ii); err != nil {
+		return fmt.Errorf("while writing image %s: %w", img.Ref, err)
+	}
+	return nil
+}
+
+func (s *Store) blobDir() string {
+	return filepath.Join(s.dir, "blobs", digest.SHA256.String())
+}
+
+func (s *Store) blobPath(d digest.Digest) string {
+	return filepath.Join(s.blobDir(), d.Encoded())
+}
+
+// withPath returns img with its path set, if it is stored as a single blob.
+func (s *Store) withPath(img Image) Image {
+	img.Path = ""
+	if !img.Layered {
+		img.Path = s.blobPath(img.Digest)
+	}
+	return img
+}
+
+// layeredImage returns the single image held by the OCI-SIF f, or nil if f
+// holds anything else, such as other images or SIF data objects, that can't
+// be stored by layer.
+func layeredImage(f *sif.FileImage) ggcrv1.Image {
+	descrs, err := f.GetDescriptors()
+	if err != nil {
+		return nil
+	}
+	for _, d := range descrs {
+		if t := d.DataType(); t != sif.DataOCIRootIndex && t != sif.DataOCIBlob {
+			return nil
+		}
+	}
+	ix, err := ocisif.ImageIndexFromFileImage(f)
+	if err != nil {
+		return nil
+	}
+	m, err := ix.IndexManifest()
+	if err != nil || len(m.Manifests) != 1 || !m.Manifests[0].MediaType.IsImage() {
+		return nil
+	}
+	img, err := ix.Image(m.Manifests[0].Digest)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// storeImage stores the blobs of img that are not already in the store, and
+// returns the digest of its manifest, and the total size of its blobs.
+func (s *Store) storeImage(img ggcrv1.Image) (digest.Digest, int64, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return "", 0, err
+	}
+	var size int64
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			return "", 0, err
+		}
+		n, err := s.storeBlob(digest.Digest(d.String()), l.Compressed)
+		if err != nil {
+			return "", 0, fmt.Errorf("while storing layer %s: %w", d, err)
+		}
+		size += n
+	}
+
+	config, err := img.RawConfigFile()
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := s.storeBytes(config)
+	if err != nil {
+		return "", 0, fmt.Errorf("while storing config: %w", err)
+	}
+	size += n
+
+	manifest, err := img.RawManifest()
+	if err != nil {
+		return "", 0, err
+	}
+	n, err = s.storeBytes(manifest)
+	if err != nil {
+		return "", 0, fmt.Errorf("while storing manifest: %w", err)
+	}
+	size += n
+
+	return digest.FromBytes(manifest), size, nil
+}
+
+// storeBytes stores data as a blob, unless the store already holds it, and
+// returns its size.
+func (s *Store) storeBytes(data []byte) (int64, error) {
+	return s.storeBlob(digest.FromBytes(data), func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// storeFile stores the file at path as a single blob, and returns its digest
+// and size.
+func (s *Store) storeFile(path string) (digest.Digest, int64, error) {
+	tmp, d, size, err := s.copyBlob(func() (io.ReadCloser, error) { return os.Open(path) })
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp)
+	if _, err := os.Stat(s.blobPath(d)); err == nil {
+		sylog.Debugf("Image %s already in store, sharing it", d)
+		return d, size, nil
+	}
+	if err := os.Rename(tmp, s.blobPath(d)); err != nil {
+		return "", 0, fmt.Errorf("while storing image: %w", err)
+	}
+	return d, size, nil
+}
+
+// storeBlob stores the content opened by open as the blob d, unless the store
+// already holds it, and returns its size.
+func (s *Store) storeBlob(d digest.Digest, open func() (io.ReadCloser, error)) (int64, error) {
+	if fi, err := os.Stat(s.blobPath(d)); err == nil {
+		sylog.Debugf("Blob %s already in store, sharing it", d)
+		return fi.Size(), nil
+	}
+	tmp, got, size, err := s.copyBlob(open)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+	if got != d {
+		return 0, fmt.Errorf("digest mismatch: expected %s, got %s", d, got)
+	}
+	if err := os.Rename(tmp, s.blobPath(d)); err != nil {
+		return 0, fmt.Errorf("while storing blob: %w", err)
+	}
+	return size, nil
+}
+
+// copyBlob copies the content opened by open to a temporary file in the
+// store, and returns its path, along with the digest and size of the content.
+func (s *Store) copyBlob(open func() (io.ReadCloser, error)) (string, digest.Digest, int64, error) {
+	in, err := open()
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(s.blobDir(), tmpPrefix)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("while creating blob in store: %w", err)
+	}
+	defer out.Close()
+
+	digester := digest.SHA256.Digester()
+	size, err := io.Copy(io.MultiWriter(out, digester.Hash()), in)
+	if err == nil {
+		err = out.Chmod(0o444)
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", "", 0, fmt.Errorf("while copying to store: %w", err)
+	}
+	return out.Name(), digester.Digest(), size, nil
+}
+
+// imageBlobs returns the digests of the blobs of img.
+func (s *Store) imageBlobs(img Image) ([]digest.Digest, error) {
+	if !img.Layered {
+		return []digest.Digest{img.Digest}, nil
+	}
+	data, err := os.ReadFile(s.blobPath(img.Digest))
+	if os.IsNotExist(err) {
+		// the image is already gone, along with its blobs
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("while reading manifest of %s: %w", img.Ref, err)
+	}
+	m, err := ggcrv1.ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("while reading manifest of %s: %w", img.Ref, err)
+	}
+	blobs := []digest.Digest{img.Digest, digest.Digest(m.Config.Digest.String())}
+	for _, l := range m.Layers {
+		blobs = append(blobs, digest.Digest(l.Digest.String()))
+	}
+	return blobs, nil
+}
+
+// storedImage returns the image img, stored by layer, read from the blobs of
+// the store.
+func (s *Store) storedImage(img Image) (ggcrv1.Image, error) {
+	manifest, err := os.ReadFile(s.blobPath(img.Digest))
+	if err != nil {
+		return nil, err
+	}
+	m, err := ggcrv1.ParseManifest(bytes.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+	return partial.CompressedToImage(&blobImage{s: s, manifest: manifest, m: m})
+}
+
+// blobImage is an image stored by layer in a store, implementing
+// partial.CompressedImageCore.
+type blobImage struct {
+	s        *Store
+	manifest []byte
+	m        *ggcrv1.Manifest
+}
+
+func (i *blobImage) RawManifest() ([]byte, error) {
+	return i.manifest, nil
+}
+
+func (i *blobImage) MediaType() (types.MediaType, error) {
+	return i.m.MediaType, nil
+}
+
+func (i *blobImage) RawConfigFile() ([]byte, error) {
+	return os.ReadFile(i.s.blobPath(digest.Digest(i.m.Config.Digest.String())))
+}
+
+func (i *blobImage) LayerByDigest(h ggcrv1.Hash) (partial.CompressedLayer, error) {
+	for _, l := range i.m.Layers {
+		if l.Digest == h {
+			return &blobLayer{s: i.s, desc: l}, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %s not found in image", h)
+}
+
+// blobLayer is a layer stored as a blob in a store, implementing
+// partial.CompressedLayer.
+type blobLayer struct {
+	s    *Store
+	desc ggcrv1.Descriptor
+}
+
+func (l *blobLayer) Digest() (ggcrv1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *blobLayer) Compressed() (io.ReadCloser, error) {
+	return os.Open(l.s.blobPath(digest.Digest(l.desc.Digest.String())))
+}
+
+func (l *blobLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+func (l *blobLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
+
+// usedBlobs returns the set of blobs used by the images of r.
+func (s *Store) usedBlobs(r *refs) (map[digest.Digest]bool, error) {
+	used := make(map[digest.Digest]bool)
+	for _, img := range r.Images {
+		blobs, err := s.imageBlobs(img)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range blobs {
+			used[b] = true
+		}
+	}
+	return used, nil
+}
+
+// update applies fn to the references of the store, with the store locked,
+// and writes them back if fn succeeds.
+func (s *Store) update(fn func(*refs) error) error {
+	lockPath := filepath.Join(s.dir, lockFile)
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("while locking image store: %w", err)
+	}
+	f.Close()
+	fd, err := lock.Exclusive(lockPath)
+	if err != nil {
+		return fmt.Errorf("while locking image store: %w", err)
+	}
+	defer lock.Release(fd)
+
+	r, err := s.readRefs()
+	if err != nil {
+		return err
+	}
+	if err := fn(r); err != nil {
+		return err
+	}
+	return s.writeRefs(r)
+}
+
+func (s *Store) readRefs() (*refs, error) {
+	r := &refs{Images: make(map[string]Image)}
+	data, err := os.ReadFile(filepath.Join(s.dir, refsFile))
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("while reading image store references: %w", err)
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("while reading image store references: %w", err)
+	}
+	if r.Images == nil {
+		r.Images = make(map[string]Image)
+	}
+	return r, nil
+}
+
+// writeRefs replaces the references file of the store atomically.
+func (s *Store) writeRefs(r *refs) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, tmpPrefix+refsFile)
+	if err != nil {
+		return fmt.Errorf("while writing image store references: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("while writing image store references: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("while writing image store references: %w", err)
+	}
+	return os.Rename(f.Name(), filepath.Join(s.dir, refsFile))
+}
+
+// copyFile copies the file at src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imagestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/opencontainers/go-digest"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"gotest.tools/v3/assert"
+)
+
+const (
+	testSIF    = "../../../test/images/empty.sif"
+	testOCISIF = "../../../test/images/empty.oci.sif"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "alpine", want: "alpine:latest"},
+		{ref: "alpine:3.18", want: "alpine:3.18"},
+		{ref: "lab/tools/python:3.11-conda", want: "lab/tools/python:3.11-conda"},
+		{ref: "Alpine", wantErr: true},
+		{ref: "alpine:", wantErr: true},
+		{ref: "/alpine", wantErr: true},
+		{ref: "alpine:-x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseRef(tt.ref)
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	s, err := Open(t.TempDir())
+	assert.NilError(t, err)
+
+	// the same image imported twice is stored once
+	a, err := s.Import(testSIF, "a")
+	assert.NilError(t, err)
+	assert.Equal(t, a.Type, TypeSIF)
+	b, err := s.Import(testSIF, "b:1.0")
+	assert.NilError(t, err)
+	assert.Equal(t, a.Path, b.Path)
+	oci, err := s.Import(testOCISIF, "oci")
+	assert.NilError(t, err)
+	assert.Equal(t, oci.Type, TypeOCISIF)
+
+	c, err := s.Tag("b:1.0", "c")
+	assert.NilError(t, err)
+	assert.Equal(t, c.Digest, a.Digest)
+
+	images, err := s.List()
+	assert.NilError(t, err)
+	var refs []string
+	for _, img := range images {
+		refs = append(refs, img.Ref)
+	}
+	assert.DeepEqual(t, refs, []string{"a:latest", "b:1.0", "c:latest", "oci:latest"})
+
+	// a shared image file is kept until its last reference is removed
+	for _, ref := range []string{"a", "b:1.0"} {
+		freed, err := s.Remove(ref)
+		assert.NilError(t, err)
+		assert.Equal(t, freed, int64(0))
+		_, err = os.Stat(a.Path)
+		assert.NilError(t, err)
+	}
+	freed, err := s.Remove("c")
+	assert.NilError(t, err)
+	assert.Equal(t, freed, a.Size)
+	_, err = os.Stat(a.Path)
+	assert.Assert(t, os.IsNotExist(err))
+
+	_, err = s.Get("a")
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+	_, err = s.Remove("a")
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+	_, err = s.Tag("a", "d")
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+
+	got, err := s.Get("oci")
+	assert.NilError(t, err)
+	assert.Equal(t, got.Path, oci.Path)
+}
+
+// writeOCISIF writes an OCI-SIF image holding img to a temporary file, and
+// returns its path.
+func writeOCISIF(t *testing.T, img ggcrv1.Image) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "image.oci.sif")
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+	assert.NilError(t, ocisif.Write(path, ii))
+	return path
+}
+
+// layerDigests returns the digests of the layers of img.
+func layerDigests(t *testing.T, img ggcrv1.Image) []digest.Digest {
+	t.Helper()
+	layers, err := img.Layers()
+	assert.NilError(t, err)
+	var ds []digest.Digest
+	for _, l := range layers {
+		d, err := l.Digest()
+		assert.NilError(t, err)
+		ds = append(ds, digest.Digest(d.String()))
+	}
+	return ds
+}
+
+func TestStoreLayered(t *testing.T) {
+	s, err := Open(t.TempDir())
+	assert.NilError(t, err)
+
+	base, err := random.Layer(1024, types.OCILayer)
+	assert.NilError(t, err)
+	extraA, err := random.Layer(1024, types.OCILayer)
+	assert.NilError(t, err)
+	extraB, err := random.Layer(1024, types.OCILayer)
+	assert.NilError(t, err)
+
+	oci := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	imgA, err := mutate.AppendLayers(oci, base, extraA)
+	assert.NilError(t, err)
+	imgB, err := mutate.AppendLayers(oci, base, extraB)
+	assert.NilError(t, err)
+
+	a, err := s.Import(writeOCISIF(t, imgA), "a")
+	assert.NilError(t, err)
+	assert.Equal(t, a.Type, TypeOCISIF)
+	assert.Assert(t, a.Layered)
+	assert.Equal(t, a.Path, "")
+	b, err := s.Import(writeOCISIF(t, imgB), "b")
+	assert.NilError(t, err)
+	assert.Assert(t, a.Digest != b.Digest)
+
+	// the base layer is stored once, and shared by both images
+	dsA, dsB := layerDigests(t, imgA), layerDigests(t, imgB)
+	assert.Equal(t, dsA[0], dsB[0])
+	usage, err := s.DiskUsage()
+	assert.NilError(t, err)
+	assert.Assert(t, usage < a.Size+b.Size)
+
+	// an image stored by layer is written back with the same manifest
+	dst := filepath.Join(t.TempDir(), "a.oci.sif")
+	assert.NilError(t, s.WriteImage(a, dst))
+	check, err := s.Import(dst, "check")
+	assert.NilError(t, err)
+	assert.Equal(t, check.Digest, a.Digest)
+	_, err = s.Remove("check")
+	assert.NilError(t, err)
+
+	// removing an image keeps the blobs still used by another image
+	freed, err := s.Remove("a")
+	assert.NilError(t, err)
+	assert.Assert(t, freed > 0)
+	_, err = os.Stat(s.blobPath(dsA[0]))
+	assert.NilError(t, err)
+	_, err = os.Stat(s.blobPath(dsA[1]))
+	assert.Assert(t, os.IsNotExist(err))
+	_, err = os.Stat(s.blobPath(a.Digest))
+	assert.Assert(t, os.IsNotExist(err))
+
+	_, err = s.Remove("b")
+	assert.NilError(t, err)
+	_, err = os.Stat(s.blobPath(dsA[0]))
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestImportInvalid(t *testing.T) {
+	s, err := Open(t.TempDir())
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "image")
+	assert.NilError(t, os.WriteFile(path, []byte("not an image"), 0o644))
+	_, err = s.Import(path, "invalid")
+	assert.ErrorContains(t, err, "is not a SIF image")
+
+	images, err := s.List()
+	assert.NilError(t, err)
+	assert.Equal(t, len(images), 0)
+}
+
+func TestPrune(t *testing.T) {
+	s, err := Open(t.TempDir())
+	assert.NilError(t, err)
+
+	img, err := s.Import(testSIF, "a")
+	assert.NilError(t, err)
+	layer, err := random.Layer(1024, types.OCILayer)
+	assert.NilError(t, err)
+	oci, err := mutate.AppendLayers(empty.Image, layer)
+	assert.NilError(t, err)
+	layered, err := s.Import(writeOCISIF(t, oci), "layered")
+	assert.NilError(t, err)
+
+	orphan := filepath.Join(s.blobDir(), "0000000000000000000000000000000000000000000000000000000000000000")
+	assert.NilError(t, os.WriteFile(orphan, []byte("orphan"), 0o444))
+	staleTmp := filepath.Join(s.blobDir(), tmpPrefix+"stale")
+	assert.NilError(t, os.WriteFile(staleTmp, []byte("stale"), 0o644))
+	old := time.Now().Add(-2 * staleTmpAge)
+	assert.NilError(t, os.Chtimes(staleTmp, old, old))
+	// an import in progress is left alone
+	activeTmp := filepath.Join(s.blobDir(), tmpPrefix+"active")
+	assert.NilError(t, os.WriteFile(activeTmp, []byte("active"), 0o644))
+
+	count, freed, err := s.Prune()
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+	assert.Equal(t, freed, int64(len("orphan")+len("stale")))
+
+	// the blobs of an image stored by layer are all in use
+	kept := []string{img.Path, activeTmp, s.blobPath(layered.Digest)}
+	for _, d := range layerDigests(t, oci) {
+		kept = append(kept, s.blobPath(d))
+	}
+	for _, path := range kept {
+		_, err := os.Stat(path)
+		assert.NilError(t, err)
+	}
+}
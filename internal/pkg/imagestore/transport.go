@@ -0,0 +1,136 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imagestore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// Scheme is the URI scheme of the images of the image store of the current
+// user, as store://name:tag.
+const Scheme = "store"
+
+func init() {
+	transport.Register(Transport{}, Scheme)
+}
+
+// Transport is the transport for store:// URIs, which retrieves images from,
+// and adds images to, the image store of the current user.
+type Transport struct{}
+
+// parseURI returns the reference of the store:// URI ref, in its canonical
+// name:tag form.
+func parseURI(ref string) (string, error) {
+	r := strings.TrimPrefix(ref, Scheme+":")
+	r = strings.TrimPrefix(r, "//")
+	return ParseRef(r)
+}
+
+// Resolve returns the canonical form of ref.
+func (Transport) Resolve(_ context.Context, ref string, _ transport.Options) (string, error) {
+	r, err := parseURI(ref)
+	if err != nil {
+		return "", err
+	}
+	return Scheme + "://" + r, nil
+}
+
+// Fetch writes the image at ref to dst, if set, and returns its path. Without
+// dst, an image stored as a single file is used in place, and an image stored
+// by layer is assembled into an OCI-SIF image in the cache.
+func (Transport) Fetch(_ context.Context, imgCache *cache.Handle, ref, dst string, opts transport.Options) (string, error) {
+	img, err := get(ref)
+	if err != nil {
+		return "", err
+	}
+	s, err := Open(DefaultDir())
+	if err != nil {
+		return "", err
+	}
+
+	if dst != "" {
+		return dst, s.WriteImage(img, dst)
+	}
+	if !img.Layered {
+		return img.Path, nil
+	}
+
+	if imgCache.IsDisabled() {
+		f, err := os.CreateTemp(opts.TmpDir, "sbuild-tmp-cache-")
+		if err != nil {
+			return "", fmt.Errorf("unable to create tmp file: %v", err)
+		}
+		f.Close()
+		sylog.Infof("Writing image %s to tmp cache: %s", img.Ref, f.Name())
+		return f.Name(), s.WriteImage(img, f.Name())
+	}
+
+	entry, err := imgCache.GetEntry(cache.OciSifCacheType, img.Digest.String())
+	if err != nil {
+		return "", fmt.Errorf("unable to check if %v exists in cache: %v", img.Digest, err)
+	}
+	defer entry.CleanTmp()
+	if !entry.Exists {
+		sylog.Infof("Assembling OCI-SIF image %s from the image store", img.Ref)
+		if err := s.WriteImage(img, entry.TmpPath); err != nil {
+			return "", err
+		}
+		if err := entry.Finalize(); err != nil {
+			return "", err
+		}
+	} else {
+		sylog.Infof("Using cached OCI-SIF image")
+	}
+	return entry.Path, nil
+}
+
+// Push adds the image at src to the image store, named as ref.
+func (Transport) Push(_ context.Context, src, ref string, _ transport.Options) error {
+	r, err := parseURI(ref)
+	if err != nil {
+		return err
+	}
+	s, err := Open(DefaultDir())
+	if err != nil {
+		return err
+	}
+	img, err := s.Import(src, r)
+	if err != nil {
+		return err
+	}
+	sylog.Infof("Image %s added to the image store", img.Ref)
+	return nil
+}
+
+// Digest returns the digest of the image at ref.
+func (Transport) Digest(_ context.Context, ref string, _ transport.Options) (string, error) {
+	img, err := get(ref)
+	if err != nil {
+		return "", err
+	}
+	return img.Digest.String(), nil
+}
+
+// get returns the image of the image store of the current user at the
+// store:// URI ref.
+func get(ref string) (Image, error) {
+	r, err := parseURI(ref)
+	if err != nil {
+		return Image{}, err
+	}
+	s, err := Open(DefaultDir())
+	if err != nil {
+		return Image{}, err
+	}
+	return s.Get(r)
+}
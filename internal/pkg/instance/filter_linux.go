@@ -0,0 +1,78 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter selects instances with a label, in label=<key> form, or with a
+// label value, in label=<key>=<value> form.
+type Filter struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// ParseFilter parses a filter in label=<key> or label=<key>=<value> form.
+func ParseFilter(filter string) (Filter, error) {
+	label, ok := strings.CutPrefix(filter, "label=")
+	if !ok {
+		return Filter{}, fmt.Errorf("invalid filter %q, must be label=<key> or label=<key>=<value>", filter)
+	}
+	key, value, hasValue := strings.Cut(label, "=")
+	if key == "" {
+		return Filter{}, fmt.Errorf("invalid filter %q: empty label key", filter)
+	}
+	return Filter{Key: key, Value: value, HasValue: hasValue}, nil
+}
+
+// ParseFilters parses filters in label=<key> or label=<key>=<value> form.
+func ParseFilters(filters []string) ([]Filter, error) {
+	ff := make([]Filter, 0, len(filters))
+	for _, filter := range filters {
+		f, err := ParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		ff = append(ff, f)
+	}
+	return ff, nil
+}
+
+// Match returns whether the instance i is selected by all filters.
+func (i *File) Match(filters []Filter) bool {
+	for _, f := range filters {
+		value, ok := i.Labels[f.Key]
+		if !ok || (f.HasValue && value != f.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateName returns the first name, in <prefix>-<N> form with N starting
+// from 1, that is not used by an instance of the current user.
+func GenerateName(prefix string, subDir string) (string, error) {
+	if err := CheckName(prefix); err != nil {
+		return "", fmt.Errorf("invalid instance name prefix: %w", err)
+	}
+	list, err := List("", prefix+"-*", subDir)
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(list))
+	for _, i := range list {
+		used[i.Name] = true
+	}
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s-%d", prefix, n)
+		if !used[name] {
+			return name, nil
+		}
+	}
+}
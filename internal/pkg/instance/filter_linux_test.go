@@ -0,0 +1,93 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		filter        string
+		want          Filter
+		expectFailure bool
+	}{
+		{filter: "label=role", want: Filter{Key: "role"}},
+		{filter: "label=role=db", want: Filter{Key: "role", Value: "db", HasValue: true}},
+		{filter: "label=role=", want: Filter{Key: "role", HasValue: true}},
+		{filter: "label=a=b=c", want: Filter{Key: "a", Value: "b=c", HasValue: true}},
+		{filter: "label=", expectFailure: true},
+		{filter: "name=db", expectFailure: true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil && !tt.expectFailure {
+			t.Errorf("unexpected failure for filter %q: %s", tt.filter, err)
+		} else if err == nil && tt.expectFailure {
+			t.Errorf("unexpected success for filter %q", tt.filter)
+		} else if f != tt.want {
+			t.Errorf("unexpected filter for %q, got %+v instead of %+v", tt.filter, f, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	i := &File{Labels: map[string]string{"role": "db", "tier": ""}}
+
+	tests := []struct {
+		filters []string
+		match   bool
+	}{
+		{filters: nil, match: true},
+		{filters: []string{"label=role"}, match: true},
+		{filters: []string{"label=role=db"}, match: true},
+		{filters: []string{"label=role=web"}, match: false},
+		{filters: []string{"label=tier="}, match: true},
+		{filters: []string{"label=role=db", "label=tier"}, match: true},
+		{filters: []string{"label=role=db", "label=zone"}, match: false},
+	}
+	for _, tt := range tests {
+		filters, err := ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatalf("unexpected failure for filters %v: %s", tt.filters, err)
+		}
+		if got := i.Match(filters); got != tt.match {
+			t.Errorf("unexpected match for filters %v, got %v instead of %v", tt.filters, got, tt.match)
+		}
+	}
+
+	if (&File{}).Match([]Filter{{Key: "role"}}) {
+		t.Errorf("unexpected match of instance without labels")
+	}
+}
+
+func TestGenerateName(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	if _, err := GenerateName("invalid prefix", testSubDir); err == nil {
+		t.Errorf("unexpected success for invalid prefix")
+	}
+
+	for _, want := range []string{"gen-1", "gen-2", "gen-3"} {
+		name, err := GenerateName("gen", testSubDir)
+		if err != nil {
+			t.Fatalf("unexpected failure: %s", err)
+		}
+		if name != want {
+			t.Fatalf("unexpected name, got %s instead of %s", name, want)
+		}
+		file, err := Add(name, testSubDir)
+		if err != nil {
+			t.Fatalf("error while adding instance %s: %s", name, err)
+		}
+		if err := file.Update(); err != nil {
+			t.Fatalf("error while creating instance %s: %s", name, err)
+		}
+		t.Cleanup(func() { file.Delete() })
+	}
+}
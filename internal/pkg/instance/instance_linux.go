@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -13,7 +13,9 @@ import (
 	"regexp"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/singularity/fusedriver"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/user"
 	"github.com/sylabs/singularity/v4/pkg/syfs"
 )
@@ -47,6 +49,24 @@ type File struct {
 	IP         string `json:"ip"`
 	LogErrPath string `json:"logErrPath"`
 	LogOutPath string `json:"logOutPath"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// OOMKills records the OOM kills of instance processes.
+	OOMKills []OOMKill `json:"oomKills,omitempty"`
+
+	// FuseDrivers reports the status of the FUSE drivers of --fusemount
+	// mounts run from the host in foreground mode.
+	FuseDrivers []fusedriver.Status `json:"fuseDrivers,omitempty"`
+}
+
+// OOMKill is an event where processes of an instance were killed by the
+// OOM killer.
+type OOMKill struct {
+	Time time.Time `json:"time"`
+	// Count is the total number of instance processes killed by the OOM
+	// killer, at the time of the event.
+	Count uint64 `json:"count"`
 }
 
 // ProcName returns processus name based on instance name
@@ -0,0 +1,422 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/pkg/runtime/engine/config"
+	singularityConfig "github.com/sylabs/singularity/v4/pkg/runtime/engine/singularity/config"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
+	"golang.org/x/sys/unix"
+)
+
+// criuFSTypes are the filesystem types of the mounts that CRIU creates again
+// on restore, rather than handling them as external mounts.
+var criuFSTypes = map[string]bool{
+	"proc":    true,
+	"sysfs":   true,
+	"devpts":  true,
+	"mqueue":  true,
+	"cgroup":  true,
+	"cgroup2": true,
+}
+
+// Checkpoint is a checkpoint of an instance, whose processes are left
+// stopped until the checkpoint is restored on the destination node, or the
+// migration fails.
+type Checkpoint struct {
+	// Dir is the directory holding the checkpoint and its manifest.
+	Dir      string
+	Manifest *Manifest
+	pids     []int
+}
+
+// Dump checkpoints the instance of file into dir, which must exist, with
+// CRIU. The instance root filesystem isn't part of the checkpoint, as it is
+// assembled again on the destination node from the instance image and
+// overlays. Mounts bound from host paths are expected at the same paths on
+// the destination node, while the content of the other mounts, such as the
+// files generated by singularity for the instance, is copied.
+func Dump(ctx context.Context, file *instance.File, dir string) (*Checkpoint, error) {
+	criu, err := bin.FindBin("criu")
+	if err != nil {
+		return nil, err
+	}
+	if len(file.FuseDrivers) > 0 {
+		return nil, fmt.Errorf("instance %s runs FUSE drivers on the host, and can't be migrated", file.Name)
+	}
+
+	engineConfig := singularityConfig.NewConfig()
+	if err := json.Unmarshal(file.Config, &config.Common{EngineConfig: engineConfig}); err != nil {
+		return nil, fmt.Errorf("while decoding configuration of instance %s: %w", file.Name, err)
+	}
+	if engineConfig.GetWritableTmpfs() {
+		return nil, fmt.Errorf("changes made in the --writable-tmpfs overlay of instance %s are held in memory, and can't be migrated, use a writable --overlay directory instead", file.Name)
+	}
+	if engineConfig.GetWritableImage() {
+		return nil, fmt.Errorf("instance %s was started with --writable, and can't be migrated, use a writable --overlay directory instead", file.Name)
+	}
+	overlays, err := overlayDirs(engineConfig.GetOverlayImage())
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		ID:       id.String(),
+		Name:     file.Name,
+		User:     file.User,
+		Image:    file.Image,
+		Config:   file.Config,
+		UserNs:   file.UserNs,
+		Cgroup:   file.Cgroup,
+		Labels:   file.Labels,
+		Overlays: overlays,
+	}
+	if err := m.addMounts(file.Pid, dir); err != nil {
+		return nil, err
+	}
+
+	pids, err := processTree(file.Pid)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.addFiles(file, pids); err != nil {
+		return nil, err
+	}
+
+	for _, o := range m.Overlays {
+		if o.Writable {
+			if err := os.WriteFile(markerPath(o.Path, m.ID), nil, 0o600); err != nil {
+				return nil, fmt.Errorf("while marking overlay %s: %w", o.Path, err)
+			}
+		}
+	}
+	c := &Checkpoint{Dir: dir, Manifest: m, pids: pids}
+
+	args := []string{
+		"dump",
+		"--tree", strconv.Itoa(file.Pid),
+		"--images-dir", filepath.Join(dir, checkpointDir),
+		"--work-dir", dir,
+		"--log-file", "dump.log",
+		// processes are killed once restored on the destination node, or
+		// resumed if the migration fails
+		"--leave-stopped",
+		"--manage-cgroups=ignore",
+		"--tcp-established",
+		"--ext-unix-sk",
+		"--file-locks",
+		"--enable-external-sharing",
+		"--enable-external-masters",
+	}
+	for _, mnt := range m.Mounts {
+		args = append(args, "--external", fmt.Sprintf("mnt[%s]:%s", mnt.Point, mnt.Key))
+	}
+	for _, f := range m.Files {
+		args = append(args, "--external", f.Key)
+	}
+	if err := os.Mkdir(filepath.Join(dir, checkpointDir), 0o700); err != nil {
+		c.removeMarkers()
+		return nil, err
+	}
+
+	sylog.Debugf("Checkpointing instance %s: %s %s", file.Name, criu, strings.Join(args, " "))
+	if out, err := exec.CommandContext(ctx, criu, args...).CombinedOutput(); err != nil {
+		c.removeMarkers()
+		return nil, fmt.Errorf("while checkpointing instance %s: %v: %s (see %s)", file.Name, err, out, filepath.Join(dir, "dump.log"))
+	}
+	if err := writeManifest(dir, m); err != nil {
+		c.Resume()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Resume resumes the processes of the checkpointed instance, when the
+// migration failed.
+func (c *Checkpoint) Resume() {
+	c.removeMarkers()
+	c.signal(syscall.SIGCONT)
+}
+
+// Kill kills the processes of the checkpointed instance, once it is restored
+// on the destination node.
+func (c *Checkpoint) Kill() {
+	c.removeMarkers()
+	c.signal(syscall.SIGKILL)
+}
+
+func (c *Checkpoint) signal(sig syscall.Signal) {
+	for _, pid := range c.pids {
+		if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+			sylog.Warningf("Could not send %s to process %d of instance %s: %v", sig, pid, c.Manifest.Name, err)
+		}
+	}
+}
+
+// removeMarkers removes the marker files of the writable overlays, which are
+// also removed by the destination node when it shares their filesystem.
+func (c *Checkpoint) removeMarkers() {
+	for _, o := range c.Manifest.Overlays {
+		if o.Writable {
+			if err := os.Remove(markerPath(o.Path, c.Manifest.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				sylog.Warningf("Could not remove migration marker of overlay %s: %v", o.Path, err)
+			}
+		}
+	}
+}
+
+// overlayDirs returns the overlay directories of the --overlay entries of an
+// instance, in <path>[:ro] form.
+func overlayDirs(entries []string) ([]Overlay, error) {
+	var overlays []Overlay
+	for _, e := range entries {
+		o := Overlay{Path: e, Writable: true}
+		if p, ok := strings.CutSuffix(e, ":ro"); ok {
+			o = Overlay{Path: p}
+		}
+		fi, err := os.Stat(o.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("overlay image %s can't be migrated, only overlay directories can", o.Path)
+		}
+		overlays = append(overlays, o)
+	}
+	return overlays, nil
+}
+
+// addMounts records the mounts of the instance process pid. The content of the
+// mounts which aren't bound from host paths is copied into dir.
+func (m *Manifest) addMounts(pid int, dir string) error {
+	entries, err := proc.GetMountInfoEntry(fmt.Sprintf("/proc/%d/mountinfo", pid))
+	if err != nil {
+		return err
+	}
+	host, err := proc.GetMountInfoEntry("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	root := fmt.Sprintf("/proc/%d/root", pid)
+
+	points := make(map[string]bool)
+	for i, e := range entries {
+		if e.Point == "/" {
+			continue
+		}
+		if !points[e.Point] {
+			fi, err := os.Lstat(filepath.Join(root, e.Point))
+			if err != nil {
+				return fmt.Errorf("while getting mount point %s: %w", e.Point, err)
+			}
+			m.MountPoints = append(m.MountPoints, MountPoint{Path: e.Point, Dir: fi.IsDir()})
+			points[e.Point] = true
+		}
+
+		if criuFSTypes[e.FSType] {
+			continue
+		}
+		mnt := Mount{Key: fmt.Sprintf("m%d", i), Point: e.Point}
+		if src := hostSource(e, host); src != "" {
+			mnt.Source = src
+			m.Mounts = append(m.Mounts, mnt)
+			continue
+		}
+
+		switch {
+		case e.FSType == "tmpfs" && e.Root == "/":
+			// content is part of the checkpoint
+			continue
+		case strings.HasPrefix(e.FSType, "fuse"):
+			return fmt.Errorf("FUSE mount %s of instance %s can't be migrated", e.Point, m.Name)
+		case e.FSType == "overlay":
+			return fmt.Errorf("overlay mount %s of instance %s can't be migrated", e.Point, m.Name)
+		case strings.HasPrefix(e.Source, "/dev/"):
+			return fmt.Errorf("image mount %s of instance %s can't be migrated", e.Point, m.Name)
+		}
+
+		mnt.Snapshot = filepath.Join(snapshotDir, mnt.Key)
+		if err := os.MkdirAll(filepath.Join(dir, snapshotDir), 0o700); err != nil {
+			return err
+		}
+		cp, err := bin.FindBin("cp")
+		if err != nil {
+			return err
+		}
+		src, dst := filepath.Join(root, e.Point), filepath.Join(dir, mnt.Snapshot)
+		if out, err := exec.Command(cp, "-a", src, dst).CombinedOutput(); err != nil {
+			return fmt.Errorf("while copying content of mount %s: %v: %s", e.Point, err, out)
+		}
+		m.Mounts = append(m.Mounts, mnt)
+	}
+	return nil
+}
+
+// hostSource returns the host path bound at the instance mount e, found from
+// the host mount of the same filesystem holding its root, or an empty string
+// if the mount isn't bound from a host path.
+func hostSource(e proc.MountInfoEntry, host []proc.MountInfoEntry) string {
+	var src *proc.MountInfoEntry
+	for i, h := range host {
+		if h.Dev != e.Dev || h.FSType != e.FSType || !within(e.Root, h.Root) {
+			continue
+		}
+		if src == nil || len(h.Root) > len(src.Root) {
+			src = &host[i]
+		}
+	}
+	if src == nil {
+		return ""
+	}
+	rel, err := filepath.Rel(src.Root, e.Root)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(src.Point, rel)
+}
+
+// within returns whether path is dir, or lies under it.
+func within(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// addFiles records the files opened by the processes pids of the instance
+// of file outside of the instance mounts.
+func (m *Manifest) addFiles(file *instance.File, pids []int) error {
+	entries, err := proc.GetMountInfoEntry(fmt.Sprintf("/proc/%d/mountinfo", file.Pid))
+	if err != nil {
+		return err
+	}
+	mounts := make(map[int]bool)
+	for _, e := range entries {
+		id, err := strconv.Atoi(e.ID)
+		if err != nil {
+			return fmt.Errorf("bad mount ID %q in mountinfo", e.ID)
+		}
+		mounts[id] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, pid := range pids {
+		fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+		fds, err := os.ReadDir(fdDir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, fd := range fds {
+			path := filepath.Join(fdDir, fd.Name())
+			target, err := os.Readlink(path)
+			// pipes, sockets and anonymous inodes are handled by CRIU, as
+			// are deleted files
+			if err != nil || !filepath.IsAbs(target) || strings.HasSuffix(target, " (deleted)") {
+				continue
+			}
+			mntID, flags, err := readFdinfo(fmt.Sprintf("/proc/%d/fdinfo/%s", pid, fd.Name()))
+			if err != nil {
+				return err
+			}
+			if mounts[mntID] {
+				continue
+			}
+			var st unix.Stat_t
+			if err := unix.Stat(path, &st); err != nil {
+				return fmt.Errorf("while getting file %s opened by process %d: %w", target, pid, err)
+			}
+
+			f := File{Key: fmt.Sprintf("file[%x:%x]", mntID, st.Ino), Path: target, Flags: flags}
+			if seen[f.Key] {
+				continue
+			}
+			seen[f.Key] = true
+			switch target {
+			case file.LogOutPath:
+				f.Log = "out"
+			case file.LogErrPath:
+				f.Log = "err"
+			}
+			m.Files = append(m.Files, f)
+		}
+	}
+	return nil
+}
+
+// readFdinfo returns the mount ID and open flags of a file descriptor, from
+// its fdinfo file.
+func readFdinfo(path string) (mntID, flags int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	mntID, flags = -1, -1
+	for s := bufio.NewScanner(f); s.Scan(); {
+		key, value, _ := strings.Cut(s.Text(), ":")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "mnt_id":
+			mntID, err = strconv.Atoi(value)
+		case "flags":
+			var v int64
+			v, err = strconv.ParseInt(value, 8, 0)
+			flags = int(v)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("while parsing %s: %w", path, err)
+		}
+	}
+	if mntID < 0 || flags < 0 {
+		return 0, 0, fmt.Errorf("no mount ID or flags found in %s", path)
+	}
+	return mntID, flags, nil
+}
+
+// processTree returns pid and the PIDs of its descendants.
+func processTree(pid int) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int][]int)
+	for _, e := range entries {
+		p, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// the process may have exited
+		if ppid, err := proc.Getppid(p); err == nil {
+			children[ppid] = append(children[ppid], p)
+		}
+	}
+
+	pids := []int{pid}
+	for i := 0; i < len(pids); i++ {
+		pids = append(pids, children[pids[i]]...)
+	}
+	return pids, nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package migrate moves a running instance to another node, e.g. to drain a
+// node for maintenance. The instance processes are checkpointed with CRIU, and
+// the checkpoint is sent over ssh to the other node, with a manifest holding
+// the image reference, mounts and open files of the instance, and with the
+// upper layer of its writable overlay directory. The instance is restored on
+// the other node on top of a root filesystem assembled again from the same
+// image and overlays.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	manifestFile   = "manifest.json"
+	checkpointDir  = "checkpoint"
+	snapshotDir    = "snapshots"
+	markerPrefix   = ".singularity-migrate-"
+	defaultDestDir = "/var/tmp"
+)
+
+// Manifest describes a checkpointed instance, as sent to the destination
+// node.
+type Manifest struct {
+	// ID identifies the migration.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	User string `json:"user"`
+	// Image is the path of the instance image, which must be present at the
+	// same path on the destination node.
+	Image string `json:"image"`
+	// Config is the engine configuration of the instance, from its instance
+	// file.
+	Config []byte            `json:"config"`
+	UserNs bool              `json:"userns"`
+	Cgroup bool              `json:"cgroup"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Overlays are the overlay directories of the instance, in order.
+	Overlays []Overlay `json:"overlays,omitempty"`
+	// MountPoints are the mount points of the instance, created again in the
+	// root filesystem of the restored instance.
+	MountPoints []MountPoint `json:"mountPoints,omitempty"`
+	// Mounts are the mounts of the instance that CRIU handles as external
+	// mounts, as their content isn't part of the checkpoint.
+	Mounts []Mount `json:"mounts,omitempty"`
+	// Files are the files opened by the instance processes outside of the
+	// instance mounts, such as its log files.
+	Files []File `json:"files,omitempty"`
+}
+
+// Overlay is an overlay directory of an instance. The upper layer of the
+// writable overlay is sent to the destination node, read-only overlays must
+// be present at the same path there.
+type Overlay struct {
+	Path     string `json:"path"`
+	Writable bool   `json:"writable"`
+}
+
+// MountPoint is a mount point of an instance.
+type MountPoint struct {
+	Path string `json:"path"`
+	Dir  bool   `json:"dir"`
+}
+
+// Mount is an external mount of an instance.
+type Mount struct {
+	// Key identifies the mount in the checkpoint.
+	Key   string `json:"key"`
+	Point string `json:"point"`
+	// Source is the host path bound at Point, which must be present at the
+	// same path on the destination node.
+	Source string `json:"source,omitempty"`
+	// Snapshot is the path, relative to the checkpoint directory, of a copy
+	// of the content mounted at Point, for mounts which aren't bound from a
+	// host path, such as the files generated by singularity for the instance.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// File is a file opened by instance processes outside of the instance mounts.
+type File struct {
+	// Key identifies the file in the checkpoint, in file[<mnt_id>:<inode>]
+	// form.
+	Key   string `json:"key"`
+	Path  string `json:"path"`
+	Flags int    `json:"flags"`
+	// Log is set to out or err for the instance log files, which are opened
+	// at the log paths of the instance on the destination node.
+	Log string `json:"log,omitempty"`
+}
+
+// Destination is where an instance is migrated to.
+type Destination struct {
+	// Host is the destination node, in [user@]host form, as passed to ssh.
+	Host string
+	// Dir is the directory of the destination node in which the checkpoint
+	// is received.
+	Dir string
+}
+
+// ParseDestination parses a destination in [user@]host:[dir] form. The
+// checkpoint is received in /var/tmp when dir is empty.
+func ParseDestination(s string) (Destination, error) {
+	host, dir, ok := strings.Cut(s, ":")
+	if !ok || host == "" || strings.HasSuffix(host, "@") {
+		return Destination{}, fmt.Errorf("destination %q is not in [user@]host:[dir] format", s)
+	}
+	if dir == "" {
+		dir = defaultDestDir
+	} else if !filepath.IsAbs(dir) {
+		return Destination{}, fmt.Errorf("destination directory %s is not an absolute path", dir)
+	}
+	return Destination{Host: host, Dir: filepath.Clean(dir)}, nil
+}
+
+// stagingDir returns the directory of the destination node receiving the
+// checkpoint of the migration id.
+func (d Destination) stagingDir(id string) string {
+	return filepath.Join(d.Dir, "singularity-migrate-"+id)
+}
+
+// markerPath returns the path of the marker file written by the migration id
+// in the overlay directory dir, which tells the destination node that dir
+// is on a filesystem shared with the source node.
+func markerPath(dir, id string) string {
+	return filepath.Join(dir, markerPrefix+id)
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), b, 0o600)
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("while reading migration manifest: %w", err)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("while decoding migration manifest: %w", err)
+	}
+	return m, nil
+}
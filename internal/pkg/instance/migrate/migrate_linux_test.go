@@ -0,0 +1,182 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
+)
+
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		name    string
+		dest    string
+		want    Destination
+		wantErr bool
+	}{
+		{name: "HostOnly", dest: "node2:", want: Destination{Host: "node2", Dir: "/var/tmp"}},
+		{name: "User", dest: "root@node2:", want: Destination{Host: "root@node2", Dir: "/var/tmp"}},
+		{name: "Dir", dest: "root@node2:/scratch/", want: Destination{Host: "root@node2", Dir: "/scratch"}},
+		{name: "NoColon", dest: "node2", wantErr: true},
+		{name: "NoHost", dest: ":/scratch", wantErr: true},
+		{name: "NoHostAfterUser", dest: "root@:", wantErr: true},
+		{name: "RelativeDir", dest: "node2:scratch", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDestination(tt.dest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDestination(%q) error = %v, wantErr %v", tt.dest, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDestination(%q) = %+v, want %+v", tt.dest, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostSource(t *testing.T) {
+	host := []proc.MountInfoEntry{
+		{Dev: "8:1", Root: "/", Point: "/", FSType: "ext4"},
+		{Dev: "8:2", Root: "/", Point: "/home", FSType: "xfs"},
+		{Dev: "8:2", Root: "/alice/data", Point: "/data", FSType: "xfs"},
+		{Dev: "0:5", Root: "/", Point: "/dev", FSType: "devtmpfs"},
+	}
+	tests := []struct {
+		name  string
+		entry proc.MountInfoEntry
+		want  string
+	}{
+		{
+			name:  "Root",
+			entry: proc.MountInfoEntry{Dev: "8:1", Root: "/etc/localtime", FSType: "ext4"},
+			want:  "/etc/localtime",
+		},
+		{
+			name:  "Subdirectory",
+			entry: proc.MountInfoEntry{Dev: "8:2", Root: "/alice", FSType: "xfs"},
+			want:  "/home/alice",
+		},
+		{
+			name:  "LongestRoot",
+			entry: proc.MountInfoEntry{Dev: "8:2", Root: "/alice/data/set", FSType: "xfs"},
+			want:  "/data/set",
+		},
+		{
+			name:  "Device",
+			entry: proc.MountInfoEntry{Dev: "0:5", Root: "/", FSType: "devtmpfs"},
+			want:  "/dev",
+		},
+		{
+			name:  "SessionFile",
+			entry: proc.MountInfoEntry{Dev: "0:42", Root: "/etc/passwd", FSType: "tmpfs"},
+			want:  "",
+		},
+		{
+			name:  "OtherFilesystemType",
+			entry: proc.MountInfoEntry{Dev: "8:2", Root: "/alice", FSType: "ext4"},
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hostSource(tt.entry, host); got != tt.want {
+				t.Errorf("hostSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFdinfo(t *testing.T) {
+	f, err := os.OpenFile(filepath.Join(t.TempDir(), "file"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mntID, flags, err := readFdinfo(fmt.Sprintf("/proc/self/fdinfo/%d", f.Fd()))
+	if err != nil {
+		t.Fatalf("readFdinfo() error = %v", err)
+	}
+	if mntID <= 0 {
+		t.Errorf("readFdinfo() mount ID = %d", mntID)
+	}
+	if flags&os.O_APPEND == 0 || flags&os.O_WRONLY == 0 {
+		t.Errorf("readFdinfo() flags = %o, want O_WRONLY|O_APPEND", flags)
+	}
+
+	if _, _, err := readFdinfo("/proc/self/fdinfo/-1"); err == nil {
+		t.Errorf("readFdinfo() of a missing file descriptor succeeded")
+	}
+}
+
+func TestOverlayDirs(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "overlay.img")
+	if err := os.WriteFile(image, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := overlayDirs([]string{dir, dir + ":ro"})
+	if err != nil {
+		t.Fatalf("overlayDirs() error = %v", err)
+	}
+	want := []Overlay{{Path: dir, Writable: true}, {Path: dir}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("overlayDirs() = %+v, want %+v", got, want)
+	}
+
+	if _, err := overlayDirs([]string{image}); err == nil {
+		t.Errorf("overlayDirs() of an overlay image succeeded")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manifest{
+		ID:          "id",
+		Name:        "test",
+		User:        "alice",
+		Image:       "/images/test.sif",
+		Config:      []byte(`{"engineName":"singularity"}`),
+		Overlays:    []Overlay{{Path: "/scratch/overlay", Writable: true}},
+		MountPoints: []MountPoint{{Path: "/etc/passwd"}, {Path: "/home/alice", Dir: true}},
+		Mounts:      []Mount{{Key: "m3", Point: "/home/alice", Source: "/home/alice"}},
+		Files:       []File{{Key: "file[1d:2a]", Path: "/home/alice/test.out", Flags: os.O_WRONLY, Log: "out"}},
+	}
+	if err := writeManifest(dir, m); err != nil {
+		t.Fatalf("writeManifest() error = %v", err)
+	}
+	got, err := readManifest(dir)
+	if err != nil {
+		t.Fatalf("readManifest() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("readManifest() = %+v, want %+v", got, m)
+	}
+}
+
+func TestProcessTree(t *testing.T) {
+	pids, err := processTree(os.Getppid())
+	if err != nil {
+		t.Fatalf("processTree() error = %v", err)
+	}
+	if pids[0] != os.Getppid() {
+		t.Errorf("processTree() first PID = %d, want %d", pids[0], os.Getppid())
+	}
+	found := false
+	for _, pid := range pids {
+		found = found || pid == os.Getpid()
+	}
+	if !found {
+		t.Errorf("processTree() = %v, doesn't hold the test process %d", pids, os.Getpid())
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/image/hostmount"
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/user"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// Restore restores the instance checkpointed in dir, as received from the
+// source node, by starting a monitor process running the singularity
+// executable with args, which must call Monitor. The monitor process is named
+// as the master process of an instance, and like it, stays the parent of the
+// instance processes, and cleans up once they exit. Restore returns once the
+// instance is restored, or failed to be.
+func Restore(dir string, args []string) error {
+	m, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	name, err := instance.ProcName(m.Name, m.User)
+	if err != nil {
+		return err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd := &exec.Cmd{
+		Path:       "/proc/self/exe",
+		Args:       append([]string{name}, args...),
+		ExtraFiles: []*os.File{w},
+		SysProcAttr: &syscall.SysProcAttr{
+			Setsid: true,
+		},
+	}
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return fmt.Errorf("while starting instance monitor: %w", err)
+	}
+	w.Close()
+	go cmd.Wait()
+
+	// the monitor reports an error, or closes the pipe once the instance is
+	// restored
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(b) > 0 {
+		return errors.New(string(b))
+	}
+	sylog.Infof("Instance %s restored", m.Name)
+	return nil
+}
+
+// Monitor restores the instance checkpointed in dir, and waits for it to
+// exit. The restore outcome is reported on the pipe ready, which is closed
+// once the instance is restored.
+func Monitor(ctx context.Context, dir string, ready *os.File) error {
+	rs, err := restore(ctx, dir)
+	if err != nil {
+		fmt.Fprint(ready, err)
+		ready.Close()
+		return err
+	}
+	ready.Close()
+
+	rs.wait()
+	if err := rs.file.Delete(); err != nil {
+		sylog.Warningf("Could not remove instance file of %s: %v", rs.manifest.Name, err)
+	}
+	rs.cleanup()
+	return nil
+}
+
+type restorer struct {
+	dir      string
+	manifest *Manifest
+	pid      int
+	file     *instance.File
+	mounts   []string
+}
+
+// restore restores the instance checkpointed in dir as a child process of
+// the current process, and registers it under the name and user it had on
+// the source node.
+func restore(ctx context.Context, dir string) (*restorer, error) {
+	criu, err := bin.FindBin("criu")
+	if err != nil {
+		return nil, err
+	}
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	u, err := user.GetPwNam(m.User)
+	if err != nil {
+		return nil, fmt.Errorf("user %s of instance %s not found on this node: %w", m.User, m.Name, err)
+	}
+	if _, err := os.Stat(m.Image); err != nil {
+		return nil, fmt.Errorf("image of instance %s must be present at the same path on this node: %w", m.Name, err)
+	}
+	for _, mnt := range m.Mounts {
+		if mnt.Source == "" {
+			continue
+		}
+		if _, err := os.Stat(mnt.Source); err != nil {
+			return nil, fmt.Errorf("%s, mounted at %s in instance %s, must be present at the same path on this node: %w", mnt.Source, mnt.Point, m.Name, err)
+		}
+	}
+
+	rs := &restorer{dir: dir, manifest: m}
+	if err := rs.placeOverlays(); err != nil {
+		return nil, err
+	}
+	root, err := rs.mountRoot(ctx)
+	if err != nil {
+		rs.cleanup()
+		return nil, err
+	}
+
+	// the instance and log files are the ones of the instance user, resolved
+	// from the real user ID, the effective user ID stays root for CRIU
+	if err := syscall.Setresgid(int(u.GID), 0, 0); err != nil {
+		rs.cleanup()
+		return nil, err
+	}
+	if err := syscall.Setresuid(int(u.UID), 0, 0); err != nil {
+		rs.cleanup()
+		return nil, err
+	}
+	if _, err := instance.Get(m.Name, instance.SingSubDir); err == nil {
+		rs.cleanup()
+		return nil, fmt.Errorf("instance %s already exists on this node", m.Name)
+	}
+	files, err := rs.openFiles(int(u.UID))
+	if err != nil {
+		rs.cleanup()
+		return nil, err
+	}
+	err = rs.criuRestore(ctx, criu, root, files)
+	for _, f := range files {
+		f.Close()
+	}
+	if err != nil {
+		rs.cleanup()
+		return nil, err
+	}
+
+	// as the master process of an instance, the monitor runs as the instance
+	// user with a saved root user ID, which the user can't trace
+	err = syscall.Setresgid(int(u.GID), int(u.GID), 0)
+	if err == nil {
+		err = syscall.Setresuid(int(u.UID), int(u.UID), 0)
+	}
+	if err == nil {
+		err = unix.Prctl(unix.PR_SET_DUMPABLE, 1, 0, 0, 0)
+	}
+	if err == nil {
+		err = rs.register()
+	}
+	if err != nil {
+		syscall.Kill(rs.pid, syscall.SIGKILL)
+		rs.wait()
+		rs.cleanup()
+		return nil, err
+	}
+	return rs, nil
+}
+
+// wait waits for the restored instance to exit.
+func (rs *restorer) wait() {
+	var ws unix.WaitStatus
+	for {
+		_, err := unix.Wait4(rs.pid, &ws, 0, nil)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	sylog.Debugf("Instance %s exited: %v", rs.manifest.Name, ws)
+}
+
+// placeOverlays checks that the overlay directories of the instance are
+// present, and removes the marker files of the writable overlay.
+func (rs *restorer) placeOverlays() error {
+	for _, o := range rs.manifest.Overlays {
+		if !o.Writable {
+			if !fs.IsDir(o.Path) {
+				return fmt.Errorf("overlay %s must be present at the same path on this node", o.Path)
+			}
+			continue
+		}
+		marker := markerPath(o.Path, rs.manifest.ID)
+		if _, err := os.Stat(marker); err != nil {
+			return fmt.Errorf("overlay %s was not received: %w", o.Path, err)
+		}
+		if err := os.Remove(marker); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(o.Path, "work"), 0o700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mountRoot assembles the root filesystem of the instance, as an overlay of
+// the mount points of the instance, its overlays and its image, and returns
+// its path.
+func (rs *restorer) mountRoot(ctx context.Context) (string, error) {
+	image := rs.manifest.Image
+	if !fs.IsDir(image) {
+		dir := filepath.Join(rs.dir, "image")
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			return "", err
+		}
+		im, err := hostmount.RootFSMount(image)
+		if err != nil {
+			return "", err
+		}
+		im.Readonly = true
+		// the instance processes don't run as root
+		im.AllowOther = true
+		im.SetMountPoint(dir)
+		if err := im.Mount(ctx); err != nil {
+			return "", err
+		}
+		rs.mounts = append(rs.mounts, dir)
+		image = dir
+	}
+
+	points := filepath.Join(rs.dir, "points")
+	for _, mp := range rs.manifest.MountPoints {
+		path := filepath.Join(points, mp.Path)
+		if mp.Dir {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		if err := fs.Touch(path); err != nil {
+			return "", err
+		}
+	}
+
+	lower := []string{points}
+	upper := ""
+	for _, o := range rs.manifest.Overlays {
+		switch {
+		case o.Writable:
+			upper = o.Path
+		case fs.IsDir(filepath.Join(o.Path, "upper")):
+			lower = append(lower, filepath.Join(o.Path, "upper"))
+		default:
+			lower = append(lower, o.Path)
+		}
+	}
+	lower = append(lower, image)
+	opts := "lowerdir=" + strings.Join(lower, ":")
+	if upper != "" {
+		opts += ",upperdir=" + filepath.Join(upper, "upper") + ",workdir=" + filepath.Join(upper, "work")
+	}
+
+	root := filepath.Join(rs.dir, "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		return "", err
+	}
+	sylog.Debugf("Mounting root filesystem of instance %s with options %s", rs.manifest.Name, opts)
+	if err := syscall.Mount("overlay", root, "overlay", syscall.MS_NODEV, opts); err != nil {
+		return "", fmt.Errorf("while mounting root filesystem of instance %s: %w", rs.manifest.Name, err)
+	}
+	rs.mounts = append(rs.mounts, root)
+	return root, nil
+}
+
+// openFiles opens the files opened by the instance processes outside of the
+// instance mounts, in the order of the manifest. The log files are opened at
+// the log paths of the instance user uid.
+func (rs *restorer) openFiles(uid int) ([]*os.File, error) {
+	stdout, stderr, err := instance.SetLogFile(rs.manifest.Name, uid, instance.LogSubDir)
+	if err != nil {
+		return nil, fmt.Errorf("while opening log files of instance %s: %w", rs.manifest.Name, err)
+	}
+	files := make([]*os.File, 0, len(rs.manifest.Files))
+	for _, f := range rs.manifest.Files {
+		switch f.Log {
+		case "out":
+			files = append(files, stdout)
+		case "err":
+			files = append(files, stderr)
+		default:
+			file, err := os.OpenFile(f.Path, f.Flags&^(os.O_CREATE|os.O_EXCL|os.O_TRUNC), 0)
+			if err != nil {
+				stdout.Close()
+				stderr.Close()
+				for _, f := range files {
+					f.Close()
+				}
+				return nil, fmt.Errorf("while opening %s for instance %s: %w", f.Path, rs.manifest.Name, err)
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// criuRestore restores the instance processes on top of root, with files
+// opened for the external files of the checkpoint. The instance processes are
+// restored as children of the current process.
+func (rs *restorer) criuRestore(ctx context.Context, criu, root string, files []*os.File) error {
+	pidFile := filepath.Join(rs.dir, "pid")
+	args := []string{
+		"restore",
+		"--images-dir", filepath.Join(rs.dir, checkpointDir),
+		"--work-dir", rs.dir,
+		"--log-file", "restore.log",
+		"--root", root,
+		"--pidfile", pidFile,
+		"--restore-detached",
+		"--restore-sibling",
+		"--manage-cgroups=ignore",
+		// connections can't follow the instance to another node
+		"--tcp-close",
+		"--ext-unix-sk",
+		"--file-locks",
+	}
+	for _, mnt := range rs.manifest.Mounts {
+		src := mnt.Source
+		if mnt.Snapshot != "" {
+			src = filepath.Join(rs.dir, mnt.Snapshot)
+		}
+		args = append(args, "--external", fmt.Sprintf("mnt[%s]:%s", mnt.Key, src))
+	}
+	for i, f := range rs.manifest.Files {
+		// files are passed from file descriptor 3
+		args = append(args, "--inherit-fd", fmt.Sprintf("fd[%d]:%s", i+3, f.Key))
+	}
+
+	sylog.Debugf("Restoring instance %s: %s %s", rs.manifest.Name, criu, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, criu, args...)
+	cmd.ExtraFiles = files
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: 0, Gid: 0},
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("while restoring instance %s: %v: %s (see %s)", rs.manifest.Name, err, out, filepath.Join(rs.dir, "restore.log"))
+	}
+
+	b, err := os.ReadFile(pidFile)
+	if err != nil {
+		return fmt.Errorf("while reading PID of restored instance %s: %w", rs.manifest.Name, err)
+	}
+	rs.pid, err = strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return fmt.Errorf("bad PID of restored instance %s: %w", rs.manifest.Name, err)
+	}
+	return nil
+}
+
+// register writes the instance file of the restored instance.
+func (rs *restorer) register() error {
+	m := rs.manifest
+	file, err := instance.Add(m.Name, instance.SingSubDir)
+	if err != nil {
+		return err
+	}
+	logErrPath, logOutPath, err := instance.GetLogFilePaths(m.Name, instance.LogSubDir)
+	if err != nil {
+		return fmt.Errorf("could not find log paths: %s", err)
+	}
+
+	file.User = m.User
+	file.Pid = rs.pid
+	file.PPid = os.Getpid()
+	file.Image = m.Image
+	file.Config = m.Config
+	file.UserNs = m.UserNs
+	file.Cgroup = m.Cgroup
+	file.Labels = m.Labels
+	file.LogErrPath = logErrPath
+	file.LogOutPath = logOutPath
+	if err := file.Update(); err != nil {
+		return err
+	}
+	rs.file = file
+	return nil
+}
+
+// cleanup unmounts the root filesystem of the instance, and removes the
+// checkpoint directory. Root privileges are regained from the saved user ID
+// when they were dropped.
+func (rs *restorer) cleanup() {
+	if err := syscall.Setresuid(-1, 0, -1); err != nil {
+		sylog.Warningf("Could not regain privileges to clean up instance %s: %v", rs.manifest.Name, err)
+		return
+	}
+	if err := syscall.Setresgid(-1, 0, -1); err != nil {
+		sylog.Warningf("Could not regain privileges to clean up instance %s: %v", rs.manifest.Name, err)
+		return
+	}
+	for i := len(rs.mounts) - 1; i >= 0; i-- {
+		if err := syscall.Unmount(rs.mounts[i], syscall.MNT_DETACH); err != nil {
+			sylog.Warningf("Could not unmount %s: %v", rs.mounts[i], err)
+		}
+	}
+	rs.mounts = nil
+	if err := os.RemoveAll(rs.dir); err != nil {
+		sylog.Warningf("Could not remove %s: %v", rs.dir, err)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// sshOptions disable prompts, which would hang the migration.
+var sshOptions = []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new"}
+
+// tarOptions keep the ownership, permissions and extended attributes of the
+// files sent, as the overlay whiteouts held in the extended attributes of an
+// upper layer.
+var tarOptions = []string{"--xattrs", "--xattrs-include=*", "--numeric-owner"}
+
+// Sender sends checkpoints to the destination node with tar over ssh, and
+// restores them there with singularity, installed at the same path on both
+// nodes. The destination node must accept non-interactive ssh connections.
+type Sender struct {
+	// SSH is the path of the ssh executable.
+	SSH string
+	// Tar is the path of the tar executable.
+	Tar string
+	// Singularity is the path of the singularity executable, on the
+	// destination node.
+	Singularity string
+}
+
+// NewSender returns a Sender using the ssh and tar executables found on PATH.
+func NewSender() (*Sender, error) {
+	ssh, err := bin.FindBin("ssh")
+	if err != nil {
+		return nil, err
+	}
+	tar, err := bin.FindBin("tar")
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{
+		SSH:         ssh,
+		Tar:         tar,
+		Singularity: filepath.Join(buildcfg.BINDIR, "singularity"),
+	}, nil
+}
+
+// Send sends the checkpoint c to dest, with the upper layer of the writable
+// overlay of the instance, and restores the instance there. The upper layer
+// isn't sent when the overlay directory is on a filesystem shared by both
+// nodes.
+func (s *Sender) Send(ctx context.Context, c *Checkpoint, dest Destination) error {
+	staging := dest.stagingDir(c.Manifest.ID)
+	sylog.Infof("Sending checkpoint of instance %s to %s:%s", c.Manifest.Name, dest.Host, staging)
+	if err := s.sendDir(ctx, dest.Host, c.Dir, staging); err != nil {
+		return err
+	}
+
+	for _, o := range c.Manifest.Overlays {
+		if !o.Writable {
+			continue
+		}
+		marker := markerPath(o.Path, c.Manifest.ID)
+		if err := s.run(ctx, dest.Host, nil, "test", "-e", marker); err == nil {
+			sylog.Infof("Overlay %s is shared with %s, not sending it", o.Path, dest.Host)
+			continue
+		}
+		sylog.Infof("Sending upper layer of overlay %s to %s", o.Path, dest.Host)
+		if err := s.run(ctx, dest.Host, nil, "test", "!", "-e", o.Path); err != nil {
+			return fmt.Errorf("overlay %s already exists on %s", o.Path, dest.Host)
+		}
+		if err := s.sendDir(ctx, dest.Host, filepath.Join(o.Path, "upper"), filepath.Join(o.Path, "upper")); err != nil {
+			return err
+		}
+		if err := s.run(ctx, dest.Host, nil, "touch", marker); err != nil {
+			return err
+		}
+	}
+
+	sylog.Infof("Restoring instance %s on %s", c.Manifest.Name, dest.Host)
+	args := []string{s.Singularity}
+	if sylog.GetLevel() >= int(sylog.DebugLevel) {
+		args = append(args, "--debug")
+	}
+	args = append(args, "instance", "restore", staging)
+	return s.run(ctx, dest.Host, os.Stderr, args...)
+}
+
+// sendDir sends the content of the local directory src to the directory dst
+// of host, which is created if needed.
+func (s *Sender) sendDir(ctx context.Context, host, src, dst string) error {
+	tarArgs := append(append([]string{"-c", "-f", "-"}, tarOptions...), "-C", src, ".")
+	tar := exec.CommandContext(ctx, s.Tar, tarArgs...)
+	var tarErr bytes.Buffer
+	tar.Stderr = &tarErr
+
+	extract := []string{"mkdir", "-p", shellQuote(dst), "&&", "tar", "-x", "-f", "-", "--same-permissions"}
+	for _, o := range append(append([]string{}, tarOptions...), "-C", dst) {
+		extract = append(extract, shellQuote(o))
+	}
+	ssh := exec.CommandContext(ctx, s.SSH, append(append([]string{}, sshOptions...), host, "--", strings.Join(extract, " "))...)
+	var sshErr bytes.Buffer
+	ssh.Stderr = &sshErr
+
+	pipe, err := tar.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	ssh.Stdin = pipe
+	if err := tar.Start(); err != nil {
+		return err
+	}
+	if err := ssh.Run(); err != nil {
+		tar.Wait()
+		return fmt.Errorf("while sending %s to %s: %v: %s", src, host, err, sshErr.String())
+	}
+	if err := tar.Wait(); err != nil {
+		return fmt.Errorf("while archiving %s: %v: %s", src, err, tarErr.String())
+	}
+	return nil
+}
+
+// run runs the command args on host. The output of the command goes to out
+// when set.
+func (s *Sender) run(ctx context.Context, host string, out *os.File, args ...string) error {
+	sshArgs := append(append([]string{}, sshOptions...), host, "--")
+	for _, a := range args {
+		sshArgs = append(sshArgs, shellQuote(a))
+	}
+	cmd := exec.CommandContext(ctx, s.SSH, sshArgs...)
+	if out != nil {
+		cmd.Stdout = out
+		cmd.Stderr = out
+		return cmd.Run()
+	}
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s on %s: %v: %s", strings.Join(args, " "), host, err, b)
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -23,7 +23,9 @@ import (
 	"github.com/opencontainers/go-digest"
 	"github.com/sylabs/singularity/v4/internal/pkg/cache"
 	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/tracing"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/term"
 )
 
@@ -72,7 +74,9 @@ func FetchLayout(ctx context.Context, tOpts *ocitransport.TransportOptions, imgC
 
 	if imgCache != nil && !imgCache.IsDisabled() {
 		// Grab the modified source ref from the cache
-		srcRef, imgDigest, err = CacheReference(ctx, tOpts, imgCache, srcRef)
+		resolveCtx, span := tracing.Start(ctx, "resolve ref", attribute.String("image.ref", imageRef))
+		srcRef, imgDigest, err = CacheReference(resolveCtx, tOpts, imgCache, srcRef)
+		tracing.End(span, err)
 		if err != nil {
 			return nil, "", err
 		}
@@ -92,7 +96,9 @@ func FetchLayout(ctx context.Context, tOpts *ocitransport.TransportOptions, imgC
 	if (sylog.GetLevel() <= -1) || !term.IsTerminal(2) {
 		copyOpts.ReportWriter = io.Discard
 	}
-	_, err = copy.Image(ctx, policyCtx, lr, srcRef, &copyOpts)
+	fetchCtx, span := tracing.Start(ctx, "fetch layers", attribute.String("image.ref", imageRef))
+	_, err = copy.Image(fetchCtx, policyCtx, lr, srcRef, &copyOpts)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, "", err
 	}
@@ -0,0 +1,258 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package recording records sessions run in a pseudo-terminal, as a
+// typescript with timing information that can be replayed with
+// scriptreplay(1), alongside metadata describing the session.
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/term"
+)
+
+// Metadata describes a recorded session.
+type Metadata struct {
+	// User is the name of the user running the session.
+	User string `json:"user"`
+	// UID is the uid of the user running the session.
+	UID int `json:"uid"`
+	// Host is the hostname of the machine on which the session was run.
+	Host string `json:"host"`
+	// Command is the command line of the session.
+	Command []string `json:"command"`
+	// Image is the container image used by the session.
+	Image string `json:"image"`
+	// ImageDigest is the sha256 digest of the image file, if it is a file.
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// Start and End are the times at which the session started and ended.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// ExitCode is the exit code of the session.
+	ExitCode int `json:"exitCode"`
+	// Typescript, Timing, and Input are the names of the files holding the
+	// output, the timing information, and (optionally) the input of the
+	// session.
+	Typescript string `json:"typescript"`
+	Timing     string `json:"timing"`
+	Input      string `json:"input,omitempty"`
+}
+
+// Options holds the options for Run.
+type Options struct {
+	// Dir is the directory in which the session recording is stored.
+	Dir string
+	// Keystrokes enables recording of the input, as well as the output, of
+	// the session.
+	Keystrokes bool
+	// Metadata is the initial metadata of the session. The fields describing
+	// the recording, times, and exit code are set by Run.
+	Metadata Metadata
+	// Digest, if set, is called concurrently with the session to compute the
+	// ImageDigest of the metadata.
+	Digest func() (string, error)
+}
+
+// recorder writes the streams of a session, with their timing in the
+// multi-stream format of script(1), to files.
+type recorder struct {
+	mu     sync.Mutex
+	last   time.Time
+	timing io.Writer
+	out    io.Writer
+	in     io.Writer
+}
+
+// record records data p, on stream 'O' (output) or 'I' (input).
+func (r *recorder) record(stream byte, p []byte) error {
+	w := r.out
+	if stream == 'I' {
+		w = r.in
+	}
+	if w == nil || len(p) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	delay := now.Sub(r.last)
+	r.last = now
+
+	if _, err := w.Write(p); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(r.timing, "%c %d.%06d %d\n", stream, delay/time.Second, (delay%time.Second)/time.Microsecond, len(p))
+	return err
+}
+
+// Run runs cmd in a new pseudo-terminal, relaying stdin and stdout to it, and
+// records the session to files in opts.Dir. If stdin is a terminal, it is put
+// in raw mode for the duration of the session, and its size is propagated to
+// the pseudo-terminal. Run returns the path of the session metadata file, and
+// the exit code of cmd.
+func Run(cmd *exec.Cmd, stdin *os.File, stdout io.Writer, opts Options) (string, int, error) {
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return "", 0, fmt.Errorf("while creating session recording directory: %w", err)
+	}
+
+	md := opts.Metadata
+	md.Start = time.Now()
+	name := fmt.Sprintf("%s-%s-%d", md.Start.UTC().Format("20060102T150405Z"), md.User, os.Getpid())
+	md.Typescript = name + ".typescript"
+	md.Timing = name + ".timing"
+	if opts.Keystrokes {
+		md.Input = name + ".input"
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	create := func(name string) (*os.File, error) {
+		f, err := os.OpenFile(filepath.Join(opts.Dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("while creating session recording file: %w", err)
+		}
+		files = append(files, f)
+		return f, nil
+	}
+
+	r := &recorder{last: md.Start}
+	var err error
+	if r.out, err = create(md.Typescript); err != nil {
+		return "", 0, err
+	}
+	if r.timing, err = create(md.Timing); err != nil {
+		return "", 0, err
+	}
+	if opts.Keystrokes {
+		if r.in, err = create(md.Input); err != nil {
+			return "", 0, err
+		}
+	}
+
+	digestCh := make(chan string, 1)
+	go func() {
+		if opts.Digest == nil {
+			digestCh <- ""
+			return
+		}
+		digest, err := opts.Digest()
+		if err != nil {
+			sylog.Warningf("Could not compute image digest for session recording: %v", err)
+		}
+		digestCh <- digest
+	}()
+
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return "", 0, fmt.Errorf("while starting session in pseudo-terminal: %w", err)
+	}
+	defer ptmx.Close()
+
+	if term.IsTerminal(int(stdin.Fd())) {
+		if err := pty.InheritSize(stdin, ptmx); err != nil {
+			sylog.Debugf("Could not set pseudo-terminal size: %v", err)
+		}
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+		go func() {
+			for range winch {
+				if err := pty.InheritSize(stdin, ptmx); err != nil {
+					sylog.Debugf("Could not set pseudo-terminal size: %v", err)
+				}
+			}
+		}()
+
+		state, err := term.MakeRaw(int(stdin.Fd()))
+		if err != nil {
+			return "", 0, fmt.Errorf("while setting terminal to raw mode: %w", err)
+		}
+		defer term.Restore(int(stdin.Fd()), state)
+	}
+
+	// The input is relayed until stdin is closed, or the process exits.
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				if _, err := ptmx.Write(buf[:n]); err != nil {
+					return
+				}
+				if err := r.record('I', buf[:n]); err != nil {
+					sylog.Warningf("While recording session input: %v", err)
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// The output is relayed until the pseudo-terminal is closed by the exit
+	// of all processes using it, which is reported as EIO.
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := ptmx.Read(buf)
+		if n > 0 {
+			if _, err := stdout.Write(buf[:n]); err != nil {
+				sylog.Debugf("While writing session output: %v", err)
+			}
+			if err := r.record('O', buf[:n]); err != nil {
+				sylog.Warningf("While recording session output: %v", err)
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, syscall.EIO) {
+				sylog.Warningf("While reading session output: %v", err)
+			}
+			break
+		}
+	}
+
+	err = cmd.Wait()
+	md.End = time.Now()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		md.ExitCode = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			md.ExitCode = 128 + int(status.Signal())
+		}
+	default:
+		return "", 0, err
+	}
+	md.ImageDigest = <-digestCh
+
+	mdPath := filepath.Join(opts.Dir, name+".json")
+	b, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return "", md.ExitCode, err
+	}
+	if err := os.WriteFile(mdPath, append(b, '\n'), 0o600); err != nil {
+		return "", md.ExitCode, fmt.Errorf("while writing session metadata: %w", err)
+	}
+	return mdPath, md.ExitCode, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		keystrokes bool
+	}{
+		{"OutputOnly", false},
+		{"Keystrokes", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "sessions")
+
+			stdin, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdin.Close()
+			if _, err := w.WriteString("hello\n"); err != nil {
+				t.Fatal(err)
+			}
+			w.Close()
+
+			var stdout bytes.Buffer
+			cmd := exec.Command("/bin/sh", "-c", "read x; echo got $x; exit 3")
+			mdPath, code, err := Run(cmd, stdin, &stdout, Options{
+				Dir:        dir,
+				Keystrokes: tt.keystrokes,
+				Metadata: Metadata{
+					User:    "test",
+					Command: []string{"singularity", "shell", "test.sif"},
+					Image:   "test.sif",
+				},
+				Digest: func() (string, error) { return "sha256:0123", nil },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != 3 {
+				t.Errorf("got exit code %d, want 3", code)
+			}
+			if !strings.Contains(stdout.String(), "got hello") {
+				t.Errorf("session output %q does not contain command output", stdout.String())
+			}
+
+			b, err := os.ReadFile(mdPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var md Metadata
+			if err := json.Unmarshal(b, &md); err != nil {
+				t.Fatal(err)
+			}
+			if md.ExitCode != 3 || md.User != "test" || md.ImageDigest != "sha256:0123" {
+				t.Errorf("unexpected metadata %+v", md)
+			}
+			if md.End.Before(md.Start) {
+				t.Errorf("session end %v is before start %v", md.End, md.Start)
+			}
+
+			ts, err := os.ReadFile(filepath.Join(dir, md.Typescript))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ts, stdout.Bytes()) {
+				t.Errorf("typescript %q does not match session output %q", ts, stdout.String())
+			}
+
+			var in []byte
+			if tt.keystrokes {
+				if in, err = os.ReadFile(filepath.Join(dir, md.Input)); err != nil {
+					t.Fatal(err)
+				}
+				if string(in) != "hello\n" {
+					t.Errorf("got input %q, want %q", in, "hello\n")
+				}
+			} else if md.Input != "" {
+				t.Errorf("input recorded without keystrokes option")
+			}
+
+			// The timing file must account for all of the recorded data.
+			f, err := os.Open(filepath.Join(dir, md.Timing))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			sizes := map[string]int{}
+			s := bufio.NewScanner(f)
+			for s.Scan() {
+				fields := strings.Fields(s.Text())
+				if len(fields) != 3 {
+					t.Fatalf("invalid timing line %q", s.Text())
+				}
+				n, err := strconv.Atoi(fields[2])
+				if err != nil {
+					t.Fatalf("invalid timing line %q", s.Text())
+				}
+				sizes[fields[0]] += n
+			}
+			if sizes["O"] != len(ts) || sizes["I"] != len(in) {
+				t.Errorf("timing sizes %v do not match recorded output %d, input %d", sizes, len(ts), len(in))
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential/ociauth"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/interactive"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
@@ -132,9 +133,9 @@ func (h *keyserverHandler) login(u *url.URL, username, password string, insecure
 	if insecure {
 		client.Transport = &http.Transport{
 			//#nosec G402
-			TLSClientConfig: &tls.Config{
+			TLSClientConfig: fips.TLSConfig(&tls.Config{
 				InsecureSkipVerify: true,
-			},
+			}),
 		}
 	}
 
@@ -47,30 +47,23 @@ func (ep *Config) KeyserverClientOpts(uri string, op KeyserverOp) ([]keyclient.O
 	if isDefault {
 		uri = primaryKeyserver.URI
 
-		if op == KeyserverVerifyOp {
-			// verify operation can query multiple keyserver, the token
-			// is automatically set by the custom client
-			keyservers = ep.Keyservers
-		} else {
-			// use the primary keyserver
-			keyservers = []*ServiceConfig{
-				primaryKeyserver,
-			}
-		}
+		// all operations use the ordered list of keyservers, with failover,
+		// or sync for push operations. The token is automatically set by the
+		// custom client
+		keyservers = ep.Keyservers
 	} else if ep.Exclusive {
 		available := make([]string, 0)
-		found := false
 		for _, kc := range ep.Keyservers {
 			if kc.Skip {
 				continue
 			}
 			available = append(available, kc.URI)
 			if remoteutil.SameKeyserver(uri, kc.URI) {
-				found = true
+				keyservers = []*ServiceConfig{kc}
 				break
 			}
 		}
-		if !found {
+		if keyservers == nil {
 			list := strings.Join(available, ", ")
 			return nil, fmt.Errorf(
 				"endpoint is set as exclusive by the system administrator: only %q can be used",
@@ -89,7 +82,7 @@ func (ep *Config) KeyserverClientOpts(uri string, op KeyserverOp) ([]keyclient.O
 	co := []keyclient.Option{
 		keyclient.OptBaseURL(uri),
 		keyclient.OptUserAgent(useragent.Value()),
-		keyclient.OptHTTPClient(newClient(keyservers, op)),
+		keyclient.OptHTTPClient(newClient(ep, keyservers, op)),
 	}
 	return co, nil
 }
@@ -113,6 +106,8 @@ func (ep *Config) LibraryClientConfig(uri string) (*libclient.Config, error) {
 		}
 		config.AuthToken = ep.Token
 		config.BaseURL = libURI
+		// the endpoint token is refreshed when it expired or is rejected
+		config.HTTPClient.Transport = &tokenTransport{ep: ep, base: http.DefaultTransport}
 	} else if ep.Exclusive {
 		libURI, err := ep.GetServiceURI(Library)
 		if err != nil {
@@ -31,16 +31,20 @@ var ErrNoURI = errors.New("no URI set for endpoint")
 
 // Config describes a single remote endpoint.
 type Config struct {
-	URI        string           `yaml:"URI,omitempty"` // hostname/path - no protocol expected
-	Token      string           `yaml:"Token,omitempty"`
-	System     bool             `yaml:"System"`             // Was this EndPoint set from system config file
-	Exclusive  bool             `yaml:"Exclusive"`          // true if the endpoint must be used exclusively
-	Insecure   bool             `yaml:"Insecure,omitempty"` // Allow use of http for service discovery
-	Keyservers []*ServiceConfig `yaml:"Keyservers,omitempty"`
+	URI          string           `yaml:"URI,omitempty"` // hostname/path - no protocol expected
+	Token        string           `yaml:"Token,omitempty"`
+	TokenExpiry  time.Time        `yaml:"TokenExpiry,omitempty"`  // zero if the token expiry is unknown
+	RefreshToken string           `yaml:"RefreshToken,omitempty"` // used to obtain a new token once it expired
+	System       bool             `yaml:"System"`                 // Was this EndPoint set from system config file
+	Exclusive    bool             `yaml:"Exclusive"`              // true if the endpoint must be used exclusively
+	Insecure     bool             `yaml:"Insecure,omitempty"`     // Allow use of http for service discovery
+	Keyservers   []*ServiceConfig `yaml:"Keyservers,omitempty"`
 
 	// for internal purpose
-	credentials []*credential.Config
-	services    map[string][]Service
+	credentials    []*credential.Config
+	services       map[string][]Service
+	staleTokens    map[string]bool
+	onTokenRefresh func(*Config) error
 }
 
 func (e *Config) SetCredentials(creds []*credential.Config) {
@@ -1,5 +1,5 @@
 // Copyright (c) 2020, Control Command Inc. All rights reserved.
-// Copyright (c) 2021, Sylabs Inc. All rights reserved.
+// Copyright (c) 2021-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -7,13 +7,16 @@
 package endpoint
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential"
 	remoteutil "github.com/sylabs/singularity/v4/internal/pkg/remote/util"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
 
@@ -153,61 +156,168 @@ func (ep *Config) UpdateKeyserversConfig() error {
 	return nil
 }
 
+// keyserverTransport sends keyserver requests to an ordered list of
+// keyservers. Push requests are sent to each keyserver, so that they are kept
+// in sync, and succeed if at least one keyserver accepts the key. Other
+// requests are sent to each keyserver in turn, until one of them succeeds.
+// The endpoint token used by the SCS key service is refreshed when it expired
+// or is rejected.
 type keyserverTransport struct {
+	ep         *Config
 	keyservers []*ServiceConfig
 	op         KeyserverOp
 	client     *http.Client
 }
 
 func (c *keyserverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	ctx := req.Context()
+	keyservers := make([]*ServiceConfig, 0, len(c.keyservers))
+	for _, k := range c.keyservers {
+		if !k.Skip {
+			keyservers = append(keyservers, k)
+		}
+	}
+	if len(keyservers) == 0 {
+		return nil, fmt.Errorf("no keyserver configured")
+	}
 
-	for i, k := range c.keyservers {
-		if k.Skip {
-			continue
+	// The request body is sent to each keyserver, so must be replayable.
+	if req.Body != nil && req.GetBody == nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
 		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+	}
 
-		cloneReq := req.Clone(ctx)
+	if c.op == KeyserverPushOp {
+		return c.push(req, keyservers)
+	}
+	return c.failover(req, keyservers)
+}
 
-		if i > 0 {
-			u, err := remoteutil.NormalizeKeyserverURI(k.URI)
-			if err != nil {
-				return nil, err
-			}
-			cloneReq.URL.Scheme = u.Scheme
-			cloneReq.URL.Host = u.Host
-			cloneReq.URL.User = u.User
+// failover sends req to each of keyservers in turn, and returns the first
+// successful response, or the last failure.
+func (c *keyserverTransport) failover(req *http.Request, keyservers []*ServiceConfig) (*http.Response, error) {
+	var (
+		resp *http.Response
+		err  error
+	)
+	for _, k := range keyservers {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = c.do(req, k)
+		if err == nil && resp.StatusCode/100 == 2 {
+			return resp, nil
+		}
+		if err != nil {
+			sylog.Debugf("Keyserver %s failed: %v", k.URI, err)
+		} else {
+			sylog.Debugf("Keyserver %s failed: %s", k.URI, resp.Status)
 		}
+	}
+	return resp, err
+}
 
-		sylog.Debugf("Querying keyserver %s", cloneReq.URL)
+// push sends req to each of keyservers, and returns the first successful
+// response, or the last failure if no keyserver accepted the request.
+func (c *keyserverTransport) push(req *http.Request, keyservers []*ServiceConfig) (*http.Response, error) {
+	var (
+		success  *http.Response
+		lastResp *http.Response
+		lastErr  error
+	)
+	for _, k := range keyservers {
+		resp, err := c.do(req, k)
+		if err == nil && resp.StatusCode/100 == 2 {
+			if success == nil {
+				success = resp
+			} else {
+				resp.Body.Close()
+			}
+			continue
+		}
 
-		cloneReq.Header.Del("Authorization")
-		if k.credential != nil && k.credential.Auth != "" {
-			cloneReq.Header.Set("Authorization", k.credential.Auth)
+		if err != nil {
+			sylog.Warningf("Failed to push to keyserver %s: %v", k.URI, err)
+		} else {
+			sylog.Warningf("Failed to push to keyserver %s: %s", k.URI, resp.Status)
 		}
+		if lastResp != nil {
+			lastResp.Body.Close()
+		}
+		lastResp, lastErr = resp, err
+	}
 
-		tr, ok := c.client.Transport.(*http.Transport)
-		if ok {
-			tr.TLSClientConfig.InsecureSkipVerify = k.Insecure
+	if success != nil {
+		if lastResp != nil {
+			lastResp.Body.Close()
 		}
+		return success, nil
+	}
+	return lastResp, lastErr
+}
 
-		resp, err := c.client.Do(cloneReq)
+// do sends req to keyserver k.
+func (c *keyserverTransport) do(req *http.Request, k *ServiceConfig) (*http.Response, error) {
+	cloneReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
 		if err != nil {
-			if i < len(c.keyservers)-1 {
-				continue
-			}
-			return resp, err
+			return nil, err
 		}
+		cloneReq.Body = body
+	}
 
-		if resp.StatusCode/100 != 2 && i < len(c.keyservers)-1 {
-			resp.Body.Close()
-			continue
+	u, err := remoteutil.NormalizeKeyserverURI(k.URI)
+	if err != nil {
+		return nil, err
+	}
+	cloneReq.URL.Scheme = u.Scheme
+	cloneReq.URL.Host = u.Host
+	cloneReq.URL.User = u.User
+	cloneReq.Host = ""
+
+	sylog.Debugf("Querying keyserver %s", cloneReq.URL)
+
+	cloneReq.Header.Del("Authorization")
+	auth, isEndpointToken := "", false
+	if k.credential != nil && k.credential.Auth != "" {
+		auth = k.credential.Auth
+		if !k.External && c.ep != nil {
+			auth, isEndpointToken = c.ep.endpointAuth(req.Context(), auth)
 		}
+		cloneReq.Header.Set("Authorization", auth)
+	}
+
+	tr, ok := c.client.Transport.(*http.Transport)
+	if ok {
+		tr.TLSClientConfig.InsecureSkipVerify = k.Insecure
+	}
 
+	resp, err := c.client.Do(cloneReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !isEndpointToken {
 		return resp, err
 	}
 
-	return nil, fmt.Errorf("no keyserver configured")
+	auth, ok = c.ep.refreshAuth(req.Context(), auth)
+	if !ok {
+		return resp, nil
+	}
+	resp.Body.Close()
+	retryReq := cloneReq.Clone(cloneReq.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retryReq.Body = body
+	}
+	retryReq.Header.Set("Authorization", auth)
+	return c.client.Do(retryReq)
 }
 
 var defaultClient = &http.Client{
@@ -221,9 +331,11 @@ var defaultClient = &http.Client{
 	},
 }
 
-func newClient(keyservers []*ServiceConfig, op KeyserverOp) *http.Client {
+func newClient(ep *Config, keyservers []*ServiceConfig, op KeyserverOp) *http.Client {
+	fips.ConfigureTransport(defaultClient.Transport)
 	return &http.Client{
 		Transport: &keyserverTransport{
+			ep:         ep,
 			keyservers: keyservers,
 			op:         op,
 			client:     defaultClient,
@@ -6,7 +6,11 @@
 package endpoint
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential"
@@ -187,3 +191,162 @@ func TestAddRemoveKeyserver(t *testing.T) {
 		})
 	}
 }
+
+// testKeyserver records the bodies of the requests it receives, and responds
+// with status.
+type testKeyserver struct {
+	*httptest.Server
+	bodies []string
+}
+
+func newTestKeyserver(t *testing.T, status int) *testKeyserver {
+	ks := &testKeyserver{}
+	ks.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		ks.bodies = append(ks.bodies, string(b))
+		w.WriteHeader(status)
+		io.WriteString(w, ks.URL)
+	}))
+	t.Cleanup(ks.Close)
+	return ks
+}
+
+func TestKeyserverTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		op         KeyserverOp
+		statuses   []int
+		skip       []bool
+		wantStatus int
+		wantFrom   int
+		wantCalls  []int
+	}{
+		{
+			name:       "PullPrimary",
+			op:         KeyserverPullOp,
+			statuses:   []int{http.StatusOK, http.StatusOK},
+			wantStatus: http.StatusOK,
+			wantFrom:   0,
+			wantCalls:  []int{1, 0},
+		},
+		{
+			name:       "PullFailover",
+			op:         KeyserverPullOp,
+			statuses:   []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusOK},
+			wantStatus: http.StatusOK,
+			wantFrom:   2,
+			wantCalls:  []int{1, 1, 1},
+		},
+		{
+			name:       "SearchAllFail",
+			op:         KeyserverSearchOp,
+			statuses:   []int{http.StatusInternalServerError, http.StatusNotFound},
+			wantStatus: http.StatusNotFound,
+			wantFrom:   1,
+			wantCalls:  []int{1, 1},
+		},
+		{
+			name:       "VerifySkipped",
+			op:         KeyserverVerifyOp,
+			statuses:   []int{http.StatusOK, http.StatusNotFound, http.StatusOK},
+			skip:       []bool{true, false, false},
+			wantStatus: http.StatusOK,
+			wantFrom:   2,
+			wantCalls:  []int{0, 1, 1},
+		},
+		{
+			name:       "PushSync",
+			op:         KeyserverPushOp,
+			statuses:   []int{http.StatusOK, http.StatusOK},
+			wantStatus: http.StatusOK,
+			wantFrom:   0,
+			wantCalls:  []int{1, 1},
+		},
+		{
+			name:       "PushPartialFailure",
+			op:         KeyserverPushOp,
+			statuses:   []int{http.StatusInternalServerError, http.StatusOK},
+			wantStatus: http.StatusOK,
+			wantFrom:   1,
+			wantCalls:  []int{1, 1},
+		},
+		{
+			name:       "PushAllFail",
+			op:         KeyserverPushOp,
+			statuses:   []int{http.StatusInternalServerError, http.StatusForbidden},
+			wantStatus: http.StatusForbidden,
+			wantFrom:   1,
+			wantCalls:  []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers := make([]*testKeyserver, len(tt.statuses))
+			keyservers := make([]*ServiceConfig, len(tt.statuses))
+			for i, status := range tt.statuses {
+				servers[i] = newTestKeyserver(t, status)
+				keyservers[i] = &ServiceConfig{URI: servers[i].URL, External: true}
+				if tt.skip != nil {
+					keyservers[i].Skip = tt.skip[i]
+				}
+			}
+
+			// Requests are built against the primary keyserver URI.
+			req, err := http.NewRequest(http.MethodPost, servers[0].URL+"/pks/add", strings.NewReader("keytext=key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Body = io.NopCloser(req.Body)
+			req.GetBody = nil
+
+			resp, err := newClient(nil, keyservers, tt.op).Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(b), servers[tt.wantFrom].URL; got != want {
+				t.Errorf("got response from %s, want %s", got, want)
+			}
+			for i, s := range servers {
+				if len(s.bodies) != tt.wantCalls[i] {
+					t.Errorf("keyserver %d got %d requests, want %d", i, len(s.bodies), tt.wantCalls[i])
+				}
+				for _, body := range s.bodies {
+					if body != "keytext=key" {
+						t.Errorf("keyserver %d got body %q", i, body)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKeyserverTransportUnreachable(t *testing.T) {
+	ks := newTestKeyserver(t, http.StatusOK)
+	keyservers := []*ServiceConfig{
+		{URI: "http://127.0.0.1:1", External: true},
+		{URI: ks.URL, External: true},
+	}
+
+	resp, err := newClient(nil, keyservers, KeyserverPullOp).Get("http://127.0.0.1:1/pks/lookup")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if _, err := newClient(nil, keyservers[:1], KeyserverPullOp).Get("http://127.0.0.1:1/pks/lookup"); err == nil {
+		t.Errorf("unexpected success with unreachable keyserver")
+	}
+}
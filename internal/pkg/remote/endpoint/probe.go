@@ -0,0 +1,197 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package endpoint
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+// ProbeTimeout is the maximum time allowed to probe a service.
+const ProbeTimeout = 15 * time.Second
+
+// ProbeResult holds the reachability, TLS certificate and response times of a
+// remote service.
+type ProbeResult struct {
+	// Reachable is true when a connection to the service was established.
+	Reachable bool `json:"reachable" yaml:"reachable"`
+	// StatusCode is the HTTP status of the probe request, if any.
+	StatusCode int `json:"statusCode,omitempty" yaml:"statusCode,omitempty"`
+	// Version is the version reported by the service, if supported.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// Error is set when the probe failed.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+	// TLS is set for services accessed with https.
+	TLS *TLSResult `json:"tls,omitempty" yaml:"tls,omitempty"`
+	// Timings holds the duration of each phase of the probe request.
+	Timings ProbeTimings `json:"timings" yaml:"timings"`
+}
+
+// TLSResult holds the validity of the TLS certificate of a service.
+type TLSResult struct {
+	Valid bool `json:"valid" yaml:"valid"`
+	// Unverified is true when the certificate wasn't verified, for services
+	// configured as insecure.
+	Unverified bool       `json:"unverified,omitempty" yaml:"unverified,omitempty"`
+	Error      string     `json:"error,omitempty" yaml:"error,omitempty"`
+	Version    string     `json:"version,omitempty" yaml:"version,omitempty"`
+	Subject    string     `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Issuer     string     `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	NotAfter   *time.Time `json:"notAfter,omitempty" yaml:"notAfter,omitempty"`
+}
+
+// ProbeTimings holds the duration, in milliseconds, of each phase of a probe
+// request.
+type ProbeTimings struct {
+	DNS       float64 `json:"dnsMs" yaml:"dnsMs"`
+	Connect   float64 `json:"connectMs" yaml:"connectMs"`
+	TLS       float64 `json:"tlsMs" yaml:"tlsMs"`
+	FirstByte float64 `json:"firstByteMs" yaml:"firstByteMs"`
+	Total     float64 `json:"totalMs" yaml:"totalMs"`
+}
+
+// OK returns whether the service was reachable, with a valid certificate, and
+// responded successfully.
+func (r *ProbeResult) OK() bool {
+	return r.Reachable && r.Error == "" && (r.TLS == nil || r.TLS.Valid || r.TLS.Unverified)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// ProbeService sends a request for the version of the service at uri, and
+// returns its reachability, TLS certificate validity and response times. The
+// version is requested only from SCS services, and the root of external
+// services is requested instead. The TLS certificate isn't verified when
+// insecure is true.
+func ProbeService(ctx context.Context, uri string, external, insecure bool) *ProbeResult {
+	res := &ProbeResult{}
+
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+
+	var start, dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			res.Timings.DNS = ms(time.Since(dnsStart))
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				res.Reachable = true
+				res.Timings.Connect = ms(time.Since(connectStart))
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(cs tls.ConnectionState, _ error) {
+			res.Timings.TLS = ms(time.Since(tlsStart))
+			setCertificate(res, cs, cs.PeerCertificates)
+		},
+		GotFirstResponseByte: func() {
+			res.Timings.FirstByte = ms(time.Since(start))
+		},
+	}
+
+	reqURI := strings.TrimSuffix(uri, "/") + "/"
+	if !external {
+		reqURI += "version"
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, reqURI, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	req.Header.Set("User-Agent", useragent.Value())
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+			TLSClientConfig: fips.TLSConfig(&tls.Config{
+				MinVersion:         tls.VersionTLS12,
+				InsecureSkipVerify: insecure, //nolint:gosec
+			}),
+		},
+	}
+
+	if req.URL.Scheme == "https" {
+		res.TLS = &TLSResult{}
+	}
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	res.Timings.Total = ms(time.Since(start))
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) && res.TLS != nil {
+			// the certificate is recorded even though it isn't valid
+			setCertificate(res, tls.ConnectionState{}, certErr.UnverifiedCertificates)
+			res.TLS.Error = certErr.Err.Error()
+		}
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS != nil && res.TLS != nil {
+		setCertificate(res, *resp.TLS, resp.TLS.PeerCertificates)
+		res.TLS.Valid = !insecure
+		res.TLS.Unverified = insecure
+	}
+
+	res.StatusCode = resp.StatusCode
+	if external {
+		// any response shows the service is available
+		if resp.StatusCode >= http.StatusInternalServerError {
+			res.Error = resp.Status
+		}
+		io.Copy(io.Discard, resp.Body)
+		return res
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		res.Error = resp.Status
+		return res
+	}
+	var vRes struct {
+		Version string `json:"version"`
+	}
+	if err := jsonresp.ReadResponse(resp.Body, &vRes); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Version = vRes.Version
+	return res
+}
+
+// setCertificate records the TLS version of cs, and the leaf certificate of
+// certs, in res.
+func setCertificate(res *ProbeResult, cs tls.ConnectionState, certs []*x509.Certificate) {
+	if res.TLS == nil || len(certs) == 0 {
+		return
+	}
+	cert := certs[0]
+	if cs.Version != 0 {
+		res.TLS.Version = tls.VersionName(cs.Version)
+	}
+	res.TLS.Subject = cert.Subject.String()
+	res.TLS.Issuer = cert.Issuer.String()
+	notAfter := cert.NotAfter.UTC()
+	res.TLS.NotAfter = &notAfter
+}
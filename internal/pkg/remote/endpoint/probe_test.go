@@ -0,0 +1,82 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+func TestProbeService(t *testing.T) {
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			w.Write([]byte(`{"data":{"version":"1.2.3"}}`))
+		case "/":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(handler)
+	defer tlsSrv.Close()
+
+	tests := []struct {
+		name          string
+		uri           string
+		external      bool
+		insecure      bool
+		wantOK        bool
+		wantReachable bool
+		wantVersion   string
+		wantTLS       bool
+	}{
+		{name: "HTTP", uri: srv.URL, wantOK: true, wantReachable: true, wantVersion: "1.2.3"},
+		{name: "External", uri: srv.URL, external: true, wantOK: true, wantReachable: true},
+		{name: "ServerError", uri: srv.URL + "/error", wantReachable: true},
+		{name: "ExternalServerError", uri: srv.URL + "/error", external: true, wantReachable: true},
+		{name: "UntrustedCertificate", uri: tlsSrv.URL, wantReachable: true, wantTLS: true},
+		{name: "Insecure", uri: tlsSrv.URL, insecure: true, wantOK: true, wantReachable: true, wantVersion: "1.2.3", wantTLS: true},
+		{name: "Unreachable", uri: "http://127.0.0.1:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ProbeService(context.Background(), tt.uri, tt.external, tt.insecure)
+			if res.OK() != tt.wantOK {
+				t.Errorf("got OK %v, want %v (error: %s)", res.OK(), tt.wantOK, res.Error)
+			}
+			if res.Reachable != tt.wantReachable {
+				t.Errorf("got reachable %v, want %v", res.Reachable, tt.wantReachable)
+			}
+			if res.Version != tt.wantVersion {
+				t.Errorf("got version %q, want %q", res.Version, tt.wantVersion)
+			}
+			if (res.TLS != nil) != tt.wantTLS {
+				t.Fatalf("got TLS result %v, want %v", res.TLS != nil, tt.wantTLS)
+			}
+			if res.TLS != nil {
+				// the certificate of the test server is recorded, even if invalid
+				if res.TLS.NotAfter == nil || res.TLS.Issuer == "" {
+					t.Errorf("certificate not recorded: %+v", res.TLS)
+				}
+				if res.TLS.Valid || res.TLS.Unverified != tt.insecure {
+					t.Errorf("unexpected TLS result %+v", res.TLS)
+				}
+			}
+			if tt.wantReachable && res.Timings.Total <= 0 {
+				t.Errorf("no response time recorded")
+			}
+		})
+	}
+}
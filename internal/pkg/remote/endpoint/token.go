@@ -1,5 +1,5 @@
 // Copyright (c) 2020, Control Command Inc. All rights reserved.
-// Copyright (c) 2019-2021, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -7,10 +7,19 @@
 package endpoint
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/auth"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
 )
@@ -66,3 +75,249 @@ func (ep *Config) VerifyToken(token string) (err error) {
 
 	return nil
 }
+
+// TokenExpiryWarning is the time before the expiry of a token from which users
+// are warned that it will expire soon.
+const TokenExpiryWarning = 7 * 24 * time.Hour
+
+// ErrNoRefreshToken is returned when a token can't be refreshed, as no refresh
+// token is set for the endpoint.
+var ErrNoRefreshToken = errors.New("no refresh token set (please login again)")
+
+// tokenMu guards the tokens of all endpoints, which may be refreshed by
+// concurrent requests.
+var tokenMu sync.Mutex
+
+// SetToken sets the token of the endpoint, with the refresh token used to
+// obtain a new token once it expired, and its lifetime. When expiresIn is 0,
+// the expiry is read from the token itself, if it is a JWT.
+func (ep *Config) SetToken(token, refreshToken string, expiresIn time.Duration) {
+	ep.Token = token
+	ep.RefreshToken = refreshToken
+	ep.TokenExpiry = time.Time{}
+	if expiresIn > 0 {
+		ep.TokenExpiry = time.Now().Add(expiresIn).UTC().Truncate(time.Second)
+	} else if exp, ok := jwtExpiry(token); ok {
+		ep.TokenExpiry = exp
+	}
+
+	// the SCS key service is authenticated with the endpoint token
+	for _, kc := range ep.Keyservers {
+		if !kc.External && kc.credential != nil {
+			kc.credential.Auth = credential.TokenPrefix + token
+		}
+	}
+}
+
+// TokenExpiresIn returns the time left before the token of the endpoint
+// expires, which is negative if it already expired. It returns false if the
+// token expiry is unknown.
+func (ep *Config) TokenExpiresIn() (time.Duration, bool) {
+	if ep.Token == "" || ep.TokenExpiry.IsZero() {
+		return 0, false
+	}
+	return time.Until(ep.TokenExpiry), true
+}
+
+// SetTokenRefreshHandler sets a function called with the endpoint after its
+// token has been refreshed, to persist the new token.
+func (ep *Config) SetTokenRefreshHandler(f func(*Config) error) {
+	ep.onTokenRefresh = f
+}
+
+// RefreshAccessToken obtains a new token for the endpoint from its token
+// service, with an OAuth 2.0 refresh token grant (RFC 6749 section 6).
+func (ep *Config) RefreshAccessToken(ctx context.Context) error {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	return ep.refreshToken(ctx)
+}
+
+// refreshToken implements RefreshAccessToken, with tokenMu held.
+func (ep *Config) refreshToken(ctx context.Context) error {
+	if ep.RefreshToken == "" {
+		return ErrNoRefreshToken
+	}
+
+	sp, err := ep.GetAllServices()
+	if err != nil {
+		return err
+	}
+
+	ts, ok := sp[Token]
+	if !ok || len(ts) == 0 {
+		return fmt.Errorf("no authentication service found")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {ep.RefreshToken},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts[0].URI()+"/v1/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", useragent.Value())
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request to server: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		convStatus, ok := errorCodeMap[res.StatusCode]
+		if !ok {
+			convStatus = "Unknown"
+		}
+		return fmt.Errorf("error response from server: %v", convStatus)
+	}
+
+	var tr auth.TokenSet
+	if err := json.NewDecoder(res.Body).Decode(&tr); err != nil {
+		return fmt.Errorf("while decoding token response: %v", err)
+	}
+	if tr.AccessToken == "" {
+		return fmt.Errorf("no token in token response")
+	}
+	// the refresh token may be kept when a new one isn't issued
+	refreshToken := tr.RefreshToken
+	if refreshToken == "" {
+		refreshToken = ep.RefreshToken
+	}
+
+	if ep.staleTokens == nil {
+		ep.staleTokens = make(map[string]bool)
+	}
+	ep.staleTokens[ep.Token] = true
+	ep.SetToken(tr.AccessToken, refreshToken, time.Duration(tr.ExpiresIn)*time.Second)
+	sylog.Debugf("Authentication token refreshed")
+
+	if ep.onTokenRefresh != nil {
+		if err := ep.onTokenRefresh(ep); err != nil {
+			sylog.Warningf("Failed to store refreshed authentication token: %v", err)
+		}
+	}
+	return nil
+}
+
+// jwtExpiry returns the expiry time held by the exp claim of token, if it is
+// a JWT. The token signature is not verified.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0).UTC(), true
+}
+
+// endpointAuth returns the Authorization header value to send in place of
+// auth, and whether auth holds a token of the endpoint. A token of the
+// endpoint is replaced with its current token, which is refreshed first if it
+// expired.
+func (ep *Config) endpointAuth(ctx context.Context, auth string) (string, bool) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if ep.Token == "" || !strings.HasPrefix(auth, credential.TokenPrefix) {
+		return auth, false
+	}
+	token := strings.TrimPrefix(auth, credential.TokenPrefix)
+	if token != ep.Token && !ep.staleTokens[token] {
+		return auth, false
+	}
+
+	if d, ok := ep.TokenExpiresIn(); ok && d <= 0 && ep.RefreshToken != "" {
+		if err := ep.refreshToken(ctx); err != nil {
+			sylog.Warningf("Failed to refresh expired authentication token: %v", err)
+		}
+	}
+	return credential.TokenPrefix + ep.Token, true
+}
+
+// refreshAuth refreshes the token of the endpoint after the server rejected
+// auth with a 401 status, and returns the Authorization header value to retry
+// the request with. It returns false if the request should not be retried, as
+// auth doesn't hold a token of the endpoint, or the token couldn't be
+// refreshed.
+func (ep *Config) refreshAuth(ctx context.Context, auth string) (string, bool) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if ep.RefreshToken == "" || !strings.HasPrefix(auth, credential.TokenPrefix) {
+		return "", false
+	}
+	token := strings.TrimPrefix(auth, credential.TokenPrefix)
+	if ep.staleTokens[token] {
+		// already refreshed by a concurrent request
+		return credential.TokenPrefix + ep.Token, true
+	} else if token != ep.Token {
+		return "", false
+	}
+
+	if err := ep.refreshToken(ctx); err != nil {
+		sylog.Warningf("Failed to refresh authentication token: %v", err)
+		return "", false
+	}
+	return credential.TokenPrefix + ep.Token, true
+}
+
+// tokenTransport authenticates requests sent with a token of the endpoint
+// with its current token, refreshing it when it expired or is rejected.
+type tokenTransport struct {
+	ep   *Config
+	base http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	auth, ok := t.ep.endpointAuth(req.Context(), req.Header.Get("Authorization"))
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	resp, err := t.base.RoundTrip(withAuth(req, auth))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// the request can be retried only if its body is replayable
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	auth, ok = t.ep.refreshAuth(req.Context(), auth)
+	if !ok {
+		return resp, nil
+	}
+	retryReq := withAuth(req, auth)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retryReq.Body = body
+	}
+	resp.Body.Close()
+	return t.base.RoundTrip(retryReq)
+}
+
+// withAuth returns a copy of req with the Authorization header set to auth.
+func withAuth(req *http.Request, auth string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", auth)
+	return r
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package endpoint
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+// testJWT returns an unsigned JWT with the exp claim set to exp.
+func testJWT(t *testing.T, exp time.Time) string {
+	claims, err := json.Marshal(map[string]any{"sub": "user", "exp": exp.Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString(claims) + ".sig"
+}
+
+func TestSetToken(t *testing.T) {
+	exp := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name       string
+		token      string
+		expiresIn  time.Duration
+		wantExpiry bool
+	}{
+		{name: "JWT", token: testJWT(t, exp), wantExpiry: true},
+		{name: "ExpiresIn", token: "opaque", expiresIn: time.Hour, wantExpiry: true},
+		{name: "Unknown", token: "opaque"},
+		{name: "InvalidJWT", token: "a.b.c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep := &Config{
+				Keyservers: []*ServiceConfig{
+					{URI: "https://keys.example.com", credential: &credential.Config{}},
+					{URI: "https://external.example.com", External: true, credential: &credential.Config{Auth: "Basic x"}},
+				},
+			}
+			ep.SetToken(tt.token, "refresh", tt.expiresIn)
+
+			d, ok := ep.TokenExpiresIn()
+			if ok != tt.wantExpiry {
+				t.Fatalf("got expiry %v, want %v", ok, tt.wantExpiry)
+			}
+			if ok && (d <= 0 || d > time.Hour) {
+				t.Errorf("unexpected time before expiry %v", d)
+			}
+			if got := ep.Keyservers[0].credential.Auth; got != credential.TokenPrefix+tt.token {
+				t.Errorf("got keyserver auth %q", got)
+			}
+			if got := ep.Keyservers[1].credential.Auth; got != "Basic x" {
+				t.Errorf("got external keyserver auth %q", got)
+			}
+		})
+	}
+}
+
+// tokenServer is a token service issuing tokens from a refresh token, and
+// an API accepting the last token issued.
+type tokenServer struct {
+	*httptest.Server
+	token     string
+	refreshes int
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	ts := &tokenServer{token: "token-0"}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/token" {
+			if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			ts.refreshes++
+			ts.token = "token-" + strings.Repeat("x", ts.refreshes)
+			json.NewEncoder(w).Encode(map[string]any{"access_token": ts.token, "expires_in": 3600})
+			return
+		}
+		if r.Header.Get("Authorization") != credential.TokenPrefix+ts.token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *tokenServer) endpoint(token, refreshToken string) *Config {
+	ep := &Config{
+		services: map[string][]Service{
+			Token: {&service{cfg: &ServiceConfig{URI: ts.URL}}},
+		},
+	}
+	ep.SetToken(token, refreshToken, 0)
+	return ep
+}
+
+func TestTokenTransport(t *testing.T) {
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	tests := []struct {
+		name         string
+		token        string
+		refreshToken string
+		auth         string
+		wantStatus   int
+		wantRefresh  int
+	}{
+		{name: "Valid", token: "token-0", refreshToken: "refresh", wantStatus: http.StatusOK},
+		{name: "Rejected", token: "revoked", refreshToken: "refresh", wantStatus: http.StatusOK, wantRefresh: 1},
+		{name: "Expired", token: testJWT(t, time.Now().Add(-time.Hour)), refreshToken: "refresh", wantStatus: http.StatusOK, wantRefresh: 1},
+		{name: "NoRefreshToken", token: "revoked", wantStatus: http.StatusUnauthorized},
+		{name: "InvalidRefreshToken", token: "revoked", refreshToken: "invalid", wantStatus: http.StatusUnauthorized},
+		{name: "OtherAuth", token: "revoked", refreshToken: "refresh", auth: "Bearer other", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTokenServer(t)
+			ep := ts.endpoint(tt.token, tt.refreshToken)
+
+			var stored *Config
+			ep.SetTokenRefreshHandler(func(ep *Config) error {
+				stored = ep
+				return nil
+			})
+
+			auth := tt.auth
+			if auth == "" {
+				auth = credential.TokenPrefix + tt.token
+			}
+
+			c := &http.Client{Transport: &tokenTransport{ep: ep, base: http.DefaultTransport}}
+			// the second request uses the refreshed token directly
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/images", strings.NewReader("body"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", auth)
+
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+				}
+			}
+
+			if ts.refreshes != tt.wantRefresh {
+				t.Errorf("got %d refreshes, want %d", ts.refreshes, tt.wantRefresh)
+			}
+			if tt.wantRefresh > 0 {
+				if stored != ep || ep.Token != ts.token || ep.RefreshToken != "refresh" {
+					t.Errorf("refreshed token not stored")
+				}
+				if d, ok := ep.TokenExpiresIn(); !ok || d <= 0 {
+					t.Errorf("refreshed token expiry not set")
+				}
+			}
+		})
+	}
+}
+
+func TestKeyserverTransportRefresh(t *testing.T) {
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	ts := newTokenServer(t)
+	ep := ts.endpoint("revoked", "refresh")
+	ep.Keyservers = []*ServiceConfig{{URI: ts.URL}}
+	if err := ep.UpdateKeyserversConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := newClient(ep, ep.Keyservers, KeyserverPullOp).Get("http://localhost/pks/lookup")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ts.refreshes != 1 {
+		t.Errorf("got %d refreshes, want 1", ts.refreshes)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ProjectConfigName is the name of a per-project remote configuration file,
+// looked up in the working directory and its parents.
+const ProjectConfigName = ".singularity-remote.yaml"
+
+// ProjectConfig overrides, for the project in the directory holding it, the
+// remote endpoint in use, its keyservers, and the file holding registry
+// credentials, without changing the default remote.
+type ProjectConfig struct {
+	// Remote is the name of a configured remote endpoint to use.
+	Remote string `yaml:"Remote,omitempty"`
+	// URI is the URI of the remote endpoint to use, when Remote is not set.
+	// The token of a configured remote endpoint with the same URI is used.
+	URI      string `yaml:"URI,omitempty"`
+	Insecure bool   `yaml:"Insecure,omitempty"`
+	// Keyservers replaces the keyservers of the remote endpoint. They are
+	// authenticated with the credentials from 'keyserver login'.
+	Keyservers []*endpoint.ServiceConfig `yaml:"Keyservers,omitempty"`
+	// AuthFile is the Docker-style file holding OCI registry credentials,
+	// relative to the project directory.
+	AuthFile string `yaml:"AuthFile,omitempty"`
+
+	// Path is the path of the file the configuration was read from.
+	Path string `yaml:"-"`
+}
+
+// FindProjectConfig looks up a ProjectConfigName file in dir and its parents,
+// and returns the configuration it holds. Only files located under one of the
+// allowed directories, owned by the current user or root, and not writable by
+// others are used. It returns nil if no such file is found.
+func FindProjectConfig(dir string, allowed []string) (*ProjectConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectConfigName)
+		if _, err := os.Lstat(path); err == nil {
+			if !underAllowedDir(dir, allowed) {
+				sylog.Debugf("Ignoring %s, not located under a 'project remote paths' directory", path)
+				return nil, nil
+			}
+			return readProjectConfig(path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// underAllowedDir returns whether dir is one of, or located under one of, the
+// allowed directories.
+func underAllowedDir(dir string, allowed []string) bool {
+	for _, a := range allowed {
+		a = filepath.Clean(a)
+		if !filepath.IsAbs(a) {
+			continue
+		}
+		if dir == a || strings.HasPrefix(dir, strings.TrimSuffix(a, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func readProjectConfig(path string) (*ProjectConfig, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if int(st.Uid) != os.Getuid() && st.Uid != 0 {
+			return nil, fmt.Errorf("%s is not owned by the current user or root", path)
+		}
+	}
+	if fi.Mode().Perm()&0o022 != 0 {
+		return nil, fmt.Errorf("%s is writable by group or others", path)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &ProjectConfig{Path: path}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("while parsing %s: %w", path, err)
+	}
+
+	if p.Remote != "" && p.URI != "" {
+		return nil, fmt.Errorf("%s: Remote and URI are mutually exclusive", path)
+	}
+	for _, kc := range p.Keyservers {
+		if kc.URI == "" {
+			return nil, fmt.Errorf("%s: keyserver without URI", path)
+		}
+		kc.External = true
+	}
+	if p.AuthFile != "" && !filepath.IsAbs(p.AuthFile) {
+		p.AuthFile = filepath.Join(filepath.Dir(path), p.AuthFile)
+	}
+	return p, nil
+}
+
+// Endpoint returns the remote endpoint selected by p, among the remote
+// endpoints of c, and its name in c. The name is empty when the endpoint is
+// not configured in c. The default remote endpoint of c is used when p
+// doesn't select one.
+func (p *ProjectConfig) Endpoint(c *Config) (string, *endpoint.Config, error) {
+	name, ep, err := p.selectEndpoint(c)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// a remote endpoint set exclusive by the system administrator can't be
+	// overridden by a project
+	for n, r := range c.Remotes {
+		if r.Exclusive && r != ep {
+			return "", nil, fmt.Errorf(
+				"could not use project remote: remote %s has been set exclusive by the system administrator", n,
+			)
+		}
+	}
+
+	if len(p.Keyservers) > 0 {
+		if ep.Exclusive {
+			return "", nil, fmt.Errorf(
+				"could not override keyservers: remote %s has been set exclusive by the system administrator", name,
+			)
+		}
+		override := *ep
+		override.Keyservers = p.Keyservers
+		ep = &override
+	}
+	return name, ep, nil
+}
+
+func (p *ProjectConfig) selectEndpoint(c *Config) (string, *endpoint.Config, error) {
+	if p.Remote != "" {
+		ep, err := c.GetRemote(p.Remote)
+		return p.Remote, ep, err
+	}
+
+	if p.URI != "" {
+		// endpoint URIs are recorded without protocol, as by 'remote add'
+		u, err := url.Parse(p.URI)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid URI %q: %w", p.URI, err)
+		}
+		uri := p.URI
+		if u.Host != "" {
+			uri = path.Join(u.Host + u.Path)
+		}
+
+		for n, r := range c.Remotes {
+			if strings.TrimSuffix(r.URI, "/") == uri && r.Insecure == p.Insecure {
+				ep, err := c.GetRemote(n)
+				return n, ep, err
+			}
+		}
+		ep := &endpoint.Config{URI: uri, Insecure: p.Insecure}
+		ep.SetCredentials(c.Credentials)
+		return "", ep, nil
+	}
+
+	ep, err := c.GetDefault()
+	if err == ErrNoDefault && len(c.Remotes) == 0 {
+		return "", endpoint.DefaultEndpointConfig, nil
+	}
+	return c.DefaultRemote, ep, err
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
+)
+
+func writeProjectConfig(t *testing.T, dir, content string, perm os.FileMode) {
+	path := filepath.Join(dir, ProjectConfigName)
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	subdir := filepath.Join(project, "src", "pkg")
+	if err := os.MkdirAll(subdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// no project configuration
+	p, err := FindProjectConfig(subdir, []string{root})
+	if err != nil || p != nil {
+		t.Fatalf("got %v, %v, want no project configuration", p, err)
+	}
+
+	writeProjectConfig(t, project, "Remote: team\nAuthFile: auth.json\nKeyservers:\n  - URI: https://keys.example.com\n", 0o644)
+
+	tests := []struct {
+		name    string
+		dir     string
+		allowed []string
+		want    bool
+	}{
+		{name: "ProjectDir", dir: project, allowed: []string{root}, want: true},
+		{name: "Subdir", dir: subdir, allowed: []string{root}, want: true},
+		{name: "AllowedProjectDir", dir: subdir, allowed: []string{project + "/"}, want: true},
+		{name: "NotAllowed", dir: subdir, allowed: []string{subdir}},
+		{name: "PrefixNotDir", dir: subdir, allowed: []string{project + "-other", project[:len(project)-1]}},
+		{name: "RelativeAllowed", dir: subdir, allowed: []string{"project"}},
+		{name: "NoneAllowed", dir: subdir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := FindProjectConfig(tt.dir, tt.allowed)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (p != nil) != tt.want {
+				t.Fatalf("got project configuration %v, want %v", p != nil, tt.want)
+			}
+			if p == nil {
+				return
+			}
+			if p.Path != filepath.Join(project, ProjectConfigName) {
+				t.Errorf("got path %s", p.Path)
+			}
+			if p.Remote != "team" {
+				t.Errorf("got remote %q, want %q", p.Remote, "team")
+			}
+			if want := filepath.Join(project, "auth.json"); p.AuthFile != want {
+				t.Errorf("got auth file %q, want %q", p.AuthFile, want)
+			}
+			if len(p.Keyservers) != 1 || !p.Keyservers[0].External {
+				t.Errorf("unexpected keyservers %v", p.Keyservers)
+			}
+		})
+	}
+
+	invalid := []struct {
+		name    string
+		content string
+		perm    os.FileMode
+	}{
+		{name: "WorldWritable", content: "Remote: team\n", perm: 0o666},
+		{name: "UnknownField", content: "Remote: team\nToken: secret\n", perm: 0o644},
+		{name: "RemoteAndURI", content: "Remote: team\nURI: cloud.example.com\n", perm: 0o644},
+		{name: "KeyserverWithoutURI", content: "Keyservers:\n  - Insecure: true\n", perm: 0o644},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			writeProjectConfig(t, project, tt.content, tt.perm)
+			if _, err := FindProjectConfig(subdir, []string{root}); err == nil {
+				t.Errorf("unexpected success")
+			}
+		})
+	}
+}
+
+func TestProjectConfigEndpoint(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			DefaultRemote: "default",
+			Remotes: map[string]*endpoint.Config{
+				"default": {URI: "cloud.example.com", Token: "default-token"},
+				"team":    {URI: "team.example.com", Token: "team-token"},
+			},
+		}
+	}
+	keyservers := []*endpoint.ServiceConfig{{URI: "https://keys.example.com", External: true}}
+
+	tests := []struct {
+		name           string
+		p              ProjectConfig
+		exclusive      string
+		wantName       string
+		wantURI        string
+		wantToken      string
+		wantKeyservers bool
+		wantErr        bool
+	}{
+		{name: "Default", wantName: "default", wantURI: "cloud.example.com", wantToken: "default-token"},
+		{name: "Remote", p: ProjectConfig{Remote: "team"}, wantName: "team", wantURI: "team.example.com", wantToken: "team-token"},
+		{name: "UnknownRemote", p: ProjectConfig{Remote: "unknown"}, wantErr: true},
+		{name: "KnownURI", p: ProjectConfig{URI: "https://team.example.com"}, wantName: "team", wantURI: "team.example.com", wantToken: "team-token"},
+		{name: "NewURI", p: ProjectConfig{URI: "other.example.com"}, wantURI: "other.example.com"},
+		{name: "Keyservers", p: ProjectConfig{Remote: "team", Keyservers: keyservers}, wantName: "team", wantURI: "team.example.com", wantToken: "team-token", wantKeyservers: true},
+		{name: "ExclusiveRemote", p: ProjectConfig{Remote: "team"}, exclusive: "default", wantErr: true},
+		{name: "ExclusiveSelected", p: ProjectConfig{Remote: "team"}, exclusive: "team", wantName: "team", wantURI: "team.example.com", wantToken: "team-token"},
+		{name: "ExclusiveKeyservers", p: ProjectConfig{Keyservers: keyservers}, exclusive: "default", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			if tt.exclusive != "" {
+				c.Remotes[tt.exclusive].Exclusive = true
+			}
+
+			name, ep, err := tt.p.Endpoint(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if name != tt.wantName || ep.URI != tt.wantURI || ep.Token != tt.wantToken {
+				t.Errorf("got %q (%s, %q), want %q (%s, %q)", name, ep.URI, ep.Token, tt.wantName, tt.wantURI, tt.wantToken)
+			}
+			if tt.wantKeyservers {
+				if len(ep.Keyservers) != 1 || ep.Keyservers[0] != keyservers[0] {
+					t.Errorf("keyservers not overridden: %v", ep.Keyservers)
+				}
+				if len(c.Remotes[name].Keyservers) != 0 {
+					t.Errorf("remote configuration was modified")
+				}
+			}
+		})
+	}
+
+	// without remote configuration, the default endpoint is used
+	_, ep, err := (&ProjectConfig{}).Endpoint(&Config{Remotes: map[string]*endpoint.Config{}})
+	if err != nil || ep != endpoint.DefaultEndpointConfig {
+		t.Errorf("got %v, %v, want default endpoint", ep, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// ErrConfigLocked is returned when the lock on a remote configuration file
+// could not be acquired before LockTimeout.
+var ErrConfigLocked = errors.New("remote configuration is locked by another process")
+
+var (
+	// LockTimeout is the maximum time spent waiting for the lock on a remote
+	// configuration file.
+	LockTimeout = 10 * time.Second
+	// lockRetryDelay is the delay between attempts to acquire the lock on a
+	// remote configuration file.
+	lockRetryDelay = 50 * time.Millisecond
+)
+
+// UpdateFile reads the remote configuration file at path, passes it to fn
+// for modification, and writes it back. The file is created with perm when it
+// doesn't exist, and is left untouched when fn returns an error.
+//
+// Concurrent updates are serialized with an advisory lock on a companion
+// path.lock file, retried until LockTimeout. The configuration is written to a
+// temporary file renamed over path, so readers never see a partially written
+// configuration.
+func UpdateFile(path string, perm os.FileMode, fn func(c *Config) error) error {
+	system := path == SystemConfigPath
+	// replace the target of a symlinked configuration file, not the symlink
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+
+	unlock, err := lockConfig(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.Open(path)
+	if err == nil {
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		perm = fi.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("while opening remote config file: %w", err)
+	}
+
+	c := &Config{Remotes: make(map[string]*endpoint.Config)}
+	if f != nil {
+		c, err = ReadFrom(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("while parsing remote config data: %w", err)
+		}
+	}
+	c.system = system
+
+	if err := fn(c); err != nil {
+		return err
+	}
+	return writeConfig(path, perm, c)
+}
+
+// writeConfig writes c to a temporary file in the directory of path, and
+// renames it to path.
+func writeConfig(path string, perm os.FileMode, c *Config) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("while creating remote config file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("while setting remote config file permissions: %w", err)
+	}
+	if _, err := c.WriteTo(tmp); err != nil {
+		return fmt.Errorf("while writing remote config to file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to flush remote config file %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("while closing remote config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("while replacing remote config file: %w", err)
+	}
+	return nil
+}
+
+// lockConfig acquires an exclusive advisory lock for the remote configuration
+// file at path, and returns a function releasing it.
+func lockConfig(path string) (func(), error) {
+	lockPath := path + ".lock"
+	fd, err := unix.Open(lockPath, unix.O_RDWR|unix.O_CREAT|unix.O_CLOEXEC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("while opening lock file %s: %w", lockPath, err)
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	waiting := false
+	for {
+		err = unix.Flock(fd, unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != unix.EWOULDBLOCK && err != unix.EINTR {
+			unix.Close(fd)
+			return nil, fmt.Errorf("while locking %s: %w", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			unix.Close(fd)
+			return nil, fmt.Errorf("%s: %w", path, ErrConfigLocked)
+		}
+		if !waiting {
+			sylog.Debugf("Waiting for lock on %s", lockPath)
+			waiting = true
+		}
+		time.Sleep(lockRetryDelay)
+	}
+
+	return func() {
+		unix.Flock(fd, unix.LOCK_UN)
+		unix.Close(fd)
+	}, nil
+}
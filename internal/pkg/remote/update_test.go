@@ -0,0 +1,131 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/endpoint"
+)
+
+func readConfigFile(t *testing.T, path string) *Config {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	c, err := ReadFrom(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestUpdateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remote.yaml")
+
+	// Concurrent updates are all applied.
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- UpdateFile(path, 0o600, func(c *Config) error {
+				return c.Add(fmt.Sprintf("remote%d", i), &endpoint.Config{URI: "cloud.example.com"})
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := len(readConfigFile(t, path).Remotes); got != n {
+		t.Errorf("got %d remotes, want %d", got, n)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("got mode %o, want %o", fi.Mode().Perm(), 0o600)
+	}
+
+	// The file is left untouched when the update fails, and keeps its mode.
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	errUpdate := errors.New("update failed")
+	err = UpdateFile(path, 0o600, func(c *Config) error {
+		c.Remotes = nil
+		return errUpdate
+	})
+	if !errors.Is(err, errUpdate) {
+		t.Fatalf("got error %v, want %v", err, errUpdate)
+	}
+	if got := len(readConfigFile(t, path).Remotes); got != n {
+		t.Errorf("got %d remotes, want %d", got, n)
+	}
+	if err := UpdateFile(path, 0o600, func(c *Config) error { return c.Remove("remote0") }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o644 {
+		t.Errorf("file mode was not preserved: %v", fi.Mode())
+	}
+
+	// The file is updated through a symlink.
+	link := filepath.Join(t.TempDir(), "remote.yaml")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateFile(link, 0o600, func(c *Config) error { return c.Remove("remote1") }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink was replaced")
+	}
+	if got := len(readConfigFile(t, path).Remotes); got != n-2 {
+		t.Errorf("got %d remotes, want %d", got, n-2)
+	}
+}
+
+func TestUpdateFileLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remote.yaml")
+
+	unlock, err := lockConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(d time.Duration) { LockTimeout = d }(LockTimeout)
+	LockTimeout = 200 * time.Millisecond
+
+	err = UpdateFile(path, 0o600, func(c *Config) error { return nil })
+	if !errors.Is(err, ErrConfigLocked) {
+		t.Fatalf("got error %v, want %v", err, ErrConfigLocked)
+	}
+
+	// The update proceeds once the lock is released.
+	time.AfterFunc(50*time.Millisecond, unlock)
+	if err := UpdateFile(path, 0o600, func(c *Config) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("configuration file was not created: %v", err)
+	}
+}
@@ -17,4 +17,15 @@ type EngineConfig struct {
 	BuildEnv    bool     `json:"buildEnv"`
 	NoPIDNS     bool     `json:"NoPIDNS"`
 	NoSetgroups bool     `json:"NoSetgroups"`
+	// KernelSquashfs requests that the command can have squashfs filesystems
+	// mounted with the kernel driver by the starter, in setuid installations
+	// where 'allow kernel squashfs' is enabled.
+	KernelSquashfs bool `json:"kernelSquashfs"`
+	// KernelSquashfsSocket is the socket pair over which kernel squashfs
+	// mounts are requested by the command.
+	KernelSquashfsSocket [2]int `json:"kernelSquashfsSocket"`
+	// MaxLoopDevices and SharedLoopDevices apply the singularity.conf loop
+	// device settings to kernel squashfs mounts.
+	MaxLoopDevices    int  `json:"maxLoopDevices"`
+	SharedLoopDevices bool `json:"sharedLoopDevices"`
 }
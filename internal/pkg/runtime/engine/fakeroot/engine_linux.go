@@ -24,12 +24,14 @@ import (
 	fakerootConfig "github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/fakeroot/config"
 	"github.com/sylabs/singularity/v4/internal/pkg/security/seccomp"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/squashfs"
 	fakerootcallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/runtime/fakeroot"
 	"github.com/sylabs/singularity/v4/pkg/runtime/engine/config"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	"github.com/sylabs/singularity/v4/pkg/util/capabilities"
 	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
 	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"golang.org/x/sys/unix"
 )
 
 // EngineOperations is a Singularity fakeroot runtime engine that implements engine.Operations.
@@ -95,6 +97,10 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 		}
 	}
 
+	if err := e.prepareKernelSquashfs(starterConfig, fileConfig); err != nil {
+		return err
+	}
+
 	g.AddOrReplaceLinuxNamespace(specs.UserNamespace, "")
 	g.AddOrReplaceLinuxNamespace(specs.MountNamespace, "")
 
@@ -158,8 +164,61 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 	return nil
 }
 
-// CreateContainer does nothing for the fakeroot engine.
+// prepareKernelSquashfs creates the socket pair over which the command
+// requests kernel squashfs mounts from the master process, when requested,
+// and permitted in a setuid installation. The master process lives in the
+// host user namespace, where it can escalate privileges to mount squashfs
+// filesystems, which is not possible from the user namespace of the command.
+func (e *EngineOperations) prepareKernelSquashfs(starterConfig *starter.Config, fileConfig *singularityconf.File) error {
+	if !e.EngineConfig.KernelSquashfs {
+		return nil
+	}
+	if !starterConfig.GetIsSUID() || !fileConfig.AllowKernelSquashfs || !fileConfig.OCISIFKernelMount {
+		e.EngineConfig.KernelSquashfs = false
+		return nil
+	}
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create socketpair for kernel squashfs mounts: %s", err)
+	}
+	if err := starterConfig.KeepFileDescriptor(fds[0]); err != nil {
+		return err
+	}
+	if err := starterConfig.KeepFileDescriptor(fds[1]); err != nil {
+		return err
+	}
+	e.EngineConfig.KernelSquashfsSocket = fds
+	e.EngineConfig.MaxLoopDevices = int(fileConfig.MaxLoopDevices)
+	e.EngineConfig.SharedLoopDevices = fileConfig.SharedLoopDevices
+	return nil
+}
+
+// CreateContainer starts serving the kernel squashfs mount requests of the
+// command, if enabled, for the lifetime of the master process. Requests are
+// only served for squashfs layers of OCI-SIF images the user may run, as
+// checked by authorizeKernelMount.
+//
+// Additional privileges are gained, in setuid installations, to attach loop
+// devices and mount squashfs filesystems (see squashfs.ServeKernelMounts).
 func (e *EngineOperations) CreateContainer(context.Context, int, net.Conn) error {
+	if !e.EngineConfig.KernelSquashfs {
+		return nil
+	}
+	fds := e.EngineConfig.KernelSquashfsSocket
+	unix.Close(fds[1])
+
+	// singularity.conf ownership was checked by PrepareConfig
+	fileConfig, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		unix.Close(fds[0])
+		return fmt.Errorf("unable to parse singularity.conf file: %s", err)
+	}
+	go squashfs.ServeKernelMounts(fds[0], squashfs.KernelMountConfig{
+		MaxLoopDevices:    e.EngineConfig.MaxLoopDevices,
+		SharedLoopDevices: e.EngineConfig.SharedLoopDevices,
+		Authorize:         authorizeKernelMount(fileConfig),
+	})
 	return nil
 }
 
@@ -267,6 +326,16 @@ func (e *EngineOperations) StartProcess(_ net.Conn) error {
 	}
 	env := e.EngineConfig.Envs
 
+	// pass the socket requesting kernel squashfs mounts to the command
+	if e.EngineConfig.KernelSquashfs {
+		fds := e.EngineConfig.KernelSquashfsSocket
+		unix.Close(fds[0])
+		if _, err := unix.FcntlInt(uintptr(fds[1]), unix.F_SETFD, 0); err != nil {
+			return fmt.Errorf("while passing kernel squashfs mount socket: %s", err)
+		}
+		env = append(env, fmt.Sprintf("%s=%d", squashfs.KernelMountFdEnv, fds[1]))
+	}
+
 	// simple command execution
 	if !e.EngineConfig.BuildEnv {
 		return syscall.Exec(args[0], args, env)
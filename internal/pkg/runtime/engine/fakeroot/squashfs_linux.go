@@ -0,0 +1,164 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fakeroot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	ociclient "github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/syecl"
+	"github.com/sylabs/singularity/v4/internal/pkg/sypgp"
+	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// authorizeKernelMount returns the function authorizing the kernel squashfs
+// mount requests of the command. A request is only served for a squashfs layer
+// of an OCI-SIF image that the user is permitted to run, according to the
+// image restrictions of singularity.conf and the ECL, which are applied as
+// for the images run by the singularity engine.
+func authorizeKernelMount(fileConfig *singularityconf.File) func(*os.File, uint64, uint64) error {
+	return func(img *os.File, offset, size uint64) error {
+		if !fileConfig.AllowContainerSIF {
+			return fmt.Errorf("configuration disallows users from running SIF containers")
+		}
+		if err := checkSquashfsLayer(img, offset, size); err != nil {
+			return err
+		}
+		if err := checkImageLimits(img, fileConfig); err != nil {
+			return err
+		}
+		return checkImageECL(img)
+	}
+}
+
+// checkSquashfsLayer returns an error if img is not an OCI-SIF image holding a
+// squashfs layer of size bytes exactly at offset.
+func checkSquashfsLayer(img *os.File, offset, size uint64) error {
+	f, err := sif.LoadContainer(img, sif.OptLoadWithFlag(os.O_RDONLY), sif.OptLoadWithCloseOnUnload(false))
+	if err != nil {
+		return fmt.Errorf("image is not an OCI-SIF image: %w", err)
+	}
+	defer f.UnloadContainer()
+
+	blobs, err := f.GetDescriptors(sif.WithDataType(sif.DataOCIBlob))
+	if err != nil {
+		return fmt.Errorf("while getting OCI-SIF blobs: %w", err)
+	}
+	var layer *sif.Descriptor
+	for i, d := range blobs {
+		if uint64(d.Offset()) == offset && uint64(d.Size()) == size {
+			layer = &blobs[i]
+			break
+		}
+	}
+	if layer == nil {
+		return fmt.Errorf("no OCI-SIF blob at offset %d of size %d", offset, size)
+	}
+	digest, err := layer.OCIBlobDigest()
+	if err != nil {
+		return err
+	}
+
+	ix, err := ocisif.ImageIndexFromFileImage(f)
+	if err != nil {
+		return fmt.Errorf("while obtaining image index: %w", err)
+	}
+	im, err := ix.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("while obtaining index manifest: %w", err)
+	}
+	for _, desc := range im.Manifests {
+		oi, err := ix.Image(desc.Digest)
+		if err != nil {
+			continue
+		}
+		m, err := oi.Manifest()
+		if err != nil {
+			return fmt.Errorf("while obtaining manifest: %w", err)
+		}
+		for _, l := range m.Layers {
+			if l.Digest == digest && l.Size == int64(size) && l.MediaType == ociclient.SquashfsLayerMediaType {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("OCI-SIF blob %s is not a squashfs layer", digest)
+}
+
+// checkImageLimits applies the limit container paths, groups and owners
+// directives of singularity.conf to img.
+func checkImageLimits(img *os.File, fileConfig *singularityconf.File) error {
+	source := fmt.Sprintf("/proc/self/fd/%d", img.Fd())
+	path, err := os.Readlink(source)
+	if err != nil {
+		return fmt.Errorf("while reading symlink %s: %s", source, err)
+	}
+	imgObject := &image.Image{
+		Path:   strings.TrimSuffix(path, " (deleted)"),
+		Source: source,
+		Fd:     img.Fd(),
+		File:   img,
+	}
+
+	if len(fileConfig.LimitContainerPaths) != 0 {
+		if authorized, err := imgObject.AuthorizedPath(fileConfig.LimitContainerPaths); err != nil {
+			return err
+		} else if !authorized {
+			return fmt.Errorf("singularity image is not in an allowed configured path")
+		}
+	}
+	if len(fileConfig.LimitContainerGroups) != 0 {
+		if authorized, err := imgObject.AuthorizedGroup(fileConfig.LimitContainerGroups); err != nil {
+			return err
+		} else if !authorized {
+			return fmt.Errorf("singularity image is not owned by required group(s)")
+		}
+	}
+	if len(fileConfig.LimitContainerOwners) != 0 {
+		if authorized, err := imgObject.AuthorizedOwner(fileConfig.LimitContainerOwners); err != nil {
+			return err
+		} else if !authorized {
+			return fmt.Errorf("singularity image is not owned by required user(s)")
+		}
+	}
+	return nil
+}
+
+// checkImageECL checks img against the ECL, if an ECL config file is found.
+func checkImageECL(img *os.File) error {
+	ecl, err := syecl.LoadConfig(buildcfg.ECL_FILE)
+	if err != nil {
+		return nil
+	}
+	if err := ecl.ValidateConfig(); err != nil {
+		return fmt.Errorf("while validating ECL configuration: %s", err)
+	}
+
+	var kr openpgp.KeyRing = openpgp.EntityList{}
+	if ecl.Activated {
+		keyring := sypgp.NewHandle(buildcfg.SINGULARITY_CONFDIR, sypgp.GlobalHandleOpt())
+		kr, err = keyring.LoadPubKeyring()
+		if err != nil {
+			return fmt.Errorf("while obtaining keyring for ECL: %s", err)
+		}
+	}
+
+	if ok, err := ecl.ShouldRunFp(context.TODO(), img, kr); err != nil {
+		return fmt.Errorf("while checking container image with ECL: %s", err)
+	} else if !ok {
+		return errors.New("image prohibited by ECL")
+	}
+	return nil
+}
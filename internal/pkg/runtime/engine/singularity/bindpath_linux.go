@@ -0,0 +1,58 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/user"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// bindPathUser returns the identity of the host user running the container,
+// which singularity.conf bind path templates are expanded for. As in OCI mode,
+// this is the original user, not the fakeroot or target user of the container.
+func bindPathUser() (singularityconf.BindPathUser, error) {
+	pw, err := user.CurrentOriginal()
+	if err != nil {
+		return singularityconf.BindPathUser{}, fmt.Errorf("while looking up host user: %w", err)
+	}
+	gr, err := user.GetGrGID(pw.GID)
+	if err != nil {
+		return singularityconf.BindPathUser{}, fmt.Errorf("while looking up group %d: %w", pw.GID, err)
+	}
+	return singularityconf.BindPathUser{
+		Username: pw.Name,
+		UID:      int(pw.UID),
+		Group:    gr.Name,
+		GID:      int(pw.GID),
+	}, nil
+}
+
+// expandBindPaths expands the templates of singularity.conf bind paths for
+// the host user running the container.
+func expandBindPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return paths, nil
+	}
+	u, err := bindPathUser()
+	if err != nil {
+		return nil, err
+	}
+	return singularityconf.ExpandBindPaths(paths, u), nil
+}
+
+// bindPaths returns the 'bind path' entries of singularity.conf, expanded for
+// the user running the container.
+func (e *EngineOperations) bindPaths() ([]string, error) {
+	return expandBindPaths(e.EngineConfig.File.BindPath)
+}
+
+// requiredBinds returns the 'required binds' entries of singularity.conf,
+// expanded for the user running the container.
+func (e *EngineOperations) requiredBinds() ([]string, error) {
+	return expandBindPaths(e.EngineConfig.File.RequiredBinds)
+}
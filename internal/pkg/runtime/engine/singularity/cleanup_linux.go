@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -36,7 +36,7 @@ import (
 // For better understanding of runtime flow in general refer to
 // https://github.com/opencontainers/runtime-spec/blob/master/runtime.md#lifecycle.
 // CleanupContainer is performing step 8/9 here.
-func (e *EngineOperations) CleanupContainer(ctx context.Context, _ error, _ syscall.WaitStatus) error {
+func (e *EngineOperations) CleanupContainer(ctx context.Context, _ error, status syscall.WaitStatus) error {
 	// firstly stop all fuse drivers before any image removal
 	// by image driver interruption or image cleanup for hybrid
 	// fakeroot workflow
@@ -90,17 +90,22 @@ func (e *EngineOperations) CleanupContainer(ctx context.Context, _ error, _ sysc
 	}
 
 	if cgroupsManager != nil {
+		reportOOMKilled(status)
 		if err := cgroupsManager.Destroy(); err != nil {
 			sylog.Warningf("failed to remove cgroup configuration: %v", err)
 		}
 	}
 
-	if cryptDev != "" {
+	for _, cryptDev := range cryptDevs {
 		if err := cleanupCrypt(cryptDev); err != nil {
 			sylog.Errorf("could not cleanup crypt: %v", err)
 		}
 	}
 
+	if dir := e.EngineConfig.GetCoreDir(); dir != "" && status.Signaled() && status.CoreDump() {
+		e.collectCoreDump(dir, status.Signal())
+	}
+
 	if e.EngineConfig.GetInstance() {
 		file, err := instance.Get(e.CommonConfig.ContainerID, instance.SingSubDir)
 		if err != nil {
@@ -11,14 +11,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	osuser "os/user"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
@@ -33,6 +34,7 @@ import (
 	fsoverlay "github.com/sylabs/singularity/v4/internal/pkg/util/fs/overlay"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/mainthread"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/mpi"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/priv"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/user"
 	"github.com/sylabs/singularity/v4/pkg/image"
@@ -52,10 +54,11 @@ import (
 // - cleanup
 // - post start process
 var (
-	cryptDev       string
+	cryptDevs      []string
 	networkSetup   *network.Setup
 	umountPoints   []string
 	cgroupsManager *cgroups.Manager
+	containerStart time.Time
 )
 
 // defaultCNIConfPath is the default directory to CNI network configuration files.
@@ -64,9 +67,26 @@ var defaultCNIConfPath = filepath.Join(buildcfg.SYSCONFDIR, "singularity", "netw
 // defaultCNIPluginPath is the default directory to CNI plugins executables.
 var defaultCNIPluginPath = filepath.Join(buildcfg.LIBEXECDIR, "singularity", "cni")
 
-type lastMount struct {
-	dest  string
-	flags uintptr
+// parallelMountTags are the tags whose mounts are performed in parallel, as
+// they may hold many independent overlay layers and bind mounts.
+var parallelMountTags = map[mount.AuthorizedTag]bool{
+	mount.PreLayerTag:  true,
+	mount.HostfsTag:    true,
+	mount.BindsTag:     true,
+	mount.UserbindsTag: true,
+}
+
+// mountOrigins describes, by tag, the singularity.conf directive or flag that
+// requests mounts. It's used to report where a failing mount came from.
+var mountOrigins = map[mount.AuthorizedTag]string{
+	mount.DevTag:       "'mount dev' in singularity.conf (disable with --no-mount dev)",
+	mount.HostfsTag:    "'mount hostfs' in singularity.conf (disable with --no-mount hostfs)",
+	mount.BindsTag:     "'bind path' in singularity.conf (disable with --no-mount bind-paths)",
+	mount.HomeTag:      "'mount home' in singularity.conf or --home (disable with --no-mount home)",
+	mount.TmpTag:       "'mount tmp' in singularity.conf (disable with --no-mount tmp)",
+	mount.ScratchTag:   "--scratch",
+	mount.CwdTag:       "the current working directory (disable with --no-mount cwd)",
+	mount.UserbindsTag: "--bind / --mount",
 }
 
 type container struct {
@@ -75,18 +95,30 @@ type container struct {
 	session       *layout.Session
 	sessionFsType string
 	sessionSize   int
+	tmpSession    bool
 	userNS        bool
 	pidNS         bool
 	utsNS         bool
 	netNS         bool
 	ipcNS         bool
 	mountInfoPath string
-	lastMount     lastMount
+	// mountMu protects bindFlags, skippedMount and cryptDevs, as the mounts of
+	// parallelMountTags are performed concurrently.
+	mountMu sync.Mutex
+	// bindFlags holds, by destination, the flags of bind mounts, applied by
+	// their remount.
+	bindFlags     map[string]uintptr
 	skippedMount  []string
 	suidFlag      uintptr
 	devSourcePath string
 	imageBind     map[string]string
 	skipCwd       bool
+	// homeOverlay is the session path where the overlay partition holding
+	// the home directory overlay is mounted, with --home-mode image.
+	homeOverlay string
+	// mountOrigin records, by destination, a more specific origin than
+	// mountOrigins for mounts that come from an individual directive or flag.
+	mountOrigin map[string]string
 }
 
 const (
@@ -129,9 +161,11 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 		rpcOps:        rpcOps,
 		sessionFsType: engine.EngineConfig.File.MemoryFSType,
 		mountInfoPath: fmt.Sprintf("/proc/%d/mountinfo", pid),
+		bindFlags:     make(map[string]uintptr),
 		skippedMount:  make([]string, 0),
 		suidFlag:      syscall.MS_NOSUID,
 		imageBind:     make(map[string]string),
+		mountOrigin:   make(map[string]string),
 	}
 
 	cwd := engine.EngineConfig.GetCwd()
@@ -161,6 +195,10 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 	} else if engine.EngineConfig.GetAllowSUID() && !c.userNS {
 		c.suidFlag = 0
 	}
+	// The writable tmpfs upper directory is held in the session directory.
+	if size := engine.EngineConfig.GetWritableTmpfsSize(); size > 0 && engine.EngineConfig.GetWritableTmpfs() {
+		c.sessionSize = int(c.tmpfsSize("--writable-tmpfs-size", size))
+	}
 
 	// user namespace was not requested but we need to check
 	// if we are currently running in a user namespace and set
@@ -171,7 +209,13 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 	}
 
 	p := &mount.Points{}
-	system := &mount.System{Points: p, Mount: c.mount}
+	system := &mount.System{
+		Points:       p,
+		Mount:        c.mount,
+		ParallelTags: parallelMountTags,
+		Workers:      mount.ParallelWorkers(),
+		Target:       c.mountTarget,
+	}
 
 	createCwdDirTag := mount.AuthorizedTag(mount.LayerTag)
 	if c.engine.EngineConfig.GetSessionLayer() == singularity.UnderlayLayer {
@@ -210,6 +254,9 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 	if err := c.addRootfsMount(system); err != nil {
 		return err
 	}
+	if err := system.RunAfterTag(mount.RootfsTag, c.checkMPIABI); err != nil {
+		return err
+	}
 	if err := c.addImageBindMount(system); err != nil {
 		return err
 	}
@@ -258,12 +305,21 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 	if err := c.addFilesMount(system); err != nil {
 		return err
 	}
+	if err := c.addMetadataMount(system); err != nil {
+		return err
+	}
 	if err := c.addResolvConfMount(system); err != nil {
 		return err
 	}
+	if err := c.addHostsMount(system); err != nil {
+		return err
+	}
 	if err := c.addHostnameMount(system); err != nil {
 		return err
 	}
+	if err := c.addVirtualProcMount(system); err != nil {
+		return err
+	}
 	usernsFd, err := c.addFuseMount(system)
 	if err != nil {
 		return err
@@ -274,6 +330,24 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 		return err
 	}
 
+	if engine.EngineConfig.GetCgroupStats() {
+		if err := c.applyCgroups(pid); err != nil {
+			return err
+		}
+		if err := c.addCgroupStatsMount(system); err != nil {
+			return err
+		}
+	}
+
+	for _, point := range p.GetAllBinds() {
+		if _, _, err := mount.GetIDMap(point.InternalOptions); err == nil {
+			// idmapped binds escalate the privileges of all threads, and
+			// share a socket pair with the RPC server
+			system.Workers = 1
+			break
+		}
+	}
+
 	sylog.Debugf("Mount all")
 	if err := system.MountAll(); err != nil {
 		return err
@@ -316,22 +390,12 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 		}
 	}
 
-	cgJSON := engine.EngineConfig.GetCgroupsJSON()
-	if cgJSON != "" {
-		// Rootless cgroups setup interacts with systemd over D-Bus.
-		// The session bus address and XDG runtime dir must be set in the environment.
-		if os.Getuid() != 0 {
-			sylog.Debugf("Setting rootless XDG_RUNTIME_DIR / DBUS_SESSION_ADDRESS for cgroup manager")
-			os.Setenv("XDG_RUNTIME_DIR", engine.EngineConfig.GetXdgRuntimeDir())
-			os.Setenv("DBUS_SESSION_BUS_ADDRESS", engine.EngineConfig.GetDbusSessionBusAddress())
-		}
-
-		cgroupsManager, err = cgroups.NewManagerWithJSON(cgJSON, pid, "", engine.EngineConfig.File.SystemdCgroups)
-		if err != nil {
-			return fmt.Errorf("while applying cgroups config: %v", err)
+	// With --cgroup-stats the cgroup was already created, to be mounted into
+	// the container.
+	if cgroupsManager == nil {
+		if err := c.applyCgroups(pid); err != nil {
+			return err
 		}
-		os.Unsetenv("XDG_RUNTIME_DIR")
-		os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
 	}
 
 	sylog.Debugf("Chdir into / to avoid errors\n")
@@ -340,13 +404,38 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 		return fmt.Errorf("change directory failed: %s", err)
 	}
 
-	if err := engine.runFuseDrivers(false, usernsFd); err != nil {
+	if err := engine.runFuseDrivers(false, pid, usernsFd); err != nil {
 		return fmt.Errorf("while running FUSE drivers: %s", err)
 	}
 
 	return nil
 }
 
+// applyCgroups creates the container cgroup, if a cgroups configuration was
+// provided, and adds the container process to it.
+func (c *container) applyCgroups(pid int) (err error) {
+	cgJSON := c.engine.EngineConfig.GetCgroupsJSON()
+	if cgJSON == "" {
+		return nil
+	}
+
+	// Rootless cgroups setup interacts with systemd over D-Bus.
+	// The session bus address and XDG runtime dir must be set in the environment.
+	if os.Getuid() != 0 {
+		sylog.Debugf("Setting rootless XDG_RUNTIME_DIR / DBUS_SESSION_ADDRESS for cgroup manager")
+		os.Setenv("XDG_RUNTIME_DIR", c.engine.EngineConfig.GetXdgRuntimeDir())
+		os.Setenv("DBUS_SESSION_BUS_ADDRESS", c.engine.EngineConfig.GetDbusSessionBusAddress())
+	}
+
+	cgroupsManager, err = cgroups.NewManagerWithJSON(cgJSON, pid, "", c.engine.EngineConfig.File.SystemdCgroups)
+	if err != nil {
+		return fmt.Errorf("while applying cgroups config: %v", err)
+	}
+	os.Unsetenv("XDG_RUNTIME_DIR")
+	os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
+	return nil
+}
+
 // setupSessionLayout will create the session layout according to the capabilities of Singularity
 // on the system. It will first attempt to use "overlay", followed by "underlay", and if neither
 // are available it will not use either. If neither are used, we will not be able to bind mount
@@ -419,12 +508,29 @@ func (c *container) mount(point *mount.Point, system *mount.System) error {
 	} else {
 		tag := system.CurrentTag()
 		if err := c.mountGeneric(point, tag); err != nil {
+			if origin := c.getMountOrigin(point.Destination, tag); origin != "" {
+				return fmt.Errorf("while mounting %s, requested by %s: %s", point.Source, origin, err)
+			}
 			return fmt.Errorf("while mounting %s: %s", point.Source, err)
 		}
 	}
 	return nil
 }
 
+// setMountOrigin records the directive or flag that requested the mount at dest.
+func (c *container) setMountOrigin(dest string, origin string) {
+	c.mountOrigin[dest] = origin
+}
+
+// getMountOrigin returns a description of the directive or flag that
+// requested the mount at dest, or an empty string if it is not known.
+func (c *container) getMountOrigin(dest string, tag mount.AuthorizedTag) string {
+	if origin, ok := c.mountOrigin[dest]; ok {
+		return origin
+	}
+	return mountOrigins[tag]
+}
+
 // setPropagationMount will apply propagation flag set by
 // configuration directive, when applied master process
 // won't see mount done by RPC server anymore. Typically
@@ -498,14 +604,16 @@ func (c *container) mountGeneric(mnt *mount.Point, tag mount.AuthorizedTag) (err
 				return fmt.Errorf("while getting mount flags for %s: %s", source, err)
 			}
 			// save them for the remount step
-			c.lastMount = lastMount{
-				dest:  mnt.Destination,
-				flags: flags,
+			c.mountMu.Lock()
+			c.bindFlags[mnt.Destination] = flags
+			c.mountMu.Unlock()
+		} else {
+			c.mountMu.Lock()
+			if bindFlags, ok := c.bindFlags[mnt.Destination]; ok {
+				flags |= bindFlags
+				delete(c.bindFlags, mnt.Destination)
 			}
-		} else if c.lastMount.dest == mnt.Destination {
-			flags = c.lastMount.flags | flags
-			c.lastMount.dest = ""
-			c.lastMount.flags = 0
+			c.mountMu.Unlock()
 		}
 	}
 
@@ -517,10 +625,8 @@ func (c *container) mountGeneric(mnt *mount.Point, tag mount.AuthorizedTag) (err
 	}
 
 	if remount || propagation {
-		for _, skipped := range c.skippedMount {
-			if skipped == mnt.Destination {
-				return nil
-			}
+		if c.isSkipped(mnt.Destination) {
+			return nil
 		}
 		sylog.Debugf("Remounting %s\n", dest)
 	} else {
@@ -534,8 +640,15 @@ func (c *container) mountGeneric(mnt *mount.Point, tag mount.AuthorizedTag) (err
 		}
 	}
 
+	_, _, idmapErr := mount.GetIDMap(mnt.InternalOptions)
+	idmap := bindMount && !remount && idmapErr == nil
+
 mount:
-	err = c.rpcOps.Mount(source, dest, mnt.Type, flags, optsString)
+	if idmap {
+		err = c.mountIDMapped(source, dest, mnt.InternalOptions)
+	} else {
+		err = c.rpcOps.Mount(source, dest, mnt.Type, flags, optsString)
+	}
 	if os.IsNotExist(err) {
 		switch tag {
 		case mount.KernelTag,
@@ -544,8 +657,11 @@ mount:
 			mount.CwdTag,
 			mount.FilesTag,
 			mount.TmpTag:
-			c.skippedMount = append(c.skippedMount, mnt.Destination)
+			c.skip(mnt.Destination)
 			sylog.Warningf("Skipping mount %s [%s]: %s doesn't exist in container", source, tag, mnt.Destination)
+			if origin := c.getMountOrigin(mnt.Destination, tag); origin != "" {
+				sylog.Verbosef("Mount of %s was requested by %s", mnt.Destination, origin)
+			}
 			return nil
 		default:
 			if c.engine.EngineConfig.GetWritableImage() {
@@ -597,7 +713,7 @@ mount:
 
 		if mount.SkipOnError(mnt.InternalOptions) {
 			sylog.Warningf("could not mount %s: %s", mnt.Source, err)
-			c.skippedMount = append(c.skippedMount, mnt.Destination)
+			c.skip(mnt.Destination)
 			return nil
 		}
 		return fmt.Errorf("could not mount %s: %s", mnt.Source, err)
@@ -606,6 +722,103 @@ mount:
 	return nil
 }
 
+// mountTarget returns the path point is mounted on, in the session directory.
+// When the destination goes through a symlink in the container, its target
+// can't be ordered lexically against the other mounts, so the container root
+// is returned to mount it after all the mounts before it, and before all the
+// mounts after it.
+func (c *container) mountTarget(point *mount.Point) string {
+	if strings.HasPrefix(point.Destination, c.session.Path()) {
+		return point.Destination
+	}
+	dest := filepath.Clean(point.Destination)
+	if fs.EvalRelative(dest, c.session.FinalPath()) != dest {
+		return c.session.FinalPath()
+	}
+	return filepath.Join(c.session.FinalPath(), dest)
+}
+
+// skip records that the mount at dest was skipped, so that its remount is
+// skipped too.
+func (c *container) skip(dest string) {
+	c.mountMu.Lock()
+	defer c.mountMu.Unlock()
+	c.skippedMount = append(c.skippedMount, dest)
+}
+
+// isSkipped returns whether the mount at dest was skipped.
+func (c *container) isSkipped(dest string) bool {
+	c.mountMu.Lock()
+	defer c.mountMu.Unlock()
+	return slice.ContainsString(c.skippedMount, dest)
+}
+
+// mountIDMapped bind mounts source to dest with an idmapped mount, on which
+// the host user is mapped to the container uid and gid of the idmap option.
+// The source is opened as the user, without following a final symlink, so
+// that only paths accessible to the user can be mounted. The idmapped mount is
+// then cloned from it by the master process, with escalated privileges, as
+// this requires CAP_SYS_ADMIN in the host user namespace, and sent to the RPC
+// server, which attaches it in the container.
+func (c *container) mountIDMapped(source, dest string, options []string) error {
+	uid, gid, err := mount.GetIDMap(options)
+	if err != nil {
+		return err
+	}
+	linux := c.engine.EngineConfig.OciConfig.Linux
+	if linux == nil {
+		return fmt.Errorf("idmap bind option requires a user namespace")
+	}
+	hostUID, err := hostID(uid, linux.UIDMappings)
+	if err != nil {
+		return fmt.Errorf("while mapping container uid %d: %s", uid, err)
+	}
+	hostGID, err := hostID(gid, linux.GIDMappings)
+	if err != nil {
+		return fmt.Errorf("while mapping container gid %d: %s", gid, err)
+	}
+	uidMap := []specs.LinuxIDMapping{{ContainerID: uint32(os.Getuid()), HostID: hostUID, Size: 1}}
+	gidMap := []specs.LinuxIDMapping{{ContainerID: uint32(os.Getgid()), HostID: hostGID, Size: 1}}
+
+	srcFd, err := mount.OpenSource(source)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(srcFd)
+
+	escalate := os.Geteuid() != 0
+	if escalate {
+		if err := priv.Escalate(); err != nil {
+			return fmt.Errorf("idmapped binds require a setuid installation: %s", err)
+		}
+	}
+	fd, err := mount.OpenIDMappedTree(srcFd, uidMap, gidMap)
+	if escalate {
+		priv.Drop()
+	}
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	socketPair := c.engine.EngineConfig.GetUnixSocketPair()
+	if err := unix.Sendmsg(socketPair[0], []byte{0}, unix.UnixRights(fd), nil, 0); err != nil {
+		return fmt.Errorf("while sending mount file descriptor: %s", err)
+	}
+	return c.rpcOps.MoveMount(socketPair[1], dest)
+}
+
+// hostID returns the host ID to which the container ID id is mapped by
+// mappings.
+func hostID(id uint32, mappings []specs.LinuxIDMapping) (uint32, error) {
+	for _, m := range mappings {
+		if id >= m.ContainerID && id-m.ContainerID < m.Size {
+			return m.HostID + id - m.ContainerID, nil
+		}
+	}
+	return 0, fmt.Errorf("not mapped in the container user namespace")
+}
+
 // mount image via loop
 func (c *container) mountImage(mnt *mount.Point) error {
 	var key []byte
@@ -666,12 +879,16 @@ func (c *container) mountImage(mnt *mount.Point) error {
 			masterPid = os.Getpid()
 		}
 
-		cryptDev, err = c.rpcOps.Decrypt(offset, path, key, masterPid)
-
+		cryptDev, err := c.rpcOps.Decrypt(offset, path, key, masterPid)
 		if err != nil {
 			return fmt.Errorf("unable to decrypt the file system: %s", err)
 		}
 
+		// images may be mounted concurrently
+		c.mountMu.Lock()
+		cryptDevs = append(cryptDevs, cryptDev)
+		c.mountMu.Unlock()
+
 		path = cryptDev
 
 		mountType = "squashfs"
@@ -843,7 +1060,9 @@ func (c *container) addOverlayMount(system *mount.System) error {
 		hasUpper = true
 	}
 
-	for _, img := range c.engine.EngineConfig.GetImageList() {
+	homeImage := c.engine.EngineConfig.GetHomeMode() == "image"
+
+	for i, img := range c.engine.EngineConfig.GetImageList() {
 		overlays, err := img.GetOverlayPartitions()
 		if err != nil {
 			return fmt.Errorf("while opening overlay image %s: %s", img.Path, err)
@@ -862,11 +1081,19 @@ func (c *container) addOverlayMount(system *mount.System) error {
 			offset := overlay.Offset
 			size := overlay.Size
 
+			// with --home-mode image, the writable overlay partition of the
+			// root filesystem image holds the home directory overlay, the
+			// changes it holds for the rest of the image are read-only
+			homeOverlay := homeImage && i == 0 && overlay.Type == image.EXT3
+
 			switch overlay.Type {
 			case image.EXT3:
 				flags := uintptr(c.suidFlag | syscall.MS_NODEV)
 
-				if !img.Writable {
+				if homeOverlay {
+					ov.AddLowerDir(filepath.Join(dst, "upper"))
+					c.homeOverlay = dst
+				} else if !img.Writable {
 					flags |= syscall.MS_RDONLY
 					ov.AddLowerDir(filepath.Join(dst, "upper"))
 				}
@@ -931,7 +1158,7 @@ func (c *container) addOverlayMount(system *mount.System) error {
 				return err
 			}
 
-			if img.Writable && !hasUpper {
+			if img.Writable && !hasUpper && !homeOverlay {
 				upper := filepath.Join(dst, "upper")
 				work := filepath.Join(dst, "work")
 
@@ -956,6 +1183,16 @@ func (c *container) addOverlayMount(system *mount.System) error {
 	return system.Points.AddPropagation(mount.SharedTag, c.session.FinalPath(), syscall.MS_UNBINDABLE)
 }
 
+// checkMPIABI checks that the PMI libraries of the host, bound with --mpi, are
+// ABI compatible with any version of them in the mounted rootfs.
+func (c *container) checkMPIABI(*mount.System) error {
+	sonames := c.engine.EngineConfig.GetMPISonames()
+	if len(sonames) == 0 {
+		return nil
+	}
+	return mpi.CheckABI(c.rpcOps.ReadDir, c.session.RootFsPath(), sonames)
+}
+
 func (c *container) addImageBindMount(system *mount.System) error {
 	nb := 0
 	imageList := c.engine.EngineConfig.GetImageList()
@@ -1111,6 +1348,7 @@ func (c *container) addKernelMount(system *mount.System) error {
 		if err != nil {
 			return fmt.Errorf("unable to add proc to mount list: %s", err)
 		}
+		c.setMountOrigin("/proc", "'mount proc' in singularity.conf (disable with --no-mount proc)")
 		sylog.Verbosef("Default mount: /proc:/proc")
 	} else {
 		sylog.Verbosef("Skipping /proc mount")
@@ -1132,6 +1370,7 @@ func (c *container) addKernelMount(system *mount.System) error {
 		if err != nil {
 			return fmt.Errorf("unable to add sys to mount list: %s", err)
 		}
+		c.setMountOrigin("/sys", "'mount sys' in singularity.conf (disable with --no-mount sys)")
 		sylog.Verbosef("Default mount: /sys:/sys")
 	} else {
 		sylog.Verbosef("Skipping /sys mount")
@@ -1212,7 +1451,11 @@ func (c *container) addDevMount(system *mount.System) error {
 		}
 		devshmPath, _ := c.session.GetPath("/dev/shm")
 		flags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV)
-		err := system.Points.AddFS(mount.DevTag, devshmPath, c.sessionFsType, flags, "mode=1777")
+		opts := "mode=1777"
+		if size := c.engine.EngineConfig.GetShmSize(); size > 0 && c.sessionFsType == "tmpfs" {
+			opts += fmt.Sprintf(",size=%dm", c.tmpfsSize("--shm-size", size))
+		}
+		err := system.Points.AddFS(mount.DevTag, devshmPath, c.sessionFsType, flags, opts)
 		if err != nil {
 			return fmt.Errorf("failed to add /dev/shm temporary filesystem: %s", err)
 		}
@@ -1319,9 +1562,12 @@ func (c *container) addDevMount(system *mount.System) error {
 			return err
 		}
 		if c.engine.EngineConfig.GetNvLegacy() {
-			devs, err := gpu.NvidiaDevices(true)
-			if err != nil {
-				return fmt.Errorf("failed to get nvidia devices: %v", err)
+			devs := c.engine.EngineConfig.GetNvLegacyDevices()
+			if len(devs) == 0 {
+				devs, err = gpu.NvidiaDevices(true)
+				if err != nil {
+					return fmt.Errorf("failed to get nvidia devices: %v", err)
+				}
 			}
 			for _, dev := range devs {
 				if err := c.addSessionDev(dev, system); err != nil {
@@ -1342,6 +1588,30 @@ func (c *container) addDevMount(system *mount.System) error {
 			}
 		}
 
+		if c.engine.EngineConfig.GetIntel() {
+			devs, err := gpu.IntelDevices()
+			if err != nil {
+				return fmt.Errorf("failed to get intel devices: %v", err)
+			}
+			for _, dev := range devs {
+				if err := c.addSessionDev(dev, system); err != nil {
+					return err
+				}
+			}
+		}
+
+		if c.engine.EngineConfig.GetRdma() {
+			devs, err := gpu.RdmaDevices()
+			if err != nil {
+				return fmt.Errorf("failed to get rdma devices: %v", err)
+			}
+			for _, dev := range devs {
+				if err := c.addSessionDev(dev, system); err != nil {
+					return err
+				}
+			}
+		}
+
 		if err := c.addSessionDev("/dev/fd", system); err != nil {
 			return err
 		}
@@ -1422,6 +1692,33 @@ func (c *container) addBindsMount(system *mount.System) error {
 	skipBinds := c.engine.EngineConfig.GetSkipBinds()
 	skipAllBinds := slice.ContainsString(skipBinds, "*")
 
+	// A customized /etc/hosts is generated by addHostsMount when --add-host is used.
+	if len(c.engine.EngineConfig.GetAddHosts()) > 0 {
+		skipBinds = append(skipBinds, hostsPath)
+	}
+
+	// Required binds are mounted even with --contain, and can't be skipped.
+	requiredBinds, err := c.engine.requiredBinds()
+	if err != nil {
+		return fmt.Errorf("while expanding 'required binds' of singularity.conf: %w", err)
+	}
+	for _, bindpath := range requiredBinds {
+		src, dst, _ := strings.Cut(bindpath, ":")
+		if dst == "" {
+			dst = src
+		}
+
+		sylog.Verbosef("Found 'required binds' = %s, %s", src, dst)
+
+		if err := system.Points.AddBind(mount.BindsTag, src, dst, flags); err != nil {
+			return fmt.Errorf("unable to add %s to mount list: %s", src, err)
+		}
+		c.setMountOrigin(dst, fmt.Sprintf("'required binds = %s' in singularity.conf", bindpath))
+		if err := system.Points.AddRemount(mount.BindsTag, dst, flags); err != nil {
+			return fmt.Errorf("unable to add %s for remount: %s", dst, err)
+		}
+	}
+
 	if c.engine.EngineConfig.GetContain() {
 		hosts := hostsPath
 
@@ -1461,7 +1758,11 @@ func (c *container) addBindsMount(system *mount.System) error {
 		return nil
 	}
 
-	for _, bindpath := range c.engine.EngineConfig.File.BindPath {
+	bindPaths, err := c.engine.bindPaths()
+	if err != nil {
+		return fmt.Errorf("while expanding 'bind path' of singularity.conf: %w", err)
+	}
+	for _, bindpath := range bindPaths {
 		splitted := strings.Split(bindpath, ":")
 		src := splitted[0]
 		dst := ""
@@ -1488,6 +1789,7 @@ func (c *container) addBindsMount(system *mount.System) error {
 		if err != nil {
 			return fmt.Errorf("unable to add %s to mount list: %s", src, err)
 		}
+		c.setMountOrigin(dst, fmt.Sprintf("'bind path = %s' in singularity.conf (disable with --no-mount %s)", bindpath, dst))
 		if err := system.Points.AddRemount(mount.BindsTag, dst, flags); err != nil {
 			return fmt.Errorf("unable to add %s for remount: %s", dst, err)
 		}
@@ -1529,6 +1831,12 @@ func (c *container) addHomeStagingDir(system *mount.System, source string, dest
 	homeStage, _ = c.session.GetPath(dest)
 
 	bindSource := !c.engine.EngineConfig.GetContain() || c.engine.EngineConfig.GetCustomHome()
+	switch c.engine.EngineConfig.GetHomeMode() {
+	case "host":
+		bindSource = true
+	case "ephemeral":
+		bindSource = false
+	}
 
 	// use the session home directory is the user home directory doesn't exist (issue #4208)
 	if _, err := os.Stat(source); os.IsNotExist(err) {
@@ -1590,6 +1898,53 @@ func (c *container) addHomeNoLayer(system *mount.System, dest string) error {
 	return nil
 }
 
+// addHomeImageMount mounts an overlay on the home directory of the image, with
+// changes persisted in the writable overlay partition of the image.
+func (c *container) addHomeImageMount(system *mount.System, dest string) error {
+	if c.homeOverlay == "" {
+		return fmt.Errorf("no writable overlay partition mounted for the home directory")
+	}
+
+	// ensure the home directory exists in the container
+	if err := c.session.AddDir(dest); err != nil {
+		return fmt.Errorf("failed to add %s as session directory: %s", dest, err)
+	}
+
+	upper := filepath.Join(c.homeOverlay, "home", "upper")
+	work := filepath.Join(c.homeOverlay, "home", "work")
+
+	uid := os.Getuid()
+	if uid == 0 && c.engine.EngineConfig.GetTargetUID() != 0 {
+		uid = c.engine.EngineConfig.GetTargetUID()
+	}
+	gid := os.Getgid()
+
+	err := system.RunBeforeTag(mount.HomeTag, func(*mount.System) error {
+		for _, d := range []string{filepath.Dir(upper), upper, work} {
+			if _, err := c.rpcOps.Lstat(d); os.IsNotExist(err) {
+				if err := c.rpcOps.Mkdir(d, 0o755); err != nil {
+					return fmt.Errorf("failed to create %s directory: %s", d, err)
+				}
+			} else if err != nil {
+				return fmt.Errorf("could not setup home overlay: %s", err)
+			}
+		}
+		// the upper directory gives its owner to the home directory
+		return c.rpcOps.Chown(upper, uid, gid)
+	})
+	if err != nil {
+		return err
+	}
+
+	lower := filepath.Join(c.session.FinalPath(), dest)
+	sylog.Debugf("Adding home directory overlay on %s persisted in %s", dest, upper)
+	flags := uintptr(c.suidFlag | syscall.MS_NODEV)
+	if err := system.Points.AddOverlay(mount.HomeTag, dest, flags, lower, upper, work); err != nil {
+		return fmt.Errorf("unable to add home overlay to mount list: %s", err)
+	}
+	return nil
+}
+
 // addHomeMount is responsible for adding the home directory mount using the proper method
 func (c *container) addHomeMount(system *mount.System) error {
 	if c.engine.EngineConfig.GetNoHome() {
@@ -1618,6 +1973,10 @@ func (c *container) addHomeMount(system *mount.System) error {
 		return nil
 	}
 
+	if c.engine.EngineConfig.GetHomeMode() == "image" {
+		return c.addHomeImageMount(system, dest)
+	}
+
 	stagingDir, err := c.addHomeStagingDir(system, source, dest)
 	if err != nil {
 		return err
@@ -1647,6 +2006,28 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 			continue
 		}
 
+		// tmpfs mount requested with --mount type=tmpfs
+		if b.Tmpfs() {
+			if !c.engine.EngineConfig.File.UserBindControl {
+				sylog.Warningf("Ignoring %s tmpfs mount: user bind control disabled by system administrator", b.Destination)
+				continue
+			}
+			opts, err := b.TmpfsOptions()
+			if err != nil {
+				return fmt.Errorf("tmpfs %s: %s", b.Destination, err)
+			}
+			flags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV)
+			if b.Readonly() {
+				flags |= syscall.MS_RDONLY
+			}
+			sylog.Debugf("Adding tmpfs %s to mount list\n", b.Destination)
+			if err := system.Points.AddFS(mount.UserbindsTag, b.Destination, "tmpfs", flags, strings.Join(opts, ",")); err != nil {
+				return fmt.Errorf("unable to add tmpfs %s to mount list: %s", b.Destination, err)
+			}
+			c.setMountOrigin(b.Destination, "--mount type=tmpfs")
+			continue
+		}
+
 		flags := defaultFlags
 		source := b.Source
 		dst := b.Destination
@@ -1660,6 +2041,17 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 			flags |= syscall.MS_RDONLY
 		}
 
+		var options []string
+		if idmap := b.IDMap(); idmap != "" {
+			if !c.engine.EngineConfig.GetFakeroot() {
+				return fmt.Errorf("idmap bind option for %s requires --fakeroot", source)
+			}
+			if _, _, err := mount.ParseIDMap(idmap); err != nil {
+				return fmt.Errorf("bind %s: %s", source, err)
+			}
+			options = append(options, "idmap="+idmap)
+		}
+
 		// special case for /dev mount to override default mount behavior
 		// with --contain option or 'mount dev = minimal'
 		if strings.HasPrefix(dst, devPrefix) && strings.HasPrefix(src, devPrefix) {
@@ -1699,7 +2091,7 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 
 		sylog.Debugf("Adding %s to mount list\n", src)
 
-		if err := system.Points.AddBind(mount.UserbindsTag, src, dst, flags); err == mount.ErrMountExists {
+		if err := system.Points.AddBind(mount.UserbindsTag, src, dst, flags, options...); err == mount.ErrMountExists {
 			sylog.Warningf("While bind mounting '%s:%s': %s", src, dst, err)
 		} else if err != nil {
 			return fmt.Errorf("unable to add %s to mount list: %s", src, err)
@@ -1709,6 +2101,13 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 				c.session.OverrideDir(dst, src)
 			}
 			system.Points.AddRemount(mount.UserbindsTag, dst, flags)
+			if p := b.Propagation(); p != "" {
+				propagation, _ := mount.ConvertOptions([]string{p})
+				if err := system.Points.AddPropagation(mount.UserbindsTag, dst, propagation); err != nil {
+					return fmt.Errorf("bind-propagation %s of %s: %s", p, dst, err)
+				}
+			}
+			c.setMountOrigin(dst, fmt.Sprintf("--bind / --mount %s:%s", source, dst))
 		}
 	}
 
@@ -1771,6 +2170,7 @@ func (c *container) createTmpSource() (tmpSource, vartmpSource string, err error
 			}
 			tmpSource, _ = c.session.GetPath(tmpSource)
 			vartmpSource, _ = c.session.GetPath(vartmpSource)
+			c.tmpSession = true
 		}
 	}
 
@@ -1780,15 +2180,46 @@ func (c *container) createTmpSource() (tmpSource, vartmpSource string, err error
 	return tmpSource, vartmpSource, nil
 }
 
+// tmpfsSize returns size, in MiB, of a tmpfs set with option, clamped to the
+// sessiondir max size set by the administrator for unprivileged users.
+func (c *container) tmpfsSize(option string, size uint) uint {
+	if os.Geteuid() == 0 && !c.engine.EngineConfig.GetFakeroot() {
+		return size
+	}
+	maxSize := c.engine.EngineConfig.File.SessiondirMaxSize
+	if maxSize > 0 && size > maxSize {
+		sylog.Warningf("%s of %d MiB exceeds the 'sessiondir max size' of %d MiB, using %d MiB", option, size, maxSize, maxSize)
+		return maxSize
+	}
+	return size
+}
+
 // addTmpMount adds bind mount definitions for /tmp and /var/tmp in the
 // container, from the provided sources. /var/tmp is not mounted if it is a
-// symlink to /tmp in the container rootfs.
+// symlink to /tmp in the container rootfs. If their size is set, /tmp and
+// /var/tmp in the session directory are dedicated tmpfs mounts instead.
 func (c *container) addTmpMount(system *mount.System, tmpSource, vartmpSource string) error {
 	tmpResolved := fs.EvalRelative(tmpPath, c.session.FinalPath())
 	varTmpResolved := fs.EvalRelative(vartmpPath, c.session.FinalPath())
 	sylog.Debugf("Container /tmp resolves to %q", tmpResolved)
 	sylog.Debugf("Container /var/tmp resolves to %q", varTmpResolved)
 
+	if size := c.engine.EngineConfig.GetTmpSize(); size > 0 && c.tmpSession && c.sessionFsType == "tmpfs" {
+		dests := []string{tmpPath}
+		if varTmpResolved != tmpResolved {
+			dests = append(dests, vartmpPath)
+		}
+		flags := uintptr(c.suidFlag | syscall.MS_NODEV)
+		opts := fmt.Sprintf("mode=1777,size=%dm", c.tmpfsSize("--tmp-size", size))
+		for _, dest := range dests {
+			if err := system.Points.AddFS(mount.TmpTag, dest, "tmpfs", flags, opts); err != nil {
+				return fmt.Errorf("could not mount container's %s directory: %s", dest, err)
+			}
+			sylog.Verbosef("Default mount: %d MiB tmpfs:%s", size, dest)
+		}
+		return nil
+	}
+
 	flags := uintptr(syscall.MS_BIND | c.suidFlag | syscall.MS_NODEV | syscall.MS_REC)
 
 	if err := system.Points.AddBind(mount.TmpTag, tmpSource, tmpPath, flags); err == nil {
@@ -2140,6 +2571,188 @@ func (c *container) addFilesMount(system *mount.System) error {
 	return nil
 }
 
+// addMetadataMount binds the container metadata files replaced by metadata
+// edits of the image over those of the image, read-only.
+func (c *container) addMetadataMount(system *mount.System) error {
+	flags := uintptr(syscall.MS_BIND | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_RDONLY)
+
+	for dst, content := range c.engine.EngineConfig.GetMetadataFiles() {
+		if err := c.session.AddFile(dst, []byte(content)); err != nil {
+			return fmt.Errorf("failed to add %s session file: %s", dst, err)
+		}
+		src, _ := c.session.GetPath(dst)
+
+		sylog.Debugf("Adding edited metadata %s to mount list", dst)
+		if err := system.Points.AddBind(mount.FilesTag, src, dst, flags); err != nil {
+			return fmt.Errorf("unable to add %s to mount list: %s", dst, err)
+		}
+		if err := system.Points.AddRemount(mount.FilesTag, dst, flags); err != nil {
+			return fmt.Errorf("unable to add %s for remount: %s", dst, err)
+		}
+		c.setMountOrigin(dst, "'sif edit-metadata' of the image")
+	}
+	return nil
+}
+
+// addCgroupStatsMount binds the container cgroup read-only at
+// /.singularity.d/cgroup, so that processes in the container can read the
+// limits and usage of the container, rather than host-wide totals. With v1
+// cgroups, each subsystem is bound in a sub-directory.
+func (c *container) addCgroupStatsMount(system *mount.System) error {
+	const cgroupDir = "/.singularity.d/cgroup"
+
+	if cgroupsManager == nil {
+		sylog.Warningf("--cgroup-stats requires a cgroup, which could not be created for this container")
+		return nil
+	}
+
+	paths, err := cgroupsManager.GetCgroupPaths()
+	if err != nil {
+		return fmt.Errorf("while getting cgroup paths: %s", err)
+	}
+
+	flags := uintptr(syscall.MS_BIND | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_RDONLY)
+
+	for subsystem, src := range paths {
+		dst := filepath.Join(cgroupDir, subsystem)
+		sylog.Debugf("Adding cgroup %s to mount list", src)
+		if err := system.Points.AddBind(mount.FilesTag, src, dst, flags); err != nil {
+			return fmt.Errorf("unable to add %s to mount list: %s", src, err)
+		}
+		if err := system.Points.AddRemount(mount.FilesTag, dst, flags); err != nil {
+			return fmt.Errorf("unable to add %s for remount: %s", dst, err)
+		}
+		c.setMountOrigin(dst, "--cgroup-stats")
+	}
+	return nil
+}
+
+// lxcfsProcFiles are the /proc files virtualized by LXCFS.
+var lxcfsProcFiles = []string{"cpuinfo", "diskstats", "loadavg", "meminfo", "stat", "swaps", "uptime"}
+
+// addVirtualProcMount binds files over /proc/cpuinfo, /proc/meminfo etc. that
+// reflect the CPU and memory limits of the container, so that runtimes size
+// themselves to the allocation rather than the whole host. LXCFS is used when
+// it is mounted at 'lxcfs path'. Otherwise static cpuinfo and meminfo files
+// are generated from the cgroups configuration.
+func (c *container) addVirtualProcMount(system *mount.System) error {
+	if !c.engine.EngineConfig.GetVirtualProc() {
+		return nil
+	}
+	if !c.engine.EngineConfig.File.MountProc || c.engine.EngineConfig.GetNoProc() {
+		sylog.Warningf("--virtual-proc ignored, as /proc is not mounted in the container")
+		return nil
+	}
+
+	flags := uintptr(syscall.MS_BIND | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_RDONLY)
+
+	if lxcfs := c.engine.EngineConfig.File.LxcfsPath; lxcfs != "" && fs.IsFile(filepath.Join(lxcfs, "proc", "meminfo")) {
+		sylog.Debugf("Using LXCFS at %s for /proc files", lxcfs)
+		for _, f := range lxcfsProcFiles {
+			src := filepath.Join(lxcfs, "proc", f)
+			if !fs.IsFile(src) {
+				continue
+			}
+			if err := c.addProcFileBind(system, src, "/proc/"+f, flags); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cgJSON := c.engine.EngineConfig.GetCgroupsJSON()
+	if cgJSON == "" {
+		sylog.Warningf("--virtual-proc requires CPU or memory limits, or LXCFS, ignoring")
+		return nil
+	}
+	resources, err := cgroups.UnmarshalJSONResources(cgJSON)
+	if err != nil {
+		return fmt.Errorf("while reading cgroups config: %s", err)
+	}
+
+	if cpus, err := limitedCPUs(resources); err != nil {
+		return err
+	} else if cpus != nil {
+		host, err := os.ReadFile("/proc/cpuinfo")
+		if err != nil {
+			return err
+		}
+		content, err := files.CPUInfo(host, cpus)
+		if err != nil {
+			return fmt.Errorf("while generating cpuinfo: %s", err)
+		}
+		if err := c.addProcFile(system, "/proc/cpuinfo", content, flags); err != nil {
+			return err
+		}
+	}
+
+	if resources.Memory != nil && resources.Memory.Limit != nil && *resources.Memory.Limit > 0 {
+		host, err := os.ReadFile("/proc/meminfo")
+		if err != nil {
+			return err
+		}
+		content, err := files.MemInfo(host, *resources.Memory.Limit)
+		if err != nil {
+			return fmt.Errorf("while generating meminfo: %s", err)
+		}
+		if err := c.addProcFile(system, "/proc/meminfo", content, flags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// limitedCPUs returns the CPUs available to the container according to the
+// cpuset or CPU quota limits in resources, or nil if there is no CPU limit. A
+// quota of n CPUs is represented by the first n CPUs we can run on.
+func limitedCPUs(resources *specs.LinuxResources) ([]int, error) {
+	if resources.CPU == nil {
+		return nil, nil
+	}
+	if resources.CPU.Cpus != "" {
+		return files.ParseCPUList(resources.CPU.Cpus)
+	}
+	cpu := resources.CPU
+	if cpu.Quota == nil || *cpu.Quota <= 0 || cpu.Period == nil || *cpu.Period == 0 {
+		return nil, nil
+	}
+	n := int((uint64(*cpu.Quota) + *cpu.Period - 1) / *cpu.Period)
+
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return nil, fmt.Errorf("while getting cpu affinity: %s", err)
+	}
+	var cpus []int
+	for i := 0; i < len(set)*64 && len(cpus) < n; i++ {
+		if set.IsSet(i) {
+			cpus = append(cpus, i)
+		}
+	}
+	return cpus, nil
+}
+
+// addProcFile adds content as a session file bound over the /proc file dst.
+func (c *container) addProcFile(system *mount.System, dst string, content []byte, flags uintptr) error {
+	if err := c.session.AddFile(dst, content); err != nil {
+		return fmt.Errorf("failed to add %s session file: %s", dst, err)
+	}
+	src, _ := c.session.GetPath(dst)
+	return c.addProcFileBind(system, src, dst, flags)
+}
+
+func (c *container) addProcFileBind(system *mount.System, src, dst string, flags uintptr) error {
+	sylog.Debugf("Adding %s to mount list", dst)
+	if err := system.Points.AddBind(mount.FilesTag, src, dst, flags); err != nil {
+		return fmt.Errorf("unable to add %s to mount list: %s", dst, err)
+	}
+	if err := system.Points.AddRemount(mount.FilesTag, dst, flags); err != nil {
+		return fmt.Errorf("unable to add %s for remount: %s", dst, err)
+	}
+	c.setMountOrigin(dst, "--virtual-proc")
+	return nil
+}
+
 func (c *container) addIdentityMount(system *mount.System) error {
 	rootfs := c.session.RootFsPath()
 	defer c.session.Update()
@@ -2158,7 +2771,9 @@ func (c *container) addIdentityMount(system *mount.System) error {
 		return nil
 	}
 
-	if c.engine.EngineConfig.File.ConfigPasswd {
+	skipBinds := c.engine.EngineConfig.GetSkipBinds()
+
+	if c.engine.EngineConfig.File.ConfigPasswd && !slice.ContainsString(skipBinds, "/etc/passwd") {
 		passwd := filepath.Join(rootfs, "/etc/passwd")
 		_, home, err := c.getHomePaths()
 		if err != nil {
@@ -2178,6 +2793,7 @@ func (c *container) addIdentityMount(system *mount.System) error {
 				if err != nil {
 					return fmt.Errorf("unable to add /etc/passwd to mount list: %s", err)
 				}
+				c.setMountOrigin("/etc/passwd", "'config passwd' in singularity.conf (disable with --no-mount /etc/passwd)")
 				sylog.Verbosef("Default mount: /etc/passwd:/etc/passwd")
 			}
 		}
@@ -2185,7 +2801,7 @@ func (c *container) addIdentityMount(system *mount.System) error {
 		sylog.Verbosef("Skipping bind of the host's /etc/passwd")
 	}
 
-	if c.engine.EngineConfig.File.ConfigGroup {
+	if c.engine.EngineConfig.File.ConfigGroup && !slice.ContainsString(skipBinds, "/etc/group") {
 		group := filepath.Join(rootfs, "/etc/group")
 		content, err := files.Group(group, uid, c.engine.EngineConfig.GetTargetGID(), c)
 		if err != nil {
@@ -2201,6 +2817,7 @@ func (c *container) addIdentityMount(system *mount.System) error {
 			if err != nil {
 				return fmt.Errorf("unable to add /etc/group to mount list: %s", err)
 			}
+			c.setMountOrigin("/etc/group", "'config group' in singularity.conf (disable with --no-mount /etc/group)")
 			sylog.Verbosef("Default mount: /etc/group:/etc/group")
 		}
 	} else {
@@ -2213,28 +2830,15 @@ func (c *container) addIdentityMount(system *mount.System) error {
 func (c *container) addResolvConfMount(system *mount.System) error {
 	resolvConf := "/etc/resolv.conf"
 
-	if c.engine.EngineConfig.File.ConfigResolvConf {
-		var err error
-		var content []byte
-
-		dns := c.engine.EngineConfig.GetDNS()
+	if slice.ContainsString(c.engine.EngineConfig.GetSkipBinds(), resolvConf) {
+		sylog.Verbosef("Skipping bind of %s at user request", resolvConf)
+		return nil
+	}
 
-		if dns == "" {
-			r, err := os.Open(resolvConf)
-			if err != nil {
-				return err
-			}
-			content, err = io.ReadAll(r)
-			r.Close()
-			if err != nil {
-				return err
-			}
-		} else {
-			dns = strings.Replace(dns, " ", "", -1)
-			content, err = files.ResolvConf(strings.Split(dns, ","))
-			if err != nil {
-				return err
-			}
+	if c.engine.EngineConfig.File.ConfigResolvConf {
+		content, err := c.engine.resolvConfContent()
+		if err != nil {
+			return err
 		}
 		if err := c.session.AddFile(resolvConf, content); err != nil {
 			sylog.Warningf("failed to add resolv.conf session file: %s", err)
@@ -2246,6 +2850,7 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 		if err != nil {
 			return fmt.Errorf("unable to add %s to mount list: %s", resolvConf, err)
 		}
+		c.setMountOrigin(resolvConf, "'config resolv_conf' in singularity.conf (disable with --no-mount /etc/resolv.conf)")
 		sylog.Verbosef("Default mount: /etc/resolv.conf:/etc/resolv.conf")
 	} else {
 		sylog.Verbosef("Skipping bind of the host's %s", resolvConf)
@@ -2253,6 +2858,90 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 	return nil
 }
 
+// resolvConfContent returns the content for the container's /etc/resolv.conf,
+// from the host's file or the --dns servers, and any --dns-search domains.
+func (e *EngineOperations) resolvConfContent() (content []byte, err error) {
+	resolvConf := "/etc/resolv.conf"
+
+	dns := e.EngineConfig.GetDNS()
+	if dns == "" {
+		content, err = os.ReadFile(resolvConf)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		dns = strings.Replace(dns, " ", "", -1)
+		content, err = files.ResolvConf(strings.Split(dns, ","))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if search := e.EngineConfig.GetDNSSearch(); search != "" {
+		search = strings.Replace(search, " ", "", -1)
+		content, err = files.ResolvConfSearch(content, strings.Split(search, ","))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+// hostsContent returns the content for the container's /etc/hosts, from the
+// host's file and any --add-host entries.
+func (e *EngineOperations) hostsContent(netNS bool) ([]byte, error) {
+	hostsFile := "/etc/hosts"
+
+	// As in addBindsMount, a contained network namespace starts from a minimal
+	// default hosts file, rather than the host's.
+	var content []byte
+	if e.EngineConfig.GetContain() && netNS {
+		content = files.DefaultHosts()
+	} else {
+		b, err := os.ReadFile(hostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("while reading %s: %s", hostsFile, err)
+		}
+		content = b
+	}
+
+	if hosts := e.EngineConfig.GetAddHosts(); len(hosts) > 0 {
+		return files.Hosts(content, hosts)
+	}
+	return content, nil
+}
+
+func (c *container) addHostsMount(system *mount.System) error {
+	hostsFile := "/etc/hosts"
+
+	hosts := c.engine.EngineConfig.GetAddHosts()
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	if slice.ContainsString(c.engine.EngineConfig.GetSkipBinds(), hostsFile) {
+		sylog.Verbosef("Skipping bind of %s at user request", hostsFile)
+		return nil
+	}
+
+	content, err := c.engine.hostsContent(c.netNS)
+	if err != nil {
+		return err
+	}
+	if err := c.session.AddFile(hostsFile, content); err != nil {
+		return fmt.Errorf("failed to add hosts session file: %s", err)
+	}
+	sessionFile, _ := c.session.GetPath(hostsFile)
+
+	sylog.Debugf("Adding %s to mount list\n", hostsFile)
+	err = system.Points.AddBind(mount.FilesTag, sessionFile, hostsFile, syscall.MS_BIND)
+	if err != nil {
+		return fmt.Errorf("unable to add %s to mount list: %s", hostsFile, err)
+	}
+	c.setMountOrigin(hostsFile, "--add-host")
+	sylog.Verbosef("Default mount: /etc/hosts:/etc/hosts")
+	return nil
+}
+
 func (c *container) addHostnameMount(system *mount.System) error {
 	hostnameFile := "/etc/hostname"
 
@@ -2381,6 +3070,24 @@ func (c *container) prepareNetworkSetup(system *mount.System, pid int) (func(con
 		return nil, fmt.Errorf("error while setting network arguments: %s", err)
 	}
 
+	rate := c.engine.EngineConfig.File.NetworkLimitRate
+	egress := c.engine.EngineConfig.File.NetworkEgressAllow
+	userLimits := c.engine.EngineConfig.GetNetworkLimits()
+	// Unprivileged users can't relax the limits set by the administrator.
+	if euid != 0 {
+		for _, l := range userLimits {
+			key, _, _ := strings.Cut(l, "=")
+			if (key == "rate" && rate != "") || (key == "egress" && len(egress) > 0) {
+				return nil, fmt.Errorf("network limit %s is set in singularity.conf, and can only be overridden by root", key)
+			}
+		}
+	}
+	limits, err := network.ParseLimits(userLimits, rate, egress)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing network limits: %s", err)
+	}
+	networkSetup.SetLimits(limits)
+
 	return func(ctx context.Context) error {
 		if fakeroot || allowedNetUnpriv {
 			// prevent port hijacking between user processes
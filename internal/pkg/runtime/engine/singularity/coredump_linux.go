@@ -0,0 +1,39 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"syscall"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/coredump"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// collectCoreDump collects the core dump of the container process, which was
+// terminated by sig, into dir.
+func (e *EngineOperations) collectCoreDump(dir string, sig syscall.Signal) {
+	md := coredump.Metadata{
+		Image:  e.EngineConfig.GetImage(),
+		Signal: unix.SignalName(sig),
+	}
+	if e.EngineConfig.OciConfig.Process != nil {
+		md.Command = e.EngineConfig.OciConfig.Process.Args
+	}
+
+	digest, err := coredump.ImageDigest(md.Image)
+	if err != nil {
+		sylog.Warningf("While computing image digest: %v", err)
+	}
+	md.ImageDigest = digest
+
+	path, err := coredump.Collect(dir, e.EngineConfig.GetCwd(), containerStart, md)
+	if err != nil {
+		sylog.Errorf("Could not collect core dump: %v", err)
+		return
+	}
+	sylog.Infof("Crash of the container process collected in %s", path)
+}
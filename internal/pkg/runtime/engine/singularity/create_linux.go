@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net"
 	"net/rpc"
+	"time"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/singularity/rpc/client"
 	singularityConfig "github.com/sylabs/singularity/v4/pkg/runtime/engine/singularity/config"
@@ -36,6 +37,8 @@ func (e *EngineOperations) CreateContainer(ctx context.Context, pid int, rpcConn
 		return nil
 	}
 
+	containerStart = time.Now()
+
 	rpcOps := &client.RPC{
 		Client: rpc.NewClient(rpcConn),
 		Name:   e.CommonConfig.EngineName,
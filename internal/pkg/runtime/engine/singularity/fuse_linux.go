@@ -0,0 +1,279 @@
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/singularity/fusedriver"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/env"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/priv"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// fuseSupervisor supervises the FUSE drivers run in foreground mode by the
+// current process, the master process for drivers run from the host, or the
+// container process for drivers run from the container.
+var fuseSupervisor *fusedriver.Supervisor
+
+// runFuseDrivers execute FUSE drivers. Drivers run in foreground mode are
+// supervised by fuseSupervisor, and restarted in the mount namespace of the
+// container process pid if they crash.
+func (e *EngineOperations) runFuseDrivers(fromContainer bool, pid, usernsFd int) error {
+	// set PATH for the command
+	oldpath := os.Getenv("PATH")
+	defer func() {
+		os.Setenv("PATH", oldpath)
+	}()
+
+	if fromContainer {
+		e.setPathEnv()
+	} else {
+		os.Setenv("PATH", env.DefaultPath)
+	}
+
+	for _, fd := range e.EngineConfig.GetUnixSocketPair() {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
+
+	var usernsFh *os.File
+
+	if usernsFd >= 0 {
+		usernsFh = os.NewFile(uintptr(usernsFd), "/proc/self/ns/user")
+		if usernsFh == nil {
+			// this should never happen
+			return errors.New("cannot map /proc/self/ns/user file descriptor to a file handle")
+		}
+		defer usernsFh.Close()
+	}
+
+	fuseMounts := e.EngineConfig.GetFuseMount()
+	for i := range fuseMounts {
+		if fromContainer != fuseMounts[i].FromContainer {
+			syscall.Close(fuseMounts[i].Fd)
+			continue
+		}
+
+		mnt := fuseMounts[i].MountPoint
+		program := fuseMounts[i].Program
+		fd := fuseMounts[i].Fd
+
+		sylog.Debugf("Running FUSE driver for %s as %v, fd %d", mnt, program, fd)
+
+		fh := os.NewFile(uintptr(fd), "/dev/fuse")
+		if fh == nil {
+			// this should never happen
+			return errors.New("cannot map /dev/fuse file descriptor to a file handle")
+		}
+
+		// as we pass file handle as first element in ExtraFiles
+		// the fuse file descriptor becomes 3 for the FUSE program
+		args := append(program, "/dev/fd/3")
+
+		// Add the /dev/fuse file descriptor to the list of file
+		// descriptors to be passed to the new process.
+		// The Go library will set things up so that stdin, stdout
+		// and stderr are 0, 1, and 2, so the first element of
+		// ExtraFiles gets 3
+		files := []*os.File{fh}
+
+		if fuseMounts[i].Daemon {
+			// the master process does not need this file descriptor after
+			// running the program, make sure it gets closed; ignore any
+			// errors that happen here
+			defer fh.Close()
+
+			// Add /proc/<container_pid>/ns/user file descriptor for nsenter
+			// so it could join the container user namespace by using /dev/fd/4
+			if usernsFh != nil {
+				files = append(files, usernsFh)
+			}
+
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stderr = os.Stderr
+			cmd.Stdout = os.Stdout
+			cmd.ExtraFiles = files
+			if err := cmd.Run(); err != nil {
+				cmdline := strings.Join(args, " ")
+				return fmt.Errorf("could not start program %s: %s", cmdline, err)
+			}
+			continue
+		}
+
+		// The supervisor closes the /dev/fuse file descriptor of drivers run
+		// in foreground mode once they are started, and keeps the following
+		// ones open to restart them if they crash.
+		if usernsFh != nil {
+			nsFd, err := unix.Dup(int(usernsFh.Fd()))
+			if err != nil {
+				return fmt.Errorf("while duplicating user namespace file descriptor: %s", err)
+			}
+			files = append(files, os.NewFile(uintptr(nsFd), "/proc/self/ns/user"))
+		}
+
+		// add -f to run FUSE in foreground mode
+		args = append(args, "-f")
+
+		if fuseSupervisor == nil {
+			cfg := fusedriver.DefaultConfig
+			cfg.Remount = e.remountFuse(pid)
+			if !fromContainer && e.EngineConfig.GetInstance() {
+				cfg.OnChange = e.recordFuseDrivers
+			}
+			fuseSupervisor = fusedriver.New(cfg)
+		}
+		if err := fuseSupervisor.Start(mnt, args, files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remountFuse returns the function mounting a new FUSE connection over the
+// mount point of a crashed FUSE driver, in the mount namespace of the
+// container process pid, so that the driver can be restarted.
+func (e *EngineOperations) remountFuse(pid int) func(string) (*os.File, error) {
+	return func(mountPoint string) (*os.File, error) {
+		fuse, err := os.OpenFile("/dev/fuse", os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// The mount is made from the current user namespace, so the FUSE
+		// connection is owned by the current user, who is also the owner of
+		// the files of the container root user with fakeroot.
+		opts := fmt.Sprintf("fd=%d,rootmode=%o,user_id=%d,group_id=%d",
+			fuse.Fd(),
+			syscall.S_IFDIR&syscall.S_IFMT,
+			os.Getuid(),
+			os.Getgid(),
+		)
+		if e.EngineConfig.GetFakeroot() {
+			opts += ",allow_other"
+		}
+
+		sylog.Debugf("Remount FUSE mount point %s with options %s", mountPoint, opts)
+		if err := mountInNamespace(pid, mountPoint, "fuse", syscall.MS_NOSUID|syscall.MS_NODEV, opts); err != nil {
+			fuse.Close()
+			return nil, err
+		}
+		return fuse, nil
+	}
+}
+
+// mountInNamespace replaces the mount on target, relative to the root of the
+// process pid, by a new mount of a filesystem of type fstype, in the mount
+// namespace of the process. Privileges are escalated in the setuid workflow.
+func mountInNamespace(pid int, target, fstype string, flags uintptr, data string) error {
+	errCh := make(chan error, 1)
+
+	go func() {
+		// The thread joins another mount namespace and changes its root, so
+		// it is locked and never unlocked, to be terminated with the
+		// goroutine rather than reused by the runtime.
+		runtime.LockOSThread()
+
+		if os.Geteuid() != 0 {
+			priv.Escalate()
+			defer priv.Drop()
+		}
+
+		root, err := os.Open(fmt.Sprintf("/proc/%d/root", pid))
+		if err != nil {
+			errCh <- fmt.Errorf("while opening container root: %s", err)
+			return
+		}
+		defer root.Close()
+		ns, err := os.Open(fmt.Sprintf("/proc/%d/ns/mnt", pid))
+		if err != nil {
+			errCh <- fmt.Errorf("while opening container mount namespace: %s", err)
+			return
+		}
+		defer ns.Close()
+
+		// A thread sharing its filesystem attributes with other threads
+		// can't join a mount namespace.
+		if err := unix.Unshare(unix.CLONE_FS); err != nil {
+			errCh <- fmt.Errorf("while unsharing filesystem attributes: %s", err)
+			return
+		}
+		if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNS); err != nil {
+			errCh <- fmt.Errorf("while joining container mount namespace: %s", err)
+			return
+		}
+		if err := unix.Fchdir(int(root.Fd())); err != nil {
+			errCh <- fmt.Errorf("while changing directory to container root: %s", err)
+			return
+		}
+		if err := unix.Chroot("."); err != nil {
+			errCh <- fmt.Errorf("while changing root to container root: %s", err)
+			return
+		}
+
+		if err := unix.Unmount(target, unix.MNT_DETACH); err != nil && err != unix.EINVAL {
+			errCh <- fmt.Errorf("while unmounting %s: %s", target, err)
+			return
+		}
+		if err := unix.Mount(fstype, target, fstype, flags, data); err != nil {
+			errCh <- fmt.Errorf("while mounting %s: %s", target, err)
+			return
+		}
+		errCh <- nil
+	}()
+
+	return <-errCh
+}
+
+// stopFuseDrivers stops FUSE drivers running in foreground mode, in reverse
+// start order.
+func (e *EngineOperations) stopFuseDrivers() {
+	if fuseSupervisor != nil {
+		fuseSupervisor.Stop()
+	}
+}
+
+// reapFuseDrivers handles the termination of FUSE drivers running in
+// foreground mode, on SIGCHLD.
+func reapFuseDrivers() {
+	if fuseSupervisor != nil {
+		fuseSupervisor.Reap()
+	}
+}
+
+// fuseDriverExited handles the termination of the process pid, reaped outside
+// of the FUSE driver supervisor, and returns true if it was a FUSE driver.
+func fuseDriverExited(pid int, status syscall.WaitStatus) bool {
+	if fuseSupervisor == nil {
+		return false
+	}
+	return fuseSupervisor.Exited(pid, status)
+}
+
+// recordFuseDrivers records the status of FUSE drivers in the instance file.
+// The instance file doesn't exist until the container process has started, and
+// the initial status is recorded by PostStartProcess.
+func (e *EngineOperations) recordFuseDrivers(status []fusedriver.Status) {
+	file, err := instance.Get(e.CommonConfig.ContainerID, instance.SingSubDir)
+	if err != nil {
+		sylog.Debugf("Could not record FUSE driver status in instance file: %v", err)
+		return
+	}
+	file.FuseDrivers = status
+	if err := file.Update(); err != nil {
+		sylog.Warningf("Could not record FUSE driver status in instance file: %v", err)
+	}
+}
@@ -0,0 +1,559 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package fusedriver supervises the FUSE driver processes of --fusemount
+// mounts running in foreground mode. The health of drivers is checked
+// periodically, and they are stopped in reverse start order when the container
+// exits.
+//
+// The kernel only sends the FUSE_INIT request once on a FUSE connection, so a
+// new driver process can't serve the mount of a crashed driver. The supervisor
+// doesn't keep the /dev/fuse file descriptor of a driver open, so that the
+// connection is aborted when the driver terminates, and accesses to the mount
+// fail with ENOTCONN rather than hang. A crashed driver is restarted on a new
+// FUSE connection, mounted over the mount point by Config.Remount.
+package fusedriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// State is the state of a supervised FUSE driver.
+type State string
+
+const (
+	// StateRunning is the state of a driver whose process is running.
+	StateRunning State = "running"
+	// StateRestarting is the state of a driver that crashed, and is waiting
+	// to be restarted.
+	StateRestarting State = "restarting"
+	// StateExited is the state of a driver whose process exited successfully,
+	// for example after the filesystem was unmounted. It is not restarted.
+	StateExited State = "exited"
+	// StateFailed is the state of a driver that crashed, or was killed, more
+	// times than allowed, or could not be restarted.
+	StateFailed State = "failed"
+	// StateStopped is the state of a driver stopped by Stop.
+	StateStopped State = "stopped"
+)
+
+// Status reports the state of a supervised FUSE driver.
+type Status struct {
+	MountPoint string   `json:"mountPoint"`
+	Program    []string `json:"program"`
+	State      State    `json:"state"`
+	// PID is the process ID of the driver, when it is running.
+	PID int `json:"pid,omitempty"`
+	// Healthy is false when the driver process is running but not able to
+	// serve requests, for example when it has been stopped by a signal.
+	Healthy bool `json:"healthy"`
+	// Restarts is the number of times the driver was restarted.
+	Restarts int `json:"restarts"`
+	// LastExit describes how the last driver process terminated.
+	LastExit string    `json:"lastExit,omitempty"`
+	Started  time.Time `json:"started"`
+}
+
+// Config holds the settings of a Supervisor. Zero values are replaced by the
+// corresponding values of DefaultConfig.
+type Config struct {
+	// MaxRestarts is the number of times a crashed driver is restarted,
+	// before it is considered as failed.
+	MaxRestarts int
+	// RestartDelay is the delay before the first restart of a crashed
+	// driver. It doubles on each following restart.
+	RestartDelay time.Duration
+	// StopTimeout is how long Stop waits for a driver to terminate after
+	// SIGTERM, before it is killed.
+	StopTimeout time.Duration
+	// HealthInterval is the interval between health checks of drivers.
+	HealthInterval time.Duration
+	// OnChange, if set, is called with the status of all drivers when the
+	// state or health of a driver changes.
+	OnChange func([]Status)
+	// Remount, if set, is called before a crashed driver is restarted. It
+	// must mount a new FUSE connection over mountPoint, and return its
+	// /dev/fuse file, which is passed to the new driver process as file
+	// descriptor 3. Crashed drivers are not restarted when Remount is nil.
+	Remount func(mountPoint string) (*os.File, error)
+}
+
+// DefaultConfig holds the default Supervisor settings.
+var DefaultConfig = Config{
+	MaxRestarts:    3,
+	RestartDelay:   time.Second,
+	StopTimeout:    5 * time.Second,
+	HealthInterval: 5 * time.Second,
+}
+
+type driver struct {
+	args []string
+	// path is the program path resolved on first start, so that restarts
+	// don't depend on PATH.
+	path string
+	// files are the extra files following /dev/fuse, kept open to restart
+	// the driver.
+	files  []*os.File
+	status Status
+	// pidfd refers to the current driver process, so that it is not
+	// confused with another process reusing its PID once it is reaped. It is
+	// -1 when pidfd_open is not supported.
+	pidfd int
+	// exited is closed when the current driver process terminates.
+	exited   chan struct{}
+	stopping bool
+}
+
+// Supervisor runs and supervises FUSE drivers.
+type Supervisor struct {
+	cfg     Config
+	mu      sync.Mutex
+	drivers []*driver
+	stopped bool
+	cancel  context.CancelFunc
+}
+
+// New returns a Supervisor with configuration cfg, and starts health checks
+// of the drivers it will run.
+func New(cfg Config) *Supervisor {
+	if cfg.MaxRestarts == 0 {
+		cfg.MaxRestarts = DefaultConfig.MaxRestarts
+	}
+	if cfg.RestartDelay == 0 {
+		cfg.RestartDelay = DefaultConfig.RestartDelay
+	}
+	if cfg.StopTimeout == 0 {
+		cfg.StopTimeout = DefaultConfig.StopTimeout
+	}
+	if cfg.HealthInterval == 0 {
+		cfg.HealthInterval = DefaultConfig.HealthInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Supervisor{cfg: cfg, cancel: cancel}
+	go s.watch(ctx)
+	return s
+}
+
+// Start runs the FUSE driver program args, for mountPoint, with files as
+// extra file descriptors starting at 3, the first one being /dev/fuse. The
+// supervisor takes ownership of files. The /dev/fuse file is closed once the
+// driver is started, the following files are kept open to restart the driver,
+// and closed by Stop.
+func (s *Supervisor) Start(mountPoint string, args []string, files []*os.File) error {
+	d := &driver{
+		args:  args,
+		pidfd: -1,
+		status: Status{
+			MountPoint: mountPoint,
+			Program:    args,
+		},
+	}
+
+	var fuse *os.File
+	if len(files) > 0 {
+		fuse = files[0]
+		defer fuse.Close()
+		d.files = files[1:]
+	}
+
+	err := errors.New("FUSE driver supervisor is stopped")
+	s.mu.Lock()
+	if !s.stopped {
+		err = s.startLocked(d, fuse)
+	}
+	if err == nil {
+		s.drivers = append(s.drivers, d)
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		for _, f := range d.files {
+			f.Close()
+		}
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// startLocked starts a process for driver d, serving the FUSE connection
+// fuse. s.mu must be held.
+func (s *Supervisor) startLocked(d *driver, fuse *os.File) error {
+	cmd := exec.Command(d.args[0], d.args[1:]...)
+	if d.path != "" {
+		cmd.Path = d.path
+		cmd.Err = nil
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if fuse != nil {
+		cmd.ExtraFiles = append([]*os.File{fuse}, d.files...)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start program %s: %s", strings.Join(d.args, " "), err)
+	}
+	// The process is waited for with wait4 by Reap or Exited, as the
+	// container process may reap any of its children.
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	d.path = cmd.Path
+
+	sylog.Debugf("FUSE driver for %s started with PID %d", d.status.MountPoint, pid)
+
+	// pidfd_open requires Linux 5.3, signals are sent to the PID otherwise.
+	if fd, err := unix.PidfdOpen(pid, 0); err == nil {
+		d.pidfd = fd
+	} else {
+		sylog.Debugf("Could not open pidfd of FUSE driver with PID %d: %s", pid, err)
+	}
+
+	d.exited = make(chan struct{})
+	d.status.PID = pid
+	d.status.State = StateRunning
+	d.status.Healthy = true
+	d.status.Started = time.Now()
+	return nil
+}
+
+// Status returns the status of the drivers, in start order.
+func (s *Supervisor) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := make([]Status, 0, len(s.drivers))
+	for _, d := range s.drivers {
+		status = append(status, d.status)
+	}
+	return status
+}
+
+func (s *Supervisor) notify() {
+	if s.cfg.OnChange != nil {
+		s.cfg.OnChange(s.Status())
+	}
+}
+
+// Exited must be called when a child process with pid, which terminated with
+// status ws, is reaped by a wait4 call outside of the supervisor. It returns
+// true if the process was a FUSE driver.
+func (s *Supervisor) Exited(pid int, ws syscall.WaitStatus) bool {
+	return s.terminated(pid, &ws)
+}
+
+// terminated handles the termination of the driver process pid, with status
+// ws, or nil if the process was reaped without its status being known.
+func (s *Supervisor) terminated(pid int, ws *syscall.WaitStatus) bool {
+	s.mu.Lock()
+
+	var d *driver
+	for _, e := range s.drivers {
+		if e.status.PID == pid && e.status.State == StateRunning {
+			d = e
+			break
+		}
+	}
+	if d == nil {
+		s.mu.Unlock()
+		return false
+	}
+
+	mnt := d.status.MountPoint
+	close(d.exited)
+	if d.pidfd >= 0 {
+		unix.Close(d.pidfd)
+		d.pidfd = -1
+	}
+	d.status.PID = 0
+	d.status.Healthy = false
+	d.status.LastExit = describe(ws)
+
+	switch {
+	case d.stopping || s.stopped:
+		d.status.State = StateStopped
+		sylog.Debugf("FUSE process for mount point %s %s", mnt, d.status.LastExit)
+	case ws != nil && ws.Exited() && ws.ExitStatus() == 0:
+		d.status.State = StateExited
+		sylog.Debugf("FUSE process for mount point %s terminated", mnt)
+	case s.cfg.Remount != nil && d.status.Restarts < s.cfg.MaxRestarts:
+		d.status.State = StateRestarting
+		delay := s.cfg.RestartDelay << d.status.Restarts
+		sylog.Warningf("FUSE process for mount point %s %s, restarting in %s", mnt, d.status.LastExit, delay)
+		time.AfterFunc(delay, func() { s.restart(d) })
+	case s.cfg.Remount != nil:
+		d.status.State = StateFailed
+		sylog.Errorf("FUSE process for mount point %s %s, giving up after %d restarts", mnt, d.status.LastExit, d.status.Restarts)
+	default:
+		d.status.State = StateFailed
+		sylog.Errorf("FUSE process for mount point %s %s, the mount is no longer available", mnt, d.status.LastExit)
+	}
+	s.mu.Unlock()
+
+	s.notify()
+	return true
+}
+
+// restart mounts a new FUSE connection over the mount point of the crashed
+// driver d, and starts a new driver process serving it.
+func (s *Supervisor) restart(d *driver) {
+	s.mu.Lock()
+	if s.stopped || d.stopping || d.status.State != StateRestarting {
+		s.mu.Unlock()
+		return
+	}
+	mnt := d.status.MountPoint
+	s.mu.Unlock()
+
+	fuse, err := s.cfg.Remount(mnt)
+	if err != nil {
+		err = fmt.Errorf("could not remount FUSE filesystem: %w", err)
+	} else {
+		defer fuse.Close()
+	}
+
+	s.mu.Lock()
+	if s.stopped || d.stopping || d.status.State != StateRestarting {
+		s.mu.Unlock()
+		return
+	}
+	if err == nil {
+		d.status.Restarts++
+		err = s.startLocked(d, fuse)
+	}
+	if err != nil {
+		sylog.Errorf("While restarting FUSE driver for %s: %v", mnt, err)
+		d.status.State = StateFailed
+		d.status.LastExit = err.Error()
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// running returns the process IDs of the running drivers.
+func (s *Supervisor) running() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pids []int
+	for _, d := range s.drivers {
+		if d.status.State == StateRunning {
+			pids = append(pids, d.status.PID)
+		}
+	}
+	return pids
+}
+
+// Reap waits for terminated driver processes, without blocking, and handles
+// their termination. It should be called on SIGCHLD.
+func (s *Supervisor) Reap() {
+	for _, pid := range s.running() {
+		s.reap(pid)
+	}
+}
+
+// reap waits for the driver process pid, without blocking, and handles its
+// termination. It returns true if the process terminated.
+func (s *Supervisor) reap(pid int) bool {
+	var ws syscall.WaitStatus
+	wpid, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, nil)
+	switch {
+	case err == nil && wpid == pid:
+		s.Exited(pid, ws)
+		return true
+	case err == syscall.ECHILD:
+		// The process was reaped by someone else, without calling Exited,
+		// its exit status is lost.
+		s.terminated(pid, nil)
+		return true
+	}
+	return false
+}
+
+// check reaps terminated drivers, and updates the health of running drivers
+// from their process state.
+func (s *Supervisor) check() {
+	s.Reap()
+
+	changed := false
+	s.mu.Lock()
+	for _, d := range s.drivers {
+		if d.status.State != StateRunning {
+			continue
+		}
+		healthy := processHealthy(d.status.PID)
+		if healthy != d.status.Healthy {
+			if !healthy {
+				sylog.Warningf("FUSE process for mount point %s is not responding", d.status.MountPoint)
+			}
+			d.status.Healthy = healthy
+			changed = true
+		}
+	}
+	s.mu.Unlock()
+
+	if changed {
+		s.notify()
+	}
+}
+
+func (s *Supervisor) watch(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+// Stop terminates the drivers in reverse start order, so that drivers for
+// nested mount points are stopped first. Each driver is sent SIGTERM, and
+// killed if it has not terminated after the stop timeout. The files passed
+// to Start are closed.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	s.cancel()
+	drivers := s.drivers
+	s.mu.Unlock()
+
+	for i := len(drivers) - 1; i >= 0; i-- {
+		s.stop(drivers[i])
+		for _, f := range drivers[i].files {
+			f.Close()
+		}
+	}
+}
+
+func (s *Supervisor) stop(d *driver) {
+	s.mu.Lock()
+	d.stopping = true
+	mnt := d.status.MountPoint
+	pid := d.status.PID
+	exited := d.exited
+	running := d.status.State == StateRunning
+	if d.status.State == StateRestarting {
+		d.status.State = StateStopped
+	}
+	s.mu.Unlock()
+
+	if !running {
+		return
+	}
+
+	if err := s.signal(d, syscall.SIGTERM); err == syscall.ESRCH {
+		s.wait(pid, exited, s.cfg.StopTimeout)
+		return
+	} else if err != nil {
+		sylog.Warningf("Can not send SIGTERM to FUSE process: %s", err)
+	} else if s.wait(pid, exited, s.cfg.StopTimeout) {
+		return
+	}
+
+	sylog.Warningf("FUSE process for mount point %s did not terminate, killing it", mnt)
+	if err := s.signal(d, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		sylog.Warningf("Can not send SIGKILL to FUSE process: %s", err)
+		return
+	}
+	s.wait(pid, exited, s.cfg.StopTimeout)
+}
+
+// signal sends sig to the process of driver d, only if it has not been
+// waited for, so that a process reusing its PID is never signaled. It returns
+// ESRCH if the process has already terminated.
+func (s *Supervisor) signal(d *driver, sig syscall.Signal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d.status.State != StateRunning {
+		return syscall.ESRCH
+	}
+	if d.pidfd >= 0 {
+		return unix.PidfdSendSignal(d.pidfd, sig, nil, 0)
+	}
+	// Without pidfd, check that the process is still a child that has not
+	// been reaped, by someone else than the supervisor, before signaling it.
+	var info unix.Siginfo
+	err := unix.Waitid(unix.P_PID, d.status.PID, &info, unix.WEXITED|unix.WNOHANG|unix.WNOWAIT, nil)
+	if err == unix.ECHILD {
+		return syscall.ESRCH
+	} else if err != nil {
+		return err
+	}
+	return syscall.Kill(d.status.PID, sig)
+}
+
+// wait waits up to timeout for the driver process pid to terminate. It returns
+// false on timeout.
+func (s *Supervisor) wait(pid int, exited <-chan struct{}, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-exited:
+			return true
+		case <-deadline:
+			return false
+		case <-ticker.C:
+			if s.reap(pid) {
+				return true
+			}
+		}
+	}
+}
+
+// processHealthy returns false if the process pid is stopped or traced, so
+// that it can't serve FUSE requests.
+func processHealthy(pid int) bool {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, in parentheses, which may contain
+	// spaces.
+	stat := string(b)
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 || i+2 >= len(stat) {
+		return false
+	}
+	switch stat[i+2] {
+	case 'T', 't', 'Z', 'X':
+		return false
+	}
+	return true
+}
+
+func describe(ws *syscall.WaitStatus) string {
+	if ws == nil {
+		return "was reaped with an unknown exit status"
+	}
+	if ws.Signaled() {
+		return fmt.Sprintf("killed by signal %s", ws.Signal())
+	}
+	return fmt.Sprintf("exited with status %d", ws.ExitStatus())
+}
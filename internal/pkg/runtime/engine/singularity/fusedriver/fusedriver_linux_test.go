@@ -0,0 +1,509 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fusedriver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// testDriverEnv is set to run the test binary as a FUSE driver serving a
+	// filesystem with a single file.
+	testDriverEnv     = "FUSEDRIVER_TEST_DRIVER"
+	testDriverFile    = "file"
+	testDriverContent = "hello\n"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testDriverEnv) != "" {
+		serveTestDriver(os.NewFile(3, "/dev/fuse"))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// FUSE kernel protocol opcodes handled by the test driver.
+const (
+	fuseLookup  = 1
+	fuseForget  = 2
+	fuseGetattr = 3
+	fuseOpen    = 14
+	fuseRead    = 15
+	fuseRelease = 18
+	fuseFlush   = 25
+	fuseInit    = 26
+
+	fuseInHeaderSize = 40
+	fuseAttrSize     = 88
+)
+
+// serveTestDriver serves FUSE requests read from fuse, until it is closed.
+func serveTestDriver(fuse *os.File) {
+	buf := make([]byte, 1<<20+4096)
+	le := binary.LittleEndian
+
+	for {
+		n, err := fuse.Read(buf)
+		if err != nil {
+			return
+		}
+		in := buf[:n]
+		opcode := le.Uint32(in[4:])
+		unique := le.Uint64(in[8:])
+		nodeid := le.Uint64(in[16:])
+		arg := in[fuseInHeaderSize:]
+
+		var out []byte
+		errno := syscall.ENOSYS
+		switch opcode {
+		case fuseForget:
+			continue
+		case fuseInit:
+			out = make([]byte, 64)
+			le.PutUint32(out[0:], 7)
+			le.PutUint32(out[4:], 31)
+			le.PutUint32(out[16:], 4096<<8) // max_background, congestion_threshold
+			le.PutUint32(out[20:], 1<<20)   // max_write
+			errno = 0
+		case fuseGetattr:
+			out = make([]byte, 16+fuseAttrSize)
+			testDriverAttr(out[16:], nodeid)
+			errno = 0
+		case fuseLookup:
+			errno = syscall.ENOENT
+			if nodeid == 1 && strings.TrimRight(string(arg), "\x00") == testDriverFile {
+				out = make([]byte, 40+fuseAttrSize)
+				le.PutUint64(out[0:], 2)
+				testDriverAttr(out[40:], 2)
+				errno = 0
+			}
+		case fuseOpen:
+			out = make([]byte, 16)
+			errno = 0
+		case fuseRead:
+			offset := le.Uint64(arg[8:])
+			size := uint64(le.Uint32(arg[16:]))
+			content := uint64(len(testDriverContent))
+			if offset < content {
+				end := offset + size
+				if end > content {
+					end = content
+				}
+				out = []byte(testDriverContent[offset:end])
+			}
+			errno = 0
+		case fuseFlush, fuseRelease:
+			errno = 0
+		}
+
+		header := make([]byte, 16, 16+len(out))
+		le.PutUint32(header[0:], uint32(16+len(out)))
+		le.PutUint32(header[4:], uint32(-int32(errno)))
+		le.PutUint64(header[8:], unique)
+		if errno != 0 {
+			out = nil
+			le.PutUint32(header[0:], 16)
+		}
+		if _, err := fuse.Write(append(header, out...)); err != nil {
+			return
+		}
+	}
+}
+
+// testDriverAttr writes the attributes of node in attr: the root directory
+// for node 1, the file otherwise.
+func testDriverAttr(attr []byte, node uint64) {
+	le := binary.LittleEndian
+	mode, nlink, size := uint32(unix.S_IFDIR|0o755), uint32(2), uint64(0)
+	if node != 1 {
+		mode, nlink, size = unix.S_IFREG|0o644, 1, uint64(len(testDriverContent))
+	}
+	le.PutUint64(attr[0:], node)
+	le.PutUint64(attr[8:], size)
+	le.PutUint32(attr[60:], mode)
+	le.PutUint32(attr[64:], nlink)
+}
+
+var testConfig = Config{
+	StopTimeout:    time.Second,
+	HealthInterval: time.Hour,
+}
+
+// newTestSupervisor returns a Supervisor with configuration cfg, stopped at
+// the end of the test. Drivers still running after Stop are reported and
+// killed, so that no test leaves driver processes behind.
+func newTestSupervisor(t *testing.T, cfg Config) *Supervisor {
+	t.Helper()
+
+	s := New(cfg)
+	t.Cleanup(func() {
+		s.Stop()
+		for _, st := range s.Status() {
+			if st.PID != 0 {
+				t.Errorf("driver for %s still running with PID %d after Stop", st.MountPoint, st.PID)
+				syscall.Kill(st.PID, syscall.SIGKILL)
+			}
+		}
+	})
+	return s
+}
+
+// waitState reaps drivers until the driver at index i is in state want.
+func waitState(t *testing.T, s *Supervisor, i int, want State) Status {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.Reap()
+		if st := s.Status()[i]; st.State == want {
+			return st
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("driver %d not in state %s: %+v", i, want, s.Status()[i])
+	return Status{}
+}
+
+func TestCrash(t *testing.T) {
+	s := newTestSupervisor(t, testConfig)
+
+	if err := s.Start("/mnt", []string{"/bin/sh", "-c", "exit 1"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	st := waitState(t, s, 0, StateFailed)
+	if st.LastExit != "exited with status 1" {
+		t.Errorf("got last exit %q", st.LastExit)
+	}
+	if st.PID != 0 || st.Healthy {
+		t.Errorf("unexpected status of crashed driver: %+v", st)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	remounts := 0
+	cfg := testConfig
+	cfg.MaxRestarts = 2
+	cfg.RestartDelay = 10 * time.Millisecond
+	cfg.Remount = func(mountPoint string) (*os.File, error) {
+		if mountPoint != "/mnt" {
+			t.Errorf("remount of %s", mountPoint)
+		}
+		remounts++
+		return os.Open(os.DevNull)
+	}
+	s := newTestSupervisor(t, cfg)
+
+	files := make([]*os.File, 2)
+	for i := range files {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[i] = f
+	}
+	// The driver crashes while it is given the remounted /dev/fuse file and
+	// the other files as descriptors 3 and 4, and exits successfully
+	// otherwise.
+	script := "[ -e /dev/fd/3 ] && [ -e /dev/fd/4 ] && exit 1; exit 0"
+	if err := s.Start("/mnt", []string{"/bin/sh", "-c", script}, files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	st := waitState(t, s, 0, StateFailed)
+	if st.Restarts != 2 || remounts != 2 {
+		t.Errorf("got %d restarts and %d remounts, want 2", st.Restarts, remounts)
+	}
+	if st.LastExit != "exited with status 1" {
+		t.Errorf("got last exit %q", st.LastExit)
+	}
+}
+
+func TestRestartRemountError(t *testing.T) {
+	cfg := testConfig
+	cfg.RestartDelay = 10 * time.Millisecond
+	cfg.Remount = func(string) (*os.File, error) {
+		return nil, errors.New("no FUSE")
+	}
+	s := newTestSupervisor(t, cfg)
+
+	if err := s.Start("/mnt", []string{"/bin/sh", "-c", "exit 1"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	st := waitState(t, s, 0, StateFailed)
+	if st.Restarts != 0 || !strings.Contains(st.LastExit, "no FUSE") {
+		t.Errorf("unexpected status of driver that could not be remounted: %+v", st)
+	}
+}
+
+func TestReapedElsewhere(t *testing.T) {
+	s := newTestSupervisor(t, testConfig)
+
+	if err := s.Start("/mnt", []string{"/bin/sh", "-c", "exit 1"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Reap the driver without calling Exited.
+	pid := s.Status()[0].PID
+	if _, err := syscall.Wait4(pid, nil, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	st := waitState(t, s, 0, StateFailed)
+	if st.LastExit != "was reaped with an unknown exit status" {
+		t.Errorf("got last exit %q", st.LastExit)
+	}
+}
+
+func TestStopReapedElsewhere(t *testing.T) {
+	for _, usePidfd := range []bool{true, false} {
+		t.Run(fmt.Sprintf("pidfd=%t", usePidfd), func(t *testing.T) {
+			s := newTestSupervisor(t, testConfig)
+
+			if err := s.Start("/mnt", []string{"/bin/sleep", "60"}, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d := s.drivers[0]
+			if !usePidfd && d.pidfd >= 0 {
+				unix.Close(d.pidfd)
+				d.pidfd = -1
+			}
+
+			// Kill and reap the driver without calling Exited, the
+			// supervisor must not signal its PID anymore.
+			pid := d.status.PID
+			if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := syscall.Wait4(pid, nil, 0, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.signal(d, syscall.SIGTERM); err != syscall.ESRCH {
+				t.Errorf("got error %v signaling reaped driver, want %v", err, syscall.ESRCH)
+			}
+
+			s.Stop()
+
+			st := s.Status()[0]
+			if st.State != StateStopped || st.LastExit != "was reaped with an unknown exit status" {
+				t.Errorf("unexpected status after Stop: %+v", st)
+			}
+		})
+	}
+}
+
+func TestExitSuccess(t *testing.T) {
+	s := newTestSupervisor(t, testConfig)
+
+	if err := s.Start("/mnt", []string{"/bin/true"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitState(t, s, 0, StateExited)
+}
+
+// mountTestFuse mounts a new FUSE connection on mnt, replacing any existing
+// mount, and returns its /dev/fuse file.
+func mountTestFuse(mnt string) (*os.File, error) {
+	fuse, err := os.OpenFile("/dev/fuse", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Unmount(mnt, unix.MNT_DETACH); err != nil && err != unix.EINVAL {
+		fuse.Close()
+		return nil, err
+	}
+	opts := fmt.Sprintf("fd=%d,rootmode=40000,user_id=0,group_id=0", fuse.Fd())
+	if err := unix.Mount("fusedriver", mnt, "fuse", unix.MS_NOSUID|unix.MS_NODEV, opts); err != nil {
+		fuse.Close()
+		return nil, err
+	}
+	return fuse, nil
+}
+
+// startTestDriver mounts a FUSE filesystem on a temporary directory, served
+// by the test driver supervised by s, and returns the path of the file of the
+// filesystem once it has been read successfully.
+func startTestDriver(t *testing.T, s *Supervisor) (mnt, file string) {
+	t.Helper()
+
+	mnt = t.TempDir()
+	fuse, err := mountTestFuse(mnt)
+	if err != nil {
+		t.Skipf("could not mount FUSE filesystem: %v", err)
+	}
+	t.Cleanup(func() { unix.Unmount(mnt, unix.MNT_DETACH) })
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The environment is set by env, so that restarted drivers also serve
+	// the filesystem.
+	args := []string{"/usr/bin/env", testDriverEnv + "=1", self}
+	if err := s.Start(mnt, args, []*os.File{fuse}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file = filepath.Join(mnt, testDriverFile)
+	checkTestFile(t, file)
+	return mnt, file
+}
+
+func checkTestFile(t *testing.T, file string) {
+	t.Helper()
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", file, err)
+	}
+	if string(b) != testDriverContent {
+		t.Fatalf("got content %q, want %q", b, testDriverContent)
+	}
+}
+
+func TestCrashMount(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	s := newTestSupervisor(t, testConfig)
+
+	_, file := startTestDriver(t, s)
+
+	if err := syscall.Kill(s.Status()[0].PID, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	st := waitState(t, s, 0, StateFailed)
+	if st.LastExit != "killed by signal killed" {
+		t.Errorf("got last exit %q", st.LastExit)
+	}
+
+	// The connection is aborted with the driver, so that accesses fail
+	// rather than wait for a driver that will never answer.
+	done := make(chan error, 1)
+	go func() {
+		_, err := os.ReadFile(file)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, syscall.ENOTCONN) {
+			t.Errorf("got error %v reading through mount of crashed driver, want %v", err, syscall.ENOTCONN)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("read through mount of crashed driver hangs")
+	}
+}
+
+func TestRestartMount(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	cfg := testConfig
+	cfg.RestartDelay = 10 * time.Millisecond
+	cfg.Remount = mountTestFuse
+	s := newTestSupervisor(t, cfg)
+
+	_, file := startTestDriver(t, s)
+
+	pid := s.Status()[0].PID
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.Reap()
+		st := s.Status()[0]
+		if st.State == StateRunning && st.Restarts == 1 {
+			if st.PID == pid || st.LastExit != "killed by signal killed" {
+				t.Errorf("unexpected status of restarted driver: %+v", st)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("driver not restarted: %+v", st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The restarted driver serves the new FUSE connection.
+	checkTestFile(t, file)
+}
+
+func TestStopOrder(t *testing.T) {
+	var changes [][]Status
+	cfg := testConfig
+	cfg.OnChange = func(s []Status) { changes = append(changes, s) }
+	s := newTestSupervisor(t, cfg)
+
+	log := filepath.Join(t.TempDir(), "log")
+	for _, mnt := range []string{"/a", "/a/b"} {
+		script := "trap 'echo " + mnt + " >> " + log + "; exit 0' TERM; while true; do sleep 0.01; done"
+		if err := s.Start(mnt, []string{"/bin/sh", "-c", script}, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Let the shells install their trap.
+	time.Sleep(200 * time.Millisecond)
+
+	s.Stop()
+
+	for i, st := range s.Status() {
+		if st.State != StateStopped {
+			t.Errorf("driver %d in state %s after Stop", i, st.State)
+		}
+	}
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(string(b)), []string{"/a/b", "/a"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got stop order %v, want %v", got, want)
+	}
+	if len(changes) == 0 {
+		t.Errorf("OnChange not called")
+	}
+	if err := s.Start("/c", []string{"/bin/true"}, nil); err == nil {
+		t.Errorf("unexpected success starting driver after Stop")
+	}
+}
+
+func TestStopKill(t *testing.T) {
+	cfg := testConfig
+	cfg.StopTimeout = 100 * time.Millisecond
+	s := newTestSupervisor(t, cfg)
+
+	if err := s.Start("/mnt", []string{"/bin/sh", "-c", "trap '' TERM; while true; do sleep 0.01; done"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	s.Stop()
+
+	st := s.Status()[0]
+	if st.State != StateStopped || st.LastExit != "killed by signal killed" {
+		t.Errorf("unexpected status after Stop: %+v", st)
+	}
+}
+
+func TestProcessHealthy(t *testing.T) {
+	if !processHealthy(os.Getpid()) {
+		t.Errorf("current process not healthy")
+	}
+	if processHealthy(-1) {
+		t.Errorf("invalid process healthy")
+	}
+}
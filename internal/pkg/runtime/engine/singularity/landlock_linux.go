@@ -0,0 +1,123 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/security/landlock"
+	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
+)
+
+// landlockEnabled returns whether the container process is restricted with
+// Landlock, as requested with --security landlock, or by default in
+// singularity.conf.
+func (e *EngineOperations) landlockEnabled() bool {
+	return e.EngineConfig.File.Landlock || slice.ContainsString(e.EngineConfig.GetSecurity(), "landlock")
+}
+
+// landlockRules returns the Landlock rules restricting the container process
+// to the container rootfs, and the paths bound or mounted into it by
+// Singularity. The rootfs is read-only, unless the container is writable, as
+// are read-only binds. A mount that is not declared, such as one propagated
+// into the container from the host, can therefore never be written, and can't
+// be read if it is mounted on a top-level directory.
+func (e *EngineOperations) landlockRules() ([]landlock.Rule, error) {
+	cfg := e.EngineConfig
+
+	writableRoot := cfg.GetWritableImage() || cfg.GetWritableTmpfs()
+	for _, o := range cfg.GetOverlayImage() {
+		writableRoot = writableRoot || !strings.HasSuffix(o, ":ro")
+	}
+	rules, err := rootfsRules("/", writableRoot)
+	if err != nil {
+		return nil, err
+	}
+	rules = append(rules,
+		landlock.Rule{Path: "/dev", Writable: true},
+		landlock.Rule{Path: "/proc", Writable: true},
+		landlock.Rule{Path: "/sys"},
+	)
+	add := func(path string, writable bool) {
+		if path != "" {
+			rules = append(rules, landlock.Rule{Path: filepath.Clean(path), Writable: writable})
+		}
+	}
+
+	if !cfg.GetNoTmp() {
+		add("/tmp", true)
+		add("/var/tmp", true)
+	}
+	if !cfg.GetNoHome() {
+		add(cfg.GetHomeDest(), true)
+	}
+	if !cfg.GetContain() && !cfg.GetNoCwd() {
+		add(cfg.GetCwd(), true)
+	}
+	for _, dir := range cfg.GetScratchDir() {
+		for _, d := range strings.Split(dir, ",") {
+			add(d, true)
+		}
+	}
+	requiredBinds, err := e.requiredBinds()
+	if err != nil {
+		return nil, fmt.Errorf("while expanding 'required binds' of singularity.conf: %w", err)
+	}
+	bindPaths, err := e.bindPaths()
+	if err != nil {
+		return nil, fmt.Errorf("while expanding 'bind path' of singularity.conf: %w", err)
+	}
+	for _, bindpath := range append(requiredBinds, bindPaths...) {
+		src, dst, _ := strings.Cut(bindpath, ":")
+		if dst == "" {
+			dst = src
+		}
+		add(dst, true)
+	}
+	for _, b := range cfg.GetBindPath() {
+		add(b.Destination, !b.Readonly())
+	}
+	for _, fm := range cfg.GetFuseMount() {
+		add(fm.MountPoint, true)
+	}
+
+	return rules, nil
+}
+
+// rootfsRules returns the Landlock rules granting access to the container
+// rootfs mounted at root. Rather than granting access beneath root itself,
+// which would include any mount in the container, access is granted beneath
+// each of its top-level entries that is not a mount point. The mount points
+// used by the container are granted access by their own rules. Only listing is
+// granted on root, so that its entries can be found.
+func rootfsRules(root string, writable bool) ([]landlock.Rule, error) {
+	entries, err := proc.GetMountInfoEntry("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	mountPoints := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		mountPoints[filepath.Clean(e.Point)] = true
+	}
+
+	dirents, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("while reading container rootfs: %w", err)
+	}
+	rules := []landlock.Rule{{Path: root, ListOnly: true}}
+	for _, d := range dirents {
+		path := filepath.Join(root, d.Name())
+		if mountPoints[path] {
+			continue
+		}
+		rules = append(rules, landlock.Rule{Path: path, Writable: writable})
+	}
+	return rules, nil
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -6,6 +6,7 @@
 package singularity
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"syscall"
@@ -39,10 +40,23 @@ func (e *EngineOperations) MonitorContainer(pid int, signals chan os.Signal) (sy
 		return callbacks[0].(singularitycallback.MonitorContainer)(e.CommonConfig, pid, signals)
 	}
 
+	if e.EngineConfig.GetInstance() && e.EngineConfig.GetWatchHostFiles() != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go e.watchHostFiles(ctx, pid)
+	}
+
+	if cgroupsManager != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go e.watchOOM(ctx)
+	}
+
 	for {
 		s := <-signals
 		switch s {
 		case syscall.SIGCHLD:
+			reapFuseDrivers()
 			if wpid, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil {
 				return status, fmt.Errorf("error while waiting child: %s", err)
 			} else if wpid != pid {
@@ -55,7 +69,8 @@ func (e *EngineOperations) MonitorContainer(pid int, signals chan os.Signal) (sy
 			// https://github.com/golang/go/issues/24543.
 			break
 		default:
-			if e.EngineConfig.GetSignalPropagation() {
+			//nolint:forcetypeassert
+			if e.propagateSignal(s.(syscall.Signal)) {
 				//nolint:forcetypeassert
 				if err := syscall.Kill(pid, s.(syscall.Signal)); err != nil {
 					return status, fmt.Errorf("interrupted by signal %s", s.String())
@@ -71,3 +86,18 @@ func (e *EngineOperations) MonitorContainer(pid int, signals chan os.Signal) (sy
 		}
 	}
 }
+
+// propagateSignal returns whether sig must be propagated to the container
+// process: all signals are when signal propagation is enabled, and signals
+// requested with --signal-proxy always are.
+func (e *EngineOperations) propagateSignal(sig syscall.Signal) bool {
+	if e.EngineConfig.GetSignalPropagation() {
+		return true
+	}
+	for _, s := range e.EngineConfig.GetSignalProxy() {
+		if s == int(sig) {
+			return true
+		}
+	}
+	return false
+}
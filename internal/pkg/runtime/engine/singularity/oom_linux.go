@@ -0,0 +1,58 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/instance"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// watchOOM reports OOM kills of container processes, and records them in the
+// instance file of an instance, until ctx is cancelled.
+func (e *EngineOperations) watchOOM(ctx context.Context) {
+	err := cgroupsManager.WatchOOM(ctx, func(count uint64) {
+		sylog.Warningf("Container process killed by the out-of-memory (OOM) killer, memory limit exceeded (%d OOM kills)", count)
+		if e.EngineConfig.GetInstance() {
+			e.recordOOMKill(count)
+		}
+	})
+	if err != nil {
+		sylog.Debugf("Not monitoring OOM events: %v", err)
+	}
+}
+
+// recordOOMKill adds an OOM kill event to the instance file.
+func (e *EngineOperations) recordOOMKill(count uint64) {
+	file, err := instance.Get(e.CommonConfig.ContainerID, instance.SingSubDir)
+	if err != nil {
+		sylog.Warningf("Could not record OOM kill in instance file: %v", err)
+		return
+	}
+	file.OOMKills = append(file.OOMKills, instance.OOMKill{Time: time.Now(), Count: count})
+	if err := file.Update(); err != nil {
+		sylog.Warningf("Could not record OOM kill in instance file: %v", err)
+	}
+}
+
+// reportOOMKilled reports when the container process, which terminated with
+// status, was killed by the OOM killer.
+func reportOOMKilled(status syscall.WaitStatus) {
+	if !status.Signaled() || status.Signal() != syscall.SIGKILL {
+		return
+	}
+	count, err := cgroupsManager.OOMKillCount()
+	if err != nil {
+		sylog.Debugf("Could not read OOM kill count: %v", err)
+		return
+	}
+	if count > 0 {
+		sylog.Errorf("Container process was killed by the out-of-memory (OOM) killer, memory limit exceeded")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	singularityConfig "github.com/sylabs/singularity/v4/pkg/runtime/engine/singularity/config"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
+	"golang.org/x/sys/unix"
+)
+
+// pathCacheFile is the PATH cache of images built with --prime-cache. Its
+// first line is the PATH of the container when the image was built, followed
+// by the executables found on it, in PATH order.
+const pathCacheFile = "/.singularity.d/path.cache"
+
+// pathCache resolves commands with the PATH cache of the image, so that the
+// directories of the PATH, on a possibly slow image, are not searched.
+type pathCache struct {
+	path     string
+	commands map[string]string
+}
+
+// loadPathCache returns the PATH cache of the image, or nil if the image has
+// none, or if the content of the PATH directories may differ from the image,
+// as they are changed by a writable image, an overlay, or a mount.
+func loadPathCache(engineConfig *singularityConfig.EngineConfig) *pathCache {
+	if engineConfig.GetWritableImage() || engineConfig.GetWritableTmpfs() || len(engineConfig.GetOverlayImage()) > 0 {
+		return nil
+	}
+
+	f, err := os.Open(pathCacheFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return nil
+	}
+	c := &pathCache{
+		path:     scanner.Text(),
+		commands: make(map[string]string),
+	}
+	for scanner.Scan() {
+		cmd := scanner.Text()
+		// the first executable found on the PATH wins
+		if _, ok := c.commands[filepath.Base(cmd)]; !ok {
+			c.commands[filepath.Base(cmd)] = cmd
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		sylog.Debugf("While reading %s: %s", pathCacheFile, err)
+		return nil
+	}
+
+	entries, err := proc.GetMountInfoEntry("/proc/self/mountinfo")
+	if err != nil {
+		sylog.Debugf("While reading mount points: %s", err)
+		return nil
+	}
+	for _, e := range entries {
+		if e.Point == "/" {
+			continue
+		}
+		for _, dir := range filepath.SplitList(c.path) {
+			if related(e.Point, dir) {
+				sylog.Debugf("Not using %s, %s is mounted on the PATH", pathCacheFile, e.Point)
+				return nil
+			}
+		}
+	}
+	return c
+}
+
+// lookPath returns the path of the command name found on path, if the cache
+// was generated for the same PATH and holds it.
+func (c *pathCache) lookPath(name, path string) (string, bool) {
+	if c == nil || path != c.path || strings.Contains(name, "/") {
+		return "", false
+	}
+	cmd, ok := c.commands[name]
+	if !ok || unix.Access(cmd, unix.X_OK) != nil {
+		return "", false
+	}
+	return cmd, true
+}
+
+// related returns whether a and b are the same directory, or one holds the
+// other.
+func related(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
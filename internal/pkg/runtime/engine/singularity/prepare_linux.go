@@ -25,6 +25,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/cgroups"
 	fakerootutil "github.com/sylabs/singularity/v4/internal/pkg/fakeroot"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/license"
 	"github.com/sylabs/singularity/v4/internal/pkg/instance"
 	"github.com/sylabs/singularity/v4/internal/pkg/plugin"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/config/starter"
@@ -45,6 +46,7 @@ import (
 	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
 	"github.com/sylabs/singularity/v4/pkg/util/namespaces"
 	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
 	"golang.org/x/sys/unix"
 )
 
@@ -466,8 +468,27 @@ func (e *EngineOperations) prepareAutofs(starterConfig *starter.Config) error {
 		}
 	}
 
+	requiredBinds, err := e.requiredBinds()
+	if err != nil {
+		return fmt.Errorf("while expanding 'required binds' of singularity.conf: %w", err)
+	}
+	for _, bindpath := range requiredBinds {
+		splitted := strings.Split(bindpath, ":")
+
+		fd, err := keepAutofsMount(splitted[0], autoFsPoints)
+		if err != nil {
+			sylog.Debugf("Could not keep file descriptor for required bind %s: %s", splitted[0], err)
+			continue
+		}
+		fds = append(fds, fd)
+	}
+
 	if !e.EngineConfig.GetContain() {
-		for _, bindpath := range e.EngineConfig.File.BindPath {
+		bindPaths, err := e.bindPaths()
+		if err != nil {
+			return fmt.Errorf("while expanding 'bind path' of singularity.conf: %w", err)
+		}
+		for _, bindpath := range bindPaths {
 			splitted := strings.Split(bindpath, ":")
 
 			fd, err := keepAutofsMount(splitted[0], autoFsPoints)
@@ -520,6 +541,10 @@ func (e *EngineOperations) prepareAutofs(starterConfig *starter.Config) error {
 // prepareContainerConfig is responsible for getting and applying
 // user supplied configuration for container creation.
 func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config) error {
+	if err := e.checkHomeMode(); err != nil {
+		return err
+	}
+
 	// always set mount namespace
 	e.EngineConfig.OciConfig.AddOrReplaceLinuxNamespace(specs.MountNamespace, "")
 
@@ -646,10 +671,45 @@ func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config)
 		}
 	}
 
+	// a container must not start without the required binds of singularity.conf
+	requiredBinds, err := e.requiredBinds()
+	if err != nil {
+		return fmt.Errorf("while expanding 'required binds' of singularity.conf: %w", err)
+	}
+	if err := singularityconf.CheckRequiredBinds(requiredBinds); err != nil {
+		return err
+	}
+
 	// open file descriptors (autofs bug path)
 	return e.prepareAutofs(starterConfig)
 }
 
+// checkHomeMode returns an error if the home mode requested is not allowed by
+// the 'allow home modes' directive of singularity.conf.
+func (e *EngineOperations) checkHomeMode() error {
+	mode := e.EngineConfig.GetHomeMode()
+	if mode == "" {
+		switch {
+		case e.EngineConfig.GetNoHome():
+			mode = "none"
+		case e.EngineConfig.GetContain() && !e.EngineConfig.GetCustomHome():
+			mode = "ephemeral"
+		default:
+			mode = "host"
+		}
+	}
+	switch mode {
+	case "host", "ephemeral", "image", "none":
+	default:
+		return fmt.Errorf("invalid home mode %q", mode)
+	}
+	allowed := e.EngineConfig.File.AllowHomeModes
+	if len(allowed) > 0 && !slice.ContainsString(allowed, mode) {
+		return fmt.Errorf("home mode %q is not allowed by configuration", mode)
+	}
+	return nil
+}
+
 // prepareInstanceJoinConfig is responsible for getting and
 // applying configuration to join a running instance.
 //
@@ -1125,9 +1185,11 @@ func (e *EngineOperations) setSessionLayer(img *image.Image) error {
 func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 	images := make([]image.Image, 0)
 
-	// load rootfs image
+	// load rootfs image, with --home-mode image its writable overlay
+	// partition holds the home directory overlay
 	writable := e.EngineConfig.GetWritableImage()
-	img, err := e.loadImage(e.EngineConfig.GetImage(), writable)
+	homeImage := e.EngineConfig.GetHomeMode() == "image"
+	img, err := e.loadImage(e.EngineConfig.GetImage(), writable || homeImage)
 	if err != nil {
 		return err
 	}
@@ -1140,6 +1202,15 @@ func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 	if writable && !img.Writable {
 		return fmt.Errorf("could not use %s for writing, you don't have write permissions", img.Path)
 	}
+	if homeImage && img.Type != image.SIF {
+		return fmt.Errorf("--home-mode image requires a SIF image")
+	} else if homeImage && !img.Writable {
+		return fmt.Errorf("--home-mode image requires write permission on %s", img.Path)
+	}
+
+	if err := e.checkLicense(img); err != nil {
+		return err
+	}
 
 	if err := e.setSessionLayer(img); err != nil {
 		return err
@@ -1223,6 +1294,7 @@ func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 		}
 
 		// look for potential overlay partition in SIF image
+		homeOverlay := false
 		if e.EngineConfig.GetSessionLayer() == singularityConfig.OverlayLayer {
 			overlays, err := img.GetOverlayPartitions()
 			if err != nil {
@@ -1230,15 +1302,23 @@ func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 			}
 			for _, p := range overlays {
 				if img.Writable && p.Type == image.EXT3 {
-					writableOverlayPath = img.Path
+					if homeImage {
+						homeOverlay = true
+					} else {
+						writableOverlayPath = img.Path
+					}
 				}
 			}
 		}
 
+		if homeImage && !homeOverlay {
+			return fmt.Errorf("--home-mode image requires a SIF writable overlay partition in %s", img.Path)
+		}
+
 		// SIF image open for writing without writable
 		// overlay partition, assuming that the root
 		// filesystem is squashfs or encrypted squashfs
-		if img.Writable && rootFs.Type != image.EXT3 && writableOverlayPath == "" {
+		if writable && rootFs.Type != image.EXT3 && writableOverlayPath == "" {
 			return fmt.Errorf("no SIF writable overlay partition found in %s", img.Path)
 		}
 	}
@@ -1269,6 +1349,25 @@ func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 }
 
 // loadOverlayImages loads overlay images.
+// checkLicense applies the 'image license groups' policy of singularity.conf
+// to the license of the rootfs image img, for the calling user. The policy is
+// applied here, where it can't be bypassed by the user in setuid
+// installations.
+func (e *EngineOperations) checkLicense(img *image.Image) error {
+	if len(e.EngineConfig.File.LicenseGroups) == 0 {
+		return nil
+	}
+	p, err := license.NewPolicy(e.EngineConfig.File.LicenseGroups, e.EngineConfig.File.LicenseOverrideGroups)
+	if err != nil {
+		return err
+	}
+	expr, err := license.FromImage(img.Path)
+	if err != nil {
+		return fmt.Errorf("while reading license of image %s: %s", img.Path, err)
+	}
+	return p.Check(expr, e.EngineConfig.GetImage(), os.Getuid(), e.EngineConfig.GetLicenseOverride())
+}
+
 func (e *EngineOperations) loadOverlayImages(starterConfig *starter.Config, writableOverlayPath string) ([]image.Image, error) {
 	images := make([]image.Image, 0)
 
@@ -32,6 +32,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/instance"
 	"github.com/sylabs/singularity/v4/internal/pkg/plugin"
 	"github.com/sylabs/singularity/v4/internal/pkg/security"
+	"github.com/sylabs/singularity/v4/internal/pkg/security/landlock"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/env"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/files"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/machine"
@@ -65,7 +66,7 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 	signals := make(chan os.Signal, 2)
 	signal.Notify(signals)
 
-	if err := e.runFuseDrivers(true, -1); err != nil {
+	if err := e.runFuseDrivers(true, os.Getpid(), -1); err != nil {
 		return err
 	}
 
@@ -156,6 +157,19 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 		return fmt.Errorf("failed to apply security configuration: %s", err)
 	}
 
+	// Landlock only restricts the current thread, from which the container
+	// process must be executed.
+	if e.landlockEnabled() {
+		runtime.LockOSThread()
+		rules, err := e.landlockRules()
+		if err != nil {
+			return fmt.Errorf("failed to apply landlock restrictions: %s", err)
+		}
+		if err := landlock.Restrict(rules); err != nil {
+			return fmt.Errorf("failed to apply landlock restrictions: %s", err)
+		}
+	}
+
 	// If necessary, set the umask that was saved from the calling environment
 	// https://github.com/hpcng/singularity/issues/5214
 	if e.EngineConfig.GetRestoreUmask() {
@@ -253,6 +267,9 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 						break
 					}
 
+					if fuseDriverExited(wpid, status) {
+						continue
+					}
 					if wpid == cmdPid {
 						e.stopFuseDrivers()
 						statusChan <- status
@@ -276,7 +293,7 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 						sylog.Debugf("No child process, exiting ...")
 						os.Exit(128 + int(signal))
 					}
-				} else if e.EngineConfig.GetSignalPropagation() && cmdPid > 0 {
+				} else if e.propagateSignal(signal) && cmdPid > 0 {
 					if err := syscall.Kill(cmdPid, signal); err == syscall.ESRCH {
 						sylog.Debugf("No child process, exiting ...")
 						os.Exit(128 + int(signal))
@@ -367,6 +384,7 @@ func (e *EngineOperations) PostStartProcess(_ context.Context, pid int) error {
 		file.Image = e.EngineConfig.GetImage()
 		file.LogErrPath = logErrPath
 		file.LogOutPath = logOutPath
+		file.Labels = e.EngineConfig.GetInstanceLabels()
 
 		ip, err := e.getIP()
 		if err != nil {
@@ -409,6 +427,10 @@ func (e *EngineOperations) PostStartProcess(_ context.Context, pid int) error {
 			file.Cgroup = true
 		}
 
+		if fuseSupervisor != nil {
+			file.FuseDrivers = fuseSupervisor.Status()
+		}
+
 		// grab configuration to store in instance file
 		file.Config, err = json.Marshal(e.CommonConfig)
 		if err != nil {
@@ -440,125 +462,6 @@ func (e *EngineOperations) setPathEnv() {
 	}
 }
 
-// runFuseDrivers execute FUSE drivers and returns the list of FUSE process ID.
-func (e *EngineOperations) runFuseDrivers(fromContainer bool, usernsFd int) error {
-	// set PATH for the command
-	oldpath := os.Getenv("PATH")
-	defer func() {
-		os.Setenv("PATH", oldpath)
-	}()
-
-	if fromContainer {
-		e.setPathEnv()
-	} else {
-		os.Setenv("PATH", env.DefaultPath)
-	}
-
-	for _, fd := range e.EngineConfig.GetUnixSocketPair() {
-		if fd >= 0 {
-			unix.Close(fd)
-		}
-	}
-
-	var usernsFh *os.File
-
-	if usernsFd >= 0 {
-		usernsFh = os.NewFile(uintptr(usernsFd), "/proc/self/ns/user")
-		if usernsFh == nil {
-			// this should never happen
-			return errors.New("cannot map /proc/self/ns/user file descriptor to a file handle")
-		}
-		defer usernsFh.Close()
-	}
-
-	fuseMounts := e.EngineConfig.GetFuseMount()
-	for i := range fuseMounts {
-		if fromContainer != fuseMounts[i].FromContainer {
-			syscall.Close(fuseMounts[i].Fd)
-			continue
-		}
-
-		mnt := fuseMounts[i].MountPoint
-		program := fuseMounts[i].Program
-		fd := fuseMounts[i].Fd
-
-		sylog.Debugf("Running FUSE driver for %s as %v, fd %d", mnt, program, fd)
-
-		fh := os.NewFile(uintptr(fd), "/dev/fuse")
-		if fh == nil {
-			// this should never happen
-			return errors.New("cannot map /dev/fuse file descriptor to a file handle")
-		}
-		// the master process does not need this file descriptor after
-		// running the program, make sure it gets closed; ignore any
-		// errors that happen here
-		defer fh.Close()
-
-		// as we pass file handle as first element in ExtraFiles
-		// the fuse file descriptor becomes 3 for the FUSE program
-		args := append(program, "/dev/fd/3")
-
-		// add -f to run FUSE in foreground mode
-		if !fuseMounts[i].Daemon {
-			args = append(args, "-f")
-		}
-
-		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
-
-		// Add the /dev/fuse file descriptor to the list of file
-		// descriptors to be passed to the new process.
-		// The Go library will set things up so that stdin, stdout
-		// and stderr are 0, 1, and 2, so the first element of
-		// ExtraFiles gets 3
-		cmd.ExtraFiles = make([]*os.File, 1)
-		cmd.ExtraFiles[0] = fh
-
-		// Add /proc/<container_pid>/ns/user file descriptor for nsenter
-		// so it could join the container user namespace by using /dev/fd/4
-		if usernsFh != nil {
-			cmd.ExtraFiles = append(cmd.ExtraFiles, usernsFh)
-		}
-
-		if fuseMounts[i].Daemon {
-			if err := cmd.Run(); err != nil {
-				cmdline := strings.Join(args, " ")
-				return fmt.Errorf("could not start program %s: %s", cmdline, err)
-			}
-		} else {
-			if err := cmd.Start(); err != nil {
-				cmdline := strings.Join(args, " ")
-				return fmt.Errorf("could not start program %s: %s", cmdline, err)
-			}
-			fuseMounts[i].Cmd = cmd
-		}
-	}
-
-	return nil
-}
-
-// stopFuseDrivers notifies FUSE drivers running in foreground mode
-// with a SIGTERM signal.
-func (e *EngineOperations) stopFuseDrivers() {
-	for _, fuseMount := range e.EngineConfig.GetFuseMount() {
-		if fuseMount.Cmd != nil {
-			cmd := fuseMount.Cmd
-			if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
-				sylog.Warningf("Can not send SIGTERM to FUSE process: %s", err)
-				continue
-			}
-			mnt := fuseMount.MountPoint
-			_, err := cmd.Process.Wait()
-			if err != nil {
-				sylog.Warningf("FUSE process for mount point %s terminated with error: %s", mnt, err)
-			} else {
-				sylog.Debugf("FUSE process for mount point %s terminated", mnt)
-			}
-		}
-	}
-}
-
 func (e *EngineOperations) getIP() (string, error) {
 	if networkSetup == nil {
 		return "", nil
@@ -630,12 +533,14 @@ func (b *bufferCloser) Close() error {
 // after /.singularity.d/env/99-base.sh or /environment.
 // This handler turns all SINGUALRITYENV_KEY=VAL defined variables into their form:
 // export KEY=VAL. It can be sourced only once otherwise it returns an empty content.
+// Variables set by metadata edits of the image, in menv, are exported first, with
+// no evaluation, so they override the image environment but not SINGULARITYENV_.
 // If noEval is true then exports are single quoted so their content is not evaluated
 // when the script is sourced (OCI compatible behavior).
 // If noEval is false then exports are double quoted, and their content is evaluated,
 // consuming one level of shell escaping and performing any unescaped var substitution,
 // subshell execution etc (Singularity historic behavior).
-func injectEnvHandler(senv map[string]string, noEval bool) interpreter.OpenHandler {
+func injectEnvHandler(senv, menv map[string]string, noEval bool) interpreter.OpenHandler {
 	var once sync.Once
 
 	return func(_ string, _ int, _ os.FileMode) (io.ReadWriteCloser, error) {
@@ -649,6 +554,10 @@ func injectEnvHandler(senv map[string]string, noEval bool) interpreter.OpenHandl
 			`
 			b.WriteString(fmt.Sprintf(defaultPathSnippet, env.DefaultPath))
 
+			for key, value := range menv {
+				b.WriteString(fmt.Sprintf("export %s='%s'\n", key, shell.EscapeSingleQuotes(value)))
+			}
+
 			snippet := `
 			if test -v %[1]s; then
 				sylog debug "Overriding %[1]s environment variable"
@@ -804,12 +713,19 @@ func runActionScript(engineConfig *singularityConfig.EngineConfig) ([]string, []
 		return nil, nil, err
 	}
 
+	pc := loadPathCache(engineConfig)
+
 	execBuiltin := func(ctx context.Context, argv []string) error {
-		cmd, err := shell.LookPath(ctx, argv[0])
-		if err != nil {
-			return err
+		hc := interp.HandlerCtx(ctx)
+		cmd, ok := pc.lookPath(argv[0], hc.Env.Get("PATH").String())
+		if !ok {
+			var err error
+			cmd, err = shell.LookPath(ctx, argv[0])
+			if err != nil {
+				return err
+			}
 		}
-		env = interpreter.GetEnv(interp.HandlerCtx(ctx))
+		env = interpreter.GetEnv(hc)
 		argv[0] = cmd
 		args = argv
 		return nil
@@ -817,7 +733,7 @@ func runActionScript(engineConfig *singularityConfig.EngineConfig) ([]string, []
 
 	// inject SINGULARITYENV_ defined variables
 	senv := engineConfig.GetSingularityEnv()
-	shell.RegisterOpenHandler("/.inject-singularity-env.sh", injectEnvHandler(senv, engineConfig.GetNoEval()))
+	shell.RegisterOpenHandler("/.inject-singularity-env.sh", injectEnvHandler(senv, engineConfig.GetMetadataEnv(), engineConfig.GetNoEval()))
 
 	shell.RegisterOpenHandler("/.singularity.d/env/99-runtimevars.sh", runtimeVarsHandler())
 
@@ -80,6 +80,13 @@ type SendFuseFdArgs struct {
 	Fds    []int
 }
 
+// MoveMountArgs defines the arguments to move a detached mount, received over
+// Socket, to Target.
+type MoveMountArgs struct {
+	Socket int
+	Target string
+}
+
 // SymlinkArgs defines the arguments to symlink.
 type SymlinkArgs struct {
 	Old string
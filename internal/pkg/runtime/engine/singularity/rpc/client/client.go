@@ -147,6 +147,25 @@ func (t *RPC) SendFuseFd(socket int, fds []int) error {
 	return err
 }
 
+// MoveMount calls the MoveMount RPC using the supplied arguments. The detached
+// mount must have been sent over socket before the call.
+func (t *RPC) MoveMount(socket int, target string) error {
+	arguments := &args.MoveMountArgs{
+		Socket: socket,
+		Target: target,
+	}
+
+	var mountErr error
+
+	err := t.Client.Call(t.Name+".MoveMount", arguments, &mountErr)
+	// RPC communication will take precedence over mount error
+	if err == nil {
+		err = mountErr
+	}
+
+	return err
+}
+
 // Symlink calls the mkdir RPC using the supplied arguments.
 func (t *RPC) Symlink(old string, new string) error {
 	arguments := &args.SymlinkArgs{
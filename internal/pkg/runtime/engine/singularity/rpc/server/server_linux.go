@@ -13,6 +13,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	args "github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/singularity/rpc"
@@ -29,7 +30,8 @@ import (
 )
 
 var (
-	diskGID          = -1
+	diskGID          = 0
+	diskGIDOnce      sync.Once
 	defaultEffective = uint64(0)
 )
 
@@ -42,33 +44,39 @@ func init() {
 // Methods is a receiver type.
 type Methods int
 
-// Mount performs a mount with the specified arguments.
+// Mount performs a mount with the specified arguments. Mounts are not
+// funneled through the main thread: the RPC server runs each request in
+// its own goroutine, so independent mounts requested concurrently by the
+// container setup are performed in parallel. All threads share the mount
+// namespace and inherit the effective capabilities set by the starter,
+// overlay mounts raise their additional capabilities on a locked thread.
 func (t *Methods) Mount(arguments *args.MountArgs, mountErr *error) (err error) {
-	mainthread.Execute(func() {
-		if arguments.Filesystem == "overlay" {
-			var oldEffective uint64
-
-			runtime.LockOSThread()
-			defer runtime.UnlockOSThread()
-
-			caps := uint64(0)
-			caps |= uint64(1 << capabilities.Map["CAP_FOWNER"].Value)
-			caps |= uint64(1 << capabilities.Map["CAP_DAC_OVERRIDE"].Value)
-			caps |= uint64(1 << capabilities.Map["CAP_DAC_READ_SEARCH"].Value)
-			caps |= uint64(1 << capabilities.Map["CAP_CHOWN"].Value)
-			caps |= uint64(1 << capabilities.Map["CAP_SYS_ADMIN"].Value)
-
-			oldEffective, err = capabilities.SetProcessEffective(caps)
-			if err != nil {
-				return
-			}
-			defer func() {
-				_, err = capabilities.SetProcessEffective(oldEffective)
-			}()
+	if arguments.Filesystem == "overlay" {
+		var oldEffective uint64
+
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		caps := uint64(0)
+		caps |= uint64(1 << capabilities.Map["CAP_FOWNER"].Value)
+		caps |= uint64(1 << capabilities.Map["CAP_DAC_OVERRIDE"].Value)
+		caps |= uint64(1 << capabilities.Map["CAP_DAC_READ_SEARCH"].Value)
+		caps |= uint64(1 << capabilities.Map["CAP_CHOWN"].Value)
+		caps |= uint64(1 << capabilities.Map["CAP_SYS_ADMIN"].Value)
+
+		oldEffective, err = capabilities.SetProcessEffective(caps)
+		if err != nil {
+			return err
 		}
-		*mountErr = syscall.Mount(arguments.Source, arguments.Target, arguments.Filesystem, arguments.Mountflags, arguments.Data)
-	})
-	return
+		defer func() {
+			_, e := capabilities.SetProcessEffective(oldEffective)
+			if err == nil {
+				err = e
+			}
+		}()
+	}
+	*mountErr = syscall.Mount(arguments.Source, arguments.Target, arguments.Filesystem, arguments.Mountflags, arguments.Data)
+	return err
 }
 
 // Decrypt decrypts the loop device.
@@ -264,13 +272,11 @@ func (t *Methods) LoopDevice(arguments *args.LoopArgs, reply *int) (err error) {
 		}
 	}
 
-	if diskGID == -1 {
+	diskGIDOnce.Do(func() {
 		if gr, err := user.GetGrNam("disk"); err == nil {
 			diskGID = int(gr.GID)
-		} else {
-			diskGID = 0
 		}
-	}
+	})
 
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
@@ -348,6 +354,30 @@ func (t *Methods) SendFuseFd(arguments *args.SendFuseFdArgs, _ *int) error {
 	return err
 }
 
+// MoveMount receives a detached mount file descriptor over unix socket, and
+// attaches it to the specified target.
+func (t *Methods) MoveMount(arguments *args.MoveMountArgs, mountErr *error) (err error) {
+	buf := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := unix.Recvmsg(arguments.Socket, make([]byte, 1), buf, 0)
+	if err != nil {
+		return fmt.Errorf("while receiving mount file descriptor: %s", err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(buf[:oobn])
+	if err != nil || len(msgs) != 1 {
+		return fmt.Errorf("while parsing socket control message: %v", err)
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		return fmt.Errorf("while getting mount file descriptor: %v", err)
+	}
+	defer unix.Close(fds[0])
+
+	mainthread.Execute(func() {
+		*mountErr = unix.MoveMount(fds[0], "", unix.AT_FDCWD, arguments.Target, unix.MOVE_MOUNT_F_EMPTY_PATH)
+	})
+	return nil
+}
+
 // Symlink performs a symlink with the specified arguments.
 func (t *Methods) Symlink(arguments *args.SymlinkArgs, _ *int) error {
 	return os.Symlink(arguments.Old, arguments.New)
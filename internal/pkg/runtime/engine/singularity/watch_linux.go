@@ -0,0 +1,204 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
+	"golang.org/x/sys/unix"
+)
+
+// hostFileWatchInterval is how often the host files bound into an instance
+// are checked for changes.
+const hostFileWatchInterval = 10 * time.Second
+
+// hostFile is a host file that is the source of a file in the container.
+type hostFile struct {
+	path string
+	// content returns the up to date content of the container copy, or is nil
+	// when the container copy can't be refreshed, only warned about.
+	content func() ([]byte, error)
+	stat    syscall.Stat_t
+}
+
+// changed stats the host file, and reports whether it was modified or replaced
+// since the last call.
+func (f *hostFile) changed() (bool, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(f.path, &st); err != nil {
+		return false, err
+	}
+	changed := st.Ino != f.stat.Ino || st.Dev != f.stat.Dev ||
+		st.Size != f.stat.Size || st.Mtim != f.stat.Mtim
+	f.stat = st
+	return changed, nil
+}
+
+// watchedHostFiles returns the host files bound into the container that can
+// be watched for changes, according to the engine configuration.
+func (e *EngineOperations) watchedHostFiles() []*hostFile {
+	var watched []*hostFile
+
+	skipBinds := e.EngineConfig.GetSkipBinds()
+	skipAllBinds := slice.ContainsString(skipBinds, "*")
+
+	netNS := false
+	if e.EngineConfig.OciConfig.Linux != nil {
+		for _, namespace := range e.EngineConfig.OciConfig.Linux.Namespaces {
+			if namespace.Type == specs.NetworkNamespace {
+				netNS = true
+			}
+		}
+	}
+
+	// A custom --dns resolv.conf doesn't depend on the host's file.
+	if e.EngineConfig.File.ConfigResolvConf && e.EngineConfig.GetDNS() == "" &&
+		!slice.ContainsString(skipBinds, "/etc/resolv.conf") {
+		watched = append(watched, &hostFile{
+			path:    "/etc/resolv.conf",
+			content: e.resolvConfContent,
+		})
+	}
+
+	// The hosts file is generated for --add-host, or bound from the host when
+	// contained without a network namespace, or by a singularity.conf bind path.
+	// A contained network namespace uses a default hosts file instead.
+	hostsBound := len(e.EngineConfig.GetAddHosts()) > 0 && !slice.ContainsString(skipBinds, "/etc/hosts")
+	if !skipAllBinds && !slice.ContainsString(skipBinds, "/etc/hosts") {
+		if e.EngineConfig.GetContain() {
+			hostsBound = hostsBound || !netNS
+		} else if bindPaths, err := e.bindPaths(); err == nil {
+			for _, bindpath := range bindPaths {
+				src, _, _ := strings.Cut(bindpath, ":")
+				hostsBound = hostsBound || src == "/etc/hosts"
+			}
+		}
+	}
+	if hostsBound && !(e.EngineConfig.GetContain() && netNS) {
+		watched = append(watched, &hostFile{
+			path: "/etc/hosts",
+			content: func() ([]byte, error) {
+				return e.hostsContent(netNS)
+			},
+		})
+	}
+
+	// The container's passwd file merges the image's file with the user's
+	// entry, and is only warned about.
+	if e.EngineConfig.File.ConfigPasswd && !slice.ContainsString(skipBinds, "/etc/passwd") {
+		watched = append(watched, &hostFile{
+			path: "/etc/passwd",
+		})
+	}
+
+	return watched
+}
+
+// watchHostFiles checks the host files bound into the instance container with
+// the given pid for changes, until ctx is canceled. Changes are logged as a
+// warning, and in refresh mode the container copy is rewritten when possible.
+func (e *EngineOperations) watchHostFiles(ctx context.Context, pid int) {
+	refresh := e.EngineConfig.GetWatchHostFiles() == "refresh"
+	watched := e.watchedHostFiles()
+	if len(watched) == 0 {
+		return
+	}
+
+	for _, f := range watched {
+		if _, err := f.changed(); err != nil {
+			sylog.Debugf("Could not stat %s: %s", f.path, err)
+		}
+	}
+
+	ticker := time.NewTicker(hostFileWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, f := range watched {
+			changed, err := f.changed()
+			if err != nil {
+				sylog.Debugf("Could not stat %s: %s", f.path, err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if !refresh || f.content == nil {
+				sylog.Warningf("Host %s has changed, restart the instance to use the new content", f.path)
+				continue
+			}
+			if err := refreshContainerFile(pid, f); err != nil {
+				sylog.Warningf("Host %s has changed, but could not refresh the container copy: %s", f.path, err)
+				continue
+			}
+			sylog.Infof("Refreshed container %s after a change on the host", f.path)
+		}
+	}
+}
+
+// refreshContainerFile rewrites the container copy of a host file in place,
+// through the root of the container process. The path is resolved within the
+// container root, without following symlinks, so that a container user can't
+// redirect the privileged write to another file.
+func refreshContainerFile(pid int, f *hostFile) error {
+	content, err := f.content()
+	if err != nil {
+		return err
+	}
+
+	root, err := unix.Open(fmt.Sprintf("/proc/%d/root", pid), unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(root)
+
+	fd, err := unix.Openat2(root, f.path, &unix.OpenHow{
+		Flags:   unix.O_RDWR | unix.O_NOFOLLOW | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_SYMLINKS | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if err != nil {
+		return fmt.Errorf("while opening container %s: %w", f.path, err)
+	}
+	file := os.NewFile(uintptr(fd), f.path)
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("container %s is not a regular file", f.path)
+	}
+	current, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	// The container may bind the host file itself, and already see the change.
+	if bytes.Equal(current, content) {
+		return nil
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(content, 0)
+	return err
+}
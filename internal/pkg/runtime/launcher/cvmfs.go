@@ -0,0 +1,78 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/samber/lo"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/bind"
+)
+
+// CVMFSDir is the directory holding CVMFS repositories, on the host and in
+// the container.
+var CVMFSDir = "/cvmfs"
+
+// CVMFSEnv is the environment variable listing the CVMFS repositories bound
+// into the container.
+const CVMFSEnv = "CVMFS_REPOSITORIES"
+
+var validCVMFSRepo = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// CVMFSBinds returns read-only binds of the CVMFS repositories requested with
+// --cvmfs, followed by the site default repositories of singularity.conf, and
+// the names of the repositories bound. Each repository is accessed, so that it
+// is mounted if CVMFS is managed by autofs. A requested repository that is not
+// available is an error, while a site default one is skipped with a warning.
+func CVMFSBinds(repos, siteRepos []string) ([]bind.Path, []string, error) {
+	var binds []bind.Path
+	var names []string
+
+	requested := lo.Uniq(repos)
+	for _, repo := range lo.Uniq(append(requested, siteRepos...)) {
+		if !validCVMFSRepo.MatchString(repo) {
+			return nil, nil, fmt.Errorf("invalid CVMFS repository name %q", repo)
+		}
+		dir := filepath.Join(CVMFSDir, repo)
+		if err := checkCVMFSRepo(dir); err != nil {
+			if lo.Contains(requested, repo) {
+				return nil, nil, fmt.Errorf("CVMFS repository %s is not available: %w", repo, err)
+			}
+			sylog.Warningf("Skipping site default CVMFS repository %s, which is not available: %v", repo, err)
+			continue
+		}
+		sylog.Debugf("Binding CVMFS repository %s", dir)
+		binds = append(binds, bind.Path{
+			Source:      dir,
+			Destination: dir,
+			Options:     map[string]*bind.Option{"ro": {}},
+		})
+		names = append(names, repo)
+	}
+	return binds, names, nil
+}
+
+// checkCVMFSRepo checks that the CVMFS repository at dir is available. Reading
+// the content of dir triggers an autofs mount.
+func checkCVMFSRepo(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Readdirnames(1); errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s is empty", dir)
+	} else if err != nil {
+		return fmt.Errorf("while reading %s: %w", dir, err)
+	}
+	return nil
+}
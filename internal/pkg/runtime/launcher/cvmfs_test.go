@@ -0,0 +1,85 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/pkg/util/bind"
+	"gotest.tools/v3/assert"
+)
+
+func TestCVMFSBinds(t *testing.T) {
+	defer func(dir string) { CVMFSDir = dir }(CVMFSDir)
+	CVMFSDir = t.TempDir()
+
+	for _, repo := range []string{"atlas.cern.ch", "sft.cern.ch"} {
+		assert.NilError(t, os.MkdirAll(filepath.Join(CVMFSDir, repo, ".cvmfs"), 0o755))
+	}
+	// An empty repository directory is an autofs mount point that failed.
+	assert.NilError(t, os.Mkdir(filepath.Join(CVMFSDir, "empty.cern.ch"), 0o755))
+
+	roBind := func(repo string) bind.Path {
+		dir := filepath.Join(CVMFSDir, repo)
+		return bind.Path{Source: dir, Destination: dir, Options: map[string]*bind.Option{"ro": {}}}
+	}
+
+	tests := []struct {
+		name      string
+		repos     []string
+		siteRepos []string
+		wantBinds []bind.Path
+		wantNames []string
+		wantErr   string
+	}{
+		{
+			name: "None",
+		},
+		{
+			name:      "Requested",
+			repos:     []string{"atlas.cern.ch", "atlas.cern.ch"},
+			wantBinds: []bind.Path{roBind("atlas.cern.ch")},
+			wantNames: []string{"atlas.cern.ch"},
+		},
+		{
+			name:      "SiteDefault",
+			repos:     []string{"atlas.cern.ch"},
+			siteRepos: []string{"sft.cern.ch", "atlas.cern.ch", "missing.cern.ch", "empty.cern.ch"},
+			wantBinds: []bind.Path{roBind("atlas.cern.ch"), roBind("sft.cern.ch")},
+			wantNames: []string{"atlas.cern.ch", "sft.cern.ch"},
+		},
+		{
+			name:    "RequestedMissing",
+			repos:   []string{"missing.cern.ch"},
+			wantErr: "CVMFS repository missing.cern.ch is not available",
+		},
+		{
+			name:    "RequestedEmpty",
+			repos:   []string{"empty.cern.ch"},
+			wantErr: "CVMFS repository empty.cern.ch is not available",
+		},
+		{
+			name:    "InvalidName",
+			repos:   []string{"../etc"},
+			wantErr: `invalid CVMFS repository name "../etc"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binds, names, err := CVMFSBinds(tt.repos, tt.siteRepos)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, binds, tt.wantBinds)
+			assert.DeepEqual(t, names, tt.wantNames)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"fmt"
+
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
+)
+
+// Home modes, selecting how the home directory is provided in the container.
+const (
+	// HomeModeHost binds the home directory from the host.
+	HomeModeHost = "host"
+	// HomeModeEphemeral provides an empty home directory, on a tmpfs,
+	// discarded when the container exits.
+	HomeModeEphemeral = "ephemeral"
+	// HomeModeImage provides the home directory of the image, through an
+	// overlay whose changes are persisted in the writable overlay partition
+	// embedded in the SIF image.
+	HomeModeImage = "image"
+	// HomeModeNone provides no home directory.
+	HomeModeNone = "none"
+)
+
+var homeModes = []string{HomeModeHost, HomeModeEphemeral, HomeModeImage, HomeModeNone}
+
+// ResolveHomeMode returns mode, or else the 'home mode' default of conf, or
+// else defaultMode, the launcher default for the other options in use, after
+// checking that it is a valid mode.
+func ResolveHomeMode(mode, defaultMode string, conf *singularityconf.File) (string, error) {
+	if mode == "" {
+		mode = conf.HomeMode
+	}
+	if mode == "" {
+		mode = defaultMode
+	}
+	if !slice.ContainsString(homeModes, mode) {
+		return "", fmt.Errorf("invalid home mode %q, must be one of %v", mode, homeModes)
+	}
+	return mode, nil
+}
+
+// CheckHomeMode returns an error if mode is not allowed by the 'allow home
+// modes' policy of conf. The native engine enforces the policy itself, so it
+// is only checked by launchers that don't use it.
+func CheckHomeMode(mode string, conf *singularityconf.File) error {
+	if len(conf.AllowHomeModes) > 0 && !slice.ContainsString(conf.AllowHomeModes, mode) {
+		return fmt.Errorf("home mode %q is not allowed by configuration", mode)
+	}
+	return nil
+}
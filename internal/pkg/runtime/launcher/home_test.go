@@ -0,0 +1,100 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"testing"
+
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+func TestResolveHomeMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		defaultMode string
+		conf        singularityconf.File
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "Default",
+			defaultMode: HomeModeHost,
+			want:        HomeModeHost,
+		},
+		{
+			name:        "Requested",
+			mode:        HomeModeEphemeral,
+			defaultMode: HomeModeHost,
+			want:        HomeModeEphemeral,
+		},
+		{
+			name:        "ConfDefault",
+			defaultMode: HomeModeHost,
+			conf:        singularityconf.File{HomeMode: HomeModeNone},
+			want:        HomeModeNone,
+		},
+		{
+			name:        "RequestedOverConf",
+			mode:        HomeModeEphemeral,
+			defaultMode: HomeModeHost,
+			conf:        singularityconf.File{HomeMode: HomeModeNone},
+			want:        HomeModeEphemeral,
+		},
+		{
+			name:        "Invalid",
+			mode:        "persistent",
+			defaultMode: HomeModeHost,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveHomeMode(tt.mode, tt.defaultMode, &tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveHomeMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveHomeMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHomeMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		conf    singularityconf.File
+		wantErr bool
+	}{
+		{
+			name: "NoPolicy",
+			mode: HomeModeHost,
+		},
+		{
+			name: "Allowed",
+			mode: HomeModeNone,
+			conf: singularityconf.File{AllowHomeModes: []string{HomeModeEphemeral, HomeModeNone}},
+		},
+		{
+			name:    "NotAllowed",
+			mode:    HomeModeHost,
+			conf:    singularityconf.File{AllowHomeModes: []string{HomeModeEphemeral, HomeModeNone}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckHomeMode(tt.mode, &tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckHomeMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
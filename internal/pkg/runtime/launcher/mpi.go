@@ -0,0 +1,126 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/mpi"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/bind"
+)
+
+const (
+	// MPIModePMIx connects MPI applications to a PMIx server on the host,
+	// e.g. that of srun --mpi=pmix, or a PMIx enabled mpirun.
+	MPIModePMIx = "pmix"
+	// MPIModePMI2 connects MPI applications to a PMI-2 server on the host,
+	// e.g. that of srun --mpi=pmi2.
+	MPIModePMI2 = "pmi2"
+)
+
+var mpiModes = []string{MPIModePMIx, MPIModePMI2}
+
+// mpiEnvPrefixes lists the prefixes of the host environment variables, set
+// by the process manager, that are passed into the container in each mode.
+var mpiEnvPrefixes = map[string][]string{
+	MPIModePMIx: {"PMIX_"},
+	MPIModePMI2: {"PMI_"},
+}
+
+// mpiTmpDirEnv lists the PMIx environment variables that give directories
+// holding the rendezvous files and sockets of the PMIx server.
+var mpiTmpDirEnv = []string{"PMIX_SERVER_TMPDIR", "PMIX_SYSTEM_TMPDIR"}
+
+// MPI holds the host libraries, binaries, binds, and environment variables
+// needed in the container by MPI applications, with --mpi.
+type MPI struct {
+	// Mode is the process management interface in use, pmix or pmi2.
+	Mode string
+	// Libs are the host PMI libraries, bound into .singularity.d/libs.
+	Libs []string
+	// Bins are the host PMI binaries, bound into /usr/bin.
+	Bins []string
+	// Binds are the directories holding the sockets of the PMIx server.
+	Binds []bind.Path
+	// Env holds the PMI environment variables set by the process manager.
+	Env map[string]string
+}
+
+// MPISetup returns the MPI configuration for mode, from the environment of the
+// host process manager, and the libraries listed in <mode>liblist.conf. An
+// error is returned if singularity was not launched by a process manager
+// providing mode, or if no PMI library for mode is found on the host.
+func MPISetup(mode string) (*MPI, error) {
+	m := &MPI{
+		Mode: mode,
+		Env:  map[string]string{},
+	}
+
+	for _, e := range os.Environ() {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok {
+			continue
+		}
+		for _, p := range mpiEnvPrefixes[mode] {
+			if strings.HasPrefix(k, p) {
+				m.Env[k] = v
+				break
+			}
+		}
+	}
+
+	switch mode {
+	case MPIModePMIx:
+		// The server URI is given by PMIX_SERVER_URI, or a versioned variant
+		// e.g. PMIX_SERVER_URI41, depending on the PMIx version of the server.
+		hasServer := false
+		for k := range m.Env {
+			if strings.HasPrefix(k, "PMIX_SERVER_URI") {
+				hasServer = true
+				break
+			}
+		}
+		if !hasServer {
+			return nil, fmt.Errorf("--mpi pmix requires a PMIx server, as provided by srun --mpi=pmix or a PMIx enabled mpirun")
+		}
+		for _, k := range mpiTmpDirEnv {
+			dir := m.Env[k]
+			if dir == "" {
+				continue
+			}
+			if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+				sylog.Warningf("Not binding PMIx server directory %s=%s, which is not available", k, dir)
+				continue
+			}
+			sylog.Debugf("Binding PMIx server directory %s", dir)
+			m.Binds = append(m.Binds, bind.Path{Source: dir, Destination: dir})
+		}
+	case MPIModePMI2:
+		if m.Env["PMI_FD"] == "" && m.Env["PMI_PORT"] == "" {
+			return nil, fmt.Errorf("--mpi pmi2 requires a PMI-2 server, as provided by srun --mpi=pmi2")
+		}
+	default:
+		return nil, fmt.Errorf("invalid --mpi mode %q, must be one of %s", mode, strings.Join(mpiModes, ", "))
+	}
+
+	confFile := filepath.Join(buildcfg.SINGULARITY_CONFDIR, mode+"liblist.conf")
+	libs, bins, err := mpi.Paths(confFile)
+	if err != nil {
+		return nil, fmt.Errorf("while finding %s bind points: %w", mode, err)
+	}
+	if len(libs) == 0 {
+		return nil, fmt.Errorf("could not find any %s libraries on this host, as listed in %s", mode, filepath.Base(confFile))
+	}
+	m.Libs = libs
+	m.Bins = bins
+
+	return m, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMPISetupNoServer(t *testing.T) {
+	for _, e := range os.Environ() {
+		if k, _, _ := strings.Cut(e, "="); strings.HasPrefix(k, "PMI") {
+			t.Setenv(k, "")
+			os.Unsetenv(k)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		wantErr string
+	}{
+		{
+			name:    "PMIx",
+			mode:    MPIModePMIx,
+			wantErr: "requires a PMIx server",
+		},
+		{
+			name:    "PMI2",
+			mode:    MPIModePMI2,
+			wantErr: "requires a PMI-2 server",
+		},
+		{
+			name:    "Invalid",
+			mode:    "pmi1",
+			wantErr: "invalid --mpi mode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MPISetup(tt.mode)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("MPISetup() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOptMPI(t *testing.T) {
+	for _, mode := range []string{"", MPIModePMIx, MPIModePMI2} {
+		lo := Options{}
+		if err := OptMPI(mode)(&lo); err != nil || lo.MPI != mode {
+			t.Errorf("OptMPI(%q) = %v, MPI %q", mode, err, lo.MPI)
+		}
+	}
+	if err := OptMPI("openmpi")(&Options{}); err == nil {
+		t.Errorf("OptMPI(%q) succeeded, want error", "openmpi")
+	}
+}
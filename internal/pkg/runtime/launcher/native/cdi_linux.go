@@ -0,0 +1,126 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package native
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/bind"
+	"tags.cncf.io/container-device-interface/pkg/cdi"
+	"tags.cncf.io/container-device-interface/pkg/parser"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
+)
+
+// getCDIEdits returns the container edits for the requested CDI devices,
+// read from the CDI specs in cdiDirs, or the default /etc/cdi and
+// /var/run/cdi directories. The edits of a spec that apply to all its
+// devices are included once.
+func getCDIEdits(devices, cdiDirs []string) ([]cdispecs.ContainerEdits, error) {
+	for _, d := range devices {
+		if !parser.IsQualifiedName(d) {
+			return nil, fmt.Errorf("string %#v does not represent a valid CDI device", d)
+		}
+	}
+
+	opts := []cdi.Option{cdi.WithAutoRefresh(false)}
+	if len(cdiDirs) > 0 {
+		opts = append(opts, cdi.WithSpecDirs(cdiDirs...))
+	}
+	reg := cdi.GetRegistry(opts...)
+	if err := reg.Refresh(); err != nil {
+		return nil, fmt.Errorf("while refreshing the CDI registry: %w", err)
+	}
+
+	edits := []cdispecs.ContainerEdits{}
+	seen := map[*cdi.Spec]bool{}
+	for _, d := range devices {
+		dev := reg.DeviceDB().GetDevice(d)
+		if dev == nil {
+			return nil, fmt.Errorf("unresolvable CDI device %s", d)
+		}
+		if spec := dev.GetSpec(); !seen[spec] {
+			seen[spec] = true
+			edits = append(edits, spec.ContainerEdits)
+		}
+		edits = append(edits, dev.ContainerEdits)
+	}
+	return edits, nil
+}
+
+// setCDIDevices sets engine configuration for the requested CDI devices.
+// The native runtime applies CDI environment variables, bind mounts, and
+// device nodes under /dev, which are bound from the host. CDI hooks are not
+// supported.
+func (l *Launcher) setCDIDevices() error {
+	if len(l.cfg.Devices) == 0 {
+		return nil
+	}
+
+	edits, err := getCDIEdits(l.cfg.Devices, l.cfg.CdiDirs)
+	if err != nil {
+		return err
+	}
+
+	if l.cfg.Env == nil {
+		l.cfg.Env = map[string]string{}
+	}
+	binds := l.engineConfig.GetBindPath()
+
+	for _, e := range edits {
+		for _, kv := range e.Env {
+			k, v, _ := strings.Cut(kv, "=")
+			// --env variables take precedence over CDI variables.
+			if _, ok := l.cfg.Env[k]; ok {
+				sylog.Warningf("Ignored CDI environment variable %s: override from --env", k)
+				continue
+			}
+			l.cfg.Env[k] = v
+		}
+
+		for _, m := range e.Mounts {
+			if m.Type != "" && m.Type != "bind" {
+				sylog.Warningf("Skipping CDI %s mount of %s: only bind mounts are supported in native mode", m.Type, m.ContainerPath)
+				continue
+			}
+			b := bind.Path{
+				Source:      m.HostPath,
+				Destination: m.ContainerPath,
+				Options:     map[string]*bind.Option{},
+			}
+			for _, o := range m.Options {
+				if o == "ro" {
+					b.Options["ro"] = &bind.Option{}
+				}
+			}
+			binds = append(binds, b)
+		}
+
+		// Device nodes are bound at the same path from the host /dev. When
+		// the host /dev is mounted in the container, they are already
+		// available.
+		for _, d := range e.DeviceNodes {
+			hostPath := d.HostPath
+			if hostPath == "" {
+				hostPath = d.Path
+			}
+			if hostPath != d.Path || !strings.HasPrefix(filepath.Clean(d.Path), "/dev/") {
+				sylog.Warningf("Skipping CDI device node %s: only host device nodes under /dev at the same path are supported in native mode", d.Path)
+				continue
+			}
+			binds = append(binds, bind.Path{Source: d.Path, Destination: d.Path})
+		}
+
+		if len(e.Hooks) > 0 {
+			sylog.Warningf("Ignoring CDI hooks: not supported in native mode")
+		}
+	}
+
+	l.engineConfig.SetBindPath(binds)
+	return nil
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/sylabs/sif/v2/pkg/sif"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/cgroups"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/metadata"
 	"github.com/sylabs/singularity/v4/internal/pkg/image/unpacker"
 	"github.com/sylabs/singularity/v4/internal/pkg/instance"
 	"github.com/sylabs/singularity/v4/internal/pkg/plugin"
@@ -31,15 +32,21 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
 	"github.com/sylabs/singularity/v4/internal/pkg/security"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/coredump"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/env"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/overlay"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/squashfs"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/mpi"
+	signalutil "github.com/sylabs/singularity/v4/internal/pkg/util/signal"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/starter"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/tracing"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/user"
 	"github.com/sylabs/singularity/v4/pkg/image"
 	imgutil "github.com/sylabs/singularity/v4/pkg/image"
 	clicallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/cli"
+	launchercallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/runtime/launcher"
 	"github.com/sylabs/singularity/v4/pkg/runtime/engine/config"
 	singularityConfig "github.com/sylabs/singularity/v4/pkg/runtime/engine/singularity/config"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
@@ -49,6 +56,7 @@ import (
 	"github.com/sylabs/singularity/v4/pkg/util/namespaces"
 	"github.com/sylabs/singularity/v4/pkg/util/rlimit"
 	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sys/unix"
 )
 
@@ -70,16 +78,38 @@ func NewLauncher(opts ...launcher.Option) (*Launcher, error) {
 			return nil, fmt.Errorf("%w", err)
 		}
 	}
-	if len(lo.Devices) > 0 {
-		return nil, fmt.Errorf("CDI device mappings unsupported in native launcher")
+	if lo.NoCompat {
+		sylog.Warningf("--no-compat applies to --oci mode only, ignoring")
 	}
 
-	if len(lo.CdiDirs) > 0 {
-		return nil, fmt.Errorf("CDI device mappings unsupported in native launcher")
+	if lo.VolumePolicy != "" {
+		sylog.Warningf("--volume-policy applies to --oci mode only, ignoring")
 	}
 
-	if lo.NoCompat {
-		sylog.Warningf("--no-compat applies to --oci mode only, ignoring")
+	if lo.OverlayPassfile != "" {
+		sylog.Warningf("--overlay-passfile applies to --oci mode only, ignoring")
+	}
+
+	if lo.SeccompTrace != "" {
+		return nil, fmt.Errorf("--seccomp-trace is only supported in --oci mode")
+	}
+	if lo.Lazy {
+		return nil, fmt.Errorf("--lazy is only supported in --oci mode")
+	}
+	if lo.ProfileIO != "" {
+		return nil, fmt.Errorf("--profile-io is only supported in --oci mode")
+	}
+	if len(lo.DataContainers) > 0 {
+		return nil, fmt.Errorf("--data is only supported in --oci mode")
+	}
+	if lo.OCIUser != "" {
+		return nil, fmt.Errorf("--oci-user is only supported in --oci mode")
+	}
+	for _, p := range lo.OverlayPaths {
+		dir, _, _ := strings.Cut(p, ":")
+		if overlay.IsEncryptedDir(dir) {
+			return nil, fmt.Errorf("encrypted overlay directory %s is only supported in --oci mode", dir)
+		}
 	}
 
 	// Initialize empty default Singularity Engine and OCI configuration
@@ -125,6 +155,15 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		l.generator.AddProcessEnv("SINGULARITY_NO_EVAL", "1")
 	}
 
+	// --no-env ignores the SINGULARITYENV_ variables of the host.
+	if l.cfg.NoEnv {
+		for _, e := range os.Environ() {
+			if k, _, _ := strings.Cut(e, "="); strings.HasPrefix(k, env.SingularityEnvPrefix) {
+				os.Unsetenv(k)
+			}
+		}
+	}
+
 	// Set container Umask w.r.t. our own, before any umask manipulation happens.
 	l.setUmask()
 
@@ -132,7 +171,7 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	// If root user requests a target uid, gid via --security options, handle them now.
 	l.uid, l.gid, err = l.setTargetIDs()
 	if err != nil {
-		sylog.Fatalf("Could not configure target UID/GID: %s", err)
+		return fmt.Errorf("could not configure target UID/GID: %w", err)
 	}
 
 	// Set image to run, or instance to join, and SINGULARITY_CONTAINER/SINGULARITY_NAME env vars.
@@ -140,6 +179,11 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		sylog.Errorf("While setting image/instance: %s", err)
 	}
 
+	// The home mode may disable the home mount.
+	if err := l.setHomeMode(); err != nil {
+		return fmt.Errorf("while setting home mode: %w", err)
+	}
+
 	// Overlay or writable image requested?
 	l.engineConfig.SetOverlayImage(l.cfg.OverlayPaths)
 	l.engineConfig.SetWritableImage(l.cfg.Writable)
@@ -149,12 +193,15 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	if !l.engineConfig.GetInstanceJoin() {
 		err = l.checkImage()
 		if err != nil {
-			sylog.Fatalf("While checking image: %s", err)
+			return fmt.Errorf("while checking image: %w", err)
 		}
 	}
 
 	// Will we use the suid starter? If not we need to force the user namespace.
-	useSuid, forceUserNs := l.useSuid()
+	useSuid, forceUserNs, err := l.useSuid()
+	if err != nil {
+		return err
+	}
 	if forceUserNs {
 		l.cfg.Namespaces.User = true
 	}
@@ -171,15 +218,15 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 
 	// Handle requested binds, fuse mounts.
 	if err := l.setBinds(); err != nil {
-		sylog.Fatalf("While setting bind mount configuration: %s", err)
+		return fmt.Errorf("while setting bind mount configuration: %w", err)
 	}
 	if err := l.setFuseMounts(); err != nil {
-		sylog.Fatalf("While setting FUSE mount configuration: %s", err)
+		return fmt.Errorf("while setting FUSE mount configuration: %w", err)
 	}
 
 	// Set the home directory that should be effective in the container.
 	if err := l.setHome(); err != nil {
-		sylog.Fatalf("While setting home directory: %s", err)
+		return fmt.Errorf("while setting home directory: %w", err)
 	}
 	// Allow user to disable the home mount via --no-home.
 	l.engineConfig.SetNoHome(l.cfg.NoHome)
@@ -189,9 +236,31 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	// GPU configuration may add library bind to /.singularity.d/libs.
 	// Note: --nvccli may implicitly add --writable-tmpfs, so handle that *after* GPUs.
 	if err := l.SetGPUConfig(); err != nil {
-		// We must fatal on error, as we are checking for correct ownership of nvidia-container-cli,
+		// We must fail on error, as we are checking for correct ownership of nvidia-container-cli,
 		// which is important to maintain security.
-		sylog.Fatalf("While setting GPU configuration: %s", err)
+		return fmt.Errorf("while setting GPU configuration: %w", err)
+	}
+
+	// RDMA configuration may add library binds alongside those for GPUs.
+	if l.cfg.Rdma {
+		l.setRdmaConfig()
+	}
+
+	// MPI configuration may add library binds alongside those for GPUs.
+	if l.cfg.MPI != "" {
+		if err := l.setMPIConfig(); err != nil {
+			return fmt.Errorf("while setting MPI configuration: %w", err)
+		}
+	}
+
+	// CDI devices may add binds, and environment variables.
+	if err := l.setCDIDevices(); err != nil {
+		return fmt.Errorf("while setting CDI devices: %w", err)
+	}
+
+	// The native rootfs is read-only by default, so --read-only only conflicts with options making it writable.
+	if l.cfg.ReadOnly && l.cfg.Writable {
+		return fmt.Errorf("--read-only and --writable cannot be used together")
 	}
 
 	// --writable-tmpfs is for an ephemeral overlay, doesn't make sense if also asking to write to image itself.
@@ -202,6 +271,20 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		l.engineConfig.SetWritableTmpfs(l.cfg.WritableTmpfs)
 	}
 
+	// tmpfs sizes not set on the command line default to those in singularity.conf.
+	if l.cfg.WritableTmpfsSize == 0 {
+		l.cfg.WritableTmpfsSize = l.engineConfig.File.WritableTmpfsSize
+	}
+	if l.cfg.TmpSize == 0 {
+		l.cfg.TmpSize = l.engineConfig.File.TmpSize
+	}
+	if l.cfg.ShmSize == 0 {
+		l.cfg.ShmSize = l.engineConfig.File.ShmSize
+	}
+	l.engineConfig.SetWritableTmpfsSize(l.cfg.WritableTmpfsSize)
+	l.engineConfig.SetTmpSize(l.cfg.TmpSize)
+	l.engineConfig.SetShmSize(l.cfg.ShmSize)
+
 	// If proot is requested (we are running an unprivileged build, without userns) we must bind it
 	// into the container /.singularity.d/libs.
 	if l.cfg.Proot != "" && l.uid != 0 {
@@ -219,7 +302,10 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	// Container networking configuration.
 	l.engineConfig.SetNetwork(l.cfg.Network)
 	l.engineConfig.SetDNS(l.cfg.DNS)
+	l.engineConfig.SetDNSSearch(l.cfg.DNSSearch)
+	l.engineConfig.SetAddHosts(l.cfg.AddHosts)
 	l.engineConfig.SetNetworkArgs(l.cfg.NetworkArgs)
+	l.engineConfig.SetNetworkLimits(l.cfg.NetworkLimits)
 
 	// If user wants to set a hostname, it requires the UTS namespace.
 	if l.cfg.Hostname != "" {
@@ -244,7 +330,7 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		return nil
 	})
 	if err != nil {
-		sylog.Fatalf("Could not configure --allow-setuid: %s", err)
+		return fmt.Errorf("could not configure --allow-setuid: %w", err)
 	}
 
 	// When running as root, the user can optionally keep all privs in the container.
@@ -253,15 +339,66 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		return nil
 	})
 	if err != nil {
-		sylog.Fatalf("Could not configure --keep-privs: %s", err)
+		return fmt.Errorf("could not configure --keep-privs: %w", err)
 	}
 
 	// User can optionally force dropping all privs from root in the container.
 	l.engineConfig.SetNoPrivs(l.cfg.NoPrivs)
 
 	// Set engine --security options (selinux, apparmor, seccomp functionality).
+	// A --seccomp-profile is applied as the equivalent --security seccomp:<path>.
+	if l.cfg.SeccompProfile != "" {
+		for _, opt := range l.cfg.SecurityOpts {
+			if strings.HasPrefix(opt, "seccomp:") {
+				return fmt.Errorf("--seccomp-profile cannot be used with --security seccomp:<path>")
+			}
+		}
+		l.cfg.SecurityOpts = append(l.cfg.SecurityOpts, "seccomp:"+l.cfg.SeccompProfile)
+	}
+	// Likewise, --apparmor-profile and --security-opt label:<label> are
+	// applied as --security apparmor:<profile> and selinux:<label>.
+	for _, o := range []struct{ feature, flag, value string }{
+		{"apparmor", "--apparmor-profile", l.cfg.ApparmorProfile},
+		{"selinux", "--security-opt label", l.cfg.SelinuxLabel},
+	} {
+		if o.value == "" {
+			continue
+		}
+		if security.GetParam(l.cfg.SecurityOpts, o.feature) != "" {
+			return fmt.Errorf("%s cannot be used with --security %s:<value>", o.flag, o.feature)
+		}
+		l.cfg.SecurityOpts = append(l.cfg.SecurityOpts, o.feature+":"+o.value)
+	}
 	l.engineConfig.SetSecurity(l.cfg.SecurityOpts)
 
+	// Collect core dumps of crashed container processes into --core-dir. The
+	// container processes inherit the raised core file size limit.
+	if l.cfg.CoreDir != "" {
+		dir, err := coredump.CheckDir(l.cfg.CoreDir)
+		if err != nil {
+			return err
+		}
+		if err := coredump.Enable(); err != nil {
+			return err
+		}
+		l.engineConfig.SetCoreDir(dir)
+	}
+
+	// Signals requested with --signal-proxy are relayed to the container
+	// process even in the foreground of a terminal, where signals are
+	// otherwise delivered to it by the terminal only.
+	if len(l.cfg.SignalProxy) > 0 {
+		signals, err := signalutil.ParseProxyList(l.cfg.SignalProxy)
+		if err != nil {
+			return fmt.Errorf("while parsing --signal-proxy: %w", err)
+		}
+		proxy := make([]int, 0, len(signals))
+		for _, s := range signals {
+			proxy = append(proxy, int(s))
+		}
+		l.engineConfig.SetSignalProxy(proxy)
+	}
+
 	// User can override shell used when entering container.
 	l.engineConfig.SetShell(l.cfg.ShellPath)
 	if l.cfg.ShellPath != "" {
@@ -282,12 +419,14 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		}
 	}
 
-	l.setCgroups(ep.Instance)
+	if err := l.setCgroups(ep.Instance); err != nil {
+		return err
+	}
 
 	// --boot flag requires privilege, so check for this.
 	err = launcher.WithPrivilege(l.cfg.Boot, "--boot", func() error { return nil })
 	if err != nil {
-		sylog.Fatalf("Could not configure --boot: %s", err)
+		return fmt.Errorf("could not configure --boot: %w", err)
 	}
 
 	// --containall or --boot infer --contain.
@@ -313,6 +452,20 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		l.engineConfig.SetInstance(true)
 		l.engineConfig.SetBootInstance(l.cfg.Boot)
 
+		switch l.cfg.WatchHostFiles {
+		case "", "warn", "refresh":
+			l.engineConfig.SetWatchHostFiles(l.cfg.WatchHostFiles)
+		default:
+			return fmt.Errorf("invalid --watch-host-files value %q, must be 'warn' or 'refresh'", l.cfg.WatchHostFiles)
+		}
+
+		for k := range l.cfg.InstanceLabels {
+			if k == "" {
+				return fmt.Errorf("invalid --label: empty label key")
+			}
+		}
+		l.engineConfig.SetInstanceLabels(l.cfg.InstanceLabels)
+
 		if useSuid && !l.cfg.Namespaces.User && launcher.HidepidProc() {
 			return fmt.Errorf("hidepid option set on /proc mount, require 'hidepid=0' to start instance with setuid workflow")
 		}
@@ -347,14 +500,27 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	l.generator.AddProcessEnv("SINGULARITY_APPNAME", l.cfg.AppName)
 
 	// Get image ready to run, if needed, via FUSE mount / extraction / image driver handling.
-	if err := l.prepareImage(ctx, ep.Image); err != nil {
+	_, span := tracing.Start(ctx, "mount image", attribute.String("image", ep.Image))
+	err = l.prepareImage(ctx, ep.Image)
+	tracing.End(span, err)
+	if err != nil {
 		return fmt.Errorf("while preparing image: %s", err)
 	}
 
 	// Call the starter binary using our prepared config.
 	if l.engineConfig.GetInstance() {
+		_, span = tracing.Start(ctx, "start container", attribute.String("instance", ep.Instance))
 		err = l.starterInstance(ep.Instance, useSuid)
+		tracing.End(span, err)
+	} else if l.cfg.Stdio != nil {
+		_, span = tracing.Start(ctx, "run container")
+		err = l.starterChild(ctx, useSuid)
+		tracing.End(span, err)
 	} else {
+		// The starter replaces this process, so traces must be flushed first.
+		if traceErr := tracing.Shutdown(); traceErr != nil {
+			sylog.Debugf("While flushing traces: %v", traceErr)
+		}
 		err = l.starterInteractive(useSuid)
 	}
 
@@ -506,10 +672,19 @@ func (l *Launcher) checkImage() error {
 		return fmt.Errorf("native runtime does not support OCI-SIF images, use --oci mode")
 	}
 
+	// The license policy is applied by the engine, with the image it opens.
+	l.engineConfig.SetLicenseOverride(l.cfg.LicenseOverride)
+
 	if err := l.checkEncryptionKey(img); err != nil {
 		return err
 	}
 
+	if img.Type == image.SIF {
+		if err := l.setMetadataEdits(); err != nil {
+			return err
+		}
+	}
+
 	// don't defer this call as in all cases it won't be
 	// called before execing starter, so it would leak the
 	// image file descriptor to the container process
@@ -517,6 +692,26 @@ func (l *Launcher) checkImage() error {
 	return nil
 }
 
+// setMetadataEdits passes the metadata edits of the SIF image, made with
+// 'sif edit-metadata', to the engine, which applies them to the container.
+func (l *Launcher) setMetadataEdits() error {
+	r, err := metadata.RuntimeEdits(l.engineConfig.GetImage())
+	if err != nil {
+		return fmt.Errorf("while reading metadata of image %s: %w", l.engineConfig.GetImage(), err)
+	}
+	if r == nil {
+		return nil
+	}
+
+	l.engineConfig.SetMetadataEnv(r.Env)
+	files := make(map[string]string, len(r.Files))
+	for path, content := range r.Files {
+		files[path] = string(content)
+	}
+	l.engineConfig.SetMetadataFiles(files)
+	return nil
+}
+
 // checkEncryptionKey verifies key material is available if the image is encrypted.
 // Allows us to fail fast if required key material is not available / usable.
 func (l *Launcher) checkEncryptionKey(img *imgutil.Image) error {
@@ -530,11 +725,18 @@ func (l *Launcher) checkEncryptionKey(img *imgutil.Image) error {
 	if part.Type == imgutil.ENCRYPTSQUASHFS {
 		sylog.Debugf("Encrypted container filesystem detected")
 
-		if l.cfg.KeyInfo == nil {
-			return fmt.Errorf("no key was provided, cannot access encrypted container")
+		keyInfo := l.cfg.KeyInfo
+		if keyInfo == nil {
+			// The image may record the key URI that unwraps its key.
+			ki, err := cryptkey.ImageKeyInfo(l.engineConfig.GetImage())
+			if err != nil {
+				return fmt.Errorf("no key was provided, cannot access encrypted container")
+			}
+			sylog.Verbosef("Using key URI %s recorded in image", ki.Path)
+			keyInfo = ki
 		}
 
-		plaintextKey, err := cryptkey.PlaintextKey(*l.cfg.KeyInfo, l.engineConfig.GetImage())
+		plaintextKey, err := cryptkey.PlaintextKey(*keyInfo, l.engineConfig.GetImage())
 		if err != nil {
 			sylog.Errorf("Please check you are providing the correct key for decryption")
 			return fmt.Errorf("cannot decrypt %s: %w", l.engineConfig.GetImage(), err)
@@ -546,7 +748,7 @@ func (l *Launcher) checkEncryptionKey(img *imgutil.Image) error {
 }
 
 // useSuid checks whether to use the setuid starter binary, and if we need to force the user namespace.
-func (l *Launcher) useSuid() (useSuid, forceUserNs bool) {
+func (l *Launcher) useSuid() (useSuid, forceUserNs bool, err error) {
 	// privileged installation by default
 	useSuid = true
 	// Are we already in a user namespace?
@@ -578,7 +780,7 @@ func (l *Launcher) useSuid() (useSuid, forceUserNs bool) {
 		} else if l.uid == 0 && !l.cfg.Namespaces.User {
 			caps, err := capabilities.GetProcessEffective()
 			if err != nil {
-				sylog.Fatalf("Could not get process effective capabilities: %s", err)
+				return false, false, fmt.Errorf("could not get process effective capabilities: %w", err)
 			}
 			if caps&uint64(1<<unix.CAP_SYS_ADMIN) == 0 {
 				sylog.Verbosef("Effective capability CAP_SYS_ADMIN is missing, fallback to user namespace")
@@ -586,7 +788,7 @@ func (l *Launcher) useSuid() (useSuid, forceUserNs bool) {
 			}
 		}
 	}
-	return useSuid, forceUserNs
+	return useSuid, forceUserNs, nil
 }
 
 // setBinds sets engine configuration for requested bind mounts.
@@ -597,12 +799,32 @@ func (l *Launcher) setBinds() error {
 		return fmt.Errorf("while parsing bind path: %w", err)
 	}
 	// Now add binds from one or more --mount and env var.
-	for _, m := range l.cfg.Mounts {
-		bps, err := bind.ParseMountString(m)
-		if err != nil {
-			return fmt.Errorf("while parsing mount %q: %w", m, err)
+	bps, err := launcher.ParseMounts(l.cfg.Mounts)
+	if err != nil {
+		return err
+	}
+	binds = append(binds, bps...)
+
+	// CVMFS repositories requested with --cvmfs, and site defaults.
+	cvmfsBinds, repos, err := launcher.CVMFSBinds(l.cfg.CVMFS, l.engineConfig.File.CVMFSRepos)
+	if err != nil {
+		return err
+	}
+	binds = append(binds, cvmfsBinds...)
+	_, envSet := l.cfg.Env[launcher.CVMFSEnv]
+	if len(repos) > 0 && !envSet && os.Getenv(env.SingularityEnvPrefix+launcher.CVMFSEnv) == "" {
+		sylog.Debugf("Setting '%s=%s' from --cvmfs", launcher.CVMFSEnv, strings.Join(repos, ","))
+		os.Setenv(env.SingularityEnvPrefix+launcher.CVMFSEnv, strings.Join(repos, ","))
+	}
+
+	// The layers of an OCI-SIF image can only be mounted in OCI mode.
+	for _, b := range binds {
+		if b.ImageSrc() == "" && b.ID() == "" {
+			continue
+		}
+		if isOCISIF, err := image.IsOCISIF(b.Source); err == nil && isOCISIF {
+			return fmt.Errorf("image mount of OCI-SIF image %s is only supported in --oci mode", b.Source)
 		}
-		binds = append(binds, bps...)
 	}
 
 	l.engineConfig.SetBindPath(binds)
@@ -659,6 +881,40 @@ func (l *Launcher) setNoMountFlags() {
 	l.engineConfig.SetSkipBinds(skipBinds)
 }
 
+// setHomeMode resolves the home mode requested with --home-mode, or set in
+// singularity.conf, against the default for other options in use. The modes
+// allowed by singularity.conf are enforced by the engine.
+func (l *Launcher) setHomeMode() error {
+	mode := l.cfg.HomeMode
+	if mode == "" && l.cfg.NoHome {
+		mode = launcher.HomeModeNone
+	} else if mode == "" && l.cfg.CustomHome {
+		mode = launcher.HomeModeHost
+	}
+	defaultMode := launcher.HomeModeHost
+	if (l.cfg.Contain || l.cfg.ContainAll) && !l.cfg.CustomHome {
+		defaultMode = launcher.HomeModeEphemeral
+	}
+
+	mode, err := launcher.ResolveHomeMode(mode, defaultMode, l.engineConfig.File)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case launcher.HomeModeNone:
+		l.cfg.NoHome = true
+	case launcher.HomeModeImage:
+		// The home directory is an overlay, persisted in the writable overlay
+		// partition of the SIF image, which can't also be the writable
+		// overlay of the whole image.
+		if l.cfg.Writable {
+			return fmt.Errorf("--home-mode image cannot be used with --writable")
+		}
+	}
+	l.engineConfig.SetHomeMode(mode)
+	return nil
+}
+
 // setHome sets the correct home directory configuration for our circumstance.
 // If it is not possible to mount a home directory then the mount will be disabled.
 func (l *Launcher) setHome() error {
@@ -707,7 +963,7 @@ func (l *Launcher) setHome() error {
 	return nil
 }
 
-// SetGPUConfig sets up EngineConfig entries for NV / ROCm usage, if requested.
+// SetGPUConfig sets up EngineConfig entries for NV / ROCm / Intel usage, if requested.
 func (l *Launcher) SetGPUConfig() error {
 	if l.engineConfig.File.AlwaysUseNv && !l.cfg.NoNvidia {
 		l.cfg.Nvidia = true
@@ -721,6 +977,9 @@ func (l *Launcher) SetGPUConfig() error {
 	if l.cfg.Nvidia && l.cfg.Rocm {
 		sylog.Warningf("--nv and --rocm cannot be used together. Only --nv will be applied.")
 	}
+	if l.cfg.Intel && (l.cfg.Nvidia || l.cfg.Rocm) {
+		sylog.Warningf("--intel cannot be used together with --nv or --rocm. Only --nv or --rocm will be applied.")
+	}
 
 	if l.cfg.Nvidia {
 		// If nvccli was not enabled by flag or config, drop down to legacy binds immediately
@@ -740,6 +999,10 @@ func (l *Launcher) SetGPUConfig() error {
 	if l.cfg.Rocm {
 		return l.setRocmConfig()
 	}
+
+	if l.cfg.Intel {
+		return l.setIntelConfig()
+	}
 	return nil
 }
 
@@ -748,6 +1011,16 @@ func (l *Launcher) setNvCCLIConfig() (err error) {
 	sylog.Debugf("Using nvidia-container-cli for GPU setup")
 	l.engineConfig.SetNvCCLI(true)
 
+	// --gpus takes precedence over NVIDIA_VISIBLE_DEVICES from the environment.
+	if l.cfg.GPUs != "" {
+		devs, err := gpu.NvidiaVisibleDevices(l.cfg.GPUs)
+		if err != nil {
+			return err
+		}
+		sylog.Debugf("Setting 'NVIDIA_VISIBLE_DEVICES=%s' from --gpus", devs)
+		os.Setenv("NVIDIA_VISIBLE_DEVICES", devs)
+	}
+
 	if os.Getenv("NVIDIA_VISIBLE_DEVICES") == "" {
 		if l.cfg.Contain || l.cfg.ContainAll {
 			// When we use --contain we don't mount the NV devices by default in the nvidia-container-cli flow,
@@ -775,6 +1048,9 @@ func (l *Launcher) setNvCCLIConfig() (err error) {
 		return fmt.Errorf("nvidia-container-cli requires --writable with user namespace/fakeroot")
 	}
 	if !l.cfg.Writable && !l.cfg.WritableTmpfs {
+		if l.cfg.ReadOnly {
+			return fmt.Errorf("nvidia-container-cli requires --writable-tmpfs, which cannot be used with --read-only")
+		}
 		sylog.Infof("Setting --writable-tmpfs (required by nvidia-container-cli)")
 		l.cfg.WritableTmpfs = true
 	}
@@ -797,6 +1073,37 @@ func (l *Launcher) setNVLegacyConfig() error {
 		sylog.Warningf("While finding nv bind points: %v", err)
 	}
 	l.setGPUBinds(libs, bins, ipcs, "nv")
+	return l.setNVLegacyGPUs()
+}
+
+// setNVLegacyGPUs restricts the NVIDIA GPUs available in the container, with
+// legacy binds, to those selected with --gpus. Only the device nodes of the
+// selected GPUs are bound into a contained /dev, and CUDA_VISIBLE_DEVICES is
+// set to select them, unless already set for the container.
+func (l *Launcher) setNVLegacyGPUs() error {
+	if l.cfg.GPUs == "" || l.cfg.GPUs == "all" {
+		return nil
+	}
+	gpus, err := gpu.NvidiaSelectGPUs(l.cfg.GPUs)
+	if err != nil {
+		return fmt.Errorf("while selecting GPUs: %w", err)
+	}
+
+	devs, err := gpu.NvidiaSelectedDevices(gpus)
+	if err != nil {
+		return fmt.Errorf("while finding NVIDIA devices: %w", err)
+	}
+	l.engineConfig.SetNvLegacyDevices(devs)
+	if !l.cfg.Contain && !l.cfg.ContainAll && l.engineConfig.File.MountDev == "yes" {
+		sylog.Infof("All NVIDIA devices are present in the host /dev, GPUs are selected with CUDA_VISIBLE_DEVICES only")
+	}
+
+	_, envSet := l.cfg.Env["CUDA_VISIBLE_DEVICES"]
+	if !envSet && os.Getenv("SINGULARITYENV_CUDA_VISIBLE_DEVICES") == "" {
+		cuda := gpu.CUDAVisibleDevices(gpus)
+		sylog.Debugf("Setting 'CUDA_VISIBLE_DEVICES=%s' from --gpus", cuda)
+		os.Setenv("SINGULARITYENV_CUDA_VISIBLE_DEVICES", cuda)
+	}
 	return nil
 }
 
@@ -813,6 +1120,86 @@ func (l *Launcher) setRocmConfig() error {
 	return nil
 }
 
+// setIntelConfig sets up EngineConfig entries for Intel GPU configuration via direct binds of configured bins/libs.
+func (l *Launcher) setIntelConfig() error {
+	sylog.Debugf("Using intel GPU setup")
+	l.engineConfig.SetIntel(true)
+	gpuConfFile := filepath.Join(buildcfg.SINGULARITY_CONFDIR, "intelliblist.conf")
+	libs, bins, err := gpu.IntelPaths(gpuConfFile)
+	if err != nil {
+		sylog.Warningf("While finding Intel GPU bind points: %v", err)
+	}
+	devs, err := gpu.IntelDevices()
+	if err != nil {
+		sylog.Warningf("While finding Intel GPU devices: %v", err)
+	}
+	if len(devs) == 0 {
+		sylog.Warningf("Could not find any Intel GPU devices on this host!")
+	}
+	l.setGPUBinds(libs, bins, []string{}, "intel")
+	return nil
+}
+
+// setRdmaConfig sets up EngineConfig entries for InfiniBand / RDMA configuration via direct binds of configured bins/libs.
+func (l *Launcher) setRdmaConfig() {
+	sylog.Debugf("Using rdma setup")
+	l.engineConfig.SetRdma(true)
+	confFile := filepath.Join(buildcfg.SINGULARITY_CONFDIR, "rdmaliblist.conf")
+	libs, bins, err := gpu.RdmaPaths(confFile)
+	if err != nil {
+		sylog.Warningf("While finding RDMA bind points: %v", err)
+	}
+	devs, err := gpu.RdmaDevices()
+	if err != nil {
+		sylog.Warningf("While finding RDMA devices: %v", err)
+	}
+	if len(devs) == 0 {
+		sylog.Warningf("Could not find any RDMA devices on this host!")
+	}
+	l.setGPUBinds(libs, bins, []string{}, "rdma")
+
+	for k, v := range gpu.RdmaEnv {
+		if _, ok := l.cfg.Env[k]; ok || os.Getenv(k) != "" || os.Getenv("SINGULARITYENV_"+k) != "" {
+			continue
+		}
+		sylog.Debugf("Setting '%s=%s' for --rdma", k, v)
+		os.Setenv("SINGULARITYENV_"+k, v)
+	}
+}
+
+// setMPIConfig sets up EngineConfig entries to connect MPI applications to the
+// process manager of the host, binding its PMI libraries, bins and sockets, and
+// passing its PMI environment. The ABI compatibility of the host libraries with
+// those of the image is checked by the engine, once the image is mounted.
+func (l *Launcher) setMPIConfig() error {
+	sylog.Debugf("Using %s MPI setup", l.cfg.MPI)
+	m, err := launcher.MPISetup(l.cfg.MPI)
+	if err != nil {
+		return err
+	}
+	l.engineConfig.SetMPISonames(mpi.Sonames(m.Libs))
+
+	if l.cfg.Writable {
+		sylog.Warningf("%s files may not be bound with --writable", m.Mode)
+	}
+	l.engineConfig.AppendLibrariesPath(m.Libs...)
+	for _, binary := range m.Bins {
+		usrBinBinary := filepath.Join("/usr/bin", filepath.Base(binary))
+		l.engineConfig.AppendFilesPath(strings.Join([]string{binary, usrBinBinary}, ":"))
+	}
+	l.engineConfig.SetBindPath(append(l.engineConfig.GetBindPath(), m.Binds...))
+
+	// The PMI environment must reach the container with --cleanenv.
+	for k, v := range m.Env {
+		if _, ok := l.cfg.Env[k]; ok || os.Getenv(env.SingularityEnvPrefix+k) != "" {
+			continue
+		}
+		sylog.Debugf("Setting '%s=%s' for --mpi", k, v)
+		os.Setenv(env.SingularityEnvPrefix+k, v)
+	}
+	return nil
+}
+
 // setGPUBinds sets EngineConfig entries to bind the provided list of libs, bins, ipc files.
 func (l *Launcher) setGPUBinds(libs, bins, ipcs []string, gpuPlatform string) {
 	files := make([]string, len(bins)+len(ipcs))
@@ -829,12 +1216,12 @@ func (l *Launcher) setGPUBinds(libs, bins, ipcs []string, gpuPlatform string) {
 		for i, ipc := range ipcs {
 			files[i+len(bins)] = ipc
 		}
-		l.engineConfig.SetFilesPath(files)
+		l.engineConfig.AppendFilesPath(files...)
 	}
 	if len(libs) == 0 {
 		sylog.Warningf("Could not find any %s libraries on this host!", gpuPlatform)
 	} else {
-		l.engineConfig.SetLibrariesPath(libs)
+		l.engineConfig.AppendLibrariesPath(libs...)
 	}
 }
 
@@ -874,27 +1261,27 @@ func (l *Launcher) setNamespaces() {
 	}
 }
 
-// setEnv sets the environment for the container, from the host environment, glads, env-file.
+// setEnv sets the environment for the container, from the host environment, flags, env-files.
 func (l *Launcher) setEnv(ctx context.Context, args []string) error {
-	if l.cfg.EnvFile != "" {
+	if len(l.cfg.EnvFiles) > 0 {
 		currentEnv := append(
 			os.Environ(),
 			"SINGULARITY_IMAGE="+l.engineConfig.GetImage(),
 		)
 
-		env, err := env.FileMap(ctx, l.cfg.EnvFile, args, currentEnv)
+		env, err := env.FilesMap(ctx, l.cfg.EnvFiles, args, currentEnv)
 		if err != nil {
-			return fmt.Errorf("while processing %s: %w", l.cfg.EnvFile, err)
+			return err
 		}
 		// --env variables will take precedence over variables
-		// defined by the environment file
-		sylog.Debugf("Setting environment variables from file %s", l.cfg.EnvFile)
+		// defined by the environment files
+		sylog.Debugf("Setting environment variables from files %v", l.cfg.EnvFiles)
 
-		// Update Env with those from file
+		// Update Env with those from files
 		for k, v := range env {
-			// Ensure we don't overwrite --env variables with environment file
+			// Ensure we don't overwrite --env variables with environment files
 			if _, ok := l.cfg.Env[k]; ok {
-				sylog.Warningf("Ignored environment variable %s from %s: override from --env", k, l.cfg.EnvFile)
+				sylog.Warningf("Ignored environment variable %s from --env-file: override from --env", k)
 			} else {
 				l.cfg.Env[k] = v
 			}
@@ -912,9 +1299,31 @@ func (l *Launcher) setEnv(ctx context.Context, args []string) error {
 	}
 	// Copy and cache environment
 	environment := os.Environ()
+	// With --no-env, only the SINGULARITYENV_ variables set above, and for
+	// --nv / --rdma, are passed to the container. Those of the host were
+	// unset when the launch started.
+	if l.cfg.NoEnv {
+		kept := environment[:0]
+		for _, e := range environment {
+			if strings.HasPrefix(e, env.SingularityEnvPrefix) {
+				kept = append(kept, e)
+			}
+		}
+		environment = kept
+	}
 	// Clean environment
-	singularityEnv := env.SetContainerEnv(l.generator, environment, l.cfg.CleanEnv, l.engineConfig.GetHomeDest())
+	singularityEnv := env.SetContainerEnv(l.generator, environment, l.cfg.CleanEnv || l.cfg.NoEnv, l.engineConfig.GetHomeDest())
 	l.engineConfig.SetSingularityEnv(singularityEnv)
+	// Scheduler env vars in the 'batch job env' allow-list are passed through
+	// --cleanenv, unless overridden by a SINGULARITYENV_ value.
+	if l.cfg.CleanEnv && !l.cfg.NoEnv {
+		for k, v := range env.BatchJobEnvMap(environment, l.engineConfig.File.BatchJobEnv) {
+			if _, ok := singularityEnv[k]; !ok {
+				sylog.Debugf("Forwarding %s batch job environment variable", k)
+				l.generator.AddProcessEnv(k, v)
+			}
+		}
+	}
 	return nil
 }
 
@@ -946,17 +1355,36 @@ func (l *Launcher) setCgroups(instanceName string) error {
 		l.engineConfig.SetDbusSessionBusAddress(os.Getenv("DBUS_SESSION_BUS_ADDRESS"))
 	}
 
+	l.engineConfig.SetCgroupStats(l.cfg.CgroupStats)
+	l.engineConfig.SetVirtualProc(l.cfg.VirtualProc)
+
+	adopted, err := l.adoptBatchJobCgroup()
+	if err != nil {
+		return err
+	}
+	if adopted {
+		return nil
+	}
+
 	if l.cfg.CGroupsJSON != "" {
 		// Handle cgroups configuration (parsed from file or flags in CLI).
+		resources, err := cgroups.UnmarshalJSONResources(l.cfg.CGroupsJSON)
+		if err != nil {
+			return err
+		}
+		if err := cgroups.CheckResources(resources, l.engineConfig.File.SystemdCgroups); err != nil {
+			return err
+		}
 		l.engineConfig.SetCgroupsJSON(l.cfg.CGroupsJSON)
 		return nil
 	}
 
-	if instanceName == "" {
+	if instanceName == "" && !l.cfg.CgroupStats {
 		return nil
 	}
 
-	// If we are an instance, always use a cgroup if possible, to enable stats.
+	// If we are an instance, or --cgroup-stats was requested, always use a
+	// cgroup if possible, to enable stats.
 	// root can always create a cgroup.
 	useCG := l.uid == 0
 	// non-root needs cgroups v2 unified mode + systemd as cgroups manager.
@@ -974,10 +1402,39 @@ func (l *Launcher) setCgroups(instanceName string) error {
 		return nil
 	}
 
+	if l.cfg.CgroupStats {
+		return fmt.Errorf("--cgroup-stats requires cgroups v2 with systemd as manager when run as a non-root user")
+	}
 	sylog.Infof("Instance stats will not be available - requires cgroups v2 with systemd as manager.")
 	return nil
 }
 
+// adoptBatchJobCgroup returns true if the container must stay in the cgroup
+// of the batch scheduler job that singularity runs in, as set by 'batch job
+// cgroup' in singularity.conf. Requested resource limits and cgroup stats are
+// not available in that case, as the job cgroup is managed by the scheduler.
+func (l *Launcher) adoptBatchJobCgroup() (bool, error) {
+	if !l.engineConfig.File.BatchJobCgroup {
+		return false, nil
+	}
+	job, err := cgroups.DetectBatchJob(os.Getpid())
+	if err != nil {
+		return false, fmt.Errorf("while detecting batch job cgroup: %w", err)
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	sylog.Verbosef("Running in cgroup %s of %s job %s", job.Group, job.Scheduler, job.ID)
+	if l.cfg.CGroupsJSON != "" {
+		sylog.Warningf("Ignoring requested cgroup resource limits, the limits of %s job %s apply", job.Scheduler, job.ID)
+	}
+	if l.cfg.CgroupStats {
+		sylog.Warningf("--cgroup-stats is not available inside the cgroup of %s job %s", job.Scheduler, job.ID)
+	}
+	return true, nil
+}
+
 // PrepareImage perfoms any image preparation required before execution.
 // This is currently limited to extraction or FUSE mount when using the user namespace,
 // and activating any image driver plugins that might handle the image mount.
@@ -1185,7 +1642,9 @@ func (l *Launcher) starterInteractive(useSuid bool) error {
 	}
 
 	// Allow any plugins with callbacks to modify the assembled Config
-	runPluginCallbacks(cfg)
+	if err := l.runPluginCallbacks(cfg); err != nil {
+		return err
+	}
 
 	err := starter.Exec(
 		"Singularity runtime parent",
@@ -1197,6 +1656,37 @@ func (l *Launcher) starterInteractive(useSuid bool) error {
 	return err
 }
 
+// starterChild runs the starter binary as a child process, connected to the
+// streams of l.cfg.Stdio, to run an image until it exits, or ctx is canceled.
+func (l *Launcher) starterChild(ctx context.Context, useSuid bool) error {
+	loadOverlay := false
+	if !l.cfg.Namespaces.User && buildcfg.SINGULARITY_SUID_INSTALL == 1 {
+		loadOverlay = true
+	}
+
+	cfg := &config.Common{
+		EngineName:   singularityConfig.Name,
+		EngineConfig: l.engineConfig,
+	}
+
+	// Allow any plugins with callbacks to modify the assembled Config
+	if err := l.runPluginCallbacks(cfg); err != nil {
+		return err
+	}
+
+	return starter.RunContext(
+		ctx,
+		"Singularity runtime parent",
+		cfg,
+		starter.UseSuid(useSuid),
+		starter.WithStdin(l.cfg.Stdio.Stdin),
+		starter.WithStdout(l.cfg.Stdio.Stdout),
+		starter.WithStderr(l.cfg.Stdio.Stderr),
+		starter.LoadOverlayModule(loadOverlay),
+		starter.CleanupHost(l.engineConfig.GetImageFuse()),
+	)
+}
+
 // starterInstance executes the starter binary to run an instance given the supplied engineConfig
 func (l *Launcher) starterInstance(name string, useSuid bool) error {
 	cfg := &config.Common{
@@ -1206,7 +1696,9 @@ func (l *Launcher) starterInstance(name string, useSuid bool) error {
 	}
 
 	// Allow any plugins with callbacks to modify the assembled Config
-	runPluginCallbacks(cfg)
+	if err := l.runPluginCallbacks(cfg); err != nil {
+		return err
+	}
 
 	pu, err := user.GetPwUID(uint32(os.Getuid()))
 	if err != nil {
@@ -1269,7 +1761,7 @@ func (l *Launcher) starterInstance(name string, useSuid bool) error {
 }
 
 // runPluginCallbacks executes any plugin callbacks to manipulate the engine config passed in
-func runPluginCallbacks(cfg *config.Common) error {
+func (l *Launcher) runPluginCallbacks(cfg *config.Common) error {
 	callbackType := (clicallback.SingularityEngineConfig)(nil)
 	callbacks, err := plugin.LoadCallbacks(callbackType)
 	if err != nil {
@@ -1279,5 +1771,17 @@ func runPluginCallbacks(cfg *config.Common) error {
 		//nolint:forcetypeassert
 		c.(clicallback.SingularityEngineConfig)(cfg)
 	}
+
+	launcherType := (launchercallback.NativeConfig)(nil)
+	callbacks, err = plugin.LoadCallbacks(launcherType)
+	if err != nil {
+		return fmt.Errorf("while loading plugin callbacks '%T': %w", launcherType, err)
+	}
+	for _, c := range callbacks {
+		//nolint:forcetypeassert
+		if err := c.(launchercallback.NativeConfig)(l.engineConfig.GetImage(), l.engineConfig); err != nil {
+			return fmt.Errorf("plugin rejected container configuration: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,93 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+	ocitest "github.com/sylabs/singularity/v4/internal/pkg/test/tool/oci"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// TestSpecConformance checks the runtime spec generated by the launcher, for
+// a range of options, against the OCI runtime specification. New options
+// affecting the spec should be covered here, to gate regressions in spec
+// compliance.
+func TestSpecConformance(t *testing.T) {
+	if _, err := Runtime(); err != nil {
+		t.Skipf("OCI runtime not available: %v", err)
+	}
+
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	sc, err := singularityconf.GetConfig(nil)
+	if err != nil {
+		t.Fatalf("while initializing singularityconf: %s", err)
+	}
+	singularityconf.SetCurrentConfig(sc)
+
+	tests := []struct {
+		name string
+		opts []launcher.Option
+	}{
+		{
+			name: "Default",
+		},
+		{
+			name: "Namespaces",
+			opts: []launcher.Option{
+				launcher.OptNamespaces(launcher.Namespaces{PID: true, UTS: true, Net: true}),
+				launcher.OptHostname("conformance"),
+				launcher.OptNetwork("none", nil),
+			},
+		},
+		{
+			name: "ReadOnly",
+			opts: []launcher.Option{
+				launcher.OptWritableTmpfs(false),
+				launcher.OptNoCompat(false),
+			},
+		},
+		{
+			name: "NoCompat",
+			opts: []launcher.Option{
+				launcher.OptNoCompat(true),
+				launcher.OptNoMount([]string{"cwd"}),
+			},
+		},
+		{
+			name: "Binds",
+			opts: []launcher.Option{
+				launcher.OptMounts([]string{"/etc/hosts:/hosts:ro", "/tmp:/mnt"}, nil, nil),
+				launcher.OptScratchDirs([]string{"/scratch"}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLauncher(tt.opts...)
+			if err != nil {
+				t.Fatalf("NewLauncher() error = %v", err)
+			}
+			spec, err := l.createSpec()
+			if err != nil {
+				t.Fatalf("createSpec() error = %v", err)
+			}
+
+			bundle := t.TempDir()
+			if err := os.Mkdir(filepath.Join(bundle, "rootfs"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			ocitest.ValidateSpec(t, spec, bundle)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/coredump"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// collectCoreDump collects the core dump of the container process into
+// --core-dir, if runErr shows that it was terminated by a signal that dumps
+// core. The OCI runtime exits with 128+<signal> in that case.
+func (l *Launcher) collectCoreDump(spec *specs.Spec, since time.Time, runErr error) {
+	var exitErr *exec.ExitError
+	if !errors.As(runErr, &exitErr) || exitErr.ExitCode() <= 128 {
+		return
+	}
+	sig := syscall.Signal(exitErr.ExitCode() - 128)
+	if !coredump.IsCoreSignal(sig) {
+		return
+	}
+
+	md := coredump.Metadata{
+		Image:   l.image,
+		Command: spec.Process.Args,
+		Signal:  unix.SignalName(sig),
+	}
+	digest, err := coredump.ImageDigest(l.image)
+	if err != nil {
+		sylog.Warningf("While computing image digest: %v", err)
+	}
+	md.ImageDigest = digest
+
+	path, err := coredump.Collect(l.cfg.CoreDir, l.hostCwd(spec.Process.Cwd), since, md)
+	if err != nil {
+		sylog.Errorf("Could not collect core dump: %v", err)
+		return
+	}
+	sylog.Infof("Crash of the container process collected in %s", path)
+}
+
+// hostCwd returns the host path of cwd, the working directory of the
+// container process, if it is within the home directory bound from the host.
+func (l *Launcher) hostCwd(cwd string) string {
+	if l.homeSrc == "" {
+		return cwd
+	}
+	rel, err := filepath.Rel(l.homeDest, cwd)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return cwd
+	}
+	return filepath.Join(l.homeSrc, rel)
+}
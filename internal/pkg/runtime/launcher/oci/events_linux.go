@@ -0,0 +1,272 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/cgroups"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// exitTimeout is how long Events waits for conmon to record the exit status
+// of a container, once its process has exited.
+const exitTimeout = 5 * time.Second
+
+// event is a container event, in the format of runc events.
+type event struct {
+	Type string      `json:"type"`
+	ID   string      `json:"id"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// exitStatus is the data of an exit event.
+type exitStatus struct {
+	Status int `json:"status"`
+}
+
+// Events writes the events of a container as JSON: OOM kills, and resource
+// usage statistics every interval seconds, until the container exits,
+// followed by its exit status when recorded by conmon. If stats is set, the
+// statistics are written once. Events are read from the cgroup of the
+// container, rather than from the OCI runtime, as crun doesn't implement
+// events.
+func Events(ctx context.Context, containerID string, interval uint32, stats bool) error {
+	if interval == 0 && !stats {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+
+	pid, err := containerPid(containerID)
+	if err != nil {
+		return err
+	}
+	manager, err := cgroups.GetManagerForPid(pid)
+	if err != nil {
+		return fmt.Errorf("while getting cgroup of container %s: %w", containerID, err)
+	}
+
+	// Events are written concurrently by the OOM watch.
+	events := make(chan event)
+	enc := json.NewEncoder(os.Stdout)
+	writeStats := func() error {
+		s, err := manager.GetStats()
+		if err != nil {
+			return err
+		}
+		return enc.Encode(event{Type: "stats", ID: containerID, Data: s})
+	}
+	if stats {
+		return writeStats()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		err := manager.WatchOOM(ctx, func(uint64) {
+			select {
+			case events <- event{Type: "oom", ID: containerID}:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil {
+			sylog.Warningf("OOM kills of container %s will not be reported: %v", containerID, err)
+		}
+	}()
+
+	statsTicker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer statsTicker.Stop()
+	exitTicker := time.NewTicker(time.Second)
+	defer exitTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-events:
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		case <-statsTicker.C:
+			if err := writeStats(); err != nil && processRunning(pid) {
+				return err
+			}
+		case <-exitTicker.C:
+			if processRunning(pid) {
+				continue
+			}
+			status, ok, err := containerExitStatus(ctx, containerID)
+			if err != nil || !ok {
+				return err
+			}
+			return enc.Encode(event{Type: "exit", ID: containerID, Data: exitStatus{Status: status}})
+		}
+	}
+}
+
+// Ps writes the processes running in a container, found from its cgroup. The
+// json format lists their PIDs, and the table format lists them as displayed
+// by ps, called with psArgs, or -ef.
+func Ps(containerID, format string, psArgs []string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format %q, must be table or json", format)
+	}
+
+	pid, err := containerPid(containerID)
+	if err != nil {
+		return err
+	}
+	manager, err := cgroups.GetManagerForPid(pid)
+	if err != nil {
+		return fmt.Errorf("while getting cgroup of container %s: %w", containerID, err)
+	}
+	pids, err := manager.GetPids()
+	if err != nil {
+		return fmt.Errorf("while getting processes of container %s: %w", containerID, err)
+	}
+
+	if format == "json" {
+		return json.NewEncoder(os.Stdout).Encode(pids)
+	}
+
+	if len(psArgs) == 0 {
+		psArgs = []string{"-ef"}
+	}
+	out, err := exec.Command("ps", psArgs...).Output()
+	if err != nil {
+		return fmt.Errorf("while running ps: %w", err)
+	}
+	return filterPs(os.Stdout, out, pids)
+}
+
+// filterPs writes the header of the ps output out to w, and its lines for the
+// processes in pids.
+func filterPs(w io.Writer, out []byte, pids []int) error {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	if !scanner.Scan() {
+		return fmt.Errorf("no output from ps")
+	}
+	header := scanner.Text()
+	pidIndex := -1
+	for i, f := range strings.Fields(header) {
+		if f == "PID" {
+			pidIndex = i
+			break
+		}
+	}
+	if pidIndex < 0 {
+		return fmt.Errorf("no PID column in ps output")
+	}
+
+	inContainer := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		inContainer[pid] = true
+	}
+
+	fmt.Fprintln(w, header)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if pidIndex >= len(fields) {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[pidIndex]); err == nil && inContainer[pid] {
+			fmt.Fprintln(w, scanner.Text())
+		}
+	}
+	return scanner.Err()
+}
+
+// containerPid returns the PID of the process of a running container, from
+// the state reported by the OCI runtime.
+func containerPid(containerID string) (int, error) {
+	runtimeBin, err := Runtime()
+	if err != nil {
+		return 0, err
+	}
+	rsd, err := runtimeStateDir()
+	if err != nil {
+		return 0, err
+	}
+
+	runtimeArgs := []string{"--root", rsd, "state", containerID}
+	sylog.Debugf("Calling %s with args %v", runtimeBin, runtimeArgs)
+	out, err := exec.Command(runtimeBin, runtimeArgs...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return 0, fmt.Errorf("while getting state of container %s: %s", containerID, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return 0, fmt.Errorf("while getting state of container %s: %w", containerID, err)
+	}
+
+	var state specs.State
+	if err := json.Unmarshal(out, &state); err != nil {
+		return 0, fmt.Errorf("while decoding state of container %s: %w", containerID, err)
+	}
+	if state.Status != specs.StateRunning && state.Status != "paused" {
+		return 0, fmt.Errorf("container %s is not running", containerID)
+	}
+	return state.Pid, nil
+}
+
+// containerExitStatus returns the exit status of an exited container,
+// recorded by conmon, waiting for it up to exitTimeout. False is returned for
+// a container not created with conmon, e.g. with oci run.
+func containerExitStatus(ctx context.Context, containerID string) (int, bool, error) {
+	sd, err := stateDir(containerID)
+	if err != nil {
+		return 0, false, fmt.Errorf("while computing state directory: %w", err)
+	}
+	dir := filepath.Join(sd, exitDir)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exitTimeout)
+	defer cancel()
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, containerID))
+		if err == nil && len(bytes.TrimSpace(data)) > 0 {
+			status, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			return status, err == nil, err
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, false, err
+		}
+		select {
+		case <-ctx.Done():
+			return 0, false, fmt.Errorf("exit status of container %s was not recorded", containerID)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// processRunning returns whether the process pid exists, and is not a zombie.
+func processRunning(pid int) bool {
+	if err := unix.Kill(pid, 0); errors.Is(err, unix.ESRCH) {
+		return false
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is between parentheses.
+	i := bytes.LastIndexByte(data, ')')
+	return i < 0 || i+2 >= len(data) || data[i+2] != 'Z'
+}
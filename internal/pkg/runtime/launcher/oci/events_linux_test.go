@@ -0,0 +1,70 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFilterPs(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		pids    []int
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Ef",
+			out: `UID          PID    PPID  C STIME TTY          TIME CMD
+root           1       0  0 10:00 ?        00:00:01 /sbin/init
+root         100       1  0 10:01 ?        00:00:00 conmon
+root         101     100  0 10:01 pts/0    00:00:00 /bin/sh
+root         102     101  0 10:01 pts/0    00:00:00 sleep 100
+`,
+			pids: []int{101, 102},
+			want: `UID          PID    PPID  C STIME TTY          TIME CMD
+root         101     100  0 10:01 pts/0    00:00:00 /bin/sh
+root         102     101  0 10:01 pts/0    00:00:00 sleep 100
+`,
+		},
+		{
+			name: "CustomColumns",
+			out: `  RSS     PID COMMAND
+ 1024       1 init
+ 2048     101 sh
+`,
+			pids: []int{101},
+			want: `  RSS     PID COMMAND
+ 2048     101 sh
+`,
+		},
+		{
+			name:    "NoPidColumn",
+			out:     "COMMAND\ninit\n",
+			pids:    []int{1},
+			wantErr: true,
+		},
+		{
+			name:    "NoOutput",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := filterPs(&b, []byte(tt.out), tt.pids)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filterPs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && b.String() != tt.want {
+				t.Errorf("filterPs() = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containers/common/pkg/hooks"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// addHooks injects into spec the OCI runtime hooks, defined in the directory
+// set by 'oci hooks dir' in singularity.conf, whose conditions match the
+// container. Hook definitions use the oci-hooks(5) JSON format shared with
+// other container engines.
+func (l *Launcher) addHooks(ctx context.Context, spec *specs.Spec) error {
+	dir := l.singularityConf.OCIHooksDir
+	if dir == "" {
+		return nil
+	}
+
+	sylog.Debugf("Reading OCI hooks from %s", dir)
+	m, err := hooks.New(ctx, []string{dir}, nil)
+	if err != nil {
+		return fmt.Errorf("while reading OCI hooks from %s: %w", dir, err)
+	}
+
+	// Host to container bind mounts are those requested with --bind / --mount.
+	hasBindMounts := len(l.cfg.BindPaths) > 0 || len(l.cfg.Mounts) > 0
+	if _, err := m.Hooks(spec, spec.Annotations, hasBindMounts); err != nil {
+		return fmt.Errorf("while adding OCI hooks: %w", err)
+	}
+
+	if spec.Hooks != nil {
+		sylog.Debugf("OCI hooks: %d prestart, %d createRuntime, %d createContainer, %d startContainer, %d poststart, %d poststop",
+			len(spec.Hooks.Prestart), //nolint:staticcheck
+			len(spec.Hooks.CreateRuntime),
+			len(spec.Hooks.CreateContainer),
+			len(spec.Hooks.StartContainer),
+			len(spec.Hooks.Poststart),
+			len(spec.Hooks.Poststop),
+		)
+	}
+	return nil
+}
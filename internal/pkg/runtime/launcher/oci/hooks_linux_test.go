@@ -0,0 +1,170 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// Hook definitions, formatted with the directory holding the hook programs.
+const (
+	alwaysHook = `{
+	"version": "1.0.0",
+	"hook": {"path": "%[1]s/always", "args": ["always", "arg"]},
+	"when": {"always": true},
+	"stages": ["createRuntime", "poststop"]
+}`
+	commandHook = `{
+	"version": "1.0.0",
+	"hook": {"path": "%[1]s/command"},
+	"when": {"commands": [".*/python3$"]},
+	"stages": ["prestart"]
+}`
+	annotationHook = `{
+	"version": "1.0.0",
+	"hook": {"path": "%[1]s/annotation"},
+	"when": {"annotations": {"^org\\.example\\.gpu$": "^true$"}},
+	"stages": ["createContainer"]
+}`
+	bindHook = `{
+	"version": "1.0.0",
+	"hook": {"path": "%[1]s/bind"},
+	"when": {"hasBindMounts": true},
+	"stages": ["poststart"]
+}`
+)
+
+func TestAddHooks(t *testing.T) {
+	// Hooks are ignored when their program doesn't exist.
+	binDir := t.TempDir()
+	for _, name := range []string{"always", "command", "annotation", "bind"} {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hooksDir := t.TempDir()
+	for name, content := range map[string]string{
+		"10-always.json":     alwaysHook,
+		"20-command.json":    commandHook,
+		"30-annotation.json": annotationHook,
+		"40-bind.json":       bindHook,
+	} {
+		if err := os.WriteFile(filepath.Join(hooksDir, name), []byte(fmt.Sprintf(content, binDir)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	badDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(badDir, "bad.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	always := specs.Hook{Path: filepath.Join(binDir, "always"), Args: []string{"always", "arg"}}
+
+	tests := []struct {
+		name        string
+		dir         string
+		cfg         launcher.Options
+		args        []string
+		annotations map[string]string
+		wantHooks   *specs.Hooks
+		wantErr     bool
+	}{
+		{
+			name:      "NoDir",
+			dir:       "",
+			args:      []string{"/usr/bin/python3"},
+			wantHooks: nil,
+		},
+		{
+			name:      "MissingDir",
+			dir:       filepath.Join(hooksDir, "missing"),
+			args:      []string{"/bin/sh"},
+			wantHooks: nil,
+		},
+		{
+			name: "Always",
+			dir:  hooksDir,
+			args: []string{"/bin/sh"},
+			wantHooks: &specs.Hooks{
+				CreateRuntime: []specs.Hook{always},
+				Poststop:      []specs.Hook{always},
+			},
+		},
+		{
+			name: "Command",
+			dir:  hooksDir,
+			args: []string{"/usr/bin/python3", "script.py"},
+			wantHooks: &specs.Hooks{
+				Prestart:      []specs.Hook{{Path: filepath.Join(binDir, "command")}}, //nolint:staticcheck
+				CreateRuntime: []specs.Hook{always},
+				Poststop:      []specs.Hook{always},
+			},
+		},
+		{
+			name:        "Annotation",
+			dir:         hooksDir,
+			args:        []string{"/bin/sh"},
+			annotations: map[string]string{"org.example.gpu": "true"},
+			wantHooks: &specs.Hooks{
+				CreateRuntime:   []specs.Hook{always},
+				CreateContainer: []specs.Hook{{Path: filepath.Join(binDir, "annotation")}},
+				Poststop:        []specs.Hook{always},
+			},
+		},
+		{
+			name: "BindMounts",
+			dir:  hooksDir,
+			cfg:  launcher.Options{BindPaths: []string{"/tmp:/mnt"}},
+			args: []string{"/bin/sh"},
+			wantHooks: &specs.Hooks{
+				CreateRuntime: []specs.Hook{always},
+				Poststart:     []specs.Hook{{Path: filepath.Join(binDir, "bind")}},
+				Poststop:      []specs.Hook{always},
+			},
+		},
+		{
+			name:    "BadHook",
+			dir:     badDir,
+			args:    []string{"/bin/sh"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Launcher{
+				cfg:             tt.cfg,
+				singularityConf: &singularityconf.File{OCIHooksDir: tt.dir},
+			}
+			spec := &specs.Spec{
+				Process:     &specs.Process{Args: tt.args},
+				Annotations: tt.annotations,
+			}
+
+			err := l.addHooks(context.Background(), spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addHooks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(spec.Hooks, tt.wantHooks) {
+				t.Errorf("addHooks() hooks = %+v, want %+v", spec.Hooks, tt.wantHooks)
+			}
+		})
+	}
+}
@@ -11,40 +11,61 @@ package oci
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	lccgroups "github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/samber/lo"
+	"github.com/sigstore/sigstore/pkg/signature"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/v4/internal/pkg/cache"
 	"github.com/sylabs/singularity/v4/internal/pkg/cgroups"
+	ocisifclient "github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/license"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/metadata"
 	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+	"github.com/sylabs/singularity/v4/internal/pkg/plugin"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
-	"github.com/sylabs/singularity/v4/internal/pkg/util/env"
+	"github.com/sylabs/singularity/v4/internal/pkg/security"
+	"github.com/sylabs/singularity/v4/internal/pkg/security/seccomp"
+	sifsignature "github.com/sylabs/singularity/v4/internal/pkg/signature"
+	"github.com/sylabs/singularity/v4/internal/pkg/syecl"
+	"github.com/sylabs/singularity/v4/internal/pkg/sypgp"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/coredump"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/files"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/ioprofile"
+	fsmount "github.com/sylabs/singularity/v4/internal/pkg/util/fs/mount"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/mpi"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/rootless"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/shell"
+	signalutil "github.com/sylabs/singularity/v4/internal/pkg/util/signal"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/tracing"
 	imgutil "github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/ocibundle"
+	"github.com/sylabs/singularity/v4/pkg/ocibundle/lazy"
 	"github.com/sylabs/singularity/v4/pkg/ocibundle/native"
 	"github.com/sylabs/singularity/v4/pkg/ocibundle/ocisif"
 	sifbundle "github.com/sylabs/singularity/v4/pkg/ocibundle/sif"
 	"github.com/sylabs/singularity/v4/pkg/ocibundle/tools"
+	launchercallback "github.com/sylabs/singularity/v4/pkg/plugin/callback/runtime/launcher"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
 	"github.com/sylabs/singularity/v4/pkg/util/slice"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sys/unix"
 	"tags.cncf.io/container-device-interface/pkg/cdi"
 )
@@ -53,7 +74,13 @@ var (
 	ErrUnsupportedOption = errors.New("not supported by OCI launcher")
 	ErrNotImplemented    = errors.New("not implemented by OCI launcher")
 
-	unsupportedNoMount = []string{"cwd"}
+	// unsupportedNoMount lists --no-mount values that have no effect in OCI
+	// mode, as the corresponding mount is never made.
+	unsupportedNoMount = []string{"hostfs"}
+
+	// etcNoMount lists the generated /etc files that can be disabled with
+	// --no-mount <path>, irrespective of --no-compat.
+	etcNoMount = []string{"/etc/resolv.conf", "/etc/passwd", "/etc/group"}
 )
 
 const (
@@ -78,6 +105,8 @@ type Launcher struct {
 	image string
 	// nativeSIF is set true when we are running a non-OCI SIF built for the native runtime.
 	nativeSIF bool
+	// metadataEdits holds the metadata edits of a native SIF image, if any.
+	metadataEdits *metadata.Runtime
 	// imageMountsByImagePath is the set of FUSE image mounts to be carried out
 	// before the container is run, mapped by the absolute path to the image.
 	imageMountsByImagePath map[string]*fuse.ImageMount
@@ -87,6 +116,15 @@ type Launcher struct {
 	// defaultTmpMountIndices contains the indices of mounts added by
 	// addTmpMounts() within the spec.Mounts slice.
 	defaultTmpMountIndices []int
+	// cudaVisibleDevices is the CUDA_VISIBLE_DEVICES value selecting the GPUs
+	// requested with --gpus, when set up with legacy binds.
+	cudaVisibleDevices string
+	// cvmfsRepos are the names of the CVMFS repositories bound into the
+	// container.
+	cvmfsRepos []string
+	// mpi is the MPI configuration requested with --mpi, set when its
+	// libraries and sockets are bound into the container.
+	mpi *launcher.MPI
 }
 
 // NewLauncher returns a oci.Launcher with an initial configuration set by opts.
@@ -102,23 +140,118 @@ func NewLauncher(opts ...launcher.Option) (*Launcher, error) {
 		return nil, err
 	}
 
+	if err := checkNetwork(lo); err != nil {
+		return nil, err
+	}
+
+	// --security seccomp:<profile> is equivalent to --seccomp-profile.
+	if p := security.GetParam(lo.SecurityOpts, "seccomp"); p != "" {
+		if lo.SeccompProfile != "" {
+			return nil, fmt.Errorf("--seccomp-profile cannot be used with --security seccomp:<profile>")
+		}
+		lo.SeccompProfile = p
+	}
+
+	if lo.SeccompProfile != "" && lo.SeccompTrace != "" {
+		return nil, fmt.Errorf("--seccomp-profile and --seccomp-trace cannot be used together")
+	}
+
+	// The image filesystem can only be watched with CAP_SYS_ADMIN on the host.
+	if lo.ProfileIO != "" {
+		if uid, err := rootless.Getuid(); err != nil || uid != 0 {
+			return nil, fmt.Errorf("--profile-io requires root")
+		}
+	}
+
+	// Collect core dumps of crashed container processes into --core-dir. The
+	// container processes inherit the raised core file size limit.
+	if lo.CoreDir != "" {
+		dir, err := coredump.CheckDir(lo.CoreDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := coredump.Enable(); err != nil {
+			return nil, err
+		}
+		lo.CoreDir = dir
+	}
+
+	// All signals are relayed to the container in OCI mode, so --signal-proxy
+	// is only validated.
+	if _, err := signalutil.ParseProxyList(lo.SignalProxy); err != nil {
+		return nil, fmt.Errorf("while parsing --signal-proxy: %w", err)
+	}
+
+	if lo.ApparmorProfile != "" && lo.SelinuxLabel != "" {
+		return nil, fmt.Errorf("an AppArmor profile and an SELinux label cannot be used together")
+	}
+
 	c := singularityconf.GetCurrentConfig()
 	if c == nil {
 		return nil, fmt.Errorf("singularity configuration is not initialized")
 	}
+	// Landlock is not applied in OCI mode, so fail rather than run the
+	// container without the restrictions requested by the administrator.
+	if c.Landlock {
+		return nil, fmt.Errorf("'landlock = yes' is set in singularity.conf, which is not supported in OCI mode")
+	}
 
 	homeHost, homeSrc, homeDest, err := parseHomeDir(lo.HomeDir, lo.CustomHome, lo.Fakeroot)
 	if err != nil {
 		return nil, err
 	}
 
+	// The home directory is an ephemeral tmpfs by default, unless bound from
+	// the host with --no-compat or a --home src:dest.
+	homeMode := lo.HomeMode
+	if homeMode == "" && lo.NoHome {
+		homeMode = launcher.HomeModeNone
+	} else if homeMode == "" && homeSrc != "" {
+		homeMode = launcher.HomeModeHost
+	}
+	defaultHomeMode := launcher.HomeModeEphemeral
+	if lo.NoCompat {
+		defaultHomeMode = launcher.HomeModeHost
+	}
+	lo.HomeMode, err = launcher.ResolveHomeMode(homeMode, defaultHomeMode, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := launcher.CheckHomeMode(lo.HomeMode, c); err != nil {
+		return nil, err
+	}
+	switch lo.HomeMode {
+	case launcher.HomeModeNone:
+		lo.NoHome = true
+	case launcher.HomeModeImage:
+		return nil, fmt.Errorf("--home-mode image is not supported in OCI mode")
+	case launcher.HomeModeEphemeral:
+		homeSrc = ""
+	}
+
 	// We are emulating native mode `--compat`, so  we provide a user-writable
 	// tmpfs by default, unless `--no-compat` was requested without
-	// `--writable-tmpfs`.
-	if !lo.NoCompat || lo.WritableTmpfs {
+	// `--writable-tmpfs`, `--read-only` was requested, or the default is
+	// disabled by 'oci writable tmpfs = no' in singularity.conf.
+	if lo.ReadOnly {
+		if lo.WritableTmpfs {
+			return nil, fmt.Errorf("--read-only and --writable-tmpfs cannot be used together")
+		}
+	} else if (!lo.NoCompat && c.OCIWritableTmpfs) || lo.WritableTmpfs {
 		lo.WritableTmpfs = true
 	}
 
+	// tmpfs sizes not set on the command line default to those in singularity.conf.
+	if lo.WritableTmpfsSize == 0 {
+		lo.WritableTmpfsSize = c.WritableTmpfsSize
+	}
+	if lo.TmpSize == 0 {
+		lo.TmpSize = c.TmpSize
+	}
+	if lo.ShmSize == 0 {
+		lo.ShmSize = c.ShmSize
+	}
+
 	return &Launcher{
 		cfg:                     lo,
 		singularityConf:         c,
@@ -143,31 +276,28 @@ func checkOpts(lo launcher.Options) error {
 	}
 
 	for _, nm := range lo.NoMount {
-		if strings.HasPrefix(nm, "/") || slice.ContainsString(unsupportedNoMount, nm) {
+		if slice.ContainsString(unsupportedNoMount, nm) {
 			sylog.Warningf("--no-mount %s is not supported in OCI mode, ignoring.", nm)
 		}
-	}
-
-	if lo.NvCCLI {
-		badOpt = append(badOpt, "NvCCLI")
+		// singularity.conf bind path entries are only mounted with --no-compat.
+		if strings.HasPrefix(nm, "/") && !lo.NoCompat && !slice.ContainsString(etcNoMount, nm) {
+			sylog.Warningf("--no-mount %s only applies to singularity.conf bind paths with --no-compat, ignoring.", nm)
+		}
 	}
 
 	if lo.Proot != "" {
 		badOpt = append(badOpt, "Proot")
 	}
 
-	// Network always set in CLI layer even if network namespace not requested.
-	// We only support isolation at present
-	if lo.Namespaces.Net && lo.Network != "none" {
-		badOpt = append(badOpt, "Network (except none)")
+	if slice.ContainsString(lo.SecurityOpts, "landlock") {
+		return fmt.Errorf("--security landlock is not supported in OCI mode")
 	}
-
-	if len(lo.NetworkArgs) > 0 {
-		badOpt = append(badOpt, "NetworkArgs")
-	}
-
-	if len(lo.SecurityOpts) > 0 {
-		badOpt = append(badOpt, "SecurityOpts")
+	// Only seccomp may be selected with --security in OCI mode.
+	for _, opt := range lo.SecurityOpts {
+		if !strings.HasPrefix(opt, "seccomp:") {
+			badOpt = append(badOpt, "SecurityOpts")
+			break
+		}
 	}
 
 	// ConfigFile always set by CLI. We should support only the default from build time.
@@ -182,6 +312,21 @@ func checkOpts(lo launcher.Options) error {
 	if lo.Boot {
 		badOpt = append(badOpt, "Boot")
 	}
+	if lo.WatchHostFiles != "" {
+		badOpt = append(badOpt, "WatchHostFiles")
+	}
+	if lo.Stdio != nil {
+		badOpt = append(badOpt, "Stdio")
+	}
+	if len(lo.InstanceLabels) > 0 {
+		badOpt = append(badOpt, "InstanceLabels")
+	}
+	if lo.CgroupStats {
+		badOpt = append(badOpt, "CgroupStats")
+	}
+	if lo.VirtualProc {
+		badOpt = append(badOpt, "VirtualProc")
+	}
 	if lo.NoInit {
 		badOpt = append(badOpt, "NoInit")
 	}
@@ -192,14 +337,14 @@ func checkOpts(lo launcher.Options) error {
 		badOpt = append(badOpt, "ContainAll")
 	}
 
-	if lo.KeyInfo != nil {
-		badOpt = append(badOpt, "KeyInfo")
-	}
-
 	if lo.SIFFUSE {
 		badOpt = append(badOpt, "SIFFUSE")
 	}
 
+	if lo.OCIUser != "" && lo.Fakeroot {
+		return fmt.Errorf("--oci-user cannot be used with --fakeroot")
+	}
+
 	if len(badOpt) > 0 {
 		return fmt.Errorf("%w: %s", ErrUnsupportedOption, strings.Join(badOpt, ","))
 	}
@@ -255,7 +400,8 @@ func (l *Launcher) createSpec() (spec *specs.Spec, err error) {
 
 	// The OCI mode always wraps the rootfs in a tmpfs.
 	// Whether we  make it writable inside the container depends on a request for `--writable-tmpfs`.
-	// Note that --writable-tmpfs is inferred by default in OCI mode. See NewLauncher().
+	// Note that --writable-tmpfs is inferred by default in OCI mode, unless
+	// --read-only is set. See NewLauncher().
 	spec.Root.Readonly = !l.cfg.WritableTmpfs
 
 	err = addNamespaces(spec, l.cfg.Namespaces)
@@ -296,6 +442,51 @@ func (l *Launcher) createSpec() (spec *specs.Spec, err error) {
 	return spec, nil
 }
 
+// verifyOCISIF checks that the OCI-SIF image at path holds a valid cosign
+// compatible signature for the key set by 'oci-sif verify key' in
+// singularity.conf, if any.
+func (l *Launcher) verifyOCISIF(ctx context.Context, path string) error {
+	keyPath := l.singularityConf.OCISIFVerifyKey
+	if keyPath == "" {
+		return nil
+	}
+
+	sylog.Debugf("Verifying OCI-SIF image %s with key %s", path, keyPath)
+	sv, err := signature.LoadVerifierFromPEMFile(keyPath, crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("while loading 'oci-sif verify key' %s: %w", keyPath, err)
+	}
+	if err := sifsignature.VerifyOCISIF(ctx, path, sv); err != nil {
+		return fmt.Errorf("image %s is not signed by the key required by singularity.conf: %w", path, err)
+	}
+	return nil
+}
+
+// checkECL checks that the SIF or OCI-SIF image at path may be run according
+// to the execution control list, if one is configured.
+func (l *Launcher) checkECL(ctx context.Context, path string) error {
+	ecl, err := syecl.LoadConfig(buildcfg.ECL_FILE)
+	if err != nil || !ecl.Activated {
+		return nil
+	}
+	if err := ecl.ValidateConfig(); err != nil {
+		return fmt.Errorf("while validating ECL configuration: %s", err)
+	}
+
+	keyring := sypgp.NewHandle(buildcfg.SINGULARITY_CONFDIR, sypgp.GlobalHandleOpt())
+	kr, err := keyring.LoadPubKeyring()
+	if err != nil {
+		return fmt.Errorf("while obtaining keyring for ECL: %s", err)
+	}
+
+	if ok, err := ecl.ShouldRun(ctx, path, kr); err != nil {
+		return fmt.Errorf("while checking container image with ECL: %s", err)
+	} else if !ok {
+		return errors.New("image prohibited by ECL")
+	}
+	return nil
+}
+
 // finalizeSpec updates the bundle config, filling in Process config that depends on the image spec.
 func (l *Launcher) finalizeSpec(ctx context.Context, b ocibundle.Bundle, spec *specs.Spec, ep launcher.ExecParams) (err error) {
 	imgSpec := b.ImageSpec()
@@ -318,34 +509,48 @@ func (l *Launcher) finalizeSpec(ctx context.Context, b ocibundle.Bundle, spec *s
 	currentGID := uint32(rootlessGID)
 	targetUID := currentUID
 	targetGID := currentGID
+	var additionalGIDs []uint32
 	containerUser := false
 
-	// If the OCI image config specifies a USER we will:
+	// If the OCI image config specifies a USER, or it is overridden with
+	// --oci-user, we will:
 	//  * When unprivileged - run as that user, via nested subuid/gid mappings (host user -> userns root -> OCI USER)
 	//  * When privileged - directly run as that user, as a host uid/gid.
-	if imgSpec.Config.User != "" {
-		imgUser, err := tools.BundleUser(b.Path(), imgSpec.Config.User)
-		if err != nil {
-			return err
-		}
-		imgUID, err := strconv.ParseUint(imgUser.Uid, 10, 32)
+	userSpec := imgSpec.Config.User
+	if l.cfg.OCIUser != "" {
+		userSpec = l.cfg.OCIUser
+	}
+	if userSpec != "" {
+		execUser, err := tools.BundleExecUser(b.Path(), userSpec)
 		if err != nil {
-			return err
+			return fmt.Errorf("while resolving user %q: %w", userSpec, err)
 		}
-		imgGID, err := strconv.ParseUint(imgUser.Gid, 10, 32)
-		if err != nil {
-			return err
+		targetUID = uint32(execUser.Uid)
+		targetGID = uint32(execUser.Gid)
+		// Supplementary groups of the user in the image /etc/group, which
+		// are only looked up when no group is specified.
+		for _, gid := range execUser.Sgids {
+			if uint32(gid) != targetGID {
+				additionalGIDs = append(additionalGIDs, uint32(gid))
+			}
 		}
-		targetUID = uint32(imgUID)
-		targetGID = uint32(imgGID)
 		containerUser = true
-		sylog.Debugf("Running as USER specified in OCI image config %d:%d", targetUID, targetGID)
+		sylog.Debugf("Running as user %q from OCI image config / --oci-user %d:%d, supplementary groups %v", userSpec, targetUID, targetGID, additionalGIDs)
+
+		// A numeric uid without a group, that is not in the image
+		// /etc/passwd, can't be given its primary group.
+		if uid, err := strconv.Atoi(userSpec); err == nil && uid != 0 {
+			if _, err := tools.BundleUser(b.Path(), userSpec); err != nil {
+				sylog.Warningf("User %s not found in the container /etc/passwd, running with gid 0. Use --oci-user %s:<gid> to select a group.", userSpec, userSpec)
+			}
+		}
 	}
 
 	// Fakeroot always overrides to give us root in the container (via userns & idmap if unprivileged).
 	if l.cfg.Fakeroot {
 		targetUID = 0
 		targetGID = 0
+		additionalGIDs = nil
 	}
 
 	if targetUID != 0 && currentUID != 0 {
@@ -355,6 +560,13 @@ func (l *Launcher) finalizeSpec(ctx context.Context, b ocibundle.Bundle, spec *s
 		}
 		spec.Linux.UIDMappings = uidMap
 		spec.Linux.GIDMappings = gidMap
+		// Supplementary groups outside of the subgid range can't be mapped
+		// in the nested user namespace.
+		var unmapped []uint32
+		additionalGIDs, unmapped = filterMappedIDs(additionalGIDs, gidMap)
+		if len(unmapped) > 0 {
+			sylog.Warningf("Supplementary groups %v are outside of your subgid range, and will not be set in the container", unmapped)
+		}
 		// Must add userns to the runc/crun applied config for the inner reverse uid/gid mapping to work.
 		spec.Linux.Namespaces = append(
 			spec.Linux.Namespaces,
@@ -363,8 +575,9 @@ func (l *Launcher) finalizeSpec(ctx context.Context, b ocibundle.Bundle, spec *s
 	}
 
 	u := specs.User{
-		UID: targetUID,
-		GID: targetGID,
+		UID:            targetUID,
+		GID:            targetGID,
+		AdditionalGids: additionalGIDs,
 	}
 	// In native mode emulation (--no-compat) propagate umask unless --no-umask set
 	if l.cfg.NoCompat && !l.cfg.NoUmask {
@@ -380,20 +593,71 @@ func (l *Launcher) finalizeSpec(ctx context.Context, b ocibundle.Bundle, spec *s
 	}
 	spec.Process = specProcess
 
+	// The AppArmor profile and SELinux label are applied by the OCI runtime.
+	if l.cfg.ApparmorProfile != "" {
+		sylog.Debugf("Applying AppArmor profile %s", l.cfg.ApparmorProfile)
+		spec.Process.ApparmorProfile = l.cfg.ApparmorProfile
+	}
+	if l.cfg.SelinuxLabel != "" {
+		sylog.Debugf("Applying SELinux label %s", l.cfg.SelinuxLabel)
+		spec.Process.SelinuxLabel = l.cfg.SelinuxLabel
+	}
+
+	// The seccomp profile is applied once the process capabilities are known,
+	// as rules in the profile may depend on them. When tracing, the tracer
+	// installs its own profile.
+	if l.cfg.SeccompTrace == "" {
+		if err := l.addSeccompProfile(spec); err != nil {
+			return err
+		}
+	}
+
 	if l.nativeSIF {
 		envMount, err := l.prepareNativeEnv(b.Path(), userEnv)
 		if err != nil {
 			return err
 		}
 		spec.Mounts = append(spec.Mounts, envMount)
+
+		metadataMounts, err := l.prepareMetadataFiles(b.Path())
+		if err != nil {
+			return err
+		}
+		spec.Mounts = append(spec.Mounts, metadataMounts...)
+	}
+
+	devices := l.cfg.Devices
+	if l.nvidiaCDI() {
+		nvDevices, err := l.nvidiaCDIDevices()
+		if err != nil {
+			return err
+		}
+		devices = append(devices, nvDevices...)
 	}
 
 	if len(l.cfg.CdiDirs) > 0 {
-		err = addCDIDevices(spec, l.cfg.Devices, cdi.WithSpecDirs(l.cfg.CdiDirs...))
+		err = addCDIDevices(spec, devices, cdi.WithSpecDirs(l.cfg.CdiDirs...))
 	} else {
-		err = addCDIDevices(spec, l.cfg.Devices)
+		err = addCDIDevices(spec, devices)
 	}
 	if err != nil {
+		if l.nvidiaCDI() {
+			return fmt.Errorf("%w (an NVIDIA CDI spec can be generated with 'nvidia-ctk cdi generate')", err)
+		}
+		return err
+	}
+
+	if l.nvidiaEnabled() {
+		l.addCUDACompat(spec, tools.RootFs(b.Path()).Path())
+	}
+
+	if l.mpi != nil {
+		if err := mpi.CheckABI(os.ReadDir, tools.RootFs(b.Path()).Path(), mpi.Sonames(l.mpi.Libs)); err != nil {
+			return err
+		}
+	}
+
+	if err := l.addVolumeMounts(spec, *imgSpec); err != nil {
 		return err
 	}
 
@@ -404,9 +668,36 @@ func (l *Launcher) finalizeSpec(ctx context.Context, b ocibundle.Bundle, spec *s
 
 	l.handleVarTmpToTmpSymlink(spec)
 
+	// Allow any plugins with callbacks to modify the assembled spec
+	if err := l.runPluginCallbacks(spec); err != nil {
+		return err
+	}
+
+	// Hooks are added once the spec is otherwise complete, as their
+	// conditions may depend on the process, annotations and mounts.
+	if err := l.addHooks(ctx, spec); err != nil {
+		return err
+	}
+
 	return b.Update(ctx, spec)
 }
 
+// runPluginCallbacks executes any plugin callbacks to manipulate the spec passed in
+func (l *Launcher) runPluginCallbacks(spec *specs.Spec) error {
+	callbackType := (launchercallback.OCISpec)(nil)
+	callbacks, err := plugin.LoadCallbacks(callbackType)
+	if err != nil {
+		return fmt.Errorf("while loading plugin callbacks '%T': %w", callbackType, err)
+	}
+	for _, c := range callbacks {
+		//nolint:forcetypeassert
+		if err := c.(launchercallback.OCISpec)(l.image, spec); err != nil {
+			return fmt.Errorf("plugin rejected container configuration: %w", err)
+		}
+	}
+	return nil
+}
+
 func (l *Launcher) handleVarTmpToTmpSymlink(spec *specs.Spec) {
 	tmpResolved := fs.EvalRelative(tmpPath, spec.Root.Path)
 	vartmpResolved := fs.EvalRelative(vartmpPath, spec.Root.Path)
@@ -449,6 +740,14 @@ func (l *Launcher) prepareEtc(b ocibundle.Bundle, spec *specs.Spec, containerUse
 		spec.Mounts = append(spec.Mounts, *resolvMount)
 	}
 
+	hostsMount, err := l.prepareHosts(b.Path())
+	if err != nil {
+		return err
+	}
+	if hostsMount != nil {
+		spec.Mounts = append(spec.Mounts, *hostsMount)
+	}
+
 	// If the container specifies a USER, we do not create a customized
 	// /etc/passwd|group. All we do is test for a conflicting --home option (in
 	// which case, we issue an error) and return
@@ -492,6 +791,11 @@ func (l *Launcher) preparePasswd(bundlePath string, uid uint32) (*specs.Mount, e
 		return nil, nil
 	}
 
+	if slice.ContainsString(l.cfg.NoMount, "/etc/passwd") {
+		sylog.Debugf("Skipping mount of /etc/passwd due to --no-mount")
+		return nil, nil
+	}
+
 	sylog.Debugf("Creating container passwd file: %s", containerPasswd)
 	content, err := files.Passwd(rootfsPasswd, l.homeDest, int(uid), nil)
 	if err != nil {
@@ -525,6 +829,11 @@ func (l *Launcher) prepareGroup(bundlePath string, uid, gid uint32) (*specs.Moun
 		return nil, nil
 	}
 
+	if slice.ContainsString(l.cfg.NoMount, "/etc/group") {
+		sylog.Debugf("Skipping mount of /etc/group due to --no-mount")
+		return nil, nil
+	}
+
 	sylog.Debugf("Creating container group file: %s", containerGroup)
 	content, err := files.Group(rootfsGroup, int(uid), []int{int(gid)}, nil)
 	if err != nil {
@@ -580,6 +889,14 @@ func (l *Launcher) prepareResolvConf(bundlePath string) (*specs.Mount, error) {
 		}
 	}
 
+	if len(l.cfg.DNSSearch) > 0 {
+		search := strings.Replace(l.cfg.DNSSearch, " ", "", -1)
+		resolvConfData, err = files.ResolvConfSearch(resolvConfData, strings.Split(search, ","))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if err := os.WriteFile(containerResolvConfPath, resolvConfData, 0o755); err != nil {
 		return nil, fmt.Errorf("while writing container's resolv.conf file: %v", err)
 	}
@@ -594,6 +911,49 @@ func (l *Launcher) prepareResolvConf(bundlePath string) (*specs.Mount, error) {
 	return &resolvMount, nil
 }
 
+// prepareHosts creates `/etc/hosts` in the bundle, if custom host entries were
+// requested with --add-host. An appropriate bind mount to bind over the
+// pristine rootfs `/etc/hosts` is returned on success.
+func (l *Launcher) prepareHosts(bundlePath string) (*specs.Mount, error) {
+	if len(l.cfg.AddHosts) == 0 {
+		return nil, nil
+	}
+
+	containerHostsPath := filepath.Join(bundlePath, "etc", "hosts")
+
+	// In native emulation mode (--no-compat) the host's /etc/hosts is the
+	// starting point, as it would be bound in from singularity.conf. Otherwise
+	// start from the image's own /etc/hosts, or a minimal default.
+	baseHostsPath := filepath.Join(tools.RootFs(bundlePath).Path(), "etc", "hosts")
+	if l.cfg.NoCompat {
+		baseHostsPath = "/etc/hosts"
+	}
+	hostsData, err := os.ReadFile(baseHostsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		hostsData = files.DefaultHosts()
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read hosts file %s: %w", baseHostsPath, err)
+	}
+
+	hostsData, err = files.Hosts(hostsData, l.cfg.AddHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(containerHostsPath, hostsData, 0o644); err != nil {
+		return nil, fmt.Errorf("while writing container's hosts file: %v", err)
+	}
+
+	hostsMount := specs.Mount{
+		Source:      containerHostsPath,
+		Destination: "/etc/hosts",
+		Type:        "bind",
+		Options:     []string{"bind"},
+	}
+
+	return &hostsMount, nil
+}
+
 // prepareNativeEnv creates a file to inject user specified (SINGULARITYENV_ /
 // --env / --env-file) environment variables into a native SIF container. We
 // need to do this so that they can override any values set when a native SIF
@@ -607,7 +967,15 @@ func (l *Launcher) prepareNativeEnv(bundlePath string, userEnv map[string]string
 
 	b := bytes.Buffer{}
 
-	// First, we export any user-set env vars, so they always override anything set in the container image.
+	// First, we export variables set by metadata edits of the image, without
+	// evaluation, so they override those set by earlier environment scripts.
+	if l.metadataEdits != nil {
+		for key, value := range l.metadataEdits.Env {
+			b.WriteString(fmt.Sprintf("export %s='%s'\n", key, shell.EscapeSingleQuotes(value)))
+		}
+	}
+
+	// Then, we export any user-set env vars, so they always override anything set in the container image.
 
 	// Don't export these, as they are handled by other env scripts
 	skipVars := map[string]bool{
@@ -630,16 +998,17 @@ func (l *Launcher) prepareNativeEnv(bundlePath string, userEnv map[string]string
 		b.WriteString(fmt.Sprintf("%s=%s\n", key, value))
 	}
 
-	// Second, we conditionally restore host env vars, if we are --no-compat and
-	// if they are not set already.
+	// Last, we conditionally restore host env vars, if we are --no-compat and
+	// if they are not set already. None are restored with --no-env.
 	hostEnvSnippet := `
 if [ ! "${%[1]s+1}" ]; then
 	export %[1]s=%[2]s
 fi
 	`
-	cleanEnv := !l.cfg.NoCompat || l.cfg.CleanEnv
-	for k, v := range env.HostEnvMap(os.Environ(), cleanEnv) {
-		b.WriteString(fmt.Sprintf(hostEnvSnippet, k, "'"+shell.EscapeSingleQuotes(v)+"'"))
+	if !l.cfg.NoEnv {
+		for k, v := range l.hostEnvMap(os.Environ()) {
+			b.WriteString(fmt.Sprintf(hostEnvSnippet, k, "'"+shell.EscapeSingleQuotes(v)+"'"))
+		}
 	}
 
 	if err := os.WriteFile(hostEnvPath, b.Bytes(), 0o755); err != nil {
@@ -656,6 +1025,30 @@ fi
 	return envMount, nil
 }
 
+// prepareMetadataFiles writes the container metadata files replaced by
+// metadata edits of a native SIF image into the bundle, and returns the
+// mounts binding them over those of the image.
+func (l *Launcher) prepareMetadataFiles(bundlePath string) ([]specs.Mount, error) {
+	if l.metadataEdits == nil {
+		return nil, nil
+	}
+
+	mounts := make([]specs.Mount, 0, len(l.metadataEdits.Files))
+	for dst, content := range l.metadataEdits.Files {
+		src := filepath.Join(bundlePath, "metadata-"+filepath.Base(dst))
+		if err := os.WriteFile(src, content, 0o644); err != nil {
+			return nil, fmt.Errorf("while writing container's %s file: %v", dst, err)
+		}
+		mounts = append(mounts, specs.Mount{
+			Source:      src,
+			Destination: dst,
+			Type:        "bind",
+			Options:     []string{"bind", "ro", "nosuid", "nodev"},
+		})
+	}
+	return mounts, nil
+}
+
 // Exec will interactively execute a container via the runc low-level runtime.
 // image is a reference to an OCI image, e.g. docker://ubuntu or oci:/tmp/mycontainer
 func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
@@ -711,12 +1104,30 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	var b ocibundle.Bundle
 	switch {
 	case strings.HasPrefix(image, "oci-sif:"):
+		if isData, err := ocisifclient.IsDataContainer(strings.TrimPrefix(image, "oci-sif:")); err == nil && isData {
+			return fmt.Errorf("%s is a data container, which can't be run; mount it into a container with --data", strings.TrimPrefix(image, "oci-sif:"))
+		}
+		if err := l.verifyOCISIF(ctx, strings.TrimPrefix(image, "oci-sif:")); err != nil {
+			return err
+		}
+		if err := l.checkECL(ctx, strings.TrimPrefix(image, "oci-sif:")); err != nil {
+			return err
+		}
 		b, err = ocisif.New(
 			ocisif.OptBundlePath(bundleDir),
 			ocisif.OptImageRef(image),
+			ocisif.OptKeyInfo(l.cfg.KeyInfo),
+			ocisif.OptKernelMount(l.singularityConf.OCISIFKernelMount),
 		)
 	case strings.HasPrefix(image, "sif:"):
 		sylog.Infof("Running a non-OCI SIF in OCI mode. See user guide for compatibility information.")
+		if err := l.checkECL(ctx, strings.TrimPrefix(image, "sif:")); err != nil {
+			return err
+		}
+		l.metadataEdits, err = metadata.RuntimeEdits(strings.TrimPrefix(image, "sif:"))
+		if err != nil {
+			return fmt.Errorf("while reading metadata of image %s: %w", strings.TrimPrefix(image, "sif:"), err)
+		}
 		b, err = sifbundle.FromSif(
 			strings.TrimPrefix(image, "sif:"),
 			bundleDir,
@@ -731,6 +1142,15 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		if !canUseTmpSandbox {
 			return fmt.Errorf("unpacking image to temporary sandbox dir required, but is prohibited by 'tmp sandbox = no' in singularity.conf or --no-tmp-sandbox command-line flag")
 		}
+		if l.cfg.Lazy && strings.HasPrefix(image, "docker:") {
+			b, err = lazy.New(
+				lazy.OptBundlePath(bundleDir),
+				lazy.OptImageRef(image),
+				lazy.OptTransportOptions(l.cfg.TransportOptions),
+				lazy.OptTmpDir(l.cfg.TransportOptions.TmpDir),
+			)
+			break
+		}
 		b, err = native.New(
 			native.OptBundlePath(bundleDir),
 			native.OptImageRef(image),
@@ -741,7 +1161,26 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	if err != nil {
 		return err
 	}
-	if err := b.Create(ctx, spec); err != nil {
+	err = b.Create(ctx, spec)
+	if errors.Is(err, lazy.ErrUnsupported) {
+		// Images which are not in eStargz format are pulled in full.
+		sylog.Warningf("%v, pulling the full image", err)
+		b, err = native.New(
+			native.OptBundlePath(bundleDir),
+			native.OptImageRef(image),
+			native.OptTransportOptions(l.cfg.TransportOptions),
+			native.OptImgCache(imgCache),
+		)
+		if err != nil {
+			return err
+		}
+		err = b.Create(ctx, spec)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := l.checkLicense(image, b); err != nil {
 		return err
 	}
 
@@ -755,9 +1194,95 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		return fmt.Errorf("while generating container id: %w", err)
 	}
 
+	// Add any CNI networks to a network namespace that the container will join.
+	netCleanup, err := l.prepareNetwork(ctx, b.Path(), id.String(), spec)
+	if err != nil {
+		return err
+	}
+	// The networks are removed on any return, but also explicitly before the
+	// bundle holding the network namespace is deleted, as Exec exits the
+	// process on completion, without running deferred calls.
+	cleanupNetwork := func() {
+		if netCleanup == nil {
+			return
+		}
+		if cleanupErr := netCleanup(context.Background()); cleanupErr != nil { //nolint:contextcheck
+			sylog.Errorf("Couldn't cleanup network: %v", cleanupErr)
+		}
+		netCleanup = nil
+	}
+	defer cleanupNetwork()
+	if netCleanup != nil {
+		if err := b.Update(ctx, spec); err != nil {
+			return err
+		}
+	}
+
+	// Trace the syscalls made by the container, with a profile that notifies
+	// the tracer of each syscall via the runtime's seccomp listener support.
+	var tracer *seccomp.Tracer
+	if l.cfg.SeccompTrace != "" {
+		tracer, err = seccomp.NewTracer(filepath.Join(bundleDir, "seccomp.sock"))
+		if err != nil {
+			return err
+		}
+		if spec.Linux.Seccomp, err = tracer.Profile(); err != nil {
+			tracer.Stop()
+			return err
+		}
+		if err := b.Update(ctx, spec); err != nil {
+			tracer.Stop()
+			return err
+		}
+		sylog.Infof("Tracing syscalls made by the container, which will run slowly")
+	}
+
+	// Record the image files opened by the container.
+	var profiler *ioprofile.Profiler
+	if l.cfg.ProfileIO != "" {
+		profiler, err = ioprofile.New(tools.RootFs(b.Path()).Path())
+		if err != nil {
+			if tracer != nil {
+				tracer.Stop()
+			}
+			return err
+		}
+		sylog.Infof("Recording image files opened by the container")
+	}
+
+	start := time.Now()
+
 	// Execution of runc/crun run, wrapped with overlay prep / cleanup.
 	err = l.RunWrapped(ctx, id.String(), b.Path(), "")
 
+	if l.cfg.CoreDir != "" {
+		l.collectCoreDump(spec, start, err)
+	}
+
+	if tracer != nil {
+		if traceErr := tracer.Stop(); traceErr != nil {
+			sylog.Errorf("While tracing syscalls: %v", traceErr)
+		}
+		if traceErr := tracer.WriteProfile(l.cfg.SeccompTrace); traceErr != nil {
+			sylog.Errorf("Couldn't write seccomp profile: %v", traceErr)
+		} else {
+			sylog.Infof("Seccomp profile written to %s", l.cfg.SeccompTrace)
+		}
+	}
+
+	if profiler != nil {
+		if profileErr := profiler.Stop(); profileErr != nil {
+			sylog.Errorf("While recording image files: %v", profileErr)
+		}
+		if profileErr := profiler.WriteProfile(l.cfg.ProfileIO, profileImage(l.image)); profileErr != nil {
+			sylog.Errorf("Couldn't write I/O profile: %v", profileErr)
+		} else {
+			sylog.Infof("I/O profile written to %s", l.cfg.ProfileIO)
+		}
+	}
+
+	cleanupNetwork()
+
 	// Unmounts pristine rootfs from bundle, and removes the bundle. We want to
 	// make a best effort here even if the main context has been canceled, hence
 	// the use of context.Background().
@@ -769,6 +1294,11 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 		sylog.Errorf("Couldn't unmount session directory: %v", err)
 	}
 
+	// Flush traces before exiting with the exit code of the container.
+	if traceErr := tracing.Shutdown(); traceErr != nil {
+		sylog.Debugf("While flushing traces: %v", traceErr)
+	}
+
 	if e, ok := err.(*exec.ExitError); ok {
 		status, ok := e.Sys().(syscall.WaitStatus)
 		if ok && status.Signaled() {
@@ -782,6 +1312,26 @@ func (l *Launcher) Exec(ctx context.Context, ep launcher.ExecParams) error {
 	return err
 }
 
+// checkLicense applies the 'image license groups' policy of singularity.conf
+// to the license of image, from which the bundle b was created. The license of
+// a SIF or OCI-SIF image is read from the image file, and that of other images
+// from the image config labels.
+func (l *Launcher) checkLicense(image string, b ocibundle.Bundle) error {
+	var expr string
+	switch {
+	case strings.HasPrefix(image, "oci-sif:"), strings.HasPrefix(image, "sif:"):
+		_, path, _ := strings.Cut(image, ":")
+		e, err := license.FromImage(path)
+		if err != nil {
+			return fmt.Errorf("while reading license of image %s: %w", path, err)
+		}
+		expr = e
+	case b.ImageSpec() != nil:
+		expr = license.FromLabels(b.ImageSpec().Config.Labels)
+	}
+	return license.Enforce(expr, image, l.cfg.LicenseOverride)
+}
+
 // RunWrapped runs a container via the OCI runtime, wrapped with prep / cleanup steps.
 func (l *Launcher) RunWrapped(ctx context.Context, containerID, bundlePath, pidFile string) error {
 	absBundle, err := filepath.Abs(bundlePath)
@@ -794,13 +1344,20 @@ func (l *Launcher) RunWrapped(ctx context.Context, containerID, bundlePath, pidF
 	}
 
 	runFunc := func() error {
-		for _, im := range l.imageMountsByMountpoint {
+		// Mountpoints are sorted, so that a mountpoint nested in another one is
+		// mounted after it.
+		mountpoints := lo.Keys(l.imageMountsByMountpoint)
+		sort.Strings(mountpoints)
+		tasks := make([]fsmount.Task, 0, len(mountpoints))
+		for _, mp := range mountpoints {
+			im := l.imageMountsByMountpoint[mp]
 			if err := os.MkdirAll(im.GetMountPoint(), 0o755); err != nil {
 				return err
 			}
-			if err := im.Mount(ctx); err != nil {
-				return err
-			}
+			tasks = append(tasks, fsmount.Task{Target: im.GetMountPoint(), Mount: im.Mount})
+		}
+		if _, err := fsmount.MountParallel(ctx, tasks, fsmount.ParallelWorkers()); err != nil {
+			return err
 		}
 
 		// On cgroups v1 rootless, it's not possible to use systemd to manage cgroups.
@@ -814,7 +1371,9 @@ func (l *Launcher) RunWrapped(ctx context.Context, containerID, bundlePath, pidF
 			systemdCgroups = false
 		}
 
-		err = Run(ctx, containerID, absBundle, pidFile, systemdCgroups)
+		runCtx, span := tracing.Start(ctx, "start container", attribute.String("container.id", containerID))
+		err = Run(runCtx, containerID, absBundle, pidFile, systemdCgroups)
+		tracing.End(span, err)
 
 		for _, im := range l.imageMountsByMountpoint {
 			im.Unmount(ctx)
@@ -824,10 +1383,10 @@ func (l *Launcher) RunWrapped(ctx context.Context, containerID, bundlePath, pidF
 	}
 
 	if len(l.cfg.OverlayPaths) > 0 {
-		return WrapWithOverlays(ctx, runFunc, absBundle, l.cfg.OverlayPaths, l.cfg.AllowSUID)
+		return WrapWithOverlays(ctx, runFunc, absBundle, l.cfg.OverlayPaths, l.cfg.OverlayPassfile, l.tmpfsSize("--writable-tmpfs-size", l.cfg.WritableTmpfsSize), l.cfg.AllowSUID)
 	}
 
-	return WrapWithWritableTmpFs(ctx, runFunc, absBundle, l.cfg.AllowSUID)
+	return WrapWithWritableTmpFs(ctx, runFunc, absBundle, l.tmpfsSize("--writable-tmpfs-size", l.cfg.WritableTmpfsSize), l.cfg.AllowSUID)
 }
 
 // getCgroup will return a cgroup path and resources for the runtime to create.
@@ -835,14 +1394,35 @@ func (l *Launcher) getCgroup() (path string, resources *specs.LinuxResources, er
 	if l.cfg.CGroupsJSON == "" {
 		return "", nil, nil
 	}
+	if job := l.batchJob(); job != nil {
+		sylog.Warningf("Ignoring requested cgroup resource limits, the limits of %s job %s apply", job.Scheduler, job.ID)
+		return "", nil, nil
+	}
 	path = cgroups.DefaultPathForPid(l.singularityConf.SystemdCgroups, -1)
 	resources, err = cgroups.UnmarshalJSONResources(l.cfg.CGroupsJSON)
 	if err != nil {
 		return "", nil, err
 	}
+	if err := cgroups.CheckResources(resources, l.singularityConf.SystemdCgroups); err != nil {
+		return "", nil, err
+	}
 	return path, resources, nil
 }
 
+// batchJob returns the batch scheduler job whose cgroup the container must stay
+// in, as set by 'batch job cgroup' in singularity.conf, or nil if there is none.
+func (l *Launcher) batchJob() *cgroups.BatchJob {
+	if !l.singularityConf.BatchJobCgroup {
+		return nil
+	}
+	job, err := cgroups.DetectBatchJob(os.Getpid())
+	if err != nil {
+		sylog.Warningf("While detecting batch job cgroup: %v", err)
+		return nil
+	}
+	return job
+}
+
 // mountSessionTmpfs mounts a tmpfs onto buildcfg.SESSIONDIR
 func (l *Launcher) mountSessionTmpfs() error {
 	sylog.Debugf("Mounting %d MiB tmpfs to %s", l.singularityConf.SessiondirMaxSize, buildcfg.SESSIONDIR)
@@ -906,6 +1486,21 @@ func CrunNestCgroup() error {
 	return nil
 }
 
+// profileImage returns image, as recorded in an I/O profile, with the path of
+// a local SIF or OCI-SIF image made absolute, so that the profile can be
+// matched against the image it is later applied to.
+func profileImage(image string) string {
+	for _, prefix := range []string{"oci-sif:", "sif:"} {
+		if p, ok := strings.CutPrefix(image, prefix); ok {
+			if abs, err := filepath.Abs(p); err == nil {
+				return prefix + abs
+			}
+			break
+		}
+	}
+	return image
+}
+
 // normalizeImageRef transforms a bare image path to an oci-sif: or sif: prefixed path,
 // after checking the image is an oci-sif or native (non-oci) sif.
 func normalizeImageRef(imageRef string) (string, error) {
@@ -40,7 +40,7 @@ func TestNewLauncher(t *testing.T) {
 		{
 			name: "default",
 			want: &Launcher{
-				cfg:                     launcher.Options{WritableTmpfs: true},
+				cfg:                     launcher.Options{WritableTmpfs: true, HomeMode: "ephemeral"},
 				singularityConf:         sc,
 				homeHost:                u.HomeDir,
 				homeSrc:                 "",
@@ -55,7 +55,7 @@ func TestNewLauncher(t *testing.T) {
 				launcher.OptHome("/home/dest", true, false),
 			},
 			want: &Launcher{
-				cfg:                     launcher.Options{HomeDir: "/home/dest", CustomHome: true, WritableTmpfs: true},
+				cfg:                     launcher.Options{HomeDir: "/home/dest", CustomHome: true, WritableTmpfs: true, HomeMode: "ephemeral"},
 				singularityConf:         sc,
 				homeHost:                u.HomeDir,
 				homeSrc:                 "",
@@ -71,7 +71,7 @@ func TestNewLauncher(t *testing.T) {
 				launcher.OptHome("/home/src:/home/dest", true, false),
 			},
 			want: &Launcher{
-				cfg:                     launcher.Options{HomeDir: "/home/src:/home/dest", CustomHome: true, WritableTmpfs: true},
+				cfg:                     launcher.Options{HomeDir: "/home/src:/home/dest", CustomHome: true, WritableTmpfs: true, HomeMode: "host"},
 				singularityConf:         sc,
 				homeHost:                u.HomeDir,
 				homeSrc:                 "/home/src",
@@ -87,7 +87,7 @@ func TestNewLauncher(t *testing.T) {
 				launcher.OptNoCompat(true),
 			},
 			want: &Launcher{
-				cfg:                     launcher.Options{NoCompat: true, WritableTmpfs: false},
+				cfg:                     launcher.Options{NoCompat: true, WritableTmpfs: false, HomeMode: "host"},
 				singularityConf:         sc,
 				homeHost:                u.HomeDir,
 				homeSrc:                 "",
@@ -104,7 +104,88 @@ func TestNewLauncher(t *testing.T) {
 				launcher.OptWritableTmpfs(true),
 			},
 			want: &Launcher{
-				cfg:                     launcher.Options{NoCompat: true, WritableTmpfs: true},
+				cfg:                     launcher.Options{NoCompat: true, WritableTmpfs: true, HomeMode: "host"},
+				singularityConf:         sc,
+				homeHost:                u.HomeDir,
+				homeSrc:                 "",
+				homeDest:                u.HomeDir,
+				imageMountsByImagePath:  make(map[string]*fuse.ImageMount),
+				imageMountsByMountpoint: make(map[string]*fuse.ImageMount),
+			},
+			wantErr: false,
+		},
+		{
+			name: "homeModeHost",
+			opts: []launcher.Option{
+				launcher.OptHomeMode("host"),
+			},
+			want: &Launcher{
+				cfg:                     launcher.Options{WritableTmpfs: true, HomeMode: "host"},
+				singularityConf:         sc,
+				homeHost:                u.HomeDir,
+				homeSrc:                 "",
+				homeDest:                u.HomeDir,
+				imageMountsByImagePath:  make(map[string]*fuse.ImageMount),
+				imageMountsByMountpoint: make(map[string]*fuse.ImageMount),
+			},
+			wantErr: false,
+		},
+		{
+			name: "homeModeNone",
+			opts: []launcher.Option{
+				launcher.OptHomeMode("none"),
+			},
+			want: &Launcher{
+				cfg:                     launcher.Options{WritableTmpfs: true, HomeMode: "none", NoHome: true},
+				singularityConf:         sc,
+				homeHost:                u.HomeDir,
+				homeSrc:                 "",
+				homeDest:                u.HomeDir,
+				imageMountsByImagePath:  make(map[string]*fuse.ImageMount),
+				imageMountsByMountpoint: make(map[string]*fuse.ImageMount),
+			},
+			wantErr: false,
+		},
+		{
+			name: "homeModeImage",
+			opts: []launcher.Option{
+				launcher.OptHomeMode("image"),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "read-only",
+			opts: []launcher.Option{
+				launcher.OptReadOnly(true),
+			},
+			want: &Launcher{
+				cfg:                     launcher.Options{ReadOnly: true, WritableTmpfs: false, HomeMode: "ephemeral"},
+				singularityConf:         sc,
+				homeHost:                u.HomeDir,
+				homeSrc:                 "",
+				homeDest:                u.HomeDir,
+				imageMountsByImagePath:  make(map[string]*fuse.ImageMount),
+				imageMountsByMountpoint: make(map[string]*fuse.ImageMount),
+			},
+			wantErr: false,
+		},
+		{
+			name: "read-only_writable-tmpfs",
+			opts: []launcher.Option{
+				launcher.OptReadOnly(true),
+				launcher.OptWritableTmpfs(true),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "securitySeccomp",
+			opts: []launcher.Option{
+				launcher.OptSecurity([]string{"seccomp:unconfined"}),
+			},
+			want: &Launcher{
+				cfg:                     launcher.Options{SecurityOpts: []string{"seccomp:unconfined"}, SeccompProfile: "unconfined", WritableTmpfs: true, HomeMode: "ephemeral"},
 				singularityConf:         sc,
 				homeHost:                u.HomeDir,
 				homeSrc:                 "",
@@ -117,11 +198,47 @@ func TestNewLauncher(t *testing.T) {
 		{
 			name: "unsupportedOption",
 			opts: []launcher.Option{
+				launcher.OptSecurity([]string{"seccomp:example.json", "apparmor:unconfined"}),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "seccompProfileAndSecurity",
+			opts: []launcher.Option{
+				launcher.OptSeccompProfile("profile.json"),
 				launcher.OptSecurity([]string{"seccomp:example.json"}),
 			},
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "seccompProfileAndTrace",
+			opts: []launcher.Option{
+				launcher.OptSeccompProfile("profile.json"),
+				launcher.OptSeccompTrace("trace.json"),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "apparmorProfileAndSelinuxLabel",
+			opts: []launcher.Option{
+				launcher.OptApparmorProfile("unconfined"),
+				launcher.OptSelinuxLabel("system_u:system_r:container_t:s0"),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "ociUserAndFakeroot",
+			opts: []launcher.Option{
+				launcher.OptOCIUser("1000:1000"),
+				launcher.OptFakeroot(true),
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
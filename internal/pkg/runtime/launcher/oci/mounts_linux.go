@@ -13,11 +13,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/samber/lo"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
@@ -26,6 +29,7 @@ import (
 	"github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	"github.com/sylabs/singularity/v4/pkg/util/bind"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
 	"github.com/sylabs/singularity/v4/pkg/util/slice"
 )
 
@@ -65,6 +69,10 @@ func (l *Launcher) getMounts() ([]specs.Mount, error) {
 	if err := l.addScratchMounts(mounts); err != nil {
 		return nil, fmt.Errorf("while configuring scratch mount(s): %w", err)
 	}
+	// Required binds (singularity.conf) are always added, unlike system bind path mounts
+	if err := l.addRequiredBindMounts(mounts); err != nil {
+		return nil, fmt.Errorf("while configuring required bind mount(s): %w", err)
+	}
 	// System bind path mounts (singularity.conf) are only added with --no-compat (native emulation)
 	if l.cfg.NoCompat {
 		if err := l.addSystemBindMounts(mounts); err != nil {
@@ -74,6 +82,12 @@ func (l *Launcher) getMounts() ([]specs.Mount, error) {
 	if err := l.addUserBindMounts(mounts); err != nil {
 		return nil, fmt.Errorf("while configuring user bind mount(s): %w", err)
 	}
+	if err := l.addDataMounts(mounts); err != nil {
+		return nil, fmt.Errorf("while configuring data container mount(s): %w", err)
+	}
+	if err := l.addCVMFSMounts(mounts); err != nil {
+		return nil, fmt.Errorf("while configuring CVMFS mount(s): %w", err)
+	}
 	if l.cfg.NoCompat {
 		if err := l.addCwdMount(mounts); err != nil {
 			return nil, fmt.Errorf("while configuring cwd mount: %w", err)
@@ -84,7 +98,22 @@ func (l *Launcher) getMounts() ([]specs.Mount, error) {
 			return nil, fmt.Errorf("while configuring ROCm mount(s): %w", err)
 		}
 	}
-	if (l.cfg.Nvidia || l.singularityConf.AlwaysUseNv) && !l.cfg.NoNvidia {
+	if l.cfg.Intel {
+		if err := l.addIntelMounts(mounts); err != nil {
+			return nil, fmt.Errorf("while configuring Intel GPU mount(s): %w", err)
+		}
+	}
+	if l.cfg.Rdma {
+		if err := l.addRdmaMounts(mounts); err != nil {
+			return nil, fmt.Errorf("while configuring RDMA mount(s): %w", err)
+		}
+	}
+	if l.cfg.MPI != "" {
+		if err := l.addMPIMounts(mounts); err != nil {
+			return nil, fmt.Errorf("while configuring MPI mount(s): %w", err)
+		}
+	}
+	if l.nvidiaEnabled() && !l.nvidiaCDI() {
 		if err := l.addNvidiaMounts(mounts); err != nil {
 			return nil, fmt.Errorf("while configuring Nvidia mount(s): %w", err)
 		}
@@ -175,7 +204,7 @@ func (l *Launcher) addTmpMounts(mounts *[]specs.Mount) error {
 				"nosuid",
 				"relatime",
 				"mode=777",
-				fmt.Sprintf("size=%dm", l.singularityConf.SessiondirMaxSize),
+				fmt.Sprintf("size=%dm", l.tmpfsSize("--tmp-size", l.cfg.TmpSize)),
 			},
 		},
 		specs.Mount{
@@ -186,7 +215,7 @@ func (l *Launcher) addTmpMounts(mounts *[]specs.Mount) error {
 				"nosuid",
 				"relatime",
 				"mode=777",
-				fmt.Sprintf("size=%dm", l.singularityConf.SessiondirMaxSize),
+				fmt.Sprintf("size=%dm", l.tmpfsSize("--tmp-size", l.cfg.TmpSize)),
 			},
 		},
 	)
@@ -194,6 +223,24 @@ func (l *Launcher) addTmpMounts(mounts *[]specs.Mount) error {
 	return nil
 }
 
+// tmpfsSize returns size, in MiB, of a tmpfs set with option, or the
+// sessiondir max size if size is 0. For unprivileged users, size is clamped
+// to the sessiondir max size set by the administrator.
+func (l *Launcher) tmpfsSize(option string, size uint) uint {
+	maxSize := l.singularityConf.SessiondirMaxSize
+	if size == 0 {
+		return maxSize
+	}
+	if uid, err := rootless.Getuid(); err == nil && uid == 0 {
+		return size
+	}
+	if maxSize > 0 && size > maxSize {
+		sylog.Warningf("%s of %d MiB exceeds the 'sessiondir max size' of %d MiB, using %d MiB", option, size, maxSize, maxSize)
+		return maxSize
+	}
+	return size
+}
+
 // addTmpBinds adds tmpfs bind mounts from /tmp and /var/tmp on the host, into the container.
 func (l *Launcher) addTmpBinds(mounts *[]specs.Mount) error {
 	err := l.addBindMount(mounts,
@@ -261,7 +308,7 @@ func (l *Launcher) addDevMounts(mounts *[]specs.Mount) error {
 				"noexec",
 				"nodev",
 				"mode=1777",
-				fmt.Sprintf("size=%dm", l.singularityConf.SessiondirMaxSize),
+				fmt.Sprintf("size=%dm", l.tmpfsSize("--shm-size", l.cfg.ShmSize)),
 			},
 		},
 		specs.Mount{
@@ -414,8 +461,9 @@ func (l *Launcher) addHomeMount(mounts *[]specs.Mount) error {
 		return fmt.Errorf("cannot add home mount with empty destination")
 	}
 
-	// In --no-compat we bind $HOME from host like native mode default.
-	if l.cfg.NoCompat && l.homeSrc == "" {
+	// In --no-compat, or with --home-mode host, we bind $HOME from host like
+	// native mode default.
+	if l.cfg.HomeMode == launcher.HomeModeHost && l.homeSrc == "" {
 		l.homeSrc = l.homeHost
 	}
 
@@ -536,30 +584,92 @@ func (l *Launcher) addSystemBindMounts(mounts *[]specs.Mount) error {
 		return nil
 	}
 
-	binds, err := bind.ParseBindPath(strings.Join(l.singularityConf.BindPath, ","))
+	bindPaths, err := expandBindPaths(l.singularityConf.BindPath)
+	if err != nil {
+		return err
+	}
+	binds, err := bind.ParseBindPath(strings.Join(bindPaths, ","))
 	if err != nil {
 		return fmt.Errorf("while parsing singularity.conf bind path: %w", err)
 	}
+	confBinds := len(binds)
 	// Now add binds from one or more --mount and env var.
-	for _, m := range l.cfg.Mounts {
-		bps, err := bind.ParseMountString(m)
-		if err != nil {
-			return fmt.Errorf("while parsing mount %q: %w", m, err)
-		}
-		binds = append(binds, bps...)
+	bps, err := launcher.ParseMounts(l.cfg.Mounts)
+	if err != nil {
+		return err
 	}
+	binds = append(binds, bps...)
 
-	for _, b := range binds {
+	for i, b := range binds {
 		if slice.ContainsString(l.cfg.NoMount, b.Destination) {
 			continue
 		}
 		if err := l.addBindMount(mounts, b, l.cfg.AllowSUID); err != nil {
-			return fmt.Errorf("while adding mount %q: %w", b.Source, err)
+			if i < confBinds {
+				return fmt.Errorf("while adding mount %q, requested by 'bind path' in singularity.conf (disable with --no-mount %s): %w", b.Source, b.Destination, err)
+			}
+			return fmt.Errorf("while adding mount %q, requested by --mount: %w", b.Source, err)
+		}
+	}
+	return nil
+}
+
+// addRequiredBindMounts adds the 'required binds' of singularity.conf, failing
+// if any of them is not available on the host.
+func (l *Launcher) addRequiredBindMounts(mounts *[]specs.Mount) error {
+	if len(l.singularityConf.RequiredBinds) == 0 {
+		return nil
+	}
+
+	bindPaths, err := expandBindPaths(l.singularityConf.RequiredBinds)
+	if err != nil {
+		return err
+	}
+	if err := singularityconf.CheckRequiredBinds(bindPaths); err != nil {
+		return err
+	}
+	binds, err := bind.ParseBindPath(strings.Join(bindPaths, ","))
+	if err != nil {
+		return fmt.Errorf("while parsing singularity.conf required binds: %w", err)
+	}
+
+	for _, b := range binds {
+		if err := l.addBindMount(mounts, b, l.cfg.AllowSUID); err != nil {
+			return fmt.Errorf("while adding mount %q, requested by 'required binds' in singularity.conf: %w", b.Source, err)
 		}
 	}
 	return nil
 }
 
+// expandBindPaths expands the templates of singularity.conf bind paths for the
+// host user running the container, and their primary group, as in native mode.
+func expandBindPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return paths, nil
+	}
+
+	uid, err := rootless.Getuid()
+	if err != nil {
+		return nil, fmt.Errorf("while fetching uid: %w", err)
+	}
+	pw, err := user.GetPwUID(uint32(uid))
+	if err != nil {
+		return nil, fmt.Errorf("while looking up user %d: %w", uid, err)
+	}
+	gr, err := user.GetGrGID(pw.GID)
+	if err != nil {
+		return nil, fmt.Errorf("while looking up group %d: %w", pw.GID, err)
+	}
+
+	u := singularityconf.BindPathUser{
+		Username: pw.Name,
+		UID:      uid,
+		Group:    gr.Name,
+		GID:      int(pw.GID),
+	}
+	return singularityconf.ExpandBindPaths(paths, u), nil
+}
+
 func (l *Launcher) addUserBindMounts(mounts *[]specs.Mount) error {
 	if !l.singularityConf.UserBindControl {
 		sylog.Warningf("Ignoring bind mount request(s): user bind control disabled by system administrator")
@@ -572,13 +682,11 @@ func (l *Launcher) addUserBindMounts(mounts *[]specs.Mount) error {
 		return fmt.Errorf("while parsing bind path: %w", err)
 	}
 	// Now add binds from one or more --mount and env var.
-	for _, m := range l.cfg.Mounts {
-		bps, err := bind.ParseMountString(m)
-		if err != nil {
-			return fmt.Errorf("while parsing mount %q: %w", m, err)
-		}
-		binds = append(binds, bps...)
+	bps, err := launcher.ParseMounts(l.cfg.Mounts)
+	if err != nil {
+		return err
 	}
+	binds = append(binds, bps...)
 
 	for _, b := range binds {
 		// Special Case - user is manually requesting all of /dev to be bound
@@ -594,7 +702,7 @@ func (l *Launcher) addUserBindMounts(mounts *[]specs.Mount) error {
 		}
 		// Anything else
 		if err := l.addBindMount(mounts, b, l.cfg.AllowSUID); err != nil {
-			return fmt.Errorf("while adding mount %q: %w", b.Source, err)
+			return fmt.Errorf("while adding mount %q, requested by --bind / --mount: %w", b.Source, err)
 		}
 	}
 
@@ -623,11 +731,20 @@ func (l *Launcher) addCwdMount(mounts *[]specs.Mount) error {
 }
 
 func (l *Launcher) addBindMount(mounts *[]specs.Mount, b bind.Path, allowSUID bool) (err error) {
+	// A --mount type=tmpfs is a new tmpfs, not a bind.
+	if b.Tmpfs() {
+		return addTmpfsMount(mounts, b)
+	}
+
 	// If request is for a /dev/xxx device, then we handle with device specific checks and flags.
 	if strings.HasPrefix(b.Source, "/dev") {
 		return addDevBindMount(mounts, b)
 	}
 
+	if b.IDMap() != "" {
+		return fmt.Errorf("idmap bind option is not supported in OCI mode")
+	}
+
 	if b.ID() != "" || b.ImageSrc() != "" {
 		if !l.singularityConf.UserBindControl {
 			sylog.Warningf("Ignoring image bind mount request: user bind control disabled by system administrator")
@@ -669,6 +786,9 @@ func (l *Launcher) addBindMount(mounts *[]specs.Mount, b bind.Path, allowSUID bo
 	if b.Readonly() {
 		opts["ro"] = true
 	}
+	if p := b.Propagation(); p != "" {
+		opts[p] = true
+	}
 
 	absSource, err := filepath.Abs(b.Source)
 	if err != nil {
@@ -704,9 +824,12 @@ func (l *Launcher) prepareImageBindMount(bindPath bind.Path) (*fuse.ImageMount,
 	if err != nil {
 		return nil, err
 	}
+	defer img.File.Close()
 
 	resolvedPath := img.Path
 	readonly := bindPath.Readonly()
+	imgType := img.Type
+	var extraOpts []string
 
 	sylog.Debugf("img is: %#v", img)
 
@@ -718,6 +841,27 @@ func (l *Launcher) prepareImageBindMount(bindPath bind.Path) (*fuse.ImageMount,
 		if bindPath.ID() != "" {
 			return nil, fmt.Errorf("image %q does not support id values, but one was supplied (%q)", bindPath.ImageSrc(), bindPath.ID())
 		}
+	case image.SIF:
+		part, err := sifDataPartition(img, bindPath.ID())
+		if err != nil {
+			return nil, err
+		}
+		if part.Type != image.SQUASHFS && part.Type != image.EXT3 {
+			return nil, fmt.Errorf("partition %d of image %q is not a squashfs or EXT3 partition", part.ID, imagePath)
+		}
+		if part.Type == image.SQUASHFS {
+			readonly = true
+		}
+		imgType = int(part.Type)
+		extraOpts = []string{fmt.Sprintf("offset=%d", part.Offset)}
+	case image.OCISIF:
+		offset, err := ociSIFLayerOffset(resolvedPath, bindPath.ID())
+		if err != nil {
+			return nil, err
+		}
+		readonly = true
+		imgType = image.SQUASHFS
+		extraOpts = []string{fmt.Sprintf("offset=%d", offset)}
 	}
 
 	enclosingDir, err := os.MkdirTemp(buildcfg.SESSIONDIR, "fusemount-enclosure")
@@ -726,12 +870,13 @@ func (l *Launcher) prepareImageBindMount(bindPath bind.Path) (*fuse.ImageMount,
 	}
 
 	im := fuse.ImageMount{
-		Type:         img.Type,
+		Type:         imgType,
 		Readonly:     readonly,
 		SourcePath:   resolvedPath,
 		EnclosingDir: enclosingDir,
 		AllowSetuid:  l.cfg.AllowSUID,
 		AllowOther:   true,
+		ExtraOpts:    extraOpts,
 	}
 
 	mountpoint := filepath.Join(enclosingDir, fmt.Sprintf("fusemount-%d", len(l.imageMountsByMountpoint)))
@@ -747,6 +892,77 @@ func (l *Launcher) prepareImageBindMount(bindPath bind.Path) (*fuse.ImageMount,
 	return &im, nil
 }
 
+// sifDataPartition returns the partition of the SIF image img with the
+// descriptor id, or its first data partition if id is empty.
+func sifDataPartition(img *image.Image, id string) (*image.Section, error) {
+	if id == "" {
+		parts, err := img.GetDataPartitions()
+		if err != nil {
+			return nil, fmt.Errorf("while getting data partitions of %s: %w", img.Path, err)
+		}
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("no data partition found in %s", img.Path)
+		}
+		return &parts[0], nil
+	}
+
+	partID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || partID == 0 {
+		return nil, fmt.Errorf("invalid id %q, must be a number greater than 0", id)
+	}
+	parts, err := img.GetAllPartitions()
+	if err != nil {
+		return nil, fmt.Errorf("while getting partitions of %s: %w", img.Path, err)
+	}
+	for i := range parts {
+		if parts[i].ID == uint32(partID) {
+			return &parts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no partition with id %d found in %s", partID, img.Path)
+}
+
+// ociSIFLayerOffset returns the offset of the squashfs layer of the OCI-SIF
+// image at path held in the descriptor id, or of its single layer if id is
+// empty.
+func ociSIFLayerOffset(path, id string) (int64, error) {
+	if id == "" {
+		return ocisif.SquashfsLayerOffset(path)
+	}
+	layerID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || layerID == 0 {
+		return 0, fmt.Errorf("invalid id %q, must be a number greater than 0", id)
+	}
+	return ocisif.SquashfsLayerOffsetByID(path, uint32(layerID))
+}
+
+// addTmpfsMount adds a new tmpfs, requested with --mount type=tmpfs, to
+// mounts.
+func addTmpfsMount(mounts *[]specs.Mount, b bind.Path) error {
+	if !filepath.IsAbs(b.Destination) {
+		return fmt.Errorf("tmpfs destination %s must be an absolute path", b.Destination)
+	}
+	tmpfsOpts, err := b.TmpfsOptions()
+	if err != nil {
+		return err
+	}
+	opts := append([]string{"nosuid", "nodev", "relatime"}, tmpfsOpts...)
+	if b.Readonly() {
+		opts = append(opts, "ro")
+	}
+
+	sylog.Debugf("Adding tmpfs at %s, with options %v", b.Destination, opts)
+
+	*mounts = append(*mounts,
+		specs.Mount{
+			Destination: b.Destination,
+			Type:        "tmpfs",
+			Source:      "tmpfs",
+			Options:     opts,
+		})
+	return nil
+}
+
 func addDevBindMount(mounts *[]specs.Mount, b bind.Path) error {
 	opts := []string{"bind", "nosuid"}
 	if b.Readonly() {
@@ -832,11 +1048,166 @@ func (l *Launcher) addRocmMounts(mounts *[]specs.Mount) error {
 	return nil
 }
 
-func (l *Launcher) addNvidiaMounts(mounts *[]specs.Mount) error {
-	if l.singularityConf.UseNvCCLI {
-		sylog.Warningf("--nvccli not yet supported with --oci. Falling back to legacy --nv support.")
+func (l *Launcher) addIntelMounts(mounts *[]specs.Mount) error {
+	gpuConfFile := filepath.Join(buildcfg.SINGULARITY_CONFDIR, "intelliblist.conf")
+
+	libs, bins, err := gpu.IntelPaths(gpuConfFile)
+	if err != nil {
+		sylog.Warningf("While finding Intel GPU bind points: %v", err)
+	}
+	if len(libs) == 0 {
+		sylog.Warningf("Could not find any Intel GPU libraries on this host!")
+	}
+
+	devs, err := gpu.IntelDevices()
+	if err != nil {
+		sylog.Warningf("While finding Intel GPU devices: %v", err)
+	}
+	if len(devs) == 0 {
+		sylog.Warningf("Could not find any Intel GPU devices on this host!")
+	}
+
+	for _, binary := range bins {
+		containerBinary := filepath.Join("/usr/bin", filepath.Base(binary))
+		bind := bind.Path{
+			Source:      binary,
+			Destination: containerBinary,
+			Options:     map[string]*bind.Option{"ro": {}},
+		}
+		if err := l.addBindMount(mounts, bind, false); err != nil {
+			return err
+		}
+	}
+
+	for _, lib := range libs {
+		containerLib := filepath.Join(containerLibDir, filepath.Base(lib))
+		bind := bind.Path{
+			Source:      lib,
+			Destination: containerLib,
+			Options:     map[string]*bind.Option{"ro": {}},
+		}
+		if err := l.addBindMount(mounts, bind, false); err != nil {
+			return err
+		}
+	}
+
+	for _, dev := range devs {
+		bind := bind.Path{
+			Source:      dev,
+			Destination: dev,
+		}
+		if err := addDevBindMount(mounts, bind); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Launcher) addRdmaMounts(mounts *[]specs.Mount) error {
+	confFile := filepath.Join(buildcfg.SINGULARITY_CONFDIR, "rdmaliblist.conf")
+
+	libs, bins, err := gpu.RdmaPaths(confFile)
+	if err != nil {
+		sylog.Warningf("While finding RDMA bind points: %v", err)
+	}
+	if len(libs) == 0 {
+		sylog.Warningf("Could not find any RDMA libraries on this host!")
+	}
+
+	devs, err := gpu.RdmaDevices()
+	if err != nil {
+		sylog.Warningf("While finding RDMA devices: %v", err)
+	}
+	if len(devs) == 0 {
+		sylog.Warningf("Could not find any RDMA devices on this host!")
+	}
+
+	for _, binary := range bins {
+		containerBinary := filepath.Join("/usr/bin", filepath.Base(binary))
+		bind := bind.Path{
+			Source:      binary,
+			Destination: containerBinary,
+			Options:     map[string]*bind.Option{"ro": {}},
+		}
+		if err := l.addBindMount(mounts, bind, false); err != nil {
+			return err
+		}
+	}
+
+	for _, lib := range libs {
+		containerLib := filepath.Join(containerLibDir, filepath.Base(lib))
+		bind := bind.Path{
+			Source:      lib,
+			Destination: containerLib,
+			Options:     map[string]*bind.Option{"ro": {}},
+		}
+		if err := l.addBindMount(mounts, bind, false); err != nil {
+			return err
+		}
 	}
 
+	for _, dev := range devs {
+		bind := bind.Path{
+			Source:      dev,
+			Destination: dev,
+		}
+		if err := addDevBindMount(mounts, bind); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addMPIMounts binds the PMI libraries, binaries, and sockets of the host
+// process manager, for the MPI mode requested with --mpi. A PMI-2 server
+// reached through an inherited file descriptor, as with srun --mpi=pmi2, is
+// not supported, as the OCI runtime does not pass it into the container.
+func (l *Launcher) addMPIMounts(mounts *[]specs.Mount) error {
+	m, err := launcher.MPISetup(l.cfg.MPI)
+	if err != nil {
+		return err
+	}
+	if m.Mode == launcher.MPIModePMI2 && m.Env["PMI_FD"] != "" {
+		return fmt.Errorf("--mpi pmi2 with a PMI_FD file descriptor is not supported in OCI mode, use --mpi pmix")
+	}
+
+	for _, binary := range m.Bins {
+		containerBinary := filepath.Join("/usr/bin", filepath.Base(binary))
+		bind := bind.Path{
+			Source:      binary,
+			Destination: containerBinary,
+			Options:     map[string]*bind.Option{"ro": {}},
+		}
+		if err := l.addBindMount(mounts, bind, false); err != nil {
+			return err
+		}
+	}
+
+	for _, lib := range m.Libs {
+		containerLib := filepath.Join(containerLibDir, filepath.Base(lib))
+		bind := bind.Path{
+			Source:      lib,
+			Destination: containerLib,
+			Options:     map[string]*bind.Option{"ro": {}},
+		}
+		if err := l.addBindMount(mounts, bind, false); err != nil {
+			return err
+		}
+	}
+
+	for _, b := range m.Binds {
+		if err := l.addBindMount(mounts, b, false); err != nil {
+			return err
+		}
+	}
+
+	l.mpi = m
+	return nil
+}
+
+func (l *Launcher) addNvidiaMounts(mounts *[]specs.Mount) error {
 	gpuConfFile := filepath.Join(buildcfg.SINGULARITY_CONFDIR, "nvliblist.conf")
 	libs, bins, err := gpu.NvidiaPaths(gpuConfFile)
 	if err != nil {
@@ -851,9 +1222,9 @@ func (l *Launcher) addNvidiaMounts(mounts *[]specs.Mount) error {
 		sylog.Warningf("While finding NVIDIA IPCs: %v", err)
 	}
 
-	devs, err := gpu.NvidiaDevices(true)
+	devs, err := l.nvidiaLegacyDevices()
 	if err != nil {
-		sylog.Warningf("While finding NVIDIA devices: %v", err)
+		return err
 	}
 	if len(devs) == 0 {
 		sylog.Warningf("Could not find any NVIDIA devices on this host!")
@@ -906,6 +1277,85 @@ func (l *Launcher) addNvidiaMounts(mounts *[]specs.Mount) error {
 	return nil
 }
 
+// addDataMounts mounts the squashfs layer of each data container requested
+// with --data, read-only, at its destination in the container.
+func (l *Launcher) addDataMounts(mounts *[]specs.Mount) error {
+	if len(l.cfg.DataContainers) == 0 {
+		return nil
+	}
+	if !l.singularityConf.UserBindControl {
+		sylog.Warningf("Ignoring data container mount request(s): user bind control disabled by system administrator")
+		return nil
+	}
+
+	for _, dc := range l.cfg.DataContainers {
+		src, dest, ok := strings.Cut(dc, ":")
+		if !ok || src == "" || dest == "" {
+			return fmt.Errorf("invalid data container specification %q, must be <data container path>:<container path>", dc)
+		}
+		absSrc, err := filepath.Abs(src)
+		if err != nil {
+			return fmt.Errorf("cannot determine absolute path of %s: %w", src, err)
+		}
+		offset, err := ocisif.DataOffset(absSrc)
+		if err != nil {
+			return fmt.Errorf("while reading data container: %w", err)
+		}
+
+		enclosingDir, err := os.MkdirTemp(buildcfg.SESSIONDIR, "fusemount-enclosure")
+		if err != nil {
+			return err
+		}
+		im := fuse.ImageMount{
+			Type:         image.SQUASHFS,
+			Readonly:     true,
+			SourcePath:   absSrc,
+			EnclosingDir: enclosingDir,
+			AllowOther:   true,
+			ExtraOpts:    []string{fmt.Sprintf("offset=%d", offset)},
+		}
+		mountpoint := filepath.Join(enclosingDir, fmt.Sprintf("fusemount-%d", len(l.imageMountsByMountpoint)))
+		im.SetMountPoint(mountpoint)
+		l.imageMountsByMountpoint[mountpoint] = &im
+
+		sylog.Debugf("Mounting data container %s at %s", absSrc, dest)
+		b := bind.Path{
+			Source:      mountpoint,
+			Destination: dest,
+			Options:     map[string]*bind.Option{"ro": {}},
+		}
+		if err := l.addBindMount(mounts, b, false); err != nil {
+			return fmt.Errorf("while adding data container %q: %w", src, err)
+		}
+	}
+
+	return nil
+}
+
+// addCVMFSMounts binds the CVMFS repositories requested with --cvmfs, and the
+// site default repositories, read-only into the container.
+func (l *Launcher) addCVMFSMounts(mounts *[]specs.Mount) error {
+	if len(l.cfg.CVMFS) == 0 && len(l.singularityConf.CVMFSRepos) == 0 {
+		return nil
+	}
+	if !l.singularityConf.UserBindControl {
+		sylog.Warningf("Ignoring CVMFS mount request(s): user bind control disabled by system administrator")
+		return nil
+	}
+
+	binds, repos, err := launcher.CVMFSBinds(l.cfg.CVMFS, l.singularityConf.CVMFSRepos)
+	if err != nil {
+		return err
+	}
+	for _, b := range binds {
+		if err := l.addBindMount(mounts, b, false); err != nil {
+			return fmt.Errorf("while adding CVMFS repository %q: %w", b.Source, err)
+		}
+	}
+	l.cvmfsRepos = repos
+	return nil
+}
+
 func (l *Launcher) addLibrariesMounts(mounts *[]specs.Mount) error {
 	if !l.singularityConf.UserBindControl {
 		sylog.Warningf("Ignoring containlibs mount request: user bind control disabled by system administrator")
@@ -18,9 +18,11 @@ import (
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/rootless"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/user"
 	"github.com/sylabs/singularity/v4/pkg/util/bind"
 	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
 )
 
 func Test_addBindMount(t *testing.T) {
@@ -292,6 +294,47 @@ func TestLauncher_addUserBindMounts(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "ValidMountPropagation",
+			cfg: launcher.Options{
+				Mounts: []string{"type=bind,source=/tmp,destination=/mnt,bind-propagation=rslave"},
+			},
+			userbind: true,
+			wantMounts: &[]specs.Mount{
+				{
+					Source:      "/tmp",
+					Destination: "/mnt",
+					Type:        "none",
+					Options:     []string{"rbind", "nodev", "nosuid", "rslave"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ValidMountTmpfs",
+			cfg: launcher.Options{
+				Mounts: []string{"type=tmpfs,destination=/scratch,tmpfs-size=1m,tmpfs-mode=700"},
+			},
+			userbind: true,
+			wantMounts: &[]specs.Mount{
+				{
+					Source:      "tmpfs",
+					Destination: "/scratch",
+					Type:        "tmpfs",
+					Options:     []string{"nosuid", "nodev", "relatime", "size=1048576", "mode=700"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "InvalidMountTmpfsDst",
+			cfg: launcher.Options{
+				Mounts: []string{"type=tmpfs,destination=scratch"},
+			},
+			userbind:   true,
+			wantMounts: &[]specs.Mount{},
+			wantErr:    true,
+		},
 		{
 			name: "InvalidBindSrc",
 			cfg: launcher.Options{
@@ -610,3 +653,75 @@ func TestLauncher_addLibrariesMounts(t *testing.T) {
 		})
 	}
 }
+
+func TestLauncher_addTmpMounts(t *testing.T) {
+	// sizes above the sessiondir max size are clamped for unprivileged users
+	clampedSize := "size=64m"
+	if uid, _ := rootless.Getuid(); uid == 0 {
+		clampedSize = "size=128m"
+	}
+
+	tests := []struct {
+		name     string
+		cfg      launcher.Options
+		wantSize string
+	}{
+		{
+			name:     "Default",
+			wantSize: "size=64m",
+		},
+		{
+			name:     "TmpSize",
+			cfg:      launcher.Options{TmpSize: 16},
+			wantSize: "size=16m",
+		},
+		{
+			name:     "TmpSizeAboveMax",
+			cfg:      launcher.Options{TmpSize: 128},
+			wantSize: clampedSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Launcher{
+				cfg: tt.cfg,
+				singularityConf: &singularityconf.File{
+					MountTmp:          true,
+					SessiondirMaxSize: 64,
+				},
+			}
+			mounts := &[]specs.Mount{}
+			if err := l.addTmpMounts(mounts); err != nil {
+				t.Fatalf("addTmpMounts() error = %v", err)
+			}
+			if len(*mounts) != 2 {
+				t.Fatalf("addTmpMounts() want 2 mounts, got %v", *mounts)
+			}
+			for _, m := range *mounts {
+				if m.Type != "tmpfs" || !slice.ContainsString(m.Options, tt.wantSize) {
+					t.Errorf("addTmpMounts() want %s tmpfs, got %v", tt.wantSize, m)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandBindPaths(t *testing.T) {
+	pw, err := user.CurrentOriginal()
+	if err != nil {
+		t.Fatalf("while looking up current user: %v", err)
+	}
+	gr, err := user.GetGrGID(pw.GID)
+	if err != nil {
+		t.Fatalf("while looking up group: %v", err)
+	}
+
+	got, err := expandBindPaths([]string{"/opt", "/scratch/{username}:/data/{group}/{uid}/{gid}"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"/opt", fmt.Sprintf("/scratch/%s:/data/%s/%d/%d", pw.Name, gr.Name, pw.UID, pw.GID)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/rootless"
+	"github.com/sylabs/singularity/v4/pkg/network"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+const noneNet = "none"
+
+var (
+	// defaultCNIConfPath is the default directory to CNI network configuration files.
+	defaultCNIConfPath = filepath.Join(buildcfg.SYSCONFDIR, "singularity", "network")
+	// defaultCNIPluginPath is the default directory to CNI plugins executables.
+	defaultCNIPluginPath = filepath.Join(buildcfg.LIBEXECDIR, "singularity", "cni")
+)
+
+// checkNetwork verifies that the requested CNI network configuration can be
+// used. CNI networks modify the host's network setup, and require root.
+func checkNetwork(lo launcher.Options) error {
+	if !lo.Namespaces.Net || lo.Network == noneNet {
+		return nil
+	}
+
+	uid, err := rootless.Getuid()
+	if err != nil {
+		return err
+	}
+	if uid != 0 {
+		return fmt.Errorf("--network %s requires root in OCI mode, non-root users can only use --network=%s", lo.Network, noneNet)
+	}
+	if lo.Fakeroot {
+		return fmt.Errorf("--network %s is not supported with --fakeroot in OCI mode", lo.Network)
+	}
+	return nil
+}
+
+// prepareNetwork creates a network namespace for the container, held in the
+// bundle, and adds the CNI networks requested with --network to it. The spec
+// is updated to join this namespace. The returned function removes the
+// networks and the namespace, and is nil when no CNI network was requested.
+func (l *Launcher) prepareNetwork(ctx context.Context, bundlePath, containerID string, spec *specs.Spec) (cleanup func(context.Context) error, err error) {
+	if !l.cfg.Namespaces.Net || l.cfg.Network == noneNet {
+		return nil, nil
+	}
+
+	nsPath := filepath.Join(bundlePath, "netns")
+	if err := createNetNS(nsPath); err != nil {
+		return nil, fmt.Errorf("while creating network namespace: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := deleteNetNS(nsPath); err != nil {
+				sylog.Errorf("Couldn't remove network namespace: %v", err)
+			}
+		}
+	}()
+
+	for i, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.NetworkNamespace {
+			spec.Linux.Namespaces[i].Path = nsPath
+		}
+	}
+
+	cniPath := &network.CNIPath{
+		Conf:   l.singularityConf.CniConfPath,
+		Plugin: l.singularityConf.CniPluginPath,
+	}
+	if cniPath.Conf == "" {
+		cniPath.Conf = defaultCNIConfPath
+	}
+	if cniPath.Plugin == "" {
+		cniPath.Plugin = defaultCNIPluginPath
+	}
+
+	setup, err := network.NewSetup(strings.Split(l.cfg.Network, ","), containerID, nsPath, cniPath)
+	if err != nil {
+		return nil, fmt.Errorf("network setup failed: %w", err)
+	}
+	if err := setup.SetArgs(l.cfg.NetworkArgs); err != nil {
+		return nil, fmt.Errorf("error while setting network arguments: %w", err)
+	}
+	limits, err := network.ParseLimits(l.cfg.NetworkLimits, l.singularityConf.NetworkLimitRate, l.singularityConf.NetworkEgressAllow)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing network limits: %w", err)
+	}
+	setup.SetLimits(limits)
+	setup.SetEnvPath("/bin:/sbin:/usr/bin:/usr/sbin")
+
+	sylog.Debugf("Adding networks %s to container network namespace", l.cfg.Network)
+	if err := setup.AddNetworks(ctx); err != nil {
+		if err := setup.DelNetworks(ctx); err != nil {
+			sylog.Debugf("While removing networks: %v", err)
+		}
+		return nil, fmt.Errorf("while adding networks: %w", err)
+	}
+
+	return func(ctx context.Context) error {
+		if err := setup.DelNetworks(ctx); err != nil {
+			sylog.Errorf("Couldn't remove networks: %v", err)
+		}
+		return deleteNetNS(nsPath)
+	}, nil
+}
+
+// createNetNS creates a new network namespace, held open by a bind mount at
+// nsPath.
+func createNetNS(nsPath string) error {
+	f, err := os.Create(nsPath)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	errCh := make(chan error)
+	go func() {
+		defer close(errCh)
+		// The thread is left locked, so that the go runtime terminates it
+		// rather than reusing it in the new network namespace.
+		runtime.LockOSThread()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			errCh <- err
+			return
+		}
+		nsSource := fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid())
+		if err := unix.Mount(nsSource, nsPath, "", unix.MS_BIND, ""); err != nil {
+			errCh <- err
+		}
+	}()
+
+	if err := <-errCh; err != nil {
+		os.Remove(nsPath)
+		return err
+	}
+	return nil
+}
+
+// deleteNetNS unmounts and removes a network namespace created by createNetNS.
+func deleteNetNS(nsPath string) error {
+	if err := unix.Unmount(nsPath, unix.MNT_DETACH); err != nil && err != syscall.EINVAL && err != syscall.ENOENT {
+		return fmt.Errorf("while unmounting network namespace: %w", err)
+	}
+	if err := os.Remove(nsPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("while removing network namespace: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// nvidiaEnabled returns true if NVIDIA GPU support is requested, by flag or
+// by singularity.conf.
+func (l *Launcher) nvidiaEnabled() bool {
+	return (l.cfg.Nvidia || l.singularityConf.AlwaysUseNv) && !l.cfg.NoNvidia
+}
+
+// nvidiaCDI returns true if NVIDIA GPUs are set up from the NVIDIA CDI spec,
+// which is generated from nvidia-container-toolkit, rather than by binding
+// the libraries and binaries listed in nvliblist.conf.
+func (l *Launcher) nvidiaCDI() bool {
+	return l.nvidiaEnabled() && (l.cfg.NvCCLI || l.singularityConf.UseNvCCLI)
+}
+
+// nvidiaCDIDevices returns the NVIDIA CDI devices for the GPUs selected with
+// --gpus, or all GPUs.
+func (l *Launcher) nvidiaCDIDevices() ([]string, error) {
+	sylog.Debugf("Using NVIDIA CDI spec for GPU setup")
+	return gpu.NvidiaCDIDevices(l.cfg.GPUs)
+}
+
+// nvidiaLegacyDevices returns the NVIDIA devices to bind into the container
+// when GPUs are set up by binding the libraries and binaries listed in
+// nvliblist.conf. If GPUs are selected with --gpus, only their device nodes
+// are included, and CUDA_VISIBLE_DEVICES will be set to select them.
+func (l *Launcher) nvidiaLegacyDevices() ([]string, error) {
+	if l.cfg.GPUs == "" || l.cfg.GPUs == "all" {
+		devs, err := gpu.NvidiaDevices(true)
+		if err != nil {
+			sylog.Warningf("While finding NVIDIA devices: %v", err)
+		}
+		return devs, nil
+	}
+
+	gpus, err := gpu.NvidiaSelectGPUs(l.cfg.GPUs)
+	if err != nil {
+		return nil, fmt.Errorf("while selecting GPUs: %w", err)
+	}
+	devs, err := gpu.NvidiaSelectedDevices(gpus)
+	if err != nil {
+		return nil, fmt.Errorf("while finding NVIDIA devices: %w", err)
+	}
+	l.cudaVisibleDevices = gpu.CUDAVisibleDevices(gpus)
+	return devs, nil
+}
+
+// addCUDACompat prefers the CUDA forward compatibility libraries in rootfs to
+// the host libcuda, if they are for a newer driver than the host driver.
+func (l *Launcher) addCUDACompat(spec *specs.Spec, rootfs string) {
+	hostDriver, err := gpu.NvidiaDriverVersion()
+	if err != nil {
+		sylog.Debugf("Not checking for CUDA compat libraries, host driver version unknown: %v", err)
+		return
+	}
+	needed, err := gpu.CUDACompatNeeded(rootfs, hostDriver)
+	if err != nil {
+		sylog.Warningf("While checking for CUDA compat libraries: %v", err)
+		return
+	}
+	if !needed {
+		return
+	}
+
+	sylog.Infof("Using CUDA forward compatibility libraries from %s", gpu.CUDACompatDir)
+	for i, e := range spec.Process.Env {
+		if v, ok := strings.CutPrefix(e, "LD_LIBRARY_PATH="); ok {
+			if v != "" {
+				v = ":" + v
+			}
+			spec.Process.Env[i] = "LD_LIBRARY_PATH=" + gpu.CUDACompatDir + v
+			return
+		}
+	}
+	spec.Process.Env = append(spec.Process.Env, "LD_LIBRARY_PATH="+gpu.CUDACompatDir)
+}
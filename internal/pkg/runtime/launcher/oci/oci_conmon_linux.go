@@ -60,10 +60,14 @@ func Create(containerID, bundlePath string, systemdCgroups bool) error {
 	if err != nil {
 		return fmt.Errorf("while computing state directory: %w", err)
 	}
-	err = os.MkdirAll(sd, 0o700)
+	err = os.MkdirAll(filepath.Join(sd, exitDir), 0o700)
 	if err != nil {
 		return fmt.Errorf("while creating state directory: %w", err)
 	}
+	// Remove the exit status of a previous container with the same ID.
+	if err := os.Remove(filepath.Join(sd, exitDir, containerID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("while removing previous exit status: %w", err)
+	}
 	containerUUID, err := uuid.NewRandom()
 	if err != nil {
 		return err
@@ -102,6 +106,7 @@ func Create(containerID, bundlePath string, systemdCgroups bool) error {
 		"--conmon-pidfile", path.Join(sd, conmonPidFile),
 		"--container-pidfile", path.Join(sd, containerPidFile),
 		"--log-path", path.Join(sd, containerLogFile),
+		"--exit-dir", path.Join(sd, exitDir),
 		"--runtime-arg", "--root",
 		"--runtime-arg", rsd,
 		"--runtime-arg", "--log",
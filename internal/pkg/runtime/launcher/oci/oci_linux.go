@@ -33,6 +33,8 @@ const (
 	runcLogFile      = "runc.log"
 	conmonPidFile    = "conmon.pid"
 	bundleLink       = "bundle"
+	// Directory in which conmon records the exit status of the container
+	exitDir = "exit"
 	// Files in the OCI bundle root
 	bundleLock   = ".singularity-oci.lock"
 	attachSocket = "attach"
@@ -10,18 +10,21 @@ import (
 	"fmt"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/overlay"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/tracing"
 	"github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/ocibundle/tools"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
-	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // WrapWithWritableTmpFs runs a function wrapped with prep / cleanup steps for a
-// tmpfs. This tmpfs is always writable so that the launcher and runtime are
-// able to add content to the container. Whether it is writable from inside the
-// container is controlled by the runtime config.
-func WrapWithWritableTmpFs(ctx context.Context, f func() error, bundleDir string, allowSetuid bool) error {
-	overlayDir, err := prepareWritableTmpfs(ctx, bundleDir, allowSetuid)
+// tmpfs of size MiB. This tmpfs is always writable so that the launcher and
+// runtime are able to add content to the container. Whether it is writable
+// from inside the container is controlled by the runtime config.
+func WrapWithWritableTmpFs(ctx context.Context, f func() error, bundleDir string, size uint, allowSetuid bool) error {
+	_, span := tracing.Start(ctx, "mount overlays")
+	overlayDir, err := prepareWritableTmpfs(ctx, bundleDir, size, allowSetuid)
+	tracing.End(span, err)
 	sylog.Debugf("Done with prepareWritableTmpfs; overlayDir is: %q", overlayDir)
 	if err != nil {
 		return err
@@ -38,13 +41,9 @@ func WrapWithWritableTmpFs(ctx context.Context, f func() error, bundleDir string
 	return err
 }
 
-func prepareWritableTmpfs(ctx context.Context, bundleDir string, allowSetuid bool) (string, error) {
-	sylog.Debugf("Configuring writable tmpfs overlay for %s", bundleDir)
-	c := singularityconf.GetCurrentConfig()
-	if c == nil {
-		return "", fmt.Errorf("singularity configuration is not initialized")
-	}
-	return tools.CreateOverlayTmpfs(ctx, bundleDir, int(c.SessiondirMaxSize), allowSetuid)
+func prepareWritableTmpfs(ctx context.Context, bundleDir string, size uint, allowSetuid bool) (string, error) {
+	sylog.Debugf("Configuring %d MiB writable tmpfs overlay for %s", size, bundleDir)
+	return tools.CreateOverlayTmpfs(ctx, bundleDir, int(size), allowSetuid)
 }
 
 func cleanupWritableTmpfs(ctx context.Context, bundleDir, overlayDir string) error {
@@ -53,11 +52,12 @@ func cleanupWritableTmpfs(ctx context.Context, bundleDir, overlayDir string) err
 }
 
 // WrapWithOverlays runs a function wrapped with prep / cleanup steps for the
-// overlays specified in overlayPaths. If there is no user-provided writable
-// overlay, it adds an ephemeral overlay which is always writable so that the
+// overlays specified in overlayPaths. Encrypted overlay directories are mounted
+// with the password held in passfile, or prompted for if passfile is empty. If there is no user-provided writable
+// overlay, it adds an ephemeral overlay of size MiB which is always writable so that the
 // launcher and runtime are able to add content to the container. Whether it is
 // writable from inside the container is controlled by the runtime config.
-func WrapWithOverlays(ctx context.Context, f func() error, bundleDir string, overlayPaths []string, allowSetuid bool) error {
+func WrapWithOverlays(ctx context.Context, f func() error, bundleDir string, overlayPaths []string, passfile string, size uint, allowSetuid bool) error {
 	s := overlay.Set{}
 	for _, p := range overlayPaths {
 		item, err := overlay.NewItemFromString(p)
@@ -66,6 +66,7 @@ func WrapWithOverlays(ctx context.Context, f func() error, bundleDir string, ove
 		}
 
 		item.SetParentDir(bundleDir)
+		item.SetPassfile(passfile)
 
 		if allowSetuid {
 			item.SetAllowSetuid(true)
@@ -83,7 +84,7 @@ func WrapWithOverlays(ctx context.Context, f func() error, bundleDir string, ove
 
 	systemOverlay := ""
 	if s.WritableOverlay == nil {
-		i, err := prepareSystemOverlay(bundleDir, allowSetuid)
+		i, err := prepareSystemOverlay(bundleDir, size, allowSetuid)
 		if err != nil {
 			return err
 		}
@@ -92,7 +93,9 @@ func WrapWithOverlays(ctx context.Context, f func() error, bundleDir string, ove
 	}
 
 	rootFsDir := tools.RootFs(bundleDir).Path()
+	_, span := tracing.Start(ctx, "mount overlays", attribute.Int("overlay.count", len(overlayPaths)))
 	err := s.Mount(ctx, rootFsDir)
+	tracing.End(span, err)
 	if err != nil {
 		return err
 	}
@@ -113,14 +116,9 @@ func WrapWithOverlays(ctx context.Context, f func() error, bundleDir string, ove
 	return err
 }
 
-func prepareSystemOverlay(bundleDir string, allowSetuid bool) (*overlay.Item, error) {
-	sylog.Debugf("Configuring ephemeral writable tmpfs overlay for %s", bundleDir)
-	c := singularityconf.GetCurrentConfig()
-	if c == nil {
-		return nil, fmt.Errorf("singularity configuration is not initialized")
-	}
-
-	systemOverlay, err := tools.PrepareOverlayTmpfs(bundleDir, int(c.SessiondirMaxSize), allowSetuid)
+func prepareSystemOverlay(bundleDir string, size uint, allowSetuid bool) (*overlay.Item, error) {
+	sylog.Debugf("Configuring %d MiB ephemeral writable tmpfs overlay for %s", size, bundleDir)
+	systemOverlay, err := tools.PrepareOverlayTmpfs(bundleDir, int(size), allowSetuid)
 	if err != nil {
 		return nil, err
 	}
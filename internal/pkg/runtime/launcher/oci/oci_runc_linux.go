@@ -9,14 +9,18 @@
 package oci
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
@@ -99,6 +103,12 @@ func Exec(containerID string, cmdArgs []string, systemdCgroups bool) error {
 
 // Kill kills container process
 func Kill(containerID string, killSignal string) error {
+	return kill(containerID, killSignal, os.Stderr)
+}
+
+// kill sends killSignal to a container, with the error output of the runtime
+// written to stderr.
+func kill(containerID string, killSignal string, stderr io.Writer) error {
 	runtimeBin, err := Runtime()
 	if err != nil {
 		return err
@@ -117,7 +127,7 @@ func Kill(containerID string, killSignal string) error {
 
 	cmd := exec.Command(runtimeBin, runtimeArgs...)
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = stderr
 	sylog.Debugf("Calling %s with args %v", runtimeBin, runtimeArgs)
 	return cmd.Run()
 }
@@ -207,8 +217,20 @@ func Run(ctx context.Context, containerID, bundlePath, pidFile string, systemdCg
 
 	signals := make(chan os.Signal, 2)
 	signal.Notify(signals)
+	defer signal.Stop(signals)
+	proxyCtx, stopProxy := context.WithCancel(ctx)
+	proxyDone := make(chan struct{})
+	defer func() {
+		stopProxy()
+		<-proxyDone
+	}()
 	sylog.Debugf("Starting signal proxy for container %s", containerID)
-	go signalProxy(containerID, signals)
+	go func() {
+		defer close(proxyDone)
+		signalProxy(proxyCtx, containerID, signals, func(sig syscall.Signal) error {
+			return sendSignal(containerID, sig)
+		})
+	}()
 
 	runtimeArgs = append(runtimeArgs, containerID)
 	cmd := exec.CommandContext(ctx, runtimeBin, runtimeArgs...)
@@ -219,9 +241,51 @@ func Run(ctx context.Context, containerID, bundlePath, pidFile string, systemdCg
 	return cmd.Run()
 }
 
-func signalProxy(containerID string, signals chan os.Signal) {
+// signalProxy relays the signals received until ctx is canceled to the
+// container, with send. Signals are delivered in the order they are received,
+// by a single worker, which retries the delivery of a signal received before
+// the runtime has created the container. The signals received meanwhile are
+// queued, rather than blocking the signals channel. signalProxy returns once
+// the worker has stopped.
+func signalProxy(ctx context.Context, containerID string, signals chan os.Signal, send func(syscall.Signal) error) {
+	queue := make(chan syscall.Signal)
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for sig := range queue {
+			err := relaySignal(ctx, send, sig)
+			if err != nil && ctx.Err() != nil {
+				sylog.Debugf("Dropping signal %s for container %s: signal proxy stopped", sig.String(), containerID)
+			} else if err != nil {
+				sylog.Errorf("Failed to send signal %s to container %s: %v", sig.String(), containerID, err)
+			}
+		}
+	}()
+	defer func() {
+		close(queue)
+		<-workerDone
+	}()
+
+	var pending []syscall.Signal
 	for {
-		s := <-signals
+		// Only offer the next pending signal to the worker, if any.
+		var next chan<- syscall.Signal
+		var sig syscall.Signal
+		if len(pending) > 0 {
+			next = queue
+			sig = pending[0]
+		}
+
+		var s os.Signal
+		select {
+		case <-ctx.Done():
+			return
+		case next <- sig:
+			pending = pending[1:]
+			continue
+		case s = <-signals:
+		}
+
 		switch s {
 		case syscall.SIGCHLD:
 			break
@@ -235,9 +299,40 @@ func signalProxy(containerID string, signals chan os.Signal) {
 			//nolint:forcetypeassert
 			sysSig := s.(syscall.Signal)
 			sylog.Debugf("Sending signal %s to container %s", sysSig.String(), containerID)
-			if err := Kill(containerID, strconv.Itoa(int(sysSig))); err != nil {
-				sylog.Errorf("Failed to send signal %s to container %s", sysSig.String(), containerID)
-			}
+			pending = append(pending, sysSig)
+		}
+	}
+}
+
+// sendSignal sends sig to the container.
+func sendSignal(containerID string, sig syscall.Signal) error {
+	var stderr bytes.Buffer
+	if err := kill(containerID, strconv.Itoa(int(sig)), &stderr); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// signalRetryTimeout is how long the delivery of a signal received before
+// the container has been created by the runtime is retried.
+const signalRetryTimeout = 10 * time.Second
+
+// relaySignal sends sig to the container with send, retrying until the
+// container exists, ctx is canceled, or signalRetryTimeout passes.
+func relaySignal(ctx context.Context, send func(syscall.Signal) error, sig syscall.Signal) error {
+	deadline := time.Now().Add(signalRetryTimeout)
+	for {
+		err := send(sig)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(100 * time.Millisecond):
 		}
 	}
 }
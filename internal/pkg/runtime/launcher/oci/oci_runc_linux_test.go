@@ -0,0 +1,105 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalProxy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var sent []syscall.Signal
+	failures := 3
+	delivered := make(chan struct{}, 3)
+	send := func(sig syscall.Signal) error {
+		mu.Lock()
+		defer mu.Unlock()
+		// The container does not exist yet.
+		if failures > 0 {
+			failures--
+			return errors.New("container does not exist")
+		}
+		sent = append(sent, sig)
+		delivered <- struct{}{}
+		return nil
+	}
+
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		signalProxy(ctx, "test", signals, send)
+	}()
+
+	want := []syscall.Signal{syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP}
+	signals <- syscall.SIGUSR1
+	signals <- syscall.SIGCHLD
+	signals <- syscall.SIGUSR2
+	signals <- syscall.SIGURG
+	signals <- syscall.SIGHUP
+
+	for range want {
+		select {
+		case <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for signals")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal proxy did not stop")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+}
+
+func TestSignalProxyCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempted := make(chan struct{}, 1)
+	send := func(syscall.Signal) error {
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+		return errors.New("container does not exist")
+	}
+
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		signalProxy(ctx, "test", signals, send)
+	}()
+
+	signals <- syscall.SIGTERM
+	<-attempted
+	cancel()
+
+	// The retries of a pending signal must not outlive the proxy.
+	select {
+	case <-done:
+	case <-time.After(signalRetryTimeout / 2):
+		t.Fatal("signal proxy did not stop")
+	}
+}
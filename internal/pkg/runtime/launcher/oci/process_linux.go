@@ -21,6 +21,7 @@ import (
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/config/oci/generate"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/env"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	"github.com/sylabs/singularity/v4/pkg/util/capabilities"
 	"golang.org/x/term"
@@ -42,15 +43,18 @@ func (l *Launcher) getProcess(ctx context.Context, imgSpec imgspecv1.Image, bund
 	// with the image ENV and set in the container at runtime.
 	rtEnv := defaultEnv(ep.Image, bundle)
 
-	// SINGULARITYENV_ has lowest priority
-	rtEnv = env.MergeMap(rtEnv, env.SingularityEnvMap(os.Environ()))
-	// --env-file can override SINGULARITYENV_
-	if l.cfg.EnvFile != "" {
+	// SINGULARITYENV_ has lowest priority, and is ignored with --no-env
+	if !l.cfg.NoEnv {
+		rtEnv = env.MergeMap(rtEnv, env.SingularityEnvMap(os.Environ()))
+	}
+	// --env-file can override SINGULARITYENV_, with later files overriding
+	// earlier ones
+	if len(l.cfg.EnvFiles) > 0 {
 		currentEnv := append(
 			os.Environ(),
 			"SINGULARITY_IMAGE="+l.image,
 		)
-		e, err := env.FileMap(ctx, l.cfg.EnvFile, []string{}, currentEnv)
+		e, err := env.FilesMap(ctx, l.cfg.EnvFiles, []string{}, currentEnv)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -60,6 +64,36 @@ func (l *Launcher) getProcess(ctx context.Context, imgSpec imgspecv1.Image, bund
 	// --env flag can override --env-file and SINGULARITYENV_
 	rtEnv = env.MergeMap(rtEnv, l.cfg.Env)
 
+	// Environment for MPI workloads with --rdma, unless set above.
+	if l.cfg.Rdma {
+		for k, v := range gpu.RdmaEnv {
+			if _, ok := rtEnv[k]; !ok {
+				rtEnv[k] = v
+			}
+		}
+	}
+
+	// PMI environment of the host process manager with --mpi, unless set above.
+	if l.mpi != nil {
+		for k, v := range l.mpi.Env {
+			if _, ok := rtEnv[k]; !ok {
+				rtEnv[k] = v
+			}
+		}
+	}
+
+	// GPUs selected with --gpus, unless CUDA_VISIBLE_DEVICES was set above.
+	if _, ok := rtEnv["CUDA_VISIBLE_DEVICES"]; !ok && l.cudaVisibleDevices != "" {
+		sylog.Debugf("Setting 'CUDA_VISIBLE_DEVICES=%s' from --gpus", l.cudaVisibleDevices)
+		rtEnv["CUDA_VISIBLE_DEVICES"] = l.cudaVisibleDevices
+	}
+
+	// CVMFS repositories bound with --cvmfs, unless CVMFS_REPOSITORIES was set
+	// above.
+	if _, ok := rtEnv[launcher.CVMFSEnv]; !ok && len(l.cvmfsRepos) > 0 {
+		rtEnv[launcher.CVMFSEnv] = strings.Join(l.cvmfsRepos, ",")
+	}
+
 	// Ensure HOME points to the required home directory, even if it is a custom one, unless the container explicitly specifies its USER, in which case we don't want to touch HOME.
 	if imgSpec.Config.User == "" {
 		rtEnv["HOME"] = l.homeDest
@@ -291,6 +325,26 @@ func reverseMapByRange(targetUID, targetGID uint32, subuidRange, subgidRange spe
 	return uidMap, gidMap
 }
 
+// filterMappedIDs splits ids into the ids mapped by idMap, and the ids that
+// are not mapped.
+func filterMappedIDs(ids []uint32, idMap []specs.LinuxIDMapping) (mapped, unmapped []uint32) {
+	for _, id := range ids {
+		found := false
+		for _, m := range idMap {
+			if id >= m.ContainerID && id-m.ContainerID < m.Size {
+				found = true
+				break
+			}
+		}
+		if found {
+			mapped = append(mapped, id)
+		} else {
+			unmapped = append(unmapped, id)
+		}
+	}
+	return mapped, unmapped
+}
+
 // getProcessEnv combines the image config ENV with the ENV requested by the user.
 // APPEND_PATH and PREPEND_PATH are honored as with the native singularity runtime.
 // LD_LIBRARY_PATH is modified to always include the singularity lib bind directory.
@@ -340,11 +394,12 @@ func (l *Launcher) getProcessEnv(imageSpec imgspecv1.Image, hostEnv []string, us
 	}
 
 	// If we aren't a native SIF, add back required host env vars now, provided they haven't been set by the image or user.
-	// In --compat (default implied) we are only adding host proxy env vars.
+	// In --compat (default implied) we are only adding host proxy env vars, and
+	// those in the 'batch job env' allow-list of singularity.conf.
 	// In --no-compat we are adding almost all host env vars.
-	if !l.nativeSIF {
-		cleanEnv := !l.cfg.NoCompat || l.cfg.CleanEnv
-		for k, v := range env.HostEnvMap(hostEnv, cleanEnv) {
+	// With --no-env we are adding none.
+	if !l.nativeSIF && !l.cfg.NoEnv {
+		for k, v := range l.hostEnvMap(hostEnv) {
 			if !envAdded[k] {
 				g.AddProcessEnv(k, v)
 				envAdded[k] = true
@@ -384,6 +439,21 @@ func setOCIPath(g *generate.Generator, prependPath, path, appendPath string) {
 	}
 }
 
+// hostEnvMap returns the host env vars of hostEnv to pass into the container.
+// Unless --no-compat is used without --cleanenv, these are only the host proxy
+// env vars, and the batch scheduler env vars of the 'batch job env' allow-list,
+// if a configuration is set.
+func (l *Launcher) hostEnvMap(hostEnv []string) map[string]string {
+	cleanEnv := !l.cfg.NoCompat || l.cfg.CleanEnv
+	m := env.HostEnvMap(hostEnv, cleanEnv)
+	if cleanEnv && l.singularityConf != nil {
+		for k, v := range env.BatchJobEnvMap(hostEnv, l.singularityConf.BatchJobEnv) {
+			m[k] = v
+		}
+	}
+	return m
+}
+
 func setNativePath(g *generate.Generator, prependPath, path, appendPath string) {
 	// Set env vars used by Singularity env script to handle PATH.
 	if prependPath != "" {
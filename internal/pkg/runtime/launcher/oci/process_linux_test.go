@@ -482,6 +482,21 @@ func TestLauncher_reverseMapByRange(t *testing.T) {
 	}
 }
 
+func TestFilterMappedIDs(t *testing.T) {
+	gidMap := []specs.LinuxIDMapping{
+		{ContainerID: 0, HostID: 1, Size: 2000},
+		{ContainerID: 2000, HostID: 0, Size: 1},
+		{ContainerID: 2001, HostID: 2001, Size: 63536},
+	}
+	mapped, unmapped := filterMappedIDs([]uint32{0, 10, 2000, 65536, 65537, 100000}, gidMap)
+	if want := []uint32{0, 10, 2000, 65536}; !reflect.DeepEqual(mapped, want) {
+		t.Errorf("got mapped ids %v, want %v", mapped, want)
+	}
+	if want := []uint32{65537, 100000}; !reflect.DeepEqual(unmapped, want) {
+		t.Errorf("got unmapped ids %v, want %v", unmapped, want)
+	}
+}
+
 func TestLauncher_getBaseCapabilities(t *testing.T) {
 	currCaps, err := capabilities.GetProcessEffective()
 	if err != nil {
@@ -0,0 +1,45 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/config/oci/generate"
+	"github.com/sylabs/singularity/v4/internal/pkg/security/seccomp"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// addSeccompProfile applies the seccomp profile requested with
+// --seccomp-profile or --security seccomp:<profile> to spec, or else the
+// profile set by 'oci seccomp profile' in singularity.conf.
+func (l *Launcher) addSeccompProfile(spec *specs.Spec) error {
+	sel, err := seccomp.Select(l.cfg.SeccompProfile, l.singularityConf.OCISeccompProfile)
+	if err != nil {
+		return err
+	}
+	if sel.Path == "" {
+		sylog.Debugf("Not applying a seccomp profile, unconfined by %s", sel.Source)
+		return nil
+	}
+
+	if !seccomp.Enabled() {
+		// Don't prevent execution under the profile from singularity.conf if
+		// this build cannot apply it.
+		if l.cfg.SeccompProfile == "" {
+			sylog.Debugf("Not applying seccomp profile %s, seccomp support was not enabled at compilation time", sel.Path)
+			return nil
+		}
+		return fmt.Errorf("a seccomp profile requires seccomp support, which was not enabled at compilation time")
+	}
+
+	sylog.Debugf("Applying seccomp profile from %s, selected by %s", sel.Path, sel.Source)
+	if err := seccomp.LoadProfileFromFile(sel.Path, generate.New(spec)); err != nil {
+		return fmt.Errorf("while loading seccomp profile %s: %w", sel.Path, err)
+	}
+	return nil
+}
@@ -63,8 +63,9 @@ func addNamespaces(spec *specs.Spec, ns launcher.Namespaces) error {
 		sylog.Infof("--oci runtime always uses an IPC namespace, ipc flag is redundant.")
 	}
 
-	// Currently supports only `--network none`, i.e. isolated loopback only.
-	// Launcher.checkopts enforces this.
+	// With `--network none` this is an isolated loopback only. Otherwise, the
+	// namespace path is set to a namespace holding CNI networks by
+	// Launcher.prepareNetwork.
 	if ns.Net {
 		spec.Linux.Namespaces = append(
 			spec.Linux.Namespaces,
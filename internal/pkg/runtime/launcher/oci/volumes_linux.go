@@ -0,0 +1,189 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/samber/lo"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/syfs"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/archive"
+)
+
+// Policies for providing the VOLUMEs declared in an OCI image config.
+const (
+	// VolumePolicyTmpfs provides each volume as a new, empty, tmpfs.
+	VolumePolicyTmpfs = "tmpfs"
+	// VolumePolicyPersistent provides each volume as a host directory that is
+	// kept between runs of the same image.
+	VolumePolicyPersistent = "persistent"
+	// VolumePolicyNone does not provide volumes, so that writes go to the
+	// container rootfs.
+	VolumePolicyNone = "none"
+)
+
+// VolumeMapping describes how a VOLUME declared in an OCI image config is
+// provided to the container.
+type VolumeMapping struct {
+	// Destination is the path of the volume in the container.
+	Destination string `json:"destination"`
+	// Policy is the volume policy applied, tmpfs or persistent.
+	Policy string `json:"policy"`
+	// Source is the host directory backing a persistent volume.
+	Source string `json:"source,omitempty"`
+}
+
+// VolumeMappings returns how the VOLUMEs declared in imgSpec are provided
+// under policy, sorted by destination.
+func VolumeMappings(imgSpec imgspecv1.Image, policy string) ([]VolumeMapping, error) {
+	switch policy {
+	case VolumePolicyNone:
+		return nil, nil
+	case VolumePolicyTmpfs, VolumePolicyPersistent:
+	default:
+		return nil, fmt.Errorf("invalid volume policy %q, must be one of %s, %s, %s", policy, VolumePolicyTmpfs, VolumePolicyPersistent, VolumePolicyNone)
+	}
+
+	var volumeDir string
+	if policy == VolumePolicyPersistent {
+		var err error
+		if volumeDir, err = persistentVolumeDir(imgSpec); err != nil {
+			return nil, err
+		}
+	}
+
+	dsts := lo.Keys(imgSpec.Config.Volumes)
+	sort.Strings(dsts)
+
+	vms := make([]VolumeMapping, 0, len(dsts))
+	for _, dst := range dsts {
+		if !filepath.IsAbs(dst) {
+			sylog.Warningf("Ignoring image volume %q, as it is not an absolute path", dst)
+			continue
+		}
+		vm := VolumeMapping{
+			Destination: filepath.Clean(dst),
+			Policy:      policy,
+		}
+		if policy == VolumePolicyPersistent {
+			replacer := strings.NewReplacer(string(os.PathSeparator), "_")
+			vm.Source = filepath.Join(volumeDir, replacer.Replace(vm.Destination))
+		}
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// persistentVolumeDir returns the directory holding the persistent volumes of
+// the image with config imgSpec. Volumes are kept per image, identified by a
+// digest of the image config.
+func persistentVolumeDir(imgSpec imgspecv1.Image) (string, error) {
+	b, err := json.Marshal(imgSpec)
+	if err != nil {
+		return "", fmt.Errorf("while computing image config digest: %w", err)
+	}
+	digest := sha256.Sum256(b)
+	return filepath.Join(syfs.ConfigDir(), "volumes", hex.EncodeToString(digest[:])), nil
+}
+
+// addVolumeMounts adds mounts to spec, providing the VOLUMEs declared in
+// imgSpec according to the configured volume policy. Volumes at a destination
+// that is already mounted, e.g. by a user bind, are skipped.
+func (l *Launcher) addVolumeMounts(spec *specs.Spec, imgSpec imgspecv1.Image) error {
+	policy := l.cfg.VolumePolicy
+	if policy == "" {
+		policy = l.singularityConf.OCIVolumes
+	}
+
+	vms, err := VolumeMappings(imgSpec, policy)
+	if err != nil {
+		return err
+	}
+
+	for _, vm := range vms {
+		mounted := lo.ContainsBy(spec.Mounts, func(m specs.Mount) bool {
+			return filepath.Clean(m.Destination) == vm.Destination
+		})
+		if mounted {
+			sylog.Debugf("Image volume %s is already a mount destination, skipping", vm.Destination)
+			continue
+		}
+
+		switch vm.Policy {
+		case VolumePolicyTmpfs:
+			sylog.Debugf("Providing image volume %s as tmpfs", vm.Destination)
+			spec.Mounts = append(spec.Mounts,
+				specs.Mount{
+					Destination: vm.Destination,
+					Type:        "tmpfs",
+					Source:      "tmpfs",
+					Options: []string{
+						"nosuid",
+						"nodev",
+						"relatime",
+						"mode=1777",
+					},
+				})
+		case VolumePolicyPersistent:
+			imgPath := filepath.Join(spec.Root.Path, fs.EvalRelative(vm.Destination, spec.Root.Path))
+			if err := preparePersistentVolume(vm.Source, imgPath); err != nil {
+				return fmt.Errorf("while preparing volume %s: %w", vm.Destination, err)
+			}
+			sylog.Debugf("Providing image volume %s from %s", vm.Destination, vm.Source)
+			spec.Mounts = append(spec.Mounts,
+				specs.Mount{
+					Destination: vm.Destination,
+					Type:        "none",
+					Source:      vm.Source,
+					Options:     []string{"rbind", "nosuid", "nodev"},
+				})
+		}
+	}
+	return nil
+}
+
+// preparePersistentVolume creates the persistent volume directory dir, if it
+// does not exist, populating it with the content of imgPath in the image.
+func preparePersistentVolume(dir, imgPath string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0o700); err != nil {
+		return err
+	}
+	// Populate a temporary directory, so that an interrupted copy does not
+	// leave a partial volume behind.
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), ".tmp-volume-")
+	if err != nil {
+		return err
+	}
+	if fs.IsDir(imgPath) {
+		sylog.Debugf("Populating volume %s from %s", dir, imgPath)
+		if err := archive.CopyWithTar(imgPath+"/.", tmpDir, false); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+	}
+	if err := os.Chmod(tmpDir, 0o755); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	return os.Rename(tmpDir, dir)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+func TestVolumeMappings(t *testing.T) {
+	imgSpec := imgspecv1.Image{
+		Config: imgspecv1.ImageConfig{
+			Volumes: map[string]struct{}{
+				"/data/":   {},
+				"/cache":   {},
+				"relative": {},
+			},
+		},
+	}
+	volumeDir, err := persistentVolumeDir(imgSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		policy  string
+		want    []VolumeMapping
+		wantErr bool
+	}{
+		{
+			name:   "Tmpfs",
+			policy: VolumePolicyTmpfs,
+			want: []VolumeMapping{
+				{Destination: "/cache", Policy: VolumePolicyTmpfs},
+				{Destination: "/data", Policy: VolumePolicyTmpfs},
+			},
+		},
+		{
+			name:   "Persistent",
+			policy: VolumePolicyPersistent,
+			want: []VolumeMapping{
+				{Destination: "/cache", Policy: VolumePolicyPersistent, Source: filepath.Join(volumeDir, "_cache")},
+				{Destination: "/data", Policy: VolumePolicyPersistent, Source: filepath.Join(volumeDir, "_data")},
+			},
+		},
+		{
+			name:   "None",
+			policy: VolumePolicyNone,
+			want:   nil,
+		},
+		{
+			name:    "Invalid",
+			policy:  "bad",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VolumeMappings(imgSpec, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VolumeMappings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VolumeMappings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddVolumeMounts(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootfs, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "data", "file"), []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	imgSpec := imgspecv1.Image{
+		Config: imgspecv1.ImageConfig{
+			Volumes: map[string]struct{}{
+				"/data":  {},
+				"/bound": {},
+			},
+		},
+	}
+	volumeDir, err := persistentVolumeDir(imgSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(volumeDir)
+	t.Cleanup(func() { os.RemoveAll(volumeDir) })
+	bound := specs.Mount{Destination: "/bound", Source: "/tmp", Type: "none"}
+
+	t.Run("Tmpfs", func(t *testing.T) {
+		l := &Launcher{
+			cfg:             launcher.Options{},
+			singularityConf: &singularityconf.File{OCIVolumes: VolumePolicyTmpfs},
+		}
+		spec := &specs.Spec{Root: &specs.Root{Path: rootfs}, Mounts: []specs.Mount{bound}}
+		if err := l.addVolumeMounts(spec, imgSpec); err != nil {
+			t.Fatal(err)
+		}
+		want := []specs.Mount{
+			bound,
+			{
+				Destination: "/data",
+				Type:        "tmpfs",
+				Source:      "tmpfs",
+				Options:     []string{"nosuid", "nodev", "relatime", "mode=1777"},
+			},
+		}
+		if !reflect.DeepEqual(spec.Mounts, want) {
+			t.Errorf("got mounts %v, want %v", spec.Mounts, want)
+		}
+	})
+
+	t.Run("Persistent", func(t *testing.T) {
+		l := &Launcher{
+			cfg:             launcher.Options{VolumePolicy: VolumePolicyPersistent},
+			singularityConf: &singularityconf.File{OCIVolumes: VolumePolicyTmpfs},
+		}
+		spec := &specs.Spec{Root: &specs.Root{Path: rootfs}, Mounts: []specs.Mount{bound}}
+		if err := l.addVolumeMounts(spec, imgSpec); err != nil {
+			t.Fatal(err)
+		}
+		if len(spec.Mounts) != 2 {
+			t.Fatalf("got %d mounts, want 2", len(spec.Mounts))
+		}
+		src := spec.Mounts[1].Source
+		b, err := os.ReadFile(filepath.Join(src, "file"))
+		if err != nil {
+			t.Fatalf("volume was not populated from image: %v", err)
+		}
+		if string(b) != "image" {
+			t.Errorf("got volume content %q, want %q", b, "image")
+		}
+
+		// An existing volume must be kept as-is.
+		if err := os.WriteFile(filepath.Join(src, "file"), []byte("changed"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		spec = &specs.Spec{Root: &specs.Root{Path: rootfs}}
+		if err := l.addVolumeMounts(spec, imgSpec); err != nil {
+			t.Fatal(err)
+		}
+		b, err = os.ReadFile(filepath.Join(src, "file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "changed" {
+			t.Errorf("got volume content %q, want %q", b, "changed")
+		}
+	})
+}
@@ -7,10 +7,12 @@ package launcher
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/overlay"
 	"github.com/sylabs/singularity/v4/pkg/util/cryptkey"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
 )
 
 // Namespaces holds flags for the optional (non-mount) namespaces that can be
@@ -32,8 +34,19 @@ type Options struct {
 	Writable bool
 	// WritableTmpfs applies an ephemeral writable overlay to the container.
 	WritableTmpfs bool
+	// ReadOnly runs the container with a read-only rootfs, without the
+	// writable tmpfs that OCI mode provides by default.
+	ReadOnly bool
+	// WritableTmpfsSize is the size, in MiB, of the writable tmpfs overlay.
+	WritableTmpfsSize uint
+	// TmpSize is the size, in MiB, of tmpfs mounts on /tmp and /var/tmp.
+	TmpSize uint
+	// ShmSize is the size, in MiB, of the tmpfs mount on /dev/shm.
+	ShmSize uint
 	// OverlayPaths holds paths to image or directory overlays to be applied.
 	OverlayPaths []string
+	// OverlayPassfile is the path of a file holding the password of an encrypted overlay directory.
+	OverlayPassfile string
 	// Scratchdir lists paths into the container to be mounted from a temporary location on the host.
 	ScratchDirs []string
 	// WorkDir is the parent path for scratch directories, and contained home/tmp on the host.
@@ -46,6 +59,9 @@ type Options struct {
 	CustomHome bool
 	// NoHome disables automatic mounting of the home directory into the container.
 	NoHome bool
+	// HomeMode selects how the home directory is provided in the container,
+	// as one of the HomeMode constants.
+	HomeMode string
 
 	// BindPaths lists paths to bind from host to container, which may be <src>:<dest> pairs.
 	BindPaths []string
@@ -55,6 +71,12 @@ type Options struct {
 	Mounts []string
 	// NoMount is a list of automatic / configured mounts to disable.
 	NoMount []string
+	// DataContainers lists data containers to mount into the container, as
+	// <src>:<dest> pairs. Effective for the OCI launcher only.
+	DataContainers []string
+	// CVMFS lists CVMFS repositories to bind into the container, from
+	// /cvmfs on the host.
+	CVMFS []string
 
 	// Nvidia enables NVIDIA GPU support.
 	Nvidia bool
@@ -62,10 +84,19 @@ type Options struct {
 	NvCCLI bool
 	// NoNvidia disables NVIDIA GPU support when set default in singularity.conf.
 	NoNvidia bool
+	// GPUs selects the NVIDIA GPUs, and MIG devices, made available with NvCCLI.
+	GPUs string
 	// Rocm enables Rocm GPU support.
 	Rocm bool
 	// NoRocm disable Rocm GPU support when set default in singularity.conf.
 	NoRocm bool
+	// Intel enables Intel GPU support.
+	Intel bool
+	// Rdma enables InfiniBand / RDMA support.
+	Rdma bool
+	// MPI connects MPI applications in the container to the process manager
+	// of the host, as one of the MPIMode constants.
+	MPI string
 
 	// ContainLibs lists paths of libraries to bind mount into the container .singularity.d/libs dir.
 	ContainLibs []string
@@ -74,10 +105,15 @@ type Options struct {
 
 	// Env is a map of name=value env vars to set in the container.
 	Env map[string]string
-	// EnvFile is a file to read container env vars from.
-	EnvFile string
+	// EnvFiles are files to read container env vars from, in order, with
+	// variables set by later files taking precedence.
+	EnvFiles []string
 	// CleanEnv starts the container with a clean environment, excluding host env vars.
 	CleanEnv bool
+	// NoEnv starts the container with a minimal environment, excluding all
+	// host env vars, including those always passed with CleanEnv, and
+	// SINGULARITYENV_ vars.
+	NoEnv bool
 	// NoEval instructs Singularity not to shell evaluate args and env vars.
 	NoEval bool
 
@@ -88,10 +124,16 @@ type Options struct {
 	Network string
 	// NetworkArgs are argument to pass to the CNI plugin that will configure networking when Network is set.
 	NetworkArgs []string
+	// NetworkLimits are rate=<rate> and egress=<cidr> limits applied to the CNI network.
+	NetworkLimits []string
 	// Hostname is the hostname to set in the container (infers/requires UTS namespace).
 	Hostname string
 	// DNS is the comma separated list of DNS servers to be set in the container's resolv.conf.
 	DNS string
+	// DNSSearch is the comma separated list of DNS search domains to be set in the container's resolv.conf.
+	DNSSearch string
+	// AddHosts lists <hostname>:<ip> entries to be added to the container's /etc/hosts.
+	AddHosts []string
 
 	// AddCaps is the list of capabilities to Add to the container process.
 	AddCaps string
@@ -103,13 +145,17 @@ type Options struct {
 	KeepPrivs bool
 	// NoPrivs drops all privileges inside a container.
 	NoPrivs bool
-	// SecurityOpts is the list of security options (selinux, apparmor, seccomp) to apply.
+	// SecurityOpts is the list of security options (selinux, apparmor, seccomp, landlock) to apply.
 	SecurityOpts []string
 	// NoUmask disables propagation of the host umask into the container, using a default 0022.
 	NoUmask bool
 
 	// CGroupsJSON is a JSON format cgroups resource limit specification to apply.
 	CGroupsJSON string
+	// CgroupStats exposes the container cgroup read-only inside the container.
+	CgroupStats bool
+	// VirtualProc shows the container CPU and memory limits in /proc files.
+	VirtualProc bool
 
 	// ConfigFile is an alternate singularity.conf that will be used by unprivileged installations only.
 	ConfigFile string
@@ -121,10 +167,18 @@ type Options struct {
 
 	// Fakeroot enables the fake root mode, using user namespaces and subuid / subgid mapping.
 	Fakeroot bool
+	// OCIUser overrides the USER of the OCI image config, as user[:group]. Effective for
+	// the OCI launcher only.
+	OCIUser string
 	// NoSetgroups disables calling setgroups for the fakeroot user namespace.
 	NoSetgroups bool
 	// Boot enables execution of /sbin/init on startup of an instance container.
 	Boot bool
+	// WatchHostFiles sets whether an instance warns about ("warn"), or
+	// refreshes ("refresh"), changes to the host files bound into it.
+	WatchHostFiles string
+	// InstanceLabels sets the labels of an instance, used to filter instances.
+	InstanceLabels map[string]string
 	// NoInit disables shim process when PID namespace is used.
 	NoInit bool
 	// Contain starts the container with minimal /dev and empty home/tmp mounts.
@@ -162,6 +216,63 @@ type Options struct {
 	// mode, i.e. with default mounts etc. as native mode. Effective for the OCI
 	// launcher only.
 	NoCompat bool
+
+	// VolumePolicy selects how VOLUMEs declared in an OCI image config are
+	// provided, overriding 'oci volumes' in singularity.conf. Effective for
+	// the OCI launcher only.
+	VolumePolicy string
+
+	// SeccompProfile is the path of a JSON seccomp profile to apply to the
+	// container.
+	SeccompProfile string
+
+	// SeccompTrace is the path to which a minimal seccomp profile, allowing
+	// the syscalls made by the container, is written after it exits.
+	// Effective for the OCI launcher only.
+	SeccompTrace string
+
+	// CoreDir is a host directory into which the core dumps of crashed
+	// container processes are collected, with metadata describing the crash.
+	CoreDir string
+
+	// SignalProxy holds the names or numbers of signals that are always
+	// relayed to the container process, or "all".
+	SignalProxy []string
+
+	// ApparmorProfile is the name of an AppArmor profile to confine the
+	// container with.
+	ApparmorProfile string
+
+	// SelinuxLabel is the SELinux label (process context) to run the
+	// container with.
+	SelinuxLabel string
+
+	// LicenseOverride is the reason given to launch an image with a license
+	// the user is not entitled to, under the 'image license groups' policy.
+	LicenseOverride string
+
+	// Lazy enables the experimental lazy pulling of eStargz images, whose
+	// files are fetched from the registry on demand. Effective for the OCI
+	// launcher only.
+	Lazy bool
+
+	// ProfileIO is the path to which a profile of the image files opened by
+	// the container is written after it exits. Effective for the OCI launcher
+	// only.
+	ProfileIO string
+
+	// Stdio, if set, runs the container as a child process connected to its
+	// streams, rather than replacing the calling process, so that the caller
+	// continues once the container exits. Effective for the native launcher
+	// only.
+	Stdio *Stdio
+}
+
+// Stdio holds the standard streams of a container run as a child process.
+type Stdio struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
 type Option func(co *Options) error
@@ -182,6 +293,41 @@ func OptWritableTmpfs(b bool) Option {
 	}
 }
 
+// OptReadOnly runs the container with a read-only rootfs.
+func OptReadOnly(b bool) Option {
+	return func(lo *Options) error {
+		lo.ReadOnly = b
+		return nil
+	}
+}
+
+// OptWritableTmpfsSize sets the size, in MiB, of the writable tmpfs overlay.
+// If 0, the size set in singularity.conf is used.
+func OptWritableTmpfsSize(size uint) Option {
+	return func(lo *Options) error {
+		lo.WritableTmpfsSize = size
+		return nil
+	}
+}
+
+// OptTmpSize sets the size, in MiB, of tmpfs mounts on /tmp and /var/tmp. If
+// 0, the size set in singularity.conf is used.
+func OptTmpSize(size uint) Option {
+	return func(lo *Options) error {
+		lo.TmpSize = size
+		return nil
+	}
+}
+
+// OptShmSize sets the size, in MiB, of the tmpfs mount on /dev/shm. If 0, the
+// size set in singularity.conf is used.
+func OptShmSize(size uint) Option {
+	return func(lo *Options) error {
+		lo.ShmSize = size
+		return nil
+	}
+}
+
 // OptOverlayPaths sets overlay images and directories to apply to the container.
 // Relative paths are resolved to absolute paths at this point.
 func OptOverlayPaths(op []string) Option {
@@ -198,6 +344,14 @@ func OptOverlayPaths(op []string) Option {
 	}
 }
 
+// OptOverlayPassfile sets the file holding the password of an encrypted overlay directory.
+func OptOverlayPassfile(path string) Option {
+	return func(lo *Options) error {
+		lo.OverlayPassfile = path
+		return nil
+	}
+}
+
 // OptScratchDirs sets temporary host directories to create and bind into the container.
 func OptScratchDirs(sd []string) Option {
 	return func(lo *Options) error {
@@ -228,6 +382,19 @@ func OptHome(homeDir string, custom bool, disable bool) Option {
 	}
 }
 
+// OptHomeMode sets how the home directory is provided in the container, as
+// one of the HomeMode constants. If empty, the default set in singularity.conf
+// or by the launcher is used.
+func OptHomeMode(mode string) Option {
+	return func(lo *Options) error {
+		if mode != "" && !slice.ContainsString(homeModes, mode) {
+			return fmt.Errorf("invalid home mode %q, must be one of %v", mode, homeModes)
+		}
+		lo.HomeMode = mode
+		return nil
+	}
+}
+
 // OptMounts sets user-requested mounts to propagate into the container.
 //
 // binds lists bind mount specifications in Singularity's <src>:<dst>[:<opts>] format.
@@ -250,6 +417,23 @@ func OptNoMount(nm []string) Option {
 	}
 }
 
+// OptCVMFS sets CVMFS repositories to bind into the container.
+func OptCVMFS(repos []string) Option {
+	return func(lo *Options) error {
+		lo.CVMFS = repos
+		return nil
+	}
+}
+
+// OptDataContainers sets data containers to mount into the container, as
+// <src>:<dest> pairs.
+func OptDataContainers(dc []string) Option {
+	return func(lo *Options) error {
+		lo.DataContainers = dc
+		return nil
+	}
+}
+
 // OptNvidia enables NVIDIA GPU support.
 //
 // nvccli sets whether to use the nvidia-container-runtime (true), or legacy bind mounts (false).
@@ -261,6 +445,15 @@ func OptNvidia(nv bool, nvccli bool) Option {
 	}
 }
 
+// OptGPUs selects the NVIDIA GPUs, and MIG devices, to make available when
+// NVIDIA GPU setup uses nvidia-container-cli, or CDI in OCI mode.
+func OptGPUs(gpus string) Option {
+	return func(lo *Options) error {
+		lo.GPUs = gpus
+		return nil
+	}
+}
+
 // OptNoNvidia disables NVIDIA GPU support, even if enabled via singularity.conf.
 func OptNoNvidia(b bool) Option {
 	return func(lo *Options) error {
@@ -285,6 +478,35 @@ func OptNoRocm(b bool) Option {
 	}
 }
 
+// OptIntel enables Intel GPU support.
+func OptIntel(b bool) Option {
+	return func(lo *Options) error {
+		lo.Intel = b
+		return nil
+	}
+}
+
+// OptRdma enables InfiniBand / RDMA support.
+func OptRdma(b bool) Option {
+	return func(lo *Options) error {
+		lo.Rdma = b
+		return nil
+	}
+}
+
+// OptMPI connects MPI applications in the container to the process manager
+// of the host, binding its PMI libraries and sockets, as one of the MPIMode
+// constants.
+func OptMPI(mode string) Option {
+	return func(lo *Options) error {
+		if mode != "" && !slice.ContainsString(mpiModes, mode) {
+			return fmt.Errorf("invalid --mpi mode %q, must be one of %v", mode, mpiModes)
+		}
+		lo.MPI = mode
+		return nil
+	}
+}
+
 // OptContainLibs mounts specified libraries into the container .singularity.d/libs dir.
 func OptContainLibs(cl []string) Option {
 	return func(lo *Options) error {
@@ -303,18 +525,27 @@ func OptProot(p string) Option {
 
 // OptEnv sets container environment
 //
-// envFile is a path to a file container environment variables to set.
+// envFiles are paths to files of container environment variables to set.
 // env is a map of name=value env vars to set.
 // clean removes host variables from the container environment.
-func OptEnv(env map[string]string, envFile string, clean bool) Option {
+func OptEnv(env map[string]string, envFiles []string, clean bool) Option {
 	return func(lo *Options) error {
 		lo.Env = env
-		lo.EnvFile = envFile
+		lo.EnvFiles = envFiles
 		lo.CleanEnv = clean
 		return nil
 	}
 }
 
+// OptNoEnv starts the container with a minimal environment, with no host
+// variables.
+func OptNoEnv(b bool) Option {
+	return func(lo *Options) error {
+		lo.NoEnv = b
+		return nil
+	}
+}
+
 // OptNoEval disables shell evaluation of args and env vars.
 func OptNoEval(b bool) Option {
 	return func(lo *Options) error {
@@ -343,6 +574,15 @@ func OptNetwork(network string, args []string) Option {
 	}
 }
 
+// OptNetworkLimits sets rate=<rate> and egress=<cidr> limits applied to the
+// CNI network.
+func OptNetworkLimits(limits []string) Option {
+	return func(lo *Options) error {
+		lo.NetworkLimits = limits
+		return nil
+	}
+}
+
 // OptHostname sets a hostname for the container (infers/requires UTS namespace).
 func OptHostname(h string) Option {
 	return func(lo *Options) error {
@@ -359,6 +599,22 @@ func OptDNS(d string) Option {
 	}
 }
 
+// OptDNSSearch sets DNS search domains for the container resolv.conf.
+func OptDNSSearch(s string) Option {
+	return func(lo *Options) error {
+		lo.DNSSearch = s
+		return nil
+	}
+}
+
+// OptAddHosts sets <hostname>:<ip> entries to add to the container /etc/hosts.
+func OptAddHosts(h []string) Option {
+	return func(lo *Options) error {
+		lo.AddHosts = h
+		return nil
+	}
+}
+
 // OptCaps sets capabilities to add and drop.
 func OptCaps(add, drop string) Option {
 	return func(lo *Options) error {
@@ -416,6 +672,22 @@ func OptCgroupsJSON(cj string) Option {
 	}
 }
 
+// OptCgroupStats exposes the container cgroup read-only inside the container.
+func OptCgroupStats(b bool) Option {
+	return func(lo *Options) error {
+		lo.CgroupStats = b
+		return nil
+	}
+}
+
+// OptVirtualProc shows the container CPU and memory limits in /proc files.
+func OptVirtualProc(b bool) Option {
+	return func(lo *Options) error {
+		lo.VirtualProc = b
+		return nil
+	}
+}
+
 // OptConfigFile specifies an alternate singularity.conf that will be used by unprivileged installations only.
 func OptConfigFile(c string) Option {
 	return func(lo *Options) error {
@@ -448,6 +720,14 @@ func OptFakeroot(b bool) Option {
 	}
 }
 
+// OptOCIUser overrides the USER of the OCI image config, as user[:group].
+func OptOCIUser(u string) Option {
+	return func(lo *Options) error {
+		lo.OCIUser = u
+		return nil
+	}
+}
+
 // OptNoSetgroups disables calling setgroups for the fakeroot user namespace.
 func OptNoSetgroups(b bool) Option {
 	return func(lo *Options) error {
@@ -464,6 +744,23 @@ func OptBoot(b bool) Option {
 	}
 }
 
+// OptWatchHostFiles sets whether an instance warns about ("warn"), or
+// refreshes ("refresh"), changes to the host files bound into it.
+func OptWatchHostFiles(mode string) Option {
+	return func(lo *Options) error {
+		lo.WatchHostFiles = mode
+		return nil
+	}
+}
+
+// OptInstanceLabels sets the labels of an instance, used to filter instances.
+func OptInstanceLabels(labels map[string]string) Option {
+	return func(lo *Options) error {
+		lo.InstanceLabels = labels
+		return nil
+	}
+}
+
 // OptNoInit disables shim process when PID namespace is used.
 func OptNoInit(b bool) Option {
 	return func(lo *Options) error {
@@ -559,3 +856,97 @@ func OptNoCompat(b bool) Option {
 		return nil
 	}
 }
+
+// OptVolumePolicy sets how VOLUMEs declared in an OCI image config are provided.
+func OptVolumePolicy(policy string) Option {
+	return func(lo *Options) error {
+		lo.VolumePolicy = policy
+		return nil
+	}
+}
+
+// OptSeccompProfile sets the path of a JSON seccomp profile to apply to the container.
+func OptSeccompProfile(path string) Option {
+	return func(lo *Options) error {
+		lo.SeccompProfile = path
+		return nil
+	}
+}
+
+// OptSeccompTrace sets the path to which a seccomp profile, generated by
+// tracing the syscalls made by the container, is written.
+func OptSeccompTrace(path string) Option {
+	return func(lo *Options) error {
+		lo.SeccompTrace = path
+		return nil
+	}
+}
+
+// OptCoreDir sets a host directory into which the core dumps of crashed
+// container processes are collected.
+func OptCoreDir(dir string) Option {
+	return func(lo *Options) error {
+		lo.CoreDir = dir
+		return nil
+	}
+}
+
+// OptSignalProxy sets the signals that are always relayed to the container
+// process.
+func OptSignalProxy(signals []string) Option {
+	return func(lo *Options) error {
+		lo.SignalProxy = signals
+		return nil
+	}
+}
+
+// OptApparmorProfile sets the name of an AppArmor profile to confine the container with.
+func OptApparmorProfile(profile string) Option {
+	return func(lo *Options) error {
+		lo.ApparmorProfile = profile
+		return nil
+	}
+}
+
+// OptLicenseOverride sets the reason to launch an image with a license the
+// user is not entitled to, if permitted to override the restriction.
+func OptLicenseOverride(reason string) Option {
+	return func(lo *Options) error {
+		lo.LicenseOverride = reason
+		return nil
+	}
+}
+
+// OptSelinuxLabel sets the SELinux label to run the container with.
+func OptSelinuxLabel(label string) Option {
+	return func(lo *Options) error {
+		lo.SelinuxLabel = label
+		return nil
+	}
+}
+
+// OptLazy sets whether eStargz images are lazily pulled.
+func OptLazy(b bool) Option {
+	return func(lo *Options) error {
+		lo.Lazy = b
+		return nil
+	}
+}
+
+// OptStdio runs the container as a child process, connected to stdin,
+// stdout, and stderr.
+func OptStdio(stdin io.Reader, stdout, stderr io.Writer) Option {
+	return func(lo *Options) error {
+		lo.Stdio = &Stdio{Stdin: stdin, Stdout: stdout, Stderr: stderr}
+		return nil
+	}
+}
+
+// OptProfileIO sets the path to which a profile of the image files opened by
+// the container is written.
+func OptProfileIO(path string) Option {
+	return func(lo *Options) error {
+		lo.ProfileIO = path
+		return nil
+	}
+}
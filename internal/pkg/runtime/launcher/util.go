@@ -1,4 +1,4 @@
-// Copyright (c) 2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2022-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -8,9 +8,12 @@ package launcher
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/sylabs/singularity/v4/pkg/syfs"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/bind"
 	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
 )
 
@@ -46,3 +49,38 @@ func HidepidProc() bool {
 	}
 	return false
 }
+
+// ParseMounts parses the --mount specifications mounts. Named volumes,
+// requested with type=volume, are bound from a directory of the user's
+// configuration directory, created on first use.
+func ParseMounts(mounts []string) ([]bind.Path, error) {
+	var binds []bind.Path
+	for _, m := range mounts {
+		bps, err := bind.ParseMountString(m)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing mount %q: %w", m, err)
+		}
+		for i := range bps {
+			name := bps[i].Volume()
+			if name == "" {
+				continue
+			}
+			dir := VolumeDir(name)
+			if err := os.MkdirAll(filepath.Dir(dir), 0o700); err != nil {
+				return nil, fmt.Errorf("while creating volume %s: %w", name, err)
+			}
+			if err := os.Mkdir(dir, 0o755); err != nil && !os.IsExist(err) {
+				return nil, fmt.Errorf("while creating volume %s: %w", name, err)
+			}
+			sylog.Debugf("Providing volume %s from %s", name, dir)
+			bps[i].Source = dir
+		}
+		binds = append(binds, bps...)
+	}
+	return binds, nil
+}
+
+// VolumeDir returns the directory holding the named volume name.
+func VolumeDir(name string) string {
+	return filepath.Join(syfs.ConfigDir(), "volumes", "named", name)
+}
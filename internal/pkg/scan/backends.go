@@ -0,0 +1,155 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// grype is a backend using the grype scanner.
+type grype struct {
+	path string
+}
+
+func (g *grype) Name() string { return "grype" }
+
+func (g *grype) SBOM(ctx context.Context, rootfs, sbomPath string) error {
+	_, err := run(ctx, g.path, "--quiet", "--output", "cyclonedx-json", "--file", sbomPath, "dir:"+rootfs)
+	return err
+}
+
+func (g *grype) Scan(ctx context.Context, sbomPath string) ([]Vulnerability, error) {
+	out, err := run(ctx, g.path, "--quiet", "--output", "json", "sbom:"+sbomPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseGrype(out)
+}
+
+// parseGrype returns the vulnerabilities in the JSON output of grype.
+func parseGrype(b []byte) ([]Vulnerability, error) {
+	var out struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID       string `json:"id"`
+				Severity string `json:"severity"`
+				Fix      struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("while decoding grype output: %w", err)
+	}
+
+	vulns := make([]Vulnerability, 0, len(out.Matches))
+	for _, m := range out.Matches {
+		vulns = append(vulns, Vulnerability{
+			ID:           m.Vulnerability.ID,
+			Package:      m.Artifact.Name,
+			Version:      m.Artifact.Version,
+			FixedVersion: strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			Severity:     toSeverity(m.Vulnerability.Severity),
+		})
+	}
+	return vulns, nil
+}
+
+// trivy is a backend using the trivy scanner.
+type trivy struct {
+	path string
+}
+
+func (t *trivy) Name() string { return "trivy" }
+
+func (t *trivy) SBOM(ctx context.Context, rootfs, sbomPath string) error {
+	_, err := run(ctx, t.path, "rootfs", "--quiet", "--format", "cyclonedx", "--output", sbomPath, rootfs)
+	return err
+}
+
+func (t *trivy) Scan(ctx context.Context, sbomPath string) ([]Vulnerability, error) {
+	out, err := run(ctx, t.path, "sbom", "--quiet", "--format", "json", sbomPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseTrivy(out)
+}
+
+// parseTrivy returns the vulnerabilities in the JSON output of trivy.
+func parseTrivy(b []byte) ([]Vulnerability, error) {
+	var out struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string `json:"VulnerabilityID"`
+				PkgName          string `json:"PkgName"`
+				InstalledVersion string `json:"InstalledVersion"`
+				FixedVersion     string `json:"FixedVersion"`
+				Severity         string `json:"Severity"`
+			} `json:"Vulnerabilities"`
+		} `json:"Results"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("while decoding trivy output: %w", err)
+	}
+
+	var vulns []Vulnerability
+	for _, r := range out.Results {
+		for _, v := range r.Vulnerabilities {
+			vulns = append(vulns, Vulnerability{
+				ID:           v.VulnerabilityID,
+				Package:      v.PkgName,
+				Version:      v.InstalledVersion,
+				FixedVersion: v.FixedVersion,
+				Severity:     toSeverity(v.Severity),
+			})
+		}
+	}
+	return vulns, nil
+}
+
+// Exec is a backend using a scanner executable, which must implement two
+// subcommands:
+//
+//	<executable> sbom <rootfs> <sbom>
+//
+// writes a CycloneDX JSON SBOM, describing the packages installed in the root
+// filesystem at <rootfs>, to the file <sbom>.
+//
+//	<executable> scan <sbom>
+//
+// writes the known vulnerabilities of the packages described by the SBOM in
+// the file <sbom> to standard output, as a JSON array of Vulnerability.
+type Exec struct {
+	path string
+}
+
+func (e *Exec) Name() string { return e.path }
+
+func (e *Exec) SBOM(ctx context.Context, rootfs, sbomPath string) error {
+	_, err := run(ctx, e.path, "sbom", rootfs, sbomPath)
+	return err
+}
+
+func (e *Exec) Scan(ctx context.Context, sbomPath string) ([]Vulnerability, error) {
+	out, err := run(ctx, e.path, "scan", sbomPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var vulns []Vulnerability
+	if err := json.Unmarshal(out, &vulns); err != nil {
+		return nil, fmt.Errorf("while decoding %s output: %w", e.path, err)
+	}
+	return vulns, nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package scan generates a software bill of materials (SBOM) for the root
+// filesystem of a container, and scans it for known vulnerabilities, using an
+// external scanner.
+package scan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// Severity is the severity of a vulnerability.
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota
+	SeverityNegligible
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"unknown", "negligible", "low", "medium", "high", "critical"}
+
+// String returns the lower case name of s.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return severityNames[SeverityUnknown]
+	}
+	return severityNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Names that are not
+// recognized are read as SeverityUnknown.
+func (s *Severity) UnmarshalText(b []byte) error {
+	*s = toSeverity(string(b))
+	return nil
+}
+
+// ParseSeverity returns the Severity named s, case insensitively.
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return Severity(i), nil
+		}
+	}
+	return SeverityUnknown, fmt.Errorf("invalid severity %q, must be one of: %s", s, strings.Join(severityNames, ", "))
+}
+
+// toSeverity returns the Severity named s, as reported by a scanner, or
+// SeverityUnknown if s is not recognized.
+func toSeverity(s string) Severity {
+	sev, err := ParseSeverity(s)
+	if err != nil {
+		sylog.Debugf("Unrecognized severity %q", s)
+	}
+	return sev
+}
+
+// Vulnerability is a known vulnerability of a package found in a container.
+type Vulnerability struct {
+	// ID is the identifier of the vulnerability, e.g. a CVE ID.
+	ID string `json:"id"`
+	// Package is the name of the affected package.
+	Package string `json:"package"`
+	// Version is the installed version of the affected package.
+	Version string `json:"version"`
+	// FixedVersion is the version of the package that fixes the
+	// vulnerability, if any.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	// Severity is the severity of the vulnerability.
+	Severity Severity `json:"severity"`
+}
+
+// Sort orders vulns by decreasing severity, then by ID and package.
+func Sort(vulns []Vulnerability) {
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if vulns[i].Severity != vulns[j].Severity {
+			return vulns[i].Severity > vulns[j].Severity
+		}
+		if vulns[i].ID != vulns[j].ID {
+			return vulns[i].ID < vulns[j].ID
+		}
+		return vulns[i].Package < vulns[j].Package
+	})
+}
+
+// AtLeast returns the vulnerabilities in vulns with a severity of min, or
+// higher.
+func AtLeast(vulns []Vulnerability, min Severity) []Vulnerability {
+	var out []Vulnerability
+	for _, v := range vulns {
+		if v.Severity >= min {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Backend generates an SBOM for a root filesystem, and scans it for known
+// vulnerabilities.
+type Backend interface {
+	// Name returns the name of the backend.
+	Name() string
+	// SBOM writes a CycloneDX JSON SBOM, describing the packages installed in
+	// the root filesystem at rootfs, to sbomPath.
+	SBOM(ctx context.Context, rootfs, sbomPath string) error
+	// Scan returns the known vulnerabilities of the packages described by the
+	// CycloneDX JSON SBOM at sbomPath.
+	Scan(ctx context.Context, sbomPath string) ([]Vulnerability, error)
+}
+
+// NewBackend returns the scanner backend specified by name. The grype and
+// trivy scanners are supported directly, and are found on PATH. Otherwise,
+// name must be the path to an executable implementing the exec backend
+// interface, described by Exec.
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case "grype":
+		path, err := bin.FindBin(name)
+		if err != nil {
+			return nil, err
+		}
+		return &grype{path: path}, nil
+	case "trivy":
+		path, err := bin.FindBin(name)
+		if err != nil {
+			return nil, err
+		}
+		return &trivy{path: path}, nil
+	}
+
+	if !strings.Contains(name, "/") {
+		return nil, fmt.Errorf("unknown scanner %q, must be grype, trivy, or the path to a scanner executable", name)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Exec{path: path}, nil
+}
+
+// run runs the executable at path with args, and returns its standard output.
+func run(ctx context.Context, path string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	sylog.Debugf("Running %s", cmd.String())
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", cmd.String(), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
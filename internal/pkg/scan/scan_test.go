@@ -0,0 +1,192 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Severity
+		wantErr bool
+	}{
+		{"negligible", SeverityNegligible, false},
+		{"Low", SeverityLow, false},
+		{"MEDIUM", SeverityMedium, false},
+		{"high", SeverityHigh, false},
+		{"Critical", SeverityCritical, false},
+		{"unknown", SeverityUnknown, false},
+		{"severe", SeverityUnknown, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseSeverity(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeverity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeverity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityJSON(t *testing.T) {
+	b, err := json.Marshal(Vulnerability{ID: "CVE-2023-0001", Severity: SeverityHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"id":"CVE-2023-0001","package":"","version":"","severity":"high"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	var v Vulnerability
+	if err := json.Unmarshal([]byte(`{"severity":"Bogus"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Severity != SeverityUnknown {
+		t.Errorf("got severity %v, want %v", v.Severity, SeverityUnknown)
+	}
+}
+
+func TestSortAtLeast(t *testing.T) {
+	vulns := []Vulnerability{
+		{ID: "CVE-3", Severity: SeverityLow},
+		{ID: "CVE-2", Severity: SeverityCritical},
+		{ID: "CVE-1", Severity: SeverityLow},
+		{ID: "CVE-4", Severity: SeverityHigh},
+	}
+	Sort(vulns)
+
+	want := []Vulnerability{
+		{ID: "CVE-2", Severity: SeverityCritical},
+		{ID: "CVE-4", Severity: SeverityHigh},
+		{ID: "CVE-1", Severity: SeverityLow},
+		{ID: "CVE-3", Severity: SeverityLow},
+	}
+	if !reflect.DeepEqual(vulns, want) {
+		t.Errorf("Sort() = %v, want %v", vulns, want)
+	}
+
+	if got := AtLeast(vulns, SeverityHigh); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("AtLeast() = %v, want %v", got, want[:2])
+	}
+	if got := AtLeast(vulns, SeverityCritical+1); got != nil {
+		t.Errorf("AtLeast() = %v, want nil", got)
+	}
+}
+
+func TestParseGrype(t *testing.T) {
+	out := `{
+  "matches": [
+    {
+      "vulnerability": {"id": "CVE-2023-0001", "severity": "High", "fix": {"versions": ["1.2.4", "1.3.0"]}},
+      "artifact": {"name": "libfoo", "version": "1.2.3"}
+    },
+    {
+      "vulnerability": {"id": "CVE-2023-0002", "severity": "Negligible", "fix": {"versions": []}},
+      "artifact": {"name": "bar", "version": "0.1"}
+    }
+  ]
+}`
+	got, err := parseGrype([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Vulnerability{
+		{ID: "CVE-2023-0001", Package: "libfoo", Version: "1.2.3", FixedVersion: "1.2.4, 1.3.0", Severity: SeverityHigh},
+		{ID: "CVE-2023-0002", Package: "bar", Version: "0.1", Severity: SeverityNegligible},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGrype() = %v, want %v", got, want)
+	}
+}
+
+func TestParseTrivy(t *testing.T) {
+	out := `{
+  "Results": [
+    {"Target": "os"},
+    {
+      "Target": "pkgs",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2023-0001", "PkgName": "libfoo", "InstalledVersion": "1.2.3", "FixedVersion": "1.2.4", "Severity": "CRITICAL"}
+      ]
+    }
+  ]
+}`
+	got, err := parseTrivy([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Vulnerability{
+		{ID: "CVE-2023-0001", Package: "libfoo", Version: "1.2.3", FixedVersion: "1.2.4", Severity: SeverityCritical},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTrivy() = %v, want %v", got, want)
+	}
+}
+
+const testScanner = `#!/bin/sh
+case "$1" in
+sbom)
+	echo "{\"rootfs\": \"$2\"}" > "$3"
+	;;
+scan)
+	grep -q rootfs "$2" || exit 1
+	echo '[{"id": "CVE-2023-0001", "package": "libfoo", "version": "1.2.3", "severity": "medium"}]'
+	;;
+*)
+	exit 1
+	;;
+esac
+`
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scanner")
+	if err := os.WriteFile(path, []byte(testScanner), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	sbom := filepath.Join(dir, "sbom.json")
+	if err := b.SBOM(ctx, dir, sbom); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.Scan(ctx, sbom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Vulnerability{
+		{ID: "CVE-2023-0001", Package: "libfoo", Version: "1.2.3", Severity: SeverityMedium},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scan() = %v, want %v", got, want)
+	}
+
+	if _, err := b.Scan(ctx, filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Scan() of missing SBOM succeeded")
+	}
+}
+
+func TestNewBackendUnknown(t *testing.T) {
+	if _, err := NewBackend("clair"); err == nil {
+		t.Errorf("NewBackend() of unknown scanner succeeded")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package landlock restricts the filesystem access of the current process, and
+// its children, with the Landlock LSM.
+package landlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// readAccess are the rights granted on read-only paths.
+const readAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+	unix.LANDLOCK_ACCESS_FS_READ_FILE |
+	unix.LANDLOCK_ACCESS_FS_READ_DIR
+
+// fileAccess are the rights that apply to files, rather than directories.
+const fileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+	unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+	unix.LANDLOCK_ACCESS_FS_READ_FILE |
+	unix.LANDLOCK_ACCESS_FS_TRUNCATE
+
+// abiAccess are the filesystem rights handled by each version of the Landlock
+// ABI, from version 1.
+var abiAccess = []uint64{
+	unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM,
+	unix.LANDLOCK_ACCESS_FS_REFER,
+	unix.LANDLOCK_ACCESS_FS_TRUNCATE,
+}
+
+// Rule grants access to the file hierarchy beneath Path.
+type Rule struct {
+	// Path is the file or directory to which the rule applies.
+	Path string
+	// Writable grants all handled rights, rather than read and execute only.
+	Writable bool
+	// ListOnly grants the right to list directories only, so that a parent
+	// of the other rules can be listed without granting read access to the
+	// files beneath it.
+	ListOnly bool
+}
+
+// ABI returns the version of the Landlock ABI supported by the kernel, or 0
+// if Landlock is not supported, or is disabled.
+func ABI() int {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(v)
+}
+
+// Enabled returns whether Landlock is supported, and enabled, by the kernel.
+func Enabled() bool {
+	return ABI() > 0
+}
+
+// handledAccess returns the filesystem rights handled by ABI version abi.
+func handledAccess(abi int) uint64 {
+	var access uint64
+	for i := 0; i < abi && i < len(abiAccess); i++ {
+		access |= abiAccess[i]
+	}
+	return access
+}
+
+// Restrict restricts the filesystem access of the current thread, and the
+// processes it executes, to the hierarchies beneath the paths of rules. Rules
+// for paths that do not exist are skipped. The no_new_privs attribute is set
+// on the thread, as required by Landlock for unprivileged processes, so
+// privileges cannot be gained through the execution of setuid binaries.
+//
+// As Landlock applies to a single thread, the caller must lock the calling
+// goroutine to its thread, and execute the container process from it.
+func Restrict(rules []Rule) error {
+	abi := ABI()
+	if abi == 0 {
+		return fmt.Errorf("landlock is not supported, or is disabled, by the kernel")
+	}
+	handled := handledAccess(abi)
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("while creating landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	for _, r := range rules {
+		access := uint64(readAccess)
+		switch {
+		case r.ListOnly:
+			access = unix.LANDLOCK_ACCESS_FS_READ_DIR
+		case r.Writable:
+			access = handled
+		}
+		if err := addRule(int(fd), r.Path, access&handled); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				sylog.Debugf("Skipping landlock rule for missing path %s", r.Path)
+				continue
+			}
+			return fmt.Errorf("while adding landlock rule for %s: %w", r.Path, err)
+		}
+		sylog.Debugf("Added landlock rule for %s (writable: %t)", r.Path, r.Writable)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("while setting no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("while enforcing landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+// addRule adds a rule granting access to the hierarchy beneath path, to the
+// ruleset with file descriptor fd.
+func addRule(fd int, path string, access uint64) error {
+	f, err := os.OpenFile(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// Only rights applying to files may be granted on a file.
+	if !fi.IsDir() {
+		access &= fileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(f.Fd()),
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(fd), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package landlock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestHandledAccess(t *testing.T) {
+	if got := handledAccess(0); got != 0 {
+		t.Errorf("handledAccess(0) = %#x, want 0", got)
+	}
+	v1 := handledAccess(1)
+	if v1&unix.LANDLOCK_ACCESS_FS_REFER != 0 || v1&unix.LANDLOCK_ACCESS_FS_READ_FILE == 0 {
+		t.Errorf("handledAccess(1) = %#x, want ABI v1 rights", v1)
+	}
+	if got, want := handledAccess(99), handledAccess(3); got != want {
+		t.Errorf("handledAccess(99) = %#x, want %#x", got, want)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	if !Enabled() {
+		t.Skip("landlock is not supported, or is disabled, by the kernel")
+	}
+
+	allowed := t.TempDir()
+	denied := t.TempDir()
+	readOnly := t.TempDir()
+	listOnly := t.TempDir()
+	if err := os.WriteFile(filepath.Join(listOnly, "file"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Landlock restricts the calling thread only. The thread is locked, and
+	// not unlocked, so that it is terminated when the goroutine exits.
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		rules := []Rule{
+			{Path: allowed, Writable: true},
+			{Path: readOnly},
+			{Path: listOnly, ListOnly: true},
+			{Path: filepath.Join(denied, "missing"), Writable: true},
+		}
+		if err := Restrict(rules); err != nil {
+			errCh <- err
+			return
+		}
+
+		if err := os.WriteFile(filepath.Join(allowed, "file"), nil, 0o644); err != nil {
+			errCh <- err
+			return
+		}
+		if _, err := os.ReadDir(readOnly); err != nil {
+			errCh <- err
+			return
+		}
+		if _, err := os.ReadDir(listOnly); err != nil {
+			errCh <- err
+			return
+		}
+		if _, err := os.ReadFile(filepath.Join(listOnly, "file")); !errors.Is(err, os.ErrPermission) {
+			errCh <- errors.New("read of file in " + listOnly + " was not denied")
+			return
+		}
+		for _, dir := range []string{denied, readOnly, listOnly} {
+			err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644)
+			if !errors.Is(err, os.ErrPermission) {
+				errCh <- errors.New("write to " + dir + " was not denied")
+				return
+			}
+		}
+		errCh <- nil
+	}()
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
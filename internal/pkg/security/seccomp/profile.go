@@ -0,0 +1,61 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package seccomp
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+)
+
+const (
+	// DefaultProfile selects the default seccomp profile, installed with
+	// singularity.
+	DefaultProfile = "default"
+	// Unconfined selects no seccomp profile.
+	Unconfined = "unconfined"
+)
+
+// DefaultProfilePath returns the path of the default seccomp profile,
+// installed with singularity.
+func DefaultProfilePath() string {
+	return filepath.Join(buildcfg.SINGULARITY_CONFDIR, "seccomp-profiles", "default.json")
+}
+
+// Selection describes the seccomp profile selected for a container.
+type Selection struct {
+	// Path is the path of the JSON seccomp profile to apply, or empty if no
+	// profile is to be applied.
+	Path string
+	// Source describes where the selection was made.
+	Source string
+}
+
+// Select returns the seccomp profile to apply to a container in OCI mode.
+// profile is the profile requested for the container, with --seccomp-profile
+// or --security seccomp:<profile>, and conf is the 'oci seccomp profile'
+// directive of singularity.conf, used when no profile is requested. Each is
+// 'default', 'unconfined', or the path of a JSON seccomp profile.
+func Select(profile, conf string) (Selection, error) {
+	s := Selection{Source: "command line"}
+	if profile == "" {
+		profile = conf
+		s.Source = "singularity.conf"
+	}
+
+	switch profile {
+	case "", DefaultProfile:
+		s.Path = DefaultProfilePath()
+	case Unconfined:
+	default:
+		if !filepath.IsAbs(profile) && s.Source == "singularity.conf" {
+			return s, fmt.Errorf("'oci seccomp profile' must be default, unconfined, or an absolute path: %q", profile)
+		}
+		s.Path = profile
+	}
+	return s, nil
+}
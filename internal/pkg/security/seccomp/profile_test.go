@@ -0,0 +1,78 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package seccomp
+
+import (
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		conf    string
+		want    Selection
+		wantErr bool
+	}{
+		{
+			name: "DefaultConf",
+			conf: DefaultProfile,
+			want: Selection{Path: DefaultProfilePath(), Source: "singularity.conf"},
+		},
+		{
+			name: "EmptyConf",
+			want: Selection{Path: DefaultProfilePath(), Source: "singularity.conf"},
+		},
+		{
+			name: "UnconfinedConf",
+			conf: Unconfined,
+			want: Selection{Source: "singularity.conf"},
+		},
+		{
+			name: "PathConf",
+			conf: "/etc/profile.json",
+			want: Selection{Path: "/etc/profile.json", Source: "singularity.conf"},
+		},
+		{
+			name:    "RelativePathConf",
+			conf:    "profile.json",
+			wantErr: true,
+		},
+		{
+			name:    "Unconfined",
+			profile: Unconfined,
+			conf:    "/etc/profile.json",
+			want:    Selection{Source: "command line"},
+		},
+		{
+			name:    "Default",
+			profile: DefaultProfile,
+			conf:    Unconfined,
+			want:    Selection{Path: DefaultProfilePath(), Source: "command line"},
+		},
+		{
+			name:    "RelativePath",
+			profile: "profile.json",
+			conf:    Unconfined,
+			want:    Selection{Path: "profile.json", Source: "command line"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select(tt.profile, tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Select() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
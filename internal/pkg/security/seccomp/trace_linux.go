@@ -0,0 +1,294 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package seccomp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+
+	cseccomp "github.com/containers/common/pkg/seccomp"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
+	"golang.org/x/sys/unix"
+)
+
+// traceAllowed are the syscalls that are allowed, rather than traced, while
+// tracing. The OCI runtime makes these calls after the seccomp filter is
+// loaded, but before it has passed the notification fd to the Tracer, so they
+// cannot wait for a response. They are always allowed in the generated
+// profile.
+var traceAllowed = []string{
+	"brk",
+	"clock_gettime",
+	"clock_nanosleep",
+	"close",
+	"close_range",
+	"connect",
+	"execve",
+	"execveat",
+	"exit",
+	"exit_group",
+	"fcntl",
+	"fstat",
+	"futex",
+	"getpid",
+	"gettid",
+	"madvise",
+	"mmap",
+	"munmap",
+	"nanosleep",
+	"newfstatat",
+	"read",
+	"recvfrom",
+	"recvmsg",
+	"rt_sigaction",
+	"rt_sigprocmask",
+	"rt_sigreturn",
+	"sched_yield",
+	"sendmsg",
+	"sendto",
+	"sigaltstack",
+	"socket",
+	"tgkill",
+	"write",
+	"writev",
+}
+
+// Tracer records the syscalls made by a container that is run with the
+// seccomp profile returned by Profile, so that a minimal profile allowing
+// only those syscalls can be written with WriteProfile.
+//
+// The profile sends a notification for each syscall to a listener socket,
+// which the OCI runtime connects to in order to pass the seccomp notification
+// fd of the container to the Tracer.
+type Tracer struct {
+	path     string
+	listener *net.UnixListener
+	stop     chan struct{}
+	done     chan struct{}
+	err      error
+
+	mu       sync.Mutex
+	syscalls map[string]bool
+}
+
+// NewTracer returns a Tracer, listening for the seccomp notification fd of a
+// container on a unix socket at path.
+func NewTracer(path string) (*Tracer, error) {
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("while creating seccomp listener socket: %w", err)
+	}
+
+	t := newTracer()
+	t.path = path
+	t.listener = l
+	go func() {
+		defer close(t.done)
+		t.err = t.serve()
+	}()
+	return t, nil
+}
+
+func newTracer() *Tracer {
+	return &Tracer{
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		syscalls: make(map[string]bool),
+	}
+}
+
+// Profile returns the seccomp profile with which the container to be traced
+// must be run.
+func (t *Tracer) Profile() (*specs.LinuxSeccomp, error) {
+	names, err := syscallNames()
+	if err != nil {
+		return nil, err
+	}
+
+	traced := slice.Subtract(names, traceAllowed)
+
+	return &specs.LinuxSeccomp{
+		DefaultAction: specs.ActAllow,
+		ListenerPath:  t.path,
+		Syscalls: []specs.LinuxSyscall{
+			{Names: traced, Action: specs.ActNotify},
+		},
+	}, nil
+}
+
+// Stop stops the Tracer, once the traced container has exited.
+func (t *Tracer) Stop() error {
+	close(t.stop)
+	t.listener.Close()
+	<-t.done
+	return t.err
+}
+
+// Syscalls returns the sorted names of the syscalls made by the traced
+// container, and those that are always allowed.
+func (t *Tracer) Syscalls() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.syscalls)+len(traceAllowed))
+	names = append(names, traceAllowed...)
+	for name := range t.syscalls {
+		if !slice.ContainsString(traceAllowed, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteProfile writes a seccomp profile to path, which allows the syscalls
+// made by the traced container, and denies all others with EPERM.
+func (t *Tracer) WriteProfile(path string) error {
+	errno := uint(syscall.EPERM)
+	profile := cseccomp.Seccomp{
+		DefaultAction:   cseccomp.ActErrno,
+		DefaultErrnoRet: &errno,
+		Syscalls: []*cseccomp.Syscall{
+			{
+				Names:  t.Syscalls(),
+				Action: cseccomp.ActAllow,
+			},
+		},
+	}
+
+	b, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func (t *Tracer) record(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.syscalls[name] = true
+}
+
+// serve accepts the connection from the OCI runtime, and traces the syscalls
+// notified on the seccomp notification fd it passes.
+func (t *Tracer) serve() error {
+	conn, err := t.listener.AcceptUnix()
+	if err != nil {
+		select {
+		case <-t.stop:
+			// The container exited, or failed to start, without connecting.
+			return nil
+		default:
+			return err
+		}
+	}
+	defer conn.Close()
+
+	fd, err := receiveNotifyFd(conn)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	sylog.Debugf("Tracing syscalls via seccomp notification fd %d", fd)
+	return t.trace(fd)
+}
+
+// trace responds to the notifications on the seccomp notification fd, allowing
+// each syscall to continue and recording its name, until all processes in the
+// container have exited or the Tracer is stopped.
+func (t *Tracer) trace(fd int) error {
+	pfd := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-t.stop:
+			return nil
+		default:
+		}
+
+		n, err := unix.Poll(pfd, 100)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return fmt.Errorf("while polling seccomp notification fd: %w", err)
+		}
+		if n == 0 {
+			continue
+		}
+		if pfd[0].Revents&unix.POLLIN != 0 {
+			name, err := notifyContinue(fd)
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				t.record(name)
+			}
+			continue
+		}
+		if pfd[0].Revents&(unix.POLLHUP|unix.POLLERR|unix.POLLNVAL) != 0 {
+			return nil
+		}
+	}
+}
+
+// receiveNotifyFd reads the container process state sent by the OCI runtime
+// on conn, and returns the seccomp notification fd passed with it.
+func receiveNotifyFd(conn *net.UnixConn) (int, error) {
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, unix.CmsgSpace(4*4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return -1, fmt.Errorf("while reading container process state: %w", err)
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return -1, fmt.Errorf("while parsing container process state: %w", err)
+	}
+	var fds []int
+	for _, msg := range msgs {
+		rights, err := unix.ParseUnixRights(&msg)
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+
+	var state specs.ContainerProcessState
+	if err := json.Unmarshal(buf[:n], &state); err != nil {
+		closeFds(fds)
+		return -1, fmt.Errorf("while decoding container process state: %w", err)
+	}
+
+	fd := -1
+	for i, name := range state.Fds {
+		if name == specs.SeccompFdName && i < len(fds) {
+			fd = fds[i]
+			fds = append(fds[:i], fds[i+1:]...)
+			break
+		}
+	}
+	closeFds(fds)
+	if fd < 0 {
+		return -1, fmt.Errorf("no seccomp notification fd received from OCI runtime")
+	}
+	return fd, nil
+}
+
+func closeFds(fds []int) {
+	for _, fd := range fds {
+		unix.Close(fd)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package seccomp
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cseccomp "github.com/containers/common/pkg/seccomp"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
+	"golang.org/x/sys/unix"
+)
+
+func TestTracerWriteProfile(t *testing.T) {
+	tr := newTracer()
+	tr.record("openat")
+	tr.record("getdents64")
+	tr.record("write")
+
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := tr.WriteProfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profile cseccomp.Seccomp
+	if err := json.Unmarshal(b, &profile); err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.DefaultAction != cseccomp.ActErrno {
+		t.Errorf("got default action %v, want %v", profile.DefaultAction, cseccomp.ActErrno)
+	}
+	if len(profile.Syscalls) != 1 {
+		t.Fatalf("got %d syscall rules, want 1", len(profile.Syscalls))
+	}
+	names := profile.Syscalls[0].Names
+	if got, want := len(names), len(traceAllowed)+2; got != want {
+		t.Errorf("got %d syscalls, want %d", got, want)
+	}
+	for _, name := range append([]string{"openat", "getdents64"}, traceAllowed...) {
+		if !slice.ContainsString(names, name) {
+			t.Errorf("syscall %s not allowed by profile", name)
+		}
+	}
+}
+
+func TestTracerStopWithoutConnection(t *testing.T) {
+	tr, err := NewTracer(filepath.Join(t.TempDir(), "seccomp.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Stop(); err != nil {
+		t.Errorf("Stop() = %v, want nil", err)
+	}
+}
+
+func TestReceiveNotifyFd(t *testing.T) {
+	tests := []struct {
+		name    string
+		fds     []string
+		wantErr bool
+	}{
+		{"SeccompFd", []string{specs.SeccompFdName}, false},
+		{"SeccompFdSecond", []string{"other", specs.SeccompFdName}, false},
+		{"NoSeccompFd", []string{"other"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "seccomp.sock")
+			l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			// Stand in for the OCI runtime, sending the state with the fds of
+			// pipes, in place of the seccomp notification fd.
+			var fds []int
+			var want unix.Stat_t
+			for _, name := range tt.fds {
+				r, w, err := os.Pipe()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer r.Close()
+				defer w.Close()
+				if name == specs.SeccompFdName {
+					if err := unix.Fstat(int(r.Fd()), &want); err != nil {
+						t.Fatal(err)
+					}
+				}
+				fds = append(fds, int(r.Fd()))
+			}
+			state, err := json.Marshal(specs.ContainerProcessState{Fds: tt.fds})
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				c, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer c.Close()
+				if _, _, err := c.WriteMsgUnix(state, unix.UnixRights(fds...), nil); err != nil {
+					t.Error(err)
+				}
+			}()
+
+			conn, err := l.AcceptUnix()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			fd, err := receiveNotifyFd(conn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("receiveNotifyFd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer unix.Close(fd)
+
+			var got unix.Stat_t
+			if err := unix.Fstat(fd, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Dev != want.Dev || got.Ino != want.Ino {
+				t.Errorf("received fd does not match the fd sent as %s", specs.SeccompFdName)
+			}
+		})
+	}
+}
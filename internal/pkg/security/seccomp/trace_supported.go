@@ -0,0 +1,68 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build seccomp
+
+package seccomp
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	lseccomp "github.com/seccomp/libseccomp-golang"
+)
+
+// maxSyscall is greater than the highest syscall number of any architecture
+// supported by libseccomp.
+const maxSyscall = 1024
+
+// syscallNames returns the names of all syscalls of the native architecture.
+func syscallNames() ([]string, error) {
+	arch, err := lseccomp.GetNativeArch()
+	if err != nil {
+		return nil, fmt.Errorf("while determining native architecture: %w", err)
+	}
+
+	var names []string
+	for nr := 0; nr < maxSyscall; nr++ {
+		name, err := lseccomp.ScmpSyscall(nr).GetNameByArch(arch)
+		if err != nil || name == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no syscalls found for architecture %s", arch)
+	}
+	return names, nil
+}
+
+// notifyContinue receives a notification from the seccomp notification fd,
+// allows the syscall to continue, and returns its name. An empty name is
+// returned if the process making the syscall exited before it was handled.
+func notifyContinue(fd int) (string, error) {
+	req, err := lseccomp.NotifReceive(lseccomp.ScmpFd(fd))
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) {
+			return "", nil
+		}
+		return "", fmt.Errorf("while receiving seccomp notification: %w", err)
+	}
+
+	name, err := req.Data.Syscall.GetNameByArch(req.Data.Arch)
+	if err != nil {
+		name = fmt.Sprintf("%d", req.Data.Syscall)
+	}
+
+	resp := &lseccomp.ScmpNotifResp{
+		ID:    req.ID,
+		Flags: lseccomp.NotifRespFlagContinue,
+	}
+	if err := lseccomp.NotifRespond(lseccomp.ScmpFd(fd), resp); err != nil && !errors.Is(err, syscall.ENOENT) {
+		return "", fmt.Errorf("while responding to seccomp notification: %w", err)
+	}
+	return name, nil
+}
@@ -0,0 +1,20 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !seccomp
+
+package seccomp
+
+import (
+	"fmt"
+)
+
+func syscallNames() ([]string, error) {
+	return nil, fmt.Errorf("can't trace syscalls: seccomp not enabled at compilation time")
+}
+
+func notifyContinue(_ int) (string, error) {
+	return "", fmt.Errorf("can't trace syscalls: seccomp not enabled at compilation time")
+}
@@ -0,0 +1,409 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package signature
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+const (
+	// CosignSimpleSigningMediaType is the media type of a cosign signature payload.
+	CosignSimpleSigningMediaType types.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// CosignArtifactType is the artifact type of a cosign signature pushed to a registry as a
+	// referrer of the signed image.
+	CosignArtifactType types.MediaType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+	// CosignSignatureAnnotation is the layer annotation holding the base64 encoded signature of
+	// a cosign signature payload.
+	CosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// cosignSignatureType is the critical type of a cosign signature payload.
+	cosignSignatureType = "cosign container image signature"
+
+	// cosignSIFObjectName is the name of SIF objects holding a cosign signature.
+	cosignSIFObjectName = "cosign-signature"
+)
+
+// ErrNoCosignSignature is returned when an image has no cosign signature.
+var ErrNoCosignSignature = errors.New("image has no cosign signature")
+
+// ErrBlobIntegrity is returned when a blob of an OCI-SIF image does not match the digest or size
+// recorded in the image manifest.
+var ErrBlobIntegrity = errors.New("blob integrity compromised")
+
+// cosignPayload is the cosign 'simple signing' payload, which identifies a signed image by the
+// digest of its manifest.
+type cosignPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// cosignSignature is a cosign signature, as stored in a SIF object.
+type cosignSignature struct {
+	// Payload is the signed cosign simple signing payload.
+	Payload []byte `json:"payload"`
+	// Signature is the base64 encoded signature of Payload.
+	Signature string `json:"signature"`
+}
+
+// newCosignSignature returns a cosign signature, generated by s, for the image with manifest
+// digest d. If ref is not empty, it is recorded in the payload as the image repository.
+func newCosignSignature(ctx context.Context, s signature.Signer, d ggcrv1.Hash, ref string) (cosignSignature, error) {
+	var p cosignPayload
+	p.Critical.Identity.DockerReference = ref
+	p.Critical.Image.DockerManifestDigest = d.String()
+	p.Critical.Type = cosignSignatureType
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return cosignSignature{}, err
+	}
+
+	sig, err := s.SignMessage(bytes.NewReader(b), options.WithContext(ctx))
+	if err != nil {
+		return cosignSignature{}, fmt.Errorf("while signing payload: %w", err)
+	}
+
+	return cosignSignature{
+		Payload:   b,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}, nil
+}
+
+// verify checks that cs is a valid signature, by one of svs, for the image with manifest
+// digest d.
+func (cs cosignSignature) verify(ctx context.Context, svs []signature.Verifier, d ggcrv1.Hash) error {
+	sig, err := base64.StdEncoding.DecodeString(cs.Signature)
+	if err != nil {
+		return fmt.Errorf("while decoding signature: %w", err)
+	}
+
+	verified := false
+	for _, sv := range svs {
+		err := sv.VerifySignature(bytes.NewReader(sig), bytes.NewReader(cs.Payload), options.WithContext(ctx))
+		if err == nil {
+			verified = true
+			break
+		}
+		sylog.Debugf("Signature not verified by key: %v", err)
+	}
+	if !verified {
+		return errors.New("signature not verified by provided key material")
+	}
+
+	// The payload is only trusted once its signature is verified.
+	var p cosignPayload
+	if err := json.Unmarshal(cs.Payload, &p); err != nil {
+		return fmt.Errorf("while decoding payload: %w", err)
+	}
+	if p.Critical.Type != cosignSignatureType {
+		return fmt.Errorf("unsupported signature type %q", p.Critical.Type)
+	}
+	if p.Critical.Image.DockerManifestDigest != d.String() {
+		return fmt.Errorf("signature is for image %s, not %s", p.Critical.Image.DockerManifestDigest, d)
+	}
+	return nil
+}
+
+// ociSIFImage returns the single image held in the OCI-SIF f.
+func ociSIFImage(f *sif.FileImage) (ggcrv1.Image, error) {
+	ix, err := ocisif.ImageIndexFromFileImage(f)
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining image index: %w", err)
+	}
+	idxManifest, err := ix.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("while obtaining index manifest: %w", err)
+	}
+	if len(idxManifest.Manifests) != 1 {
+		return nil, fmt.Errorf("only single image oci-sif files are supported")
+	}
+	return ix.Image(idxManifest.Manifests[0].Digest)
+}
+
+// IsCosignSignature returns true if od is a SIF object holding a cosign signature, rather than a
+// PGP signature.
+func IsCosignSignature(od sif.Descriptor) bool {
+	return od.DataType() == sif.DataSignature && od.Name() == cosignSIFObjectName
+}
+
+// SignOCISIF adds a cosign compatible signature, generated by s, to the OCI-SIF image found at
+// path. The signature covers the manifest digest of the image, which is preserved when the image
+// is pushed to a registry, and is stored in a SIF object.
+func SignOCISIF(ctx context.Context, path string, s signature.Signer) error {
+	f, err := sif.LoadContainerFromPath(path)
+	if err != nil {
+		return err
+	}
+	defer f.UnloadContainer()
+
+	img, err := ociSIFImage(f)
+	if err != nil {
+		return err
+	}
+	d, err := img.Digest()
+	if err != nil {
+		return err
+	}
+
+	cs, err := newCosignSignature(ctx, s, d, "")
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+
+	// The signature object is not part of, or linked to, an object group, so that it does not
+	// affect the verification of PGP signatures of the image.
+	di, err := sif.NewDescriptorInput(sif.DataSignature, bytes.NewReader(b),
+		sif.OptObjectName(cosignSIFObjectName),
+		sif.OptNoGroup(),
+	)
+	if err != nil {
+		return err
+	}
+	return f.AddObject(di)
+}
+
+// VerifyOCISIF verifies that the OCI-SIF image found at path holds a cosign compatible signature
+// of its image manifest digest, that is valid for one of svs.
+func VerifyOCISIF(ctx context.Context, path string, svs ...signature.Verifier) error {
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return err
+	}
+	defer f.UnloadContainer()
+
+	return VerifyOCISIFFileImage(ctx, f, svs...)
+}
+
+// VerifyOCISIFFileImage verifies that the OCI-SIF image f holds a cosign compatible signature of
+// its image manifest digest, that is valid for one of svs. As the signature only covers the
+// manifest, the config and layer blobs of the image are also checked against the manifest, and an
+// error wrapping ErrBlobIntegrity is returned if they don't match.
+func VerifyOCISIFFileImage(ctx context.Context, f *sif.FileImage, svs ...signature.Verifier) error {
+	img, err := ociSIFImage(f)
+	if err != nil {
+		return err
+	}
+	d, err := img.Digest()
+	if err != nil {
+		return err
+	}
+
+	ods, err := f.GetDescriptors(
+		sif.WithDataType(sif.DataSignature),
+		func(od sif.Descriptor) (bool, error) { return IsCosignSignature(od), nil },
+	)
+	if err != nil {
+		return err
+	}
+
+	var sigs []cosignSignature
+	for _, od := range ods {
+		b, err := od.GetData()
+		if err != nil {
+			return err
+		}
+		var cs cosignSignature
+		if err := json.Unmarshal(b, &cs); err != nil {
+			return fmt.Errorf("while decoding signature object %d: %w", od.ID(), err)
+		}
+		sigs = append(sigs, cs)
+	}
+	if len(sigs) == 0 {
+		return ErrNoCosignSignature
+	}
+	if err := verifyOCISIFBlobs(f, img); err != nil {
+		return err
+	}
+	return verifyAny(ctx, sigs, svs, d)
+}
+
+// verifyOCISIFBlobs checks that the config and layer blobs of img, held in the OCI-SIF f, have
+// the digests and sizes recorded in its manifest.
+func verifyOCISIFBlobs(f *sif.FileImage, img ggcrv1.Image) error {
+	mf, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("while obtaining manifest: %w", err)
+	}
+
+	for _, desc := range append([]ggcrv1.Descriptor{mf.Config}, mf.Layers...) {
+		if desc.Digest.Algorithm != "sha256" {
+			return fmt.Errorf("blob %s: unsupported digest algorithm", desc.Digest)
+		}
+		od, err := f.GetDescriptor(sif.WithOCIBlobDigest(desc.Digest))
+		if err != nil {
+			return fmt.Errorf("while getting blob %s: %w", desc.Digest, err)
+		}
+		if od.Size() != desc.Size {
+			return fmt.Errorf("blob %s has size %d, expected %d: %w", desc.Digest, od.Size(), desc.Size, ErrBlobIntegrity)
+		}
+		h, _, err := ggcrv1.SHA256(od.GetReader())
+		if err != nil {
+			return fmt.Errorf("while hashing blob %s: %w", desc.Digest, err)
+		}
+		if h != desc.Digest {
+			return fmt.Errorf("blob %s has digest %s: %w", desc.Digest, h, ErrBlobIntegrity)
+		}
+	}
+	return nil
+}
+
+// SignRemote generates a cosign compatible signature, using s, for the image at ref in an OCI
+// registry, and pushes it to the registry as a referrer of the image.
+func SignRemote(ctx context.Context, ref name.Reference, s signature.Signer, opts ...remote.Option) error {
+	opts = append(opts, remote.WithContext(ctx))
+
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return fmt.Errorf("while retrieving image descriptor: %w", err)
+	}
+
+	cs, err := newCosignSignature(ctx, s, desc.Digest, ref.Context().Name())
+	if err != nil {
+		return err
+	}
+
+	sigImg := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	sigImg = mutate.ConfigMediaType(sigImg, CosignArtifactType)
+	sigImg, err = mutate.Append(sigImg, mutate.Addendum{
+		Layer: static.NewLayer(cs.Payload, CosignSimpleSigningMediaType),
+		Annotations: map[string]string{
+			CosignSignatureAnnotation: cs.Signature,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	sigImg = mutate.Subject(sigImg, *desc).(ggcrv1.Image)
+
+	d, err := sigImg.Digest()
+	if err != nil {
+		return err
+	}
+	return remote.Write(ref.Context().Digest(d.String()), sigImg, opts...)
+}
+
+// VerifyRemote verifies that the image at ref in an OCI registry has a cosign compatible
+// signature, pushed as a referrer, that is valid for one of svs.
+func VerifyRemote(ctx context.Context, ref name.Reference, svs []signature.Verifier, opts ...remote.Option) error {
+	opts = append(opts, remote.WithContext(ctx))
+
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return fmt.Errorf("while retrieving image descriptor: %w", err)
+	}
+
+	ix, err := remote.Referrers(ref.Context().Digest(desc.Digest.String()), opts...)
+	if err != nil {
+		return fmt.Errorf("while retrieving referrers: %w", err)
+	}
+	im, err := ix.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	var sigs []cosignSignature
+	for _, m := range im.Manifests {
+		if m.ArtifactType != string(CosignArtifactType) {
+			continue
+		}
+		sigImg, err := remote.Image(ref.Context().Digest(m.Digest.String()), opts...)
+		if err != nil {
+			return fmt.Errorf("while retrieving signature %s: %w", m.Digest, err)
+		}
+		cs, err := remoteSignatures(sigImg)
+		if err != nil {
+			return fmt.Errorf("while retrieving signature %s: %w", m.Digest, err)
+		}
+		sigs = append(sigs, cs...)
+	}
+	return verifyAny(ctx, sigs, svs, desc.Digest)
+}
+
+// remoteSignatures returns the cosign signatures held in the layers of sigImg.
+func remoteSignatures(sigImg ggcrv1.Image) ([]cosignSignature, error) {
+	mf, err := sigImg.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var sigs []cosignSignature
+	for _, ld := range mf.Layers {
+		if ld.MediaType != CosignSimpleSigningMediaType {
+			continue
+		}
+		l, err := sigImg.LayerByDigest(ld.Digest)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := l.Uncompressed()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, cosignSignature{
+			Payload:   b,
+			Signature: ld.Annotations[CosignSignatureAnnotation],
+		})
+	}
+	return sigs, nil
+}
+
+// verifyAny verifies that at least one of sigs is a valid signature, by one of svs, for the image
+// with manifest digest d.
+func verifyAny(ctx context.Context, sigs []cosignSignature, svs []signature.Verifier, d ggcrv1.Hash) error {
+	if len(sigs) == 0 {
+		return ErrNoCosignSignature
+	}
+
+	var errs []error
+	for i, cs := range sigs {
+		err := cs.verify(ctx, svs, d)
+		if err == nil {
+			sylog.Debugf("Verified cosign signature %d of %d for image %s", i+1, len(sigs), d)
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("no valid cosign signature found: %w", errors.Join(errs...))
+}
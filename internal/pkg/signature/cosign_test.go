@@ -0,0 +1,207 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package signature
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sigstore/sigstore/pkg/signature"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/sif"
+)
+
+// writeTestOCISIF writes an OCI-SIF holding a random single image, and returns its path.
+func writeTestOCISIF(t *testing.T) string {
+	t.Helper()
+
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+
+	path := filepath.Join(t.TempDir(), "image.oci.sif")
+	if err := ocisif.Write(path, ii); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSignVerifyOCISIF(t *testing.T) {
+	path := writeTestOCISIF(t)
+
+	ctx := context.Background()
+
+	if err := VerifyOCISIF(ctx, path, getTestVerifier(t, "ecdsa-public.pem")); !errors.Is(err, ErrNoCosignSignature) {
+		t.Fatalf("got error %v, want %v", err, ErrNoCosignSignature)
+	}
+
+	if err := SignOCISIF(ctx, path, getTestSigner(t, "ecdsa-private.pem")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		svs     []signature.Verifier
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			svs:  []signature.Verifier{getTestVerifier(t, "ecdsa-public.pem")},
+		},
+		{
+			name: "ValidSecondKey",
+			svs: []signature.Verifier{
+				getTestVerifier(t, "rsa-public.pem"),
+				getTestVerifier(t, "ecdsa-public.pem"),
+			},
+		},
+		{
+			name:    "WrongKey",
+			svs:     []signature.Verifier{getTestVerifier(t, "rsa-public.pem")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyOCISIF(ctx, path, tt.svs...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyOCISIF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// tamperOCISIFBlob overwrites the start of the config, or first layer, blob of the image in the
+// OCI-SIF at path.
+func tamperOCISIFBlob(t *testing.T, path string, config bool) {
+	t.Helper()
+
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := ociSIFImage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := mf.Layers[0].Digest
+	if config {
+		digest = mf.Config.Digest
+	}
+	od, err := f.GetDescriptor(sif.WithOCIBlobDigest(digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := od.Offset()
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	if _, err := fp.WriteAt([]byte("tampered"), offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyOCISIFTampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		config bool
+	}{
+		{name: "Layer"},
+		{name: "Config", config: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestOCISIF(t)
+
+			ctx := context.Background()
+			if err := SignOCISIF(ctx, path, getTestSigner(t, "ecdsa-private.pem")); err != nil {
+				t.Fatal(err)
+			}
+			tamperOCISIFBlob(t, path, tt.config)
+
+			err := VerifyOCISIF(ctx, path, getTestVerifier(t, "ecdsa-public.pem"))
+			if !errors.Is(err, ErrBlobIntegrity) {
+				t.Errorf("VerifyOCISIF() error = %v, want %v", err, ErrBlobIntegrity)
+			}
+		})
+	}
+}
+
+func TestSignVerifyRemote(t *testing.T) {
+	s := httptest.NewServer(registry.New())
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(u.Host + "/test/image:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	svs := []signature.Verifier{getTestVerifier(t, "ecdsa-public.pem")}
+
+	if err := VerifyRemote(ctx, ref, svs); !errors.Is(err, ErrNoCosignSignature) {
+		t.Fatalf("got error %v, want %v", err, ErrNoCosignSignature)
+	}
+
+	if err := SignRemote(ctx, ref, getTestSigner(t, "ecdsa-private.pem")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyRemote(ctx, ref, svs); err != nil {
+		t.Errorf("VerifyRemote() error = %v", err)
+	}
+	if err := VerifyRemote(ctx, ref, []signature.Verifier{getTestVerifier(t, "rsa-public.pem")}); err == nil {
+		t.Errorf("VerifyRemote() with wrong key succeeded")
+	}
+
+	// A signature must not verify a different image at the same reference.
+	other, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRemote(ctx, ref, svs); !errors.Is(err, ErrNoCosignSignature) {
+		t.Errorf("got error %v, want %v", err, ErrNoCosignSignature)
+	}
+}
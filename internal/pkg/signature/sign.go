@@ -12,6 +12,7 @@ import (
 	"github.com/sylabs/sif/v2/pkg/integrity"
 	"github.com/sylabs/sif/v2/pkg/sif"
 	"github.com/sylabs/singularity/v4/internal/pkg/sypgp"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
 )
 
 type signer struct {
@@ -37,6 +38,9 @@ func OptSignEntitySelector(f sypgp.EntitySelector) SignOpt {
 		if err != nil {
 			return err
 		}
+		if err := fips.CheckEntity(e); err != nil {
+			return err
+		}
 
 		s.opts = append(s.opts, integrity.OptSignWithEntity(e))
 
@@ -0,0 +1,147 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package snapshotter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	snapshotsapi "github.com/containerd/containerd/api/services/snapshots/v1"
+	"github.com/containerd/containerd/contrib/snapshotservice"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sylabs/singularity/v4/internal/pkg/ociplatform"
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential/ociauth"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"google.golang.org/grpc"
+)
+
+// Serve serves sn as a containerd proxy snapshotter plugin, over gRPC on the
+// unix socket at address, until ctx is done.
+func Serve(ctx context.Context, sn snapshots.Snapshotter, address string) error {
+	if err := os.MkdirAll(filepath.Dir(address), 0o700); err != nil {
+		return err
+	}
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", address)
+	if err != nil {
+		return err
+	}
+
+	rpc := grpc.NewServer()
+	snapshotsapi.RegisterSnapshotsServer(rpc, snapshotservice.FromSnapshotter(sn))
+
+	go func() {
+		<-ctx.Done()
+		rpc.GracefulStop()
+	}()
+
+	sylog.Infof("Serving OCI-SIF snapshotter on %s", address)
+	if err := rpc.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// RegistryLayerSource returns a LayerSource retrieving layers from the
+// registry of an image, for the platform of the host, authenticating with the
+// credentials of the user. As containerd prepares a snapshot for each layer of
+// an image, the manifest of an image is fetched once, and again only if it
+// doesn't list a layer, e.g. after a tag was moved.
+func RegistryLayerSource() LayerSource {
+	var mu sync.Mutex
+	manifests := make(map[string]*ggcrv1.Manifest)
+
+	return func(ctx context.Context, imageRef string, digest ggcrv1.Hash) (ggcrv1.Layer, error) {
+		ref, err := name.ParseReference(imageRef)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		m, ok := manifests[imageRef]
+		mu.Unlock()
+		if !ok || !hasLayer(m, digest) {
+			if m, err = fetchManifest(ctx, ref); err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			if len(manifests) >= maxCachedManifests {
+				manifests = make(map[string]*ggcrv1.Manifest)
+			}
+			manifests[imageRef] = m
+			mu.Unlock()
+		}
+
+		for _, desc := range m.Layers {
+			if desc.Digest != digest {
+				continue
+			}
+			layer, err := remote.Layer(ref.Context().Digest(digest.String()),
+				remote.WithContext(ctx),
+				ociauth.AuthOptn(nil, ""),
+			)
+			if err != nil {
+				return nil, err
+			}
+			return &registryLayer{Layer: layer, mediaType: desc.MediaType}, nil
+		}
+		return nil, fmt.Errorf("layer %s not found in image manifest", digest)
+	}
+}
+
+// maxCachedManifests is the number of image manifests cached by the
+// LayerSource returned by RegistryLayerSource, before the cache is cleared.
+const maxCachedManifests = 64
+
+// registryLayer is a layer fetched from a registry, with the media type
+// recorded in the manifest of its image.
+type registryLayer struct {
+	ggcrv1.Layer
+	mediaType types.MediaType
+}
+
+func (l *registryLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
+
+// fetchManifest fetches the manifest of the image ref, for the platform of
+// the host.
+func fetchManifest(ctx context.Context, ref name.Reference) (*ggcrv1.Manifest, error) {
+	platform, err := ociplatform.DefaultPlatform()
+	if err != nil {
+		return nil, err
+	}
+	img, err := remote.Image(ref,
+		remote.WithContext(ctx),
+		remote.WithPlatform(*platform),
+		ociauth.AuthOptn(nil, ""),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return img.Manifest()
+}
+
+// hasLayer returns true if the manifest m lists the layer with digest.
+func hasLayer(m *ggcrv1.Manifest, digest ggcrv1.Hash) bool {
+	for _, desc := range m.Layers {
+		if desc.Digest == digest {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package snapshotter provides a containerd snapshotter that mounts the
+// squashfs layers of OCI-SIF images directly, rather than unpacking them.
+//
+// Snapshots are managed by the containerd overlayfs snapshotter. When the CRI
+// plugin of containerd prepares a snapshot to unpack a squashfs layer, the
+// layer is fetched from the registry and mounted read-only instead, and the
+// unpack is reported as already done. Other layers are unpacked by containerd
+// as usual.
+package snapshotter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/containerd/containerd/snapshots/overlay"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/loop"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// targetRefLabel is set by containerd on the snapshot prepared to unpack
+	// a layer, to the name of the snapshot it will be committed as.
+	targetRefLabel = "containerd.io/snapshot.ref"
+	// imageRefLabel and layerDigestLabel are set by the CRI plugin of
+	// containerd, when snapshot annotations are enabled.
+	imageRefLabel    = "containerd.io/snapshot/cri.image-ref"
+	layerDigestLabel = "containerd.io/snapshot/cri.layer-digest"
+
+	// LayerLabel records the digest of the squashfs layer mounted as the
+	// content of a snapshot.
+	LayerLabel = "containerd.io/snapshot/singularity.oci-sif-layer"
+)
+
+// LayerSource returns the layer with digest of the image imageRef.
+type LayerSource func(ctx context.Context, imageRef string, digest ggcrv1.Hash) (ggcrv1.Layer, error)
+
+// Snapshotter is a containerd snapshotter mounting the squashfs layers of
+// OCI-SIF images.
+type Snapshotter struct {
+	snapshots.Snapshotter
+
+	layerDir string
+	source   LayerSource
+	mount    func(path, mountPath string) error
+	unmount  func(mountPath string) error
+
+	// mu serializes fetching, mounting, and unmounting of layers, with the
+	// creation and removal of the snapshots using them.
+	mu sync.Mutex
+}
+
+// New returns a snapshotter storing snapshots and layers below root. Layers
+// are retrieved with source. The layers of existing snapshots are mounted,
+// if not already.
+func New(ctx context.Context, root string, source LayerSource) (*Snapshotter, error) {
+	sn, err := overlay.NewSnapshotter(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshotter{
+		Snapshotter: sn,
+		layerDir:    filepath.Join(root, "layers"),
+		source:      source,
+		mount:       mountSquashfs,
+		unmount:     unmountSquashfs,
+	}
+	if err := os.MkdirAll(s.layerDir, 0o700); err != nil {
+		sn.Close()
+		return nil, err
+	}
+	if err := s.restore(ctx); err != nil {
+		sn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// restore mounts the layers of existing snapshots, which are no longer
+// mounted after a reboot.
+func (s *Snapshotter) restore(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.Snapshotter.Walk(ctx, func(ctx context.Context, info snapshots.Info) error {
+		layer, ok := info.Labels[LayerLabel]
+		if !ok {
+			return nil
+		}
+		digest, err := ggcrv1.NewHash(layer)
+		if err != nil {
+			return fmt.Errorf("snapshot %q: %w", info.Name, err)
+		}
+		if err := s.mountLayer(digest); err != nil {
+			sylog.Warningf("Snapshot %q is unusable, cannot mount layer %s: %v", info.Name, digest, err)
+		}
+		return nil
+	})
+	// no snapshot was ever created
+	if errdefs.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Prepare creates an active snapshot key, with parent. When the snapshot is
+// prepared by containerd to unpack a squashfs layer, the layer is mounted and
+// committed as the target snapshot instead, and an error wrapping
+// errdefs.ErrAlreadyExists is returned, so that containerd skips the unpack.
+func (s *Snapshotter) Prepare(ctx context.Context, key, parent string, opts ...snapshots.Opt) ([]mount.Mount, error) {
+	var info snapshots.Info
+	for _, opt := range opts {
+		if err := opt(&info); err != nil {
+			return nil, err
+		}
+	}
+
+	target, ok := info.Labels[targetRefLabel]
+	if !ok || info.Labels[imageRefLabel] == "" || info.Labels[layerDigestLabel] == "" {
+		return s.Snapshotter.Prepare(ctx, key, parent, opts...)
+	}
+	imageRef := info.Labels[imageRefLabel]
+	digest, err := ggcrv1.NewHash(info.Labels[layerDigestLabel])
+	if err != nil {
+		return nil, fmt.Errorf("invalid layer digest: %w", err)
+	}
+
+	// If the layer can't be looked up, e.g. as the registry requires
+	// credentials only known to containerd, it is left to containerd to
+	// unpack.
+	layer, err := s.source(ctx, imageRef, digest)
+	if err != nil {
+		sylog.Warningf("While retrieving layer %s of %s, leaving it to containerd: %v", digest, imageRef, err)
+		return s.Snapshotter.Prepare(ctx, key, parent, opts...)
+	}
+	mt, err := layer.MediaType()
+	if err != nil {
+		sylog.Warningf("While retrieving media type of layer %s of %s, leaving it to containerd: %v", digest, imageRef, err)
+		return s.Snapshotter.Prepare(ctx, key, parent, opts...)
+	}
+	if mt != ocisif.SquashfsLayerMediaType {
+		return s.Snapshotter.Prepare(ctx, key, parent, opts...)
+	}
+
+	sylog.Debugf("Mounting squashfs layer %s of %s as snapshot %q", digest, imageRef, target)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.addLayer(layer, digest); err != nil {
+		return nil, fmt.Errorf("while mounting layer %s of %s: %w", digest, imageRef, err)
+	}
+	if err := s.commitLayer(ctx, key, parent, target, digest, opts); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("target snapshot %q: %w", target, errdefs.ErrAlreadyExists)
+}
+
+// commitLayer prepares the snapshot key, with parent, replaces its empty
+// content directory with a link to the mounted layer with digest, and
+// commits it as target.
+func (s *Snapshotter) commitLayer(ctx context.Context, key, parent, target string, digest ggcrv1.Hash, opts []snapshots.Opt) (err error) {
+	opts = append(opts, snapshots.WithLabels(map[string]string{LayerLabel: digest.String()}))
+	mounts, err := s.Snapshotter.Prepare(ctx, key, parent, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rerr := s.Snapshotter.Remove(ctx, key); rerr != nil {
+				sylog.Warningf("While removing snapshot %q: %v", key, rerr)
+			}
+		}
+	}()
+
+	upper, err := upperDir(mounts)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(upper); err != nil {
+		return err
+	}
+	if err := os.Symlink(s.layerMountPath(digest), upper); err != nil {
+		return err
+	}
+
+	err = s.Snapshotter.Commit(ctx, target, key, opts...)
+	if errdefs.IsAlreadyExists(err) {
+		// concurrent unpack of the same layer
+		if rerr := s.Snapshotter.Remove(ctx, key); rerr != nil {
+			sylog.Warningf("While removing snapshot %q: %v", key, rerr)
+		}
+		return nil
+	}
+	return err
+}
+
+// Remove removes the snapshot key, and unmounts its squashfs layer once no
+// longer used by any snapshot.
+func (s *Snapshotter) Remove(ctx context.Context, key string) error {
+	info, err := s.Snapshotter.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := s.Snapshotter.Remove(ctx, key); err != nil {
+		return err
+	}
+
+	layer, ok := info.Labels[LayerLabel]
+	if !ok {
+		return nil
+	}
+	digest, err := ggcrv1.NewHash(layer)
+	if err != nil {
+		return err
+	}
+	return s.releaseLayer(ctx, digest)
+}
+
+// addLayer fetches layer, with digest, and mounts it, if not already. It is
+// called with s.mu held.
+func (s *Snapshotter) addLayer(layer ggcrv1.Layer, digest ggcrv1.Hash) error {
+	path := s.layerPath(digest)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := fetchLayer(layer, digest, path); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return s.mountLayer(digest)
+}
+
+// releaseLayer unmounts and removes the layer with digest, if no snapshot
+// uses it.
+func (s *Snapshotter) releaseLayer(ctx context.Context, digest ggcrv1.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := false
+	err := s.Snapshotter.Walk(ctx, func(ctx context.Context, info snapshots.Info) error {
+		used = used || info.Labels[LayerLabel] == digest.String()
+		return nil
+	})
+	if err != nil || used {
+		return err
+	}
+
+	sylog.Debugf("Unmounting unused squashfs layer %s", digest)
+	mountPath := s.layerMountPath(digest)
+	if mounted, err := isMountPoint(mountPath); err != nil {
+		return err
+	} else if mounted {
+		if err := s.unmount(mountPath); err != nil {
+			return fmt.Errorf("while unmounting layer %s: %w", digest, err)
+		}
+	}
+	return os.RemoveAll(filepath.Dir(mountPath))
+}
+
+// mountLayer mounts the fetched layer with digest, if not already. It is
+// called with s.mu held.
+func (s *Snapshotter) mountLayer(digest ggcrv1.Hash) error {
+	mountPath := s.layerMountPath(digest)
+	if err := os.MkdirAll(mountPath, 0o755); err != nil {
+		return err
+	}
+	if mounted, err := isMountPoint(mountPath); err != nil || mounted {
+		return err
+	}
+	return s.mount(s.layerPath(digest), mountPath)
+}
+
+// layerPath returns the path of the squashfs file of the layer with digest.
+func (s *Snapshotter) layerPath(digest ggcrv1.Hash) string {
+	return filepath.Join(s.layerDir, digest.Algorithm, digest.Hex, "layer.sqfs")
+}
+
+// layerMountPath returns the path at which the layer with digest is mounted.
+func (s *Snapshotter) layerMountPath(digest ggcrv1.Hash) string {
+	return filepath.Join(s.layerDir, digest.Algorithm, digest.Hex, "rootfs")
+}
+
+// fetchLayer writes the content of layer to path, verifying that it matches
+// digest.
+func fetchLayer(layer ggcrv1.Layer, digest ggcrv1.Hash, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.CreateTemp(filepath.Dir(path), "layer-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	got, _, err := ggcrv1.SHA256(io.TeeReader(rc, f))
+	if err != nil {
+		return err
+	}
+	if got != digest {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", digest, got)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// upperDir returns the directory holding the content of the active snapshot
+// mounted by mounts, as returned by the overlayfs snapshotter.
+func upperDir(mounts []mount.Mount) (string, error) {
+	if len(mounts) != 1 {
+		return "", fmt.Errorf("unexpected number of snapshot mounts: %d", len(mounts))
+	}
+	m := mounts[0]
+	switch m.Type {
+	case "bind":
+		return m.Source, nil
+	case "overlay":
+		for _, o := range m.Options {
+			if dir, ok := strings.CutPrefix(o, "upperdir="); ok {
+				return dir, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no upper directory in %s snapshot mount", m.Type)
+}
+
+// isMountPoint returns true if path is a mount point.
+func isMountPoint(path string) (bool, error) {
+	var st, parent unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return false, err
+	}
+	if err := unix.Lstat(filepath.Dir(path), &parent); err != nil {
+		return false, err
+	}
+	return st.Dev != parent.Dev || st.Ino == parent.Ino, nil
+}
+
+// mountSquashfs attaches the squashfs file at path to a loop device, and
+// mounts it read-only at mountPath with the kernel squashfs driver.
+func mountSquashfs(path, mountPath string) error {
+	loopDev := &loop.Device{
+		MaxLoopDevices: loop.GetMaxLoopDevices(),
+		Shared:         true,
+		Info: &unix.LoopInfo64{
+			Flags: unix.LO_FLAGS_AUTOCLEAR | unix.LO_FLAGS_READ_ONLY,
+		},
+	}
+	idx := 0
+	if err := loopDev.AttachFromPath(path, os.O_RDONLY, &idx); err != nil {
+		return fmt.Errorf("failed to attach %s: %w", path, err)
+	}
+	// The loop device is cleared automatically once the squashfs filesystem
+	// is unmounted.
+	defer loopDev.Close()
+
+	flags := uintptr(syscall.MS_RDONLY | syscall.MS_NODEV | syscall.MS_NOSUID)
+	if err := syscall.Mount(fmt.Sprintf("/dev/loop%d", idx), mountPath, "squashfs", flags, ""); err != nil {
+		return fmt.Errorf("while mounting squashfs: %w", err)
+	}
+	return nil
+}
+
+// unmountSquashfs unmounts the squashfs filesystem at mountPath. A layer
+// still in use by a container is detached, and released by the kernel once
+// the container exits.
+func unmountSquashfs(mountPath string) error {
+	err := syscall.Unmount(mountPath, 0)
+	if errors.Is(err, syscall.EBUSY) {
+		err = syscall.Unmount(mountPath, syscall.MNT_DETACH)
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package snapshotter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/snapshots"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"gotest.tools/v3/assert"
+)
+
+func TestUpperDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		mounts  []mount.Mount
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Bind",
+			mounts: []mount.Mount{{Type: "bind", Source: "/s/1/fs", Options: []string{"rw", "rbind"}}},
+			want:   "/s/1/fs",
+		},
+		{
+			name: "Overlay",
+			mounts: []mount.Mount{{
+				Type:    "overlay",
+				Source:  "overlay",
+				Options: []string{"index=off", "workdir=/s/2/work", "upperdir=/s/2/fs", "lowerdir=/s/1/fs"},
+			}},
+			want: "/s/2/fs",
+		},
+		{
+			name:    "NoUpper",
+			mounts:  []mount.Mount{{Type: "overlay", Source: "overlay", Options: []string{"lowerdir=/s/1/fs"}}},
+			wantErr: true,
+		},
+		{
+			name:    "None",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := upperDir(tt.mounts)
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestSnapshotter(t *testing.T) {
+	ctx := context.Background()
+
+	layers := map[string]ggcrv1.Layer{
+		"sqfs": static.NewLayer([]byte("squashfs layer"), ocisif.SquashfsLayerMediaType),
+		"tar":  static.NewLayer([]byte("tar layer"), types.OCILayer),
+	}
+	source := func(_ context.Context, imageRef string, _ ggcrv1.Hash) (ggcrv1.Layer, error) {
+		return layers[imageRef], nil
+	}
+
+	root := t.TempDir()
+	s, err := New(ctx, root, source)
+	assert.NilError(t, err)
+	t.Cleanup(func() { s.Close() })
+	var mounted []string
+	s.mount = func(path, mountPath string) error {
+		_, err := os.Stat(path)
+		mounted = append(mounted, mountPath)
+		return err
+	}
+
+	unpackLabels := func(imageRef, target string) snapshots.Opt {
+		digest, err := layers[imageRef].Digest()
+		assert.NilError(t, err)
+		return snapshots.WithLabels(map[string]string{
+			targetRefLabel:   target,
+			imageRefLabel:    imageRef,
+			layerDigestLabel: digest.String(),
+		})
+	}
+
+	// a squashfs layer is mounted, and committed as the target snapshot
+	_, err = s.Prepare(ctx, "extract-1", "", unpackLabels("sqfs", "layer-1"))
+	assert.Assert(t, errdefs.IsAlreadyExists(err))
+	info, err := s.Stat(ctx, "layer-1")
+	assert.NilError(t, err)
+	assert.Equal(t, info.Kind, snapshots.KindCommitted)
+	digest, err := layers["sqfs"].Digest()
+	assert.NilError(t, err)
+	assert.Equal(t, info.Labels[LayerLabel], digest.String())
+	assert.DeepEqual(t, mounted, []string{s.layerMountPath(digest)})
+	_, err = s.Stat(ctx, "extract-1")
+	assert.Assert(t, errdefs.IsNotFound(err))
+
+	// a tar layer is left to containerd to unpack
+	mounts, err := s.Prepare(ctx, "extract-2", "layer-1", unpackLabels("tar", "layer-2"))
+	assert.NilError(t, err)
+	upper, err := upperDir(mounts)
+	assert.NilError(t, err)
+	fi, err := os.Lstat(upper)
+	assert.NilError(t, err)
+	assert.Assert(t, fi.IsDir())
+	assert.NilError(t, s.Commit(ctx, "layer-2", "extract-2"))
+
+	// the layer is unmounted with the last snapshot using it
+	assert.NilError(t, s.Remove(ctx, "layer-2"))
+	_, err = os.Stat(s.layerPath(digest))
+	assert.NilError(t, err)
+	assert.NilError(t, s.Remove(ctx, "layer-1"))
+	_, err = os.Stat(filepath.Dir(s.layerPath(digest)))
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestSnapshotterDigestMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	layer := static.NewLayer([]byte("squashfs layer"), ocisif.SquashfsLayerMediaType)
+	source := func(context.Context, string, ggcrv1.Hash) (ggcrv1.Layer, error) {
+		return layer, nil
+	}
+
+	s, err := New(ctx, t.TempDir(), source)
+	assert.NilError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	_, err = s.Prepare(ctx, "extract-1", "", snapshots.WithLabels(map[string]string{
+		targetRefLabel:   "layer-1",
+		imageRefLabel:    "image",
+		layerDigestLabel: "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+	}))
+	assert.ErrorContains(t, err, "digest mismatch")
+	_, err = s.Stat(ctx, "layer-1")
+	assert.Assert(t, errdefs.IsNotFound(err))
+}
+
+func TestSnapshotterSourceError(t *testing.T) {
+	ctx := context.Background()
+
+	source := func(context.Context, string, ggcrv1.Hash) (ggcrv1.Layer, error) {
+		return nil, errors.New("unauthorized")
+	}
+
+	s, err := New(ctx, t.TempDir(), source)
+	assert.NilError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	// a layer that can't be retrieved is left to containerd to unpack
+	mounts, err := s.Prepare(ctx, "extract-1", "", snapshots.WithLabels(map[string]string{
+		targetRefLabel:   "layer-1",
+		imageRefLabel:    "image",
+		layerDigestLabel: "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+	}))
+	assert.NilError(t, err)
+	_, err = upperDir(mounts)
+	assert.NilError(t, err)
+	info, err := s.Stat(ctx, "extract-1")
+	assert.NilError(t, err)
+	assert.Equal(t, info.Kind, snapshots.KindActive)
+}
+
+func TestRegistryLayerSource(t *testing.T) {
+	ctx := context.Background()
+
+	var manifestGets atomic.Int32
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") {
+			manifestGets.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	sqfs := static.NewLayer([]byte("squashfs layer"), ocisif.SquashfsLayerMediaType)
+	tar := static.NewLayer([]byte("tar layer"), types.OCILayer)
+	img, err := mutate.AppendLayers(empty.Image, sqfs, tar)
+	assert.NilError(t, err)
+	imageRef := strings.TrimPrefix(srv.URL, "http://") + "/test/image:latest"
+	ref, err := name.ParseReference(imageRef)
+	assert.NilError(t, err)
+	assert.NilError(t, remote.Write(ref, img))
+	manifestGets.Store(0)
+
+	source := RegistryLayerSource()
+	for _, want := range []ggcrv1.Layer{sqfs, tar} {
+		digest, err := want.Digest()
+		assert.NilError(t, err)
+		layer, err := source(ctx, imageRef, digest)
+		assert.NilError(t, err)
+		mt, err := layer.MediaType()
+		assert.NilError(t, err)
+		wantMT, err := want.MediaType()
+		assert.NilError(t, err)
+		assert.Equal(t, mt, wantMT)
+		got, err := layer.Digest()
+		assert.NilError(t, err)
+		assert.Equal(t, got, digest)
+	}
+	// the manifest is fetched once for all the layers of the image
+	assert.Equal(t, manifestGets.Load(), int32(1))
+
+	// an unknown layer causes the manifest to be fetched again
+	_, err = source(ctx, imageRef, ggcrv1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0", 64)})
+	assert.ErrorContains(t, err, "not found in image manifest")
+	assert.Equal(t, manifestGets.Load(), int32(2))
+}
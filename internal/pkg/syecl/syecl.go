@@ -12,6 +12,7 @@ package syecl
 
 import (
 	"context"
+	"crypto"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -21,8 +22,11 @@ import (
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	toml "github.com/pelletier/go-toml/v2"
+	"github.com/sigstore/sigstore/pkg/signature"
 	"github.com/sylabs/sif/v2/pkg/integrity"
 	"github.com/sylabs/sif/v2/pkg/sif"
+	sifsignature "github.com/sylabs/singularity/v4/internal/pkg/signature"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
 
 var (
@@ -46,11 +50,14 @@ type EclConfig struct {
 //		blacklist: none of the KeyFP should be present
 //	DirPath: containers must be stored in this directory path
 //	KeyFPs: list of Key Fingerprints of entities to verify
+//	KeyPaths: list of paths to public keys of entities to verify, by their
+//		cosign signatures of OCI-SIF images
 type Execgroup struct {
 	TagName  string   `toml:"tagname"`
 	ListMode string   `toml:"mode"`
 	DirPath  string   `toml:"dirpath"`
 	KeyFPs   []string `toml:"keyfp"`
+	KeyPaths []string `toml:"keypath,omitempty"`
 }
 
 // LoadConfig opens an ECL config file and unmarshals it into structures
@@ -110,81 +117,175 @@ func (ecl *EclConfig) ValidateConfig() error {
 				return fmt.Errorf("expecting a 40 chars hex fingerprint string")
 			}
 		}
+		for _, k := range v.KeyPaths {
+			if !filepath.IsAbs(k) {
+				return fmt.Errorf("keypath entries must be absolute paths: %s", k)
+			}
+		}
 	}
 
 	return nil
 }
 
-// checkWhiteList evaluates authorization by requiring at least 1 entity
-func checkWhiteList(v *integrity.Verifier, egroup *Execgroup) (ok bool, err error) {
-	// get signing entities fingerprints that have signed all selected objects
-	keyfps, err := v.AllSignedBy()
-	if err != nil {
-		return
+// entities returns the signing entities listed in egroup, identified by their
+// lower case key fingerprint, or by the path of their public key.
+func entities(egroup *Execgroup) []string {
+	ids := make([]string, 0, len(egroup.KeyFPs)+len(egroup.KeyPaths))
+	for _, fp := range egroup.KeyFPs {
+		ids = append(ids, strings.ToLower(fp))
 	}
+	return append(ids, egroup.KeyPaths...)
+}
 
+// checkWhiteList evaluates authorization by requiring at least 1 entity
+func checkWhiteList(signers map[string]bool, egroup *Execgroup) (ok bool, err error) {
 	// were the selected objects signed by an authorized entity?
-	for _, v := range egroup.KeyFPs {
-		for _, u := range keyfps {
-			if strings.EqualFold(v, hex.EncodeToString(u[:])) {
-				ok = true
-			}
+	for _, id := range entities(egroup) {
+		if signers[id] {
+			return true, nil
+		}
+	}
+
+	return false, errNotSignedByRequired
+}
+
+// checkWhiteStrict evaluates authorization by requiring all entities
+func checkWhiteStrict(signers map[string]bool, egroup *Execgroup) (ok bool, err error) {
+	// were all selected objects signed by all authorized entity?
+	for _, id := range entities(egroup) {
+		if !signers[id] {
+			return false, errNotSignedByRequired
 		}
 	}
 
-	if !ok {
-		return false, errNotSignedByRequired
+	return true, nil
+}
+
+// checkBlackList evaluates authorization by requiring all entities to be absent
+func checkBlackList(signers map[string]bool, egroup *Execgroup) (ok bool, err error) {
+	// was a selected object signed by a forbidden entity?
+	for _, id := range entities(egroup) {
+		if signers[id] {
+			return false, errSignedByForbidden
+		}
 	}
 
 	return true, nil
 }
 
-// checkWhiteStrict evaluates authorization by requiring all entities
-func checkWhiteStrict(v *integrity.Verifier, egroup *Execgroup) (ok bool, err error) {
-	// get signing entities fingerprints that have signed all selected objects
-	keyfps, err := v.AllSignedBy()
+// checkList evaluates authorization of signers according to the list mode of
+// egroup.
+func checkList(signers map[string]bool, egroup *Execgroup) (ok bool, err error) {
+	switch egroup.ListMode {
+	case "whitelist":
+		return checkWhiteList(signers, egroup)
+	case "whitestrict":
+		return checkWhiteStrict(signers, egroup)
+	case "blacklist":
+		return checkBlackList(signers, egroup)
+	}
+
+	return false, fmt.Errorf("ecl config file invalid")
+}
+
+// pgpSigners returns the fingerprints of the entities that have signed the
+// objects selected by v. In blacklist mode, entities that have signed any
+// selected object are returned, otherwise those that have signed all selected
+// objects.
+func pgpSigners(v *integrity.Verifier, egroup *Execgroup) (map[string]bool, error) {
+	signedBy := v.AllSignedBy
+	if egroup.ListMode == "blacklist" {
+		signedBy = v.AnySignedBy
+	}
+
+	keyfps, err := signedBy()
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	// were all selected objects signed by all authorized entity?
-	m := map[string]bool{}
-	for _, v := range egroup.KeyFPs {
-		m[v] = false
-		for _, u := range keyfps {
-			if strings.EqualFold(v, hex.EncodeToString(u[:])) {
-				m[v] = true
-			}
-		}
+	signers := make(map[string]bool)
+	for _, fp := range keyfps {
+		signers[hex.EncodeToString(fp[:])] = true
 	}
+	return signers, nil
+}
 
-	for _, v := range m {
-		if !v {
-			return false, errNotSignedByRequired
-		}
+// verifyPGP validates the PGP signatures of f against kr, and returns the
+// fingerprints of the signing entities.
+func verifyPGP(ctx context.Context, egroup *Execgroup, f *sif.FileImage, kr openpgp.KeyRing, opts ...integrity.VerifierOpt) (map[string]bool, error) {
+	opts = append(opts,
+		integrity.OptVerifyWithContext(ctx),
+		integrity.OptVerifyWithKeyRing(kr),
+	)
+
+	v, err := integrity.NewVerifier(f, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	return true, nil
+	// Validate signature.
+	if err := v.Verify(); err != nil {
+		return nil, fmt.Errorf("image signature not valid: %v", err)
+	}
+
+	return pgpSigners(v, egroup)
 }
 
-// checkBlackList evaluates authorization by requiring all entities to be absent
-func checkBlackList(v *integrity.Verifier, egroup *Execgroup) (ok bool, err error) {
-	// get all signing entities fingerprints that have signed any selected object
-	keyfps, err := v.AnySignedBy()
+// ociSIFSigners returns the signing entities of the OCI-SIF image f. PGP
+// signatures, if present, must be valid against kr, and identify their signers
+// by fingerprint. Cosign signatures identify their signers by the path of the
+// execgroup public key they are valid for.
+func ociSIFSigners(ctx context.Context, egroup *Execgroup, f *sif.FileImage, kr openpgp.KeyRing) (map[string]bool, error) {
+	signers := make(map[string]bool)
+	signed := false
+
+	pgpSigs, err := f.GetDescriptors(
+		sif.WithDataType(sif.DataSignature),
+		func(od sif.Descriptor) (bool, error) { return !sifsignature.IsCosignSignature(od), nil },
+	)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if len(pgpSigs) > 0 {
+		s, err := verifyPGP(ctx, egroup, f, kr)
+		if err != nil {
+			return nil, err
+		}
+		signers = s
+		signed = true
 	}
 
-	// was a selected object signed by a forbidden entity?
-	for _, v := range egroup.KeyFPs {
-		for _, u := range keyfps {
-			if strings.EqualFold(v, hex.EncodeToString(u[:])) {
-				return false, errSignedByForbidden
-			}
+	for _, path := range egroup.KeyPaths {
+		sv, err := signature.LoadVerifierFromPEMFile(path, crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("while loading key %s: %w", path, err)
+		}
+
+		err = sifsignature.VerifyOCISIFFileImage(ctx, f, sv)
+		if errors.Is(err, sifsignature.ErrNoCosignSignature) {
+			break
+		}
+		signed = true
+		if errors.Is(err, sifsignature.ErrBlobIntegrity) {
+			return nil, fmt.Errorf("image signature not valid: %w", err)
 		}
+		if err != nil {
+			sylog.Debugf("Image not signed with key %s: %v", path, err)
+			continue
+		}
+		signers[path] = true
 	}
 
-	return true, nil
+	if !signed {
+		return nil, errors.New("image is not signed")
+	}
+	return signers, nil
+}
+
+// isOCISIF returns true if f holds an OCI image index.
+func isOCISIF(f *sif.FileImage) bool {
+	_, err := f.GetDescriptor(sif.WithDataType(sif.DataOCIRootIndex))
+	return err == nil
 }
 
 func shouldRun(ctx context.Context, ecl *EclConfig, fp *os.File, kr openpgp.KeyRing) (ok bool, err error) {
@@ -202,40 +303,31 @@ func shouldRun(ctx context.Context, ecl *EclConfig, fp *os.File, kr openpgp.KeyR
 	}
 	defer f.UnloadContainer()
 
-	opts := []integrity.VerifierOpt{
-		integrity.OptVerifyWithContext(ctx),
-		integrity.OptVerifyWithKeyRing(kr),
-	}
-	if ecl.Legacy {
-		// Legacy behavior is to verify the primary partition only.
-		od, err := f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	var signers map[string]bool
+	if isOCISIF(f) {
+		signers, err = ociSIFSigners(ctx, egroup, f, kr)
 		if err != nil {
-			return false, fmt.Errorf("get primary system partition: %v", err)
+			return false, err
+		}
+	} else {
+		var opts []integrity.VerifierOpt
+		if ecl.Legacy {
+			// Legacy behavior is to verify the primary partition only.
+			od, err := f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+			if err != nil {
+				return false, fmt.Errorf("get primary system partition: %v", err)
+			}
+			opts = append(opts, integrity.OptVerifyLegacy(), integrity.OptVerifyObject(od.ID()))
 		}
-		opts = append(opts, integrity.OptVerifyLegacy(), integrity.OptVerifyObject(od.ID()))
-	}
-
-	v, err := integrity.NewVerifier(f, opts...)
-	if err != nil {
-		return false, err
-	}
-
-	// Validate signature.
-	if err := v.Verify(); err != nil {
-		return false, fmt.Errorf("image signature not valid: %v", err)
-	}
 
-	// Check fingerprints against policy.
-	switch egroup.ListMode {
-	case "whitelist":
-		return checkWhiteList(v, egroup)
-	case "whitestrict":
-		return checkWhiteStrict(v, egroup)
-	case "blacklist":
-		return checkBlackList(v, egroup)
+		signers, err = verifyPGP(ctx, egroup, f, kr, opts...)
+		if err != nil {
+			return false, err
+		}
 	}
 
-	return false, fmt.Errorf("ecl config file invalid")
+	// Check signing entities against policy.
+	return checkList(signers, egroup)
 }
 
 func getExecGroup(ecl *EclConfig, fp *os.File) *Execgroup {
@@ -8,6 +8,7 @@ package syecl
 
 import (
 	"context"
+	"crypto"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,6 +17,15 @@ import (
 	"testing"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	ocisif "github.com/sylabs/oci-tools/pkg/sif"
+	"github.com/sylabs/sif/v2/pkg/integrity"
+	"github.com/sylabs/sif/v2/pkg/sif"
+	sifsignature "github.com/sylabs/singularity/v4/internal/pkg/signature"
 	"gotest.tools/v3/golden"
 )
 
@@ -465,3 +475,248 @@ func TestShouldRun(t *testing.T) {
 		})
 	}
 }
+
+// writeTestOCISIF writes an OCI-SIF holding a random single image to dir, and returns its path.
+// If pgp is true, the image is signed with the fixed test PGP entity. If cosign is true, the image
+// is signed with the ECDSA test key.
+func writeTestOCISIF(t *testing.T, dir, name string, pgp, cosign bool) string {
+	t.Helper()
+
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img})
+
+	path := filepath.Join(dir, name)
+	if err := ocisif.Write(path, ii); err != nil {
+		t.Fatal(err)
+	}
+
+	if cosign {
+		keyPath := filepath.Join("..", "..", "..", "test", "keys", "ecdsa-private.pem")
+		s, err := signature.LoadSignerFromPEMFile(keyPath, crypto.SHA256, cryptoutils.SkipPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sifsignature.SignOCISIF(context.Background(), path, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if pgp {
+		f, err := os.Open(filepath.Join("..", "..", "..", "test", "keys", "pgp-private.asc"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		el, err := openpgp.ReadArmoredKeyRing(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := sif.LoadContainerFromPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fi.UnloadContainer()
+
+		s, err := integrity.NewSigner(fi, integrity.OptSignWithEntity(el[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Sign(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+// tamperOCISIFLayer overwrites the start of the layer blob of the single image
+// in the OCI-SIF at path.
+func tamperOCISIFLayer(t *testing.T, path string) {
+	t.Helper()
+
+	fi, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix, err := ocisif.ImageIndexFromFileImage(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := ix.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := ix.Image(im.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := fi.GetDescriptor(sif.WithOCIBlobDigest(mf.Layers[0].Digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := d.Offset()
+	if err := fi.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt([]byte("tampered"), offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShouldRunOCISIF(t *testing.T) {
+	dir := t.TempDir()
+	unsigned := writeTestOCISIF(t, dir, "unsigned.oci.sif", false, false)
+	pgpSigned := writeTestOCISIF(t, dir, "pgp.oci.sif", true, false)
+	cosignSigned := writeTestOCISIF(t, dir, "cosign.oci.sif", false, true)
+	bothSigned := writeTestOCISIF(t, dir, "both.oci.sif", true, true)
+	cosignTampered := writeTestOCISIF(t, dir, "tampered.oci.sif", false, true)
+	tamperOCISIFLayer(t, cosignTampered)
+
+	ecdsaKey, err := filepath.Abs(filepath.Join("..", "..", "..", "test", "keys", "ecdsa-public.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := filepath.Abs(filepath.Join("..", "..", "..", "test", "keys", "rsa-public.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		eg      Execgroup
+		path    string
+		wantErr bool
+	}{
+		{
+			name:    "Unsigned",
+			eg:      Execgroup{ListMode: "blacklist", KeyFPs: []string{KeyFP2}, KeyPaths: []string{rsaKey}},
+			path:    unsigned,
+			wantErr: true,
+		},
+		{
+			name: "PGPWhitelistOK",
+			eg:   Execgroup{ListMode: "whitelist", KeyFPs: []string{KeyFP1}},
+			path: pgpSigned,
+		},
+		{
+			name:    "PGPWhitelistError",
+			eg:      Execgroup{ListMode: "whitelist", KeyFPs: []string{KeyFP2}},
+			path:    pgpSigned,
+			wantErr: true,
+		},
+		{
+			name: "PGPWhitestrictOK",
+			eg:   Execgroup{ListMode: "whitestrict", KeyFPs: []string{KeyFP1}},
+			path: pgpSigned,
+		},
+		{
+			name:    "PGPBlacklistError",
+			eg:      Execgroup{ListMode: "blacklist", KeyFPs: []string{KeyFP1}},
+			path:    pgpSigned,
+			wantErr: true,
+		},
+		{
+			name: "CosignWhitelistOK",
+			eg:   Execgroup{ListMode: "whitelist", KeyPaths: []string{rsaKey, ecdsaKey}},
+			path: cosignSigned,
+		},
+		{
+			name:    "CosignWhitelistError",
+			eg:      Execgroup{ListMode: "whitelist", KeyFPs: []string{KeyFP1}, KeyPaths: []string{rsaKey}},
+			path:    cosignSigned,
+			wantErr: true,
+		},
+		{
+			name: "CosignBlacklistOK",
+			eg:   Execgroup{ListMode: "blacklist", KeyPaths: []string{rsaKey}},
+			path: cosignSigned,
+		},
+		{
+			name:    "CosignBlacklistError",
+			eg:      Execgroup{ListMode: "blacklist", KeyPaths: []string{ecdsaKey}},
+			path:    cosignSigned,
+			wantErr: true,
+		},
+		{
+			name: "WhitestrictOK",
+			eg:   Execgroup{ListMode: "whitestrict", KeyPaths: []string{ecdsaKey}},
+			path: cosignSigned,
+		},
+		{
+			name:    "WhitestrictError",
+			eg:      Execgroup{ListMode: "whitestrict", KeyPaths: []string{ecdsaKey, rsaKey}},
+			path:    cosignSigned,
+			wantErr: true,
+		},
+		{
+			name: "BothWhitelistPGP",
+			eg:   Execgroup{ListMode: "whitelist", KeyFPs: []string{KeyFP1}},
+			path: bothSigned,
+		},
+		{
+			name: "BothWhitelistCosign",
+			eg:   Execgroup{ListMode: "whitelist", KeyPaths: []string{ecdsaKey}},
+			path: bothSigned,
+		},
+		{
+			name: "BothWhitestrictOK",
+			eg:   Execgroup{ListMode: "whitestrict", KeyFPs: []string{KeyFP1}, KeyPaths: []string{ecdsaKey}},
+			path: bothSigned,
+		},
+		{
+			name:    "CosignTamperedWhitelistError",
+			eg:      Execgroup{ListMode: "whitelist", KeyPaths: []string{ecdsaKey}},
+			path:    cosignTampered,
+			wantErr: true,
+		},
+		{
+			name:    "CosignTamperedBlacklistError",
+			eg:      Execgroup{ListMode: "blacklist", KeyPaths: []string{rsaKey}},
+			path:    cosignTampered,
+			wantErr: true,
+		},
+		{
+			name:    "BothBlacklistPGPError",
+			eg:      Execgroup{ListMode: "blacklist", KeyFPs: []string{KeyFP1}},
+			path:    bothSigned,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := EclConfig{
+				Activated:  true,
+				ExecGroups: []Execgroup{tt.eg},
+			}
+			if err := c.ValidateConfig(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := c.ShouldRun(context.Background(), tt.path, openpgp.EntityList{getTestEntity(t)})
+
+			if want := !tt.wantErr; got != want {
+				t.Errorf("got run %v, want %v", got, want)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -7,17 +7,44 @@
 package sypgp
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/sylabs/scs-key-client/client"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
 
+// keyCacheTTL is the duration for which a public key fetched from a keyserver
+// is used from the key cache, before it is fetched again.
+const keyCacheTTL = 24 * time.Hour
+
+var (
+	keyCacheMu  sync.RWMutex
+	keyCacheDir string
+)
+
+// SetKeyCacheDir sets the directory caching public keys fetched from
+// keyservers to verify signatures. An empty dir disables the key cache.
+func SetKeyCacheDir(dir string) {
+	keyCacheMu.Lock()
+	defer keyCacheMu.Unlock()
+	keyCacheDir = dir
+}
+
+func getKeyCacheDir() string {
+	keyCacheMu.RLock()
+	defer keyCacheMu.RUnlock()
+	return keyCacheDir
+}
+
 // PublicKeyRing retrieves the Singularity public KeyRing.
 func PublicKeyRing() (openpgp.KeyRing, error) {
 	return NewHandle("").LoadPubKeyring()
@@ -29,6 +56,8 @@ type hybridKeyRing struct {
 	local openpgp.KeyRing // Local keyring.
 	ctx   context.Context //nolint:containedctx // Context, for use when retrieving keys remotely.
 	c     *client.Client  // Keyserver client.
+
+	fetched map[uint64]openpgp.EntityList // Entities fetched from keyserver, by key id.
 }
 
 // NewHybridKeyRing returns a keyring backed by both the local public keyring and the configured
@@ -47,9 +76,10 @@ func NewHybridKeyRing(ctx context.Context, opts ...client.Option) (openpgp.KeyRi
 	}
 
 	return &hybridKeyRing{
-		local: kr,
-		ctx:   ctx,
-		c:     c,
+		local:   kr,
+		ctx:     ctx,
+		c:       c,
+		fetched: make(map[uint64]openpgp.EntityList),
 	}, nil
 }
 
@@ -94,7 +124,31 @@ func (kr *hybridKeyRing) DecryptionKeys() []openpgp.Key {
 }
 
 // remoteEntitiesByID returns the set of entities from the keyserver that have the given key id.
+// Entities are cached in memory, and in the key cache directory if set. A cached entity older than
+// keyCacheTTL is fetched again, but still used if the keyservers can't be reached.
 func (kr *hybridKeyRing) remoteEntitiesByID(id uint64) (openpgp.EntityList, error) {
+	if el, ok := kr.fetched[id]; ok {
+		return el, nil
+	}
+
+	cachePath := ""
+	if dir := getKeyCacheDir(); dir != "" {
+		cachePath = filepath.Join(dir, fmt.Sprintf("%016X.asc", id))
+	}
+
+	var cached []byte
+	if cachePath != "" {
+		if fi, err := os.Stat(cachePath); err == nil {
+			if b, err := os.ReadFile(cachePath); err == nil {
+				if time.Since(fi.ModTime()) < keyCacheTTL {
+					sylog.Debugf("Using cached public key %016X", id)
+					return kr.readEntities(id, b)
+				}
+				cached = b
+			}
+		}
+	}
+
 	kt, err := kr.c.PKSLookup(kr.ctx, nil, fmt.Sprintf("%#x", id), client.OperationGet, false, true, nil)
 	if err != nil {
 		// If the request failed with HTTP status code unauthorized, guide the user to fix that.
@@ -102,10 +156,52 @@ func (kr *hybridKeyRing) remoteEntitiesByID(id uint64) (openpgp.EntityList, erro
 		if errors.As(err, &httpError) && httpError.Code() == http.StatusUnauthorized {
 			sylog.Infof(helpAuth)
 		}
+		if cached != nil {
+			sylog.Warningf("Failed to fetch public key %016X, using cached copy: %v", id, err)
+			return kr.readEntities(id, cached)
+		}
 		return nil, err
 	}
 
-	return openpgp.ReadArmoredKeyRing(strings.NewReader(kt))
+	el, err := kr.readEntities(id, []byte(kt))
+	if err != nil {
+		return nil, err
+	}
+	if cachePath != "" {
+		if err := writeKeyCache(cachePath, []byte(kt)); err != nil {
+			sylog.Debugf("Failed to cache public key %016X: %v", id, err)
+		}
+	}
+	return el, nil
+}
+
+// readEntities reads the armored keyring b, holding the entities with the given key id, and
+// caches them in memory.
+func (kr *hybridKeyRing) readEntities(id uint64, b []byte) (openpgp.EntityList, error) {
+	el, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	kr.fetched[id] = el
+	return el, nil
+}
+
+// writeKeyCache atomically writes the armored keyring b to path.
+func writeKeyCache(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
 }
 
 type multiKeyRing struct {
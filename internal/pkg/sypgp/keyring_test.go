@@ -0,0 +1,109 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/sylabs/scs-key-client/client"
+)
+
+// countingHandler counts the requests handled by h.
+type countingHandler struct {
+	h     http.Handler
+	count int
+}
+
+func (c *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.count++
+	c.h.ServeHTTP(w, r)
+}
+
+func TestHybridKeyRingCache(t *testing.T) {
+	ms := &mockPKSLookup{code: http.StatusOK, el: openpgp.EntityList{testEntity}}
+	ch := &countingHandler{h: ms}
+	srv := httptest.NewTLSServer(ch)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	SetKeyCacheDir(dir)
+	defer SetKeyCacheDir("")
+
+	id := testEntity.PrimaryKey.KeyId
+	cachePath := filepath.Join(dir, fmt.Sprintf("%016X.asc", id))
+
+	newKeyRing := func() *hybridKeyRing {
+		c, err := client.NewClient(client.OptBaseURL(srv.URL), client.OptHTTPClient(srv.Client()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &hybridKeyRing{
+			local:   openpgp.EntityList{},
+			ctx:     context.Background(),
+			c:       c,
+			fetched: make(map[uint64]openpgp.EntityList),
+		}
+	}
+
+	// The key is fetched once, and cached on disk.
+	kr := newKeyRing()
+	for i := 0; i < 2; i++ {
+		if keys := kr.KeysById(id); len(keys) != 1 {
+			t.Fatalf("got %d keys, want 1", len(keys))
+		}
+	}
+	if ch.count != 1 {
+		t.Errorf("got %d requests, want 1", ch.count)
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Fatalf("key was not cached: %v", err)
+	}
+
+	// A new keyring uses the cached key.
+	if keys := newKeyRing().KeysById(id); len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	if ch.count != 1 {
+		t.Errorf("got %d requests, want 1", ch.count)
+	}
+
+	// An expired cached key is fetched again...
+	expired := time.Now().Add(-2 * keyCacheTTL)
+	if err := os.Chtimes(cachePath, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	if keys := newKeyRing().KeysById(id); len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	if ch.count != 2 {
+		t.Errorf("got %d requests, want 2", ch.count)
+	}
+
+	// ...but still used when the keyserver fails.
+	if err := os.Chtimes(cachePath, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	ms.code = http.StatusInternalServerError
+	if keys := newKeyRing().KeysById(id); len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+
+	// Without a cached key, the failure is reported.
+	if err := os.Remove(cachePath); err != nil {
+		t.Fatal(err)
+	}
+	if keys := newKeyRing().KeysById(id); len(keys) != 0 {
+		t.Fatalf("got %d keys, want 0", len(keys))
+	}
+}
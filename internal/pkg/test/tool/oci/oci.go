@@ -13,6 +13,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/opencontainers/runtime-tools/validate"
 )
 
@@ -50,3 +51,16 @@ func ValidateBundle(t *testing.T, bundlePath string) {
 		t.Errorf("Bundle not valid: %v", err)
 	}
 }
+
+// ValidateSpec checks spec, for a bundle at bundlePath, against the OCI
+// runtime specification, using the runtime-tools validator.
+func ValidateSpec(t *testing.T, spec *specs.Spec, bundlePath string) {
+	v, err := validate.NewValidator(spec, bundlePath, false, "linux")
+	if err != nil {
+		t.Errorf("Could not create spec validator: %v", err)
+		return
+	}
+	if err := v.CheckAll(); err != nil {
+		t.Errorf("Spec not valid: %v", err)
+	}
+}
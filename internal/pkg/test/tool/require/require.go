@@ -21,8 +21,10 @@ import (
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/security/landlock"
 	"github.com/sylabs/singularity/v4/internal/pkg/security/seccomp"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/rpm"
 	"github.com/sylabs/singularity/v4/pkg/network"
 	"github.com/sylabs/singularity/v4/pkg/util/fs/proc"
@@ -241,6 +243,28 @@ func Rocm(t *testing.T) {
 	}
 }
 
+// Intel checks that an Intel GPU render node is available
+func Intel(t *testing.T) {
+	devs, err := gpu.IntelDevices()
+	if err != nil {
+		t.Skipf("while finding Intel GPU devices: %v", err)
+	}
+	if len(devs) == 0 {
+		t.Skipf("no Intel GPU render node found")
+	}
+}
+
+// Rdma checks that an InfiniBand / RDMA device is available
+func Rdma(t *testing.T) {
+	devs, err := gpu.RdmaDevices()
+	if err != nil {
+		t.Skipf("while finding RDMA devices: %v", err)
+	}
+	if len(devs) == 0 {
+		t.Skipf("no RDMA device found")
+	}
+}
+
 // Filesystem checks that the current test could use the
 // corresponding filesystem, if the filesystem is not
 // listed in /proc/filesystems, the current test is skipped
@@ -278,6 +302,14 @@ func Seccomp(t *testing.T) {
 	}
 }
 
+// Landlock checks that the Landlock LSM is supported, and enabled, by the
+// kernel. If not, the current test is skipped with a message.
+func Landlock(t *testing.T) {
+	if !landlock.Enabled() {
+		t.Skipf("landlock is not supported, or is disabled, by the kernel")
+	}
+}
+
 // Arch checks the test machine has the specified architecture.
 // If not, the test is skipped with a message.
 func Arch(t *testing.T, arch string) {
@@ -6,6 +6,8 @@
 package auth
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/test"
@@ -36,3 +38,37 @@ func Test_ReadToken(t *testing.T) {
 		t.Errorf("readToken from valid file must match expected result")
 	}
 }
+
+func Test_ReadTokenSet(t *testing.T) {
+	ts, err := ReadTokenSet(testTokenPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ts.AccessToken != testToken || ts.RefreshToken != "" || ts.ExpiresIn != 0 {
+		t.Errorf("unexpected token set %+v", ts)
+	}
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	data := `{"access_token":"` + testToken + `","refresh_token":"refresh","expires_in":3600,"token_type":"Bearer"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ts, err = ReadTokenSet(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ts.AccessToken != testToken || ts.RefreshToken != "refresh" || ts.ExpiresIn != 3600 {
+		t.Errorf("unexpected token set %+v", ts)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"access_token":"short"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadTokenSet(path); err != ErrTokenTooShort {
+		t.Errorf("got error %v, want %v", err, ErrTokenTooShort)
+	}
+
+	if _, err := ReadTokenSet("/no/such/file"); err != ErrTokenFileNotFound {
+		t.Errorf("got error %v, want %v", err, ErrTokenFileNotFound)
+	}
+}
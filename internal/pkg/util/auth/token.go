@@ -6,6 +6,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
 	"strings"
@@ -42,17 +43,63 @@ func ReadToken(tokenPath string) (token string, err error) {
 		return "", ErrEmptyToken
 	}
 
-	// A valid RSA signed token is at least 200 chars with no extra payload
 	token = lines[0]
+	if err := checkToken(token); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// TokenSet holds an access token, with the refresh token and lifetime in
+// seconds of an OAuth 2.0 token response (RFC 6749 section 5.1).
+type TokenSet struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+}
+
+// ReadTokenSet reads a sylabs JWT auth token from a file, which holds either
+// the token alone, or an OAuth 2.0 token response in JSON format, with an
+// optional refresh token and lifetime.
+func ReadTokenSet(tokenPath string) (*TokenSet, error) {
+	buf, err := os.ReadFile(tokenPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTokenFileNotFound
+		}
+		return nil, ErrCouldntReadFile
+	}
+
+	if !strings.HasPrefix(strings.TrimSpace(string(buf)), "{") {
+		token, err := ReadToken(tokenPath)
+		if err != nil {
+			return nil, err
+		}
+		return &TokenSet{AccessToken: token}, nil
+	}
+
+	ts := new(TokenSet)
+	if err := json.Unmarshal(buf, ts); err != nil {
+		return nil, err
+	}
+	if err := checkToken(ts.AccessToken); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func checkToken(token string) error {
+	// A valid RSA signed token is at least 200 chars with no extra payload
 	if len(token) < 200 {
-		return "", ErrTokenTooShort
+		return ErrTokenTooShort
 	}
 
 	// A token should never be bigger than 4Kb - if it is we will have problems
 	// with header buffers
 	if len(token) > 4096 {
-		return "", ErrTokenToolong
+		return ErrTokenToolong
 	}
 
-	return token, nil
+	return nil
 }
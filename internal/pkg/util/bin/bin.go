@@ -14,11 +14,17 @@ import (
 func FindBin(name string) (path string, err error) {
 	switch name {
 	// Basic system executables that we assume are always on PATH
-	case "true", "mkfs.ext3", "cp", "rm", "dd", "truncate":
+	case "true", "mkfs.ext3", "cp", "rm", "dd", "truncate", "tar":
 		return findOnPath(name)
 	// Bootstrap related executables that we assume are on PATH
 	case "mount", "mknod", "debootstrap", "pacstrap", "dnf", "yum", "rpm", "curl", "uname", "zypper", "SUSEConnect", "rpmkeys", "proot":
 		return findOnPath(name)
+	// Remote copy executables used to distribute images to the nodes of a job
+	case "ssh", "scp":
+		return findOnPath(name)
+	// CRIU, which checkpoints and restores instances migrated between nodes
+	case "criu":
+		return findOnPath(name)
 	// Configurable executables that are found at build time, can be overridden
 	// in singularity.conf. If config value is "" will look on PATH.
 	case "unsquashfs", "mksquashfs", "go":
@@ -49,10 +55,25 @@ func FindBin(name string) (path string, err error) {
 	// fuse2fs for OCI-mode bare-image overlay
 	case "fuse2fs":
 		return findOnPath(name)
+	// gocryptfs for OCI-mode encrypted directory overlay
+	case "gocryptfs":
+		return findOnPath(name)
 	// fuse-overlayfs for mounting overlays without kernel support for
 	// unprivileged overlays
 	case "fuse-overlayfs":
 		return findOnPath(name)
+	// stargz-store for OCI-mode lazy pulling of eStargz images
+	case "stargz-store":
+		return findOnPath(name)
+	// sqfs2tar for converting OCI-SIF squashfs layers back to tar layers
+	case "sqfs2tar":
+		return findOnPath(name)
+	// podman for loading images into containers-storage
+	case "podman":
+		return findOnPath(name)
+	// vulnerability scanners for 'singularity scan'
+	case "grype", "trivy":
+		return findOnPath(name)
 	default:
 		return "", fmt.Errorf("executable name %q is not known to FindBin", name)
 	}
@@ -0,0 +1,238 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package coredump collects the core dumps of crashed container processes
+// into a host directory, with metadata describing the crash.
+//
+// Where a core dump is written is set system-wide by the kernel core_pattern,
+// which cannot be changed from a container. When core_pattern is a file name,
+// the core dump is written relative to the working directory of the crashed
+// process, in its mount namespace, and can be collected from there if that
+// directory is shared with the host. When core_pattern pipes core dumps to a
+// handler, such as systemd-coredump, the core dump is held by the handler and
+// only the metadata is collected.
+package coredump
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"golang.org/x/sys/unix"
+)
+
+// corePatternFile holds the kernel core_pattern.
+const corePatternFile = "/proc/sys/kernel/core_pattern"
+
+// Metadata describes a crashed container process.
+type Metadata struct {
+	// Image is the path or URI of the container image.
+	Image string `json:"image"`
+	// ImageDigest is the sha256 digest of the container image file, if it is
+	// a local file.
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// Command is the command run in the container.
+	Command []string `json:"command"`
+	// Signal is the signal that terminated the process.
+	Signal string `json:"signal"`
+	// Time is the time at which the crash was collected.
+	Time time.Time `json:"time"`
+	// Core is the file name, in the collection directory, of the core dump,
+	// if it was collected.
+	Core string `json:"core,omitempty"`
+	// Handler is the core_pattern handler to which the core dump was piped,
+	// if any.
+	Handler string `json:"handler,omitempty"`
+}
+
+// coreSignals are the signals whose default action dumps core.
+var coreSignals = map[syscall.Signal]bool{
+	unix.SIGQUIT: true,
+	unix.SIGILL:  true,
+	unix.SIGTRAP: true,
+	unix.SIGABRT: true,
+	unix.SIGBUS:  true,
+	unix.SIGFPE:  true,
+	unix.SIGSEGV: true,
+	unix.SIGSYS:  true,
+	unix.SIGXCPU: true,
+	unix.SIGXFSZ: true,
+}
+
+// IsCoreSignal returns true if the default action of sig dumps core.
+func IsCoreSignal(sig syscall.Signal) bool {
+	return coreSignals[sig]
+}
+
+// Enable raises the soft core file size limit to the hard limit, so that the
+// container processes, which inherit it, dump core when they crash.
+func Enable() error {
+	var rl unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_CORE, &rl); err != nil {
+		return fmt.Errorf("while getting core file size limit: %w", err)
+	}
+	if rl.Max == 0 {
+		sylog.Warningf("The hard core file size limit is 0, core dumps will not be written")
+		return nil
+	}
+	rl.Cur = rl.Max
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &rl); err != nil {
+		return fmt.Errorf("while setting core file size limit: %w", err)
+	}
+	return nil
+}
+
+// CheckDir returns the absolute path of dir, which must be a writable
+// directory, in which crashes are collected.
+func CheckDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("core dump directory: %w", err)
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("core dump directory %s is not a directory", abs)
+	}
+	if err := unix.Access(abs, unix.W_OK); err != nil {
+		return "", fmt.Errorf("core dump directory %s is not writable: %w", abs, err)
+	}
+	return abs, nil
+}
+
+// ImageDigest returns the sha256 digest of the image at path, or an empty
+// string if path is not a regular file, such as a sandbox or image URI.
+func ImageDigest(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return "", nil //nolint:nilerr
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Collect moves the core dump of a process that crashed, with md.Signal,
+// after since, into dir, and writes md alongside it. cwd is the working
+// directory of the process, against which a relative core_pattern is
+// resolved. The path of the metadata file is returned.
+func Collect(dir, cwd string, since time.Time, md Metadata) (string, error) {
+	b, err := os.ReadFile(corePatternFile)
+	if err != nil {
+		return "", fmt.Errorf("while reading core_pattern: %w", err)
+	}
+	pattern := strings.TrimSpace(string(b))
+
+	md.Time = time.Now()
+	name := "crash-" + md.Time.Format("20060102-150405")
+
+	if strings.HasPrefix(pattern, "|") {
+		md.Handler = strings.TrimPrefix(pattern, "|")
+		sylog.Infof("Core dumps are piped to %s, by core_pattern, and cannot be collected", md.Handler)
+	} else if core, err := findCore(pattern, cwd, since); err != nil {
+		sylog.Warningf("Could not find core dump: %v", err)
+	} else {
+		md.Core = name + ".core"
+		if err := move(core, filepath.Join(dir, md.Core)); err != nil {
+			return "", fmt.Errorf("while collecting core dump %s: %w", core, err)
+		}
+	}
+
+	mdPath := filepath.Join(dir, name+".json")
+	b, err = json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(mdPath, b, 0o600); err != nil {
+		return "", fmt.Errorf("while writing crash metadata: %w", err)
+	}
+	return mdPath, nil
+}
+
+// specifierRegexp matches core_pattern specifiers.
+var specifierRegexp = regexp.MustCompile(`%.`)
+
+// findCore returns the most recent core dump, written after since, matching
+// pattern, a core_pattern file name. The kernel may append the PID to the
+// file name, per core_uses_pid.
+func findCore(pattern, cwd string, since time.Time) (string, error) {
+	glob := specifierRegexp.ReplaceAllStringFunc(pattern, func(s string) string {
+		if s == "%%" {
+			return "%"
+		}
+		return "*"
+	}) + "*"
+	if !filepath.IsAbs(glob) {
+		glob = filepath.Join(cwd, glob)
+	}
+
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return "", err
+	}
+
+	var core string
+	var coreTime time.Time
+	for _, m := range matches {
+		fi, err := os.Lstat(m)
+		if err != nil || !fi.Mode().IsRegular() || fi.ModTime().Before(since) {
+			continue
+		}
+		if fi.ModTime().After(coreTime) {
+			core, coreTime = m, fi.ModTime()
+		}
+	}
+	if core == "" {
+		return "", fmt.Errorf("no core dump matching %s", glob)
+	}
+	return core, nil
+}
+
+// move moves src to dst, copying it if they are on different filesystems.
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, unix.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package coredump
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindCore(t *testing.T) {
+	cwd := t.TempDir()
+	since := time.Now().Add(-time.Minute)
+
+	// An old core dump, and a directory, must be ignored.
+	old := filepath.Join(cwd, "core.100")
+	if err := os.WriteFile(old, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(old, since.Add(-time.Hour), since.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(cwd, "core.dir"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	core := filepath.Join(cwd, "core.200")
+	if err := os.WriteFile(core, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Core",
+			pattern: "core",
+			want:    core,
+		},
+		{
+			name:    "Specifiers",
+			pattern: "core.%p",
+			want:    core,
+		},
+		{
+			name:    "Absolute",
+			pattern: filepath.Join(cwd, "core.%e"),
+			want:    core,
+		},
+		{
+			name:    "NoMatch",
+			pattern: "dump.%p",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findCore(tt.pattern, cwd, since)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findCore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("findCore() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageDigest(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "image.sif")
+	if err := os.WriteFile(image, []byte("image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ImageDigest(image)
+	if err != nil {
+		t.Fatalf("ImageDigest() error = %v", err)
+	}
+	if want := "sha256:6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d"; got != want {
+		t.Errorf("ImageDigest() = %q, want %q", got, want)
+	}
+
+	// A sandbox has no digest.
+	if got, err := ImageDigest(dir); err != nil || got != "" {
+		t.Errorf("ImageDigest() = %q, %v, want \"\", nil", got, err)
+	}
+}
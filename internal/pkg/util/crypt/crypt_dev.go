@@ -17,6 +17,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 	"github.com/sylabs/singularity/v4/pkg/util/fs/lock"
@@ -170,7 +171,12 @@ func (crypt *Device) EncryptFilesystem(path string, key []byte) (string, error)
 		return "", fmt.Errorf("%s must be owned by root", cryptsetup)
 	}
 
-	cmd := exec.Command(cryptsetup, "luksFormat", "--batch-mode", "--type", "luks2", "--key-file", "-", loop)
+	args := []string{"luksFormat", "--batch-mode", "--type", "luks2", "--key-file", "-"}
+	if fips.Enabled() {
+		// The default argon2 key derivation function is not FIPS approved.
+		args = append(args, "--pbkdf", "pbkdf2")
+	}
+	cmd := exec.Command(cryptsetup, append(args, loop)...)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return "", err
@@ -0,0 +1,180 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package crypt
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/cryptkey"
+)
+
+const (
+	// KeyFileEnv is the environment variable holding the path to a file that
+	// contains an encryption passphrase, or a PEM formatted RSA key.
+	KeyFileEnv = "SINGULARITY_ENCRYPTION_KEY_FILE"
+
+	// KeyURIEnv is the environment variable holding the URI of a key held by
+	// a key management service, e.g. vault://transit/my-key, that wraps the
+	// encryption key of a container.
+	KeyURIEnv = "SINGULARITY_ENCRYPTION_KEY_URI"
+
+	// KeyAgentSocketEnv is the environment variable holding the path to the
+	// unix socket of a key agent. On connection, the agent writes an
+	// encryption passphrase and closes the connection.
+	KeyAgentSocketEnv = "SINGULARITY_ENCRYPTION_AGENT_SOCK"
+
+	// KeyCredential is the name of the systemd credential, in the directory
+	// given by $CREDENTIALS_DIRECTORY, that holds an encryption passphrase
+	// or a PEM formatted RSA key. It can be provided to a service with
+	// LoadCredential= or LoadCredentialEncrypted= (see systemd-creds(1)).
+	KeyCredential = "singularity-encryption-key"
+
+	// credentialsDirEnv is set by systemd for services that have credentials.
+	credentialsDirEnv = "CREDENTIALS_DIRECTORY"
+
+	// maxKeySize is the maximum size of key material read from a file or
+	// key agent.
+	maxKeySize = 64 * 1024
+
+	// keyAgentTimeout is the maximum time allowed to obtain a key from a key
+	// agent.
+	keyAgentTimeout = 10 * time.Second
+)
+
+// ResolveKey returns the key material for an encrypted container from the
+// first of the following non-interactive sources that is set:
+//
+//  1. keyFile, as specified with a CLI flag.
+//  2. The file named by the SINGULARITY_ENCRYPTION_KEY_FILE environment
+//     variable.
+//  3. The key URI held by the SINGULARITY_ENCRYPTION_KEY_URI environment
+//     variable.
+//  4. The key agent listening on the socket named by the
+//     SINGULARITY_ENCRYPTION_AGENT_SOCK environment variable.
+//  5. The singularity-encryption-key systemd credential.
+//
+// None of these sources place the key material itself in the environment,
+// where it would be visible to other processes through /proc. If no source is
+// set, ResolveKey returns nil.
+func ResolveKey(keyFile string) (*cryptkey.KeyInfo, error) {
+	if keyFile != "" {
+		sylog.Verbosef("Using key file flag for encrypted container")
+		return KeyFromFile(keyFile)
+	}
+
+	if path, ok := os.LookupEnv(KeyFileEnv); ok && path != "" {
+		sylog.Verbosef("Using key file environment variable for encrypted container")
+		return KeyFromFile(path)
+	}
+
+	if uri, ok := os.LookupEnv(KeyURIEnv); ok && uri != "" {
+		sylog.Verbosef("Using key URI environment variable for encrypted container")
+		return KeyFromURI(uri)
+	}
+
+	if sock, ok := os.LookupEnv(KeyAgentSocketEnv); ok && sock != "" {
+		sylog.Verbosef("Using key agent %s for encrypted container", sock)
+		return KeyFromAgent(sock)
+	}
+
+	if dir, ok := os.LookupEnv(credentialsDirEnv); ok && dir != "" {
+		path := filepath.Join(dir, KeyCredential)
+		if _, err := os.Stat(path); err == nil {
+			sylog.Verbosef("Using systemd credential %s for encrypted container", KeyCredential)
+			return KeyFromFile(path)
+		}
+	}
+
+	return nil, nil
+}
+
+// KeyFromFile returns the key material held in the file at path. A file
+// containing a PEM block is used as a PEM formatted RSA key. Otherwise its
+// content, without any trailing newline, is used as a passphrase.
+func KeyFromFile(path string) (*cryptkey.KeyInfo, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("while accessing key file: %w", err)
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("key file %s is not a regular file", path)
+	}
+	if fi.Mode().Perm()&0o077 != 0 {
+		sylog.Warningf("Key file %s is accessible by other users", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("while opening key file: %w", err)
+	}
+	defer f.Close()
+
+	b, err := readKey(f)
+	if err != nil {
+		return nil, fmt.Errorf("while reading key file %s: %w", path, err)
+	}
+
+	if block, _ := pem.Decode(b); block != nil {
+		return &cryptkey.KeyInfo{Format: cryptkey.PEM, Path: path}, nil
+	}
+	return passphraseKey(b)
+}
+
+// KeyFromURI returns the key material held by the key management service at
+// the key URI uri, e.g. vault://transit/my-key.
+func KeyFromURI(uri string) (*cryptkey.KeyInfo, error) {
+	if err := cryptkey.CheckKeyURI(uri); err != nil {
+		return nil, err
+	}
+	return &cryptkey.KeyInfo{Format: cryptkey.URI, Path: uri}, nil
+}
+
+// KeyFromAgent returns the passphrase provided by the key agent listening on
+// the unix socket at sock.
+func KeyFromAgent(sock string) (*cryptkey.KeyInfo, error) {
+	conn, err := net.DialTimeout("unix", sock, keyAgentTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("while connecting to key agent: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetReadDeadline(time.Now().Add(keyAgentTimeout)); err != nil {
+		return nil, err
+	}
+	b, err := readKey(conn)
+	if err != nil {
+		return nil, fmt.Errorf("while reading from key agent: %w", err)
+	}
+	return passphraseKey(b)
+}
+
+// readKey reads key material from r, up to maxKeySize bytes.
+func readKey(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxKeySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxKeySize {
+		return nil, fmt.Errorf("key material exceeds %d bytes", maxKeySize)
+	}
+	return b, nil
+}
+
+func passphraseKey(b []byte) (*cryptkey.KeyInfo, error) {
+	b = bytes.TrimRight(b, "\r\n")
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return &cryptkey.KeyInfo{Format: cryptkey.Passphrase, Material: string(b)}, nil
+}
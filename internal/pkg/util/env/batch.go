@@ -0,0 +1,50 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package env
+
+import (
+	"path"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// BatchJobEnvMap returns a map of the host env vars whose names match one of
+// patterns, the 'batch job env' allow-list of singularity.conf. They are passed
+// into the container even when the environment is otherwise cleaned.
+func BatchJobEnvMap(hostEnvs []string, patterns []string) map[string]string {
+	batchEnv := map[string]string{}
+
+	valid := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			sylog.Warningf("Ignoring invalid 'batch job env' pattern %q: %v", p, err)
+			continue
+		}
+		valid = append(valid, p)
+	}
+	if len(valid) == 0 {
+		return batchEnv
+	}
+
+	for _, envVar := range hostEnvs {
+		key, value, ok := strings.Cut(envVar, "=")
+		if !ok || strings.HasPrefix(key, SingularityPrefix) || strings.HasPrefix(key, SingularityEnvPrefix) {
+			continue
+		}
+		if _, ok := AlwaysOmitKeys[key]; ok {
+			continue
+		}
+		for _, p := range valid {
+			if match, _ := path.Match(p, key); match {
+				batchEnv[key] = value
+				break
+			}
+		}
+	}
+
+	return batchEnv
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package env
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBatchJobEnvMap(t *testing.T) {
+	hostEnv := []string{
+		"SLURM_JOB_ID=1234",
+		"SLURM_NTASKS=4",
+		"PBS_JOBID=7.server",
+		"SINGULARITYENV_SLURM_JOB_ID=1",
+		"PATH=/usr/bin",
+		"PS1=test",
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     map[string]string
+	}{
+		{
+			name: "NoPatterns",
+			want: map[string]string{},
+		},
+		{
+			name:     "Slurm",
+			patterns: []string{"SLURM_*"},
+			want: map[string]string{
+				"SLURM_JOB_ID": "1234",
+				"SLURM_NTASKS": "4",
+			},
+		},
+		{
+			name:     "Multiple",
+			patterns: []string{"SLURM_JOB_ID", "PBS_*", "LSB_*"},
+			want: map[string]string{
+				"SLURM_JOB_ID": "1234",
+				"PBS_JOBID":    "7.server",
+			},
+		},
+		{
+			name:     "Omitted",
+			patterns: []string{"*"},
+			want: map[string]string{
+				"SLURM_JOB_ID": "1234",
+				"SLURM_NTASKS": "4",
+				"PBS_JOBID":    "7.server",
+				"PS1":          "test",
+			},
+		},
+		{
+			name:     "Invalid",
+			patterns: []string{"SLURM_[", "PBS_*"},
+			want: map[string]string{
+				"PBS_JOBID": "7.server",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, tt.want, BatchJobEnvMap(hostEnv, tt.patterns))
+		})
+	}
+}
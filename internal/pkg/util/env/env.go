@@ -93,6 +93,26 @@ func FileMap(ctx context.Context, f string, args []string, hostEnv []string) (ma
 	return envMap, nil
 }
 
+// FilesMap returns a map of KEY=VAL env vars from the environment files fs,
+// evaluated in order as with FileMap. Variables set by a file take precedence
+// over those set by earlier files, and are visible to later files, so that
+// they can be expanded there.
+func FilesMap(ctx context.Context, fs []string, args []string, hostEnv []string) (map[string]string, error) {
+	envMap := map[string]string{}
+	for _, f := range fs {
+		fileEnv := append([]string{}, hostEnv...)
+		for k, v := range envMap {
+			fileEnv = append(fileEnv, k+"="+v)
+		}
+		m, err := FileMap(ctx, f, args, fileEnv)
+		if err != nil {
+			return envMap, fmt.Errorf("while processing %s: %w", f, err)
+		}
+		envMap = MergeMap(envMap, m)
+	}
+	return envMap, nil
+}
+
 // MergeMap merges two maps of environment variables, with values in b replacing
 // values also set in a.
 func MergeMap(a map[string]string, b map[string]string) map[string]string {
@@ -205,3 +205,65 @@ func TestEnvFileMap(t *testing.T) {
 		})
 	}
 }
+
+func TestEnvFilesMap(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Could not write test env-file: %v", err)
+		}
+		return path
+	}
+	base := write("base", "FOO=base\nBAR=base\nDIR=$HOST_DIR/base")
+	override := write("override", "BAR=override\nDIR=\"$DIR:$FOO\"\nMULTI='one\ntwo'")
+	invalid := write("invalid", "!!!@@NOTAVAR")
+
+	tests := []struct {
+		name    string
+		files   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "None",
+			want: map[string]string{},
+		},
+		{
+			name:  "Single",
+			files: []string{base},
+			want: map[string]string{
+				"FOO": "base",
+				"BAR": "base",
+				"DIR": "/host/base",
+			},
+		},
+		{
+			name:  "Override",
+			files: []string{base, override},
+			want: map[string]string{
+				"FOO":   "base",
+				"BAR":   "override",
+				"DIR":   "/host/base:base",
+				"MULTI": "one\ntwo",
+			},
+		},
+		{
+			name:    "Invalid",
+			files:   []string{base, invalid},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FilesMap(context.Background(), tt.files, []string{}, []string{"HOST_DIR=/host", "DIR=/ignored"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FilesMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilesMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
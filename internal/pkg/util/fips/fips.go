@@ -0,0 +1,134 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package fips implements the strict FIPS mode, which restricts signing,
+// encryption, and TLS connections to FIPS 140 approved algorithms.
+//
+// Strict FIPS mode is always enabled when singularity is built with the Go
+// BoringCrypto FIPS 140 module (GOEXPERIMENT=boringcrypto), which also
+// restricts all TLS connections to FIPS approved settings. Otherwise it can be
+// enabled at runtime with the 'fips mode' directive of singularity.conf.
+package fips
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// minRSABits is the minimum size of RSA keys allowed in strict FIPS mode.
+const minRSABits = 2048
+
+// cipherSuites are the FIPS approved TLS 1.2 cipher suites. TLS 1.3 cipher
+// suites are not configurable.
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// curves are the FIPS approved TLS key exchange curves.
+var curves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+var enabled = toolchain
+
+// Enabled returns true if strict FIPS mode is enabled.
+func Enabled() bool {
+	return enabled
+}
+
+// SetEnabled enables or disables strict FIPS mode. Strict FIPS mode can't be
+// disabled when built with the Go FIPS 140 module.
+func SetEnabled(b bool) {
+	enabled = b || toolchain
+}
+
+// Backend returns a description of the cryptographic backend in use.
+func Backend() string {
+	return backend()
+}
+
+// CheckPublicKey returns an error if pub is not a FIPS approved key, when
+// strict FIPS mode is enabled.
+func CheckPublicKey(pub crypto.PublicKey) error {
+	if !enabled {
+		return nil
+	}
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return fmt.Errorf("%d bit RSA keys are not allowed in strict FIPS mode, at least %d bits are required", k.N.BitLen(), minRSABits)
+		}
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return fmt.Errorf("ECDSA curve %s is not allowed in strict FIPS mode", k.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("%T keys are not allowed in strict FIPS mode", pub)
+	}
+	return nil
+}
+
+// CheckKey returns an error if the public key of the signer or verifier p is
+// not a FIPS approved key, when strict FIPS mode is enabled.
+func CheckKey(p signature.PublicKeyProvider) error {
+	if !enabled {
+		return nil
+	}
+	pub, err := p.PublicKey()
+	if err != nil {
+		return err
+	}
+	return CheckPublicKey(pub)
+}
+
+// CheckEntity returns an error if the primary key of the PGP entity e is not a
+// FIPS approved key, when strict FIPS mode is enabled.
+func CheckEntity(e *openpgp.Entity) error {
+	if !enabled {
+		return nil
+	}
+	if e.PrimaryKey == nil {
+		return fmt.Errorf("PGP entity has no primary key")
+	}
+	return CheckPublicKey(e.PrimaryKey.PublicKey)
+}
+
+// TLSConfig restricts c to FIPS approved TLS versions, cipher suites and
+// curves, when strict FIPS mode is enabled. A new configuration is returned if
+// c is nil.
+func TLSConfig(c *tls.Config) *tls.Config {
+	if c == nil {
+		c = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if !enabled {
+		return c
+	}
+	if c.MinVersion < tls.VersionTLS12 {
+		c.MinVersion = tls.VersionTLS12
+	}
+	c.CipherSuites = cipherSuites
+	c.CurvePreferences = curves
+	return c
+}
+
+// ConfigureTransport restricts the TLS configuration of rt to FIPS approved
+// settings, when strict FIPS mode is enabled and rt is an *http.Transport.
+func ConfigureTransport(rt http.RoundTripper) {
+	if t, ok := rt.(*http.Transport); ok && enabled {
+		t.TLSClientConfig = TLSConfig(t.TLSClientConfig)
+	}
+}
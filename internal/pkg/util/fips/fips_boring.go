@@ -0,0 +1,24 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build boringcrypto
+
+package fips
+
+import (
+	"crypto/boring"
+	// Restrict all TLS connections to FIPS approved settings.
+	_ "crypto/tls/fipsonly"
+)
+
+// toolchain is true when built with the Go BoringCrypto FIPS 140 module.
+const toolchain = true
+
+func backend() string {
+	if boring.Enabled() {
+		return "BoringCrypto (FIPS 140 module)"
+	}
+	return "Go standard library (BoringCrypto unavailable)"
+}
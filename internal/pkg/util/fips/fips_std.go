@@ -0,0 +1,15 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !boringcrypto
+
+package fips
+
+// toolchain is true when built with the Go BoringCrypto FIPS 140 module.
+const toolchain = false
+
+func backend() string {
+	return "Go standard library"
+}
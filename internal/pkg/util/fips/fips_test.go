@@ -0,0 +1,88 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fips
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+)
+
+func TestCheckPublicKey(t *testing.T) {
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pub     crypto.PublicKey
+		wantErr bool
+	}{
+		{name: "RSA1024", pub: &rsa1024.PublicKey, wantErr: true},
+		{name: "RSA2048", pub: &rsa2048.PublicKey},
+		{name: "P256", pub: &p256.PublicKey},
+		{name: "P224", pub: &p224.PublicKey, wantErr: true},
+		{name: "Ed25519", pub: ed, wantErr: true},
+	}
+
+	defer SetEnabled(false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetEnabled(false)
+			if err := CheckPublicKey(tt.pub); err != nil && !toolchain {
+				t.Errorf("CheckPublicKey() error = %v with strict FIPS mode disabled", err)
+			}
+			SetEnabled(true)
+			if err := CheckPublicKey(tt.pub); (err != nil) != tt.wantErr {
+				t.Errorf("CheckPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	defer SetEnabled(false)
+
+	SetEnabled(true)
+	c := TLSConfig(&tls.Config{MinVersion: tls.VersionTLS10})
+	if c.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want %x", c.MinVersion, tls.VersionTLS12)
+	}
+	for _, cs := range c.CipherSuites {
+		found := false
+		for _, approved := range cipherSuites {
+			found = found || cs == approved
+		}
+		if !found {
+			t.Errorf("cipher suite %s is not approved", tls.CipherSuiteName(cs))
+		}
+	}
+	if len(c.CurvePreferences) == 0 {
+		t.Errorf("no curve preferences set")
+	}
+}
@@ -86,3 +86,54 @@ func TestResolvConf(t *testing.T) {
 		t.Errorf("ResolvConf returns a bad content")
 	}
 }
+
+func TestResolvConfSearch(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	_, err := ResolvConfSearch([]byte("nameserver 8.8.8.8\n"), []string{})
+	if err == nil {
+		t.Errorf("should have failed with empty search list")
+	}
+	_, err = ResolvConfSearch([]byte("nameserver 8.8.8.8\n"), []string{"bad|domain"})
+	if err == nil {
+		t.Errorf("should have failed with bad search domain")
+	}
+	content, err := ResolvConfSearch(
+		[]byte("domain old.example.com\nnameserver 8.8.8.8\nsearch old.example.com\noptions ndots:2"),
+		[]string{"example.com", "example.org"},
+	)
+	if err != nil {
+		t.Errorf("should have passed with valid search domains")
+	}
+	expected := "nameserver 8.8.8.8\noptions ndots:2\nsearch example.com example.org\n"
+	if !bytes.Equal(content, []byte(expected)) {
+		t.Errorf("ResolvConfSearch returns a bad content: %q", content)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	_, err := Hosts(DefaultHosts(), []string{"myhost"})
+	if err == nil {
+		t.Errorf("should have failed with missing ip")
+	}
+	_, err = Hosts(DefaultHosts(), []string{"bad|host:10.0.0.1"})
+	if err == nil {
+		t.Errorf("should have failed with bad hostname")
+	}
+	_, err = Hosts(DefaultHosts(), []string{"myhost:10.0.0"})
+	if err == nil {
+		t.Errorf("should have failed with bad ip")
+	}
+	content, err := Hosts([]byte("127.0.0.1 localhost"), []string{"myhost:10.0.0.1", "myhost6:fe80::1"})
+	if err != nil {
+		t.Errorf("should have passed with valid host entries")
+	}
+	expected := "127.0.0.1 localhost\n10.0.0.1\tmyhost\nfe80::1\tmyhost6\n"
+	if !bytes.Equal(content, []byte(expected)) {
+		t.Errorf("Hosts returns a bad content: %q", content)
+	}
+}
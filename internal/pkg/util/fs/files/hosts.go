@@ -0,0 +1,42 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package files
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// Hosts appends entries to the hosts file content and returns it. Each entry
+// must be in <hostname>:<ip> format, as used by the --add-host flag.
+func Hosts(content []byte, entries []string) ([]byte, error) {
+	sylog.Verbosef("Adding custom entries to hosts content\n")
+	r := regexp.MustCompile(hostRegex)
+
+	out := append([]byte{}, content...)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	for _, e := range entries {
+		// The IP may be IPv6, which contains colons, so split on the first one only.
+		host, ip, found := strings.Cut(e, ":")
+		if !found {
+			return nil, fmt.Errorf("host entry %q must be in <hostname>:<ip> format", e)
+		}
+		if !r.MatchString(host) {
+			return nil, fmt.Errorf("%s is not a valid hostname", host)
+		}
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("%s is not a valid IP address", ip)
+		}
+		out = append(out, fmt.Sprintf("%s\t%s\n", ip, host)...)
+	}
+	return out, nil
+}
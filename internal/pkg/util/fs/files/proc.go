@@ -0,0 +1,145 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseCPUList parses a list of CPUs in the cpuset format, e.g. "0-3,6", and
+// returns the sorted CPU numbers.
+func ParseCPUList(list string) ([]int, error) {
+	var cpus []int
+	seen := map[int]bool{}
+
+	for _, r := range strings.Split(strings.TrimSpace(list), ",") {
+		if r == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(r, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q: %s", list, err)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpu list %q: %s", list, err)
+			}
+		}
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("invalid cpu list %q: bad range %s", list, r)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("invalid cpu list %q: no cpus", list)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// CPUInfo takes the content of a /proc/cpuinfo file, and returns it with only
+// the processor entries of the given CPUs, renumbered from 0. Entries that
+// don't describe a processor are kept.
+func CPUInfo(content []byte, cpus []int) ([]byte, error) {
+	keep := map[int]bool{}
+	for _, cpu := range cpus {
+		keep[cpu] = true
+	}
+
+	var out bytes.Buffer
+	index := 0
+	for _, block := range bytes.SplitAfter(content, []byte("\n\n")) {
+		if len(bytes.TrimSpace(block)) == 0 {
+			continue
+		}
+
+		processor := -1
+		var lines []string
+		scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimRight(block, "\n")))
+		for scanner.Scan() {
+			line := scanner.Text()
+			key, value, ok := strings.Cut(line, ":")
+			if ok && strings.TrimSpace(key) == "processor" {
+				n, err := strconv.Atoi(strings.TrimSpace(value))
+				if err != nil {
+					return nil, fmt.Errorf("invalid processor entry %q", line)
+				}
+				processor = n
+				line = fmt.Sprintf("%s: %d", key, index)
+			}
+			lines = append(lines, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		if processor >= 0 {
+			if !keep[processor] {
+				continue
+			}
+			index++
+		}
+		out.WriteString(strings.Join(lines, "\n"))
+		out.WriteString("\n\n")
+	}
+
+	if index == 0 {
+		return nil, fmt.Errorf("none of the cpus %v were found in cpuinfo", cpus)
+	}
+	return out.Bytes(), nil
+}
+
+// MemInfo takes the content of a /proc/meminfo file, and returns it with the
+// total memory set to limit bytes, and the free and available memory capped to
+// the limit. The content is unchanged if the limit exceeds the total memory.
+func MemInfo(content []byte, limit int64) ([]byte, error) {
+	limitKB := limit / 1024
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			out.WriteString(line + "\n")
+			continue
+		}
+
+		switch key {
+		case "MemTotal", "MemFree", "MemAvailable":
+			fields := strings.Fields(value)
+			if len(fields) != 2 || fields[1] != "kB" {
+				return nil, fmt.Errorf("invalid meminfo entry %q", line)
+			}
+			kb, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid meminfo entry %q: %s", line, err)
+			}
+			if kb > limitKB {
+				kb = limitKB
+			}
+			line = fmt.Sprintf("%-16s%8d kB", key+":", kb)
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
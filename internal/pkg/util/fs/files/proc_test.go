@@ -0,0 +1,80 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package files
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "0", want: []int{0}},
+		{list: "0-3,6\n", want: []int{0, 1, 2, 3, 6}},
+		{list: "6,0-1,1", want: []int{0, 1, 6}},
+		{list: "", wantErr: true},
+		{list: "3-1", wantErr: true},
+		{list: "a-b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCPUList(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCPUList(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCPUList(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestCPUInfo(t *testing.T) {
+	cpuinfo := "processor\t: 0\nmodel name\t: A\n\n" +
+		"processor\t: 1\nmodel name\t: B\n\n" +
+		"processor\t: 2\nmodel name\t: C\n\n"
+
+	got, err := CPUInfo([]byte(cpuinfo), []int{1, 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "processor\t: 0\nmodel name\t: B\n\n" +
+		"processor\t: 1\nmodel name\t: C\n\n"
+	if string(got) != want {
+		t.Errorf("CPUInfo returns a bad content: %q", got)
+	}
+
+	if _, err := CPUInfo([]byte(cpuinfo), []int{8}); err == nil {
+		t.Errorf("should have failed with unknown cpu")
+	}
+}
+
+func TestMemInfo(t *testing.T) {
+	meminfo := "MemTotal:       16000000 kB\n" +
+		"MemFree:         1000000 kB\n" +
+		"MemAvailable:    8000000 kB\n" +
+		"Buffers:          100000 kB\n"
+
+	got, err := MemInfo([]byte(meminfo), 4096000*1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "MemTotal:        4096000 kB\n" +
+		"MemFree:         1000000 kB\n" +
+		"MemAvailable:    4096000 kB\n" +
+		"Buffers:          100000 kB\n"
+	if string(got) != want {
+		t.Errorf("MemInfo returns a bad content: %q", got)
+	}
+
+	if _, err := MemInfo([]byte("MemTotal: lots\n"), 1024); err == nil {
+		t.Errorf("should have failed with bad meminfo")
+	}
+}
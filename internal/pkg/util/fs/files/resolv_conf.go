@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2020, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -8,6 +8,8 @@ package files
 import (
 	"fmt"
 	"net"
+	"regexp"
+	"strings"
 
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
@@ -27,3 +29,33 @@ func ResolvConf(dns []string) (content []byte, err error) {
 	}
 	return content, nil
 }
+
+// ResolvConfSearch replaces any search / domain lines in the resolv.conf
+// content with a search line for the provided domain list, and returns it.
+func ResolvConfSearch(content []byte, search []string) ([]byte, error) {
+	sylog.Verbosef("Setting resolv.conf search domains\n")
+	if len(search) == 0 {
+		return nil, fmt.Errorf("no dns search domain provided")
+	}
+	r := regexp.MustCompile(hostRegex)
+	for _, d := range search {
+		// A single dot is allowed, and means no search domain.
+		if d != "." && !r.MatchString(d) {
+			return nil, fmt.Errorf("dns search domain %s is not a valid domain name", d)
+		}
+	}
+
+	var out []byte
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && (fields[0] == "search" || fields[0] == "domain") {
+			continue
+		}
+		out = append(out, line...)
+	}
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	out = append(out, fmt.Sprintf("search %s\n", strings.Join(search, " "))...)
+	return out, nil
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package ioprofile records the files of a container image that are opened
+// by a workload, so that a profile of them can be written for use by lazy
+// pull prefetch, or image minimization tooling.
+package ioprofile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// File is the record of a file opened by the profiled workload.
+type File struct {
+	// Path is the path of the file in the container.
+	Path string `json:"path"`
+	// First is the time of the first open of the file, in milliseconds since
+	// the start of the profile.
+	First int64 `json:"first"`
+	// Opens is the number of times the file was opened.
+	Opens int `json:"opens"`
+}
+
+// Profile is the record of the files opened by a workload, written by
+// WriteProfile.
+type Profile struct {
+	// Image is the image that the workload was run from.
+	Image string `json:"image"`
+	// Duration is the duration of the profile, in milliseconds.
+	Duration int64 `json:"duration"`
+	// Files are the files opened, in the order they were first opened.
+	Files []File `json:"files"`
+}
+
+// Profiler records the files opened on the filesystem of a container rootfs,
+// using fanotify. As the whole filesystem is watched, opens in any mount of it
+// are seen, including those in the mount namespace of the container. Opens
+// through a mount of the mount namespace of the Profiler are only recorded
+// below rootfs, so that files opened by host processes are left out when the
+// rootfs is a directory of a host filesystem. Watching a filesystem requires
+// CAP_SYS_ADMIN.
+type Profiler struct {
+	rootfs string
+	start  time.Time
+	f      *os.File
+	done   chan struct{}
+	err    error
+
+	// mounts records whether the mount IDs seen in events are those of
+	// mounts in the mount namespace of the Profiler.
+	mounts map[int]bool
+
+	mu    sync.Mutex
+	files map[string]*File
+	end   time.Time
+}
+
+// New returns a Profiler, recording the files opened on the filesystem mounted
+// at rootfs.
+func New(rootfs string) (*Profiler, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE)
+	if errors.Is(err, unix.EPERM) {
+		return nil, fmt.Errorf("profiling file accesses requires CAP_SYS_ADMIN: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while initializing fanotify: %w", err)
+	}
+	mask := uint64(unix.FAN_OPEN | unix.FAN_OPEN_EXEC)
+	if err := unix.FanotifyMark(fd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM, mask, unix.AT_FDCWD, rootfs); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("while watching %s: %w", rootfs, err)
+	}
+
+	p := newProfiler(rootfs)
+	p.f = os.NewFile(uintptr(fd), "fanotify")
+	go func() {
+		defer close(p.done)
+		p.err = p.serve()
+	}()
+	return p, nil
+}
+
+func newProfiler(rootfs string) *Profiler {
+	return &Profiler{
+		rootfs: filepath.Clean(rootfs),
+		start:  time.Now(),
+		done:   make(chan struct{}),
+		mounts: make(map[int]bool),
+		files:  make(map[string]*File),
+	}
+}
+
+// Stop stops the Profiler, once the profiled container has exited.
+func (p *Profiler) Stop() error {
+	p.mu.Lock()
+	p.end = time.Now()
+	p.mu.Unlock()
+
+	p.f.Close()
+	<-p.done
+	return p.err
+}
+
+// Files returns the files opened by the profiled workload, in the order they
+// were first opened.
+func (p *Profiler) Files() []File {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	files := make([]File, 0, len(p.files))
+	for _, f := range p.files {
+		files = append(files, *f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].First != files[j].First {
+			return files[i].First < files[j].First
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// WriteProfile writes the profile of the files opened by the workload, run
+// from image, to path as JSON.
+func (p *Profiler) WriteProfile(path, image string) error {
+	p.mu.Lock()
+	end := p.end
+	p.mu.Unlock()
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	profile := Profile{
+		Image:    image,
+		Duration: end.Sub(p.start).Milliseconds(),
+		Files:    p.Files(),
+	}
+	b, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// ReadPaths returns the paths of the files listed in the profile at path, and
+// the image the profile was recorded from, if known. The profile is either a
+// JSON profile written by WriteProfile, or a text file listing one path per
+// line, as taken by build --access-profile, where empty lines and lines
+// starting with # are ignored.
+func ReadPaths(path string) (paths []string, image string, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		var profile Profile
+		if err := json.Unmarshal(trimmed, &profile); err != nil {
+			return nil, "", fmt.Errorf("while decoding profile %s: %w", path, err)
+		}
+		paths = make([]string, 0, len(profile.Files))
+		for _, f := range profile.Files {
+			paths = append(paths, f.Path)
+		}
+		return paths, profile.Image, nil
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, "", nil
+}
+
+// containerPath returns the path in the container of a file opened at path,
+// which is given as seen from the host, below rootfs, if the file was opened
+// through a mount of the mount namespace of the Profiler, or from within the
+// container if foreign is true. False is returned if the file was opened by a
+// host process, outside of rootfs.
+func (p *Profiler) containerPath(path string, foreign bool) (string, bool) {
+	if rel, ok := strings.CutPrefix(path, p.rootfs); ok && (rel == "" || rel[0] == '/') {
+		if rel == "" {
+			rel = "/"
+		}
+		return rel, true
+	}
+	return path, foreign
+}
+
+// foreignMount returns true if the mount with ID mntID is not a mount of the
+// mount namespace of the Profiler, such as a mount of the container.
+func (p *Profiler) foreignMount(mntID int) bool {
+	if own, ok := p.mounts[mntID]; ok {
+		return !own
+	}
+	// New mounts may have been made in our namespace since the last lookup.
+	ids, err := mountIDs()
+	if err != nil {
+		return false
+	}
+	for id := range ids {
+		p.mounts[id] = true
+	}
+	if !ids[mntID] {
+		p.mounts[mntID] = false
+	}
+	return !p.mounts[mntID]
+}
+
+// mountIDs returns the IDs of the mounts in the current mount namespace.
+func mountIDs() (map[int]bool, error) {
+	b, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int]bool)
+	for _, line := range strings.Split(string(b), "\n") {
+		id, _, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(id); err == nil {
+			ids[n] = true
+		}
+	}
+	return ids, nil
+}
+
+// fdMountID returns the ID of the mount through which the file descriptor fd
+// was opened.
+func fdMountID(fd int) (int, error) {
+	b, err := os.ReadFile("/proc/self/fdinfo/" + strconv.Itoa(fd))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if v, ok := strings.CutPrefix(line, "mnt_id:"); ok {
+			return strconv.Atoi(strings.TrimSpace(v))
+		}
+	}
+	return 0, fmt.Errorf("no mount ID for file descriptor %d", fd)
+}
+
+// record records an open of the file at path in the container.
+func (p *Profiler) record(path string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f, ok := p.files[path]; ok {
+		f.Opens++
+		return
+	}
+	p.files[path] = &File{
+		Path:  path,
+		First: at.Sub(p.start).Milliseconds(),
+		Opens: 1,
+	}
+}
+
+// serve reads fanotify events until the Profiler is stopped, recording the
+// path of the file opened for each.
+func (p *Profiler) serve() error {
+	buf := make([]byte, 4096*int(unsafe.Sizeof(unix.FanotifyEventMetadata{})))
+	for {
+		n, err := p.f.Read(buf)
+		if errors.Is(err, os.ErrClosed) || errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("while reading fanotify events: %w", err)
+		}
+		now := time.Now()
+
+		for off := 0; off+int(unsafe.Sizeof(unix.FanotifyEventMetadata{})) <= n; {
+			ev := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[off]))
+			if ev.Event_len == 0 {
+				break
+			}
+			off += int(ev.Event_len)
+			if ev.Vers != unix.FANOTIFY_METADATA_VERSION {
+				return fmt.Errorf("unsupported fanotify metadata version %d", ev.Vers)
+			}
+			if ev.Fd < 0 {
+				continue
+			}
+			path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(ev.Fd)))
+			if err != nil {
+				unix.Close(int(ev.Fd))
+				continue
+			}
+			mntID, err := fdMountID(int(ev.Fd))
+			unix.Close(int(ev.Fd))
+			if err != nil {
+				continue
+			}
+			if path, ok := p.containerPath(path, p.foreignMount(mntID)); ok {
+				p.record(path, now)
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ioprofile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestContainerPath(t *testing.T) {
+	p := newProfiler("/var/lib/bundle/rootfs")
+
+	tests := []struct {
+		name    string
+		path    string
+		foreign bool
+		want    string
+		wantOK  bool
+	}{
+		{name: "Rootfs", path: "/var/lib/bundle/rootfs/usr/bin/python3", want: "/usr/bin/python3", wantOK: true},
+		{name: "RootfsDir", path: "/var/lib/bundle/rootfs", want: "/", wantOK: true},
+		{name: "Container", path: "/usr/lib/libc.so.6", foreign: true, want: "/usr/lib/libc.so.6", wantOK: true},
+		{name: "Host", path: "/usr/lib/libc.so.6"},
+		{name: "HostPrefix", path: "/var/lib/bundle/rootfs-other/etc/hosts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := p.containerPath(tt.path, tt.foreign)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("containerPath(%q, %v) = %q, %v, want %q, %v", tt.path, tt.foreign, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestProfilerWriteProfile(t *testing.T) {
+	p := newProfiler("/var/lib/bundle/rootfs")
+	p.record("/usr/bin/python3", p.start.Add(5*time.Millisecond))
+	p.record("/usr/lib/libc.so.6", p.start.Add(2*time.Millisecond))
+	p.record("/usr/bin/python3", p.start.Add(9*time.Millisecond))
+
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := p.WriteProfile(path, "image.sif"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profile Profile
+	if err := json.Unmarshal(b, &profile); err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.Image != "image.sif" {
+		t.Errorf("got image %q, want %q", profile.Image, "image.sif")
+	}
+	want := []File{
+		{Path: "/usr/lib/libc.so.6", First: 2, Opens: 1},
+		{Path: "/usr/bin/python3", First: 5, Opens: 2},
+	}
+	if len(profile.Files) != len(want) {
+		t.Fatalf("got files %v, want %v", profile.Files, want)
+	}
+	for i := range want {
+		if profile.Files[i] != want[i] {
+			t.Errorf("got file %v, want %v", profile.Files[i], want[i])
+		}
+	}
+}
+
+func TestProfiler(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A file of the same filesystem, outside of the rootfs.
+	hostFile := filepath.Join(t.TempDir(), "host")
+	if err := os.WriteFile(hostFile, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(dir)
+	if errors.Is(err, unix.EPERM) {
+		t.Skipf("fanotify requires CAP_SYS_ADMIN: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.ReadFile(hostFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.ReadFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events are read asynchronously.
+	found := false
+	for i := 0; i < 50 && !found; i++ {
+		time.Sleep(10 * time.Millisecond)
+		for _, f := range p.Files() {
+			if f.Path == "/file" {
+				found = true
+			}
+		}
+	}
+	if err := p.Stop(); err != nil {
+		t.Errorf("Stop() = %v, want nil", err)
+	}
+	if !found {
+		t.Errorf("open of %s not recorded, got %v", file, p.Files())
+	}
+	for _, f := range p.Files() {
+		if f.Path != "/file" {
+			t.Errorf("unexpected open of %s recorded", f.Path)
+		}
+	}
+}
+
+func TestReadPaths(t *testing.T) {
+	dir := t.TempDir()
+
+	p := newProfiler("/rootfs")
+	p.record("/usr/bin/python3", p.start)
+	p.record("/etc/hosts", p.start.Add(time.Millisecond))
+	jsonProfile := filepath.Join(dir, "profile.json")
+	if err := p.WriteProfile(jsonProfile, "image.sif"); err != nil {
+		t.Fatal(err)
+	}
+
+	textProfile := filepath.Join(dir, "profile.txt")
+	if err := os.WriteFile(textProfile, []byte("# startup\n/usr/bin/python3\n\n  /etc/hosts\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path      string
+		wantImage string
+	}{
+		{path: jsonProfile, wantImage: "image.sif"},
+		{path: textProfile, wantImage: ""},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			got, image, err := ReadPaths(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"/usr/bin/python3", "/etc/hosts"}
+			if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+				t.Errorf("ReadPaths() = %v, want %v", got, want)
+			}
+			if image != tt.wantImage {
+				t.Errorf("ReadPaths() image = %q, want %q", image, tt.wantImage)
+			}
+		})
+	}
+}
@@ -90,16 +90,22 @@ func (u *Underlay) createLayer(rootFsPath string, system *mount.System) error {
 			if err == nil {
 				continue
 			}
-			fi, err := u.session.VFS.Stat(point.Source)
-			if err != nil {
-				sylog.Warningf("skipping mount of %s: %s", point.Source, err)
-				continue
+			// file system mounts, e.g. tmpfs, are always mounted on a
+			// directory
+			isDir := point.Type != ""
+			if !isDir {
+				fi, err := u.session.VFS.Stat(point.Source)
+				if err != nil {
+					sylog.Warningf("skipping mount of %s: %s", point.Source, err)
+					continue
+				}
+				isDir = fi.IsDir()
 			}
 			underlayDst := filepath.Join(underlayDir, dst)
 			if _, err := u.session.GetPath(underlayDst); err == nil {
 				continue
 			}
-			if fi.IsDir() {
+			if isDir {
 				if err := u.session.AddDir(underlayDst); err != nil {
 					return err
 				}
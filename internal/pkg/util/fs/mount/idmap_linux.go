@@ -0,0 +1,143 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// GetIDMap returns the container uid and gid from the idmap option, in
+// idmap=<uid>[.<gid>] format. The gid is the uid if not specified.
+func GetIDMap(options []string) (uid, gid uint32, err error) {
+	for _, opt := range options {
+		if value, ok := strings.CutPrefix(opt, "idmap="); ok {
+			return ParseIDMap(value)
+		}
+	}
+	return 0, 0, fmt.Errorf("idmap option not found")
+}
+
+// ParseIDMap parses an idmap option value in <uid>[.<gid>] format. The gid is
+// the uid if not specified.
+func ParseIDMap(value string) (uid, gid uint32, err error) {
+	u, g, ok := strings.Cut(value, ".")
+	if !ok {
+		g = u
+	}
+	uid64, err := strconv.ParseUint(u, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid idmap uid %q: %w", u, err)
+	}
+	gid64, err := strconv.ParseUint(g, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid idmap gid %q: %w", g, err)
+	}
+	return uint32(uid64), uint32(gid64), nil
+}
+
+// OpenSource opens source, as the calling user, with O_PATH|O_NOFOLLOW, for
+// use as the source of OpenIDMappedTree. As permissions are checked on open,
+// and not when the mount is cloned from the returned file descriptor, source
+// must be opened before privileges are escalated. An error is returned if
+// source is a symlink, or is neither a directory nor a regular file.
+func OpenSource(source string) (int, error) {
+	fd, err := unix.Open(source, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("while opening %s: %w", source, err)
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("while reading attributes of %s: %w", source, err)
+	}
+	if mode := st.Mode & unix.S_IFMT; mode != unix.S_IFDIR && mode != unix.S_IFREG {
+		unix.Close(fd)
+		return -1, fmt.Errorf("%s is not a directory or regular file", source)
+	}
+	return fd, nil
+}
+
+// OpenIDMappedTree returns a file descriptor for a detached, idmapped, bind
+// mount of the file or directory opened as srcFd by OpenSource, which can be
+// attached with move_mount. File ownership on disk is mapped to the ownership
+// seen through the mount with uidMap and gidMap, in which ContainerID is an ID
+// on disk, and HostID the ID it is mapped to. Files owned by IDs that are not
+// mapped appear to be owned by the overflow IDs, and files cannot be created
+// by them. Submounts of the source are not included.
+//
+// Creating an idmapped mount requires CAP_SYS_ADMIN in the initial user
+// namespace, and a filesystem supporting idmapped mounts (Linux >= 5.12).
+func OpenIDMappedTree(srcFd int, uidMap, gidMap []specs.LinuxIDMapping) (int, error) {
+	usernsFd, err := openUserNamespace(uidMap, gidMap)
+	if err != nil {
+		return -1, fmt.Errorf("while creating user namespace for idmapped mount: %w", err)
+	}
+	defer unix.Close(usernsFd)
+
+	fd, err := unix.OpenTree(srcFd, "", unix.OPEN_TREE_CLONE|unix.OPEN_TREE_CLOEXEC|unix.AT_EMPTY_PATH)
+	if err != nil {
+		return -1, fmt.Errorf("while cloning mount: %w", err)
+	}
+
+	attr := unix.MountAttr{
+		Attr_set:  unix.MOUNT_ATTR_IDMAP,
+		Userns_fd: uint64(usernsFd),
+	}
+	if err := unix.MountSetattr(fd, "", unix.AT_EMPTY_PATH, &attr); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("while setting idmap on mount: %w", err)
+	}
+	return fd, nil
+}
+
+// openUserNamespace returns a file descriptor for a new user namespace with
+// the ID mappings uidMap and gidMap. The user namespace is created by a
+// child process, which is stopped by ptrace before it executes, and killed
+// once the namespace has been opened.
+func openUserNamespace(uidMap, gidMap []specs.LinuxIDMapping) (int, error) {
+	// The tracer of the child is the thread that started it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd := exec.Command("/proc/self/exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER,
+		UidMappings: sysProcIDMap(uidMap),
+		GidMappings: sysProcIDMap(gidMap),
+		Ptrace:      true,
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	if err := cmd.Start(); err != nil {
+		return -1, err
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	return unix.Open(fmt.Sprintf("/proc/%d/ns/user", cmd.Process.Pid), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+}
+
+// sysProcIDMap converts OCI ID mappings to syscall ID mappings.
+func sysProcIDMap(mappings []specs.LinuxIDMapping) []syscall.SysProcIDMap {
+	m := make([]syscall.SysProcIDMap, 0, len(mappings))
+	for _, mapping := range mappings {
+		m = append(m, syscall.SysProcIDMap{
+			ContainerID: int(mapping.ContainerID),
+			HostID:      int(mapping.HostID),
+			Size:        int(mapping.Size),
+		})
+	}
+	return m
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseIDMap(t *testing.T) {
+	tests := []struct {
+		value    string
+		uid, gid uint32
+		wantErr  bool
+	}{
+		{"999", 999, 999, false},
+		{"999.100", 999, 100, false},
+		{"", 0, 0, true},
+		{"999.", 0, 0, true},
+		{"user.group", 0, 0, true},
+		{"-1", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		uid, gid, err := ParseIDMap(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIDMap(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if uid != tt.uid || gid != tt.gid {
+			t.Errorf("ParseIDMap(%q) = %d, %d, want %d, %d", tt.value, uid, gid, tt.uid, tt.gid)
+		}
+	}
+
+	if _, _, err := GetIDMap([]string{"offset=31", "idmap=1.2"}); err != nil {
+		t.Errorf("GetIDMap() error = %v", err)
+	}
+	if _, _, err := GetIDMap([]string{"offset=31"}); err == nil {
+		t.Errorf("GetIDMap() without idmap option succeeded")
+	}
+}
+
+func TestOpenIDMappedTree(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	source := t.TempDir()
+	target := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files owned by root on disk are seen as owned by 1234:5678.
+	uidMap := []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1234, Size: 1}}
+	gidMap := []specs.LinuxIDMapping{{ContainerID: 0, HostID: 5678, Size: 1}}
+	srcFd, err := OpenSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(srcFd)
+	fd, err := OpenIDMappedTree(srcFd, uidMap, gidMap)
+	if err != nil {
+		t.Skipf("idmapped mounts are not supported: %v", err)
+	}
+	defer unix.Close(fd)
+
+	if err := unix.MoveMount(fd, "", unix.AT_FDCWD, target, unix.MOVE_MOUNT_F_EMPTY_PATH); err != nil {
+		t.Fatalf("while attaching idmapped mount: %v", err)
+	}
+	defer syscall.Unmount(target, syscall.MNT_DETACH)
+
+	fi, err := os.Stat(filepath.Join(target, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Fatalf("unexpected stat type %T", fi.Sys())
+	}
+	if st.Uid != 1234 || st.Gid != 5678 {
+		t.Errorf("file is owned by %d:%d, want 1234:5678", st.Uid, st.Gid)
+	}
+}
+
+func TestOpenSourceSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("/etc", link); err != nil {
+		t.Fatal(err)
+	}
+	if fd, err := OpenSource(link); err == nil {
+		unix.Close(fd)
+		t.Errorf("OpenSource(%s) succeeded on a symlink", link)
+	}
+
+	fd, err := OpenSource(dir)
+	if err != nil {
+		t.Fatalf("OpenSource(%s) error = %v", dir, err)
+	}
+	unix.Close(fd)
+}
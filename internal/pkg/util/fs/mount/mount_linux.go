@@ -160,7 +160,7 @@ var authorizedFS = map[string]fsContext{
 	"fuse":    {false},
 }
 
-var internalOptions = []string{"loop", "offset", "sizelimit", "key", "skip-on-error"}
+var internalOptions = []string{"loop", "offset", "sizelimit", "key", "skip-on-error", "idmap"}
 
 // Point describes a mount point
 type Point struct {
@@ -0,0 +1,176 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// maxParallelMounts bounds the number of mounts performed at once, as most
+// are FUSE mounts, each running a process.
+const maxParallelMounts = 8
+
+// Task is a mount operation to be performed by MountParallel.
+type Task struct {
+	// Target is the directory the operation mounts on.
+	Target string
+	// Source, if set, is the path the operation mounts from, such as the
+	// source of a bind mount, which may itself be mounted by another task.
+	Source string
+	// Mount performs the mount.
+	Mount func(ctx context.Context) error
+}
+
+// errSkipped is returned for tasks not run, because a task failed.
+var errSkipped = errors.New("skipped")
+
+// ParallelWorkers returns the number of mounts to perform at once. Mounts are
+// performed serially when 'parallel mounts' is disabled in singularity.conf.
+func ParallelWorkers() int {
+	if cfg := singularityconf.GetCurrentConfig(); cfg != nil && !cfg.ParallelMounts {
+		return 1
+	}
+	if n := runtime.NumCPU(); n < maxParallelMounts {
+		return n
+	}
+	return maxParallelMounts
+}
+
+// MountParallel performs the mounts of tasks, with up to workers of them at
+// once. A task depends on the tasks listed before it whose target is the same
+// as, above, or below its own target, as mounting them out of order would
+// change the resulting mount tree, and on those whose target is the same as,
+// or above, its source. A task is only started once the tasks it
+// depends on are done, so that independent mounts are performed concurrently
+// while dependent ones keep their order.
+//
+// Once a task fails, tasks that are not started yet are skipped. The returned
+// slice reports which tasks mounted successfully, so that the caller can
+// unmount them, along with the errors of failed tasks.
+func MountParallel(ctx context.Context, tasks []Task, workers int) ([]bool, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	deps := dependencies(tasks)
+	done := make([]chan struct{}, len(tasks))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	mounted := make([]bool, len(tasks))
+	errs := make([]error, len(tasks))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := range tasks {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, d := range deps[i] {
+				<-done[d]
+				if !mounted[d] {
+					errs[i] = errSkipped
+					return
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = errSkipped
+				return
+			}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				errs[i] = errSkipped
+				return
+			}
+			if err := tasks[i].Mount(ctx); err != nil {
+				errs[i] = fmt.Errorf("while mounting %s: %w", tasks[i].Target, err)
+				cancel()
+				return
+			}
+			mounted[i] = true
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errSkipped) {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		// only skipped tasks remain when the parent context was canceled
+		if err := ctx.Err(); err != nil && !allMounted(mounted) {
+			failed = append(failed, err)
+		}
+	}
+	return mounted, errors.Join(failed...)
+}
+
+// dependencies returns, for each task, the indices of the tasks listed before
+// it that it depends on.
+func dependencies(tasks []Task) [][]int {
+	deps := make([][]int, len(tasks))
+	for j := range tasks {
+		for i := 0; i < j; i++ {
+			if related(tasks[i].Target, tasks[j].Target) || holds(tasks[i].Target, tasks[j].Source) {
+				deps[j] = append(deps[j], i)
+			}
+		}
+	}
+	return deps
+}
+
+// related returns whether a and b are the same directory, or one holds the
+// other.
+func related(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	return a == b || isBelow(a, b) || isBelow(b, a)
+}
+
+// holds returns whether path is dir, or lies under it.
+func holds(dir, path string) bool {
+	if path == "" {
+		return false
+	}
+	dir, path = filepath.Clean(dir), filepath.Clean(path)
+	return path == dir || isBelow(path, dir)
+}
+
+// isBelow returns whether path lies under dir.
+func isBelow(path, dir string) bool {
+	if dir == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
+
+func allMounted(mounted []bool) bool {
+	for _, m := range mounted {
+		if !m {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"gotest.tools/v3/assert"
+)
+
+// recorder records the order in which mounts complete, and the maximum
+// number of mounts in progress at once.
+type recorder struct {
+	mu      sync.Mutex
+	order   []string
+	running int
+	max     int
+}
+
+func (r *recorder) task(target string, delay time.Duration, err error) Task {
+	return Task{
+		Target: target,
+		Mount: func(ctx context.Context) error {
+			r.mu.Lock()
+			r.running++
+			if r.running > r.max {
+				r.max = r.running
+			}
+			r.mu.Unlock()
+
+			time.Sleep(delay)
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.running--
+			if err != nil {
+				return err
+			}
+			r.order = append(r.order, target)
+			return nil
+		},
+	}
+}
+
+func (r *recorder) index(target string) int {
+	for i, t := range r.order {
+		if t == target {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestMountParallel(t *testing.T) {
+	t.Run("Independent", func(t *testing.T) {
+		r := &recorder{}
+		var tasks []Task
+		for i := 0; i < 8; i++ {
+			tasks = append(tasks, r.task(fmt.Sprintf("/layers/%d", i), 20*time.Millisecond, nil))
+		}
+		mounted, err := MountParallel(context.Background(), tasks, 4)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, mounted, []bool{true, true, true, true, true, true, true, true})
+		assert.Equal(t, r.max, 4)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		r := &recorder{}
+		// a nested mountpoint listed after its parent is mounted after it,
+		// even though the parent mount is slower
+		tasks := []Task{
+			r.task("/mnt/a", 50*time.Millisecond, nil),
+			r.task("/mnt/b", 0, nil),
+			r.task("/mnt/a/c", 0, nil),
+			r.task("/mnt", 0, nil),
+		}
+		_, err := MountParallel(context.Background(), tasks, 4)
+		assert.NilError(t, err)
+		assert.Assert(t, r.index("/mnt/a") < r.index("/mnt/a/c"))
+		assert.Equal(t, r.order[len(r.order)-1], "/mnt")
+	})
+
+	t.Run("Source", func(t *testing.T) {
+		r := &recorder{}
+		// a bind mount from an image mounted before it waits for the image
+		bind := r.task("/final/data", 0, nil)
+		bind.Source = "/session/images/0/data"
+		tasks := []Task{
+			r.task("/session/images/0", 50*time.Millisecond, nil),
+			bind,
+		}
+		_, err := MountParallel(context.Background(), tasks, 2)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, r.order, []string{"/session/images/0", "/final/data"})
+	})
+
+	t.Run("Serial", func(t *testing.T) {
+		r := &recorder{}
+		tasks := []Task{
+			r.task("/a", 10*time.Millisecond, nil),
+			r.task("/b", 0, nil),
+			r.task("/c", 0, nil),
+		}
+		_, err := MountParallel(context.Background(), tasks, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, r.max, 1)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		r := &recorder{}
+		tasks := []Task{
+			r.task("/a", 0, errors.New("bad image")),
+			r.task("/a/b", 0, nil),
+		}
+		mounted, err := MountParallel(context.Background(), tasks, 2)
+		assert.ErrorContains(t, err, "while mounting /a: bad image")
+		// the mount depending on the failed one is skipped
+		assert.DeepEqual(t, mounted, []bool{false, false})
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := &recorder{}
+		mounted, err := MountParallel(ctx, []Task{r.task("/a", 0, nil)}, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.DeepEqual(t, mounted, []bool{false})
+	})
+}
+
+func TestParallelWorkers(t *testing.T) {
+	defer singularityconf.SetCurrentConfig(singularityconf.GetCurrentConfig())
+
+	singularityconf.SetCurrentConfig(&singularityconf.File{ParallelMounts: false})
+	assert.Equal(t, ParallelWorkers(), 1)
+
+	singularityconf.SetCurrentConfig(&singularityconf.File{ParallelMounts: true})
+	assert.Assert(t, ParallelWorkers() >= 1 && ParallelWorkers() <= maxParallelMounts)
+}
+
+// BenchmarkMountParallel compares serial and parallel setup of mounts which,
+// as FUSE mounts, each take a few milliseconds to complete.
+func BenchmarkMountParallel(b *testing.B) {
+	tasks := make([]Task, 32)
+	for i := range tasks {
+		tasks[i] = Task{
+			Target: fmt.Sprintf("/layers/%d", i),
+			Mount: func(context.Context) error {
+				time.Sleep(2 * time.Millisecond)
+				return nil
+			},
+		}
+	}
+
+	for _, workers := range []int{1, 4, maxParallelMounts} {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := MountParallel(context.Background(), tasks, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@
 package mount
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -20,8 +21,17 @@ type mountFn func(*Point, *System) error
 // System defines a mount system allowing to register before/after
 // hook functions for specific tag during mount phase
 type System struct {
-	Points         *Points
-	Mount          mountFn
+	Points *Points
+	Mount  mountFn
+	// ParallelTags holds the tags whose points are mounted in parallel, with
+	// up to Workers of them at once, as done by MountParallel. The points of
+	// other tags, and all points when Workers is lower than 2, are mounted
+	// serially, in order.
+	ParallelTags map[AuthorizedTag]bool
+	Workers      int
+	// Target returns the path a point is mounted on, used to order the points
+	// mounted in parallel. The point destination is used when nil.
+	Target         func(*Point) string
 	currentTag     AuthorizedTag
 	beforeTagHooks map[AuthorizedTag][]hookFn
 	afterTagHooks  map[AuthorizedTag][]hookFn
@@ -74,13 +84,8 @@ func (b *System) MountAll() error {
 				return fmt.Errorf("hook function for tag %s returns error: %s", tag, err)
 			}
 		}
-		for _, point := range b.Points.GetByTag(tag) {
-			p := point
-			if b.Mount != nil {
-				if err := b.Mount(&p, b); err != nil {
-					return fmt.Errorf("mount %s->%s error: %s", p.Source, p.Destination, err)
-				}
-			}
+		if err := b.mountTag(tag); err != nil {
+			return err
 		}
 		for _, fn := range b.afterTagHooks[tag] {
 			if err := fn(b); err != nil {
@@ -90,3 +95,42 @@ func (b *System) MountAll() error {
 	}
 	return nil
 }
+
+// mountTag mounts the points of tag, in parallel if tag is one of
+// ParallelTags.
+func (b *System) mountTag(tag AuthorizedTag) error {
+	if b.Mount == nil {
+		return nil
+	}
+	points := b.Points.GetByTag(tag)
+	if !b.ParallelTags[tag] || b.Workers < 2 || len(points) < 2 {
+		for _, point := range points {
+			p := point
+			if err := b.Mount(&p, b); err != nil {
+				return fmt.Errorf("mount %s->%s error: %s", p.Source, p.Destination, err)
+			}
+		}
+		return nil
+	}
+
+	tasks := make([]Task, len(points))
+	for i := range points {
+		p := points[i]
+		target := p.Destination
+		if b.Target != nil {
+			target = b.Target(&p)
+		}
+		tasks[i] = Task{
+			Target: target,
+			Source: p.Source,
+			Mount: func(context.Context) error {
+				if err := b.Mount(&p, b); err != nil {
+					return fmt.Errorf("mount %s->%s error: %s", p.Source, p.Destination, err)
+				}
+				return nil
+			},
+		}
+	}
+	_, err := MountParallel(context.Background(), tasks, b.Workers)
+	return err
+}
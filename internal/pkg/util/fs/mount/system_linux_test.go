@@ -7,8 +7,11 @@ package mount
 
 import (
 	"fmt"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/sylabs/singularity/v4/internal/pkg/test"
 )
@@ -82,3 +85,63 @@ func TestSystem(t *testing.T) {
 		t.Errorf("mountFn wasn't executed")
 	}
 }
+
+func TestSystemParallel(t *testing.T) {
+	points := &Points{}
+	for _, dest := range []string{"/mnt/a", "/mnt/b", "/mnt/c", "/mnt/a/d"} {
+		if err := points.AddBind(UserbindsTag, "/etc", dest, syscall.MS_BIND); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var order []string
+	running, maxRunning := 0, 0
+	system := &System{
+		Points:       points,
+		ParallelTags: map[AuthorizedTag]bool{UserbindsTag: true},
+		Workers:      4,
+		Target: func(p *Point) string {
+			return filepath.Join("/final", p.Destination)
+		},
+		Mount: func(p *Point, _ *System) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+			order = append(order, p.Destination)
+			return nil
+		},
+	}
+	if err := system.MountAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 4 {
+		t.Fatalf("got %d mounts, want 4", len(order))
+	}
+	if maxRunning < 2 {
+		t.Errorf("points were not mounted in parallel")
+	}
+	// the nested mount point is mounted after its parent
+	if order[len(order)-1] != "/mnt/a/d" {
+		t.Errorf("/mnt/a/d mounted before /mnt/a: %v", order)
+	}
+
+	// points of other tags are mounted serially
+	system.ParallelTags = nil
+	order, maxRunning = nil, 0
+	if err := system.MountAll(); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning != 1 {
+		t.Errorf("points were mounted in parallel")
+	}
+}
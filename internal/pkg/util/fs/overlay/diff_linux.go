@@ -0,0 +1,240 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// whiteoutPrefix is the prefix of the name of a file marking the deletion of
+// a file with the rest of the name, in an OCI image layer tar.
+const whiteoutPrefix = ".wh."
+
+// opaqueXattrs are the extended attributes marking an opaque directory in an
+// overlay upper dir, which hides the content of the same directory in the
+// lower dirs. The user namespace attribute is used by rootless overlays.
+var opaqueXattrs = []string{"trusted.overlay.opaque", "user.overlay.opaque"}
+
+// ChangeKind is the kind of a change to a file in an overlay.
+type ChangeKind int
+
+const (
+	// ChangeAdd is a file added by the overlay.
+	ChangeAdd ChangeKind = iota
+	// ChangeModify is a file of the base image modified, or replaced, by the
+	// overlay.
+	ChangeModify
+	// ChangeDelete is a file of the base image deleted by the overlay.
+	ChangeDelete
+)
+
+// String returns the one letter code of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdd:
+		return "A"
+	case ChangeModify:
+		return "M"
+	case ChangeDelete:
+		return "D"
+	}
+	return "?"
+}
+
+// Change is a change made by an overlay to a file of its base image.
+type Change struct {
+	Kind ChangeKind
+	// Path is the absolute path of the file in the container.
+	Path string
+}
+
+// isWhiteout returns true if fi describes an overlayfs whiteout, a character
+// device with device number 0/0.
+func isWhiteout(fi fs.FileInfo) bool {
+	if fi.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Rdev == 0
+}
+
+// isOpaque returns true if the directory at path is an overlayfs opaque
+// directory.
+func isOpaque(path string) bool {
+	buf := make([]byte, 1)
+	for _, attr := range opaqueXattrs {
+		n, err := unix.Lgetxattr(path, attr, buf)
+		if err == nil && n == 1 && buf[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the changes made by an overlay, with upper dir upper, to the
+// root filesystem of its base image at lower, ordered by path.
+func Diff(upper, lower string) ([]Change, error) {
+	changes := []Change{}
+
+	err := filepath.WalkDir(upper, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upper, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		ctrPath := "/" + rel
+		lowerPath := filepath.Join(lower, rel)
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if isWhiteout(fi) {
+			changes = append(changes, Change{Kind: ChangeDelete, Path: ctrPath})
+			return nil
+		}
+
+		lfi, err := os.Lstat(lowerPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		inLower := err == nil
+
+		if !d.IsDir() {
+			kind := ChangeAdd
+			if inLower {
+				kind = ChangeModify
+			}
+			changes = append(changes, Change{Kind: kind, Path: ctrPath})
+			return nil
+		}
+
+		switch {
+		case !inLower:
+			changes = append(changes, Change{Kind: ChangeAdd, Path: ctrPath})
+		case !lfi.IsDir():
+			changes = append(changes, Change{Kind: ChangeModify, Path: ctrPath})
+		case isOpaque(p):
+			// The content of the directory in the base image is hidden, so
+			// anything not in the upper dir has been deleted.
+			deleted, err := opaqueDeletions(p, lowerPath, ctrPath)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, deleted...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// opaqueDeletions returns deletions of the entries of lowerDir that are not
+// present in the opaque upper directory upperDir, at ctrDir in the container.
+func opaqueDeletions(upperDir, lowerDir, ctrDir string) ([]Change, error) {
+	entries, err := os.ReadDir(lowerDir)
+	if err != nil {
+		return nil, err
+	}
+	changes := []Change{}
+	for _, e := range entries {
+		_, err := os.Lstat(filepath.Join(upperDir, e.Name()))
+		if errors.Is(err, os.ErrNotExist) {
+			changes = append(changes, Change{Kind: ChangeDelete, Path: path.Join(ctrDir, e.Name())})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// WriteTar writes changes, made by an overlay with upper dir upper, as a tar
+// archive to w. Added and modified files are copied from the upper dir.
+// Deleted files are written as whiteout files, as in an OCI image layer.
+func WriteTar(w io.Writer, upper string, changes []Change) error {
+	tw := tar.NewWriter(w)
+
+	for _, c := range changes {
+		name := c.Path[1:]
+
+		if c.Kind == ChangeDelete {
+			hdr := &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     path.Join(path.Dir(name), whiteoutPrefix+path.Base(name)),
+				Mode:     0o600,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := writeTarEntry(tw, filepath.Join(upper, name), name); err != nil {
+			return fmt.Errorf("while adding %s: %w", c.Path, err)
+		}
+	}
+
+	return tw.Close()
+}
+
+// writeTarEntry writes the file at p, named name, to tw.
+func writeTarEntry(tw *tar.Writer, p, name string) error {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
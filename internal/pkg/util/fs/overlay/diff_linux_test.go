@@ -0,0 +1,130 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package overlay
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+	"golang.org/x/sys/unix"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	lower := t.TempDir()
+	upper := t.TempDir()
+
+	writeFiles(t, lower, map[string]string{
+		"etc/hosts":   "lower",
+		"etc/passwd":  "lower",
+		"opt/app/bin": "lower",
+		"opt/app/lib": "lower",
+	})
+	writeFiles(t, upper, map[string]string{
+		"etc/hosts":      "upper",
+		"etc/new.conf":   "upper",
+		"home/user/file": "upper",
+	})
+	if err := os.Symlink("hosts", filepath.Join(upper, "etc", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{Kind: ChangeModify, Path: "/etc/hosts"},
+		{Kind: ChangeAdd, Path: "/etc/link"},
+		{Kind: ChangeAdd, Path: "/etc/new.conf"},
+		{Kind: ChangeAdd, Path: "/home"},
+		{Kind: ChangeAdd, Path: "/home/user"},
+		{Kind: ChangeAdd, Path: "/home/user/file"},
+	}
+
+	got, err := Diff(upper, lower)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTar(&buf, upper, got); err != nil {
+		t.Fatalf("WriteTar() error = %v", err)
+	}
+	names := []string{}
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	wantNames := []string{"etc/hosts", "etc/link", "etc/new.conf", "home/", "home/user/", "home/user/file"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("WriteTar() entries = %v, want %v", names, wantNames)
+	}
+}
+
+func TestDiffWhiteoutOpaque(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	lower := t.TempDir()
+	upper := t.TempDir()
+
+	writeFiles(t, lower, map[string]string{
+		"etc/passwd":  "lower",
+		"opt/app/bin": "lower",
+		"opt/app/lib": "lower",
+	})
+	writeFiles(t, upper, map[string]string{
+		"opt/app/bin": "upper",
+	})
+	if err := os.Mkdir(filepath.Join(upper, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Mknod(filepath.Join(upper, "etc", "passwd"), unix.S_IFCHR, 0); err != nil {
+		t.Fatalf("while creating whiteout: %v", err)
+	}
+	if err := unix.Setxattr(filepath.Join(upper, "opt", "app"), "trusted.overlay.opaque", []byte("y"), 0); err != nil {
+		t.Skipf("cannot set opaque xattr: %v", err)
+	}
+
+	want := []Change{
+		{Kind: ChangeDelete, Path: "/etc/passwd"},
+		{Kind: ChangeModify, Path: "/opt/app/bin"},
+		{Kind: ChangeDelete, Path: "/opt/app/lib"},
+	}
+
+	got, err := Diff(upper, lower)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+}
@@ -9,16 +9,22 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"syscall"
 
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
 	fsfuse "github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
 	"github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
 
+// GocryptfsConf is the configuration file found at the top of a directory
+// encrypted with gocryptfs.
+const GocryptfsConf = "gocryptfs.conf"
+
 // Item represents information about a single overlay item (as specified,
 // for example, in a single --overlay argument)
 type Item struct {
@@ -29,6 +35,10 @@ type Item struct {
 	// Readonly represents whether this is a readonly overlay
 	Readonly bool
 
+	// Encrypted represents whether this is a directory overlay encrypted with
+	// gocryptfs, whose decrypted view is mounted with FUSE
+	Encrypted bool
+
 	// SourcePath is the path of the overlay item, stripped of any
 	// colon-prefixed options (like ":ro")
 	SourcePath string
@@ -47,6 +57,10 @@ type Item struct {
 
 	// allowDev is set to true to mount the overlay item without the "nodev" option.
 	allowDev bool
+
+	// passfile is the (optional) path of a file holding the password of an
+	// encrypted overlay. If empty, gocryptfs prompts for the password.
+	passfile string
 }
 
 // NewItemFromString takes a string argument, as passed to --overlay, and
@@ -77,6 +91,7 @@ func NewItemFromString(overlayString string) (*Item, error) {
 
 	if s.IsDir() {
 		item.Type = image.SANDBOX
+		item.Encrypted = IsEncryptedDir(item.SourcePath)
 	} else if err := item.analyzeImageFile(); err != nil {
 		return nil, fmt.Errorf("while examining image file %s: %w", item.SourcePath, err)
 	}
@@ -106,6 +121,11 @@ func (i *Item) analyzeImageFile() error {
 	return nil
 }
 
+// IsEncryptedDir returns true if path is a directory encrypted with gocryptfs.
+func IsEncryptedDir(path string) bool {
+	return fs.IsFile(filepath.Join(path, GocryptfsConf))
+}
+
 // SetParentDir sets the parent-dir in which to create overlay-specific mount
 // directories.
 func (i *Item) SetParentDir(d string) {
@@ -122,6 +142,11 @@ func (i *Item) SetAllowSetuid(a bool) {
 	i.allowSetuid = a
 }
 
+// SetPassfile sets the file holding the password of an encrypted overlay.
+func (i *Item) SetPassfile(path string) {
+	i.passfile = path
+}
+
 // GetParentDir gets a parent-dir in which to create overlay-specific mount
 // directories. If one has not been set using SetParentDir(), one will be
 // created using os.MkdirTemp().
@@ -148,7 +173,11 @@ func (i *Item) Mount(ctx context.Context) error {
 	var err error
 	switch i.Type {
 	case image.SANDBOX:
-		err = i.mountDir()
+		if i.Encrypted {
+			err = i.mountEncrypted(ctx)
+		} else {
+			err = i.mountDir()
+		}
 
 	case image.SQUASHFS, image.EXT3:
 		err = i.mountWithFuse(ctx)
@@ -261,12 +290,74 @@ func (i *Item) mountWithFuse(ctx context.Context) error {
 	return nil
 }
 
+// mountEncrypted mounts the decrypted view of a gocryptfs directory to a
+// temporary directory. Without a passfile, gocryptfs prompts for the password
+// on the terminal.
+func (i *Item) mountEncrypted(ctx context.Context) error {
+	gocryptfsCmd, err := bin.FindBin("gocryptfs")
+	if err != nil {
+		return fmt.Errorf("use of encrypted directory %q as overlay requires gocryptfs to be installed: %w", i.SourcePath, err)
+	}
+	// Even though fusermount is not needed for this step, we shouldn't perform
+	// the mount unless we have the necessary tools to eventually unmount it
+	if _, err := bin.FindBin("fusermount"); err != nil {
+		return fmt.Errorf("use of encrypted directory %q as overlay requires fusermount to be installed: %w", i.SourcePath, err)
+	}
+
+	parentDir, err := i.GetParentDir()
+	if err != nil {
+		return err
+	}
+	mountpoint, err := os.MkdirTemp(parentDir, "mountpoint-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary dir for overlay %q: %w", i.SourcePath, err)
+	}
+
+	args := i.gocryptfsArgs()
+	args = append(args, i.SourcePath, mountpoint)
+
+	sylog.Debugf("Executing gocryptfs mount command: %s %s", gocryptfsCmd, strings.Join(args, " "))
+	execCmd := exec.CommandContext(ctx, gocryptfsCmd, args...)
+	execCmd.Stdin = os.Stdin
+	execCmd.Stderr = os.Stderr
+	if _, err := execCmd.Output(); err != nil {
+		os.Remove(mountpoint)
+		return fmt.Errorf("encountered error while trying to mount encrypted directory %q as overlay at %s: %w", i.SourcePath, mountpoint, err)
+	}
+
+	i.StagingDir = mountpoint
+
+	return nil
+}
+
+// gocryptfsArgs returns the gocryptfs options used to mount an encrypted Item.
+func (i Item) gocryptfsArgs() []string {
+	args := []string{"-q", "-nosyslog"}
+	if i.passfile != "" {
+		args = append(args, "-passfile", i.passfile)
+	}
+	if i.Readonly {
+		args = append(args, "-ro")
+	}
+	// gocryptfs defaults to nosuid,nodev - reverse if AllowDev/Setuid requested.
+	if i.allowDev {
+		args = append(args, "-dev")
+	}
+	if i.allowSetuid {
+		args = append(args, "-suid")
+	}
+	return args
+}
+
 // Unmount performs the necessary steps to unmount an individual Item. Note that
 // this method does not unmount the overlay itself. That happens in
 // Set.Unmount().
 func (i Item) Unmount(ctx context.Context) error {
 	switch i.Type {
 	case image.SANDBOX:
+		if i.Encrypted {
+			return i.unmountFuse(ctx)
+		}
 		return i.unmountDir(ctx)
 
 	case image.SQUASHFS, image.EXT3:
@@ -297,7 +388,9 @@ func (i Item) unmountFuse(ctx context.Context) error {
 func (i *Item) prepareWritableOverlay() error {
 	switch i.Type {
 	case image.SANDBOX:
-		i.StagingDir = i.SourcePath
+		if !i.Encrypted {
+			i.StagingDir = i.SourcePath
+		}
 		fallthrough
 	case image.EXT3:
 		if err := EnsureOverlayDir(i.StagingDir, true, 0o755); err != nil {
@@ -9,6 +9,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -77,6 +78,37 @@ func TestItemWritableField(t *testing.T) {
 	}
 }
 
+func TestItemEncryptedField(t *testing.T) {
+	plainDir := mkTempOlDirOrFatal(t)
+	encDir := mkTempOlDirOrFatal(t)
+	if err := os.WriteFile(filepath.Join(encDir, GocryptfsConf), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("while writing %s: %s", GocryptfsConf, err)
+	}
+
+	plainItem, err := NewItemFromString(plainDir)
+	if err != nil {
+		t.Fatalf("unexpected error while initializing plainItem from string %q: %s", plainDir, err)
+	}
+	encItem, err := NewItemFromString(encDir + ":ro")
+	if err != nil {
+		t.Fatalf("unexpected error while initializing encItem from string %q: %s", encDir, err)
+	}
+
+	if plainItem.Encrypted {
+		t.Errorf("Encrypted field of overlay.Item initialized with plain directory %q should be false but is true", plainDir)
+	}
+	if !encItem.Encrypted || encItem.Type != image.SANDBOX {
+		t.Errorf("overlay.Item initialized with gocryptfs directory %q should be an encrypted directory (Encrypted: %v, Type: %v)", encDir, encItem.Encrypted, encItem.Type)
+	}
+
+	encItem.SetPassfile("/path/to/passfile")
+	encItem.SetAllowSetuid(true)
+	wantArgs := []string{"-q", "-nosyslog", "-passfile", "/path/to/passfile", "-ro", "-suid"}
+	if args := encItem.gocryptfsArgs(); !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("unexpected gocryptfs arguments %v, expected %v", args, wantArgs)
+	}
+}
+
 func TestItemMissing(t *testing.T) {
 	const dir string = "/testoverlayitem-this_should_be_missing"
 	rwOverlayStr := dir
@@ -18,6 +18,7 @@ import (
 	"github.com/samber/lo"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
 	fsfuse "github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/mount"
 	"github.com/sylabs/singularity/v4/pkg/image"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
 )
@@ -71,7 +72,7 @@ func (s Set) Unmount(ctx context.Context, rootFsDir string) error {
 		return fmt.Errorf("while checking for unprivileged overlay support in kernel: %w", err)
 	}
 
-	useKernelMount := unprivOls && !s.hasWritableExtfsImg()
+	useKernelMount := unprivOls && !s.hasWritableFuseItem()
 	if useKernelMount {
 		err = DetachMount(ctx, rootFsDir)
 	} else {
@@ -93,14 +94,19 @@ func (s Set) performIndividualMounts(ctx context.Context) error {
 		overlaysToBind = append(overlaysToBind, s.WritableOverlay)
 	}
 
-	// Try to do initial bind-mounts
-	for _, o := range overlaysToBind {
-		if err := o.Mount(ctx); err != nil {
-			return err
+	// Try to do initial bind-mounts. Directories are bind-mounted onto
+	// themselves, so nested directories are mounted in order, while images are
+	// mounted with FUSE on directories of their own, concurrently. Encrypted
+	// directories may prompt for a password, so are mounted on their own.
+	tasks := lo.Map(overlaysToBind, func(o *Item, _ int) mount.Task {
+		target := o.SourcePath
+		if o.Encrypted {
+			target = "/"
 		}
-	}
-
-	return nil
+		return mount.Task{Target: target, Mount: o.Mount}
+	})
+	_, err := mount.MountParallel(ctx, tasks, mount.ParallelWorkers())
+	return err
 }
 
 // performFinalMount performs the final step in mounting a Set, namely mounting
@@ -114,7 +120,7 @@ func (s Set) performFinalMount(ctx context.Context, rootFsDir string) error {
 		return fmt.Errorf("while checking for unprivileged overlay support in kernel: %w", err)
 	}
 
-	useKernelMount := unprivOls && !s.hasWritableExtfsImg()
+	useKernelMount := unprivOls && !s.hasWritableFuseItem()
 
 	if useKernelMount {
 		flags := uintptr(syscall.MS_NODEV)
@@ -173,8 +179,10 @@ func (s Set) options(rootFsDir string) string {
 		lowerDirJoined, s.WritableOverlay.Upper(), s.WritableOverlay.Work())
 }
 
-func (s Set) hasWritableExtfsImg() bool {
-	if (s.WritableOverlay != nil) && (s.WritableOverlay.Type == image.EXT3) {
+// hasWritableFuseItem returns true if the writable overlay is mounted with
+// FUSE, and so can't be used as upper dir of a kernel overlay mount.
+func (s Set) hasWritableFuseItem() bool {
+	if (s.WritableOverlay != nil) && (s.WritableOverlay.Type == image.EXT3 || s.WritableOverlay.Encrypted) {
 		return true
 	}
 
@@ -0,0 +1,330 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package squashfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/priv"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/loop"
+	"golang.org/x/sys/unix"
+)
+
+// KernelMountFdEnv is the environment variable holding the file descriptor of
+// the socket over which kernel squashfs mounts are requested from the setuid
+// starter, when singularity runs in a fakeroot user namespace. A squashfs
+// filesystem can't be mounted with the kernel driver from a user namespace, so
+// the privileged starter process in the host user namespace attaches it to a
+// loop device and mounts it, and returns a detached mount, which is moved into
+// place in the user namespace.
+const KernelMountFdEnv = "_SINGULARITY_KERNEL_SQUASHFS_FD"
+
+// fsconfig commands, not defined by golang.org/x/sys/unix.
+const (
+	fsconfigSetString = 1
+	fsconfigCmdCreate = 6
+)
+
+// kernelMountRequest is sent, along with the file descriptor of an image, to
+// request the kernel mount of the squashfs filesystem at Offset in the image.
+type kernelMountRequest struct {
+	Offset uint64 `json:"offset"`
+	Size   uint64 `json:"size"`
+}
+
+// kernelMountResponse is returned for a kernel mount request, along with the
+// file descriptor of the detached mount on success.
+type kernelMountResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	// kernelMountFd is the socket to request kernel mounts over, or -1.
+	kernelMountFd = -1
+	// kernelMountMu serializes requests over kernelMountFd.
+	kernelMountMu sync.Mutex
+)
+
+// The kernel mount socket is passed to singularity by the starter. It is not
+// inherited by the processes singularity runs, such as the OCI runtime, and
+// the environment variable is not passed to containers.
+func init() {
+	v, ok := os.LookupEnv(KernelMountFdEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(KernelMountFdEnv)
+
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return
+	}
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_SETFD, unix.FD_CLOEXEC); err != nil {
+		return
+	}
+	if t, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TYPE); err != nil || t != unix.SOCK_SEQPACKET {
+		return
+	}
+	kernelMountFd = fd
+}
+
+// KernelMountAvailable returns whether kernel squashfs mounts can be requested
+// from the setuid starter with KernelMount.
+func KernelMountAvailable() bool {
+	return kernelMountFd >= 0
+}
+
+// KernelMount requests the setuid starter to mount the squashfs filesystem of
+// size bytes at offset in image with the kernel driver, and moves the mount
+// onto mountPath. The image is accessed through the file descriptor of image,
+// so that the starter can't be used to mount an image the user can't read.
+func KernelMount(image *os.File, offset, size uint64, mountPath string) error {
+	if !KernelMountAvailable() {
+		return fmt.Errorf("kernel squashfs mounts are not available")
+	}
+
+	kernelMountMu.Lock()
+	defer kernelMountMu.Unlock()
+
+	req, err := json.Marshal(kernelMountRequest{Offset: offset, Size: size})
+	if err != nil {
+		return err
+	}
+	if err := unix.Sendmsg(kernelMountFd, req, unix.UnixRights(int(image.Fd())), nil, 0); err != nil {
+		return fmt.Errorf("while sending kernel mount request: %w", err)
+	}
+
+	var resp kernelMountResponse
+	fds, err := recvMessage(kernelMountFd, &resp)
+	if err != nil {
+		return fmt.Errorf("while receiving kernel mount response: %w", err)
+	}
+	defer closeFds(fds)
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if len(fds) != 1 {
+		return fmt.Errorf("no mount received from starter")
+	}
+
+	err = unix.MoveMount(fds[0], "", unix.AT_FDCWD, mountPath, unix.MOVE_MOUNT_F_EMPTY_PATH)
+	if err != nil {
+		return fmt.Errorf("while moving squashfs mount to %s: %w", mountPath, err)
+	}
+	return nil
+}
+
+// KernelMountConfig holds the loop device settings applied by
+// ServeKernelMounts, and the function authorizing each request.
+type KernelMountConfig struct {
+	MaxLoopDevices    int
+	SharedLoopDevices bool
+	// Authorize is called, before privileges are escalated, with the image
+	// file, offset and size of each request, and returns an error if the
+	// filesystem must not be mounted. Requests are rejected if it is nil.
+	Authorize func(image *os.File, offset, size uint64) error
+}
+
+// ServeKernelMounts serves the kernel mount requests received over the
+// socket fd, until it is closed by the requesting process. Privileges are
+// escalated to attach loop devices and mount squashfs filesystems, which
+// requires a setuid installation when not run as root.
+func ServeKernelMounts(fd int, cfg KernelMountConfig) {
+	defer unix.Close(fd)
+
+	for {
+		var req kernelMountRequest
+		fds, err := recvMessage(fd, &req)
+		if errors.Is(err, errClosed) {
+			return
+		}
+
+		var resp kernelMountResponse
+		mntFd := -1
+		if err == nil {
+			mntFd, err = serveKernelMount(fds, req, cfg)
+		}
+		if err != nil {
+			sylog.Debugf("Kernel squashfs mount request failed: %v", err)
+			resp.Error = err.Error()
+		}
+
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return
+		}
+		var rights []byte
+		if mntFd >= 0 {
+			rights = unix.UnixRights(mntFd)
+		}
+		err = unix.Sendmsg(fd, data, rights, nil, 0)
+		if mntFd >= 0 {
+			unix.Close(mntFd)
+		}
+		if err != nil {
+			sylog.Debugf("While sending kernel mount response: %v", err)
+			return
+		}
+	}
+}
+
+// serveKernelMount mounts the squashfs filesystem of req in the image file
+// passed as the single file descriptor in fds, and returns the file
+// descriptor of the detached mount. The file descriptors in fds are closed.
+func serveKernelMount(fds []int, req kernelMountRequest, cfg KernelMountConfig) (int, error) {
+	if len(fds) != 1 {
+		closeFds(fds)
+		return -1, fmt.Errorf("expected an image file descriptor")
+	}
+	// The image is named by its path, which the ECL matches execgroups on.
+	name, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fds[0]))
+	if err != nil {
+		name = "image"
+	}
+	image := os.NewFile(uintptr(fds[0]), strings.TrimSuffix(name, " (deleted)"))
+	defer image.Close()
+
+	fi, err := image.Stat()
+	if err != nil {
+		return -1, err
+	}
+	if !fi.Mode().IsRegular() {
+		return -1, fmt.Errorf("image is not a regular file")
+	}
+	if req.Size == 0 || req.Offset > uint64(fi.Size()) || req.Size > uint64(fi.Size())-req.Offset {
+		return -1, fmt.Errorf("squashfs filesystem is out of the image bounds")
+	}
+	if cfg.Authorize == nil {
+		return -1, fmt.Errorf("kernel squashfs mount requests are not authorized")
+	}
+	if err := cfg.Authorize(image, req.Offset, req.Size); err != nil {
+		return -1, fmt.Errorf("kernel squashfs mount not authorized: %w", err)
+	}
+
+	if os.Geteuid() != 0 {
+		if err := priv.Escalate(); err != nil {
+			return -1, fmt.Errorf("while escalating privileges: %w", err)
+		}
+		defer priv.Drop()
+	}
+
+	loopDev := &loop.Device{
+		MaxLoopDevices: cfg.MaxLoopDevices,
+		Shared:         cfg.SharedLoopDevices,
+		Info: &unix.LoopInfo64{
+			Offset:    req.Offset,
+			Sizelimit: req.Size,
+			Flags:     unix.LO_FLAGS_AUTOCLEAR | unix.LO_FLAGS_READ_ONLY,
+		},
+	}
+	idx := 0
+	if err := loopDev.AttachFromFile(image, os.O_RDONLY, &idx); err != nil {
+		return -1, fmt.Errorf("while attaching image to loop device: %w", err)
+	}
+	// The loop device is cleared automatically once the squashfs filesystem
+	// is unmounted.
+	defer loopDev.Close()
+
+	return fsmountSquashfs(fmt.Sprintf("/dev/loop%d", idx))
+}
+
+// fsmountSquashfs creates a detached, read-only, mount of the squashfs
+// filesystem on device, and returns its file descriptor.
+func fsmountSquashfs(device string) (int, error) {
+	fsFd, err := unix.Fsopen("squashfs", unix.FSOPEN_CLOEXEC)
+	if err != nil {
+		return -1, fmt.Errorf("while opening squashfs filesystem context: %w", err)
+	}
+	defer unix.Close(fsFd)
+
+	if err := fsconfig(fsFd, fsconfigSetString, "source", device); err != nil {
+		return -1, fmt.Errorf("while setting squashfs source: %w", err)
+	}
+	if err := fsconfig(fsFd, fsconfigCmdCreate, "", ""); err != nil {
+		return -1, fmt.Errorf("while creating squashfs filesystem: %w", err)
+	}
+
+	attrs := unix.MOUNT_ATTR_RDONLY | unix.MOUNT_ATTR_NODEV | unix.MOUNT_ATTR_NOSUID
+	mntFd, err := unix.Fsmount(fsFd, unix.FSMOUNT_CLOEXEC, attrs)
+	if err != nil {
+		return -1, fmt.Errorf("while mounting squashfs filesystem: %w", err)
+	}
+	return mntFd, nil
+}
+
+// fsconfig calls the fsconfig system call with the command cmd, and an
+// optional string key and value, on the filesystem context fd.
+func fsconfig(fd int, cmd uint, key, value string) error {
+	var k, v *byte
+	var err error
+	if key != "" {
+		if k, err = unix.BytePtrFromString(key); err != nil {
+			return err
+		}
+	}
+	if value != "" {
+		if v, err = unix.BytePtrFromString(value); err != nil {
+			return err
+		}
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_FSCONFIG, uintptr(fd), uintptr(cmd), uintptr(unsafe.Pointer(k)), uintptr(unsafe.Pointer(v)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// errClosed is returned by recvMessage when the peer closed the socket.
+var errClosed = errors.New("socket closed")
+
+// recvMessage receives a JSON message in v, and the file descriptors passed
+// along with it, over the socket fd.
+func recvMessage(fd int, v interface{}) ([]int, error) {
+	buf := make([]byte, 4096)
+	oob := make([]byte, unix.CmsgSpace(4))
+
+	n, oobn, _, _, err := unix.Recvmsg(fd, buf, oob, unix.MSG_CMSG_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errClosed
+	}
+
+	var fds []int
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		rights, err := unix.ParseUnixRights(&m)
+		if err != nil {
+			closeFds(fds)
+			return nil, err
+		}
+		fds = append(fds, rights...)
+	}
+
+	if err := json.Unmarshal(buf[:n], v); err != nil {
+		closeFds(fds)
+		return nil, fmt.Errorf("while decoding message: %w", err)
+	}
+	return fds, nil
+}
+
+func closeFds(fds []int) {
+	for _, fd := range fds {
+		unix.Close(fd)
+	}
+}
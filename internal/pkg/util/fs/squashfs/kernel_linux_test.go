@@ -0,0 +1,119 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package squashfs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+	"github.com/sylabs/singularity/v4/internal/pkg/test/tool/require"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+// startKernelMountServer serves kernel mount requests, authorized by
+// authorize, over a socket pair, and sets it as the socket KernelMount uses.
+func startKernelMountServer(t *testing.T, authorize func(*os.File, uint64, uint64) error) {
+	t.Helper()
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	assert.NilError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		ServeKernelMounts(fds[0], KernelMountConfig{MaxLoopDevices: 256, Authorize: authorize})
+		close(done)
+	}()
+
+	kernelMountFd = fds[1]
+	t.Cleanup(func() {
+		kernelMountFd = -1
+		unix.Close(fds[1])
+		<-done
+	})
+}
+
+func allowAll(*os.File, uint64, uint64) error { return nil }
+
+func TestKernelMountRejected(t *testing.T) {
+	startKernelMountServer(t, allowAll)
+	mnt := t.TempDir()
+
+	// a directory is not an image file
+	dir, err := os.Open(t.TempDir())
+	assert.NilError(t, err)
+	defer dir.Close()
+	assert.ErrorContains(t, KernelMount(dir, 0, 4096, mnt), "not a regular file")
+
+	// the filesystem must be within the image
+	img := filepath.Join(t.TempDir(), "image")
+	assert.NilError(t, os.WriteFile(img, make([]byte, 4096), 0o644))
+	f, err := os.Open(img)
+	assert.NilError(t, err)
+	defer f.Close()
+	assert.ErrorContains(t, KernelMount(f, 4096, 1, mnt), "out of the image bounds")
+	assert.ErrorContains(t, KernelMount(f, 0, 0, mnt), "out of the image bounds")
+}
+
+func TestKernelMountUnauthorized(t *testing.T) {
+	img := filepath.Join(t.TempDir(), "image")
+	assert.NilError(t, os.WriteFile(img, make([]byte, 8192), 0o644))
+	f, err := os.Open(img)
+	assert.NilError(t, err)
+	defer f.Close()
+	mnt := t.TempDir()
+
+	// requests are rejected without an authorization function
+	startKernelMountServer(t, nil)
+	assert.ErrorContains(t, KernelMount(f, 4096, 4096, mnt), "not authorized")
+
+	// the image file, offset and size are passed for authorization
+	var gotOffset, gotSize uint64
+	gotPath := ""
+	startKernelMountServer(t, func(image *os.File, offset, size uint64) error {
+		gotPath, gotOffset, gotSize = image.Name(), offset, size
+		return fmt.Errorf("not a layer")
+	})
+	assert.ErrorContains(t, KernelMount(f, 4096, 4096, mnt), "not a layer")
+	assert.Equal(t, gotPath, img)
+	assert.Equal(t, gotOffset, uint64(4096))
+	assert.Equal(t, gotSize, uint64(4096))
+}
+
+func TestKernelMount(t *testing.T) {
+	test.EnsurePrivilege(t)
+	require.Command(t, "mksquashfs")
+
+	src := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(src, "file"), []byte("content"), 0o644))
+
+	// the squashfs filesystem is preceded by data in the image
+	sqfs := filepath.Join(t.TempDir(), "image.sqfs")
+	out, err := exec.Command("mksquashfs", src, sqfs, "-noappend").CombinedOutput()
+	assert.NilError(t, err, string(out))
+	data, err := os.ReadFile(sqfs)
+	assert.NilError(t, err)
+	img := filepath.Join(t.TempDir(), "image")
+	assert.NilError(t, os.WriteFile(img, append(make([]byte, 4096), data...), 0o644))
+
+	startKernelMountServer(t, allowAll)
+	f, err := os.Open(img)
+	assert.NilError(t, err)
+	defer f.Close()
+
+	mnt := t.TempDir()
+	assert.NilError(t, KernelMount(f, 4096, uint64(len(data)), mnt))
+	defer unix.Unmount(mnt, unix.MNT_DETACH)
+
+	content, err := os.ReadFile(filepath.Join(mnt, "file"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "content")
+	assert.ErrorIs(t, os.WriteFile(filepath.Join(mnt, "new"), nil, 0o644), unix.EROFS)
+}
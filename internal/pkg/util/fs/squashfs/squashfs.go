@@ -9,12 +9,24 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
 	"github.com/sylabs/singularity/v4/pkg/image"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
 )
 
+// FUSEMount mounts the squashfs filesystem at offset in the file at path on
+// mountPath with squashfuse. The squashfuse threads and cache size set in
+// singularity.conf are applied if the squashfuse in use supports them. If the
+// mount fails with them, it is attempted again with the squashfuse defaults.
 func FUSEMount(ctx context.Context, offset uint64, path, mountPath string) (*fuse.ImageMount, error) {
 	im := fuse.ImageMount{
 		Type:       image.SQUASHFS,
@@ -28,9 +40,77 @@ func FUSEMount(ctx context.Context, offset uint64, path, mountPath string) (*fus
 	}
 	im.SetMountPoint(filepath.Clean(mountPath))
 
+	if tuning := tuningOpts(singularityconf.GetCurrentConfig()); len(tuning) > 0 {
+		tuned := im
+		tuned.ExtraOpts = append(append([]string{}, im.ExtraOpts...), tuning...)
+		err := tuned.Mount(ctx)
+		if err == nil {
+			return &tuned, nil
+		}
+		sylog.Warningf("squashfuse mount with %s failed, retrying with defaults: %v", strings.Join(tuning, ","), err)
+	}
+
 	return &im, im.Mount(ctx)
 }
 
 func FUSEUnmount(ctx context.Context, mountPath string) error {
 	return fuse.UnmountWithFuse(ctx, mountPath)
 }
+
+// tuningOpts returns the squashfuse options for the threads and cache size set
+// in cfg, which are supported by the squashfuse in use.
+func tuningOpts(cfg *singularityconf.File) []string {
+	if cfg == nil || (cfg.SquashfuseThreads == 0 && cfg.SquashfuseCacheSize == 0) {
+		return nil
+	}
+	cmd, err := bin.FindBin("squashfuse")
+	if err != nil {
+		return nil
+	}
+	return selectOpts(squashfuseHelp(cmd), cfg.SquashfuseThreads, cfg.SquashfuseCacheSize)
+}
+
+// selectOpts returns the options setting threads and cacheSize, which are
+// listed in help, the usage of squashfuse. Unsupported settings are skipped.
+func selectOpts(help string, threads, cacheSize uint) []string {
+	supported := func(opt string) bool {
+		return regexp.MustCompile(`\b` + opt + `=`).MatchString(help)
+	}
+
+	var opts []string
+	if threads > 0 {
+		switch {
+		case supported("threads"):
+			opts = append(opts, fmt.Sprintf("threads=%d", threads))
+		case supported("max_threads"):
+			opts = append(opts, fmt.Sprintf("max_threads=%d", threads))
+		default:
+			sylog.Debugf("squashfuse does not support multiple threads, ignoring 'squashfuse threads'")
+		}
+	}
+	if cacheSize > 0 {
+		if supported("cache_size") {
+			opts = append(opts, fmt.Sprintf("cache_size=%d", cacheSize))
+		} else {
+			sylog.Debugf("squashfuse does not support setting its cache size, ignoring 'squashfuse cache size'")
+		}
+	}
+	return opts
+}
+
+var helpCache sync.Map
+
+// squashfuseHelp returns the usage printed by the squashfuse executable cmd,
+// which lists the options it supports.
+func squashfuseHelp(cmd string) string {
+	if help, ok := helpCache.Load(cmd); ok {
+		return help.(string)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// squashfuse exits with a non-zero status after printing its usage.
+	out, _ := exec.CommandContext(ctx, cmd, "-h").CombinedOutput()
+	helpCache.Store(cmd, string(out))
+	return string(out)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package squashfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const (
+	llHelp = `usage: squashfuse_ll [options] ARCHIVE MOUNTPOINT
+    -o offset=N            offset of squashfs data within archive
+    -o timeout=N           idle timeout in seconds
+    -o threads=N           number of threads
+    -o cache_size=N        size of the block cache in MiB
+`
+	fuse3Help = `usage: squashfuse [options] ARCHIVE MOUNTPOINT
+    -o offset=N            offset of squashfs data within archive
+    -o max_threads         the maximum number of threads allowed (default: 10)
+    -o max_threads=N
+`
+	plainHelp = `usage: squashfuse [options] ARCHIVE MOUNTPOINT
+    -o offset=N            offset of squashfs data within archive
+`
+)
+
+func TestSelectOpts(t *testing.T) {
+	tests := []struct {
+		name      string
+		help      string
+		threads   uint
+		cacheSize uint
+		want      []string
+	}{
+		{
+			name:      "Squashfuse_ll",
+			help:      llHelp,
+			threads:   8,
+			cacheSize: 64,
+			want:      []string{"threads=8", "cache_size=64"},
+		},
+		{
+			name:      "Fuse3",
+			help:      fuse3Help,
+			threads:   8,
+			cacheSize: 64,
+			want:      []string{"max_threads=8"},
+		},
+		{
+			name:      "Unsupported",
+			help:      plainHelp,
+			threads:   8,
+			cacheSize: 64,
+			want:      nil,
+		},
+		{
+			name:      "Defaults",
+			help:      llHelp,
+			threads:   0,
+			cacheSize: 0,
+			want:      nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, selectOpts(tt.help, tt.threads, tt.cacheSize), tt.want)
+		})
+	}
+}
+
+func TestSquashfuseHelp(t *testing.T) {
+	cmd := filepath.Join(t.TempDir(), "squashfuse_ll")
+	script := "#!/bin/sh\necho '    -o threads=N' >&2\nexit 1\n"
+	assert.NilError(t, os.WriteFile(cmd, []byte(script), 0o755))
+
+	assert.DeepEqual(t, selectOpts(squashfuseHelp(cmd), 4, 0), []string{"threads=4"})
+	// the usage is only read once
+	assert.NilError(t, os.Remove(cmd))
+	assert.DeepEqual(t, selectOpts(squashfuseHelp(cmd), 4, 0), []string{"threads=4"})
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kfdTopologyDir holds a directory describing each node, CPU or GPU, known to
+// the AMD kernel fusion driver.
+const kfdTopologyDir = "/sys/class/kfd/kfd/topology/nodes"
+
+// NvidiaArchs returns the CUDA architectures of the NVIDIA GPUs on the host,
+// of the form sm_<major><minor>, as reported by nvidia-smi. No architectures
+// are returned if nvidia-smi is not found.
+func NvidiaArchs() ([]string, error) {
+	nvidiaSMI, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		return nil, nil
+	}
+	out, err := exec.Command(nvidiaSMI, "--query-gpu=compute_cap", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("while querying GPU compute capability: %w", err)
+	}
+	return nvidiaArchs(string(out))
+}
+
+// nvidiaArchs returns the distinct CUDA architectures for the compute
+// capabilities, one per line, in out.
+func nvidiaArchs(out string) ([]string, error) {
+	archs := []string{}
+	for _, cc := range strings.Fields(out) {
+		major, minor, ok := strings.Cut(cc, ".")
+		if !ok {
+			return nil, fmt.Errorf("invalid compute capability %q", cc)
+		}
+		archs = append(archs, "sm_"+major+minor)
+	}
+	return uniqueSorted(archs), nil
+}
+
+// RocmArchs returns the architectures of the AMD GPUs on the host, of the form
+// gfx<target>, as described by the kernel fusion driver.
+func RocmArchs() ([]string, error) {
+	return rocmArchs(kfdTopologyDir)
+}
+
+// rocmArchs returns the GPU architectures of the nodes under topologyDir.
+func rocmArchs(topologyDir string) ([]string, error) {
+	props, err := filepath.Glob(filepath.Join(topologyDir, "*", "properties"))
+	if err != nil {
+		return nil, err
+	}
+
+	archs := []string{}
+	for _, p := range props {
+		version, err := gfxTargetVersion(p)
+		if err != nil {
+			return nil, fmt.Errorf("while reading %s: %w", p, err)
+		}
+		// CPU nodes have no GFX target.
+		if version == 0 {
+			continue
+		}
+		major := version / 10000
+		minor := (version / 100) % 100
+		step := version % 100
+		archs = append(archs, fmt.Sprintf("gfx%d%x%x", major, minor, step))
+	}
+	return uniqueSorted(archs), nil
+}
+
+// gfxTargetVersion returns the gfx_target_version from a KFD node properties
+// file, which is 0 for a CPU node.
+func gfxTargetVersion(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), " ")
+		if !ok || k != "gfx_target_version" {
+			continue
+		}
+		return strconv.Atoi(strings.TrimSpace(v))
+	}
+	return 0, s.Err()
+}
+
+func uniqueSorted(s []string) []string {
+	sort.Strings(s)
+	u := []string{}
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			u = append(u, v)
+		}
+	}
+	return u
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNvidiaArchs(t *testing.T) {
+	got, err := nvidiaArchs("8.0\n9.0\n8.0\n")
+	if err != nil {
+		t.Fatalf("nvidiaArchs() error = %v", err)
+	}
+	if want := []string{"sm_80", "sm_90"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("nvidiaArchs() = %v, want %v", got, want)
+	}
+
+	if _, err := nvidiaArchs("80\n"); err == nil {
+		t.Errorf("nvidiaArchs() expected error for invalid compute capability")
+	}
+}
+
+func TestRocmArchs(t *testing.T) {
+	dir := t.TempDir()
+	nodes := map[string]string{
+		"0": "cpu_cores_count 64\ngfx_target_version 0\n",
+		"1": "simd_count 440\ngfx_target_version 90010\n",
+		"2": "simd_count 440\ngfx_target_version 90010\n",
+		"3": "simd_count 304\ngfx_target_version 110000\n",
+	}
+	for n, props := range nodes {
+		if err := os.Mkdir(filepath.Join(dir, n), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, n, "properties"), []byte(props), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := rocmArchs(dir)
+	if err != nil {
+		t.Fatalf("rocmArchs() error = %v", err)
+	}
+	if want := []string{"gfx1100", "gfx90a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rocmArchs() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// intelVendorID is the PCI vendor ID of Intel devices, as found in sysfs.
+const intelVendorID = "0x8086"
+
+// IntelPaths returns a list of Intel GPU (Level Zero / OpenCL) libraries and
+// binaries that should be mounted into the container in order to use Intel
+// GPUs.
+func IntelPaths(configFilePath string) ([]string, []string, error) {
+	intelFiles, err := gpuliblist(configFilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %v", filepath.Base(configFilePath), err)
+	}
+
+	return paths(intelFiles)
+}
+
+// IntelDevices returns a list of /dev/dri render nodes that belong to Intel
+// GPUs.
+func IntelDevices() ([]string, error) {
+	return intelDevices("/sys/class/drm", "/dev/dri")
+}
+
+// intelDevices returns the render nodes in devDir whose device, described
+// under sysDir, has the Intel vendor ID.
+func intelDevices(sysDir, devDir string) ([]string, error) {
+	nodes, err := filepath.Glob(filepath.Join(sysDir, "renderD*"))
+	if err != nil {
+		return nil, err
+	}
+
+	devs := []string{}
+	for _, n := range nodes {
+		vendor, err := os.ReadFile(filepath.Join(n, "device", "vendor"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(vendor)) != intelVendorID {
+			continue
+		}
+		dev := filepath.Join(devDir, filepath.Base(n))
+		if _, err := os.Stat(dev); err == nil {
+			devs = append(devs, dev)
+		}
+	}
+	return devs, nil
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build linux
+
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIntelDevices(t *testing.T) {
+	sysDir := t.TempDir()
+	devDir := t.TempDir()
+
+	nodes := map[string]string{
+		"renderD128": "0x8086\n",
+		"renderD129": "0x1002\n",
+		"renderD130": "0x8086\n",
+	}
+	for n, vendor := range nodes {
+		if err := os.MkdirAll(filepath.Join(sysDir, n, "device"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(sysDir, n, "device", "vendor"), []byte(vendor), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// renderD130 has no device node, so must not be returned.
+	for _, n := range []string{"renderD128", "renderD129"} {
+		if err := os.WriteFile(filepath.Join(devDir, n), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	devs, err := intelDevices(sysDir, devDir)
+	if err != nil {
+		t.Fatalf("intelDevices() error = %v", err)
+	}
+	want := []string{filepath.Join(devDir, "renderD128")}
+	if !reflect.DeepEqual(devs, want) {
+		t.Errorf("intelDevices() = %v, want %v", devs, want)
+	}
+}
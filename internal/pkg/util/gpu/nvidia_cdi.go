@@ -0,0 +1,123 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// NvidiaCDIKind is the CDI vendor and class of NVIDIA GPU devices, in specs
+// generated by 'nvidia-ctk cdi generate'.
+const NvidiaCDIKind = "nvidia.com/gpu"
+
+// CUDACompatDir is the location of the CUDA forward compatibility libraries
+// in CUDA container images.
+const CUDACompatDir = "/usr/local/cuda/compat"
+
+// nvidiaVersionFile holds the version of the loaded NVIDIA kernel driver.
+const nvidiaVersionFile = "/sys/module/nvidia/version"
+
+// ParseGPUs parses a GPU selection, as passed to --gpus, into NVIDIA device
+// identifiers. The selection is "all", or a comma separated list of GPU
+// indexes, GPU or MIG device UUIDs, and MIG devices as mig:<gpu>:<mig>. An
+// empty selection selects all GPUs.
+func ParseGPUs(gpus string) ([]string, error) {
+	if gpus == "" || gpus == "all" {
+		return []string{"all"}, nil
+	}
+
+	ids := []string{}
+	for _, g := range strings.Split(gpus, ",") {
+		g = strings.TrimSpace(g)
+		switch {
+		case strings.HasPrefix(g, "mig:"):
+			gpu, mig, ok := strings.Cut(strings.TrimPrefix(g, "mig:"), ":")
+			if !ok || !isIndex(gpu) || !isIndex(mig) {
+				return nil, fmt.Errorf("invalid MIG device %q: must be mig:<gpu index>:<mig index>", g)
+			}
+			ids = append(ids, gpu+":"+mig)
+		case isIndex(g), strings.HasPrefix(g, "GPU-"), strings.HasPrefix(g, "MIG-"):
+			ids = append(ids, g)
+		default:
+			return nil, fmt.Errorf("invalid GPU %q: must be 'all', an index, a UUID, or mig:<gpu index>:<mig index>", g)
+		}
+	}
+	return ids, nil
+}
+
+func isIndex(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
+// NvidiaVisibleDevices returns the NVIDIA_VISIBLE_DEVICES value selecting the
+// GPUs in gpus, which is parsed by ParseGPUs.
+func NvidiaVisibleDevices(gpus string) (string, error) {
+	ids, err := ParseGPUs(gpus)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(ids, ","), nil
+}
+
+// NvidiaCDIDevices returns the fully qualified names of the CDI devices
+// selecting the GPUs in gpus, which is parsed by ParseGPUs.
+func NvidiaCDIDevices(gpus string) ([]string, error) {
+	ids, err := ParseGPUs(gpus)
+	if err != nil {
+		return nil, err
+	}
+	devs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		devs = append(devs, NvidiaCDIKind+"="+id)
+	}
+	return devs, nil
+}
+
+// NvidiaDriverVersion returns the major version of the loaded NVIDIA kernel
+// driver.
+func NvidiaDriverVersion() (int, error) {
+	b, err := os.ReadFile(nvidiaVersionFile)
+	if err != nil {
+		return 0, err
+	}
+	return majorVersion(strings.TrimSpace(string(b)))
+}
+
+// CUDACompatNeeded returns true if the rootfs holds CUDA forward compatibility
+// libraries for a driver newer than hostDriver, the major version of the host
+// NVIDIA driver. These must then be used in place of the host libcuda.
+func CUDACompatNeeded(rootfs string, hostDriver int) (bool, error) {
+	libs, err := filepath.Glob(filepath.Join(rootfs, CUDACompatDir, "libcuda.so.*.*"))
+	if err != nil {
+		return false, err
+	}
+	for _, l := range libs {
+		v, err := majorVersion(strings.TrimPrefix(filepath.Base(l), "libcuda.so."))
+		if err != nil {
+			continue
+		}
+		if v > hostDriver {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// majorVersion returns the major version from a <major>.<minor>[.<patch>]
+// driver version.
+func majorVersion(v string) (int, error) {
+	major, _, _ := strings.Cut(v, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("invalid driver version %q", v)
+	}
+	return n, nil
+}
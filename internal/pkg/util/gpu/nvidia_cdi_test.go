@@ -0,0 +1,115 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNvidiaCDIDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		gpus    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			gpus: "",
+			want: []string{"nvidia.com/gpu=all"},
+		},
+		{
+			name: "all",
+			gpus: "all",
+			want: []string{"nvidia.com/gpu=all"},
+		},
+		{
+			name: "indexes",
+			gpus: "0, 2",
+			want: []string{"nvidia.com/gpu=0", "nvidia.com/gpu=2"},
+		},
+		{
+			name: "mig",
+			gpus: "mig:0:1,1",
+			want: []string{"nvidia.com/gpu=0:1", "nvidia.com/gpu=1"},
+		},
+		{
+			name: "uuid",
+			gpus: "GPU-5f1e5a7c-1f2e-4c1d-9a3b-6f5b3c2a1d0e",
+			want: []string{"nvidia.com/gpu=GPU-5f1e5a7c-1f2e-4c1d-9a3b-6f5b3c2a1d0e"},
+		},
+		{
+			name:    "badMIG",
+			gpus:    "mig:0",
+			wantErr: true,
+		},
+		{
+			name:    "badGPU",
+			gpus:    "first",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NvidiaCDIDevices(tt.gpus)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NvidiaCDIDevices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("NvidiaCDIDevices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNvidiaVisibleDevices(t *testing.T) {
+	got, err := NvidiaVisibleDevices("mig:0:1,3")
+	if err != nil {
+		t.Fatalf("NvidiaVisibleDevices() error = %v", err)
+	}
+	if want := "0:1,3"; got != want {
+		t.Errorf("NvidiaVisibleDevices() = %q, want %q", got, want)
+	}
+}
+
+func TestCUDACompatNeeded(t *testing.T) {
+	rootfs := t.TempDir()
+	compat := filepath.Join(rootfs, CUDACompatDir)
+	if err := os.MkdirAll(compat, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(compat, "libcuda.so.545.23.06"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		rootfs     string
+		hostDriver int
+		want       bool
+	}{
+		{name: "OlderHost", rootfs: rootfs, hostDriver: 535, want: true},
+		{name: "SameHost", rootfs: rootfs, hostDriver: 545, want: false},
+		{name: "NewerHost", rootfs: rootfs, hostDriver: 550, want: false},
+		{name: "NoCompat", rootfs: t.TempDir(), hostDriver: 535, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CUDACompatNeeded(tt.rootfs, tt.hostDriver)
+			if err != nil {
+				t.Fatalf("CUDACompatNeeded() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CUDACompatNeeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nvidiaProcDir holds a directory, named by PCI bus location, describing each
+// NVIDIA GPU on the host.
+const nvidiaProcDir = "/proc/driver/nvidia/gpus"
+
+// NvidiaGPU describes an NVIDIA GPU on the host.
+type NvidiaGPU struct {
+	// UUID is the GPU UUID, of the form GPU-<uuid>.
+	UUID string
+	// Minor is the minor number of the GPU device node, /dev/nvidia<minor>.
+	Minor int
+	// BusID is the PCI bus location of the GPU.
+	BusID string
+}
+
+// Device returns the path of the device node of the GPU.
+func (g NvidiaGPU) Device() string {
+	return "/dev/nvidia" + strconv.Itoa(g.Minor)
+}
+
+// NvidiaGPUs returns the NVIDIA GPUs on the host, in index order. As with
+// nvidia-smi, GPUs are indexed in order of PCI bus location.
+func NvidiaGPUs() ([]NvidiaGPU, error) {
+	return nvidiaGPUs(nvidiaProcDir)
+}
+
+// nvidiaGPUs returns the NVIDIA GPUs described under procDir, in index order.
+func nvidiaGPUs(procDir string) ([]NvidiaGPU, error) {
+	infos, err := filepath.Glob(filepath.Join(procDir, "*", "information"))
+	if err != nil {
+		return nil, err
+	}
+	// Bus locations are fixed width, so sort in PCI bus order.
+	sort.Strings(infos)
+
+	gpus := make([]NvidiaGPU, 0, len(infos))
+	for _, info := range infos {
+		g, err := readNvidiaGPU(info)
+		if err != nil {
+			return nil, fmt.Errorf("while reading %s: %w", info, err)
+		}
+		gpus = append(gpus, g)
+	}
+	return gpus, nil
+}
+
+// readNvidiaGPU reads the GPU described by the information file at path.
+func readNvidiaGPU(path string) (NvidiaGPU, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return NvidiaGPU{}, err
+	}
+	defer f.Close()
+
+	g := NvidiaGPU{BusID: filepath.Base(filepath.Dir(path)), Minor: -1}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch strings.TrimSpace(k) {
+		case "GPU UUID":
+			g.UUID = v
+		case "Device Minor":
+			if g.Minor, err = strconv.Atoi(v); err != nil {
+				return NvidiaGPU{}, fmt.Errorf("invalid device minor %q", v)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return NvidiaGPU{}, err
+	}
+	if g.Minor < 0 {
+		return NvidiaGPU{}, fmt.Errorf("no device minor")
+	}
+	return g, nil
+}
+
+// NvidiaSelectGPUs returns the GPUs in gpus, a selection parsed by ParseGPUs,
+// from the NVIDIA GPUs on the host. MIG devices can only be selected via
+// nvidia-container-cli, or the NVIDIA CDI spec, and are refused.
+func NvidiaSelectGPUs(gpus string) ([]NvidiaGPU, error) {
+	host, err := NvidiaGPUs()
+	if err != nil {
+		return nil, err
+	}
+	return selectNvidiaGPUs(gpus, host)
+}
+
+// selectNvidiaGPUs returns the GPUs in gpus from host, in index order.
+func selectNvidiaGPUs(gpus string, host []NvidiaGPU) ([]NvidiaGPU, error) {
+	ids, err := ParseGPUs(gpus)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 1 && ids[0] == "all" {
+		return host, nil
+	}
+
+	selected := make([]bool, len(host))
+	for _, id := range ids {
+		if strings.HasPrefix(id, "MIG-") || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("MIG device %q can only be selected with --nvccli", id)
+		}
+		i := -1
+		if isIndex(id) {
+			i, _ = strconv.Atoi(id)
+			if i >= len(host) {
+				return nil, fmt.Errorf("no GPU with index %d, %d GPUs found", i, len(host))
+			}
+		} else {
+			for j, g := range host {
+				if g.UUID == id {
+					i = j
+				}
+			}
+			if i < 0 {
+				return nil, fmt.Errorf("no GPU with UUID %s", id)
+			}
+		}
+		selected[i] = true
+	}
+
+	sel := []NvidiaGPU{}
+	for i, g := range host {
+		if selected[i] {
+			sel = append(sel, g)
+		}
+	}
+	return sel, nil
+}
+
+// CUDAVisibleDevices returns the CUDA_VISIBLE_DEVICES value selecting gpus.
+// GPUs are identified by UUID, as the CUDA device order need not match the
+// order of GPU indexes.
+func CUDAVisibleDevices(gpus []NvidiaGPU) string {
+	uuids := make([]string, 0, len(gpus))
+	for _, g := range gpus {
+		uuids = append(uuids, g.UUID)
+	}
+	return strings.Join(uuids, ",")
+}
+
+// NvidiaSelectedDevices returns the non-GPU NVIDIA devices present on the host,
+// and the device nodes of gpus.
+func NvidiaSelectedDevices(gpus []NvidiaGPU) ([]string, error) {
+	devs, err := NvidiaDevices(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range gpus {
+		devs = append(devs, g.Device())
+	}
+	return devs, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNvidiaGPUs(t *testing.T) {
+	procDir := t.TempDir()
+
+	infos := map[string]string{
+		"0000:3b:00.0": "Model: \t\t Tesla V100\nGPU UUID: \t GPU-bbbb\nBus Location: \t 0000:3b:00.0\nDevice Minor: \t 1\n",
+		"0000:1a:00.0": "Model: \t\t Tesla V100\nGPU UUID: \t GPU-aaaa\nBus Location: \t 0000:1a:00.0\nDevice Minor: \t 0\n",
+	}
+	for bus, info := range infos {
+		if err := os.MkdirAll(filepath.Join(procDir, bus), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(procDir, bus, "information"), []byte(info), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gpus, err := nvidiaGPUs(procDir)
+	if err != nil {
+		t.Fatalf("nvidiaGPUs() error = %v", err)
+	}
+	want := []NvidiaGPU{
+		{UUID: "GPU-aaaa", Minor: 0, BusID: "0000:1a:00.0"},
+		{UUID: "GPU-bbbb", Minor: 1, BusID: "0000:3b:00.0"},
+	}
+	if !reflect.DeepEqual(gpus, want) {
+		t.Errorf("nvidiaGPUs() = %v, want %v", gpus, want)
+	}
+}
+
+func TestSelectNvidiaGPUs(t *testing.T) {
+	host := []NvidiaGPU{
+		{UUID: "GPU-aaaa", Minor: 0},
+		{UUID: "GPU-bbbb", Minor: 2},
+		{UUID: "GPU-cccc", Minor: 1},
+	}
+
+	tests := []struct {
+		name     string
+		gpus     string
+		want     []NvidiaGPU
+		wantCUDA string
+		wantErr  bool
+	}{
+		{
+			name:     "All",
+			gpus:     "all",
+			want:     host,
+			wantCUDA: "GPU-aaaa,GPU-bbbb,GPU-cccc",
+		},
+		{
+			name:     "Indexes",
+			gpus:     "2,0",
+			want:     []NvidiaGPU{host[0], host[2]},
+			wantCUDA: "GPU-aaaa,GPU-cccc",
+		},
+		{
+			name:     "UUID",
+			gpus:     "GPU-bbbb",
+			want:     []NvidiaGPU{host[1]},
+			wantCUDA: "GPU-bbbb",
+		},
+		{
+			name:    "BadIndex",
+			gpus:    "3",
+			wantErr: true,
+		},
+		{
+			name:    "BadUUID",
+			gpus:    "GPU-dddd",
+			wantErr: true,
+		},
+		{
+			name:    "MIG",
+			gpus:    "mig:0:1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectNvidiaGPUs(tt.gpus, host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectNvidiaGPUs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectNvidiaGPUs() = %v, want %v", got, tt.want)
+			}
+			if cuda := CUDAVisibleDevices(got); cuda != tt.wantCUDA {
+				t.Errorf("CUDAVisibleDevices() = %q, want %q", cuda, tt.wantCUDA)
+			}
+		})
+	}
+}
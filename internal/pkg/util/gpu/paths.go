@@ -39,6 +39,18 @@ func gpuliblist(configFilePath string) ([]string, error) {
 	return libs, nil
 }
 
+// LibListPaths returns the libraries and binaries listed in the lib list
+// config file at configFilePath, resolved on the host, for lib lists that are
+// not specific to a GPU platform, such as those of --mpi.
+func LibListPaths(configFilePath string) ([]string, []string, error) {
+	files, err := gpuliblist(configFilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %v", filepath.Base(configFilePath), err)
+	}
+
+	return paths(files)
+}
+
 // soLinks returns a list of versioned symlinks resolving to a specified library file
 func soLinks(libPath string) (paths []string, err error) {
 	bareLibPath := strings.SplitAfter(libPath, ".so")[0]
@@ -0,0 +1,56 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RdmaEnv holds environment variables set in the container with --rdma,
+// unless already set. Fork safety is required by MPI implementations that
+// fork while RDMA memory is registered.
+var RdmaEnv = map[string]string{
+	"RDMAV_FORK_SAFE": "1",
+}
+
+// RdmaPaths returns a list of InfiniBand / RDMA libraries and binaries that
+// should be mounted into the container in order to use RDMA devices. These
+// are bound in the same manner as GPU libraries.
+func RdmaPaths(configFilePath string) ([]string, []string, error) {
+	rdmaFiles, err := gpuliblist(configFilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %v", filepath.Base(configFilePath), err)
+	}
+
+	return paths(rdmaFiles)
+}
+
+// RdmaDevices returns a list of the InfiniBand / RDMA devices present on the
+// host, under /dev/infiniband.
+func RdmaDevices() ([]string, error) {
+	return rdmaDevices("/dev/infiniband")
+}
+
+// rdmaDevices returns the character devices in devDir.
+func rdmaDevices(devDir string) ([]string, error) {
+	entries, err := os.ReadDir(devDir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list RDMA devices: %v", err)
+	}
+
+	devs := []string{}
+	for _, e := range entries {
+		if e.Type()&os.ModeCharDevice != 0 {
+			devs = append(devs, filepath.Join(devDir, e.Name()))
+		}
+	}
+	return devs, nil
+}
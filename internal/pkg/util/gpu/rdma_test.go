@@ -0,0 +1,46 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build linux
+
+package gpu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRdmaDevices(t *testing.T) {
+	// A missing device directory holds no devices.
+	devs, err := rdmaDevices(filepath.Join(t.TempDir(), "infiniband"))
+	if err != nil || len(devs) != 0 {
+		t.Errorf("rdmaDevices() = %v, %v, want [], nil", devs, err)
+	}
+
+	// Only character devices are returned.
+	devDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(devDir, "uverbs0"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	devs, err = rdmaDevices(devDir)
+	if err != nil || len(devs) != 0 {
+		t.Errorf("rdmaDevices() = %v, %v, want [], nil", devs, err)
+	}
+
+	devs, err = rdmaDevices("/dev")
+	if err != nil {
+		t.Fatalf("rdmaDevices() error = %v", err)
+	}
+	found := false
+	for _, d := range devs {
+		if d == "/dev/null" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("rdmaDevices() = %v, want /dev/null included", devs)
+	}
+}
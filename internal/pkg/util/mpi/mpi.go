@@ -0,0 +1,149 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package mpi finds the host PMI libraries bound into containers with --mpi,
+// and checks their ABI compatibility with the libraries of an image.
+package mpi
+
+import (
+	"debug/elf"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/gpu"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// libDirs are the directories searched for PMI libraries in an image.
+var libDirs = []string{
+	"/lib",
+	"/lib64",
+	"/lib/*-linux-gnu",
+	"/usr/lib",
+	"/usr/lib64",
+	"/usr/lib/*-linux-gnu",
+	"/usr/local/lib",
+	"/usr/local/lib64",
+}
+
+// ReadDirFunc reads the directory dir, as os.ReadDir does. It allows images
+// mounted in another mount namespace to be read.
+type ReadDirFunc func(dir string) ([]fs.DirEntry, error)
+
+// Paths returns a list of PMI / PMIx libraries and binaries that should be
+// mounted into the container for MPI applications to reach the process
+// manager of the host. These are bound in the same manner as GPU libraries.
+func Paths(configFilePath string) ([]string, []string, error) {
+	return gpu.LibListPaths(configFilePath)
+}
+
+// Sonames returns the SONAMEs of the host libraries libs, without duplicates.
+// The file name of a library is used as a fallback, if its SONAME can't be
+// read.
+func Sonames(libs []string) []string {
+	var sonames []string
+	seen := map[string]bool{}
+	for _, lib := range libs {
+		soname, err := libSoname(lib)
+		if err != nil || soname == "" {
+			sylog.Debugf("Using file name of %s, as its SONAME could not be read: %v", lib, err)
+			soname = filepath.Base(lib)
+		}
+		if !seen[soname] {
+			seen[soname] = true
+			sonames = append(sonames, soname)
+		}
+	}
+	return sonames
+}
+
+// CheckABI returns an error if the image at rootfs, read with readDir,
+// provides a version of one of the host libraries sonames with a different
+// ABI. The ABI is taken to change with the major version of the SONAME,
+// <name>.so.<major>, as with libtool versioning. A library with no major
+// version, in its SONAME or file name, can't be checked, so its compatibility
+// is left to the user. Libraries that the image does not provide are not
+// checked, as the host version will be used.
+func CheckABI(readDir ReadDirFunc, rootfs string, sonames []string) error {
+	for _, soname := range sonames {
+		if err := checkSonameABI(readDir, rootfs, soname); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSonameABI returns an error if the image at rootfs holds a library of
+// the same name as soname, <name>.so.<major>, with none matching its major
+// version.
+func checkSonameABI(readDir ReadDirFunc, rootfs, soname string) error {
+	name, major, ok := strings.Cut(soname, ".so.")
+	if !ok {
+		sylog.Verbosef("Not checking ABI compatibility of host %s, which has no major version", soname)
+		return nil
+	}
+	major, _, _ = strings.Cut(major, ".")
+
+	var imageLibs []string
+	for _, dir := range imageLibDirs(readDir, rootfs) {
+		entries, err := readDir(filepath.Join(rootfs, dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			v, ok := strings.CutPrefix(e.Name(), name+".so.")
+			if !ok {
+				continue
+			}
+			if m, _, _ := strings.Cut(v, "."); m == major {
+				return nil
+			}
+			imageLibs = append(imageLibs, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(imageLibs) > 0 {
+		return fmt.Errorf("host %s is not ABI compatible with %s in the container image", soname, strings.Join(imageLibs, ", "))
+	}
+	return nil
+}
+
+// imageLibDirs returns the directories of libDirs, with patterns expanded,
+// in the image at rootfs.
+func imageLibDirs(readDir ReadDirFunc, rootfs string) []string {
+	var dirs []string
+	for _, dir := range libDirs {
+		parent, pattern := filepath.Split(dir)
+		if !strings.Contains(pattern, "*") {
+			dirs = append(dirs, dir)
+			continue
+		}
+		entries, err := readDir(filepath.Join(rootfs, parent))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if ok, _ := filepath.Match(pattern, e.Name()); ok {
+				dirs = append(dirs, filepath.Join(parent, e.Name()))
+			}
+		}
+	}
+	return dirs
+}
+
+// libSoname returns the SONAME of the ELF shared library at path.
+func libSoname(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sonames, err := f.DynString(elf.DT_SONAME)
+	if err != nil || len(sonames) == 0 {
+		return "", err
+	}
+	return sonames[0], nil
+}
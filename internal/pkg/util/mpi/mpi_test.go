@@ -0,0 +1,47 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build linux
+
+package mpi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSonameABI(t *testing.T) {
+	rootfs := t.TempDir()
+	libDir := filepath.Join(rootfs, "usr", "lib", "x86_64-linux-gnu")
+	if err := os.MkdirAll(libDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, lib := range []string{"libpmix.so.2.5.2", "libpmi2.so.0"} {
+		if err := os.WriteFile(filepath.Join(libDir, lib), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		soname  string
+		wantErr bool
+	}{
+		{name: "Compatible", soname: "libpmix.so.2", wantErr: false},
+		{name: "CompatibleNoMinor", soname: "libpmi2.so.0", wantErr: false},
+		{name: "Incompatible", soname: "libpmix.so.1", wantErr: true},
+		{name: "NotInImage", soname: "libpmi.so.0", wantErr: false},
+		{name: "Unversioned", soname: "libpmix.so", wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSonameABI(os.ReadDir, rootfs, tt.soname)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSonameABI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
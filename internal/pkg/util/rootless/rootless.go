@@ -85,10 +85,11 @@ func ExecWithFakeroot(args []string) error {
 		EngineName:  fakerootConfig.Name,
 		ContainerID: "fakeroot",
 		EngineConfig: &fakerootConfig.EngineConfig{
-			Envs:        env,
-			Args:        args,
-			NoPIDNS:     true,
-			NoSetgroups: true,
+			Envs:           env,
+			Args:           args,
+			NoPIDNS:        true,
+			NoSetgroups:    true,
+			KernelSquashfs: true,
 		},
 	}
 
@@ -61,6 +61,54 @@ func Convert(sig string) (unix.Signal, error) {
 	return sigNum, nil
 }
 
+// ParseProxyList converts the signal names or numbers in list to the signals
+// to relay to a container process. The value "all" selects all the signals
+// that can be relayed. SIGKILL and SIGSTOP cannot be caught, SIGCHLD and
+// SIGURG are handled by Singularity itself, and SIGINT, SIGQUIT and SIGTSTP are
+// sent by a terminal to its whole foreground process group, so that the
+// container process would receive them twice. None of them can be relayed.
+func ParseProxyList(list []string) ([]unix.Signal, error) {
+	var signals []unix.Signal
+	seen := make(map[unix.Signal]bool)
+	add := func(sig unix.Signal) {
+		if !seen[sig] {
+			seen[sig] = true
+			signals = append(signals, sig)
+		}
+	}
+
+	for _, v := range list {
+		if strings.EqualFold(v, "all") {
+			// standard signals, real-time signals are left out
+			for sig := unix.Signal(1); sig < 32; sig++ {
+				if unix.SignalName(sig) != "" && !unrelayable(sig) {
+					add(sig)
+				}
+			}
+			continue
+		}
+		sig, err := Convert(v)
+		if err != nil {
+			return nil, err
+		}
+		if unrelayable(sig) {
+			return nil, fmt.Errorf("%s cannot be relayed to a container", unix.SignalName(sig))
+		}
+		add(sig)
+	}
+	return signals, nil
+}
+
+// unrelayable returns whether sig cannot be relayed by ParseProxyList.
+func unrelayable(sig unix.Signal) bool {
+	switch sig {
+	case unix.SIGKILL, unix.SIGSTOP, unix.SIGCHLD, unix.SIGURG,
+		unix.SIGINT, unix.SIGQUIT, unix.SIGTSTP:
+		return true
+	}
+	return false
+}
+
 // Raise sends a signal to the current process and ensure the
 // current signal handler is set to its default handler for the
 // corresponding signal. It allows to send signals like SIGABRT
@@ -6,6 +6,7 @@
 package signal
 
 import (
+	"reflect"
 	"testing"
 
 	"golang.org/x/sys/unix"
@@ -73,3 +74,95 @@ func TestConvert(t *testing.T) {
 		}
 	}
 }
+
+func TestParseProxyList(t *testing.T) {
+	tests := []struct {
+		name        string
+		list        []string
+		wantSignals []unix.Signal
+		wantErr     bool
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name:        "Signals",
+			list:        []string{"USR1", "SIGUSR2", "15"},
+			wantSignals: []unix.Signal{unix.SIGUSR1, unix.SIGUSR2, unix.SIGTERM},
+		},
+		{
+			name:        "Duplicates",
+			list:        []string{"USR1", "SIGUSR1", "10"},
+			wantSignals: []unix.Signal{unix.SIGUSR1},
+		},
+		{
+			name:    "Unknown",
+			list:    []string{"USR3"},
+			wantErr: true,
+		},
+		{
+			name:    "Kill",
+			list:    []string{"KILL"},
+			wantErr: true,
+		},
+		{
+			name:    "Child",
+			list:    []string{"SIGCHLD"},
+			wantErr: true,
+		},
+		{
+			name:    "Interrupt",
+			list:    []string{"INT"},
+			wantErr: true,
+		},
+		{
+			name:    "Quit",
+			list:    []string{"3"},
+			wantErr: true,
+		},
+		{
+			name:    "TerminalStop",
+			list:    []string{"SIGTSTP"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := ParseProxyList(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(signals, tt.wantSignals) {
+				t.Errorf("got signals %v, want %v", signals, tt.wantSignals)
+			}
+		})
+	}
+
+	t.Run("All", func(t *testing.T) {
+		signals, err := ParseProxyList([]string{"HUP", "ALL"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if signals[0] != unix.SIGHUP {
+			t.Errorf("got first signal %v, want %v", signals[0], unix.SIGHUP)
+		}
+		found := make(map[unix.Signal]bool)
+		for _, s := range signals {
+			if found[s] {
+				t.Errorf("signal %v listed twice", s)
+			}
+			found[s] = true
+		}
+		for _, s := range []unix.Signal{unix.SIGUSR1, unix.SIGTERM, unix.SIGWINCH} {
+			if !found[s] {
+				t.Errorf("signal %v not selected by all", s)
+			}
+		}
+		for _, s := range []unix.Signal{unix.SIGINT, unix.SIGQUIT, unix.SIGTSTP, unix.SIGKILL, unix.SIGCHLD} {
+			if found[s] {
+				t.Errorf("signal %v selected by all", s)
+			}
+		}
+	})
+}
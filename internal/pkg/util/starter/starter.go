@@ -6,6 +6,7 @@
 package starter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -110,6 +111,13 @@ func Exec(name string, config *config.Common, ops ...CommandOp) error {
 // Run executes the starter binary and returns once starter
 // finished its execution.
 func Run(name string, config *config.Common, ops ...CommandOp) error {
+	return RunContext(context.Background(), name, config, ops...)
+}
+
+// RunContext executes the starter binary and returns once starter finished
+// its execution. If ctx is canceled first, starter is sent SIGTERM, which it
+// relays to the container process.
+func RunContext(ctx context.Context, name string, config *config.Common, ops ...CommandOp) error {
 	c := new(Command)
 	if err := c.init(config, ops...); err != nil {
 		return fmt.Errorf("while initializing starter command: %s", err)
@@ -118,7 +126,10 @@ func Run(name string, config *config.Common, ops ...CommandOp) error {
 	sylog.Debugf("Setting GOGC=off for starter")
 	c.env = append(c.env, "GOGC=off")
 
-	cmd := exec.Command(c.path)
+	cmd := exec.CommandContext(ctx, c.path)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(unix.SIGTERM)
+	}
 	cmd.Args = []string{name}
 	cmd.Env = c.env
 	cmd.Stdin = c.stdin
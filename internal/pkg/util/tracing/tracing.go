@@ -0,0 +1,140 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package tracing provides optional OpenTelemetry tracing of the major phases
+// of singularity operations. Spans are exported with OTLP when an endpoint is
+// set by the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment variables, and are otherwise
+// discarded at no cost.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/sylabs/singularity"
+
+// shutdownTimeout bounds the time spent flushing spans to the exporter.
+const shutdownTimeout = 5 * time.Second
+
+var (
+	provider *sdktrace.TracerProvider
+	rootSpan trace.Span
+)
+
+// Enabled returns true if an OTLP endpoint is configured in the environment.
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// protocol returns the OTLP protocol configured in the environment.
+func protocol() string {
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); p != "" {
+		return p
+	}
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" {
+		return p
+	}
+	return "http/protobuf"
+}
+
+// Init sets up the global tracer provider to export spans with OTLP, if
+// Enabled. The exporter is configured by the standard OTEL_EXPORTER_OTLP_*
+// environment variables, and the resource by OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES.
+func Init(ctx context.Context) error {
+	if !Enabled() || provider != nil {
+		return nil
+	}
+
+	var client otlptrace.Client
+	switch p := protocol(); p {
+	case "http/protobuf":
+		client = otlptracehttp.NewClient()
+	case "grpc":
+		client = otlptracegrpc.NewClient()
+	default:
+		return fmt.Errorf("unsupported OTLP protocol %q", p)
+	}
+
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return fmt.Errorf("while creating OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "singularity"),
+			attribute.String("service.version", buildcfg.PACKAGE_VERSION),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithProcessPID(),
+	)
+	if err != nil {
+		return fmt.Errorf("while creating trace resource: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// StartRoot starts the span covering a whole singularity command, which is
+// ended by Shutdown.
+func StartRoot(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	ctx, rootSpan = Start(ctx, name, attrs...)
+	return ctx
+}
+
+// Start starts a span named name, as a child of any span in ctx. The span
+// must be ended with End.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, recording err if it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Shutdown ends the root span, and flushes all spans to the exporter. It must
+// be called before the process exits or is replaced with exec.
+func Shutdown() error {
+	if rootSpan != nil {
+		rootSpan.End()
+		rootSpan = nil
+	}
+	if provider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := provider.Shutdown(ctx)
+	provider = nil
+	return err
+}
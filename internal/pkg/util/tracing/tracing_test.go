@@ -0,0 +1,84 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{
+			name: "Disabled",
+		},
+		{
+			name: "Endpoint",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318",
+			},
+			wantEnabled: true,
+		},
+		{
+			name: "TracesEndpointGRPC",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:4317",
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+			},
+			wantEnabled: true,
+		},
+		{
+			name: "UnsupportedProtocol",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318",
+				"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json",
+			},
+			wantEnabled: true,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+				"OTEL_EXPORTER_OTLP_PROTOCOL",
+				"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL",
+			} {
+				t.Setenv(k, tt.env[k])
+			}
+
+			if got := Enabled(); got != tt.wantEnabled {
+				t.Errorf("Enabled() = %v, want %v", got, tt.wantEnabled)
+			}
+
+			err := Init(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := provider != nil; got != (tt.wantEnabled && !tt.wantErr) {
+				t.Errorf("provider set = %v", got)
+			}
+
+			ctx := StartRoot(context.Background(), "root")
+			_, span := Start(ctx, "phase")
+			End(span, errors.New("failed"))
+
+			// Export to the unreachable endpoint is bounded by shutdownTimeout.
+			_ = Shutdown()
+			if provider != nil || rootSpan != nil {
+				t.Errorf("Shutdown() left provider or root span set")
+			}
+		})
+	}
+}
@@ -21,20 +21,25 @@ const (
 	HTTPS = "https"
 	// Oras is the keyword for an oras ref
 	Oras = "oras"
+	// Globus is the keyword for a globus ref
+	Globus = "globus"
+	// Variants is the keyword for an image variants manifest ref
+	Variants = "variants"
 )
 
 // validURIs contains a list of known uris
 var validURIs = map[string]bool{
-	"library":        true,
-	"shub":           true,
-	"docker":         true,
-	"docker-archive": true,
-	"docker-daemon":  true,
-	"oci":            true,
-	"oci-archive":    true,
-	"http":           true,
-	"https":          true,
-	"oras":           true,
+	"library":            true,
+	"shub":               true,
+	"docker":             true,
+	"docker-archive":     true,
+	"docker-daemon":      true,
+	"containers-storage": true,
+	"oci":                true,
+	"oci-archive":        true,
+	"http":               true,
+	"https":              true,
+	"oras":               true,
 }
 
 // IsValid returns whether or not the given source is valid
@@ -66,7 +71,7 @@ func Filename(uri string, suffix string) string {
 	ref = strings.TrimLeft(ref, "/")    // Trim leading "/" characters
 	refSplit := strings.Split(ref, "/") // Split ref into parts
 
-	if transport == HTTP || transport == HTTPS {
+	if transport == HTTP || transport == HTTPS || transport == Globus {
 		imageName := refSplit[len(refSplit)-1]
 		return imageName
 	}
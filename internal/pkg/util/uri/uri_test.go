@@ -20,6 +20,7 @@ func Test_GetName(t *testing.T) {
 		{"docker scoped", "docker://user/image", "oci.sif", "image_latest.oci.sif"},
 		{"dave's magical lolcow", "docker://sylabs.io/lolcow", "sif", "lolcow_latest.sif"},
 		{"docker w/ tags", "docker://sylabs.io/lolcow:3.7", "sif", "lolcow_3.7.sif"},
+		{"globus", "globus://ddb59aef-6d04-11e5-ba46-22000b92c6ec/images/lolcow.sif", "sif", "lolcow.sif"},
 	}
 
 	for _, tt := range tests {
@@ -118,6 +118,54 @@ $(rocm_liblist_INSTALL): $(rocm_liblist)
 INSTALLFILES += $(rocm_liblist_INSTALL)
 
 
+# intel liblist config file
+intel_liblist := $(SOURCEDIR)/etc/intelliblist.conf
+
+intel_liblist_INSTALL := $(DESTDIR)$(SYSCONFDIR)/singularity/intelliblist.conf
+$(intel_liblist_INSTALL): $(intel_liblist)
+	@echo " INSTALL" $@
+	$(V)umask 0022 && mkdir -p $(@D)
+	$(V)install -m 0644 $< $@
+
+INSTALLFILES += $(intel_liblist_INSTALL)
+
+
+# rdma liblist config file
+rdma_liblist := $(SOURCEDIR)/etc/rdmaliblist.conf
+
+rdma_liblist_INSTALL := $(DESTDIR)$(SYSCONFDIR)/singularity/rdmaliblist.conf
+$(rdma_liblist_INSTALL): $(rdma_liblist)
+	@echo " INSTALL" $@
+	$(V)umask 0022 && mkdir -p $(@D)
+	$(V)install -m 0644 $< $@
+
+INSTALLFILES += $(rdma_liblist_INSTALL)
+
+
+# pmix liblist config file
+pmix_liblist := $(SOURCEDIR)/etc/pmixliblist.conf
+
+pmix_liblist_INSTALL := $(DESTDIR)$(SYSCONFDIR)/singularity/pmixliblist.conf
+$(pmix_liblist_INSTALL): $(pmix_liblist)
+	@echo " INSTALL" $@
+	$(V)umask 0022 && mkdir -p $(@D)
+	$(V)install -m 0644 $< $@
+
+INSTALLFILES += $(pmix_liblist_INSTALL)
+
+
+# pmi2 liblist config file
+pmi2_liblist := $(SOURCEDIR)/etc/pmi2liblist.conf
+
+pmi2_liblist_INSTALL := $(DESTDIR)$(SYSCONFDIR)/singularity/pmi2liblist.conf
+$(pmi2_liblist_INSTALL): $(pmi2_liblist)
+	@echo " INSTALL" $@
+	$(V)umask 0022 && mkdir -p $(@D)
+	$(V)install -m 0644 $< $@
+
+INSTALLFILES += $(pmi2_liblist_INSTALL)
+
+
 # cgroups config file
 cgroups_config := $(SOURCEDIR)/internal/pkg/cgroups/example/cgroups.toml
 
@@ -0,0 +1,4 @@
+# build with the Go BoringCrypto FIPS 140 module, which enforces strict FIPS mode
+GOEXPERIMENT := boringcrypto
+
+export GOEXPERIMENT
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	ocitypes "github.com/containers/image/v5/types"
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -85,6 +86,27 @@ type Options struct {
 	Platform ggcrv1.Platform
 	// Authentication file for registry credentials
 	DockerAuthFile string
+	// OnlyPaths lists the paths to extract from an image source into a
+	// sandbox, instead of its whole root filesystem.
+	OnlyPaths []string `json:"onlyPaths"`
+	// Network is the network policy of the %pre, %setup and %post sections:
+	// empty for the host network, "none" for an isolated network namespace,
+	// or a comma separated list of CNI networks, which only applies to %post.
+	Network string `json:"network"`
+	// NetworkArgs are the arguments passed to the CNI plugins of Network.
+	NetworkArgs []string `json:"networkArgs"`
+	// Reproducible requests images that are bit-identical when built twice
+	// from the same inputs, with all timestamps set to SourceDateEpoch.
+	Reproducible bool `json:"reproducible"`
+	// SourceDateEpoch is the time recorded in reproducible images.
+	SourceDateEpoch time.Time `json:"sourceDateEpoch"`
+	// PrimeCache requests the generation of /etc/ld.so.cache and of the PATH
+	// cache in the image, once its %post section has run.
+	PrimeCache bool `json:"primeCache"`
+	// AccessProfile is the path of a file listing the paths in the image
+	// accessed first when it runs, one per line. They are stored first in
+	// the squashfs root filesystem of SIF images, in the listed order.
+	AccessProfile string `json:"accessProfile"`
 }
 
 // NewEncryptedBundle creates an Encrypted Bundle environment.
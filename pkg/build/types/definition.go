@@ -84,7 +84,8 @@ type Files struct {
 }
 
 // Stage returns the build stage referenced by the files section f, or "" if no stage is
-// referenced.
+// referenced. The returned value is a container image reference when Image
+// returns a non-empty URI.
 func (f Files) Stage() string {
 	// Trim comments from args.
 	cleanArgs := strings.SplitN(f.Args, "#", 2)[0]
@@ -97,6 +98,23 @@ func (f Files) Stage() string {
 	return ""
 }
 
+// Image returns the URI of the container image referenced by the files
+// section f, or "" if f doesn't reference an image. Images are referenced by
+// URI, such as docker://alpine or oras://registry/image, or by the path of a
+// local SIF, OCI-SIF or sandbox image, which is returned as a localimage URI.
+func (f Files) Image() string {
+	ref := f.Stage()
+	switch {
+	case ref == "":
+		return ""
+	case strings.Contains(ref, ":"):
+		return ref
+	case strings.HasPrefix(ref, "/"), strings.HasPrefix(ref, "./"), strings.HasPrefix(ref, "../"):
+		return "localimage://" + ref
+	}
+	return ""
+}
+
 // FileTransport holds source and destination information of files to copy into the container.
 type FileTransport struct {
 	Src string `json:"source"`
@@ -143,3 +143,29 @@ func TestNewDefinitionFromJSON(t *testing.T) {
 		t.Fatal("Invalid number of labels")
 	}
 }
+
+func TestFilesImage(t *testing.T) {
+	cases := []struct {
+		args      string
+		wantStage string
+		wantImage string
+	}{
+		{args: "", wantStage: "", wantImage: ""},
+		{args: "from devel", wantStage: "devel", wantImage: ""},
+		{args: "from devel # comment", wantStage: "devel", wantImage: ""},
+		{args: "from docker://alpine:3.18", wantStage: "docker://alpine:3.18", wantImage: "docker://alpine:3.18"},
+		{args: "from oras://registry/image:tag", wantStage: "oras://registry/image:tag", wantImage: "oras://registry/image:tag"},
+		{args: "from /images/tools.oci.sif", wantStage: "/images/tools.oci.sif", wantImage: "localimage:///images/tools.oci.sif"},
+		{args: "from ./tools.sif", wantStage: "./tools.sif", wantImage: "localimage://./tools.sif"},
+	}
+
+	for _, tc := range cases {
+		f := Files{Args: tc.args}
+		if got := f.Stage(); got != tc.wantStage {
+			t.Errorf("Stage() for %q = %q, want %q", tc.args, got, tc.wantStage)
+		}
+		if got := f.Image(); got != tc.wantImage {
+			t.Errorf("Image() for %q = %q, want %q", tc.args, got, tc.wantImage)
+		}
+	}
+}
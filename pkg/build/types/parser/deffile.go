@@ -372,6 +372,12 @@ func doHeader(h string, d *types.Definition) error {
 			continue
 		}
 
+		// skip build arg template actions, such as {{ if ... }}, which are
+		// evaluated before the definition is parsed again
+		if strings.HasPrefix(line, "{{") && strings.HasSuffix(line, "}}") && len(valCont) == 0 {
+			continue
+		}
+
 		// trim any comments on header lines
 		trimLine := strings.Split(line, "#")[0]
 		if len(valCont) == 0 {
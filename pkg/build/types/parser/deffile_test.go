@@ -408,6 +408,7 @@ func TestParseAll(t *testing.T) {
 	}{
 		{"Single", "testdata_multi/single/docker", "testdata_multi/single/docker.json"},
 		{"MultiStage", "testdata_multi/simple/simple", "testdata_multi/simple/simple.json"},
+		{"MultiStageImages", "testdata_multi/images/images", "testdata_multi/images/images.json"},
 		{"NoHeader", "testdata_multi/noheader/noheader", "testdata_multi/noheader/noheader.json"},
 		{"NoHeaderComments", "testdata_multi/noheadercomments/noheadercomments", "testdata_multi/noheadercomments/noheadercomments.json"},
 		{"NoHeaderWhiteSpace", "testdata_multi/noheaderwhitespace/noheaderwhitespace", "testdata_multi/noheaderwhitespace/noheaderwhitespace.json"},
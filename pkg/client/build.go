@@ -0,0 +1,149 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/build"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/v4/pkg/build/types"
+	"github.com/sylabs/singularity/v4/pkg/image"
+)
+
+// BuildOptions holds the options of Build.
+type BuildOptions struct {
+	RegistryOptions
+	CacheOptions
+
+	// OCISIF builds an OCI-SIF image, rather than a native SIF image.
+	OCISIF bool
+	// Sandbox builds a sandbox directory, rather than a SIF image.
+	Sandbox bool
+	// BuildArgs sets the values of {{ variables }} of the definition file.
+	BuildArgs map[string]string
+	// Sections restricts the build to these sections of the definition file,
+	// or "none". All sections are run if empty.
+	Sections []string
+	// NoTest skips the %test section.
+	NoTest bool
+	// Update runs the definition file over an existing sandbox destination.
+	Update bool
+	// Force overwrites an existing destination.
+	Force bool
+	// FixPerms ensures the owner has rw access to all files of the image.
+	FixPerms bool
+	// TmpDir is the directory used for temporary files.
+	TmpDir string
+	// NoCleanUp keeps the build directory of a failed build.
+	NoCleanUp bool
+	// Progress, if set, is called with the progress of the build.
+	Progress ProgressFunc
+}
+
+// Build builds the image dest from spec, which is a definition file, an image
+// URI, or a local image. Definition files bootstrapping from a Singularity
+// library are not supported, as they require a configured remote endpoint.
+// Building from a definition file requires root privileges, or the proot
+// command for unprivileged builds. Fakeroot builds are not supported, as they
+// must be started from a process in a user namespace.
+func Build(ctx context.Context, dest, spec string, opts BuildOptions) error {
+	r := newReporter(StageBuild, opts.Progress)
+	r.step("building %s from %s", dest, spec)
+	return r.done(buildImage(ctx, dest, spec, opts, r), "built %s from %s", dest, spec)
+}
+
+func buildImage(ctx context.Context, dest, spec string, opts BuildOptions, r *reporter) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if opts.OCISIF && opts.Sandbox {
+		return fmt.Errorf("OCI-SIF and sandbox builds are mutually exclusive")
+	}
+
+	imgCache, err := opts.handle()
+	if err != nil {
+		return err
+	}
+	if err := checkPrivileges(spec, r); err != nil {
+		return err
+	}
+	defs, err := build.MakeAllDefs(spec, opts.BuildArgs, false)
+	if err != nil {
+		return fmt.Errorf("unable to build from %s: %w", spec, err)
+	}
+	plat, err := platform("")
+	if err != nil {
+		return err
+	}
+
+	format := "sif"
+	if opts.OCISIF {
+		format = "oci-sif"
+	} else if opts.Sandbox {
+		format = "sandbox"
+	}
+	sections := opts.Sections
+	if len(sections) == 0 {
+		sections = []string{"all"}
+	}
+
+	b, err := build.New(defs, build.Config{
+		Dest:      dest,
+		Format:    format,
+		NoCleanUp: opts.NoCleanUp,
+		Opts: types.Options{
+			ImgCache:         imgCache,
+			TmpDir:           opts.TmpDir,
+			NoCache:          opts.DisableCache,
+			Update:           opts.Update,
+			Force:            opts.Force,
+			Sections:         sections,
+			NoTest:           opts.NoTest,
+			NoHTTPS:          opts.NoHTTPS,
+			OCIAuthConfig:    opts.Auth,
+			DockerDaemonHost: opts.DockerHost,
+			DockerAuthFile:   opts.AuthFile,
+			FixPerms:         opts.FixPerms,
+			SandboxTarget:    opts.Sandbox,
+			Platform:         plat,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create build: %w", err)
+	}
+
+	r.step("running build of %d stage(s)", len(defs))
+	return b.Full(ctx)
+}
+
+// checkPrivileges checks that a build from spec can run with the privileges
+// of the process. Builds from a definition file, rather than an image, run
+// its sections as root, so an unprivileged user must build with proot.
+func checkPrivileges(spec string, r *reporter) error {
+	if os.Getuid() == 0 || !fs.IsFile(spec) || isImage(spec) {
+		return nil
+	}
+	prootPath, err := bin.FindBin("proot")
+	if err != nil {
+		return fmt.Errorf("root privileges, or the proot command, are required to build from a definition file as a non-root user")
+	}
+	os.Setenv("SINGULARITY_PROOT", prootPath)
+	r.step("using proot to build unprivileged, not all builds are supported")
+	return nil
+}
+
+// isImage returns whether spec is an image file.
+func isImage(spec string) bool {
+	i, err := image.Init(spec, false)
+	if i != nil {
+		_ = i.File.Close()
+	}
+	return err == nil
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/cache"
+	"github.com/sylabs/singularity/v4/internal/pkg/ociplatform"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+)
+
+// Stage is the operation an Event reports the progress of.
+type Stage string
+
+const (
+	StagePull  Stage = "pull"
+	StageBuild Stage = "build"
+	StageRun   Stage = "run"
+)
+
+// Event reports the progress of an operation.
+type Event struct {
+	Stage Stage
+	// Message describes the step reached.
+	Message string
+	// Done is set on the last event of an operation, with Err set if the
+	// operation failed.
+	Done bool
+	Err  error
+}
+
+// ProgressFunc is called with the events of an operation, in order. Besides
+// the steps of the operation, the info, warning, and error messages logged by
+// Singularity while the operation runs are sent as events. As they may be
+// logged by other goroutines, a ProgressFunc may be called concurrently, and
+// must not block. The messages of concurrent operations are only sent to the
+// operation logging them.
+type ProgressFunc func(Event)
+
+// RegistryOptions holds the options to access images in OCI registries, and
+// in the Docker daemon.
+type RegistryOptions struct {
+	// Auth holds credentials for OCI registries. If nil, credentials are read
+	// from AuthFile, or from the registry login of the user.
+	Auth *authn.AuthConfig
+	// AuthFile is the path of an OCI registry authentication file.
+	AuthFile string
+	// NoHTTPS disables the use of TLS.
+	NoHTTPS bool
+	// DockerHost is the address of the Docker daemon, for docker-daemon:
+	// sources.
+	DockerHost string
+}
+
+// CacheOptions holds the options of the image cache.
+type CacheOptions struct {
+	// CacheDir is the parent directory of the image cache, instead of the
+	// default of the user.
+	CacheDir string
+	// DisableCache disables the image cache.
+	DisableCache bool
+}
+
+// handle returns a handle on the image cache.
+func (o CacheOptions) handle() (*cache.Handle, error) {
+	h, err := cache.New(cache.Config{ParentDir: o.CacheDir, Disable: o.DisableCache})
+	if err != nil {
+		return nil, fmt.Errorf("while opening image cache: %w", err)
+	}
+	return h, nil
+}
+
+// platform returns the platform p, in os/arch[/variant] form, or the platform
+// of the host if empty.
+func platform(p string) (ggcrv1.Platform, error) {
+	var plat *ggcrv1.Platform
+	var err error
+	if p == "" {
+		plat, err = ociplatform.DefaultPlatform()
+	} else {
+		plat, err = ociplatform.PlatformFromString(p)
+	}
+	if err != nil {
+		return ggcrv1.Platform{}, err
+	}
+	return *plat, nil
+}
+
+var (
+	configOnce sync.Once
+	configErr  error
+)
+
+// loadConfig loads the singularity.conf of the installation, unless a
+// configuration is already set.
+func loadConfig() error {
+	configOnce.Do(func() {
+		if singularityconf.GetCurrentConfig() != nil {
+			return
+		}
+		config, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+		if err != nil {
+			configErr = fmt.Errorf("couldn't parse configuration file %s: %w", buildcfg.SINGULARITY_CONF_FILE, err)
+			return
+		}
+		singularityconf.SetCurrentConfig(config)
+	})
+	return configErr
+}
+
+// reporter sends the events of an operation at stage to fn, if set.
+type reporter struct {
+	stage Stage
+	fn    ProgressFunc
+	// goroutine is the goroutine running the operation, and prev the reporter
+	// of an enclosing operation run by the same goroutine, if any.
+	goroutine uint64
+	prev      *reporter
+}
+
+var (
+	reportersMu sync.Mutex
+	// reporters maps the goroutines running operations, and the goroutines
+	// they started, to the reporter of the operation.
+	reporters = make(map[uint64]*reporter)
+	// active is the number of operations sent logged messages. The message
+	// hook is set while it is not zero.
+	active int
+)
+
+// newReporter returns a reporter of the events of an operation at stage, also
+// sending the info, warning, and error messages logged by the operation until
+// done is called.
+func newReporter(stage Stage, fn ProgressFunc) *reporter {
+	r := &reporter{stage: stage, fn: fn}
+	if fn == nil {
+		return r
+	}
+	r.goroutine, _ = goroutineIDs()
+
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	if active == 0 {
+		sylog.SetMessageHook(logMessage)
+	}
+	active++
+	r.prev = reporters[r.goroutine]
+	reporters[r.goroutine] = r
+	return r
+}
+
+// logMessage sends a logged message to the reporter of the operation logging
+// it. A message is attributed to an operation when it is logged by the
+// goroutine running the operation, or by a goroutine started by a goroutine
+// whose messages are already attributed to the operation. Messages that can't
+// be attributed are not reported.
+func logMessage(_ int, msg string) {
+	id, parent := goroutineIDs()
+
+	reportersMu.Lock()
+	r, ok := reporters[id]
+	if !ok && parent != 0 {
+		if r, ok = reporters[parent]; ok {
+			reporters[id] = r
+		}
+	}
+	reportersMu.Unlock()
+
+	if ok {
+		r.fn(Event{Stage: r.stage, Message: msg})
+	}
+}
+
+// goroutineIDs returns the ID of the calling goroutine, and the ID of the
+// goroutine that started it, or 0 when it is not known, from its stack trace.
+func goroutineIDs() (id, parent uint64) {
+	buf := make([]byte, 64<<10)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	// goroutine 18 [running]:
+	if f := bytes.Fields(buf); len(f) > 1 {
+		id, _ = strconv.ParseUint(string(f[1]), 10, 64)
+	}
+	// created by main.main in goroutine 1
+	if i := bytes.LastIndex(buf, []byte("\ncreated by ")); i >= 0 {
+		line, _, _ := bytes.Cut(buf[i+1:], []byte("\n"))
+		if _, p, ok := bytes.Cut(line, []byte(" in goroutine ")); ok {
+			parent, _ = strconv.ParseUint(string(p), 10, 64)
+		}
+	}
+	return id, parent
+}
+
+func (r *reporter) step(format string, a ...any) {
+	if r.fn != nil {
+		r.fn(Event{Stage: r.stage, Message: fmt.Sprintf(format, a...)})
+	}
+}
+
+// done reports the end of the operation, failed if err is set, and returns
+// err.
+func (r *reporter) done(err error, format string, a ...any) error {
+	if r.fn == nil {
+		return err
+	}
+
+	reportersMu.Lock()
+	for id, e := range reporters {
+		if e == r {
+			delete(reporters, id)
+		}
+	}
+	if r.prev != nil {
+		reporters[r.goroutine] = r.prev
+	}
+	active--
+	if active == 0 {
+		sylog.SetMessageHook(nil)
+	}
+	reportersMu.Unlock()
+
+	msg := fmt.Sprintf(format, a...)
+	if err != nil {
+		msg = err.Error()
+	}
+	r.fn(Event{Stage: r.stage, Message: msg, Done: true, Err: err})
+	return err
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestExecParams(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		opts RunOptions
+		want launcher.ExecParams
+	}{
+		{
+			name: "Runscript",
+			args: []string{"--input", "data.csv"},
+			want: launcher.ExecParams{
+				Image:  "image.sif",
+				Action: "run",
+				Args:   []string{"--input", "data.csv"},
+			},
+		},
+		{
+			name: "Command",
+			args: []string{"-c", "print(1)"},
+			opts: RunOptions{Command: "python3"},
+			want: launcher.ExecParams{
+				Image:   "image.sif",
+				Action:  "exec",
+				Process: "python3",
+				Args:    []string{"-c", "print(1)"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, execParams("image.sif", tt.args, tt.opts), tt.want)
+		})
+	}
+}
+
+func TestRunURI(t *testing.T) {
+	defer singularityconf.SetCurrentConfig(singularityconf.GetCurrentConfig())
+	singularityconf.SetCurrentConfig(&singularityconf.File{})
+
+	err := Run(context.Background(), "docker://alpine", nil, RunOptions{})
+	assert.ErrorContains(t, err, "running docker images is not supported")
+}
+
+func TestReporterMessages(t *testing.T) {
+	var events []Event
+	r := newReporter(StageRun, func(e Event) { events = append(events, e) })
+	sylog.Infof("first")
+	sylog.Debugf("hidden")
+	r.done(nil, "done")
+	sylog.Warningf("after")
+
+	assert.DeepEqual(t, events, []Event{
+		{Stage: StageRun, Message: "first"},
+		{Stage: StageRun, Message: "done", Done: true},
+	})
+}
+
+func TestReporterConcurrent(t *testing.T) {
+	const ops = 4
+
+	var wg sync.WaitGroup
+	events := make([][]Event, ops)
+	start := make(chan struct{})
+	for i := 0; i < ops; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := newReporter(StagePull, func(e Event) { events[i] = append(events[i], e) })
+			<-start
+			sylog.Infof("op %d", i)
+			// Messages of goroutines started by the operation are also
+			// attributed to it.
+			done := make(chan struct{})
+			go func() {
+				sylog.Warningf("op %d child", i)
+				close(done)
+			}()
+			<-done
+			r.done(nil, "op %d done", i)
+		}()
+	}
+	// Let all operations register before they log.
+	time.Sleep(100 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < ops; i++ {
+		assert.DeepEqual(t, events[i], []Event{
+			{Stage: StagePull, Message: fmt.Sprintf("op %d", i)},
+			{Stage: StagePull, Message: fmt.Sprintf("op %d child", i)},
+			{Stage: StagePull, Message: fmt.Sprintf("op %d done", i), Done: true},
+		})
+	}
+	assert.Equal(t, len(reporters), 0)
+	assert.Equal(t, active, 0)
+}
+
+func TestPullErrors(t *testing.T) {
+	defer singularityconf.SetCurrentConfig(singularityconf.GetCurrentConfig())
+	singularityconf.SetCurrentConfig(&singularityconf.File{})
+
+	existing := filepath.Join(t.TempDir(), "existing.sif")
+	assert.NilError(t, os.WriteFile(existing, nil, 0o644))
+	dest := filepath.Join(t.TempDir(), "image.sif")
+
+	tests := []struct {
+		name    string
+		dest    string
+		src     string
+		opts    PullOptions
+		wantErr string
+	}{
+		{
+			name:    "Exists",
+			dest:    existing,
+			src:     "docker://alpine",
+			wantErr: "image file already exists",
+		},
+		{
+			name:    "Library",
+			dest:    dest,
+			src:     "library://alpine",
+			wantErr: "pulling library images is not supported",
+		},
+		{
+			name:    "Unsupported",
+			dest:    dest,
+			src:     "ftp://example.com/alpine.sif",
+			wantErr: "unsupported transport type: ftp",
+		},
+		{
+			name:    "ShubOCISIF",
+			dest:    dest,
+			src:     "shub://vsoch/hello-world",
+			opts:    PullOptions{OCISIF: true},
+			wantErr: "pulling shub images to OCI-SIF is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []Event
+			tt.opts.DisableCache = true
+			tt.opts.Progress = func(e Event) { events = append(events, e) }
+
+			err := Pull(context.Background(), tt.dest, tt.src, tt.opts)
+			assert.ErrorContains(t, err, tt.wantErr)
+
+			assert.Equal(t, len(events), 2)
+			assert.Equal(t, events[0].Stage, StagePull)
+			assert.Assert(t, !events[0].Done)
+			assert.Assert(t, events[1].Done)
+			assert.Equal(t, events[1].Err, err)
+		})
+	}
+}
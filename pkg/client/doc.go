@@ -0,0 +1,28 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+/*
+Package client provides a Go API to pull, build, and run Singularity images,
+for programs such as workflow engines that embed Singularity operations.
+
+	err := client.Pull(ctx, "alpine.sif", "docker://alpine:3.18", client.PullOptions{
+	    Progress: func(e client.Event) { log.Println(e.Stage, e.Message) },
+	})
+
+Pull, Build, and Run are performed by the calling process, and honor the
+singularity.conf of the installation. Run starts the starter of the
+installation as a child process, which runs the container with the native
+runtime. Operations that must be started from a process in a user namespace,
+such as OCI mode runs and fakeroot builds, are not supported. Operations stop
+when their context is canceled.
+
+Info, warning, and error messages logged by an operation are sent to its
+ProgressFunc, so that concurrent operations each receive their own messages.
+Messages are attributed to an operation by the goroutine logging them: the
+goroutine calling the operation, or a goroutine started by a goroutine whose
+messages are already attributed to the operation. Other messages are not
+reported.
+*/
+package client
@@ -0,0 +1,108 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/client/oci"
+	"github.com/sylabs/singularity/v4/internal/pkg/client/shub"
+	uritransport "github.com/sylabs/singularity/v4/internal/pkg/client/transport"
+	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/uri"
+
+	// transports registered for pulls from oras://, http(s)://, and globus://
+	_ "github.com/sylabs/singularity/v4/internal/pkg/client/globus"
+	_ "github.com/sylabs/singularity/v4/internal/pkg/client/net"
+	_ "github.com/sylabs/singularity/v4/internal/pkg/client/oras"
+)
+
+// PullOptions holds the options of Pull.
+type PullOptions struct {
+	RegistryOptions
+	CacheOptions
+
+	// OCISIF pulls OCI images to an OCI-SIF image, rather than converting
+	// them to a native SIF image.
+	OCISIF bool
+	// Platform selects the image of a multi-platform OCI image, in
+	// os/arch[/variant] form. It defaults to the platform of the host.
+	Platform string
+	// TmpDir is the directory used for temporary files.
+	TmpDir string
+	// Force overwrites an existing destination file.
+	Force bool
+	// Progress, if set, is called with the progress of the pull.
+	Progress ProgressFunc
+}
+
+// Pull retrieves the image at the URI src, to the image file dest. OCI
+// images (docker://, oci:, docker-daemon:, ...) and images at oras://,
+// shub://, http(s)://, and globus:// URIs are supported. Images in a
+// Singularity library are not, as they require a configured remote endpoint.
+func Pull(ctx context.Context, dest, src string, opts PullOptions) error {
+	r := newReporter(StagePull, opts.Progress)
+	r.step("pulling %s to %s", src, dest)
+	return r.done(pull(ctx, dest, src, opts), "pulled %s to %s", src, dest)
+}
+
+func pull(ctx context.Context, dest, src string, opts PullOptions) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	transport, ref := uri.Split(src)
+	if ref == "" {
+		return fmt.Errorf("bad URI %s", src)
+	}
+	if _, err := os.Stat(dest); err == nil && !opts.Force {
+		return fmt.Errorf("image file already exists: %q - will not overwrite", dest)
+	}
+
+	imgCache, err := opts.handle()
+	if err != nil {
+		return err
+	}
+
+	switch transport {
+	case uri.Library, "":
+		return fmt.Errorf("pulling %s images is not supported, use the singularity pull command", uri.Library)
+	case uri.Shub:
+		if opts.OCISIF {
+			return fmt.Errorf("pulling %s images to OCI-SIF is not supported", uri.Shub)
+		}
+		_, err = shub.PullToFile(ctx, imgCache, dest, src, opts.NoHTTPS)
+	case ocitransport.SupportedTransport(transport):
+		plat, err := platform(opts.Platform)
+		if err != nil {
+			return err
+		}
+		_, err = oci.PullToFile(ctx, imgCache, dest, src, oci.PullOptions{
+			TmpDir:      opts.TmpDir,
+			OciAuth:     opts.Auth,
+			DockerHost:  opts.DockerHost,
+			NoHTTPS:     opts.NoHTTPS,
+			OciSif:      opts.OCISIF,
+			Platform:    plat,
+			ReqAuthFile: opts.AuthFile,
+		})
+		return err
+	default:
+		tr, ok := uritransport.Lookup(transport)
+		if !ok {
+			return fmt.Errorf("unsupported transport type: %s", transport)
+		}
+		_, err = tr.Fetch(ctx, imgCache, src, dest, uritransport.Options{
+			TmpDir:      opts.TmpDir,
+			NoHTTPS:     opts.NoHTTPS,
+			OciAuth:     opts.Auth,
+			ReqAuthFile: opts.AuthFile,
+		})
+	}
+	return err
+}
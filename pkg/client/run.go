@@ -0,0 +1,99 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/launcher/native"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/uri"
+)
+
+// RunOptions holds the options of Run.
+type RunOptions struct {
+	// Command is executed in the container, with the arguments of Run,
+	// rather than the runscript of the image.
+	Command string
+	// Binds holds bind path specs, in src[:dest[:opts]] form.
+	Binds []string
+	// Env sets environment variables in the container.
+	Env map[string]string
+	// CleanEnv does not pass the host environment to the container.
+	CleanEnv bool
+	// Contain uses minimal /dev and empty other directories, such as /tmp
+	// and $HOME, instead of sharing them with the host.
+	Contain bool
+	// Stdin, Stdout, and Stderr are connected to the container process. If
+	// nil, they are connected to the null device.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Progress, if set, is called with the progress of the run.
+	Progress ProgressFunc
+}
+
+// Run runs the runscript of the local image, or opts.Command, with args,
+// until it exits or ctx is canceled. Images are run with the native runtime.
+// OCI mode is not supported, as it must be started from a process in a user
+// namespace. If the container exits with a non-zero status, the returned
+// error wraps an *exec.ExitError.
+func Run(ctx context.Context, image string, args []string, opts RunOptions) error {
+	r := newReporter(StageRun, opts.Progress)
+	r.step("running %s", image)
+	return r.done(run(ctx, image, args, opts), "%s exited", image)
+}
+
+func run(ctx context.Context, image string, args []string, opts RunOptions) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if transport, _ := uri.Split(image); transport != "" {
+		return fmt.Errorf("running %s images is not supported, pull %s to a local image first", transport, image)
+	}
+
+	l, err := native.NewLauncher(runOptions(opts)...)
+	if err != nil {
+		return fmt.Errorf("while configuring container: %w", err)
+	}
+
+	err = l.Exec(ctx, execParams(image, args, opts))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("container exited with status %d: %w", exitErr.ExitCode(), exitErr)
+	}
+	return err
+}
+
+// runOptions returns the launcher options to run a container with opts.
+func runOptions(opts RunOptions) []launcher.Option {
+	return []launcher.Option{
+		launcher.OptConfigFile(buildcfg.SINGULARITY_CONF_FILE),
+		launcher.OptMounts(opts.Binds, nil, nil),
+		launcher.OptEnv(opts.Env, nil, opts.CleanEnv),
+		launcher.OptContain(opts.Contain),
+		launcher.OptStdio(opts.Stdin, opts.Stdout, opts.Stderr),
+	}
+}
+
+// execParams returns the parameters of the launcher to run image with args.
+func execParams(image string, args []string, opts RunOptions) launcher.ExecParams {
+	ep := launcher.ExecParams{
+		Image:  image,
+		Action: "run",
+		Args:   args,
+	}
+	if opts.Command != "" {
+		ep.Action = "exec"
+		ep.Process = opts.Command
+	}
+	return ep
+}
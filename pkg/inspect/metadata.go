@@ -35,6 +35,9 @@ type Attributes struct {
 // Data holds the container metadata attributes.
 type Data struct {
 	Attributes Attributes `json:"attributes"`
+	// Edited lists the attributes changed since the image was built, which
+	// are applied to the container when it runs.
+	Edited []string `json:"edited,omitempty"`
 }
 
 // Metadata describes the JSON format of Singularity container metadata.
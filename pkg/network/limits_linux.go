@@ -0,0 +1,264 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/containernetworking/plugins/pkg/ip"
+	"github.com/containernetworking/plugins/pkg/ns"
+)
+
+const defaultEnvPath = "/bin:/sbin:/usr/bin:/usr/sbin"
+
+var rateRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([kmgt]?)(i?)(bit|bps)$`)
+
+// Limits describes the bandwidth and egress restrictions applied inside a
+// container network namespace.
+type Limits struct {
+	// Rate is the maximum rate, in bits per second, of traffic sent from each
+	// container interface. Zero means no rate limit.
+	Rate uint64
+	// Egress lists the networks that the container may send traffic to. When
+	// empty, egress traffic is not restricted.
+	Egress []*net.IPNet
+}
+
+// ParseLimits parses network limits from a list of "rate=<rate>" and
+// "egress=<cidr>" entries, as accepted by --network-limit. Entries override
+// the default rate and egress allow-list, e.g. from singularity.conf. A nil
+// Limits is returned when no limit applies.
+func ParseLimits(entries []string, defaultRate string, defaultEgress []string) (*Limits, error) {
+	rate := defaultRate
+	var egress []string
+
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid network limit %q, must be of form rate=<rate> or egress=<cidr>", entry)
+		}
+		switch key {
+		case "rate":
+			rate = value
+		case "egress":
+			egress = append(egress, value)
+		default:
+			return nil, fmt.Errorf("unknown network limit %q, must be rate or egress", key)
+		}
+	}
+	if egress == nil {
+		egress = defaultEgress
+	}
+
+	if rate == "" && len(egress) == 0 {
+		return nil, nil
+	}
+
+	l := &Limits{}
+	if rate != "" {
+		r, err := parseRate(rate)
+		if err != nil {
+			return nil, err
+		}
+		l.Rate = r
+	}
+	for _, e := range egress {
+		_, cidr, err := net.ParseCIDR(e)
+		if err != nil {
+			ip := net.ParseIP(e)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid egress network %q: %s", e, err)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			cidr = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		l.Egress = append(l.Egress, cidr)
+	}
+	return l, nil
+}
+
+// parseRate converts a tc style rate, e.g. 10mbit or 1.5MBps, to bits per
+// second.
+func parseRate(rate string) (uint64, error) {
+	m := rateRegex.FindStringSubmatch(strings.ToLower(rate))
+	if m == nil {
+		return 0, fmt.Errorf("invalid network rate %q, must be a number followed by bit or bps, with an optional k, m, g, t prefix", rate)
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid network rate %q: %s", rate, err)
+	}
+
+	base := 1000.0
+	if m[3] == "i" {
+		base = 1024.0
+	}
+	switch m[2] {
+	case "t":
+		value *= base
+		fallthrough
+	case "g":
+		value *= base
+		fallthrough
+	case "m":
+		value *= base
+		fallthrough
+	case "k":
+		value *= base
+	}
+	if m[4] == "bps" {
+		value *= 8
+	}
+	if value < 8 {
+		return 0, fmt.Errorf("invalid network rate %q, must be at least 8bit", rate)
+	}
+	return uint64(value), nil
+}
+
+// SetLimits sets the bandwidth and egress limits that AddNetworks applies to
+// the container interfaces. The limits are removed along with the container
+// interfaces and network namespace, so DelNetworks has nothing to undo.
+func (m *Setup) SetLimits(limits *Limits) {
+	m.limits = limits
+}
+
+// applyLimits installs a tc rate limit on the host side of each container
+// interface, out of reach of processes in the container network namespace,
+// and an nftables egress allow-list inside the container network namespace.
+func (m *Setup) applyLimits() error {
+	if m.limits == nil {
+		return nil
+	}
+
+	if m.limits.Rate > 0 {
+		// Allow bursts of 10ms of traffic, but no less than an MTU or two.
+		burst := m.limits.Rate / 8 / 100
+		if burst < 32*1024 {
+			burst = 32 * 1024
+		}
+		for _, rc := range m.runtimeConf {
+			hostIf, err := m.hostInterface(rc.IfName)
+			if err != nil {
+				return fmt.Errorf("while setting rate limit on %s: %s", rc.IfName, err)
+			}
+			// Traffic sent from the container is received by the host side
+			// of the veth pair, where it is policed.
+			if err := m.run(nil, "tc", "qdisc", "add", "dev", hostIf, "handle", "ffff:", "ingress"); err != nil {
+				return fmt.Errorf("while setting rate limit on %s: %s", rc.IfName, err)
+			}
+			err = m.run(nil, "tc", "filter", "add", "dev", hostIf, "parent", "ffff:",
+				"protocol", "all", "prio", "1", "u32", "match", "u32", "0", "0",
+				"police", "rate", fmt.Sprintf("%dbit", m.limits.Rate),
+				"burst", strconv.FormatUint(burst, 10), "drop",
+			)
+			if err != nil {
+				return fmt.Errorf("while setting rate limit on %s: %s", rc.IfName, err)
+			}
+		}
+	}
+
+	if len(m.limits.Egress) > 0 {
+		return ns.WithNetNSPath(m.netNS, func(ns.NetNS) error {
+			if err := m.run(egressRuleset(m.limits.Egress), "nft", "-f", "-"); err != nil {
+				return fmt.Errorf("while setting egress allow-list: %s", err)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// hostInterface returns the name of the host side peer of the veth interface
+// ifName in the container network namespace. Rate limits require a veth
+// interface, as other interface types have no host side to shape traffic on.
+func (m *Setup) hostInterface(ifName string) (string, error) {
+	var peerIndex int
+	err := ns.WithNetNSPath(m.netNS, func(ns.NetNS) error {
+		var err error
+		_, peerIndex, err = ip.GetVethPeerIfindex(ifName)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("rate limits require a veth interface: %s", err)
+	}
+	peer, err := net.InterfaceByIndex(peerIndex)
+	if err != nil {
+		return "", fmt.Errorf("while looking up host interface %d: %s", peerIndex, err)
+	}
+	return peer.Name, nil
+}
+
+// egressRuleset returns an nftables ruleset dropping traffic sent from the
+// container, except to loopback, established connections, and the allowed
+// networks.
+func egressRuleset(allowed []*net.IPNet) []byte {
+	var ip4, ip6 []string
+	for _, n := range allowed {
+		if n.IP.To4() != nil {
+			ip4 = append(ip4, n.String())
+		} else {
+			ip6 = append(ip6, n.String())
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("table inet singularity {\n")
+	b.WriteString("\tchain egress {\n")
+	b.WriteString("\t\ttype filter hook output priority 0; policy drop;\n")
+	b.WriteString("\t\toifname \"lo\" accept\n")
+	b.WriteString("\t\tct state established,related accept\n")
+	if len(ip4) > 0 {
+		fmt.Fprintf(&b, "\t\tip daddr { %s } accept\n", strings.Join(ip4, ", "))
+	}
+	if len(ip6) > 0 {
+		fmt.Fprintf(&b, "\t\tip6 daddr { %s } accept\n", strings.Join(ip6, ", "))
+	}
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// run executes a command found in the setup PATH, with optional stdin.
+func (m *Setup) run(stdin []byte, name string, args ...string) error {
+	envPath := m.envPath
+	if envPath == "" {
+		envPath = defaultEnvPath
+	}
+
+	path := ""
+	for _, dir := range filepath.SplitList(envPath) {
+		p := filepath.Join(dir, name)
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() && fi.Mode()&0o111 != 0 {
+			path = p
+			break
+		}
+	}
+	if path == "" {
+		return fmt.Errorf("%s not found in %s", name, envPath)
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Env = []string{"PATH=" + envPath}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"net"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/sylabs/singularity/v4/internal/pkg/test"
+)
+
+func TestParseLimits(t *testing.T) {
+	mustCIDR := func(s string) *net.IPNet {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tests := []struct {
+		name          string
+		entries       []string
+		defaultRate   string
+		defaultEgress []string
+		want          *Limits
+		wantErr       bool
+	}{
+		{
+			name: "None",
+			want: nil,
+		},
+		{
+			name:    "Rate",
+			entries: []string{"rate=10mbit"},
+			want:    &Limits{Rate: 10000000},
+		},
+		{
+			name:    "RateBytesBinary",
+			entries: []string{"rate=2KiBps"},
+			want:    &Limits{Rate: 16384},
+		},
+		{
+			name:    "Egress",
+			entries: []string{"egress=10.0.0.0/8", "egress=fd00::/8", "egress=192.168.1.1"},
+			want: &Limits{Egress: []*net.IPNet{
+				mustCIDR("10.0.0.0/8"),
+				mustCIDR("fd00::/8"),
+				mustCIDR("192.168.1.1/32"),
+			}},
+		},
+		{
+			name:          "Defaults",
+			defaultRate:   "1gbit",
+			defaultEgress: []string{"10.0.0.0/8"},
+			want:          &Limits{Rate: 1000000000, Egress: []*net.IPNet{mustCIDR("10.0.0.0/8")}},
+		},
+		{
+			name:          "OverrideDefaults",
+			entries:       []string{"rate=1mbit", "egress=172.16.0.0/12"},
+			defaultRate:   "1gbit",
+			defaultEgress: []string{"10.0.0.0/8"},
+			want:          &Limits{Rate: 1000000, Egress: []*net.IPNet{mustCIDR("172.16.0.0/12")}},
+		},
+		{
+			name:    "BadRate",
+			entries: []string{"rate=fast"},
+			wantErr: true,
+		},
+		{
+			name:    "BadEgress",
+			entries: []string{"egress=10.0.0"},
+			wantErr: true,
+		},
+		{
+			name:    "UnknownKey",
+			entries: []string{"ingress=10.0.0.0/8"},
+			wantErr: true,
+		},
+		{
+			name:    "NoValue",
+			entries: []string{"rate"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLimits(tt.entries, tt.defaultRate, tt.defaultEgress)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLimits() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEgressRuleset(t *testing.T) {
+	_, n4, _ := net.ParseCIDR("10.0.0.0/8")
+	_, n6, _ := net.ParseCIDR("fd00::/8")
+
+	got := string(egressRuleset([]*net.IPNet{n4, n6}))
+	want := `table inet singularity {
+	chain egress {
+		type filter hook output priority 0; policy drop;
+		oifname "lo" accept
+		ct state established,related accept
+		ip daddr { 10.0.0.0/8 } accept
+		ip6 daddr { fd00::/8 } accept
+	}
+}
+`
+	if got != want {
+		t.Errorf("egressRuleset() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyLimitsRate(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	ipCmd := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			t.Fatalf("ip %s: %s: %s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	// A container network namespace with one end of a veth pair.
+	ipCmd("netns", "add", "sylimits")
+	t.Cleanup(func() { exec.Command("ip", "netns", "del", "sylimits").Run() })
+	ipCmd("link", "add", "sylimits0", "type", "veth", "peer", "name", "eth0", "netns", "sylimits")
+	t.Cleanup(func() { exec.Command("ip", "link", "del", "sylimits0").Run() })
+
+	m := &Setup{
+		netNS:       "/run/netns/sylimits",
+		runtimeConf: []*libcni.RuntimeConf{{IfName: "lo"}},
+		limits:      &Limits{Rate: 10000000},
+	}
+
+	// Rate limits require a veth interface.
+	if err := m.applyLimits(); err == nil {
+		t.Errorf("applyLimits() succeeded on a non veth interface")
+	}
+
+	m.runtimeConf = []*libcni.RuntimeConf{{IfName: "eth0"}}
+	if err := m.applyLimits(); err != nil {
+		if strings.Contains(err.Error(), "Failed to load TC action module") {
+			t.Skipf("kernel doesn't support tc police action: %v", err)
+		}
+		t.Fatalf("applyLimits() error = %v", err)
+	}
+
+	// The rate limit is applied on the host side of the veth pair.
+	out, err := exec.Command("tc", "filter", "show", "dev", "sylimits0", "ingress").CombinedOutput()
+	if err != nil {
+		t.Fatalf("tc filter show: %s: %s", err, out)
+	}
+	if !strings.Contains(string(out), "police") || !strings.Contains(string(out), "rate 10Mbit") {
+		t.Errorf("no rate limit on host interface, got filters:\n%s", out)
+	}
+}
@@ -52,6 +52,7 @@ type Setup struct {
 	containerID     string
 	netNS           string
 	envPath         string
+	limits          *Limits
 }
 
 // PortMapEntry describes a port mapping between host and container
@@ -478,6 +479,7 @@ func (m *Setup) command(ctx context.Context, command string) error {
 				return err
 			}
 		}
+		return m.applyLimits()
 	} else if command == "DEL" {
 		for i := 0; i < len(m.networkConfList); i++ {
 			if err := config.DelNetworkList(ctx, m.networkConfList[i], m.runtimeConf[i]); err != nil {
@@ -0,0 +1,433 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package lazy provides an experimental OCI bundle, whose rootfs is assembled
+// from the layers of a remote eStargz image without pulling them. The layers
+// are mounted by stargz-store, which fetches the file ranges that are
+// accessed from the registry on demand.
+package lazy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/v4/internal/pkg/ociplatform"
+	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+	"github.com/sylabs/singularity/v4/internal/pkg/remote/credential/ociauth"
+	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/config/oci/generate"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fs"
+	fsfuse "github.com/sylabs/singularity/v4/internal/pkg/util/fs/fuse"
+	"github.com/sylabs/singularity/v4/pkg/ocibundle"
+	"github.com/sylabs/singularity/v4/pkg/ocibundle/tools"
+	"github.com/sylabs/singularity/v4/pkg/sylog"
+)
+
+// tocDigestAnnotation is the annotation holding the digest of the table of
+// contents of an eStargz layer.
+const tocDigestAnnotation = "containerd.io/snapshot/stargz/toc.digest"
+
+// storeTimeout is how long to wait for stargz-store to mount its filesystem.
+const storeTimeout = 10 * time.Second
+
+// ErrUnsupported is returned by Create when the image cannot be lazily
+// pulled, and must be pulled in full instead.
+var ErrUnsupported = errors.New("image cannot be lazily pulled")
+
+// Bundle is an OCI bundle created from a remote eStargz image, whose layers
+// are fetched on demand.
+type Bundle struct {
+	// imageRef is the reference to the OCI image source, e.g. docker://ubuntu:latest.
+	imageRef string
+	// imageSpec is the OCI image information, CMD, ENTRYPOINT, etc.
+	imageSpec *imgspecv1.Image
+	// bundlePath is the location where the OCI bundle will be created.
+	bundlePath string
+	// transportOptions provides auth / platform etc. configuration for
+	// interactions with image transports.
+	transportOptions *ocitransport.TransportOptions
+	// tmpDir is the location for any temporary files that will be created outside of the
+	// assembled runtime bundle directory.
+	tmpDir string
+	// storeDir holds the mountpoint, cache and credentials of stargz-store.
+	// It is created during Create() and removed during Delete().
+	storeDir string
+	// store is the running stargz-store process.
+	store *exec.Cmd
+	// rootfsMounted is set to true when the layers have been mounted as the
+	// bundle rootfs.
+	rootfsMounted bool
+	ocibundle.Bundle
+}
+
+type Option func(b *Bundle) error
+
+// OptBundlePath sets the path that the bundle will be created at.
+func OptBundlePath(bp string) Option {
+	return func(b *Bundle) error {
+		var err error
+		b.bundlePath, err = filepath.Abs(bp)
+		if err != nil {
+			return fmt.Errorf("failed to determine bundle path: %s", err)
+		}
+		return nil
+	}
+}
+
+// OptImageRef sets the image source reference, from which the bundle will be
+// created. Only docker:// references are supported.
+func OptImageRef(ref string) Option {
+	return func(b *Bundle) error {
+		b.imageRef = ref
+		return nil
+	}
+}
+
+// OptTransportOptions sets configuration for interaction with image transports.
+func OptTransportOptions(tOpts *ocitransport.TransportOptions) Option {
+	return func(b *Bundle) error {
+		b.transportOptions = tOpts
+		return nil
+	}
+}
+
+// OptTmpDir sets the parent temporary directory for temporary files generated
+// outside of the assembled bundle.
+func OptTmpDir(tmpDir string) Option {
+	return func(b *Bundle) error {
+		b.tmpDir = tmpDir
+		return nil
+	}
+}
+
+// New returns a bundle interface to create/delete an OCI bundle from a remote
+// eStargz image.
+func New(opts ...Option) (ocibundle.Bundle, error) {
+	b := Bundle{
+		transportOptions: &ocitransport.TransportOptions{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(&b); err != nil {
+			return nil, fmt.Errorf("while initializing bundle: %w", err)
+		}
+	}
+
+	return &b, nil
+}
+
+// Delete unmounts the bundle rootfs, stops stargz-store, and erases the OCI
+// bundle.
+func (b *Bundle) Delete(ctx context.Context) error {
+	if b.rootfsMounted {
+		sylog.Debugf("Unmounting bundle rootfs %q", tools.RootFs(b.bundlePath).Path())
+		if err := syscall.Unmount(tools.RootFs(b.bundlePath).Path(), syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("while unmounting bundle rootfs: %w", err)
+		}
+		b.rootfsMounted = false
+	}
+
+	if err := b.stopStore(ctx); err != nil {
+		return err
+	}
+
+	return tools.DeleteBundle(b.bundlePath)
+}
+
+// Create will created the on-disk structures for the OCI bundle, so that it is
+// ready for execution. If the image cannot be lazily pulled, an error wrapping
+// ErrUnsupported is returned before anything is created.
+func (b *Bundle) Create(ctx context.Context, ociConfig *specs.Spec) (err error) {
+	ref, img, err := b.resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("error obtaining manifest: %w", err)
+	}
+	for _, l := range manifest.Layers {
+		if _, ok := l.Annotations[tocDigestAnnotation]; !ok {
+			return fmt.Errorf("%w: layer %s is not in eStargz format", ErrUnsupported, l.Digest)
+		}
+	}
+
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return fmt.Errorf("error obtaining image config: %w", err)
+	}
+	b.imageSpec = &imgspecv1.Image{}
+	if err := json.Unmarshal(rawConfig, b.imageSpec); err != nil {
+		return fmt.Errorf("error parsing image config: %w", err)
+	}
+
+	// generate OCI bundle directory and config
+	g, err := tools.GenerateBundleConfig(b.bundlePath, ociConfig)
+	if err != nil {
+		return fmt.Errorf("failed to generate OCI bundle/config: %s", err)
+	}
+
+	if err := b.startStore(ctx, ref.Context().RegistryStr()); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if stopErr := b.stopStore(ctx); stopErr != nil {
+				sylog.Errorf("Couldn't stop stargz-store: %v", stopErr)
+			}
+		}
+	}()
+
+	// Lower directories of an overlay mount are listed from the top layer down.
+	lowerDirs := make([]string, len(manifest.Layers))
+	for i, l := range manifest.Layers {
+		dir := storeLayerPath(filepath.Join(b.storeDir, "mnt"), ref, l.Digest)
+		// Accessing the layer makes stargz-store fetch its table of contents.
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("while mounting layer %s: %w", l.Digest, err)
+		}
+		lowerDirs[len(lowerDirs)-1-i] = dir
+	}
+
+	bundleRootfs := tools.RootFs(b.bundlePath).Path()
+	if err := os.Mkdir(bundleRootfs, 0o755); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	// An overlay requires at least two lower directories, so the empty bundle
+	// rootfs is always added as the bottom one.
+	options := "lowerdir=" + strings.Join(append(lowerDirs, bundleRootfs), ":")
+	sylog.Debugf("Mounting lazily pulled layers on bundle rootfs %q, options: %q", bundleRootfs, options)
+	if err := syscall.Mount("overlay", bundleRootfs, "overlay", syscall.MS_RDONLY|syscall.MS_NODEV|syscall.MS_NOSUID, options); err != nil {
+		return fmt.Errorf("failed to mount overlay of layers on %q: %w", bundleRootfs, err)
+	}
+	b.rootfsMounted = true
+
+	return b.writeConfig(g)
+}
+
+// Update will update the OCI config for the OCI bundle, so that it is ready for
+// execution.
+// Context argument isn't used here, but is part of the Bundle interface which
+// is defined under pkg/, so changing this API is not possible until next major
+// version.
+func (b *Bundle) Update(_ context.Context, ociConfig *specs.Spec) error {
+	// generate OCI bundle directory and config
+	g, err := tools.GenerateBundleConfig(b.bundlePath, ociConfig)
+	if err != nil {
+		return fmt.Errorf("failed to generate OCI bundle/config: %s", err)
+	}
+	return b.writeConfig(g)
+}
+
+// ImageSpec returns the OCI image spec associated with the bundle.
+func (b *Bundle) ImageSpec() (imgSpec *imgspecv1.Image) {
+	return b.imageSpec
+}
+
+// Path returns the bundle's path on disk.
+func (b *Bundle) Path() string {
+	return b.bundlePath
+}
+
+func (b *Bundle) writeConfig(g *generate.Generator) error {
+	return tools.SaveBundleConfig(b.bundlePath, g)
+}
+
+// resolve resolves the image reference to the image manifest for the
+// requested platform, returning a reference pinned to its digest, so that
+// stargz-store serves the same image.
+func (b *Bundle) resolve(ctx context.Context) (name.Digest, ggcrv1.Image, error) {
+	if !strings.HasPrefix(b.imageRef, "docker:") {
+		return name.Digest{}, nil, fmt.Errorf("%w: only docker:// images are supported", ErrUnsupported)
+	}
+	if b.transportOptions.Insecure {
+		return name.Digest{}, nil, fmt.Errorf("%w: insecure registries are not supported", ErrUnsupported)
+	}
+
+	ref := strings.TrimPrefix(strings.TrimPrefix(b.imageRef, "docker:"), "//")
+	ir, err := name.ParseReference(ref)
+	if err != nil {
+		return name.Digest{}, nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+
+	platform := b.transportOptions.Platform
+	if platform.OS == "" {
+		dp, err := ociplatform.DefaultPlatform()
+		if err != nil {
+			return name.Digest{}, nil, err
+		}
+		platform = *dp
+	}
+
+	remoteOpts := []remote.Option{
+		ociauth.AuthOptn(b.transportOptions.AuthConfig, b.transportOptions.AuthFilePath),
+		remote.WithContext(ctx),
+		remote.WithPlatform(platform),
+	}
+	if b.transportOptions.UserAgent != "" {
+		remoteOpts = append(remoteOpts, remote.WithUserAgent(b.transportOptions.UserAgent))
+	}
+
+	img, err := remote.Image(ir, remoteOpts...)
+	if err != nil {
+		return name.Digest{}, nil, fmt.Errorf("while resolving %s: %w", ref, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return name.Digest{}, nil, err
+	}
+	return ir.Context().Digest(digest.String()), img, nil
+}
+
+// startStore starts stargz-store, with the credentials for registry, and
+// waits for its filesystem to be mounted.
+func (b *Bundle) startStore(ctx context.Context, registry string) (err error) {
+	storeBin, err := bin.FindBin("stargz-store")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	b.storeDir, err = os.MkdirTemp(b.tmpDir, "lazy-store-")
+	if err != nil {
+		return err
+	}
+	mnt := filepath.Join(b.storeDir, "mnt")
+	for _, d := range []string{mnt, filepath.Join(b.storeDir, "root"), filepath.Join(b.storeDir, "docker")} {
+		if err := os.Mkdir(d, 0o700); err != nil {
+			return err
+		}
+	}
+	if err := b.writeStoreAuth(filepath.Join(b.storeDir, "docker", "config.json"), registry); err != nil {
+		return fmt.Errorf("while writing stargz-store credentials: %w", err)
+	}
+
+	logFile, err := os.Create(filepath.Join(b.storeDir, "store.log"))
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	b.store = exec.Command(storeBin, "--root", filepath.Join(b.storeDir, "root"), mnt)
+	b.store.Env = append(os.Environ(), "DOCKER_CONFIG="+filepath.Join(b.storeDir, "docker"))
+	b.store.Stdout = logFile
+	b.store.Stderr = logFile
+	sylog.Debugf("Starting %s, logging to %s", b.store, logFile.Name())
+	if err := b.store.Start(); err != nil {
+		return fmt.Errorf("while starting stargz-store: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- b.store.Wait()
+	}()
+
+	deadline := time.Now().Add(storeTimeout)
+	for {
+		if mounted, err := isMounted(mnt); err != nil {
+			return err
+		} else if mounted {
+			return nil
+		}
+		select {
+		case err := <-exited:
+			b.store = nil
+			log, _ := os.ReadFile(logFile.Name())
+			return fmt.Errorf("stargz-store exited: %v: %s", err, log)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("stargz-store did not mount %s within %s", mnt, storeTimeout)
+		}
+	}
+}
+
+// writeStoreAuth writes the docker config file read by stargz-store. Explicit
+// credentials are written for registry, otherwise the auth file used by
+// singularity is linked.
+func (b *Bundle) writeStoreAuth(path, registry string) error {
+	if ac := b.transportOptions.AuthConfig; ac != nil {
+		auth := ac.Auth
+		if auth == "" {
+			auth = base64.StdEncoding.EncodeToString([]byte(ac.Username + ":" + ac.Password))
+		}
+		cfg := map[string]map[string]map[string]string{
+			"auths": {registry: {"auth": auth}},
+		}
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o600)
+	}
+
+	authFile := ociauth.ChooseAuthFile(b.transportOptions.AuthFilePath)
+	if !fs.IsFile(authFile) {
+		return nil
+	}
+	return os.Symlink(authFile, path)
+}
+
+// stopStore unmounts the stargz-store filesystem, waits for stargz-store to
+// exit, and removes its directory.
+func (b *Bundle) stopStore(ctx context.Context) error {
+	if b.store != nil {
+		mnt := filepath.Join(b.storeDir, "mnt")
+		if err := fsfuse.UnmountWithFuse(ctx, mnt); err != nil {
+			sylog.Debugf("While unmounting %s: %v", mnt, err)
+			b.store.Process.Signal(syscall.SIGTERM)
+		}
+		b.store = nil
+	}
+
+	if b.storeDir != "" {
+		sylog.Debugf("Removing stargz-store directory %q", b.storeDir)
+		if err := fs.ForceRemoveAll(b.storeDir); err != nil {
+			return fmt.Errorf("while removing stargz-store directory %q: %w", b.storeDir, err)
+		}
+		b.storeDir = ""
+	}
+	return nil
+}
+
+// storeLayerPath returns the directory holding the content of the layer dgst
+// of the image ref, in the stargz-store filesystem mounted at mnt. It follows
+// the layout of a containers/storage additional layer store.
+func storeLayerPath(mnt string, ref name.Digest, dgst ggcrv1.Hash) string {
+	registry := ref.RegistryStr()
+	if registry == name.DefaultRegistry {
+		registry = "docker.io"
+	}
+	storeRef := registry + "/" + ref.RepositoryStr() + "@" + ref.DigestStr()
+	return filepath.Join(mnt, base64.StdEncoding.EncodeToString([]byte(storeRef)), dgst.String(), "diff")
+}
+
+// isMounted returns whether path is a mountpoint.
+func isMounted(path string) (bool, error) {
+	var st, parent syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return false, err
+	}
+	if err := syscall.Stat(filepath.Dir(path), &parent); err != nil {
+		return false, err
+	}
+	return st.Dev != parent.Dev, nil
+}
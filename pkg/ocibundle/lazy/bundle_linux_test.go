@@ -0,0 +1,92 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package lazy
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sylabs/singularity/v4/internal/pkg/ocitransport"
+)
+
+const testDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+
+func TestStoreLayerPath(t *testing.T) {
+	layer := ggcrv1.Hash{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000002"}
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantRef string
+	}{
+		{
+			name:    "DockerHub",
+			ref:     "alpine@" + testDigest,
+			wantRef: "docker.io/library/alpine@" + testDigest,
+		},
+		{
+			name:    "Registry",
+			ref:     "ghcr.io/stargz-containers/python@" + testDigest,
+			wantRef: "ghcr.io/stargz-containers/python@" + testDigest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := name.NewDigest(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := storeLayerPath("/mnt", ref, layer)
+			want := filepath.Join("/mnt", base64.StdEncoding.EncodeToString([]byte(tt.wantRef)), layer.String(), "diff")
+			if got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCreateUnsupported(t *testing.T) {
+	tests := []struct {
+		name  string
+		ref   string
+		tOpts *ocitransport.TransportOptions
+	}{
+		{
+			name:  "OCILayout",
+			ref:   "oci:/tmp/layout",
+			tOpts: &ocitransport.TransportOptions{},
+		},
+		{
+			name:  "Insecure",
+			ref:   "docker://localhost:5000/alpine",
+			tOpts: &ocitransport.TransportOptions{Insecure: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(
+				OptBundlePath(t.TempDir()),
+				OptImageRef(tt.ref),
+				OptTransportOptions(tt.tOpts),
+				OptTmpDir(t.TempDir()),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = b.Create(context.Background(), nil)
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("got error %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
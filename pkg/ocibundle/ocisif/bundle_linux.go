@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -20,12 +21,19 @@ import (
 	ocisif "github.com/sylabs/oci-tools/pkg/sif"
 	"github.com/sylabs/sif/v2/pkg/sif"
 	ociclient "github.com/sylabs/singularity/v4/internal/pkg/client/ocisif"
+	"github.com/sylabs/singularity/v4/internal/pkg/image/variant"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/config/oci/generate"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/crypt"
+	fsmount "github.com/sylabs/singularity/v4/internal/pkg/util/fs/mount"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/overlay"
 	"github.com/sylabs/singularity/v4/internal/pkg/util/fs/squashfs"
 	"github.com/sylabs/singularity/v4/pkg/ocibundle"
 	"github.com/sylabs/singularity/v4/pkg/ocibundle/tools"
 	"github.com/sylabs/singularity/v4/pkg/sylog"
+	"github.com/sylabs/singularity/v4/pkg/util/cryptkey"
+	"github.com/sylabs/singularity/v4/pkg/util/loop"
+	"github.com/sylabs/singularity/v4/pkg/util/namespaces"
+	"golang.org/x/sys/unix"
 )
 
 // UnavailableError is used to wrap an Underlying error, while indicating that
@@ -52,6 +60,16 @@ type Bundle struct {
 	bundlePath string
 	// paths to squashfs layers that have been mounted
 	mountedLayers []string
+	// crypt device names for encrypted layers, keyed by layer mount path
+	cryptLayers map[string]string
+	// layer mount paths mounted with the kernel squashfs driver
+	kernelLayers map[string]bool
+	// Should layers be mounted with the kernel squashfs driver, when permitted?
+	kernelMount bool
+	// keyInfo holds the key material used to open encrypted layers
+	keyInfo *cryptkey.KeyInfo
+	// Does the image have encrypted layers?
+	encrypted bool
 	// assembled rootfs, from overlay mount of mountedLayers
 	rootfsOverlaySet overlay.Set
 	// Has the image been mounted onto the bundle rootfs?
@@ -82,10 +100,30 @@ func OptImageRef(ref string) Option {
 	}
 }
 
+// OptKeyInfo sets the key material used to decrypt encrypted layers.
+func OptKeyInfo(ki *cryptkey.KeyInfo) Option {
+	return func(b *Bundle) error {
+		b.keyInfo = ki
+		return nil
+	}
+}
+
+// OptKernelMount sets whether squashfs layers are mounted with the kernel
+// squashfs driver, through loop devices, when running with root privileges
+// outside of a user namespace. Layers are mounted with squashfuse otherwise.
+func OptKernelMount(k bool) Option {
+	return func(b *Bundle) error {
+		b.kernelMount = k
+		return nil
+	}
+}
+
 // New returns a bundle interface to create/delete an OCI bundle from an oci-sif image ref.
 func New(opts ...Option) (ocibundle.Bundle, error) {
 	b := Bundle{
-		imageRef: "",
+		imageRef:     "",
+		cryptLayers:  map[string]string{},
+		kernelLayers: map[string]bool{},
 	}
 
 	for _, opt := range opts {
@@ -111,6 +149,18 @@ func (b *Bundle) Delete(ctx context.Context) error {
 
 	for _, layerPath := range b.mountedLayers {
 		sylog.Debugf("Unmounting layer fs from %q", layerPath)
+		if cryptName, ok := b.cryptLayers[layerPath]; ok {
+			if err := unmountEncrypted(layerPath, cryptName); err != nil {
+				return err
+			}
+			continue
+		}
+		if b.kernelLayers[layerPath] {
+			if err := syscall.Unmount(layerPath, 0); err != nil {
+				return fmt.Errorf("while unmounting %s: %w", layerPath, err)
+			}
+			continue
+		}
 		if err := squashfs.FUSEUnmount(ctx, layerPath); err != nil {
 			return err
 		}
@@ -128,7 +178,8 @@ func (b *Bundle) Create(ctx context.Context, ociConfig *specs.Spec) error {
 	}
 
 	// Retrieve and check the index manifest, which lists the images in the oci-sif file.
-	// We currently only support oci-sif files containing exactly 1 image.
+	// Where the oci-sif file holds variants of an image, for different node
+	// hardware, the variant matching the local node is used.
 	fi, err := sif.LoadContainerFromPath(imgFile, sif.OptLoadWithFlag(os.O_RDONLY))
 	if err != nil {
 		return fmt.Errorf("while loading SIF: %w", err)
@@ -141,10 +192,21 @@ func (b *Bundle) Create(ctx context.Context, ociConfig *specs.Spec) error {
 	if err != nil {
 		return fmt.Errorf("while obtaining index manifest: %w", err)
 	}
-	if len(idxManifest.Manifests) != 1 {
-		return fmt.Errorf("only single image oci-sif files are supported")
+	if len(idxManifest.Manifests) == 0 {
+		return fmt.Errorf("oci-sif file contains no images")
 	}
-	imageDigest := idxManifest.Manifests[0].Digest
+	desc := idxManifest.Manifests[0]
+	if len(idxManifest.Manifests) > 1 {
+		node, err := variant.LocalNode()
+		if err != nil {
+			return err
+		}
+		if desc, err = variant.SelectDescriptor(idxManifest.Manifests, node); err != nil {
+			return err
+		}
+		sylog.Infof("Selected image %s from oci-sif file for this node (%s)", desc.Digest, node)
+	}
+	imageDigest := desc.Digest
 
 	img, err := ix.Image(imageDigest)
 	if err != nil {
@@ -178,6 +240,10 @@ func (b *Bundle) Create(ctx context.Context, ociConfig *specs.Spec) error {
 		if errCleanup := b.Delete(ctx); errCleanup != nil {
 			sylog.Errorf("While removing temporary bundle: %v", errCleanup)
 		}
+		// Encrypted layers can't be extracted, so there is no fall-back.
+		if b.encrypted {
+			return fmt.Errorf("while mounting encrypted squashfs layer: %w", err)
+		}
 		return UnavailableError{Underlying: fmt.Errorf("while mounting squashfs layer: %w", err)}
 	}
 
@@ -190,19 +256,68 @@ func (b *Bundle) mountRootfs(ctx context.Context, img v1.Image, imgFile string)
 		return fmt.Errorf("while obtaining manifest: %s", err)
 	}
 
+	var key []byte
+	for _, l := range imageManifest.Layers {
+		if l.MediaType == ociclient.EncryptedSquashfsLayerMediaType {
+			b.encrypted = true
+			if key, err = b.layerKey(img); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	// Layers are mounted on distinct directories, so are mounted concurrently.
+	// Results are recorded per layer, and gathered once all mounts are done.
+	layerPaths := make([]string, len(imageManifest.Layers))
+	cryptNames := make([]string, len(imageManifest.Layers))
+	kernelMounted := make([]bool, len(imageManifest.Layers))
+	tasks := make([]fsmount.Task, len(imageManifest.Layers))
 	for i, l := range imageManifest.Layers {
-		if l.MediaType != ociclient.SquashfsLayerMediaType {
+		i, l := i, l
+		if l.MediaType != ociclient.SquashfsLayerMediaType && l.MediaType != ociclient.EncryptedSquashfsLayerMediaType {
 			return fmt.Errorf("unsupported layer mediaType %q", l.MediaType)
 		}
 		layerPath := filepath.Join(tools.Layers(b.bundlePath).Path(), strconv.Itoa(i))
-		sylog.Debugf("Mounting layer %d fs from %q to %q", i, imgFile, layerPath)
 		if err := os.Mkdir(layerPath, 0o755); err != nil {
 			return fmt.Errorf("while creating layer directory: %w", err)
 		}
-		if err := mount(ctx, imgFile, layerPath, l.Digest); err != nil {
-			return UnavailableError{Underlying: fmt.Errorf("while mounting squashfs layer: %w", err)}
+		layerPaths[i] = layerPath
+		tasks[i] = fsmount.Task{
+			Target: layerPath,
+			Mount: func(ctx context.Context) (err error) {
+				sylog.Debugf("Mounting layer %d fs from %q to %q", i, imgFile, layerPath)
+				if l.MediaType == ociclient.EncryptedSquashfsLayerMediaType {
+					cryptNames[i], err = mountEncrypted(imgFile, layerPath, l.Digest, key)
+					if err != nil {
+						return fmt.Errorf("while mounting encrypted squashfs layer: %w", err)
+					}
+					return nil
+				}
+				kernelMounted[i], err = b.mountLayer(ctx, imgFile, layerPath, l.Digest)
+				if err != nil {
+					return UnavailableError{Underlying: fmt.Errorf("while mounting squashfs layer: %w", err)}
+				}
+				return nil
+			},
+		}
+	}
+
+	mounted, err := fsmount.MountParallel(ctx, tasks, fsmount.ParallelWorkers())
+	for i, ok := range mounted {
+		if !ok {
+			continue
+		}
+		b.mountedLayers = append(b.mountedLayers, layerPaths[i])
+		if cryptNames[i] != "" {
+			b.cryptLayers[layerPaths[i]] = cryptNames[i]
+		}
+		if kernelMounted[i] {
+			b.kernelLayers[layerPaths[i]] = true
 		}
-		b.mountedLayers = append(b.mountedLayers, layerPath)
+	}
+	if err != nil {
+		return err
 	}
 
 	for i := len(b.mountedLayers) - 1; i >= 0; i-- {
@@ -261,6 +376,64 @@ func (b *Bundle) imageFile() (path string, err error) {
 	return parts[1], nil
 }
 
+// mountLayer mounts the squashfs layer blob with digest at mountPath, with the
+// kernel squashfs driver if enabled and permitted, or else with squashfuse.
+// It returns whether the kernel driver was used.
+func (b *Bundle) mountLayer(ctx context.Context, path, mountPath string, digest v1.Hash) (bool, error) {
+	if b.kernelMount {
+		var err error
+		switch {
+		case canKernelMount():
+			err = mountKernel(path, mountPath, digest)
+		case squashfs.KernelMountAvailable():
+			err = mountKernelStarter(path, mountPath, digest)
+		default:
+			return false, mount(ctx, path, mountPath, digest)
+		}
+		if err == nil {
+			return true, nil
+		}
+		sylog.Warningf("Kernel mount of squashfs layer %s failed, falling back to squashfuse: %v", digest, err)
+	}
+	return false, mount(ctx, path, mountPath, digest)
+}
+
+// canKernelMount returns whether squashfs filesystems can be mounted with the
+// kernel driver, which requires root privileges in the initial user namespace.
+// Unprivileged users of setuid installations, run in a user namespace, may
+// request kernel mounts from the starter instead (see mountKernelStarter).
+func canKernelMount() bool {
+	if os.Geteuid() != 0 {
+		return false
+	}
+	insideUserNs, _ := namespaces.IsInsideUserNamespace(os.Getpid())
+	return !insideUserNs
+}
+
+// mountKernelStarter requests the setuid starter to mount the layer blob with
+// digest at mountPath with the kernel squashfs driver. The image file is
+// opened, and passed to the starter, with the privileges of the user.
+func mountKernelStarter(path, mountPath string, digest v1.Hash) error {
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+	defer func() { _ = f.UnloadContainer() }()
+
+	d, err := f.GetDescriptor(sif.WithOCIBlobDigest(digest))
+	if err != nil {
+		return fmt.Errorf("failed to get partition descriptor: %w", err)
+	}
+
+	img, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	return squashfs.KernelMount(img, uint64(d.Offset()), uint64(d.Size()), mountPath)
+}
+
 func mount(ctx context.Context, path, mountPath string, digest v1.Hash) error {
 	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
 	if err != nil {
@@ -277,3 +450,112 @@ func mount(ctx context.Context, path, mountPath string, digest v1.Hash) error {
 
 	return err
 }
+
+// layerKey returns the key used to open the encrypted layers of img.
+func (b *Bundle) layerKey(img v1.Image) ([]byte, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("encrypted OCI-SIF images can only be run as root")
+	}
+	keyInfo := b.keyInfo
+	if keyInfo == nil {
+		// The image may record the key URI that unwraps its key.
+		ki, err := ociclient.LayerKeyInfo(img)
+		if err != nil {
+			return nil, fmt.Errorf("no key was provided, cannot access encrypted container")
+		}
+		sylog.Verbosef("Using key URI %s recorded in image", ki.Path)
+		keyInfo = ki
+	}
+	key, err := ociclient.LayerKey(img, *keyInfo)
+	if err != nil {
+		sylog.Errorf("Please check you are providing the correct key for decryption")
+		return nil, fmt.Errorf("cannot decrypt %s: %w", b.imageRef, err)
+	}
+	return key, nil
+}
+
+// mountKernel attaches the layer blob with digest to a loop device, and mounts
+// it at mountPath with the kernel squashfs driver.
+func mountKernel(path, mountPath string, digest v1.Hash) error {
+	loopDev, idx, err := attachLayer(path, digest)
+	if err != nil {
+		return err
+	}
+	// The loop device is cleared automatically once the squashfs filesystem
+	// is unmounted.
+	defer loopDev.Close()
+
+	flags := uintptr(syscall.MS_RDONLY | syscall.MS_NODEV | syscall.MS_NOSUID)
+	if err := syscall.Mount(fmt.Sprintf("/dev/loop%d", idx), mountPath, "squashfs", flags, ""); err != nil {
+		return fmt.Errorf("while mounting squashfs: %w", err)
+	}
+	return nil
+}
+
+// attachLayer attaches the layer blob with digest, in the OCI-SIF file at
+// path, to a read-only loop device, which is cleared automatically when no
+// longer in use. The loop device and its index are returned.
+func attachLayer(path string, digest v1.Hash) (*loop.Device, int, error) {
+	f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load image: %w", err)
+	}
+	defer func() { _ = f.UnloadContainer() }()
+
+	d, err := f.GetDescriptor(sif.WithOCIBlobDigest(digest))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get partition descriptor: %w", err)
+	}
+
+	loopDev := &loop.Device{
+		MaxLoopDevices: loop.GetMaxLoopDevices(),
+		Shared:         true,
+		Info: &unix.LoopInfo64{
+			Offset:    uint64(d.Offset()),
+			Sizelimit: uint64(d.Size()),
+			Flags:     unix.LO_FLAGS_AUTOCLEAR | unix.LO_FLAGS_READ_ONLY,
+		},
+	}
+	idx := 0
+	if err := loopDev.AttachFromPath(path, os.O_RDONLY, &idx); err != nil {
+		return nil, 0, fmt.Errorf("failed to attach image %s: %w", path, err)
+	}
+	return loopDev, idx, nil
+}
+
+// mountEncrypted attaches the encrypted layer blob with digest to a loop
+// device, opens it with cryptsetup, and mounts the resulting squashfs at
+// mountPath. The crypt device name is returned, for use in cleanup.
+func mountEncrypted(path, mountPath string, digest v1.Hash, key []byte) (string, error) {
+	loopDev, idx, err := attachLayer(path, digest)
+	if err != nil {
+		return "", err
+	}
+	// The loop device is cleared automatically once the crypt device that
+	// holds it is closed.
+	defer loopDev.Close()
+
+	cryptDev := &crypt.Device{}
+	cryptName, err := cryptDev.Open(key, fmt.Sprintf("/dev/loop%d", idx))
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt the file system: %w", err)
+	}
+
+	flags := uintptr(syscall.MS_RDONLY | syscall.MS_NODEV | syscall.MS_NOSUID)
+	if err := syscall.Mount("/dev/mapper/"+cryptName, mountPath, "squashfs", flags, ""); err != nil {
+		if err := cryptDev.CloseCryptDevice(cryptName); err != nil {
+			sylog.Errorf("While closing crypt device %s: %v", cryptName, err)
+		}
+		return "", fmt.Errorf("while mounting decrypted squashfs: %w", err)
+	}
+
+	return cryptName, nil
+}
+
+func unmountEncrypted(mountPath, cryptName string) error {
+	if err := syscall.Unmount(mountPath, 0); err != nil {
+		return fmt.Errorf("while unmounting %s: %w", mountPath, err)
+	}
+	cryptDev := &crypt.Device{}
+	return cryptDev.CloseCryptDevice(cryptName)
+}
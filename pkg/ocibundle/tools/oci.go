@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"syscall"
 
+	lcuser "github.com/opencontainers/runc/libcontainer/user"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/config/oci"
 	"github.com/sylabs/singularity/v4/internal/pkg/runtime/engine/config/oci/generate"
@@ -130,6 +131,15 @@ func DeleteBundle(bundlePath string) error {
 	return nil
 }
 
+// BundleExecUser resolves a user specification of the form user[:group], as
+// found in the USER of an OCI image config, against the bundle passwd and group
+// files. Numeric ids that don't exist in these files are used as is, with a
+// default gid of 0.
+func BundleExecUser(bundlePath, userSpec string) (*lcuser.ExecUser, error) {
+	etc := filepath.Join(RootFs(bundlePath).Path(), "etc")
+	return lcuser.GetExecUserPath(userSpec, nil, filepath.Join(etc, "passwd"), filepath.Join(etc, "group"))
+}
+
 // BundleUser returns a user struct for the specified user, from the bundle passwd file.
 func BundleUser(bundlePath, user string) (u *user.User, err error) {
 	passwd := filepath.Join(RootFs(bundlePath).Path(), "etc", "passwd")
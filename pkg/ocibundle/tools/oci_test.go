@@ -0,0 +1,60 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundleExecUser(t *testing.T) {
+	bundle := t.TempDir()
+	etc := filepath.Join(RootFs(bundle).Path(), "etc")
+	if err := os.MkdirAll(etc, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	passwd := "root:x:0:0:root:/root:/bin/sh\ntestuser:x:2000:2000::/home/testuser:/bin/sh\n"
+	if err := os.WriteFile(filepath.Join(etc, "passwd"), []byte(passwd), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	group := "root:x:0:\ntestgroup:x:2000:\nother:x:3000:testuser\n"
+	if err := os.WriteFile(filepath.Join(etc, "group"), []byte(group), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		userSpec string
+		wantUID  int
+		wantGID  int
+		wantErr  bool
+	}{
+		{name: "Name", userSpec: "testuser", wantUID: 2000, wantGID: 2000},
+		{name: "UID", userSpec: "2000", wantUID: 2000, wantGID: 2000},
+		{name: "NameGroup", userSpec: "testuser:other", wantUID: 2000, wantGID: 3000},
+		{name: "UIDGID", userSpec: "2000:0", wantUID: 2000, wantGID: 0},
+		{name: "UnknownUID", userSpec: "4000", wantUID: 4000, wantGID: 0},
+		{name: "UnknownUIDGID", userSpec: "4000:4001", wantUID: 4000, wantGID: 4001},
+		{name: "UnknownName", userSpec: "nosuchuser", wantErr: true},
+		{name: "UnknownGroup", userSpec: "testuser:nosuchgroup", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := BundleExecUser(bundle, tt.userSpec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BundleExecUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if u.Uid != tt.wantUID || u.Gid != tt.wantGID {
+				t.Errorf("BundleExecUser() = %d:%d, want %d:%d", u.Uid, u.Gid, tt.wantUID, tt.wantGID)
+			}
+		})
+	}
+}
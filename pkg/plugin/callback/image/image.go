@@ -0,0 +1,48 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnsupported is returned by the methods of an ImageTransport for
+// operations it does not support.
+var ErrUnsupported = errors.New("operation not supported by transport")
+
+// TransportOptions holds the options, set from the command line, passed to
+// an ImageTransport.
+type TransportOptions struct {
+	// TmpDir is the directory used for temporary files.
+	TmpDir string
+	// NoHTTPS requests that TLS is not used.
+	NoHTTPS bool
+	// AuthFile is the path of an authentication file set with --authfile.
+	AuthFile string
+}
+
+// ImageTransport retrieves images from, and pushes images to, URIs with the
+// schemes it is registered for. URIs are passed to its methods in full,
+// including the scheme, e.g. s3://bucket/image.sif.
+type ImageTransport interface {
+	// Pull retrieves the image at ref to the file dst.
+	Pull(ctx context.Context, ref, dst string, opts TransportOptions) error
+	// Push pushes the image file src to ref, or returns ErrUnsupported.
+	Push(ctx context.Context, src, ref string, opts TransportOptions) error
+	// Digest returns a string identifying the current content of the image
+	// at ref, such as a checksum, or returns ErrUnsupported. Images pulled
+	// to run them are cached by digest, and are not cached if the digest is
+	// not supported.
+	Digest(ctx context.Context, ref string, opts TransportOptions) (string, error)
+}
+
+// Transport callback allows plugins to add image transports for new URI
+// schemes, such as s3:// or cvmfs://. This callback is called in
+// cmd/internal/cli/singularity.go, and the ImageTransport returned is used by
+// the pull, push and action commands for URIs with the returned schemes.
+// Schemes already handled by Singularity cannot be overridden.
+type Transport func() (schemes []string, t ImageTransport)
@@ -0,0 +1,39 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+	singularityConfig "github.com/sylabs/singularity/v4/pkg/runtime/engine/singularity/config"
+)
+
+// NativeConfig callback allows plugins to inspect and modify the engine
+// configuration of a container run by the native launcher, once it has
+// been composed and before the container is started. This callback is
+// called in internal/pkg/runtime/launcher/native/launcher_linux.go, for
+// action commands and instance start, with image set to the container
+// image. It is the place to enforce site policy:
+//   - binds are held by cfg.GetBindPath() / cfg.SetBindPath()
+//   - the host environment passed to the container is held by
+//     cfg.OciConfig.Process.Env, and SINGULARITYENV_ variables by
+//     cfg.GetSingularityEnv() / cfg.SetSingularityEnv()
+//   - cgroups resource limits are held, as JSON, by
+//     cfg.GetCgroupsJSON() / cfg.SetCgroupsJSON()
+//
+// Returning an error aborts the execution of the container.
+type NativeConfig func(image string, cfg *singularityConfig.EngineConfig) error
+
+// OCISpec callback allows plugins to inspect and modify the runtime spec
+// of a container run by the OCI launcher, once it has been composed and
+// before the container is started. This callback is called in
+// internal/pkg/runtime/launcher/oci/launcher_linux.go, for action commands,
+// with image set to the container image. Mounts, process environment and
+// resource limits are held by spec.Mounts, spec.Process.Env and
+// spec.Linux.Resources. OCI hooks are added to the spec after this
+// callback is called, so that their conditions apply to the modified spec.
+//
+// Returning an error aborts the execution of the container.
+type OCISpec func(image string, spec *specs.Spec) error
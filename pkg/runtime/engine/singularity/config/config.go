@@ -59,7 +59,7 @@ type FuseMount struct {
 	Fd            int       `json:"fd,omitempty"`            // /dev/fuse file descriptor
 	FromContainer bool      `json:"fromContainer,omitempty"` // is FUSE driver program is run from container or from host
 	Daemon        bool      `json:"daemon,omitempty"`        // is FUSE driver program is run in daemon/background mode
-	Cmd           *exec.Cmd `json:"-"`                       // holds the process exec command when FUSE driver run in foreground mode
+	Cmd           *exec.Cmd `json:"-"`                       // Deprecated: FUSE drivers run in foreground mode are supervised by the engine, and Cmd is no longer set
 }
 
 type UserInfo struct {
@@ -77,19 +77,26 @@ type JSONConfig struct {
 	ScratchDir            []string          `json:"scratchdir,omitempty"`
 	OverlayImage          []string          `json:"overlayImage,omitempty"`
 	NetworkArgs           []string          `json:"networkArgs,omitempty"`
+	NetworkLimits         []string          `json:"networkLimits,omitempty"`
 	Security              []string          `json:"security,omitempty"`
 	FilesPath             []string          `json:"filesPath,omitempty"`
 	LibrariesPath         []string          `json:"librariesPath,omitempty"`
+	MPISonames            []string          `json:"mpiSonames,omitempty"`
 	FuseMount             []FuseMount       `json:"fuseMount,omitempty"`
 	ImageList             []image.Image     `json:"imageList,omitempty"`
 	BindPath              []bind.Path       `json:"bindpath,omitempty"`
 	SingularityEnv        map[string]string `json:"singularityEnv,omitempty"`
+	MetadataEnv           map[string]string `json:"metadataEnv,omitempty"`
+	MetadataFiles         map[string]string `json:"metadataFiles,omitempty"`
 	UnixSocketPair        [2]int            `json:"unixSocketPair,omitempty"`
 	OpenFd                []int             `json:"openFd,omitempty"`
 	TargetGID             []int             `json:"targetGID,omitempty"`
 	Image                 string            `json:"image"`
 	Workdir               string            `json:"workdir,omitempty"`
+	LicenseOverride       string            `json:"licenseOverride,omitempty"`
 	CgroupsJSON           string            `json:"cgroupsJSON,omitempty"`
+	CgroupStats           bool              `json:"cgroupStats,omitempty"`
+	VirtualProc           bool              `json:"virtualProc,omitempty"`
 	HomeSource            string            `json:"homedir,omitempty"`
 	HomeDest              string            `json:"homeDest,omitempty"`
 	Command               string            `json:"command,omitempty"`
@@ -100,6 +107,8 @@ type JSONConfig struct {
 	Hostname              string            `json:"hostname,omitempty"`
 	Network               string            `json:"network,omitempty"`
 	DNS                   string            `json:"dns,omitempty"`
+	DNSSearch             string            `json:"dnsSearch,omitempty"`
+	AddHosts              []string          `json:"addHosts,omitempty"`
 	Cwd                   string            `json:"cwd,omitempty"`
 	SessionLayer          string            `json:"sessionLayer,omitempty"`
 	ConfigurationFile     string            `json:"configurationFile,omitempty"`
@@ -107,15 +116,25 @@ type JSONConfig struct {
 	TargetUID             int               `json:"targetUID,omitempty"`
 	WritableImage         bool              `json:"writableImage,omitempty"`
 	WritableTmpfs         bool              `json:"writableTmpfs,omitempty"`
+	WritableTmpfsSize     uint              `json:"writableTmpfsSize,omitempty"`
+	TmpSize               uint              `json:"tmpSize,omitempty"`
+	ShmSize               uint              `json:"shmSize,omitempty"`
 	Contain               bool              `json:"container,omitempty"`
 	NvLegacy              bool              `json:"nvLegacy,omitempty"`
+	NvLegacyDevices       []string          `json:"nvLegacyDevices,omitempty"`
+	CoreDir               string            `json:"coreDir,omitempty"`
 	NvCCLI                bool              `json:"nvCCLI,omitempty"`
 	NvCCLIEnv             []string          `json:"NvCCLIEnv,omitempty"`
 	Rocm                  bool              `json:"rocm,omitempty"`
+	Intel                 bool              `json:"intel,omitempty"`
+	Rdma                  bool              `json:"rdma,omitempty"`
 	CustomHome            bool              `json:"customHome,omitempty"`
+	HomeMode              string            `json:"homeMode,omitempty"`
 	Instance              bool              `json:"instance,omitempty"`
 	InstanceJoin          bool              `json:"instanceJoin,omitempty"`
 	BootInstance          bool              `json:"bootInstance,omitempty"`
+	WatchHostFiles        string            `json:"watchHostFiles,omitempty"`
+	InstanceLabels        map[string]string `json:"instanceLabels,omitempty"`
 	RunPrivileged         bool              `json:"runPrivileged,omitempty"`
 	AllowSUID             bool              `json:"allowSUID,omitempty"`
 	KeepPrivs             bool              `json:"keepPrivs,omitempty"`
@@ -132,6 +151,7 @@ type JSONConfig struct {
 	NoInit                bool              `json:"noInit,omitempty"`
 	Fakeroot              bool              `json:"fakeroot,omitempty"`
 	SignalPropagation     bool              `json:"signalPropagation,omitempty"`
+	SignalProxy           []int             `json:"signalProxy,omitempty"`
 	RestoreUmask          bool              `json:"restoreUmask,omitempty"`
 	DeleteTempDir         string            `json:"deleteTempDir,omitempty"`
 	ImageFuse             bool              `json:"imageFuse,omitempty"`
@@ -203,6 +223,30 @@ func (e *EngineConfig) GetNvLegacy() bool {
 	return e.JSON.NvLegacy
 }
 
+// SetNvLegacyDevices sets the NVIDIA devices to bind into the container with
+// nvLegacy, when GPUs are selected. All NVIDIA devices are bound if unset.
+func (e *EngineConfig) SetNvLegacyDevices(devs []string) {
+	e.JSON.NvLegacyDevices = devs
+}
+
+// GetNvLegacyDevices returns the NVIDIA devices to bind into the container
+// with nvLegacy.
+func (e *EngineConfig) GetNvLegacyDevices() []string {
+	return e.JSON.NvLegacyDevices
+}
+
+// SetCoreDir sets the host directory into which core dumps of crashed
+// container processes are collected.
+func (e *EngineConfig) SetCoreDir(dir string) {
+	e.JSON.CoreDir = dir
+}
+
+// GetCoreDir returns the host directory into which core dumps of crashed
+// container processes are collected.
+func (e *EngineConfig) GetCoreDir() string {
+	return e.JSON.CoreDir
+}
+
 // SetNvCCLI sets nvcontainer flag to use nvidia-container-cli for CUDA setup
 func (e *EngineConfig) SetNvCCLI(nvCCLI bool) {
 	e.JSON.NvCCLI = nvCCLI
@@ -233,6 +277,26 @@ func (e *EngineConfig) GetRocm() bool {
 	return e.JSON.Rocm
 }
 
+// SetIntel sets intel flag to bind Intel GPU libraries and devices into container.
+func (e *EngineConfig) SetIntel(intel bool) {
+	e.JSON.Intel = intel
+}
+
+// GetIntel returns if intel flag is set or not.
+func (e *EngineConfig) GetIntel() bool {
+	return e.JSON.Intel
+}
+
+// SetRdma sets rdma flag to bind InfiniBand / RDMA libraries and devices into container.
+func (e *EngineConfig) SetRdma(rdma bool) {
+	e.JSON.Rdma = rdma
+}
+
+// GetRdma returns if rdma flag is set or not.
+func (e *EngineConfig) GetRdma() bool {
+	return e.JSON.Rdma
+}
+
 // SetWorkdir sets a work directory path.
 func (e *EngineConfig) SetWorkdir(name string) {
 	e.JSON.Workdir = name
@@ -243,6 +307,18 @@ func (e *EngineConfig) GetWorkdir() string {
 	return e.JSON.Workdir
 }
 
+// SetLicenseOverride sets the reason given to launch an image with a license
+// the user is not entitled to.
+func (e *EngineConfig) SetLicenseOverride(reason string) {
+	e.JSON.LicenseOverride = reason
+}
+
+// GetLicenseOverride returns the reason given to launch an image with a
+// license the user is not entitled to.
+func (e *EngineConfig) GetLicenseOverride() string {
+	return e.JSON.LicenseOverride
+}
+
 // SetScratchDir set a scratch directory path.
 func (e *EngineConfig) SetScratchDir(scratchdir []string) {
 	e.JSON.ScratchDir = scratchdir
@@ -283,6 +359,16 @@ func (e *EngineConfig) GetCustomHome() bool {
 	return e.JSON.CustomHome
 }
 
+// SetHomeMode sets how the home directory is provided in the container.
+func (e *EngineConfig) SetHomeMode(mode string) {
+	e.JSON.HomeMode = mode
+}
+
+// GetHomeMode returns how the home directory is provided in the container.
+func (e *EngineConfig) GetHomeMode() string {
+	return e.JSON.HomeMode
+}
+
 // SetBindPath sets the paths to bind into container.
 func (e *EngineConfig) SetBindPath(bindpath []bind.Path) {
 	e.JSON.BindPath = bindpath
@@ -353,6 +439,27 @@ func (e *EngineConfig) GetBootInstance() bool {
 	return e.JSON.BootInstance
 }
 
+// SetWatchHostFiles sets whether an instance warns about, or refreshes,
+// changes to the host files bound into it.
+func (e *EngineConfig) SetWatchHostFiles(mode string) {
+	e.JSON.WatchHostFiles = mode
+}
+
+// GetWatchHostFiles returns the host file watch mode of an instance.
+func (e *EngineConfig) GetWatchHostFiles() string {
+	return e.JSON.WatchHostFiles
+}
+
+// SetInstanceLabels sets the labels of an instance.
+func (e *EngineConfig) SetInstanceLabels(labels map[string]string) {
+	e.JSON.InstanceLabels = labels
+}
+
+// GetInstanceLabels returns the labels of an instance.
+func (e *EngineConfig) GetInstanceLabels() map[string]string {
+	return e.JSON.InstanceLabels
+}
+
 // SetAddCaps sets bounding/effective/permitted/inheritable/ambient capabilities to add.
 func (e *EngineConfig) SetAddCaps(caps string) {
 	e.JSON.AddCaps = caps
@@ -533,6 +640,16 @@ func (e *EngineConfig) GetNetworkArgs() []string {
 	return e.JSON.NetworkArgs
 }
 
+// SetNetworkLimits sets the rate and egress limits applied to CNI networks.
+func (e *EngineConfig) SetNetworkLimits(limits []string) {
+	e.JSON.NetworkLimits = limits
+}
+
+// GetNetworkLimits retrieves the rate and egress limits applied to CNI networks.
+func (e *EngineConfig) GetNetworkLimits() []string {
+	return e.JSON.NetworkLimits
+}
+
 // SetDNS sets a commas separated list of DNS servers to add in resolv.conf.
 func (e *EngineConfig) SetDNS(dns string) {
 	e.JSON.DNS = dns
@@ -543,6 +660,26 @@ func (e *EngineConfig) GetDNS() string {
 	return e.JSON.DNS
 }
 
+// SetDNSSearch sets a commas separated list of DNS search domains to add in resolv.conf.
+func (e *EngineConfig) SetDNSSearch(search string) {
+	e.JSON.DNSSearch = search
+}
+
+// GetDNSSearch retrieves list of DNS search domains.
+func (e *EngineConfig) GetDNSSearch() string {
+	return e.JSON.DNSSearch
+}
+
+// SetAddHosts sets a list of <hostname>:<ip> entries to add in /etc/hosts.
+func (e *EngineConfig) SetAddHosts(hosts []string) {
+	e.JSON.AddHosts = hosts
+}
+
+// GetAddHosts retrieves list of <hostname>:<ip> entries to add in /etc/hosts.
+func (e *EngineConfig) GetAddHosts() []string {
+	return e.JSON.AddHosts
+}
+
 // SetImageList sets image list containing opened images.
 func (e *EngineConfig) SetImageList(list []image.Image) {
 	e.JSON.ImageList = list
@@ -583,6 +720,36 @@ func (e *EngineConfig) GetWritableTmpfs() bool {
 	return e.JSON.WritableTmpfs
 }
 
+// SetWritableTmpfsSize sets the size, in MiB, of the writable tmpfs.
+func (e *EngineConfig) SetWritableTmpfsSize(size uint) {
+	e.JSON.WritableTmpfsSize = size
+}
+
+// GetWritableTmpfsSize returns the size, in MiB, of the writable tmpfs.
+func (e *EngineConfig) GetWritableTmpfsSize() uint {
+	return e.JSON.WritableTmpfsSize
+}
+
+// SetTmpSize sets the size, in MiB, of tmpfs mounts on /tmp and /var/tmp.
+func (e *EngineConfig) SetTmpSize(size uint) {
+	e.JSON.TmpSize = size
+}
+
+// GetTmpSize returns the size, in MiB, of tmpfs mounts on /tmp and /var/tmp.
+func (e *EngineConfig) GetTmpSize() uint {
+	return e.JSON.TmpSize
+}
+
+// SetShmSize sets the size, in MiB, of the tmpfs mount on /dev/shm.
+func (e *EngineConfig) SetShmSize(size uint) {
+	e.JSON.ShmSize = size
+}
+
+// GetShmSize returns the size, in MiB, of the tmpfs mount on /dev/shm.
+func (e *EngineConfig) GetShmSize() uint {
+	return e.JSON.ShmSize
+}
+
 // SetSecurity sets security feature arguments.
 func (e *EngineConfig) SetSecurity(security []string) {
 	e.JSON.Security = security
@@ -603,6 +770,30 @@ func (e *EngineConfig) GetCgroupsJSON() string {
 	return e.JSON.CgroupsJSON
 }
 
+// SetCgroupStats sets whether the container cgroup is exposed read-only in
+// the container.
+func (e *EngineConfig) SetCgroupStats(b bool) {
+	e.JSON.CgroupStats = b
+}
+
+// GetCgroupStats returns whether the container cgroup is exposed read-only in
+// the container.
+func (e *EngineConfig) GetCgroupStats() bool {
+	return e.JSON.CgroupStats
+}
+
+// SetVirtualProc sets whether /proc files show the container CPU and memory
+// limits.
+func (e *EngineConfig) SetVirtualProc(b bool) {
+	e.JSON.VirtualProc = b
+}
+
+// GetVirtualProc returns whether /proc files show the container CPU and
+// memory limits.
+func (e *EngineConfig) GetVirtualProc() bool {
+	return e.JSON.VirtualProc
+}
+
 // SetTargetUID sets target UID to execute the container process as user ID.
 func (e *EngineConfig) SetTargetUID(uid int) {
 	e.JSON.TargetUID = uid
@@ -641,6 +832,17 @@ func (e *EngineConfig) GetLibrariesPath() []string {
 	return e.JSON.LibrariesPath
 }
 
+// SetMPISonames sets the SONAMEs of the host PMI libraries bound with --mpi,
+// whose ABI compatibility is checked against the libraries of the image.
+func (e *EngineConfig) SetMPISonames(sonames []string) {
+	e.JSON.MPISonames = sonames
+}
+
+// GetMPISonames returns the SONAMEs of the host PMI libraries bound with --mpi.
+func (e *EngineConfig) GetMPISonames() []string {
+	return e.JSON.MPISonames
+}
+
 // SetFilesPath sets files to bind in container (eg: --nv).
 func (e *EngineConfig) SetFilesPath(files []string) {
 	e.JSON.FilesPath = files
@@ -702,6 +904,19 @@ func (e *EngineConfig) GetSignalPropagation() bool {
 	return e.JSON.SignalPropagation
 }
 
+// SetSignalProxy sets the signals that the engine always propagates to the
+// container process, even when signal propagation is not enabled (see
+// SetSignalPropagation), e.g. when running in the foreground of a terminal.
+func (e *EngineConfig) SetSignalProxy(signals []int) {
+	e.JSON.SignalProxy = signals
+}
+
+// GetSignalProxy returns the signals that the engine always propagates to
+// the container process (see SetSignalProxy).
+func (e *EngineConfig) GetSignalProxy() []int {
+	return e.JSON.SignalProxy
+}
+
 // GetSessionLayer returns the session layer used to setup the
 // container mount points.
 func (e *EngineConfig) GetSessionLayer() string {
@@ -786,6 +1001,30 @@ func (e *EngineConfig) GetSingularityEnv() map[string]string {
 	return e.JSON.SingularityEnv
 }
 
+// SetMetadataEnv sets the environment variables set by metadata edits of
+// the image.
+func (e *EngineConfig) SetMetadataEnv(env map[string]string) {
+	e.JSON.MetadataEnv = env
+}
+
+// GetMetadataEnv returns the environment variables set by metadata edits of
+// the image.
+func (e *EngineConfig) GetMetadataEnv() map[string]string {
+	return e.JSON.MetadataEnv
+}
+
+// SetMetadataFiles sets the container metadata files replaced by metadata
+// edits of the image, mapped to their content.
+func (e *EngineConfig) SetMetadataFiles(files map[string]string) {
+	e.JSON.MetadataFiles = files
+}
+
+// GetMetadataFiles returns the container metadata files replaced by metadata
+// edits of the image, mapped to their content.
+func (e *EngineConfig) GetMetadataFiles() map[string]string {
+	return e.JSON.MetadataFiles
+}
+
 // SetConfigurationFile sets the singularity configuration file to
 // use instead of the default one.
 func (e *EngineConfig) SetConfigurationFile(filename string) {
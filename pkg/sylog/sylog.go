@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -71,6 +71,8 @@ func prefix(logLevel, msgLevel messageLevel) string {
 }
 
 func writef(msgLevel messageLevel, format string, a ...interface{}) {
+	callMessageHook(msgLevel, format, a...)
+
 	logLevel := getLoggerLevel()
 	if logLevel < msgLevel {
 		return
@@ -95,6 +97,7 @@ func getLoggerLevel() messageLevel {
 // may be imported by other projects should NOT use Fatalf.
 func Fatalf(format string, a ...interface{}) {
 	writef(FatalLevel, format, a...)
+	callFatalHook(format, a...)
 	os.Exit(255)
 }
 
@@ -1,10 +1,16 @@
-// Copyright (c) 2019-2021, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
 
 package sylog
 
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
 type messageLevel int
 
 // Log levels.
@@ -42,3 +48,45 @@ var messageLabels = map[messageLevel]string{
 	Verbose3Level: "VERBOSE",
 	DebugLevel:    "DEBUG",
 }
+
+// fatalHook is called with the message of a fatal error, before exiting.
+var fatalHook func(msg string)
+
+// SetFatalHook sets a function called with the message passed to Fatalf,
+// before the process exits. A nil function removes the hook.
+func SetFatalHook(fn func(msg string)) {
+	fatalHook = fn
+}
+
+// messageHook is called with the level and text of log messages.
+var messageHook atomic.Pointer[func(level int, msg string)]
+
+// SetMessageHook sets a function called with the level and text of log
+// messages from InfoLevel up to FatalLevel, whatever the log level, and even
+// when the log output is not compiled in. It may be called concurrently, from
+// the goroutines writing messages. A nil function removes the hook.
+func SetMessageHook(fn func(level int, msg string)) {
+	if fn == nil {
+		messageHook.Store(nil)
+		return
+	}
+	messageHook.Store(&fn)
+}
+
+// callMessageHook calls the message hook, if any, with the level and the
+// formatted message.
+func callMessageHook(level messageLevel, format string, a ...interface{}) {
+	if level > InfoLevel {
+		return
+	}
+	if fn := messageHook.Load(); fn != nil {
+		(*fn)(int(level), strings.TrimRight(fmt.Sprintf(format, a...), "\n"))
+	}
+}
+
+// callFatalHook calls the fatal hook, if any, with the formatted message.
+func callFatalHook(format string, a ...interface{}) {
+	if fatalHook != nil {
+		fatalHook(strings.TrimRight(fmt.Sprintf(format, a...), "\n"))
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2019-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -15,17 +15,25 @@ import (
 // Fatalf is a dummy function exiting with code 255. This
 // function must not be used in public packages.
 func Fatalf(format string, a ...interface{}) {
+	callMessageHook(FatalLevel, format, a...)
+	callFatalHook(format, a...)
 	os.Exit(255)
 }
 
-// Errorf is a dummy function doing nothing.
-func Errorf(format string, a ...interface{}) {}
+// Errorf is a dummy function only calling the message hook.
+func Errorf(format string, a ...interface{}) {
+	callMessageHook(ErrorLevel, format, a...)
+}
 
-// Warningf is a dummy function doing nothing.
-func Warningf(format string, a ...interface{}) {}
+// Warningf is a dummy function only calling the message hook.
+func Warningf(format string, a ...interface{}) {
+	callMessageHook(WarnLevel, format, a...)
+}
 
-// Infof is a dummy function doing nothing.
-func Infof(format string, a ...interface{}) {}
+// Infof is a dummy function only calling the message hook.
+func Infof(format string, a ...interface{}) {
+	callMessageHook(InfoLevel, format, a...)
+}
 
 // Verbosef is a dummy function doing nothing.
 func Verbosef(format string, a ...interface{}) {}
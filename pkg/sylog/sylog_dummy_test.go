@@ -75,3 +75,16 @@ func TestNoOps(t *testing.T) {
 
 	SetLevel(0, false)
 }
+
+func TestMessageHook(t *testing.T) {
+	var got []string
+	SetMessageHook(func(level int, msg string) { got = append(got, msg) })
+	defer SetMessageHook(nil)
+
+	Errorf("failed")
+	Infof("pulling %s", "alpine")
+	Verbosef("hidden")
+	if len(got) != 2 || got[0] != "failed" || got[1] != "pulling alpine" {
+		t.Errorf("message hook got %q", got)
+	}
+}
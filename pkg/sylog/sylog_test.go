@@ -359,3 +359,36 @@ func TestStderrOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestFatalHook(t *testing.T) {
+	var got string
+	SetFatalHook(func(msg string) { got = msg })
+	defer SetFatalHook(nil)
+
+	callFatalHook("failed to mount %s\n", "loop0")
+	if want := "failed to mount loop0"; got != want {
+		t.Errorf("fatal hook got %q, want %q", got, want)
+	}
+
+	SetFatalHook(nil)
+	callFatalHook("no hook")
+}
+
+func TestMessageHook(t *testing.T) {
+	var got []string
+	SetMessageHook(func(level int, msg string) { got = append(got, fmt.Sprintf("%d %s", level, msg)) })
+	defer SetMessageHook(nil)
+
+	SetLevel(int(ErrorLevel), false)
+	defer SetLevel(int(InfoLevel), false)
+	logWriter = io.Discard
+	defer func() { logWriter = defaultWriter }()
+
+	Warningf("low %s\n", "space")
+	Infof("pulling")
+	Debugf("hidden")
+	want := []string{fmt.Sprintf("%d low space", WarnLevel), fmt.Sprintf("%d pulling", InfoLevel)}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("message hook got %q, want %q", got, want)
+	}
+}
@@ -8,6 +8,7 @@ package bind
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -29,8 +30,12 @@ var bindOptions = map[string]bool{
 	"rw":        flagOption,
 	"image-src": valueOption,
 	"id":        valueOption,
+	"idmap":     valueOption,
 }
 
+// propagations are the valid values of the bind-propagation of a mount.
+var propagations = []string{"private", "rprivate", "shared", "rshared", "slave", "rslave"}
+
 // Path stores a parsed bind path specification. Source and Destination
 // paths are required.
 type Path struct {
@@ -61,11 +66,66 @@ func (b *Path) ID() string {
 	return ""
 }
 
+// IDMap returns the value of the option idmap for a BindPath, or an empty
+// string if the option wasn't set.
+func (b *Path) IDMap() string {
+	if b.Options != nil && b.Options["idmap"] != nil {
+		return b.Options["idmap"].Value
+	}
+	return ""
+}
+
 // Readonly returns true if the ro option was set for a BindPath.
 func (b *Path) Readonly() bool {
 	return b.Options != nil && b.Options["ro"] != nil
 }
 
+// Propagation returns the value of the option propagation for a BindPath, or
+// an empty string if the option wasn't set.
+func (b *Path) Propagation() string {
+	if b.Options != nil && b.Options["propagation"] != nil {
+		return b.Options["propagation"].Value
+	}
+	return ""
+}
+
+// Volume returns the name of the named volume a BindPath binds, or an empty
+// string if it is not a mount of type volume.
+func (b *Path) Volume() string {
+	if b.Options != nil && b.Options["volume"] != nil {
+		return b.Options["volume"].Value
+	}
+	return ""
+}
+
+// Tmpfs returns true if a BindPath is a mount of a new tmpfs, rather than a
+// bind.
+func (b *Path) Tmpfs() bool {
+	return b.Options != nil && b.Options["tmpfs"] != nil
+}
+
+// TmpfsOptions returns the size and mode mount options of a tmpfs BindPath.
+// The mode defaults to 1777.
+func (b *Path) TmpfsOptions() ([]string, error) {
+	var opts []string
+	if b.Options["tmpfs-size"] != nil {
+		size, err := strconv.ParseInt(b.Options["tmpfs-size"].Value, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid tmpfs-size %q", b.Options["tmpfs-size"].Value)
+		}
+		opts = append(opts, fmt.Sprintf("size=%d", size))
+	}
+	mode := uint64(0o1777)
+	if b.Options["tmpfs-mode"] != nil {
+		var err error
+		mode, err = strconv.ParseUint(b.Options["tmpfs-mode"].Value, 8, 32)
+		if err != nil || mode > 0o7777 {
+			return nil, fmt.Errorf("invalid tmpfs-mode %q", b.Options["tmpfs-mode"].Value)
+		}
+	}
+	return append(opts, fmt.Sprintf("mode=%o", mode)), nil
+}
+
 // ParseBindPath parses a string specifying one or more (comma separated) bind
 // paths in src[:dst[:options]] format, and returns all encountered bind paths
 // as a slice. Options may be simple flags, e.g. 'rw', or take a value, e.g.
@@ -174,6 +174,20 @@ func TestParseBindPath(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "srcDstIDMap",
+			bindpaths: "/opt:/other:idmap=999.999,ro",
+			want: []Path{
+				{
+					Source:      "/opt",
+					Destination: "/other",
+					Options: map[string]*Option{
+						"idmap": {"999.999"},
+						"ro":    {},
+					},
+				},
+			},
+		},
 		{
 			name:      "invalidOption",
 			bindpaths: "/opt:/other:invalid",
@@ -200,3 +214,51 @@ func TestParseBindPath(t *testing.T) {
 		})
 	}
 }
+
+func TestTmpfsOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]*Option
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "Default",
+			want: []string{"mode=1777"},
+		},
+		{
+			name: "SizeMode",
+			options: map[string]*Option{
+				"tmpfs-size": {Value: "1048576"},
+				"tmpfs-mode": {Value: "700"},
+			},
+			want: []string{"size=1048576", "mode=700"},
+		},
+		{
+			name: "InvalidSize",
+			options: map[string]*Option{
+				"tmpfs-size": {Value: "1m,uid=0"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "InvalidMode",
+			options: map[string]*Option{
+				"tmpfs-mode": {Value: "17777"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Path{Destination: "/tmp", Options: tt.options}
+			got, err := b.TmpfsOptions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TmpfsOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TmpfsOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
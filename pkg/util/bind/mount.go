@@ -8,14 +8,30 @@ package bind
 import (
 	"encoding/csv"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/sylabs/singularity/v4/pkg/util/slice"
+)
+
+// Mount types supported in a --mount string.
+const (
+	mountTypeBind   = "bind"
+	mountTypeImage  = "image"
+	mountTypeTmpfs  = "tmpfs"
+	mountTypeVolume = "volume"
 )
 
+// validVolumeName matches the names of named volumes, as docker does.
+var validVolumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
 // ParseMountString converts a --mount string into one or more BindPath structs.
 //
 // Our intention is to support common docker --mount strings, but have
 // additional fields for singularity specific concepts (image-src, id when
-// binding out of an image file).
+// binding out of an image file, idmap for idmapped binds).
 //
 // We use a CSV reader to parse the fields in a mount string according to CSV
 // escaping rules. This is the approach docker uses to allow special characters
@@ -27,8 +43,16 @@ import (
 //
 //	type=bind,source=/opt,destination=/other,rw
 //
-// We only support type=bind at present, so assume this if type is missing and
-// error for other types.
+// The type defaults to bind if missing. Other types are:
+//
+//	type=image   mounts a directory (image-src, default /) from the image file
+//	             source, which may be a SIF, OCI-SIF, squashfs, or EXT3 image.
+//	             The partition of a SIF, or layer of an OCI-SIF, is selected
+//	             by its descriptor id with partition (or id).
+//	type=tmpfs   mounts a new tmpfs, with no source, sized with tmpfs-size and
+//	             with the permissions tmpfs-mode.
+//	type=volume  binds the named volume source, a directory that is created on
+//	             first use and kept between runs.
 func ParseMountString(mount string) (bindPaths []Path, err error) {
 	r := strings.NewReader(mount)
 	c := csv.NewReader(r)
@@ -41,6 +65,7 @@ func ParseMountString(mount string) (bindPaths []Path, err error) {
 		bp := Path{
 			Options: map[string]*Option{},
 		}
+		mountType := mountTypeBind
 
 		for _, f := range r {
 			kv := strings.SplitN(f, "=", 2)
@@ -51,10 +76,12 @@ func ParseMountString(mount string) (bindPaths []Path, err error) {
 			}
 
 			switch key {
-			// TODO - Eventually support volume and tmpfs? Requires structural changes to engine mount functionality.
 			case "type":
-				if val != "bind" {
-					return []Path{}, fmt.Errorf("unsupported mount type %q, only 'bind' is supported", val)
+				switch val {
+				case mountTypeBind, mountTypeImage, mountTypeTmpfs, mountTypeVolume:
+					mountType = val
+				default:
+					return []Path{}, fmt.Errorf("unsupported mount type %q, must be one of %s, %s, %s, %s", val, mountTypeBind, mountTypeImage, mountTypeTmpfs, mountTypeVolume)
 				}
 			case "source", "src":
 				if val == "" {
@@ -80,18 +107,102 @@ func ParseMountString(mount string) (bindPaths []Path, err error) {
 					return []Path{}, fmt.Errorf("id cannot be empty")
 				}
 				bp.Options["id"] = &Option{Value: val}
+			// Singularity only - id of the partition of a SIF, or squashfs
+			// layer of an OCI-SIF, image source to mount from, as id
+			case "partition":
+				if id, err := strconv.ParseUint(val, 10, 32); err != nil || id == 0 {
+					return []Path{}, fmt.Errorf("partition must be a descriptor id greater than 0")
+				}
+				bp.Options["id"] = &Option{Value: val}
+			// Singularity only - container uid:gid mapped to the host user in an idmapped mount
+			case "idmap":
+				if val == "" {
+					return []Path{}, fmt.Errorf("idmap cannot be empty")
+				}
+				bp.Options["idmap"] = &Option{Value: val}
 			case "bind-propagation":
-				return []Path{}, fmt.Errorf("bind-propagation not supported for individual mounts, check singularity.conf for global setting")
+				if !slice.ContainsString(propagations, val) {
+					return []Path{}, fmt.Errorf("invalid bind-propagation %q, must be one of %s", val, strings.Join(propagations, ", "))
+				}
+				bp.Options["propagation"] = &Option{Value: val}
+			case "tmpfs-size":
+				size, err := units.RAMInBytes(val)
+				if err != nil || size <= 0 {
+					return []Path{}, fmt.Errorf("invalid tmpfs-size %q", val)
+				}
+				bp.Options["tmpfs-size"] = &Option{Value: strconv.FormatInt(size, 10)}
+			case "tmpfs-mode":
+				mode, err := strconv.ParseUint(val, 8, 32)
+				if err != nil || mode > 0o7777 {
+					return []Path{}, fmt.Errorf("invalid tmpfs-mode %q, must be an octal file mode", val)
+				}
+				bp.Options["tmpfs-mode"] = &Option{Value: strconv.FormatUint(mode, 8)}
 			default:
 				return []Path{}, fmt.Errorf("invalid key %q in mount specification", key)
 			}
 		}
 
-		if bp.Source == "" || bp.Destination == "" {
-			return []Path{}, fmt.Errorf("mounts must specify a source and a destination")
+		if err := checkMountType(&bp, mountType); err != nil {
+			return []Path{}, err
 		}
 		bindPaths = append(bindPaths, bp)
 	}
 
 	return bindPaths, nil
 }
+
+// checkMountType checks that the fields of bp are valid for mountType, and
+// records the type in its options.
+func checkMountType(bp *Path, mountType string) error {
+	only := func(key, typ string) error {
+		if mountType != typ && bp.Options[key] != nil {
+			return fmt.Errorf("%s is only supported for mounts of type %s", key, typ)
+		}
+		return nil
+	}
+	if err := only("tmpfs-size", mountTypeTmpfs); err != nil {
+		return err
+	}
+	if err := only("tmpfs-mode", mountTypeTmpfs); err != nil {
+		return err
+	}
+
+	switch mountType {
+	case mountTypeTmpfs:
+		if bp.Source != "" {
+			return fmt.Errorf("mounts of type tmpfs cannot specify a source")
+		}
+		if bp.Destination == "" {
+			return fmt.Errorf("mounts of type tmpfs must specify a destination")
+		}
+		for _, key := range []string{"image-src", "id", "idmap", "propagation"} {
+			if bp.Options[key] != nil {
+				return fmt.Errorf("%s is not supported for mounts of type tmpfs", key)
+			}
+		}
+		bp.Options["tmpfs"] = &Option{}
+		return nil
+	case mountTypeVolume:
+		if bp.Source != "" && !validVolumeName.MatchString(bp.Source) {
+			return fmt.Errorf("invalid volume name %q", bp.Source)
+		}
+		for _, key := range []string{"image-src", "id"} {
+			if bp.Options[key] != nil {
+				return fmt.Errorf("%s is not supported for mounts of type volume", key)
+			}
+		}
+		bp.Options["volume"] = &Option{Value: bp.Source}
+	case mountTypeImage:
+		if bp.Options["propagation"] != nil {
+			return fmt.Errorf("bind-propagation is not supported for mounts of type image")
+		}
+		if bp.Options["image-src"] == nil {
+			bp.Options["image-src"] = &Option{}
+		}
+	}
+
+	if bp.Source == "" || bp.Destination == "" {
+		return fmt.Errorf("mounts must specify a source and a destination")
+	}
+	return nil
+}
@@ -194,9 +194,158 @@ func TestParseMountString(t *testing.T) {
 			want:        []Path{},
 			wantErr:     true,
 		},
+		{
+			name:        "idmap",
+			mountString: "type=bind,source=/opt,destination=/opt,idmap=999.999",
+			want: []Path{
+				{
+					Source:      "/opt",
+					Destination: "/opt",
+					Options: map[string]*Option{
+						"idmap": {Value: "999.999"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "idmapEmpty",
+			mountString: "type=bind,source=/opt,destination=/opt,idmap=",
+			want:        []Path{},
+			wantErr:     true,
+		},
 		{
 			name:        "bindpropagation",
-			mountString: "type=bind,source=/opt,destination=/opt,bind-propagation=shared",
+			mountString: "type=bind,source=/opt,destination=/opt,bind-propagation=rshared",
+			want: []Path{
+				{
+					Source:      "/opt",
+					Destination: "/opt",
+					Options: map[string]*Option{
+						"propagation": {Value: "rshared"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "bindpropagationInvalid",
+			mountString: "type=bind,source=/opt,destination=/opt,bind-propagation=potato",
+			want:        []Path{},
+			wantErr:     true,
+		},
+		{
+			name:        "image",
+			mountString: "type=image,source=test.sif,destination=/opt",
+			want: []Path{
+				{
+					Source:      "test.sif",
+					Destination: "/opt",
+					Options: map[string]*Option{
+						"image-src": {},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "imageSubPath",
+			mountString: "type=image,source=test.sif,destination=/opt,image-src=/data,ro",
+			want: []Path{
+				{
+					Source:      "test.sif",
+					Destination: "/opt",
+					Options: map[string]*Option{
+						"image-src": {Value: "/data"},
+						"ro":        {},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "imagePartition",
+			mountString: "type=image,src=tools.sif,dst=/opt/tools,partition=3",
+			want: []Path{
+				{
+					Source:      "tools.sif",
+					Destination: "/opt/tools",
+					Options: map[string]*Option{
+						"image-src": {},
+						"id":        {Value: "3"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "imagePartitionInvalid",
+			mountString: "type=image,src=tools.sif,dst=/opt/tools,partition=0",
+			want:        []Path{},
+			wantErr:     true,
+		},
+		{
+			name:        "tmpfs",
+			mountString: "type=tmpfs,destination=/scratch,tmpfs-size=64m,tmpfs-mode=0700",
+			want: []Path{
+				{
+					Destination: "/scratch",
+					Options: map[string]*Option{
+						"tmpfs":      {},
+						"tmpfs-size": {Value: "67108864"},
+						"tmpfs-mode": {Value: "700"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "tmpfsSource",
+			mountString: "type=tmpfs,source=/opt,destination=/scratch",
+			want:        []Path{},
+			wantErr:     true,
+		},
+		{
+			name:        "tmpfsNoDestination",
+			mountString: "type=tmpfs,tmpfs-size=64m",
+			want:        []Path{},
+			wantErr:     true,
+		},
+		{
+			name:        "tmpfsSizeInvalid",
+			mountString: "type=tmpfs,destination=/scratch,tmpfs-size=lots",
+			want:        []Path{},
+			wantErr:     true,
+		},
+		{
+			name:        "tmpfsModeInvalid",
+			mountString: "type=tmpfs,destination=/scratch,tmpfs-mode=999",
+			want:        []Path{},
+			wantErr:     true,
+		},
+		{
+			name:        "tmpfsSizeBind",
+			mountString: "type=bind,source=/opt,destination=/opt,tmpfs-size=64m",
+			want:        []Path{},
+			wantErr:     true,
+		},
+		{
+			name:        "volume",
+			mountString: "type=volume,source=data,destination=/data",
+			want: []Path{
+				{
+					Source:      "data",
+					Destination: "/data",
+					Options: map[string]*Option{
+						"volume": {Value: "data"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "volumeInvalidName",
+			mountString: "type=volume,source=../data,destination=/data",
 			want:        []Path{},
 			wantErr:     true,
 		},
@@ -0,0 +1,76 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cryptkey
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// KMSScheme is the key URI scheme of keys accessed through a KMS helper
+// program, e.g. kms://aws/alias/my-key for the key alias/my-key of the
+// singularity-kms-aws helper. This allows cloud KMS services to be used
+// through their own tooling and credentials.
+const KMSScheme = "kms"
+
+// kmsHelperPrefix is the prefix of the name of KMS helper programs, which
+// are looked up in $PATH.
+const kmsHelperPrefix = "singularity-kms-"
+
+var kmsHelperRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func init() {
+	RegisterKeyProvider(KMSScheme, kmsHelperProvider{})
+}
+
+// kmsHelperProvider wraps keys with a KMS helper program. The helper for
+// kms://<helper>/<key-id> is singularity-kms-<helper>, which is run as:
+//
+//	singularity-kms-<helper> wrap <key-id>
+//	singularity-kms-<helper> unwrap <key-id>
+//
+// reading the plaintext or wrapped key from stdin, and writing the wrapped or
+// plaintext key to stdout.
+type kmsHelperProvider struct{}
+
+func (kmsHelperProvider) Wrap(ctx context.Context, u *url.URL, plaintext []byte) ([]byte, error) {
+	return runKMSHelper(ctx, u, "wrap", plaintext)
+}
+
+func (kmsHelperProvider) Unwrap(ctx context.Context, u *url.URL, wrapped []byte) ([]byte, error) {
+	return runKMSHelper(ctx, u, "unwrap", wrapped)
+}
+
+func runKMSHelper(ctx context.Context, u *url.URL, op string, in []byte) ([]byte, error) {
+	helper := u.Host
+	keyID := strings.TrimPrefix(u.Path, "/")
+	if !kmsHelperRegexp.MatchString(helper) || keyID == "" {
+		return nil, fmt.Errorf("invalid KMS key URI %s, expected %s://<helper>/<key-id>", u, KMSScheme)
+	}
+
+	path, err := exec.LookPath(kmsHelperPrefix + helper)
+	if err != nil {
+		return nil, fmt.Errorf("KMS helper not found: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, op, keyID)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w: %s", kmsHelperPrefix+helper, op, err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("%s %s returned no key", kmsHelperPrefix+helper, op)
+	}
+	return stdout.Bytes(), nil
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2019-2022, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2023, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -19,6 +19,7 @@ import (
 	"os"
 
 	"github.com/sylabs/sif/v2/pkg/sif"
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
 )
 
 var (
@@ -39,6 +40,9 @@ const (
 	Passphrase
 	// PEM indicates the key material is formatted as a PEM file.
 	PEM
+	// URI indicates the key material is held by the key management service
+	// at the key URI in Path, and accessed through a KeyProvider.
+	URI
 )
 
 // KeyInfo contains information for passing around
@@ -60,10 +64,9 @@ func getRandomBytes(size int) ([]byte, error) {
 
 func NewPlaintextKey(k KeyInfo) ([]byte, error) {
 	switch k.Format {
-	case PEM:
+	case PEM, URI:
 		// in this case we will generate a random secret and
-		// encrypt it using the PEM key.use the PEM key to
-		// encrypt a secret
+		// encrypt it using the PEM key, or the key provider
 		return getRandomBytes(64)
 
 	case Passphrase:
@@ -82,6 +85,9 @@ func EncryptKey(k KeyInfo, plaintext []byte) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("loading public key for key encryption: %v", err)
 		}
+		if err := fips.CheckPublicKey(pubKey); err != nil {
+			return nil, fmt.Errorf("loading public key for key encryption: %v", err)
+		}
 
 		ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pubKey, plaintext, nil)
 		if err != nil {
@@ -96,6 +102,9 @@ func EncryptKey(k KeyInfo, plaintext []byte) ([]byte, error) {
 
 		return buf.Bytes(), nil
 
+	case URI:
+		return wrapKey(k.Path, plaintext)
+
 	case Passphrase:
 		return nil, nil
 
@@ -112,24 +121,44 @@ func PlaintextKey(k KeyInfo, image string) ([]byte, error) {
 			return nil, fmt.Errorf("could not load PEM private key: %v", err)
 		}
 
-		pemKey, err := getEncryptionKeyFromImage(image)
+		pemKey, err := getEncryptionKeyFromImage(image, sif.MessageRSAOAEP)
 		if err != nil {
 			return nil, fmt.Errorf("could not get encryption information from SIF: %v", err)
 		}
 
-		pemBuf := bytes.NewReader(pemKey)
+		return decryptPEMMessage(privateKey, pemKey)
 
-		encKey, err := loadPEMMessage(pemBuf)
+	case URI:
+		message, err := getEncryptionKeyFromImage(image, MessageKeyProvider)
 		if err != nil {
-			return nil, fmt.Errorf("could not unpack LUKS PEM from SIF: %v", err)
+			return nil, fmt.Errorf("could not get encryption information from SIF: %v", err)
 		}
 
-		plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encKey, nil)
+		return unwrapKey(k.Path, message)
+
+	case Passphrase:
+		return []byte(k.Material), nil
+
+	default:
+		return nil, ErrUnsupportedKeyURI
+	}
+}
+
+// PlaintextKeyFromMessage returns the plaintext key for k, decrypting the PEM
+// message produced by EncryptKey when k refers to a PEM private key or a key
+// URI. The message is ignored for passphrases.
+func PlaintextKeyFromMessage(k KeyInfo, message []byte) ([]byte, error) {
+	switch k.Format {
+	case PEM:
+		privateKey, err := LoadPEMPrivateKey(k.Path)
 		if err != nil {
-			return nil, fmt.Errorf("could not decrypt LUKS key: %v", err)
+			return nil, fmt.Errorf("could not load PEM private key: %v", err)
 		}
 
-		return plaintext, nil
+		return decryptPEMMessage(privateKey, message)
+
+	case URI:
+		return unwrapKey(k.Path, message)
 
 	case Passphrase:
 		return []byte(k.Material), nil
@@ -139,6 +168,29 @@ func PlaintextKey(k KeyInfo, image string) ([]byte, error) {
 	}
 }
 
+// MessageType returns the type of the SIF crypto message holding the
+// encrypted key produced by EncryptKey for k.
+func MessageType(k KeyInfo) sif.MessageType {
+	if k.Format == URI {
+		return MessageKeyProvider
+	}
+	return sif.MessageRSAOAEP
+}
+
+func decryptPEMMessage(privateKey *rsa.PrivateKey, message []byte) ([]byte, error) {
+	encKey, err := loadPEMMessage(bytes.NewReader(message))
+	if err != nil {
+		return nil, fmt.Errorf("could not unpack LUKS PEM: %v", err)
+	}
+
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt LUKS key: %v", err)
+	}
+
+	return plaintext, nil
+}
+
 func LoadPEMPrivateKey(fn string) (*rsa.PrivateKey, error) {
 	b, err := os.ReadFile(fn)
 	if err != nil {
@@ -200,7 +252,7 @@ func savePEMMessage(w io.Writer, msg []byte) error {
 	return pem.Encode(w, b)
 }
 
-func getEncryptionKeyFromImage(fn string) ([]byte, error) {
+func getEncryptionKeyFromImage(fn string, messageType sif.MessageType) ([]byte, error) {
 	img, err := sif.LoadContainerFromPath(fn, sif.OptLoadWithFlag(os.O_RDONLY))
 	if err != nil {
 		return nil, fmt.Errorf("could not load container: %w", err)
@@ -226,8 +278,7 @@ func getEncryptionKeyFromImage(fn string) ([]byte, error) {
 			return nil, fmt.Errorf("could not get crypto message metadata: %w", err)
 		}
 
-		// currently only support one type of message
-		if format != sif.FormatPEM || message != sif.MessageRSAOAEP {
+		if format != sif.FormatPEM || message != messageType {
 			continue
 		}
 
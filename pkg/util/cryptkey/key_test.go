@@ -6,7 +6,9 @@
 package cryptkey
 
 import (
+	"bytes"
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -144,3 +146,55 @@ func TestPlaintextKey(t *testing.T) {
 		})
 	}
 }
+
+func TestPlaintextKeyFromMessage(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "private.pem")
+	pubPath := filepath.Join(dir, "public.pem")
+
+	key, err := GenerateRSAKey(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SavePrivatePEM(privPath, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := SavePublicPEM(pubPath, key); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := NewPlaintextKey(KeyInfo{Format: PEM, Path: pubPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := EncryptKey(KeyInfo{Format: PEM, Path: pubPath}, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := PlaintextKeyFromMessage(KeyInfo{Format: PEM, Path: privPath}, message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted key does not match plaintext key")
+	}
+
+	got, err = PlaintextKeyFromMessage(KeyInfo{Format: Passphrase, Material: testPassphrase}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != testPassphrase {
+		t.Errorf("got %q, want %q", got, testPassphrase)
+	}
+
+	if _, err := PlaintextKeyFromMessage(KeyInfo{Format: PEM, Path: privPath}, []byte("garbage")); err == nil {
+		t.Errorf("unexpected success decrypting invalid message")
+	}
+	if _, err := PlaintextKeyFromMessage(KeyInfo{Format: Unknown}, message); err != ErrUnsupportedKeyURI {
+		t.Errorf("got error %v, want %v", err, ErrUnsupportedKeyURI)
+	}
+}
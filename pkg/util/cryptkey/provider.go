@@ -0,0 +1,194 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cryptkey
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sylabs/sif/v2/pkg/sif"
+)
+
+// MessageKeyProvider is the SIF crypto message type of a key wrapped by a
+// KeyProvider. The message is PEM formatted.
+const MessageKeyProvider sif.MessageType = 0x201
+
+const (
+	// wrappedKeyBlockType is the type of the PEM block holding a key wrapped
+	// by a KeyProvider.
+	wrappedKeyBlockType = "WRAPPED KEY"
+	// keyURIHeader is the header of the PEM block recording the URI of the
+	// key that wrapped it.
+	keyURIHeader = "Key-URI"
+
+	// providerTimeout is the maximum time allowed for a KeyProvider to wrap
+	// or unwrap a key.
+	providerTimeout = 30 * time.Second
+)
+
+// ErrUnknownKeyProvider indicates no KeyProvider is registered for the scheme
+// of a key URI.
+var ErrUnknownKeyProvider = errors.New("unknown key provider")
+
+// KeyProvider wraps and unwraps the random key that encrypts a container
+// filesystem, with a key held by an external key management service such as
+// Hashicorp Vault or a cloud KMS. The key material of the service never
+// leaves it. A KeyProvider is selected by the scheme of a key URI, e.g.
+// vault://transit/my-key, and receives the parsed URI.
+type KeyProvider interface {
+	// Wrap returns plaintext encrypted with the key at u.
+	Wrap(ctx context.Context, u *url.URL, plaintext []byte) ([]byte, error)
+	// Unwrap returns the plaintext of a key wrapped with the key at u.
+	Unwrap(ctx context.Context, u *url.URL, wrapped []byte) ([]byte, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]KeyProvider{}
+)
+
+// RegisterKeyProvider registers p for key URIs with scheme, replacing any
+// KeyProvider already registered for scheme.
+func RegisterKeyProvider(scheme string, p KeyProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[scheme] = p
+}
+
+// KeyProviderSchemes returns the sorted list of key URI schemes with a
+// registered KeyProvider.
+func KeyProviderSchemes() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	schemes := make([]string, 0, len(providers))
+	for s := range providers {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// keyProvider returns the KeyProvider for, and the parsed, key URI uri.
+func keyProvider(uri string) (KeyProvider, *url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key URI %q: %w", uri, err)
+	}
+
+	providersMu.RLock()
+	p, ok := providers[u.Scheme]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w for key URI %q, supported schemes are %s",
+			ErrUnknownKeyProvider, uri, strings.Join(KeyProviderSchemes(), ", "))
+	}
+	return p, u, nil
+}
+
+// CheckKeyURI returns an error if uri is not a valid key URI, with a
+// registered KeyProvider.
+func CheckKeyURI(uri string) error {
+	_, _, err := keyProvider(uri)
+	return err
+}
+
+// wrapKey wraps plaintext with the key at uri, and returns a PEM message
+// recording uri.
+func wrapKey(uri string, plaintext []byte) ([]byte, error) {
+	p, u, err := keyProvider(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+	defer cancel()
+
+	wrapped, err := p.Wrap(ctx, u, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("while wrapping key with %s: %w", uri, err)
+	}
+
+	b := &pem.Block{
+		Type:    wrappedKeyBlockType,
+		Headers: map[string]string{keyURIHeader: uri},
+		Bytes:   wrapped,
+	}
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, b); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeWrappedKey returns the key URI and wrapped key held in a PEM message
+// produced by wrapKey.
+func decodeWrappedKey(message []byte) (string, []byte, error) {
+	block, _ := pem.Decode(message)
+	if block == nil || block.Type != wrappedKeyBlockType {
+		return "", nil, fmt.Errorf("no wrapped key data: %w", ErrNoPEMData)
+	}
+	uri := block.Headers[keyURIHeader]
+	if uri == "" {
+		return "", nil, fmt.Errorf("wrapped key has no %s header", keyURIHeader)
+	}
+	return uri, block.Bytes, nil
+}
+
+// unwrapKey returns the plaintext of the key wrapped in a PEM message produced
+// by wrapKey. The key URI recorded in the message is used, unless uri is set.
+func unwrapKey(uri string, message []byte) ([]byte, error) {
+	msgURI, wrapped, err := decodeWrappedKey(message)
+	if err != nil {
+		return nil, err
+	}
+	if uri == "" {
+		uri = msgURI
+	}
+
+	p, u, err := keyProvider(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+	defer cancel()
+
+	plaintext, err := p.Unwrap(ctx, u, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("while unwrapping key with %s: %w", uri, err)
+	}
+	return plaintext, nil
+}
+
+// KeyInfoFromMessage returns the KeyInfo that unwraps the key held in message,
+// when it was wrapped by a KeyProvider. This allows an image encrypted with a
+// key URI to be run without specifying key material.
+func KeyInfoFromMessage(message []byte) (*KeyInfo, error) {
+	uri, _, err := decodeWrappedKey(message)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyInfo{Format: URI, Path: uri}, nil
+}
+
+// ImageKeyInfo returns the KeyInfo that unwraps the key of the encrypted SIF
+// image at path, when it was wrapped by a KeyProvider.
+func ImageKeyInfo(path string) (*KeyInfo, error) {
+	message, err := getEncryptionKeyFromImage(path, MessageKeyProvider)
+	if err != nil {
+		return nil, err
+	}
+	return KeyInfoFromMessage(message)
+}
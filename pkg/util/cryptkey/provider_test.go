@@ -0,0 +1,221 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cryptkey
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+// xorProvider wraps keys by XORing them with the first byte of the key name.
+type xorProvider struct{}
+
+func (xorProvider) xor(u *url.URL, b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ u.Host[0]
+	}
+	return out
+}
+
+func (p xorProvider) Wrap(_ context.Context, u *url.URL, plaintext []byte) ([]byte, error) {
+	return p.xor(u, plaintext), nil
+}
+
+func (p xorProvider) Unwrap(_ context.Context, u *url.URL, wrapped []byte) ([]byte, error) {
+	return p.xor(u, wrapped), nil
+}
+
+func TestKeyProvider(t *testing.T) {
+	RegisterKeyProvider("xor", xorProvider{})
+
+	k := KeyInfo{Format: URI, Path: "xor://a"}
+	plaintext, err := NewPlaintextKey(k)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	message, err := EncryptKey(k, plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(message, plaintext) {
+		t.Errorf("plaintext key found in message")
+	}
+
+	got, err := PlaintextKeyFromMessage(k, message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("unwrapped key differs from plaintext key")
+	}
+
+	// The key URI recorded in the message is used by default.
+	ki, err := KeyInfoFromMessage(message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *ki != k {
+		t.Errorf("got key info %v, want %v", *ki, k)
+	}
+	got, err = PlaintextKeyFromMessage(KeyInfo{Format: URI}, message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("unwrapped key differs from plaintext key")
+	}
+
+	if MessageType(k) != MessageKeyProvider {
+		t.Errorf("unexpected message type %v", MessageType(k))
+	}
+
+	if err := CheckKeyURI("unknown://a"); !errors.Is(err, ErrUnknownKeyProvider) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownKeyProvider)
+	}
+	if _, err := KeyInfoFromMessage([]byte("not a message")); err == nil {
+		t.Errorf("unexpected success decoding invalid message")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	const token = "s.token"
+
+	useragent.InitValue("singularity", "3.0.0-alpha.1-303-gaed8d30-dirty")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var data map[string]string
+		switch r.URL.Path {
+		case "/v1/secret/transit/encrypt/my-key":
+			data = map[string]string{"ciphertext": "vault:v1:" + in["plaintext"]}
+		case "/v1/secret/transit/decrypt/my-key":
+			data = map[string]string{"plaintext": in["ciphertext"][len("vault:v1:"):]}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer srv.Close()
+
+	t.Setenv(vaultAddrEnv, srv.URL)
+	t.Setenv(vaultTokenEnv, token)
+
+	plaintext := []byte("plaintext key")
+	k := KeyInfo{Format: URI, Path: "vault://secret/transit/my-key"}
+
+	message, err := EncryptKey(k, plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, wrapped, err := decodeWrappedKey(message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "vault:v1:" + base64.StdEncoding.EncodeToString(plaintext); string(wrapped) != want {
+		t.Errorf("got wrapped key %q, want %q", wrapped, want)
+	}
+
+	got, err := PlaintextKeyFromMessage(k, message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("got unwrapped key %q, want %q", got, plaintext)
+	}
+
+	if _, err := EncryptKey(KeyInfo{Format: URI, Path: "vault://secret/transit/other-key"}, plaintext); err == nil {
+		t.Errorf("unexpected success with unknown key")
+	}
+	if _, err := EncryptKey(KeyInfo{Format: URI, Path: "vault://my-key"}, plaintext); err == nil {
+		t.Errorf("unexpected success without mount")
+	}
+
+	t.Setenv(vaultTokenEnv, "s.invalid")
+	if _, err := PlaintextKeyFromMessage(k, message); err == nil {
+		t.Errorf("unexpected success with invalid token")
+	}
+}
+
+func TestKMSHelperProvider(t *testing.T) {
+	dir := t.TempDir()
+	// The helper wraps keys by substituting characters, and checks the key ID.
+	helper := `#!/bin/sh
+[ "$2" = "alias/my-key" ] || { echo "unknown key $2" >&2; exit 1; }
+case "$1" in
+wrap) tr abc xyz ;;
+unwrap) tr xyz abc ;;
+*) exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, kmsHelperPrefix+"test"), []byte(helper), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+
+	plaintext := []byte("abc")
+	k := KeyInfo{Format: URI, Path: "kms://test/alias/my-key"}
+
+	message, err := EncryptKey(k, plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, wrapped, err := decodeWrappedKey(message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(wrapped) != "xyz" {
+		t.Errorf("got wrapped key %q, want %q", wrapped, "xyz")
+	}
+
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr bool
+	}{
+		{name: "Valid", uri: "kms://test/alias/my-key"},
+		{name: "UnknownKey", uri: "kms://test/alias/other-key", wantErr: true},
+		{name: "UnknownHelper", uri: "kms://unknown/alias/my-key", wantErr: true},
+		{name: "InvalidHelper", uri: "kms://..%2Ftest/alias/my-key", wantErr: true},
+		{name: "NoKeyID", uri: "kms://test", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlaintextKeyFromMessage(KeyInfo{Format: URI, Path: tt.uri}, message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, plaintext) {
+				t.Errorf("got unwrapped key %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
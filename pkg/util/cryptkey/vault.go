@@ -0,0 +1,186 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cryptkey
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/v4/internal/pkg/util/fips"
+	"github.com/sylabs/singularity/v4/pkg/util/singularityconf"
+	useragent "github.com/sylabs/singularity/v4/pkg/util/user-agent"
+)
+
+// VaultScheme is the key URI scheme of keys held by the transit secrets
+// engine of Hashicorp Vault, e.g. vault://transit/my-key for the key my-key of
+// the engine mounted at transit/.
+const VaultScheme = "vault"
+
+const (
+	vaultAddrEnv      = "VAULT_ADDR"
+	vaultTokenEnv     = "VAULT_TOKEN"
+	vaultNamespaceEnv = "VAULT_NAMESPACE"
+	vaultCACertEnv    = "VAULT_CACERT"
+	vaultTokenFile    = ".vault-token"
+)
+
+func init() {
+	RegisterKeyProvider(VaultScheme, vaultProvider{})
+}
+
+// vaultProvider wraps keys with the transit secrets engine of Hashicorp
+// Vault. The Vault server and credentials are taken from the environment
+// variables used by the vault CLI: VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE
+// and VAULT_CACERT. The server defaults to the 'vault address' directive of
+// singularity.conf, and the token to the content of ~/.vault-token.
+type vaultProvider struct{}
+
+func (vaultProvider) Wrap(ctx context.Context, u *url.URL, plaintext []byte) ([]byte, error) {
+	var res struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	in := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := vaultTransit(ctx, u, "encrypt", in, &res); err != nil {
+		return nil, err
+	}
+	return []byte(res.Ciphertext), nil
+}
+
+func (vaultProvider) Unwrap(ctx context.Context, u *url.URL, wrapped []byte) ([]byte, error) {
+	var res struct {
+		Plaintext string `json:"plaintext"`
+	}
+	in := map[string]string{"ciphertext": string(wrapped)}
+	if err := vaultTransit(ctx, u, "decrypt", in, &res); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(res.Plaintext)
+}
+
+// vaultAddress returns the address of the Vault server.
+func vaultAddress() (string, error) {
+	if addr := os.Getenv(vaultAddrEnv); addr != "" {
+		return addr, nil
+	}
+	if c := singularityconf.GetCurrentConfig(); c != nil && c.VaultAddress != "" {
+		return c.VaultAddress, nil
+	}
+	return "", fmt.Errorf("no Vault server address, set %s or 'vault address' in singularity.conf", vaultAddrEnv)
+}
+
+// vaultToken returns the token used to authenticate to the Vault server.
+func vaultToken() (string, error) {
+	if token := os.Getenv(vaultTokenEnv); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(filepath.Join(home, vaultTokenFile))
+	if err != nil {
+		return "", fmt.Errorf("no Vault token, set %s or log in with the vault CLI: %w", vaultTokenEnv, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// vaultClient returns the HTTP client used to access the Vault server.
+func vaultClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca := os.Getenv(vaultCACertEnv); ca != "" {
+		b, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("while reading Vault CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificate found in %s", ca)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: fips.TLSConfig(tlsConfig),
+		},
+	}, nil
+}
+
+// vaultTransit calls the op (encrypt / decrypt) endpoint of the transit
+// secrets engine for the key at u, with request body in, and decodes the data
+// of the response into out.
+func vaultTransit(ctx context.Context, u *url.URL, op string, in, out any) error {
+	mount, key := path.Split(strings.Trim(u.Host+u.Path, "/"))
+	mount = strings.Trim(mount, "/")
+	if mount == "" || key == "" {
+		return fmt.Errorf("invalid Vault key URI %s, expected %s://<mount>/<key>", u, VaultScheme)
+	}
+
+	addr, err := vaultAddress()
+	if err != nil {
+		return err
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return err
+	}
+	client, err := vaultClient()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(addr, "/") + "/v1/" + mount + "/" + op + "/" + url.PathEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv(vaultNamespaceEnv); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", useragent.Value())
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var apiErr struct {
+			Errors []string `json:"errors"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err == nil && len(apiErr.Errors) > 0 {
+			return fmt.Errorf("vault %s: %s", op, strings.Join(apiErr.Errors, "; "))
+		}
+		return fmt.Errorf("vault %s: %s", op, res.Status)
+	}
+
+	data := struct {
+		Data any `json:"data"`
+	}{Data: out}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return fmt.Errorf("while decoding vault %s response: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularityconf
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BindPathUser holds the identity of the user running a container, which
+// 'bind path' and 'required binds' templates are expanded for.
+type BindPathUser struct {
+	Username string
+	UID      int
+	Group    string
+	GID      int
+}
+
+// ExpandBindPaths returns paths with the {username}, {uid}, {group}, and
+// {gid} templates replaced by the identity of u.
+func ExpandBindPaths(paths []string, u BindPathUser) []string {
+	r := strings.NewReplacer(
+		"{username}", u.Username,
+		"{uid}", strconv.Itoa(u.UID),
+		"{group}", u.Group,
+		"{gid}", strconv.Itoa(u.GID),
+	)
+	expanded := make([]string, 0, len(paths))
+	for _, p := range paths {
+		expanded = append(expanded, r.Replace(p))
+	}
+	return expanded
+}
+
+// CheckRequiredBinds returns an error if the source of any of binds, the
+// expanded 'required binds' entries, does not exist on the host.
+func CheckRequiredBinds(binds []string) error {
+	for _, b := range binds {
+		src, _, _ := strings.Cut(b, ":")
+		if _, err := os.Stat(src); err != nil {
+			return fmt.Errorf("required bind %s from singularity.conf is not available: %w", src, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2023, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularityconf
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandBindPaths(t *testing.T) {
+	u := BindPathUser{
+		Username: "alice",
+		UID:      1000,
+		Group:    "physics",
+		GID:      2000,
+	}
+	paths := []string{
+		"/opt",
+		"/scratch/{username}",
+		"/projects/{group}:/projects",
+		"/run/user/{uid}:/run/user/{uid}:ro",
+		"/data/{gid}/{username}",
+	}
+	want := []string{
+		"/opt",
+		"/scratch/alice",
+		"/projects/physics:/projects",
+		"/run/user/1000:/run/user/1000:ro",
+		"/data/2000/alice",
+	}
+
+	if got := ExpandBindPaths(paths, u); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected expanded paths: got %v, want %v", got, want)
+	}
+}
+
+func TestCheckRequiredBinds(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		binds   []string
+		wantErr bool
+	}{
+		{
+			name: "None",
+		},
+		{
+			name:  "Exists",
+			binds: []string{dir, dir + ":/data:ro"},
+		},
+		{
+			name:    "Missing",
+			binds:   []string{dir, filepath.Join(dir, "missing") + ":/data"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRequiredBinds(tt.binds)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
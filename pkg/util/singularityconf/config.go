@@ -28,9 +28,12 @@ type File struct {
 	ConfigGroup             bool     `default:"yes" authorized:"yes,no" directive:"config group"`
 	ConfigResolvConf        bool     `default:"yes" authorized:"yes,no" directive:"config resolv_conf"`
 	MountProc               bool     `default:"yes" authorized:"yes,no" directive:"mount proc"`
+	LxcfsPath               string   `default:"/var/lib/lxcfs" directive:"lxcfs path"`
 	MountSys                bool     `default:"yes" authorized:"yes,no" directive:"mount sys"`
 	MountDevPts             bool     `default:"yes" authorized:"yes,no" directive:"mount devpts"`
 	MountHome               bool     `default:"yes" authorized:"yes,no" directive:"mount home"`
+	HomeMode                string   `authorized:"host,ephemeral,image,none" directive:"home mode"`
+	AllowHomeModes          []string `directive:"allow home modes"`
 	MountTmp                bool     `default:"yes" authorized:"yes,no" directive:"mount tmp"`
 	MountHostfs             bool     `default:"no" authorized:"yes,no" directive:"mount hostfs"`
 	UserBindControl         bool     `default:"yes" authorized:"yes,no" directive:"user bind control"`
@@ -50,9 +53,14 @@ type File struct {
 	SharedLoopDevices       bool     `default:"no" authorized:"yes,no" directive:"shared loop devices"`
 	MaxLoopDevices          uint     `default:"256" directive:"max loop devices"`
 	SessiondirMaxSize       uint     `default:"64" directive:"sessiondir max size"`
+	WritableTmpfsSize       uint     `default:"0" directive:"writable tmpfs size"`
+	TmpSize                 uint     `default:"0" directive:"tmp size"`
+	ShmSize                 uint     `default:"0" directive:"shm size"`
 	MountDev                string   `default:"yes" authorized:"yes,no,minimal" directive:"mount dev"`
 	EnableOverlay           string   `default:"try" authorized:"yes,no,try" directive:"enable overlay"`
 	BindPath                []string `default:"/etc/localtime,/etc/hosts" directive:"bind path"`
+	RequiredBinds           []string `directive:"required binds"`
+	CVMFSRepos              []string `directive:"cvmfs repos"`
 	LimitContainerOwners    []string `directive:"limit container owners"`
 	LimitContainerGroups    []string `directive:"limit container groups"`
 	LimitContainerPaths     []string `directive:"limit container paths"`
@@ -63,6 +71,8 @@ type File struct {
 	MemoryFSType            string   `default:"tmpfs" authorized:"tmpfs,ramfs" directive:"memory fs type"`
 	CniConfPath             string   `directive:"cni configuration path"`
 	CniPluginPath           string   `directive:"cni plugin path"`
+	NetworkLimitRate        string   `directive:"network limit rate"`
+	NetworkEgressAllow      []string `directive:"network egress allow"`
 	CryptsetupPath          string   `directive:"cryptsetup path"`
 	GoPath                  string   `directive:"go path"`
 	LdconfigPath            string   `directive:"ldconfig path"`
@@ -74,10 +84,30 @@ type File struct {
 	DownloadConcurrency     uint     `default:"3" directive:"download concurrency"`
 	DownloadPartSize        uint     `default:"5242880" directive:"download part size"`
 	DownloadBufferSize      uint     `default:"32768" directive:"download buffer size"`
+	UploadConcurrency       uint     `default:"4" directive:"upload concurrency"`
 	SystemdCgroups          bool     `default:"yes" authorized:"yes,no" directive:"systemd cgroups"`
+	BatchJobCgroup          bool     `default:"no" authorized:"yes,no" directive:"batch job cgroup"`
+	BatchJobEnv             []string `directive:"batch job env"`
 	SIFFUSE                 bool     `default:"no" authorized:"yes,no" directive:"sif fuse"`
+	SquashfuseThreads       uint     `default:"0" directive:"squashfuse threads"`
+	SquashfuseCacheSize     uint     `default:"0" directive:"squashfuse cache size"`
+	ParallelMounts          bool     `default:"yes" authorized:"yes,no" directive:"parallel mounts"`
 	OCIMode                 bool     `default:"no" authorized:"yes,no" directive:"oci mode"`
 	TmpSandboxAllowed       bool     `default:"yes" authorized:"yes,no" directive:"tmp sandbox"`
+	OCIVolumes              string   `default:"tmpfs" authorized:"tmpfs,persistent,none" directive:"oci volumes"`
+	OCIWritableTmpfs        bool     `default:"yes" authorized:"yes,no" directive:"oci writable tmpfs"`
+	OCISIFVerifyKey         string   `directive:"oci-sif verify key"`
+	OCISIFKernelMount       bool     `default:"yes" authorized:"yes,no" directive:"oci-sif kernel mount"`
+	OCISeccompProfile       string   `default:"default" directive:"oci seccomp profile"`
+	OCIHooksDir             string   `directive:"oci hooks dir"`
+	ImageAdvisoryPolicy     string   `default:"warn" authorized:"warn,block,ignore" directive:"image advisory policy"`
+	LicenseGroups           []string `directive:"image license groups"`
+	LicenseOverrideGroups   []string `directive:"image license override groups"`
+	Landlock                bool     `default:"no" authorized:"yes,no" directive:"landlock"`
+	FIPSMode                bool     `default:"no" authorized:"yes,no" directive:"fips mode"`
+	EncryptionKeyURI        string   `directive:"encryption key uri"`
+	VaultAddress            string   `directive:"vault address"`
+	ProjectRemotePaths      []string `directive:"project remote paths"`
 }
 
 const TemplateAsset = `# SINGULARITY.CONF
@@ -106,6 +136,150 @@ allow setuid = {{ if eq .AllowSetuid true }}yes{{ else }}no{{ end }}
 # subuid / subgid mappings.
 oci mode = {{ if eq .OCIMode true }}yes{{ else }}no{{ end }}
 
+# OCI VOLUMES: [tmpfs/persistent/none]
+# DEFAULT: tmpfs
+# How VOLUMEs declared in the config of an OCI image are provided, in OCI mode.
+# tmpfs: each volume is a new, empty, writable tmpfs.
+# persistent: each volume is a directory under ~/.singularity/volumes, kept
+#   between runs of the same image. It is populated from the image on creation.
+# none: volumes are not created, and writes go to the container rootfs.
+# Can be overridden with the --volume-policy flag.
+oci volumes = {{ .OCIVolumes }}
+
+# OCI WRITABLE TMPFS: [BOOL]
+# DEFAULT: yes
+# Should containers run in OCI mode have a writable tmpfs overlay on their
+# rootfs by default, as with --writable-tmpfs? If set to no, the rootfs is
+# read-only unless --writable-tmpfs is specified. Users can always request a
+# read-only rootfs with --read-only.
+oci writable tmpfs = {{ if eq .OCIWritableTmpfs true }}yes{{ else }}no{{ end }}
+
+# OCI-SIF VERIFY KEY: [STRING]
+# DEFAULT: Undefined
+# Path to a PEM formatted public key. When set, OCI-SIF images must hold a
+# valid cosign compatible signature, as created with 'singularity sign --key',
+# for this key in order to be run.
+#oci-sif verify key =
+{{ if ne .OCISIFVerifyKey "" }}oci-sif verify key = {{ .OCISIFVerifyKey }}{{ end }}
+
+# OCI-SIF KERNEL MOUNT: [BOOL]
+# DEFAULT: yes
+# Should the squashfs layers of OCI-SIF images be mounted with the kernel
+# squashfs driver, through loop devices, as for native SIF images, when
+# singularity runs with root privileges? Kernel mounts perform better than the
+# squashfuse mounts, which are used otherwise, and as a fall-back if a kernel
+# mount fails. For containers run by unprivileged users, in a user namespace
+# where squashfs can't be mounted, the layers are mounted by the setuid
+# starter if 'allow setuid' and 'allow kernel squashfs' are enabled, and with
+# squashfuse otherwise.
+oci-sif kernel mount = {{ if eq .OCISIFKernelMount true }}yes{{ else }}no{{ end }}
+
+# FIPS MODE: [BOOL]
+# DEFAULT: no
+# Should we restrict signing, encryption, and TLS connections to keyservers,
+# libraries and registries to FIPS 140 approved algorithms? Signing and
+# encryption keys must be RSA keys of at least 2048 bits, or ECDSA keys on the
+# P-256, P-384 or P-521 curves. Encrypted images are formatted with the PBKDF2
+# key derivation function. Always enabled when SingularityCE is built with the
+# Go FIPS 140 module (mconfig --with-fips). The active crypto backend is
+# reported by 'singularity version --crypto'.
+fips mode = {{ if eq .FIPSMode true }}yes{{ else }}no{{ end }}
+
+# ENCRYPTION KEY URI: [STRING]
+# DEFAULT: Undefined
+# The URI of a key held by a key management service, used to encrypt
+# containers built or pulled with --encrypt when no other key material is
+# specified. The random key encrypting the container is wrapped with this key,
+# and recorded in the image with the URI, so that the container can be run
+# without specifying key material by users allowed to unwrap it. Supported
+# URIs are:
+#   vault://<mount>/<key>: a key of the Hashicorp Vault transit secrets engine
+#     mounted at <mount>. VAULT_TOKEN, or ~/.vault-token, holds the Vault token.
+#   kms://<helper>/<key-id>: a key accessed through the singularity-kms-<helper>
+#     program in $PATH, e.g. for a cloud KMS.
+#encryption key uri =
+{{ if ne .EncryptionKeyURI "" }}encryption key uri = {{ .EncryptionKeyURI }}{{ end }}
+
+# VAULT ADDRESS: [STRING]
+# DEFAULT: Undefined
+# The address of the Hashicorp Vault server for vault:// key URIs, when the
+# VAULT_ADDR environment variable is not set, e.g. https://vault.example.com:8200
+#vault address =
+{{ if ne .VaultAddress "" }}vault address = {{ .VaultAddress }}{{ end }}
+
+# PROJECT REMOTE PATHS: [STRING]
+# DEFAULT: NULL
+# Only honor per-project remote configuration files (.singularity-remote.yaml),
+# found in the working directory or its parents, that are located within an
+# allowed path prefix. A project file can select the remote endpoint, replace
+# its keyservers, and set the registry credentials file used by commands run
+# in the project. If this configuration is undefined (commented or set to
+# NULL), project files are ignored.
+#project remote paths = /home, /projects
+{{ range $index, $path := .ProjectRemotePaths }}
+{{- if eq $index 0 }}project remote paths = {{ else }}, {{ end }}{{$path}}
+{{- end }}
+
+# OCI SECCOMP PROFILE: [STRING]
+# DEFAULT: default
+# The seccomp profile applied to containers in OCI mode, unless another is
+# requested with --seccomp-profile or --security seccomp:<profile>.
+# default: the profile installed as seccomp-profiles/default.json in the
+#   singularity configuration directory.
+# unconfined: no seccomp profile is applied.
+# Otherwise, the absolute path of a JSON seccomp profile.
+oci seccomp profile = {{ .OCISeccompProfile }}
+
+# OCI HOOKS DIR: [STRING]
+# DEFAULT: Undefined
+# Directory holding OCI runtime hook definitions, in the oci-hooks(5) JSON
+# format also used by Podman and CRI-O. In OCI mode, hooks whose 'when'
+# conditions match the container are added to its runtime spec, for the
+# prestart, createRuntime, createContainer, startContainer, poststart and
+# poststop stages. Hooks are run by the OCI runtime, with the privileges of the
+# user running the container.
+#oci hooks dir =
+{{ if ne .OCIHooksDir "" }}oci hooks dir = {{ .OCIHooksDir }}{{ end }}
+
+# IMAGE ADVISORY POLICY: [warn/block/ignore]
+# DEFAULT: warn
+# How advisories attached to an image are handled when it is pulled or run.
+# Advisories are read from the org.sylabs.advisory.eol (end-of-life date),
+# org.sylabs.advisory.cves (comma separated CVE list), and
+# org.sylabs.advisory.notice annotations of an OCI-SIF image manifest, as may
+# be added by a registry, or from the same image labels.
+# warn: advisories are displayed as warnings.
+# block: advisories are displayed, and images past their end-of-life date, or
+#   affected by listed CVEs, are refused.
+# ignore: advisories are not displayed.
+image advisory policy = {{ .ImageAdvisoryPolicy }}
+
+# IMAGE LICENSE GROUPS: [STRING]
+# DEFAULT: Undefined
+# Restrict the launch of images declaring a license, in the
+# org.opencontainers.image.licenses annotation of an OCI-SIF image manifest, or
+# the same image label, to members of entitled groups. Each entry is of the
+# form <license>:<group>, where <license> is an SPDX license identifier, such
+# as LicenseRef-Acme-Commercial, and <group> is a group name or GID. Repeat the
+# directive to entitle more than one group to a license. Images declaring other
+# licenses are not restricted.
+#image license groups = LicenseRef-Acme-Commercial:acme-users
+{{ range $entry := .LicenseGroups }}
+{{- if ne $entry "" -}}
+image license groups = {{$entry}}
+{{ end -}}
+{{ end }}
+# IMAGE LICENSE OVERRIDE GROUPS: [STRING]
+# DEFAULT: Undefined
+# Members of these groups (names or GIDs) may launch images they are not
+# entitled to by 'image license groups', by stating a reason with the
+# --license-override flag. Each override is recorded, with the user, image,
+# and reason, to syslog (auth facility) as an audit trail.
+#image license override groups = wheel
+{{ range $index, $group := .LicenseOverrideGroups }}
+{{- if eq $index 0 }}image license override groups = {{ else }}, {{ end }}{{$group}}
+{{- end }}
+
 # MAX LOOP DEVICES: [INT]
 # DEFAULT: 256
 # Set the maximum number of loop devices that Singularity should ever attempt
@@ -143,6 +317,14 @@ config resolv_conf = {{ if eq .ConfigResolvConf true }}yes{{ else }}no{{ end }}
 # Should we automatically bind mount /proc within the container?
 mount proc = {{ if eq .MountProc true }}yes{{ else }}no{{ end }}
 
+# LXCFS PATH: [STRING]
+# DEFAULT: /var/lib/lxcfs
+# Where LXCFS is mounted, if installed. With --virtual-proc, its /proc files
+# (cpuinfo, meminfo, uptime etc.) are bound into the container, reflecting the
+# container's cgroup limits. Without LXCFS, static cpuinfo and meminfo files are
+# generated from the requested limits.
+lxcfs path = {{ .LxcfsPath }}
+
 # MOUNT SYS: [BOOL]
 # DEFAULT: yes
 # Should we automatically bind mount /sys within the container?
@@ -176,6 +358,30 @@ mount devpts = {{ if eq .MountDevPts true }}yes{{ else }}no{{ end }}
 # environment variables (or their corresponding command line options).
 mount home = {{ if eq .MountHome true }}yes{{ else }}no{{ end }}
 
+# HOME MODE: [host/ephemeral/image/none]
+# DEFAULT: Undefined
+# How the home directory is provided in containers, unless the --home-mode flag
+# is used.
+# host: the home directory is bound from the host.
+# ephemeral: the home directory is an empty tmpfs, discarded on exit.
+# image: the home directory of the image is an overlay, with changes persisted
+#   in the writable overlay partition embedded in the SIF image. The rest of
+#   the image stays read-only. Native mode only.
+# none: no home directory is provided.
+# If undefined, the home directory is bound from the host in native mode, and
+# ephemeral in OCI mode, unless --no-compat is used.
+#home mode = host
+{{ if ne .HomeMode "" }}home mode = {{ .HomeMode }}{{ end }}
+
+# ALLOW HOME MODES: [STRING]
+# DEFAULT: Undefined
+# Comma separated list of the home modes users can select with --home-mode, or
+# that can be set by 'home mode'. If undefined, all modes are allowed.
+#allow home modes = ephemeral, none
+{{ range $index, $mode := .AllowHomeModes }}
+{{- if eq $index 0 }}allow home modes = {{ else }}, {{ end }}{{$mode}}
+{{- end }}
+
 # MOUNT TMP: [BOOL]
 # DEFAULT: yes
 # Should we automatically bind mount /tmp and /var/tmp into the container? If
@@ -205,11 +411,42 @@ mount hostfs = {{ if eq .MountHostfs true }}yes{{ else }}no{{ end }}
 #bind path = /etc/singularity/default-nsswitch.conf:/etc/nsswitch.conf
 #bind path = /opt
 #bind path = /scratch
+#
+# The {username}, {uid}, {group}, and {gid} templates in a path are replaced by
+# the name and ID of the host user running the container, and of their primary
+# group, also when the container is run with --fakeroot.
+#bind path = /scratch/{username}
 {{ range $path := .BindPath }}
 {{- if ne $path "" -}}
 bind path = {{$path}}
 {{ end -}}
 {{ end }}
+
+# REQUIRED BINDS: [STRING]
+# DEFAULT: Undefined
+# Define a list of files/directories that must be bound into every container,
+# in the same format and with the same templates as 'bind path'. Unlike
+# 'bind path', these are also bound with --contain, cannot be disabled with
+# --no-mount, and are bound in OCI mode without --no-compat. A container fails
+# to start if the source of a required bind does not exist on the host.
+#required binds = /scratch/{username}
+#required binds = /projects/{group}:/projects
+{{ range $path := .RequiredBinds }}
+{{- if ne $path "" -}}
+required binds = {{$path}}
+{{ end -}}
+{{ end }}
+
+# CVMFS REPOS: [STRING]
+# DEFAULT: NULL
+# CVMFS repositories, under /cvmfs on the host, to bind read-only into every
+# container, in addition to those requested with --cvmfs. Repositories that are
+# not available are skipped with a warning. As other user binds, they are not
+# mounted if user bind control is disabled.
+#cvmfs repos = atlas.cern.ch, sft.cern.ch
+{{ range $index, $repo := .CVMFSRepos }}
+{{- if eq $index 0 }}cvmfs repos = {{ else }}, {{ end }}{{$repo}}
+{{- end }}
 # USER BIND CONTROL: [BOOL]
 # DEFAULT: yes
 # Allow users to influence and/or define bind points at runtime? This will allow
@@ -244,6 +481,18 @@ enable underlay = {{ if eq .EnableUnderlay true }}yes{{ else }}no{{ end }}
 # show up in the container.
 mount slave = {{ if eq .MountSlave true }}yes{{ else }}no{{ end }}
 
+# LANDLOCK: [BOOL]
+# DEFAULT: no
+# Should we restrict the filesystem access of container processes with the
+# Landlock LSM, as with the --security landlock option, by default? The
+# container rootfs and read-only binds are readable only, and writes are
+# limited to writable binds, home, /tmp, /var/tmp, /dev, and /proc, even if
+# other mounts, such as autofs mounts, propagate into the container. Such
+# mounts can't be read if they are on top-level directories. Requires
+# Linux >= 5.13 with Landlock enabled. Not supported in OCI mode, which fails
+# to run containers if this is set.
+landlock = {{ if eq .Landlock true }}yes{{ else }}no{{ end }}
+
 # SESSIONDIR MAXSIZE: [STRING]
 # DEFAULT: 64
 # This specifies how large the default tmpfs sessiondir should be (in MB).
@@ -252,6 +501,32 @@ mount slave = {{ if eq .MountSlave true }}yes{{ else }}no{{ end }}
 # In --oci mode, each tmpfs mount in the container can be up to this size.
 sessiondir max size = {{ .SessiondirMaxSize }}
 
+# WRITABLE TMPFS SIZE: [UINT]
+# DEFAULT: 0
+# How large, in MiB, the tmpfs holding ephemeral changes made with
+# --writable-tmpfs can be. In native mode, it sets the size of the sessiondir
+# of containers run with --writable-tmpfs. 0 uses the sessiondir max size.
+# Can be overridden with the --writable-tmpfs-size flag.
+writable tmpfs size = {{ .WritableTmpfsSize }}
+
+# TMP SIZE: [UINT]
+# DEFAULT: 0
+# How large, in MiB, the tmpfs mounted on /tmp and /var/tmp can be, when they
+# are not shared with the host: in OCI mode, or with --contain in native mode,
+# unless --workdir is used. If 0, they are limited to the sessiondir max size in
+# OCI mode, and share the sessiondir in native mode.
+# Can be overridden with the --tmp-size flag.
+tmp size = {{ .TmpSize }}
+
+# SHM SIZE: [UINT]
+# DEFAULT: 0
+# How large, in MiB, the tmpfs mounted on /dev/shm can be, when /dev is not
+# shared with the host: in OCI mode, or with --contain or 'mount dev = minimal'
+# in native mode. If 0, it is limited to the sessiondir max size in OCI mode,
+# and to the kernel default in native mode.
+# Can be overridden with the --shm-size flag.
+shm size = {{ .ShmSize }}
+
 # *****************************************************************************
 # WARNING
 #
@@ -425,6 +700,25 @@ memory fs type = {{ .MemoryFSType }}
 #cni plugin path =
 {{ if ne .CniPluginPath "" }}cni plugin path = {{ .CniPluginPath }}{{ end }}
 
+# NETWORK LIMIT RATE: [STRING]
+# DEFAULT: Undefined
+# Limits the rate of traffic sent from each interface of a container using a
+# CNI network (--network), e.g. 100mbit. Applied with tc on the host side of
+# each veth interface, so it can't be removed from within the container. Root
+# may override this with --network-limit rate=<rate>.
+#network limit rate =
+{{ if ne .NetworkLimitRate "" }}network limit rate = {{ .NetworkLimitRate }}{{ end }}
+
+# NETWORK EGRESS ALLOW: [STRING]
+# DEFAULT: NULL
+# Restricts traffic sent from a container using a CNI network (--network) to the
+# listed networks (CIDRs), with nftables inside the container network namespace.
+# Root may override this with --network-limit egress=<cidr>.
+#network egress allow = 10.0.0.0/8, 192.168.0.0/16
+{{ range $index, $cidr := .NetworkEgressAllow }}
+{{- if eq $index 0 }}network egress allow = {{ else }}, {{ end }}{{$cidr}}
+{{- end }}
+
 # CRYPTSETUP PATH: [STRING]
 # DEFAULT: Undefined
 # Path to the cryptsetup executable, used to work with encrypted containers.
@@ -515,12 +809,38 @@ download part size = {{ .DownloadPartSize }}
 # are enabled.
 download buffer size = {{ .DownloadBufferSize }}
 
+# UPLOAD CONCURRENCY: [UINT]
+# DEFAULT: 4
+# This option specifies how many parts are uploaded concurrently when pushing
+# a large SIF image to a cloud library supporting multipart uploads.
+upload concurrency = {{ .UploadConcurrency }}
+
 # SYSTEMD CGROUPS: [BOOL]
 # DEFAULT: yes
 # Whether to use systemd to manage container cgroups. Required for rootless cgroups
 # functionality. 'no' will manage cgroups directly via cgroupfs.
 systemd cgroups = {{ if eq .SystemdCgroups true }}yes{{ else }}no{{ end }}
 
+# BATCH JOB CGROUP: [BOOL]
+# DEFAULT: no
+# When singularity is run inside the cgroup of a Slurm, LSF, or PBS job, keep
+# the container in the job cgroup rather than creating a new cgroup or systemd
+# scope for it, so that the job's accounting and resource limits apply to the
+# container. Resource limits requested with --apply-cgroups or the limit flags
+# are ignored with a warning in that case.
+batch job cgroup = {{ if eq .BatchJobCgroup true }}yes{{ else }}no{{ end }}
+
+# BATCH JOB ENV: [STRING]
+# DEFAULT: Undefined
+# Comma separated list of patterns of host environment variable names, set by
+# the batch scheduler, that are passed into the container even when the
+# environment is otherwise cleaned, with --cleanenv, or in OCI mode. Patterns
+# use shell glob syntax. Variables are not passed with --no-env.
+#batch job env = SLURM_*, PBS_*, LSB_*
+{{ range $index, $pattern := .BatchJobEnv }}
+{{- if eq $index 0 }}batch job env = {{ else }}, {{ end }}{{$pattern}}
+{{- end }}
+
 # SIF FUSE: [BOOL]
 # DEFAULT: no
 # EXPERIMENTAL
@@ -528,4 +848,29 @@ systemd cgroups = {{ if eq .SystemdCgroups true }}yes{{ else }}no{{ end }}
 # Applies only to unprivileged / user namespace flows. Requires squashfuse and
 # fusermount on PATH. Will fall back to extracting the SIF on failure.
 sif fuse = {{ if eq .SIFFUSE true }}yes{{ else }}no{{ end }}
+
+# SQUASHFUSE THREADS: [UINT]
+# DEFAULT: 0
+# Number of threads serving reads from SIF and OCI-SIF images mounted with
+# squashfuse, passed as '-o threads=N', or '-o max_threads=N' with libfuse 3.
+# Multiple threads improve read throughput for I/O heavy workloads, and
+# require a multithreaded squashfuse_ll. 0 uses the squashfuse default.
+# Ignored if the installed squashfuse does not support it.
+squashfuse threads = {{ .SquashfuseThreads }}
+
+# SQUASHFUSE CACHE SIZE: [UINT]
+# DEFAULT: 0
+# Size, in MiB, of the block cache of squashfuse for SIF and OCI-SIF image
+# mounts, passed as '-o cache_size=N'. 0 uses the squashfuse default.
+# Ignored if the installed squashfuse does not support it.
+squashfuse cache size = {{ .SquashfuseCacheSize }}
+
+# PARALLEL MOUNTS: [BOOL]
+# DEFAULT: yes
+# Should independent mounts be performed concurrently when setting up a
+# container, i.e. the squashfs layers of OCI-SIF images, overlay images and
+# directories, and bind mounts? This speeds up the start of containers with
+# many layers, overlays or binds. Mounts that depend on each other, as their
+# mountpoints are nested, are always performed in order.
+parallel mounts = {{ if eq .ParallelMounts true }}yes{{ else }}no{{ end }}
 `